@@ -9,6 +9,8 @@ import (
 	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/handler"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/server"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
 	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
 	"go.uber.org/zap"
@@ -40,20 +42,15 @@ func main() {
 	if cfg.App.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	r := gin.New()
-
-	// 添加中间件
-	r.Use(gin.Recovery())
-	r.Use(middleware.RequestIDMiddleware())
-	r.Use(middleware.LoggerMiddleware())
-	r.Use(middleware.CORSMiddleware())
+	// 路由与所有服务共用的中间件，包括 HTTP 指标与 /metrics
+	r := server.NewRouter()
 
 	// 初始化 Handler
 	agentHandler := handler.NewAgentHandler()
 
 	// 注册路由 - 所有接口都需要鉴权
 	api := r.Group("/api/v1")
-	api.Use(middleware.AuthMiddleware([]byte(cfg.JWT.Secret)))
+	api.Use(middleware.AuthMiddlewareWithCachedStatus([]byte(cfg.JWT.Secret), repository.NewUserRepository().FindStatus))
 	{
 		// 创建助手
 		api.POST("/agents", agentHandler.CreateAgent)
@@ -87,6 +84,11 @@ func main() {
 
 		// 删除助手
 		api.DELETE("/agents/:id", agentHandler.DeleteAgent)
+
+		// 系统提示词版本历史与回滚
+		api.GET("/agents/:id/prompt/versions", agentHandler.GetPromptVersions)
+		api.GET("/agents/:id/prompt/versions/:version", agentHandler.GetPromptVersion)
+		api.POST("/agents/:id/prompt/rollback", agentHandler.RollbackPrompt)
 	}
 
 	// 健康检查
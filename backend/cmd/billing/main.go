@@ -11,10 +11,15 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/handler"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/scaling"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/server"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
 )
 
@@ -33,17 +38,75 @@ func main() {
 	// 创建服务
 	billingService := service.NewAdvancedBillingService(database.DB)
 
+	// 异步计费：重试耗尽的事件写入 billing_dead_letters，管理员可重放或丢弃
+	billingEngine := billing.NewBillingEngine()
+	asyncBilling := billing.NewAsyncBillingService("billing", 10000)
+	asyncBilling.SetDeadLetterStore(service.NewBillingDeadLetterStore(repository.NewBillingDeadLetterRepository()))
+	// 按用户分组应用同名价格组的倍率，分组从 users 表查询
+	billingConsumer := billing.NewBillingConsumer("billing-consumer", asyncBilling.Queue(), billingEngine.GetQuotaManager(), billingEngine.GetPricingManager())
+	billingConsumer.SetGroupResolver(service.NewUserGroupResolver(repository.NewUserRepository()).Resolve)
+	// 结算后检查配额预警，按用户偏好通过 Webhook、邮件通知
+	alertService := service.NewQuotaAlertService(repository.NewAlertRepository())
+	if err := service.SetupAlertNotifications(billingEngine.GetAlertManager(), alertService, &cfg.Alert); err != nil {
+		log.Fatalf("Failed to set up quota alerts: %v", err)
+	}
+	defer billingEngine.GetAlertManager().WaitNotifications()
+	billingConsumer.SetAlertManager(billingEngine.GetAlertManager())
+
+	// 限时定价策略：按有效期自动切换活跃策略
+	billingEngine.GetPricingManager().StartStrategyScheduler(time.Minute)
+	defer billingEngine.GetPricingManager().StopStrategyScheduler()
+
 	// 创建处理器
 	billingHandler := handler.NewBillingHandler(billingService)
 
-	// 设置路由
-	router := gin.Default()
+	// 预付费充值：Stripe Checkout 支付完成后按发票入账额度
+	var invoiceHandler *handler.BillingInvoiceHandler
+	var autoRechargeHandler *handler.BillingAutoRechargeHandler
+	if cfg.Stripe.SecretKey != "" {
+		packages, err := service.ParseQuotaPackages(cfg.Stripe.Packages, cfg.Stripe.Currency)
+		if err != nil {
+			log.Fatalf("Invalid STRIPE_QUOTA_PACKAGES: %v", err)
+		}
+		stripeClient := service.NewStripeClient(cfg.Stripe.SecretKey, cfg.Stripe.WebhookSecret, cfg.Stripe.APIBase)
+		checkoutConfig := service.CheckoutConfig{SuccessURL: cfg.Stripe.SuccessURL, CancelURL: cfg.Stripe.CancelURL}
+		checkoutService := service.NewCheckoutService(
+			repository.NewRechargeInvoiceRepository(),
+			billingEngine.GetQuotaManager(),
+			stripeClient,
+			packages,
+			checkoutConfig,
+		)
+		invoiceHandler = handler.NewBillingInvoiceHandler(checkoutService)
+
+		// 自动充值：使用保存的支付方式离线扣款，扣款成功后才入账额度
+		autoRecharge := billing.NewAutoRechargeManager(billingEngine.GetQuotaManager())
+		autoRechargeService := service.NewAutoRechargeService(repository.NewAutoRechargeRepository(), autoRecharge, stripeClient, cfg.Stripe.Currency, checkoutConfig)
+		autoRecharge.SetPayment(service.NewStripePaymentClient(stripeClient), autoRechargeService, cfg.Stripe.Currency)
+		autoRecharge.SetAlertManager(billingEngine.GetAlertManager())
+		if n, err := autoRechargeService.LoadConfigs(context.Background()); err != nil {
+			log.Printf("Failed to load auto-recharge settings: %v", err)
+		} else {
+			log.Printf("Loaded %d auto-recharge settings", n)
+		}
+		checkoutService.SetSetupHandler(autoRechargeService.HandleSetupCompleted)
+		billingConsumer.SetAutoRecharge(autoRecharge)
+		autoRechargeHandler = handler.NewBillingAutoRechargeHandler(autoRechargeService)
+	} else {
+		log.Println("STRIPE_SECRET_KEY not set, prepaid recharge and auto-recharge disabled")
+	}
 
-	// 全局中间件
-	router.Use(gin.Recovery())
-	router.Use(middleware.RequestIDMiddleware())
-	router.Use(middleware.LoggerMiddleware())
-	router.Use(middleware.CORSMiddleware())
+	asyncBilling.AddConsumer(billingConsumer)
+	asyncBilling.Start()
+	defer asyncBilling.Stop()
+
+	// 设置路由，包含所有服务共用的中间件、HTTP 指标与 /metrics
+	router := server.NewRouter()
+
+	// 计费事件队列的积压深度随 /metrics 输出，也可通过 /internal/scaling-hints 查看
+	scalingRegistry := scaling.NewRegistry()
+	asyncBilling.Queue().RegisterScalingSignals(scalingRegistry, time.Duration(cfg.Scaling.BillingMaxEventAgeSecs)*time.Second)
+	handler.NewScalingHandler(scalingRegistry).RegisterRoutes(&router.RouterGroup)
 
 	// 健康检查
 	router.GET("/health", func(c *gin.Context) {
@@ -54,12 +117,21 @@ func main() {
 		})
 	})
 
+	// 内部接口（服务间签名校验）
+	internal := router.Group("/internal")
+	internal.Use(middleware.InternalAuthMiddleware(cfg.Internal.Secret))
+	handler.NewInternalQuotaHandler().RegisterRoutes(internal)
+
+	// Stripe Webhook（签名校验，不经过 JWT）
+	if invoiceHandler != nil {
+		invoiceHandler.RegisterWebhook(router)
+	}
+
 	// API路由
 	v1 := router.Group("/api/v1")
 	{
 		// 需要认证
-		authFactory := middleware.NewAuthFactory([]byte(cfg.JWT.Secret), nil)
-		v1.Use(authFactory.JWT())
+		v1.Use(middleware.AuthMiddlewareWithCachedStatus([]byte(cfg.JWT.Secret), repository.NewUserRepository().FindStatus))
 
 		// 计费相关
 		billing := v1.Group("/billing")
@@ -69,12 +141,40 @@ func main() {
 			billing.POST("/refund/:id", billingHandler.Refund)
 		}
 
+		// 按模型、天或渠道汇总的消费报表
+		handler.NewBillingUsageHandler(service.NewUsageReportService(repository.NewUnifiedLogRepository())).RegisterRoutes(v1)
+
+		// 配额预警历史与通知偏好
+		handler.NewBillingAlertHandler(alertService).RegisterRoutes(v1)
+
+		// 预付费充值发票
+		if invoiceHandler != nil {
+			invoiceHandler.RegisterRoutes(v1)
+		}
+
+		// 自动充值与保存的支付方式
+		if autoRechargeHandler != nil {
+			autoRechargeHandler.RegisterRoutes(v1)
+		}
+
 		// 配额相关
 		quota := v1.Group("/quota")
 		{
 			quota.GET("/logs", billingHandler.GetQuotaLogs)
 			quota.POST("/recharge", billingHandler.Recharge)
 		}
+
+		// 以下管理接口需要管理员角色
+		admin := v1.Group("", middleware.RoleMiddleware(model.UserRoleAdmin))
+
+		// 计费死信管理
+		handler.NewBillingDLQHandler(asyncBilling).RegisterRoutes(admin)
+
+		// 价格组管理
+		handler.NewBillingPriceGroupHandler(billingEngine.GetPricingManager()).RegisterRoutes(admin)
+
+		// 限时定价策略与价格预览
+		handler.NewBillingStrategyHandler(billingEngine.GetPricingManager()).RegisterRoutes(admin)
 	}
 
 	// 启动服务器
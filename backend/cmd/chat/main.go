@@ -3,15 +3,24 @@ package main
 import (
 	"fmt"
 	"log"
+	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/chat"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/handler"
 	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/scaling"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/server"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tracing"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
 	"go.uber.org/zap"
 )
@@ -29,11 +38,31 @@ func main() {
 	}
 	defer logger.Sync()
 
+	// 收到退出信号后等待进行中的请求完成，再停止后台任务并关闭数据库与 Redis
+	srv := server.New("Chat service", time.Duration(cfg.Shutdown.DrainTimeoutSeconds)*time.Second)
+
+	// 收到 SIGHUP 时重新加载配置，日志级别在运行中生效
+	srv.WatchConfig(cfg)
+
+	// 分布式追踪，关闭时最后导出剩余的 span
+	shutdownTracing, err := tracing.Setup("chat", &cfg.Tracing)
+	if err != nil {
+		logger.Fatal("Failed to set up tracing", zap.Error(err))
+	}
+	srv.OnClose(shutdownTracing)
+
 	// 初始化数据库
 	if err := database.InitPostgres(&cfg.Database, cfg.App.Env); err != nil {
 		logger.Fatal("Failed to init database", zap.Error(err))
 	}
-	defer database.Close()
+	srv.OnClose(database.Close)
+
+	// 初始化 Redis（用于广播余额变更，使网关的额度预检缓存失效；不可用时仅降级）
+	if err := database.InitRedis(&cfg.Redis); err != nil {
+		logger.Warn("Failed to init redis, balance change notifications disabled", zap.Error(err))
+	} else {
+		srv.OnClose(database.CloseRedis)
+	}
 
 	// 初始化 JWT
 	utils.InitJWT(&cfg.JWT)
@@ -42,20 +71,33 @@ func main() {
 	if cfg.App.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	r := gin.New()
-
-	// 添加中间件
-	r.Use(gin.Recovery())
-	r.Use(middleware.RequestIDMiddleware())
-	r.Use(middleware.LoggerMiddleware())
-	r.Use(middleware.CORSMiddleware())
+	// 路由与所有服务共用的中间件，包括 HTTP 指标与 /metrics
+	r := server.NewRouter()
 
 	// 初始化 Service
 	chatService := service.NewChatService()
 
+	// 对话超出模型上下文窗口时裁剪较早的消息
+	trimmer, err := service.NewContextTrimmer(cfg.Chat.ContextStrategy, cfg.Chat.ContextWindowSize)
+	if err != nil {
+		logger.Fatal("Invalid chat context strategy", zap.Error(err))
+	}
+	chatService.SetContextTrimming(trimmer, cfg.Chat.DefaultContextWindow, cfg.Chat.CompletionReserve)
+	chatService.SetTitleGeneration(cfg.Chat.TitleModel)
+	chatService.SetKnowledgeSearch(service.NewKBSearchClient(cfg.Services.KBServiceURL))
+
+	// 永久删除超过保留期的已删除会话
+	purger := service.NewSessionPurger(time.Duration(cfg.Chat.SessionRetentionDays)*24*time.Hour, time.Hour)
+	purger.Start()
+	srv.OnStop(purger.Stop)
+
+	// 扩缩容信号：正在输出的流式响应数
+	scalingRegistry := scaling.NewRegistry()
+	chatService.SetScalingSignals(scalingRegistry, cfg.Scaling.ChatMaxStreams)
+
 	// 注册路由 - 所有接口都需要鉴权
 	api := r.Group("/api/v1")
-	api.Use(middleware.AuthMiddleware([]byte(cfg.JWT.Secret)))
+	api.Use(middleware.AuthMiddlewareWithCachedStatus([]byte(cfg.JWT.Secret), repository.NewUserRepository().FindStatus))
 	{
 		// 创建会话
 		api.POST("/chat/sessions", func(c *gin.Context) {
@@ -76,15 +118,15 @@ func main() {
 			utils.Success(c, session, "会话创建成功")
 		})
 
-		// 获取会话列表
+		// 获取会话列表（state=active|archived|deleted，默认 active）
 		api.GET("/chat/sessions", func(c *gin.Context) {
 			userID := c.GetInt("user_id")
 			page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 			pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
 
-			sessions, total, err := chatService.GetUserSessions(c.Request.Context(), userID, page, pageSize)
+			sessions, total, err := chatService.GetUserSessions(c.Request.Context(), userID, c.Query("state"), page, pageSize)
 			if err != nil {
-				utils.InternalError(c, err.Error())
+				utils.RespondError(c, err)
 				return
 			}
 
@@ -140,6 +182,55 @@ func main() {
 			utils.Success(c, session, "更新成功")
 		})
 
+		// 置顶 / 取消置顶会话或调整排序
+		api.PATCH("/chat/sessions/:id", func(c *gin.Context) {
+			userID, ok := middleware.UserIDFromContext(c)
+			if !ok {
+				utils.Unauthorized(c, "未登录")
+				return
+			}
+			sessionID, err := uuid.Parse(c.Param("id"))
+			if err != nil {
+				utils.BadRequest(c, "Invalid session ID")
+				return
+			}
+
+			var req service.PatchSessionRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				utils.BadRequest(c, err.Error())
+				return
+			}
+
+			session, err := chatService.PatchSession(c.Request.Context(), userID, sessionID, &req)
+			if err != nil {
+				utils.InternalError(c, err.Error())
+				return
+			}
+
+			utils.Success(c, session, "更新成功")
+		})
+
+		// 批量调整会话排序
+		api.PUT("/chat/sessions/order", func(c *gin.Context) {
+			userID, ok := middleware.UserIDFromContext(c)
+			if !ok {
+				utils.Unauthorized(c, "未登录")
+				return
+			}
+
+			var req []service.SessionOrder
+			if err := c.ShouldBindJSON(&req); err != nil {
+				utils.BadRequest(c, err.Error())
+				return
+			}
+
+			if err := chatService.ReorderSessions(c.Request.Context(), userID, req); err != nil {
+				utils.RespondError(c, err)
+				return
+			}
+			utils.Success(c, nil, "更新成功")
+		})
+
 		// 删除会话
 		api.DELETE("/chat/sessions/:id", func(c *gin.Context) {
 			userID := c.GetInt("user_id")
@@ -157,6 +248,74 @@ func main() {
 			utils.Success(c, nil, "删除成功")
 		})
 
+		// 归档 / 取消归档会话
+		for path, archived := range map[string]bool{"archive": true, "unarchive": false} {
+			api.POST("/chat/sessions/:id/"+path, func(c *gin.Context) {
+				userID, ok := middleware.UserIDFromContext(c)
+				if !ok {
+					utils.Unauthorized(c, "未登录")
+					return
+				}
+				sessionID, err := uuid.Parse(c.Param("id"))
+				if err != nil {
+					utils.BadRequest(c, "Invalid session ID")
+					return
+				}
+
+				session, err := chatService.SetSessionArchived(c.Request.Context(), userID, sessionID, archived)
+				if err != nil {
+					utils.InternalError(c, err.Error())
+					return
+				}
+
+				utils.Success(c, session, "更新成功")
+			})
+		}
+
+		// 恢复已删除的会话及其消息
+		api.POST("/chat/sessions/:id/restore", func(c *gin.Context) {
+			userID, ok := middleware.UserIDFromContext(c)
+			if !ok {
+				utils.Unauthorized(c, "未登录")
+				return
+			}
+			sessionID, err := uuid.Parse(c.Param("id"))
+			if err != nil {
+				utils.BadRequest(c, "Invalid session ID")
+				return
+			}
+
+			session, err := chatService.RestoreSession(c.Request.Context(), userID, sessionID)
+			if err != nil {
+				utils.InternalError(c, err.Error())
+				return
+			}
+
+			utils.Success(c, session, "恢复成功")
+		})
+
+		// 获取会话的 Token 用量明细
+		api.GET("/chat/sessions/:id/usage", func(c *gin.Context) {
+			userID, ok := middleware.UserIDFromContext(c)
+			if !ok {
+				utils.Unauthorized(c, "未登录")
+				return
+			}
+			sessionID, err := uuid.Parse(c.Param("id"))
+			if err != nil {
+				utils.BadRequest(c, "Invalid session ID")
+				return
+			}
+
+			usage, err := chatService.GetSessionUsage(c.Request.Context(), sessionID, userID)
+			if err != nil {
+				utils.InternalError(c, err.Error())
+				return
+			}
+
+			utils.Success(c, usage, "")
+		})
+
 		// 获取会话的消息列表
 		api.GET("/chat/sessions/:id/messages", func(c *gin.Context) {
 			userID := c.GetInt("user_id")
@@ -169,9 +328,16 @@ func main() {
 			page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 			pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
 
-			messages, total, err := chatService.GetSessionMessages(c.Request.Context(), sessionID, userID, page, pageSize)
+			// ?branch= 返回分支视图：主线中截至分支父消息的消息，加上分支内的消息
+			var messages []*model.Message
+			var total int64
+			if branchID := c.Query("branch"); branchID != "" {
+				messages, total, err = chatService.GetBranchMessages(c.Request.Context(), sessionID, userID, branchID, page, pageSize)
+			} else {
+				messages, total, err = chatService.GetSessionMessages(c.Request.Context(), sessionID, userID, page, pageSize)
+			}
 			if err != nil {
-				utils.InternalError(c, err.Error())
+				utils.RespondError(c, err)
 				return
 			}
 
@@ -183,6 +349,85 @@ func main() {
 			}, "")
 		})
 
+		// 按内容搜索自己的历史消息
+		api.GET("/chat/search", func(c *gin.Context) {
+			userID, ok := middleware.UserIDFromContext(c)
+			if !ok {
+				utils.Unauthorized(c, "未登录")
+				return
+			}
+			page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+			pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+			hits, total, err := chatService.SearchMessages(c.Request.Context(), userID, c.Query("q"), page, pageSize)
+			if err != nil {
+				utils.RespondError(c, err)
+				return
+			}
+
+			utils.Success(c, gin.H{
+				"results":  hits,
+				"total":    total,
+				"page":     page,
+				"pageSize": pageSize,
+			}, "")
+		})
+
+		// 导出会话（format=markdown|json|html|csv，默认 markdown），内容分批读取并流式写出
+		api.GET("/chat/sessions/:id/export", func(c *gin.Context) {
+			userID, ok := middleware.UserIDFromContext(c)
+			if !ok {
+				utils.Unauthorized(c, "未登录")
+				return
+			}
+			sessionID, err := uuid.Parse(c.Param("id"))
+			if err != nil {
+				utils.BadRequest(c, "Invalid session ID")
+				return
+			}
+			format, err := chat.ParseExportFormat(c.Query("format"))
+			if err != nil {
+				utils.BadRequest(c, err.Error())
+				return
+			}
+
+			session, err := chatService.GetSessionByID(c.Request.Context(), sessionID, userID)
+			if err != nil {
+				utils.InternalError(c, err.Error())
+				return
+			}
+
+			c.Header("Content-Type", format.ContentType())
+			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"session-%s.%s\"", session.ID, format.Extension()))
+			c.Status(http.StatusOK)
+
+			// 响应头已发送，导出中途失败只能记录日志
+			if _, err := chatService.ExportSession(c.Request.Context(), session, format, c.Writer); err != nil {
+				logger.Error("session export failed", zap.String("session_id", session.ID.String()), zap.Error(err))
+			}
+		})
+
+		// 获取消息详情（管理员功能，包含生成该消息的中转请求分阶段耗时）
+		api.GET("/admin/chat/messages/:id", middleware.RoleMiddleware(model.UserRoleAdmin), func(c *gin.Context) {
+			messageID, err := uuid.Parse(c.Param("id"))
+			if err != nil {
+				utils.BadRequest(c, "Invalid message ID")
+				return
+			}
+
+			detail, err := chatService.GetMessageDetail(c.Request.Context(), messageID)
+			if err != nil {
+				utils.InternalError(c, err.Error())
+				return
+			}
+			if detail == nil {
+				utils.NotFound(c, "消息不存在")
+				return
+			}
+
+			utils.Success(c, detail, "")
+		})
+
 		// 发送消息（非流式）
 		api.POST("/chat/messages", func(c *gin.Context) {
 			userID := c.GetInt("user_id")
@@ -195,7 +440,7 @@ func main() {
 
 			message, err := chatService.SendMessage(c.Request.Context(), userID, &req)
 			if err != nil {
-				utils.InternalError(c, err.Error())
+				utils.RespondError(c, err)
 				return
 			}
 
@@ -220,10 +465,17 @@ func main() {
 
 			// 获取响应写入器
 			w := c.Writer
-
-			// 通过流式服务发送消息
-			if err := chatService.SendMessageStream(c.Request.Context(), userID, &req, w); err != nil {
-				logger.Error("stream error", zap.Error(err))
+			utils.WriteSSERequestID(c)
+
+			// 通过流式服务发送消息，服务关闭时中断输出，客户端收到 server_shutdown 事件后重新连接
+			ctx, stop := server.StreamContext(c.Request.Context())
+			defer stop()
+			if err := chatService.SendMessageStream(ctx, userID, &req, w); err != nil {
+				if server.Interrupted(ctx) {
+					server.WriteShutdownEvent(w)
+					return
+				}
+				logger.Ctx(c.Request.Context()).Error("stream error", zap.Error(err))
 				fmt.Fprintf(w, "event: error\n")
 				fmt.Fprintf(w, "data: %s\n\n", err.Error())
 				return
@@ -233,18 +485,141 @@ func main() {
 			fmt.Fprintf(w, "event: done\n")
 			fmt.Fprintf(w, "data: {\"status\":\"completed\"}\n\n")
 		})
+
+		// 重新生成回复（新回复保存在新分支中，原回复保留）；?stream=true 时以 SSE 流式输出
+		api.POST("/chat/messages/:id/regenerate", func(c *gin.Context) {
+			userID, ok := middleware.UserIDFromContext(c)
+			if !ok {
+				utils.Unauthorized(c, "未登录")
+				return
+			}
+			messageID, err := uuid.Parse(c.Param("id"))
+			if err != nil {
+				utils.BadRequest(c, "Invalid message ID")
+				return
+			}
+
+			if c.Query("stream") != "true" {
+				result, err := chatService.RegenerateMessage(c.Request.Context(), userID, messageID, nil)
+				if err != nil {
+					utils.RespondError(c, err)
+					return
+				}
+				utils.Success(c, result, "")
+				return
+			}
+
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Connection", "keep-alive")
+			c.Header("Transfer-Encoding", "chunked")
+
+			w := c.Writer
+			utils.WriteSSERequestID(c)
+			// 服务关闭时中断流式输出，客户端收到 server_shutdown 事件后重新连接
+			ctx, stop := server.StreamContext(c.Request.Context())
+			defer stop()
+			if _, err := chatService.RegenerateMessage(ctx, userID, messageID, w); err != nil {
+				if server.Interrupted(ctx) {
+					server.WriteShutdownEvent(w)
+					return
+				}
+				logger.Ctx(c.Request.Context()).Error("regenerate stream error", zap.Error(err))
+				fmt.Fprintf(w, "event: error\n")
+				fmt.Fprintf(w, "data: %s\n\n", err.Error())
+				return
+			}
+
+			fmt.Fprintf(w, "event: done\n")
+			fmt.Fprintf(w, "data: {\"status\":\"completed\"}\n\n")
+		})
+
+		// 编辑消息；?truncate_after=1 时作废该消息之后的消息
+		api.PUT("/chat/messages/:id", func(c *gin.Context) {
+			userID, ok := middleware.UserIDFromContext(c)
+			if !ok {
+				utils.Unauthorized(c, "未登录")
+				return
+			}
+			messageID, err := uuid.Parse(c.Param("id"))
+			if err != nil {
+				utils.BadRequest(c, "Invalid message ID")
+				return
+			}
+
+			var req service.EditMessageRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				utils.BadRequest(c, err.Error())
+				return
+			}
+			truncateAfter := c.Query("truncate_after") == "1" || c.Query("truncate_after") == "true"
+
+			result, err := chatService.EditMessage(c.Request.Context(), userID, messageID, &req, truncateAfter)
+			if err != nil {
+				utils.RespondError(c, err)
+				return
+			}
+
+			utils.Success(c, result, "更新成功")
+		})
+
+		// 获取消息的编辑历史（按编辑顺序）
+		api.GET("/chat/messages/:id/edits", func(c *gin.Context) {
+			userID, ok := middleware.UserIDFromContext(c)
+			if !ok {
+				utils.Unauthorized(c, "未登录")
+				return
+			}
+			messageID, err := uuid.Parse(c.Param("id"))
+			if err != nil {
+				utils.BadRequest(c, "Invalid message ID")
+				return
+			}
+
+			edits, err := chatService.GetMessageEdits(c.Request.Context(), userID, messageID)
+			if err != nil {
+				utils.RespondError(c, err)
+				return
+			}
+
+			utils.Success(c, gin.H{"edits": edits}, "")
+		})
+
+		// 列出消息的备选回复分支
+		api.GET("/chat/messages/:id/branches", func(c *gin.Context) {
+			userID, ok := middleware.UserIDFromContext(c)
+			if !ok {
+				utils.Unauthorized(c, "未登录")
+				return
+			}
+			messageID, err := uuid.Parse(c.Param("id"))
+			if err != nil {
+				utils.BadRequest(c, "Invalid message ID")
+				return
+			}
+
+			branches, err := chatService.ListMessageBranches(c.Request.Context(), userID, messageID)
+			if err != nil {
+				utils.RespondError(c, err)
+				return
+			}
+
+			utils.Success(c, gin.H{"branches": branches}, "")
+		})
 	}
 
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
+	handler.NewScalingHandler(scalingRegistry).RegisterRoutes(&r.RouterGroup)
+
+	// WebSocket 流式对话（令牌通过查询参数或第一个 auth 帧传递）
+	handler.NewChatWSHandler(chatService, []byte(cfg.JWT.Secret)).RegisterRoutes(r.Group("/api/v1"))
 
 	// 启动服务
 	port := 8082 // 对话服务端口
-	addr := fmt.Sprintf(":%d", port)
-	logger.Info("Chat service starting", zap.String("addr", addr))
-	if err := r.Run(addr); err != nil {
-		logger.Fatal("Failed to start server", zap.Error(err))
+	if err := srv.Run(fmt.Sprintf(":%d", port), r); err != nil {
+		logger.Fatal("Server exited with error", zap.Error(err))
 	}
 }
@@ -11,13 +11,60 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/handler"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/server"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/storage"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
 )
 
 func main() {
-	router := gin.Default()
+	// 加载配置
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	// 初始化日志
+	if err := logger.Init(cfg.App.Env); err != nil {
+		log.Fatal("Failed to init logger:", err)
+	}
+	defer logger.Sync()
+
+	// 初始化数据库
+	if err := database.InitPostgres(&cfg.Database, cfg.App.Env); err != nil {
+		logger.Fatal("Failed to init database", zap.Error(err))
+	}
+	defer database.Close()
 
-	// 全局中间件
-	router.Use(gin.Recovery())
+	// 初始化 JWT
+	utils.InitJWT(&cfg.JWT)
+
+	// 初始化对象存储
+	objects, err := newObjectStore(&cfg.File)
+	if err != nil {
+		logger.Fatal("Failed to init file storage", zap.Error(err))
+	}
+
+	fileService := service.NewFileService(
+		repository.NewFileRepository(),
+		objects,
+		int64(cfg.File.MaxUploadMB)<<20,
+		cfg.File.AllowedMIMETypes,
+	)
+	fileHandler := handler.NewFileHandler(fileService)
+
+	if cfg.App.Env == "production" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	// 路由与所有服务共用的中间件，包括 HTTP 指标与 /metrics
+	router := server.NewRouter()
 
 	// 健康检查
 	router.GET("/health", func(c *gin.Context) {
@@ -30,22 +77,8 @@ func main() {
 
 	// API路由
 	v1 := router.Group("/api/v1")
-	{
-		// 文件上传
-		v1.POST("/upload", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"message": "upload endpoint"})
-		})
-
-		// 文件下载
-		v1.GET("/download/:id", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"message": "download endpoint"})
-		})
-
-		// 文件列表
-		v1.GET("/files", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"message": "files list endpoint"})
-		})
-	}
+	v1.Use(middleware.AuthMiddlewareWithCachedStatus([]byte(cfg.JWT.Secret), repository.NewUserRepository().FindStatus))
+	fileHandler.RegisterRoutes(v1)
 
 	// 启动服务器
 	port := 8087
@@ -57,25 +90,44 @@ func main() {
 
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			logger.Fatal("Failed to start server", zap.Error(err))
 		}
 	}()
 
-	log.Printf("File service started on port %d", port)
+	logger.Info("File service started", zap.Int("port", port), zap.String("storage", cfg.File.StorageBackend))
 
 	// 等待中断信号
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	logger.Info("Shutting down server...")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
+		logger.Fatal("Server forced to shutdown", zap.Error(err))
 	}
 
-	log.Println("Server exited")
+	logger.Info("Server exited")
+}
+
+// newObjectStore 按配置创建本地或 S3 兼容的对象存储
+func newObjectStore(cfg *config.FileConfig) (storage.ObjectStore, error) {
+	switch cfg.StorageBackend {
+	case "", "local":
+		return storage.NewLocalObjectStore(cfg.StorageDir)
+	case "s3":
+		return storage.NewS3ObjectStore(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown file storage backend: %s", cfg.StorageBackend)
+	}
 }
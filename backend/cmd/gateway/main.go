@@ -1,11 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"net/url"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,6 +11,9 @@ import (
 	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
 	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/server"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tracing"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
 	"go.uber.org/zap"
 )
@@ -30,11 +31,24 @@ func main() {
 	}
 	defer logger.Sync()
 
+	// 收到退出信号后等待进行中的请求完成，再停止后台任务并关闭数据库与 Redis
+	srv := server.New("Gateway", time.Duration(cfg.Shutdown.DrainTimeoutSeconds)*time.Second)
+
+	// 收到 SIGHUP 时重新加载配置，日志级别与限流额度在运行中生效
+	reloader := srv.WatchConfig(cfg)
+
+	// 分布式追踪，关闭时最后导出剩余的 span
+	shutdownTracing, err := tracing.Setup("gateway", &cfg.Tracing)
+	if err != nil {
+		logger.Fatal("Failed to set up tracing", zap.Error(err))
+	}
+	srv.OnClose(shutdownTracing)
+
 	// 初始化 Redis（用于限流）
 	if err := database.InitRedis(&cfg.Redis); err != nil {
 		logger.Fatal("Failed to init redis", zap.Error(err))
 	}
-	defer database.CloseRedis()
+	srv.OnClose(database.CloseRedis)
 
 	// 初始化 JWT
 	utils.InitJWT(&cfg.JWT)
@@ -43,43 +57,77 @@ func main() {
 	if cfg.App.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	r := gin.New()
-
-	// 全局中间件
-	r.Use(gin.Recovery())
-	r.Use(middleware.RequestIDMiddleware())
-	r.Use(middleware.LoggerMiddleware())
-	r.Use(middleware.CORSMiddleware())
+	// 路由与所有服务共用的中间件，包括 HTTP 指标与 /metrics
+	r := server.NewRouter()
+
+	// 额度预检：转发对话请求前先询问计费服务，余额不足时直接返回 402
+	quotaCheck := func(c *gin.Context) { c.Next() }
+	if cfg.QuotaCheck.Enabled {
+		checker := middleware.NewQuotaChecker(middleware.QuotaCheckConfig{
+			BillingURL:    cfg.Services.BillingServiceURL,
+			Secret:        cfg.Internal.Secret,
+			Timeout:       time.Duration(cfg.QuotaCheck.TimeoutMs) * time.Millisecond,
+			FailOpen:      cfg.QuotaCheck.FailOpen,
+			CacheTTL:      time.Duration(cfg.QuotaCheck.CacheTTLSeconds) * time.Second,
+			EstimatedCost: float64(cfg.QuotaCheck.EstimatedCost),
+		})
+		stopListening := checker.ListenBalanceChanges(context.Background(), database.RedisClient)
+		srv.OnStop(stopListening)
+		quotaCheck = checker.Middleware()
+	}
 
 	// API 路由组
 	api := r.Group("/api/v1")
+	rateLimitWindow := time.Duration(cfg.RateLimit.WindowSeconds) * time.Second
 
 	// 公开接口（无需鉴权）
 	public := api.Group("")
-	public.Use(middleware.RateLimitMiddleware(&middleware.RateLimitConfig{
-		Rate:  10,
-		Burst: 20,
-		TTL:   time.Minute,
-	}))
+	publicLimit := &middleware.RateLimitConfig{
+		Limit:  cfg.RateLimit.PublicLimit,
+		Window: rateLimitWindow,
+	}
+	if cfg.RateLimit.Enabled {
+		public.Use(middleware.RateLimitMiddleware(publicLimit))
+	}
 	{
 		// 转发到用户服务
 		public.POST("/register", proxyToService(cfg.Services.UserServiceURL))
 		public.POST("/login", proxyToService(cfg.Services.UserServiceURL))
 		public.POST("/refresh", proxyToService(cfg.Services.UserServiceURL))
+		// 数据导出下载使用签名链接鉴权，归档可能较大，走无 30 秒超时的流式代理
+		public.GET("/user/export/:id/download", proxyToServiceSSE(cfg.Services.UserServiceURL))
+		// WebSocket 无法携带 Authorization 头，由对话服务校验查询参数或 auth 帧中的令牌
+		public.GET("/chat/ws", proxyToServiceWS(cfg.Services.ChatServiceURL))
 	}
 
 	// 需要鉴权的接口
 	protected := api.Group("")
 	protected.Use(middleware.AuthMiddleware([]byte(cfg.JWT.Secret)))
-	protected.Use(middleware.RateLimitMiddleware(&middleware.RateLimitConfig{
-		Rate:  100,
-		Burst: 200,
-		TTL:   time.Minute,
-	}))
+	userLimit := &middleware.RateLimitConfig{
+		Limit:      cfg.RateLimit.UserLimit,
+		Window:     rateLimitWindow,
+		KeyFunc:    middleware.TokenKeyFunc,
+		RoleLimits: cfg.RateLimit.RoleLimits,
+	}
+	if cfg.RateLimit.Enabled {
+		// 按用户或 API Token 计数，同一出口 IP 下的用户互不影响
+		protected.Use(middleware.RateLimitMiddleware(userLimit))
+	}
+	// 限流开关需要重启生效，额度与窗口在运行中更新
+	reloader.Subscribe("rate_limit", func(prev, next *config.Config) {
+		window := time.Duration(next.RateLimit.WindowSeconds) * time.Second
+		publicLimit.Update(next.RateLimit.PublicLimit, window, nil)
+		userLimit.Update(next.RateLimit.UserLimit, window, next.RateLimit.RoleLimits)
+	})
 	{
 		// 用户相关
 		protected.GET("/user/profile", proxyToService(cfg.Services.UserServiceURL))
 		protected.PUT("/user/profile", proxyToService(cfg.Services.UserServiceURL))
+		protected.POST("/user/export", proxyToService(cfg.Services.UserServiceURL))
+		protected.GET("/user/export/:id", proxyToService(cfg.Services.UserServiceURL))
+
+		// 接入引导页的 API 调用示例
+		protected.GET("/config/client-examples", proxyToService(cfg.Services.RelayServiceURL))
 
 		// 对话相关
 		protected.POST("/chat/sessions", proxyToService(cfg.Services.ChatServiceURL))
@@ -88,8 +136,8 @@ func main() {
 		protected.PUT("/chat/sessions/:id", proxyToService(cfg.Services.ChatServiceURL))
 		protected.DELETE("/chat/sessions/:id", proxyToService(cfg.Services.ChatServiceURL))
 		protected.GET("/chat/sessions/:id/messages", proxyToService(cfg.Services.ChatServiceURL))
-		protected.POST("/chat/messages", proxyToService(cfg.Services.ChatServiceURL))
-		protected.POST("/chat/messages/stream", proxyToServiceSSE(cfg.Services.ChatServiceURL))
+		protected.POST("/chat/messages", quotaCheck, proxyToService(cfg.Services.ChatServiceURL))
+		protected.POST("/chat/messages/stream", quotaCheck, proxyToServiceSSE(cfg.Services.ChatServiceURL))
 
 		// 计费相关（TODO: 当计费服务启动后启用）
 		// protected.GET("/billing/history", proxyToService(cfg.Services.BillingServiceURL))
@@ -97,12 +145,13 @@ func main() {
 		// protected.GET("/billing/invoices", proxyToService(cfg.Services.BillingServiceURL))
 		// protected.POST("/billing/invoices", proxyToService(cfg.Services.BillingServiceURL))
 
-		// 管理员接口 (TODO: 实现 RoleMiddleware)
-		// admin := protected.Group("")
-		// admin.Use(middleware.RoleMiddleware(100)) // role >= 100 才能访问
-		// {
-		// 	// 管理员功能...
-		// }
+		// 管理员接口
+		admin := protected.Group("")
+		admin.Use(middleware.RoleMiddleware(model.UserRoleAdmin))
+		{
+			admin.GET("/user/:id", proxyToService(cfg.Services.UserServiceURL))
+			admin.GET("/admin/chat/messages/:id", proxyToService(cfg.Services.ChatServiceURL))
+		}
 	}
 
 	// 健康检查
@@ -111,126 +160,7 @@ func main() {
 	})
 
 	// 启动服务
-	addr := fmt.Sprintf(":%d", cfg.App.Port)
-	logger.Info("Gateway starting", zap.String("addr", addr))
-	if err := r.Run(addr); err != nil {
-		logger.Fatal("Failed to start server", zap.Error(err))
-	}
-}
-
-// proxyToService 代理请求到目标服务
-func proxyToService(targetURL string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		target, err := joinURL(targetURL, c.Request.URL.Path, c.Request.URL.RawQuery)
-		if err != nil {
-			utils.InternalError(c, "创建请求失败")
-			return
-		}
-
-		req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, target, c.Request.Body)
-		if err != nil {
-			utils.InternalError(c, "创建请求失败")
-			return
-		}
-
-		// 复制 Header
-		for key, values := range c.Request.Header {
-			for _, value := range values {
-				req.Header.Add(key, value)
-			}
-		}
-
-		// 传递用户信息（如果已鉴权）
-		if userID, exists := c.Get("user_id"); exists {
-			req.Header.Set("X-User-ID", fmt.Sprintf("%d", userID))
-			req.Header.Set("X-Username", c.GetString("username"))
-			req.Header.Set("X-User-Role", fmt.Sprintf("%d", c.GetInt("role")))
-		}
-
-		client := &http.Client{Timeout: 30 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			utils.InternalError(c, "请求上游服务失败")
-			return
-		}
-		defer resp.Body.Close()
-
-		for key, values := range resp.Header {
-			for _, value := range values {
-				c.Header(key, value)WORK_PLAN.md，包含从环境搭建、后端组件、Relay/适配器、计费与异步任务、知识库、Token
-			}
-		}
-
-		c.Status(resp.StatusCode)
-		if _, err := io.Copy(c.Writer, resp.Body); err != nil {
-			logger.Error("Failed to copy response", zap.Error(err))
-		}
-	}
-}
-
-// proxyToServiceSSE 代理 SSE 流式请求到目标服务
-func proxyToServiceSSE(targetURL string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		target, err := joinURL(targetURL, c.Request.URL.Path, c.Request.URL.RawQuery)
-		if err != nil {
-			utils.InternalError(c, "创建请求失败")
-			return
-		}
-
-		req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, target, c.Request.Body)
-		if err != nil {
-			utils.InternalError(c, "创建请求失败")
-			return
-		}
-
-		for key, values := range c.Request.Header {
-			for _, value := range values {
-				req.Header.Add(key, value)
-			}
-		}
-
-		if userID, exists := c.Get("user_id"); exists {
-			req.Header.Set("X-User-ID", fmt.Sprintf("%d", userID))
-			req.Header.Set("X-Username", c.GetString("username"))
-			req.Header.Set("X-User-Role", fmt.Sprintf("%d", c.GetInt("role")))
-		}
-
-		client := &http.Client{Timeout: 300 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			utils.InternalError(c, "请求上游服务失败")
-			return
-		}
-		defer resp.Body.Close()
-
-		c.Header("Content-Type", "text/event-stream")
-		c.Header("Cache-Control", "no-cache")
-		c.Header("Connection", "keep-alive")
-		c.Header("Access-Control-Allow-Origin", "*")
-
-		for key, values := range resp.Header {
-			if key == "Content-Length" {
-				continue
-			}
-			for _, value := range values {
-				c.Header(key, value)
-			}
-		}
-
-		c.Status(resp.StatusCode)
-		if _, err := io.Copy(c.Writer, resp.Body); err != nil {
-			logger.Error("Failed to copy SSE response", zap.Error(err))
-		}
-	}
-}
-
-// joinURL 组装目标 URL
-func joinURL(base, path, rawQuery string) (string, error) {
-	u, err := url.Parse(base)
-	if err != nil {
-		return "", err
+	if err := srv.Run(fmt.Sprintf(":%d", cfg.App.Port), r); err != nil {
+		logger.Fatal("Server exited with error", zap.Error(err))
 	}
-	u.Path = path
-	u.RawQuery = rawQuery
-	return u.String(), nil
 }
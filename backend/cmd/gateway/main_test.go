@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestIDPropagation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zap.InfoLevel)
+	previous := logger.DefaultLogger
+	logger.DefaultLogger = logger.Wrap(zap.New(core))
+	defer func() { logger.DefaultLogger = previous }()
+
+	// 下游服务：与各服务相同的请求 ID 与访问日志中间件
+	downstream := gin.New()
+	downstream.Use(middleware.RequestIDMiddleware(), middleware.LoggerMiddleware())
+	downstream.POST("/api/v1/chat/messages", func(c *gin.Context) {
+		utils.BadRequest(c, "invalid message")
+	})
+	downstream.POST("/api/v1/chat/messages/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		utils.WriteSSERequestID(c)
+		fmt.Fprint(c.Writer, "data: {}\n\n")
+	})
+	downstream.GET("/api/v1/user/profile", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"user_id":    c.GetHeader("X-User-ID"),
+			"connection": c.GetHeader("X-Hop"),
+		})
+	})
+	server := httptest.NewServer(downstream)
+	defer server.Close()
+
+	gateway := gin.New()
+	gateway.Use(middleware.RequestIDMiddleware())
+	gateway.POST("/api/v1/chat/messages", proxyToService(server.URL))
+	gateway.POST("/api/v1/chat/messages/stream", proxyToServiceSSE(server.URL))
+	gateway.GET("/api/v1/user/profile", proxyToService(server.URL))
+
+	do := func(method, path string, header map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		gateway.ServeHTTP(w, req)
+		return w
+	}
+	// 下游在写出响应后才记录访问日志，需要等待
+	assertAccessLog := func(t *testing.T, path, requestID string) {
+		t.Helper()
+		assert.Eventually(t, func() bool {
+			return logs.FilterField(zap.String("path", path)).FilterField(zap.String("request_id", requestID)).Len() == 1
+		}, time.Second, 5*time.Millisecond)
+	}
+	errorRequestID := func(t *testing.T, w *httptest.ResponseRecorder) string {
+		t.Helper()
+		var resp utils.Response
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.NotNil(t, resp.Error)
+		return resp.Error.RequestID
+	}
+
+	t.Run("generated at the gateway", func(t *testing.T) {
+		w := do(http.MethodPost, "/api/v1/chat/messages", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		requestID := w.Header().Get(middleware.RequestIDKey)
+		require.NotEmpty(t, requestID)
+		assert.Len(t, w.Header().Values(middleware.RequestIDKey), 1)
+		assert.Equal(t, requestID, errorRequestID(t, w))
+		assertAccessLog(t, "/api/v1/chat/messages", requestID)
+	})
+
+	t.Run("client id is kept", func(t *testing.T) {
+		w := do(http.MethodPost, "/api/v1/chat/messages", map[string]string{middleware.RequestIDKey: "req-from-client"})
+		assert.Equal(t, "req-from-client", w.Header().Get(middleware.RequestIDKey))
+		assert.Equal(t, "req-from-client", errorRequestID(t, w))
+		assertAccessLog(t, "/api/v1/chat/messages", "req-from-client")
+	})
+
+	t.Run("sse stream starts with request id comment", func(t *testing.T) {
+		w := do(http.MethodPost, "/api/v1/chat/messages/stream", map[string]string{middleware.RequestIDKey: "req-stream"})
+		assert.True(t, strings.HasPrefix(w.Body.String(), ": request_id=req-stream\n\n"), w.Body.String())
+		assert.Equal(t, "req-stream", w.Header().Get(middleware.RequestIDKey))
+	})
+
+	t.Run("hop-by-hop and forged user headers are dropped", func(t *testing.T) {
+		w := do(http.MethodGet, "/api/v1/user/profile", map[string]string{
+			"X-User-ID":  "1",
+			"Connection": "X-Hop",
+			"X-Hop":      "secret",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"user_id":"","connection":""}`, w.Body.String())
+	})
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/server"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tracing"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	proxyTimeout       = 30 * time.Second  // 普通请求的总超时
+	proxyStreamTimeout = 300 * time.Second // SSE 请求的总超时
+)
+
+// proxyTransport 所有下游服务共享的连接池，下游均为内部服务，每个服务保留足够的空闲连接以应对并发
+var proxyTransport = &http.Transport{
+	DialContext: (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          1024,
+	MaxIdleConnsPerHost:   256,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// proxyKey 反向代理的缓存键，同一下游服务的普通请求与 SSE 请求分别使用一个代理
+type proxyKey struct {
+	target string
+	stream bool
+}
+
+var (
+	proxiesMu sync.Mutex
+	proxies   = make(map[proxyKey]*httputil.ReverseProxy)
+)
+
+// ginContextKey 转发请求的上下文中 gin.Context 的键，Director 与 ErrorHandler 从中读取用户信息与请求 ID
+type ginContextKey struct{}
+
+// proxyToService 代理请求到目标服务
+func proxyToService(targetURL string) gin.HandlerFunc {
+	proxy := reverseProxy(targetURL, false)
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), proxyTimeout)
+		defer cancel()
+		proxy.ServeHTTP(c.Writer, c.Request.WithContext(context.WithValue(ctx, ginContextKey{}, c)))
+	}
+}
+
+// proxyToServiceSSE 代理 SSE 流式请求到目标服务，逐块转发不做缓冲
+func proxyToServiceSSE(targetURL string) gin.HandlerFunc {
+	proxy := reverseProxy(targetURL, true)
+	return func(c *gin.Context) {
+		// 网关关闭时中断转发，客户端收到 server_shutdown 事件后重新连接
+		streamCtx, stop := server.StreamContext(c.Request.Context())
+		defer stop()
+		ctx, cancel := context.WithTimeout(streamCtx, proxyStreamTimeout)
+		defer cancel()
+		proxy.ServeHTTP(c.Writer, c.Request.WithContext(context.WithValue(ctx, ginContextKey{}, c)))
+	}
+}
+
+// proxyToServiceWS 代理 WebSocket 连接到目标服务，协议升级由 ReverseProxy 处理，连接不设总超时
+func proxyToServiceWS(targetURL string) gin.HandlerFunc {
+	proxy := reverseProxy(targetURL, false)
+	return func(c *gin.Context) {
+		// 网关关闭时断开连接，客户端重新连接到其它实例
+		ctx, stop := server.StreamContext(c.Request.Context())
+		defer stop()
+		proxy.ServeHTTP(c.Writer, c.Request.WithContext(context.WithValue(ctx, ginContextKey{}, c)))
+	}
+}
+
+// reverseProxy 返回目标服务的反向代理，同一目标复用同一实例
+func reverseProxy(targetURL string, stream bool) *httputil.ReverseProxy {
+	proxiesMu.Lock()
+	defer proxiesMu.Unlock()
+
+	key := proxyKey{target: targetURL, stream: stream}
+	if proxy, ok := proxies[key]; ok {
+		return proxy
+	}
+	target, err := url.Parse(targetURL)
+	if err != nil || target.Host == "" {
+		logger.Fatal("Invalid service URL", zap.String("url", targetURL), zap.Error(err))
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			// 路径与查询参数原样转发
+			req.URL.Scheme = target.Scheme
+			req.URL.Host = target.Host
+			req.Host = target.Host
+			setForwardHeaders(req)
+		},
+		Transport:      proxyTransport,
+		ModifyResponse: modifyProxyResponse(stream),
+		ErrorHandler:   proxyErrorHandler,
+	}
+	if stream {
+		proxy.FlushInterval = -1
+	}
+	proxies[key] = proxy
+	return proxy
+}
+
+// setForwardHeaders 去掉客户端伪造的用户信息，注入网关鉴权得到的用户信息与追踪上下文，
+// 并使用网关确定的请求 ID，下游服务沿用该 ID 记录日志。逐跳请求头由 ReverseProxy 删除（RFC 7230 6.1）
+func setForwardHeaders(req *http.Request) {
+	for _, key := range []string{"X-User-ID", "X-Username", "X-User-Role"} {
+		req.Header.Del(key)
+	}
+	// 以网关的 span 作为下游服务 span 的父 span
+	tracing.Inject(req.Context(), req.Header)
+	c, ok := req.Context().Value(ginContextKey{}).(*gin.Context)
+	if !ok {
+		return
+	}
+	if requestID := c.GetString("request_id"); requestID != "" {
+		req.Header.Set(middleware.RequestIDKey, requestID)
+	}
+
+	// 传递用户信息（如果已鉴权）
+	if userID, ok := middleware.UserIDFromContext(c); ok {
+		req.Header.Set("X-User-ID", strconv.Itoa(userID))
+		req.Header.Set("X-Username", c.GetString("username"))
+		req.Header.Set("X-User-Role", fmt.Sprintf("%d", c.GetInt("role")))
+	}
+}
+
+// modifyProxyResponse 去掉下游的请求 ID（由 RequestIDMiddleware 设置，与下游一致，避免重复）；
+// SSE 响应不设置 Content-Length，网关关闭中断转发时以 server_shutdown 事件结束
+func modifyProxyResponse(stream bool) func(*http.Response) error {
+	return func(resp *http.Response) error {
+		resp.Header.Del(middleware.RequestIDKey)
+		if !stream {
+			return nil
+		}
+		resp.Header.Del("Content-Length")
+		if resp.Header.Get("Cache-Control") == "" {
+			resp.Header.Set("Cache-Control", "no-cache")
+		}
+		resp.Body = &shutdownBody{ReadCloser: resp.Body, ctx: resp.Request.Context()}
+		return nil
+	}
+}
+
+// proxyErrorHandler 下游不可达或超时时返回统一格式的错误
+func proxyErrorHandler(w http.ResponseWriter, req *http.Request, err error) {
+	c, ok := req.Context().Value(ginContextKey{}).(*gin.Context)
+	if !ok {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	if errors.Is(err, context.Canceled) && !server.Interrupted(req.Context()) {
+		// 客户端已断开
+		c.Abort()
+		return
+	}
+
+	logger.Ctx(req.Context()).Error("Failed to proxy request", zap.String("target", req.URL.Host), zap.Error(err))
+	appErr := utils.NewAppError(utils.CodeUpstreamError, "请求上游服务失败")
+	if errors.Is(err, context.DeadlineExceeded) {
+		appErr.HTTPStatus = http.StatusGatewayTimeout
+		appErr.Message = "上游服务响应超时"
+	}
+	utils.RespondError(c, appErr)
+}
+
+// shutdownBody 网关关闭中断 SSE 转发时，以 server_shutdown 事件代替读取错误结束响应体
+type shutdownBody struct {
+	io.ReadCloser
+	ctx  context.Context
+	tail *strings.Reader
+}
+
+func (b *shutdownBody) Read(p []byte) (int, error) {
+	if b.tail != nil {
+		return b.tail.Read(p)
+	}
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && server.Interrupted(b.ctx) {
+		b.tail = strings.NewReader(server.ShutdownEvent)
+		if n > 0 {
+			return n, nil
+		}
+		return b.tail.Read(p)
+	}
+	return n, err
+}
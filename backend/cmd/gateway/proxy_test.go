@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseProxy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("Keep-Alive", "timeout=5")
+		fmt.Fprintf(w, "%s?%s user=%s", r.URL.Path, r.URL.RawQuery, r.Header.Get("X-User-ID"))
+		w.Header().Set("X-Checksum", "abc")
+	}))
+	defer downstream.Close()
+
+	gateway := gin.New()
+	gateway.GET("/api/v1/chat/sessions", func(c *gin.Context) {
+		c.Set("user_id", 42)
+	}, proxyToService(downstream.URL))
+	gateway.GET("/api/v1/unreachable", proxyToService("http://127.0.0.1:1"))
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	t.Run("path, query, user and trailer are kept", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/chat/sessions?page=2", nil)
+		require.NoError(t, err)
+		req.Header.Set("X-User-ID", "1")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "/api/v1/chat/sessions?page=2 user=42", string(body))
+		assert.Equal(t, "abc", resp.Trailer.Get("X-Checksum"))
+		assert.Empty(t, resp.Header.Get("Keep-Alive"))
+	})
+
+	t.Run("unreachable service returns structured error", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/api/v1/unreachable")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+
+		var body utils.Response
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		require.NotNil(t, body.Error)
+		assert.Equal(t, utils.CodeUpstreamError, body.Error.ErrorCode)
+	})
+}
+
+func TestReverseProxyForwardsAuthenticatedUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := []byte("test-secret")
+
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "user=%s role=%s", r.Header.Get("X-User-ID"), r.Header.Get("X-User-Role"))
+	}))
+	defer downstream.Close()
+
+	gateway := gin.New()
+	gateway.GET("/api/v1/chat/sessions", middleware.AuthMiddleware(secret), proxyToService(downstream.URL))
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": "42",
+		"role":    10,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString(secret)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/chat/sessions", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "user=42 role=10", string(body))
+}
+
+func TestWebSocketProxy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upgrader := websocket.Upgrader{}
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, append(data, []byte(" token="+r.URL.Query().Get("token"))...))
+		}
+	}))
+	defer downstream.Close()
+
+	gateway := gin.New()
+	gateway.GET("/api/v1/chat/ws", proxyToServiceWS(downstream.URL))
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/chat/ws?token=abc", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("ping")))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "ping token=abc", string(data))
+}
+
+// BenchmarkProxyLatency 对比连接复用的反向代理与每个请求新建 http.Client 的 p99 延迟（200 并发）
+func BenchmarkProxyLatency(b *testing.B) {
+	gin.SetMode(gin.ReleaseMode)
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":true}`))
+	}))
+	defer downstream.Close()
+
+	clientPerRequest := func(c *gin.Context) {
+		req, _ := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, downstream.URL+c.Request.URL.Path, c.Request.Body)
+		client := &http.Client{Timeout: 30 * time.Second, Transport: &http.Transport{}}
+		resp, err := client.Do(req)
+		if err != nil {
+			c.Status(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		c.Status(resp.StatusCode)
+		io.Copy(c.Writer, resp.Body)
+	}
+
+	for _, bc := range []struct {
+		name    string
+		handler gin.HandlerFunc
+	}{
+		{"reverse_proxy", proxyToService(downstream.URL)},
+		{"client_per_request", clientPerRequest},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			gateway := gin.New()
+			gateway.GET("/api/v1/user/profile", bc.handler)
+			server := httptest.NewServer(gateway)
+			defer server.Close()
+
+			const concurrency = 200
+			client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: concurrency}}
+			latencies := make([]time.Duration, b.N)
+			requests := make(chan int)
+			var wg sync.WaitGroup
+			b.ResetTimer()
+			for w := 0; w < concurrency; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range requests {
+						start := time.Now()
+						resp, err := client.Get(server.URL + "/api/v1/user/profile")
+						if err == nil {
+							io.Copy(io.Discard, resp.Body)
+							resp.Body.Close()
+						}
+						latencies[i] = time.Since(start)
+					}
+				}()
+			}
+			for i := 0; i < b.N; i++ {
+				requests <- i
+			}
+			close(requests)
+			wg.Wait()
+			b.StopTimer()
+
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+			b.ReportMetric(float64(latencies[b.N*99/100].Microseconds()), "p99-µs")
+		})
+	}
+}
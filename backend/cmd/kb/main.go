@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
@@ -11,7 +13,10 @@ import (
 	"github.com/shirosoralumie648/Oblivious/backend/internal/handler"
 	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/server"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tracing"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
 	"go.uber.org/zap"
 )
@@ -29,11 +34,31 @@ func main() {
 	}
 	defer logger.Sync()
 
+	// 收到退出信号后等待进行中的请求完成，再停止后台任务并关闭数据库与 Redis
+	srv := server.New("Knowledge Base service", time.Duration(cfg.Shutdown.DrainTimeoutSeconds)*time.Second)
+
+	// 收到 SIGHUP 时重新加载配置，日志级别在运行中生效
+	srv.WatchConfig(cfg)
+
+	// 分布式追踪，关闭时最后导出剩余的 span
+	shutdownTracing, err := tracing.Setup("kb", &cfg.Tracing)
+	if err != nil {
+		logger.Fatal("Failed to set up tracing", zap.Error(err))
+	}
+	srv.OnClose(shutdownTracing)
+
 	// 初始化数据库
 	if err := database.InitPostgres(&cfg.Database, cfg.App.Env); err != nil {
 		logger.Fatal("Failed to init database", zap.Error(err))
 	}
-	defer database.Close()
+	srv.OnClose(database.Close)
+
+	// 初始化 Redis（用于共享文本向量缓存；不可用时仅使用进程内缓存）
+	if err := database.InitRedis(&cfg.Redis); err != nil {
+		logger.Warn("Failed to init redis, embedding cache is process-local", zap.Error(err))
+	} else {
+		srv.OnClose(database.CloseRedis)
+	}
 
 	// 初始化 JWT
 	utils.InitJWT(&cfg.JWT)
@@ -42,13 +67,8 @@ func main() {
 	if cfg.App.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	r := gin.New()
-
-	// 添加中间件
-	r.Use(gin.Recovery())
-	r.Use(middleware.RequestIDMiddleware())
-	r.Use(middleware.LoggerMiddleware())
-	r.Use(middleware.CORSMiddleware())
+	// 路由与所有服务共用的中间件，包括 HTTP 指标与 /metrics
+	r := server.NewRouter()
 
 	// 获取 Embedding API 配置
 	embeddingURL := os.Getenv("EMBEDDING_API_URL")
@@ -60,12 +80,39 @@ func main() {
 
 	// 初始化服务
 	ragService := service.NewRAGService(embeddingURL, embeddingKey)
+
+	// 可选的重排序接口（Cohere 风格 /rerank），未配置时请求重排序的搜索保持检索顺序
+	if rerankURL := os.Getenv("RERANK_API_URL"); rerankURL != "" {
+		rerankTimeout, _ := time.ParseDuration(os.Getenv("RERANK_TIMEOUT"))
+		ragService.SetReranker(service.NewHTTPReranker(rerankURL, os.Getenv("RERANK_API_KEY"), os.Getenv("RERANK_MODEL"), rerankTimeout))
+	}
+
+	// 文本向量缓存，重新索引未变化的文本块时不再调用 Embedding API
+	cacheConfig := service.DefaultEmbeddingCacheConfig()
+	if ttl, err := time.ParseDuration(os.Getenv("EMBEDDING_CACHE_TTL")); err == nil && ttl > 0 {
+		cacheConfig.TTL = ttl
+	}
+	if n, err := strconv.Atoi(os.Getenv("EMBEDDING_CACHE_LOCAL_SIZE")); err == nil && n >= 0 {
+		cacheConfig.LocalSize = n
+	}
+	if n, err := strconv.ParseInt(os.Getenv("EMBEDDING_CACHE_MAX_ENTRIES"), 10, 64); err == nil && n >= 0 {
+		cacheConfig.MaxEntries = n
+	}
+	ragService.SetEmbeddingCache(service.NewEmbeddingCache(database.RedisClient, cacheConfig))
 	kbHandler := handler.NewKBHandler(ragService)
 
+	// 文档分块与向量化在后台执行，上次中断的任务重新排队
+	indexWorkers := service.DefaultIndexWorkers
+	if n, err := strconv.Atoi(os.Getenv("KB_INDEX_WORKERS")); err == nil && n > 0 {
+		indexWorkers = n
+	}
+	ragService.StartIndexing(indexWorkers)
+	srv.OnStop(ragService.StopIndexing)
+
 	// 注册路由 - 所有接口都需要鉴权
 	// API 路由
 	api := r.Group("/api/v1")
-	api.Use(middleware.AuthMiddleware([]byte(cfg.JWT.Secret)))
+	api.Use(middleware.AuthMiddlewareWithCachedStatus([]byte(cfg.JWT.Secret), repository.NewUserRepository().FindStatus))
 	{
 		// 知识库管理
 		api.POST("/knowledge-bases", kbHandler.CreateKnowledgeBase)
@@ -77,6 +124,7 @@ func main() {
 		api.POST("/knowledge-bases/:id/documents", kbHandler.UploadDocument)
 		api.GET("/knowledge-bases/:id/documents", kbHandler.GetDocumentList)
 		api.DELETE("/knowledge-bases/:id/documents/:doc_id", kbHandler.DeleteDocument)
+		api.GET("/knowledge-bases/:id/documents/:doc_id/status", kbHandler.GetDocumentStatus)
 
 		// 搜索
 		api.POST("/knowledge-bases/:id/search", kbHandler.SearchDocuments)
@@ -87,11 +135,14 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// 运行统计
+	r.GET("/stats", func(c *gin.Context) {
+		utils.Success(c, gin.H{"embedding_cache": ragService.EmbeddingCacheStats()}, "")
+	})
+
 	// 启动服务
 	port := 8085 // 知识库服务端口
-	addr := fmt.Sprintf(":%d", port)
-	logger.Info("Knowledge Base service starting", zap.String("addr", addr))
-	if err := r.Run(addr); err != nil {
-		logger.Fatal("Failed to start server", zap.Error(err))
+	if err := srv.Run(fmt.Sprintf(":%d", port), r); err != nil {
+		logger.Fatal("Server exited with error", zap.Error(err))
 	}
 }
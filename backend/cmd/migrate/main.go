@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/joho/godotenv"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/abilitycheck"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
 	"gorm.io/driver/postgres"
@@ -74,9 +76,19 @@ func main() {
 		}
 		log.Println("✅ Channel abilities synced successfully!")
 
+	case "verify-abilities":
+		// 检查 channel_abilities 一致性，--repair 时在事务内修复
+		fs := flag.NewFlagSet("verify-abilities", flag.ExitOnError)
+		repair := fs.Bool("repair", false, "delete orphan/duplicate abilities and disable stale ones")
+		_ = fs.Parse(os.Args[2:])
+
+		if err := verifyChannelAbilities(db, *repair); err != nil {
+			log.Fatalf("Failed to verify channel abilities: %v", err)
+		}
+
 	default:
 		log.Printf("Unknown command: %s\n", command)
-		log.Println("Usage: migrate [up|down|status|sync]")
+		log.Println("Usage: migrate [up|down|status|sync|verify-abilities [--repair]]")
 		os.Exit(1)
 	}
 }
@@ -257,6 +269,46 @@ func syncChannelAbilities(db *gorm.DB) error {
 	return nil
 }
 
+// verifyChannelAbilities 检查并（可选）修复渠道能力数据
+func verifyChannelAbilities(db *gorm.DB, repair bool) error {
+	report, err := abilitycheck.NewChecker(db).Run(context.Background(), repair)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Checked %d abilities across %d channels\n", report.Abilities, report.Channels)
+	sections := []struct {
+		title  string
+		issues []abilitycheck.Issue
+	}{
+		{"Orphan abilities (channel missing or deleted)", report.Orphans},
+		{"Duplicate abilities", report.Duplicates},
+		{"Abilities for models not listed on the channel", report.StaleModels},
+		{"Enabled abilities on disabled channels", report.EnabledOnDisabled},
+	}
+	for _, section := range sections {
+		log.Printf("%s: %d\n", section.title, len(section.issues))
+		for _, issue := range section.issues {
+			line := fmt.Sprintf("  ability=%d channel=%d model=%s group=%s",
+				issue.AbilityID, issue.ChannelID, issue.Model, issue.Group)
+			if issue.KeptID != 0 {
+				line += fmt.Sprintf(" (kept %d)", issue.KeptID)
+			}
+			log.Println(line)
+		}
+	}
+
+	switch {
+	case report.Total() == 0:
+		log.Println("✅ Channel abilities are consistent")
+	case report.Repaired:
+		log.Printf("✅ Repaired: %d deleted, %d disabled\n", report.Deleted, report.Disabled)
+	default:
+		log.Printf("Found %d inconsistencies, run with --repair to fix\n", report.Total())
+	}
+	return nil
+}
+
 // Migration 迁移信息
 type Migration struct {
 	Name string
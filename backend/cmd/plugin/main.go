@@ -11,13 +11,55 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/handler"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/server"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tools"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
 )
 
 func main() {
-	router := gin.Default()
+	// 加载配置
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	// 初始化日志
+	if err := logger.Init(cfg.App.Env); err != nil {
+		log.Fatal("Failed to init logger:", err)
+	}
+	defer logger.Sync()
+
+	// 初始化数据库
+	if err := database.InitPostgres(&cfg.Database, cfg.App.Env); err != nil {
+		logger.Fatal("Failed to init database", zap.Error(err))
+	}
+	defer database.Close()
+
+	// 初始化 JWT
+	utils.InitJWT(&cfg.JWT)
 
-	// 全局中间件
-	router.Use(gin.Recovery())
+	if cfg.App.Env == "production" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	// 路由与所有服务共用的中间件，包括 HTTP 指标与 /metrics
+	router := server.NewRouter()
+
+	// 初始化插件注册表，恢复已保存的插件
+	pluginManager := tools.NewMCPPluginManager()
+	pluginService := service.NewPluginService(repository.NewPluginRepository(), pluginManager)
+	if err := pluginService.Restore(context.Background()); err != nil {
+		logger.Error("Failed to restore plugins", zap.Error(err))
+	}
+	pluginHandler := handler.NewPluginHandler(pluginService)
 
 	// 健康检查
 	router.GET("/health", func(c *gin.Context) {
@@ -28,24 +70,11 @@ func main() {
 		})
 	})
 
-	// API路由
+	// API路由：注册插件会在服务器上执行命令，仅管理员可访问
 	v1 := router.Group("/api/v1")
-	{
-		// 插件列表
-		v1.GET("/plugins", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"message": "plugins list endpoint"})
-		})
-
-		// 插件详情
-		v1.GET("/plugins/:id", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"message": "plugin detail endpoint"})
-		})
-
-		// 执行插件
-		v1.POST("/plugins/:id/execute", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"message": "plugin execute endpoint"})
-		})
-	}
+	v1.Use(middleware.AuthMiddlewareWithCachedStatus([]byte(cfg.JWT.Secret), repository.NewUserRepository().FindStatus))
+	v1.Use(middleware.RoleMiddleware(model.UserRoleAdmin))
+	pluginHandler.RegisterRoutes(v1)
 
 	// 启动服务器
 	port := 8088
@@ -57,25 +86,30 @@ func main() {
 
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			logger.Fatal("Failed to start server", zap.Error(err))
 		}
 	}()
 
-	log.Printf("Plugin service started on port %d", port)
+	logger.Info("Plugin service started", zap.Int("port", port))
 
 	// 等待中断信号
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	logger.Info("Shutting down server...")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
+		logger.Fatal("Server forced to shutdown", zap.Error(err))
+	}
+
+	// 停止运行中的插件进程
+	for _, name := range pluginManager.ListPlugins() {
+		pluginManager.StopPlugin(name)
 	}
 
-	log.Println("Server exited")
+	logger.Info("Server exited")
 }
@@ -11,13 +11,12 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/server"
 )
 
 func main() {
-	router := gin.Default()
-
-	// 全局中间件
-	router.Use(gin.Recovery())
+	// 路由与所有服务共用的中间件，包括 HTTP 指标与 /metrics
+	router := server.NewRouter()
 
 	// 健康检查
 	router.GET("/health", func(c *gin.Context) {
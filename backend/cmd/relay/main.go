@@ -1,18 +1,35 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/abilitycheck"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/handler"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/logarchive"
 	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/logtail"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/metrics"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relaylog"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/scaling"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/server"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/storage"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tracing"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
 	"go.uber.org/zap"
 )
@@ -30,11 +47,24 @@ func main() {
 	}
 	defer logger.Sync()
 
+	// 收到退出信号后等待进行中的请求完成，再停止后台任务并关闭数据库与 Redis
+	srv := server.New("Relay service", time.Duration(cfg.Shutdown.DrainTimeoutSeconds)*time.Second)
+
+	// 收到 SIGHUP 时重新加载配置，日志级别与健康探测间隔在运行中生效
+	reloader := srv.WatchConfig(cfg)
+
+	// 分布式追踪，关闭时最后导出剩余的 span
+	shutdownTracing, err := tracing.Setup("relay", &cfg.Tracing)
+	if err != nil {
+		logger.Fatal("Failed to set up tracing", zap.Error(err))
+	}
+	srv.OnClose(shutdownTracing)
+
 	// 初始化数据库
 	if err := database.InitPostgres(&cfg.Database, cfg.App.Env); err != nil {
 		logger.Fatal("Failed to init database", zap.Error(err))
 	}
-	defer database.Close()
+	srv.OnClose(database.Close)
 
 	// 初始化 JWT
 	utils.InitJWT(&cfg.JWT)
@@ -43,50 +73,235 @@ func main() {
 	if cfg.App.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	r := gin.New()
-
-	// 添加中间件
-	r.Use(gin.Recovery())
-	r.Use(middleware.RequestIDMiddleware())
-	r.Use(middleware.LoggerMiddleware())
-	r.Use(middleware.CORSMiddleware())
+	// 路由与所有服务共用的中间件，包括 HTTP 指标与 /metrics
+	r := server.NewRouter()
 
 	// 初始化服务
 	relayService := service.NewRelayService()
+	relayService.SetFailoverConfig(cfg.Failover.MaxRetries, cfg.Failover.PartialFailureWeight, time.Duration(cfg.Failover.QueueTimeoutMillis)*time.Millisecond)
+
+	// 渠道健康探测：配置了间隔时在后台定期探测，否则只在管理员手动触发时探测
+	if cfg.Failover.HealthCheckSeconds > 0 {
+		interval := time.Duration(cfg.Failover.HealthCheckSeconds) * time.Second
+		if err := relayService.StartHealthChecks(context.Background(), interval); err != nil {
+			logger.Warn("Failed to start channel health checks", zap.Error(err))
+		} else {
+			srv.OnStop(relayService.StopHealthChecks)
+			reloader.Subscribe("relay_health_check", func(prev, next *config.Config) {
+				if next.Failover.HealthCheckSeconds == prev.Failover.HealthCheckSeconds {
+					return
+				}
+				// 后台探测的启停需要重启服务，运行中只调整间隔
+				if next.Failover.HealthCheckSeconds <= 0 {
+					logger.Warn("Disabling channel health checks requires a restart")
+					return
+				}
+				relayService.SetHealthCheckInterval(time.Duration(next.Failover.HealthCheckSeconds) * time.Second)
+			})
+		}
+	}
+
+	// 管理员实时跟踪用户请求（请求完成写入统一日志时推送元数据）
+	tailRegistry := logtail.NewRegistry(&logtail.Config{
+		BufferSize:    cfg.LogTail.BufferSize,
+		IdleTimeout:   time.Duration(cfg.LogTail.IdleMinutes) * time.Minute,
+		MaxPerAdmin:   cfg.LogTail.MaxPerAdmin,
+		SweepInterval: time.Minute,
+	})
+	tailRegistry.Start()
+	srv.OnStop(tailRegistry.Stop)
+	relayService.SetLogTail(tailRegistry)
+
+	// 统一日志异步批量写入，退出时写完缓冲区中的日志
+	logWriter := relaylog.NewWriter(repository.NewUnifiedLogRepository(), &relaylog.Config{
+		BufferSize:    cfg.RelayLog.BufferSize,
+		BatchSize:     cfg.RelayLog.BatchSize,
+		FlushInterval: time.Duration(cfg.RelayLog.FlushMillis) * time.Millisecond,
+		MaxBodyBytes:  cfg.RelayLog.MaxBodyBytes,
+	})
+	logWriter.Start()
+	srv.OnStop(logWriter.Stop)
+	relayService.SetLogWriter(logWriter)
+	tokenService := service.NewTokenService(repository.NewTokenRepository(database.DB))
+
+	// 额度预检：上游调用前预留 Token 额度，避免额度耗尽的 Token 继续消耗上游费用
+	// 扩缩容信号：/metrics 供 Prometheus Adapter 驱动 HPA，/internal/scaling-hints 返回摘要
+	scalingRegistry := scaling.NewRegistry()
+	admission := relay.NewAdmissionLimiter(cfg.Scaling.RelayMaxInFlight, cfg.Scaling.RelayMaxQueue, scalingRegistry)
+
+	if cfg.QuotaReserve.Enabled {
+		billingEngine := billing.NewBillingEngine()
+		billingEngine.GetAccountingQueue().RegisterScalingSignals(scalingRegistry, time.Duration(cfg.Scaling.BillingMaxEventAgeSecs)*time.Second)
+
+		// 预留写入数据库，重启后恢复尚未结算的预留，超时未结算的定期释放
+		quotaManager := billingEngine.GetQuotaManager()
+		quotaManager.SetReservationStore(service.NewQuotaReservationStore(repository.NewQuotaReservationRepository()))
+		quotaManager.SetReservationTTL(time.Duration(cfg.QuotaReserve.TTLSeconds) * time.Second)
+		if n, err := quotaManager.RestoreReservations(context.Background()); err != nil {
+			logger.Warn("Failed to restore quota reservations", zap.Error(err))
+		} else if n > 0 {
+			logger.Info("Restored quota reservations", zap.Int("count", n))
+		}
+		quotaManager.StartSweeper(time.Minute)
+		srv.OnStop(quotaManager.StopSweeper)
+
+		relayService.SetQuotaGuard(service.NewRelayQuotaGuard(
+			tokenService,
+			quotaManager,
+			billingEngine.GetPricingManager(),
+			cfg.QuotaReserve.DefaultMaxTokens,
+		))
+	}
+
+	// 响应缓存：相同的非流式请求直接返回缓存的响应，Redis 不可用时仅使用进程内缓存
+	if cfg.ResponseCache.Enabled {
+		if err := database.InitRedis(&cfg.Redis); err != nil {
+			logger.Warn("Failed to init redis, response cache is process-local", zap.Error(err))
+		} else {
+			srv.OnClose(database.CloseRedis)
+		}
+		relayService.SetResponseCache(service.NewResponseCache(database.RedisClient, service.NewResponseCacheConfig(&cfg.ResponseCache)))
+	}
+
+	// 项目关联知识库的检索，Embedding 配置与知识库服务一致
+	relayService.SetRAGService(service.NewRAGService(os.Getenv("EMBEDDING_API_URL"), os.Getenv("EMBEDDING_API_KEY")))
+
+	// 统一日志分区维护与冷存储归档
+	var archiver *logarchive.Archiver
+	if cfg.LogArchive.Enabled {
+		store, err := storage.NewLocalObjectStore(cfg.LogArchive.StorageDir)
+		if err != nil {
+			logger.Fatal("Failed to init log archive storage", zap.Error(err))
+		}
+		archiveCfg := logarchive.DefaultConfig()
+		archiveCfg.HotMonths = cfg.LogArchive.HotMonths
+		archiveCfg.FutureMonths = cfg.LogArchive.FutureMonths
+		archiveCfg.Interval = time.Duration(cfg.LogArchive.IntervalHours) * time.Hour
+		archiver = logarchive.NewArchiver(database.DB, store, archiveCfg)
+		archiver.Start()
+		srv.OnStop(archiver.Stop)
+	}
+
+	// 自托管渠道（Ollama/vLLM/LM Studio）的模型发现
+	abilityRepo := repository.NewChannelAbilityRepository(database.DB)
+	abilityService := service.NewChannelAbilityService(database.DB, abilityRepo)
+	discoveryService := service.NewModelDiscoveryService(abilityService, time.Duration(cfg.Discovery.IntervalSeconds)*time.Second)
+	discoveryService.SetOnChange(relayService.ReloadChannels)
+	if cfg.Discovery.Enabled {
+		discoveryService.Start()
+		srv.OnStop(discoveryService.Stop)
+	}
+
+	// 渠道能力一致性定时检查（只读，修复需通过管理接口或 migrate verify-abilities --repair）
+	abilityChecker := abilitycheck.NewChecker(database.DB)
+	if cfg.AbilityCheck.Enabled {
+		var notifier abilitycheck.Notifier
+		if cfg.AbilityCheck.WebhookURL != "" {
+			notifier = abilitycheck.NewWebhookNotifier(cfg.AbilityCheck.WebhookURL)
+		}
+		abilityScheduler := abilitycheck.NewScheduler(abilityChecker, notifier, &abilitycheck.SchedulerConfig{
+			Interval:  time.Duration(cfg.AbilityCheck.IntervalHours) * time.Hour,
+			Threshold: cfg.AbilityCheck.DriftThreshold,
+		})
+		abilityScheduler.Start()
+		srv.OnStop(abilityScheduler.Stop)
+	}
+
+	// 渠道能力探测（JSON 模式、工具调用、图片输入、流式输出）
+	probeService := service.NewCapabilityProbeService(abilityRepo, cfg.CapProbe.SystemUserID, time.Duration(cfg.CapProbe.IntervalSeconds)*time.Second)
+	probeService.SetOnApply(relayService.ReloadChannels)
 
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
+	handler.NewScalingHandler(scalingRegistry).RegisterRoutes(&r.RouterGroup)
+	// 渠道断路器状态在抓取 /metrics 时读取
+	metrics.CircuitBreakerStates(func() map[string]string {
+		states := make(map[string]string)
+		for _, state := range relayService.CircuitBreakerStates() {
+			states[state.ChannelID] = state.State.String()
+		}
+		return states
+	})
 
 	// API 路由组
 	api := r.Group("/v1")
 
+	// 中转接口的并发准入：执行期间（含流式输出）占用名额，超出按 X-Relay-Priority 排队
+	admit := middleware.AdmissionMiddleware(admission)
+
+	// 中转 OpenAI 兼容的接口必须携带 API Key，失败时返回 OpenAI 格式的错误
+	apiKeyAuth := middleware.APIKeyAuth(tokenService.ValidateKey)
+
 	// 公开接口 - 中转 OpenAI 兼容的 API
 	{
 		// Chat Completion 接口（支持流式和非流式）
-		api.POST("/chat/completions", func(c *gin.Context) {
+		api.POST("/chat/completions", apiKeyAuth, admit, func(c *gin.Context) {
 			var req relay.ChatCompletionRequest
 			if err := c.ShouldBindJSON(&req); err != nil {
-				utils.BadRequest(c, err.Error())
+				utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInvalidRequest, err))
+				return
+			}
+
+			// 中转上下文：贯穿渠道选择与上游请求，结束后由统一日志与计费读取
+			token := middleware.APITokenFromContext(c)
+			rc, err := relay.NewRelayContextBuilder(c.GetString("request_id"), relay.EndpointChatCompletions).
+				Token(token).
+				Client(c.ClientIP()).
+				Headers(c.Request.Header).
+				Affinity(c.GetHeader(relay.SessionHeader)).
+				Admission(middleware.AdmissionTiming(c)).
+				Build()
+			if err != nil {
+				utils.RespondOpenAIError(c, err)
+				return
+			}
+			ctx := relay.WithRelayContext(c.Request.Context(), rc)
+
+			// 项目 Token：应用项目的系统提示词、知识库与默认参数
+			if err := relayService.ApplyProject(ctx, token, &req); err != nil {
+				utils.RespondOpenAIError(c, handler.RelayError(err, rc))
+				return
+			}
+			if req.Model == "" {
+				utils.RespondOpenAIError(c, utils.NewAppError(utils.CodeInvalidRequest, "model is required"))
+				return
+			}
+			if token != nil && !token.ValidateModel(req.Model) {
+				utils.RespondOpenAIError(c, utils.NewAppError(utils.CodeModelNotAllowed, "model not allowed for this token: "+req.Model))
 				return
 			}
 
 			// 检查 stream 参数
 			if req.Stream {
-				// 流式响应 - Week 7 实现
-				c.Header("Content-Type", "text/event-stream")
-				c.Header("Cache-Control", "no-cache")
-				c.Header("Connection", "keep-alive")
-				c.Header("Transfer-Encoding", "chunked")
-
+				// 流式响应头在首个数据块前写入，额度预检被拒绝时仍可返回 JSON 错误
 				w := c.Writer
+				headerSent := false
+				sendHeaders := func() {
+					c.Header("Content-Type", "text/event-stream")
+					c.Header("Cache-Control", "no-cache")
+					c.Header("Connection", "keep-alive")
+					c.Header("Transfer-Encoding", "chunked")
+					setTraceHeaders(c, rc)
+					utils.WriteSSERequestID(c)
+					headerSent = true
+				}
+
+				// 服务关闭时中断流式输出，客户端收到 server_shutdown 事件后重新连接
+				streamCtx, stopStream := server.StreamContext(ctx)
+				defer stopStream()
 
-				err := relayService.RelayChatCompletionStream(c.Request.Context(), &req, func(chunk *relay.ChatCompletionResponse) error {
-					// 格式化 SSE 数据
+				err := relayService.RelayChatCompletionStream(streamCtx, &req, func(chunk *relay.ChatCompletionResponse) error {
+					if !headerSent {
+						sendHeaders()
+					}
+					// 格式化 SSE 数据，写入失败说明客户端已断开，中转随之取消上游请求
 					if len(chunk.Choices) > 0 {
 						data, _ := json.Marshal(chunk)
-						fmt.Fprintf(w, "data: %s\n\n", string(data))
+						if _, err := fmt.Fprintf(w, "data: %s\n\n", string(data)); err != nil {
+							return err
+						}
 						if f, ok := w.(http.Flusher); ok {
 							f.Flush()
 						}
@@ -94,10 +309,36 @@ func main() {
 					return nil
 				})
 
+				if err != nil && server.Interrupted(streamCtx) {
+					if !headerSent {
+						sendHeaders()
+					}
+					server.WriteShutdownEvent(w)
+					return
+				}
+
+				// 客户端已断开，无需再写入响应
+				var cancelled *relay.ClientCancelledError
+				if errors.As(err, &cancelled) {
+					return
+				}
+
+				// 尚未输出任何数据块时按 OpenAI 格式返回 JSON 错误与对应状态码
+				if err != nil && !headerSent {
+					utils.RespondOpenAIError(c, handler.RelayError(err, rc))
+					return
+				}
+				if !headerSent {
+					sendHeaders()
+				}
 				if err != nil {
-					logger.Error("stream error", zap.Error(err))
+					logger.Error("stream error",
+						zap.String("request_id", rc.RequestID),
+						zap.String("upstream_request_id", rc.UpstreamRequestID),
+						zap.Error(err))
+					data, _ := json.Marshal(utils.NewOpenAIError(handler.RelayError(err, rc)))
 					fmt.Fprintf(w, "event: error\n")
-					fmt.Fprintf(w, "data: %s\n\n", err.Error())
+					fmt.Fprintf(w, "data: %s\n\n", string(data))
 					return
 				}
 
@@ -107,53 +348,37 @@ func main() {
 			}
 
 			// 非流式响应
-			resp, err := relayService.RelayChatCompletion(c.Request.Context(), &req)
+			resp, err := relayService.RelayChatCompletion(ctx, &req)
+			setTraceHeaders(c, rc)
 			if err != nil {
-				utils.InternalError(c, err.Error())
+				// 所有渠道均失败时详情中包含尝试过的渠道
+				utils.RespondOpenAIError(c, handler.RelayError(err, rc))
 				return
 			}
 
 			utils.Success(c, resp, "")
 		})
 
-		// 列出可用模型
-		api.GET("/models", func(c *gin.Context) {
-			channels, err := relayService.GetAvailableChannels(c.Request.Context())
-			if err != nil {
-				utils.InternalError(c, err.Error())
-				return
-			}
-
-			// 构建模型列表
-			modelMap := make(map[string]bool)
-			var models []string
+		// Embedding 接口（知识库索引默认通过该接口生成向量）
+		handler.NewEmbeddingHandler(relayService).RegisterRoutes(api.Group("", apiKeyAuth, admit))
 
-			for _, ch := range channels {
-				if ch.SupportModels == "" {
-					// 如果没有指定，默认支持所有常见模型
-					defaultModels := []string{
-						"gpt-3.5-turbo",
-						"gpt-4",
-						"gpt-4-turbo",
-						"gpt-4o",
-						"claude-3-opus",
-						"claude-3-sonnet",
-						"gemini-pro",
-					}
-					for _, m := range defaultModels {
-						if !modelMap[m] {
-							models = append(models, m)
-							modelMap[m] = true
-						}
-					}
-				}
-			}
+		// 图像生成与语音接口，只路由到能力中标记了 images / audio 的渠道
+		handler.NewMediaHandler(relayService).RegisterRoutes(api.Group("", apiKeyAuth, admit))
 
-			utils.Success(c, gin.H{
-				"object": "list",
-				"data":   models,
-			}, "")
+		// 批量 Chat Completion：创建时预留全部条目的额度，后台以有限并发处理，重启后继续处理未完成的条目
+		batchService := service.NewRelayBatchService(repository.NewRelayBatchRepository(), relayService, tokenService, service.NewRelayBatchConfig(&cfg.Batch))
+		batchService.SetErrorFormatter(func(err error, rc *relay.RelayContext) interface{} {
+			return utils.NewOpenAIError(handler.RelayError(err, rc)).Error
 		})
+		batchService.Start()
+		srv.OnStop(batchService.Stop)
+		handler.NewBatchHandler(batchService).RegisterRoutes(api.Group("", apiKeyAuth))
+
+		// Anthropic 原生 Messages 接口：Claude 渠道原样透传，其它渠道转换为 OpenAI 格式
+		handler.NewMessagesHandler(relayService).RegisterRoutes(api.Group("", middleware.APITokenMiddleware(tokenService.AuthenticateKey), admit))
+
+		// 列出可用模型（OpenAI 兼容格式，不经过统一响应包装）
+		api.GET("/models", apiKeyAuth, listModels(relayService, false))
 
 		// 获取渠道列表（用于管理）
 		api.GET("/channels", func(c *gin.Context) {
@@ -163,13 +388,22 @@ func main() {
 				return
 			}
 
-			utils.Success(c, channels, "")
+			redacted := make([]*model.Channel, 0, len(channels))
+			for _, ch := range channels {
+				redacted = append(redacted, service.RedactChannel(ch))
+			}
+			utils.Success(c, redacted, "")
 		})
 	}
 
+	channelService := service.NewChannelService(repository.NewChannelRepository())
+
+	// JWT 鉴权并拒绝被管理员停用的用户
+	requireAuth := middleware.AuthMiddlewareWithCachedStatus([]byte(cfg.JWT.Secret), repository.NewUserRepository().FindStatus)
+
 	// 需要鉴权的管理接口
 	admin := api.Group("")
-	admin.Use(middleware.AuthMiddleware([]byte(cfg.JWT.Secret)))
+	admin.Use(requireAuth)
 	{
 		// 获取模型价格（用于计费）
 		admin.GET("/model-price/:channel_id/:model", func(c *gin.Context) {
@@ -186,11 +420,82 @@ func main() {
 		})
 	}
 
+	// 登录用户接口
+	userAPI := r.Group("/api/v1")
+	userAPI.Use(requireAuth)
+	exampleService := service.NewClientExampleService(
+		repository.NewTokenRepository(database.DB),
+		repository.NewProjectRepository().FindByID,
+		relayService,
+		service.ClientExampleConfig{
+			BaseURL:        cfg.ClientExamples.BaseURL,
+			Model:          cfg.ClientExamples.Model,
+			EmbeddingModel: cfg.ClientExamples.EmbeddingModel,
+		},
+	)
+	handler.NewClientExampleHandler(exampleService).RegisterRoutes(userAPI)
+
+	adminAuth := adminAuthChain(requireAuth)
+
+	// 中转管理员接口（需要管理员角色）：统一日志查询与归档恢复、渠道详情与增删改（立即生效）、手动重新加载、渠道测试与模型发现、渠道能力校验与修复、渠道断路器状态与手动重置、渠道并发、渠道健康检查
+	relayAdmin := api.Group("/admin", adminAuth...)
+	relayAdmin.GET("/models", listModels(relayService, true)) // 附带提供每个模型的渠道
+	handler.NewLogHandler(archiver).RegisterRoutes(relayAdmin)
+	handler.NewRelayChannelHandler(channelService, relayService, abilityService).RegisterRoutes(relayAdmin)
+	// 渠道详情：多密钥渠道附带各密钥的实时健康状态（密钥已掩码）
+	channelHandler := handler.NewChannelHandler(channelService, abilityService)
+	channelHandler.SetKeyHealthSource(relayService.ChannelKeyHealth)
+	relayAdmin.GET("/channels/:id", channelHandler.GetChannel)
+	handler.NewModelDiscoveryHandler(discoveryService).RegisterRoutes(relayAdmin)
+	handler.NewAbilityCheckHandler(abilityChecker, relayService.ReloadChannels).RegisterRoutes(relayAdmin)
+	handler.NewCircuitBreakerHandler(relayService).RegisterRoutes(relayAdmin)
+	handler.NewChannelConcurrencyHandler(relayService).RegisterRoutes(relayAdmin)
+	handler.NewChannelHealthHandler(relayService).RegisterRoutes(relayAdmin)
+	handler.NewModelFallbackHandler(repository.NewModelFallbackRuleRepository(), relayService).RegisterRoutes(relayAdmin)
+
+	// 平台管理员接口（需要管理员角色）
+	platformAPI := r.Group("/api/v1")
+	platformAPI.Use(adminAuth...)
+	handler.NewCapabilityProbeHandler(probeService).RegisterRoutes(platformAPI)
+	handler.NewLogTailHandler(tailRegistry).RegisterRoutes(platformAPI.Group("/admin"))
+
 	// 启动服务
 	port := 8083 // 中转服务端口
-	addr := fmt.Sprintf(":%d", port)
-	logger.Info("Relay service starting", zap.String("addr", addr))
-	if err := r.Run(addr); err != nil {
-		logger.Fatal("Failed to start server", zap.Error(err))
+	if err := srv.Run(fmt.Sprintf(":%d", port), r); err != nil {
+		logger.Fatal("Server exited with error", zap.Error(err))
+	}
+}
+
+// listModels 列出可用模型，includeChannels 为 true 时附带提供每个模型的渠道（仅管理员）
+func listModels(relayService *service.RelayService, includeChannels bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		models, err := relayService.ListModels(c.Request.Context(), includeChannels)
+		if err != nil {
+			utils.RespondOpenAIError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, models)
+	}
+}
+
+// setTraceHeaders 在响应头中返回上游请求 ID、参数适配警告、响应缓存命中与模型降级
+func setTraceHeaders(c *gin.Context, rc *relay.RelayContext) {
+	if rc.CacheHit {
+		c.Header("X-Cache", "HIT")
+	}
+	if rc.RequestedModel != "" {
+		c.Header("X-Requested-Model", rc.RequestedModel)
+		c.Header("X-Fallback-Model", rc.Model)
+	}
+	if rc.UpstreamRequestID != "" {
+		c.Header("X-Upstream-Request-ID", rc.UpstreamRequestID)
 	}
+	for _, warning := range rc.Warnings {
+		c.Writer.Header().Add("X-Param-Warning", warning)
+	}
+}
+
+// adminAuthChain 管理员接口的鉴权链：先经 auth 鉴权，再要求管理员角色
+func adminAuthChain(auth gin.HandlerFunc) []gin.HandlerFunc {
+	return []gin.HandlerFunc{auth, middleware.RoleMiddleware(model.UserRoleAdmin)}
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/handler"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelDetailRequiresAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := []byte("test-secret")
+
+	// 与 main 相同：渠道详情挂载在中转管理员路由组下
+	r := gin.New()
+	relayAdmin := r.Group("/v1/admin", adminAuthChain(middleware.AuthMiddleware(secret))...)
+	relayAdmin.GET("/channels/:id", handler.NewChannelHandler(nil, nil).GetChannel)
+
+	token := func(role int) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &middleware.Claims{
+			UserID: "7",
+			Role:   role,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}).SignedString(secret)
+		require.NoError(t, err)
+		return signed
+	}
+	get := func(authorization string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/admin/channels/1", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get(""))
+	assert.Equal(t, http.StatusForbidden, get("Bearer "+token(model.UserRoleUser)))
+}
@@ -1,15 +1,29 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/dataexport"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/handler"
 	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/oauth"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/server"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/sso"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/storage"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tracing"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
 	"go.uber.org/zap"
 )
@@ -27,11 +41,24 @@ func main() {
 	}
 	defer logger.Sync()
 
+	// 收到退出信号后等待进行中的请求完成，再停止后台任务并关闭数据库与 Redis
+	srv := server.New("User service", time.Duration(cfg.Shutdown.DrainTimeoutSeconds)*time.Second)
+
+	// 收到 SIGHUP 时重新加载配置，日志级别在运行中生效
+	srv.WatchConfig(cfg)
+
+	// 分布式追踪，关闭时最后导出剩余的 span
+	shutdownTracing, err := tracing.Setup("user", &cfg.Tracing)
+	if err != nil {
+		logger.Fatal("Failed to set up tracing", zap.Error(err))
+	}
+	srv.OnClose(shutdownTracing)
+
 	// 初始化数据库
 	if err := database.InitPostgres(&cfg.Database, cfg.App.Env); err != nil {
 		logger.Fatal("Failed to init database", zap.Error(err))
 	}
-	defer database.Close()
+	srv.OnClose(database.Close)
 
 	// 初始化 JWT
 	utils.InitJWT(&cfg.JWT)
@@ -40,16 +67,94 @@ func main() {
 	if cfg.App.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	r := gin.New()
-
-	// 添加中间件
-	r.Use(gin.Recovery())
-	r.Use(middleware.RequestIDMiddleware())
-	r.Use(middleware.LoggerMiddleware())
-	r.Use(middleware.CORSMiddleware())
+	// 路由与所有服务共用的中间件，包括 HTTP 指标与 /metrics
+	r := server.NewRouter()
 
 	// 初始化 Service
-	userService := service.NewUserService(&cfg.JWT)
+	// 登录会话：每次登录记录设备与 IP，撤销会话后其刷新令牌失效
+	sessionService := service.NewSessionService(repository.NewUserSessionRepository(database.DB), repository.NewUserRepository(), &cfg.JWT)
+	userService := service.NewUserService(&cfg.JWT, sessionService)
+	ssoService := service.NewSSOService(sessionService)
+
+	// 邮箱验证令牌与第三方登录的授权状态保存在 Redis，两者都未启用时不连接 Redis
+	if cfg.EmailVerify.Enabled || cfg.OAuth.Enabled() {
+		if err := database.InitRedis(&cfg.Redis); err != nil {
+			logger.Fatal("Failed to init redis", zap.Error(err))
+		}
+		srv.OnClose(database.CloseRedis)
+	}
+
+	// 注册邮箱验证：验证邮件使用预警通知的 SMTP 配置发送
+	var verificationService *service.EmailVerificationService
+	if cfg.EmailVerify.Enabled {
+		if cfg.Alert.SMTPAddr == "" {
+			logger.Fatal("Email verification requires ALERT_SMTP_ADDR")
+		}
+
+		mailer := service.NewSMTPVerificationMailer(&billing.SMTPSender{
+			Addr:     cfg.Alert.SMTPAddr,
+			Username: cfg.Alert.SMTPUsername,
+			Password: cfg.Alert.SMTPPassword,
+		}, cfg.Alert.SMTPFrom)
+		verificationService = service.NewEmailVerificationService(
+			repository.NewUserRepository(),
+			service.NewRedisVerificationStore(database.RedisClient),
+			mailer,
+			[]byte(cfg.JWT.Secret),
+			service.NewEmailVerificationConfig(&cfg.EmailVerify),
+		)
+		userService.SetEmailVerification(verificationService)
+	}
+
+	// 第三方账号登录与绑定：GitHub、Google 按配置启用
+	var oauthHandler *handler.OAuthHandler
+	if cfg.OAuth.Enabled() {
+		oauthManager := service.NewOAuthManager(&cfg.OAuth, oauth.NewRedisStateStore(database.RedisClient))
+		oauthHandler = handler.NewOAuthHandler(service.NewOAuthService(oauthManager, sessionService, repository.NewUserRepository()))
+	}
+
+	// 用户数据导出：归档写入本地对象存储，后台任务续跑中断的导出并清理过期归档
+	exportStore, err := storage.NewLocalObjectStore(cfg.DataExport.StorageDir)
+	if err != nil {
+		logger.Fatal("Failed to init data export storage", zap.Error(err))
+	}
+	fileStore, err := storage.NewLocalObjectStore(cfg.DataExport.FilesDir)
+	if err != nil {
+		logger.Fatal("Failed to init file storage", zap.Error(err))
+	}
+	var exportNotifier dataexport.Notifier
+	if cfg.DataExport.WebhookURL != "" {
+		exportNotifier = dataexport.NewWebhookNotifier(cfg.DataExport.WebhookURL)
+	}
+	exportCfg := dataexport.DefaultConfig()
+	exportCfg.Retention = time.Duration(cfg.DataExport.RetentionDays) * 24 * time.Hour
+	exportCfg.LinkTTL = time.Duration(cfg.DataExport.LinkTTLHours) * time.Hour
+	exportCfg.PublicURL = cfg.DataExport.PublicURL
+	exportCfg.Secret = []byte(cfg.JWT.Secret)
+	exportService := dataexport.NewService(
+		repository.NewDataExportRepository(),
+		dataexport.NewExporter(dataexport.NewDBSource(database.DB), fileStore),
+		exportStore,
+		exportNotifier,
+		exportCfg,
+	)
+	exportService.Start()
+	srv.OnStop(exportService.Stop)
+	exportHandler := handler.NewDataExportHandler(exportService)
+
+	// API Token 过期检查：定期标记过期的 Token，并按用户的预警偏好发送一次即将过期通知
+	tokenService := service.NewTokenService(repository.NewTokenRepository(database.DB))
+	tokenAlerts := billing.NewAlertManager(billing.NewQuotaManager())
+	if err := service.SetupAlertNotifications(tokenAlerts, service.NewQuotaAlertService(repository.NewAlertRepository()), &cfg.Alert); err != nil {
+		logger.Fatal("Failed to set up token expiry notifications", zap.Error(err))
+	}
+	srv.OnStop(tokenAlerts.WaitNotifications)
+	expirySweeper := service.NewTokenExpirySweeper(tokenService, service.NewAlertTokenExpiryNotifier(tokenAlerts), time.Duration(cfg.TokenExpiry.IntervalMinutes)*time.Minute)
+	expirySweeper.SetSessionPruning(sessionService, time.Duration(cfg.TokenExpiry.SessionIdleDays)*24*time.Hour)
+	if cfg.TokenExpiry.Enabled {
+		expirySweeper.Start()
+		srv.OnStop(expirySweeper.Stop)
+	}
 
 	// 注册路由
 	api := r.Group("/api/v1")
@@ -79,7 +184,11 @@ func main() {
 				return
 			}
 
-			resp, err := userService.Login(c.Request.Context(), &req)
+			resp, err := userService.Login(c.Request.Context(), &req, clientInfo(c))
+			if errors.Is(err, service.ErrEmailNotVerified) {
+				utils.RespondError(c, err)
+				return
+			}
 			if err != nil {
 				utils.Unauthorized(c, err.Error())
 				return
@@ -88,6 +197,35 @@ func main() {
 			utils.Success(c, resp, "登录成功")
 		})
 
+		if verificationService != nil {
+			// 邮箱验证链接
+			api.GET("/verify-email", func(c *gin.Context) {
+				user, err := verificationService.Verify(c.Request.Context(), c.Query("token"))
+				if err != nil {
+					utils.RespondError(c, err)
+					return
+				}
+
+				utils.Success(c, user, "邮箱验证成功")
+			})
+
+			// 重发验证邮件，同一邮箱按间隔限流
+			api.POST("/resend-verification", func(c *gin.Context) {
+				var req service.ResendVerificationRequest
+				if err := c.ShouldBindJSON(&req); err != nil {
+					utils.BadRequest(c, err.Error())
+					return
+				}
+
+				if err := verificationService.Resend(c.Request.Context(), req.Email); err != nil {
+					utils.RespondError(c, err)
+					return
+				}
+
+				utils.Success(c, nil, "如果该邮箱已注册且尚未验证，验证邮件已发送")
+			})
+		}
+
 		// Token 刷新
 		api.POST("/refresh", func(c *gin.Context) {
 			var req service.RefreshTokenRequest
@@ -96,7 +234,7 @@ func main() {
 				return
 			}
 
-			resp, err := userService.RefreshAccessToken(c.Request.Context(), req.RefreshToken)
+			resp, err := userService.RefreshAccessToken(c.Request.Context(), req.RefreshToken, clientInfo(c))
 			if err != nil {
 				utils.Unauthorized(c, err.Error())
 				return
@@ -104,11 +242,115 @@ func main() {
 
 			utils.Success(c, resp, "Token 刷新成功")
 		})
+
+		// 组织 SSO 登录发起
+		api.GET("/sso/:org/login", func(c *gin.Context) {
+			authURL, err := ssoService.BeginLogin(c.Request.Context(), c.Param("org"))
+			if err != nil {
+				handleSSOError(c, err)
+				return
+			}
+
+			c.Redirect(http.StatusFound, authURL)
+		})
+
+		// 组织 SSO 回调
+		api.GET("/sso/:org/callback", func(c *gin.Context) {
+			if errMsg := c.Query("error"); errMsg != "" {
+				utils.Unauthorized(c, "SSO 登录失败: "+errMsg)
+				return
+			}
+
+			resp, err := ssoService.CompleteLogin(c.Request.Context(), c.Param("org"), c.Query("state"), c.Query("code"), clientInfo(c))
+			if err != nil {
+				handleSSOError(c, err)
+				return
+			}
+
+			utils.Success(c, resp, "登录成功")
+		})
+
+		// 第三方账号登录发起与回调
+		if oauthHandler != nil {
+			oauthHandler.RegisterRoutes(api)
+		}
+
+		// 数据导出下载（签名链接，不需要登录）
+		exportHandler.RegisterDownloadRoute(api)
+	}
+
+	// 每次请求校验用户状态，被管理员停用的账号立即失去访问权限
+	requireAuth := middleware.AuthMiddlewareWithStatus([]byte(cfg.JWT.Secret), repository.NewUserRepository().FindStatus)
+
+	// 管理员接口
+	admin := r.Group("/api/v1/admin")
+	admin.Use(requireAuth, middleware.RoleMiddleware(model.UserRoleAdmin))
+	// API Token 过期检查状态
+	handler.NewTokenExpiryHandler(expirySweeper).RegisterRoutes(admin)
+	{
+		// 获取组织 SSO 配置
+		admin.GET("/orgs/:org/sso", func(c *gin.Context) {
+			ssoCfg, err := ssoService.GetConfig(c.Request.Context(), c.Param("org"))
+			if err != nil {
+				handleSSOError(c, err)
+				return
+			}
+
+			if ssoCfg == nil {
+				utils.NotFound(c, "SSO 未配置")
+				return
+			}
+
+			utils.Success(c, ssoCfg, "")
+		})
+
+		// 创建或更新组织 SSO 配置
+		admin.PUT("/orgs/:org/sso", func(c *gin.Context) {
+			var req service.SSOConfigRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				utils.BadRequest(c, err.Error())
+				return
+			}
+
+			ssoCfg, err := ssoService.SaveConfig(c.Request.Context(), c.Param("org"), &req)
+			if err != nil {
+				utils.BadRequest(c, err.Error())
+				return
+			}
+
+			utils.Success(c, ssoCfg, "保存成功")
+		})
+
+		// 测试组织 SSO 连接
+		admin.POST("/orgs/:org/sso/test", func(c *gin.Context) {
+			result, err := ssoService.TestConnection(c.Request.Context(), c.Param("org"))
+			if err != nil {
+				handleSSOError(c, err)
+				return
+			}
+
+			utils.Success(c, result, "")
+		})
 	}
 
 	// 鉴权中间件
 	auth := r.Group("/api/v1")
-	auth.Use(middleware.AuthMiddleware([]byte(cfg.JWT.Secret)))
+	auth.Use(requireAuth)
+	// 中转项目：绑定系统提示词、知识库与默认参数的项目级 API Token
+	handler.NewProjectHandler().RegisterRoutes(auth)
+	// 用户数据导出
+	exportHandler.RegisterRoutes(auth)
+	// API Token 管理：创建、轮换与白名单配置
+	handler.NewTokenHandler(tokenService).RegisterRoutes(auth)
+	// 登录设备管理
+	handler.NewUserSessionHandler(sessionService).RegisterRoutes(auth)
+	// 管理后台用户管理：查询、停用、调整角色与额度
+	handler.NewAdminUserHandler(service.NewAdminUserService(repository.NewUserRepository(), repository.NewAuditLogRepository().Create)).
+		RegisterRoutes(auth.Group("/admin", middleware.RoleMiddleware(model.UserRoleAdmin)))
+	// 第三方账号绑定管理
+	if oauthHandler != nil {
+		oauthHandler.RegisterBindingRoutes(auth)
+	}
 	{
 		// 用户信息获取当前用户信息
 		auth.GET("/user/profile", func(c *gin.Context) {
@@ -148,9 +390,14 @@ func main() {
 		})
 
 		// 根据 ID 获取用户信息（管理员功能）
-		auth.GET("/user/:id", func(c *gin.Context) {
-			// TODO: 添加管理员权限检查
-			user, err := userService.GetUserByID(c.Request.Context(), 1) // 临时写死
+		auth.GET("/user/:id", middleware.RoleMiddleware(model.UserRoleAdmin), func(c *gin.Context) {
+			id, err := strconv.Atoi(c.Param("id"))
+			if err != nil || id <= 0 {
+				utils.BadRequest(c, "无效的用户 ID")
+				return
+			}
+
+			user, err := userService.GetUserByID(c.Request.Context(), id)
 			if err != nil {
 				utils.InternalError(c, err.Error())
 				return
@@ -172,9 +419,26 @@ func main() {
 
 	// 启动服务
 	port := 8081 // 用户服务端口
-	addr := fmt.Sprintf(":%d", port)
-	logger.Info("User service starting", zap.String("addr", addr))
-	if err := r.Run(addr); err != nil {
-		logger.Fatal("Failed to start server", zap.Error(err))
+	if err := srv.Run(fmt.Sprintf(":%d", port), r); err != nil {
+		logger.Fatal("Server exited with error", zap.Error(err))
+	}
+}
+
+// clientInfo 登录与刷新请求的客户端信息，用于记录登录会话
+func clientInfo(c *gin.Context) service.ClientInfo {
+	return service.ClientInfo{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
+
+// handleSSOError 将 SSO 错误映射为 HTTP 响应
+func handleSSOError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, sso.ErrOrgNotFound), errors.Is(err, sso.ErrSSONotConfigured):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, sso.ErrDomainNotAllowed), errors.Is(err, sso.ErrEmailNotVerified), errors.Is(err, sso.ErrUserDisabled):
+		utils.Error(c, http.StatusForbidden, utils.ErrForbidden, err.Error(), nil)
+	case errors.Is(err, sso.ErrInvalidState):
+		utils.BadRequest(c, err.Error())
+	default:
+		utils.Unauthorized(c, err.Error())
 	}
 }
@@ -0,0 +1,257 @@
+package abilitycheck
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// IssueKind 不一致类型
+type IssueKind string
+
+const (
+	// IssueOrphan 能力引用的渠道不存在或已删除
+	IssueOrphan IssueKind = "orphan"
+	// IssueDuplicate 同一 (渠道, 模型, 分组) 存在多行
+	IssueDuplicate IssueKind = "duplicate"
+	// IssueStaleModel 启用的能力对应的模型不在渠道声明/发现的模型列表中
+	IssueStaleModel IssueKind = "stale_model"
+	// IssueEnabledOnDisabled 渠道已禁用但能力仍处于启用状态
+	IssueEnabledOnDisabled IssueKind = "enabled_on_disabled_channel"
+)
+
+// Issue 单条不一致记录
+type Issue struct {
+	Kind      IssueKind `json:"kind"`
+	AbilityID int       `json:"ability_id"`
+	ChannelID int       `json:"channel_id"`
+	Model     string    `json:"model"`
+	Group     string    `json:"group"`
+	KeptID    int       `json:"kept_id,omitempty"` // 重复行中保留的能力 ID
+}
+
+// Report 一致性检查报告
+type Report struct {
+	CheckedAt         time.Time `json:"checked_at"`
+	Channels          int       `json:"channels"`
+	Abilities         int       `json:"abilities"`
+	Orphans           []Issue   `json:"orphans"`
+	Duplicates        []Issue   `json:"duplicates"`
+	StaleModels       []Issue   `json:"stale_models"`
+	EnabledOnDisabled []Issue   `json:"enabled_on_disabled"`
+
+	Repaired bool `json:"repaired"`
+	Deleted  int  `json:"deleted"`  // 修复时删除的行数
+	Disabled int  `json:"disabled"` // 修复时禁用的行数
+}
+
+// Total 不一致记录总数
+func (r *Report) Total() int {
+	return len(r.Orphans) + len(r.Duplicates) + len(r.StaleModels) + len(r.EnabledOnDisabled)
+}
+
+// DeleteIDs 修复时需要删除的能力 ID（孤儿行与重复行）
+func (r *Report) DeleteIDs() []int {
+	ids := make([]int, 0, len(r.Orphans)+len(r.Duplicates))
+	for _, issue := range r.Orphans {
+		ids = append(ids, issue.AbilityID)
+	}
+	for _, issue := range r.Duplicates {
+		ids = append(ids, issue.AbilityID)
+	}
+	return ids
+}
+
+// DisableIDs 修复时需要禁用的能力 ID（过期模型与已禁用渠道上的启用行）
+func (r *Report) DisableIDs() []int {
+	ids := make([]int, 0, len(r.StaleModels)+len(r.EnabledOnDisabled))
+	for _, issue := range r.StaleModels {
+		ids = append(ids, issue.AbilityID)
+	}
+	for _, issue := range r.EnabledOnDisabled {
+		ids = append(ids, issue.AbilityID)
+	}
+	return ids
+}
+
+// abilityKey 能力的唯一键
+type abilityKey struct {
+	channelID int
+	model     string
+	group     string
+}
+
+// Inspect 比对渠道与能力数据，找出所有不一致
+//
+// 每行能力最多归入一种问题：孤儿行与重复行会被删除，不再参与后续检查；
+// 重复行保留 updated_at 最新的一行（相同时保留 ID 较大者）。
+// channels 需包含已软删除的渠道，否则其能力会被视为引用了不存在的渠道。
+func Inspect(channels []model.Channel, abilities []model.ChannelAbility) *Report {
+	report := &Report{
+		CheckedAt:         time.Now(),
+		Channels:          len(channels),
+		Abilities:         len(abilities),
+		Orphans:           []Issue{},
+		Duplicates:        []Issue{},
+		StaleModels:       []Issue{},
+		EnabledOnDisabled: []Issue{},
+	}
+
+	channelByID := make(map[int]*model.Channel, len(channels))
+	for i := range channels {
+		channelByID[channels[i].ID] = &channels[i]
+	}
+
+	// 1. 孤儿行，其余按唯一键分组
+	groups := make(map[abilityKey][]*model.ChannelAbility)
+	keys := make([]abilityKey, 0)
+	for i := range abilities {
+		ability := &abilities[i]
+		ch := channelByID[ability.ChannelID]
+		if ch == nil || ch.DeletedAt != nil {
+			report.Orphans = append(report.Orphans, newIssue(IssueOrphan, ability))
+			continue
+		}
+
+		key := abilityKey{channelID: ability.ChannelID, model: ability.Model, group: ability.Group}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], ability)
+	}
+
+	for _, key := range keys {
+		rows := groups[key]
+
+		// 2. 重复行，保留最近更新的一行
+		kept := rows[0]
+		for _, row := range rows[1:] {
+			if row.UpdatedAt.After(kept.UpdatedAt) || (row.UpdatedAt.Equal(kept.UpdatedAt) && row.ID > kept.ID) {
+				kept = row
+			}
+		}
+		for _, row := range rows {
+			if row != kept {
+				issue := newIssue(IssueDuplicate, row)
+				issue.KeptID = kept.ID
+				report.Duplicates = append(report.Duplicates, issue)
+			}
+		}
+
+		// 3. 只有启用的行需要处理，已禁用的过期行不影响路由
+		if !kept.Enabled {
+			continue
+		}
+		ch := channelByID[key.channelID]
+		switch {
+		case !supportsModel(ch, key.model):
+			report.StaleModels = append(report.StaleModels, newIssue(IssueStaleModel, kept))
+		case channelDisabled(ch):
+			report.EnabledOnDisabled = append(report.EnabledOnDisabled, newIssue(IssueEnabledOnDisabled, kept))
+		}
+	}
+
+	for _, issues := range [][]Issue{report.Orphans, report.Duplicates, report.StaleModels, report.EnabledOnDisabled} {
+		sort.Slice(issues, func(i, j int) bool { return issues[i].AbilityID < issues[j].AbilityID })
+	}
+
+	return report
+}
+
+// newIssue 根据能力行构造问题记录
+func newIssue(kind IssueKind, ability *model.ChannelAbility) Issue {
+	return Issue{
+		Kind:      kind,
+		AbilityID: ability.ID,
+		ChannelID: ability.ChannelID,
+		Model:     ability.Model,
+		Group:     ability.Group,
+	}
+}
+
+// supportsModel 模型是否在渠道声明（或模型发现写入）的列表中
+func supportsModel(ch *model.Channel, modelName string) bool {
+	for _, m := range ch.GetSupportedModels() {
+		if m == modelName {
+			return true
+		}
+	}
+	return false
+}
+
+// channelDisabled 渠道是否已被手动或自动禁用
+func channelDisabled(ch *model.Channel) bool {
+	return !ch.Enabled || ch.Status == model.ChannelStatusDisabled || ch.Status == model.ChannelStatusAutoDisabled
+}
+
+// Checker 基于数据库的渠道能力一致性检查与修复
+type Checker struct {
+	db *gorm.DB
+}
+
+// NewChecker 创建一致性检查器
+func NewChecker(db *gorm.DB) *Checker {
+	return &Checker{db: db}
+}
+
+// Run 执行检查；repair 为 true 时在同一事务内删除孤儿行与重复行、禁用过期行
+func (c *Checker) Run(ctx context.Context, repair bool) (*Report, error) {
+	if !repair {
+		return c.inspect(c.db.WithContext(ctx))
+	}
+
+	var report *Report
+	err := c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		// 锁定能力表，避免修复期间同步任务写入新行
+		if err = tx.Exec("LOCK TABLE channel_abilities IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			return fmt.Errorf("failed to lock channel_abilities: %w", err)
+		}
+		if report, err = c.inspect(tx); err != nil {
+			return err
+		}
+
+		if ids := report.DeleteIDs(); len(ids) > 0 {
+			result := tx.Where("id IN ?", ids).Delete(&model.ChannelAbility{})
+			if result.Error != nil {
+				return fmt.Errorf("failed to delete abilities: %w", result.Error)
+			}
+			report.Deleted = int(result.RowsAffected)
+		}
+
+		if ids := report.DisableIDs(); len(ids) > 0 {
+			result := tx.Model(&model.ChannelAbility{}).Where("id IN ?", ids).
+				Updates(map[string]interface{}{"enabled": false, "updated_at": time.Now()})
+			if result.Error != nil {
+				return fmt.Errorf("failed to disable abilities: %w", result.Error)
+			}
+			report.Disabled = int(result.RowsAffected)
+		}
+
+		report.Repaired = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// inspect 读取全部渠道（含已软删除）与能力并检查
+func (c *Checker) inspect(db *gorm.DB) (*Report, error) {
+	var channels []model.Channel
+	if err := db.Find(&channels).Error; err != nil {
+		return nil, fmt.Errorf("failed to query channels: %w", err)
+	}
+
+	var abilities []model.ChannelAbility
+	if err := db.Order("id").Find(&abilities).Error; err != nil {
+		return nil, fmt.Errorf("failed to query channel abilities: %w", err)
+	}
+
+	return Inspect(channels, abilities), nil
+}
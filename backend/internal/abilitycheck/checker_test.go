@@ -0,0 +1,155 @@
+package abilitycheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// applyRepair 在内存中执行报告给出的修复，模拟事务内的删除与禁用
+func applyRepair(abilities []model.ChannelAbility, report *Report) []model.ChannelAbility {
+	deleted := make(map[int]bool)
+	for _, id := range report.DeleteIDs() {
+		deleted[id] = true
+	}
+	disabled := make(map[int]bool)
+	for _, id := range report.DisableIDs() {
+		disabled[id] = true
+	}
+
+	result := make([]model.ChannelAbility, 0, len(abilities))
+	for _, a := range abilities {
+		if deleted[a.ID] {
+			continue
+		}
+		if disabled[a.ID] {
+			a.Enabled = false
+		}
+		result = append(result, a)
+	}
+	return result
+}
+
+func seed() ([]model.Channel, []model.ChannelAbility) {
+	deletedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	older := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	channels := []model.Channel{
+		{ID: 1, Name: "openai", SupportModels: "gpt-4o, gpt-4o-mini", Status: model.ChannelStatusEnabled, Enabled: true},
+		{ID: 2, Name: "paused", SupportModels: "claude-3", Status: model.ChannelStatusDisabled, Enabled: true},
+		{ID: 3, Name: "removed", SupportModels: "gpt-4o", Status: model.ChannelStatusEnabled, Enabled: true, DeletedAt: &deletedAt},
+	}
+	abilities := []model.ChannelAbility{
+		// 正常
+		{ID: 10, ChannelID: 1, Model: "gpt-4o", Group: "default", Enabled: true, UpdatedAt: older},
+		// 重复：11 更新更晚，保留 11
+		{ID: 11, ChannelID: 1, Model: "gpt-4o-mini", Group: "default", Enabled: true, UpdatedAt: newer},
+		{ID: 12, ChannelID: 1, Model: "gpt-4o-mini", Group: "default", Enabled: true, UpdatedAt: older},
+		// 过期模型
+		{ID: 13, ChannelID: 1, Model: "gpt-3.5-turbo", Group: "default", Enabled: true, UpdatedAt: older},
+		// 已禁用的过期模型不算问题
+		{ID: 14, ChannelID: 1, Model: "gpt-3", Group: "default", Enabled: false, UpdatedAt: older},
+		// 已禁用渠道上的启用行
+		{ID: 20, ChannelID: 2, Model: "claude-3", Group: "default", Enabled: true, UpdatedAt: older},
+		// 孤儿：渠道已软删除 / 不存在
+		{ID: 30, ChannelID: 3, Model: "gpt-4o", Group: "default", Enabled: true, UpdatedAt: older},
+		{ID: 40, ChannelID: 99, Model: "gpt-4o", Group: "default", Enabled: true, UpdatedAt: older},
+	}
+	return channels, abilities
+}
+
+func issueIDs(issues []Issue) []int {
+	ids := make([]int, 0, len(issues))
+	for _, issue := range issues {
+		ids = append(ids, issue.AbilityID)
+	}
+	return ids
+}
+
+func TestInspectFindsEachInconsistencyClass(t *testing.T) {
+	channels, abilities := seed()
+	report := Inspect(channels, abilities)
+
+	assert.Equal(t, []int{30, 40}, issueIDs(report.Orphans))
+	assert.Equal(t, []int{12}, issueIDs(report.Duplicates))
+	assert.Equal(t, 11, report.Duplicates[0].KeptID)
+	assert.Equal(t, []int{13}, issueIDs(report.StaleModels))
+	assert.Equal(t, []int{20}, issueIDs(report.EnabledOnDisabled))
+	assert.Equal(t, 5, report.Total())
+	assert.False(t, report.Repaired)
+}
+
+func TestRepairOutcome(t *testing.T) {
+	channels, abilities := seed()
+	repaired := applyRepair(abilities, Inspect(channels, abilities))
+
+	state := make(map[int]bool)
+	for _, a := range repaired {
+		state[a.ID] = a.Enabled
+	}
+	assert.Equal(t, map[int]bool{
+		10: true,
+		11: true,
+		13: false,
+		14: false,
+		20: false,
+	}, state)
+
+	// 修复后再次检查应当没有任何不一致
+	assert.Zero(t, Inspect(channels, repaired).Total())
+}
+
+func TestInspectDuplicateTieKeepsHigherID(t *testing.T) {
+	at := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	channels := []model.Channel{{ID: 1, SupportModels: "m", Status: model.ChannelStatusEnabled, Enabled: true}}
+	abilities := []model.ChannelAbility{
+		{ID: 5, ChannelID: 1, Model: "m", Group: "vip", Enabled: true, UpdatedAt: at},
+		{ID: 3, ChannelID: 1, Model: "m", Group: "vip", Enabled: true, UpdatedAt: at},
+		{ID: 4, ChannelID: 1, Model: "m", Group: "default", Enabled: true, UpdatedAt: at},
+	}
+
+	report := Inspect(channels, abilities)
+	require.Len(t, report.Duplicates, 1)
+	assert.Equal(t, 3, report.Duplicates[0].AbilityID)
+	assert.Equal(t, 5, report.Duplicates[0].KeptID)
+}
+
+func TestSchedulerNotifiesAboveThreshold(t *testing.T) {
+	channels, abilities := seed()
+	var notified []*Report
+
+	s := &Scheduler{
+		check: func(ctx context.Context, repair bool) (*Report, error) {
+			assert.False(t, repair, "scheduled checks must be report-only")
+			return Inspect(channels, abilities), nil
+		},
+		notify: func(ctx context.Context, report *Report) error {
+			notified = append(notified, report)
+			return nil
+		},
+		cfg: &SchedulerConfig{Threshold: 5},
+	}
+
+	_, err := s.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, notified, "drift equal to threshold should not notify")
+
+	s.cfg.Threshold = 4
+	report, err := s.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Len(t, notified, 1)
+	assert.Same(t, report, notified[0])
+
+	s.check = func(ctx context.Context, repair bool) (*Report, error) {
+		return nil, errors.New("db down")
+	}
+	_, err = s.RunOnce(context.Background())
+	assert.Error(t, err)
+	assert.Len(t, notified, 1)
+}
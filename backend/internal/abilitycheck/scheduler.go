@@ -0,0 +1,143 @@
+package abilitycheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"go.uber.org/zap"
+)
+
+// Notifier 不一致数量超过阈值时的通知
+type Notifier func(ctx context.Context, report *Report) error
+
+// SchedulerConfig 定时检查配置
+type SchedulerConfig struct {
+	Interval  time.Duration // 检查间隔
+	Threshold int           // 不一致数量超过该值时发送通知
+}
+
+// DefaultSchedulerConfig 默认每周检查一次，出现任何不一致即通知
+func DefaultSchedulerConfig() *SchedulerConfig {
+	return &SchedulerConfig{
+		Interval:  7 * 24 * time.Hour,
+		Threshold: 0,
+	}
+}
+
+// Scheduler 定时以只读模式检查渠道能力一致性，不做任何修复
+type Scheduler struct {
+	check  func(ctx context.Context, repair bool) (*Report, error)
+	notify Notifier
+	cfg    *SchedulerConfig
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewScheduler 创建定时检查任务，notifier 为空时只记录告警日志
+func NewScheduler(checker *Checker, notifier Notifier, cfg *SchedulerConfig) *Scheduler {
+	if cfg == nil {
+		cfg = DefaultSchedulerConfig()
+	}
+	return &Scheduler{
+		check:  checker.Run,
+		notify: notifier,
+		cfg:    cfg,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start 启动定时检查
+func (s *Scheduler) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			if _, err := s.RunOnce(ctx); err != nil {
+				logger.Error("channel ability check failed", zap.Error(err))
+			}
+			cancel()
+		}
+	}()
+}
+
+// Stop 停止定时检查
+func (s *Scheduler) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+}
+
+// RunOnce 执行一次只读检查，超过阈值时发送通知
+func (s *Scheduler) RunOnce(ctx context.Context) (*Report, error) {
+	report, err := s.check(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Total() <= s.cfg.Threshold {
+		return report, nil
+	}
+
+	logger.Warn("channel ability drift detected",
+		zap.Int("total", report.Total()),
+		zap.Int("orphans", len(report.Orphans)),
+		zap.Int("duplicates", len(report.Duplicates)),
+		zap.Int("stale_models", len(report.StaleModels)),
+		zap.Int("enabled_on_disabled", len(report.EnabledOnDisabled)))
+
+	if s.notify != nil {
+		if err := s.notify(ctx, report); err != nil {
+			return report, fmt.Errorf("failed to send drift notification: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// NewWebhookNotifier 以 JSON POST 的方式将报告发送到 Webhook
+func NewWebhookNotifier(url string) Notifier {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return func(ctx context.Context, report *Report) error {
+		body, err := json.Marshal(map[string]interface{}{
+			"event":  "channel_ability_drift",
+			"total":  report.Total(),
+			"report": report,
+		})
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
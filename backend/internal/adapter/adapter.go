@@ -6,32 +6,63 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tracing"
 )
 
 // OpenAIRequest OpenAI 标准请求格式
 type OpenAIRequest struct {
-	Model            string                 `json:"model"`
-	Messages         []Message              `json:"messages"`
-	Temperature      float32                `json:"temperature,omitempty"`
-	MaxTokens        int                    `json:"max_tokens,omitempty"`
-	TopP             float32                `json:"top_p,omitempty"`
-	FrequencyPenalty float32                `json:"frequency_penalty,omitempty"`
-	PresencePenalty  float32                `json:"presence_penalty,omitempty"`
-	Stop             []string               `json:"stop,omitempty"`
-	Tools            []Tool                 `json:"tools,omitempty"`
-	Stream           bool                   `json:"stream,omitempty"`
-	User             string                 `json:"user,omitempty"`
-	Extra            map[string]interface{} `json:"extra,omitempty"`
+	Model               string                 `json:"model"`
+	Messages            []Message              `json:"messages"`
+	Temperature         float32                `json:"temperature,omitempty"`
+	MaxTokens           int                    `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int                    `json:"max_completion_tokens,omitempty"`
+	TopP                float32                `json:"top_p,omitempty"`
+	FrequencyPenalty    float32                `json:"frequency_penalty,omitempty"`
+	PresencePenalty     float32                `json:"presence_penalty,omitempty"`
+	Stop                []string               `json:"stop,omitempty"`
+	Tools               []Tool                 `json:"tools,omitempty"`
+	ToolChoice          interface{}            `json:"tool_choice,omitempty"`
+	ParallelToolCalls   *bool                  `json:"parallel_tool_calls,omitempty"`
+	ResponseFormat      interface{}            `json:"response_format,omitempty"`
+	Stream              bool                   `json:"stream,omitempty"`
+	StreamOptions       *StreamOptions         `json:"stream_options,omitempty"`
+	User                string                 `json:"user,omitempty"`
+	ReasoningEffort     string                 `json:"reasoning_effort,omitempty"`
+	Extra               map[string]interface{} `json:"extra,omitempty"`
+}
+
+// StreamOptions 流式请求选项
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"` // 在最后一个数据块中返回用量
 }
 
 // Message 消息结构
 type Message struct {
-	Role    string      `json:"role"`
-	Content interface{} `json:"content"`
-	Name    string      `json:"name,omitempty"`
+	Role       string      `json:"role"`
+	Content    interface{} `json:"content"`
+	Name       string      `json:"name,omitempty"`
+	ToolCalls  []ToolCall  `json:"tool_calls,omitempty"`
+	ToolCallID string      `json:"tool_call_id,omitempty"`
+}
+
+// ToolCall 助手消息中的工具调用
+type ToolCall struct {
+	Index    *int             `json:"index,omitempty"` // 流式增量中的调用序号
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction 工具调用的函数名与 JSON 编码的参数
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 // Tool 工具定义
@@ -47,6 +78,60 @@ type ToolFunction struct {
 	Parameters  interface{} `json:"parameters"`
 }
 
+// setToolParams 将工具定义、调用参数与 response_format 原样写入 OpenAI 兼容的请求体，未设置的参数不发送
+func setToolParams(body map[string]interface{}, req *OpenAIRequest) {
+	if len(req.Tools) > 0 {
+		body["tools"] = req.Tools
+	}
+	if req.ToolChoice != nil {
+		body["tool_choice"] = req.ToolChoice
+	}
+	if req.ParallelToolCalls != nil {
+		body["parallel_tool_calls"] = *req.ParallelToolCalls
+	}
+	if req.ResponseFormat != nil {
+		body["response_format"] = req.ResponseFormat
+	}
+}
+
+// jsonResponseFormat 请求是否要求 JSON 输出，json_schema 格式同时返回其中的 schema
+func jsonResponseFormat(req *OpenAIRequest) (schema interface{}, ok bool) {
+	format, _ := req.ResponseFormat.(map[string]interface{})
+	switch format["type"] {
+	case "json_object":
+		return nil, true
+	case "json_schema":
+		spec, _ := format["json_schema"].(map[string]interface{})
+		return spec["schema"], true
+	default:
+		return nil, false
+	}
+}
+
+// parseDataURL 解析 data:<mime>;base64,<data> 形式的内联图片，其他 URL 返回 false
+func parseDataURL(url string) (mimeType, data string, ok bool) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return "", "", false
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	mimeType, isBase64 := strings.CutSuffix(meta, ";base64")
+	if !ok || !isBase64 || mimeType == "" {
+		return "", "", false
+	}
+	return mimeType, data, true
+}
+
+// imagePartURL OpenAI 格式内容片段中的图片 URL，不是图片片段时返回 false
+func imagePartURL(part map[string]interface{}) (string, bool) {
+	if part["type"] != "image_url" {
+		return "", false
+	}
+	image, _ := part["image_url"].(map[string]interface{})
+	url, ok := image["url"].(string)
+	return url, ok && url != ""
+}
+
 // OpenAIResponse OpenAI 标准响应格式
 type OpenAIResponse struct {
 	ID      string     `json:"id"`
@@ -68,9 +153,23 @@ type Choice struct {
 
 // Usage 使用情况
 type Usage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens            int                      `json:"prompt_tokens"`
+	CompletionTokens        int                      `json:"completion_tokens"`
+	TotalTokens             int                      `json:"total_tokens"`
+	CompletionTokensDetails *CompletionTokensDetails `json:"completion_tokens_details,omitempty"`
+}
+
+// CompletionTokensDetails 输出 Token 明细
+type CompletionTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
+// ReasoningTokens 推理 Token 数（已包含在 CompletionTokens 中）
+func (u *Usage) ReasoningTokens() int {
+	if u == nil || u.CompletionTokensDetails == nil {
+		return 0
+	}
+	return u.CompletionTokensDetails.ReasoningTokens
 }
 
 // ErrorInfo 错误信息
@@ -89,6 +188,9 @@ type StreamChunk struct {
 	Model   string   `json:"model,omitempty"`
 	Choices []Choice `json:"choices,omitempty"`
 	Usage   *Usage   `json:"usage,omitempty"`
+
+	// Err 流式响应中途的上游错误，出现时为最后一个数据块
+	Err *StreamError `json:"-"`
 }
 
 // AdapterConfig 适配器配置
@@ -108,6 +210,21 @@ type AdapterConfig struct {
 	// 超时时间
 	Timeout time.Duration
 
+	// 透传本系统请求 ID 的请求头（为空则不透传）
+	RequestIDHeader string
+
+	// 允许透传到上游的 extra_body 字段
+	ExtraBodyAllowlist []string
+
+	// 注入每个上游请求的静态请求头，值中的 {api_key} 替换为渠道密钥
+	Headers map[string]string
+
+	// 追加到每个上游请求 URL 的静态查询参数（如 api-version）
+	QueryParams map[string]string
+
+	// 模型到部署名的映射（Azure OpenAI 按部署路由）
+	Deployments map[string]string
+
 	// 额外配置
 	Extra map[string]interface{}
 }
@@ -147,7 +264,14 @@ type BaseAdapter struct {
 	config          *AdapterConfig
 	httpClient      *http.Client
 	supportedModels []string
-	mu              sync.RWMutex
+	// 提供方返回请求 ID 的响应头
+	requestIDHeaders []string
+	// 携带渠道密钥的请求头与前缀，默认 Authorization: Bearer <key>
+	authHeader string
+	authScheme string
+	// 提供方要求的固定请求头（如 anthropic-version），渠道配置的请求头可以覆盖
+	defaultHeaders map[string]string
+	mu             sync.RWMutex
 }
 
 // NewBaseAdapter 创建基础适配器
@@ -159,6 +283,8 @@ func NewBaseAdapter(config *AdapterConfig) *BaseAdapter {
 	return &BaseAdapter{
 		config:     config,
 		httpClient: client,
+		authHeader: "Authorization",
+		authScheme: "Bearer ",
 	}
 }
 
@@ -186,6 +312,43 @@ func (ba *BaseAdapter) SetSupportedModels(models []string) {
 	ba.supportedModels = models
 }
 
+// SetRequestIDHeaders 设置提供方返回请求 ID 的响应头
+func (ba *BaseAdapter) SetRequestIDHeaders(headers ...string) {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+
+	ba.requestIDHeaders = headers
+}
+
+// SetAuthHeader 设置携带渠道密钥的请求头，scheme 为密钥前的前缀（如 "Bearer "），可为空
+func (ba *BaseAdapter) SetAuthHeader(name, scheme string) {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+
+	ba.authHeader = name
+	ba.authScheme = scheme
+}
+
+// SetDefaultHeaders 设置每个上游请求都携带的固定请求头
+func (ba *BaseAdapter) SetDefaultHeaders(headers map[string]string) {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+
+	ba.defaultHeaders = headers
+}
+
+// UpstreamRequestID 从响应头中提取提供方请求 ID
+func (ba *BaseAdapter) UpstreamRequestID(resp *http.Response) string {
+	ba.mu.RLock()
+	headers := ba.requestIDHeaders
+	ba.mu.RUnlock()
+
+	if id := headerValue(resp.Header, headers); id != "" {
+		return id
+	}
+	return headerValue(resp.Header, commonRequestIDHeaders)
+}
+
 // NewRequest 创建 HTTP 请求
 func (ba *BaseAdapter) NewRequest(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
 	url := ba.config.BaseURL + path
@@ -212,17 +375,79 @@ func (ba *BaseAdapter) NewRequest(ctx context.Context, method, path string, body
 	if bodyBytes != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	ba.prepareRequest(ctx, req)
+
+	return req, nil
+}
+
+// MultipartFile multipart 请求中的文件字段
+type MultipartFile struct {
+	Field    string
+	Filename string
+	Content  io.Reader
+}
+
+// NewMultipartRequest 创建 multipart/form-data 请求（如音频转写、图片编辑）
+func (ba *BaseAdapter) NewMultipartRequest(ctx context.Context, path string, fields map[string]string, files []MultipartFile) (*http.Request, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return nil, fmt.Errorf("failed to write field %s: %v", name, err)
+		}
+	}
+	for _, file := range files {
+		part, err := writer.CreateFormFile(file.Field, file.Filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create file field %s: %v", file.Field, err)
+		}
+		if _, err := io.Copy(part, file.Content); err != nil {
+			return nil, fmt.Errorf("failed to write file field %s: %v", file.Field, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close multipart body: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ba.config.BaseURL+path, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	ba.prepareRequest(ctx, req)
 
+	return req, nil
+}
+
+// prepareRequest 添加认证、透传请求 ID 与追踪上下文，并应用渠道配置的静态请求头与查询参数
+//
+// JSON 与 multipart 请求、渠道测试都经过这里，保证注入行为一致。
+func (ba *BaseAdapter) prepareRequest(ctx context.Context, req *http.Request) {
 	// 添加认证
 	ba.addAuthHeader(req)
 
-	return req, nil
+	// 透传请求 ID，便于与上游日志关联
+	if ba.config.RequestIDHeader != "" {
+		if requestID := RequestIDFromContext(ctx); requestID != "" {
+			req.Header.Set(ba.config.RequestIDHeader, requestID)
+		}
+	}
+	// 传递追踪上下文，上游支持 W3C Trace Context 时可关联到同一条追踪
+	tracing.Inject(ctx, req.Header)
+
+	ba.applyUpstreamOverrides(req)
 }
 
-// addAuthHeader 添加认证头
+// addAuthHeader 添加认证头与提供方要求的固定请求头
 func (ba *BaseAdapter) addAuthHeader(req *http.Request) {
+	ba.mu.RLock()
+	defer ba.mu.RUnlock()
+
 	if ba.config.APIKey != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ba.config.APIKey))
+		req.Header.Set(ba.authHeader, ba.authScheme+ba.config.APIKey)
+	}
+	for name, value := range ba.defaultHeaders {
+		req.Header.Set(name, value)
 	}
 }
 
@@ -241,6 +466,21 @@ func (ba *BaseAdapter) DoHTTPRequest(ctx context.Context, method, path string, b
 	return resp, nil
 }
 
+// DoMultipartRequest 执行 multipart/form-data 请求
+func (ba *BaseAdapter) DoMultipartRequest(ctx context.Context, path string, fields map[string]string, files []MultipartFile) (*http.Response, error) {
+	req, err := ba.NewMultipartRequest(ctx, path, fields, files)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ba.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request failed: %v", err)
+	}
+
+	return resp, nil
+}
+
 // AdapterMetrics 适配器指标
 type AdapterMetrics struct {
 	// 总请求数
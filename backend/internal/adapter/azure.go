@@ -0,0 +1,98 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// AzureAPIVersion 渠道未配置 api_version 时使用的 Azure OpenAI API 版本
+const AzureAPIVersion = "2024-10-21"
+
+// AzureOpenAIAdapter Azure OpenAI 适配器
+//
+// 请求与响应格式与 OpenAI 兼容，区别在于：密钥放在 api-key 请求头，URL 按部署区分
+// （/openai/deployments/{deployment}/chat/completions），并且必须携带 api-version 查询参数。
+// 模型到部署名的映射来自 AdapterConfig.Deployments，未配置的模型去掉 "." 后作为部署名
+// （Azure 部署名不允许包含 "."，如 gpt-3.5-turbo 通常部署为 gpt-35-turbo）。
+type AzureOpenAIAdapter struct {
+	*OpenAIAdapter
+}
+
+// azureChatRequest 发往 Azure 的请求，模型由 URL 中的部署决定，请求体不带 model
+type azureChatRequest struct {
+	*OpenAIRequest
+	Model      string `json:"model,omitempty"`
+	Deployment string `json:"-"`
+}
+
+// NewAzureOpenAIAdapter 创建 Azure OpenAI 适配器
+func NewAzureOpenAIAdapter(config *AdapterConfig) *AzureOpenAIAdapter {
+	adapter := &AzureOpenAIAdapter{
+		OpenAIAdapter: NewOpenAIAdapter(config),
+	}
+
+	adapter.SetAuthHeader("api-key", "")
+	adapter.SetRequestIDHeaders("apim-request-id", "X-Request-Id")
+	if len(config.Deployments) > 0 {
+		models := make([]string, 0, len(config.Deployments))
+		for model := range config.Deployments {
+			models = append(models, model)
+		}
+		sort.Strings(models)
+		adapter.SetSupportedModels(models)
+	}
+
+	return adapter
+}
+
+// Deployment 模型对应的部署名
+func (aa *AzureOpenAIAdapter) Deployment(model string) string {
+	if deployment := aa.config.Deployments[model]; deployment != "" {
+		return deployment
+	}
+	return strings.ReplaceAll(model, ".", "")
+}
+
+// APIVersion 请求使用的 api-version
+func (aa *AzureOpenAIAdapter) APIVersion() string {
+	if aa.config.Version != "" {
+		return aa.config.Version
+	}
+	return AzureAPIVersion
+}
+
+// ConvertRequest 转换请求，参数调整与 OpenAI 一致，并确定目标部署
+func (aa *AzureOpenAIAdapter) ConvertRequest(req *OpenAIRequest) (interface{}, error) {
+	if _, err := aa.AdaptParams(req); err != nil {
+		return nil, err
+	}
+	return &azureChatRequest{OpenAIRequest: req, Deployment: aa.Deployment(req.Model)}, nil
+}
+
+// DoRequest 发送请求到部署的 chat/completions 接口
+func (aa *AzureOpenAIAdapter) DoRequest(ctx context.Context, convertedReq interface{}) (*http.Response, error) {
+	var req *azureChatRequest
+	switch r := convertedReq.(type) {
+	case *azureChatRequest:
+		req = r
+	case *OpenAIRequest:
+		req = &azureChatRequest{OpenAIRequest: r, Deployment: aa.Deployment(r.Model)}
+	default:
+		return nil, fmt.Errorf("invalid request type")
+	}
+	if req.Deployment == "" {
+		return nil, fmt.Errorf("no azure deployment for model %q", req.OpenAIRequest.Model)
+	}
+
+	return aa.DoHTTPRequest(ctx, "POST", aa.deploymentPath(req.Deployment, "/chat/completions"), req)
+}
+
+// deploymentPath 部署接口的路径，api-version 作为查询参数（渠道配置的同名查询参数优先）
+func (aa *AzureOpenAIAdapter) deploymentPath(deployment, endpoint string) string {
+	return "/openai/deployments/" + url.PathEscape(deployment) + endpoint +
+		"?api-version=" + url.QueryEscape(aa.APIVersion())
+}
@@ -0,0 +1,136 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+func TestAzureRequestURLAndAuth(t *testing.T) {
+	var received *http.Request
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`))
+	}))
+	defer server.Close()
+
+	adapter := NewAzureOpenAIAdapter(&AdapterConfig{
+		Type:        "azure",
+		BaseURL:     server.URL,
+		APIKey:      "azure-key",
+		Deployments: map[string]string{"gpt-4o": "prod-gpt4o"},
+	})
+	converted, err := adapter.ConvertRequest(&OpenAIRequest{Model: "gpt-4o",
+		Messages: []Message{{Role: "user", Content: "Hi"}}})
+	if err != nil {
+		t.Fatalf("ConvertRequest failed: %v", err)
+	}
+	resp, err := adapter.DoRequest(context.Background(), converted)
+	if err != nil {
+		t.Fatalf("DoRequest failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if received.URL.Path != "/openai/deployments/prod-gpt4o/chat/completions" {
+		t.Errorf("Unexpected path %s", received.URL.Path)
+	}
+	if got := received.URL.Query().Get("api-version"); got != AzureAPIVersion {
+		t.Errorf("Expected api-version %s, got %q", AzureAPIVersion, got)
+	}
+	if got := received.Header.Get("api-key"); got != "azure-key" {
+		t.Errorf("Expected api-key auth, got %q", got)
+	}
+	if received.Header.Get("Authorization") != "" {
+		t.Error("Azure requests must not send a bearer token")
+	}
+	if _, ok := body["model"]; ok {
+		t.Errorf("Expected model to be routed by deployment, got body model %v", body["model"])
+	}
+	if len(body["messages"].([]interface{})) != 1 {
+		t.Errorf("Expected messages in body, got %v", body["messages"])
+	}
+
+	// 响应格式与 OpenAI 兼容
+	parsed, err := adapter.ParseResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseResponse failed: %v", err)
+	}
+	usage, err := adapter.ExtractUsage(parsed)
+	if err != nil || usage.TotalTokens != 4 {
+		t.Errorf("Expected usage to be extracted, got %+v (%v)", usage, err)
+	}
+}
+
+func TestAzureDeploymentMapping(t *testing.T) {
+	adapter := NewAzureOpenAIAdapter(&AdapterConfig{
+		Type:        "azure",
+		Version:     "2024-06-01",
+		Deployments: map[string]string{"gpt-4o": "prod-gpt4o", "gpt-4o-mini": "mini"},
+	})
+
+	tests := []struct {
+		model, deployment string
+	}{
+		{"gpt-4o", "prod-gpt4o"},
+		{"gpt-4o-mini", "mini"},
+		{"gpt-3.5-turbo", "gpt-35-turbo"}, // 未配置的模型去掉 "."
+	}
+	for _, tt := range tests {
+		if got := adapter.Deployment(tt.model); got != tt.deployment {
+			t.Errorf("Deployment(%s) = %s, want %s", tt.model, got, tt.deployment)
+		}
+	}
+
+	if got := adapter.deploymentPath("prod gpt", "/chat/completions"); got != "/openai/deployments/prod%20gpt/chat/completions?api-version=2024-06-01" {
+		t.Errorf("Unexpected deployment path %s", got)
+	}
+	if models := adapter.GetSupportedModels(); len(models) != 2 || models[0] != "gpt-4o" || models[1] != "gpt-4o-mini" {
+		t.Errorf("Expected mapped models to be supported, got %v", models)
+	}
+}
+
+func TestGetAdapterByChannelAzure(t *testing.T) {
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer server.Close()
+
+	channel := &model.Channel{
+		Type:          "azure",
+		BaseURL:       server.URL,
+		APIKey:        "azure-key",
+		OtherSettings: `{"deployments":{"gpt-4o":"prod-gpt4o"},"api_version":"2024-08-01-preview"}`,
+	}
+	a, err := GetAdapterByChannel(channel)
+	if err != nil {
+		t.Fatalf("GetAdapterByChannel failed: %v", err)
+	}
+	if _, ok := a.(*AzureOpenAIAdapter); !ok {
+		t.Fatalf("Expected azure channel to use AzureOpenAIAdapter, got %T", a)
+	}
+
+	converted, _ := a.ConvertRequest(&OpenAIRequest{Model: "gpt-4o", Messages: []Message{{Role: "user", Content: "Hi"}}})
+	resp, err := a.DoRequest(context.Background(), converted)
+	if err != nil {
+		t.Fatalf("DoRequest failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if received.URL.Path != "/openai/deployments/prod-gpt4o/chat/completions" {
+		t.Errorf("Unexpected path %s", received.URL.Path)
+	}
+	if got := received.URL.Query().Get("api-version"); got != "2024-08-01-preview" {
+		t.Errorf("Expected channel api-version, got %q", got)
+	}
+	if got := received.Header.Get("api-key"); got != "azure-key" {
+		t.Errorf("Expected api-key auth, got %q", got)
+	}
+}
@@ -35,6 +35,7 @@ func (da *DeepSeekAdapter) ConvertRequest(req *OpenAIRequest) (interface{}, erro
 		"temperature": req.Temperature,
 		"max_tokens":  req.MaxTokens,
 	}
+	setToolParams(deepseekReq, req)
 
 	return deepseekReq, nil
 }
@@ -119,6 +120,7 @@ func (ma *MoonshotAdapter) ConvertRequest(req *OpenAIRequest) (interface{}, erro
 		"temperature": req.Temperature,
 		"max_tokens":  req.MaxTokens,
 	}
+	setToolParams(moonshotReq, req)
 
 	return moonshotReq, nil
 }
@@ -185,6 +187,7 @@ func NewMinimaxAdapter(config *AdapterConfig) *MinimaxAdapter {
 	adapter.SetSupportedModels([]string{
 		"abab6.5-chat", "abab6.5s-chat", "abab5.5s-chat",
 	})
+	adapter.SetRequestIDHeaders("Trace-Id")
 
 	return adapter
 }
@@ -197,6 +200,7 @@ func (mma *MinimaxAdapter) ConvertRequest(req *OpenAIRequest) (interface{}, erro
 		"temperature": req.Temperature,
 		"max_tokens":  req.MaxTokens,
 	}
+	setToolParams(minimaxReq, req)
 
 	return minimaxReq, nil
 }
@@ -0,0 +1,267 @@
+package adapter
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// structuralParams 决定请求结构的字段，不允许由渠道参数配置
+var structuralParams = map[string]bool{
+	"model":               true,
+	"messages":            true,
+	"stream":              true,
+	"tools":               true,
+	"tool_choice":         true,
+	"parallel_tool_calls": true,
+	"response_format":     true,
+	"extra":               true,
+}
+
+// requestParamFields OpenAIRequest 中可由渠道配置的字段下标，按 JSON 名称索引
+var requestParamFields = func() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(OpenAIRequest{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || structuralParams[name] {
+			continue
+		}
+		fields[name] = i
+	}
+	return fields
+}()
+
+// ValidateChannelParams 校验渠道的默认参数与强制参数
+//
+// 结构性字段（model、messages 等）不允许配置；已知字段的值须与字段类型匹配，未知字段原样透传。
+// 强制参数须设置 value 或 min/max 之一，min/max 只能用于数值。
+func ValidateChannelParams(defaults map[string]interface{}, overrides map[string]model.ParamOverride) error {
+	var scratch OpenAIRequest
+	check := func(name string, value interface{}) error {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("parameter name must not be empty")
+		}
+		if structuralParams[name] {
+			return fmt.Errorf("parameter %s cannot be configured per channel", name)
+		}
+		if i, ok := requestParamFields[name]; ok && value != nil {
+			if err := setParamField(reflect.ValueOf(&scratch).Elem().Field(i), value); err != nil {
+				return fmt.Errorf("parameter %s: %w", name, err)
+			}
+		}
+		return nil
+	}
+
+	for name, value := range defaults {
+		if err := check(name, value); err != nil {
+			return err
+		}
+	}
+	for name, o := range overrides {
+		if err := check(name, o.Value); err != nil {
+			return err
+		}
+		bounded := o.Min != nil || o.Max != nil
+		switch {
+		case o.Value == nil && !bounded:
+			return fmt.Errorf("override for %s requires value, min or max", name)
+		case o.Value != nil && bounded:
+			return fmt.Errorf("override for %s cannot set both value and min/max", name)
+		case o.Min != nil && o.Max != nil && *o.Min > *o.Max:
+			return fmt.Errorf("override for %s has min greater than max", name)
+		}
+		if i, ok := requestParamFields[name]; ok && bounded {
+			kind := reflect.TypeOf(scratch).Field(i).Type.Kind()
+			if !isNumericKind(kind) {
+				return fmt.Errorf("min/max override for %s requires a numeric parameter", name)
+			}
+			for _, bound := range []*float64{o.Min, o.Max} {
+				if bound != nil && (kind == reflect.Int || kind == reflect.Int64) && *bound != math.Trunc(*bound) {
+					return fmt.Errorf("min/max override for %s must be an integer", name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyChannelParams 应用渠道的默认参数与强制参数，返回用户设置的值被修改时的说明
+//
+// 默认参数只填充用户未设置（零值）的字段；强制参数替换字段的值，或将用户设置的数值限制在范围内。
+// OpenAIRequest 中没有的字段写入 Extra 透传给上游。配置应已通过 ValidateChannelParams 校验，
+// 类型不匹配的参数被忽略。
+func ApplyChannelParams(req *OpenAIRequest, defaults map[string]interface{}, overrides map[string]model.ParamOverride) []string {
+	for _, name := range sortedKeys(defaults) {
+		if structuralParams[name] {
+			continue
+		}
+		if i, ok := requestParamFields[name]; ok {
+			if field := reflect.ValueOf(req).Elem().Field(i); field.IsZero() {
+				_ = setParamField(field, defaults[name])
+			}
+			continue
+		}
+		if _, ok := req.Extra[name]; !ok {
+			setExtra(req, name, defaults[name])
+		}
+	}
+
+	var notes []string
+	for _, name := range sortedKeys(overrides) {
+		if structuralParams[name] {
+			continue
+		}
+		o := overrides[name]
+		if i, ok := requestParamFields[name]; ok {
+			field := reflect.ValueOf(req).Elem().Field(i)
+			if field.IsZero() && o.Value == nil {
+				continue // 限制范围只作用于用户设置的值
+			}
+			userSet := !field.IsZero()
+			before := fmt.Sprint(field.Interface())
+			if o.Value != nil {
+				_ = setParamField(field, o.Value)
+			} else if isNumericKind(field.Kind()) {
+				_ = setParamField(field, clampParam(numericValue(field), o.Min, o.Max))
+			}
+			if after := fmt.Sprint(field.Interface()); userSet && after != before {
+				notes = append(notes, overrideNote(name, before, after, o))
+			}
+			continue
+		}
+
+		current, userSet := req.Extra[name]
+		switch {
+		case o.Value != nil:
+			setExtra(req, name, o.Value)
+		case userSet:
+			if n, ok := current.(float64); ok {
+				setExtra(req, name, clampParam(n, o.Min, o.Max))
+			}
+		}
+		if after, ok := req.Extra[name]; userSet && ok && fmt.Sprint(after) != fmt.Sprint(current) {
+			notes = append(notes, overrideNote(name, fmt.Sprint(current), fmt.Sprint(after), o))
+		}
+	}
+	return notes
+}
+
+// overrideNote 用户参数被渠道强制参数修改时的说明
+func overrideNote(name, before, after string, o model.ParamOverride) string {
+	if o.Value != nil {
+		return fmt.Sprintf("%s %s was replaced with %s by channel configuration", name, before, after)
+	}
+	return fmt.Sprintf("%s %s is outside the channel limit and was clamped to %s", name, before, after)
+}
+
+// setParamField 将配置中的 JSON 值写入请求字段
+func setParamField(field reflect.Value, value interface{}) error {
+	switch field.Kind() {
+	case reflect.Float32, reflect.Float64:
+		n, ok := toFloat(value)
+		if !ok {
+			return fmt.Errorf("expected a number, got %T", value)
+		}
+		field.SetFloat(n)
+	case reflect.Int, reflect.Int64:
+		n, ok := toFloat(value)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("expected an integer, got %v", value)
+		}
+		field.SetInt(int64(n))
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected a string, got %T", value)
+		}
+		field.SetString(s)
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("expected a boolean, got %T", value)
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported parameter type %s", field.Type())
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected a list of strings, got %T", value)
+		}
+		list := make([]string, 0, len(items))
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("expected a list of strings, got %T item", item)
+			}
+			list = append(list, s)
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported parameter type %s", field.Type())
+	}
+	return nil
+}
+
+// setExtra 写入透传给上游的参数
+func setExtra(req *OpenAIRequest, name string, value interface{}) {
+	if req.Extra == nil {
+		req.Extra = make(map[string]interface{})
+	}
+	req.Extra[name] = value
+}
+
+// clampParam 将数值限制在 [min, max] 内，未设置的一侧不限制
+func clampParam(n float64, min, max *float64) float64 {
+	if min != nil && n < *min {
+		n = *min
+	}
+	if max != nil && n > *max {
+		n = *max
+	}
+	return n
+}
+
+func isNumericKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func numericValue(field reflect.Value) float64 {
+	if field.Kind() == reflect.Int || field.Kind() == reflect.Int64 {
+		return float64(field.Int())
+	}
+	return field.Float()
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
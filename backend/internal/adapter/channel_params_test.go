@@ -0,0 +1,94 @@
+package adapter
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// channelParams 按渠道配置中的 JSON 解析默认参数与强制参数
+func channelParams(t *testing.T, raw string) model.ChannelSettings {
+	t.Helper()
+	var settings model.ChannelSettings
+	if err := json.Unmarshal([]byte(raw), &settings); err != nil {
+		t.Fatalf("invalid settings: %v", err)
+	}
+	if err := ValidateChannelParams(settings.ParamDefaults, settings.ParamOverrides); err != nil {
+		t.Fatalf("ValidateChannelParams failed: %v", err)
+	}
+	return settings
+}
+
+func TestApplyChannelParamsClampsMaxTokens(t *testing.T) {
+	settings := channelParams(t, `{"param_overrides":{"max_tokens":{"max":2048},"temperature":{"max":1}}}`)
+
+	req := &OpenAIRequest{Model: "gpt-4o", MaxTokens: 8000, Temperature: 0.5}
+	notes := ApplyChannelParams(req, settings.ParamDefaults, settings.ParamOverrides)
+	if req.MaxTokens != 2048 {
+		t.Errorf("Expected max_tokens clamped to 2048, got %d", req.MaxTokens)
+	}
+	if req.Temperature != 0.5 {
+		t.Errorf("Temperature within the limit must be kept, got %v", req.Temperature)
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "max_tokens 8000") || !strings.Contains(notes[0], "2048") {
+		t.Errorf("Expected an audit note for max_tokens, got %v", notes)
+	}
+
+	// 用户未设置时不受范围限制影响，也没有说明
+	req = &OpenAIRequest{Model: "gpt-4o"}
+	if notes := ApplyChannelParams(req, settings.ParamDefaults, settings.ParamOverrides); len(notes) != 0 || req.MaxTokens != 0 {
+		t.Errorf("Expected unset max_tokens to stay unset, got %d with notes %v", req.MaxTokens, notes)
+	}
+}
+
+func TestApplyChannelParamsInjectsDefaultTemperature(t *testing.T) {
+	settings := channelParams(t, `{
+		"param_defaults": {"temperature": 0.7, "max_tokens": 1024, "safe_mode": true},
+		"param_overrides": {"top_p": {"value": 0.9}, "repetition_penalty": {"max": 1.2}}
+	}`)
+
+	req := &OpenAIRequest{Model: "gpt-4o", MaxTokens: 300, TopP: 0.5, Extra: map[string]interface{}{"repetition_penalty": 1.5}}
+	notes := ApplyChannelParams(req, settings.ParamDefaults, settings.ParamOverrides)
+	if req.Temperature != 0.7 {
+		t.Errorf("Expected default temperature 0.7, got %v", req.Temperature)
+	}
+	if req.MaxTokens != 300 {
+		t.Errorf("Defaults must not replace user values, got max_tokens %d", req.MaxTokens)
+	}
+	if req.TopP != 0.9 {
+		t.Errorf("Expected top_p replaced with 0.9, got %v", req.TopP)
+	}
+	// 未知字段透传给上游
+	want := map[string]interface{}{"safe_mode": true, "repetition_penalty": 1.2}
+	if !reflect.DeepEqual(req.Extra, want) {
+		t.Errorf("Expected unknown params passed through, got %v", req.Extra)
+	}
+	if len(notes) != 2 || !strings.HasPrefix(notes[0], "repetition_penalty 1.5") || !strings.HasPrefix(notes[1], "top_p 0.5 was replaced with 0.9") {
+		t.Errorf("Expected audit notes for modified user values, got %v", notes)
+	}
+}
+
+func TestValidateChannelParams(t *testing.T) {
+	max := 1.0
+	min := 2.0
+	for name, tc := range map[string]struct {
+		defaults  map[string]interface{}
+		overrides map[string]model.ParamOverride
+	}{
+		"messages default":      {defaults: map[string]interface{}{"messages": []interface{}{}}},
+		"model override":        {overrides: map[string]model.ParamOverride{"model": {Value: "gpt-4o"}}},
+		"wrong type":            {defaults: map[string]interface{}{"max_tokens": "many"}},
+		"fractional max_tokens": {defaults: map[string]interface{}{"max_tokens": 1.5}},
+		"empty override":        {overrides: map[string]model.ParamOverride{"top_p": {}}},
+		"value and bounds":      {overrides: map[string]model.ParamOverride{"top_p": {Value: 0.5, Max: &max}}},
+		"min above max":         {overrides: map[string]model.ParamOverride{"top_p": {Min: &min, Max: &max}}},
+		"bounds on a string":    {overrides: map[string]model.ParamOverride{"user": {Max: &max}}},
+	} {
+		if err := ValidateChannelParams(tc.defaults, tc.overrides); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
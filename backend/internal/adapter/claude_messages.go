@@ -0,0 +1,158 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ClaudeAPIVersion 未配置渠道版本时使用的 anthropic-version
+const ClaudeAPIVersion = "2023-06-01"
+
+// claudeDefaultMaxTokens 调用方未指定 max_tokens 时发送给 Claude 的默认值（Claude 要求必填）
+const claudeDefaultMaxTokens = 4096
+
+// claudeMessage Claude messages 数组中的一条消息
+type claudeMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+// contentText OpenAI 消息内容中的文本：字符串原样返回，内容片段数组拼接其中的文本片段
+func contentText(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []interface{}:
+		var parts []string
+		for _, part := range c {
+			if p, ok := part.(map[string]interface{}); ok && p["type"] == "text" {
+				if text, ok := p["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// mergeClaudeContent 合并连续同角色消息的内容：都是字符串时以空行连接，否则合并为内容块数组
+func mergeClaudeContent(a, b interface{}) interface{} {
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if aok && bok {
+		return as + "\n\n" + bs
+	}
+	blocks := append([]interface{}{}, claudeContentBlocks(a)...)
+	return append(blocks, claudeContentBlocks(b)...)
+}
+
+// claudeContent 将 OpenAI 消息内容转换为 Claude 格式：字符串原样保留，内容片段数组中的图片转换为图片块
+func claudeContent(content interface{}) interface{} {
+	parts, ok := content.([]interface{})
+	if !ok {
+		return content
+	}
+	blocks := make([]interface{}, 0, len(parts))
+	for _, item := range parts {
+		p, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if url, ok := imagePartURL(p); ok {
+			blocks = append(blocks, claudeImageBlock(url))
+			continue
+		}
+		blocks = append(blocks, p)
+	}
+	return blocks
+}
+
+// claudeImageBlock 图片块：data URL 转为 base64 source，其他 URL 由 Claude 拉取
+func claudeImageBlock(url string) map[string]interface{} {
+	source := map[string]interface{}{"type": "url", "url": url}
+	if mediaType, data, ok := parseDataURL(url); ok {
+		source = map[string]interface{}{"type": "base64", "media_type": mediaType, "data": data}
+	}
+	return map[string]interface{}{"type": "image", "source": source}
+}
+
+// claudeContentBlocks 将消息内容转换为内容块数组，字符串转换为单个文本块
+func claudeContentBlocks(content interface{}) []interface{} {
+	switch c := content.(type) {
+	case string:
+		return []interface{}{map[string]interface{}{"type": "text", "text": c}}
+	case []interface{}:
+		return c
+	case nil:
+		return nil
+	}
+	return []interface{}{content}
+}
+
+// ClaudeStreamEvent 原样透传的 Claude 流式事件
+//
+// 流异常结束（上游 error 事件、连接中断、没有 message_stop）时，
+// 以带 Err 的事件作为最后一个事件发出。
+type ClaudeStreamEvent struct {
+	Event string
+	Data  json.RawMessage
+	Err   *StreamError
+}
+
+// DoMessages 将 Anthropic Messages API 的请求体原样发送到上游（不经过 OpenAI 格式转换）
+func (ca *ClaudeAdapter) DoMessages(ctx context.Context, body []byte) (*http.Response, error) {
+	return ca.DoHTTPRequest(ctx, "POST", "/messages", json.RawMessage(body))
+}
+
+// ParseMessagesStream 逐个读取 Claude 流式事件，不做格式转换
+func (ca *ClaudeAdapter) ParseMessagesStream(resp *http.Response) <-chan *ClaudeStreamEvent {
+	ch := make(chan *ClaudeStreamEvent, 1)
+
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		done := false
+		var streamErr *StreamError
+		err := readSSE(resp.Body, func(event, data string) bool {
+			var ev struct {
+				Type  string     `json:"type"`
+				Error *ErrorInfo `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				return true
+			}
+			if ev.Type == "" {
+				ev.Type = event
+			}
+
+			switch ev.Type {
+			case "error":
+				info := ev.Error
+				if info == nil {
+					info = &ErrorInfo{Message: data}
+				}
+				streamErr = newInBandStreamError(info)
+				return false
+			case "message_stop":
+				done = true
+			}
+			ch <- &ClaudeStreamEvent{Event: ev.Type, Data: json.RawMessage(data)}
+			return !done
+		})
+
+		switch {
+		case streamErr != nil:
+			ch <- &ClaudeStreamEvent{Err: streamErr}
+		case err != nil:
+			ch <- &ClaudeStreamEvent{Err: &StreamError{Class: StreamErrorConnection, Message: err.Error()}}
+		case !done:
+			ch <- &ClaudeStreamEvent{Err: &StreamError{Class: StreamErrorIncomplete, Message: "upstream closed the stream before message_stop"}}
+		}
+	}()
+
+	return ch
+}
@@ -18,6 +18,9 @@ const (
 	ProviderXunfei
 	ProviderTencent
 	ProviderVolcEngine
+	ProviderOllama
+	ProviderVLLM
+	ProviderLMStudio
 )
 
 // String 返回提供商类型的字符串表示
@@ -51,6 +54,12 @@ func (pt ProviderType) String() string {
 		return "tencent"
 	case ProviderVolcEngine:
 		return "volcengine"
+	case ProviderOllama:
+		return "ollama"
+	case ProviderVLLM:
+		return "vllm"
+	case ProviderLMStudio:
+		return "lmstudio"
 	default:
 		return "unknown"
 	}
@@ -87,11 +96,78 @@ func ParseProviderType(s string) ProviderType {
 		return ProviderTencent
 	case "volcengine":
 		return ProviderVolcEngine
+	case "ollama":
+		return ProviderOllama
+	case "vllm":
+		return ProviderVLLM
+	case "lmstudio", "lm-studio":
+		return ProviderLMStudio
 	default:
 		return 0
 	}
 }
 
+// IsSelfHosted 是否为自托管的 OpenAI 兼容服务（无需密钥、模型随运维加载而变化）
+func (pt ProviderType) IsSelfHosted() bool {
+	switch pt {
+	case ProviderOllama, ProviderVLLM, ProviderLMStudio:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsOpenAICompatible 是否直接提供 OpenAI 兼容的 /chat/completions 接口
+func (pt ProviderType) IsOpenAICompatible() bool {
+	switch pt {
+	case ProviderOpenAI, ProviderDeepSeek, ProviderMoonshot, ProviderMistral:
+		return true
+	default:
+		return pt.IsSelfHosted()
+	}
+}
+
+// ToolCallSupport 适配器对工具调用的支持：tools 表示转发 tools、tool_calls 与 tool 消息，
+// parallel 表示上游接受 parallel_tool_calls（一次返回多个调用）
+//
+// Claude、Gemini 与百度的适配器不转换工具定义，渠道选择时不会把工具调用请求发给它们。
+func (pt ProviderType) ToolCallSupport() (tools, parallel bool) {
+	switch pt {
+	case ProviderOpenAI, ProviderAzure, ProviderQwen, ProviderDeepSeek, ProviderMoonshot, ProviderMistral,
+		ProviderVLLM, ProviderLMStudio:
+		return true, true
+	case ProviderOllama:
+		// /api/chat 支持工具调用，但没有 parallel_tool_calls 参数
+		return true, false
+	default:
+		return false, false
+	}
+}
+
+// JSONModeSupport 适配器是否转发 response_format（JSON 模式），Claude 与百度的适配器不转发
+func (pt ProviderType) JSONModeSupport() bool {
+	switch pt {
+	case ProviderOpenAI, ProviderAzure, ProviderQwen, ProviderDeepSeek, ProviderMoonshot, ProviderMistral,
+		ProviderVLLM, ProviderLMStudio, ProviderOllama, ProviderGoogle:
+		return true
+	default:
+		return false
+	}
+}
+
+// VisionSupport 适配器是否转发消息中的图片片段
+//
+// Claude 与 Gemini 的适配器把 image_url 转换为各自的图片块；DeepSeek 与百度的接口只接受文本。
+func (pt ProviderType) VisionSupport() bool {
+	switch pt {
+	case ProviderOpenAI, ProviderAzure, ProviderAnthropic, ProviderGoogle, ProviderQwen, ProviderMoonshot, ProviderMistral,
+		ProviderVLLM, ProviderLMStudio, ProviderOllama:
+		return true
+	default:
+		return false
+	}
+}
+
 // RelayMode 中继模式（参考 New API）
 type RelayMode int
 
@@ -0,0 +1,242 @@
+package adapter
+
+import (
+	"fmt"
+	"mime"
+	neturl "net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// geminiPart Gemini 内容片段（只使用文本、内联数据与文件引用）
+type geminiPart struct {
+	Text       string            `json:"text,omitempty"`
+	InlineData *geminiInlineData `json:"inlineData,omitempty"`
+	FileData   *geminiFileData   `json:"fileData,omitempty"`
+}
+
+// geminiFileData 按 URI 引用的文件，用于 https 图片 URL
+type geminiFileData struct {
+	MimeType string `json:"mimeType"`
+	FileURI  string `json:"fileUri"`
+}
+
+// geminiInlineData 内联的图片等二进制数据（base64）
+type geminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// geminiContent Gemini contents 数组中的一条消息，角色为 user 或 model
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiGenerationConfig 生成参数，未指定的参数不发送，使用 Gemini 的默认值
+type geminiGenerationConfig struct {
+	Temperature     float32  `json:"temperature,omitempty"`
+	TopP            float32  `json:"topP,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+	// ResponseMimeType 为 application/json 时只输出 JSON（对应 response_format）
+	ResponseMimeType string `json:"responseMimeType,omitempty"`
+}
+
+// geminiRequest generateContent 请求体
+type geminiRequest struct {
+	Contents          []geminiContent        `json:"contents"`
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+}
+
+// geminiResponse generateContent 响应体（只解析需要的字段）
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+		Index        int           `json:"index"`
+	} `json:"candidates"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata *geminiUsageMetadata `json:"usageMetadata"`
+	ModelVersion  string               `json:"modelVersion"`
+	ResponseID    string               `json:"responseId"`
+}
+
+// geminiUsageMetadata Gemini 用量，candidatesTokenCount 不含思考 Token
+type geminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// geminiFinishReasons Gemini finishReason 到 OpenAI finish_reason 的映射，未列出的按 stop 处理
+var geminiFinishReasons = map[string]string{
+	"STOP":               "stop",
+	"MAX_TOKENS":         "length",
+	"SAFETY":             "content_filter",
+	"RECITATION":         "content_filter",
+	"BLOCKLIST":          "content_filter",
+	"PROHIBITED_CONTENT": "content_filter",
+	"SPII":               "content_filter",
+}
+
+// convertGeminiRequest 将 OpenAI 请求转换为 Gemini generateContent 请求
+//
+// system 消息按顺序合并为 systemInstruction；assistant 角色改名为 model，其余角色按 user 处理；
+// Gemini 要求 user/model 交替，连续的同角色消息合并为一条。
+func convertGeminiRequest(req *OpenAIRequest) *geminiRequest {
+	var system []geminiPart
+	contents := make([]geminiContent, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		parts := geminiParts(m.Content)
+		if m.Role == "system" {
+			system = append(system, parts...)
+			continue
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		role := "user"
+		if m.Role == "assistant" {
+			role = "model"
+		}
+		if n := len(contents); n > 0 && contents[n-1].Role == role {
+			contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+			continue
+		}
+		contents = append(contents, geminiContent{Role: role, Parts: parts})
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = req.MaxCompletionTokens
+	}
+
+	geminiReq := &geminiRequest{
+		Contents: contents,
+		GenerationConfig: geminiGenerationConfig{
+			Temperature:     req.Temperature,
+			TopP:            req.TopP,
+			MaxOutputTokens: maxTokens,
+			StopSequences:   req.Stop,
+		},
+	}
+	if len(system) > 0 {
+		geminiReq.SystemInstruction = &geminiContent{Parts: system}
+	}
+	// Gemini 的 responseSchema 只支持 OpenAPI 子集，json_schema 格式只约束为 JSON 输出
+	if _, ok := jsonResponseFormat(req); ok {
+		geminiReq.GenerationConfig.ResponseMimeType = "application/json"
+	}
+	return geminiReq
+}
+
+// geminiParts 将 OpenAI 消息内容转换为 Gemini 片段：文本片段原样保留，data URL 形式的图片转为内联数据，
+// https 图片转为按 URI 引用的文件
+func geminiParts(content interface{}) []geminiPart {
+	switch c := content.(type) {
+	case string:
+		if c == "" {
+			return nil
+		}
+		return []geminiPart{{Text: c}}
+	case []interface{}:
+		var parts []geminiPart
+		for _, item := range c {
+			p, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if p["type"] == "text" {
+				if text, ok := p["text"].(string); ok && text != "" {
+					parts = append(parts, geminiPart{Text: text})
+				}
+				continue
+			}
+			if url, ok := imagePartURL(p); ok {
+				if part, ok := geminiImagePart(url); ok {
+					parts = append(parts, part)
+				}
+			}
+		}
+		return parts
+	}
+	return nil
+}
+
+// geminiImagePart 图片 URL 对应的片段：data URL 转为 inlineData，http(s) URL 转为 fileData，
+// MIME 类型按扩展名推断，无法推断时按 image/jpeg 处理
+func geminiImagePart(url string) (geminiPart, bool) {
+	if mimeType, data, ok := parseDataURL(url); ok {
+		return geminiPart{InlineData: &geminiInlineData{MimeType: mimeType, Data: data}}, true
+	}
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return geminiPart{}, false
+	}
+	mimeType := "image/jpeg"
+	if u, err := neturl.Parse(url); err == nil {
+		if t := mime.TypeByExtension(path.Ext(u.Path)); strings.HasPrefix(t, "image/") {
+			mimeType = t
+		}
+	}
+	return geminiPart{FileData: &geminiFileData{MimeType: mimeType, FileURI: url}}, true
+}
+
+// toOpenAI 转换为 OpenAI 格式的响应，每个候选结果对应一个 choice
+func (r *geminiResponse) toOpenAI() (*OpenAIResponse, error) {
+	if len(r.Candidates) == 0 && r.PromptFeedback != nil && r.PromptFeedback.BlockReason != "" {
+		return nil, NewAdapterError("content_filter", fmt.Sprintf("prompt blocked by gemini: %s", r.PromptFeedback.BlockReason))
+	}
+
+	id := r.ResponseID
+	if id == "" {
+		id = fmt.Sprintf("gemini-%d", time.Now().UnixNano())
+	}
+	result := &OpenAIResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   r.ModelVersion,
+		Choices: make([]Choice, 0, len(r.Candidates)),
+	}
+
+	for _, candidate := range r.Candidates {
+		var text strings.Builder
+		for _, part := range candidate.Content.Parts {
+			text.WriteString(part.Text)
+		}
+		finishReason, ok := geminiFinishReasons[candidate.FinishReason]
+		if !ok {
+			finishReason = "stop"
+		}
+		result.Choices = append(result.Choices, Choice{
+			Index:        candidate.Index,
+			Message:      Message{Role: "assistant", Content: text.String()},
+			FinishReason: finishReason,
+		})
+	}
+
+	if r.UsageMetadata != nil {
+		result.Usage = r.UsageMetadata.toUsage()
+	}
+	return result, nil
+}
+
+// toUsage 转换为 OpenAI 用量，思考 Token 计入输出 Token 并记录在明细中
+func (m *geminiUsageMetadata) toUsage() Usage {
+	usage := Usage{
+		PromptTokens:     m.PromptTokenCount,
+		CompletionTokens: m.CandidatesTokenCount + m.ThoughtsTokenCount,
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	if m.ThoughtsTokenCount > 0 {
+		usage.CompletionTokensDetails = &CompletionTokensDetails{ReasoningTokens: m.ThoughtsTokenCount}
+	}
+	return usage
+}
@@ -0,0 +1,393 @@
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ==================== Ollama 原生适配器 ====================
+
+// OllamaAdapter 使用 Ollama 原生 /api/chat 接口的适配器
+//
+// 模型发现、extra_body allowlist 与离线判断沿用 SelfHostedAdapter；请求转换为 /api/chat 格式
+// （采样参数放在 options 中，max_tokens 对应 num_predict），流式响应为 NDJSON，
+// 用量由 prompt_eval_count / eval_count 换算。
+type OllamaAdapter struct {
+	*SelfHostedAdapter
+}
+
+// ollamaMessage /api/chat 的消息，图片为 base64 数据（不含 data URL 前缀）
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+// ollamaToolCall /api/chat 的工具调用，没有调用 ID，参数是 JSON 对象而不是编码后的字符串
+type ollamaToolCall struct {
+	Function ollamaToolCallFunction `json:"function"`
+}
+
+// ollamaToolCallFunction 工具调用的函数名与参数
+type ollamaToolCallFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// ollamaChatRequest /api/chat 请求体，stream 默认为 true，必须显式发送
+type ollamaChatRequest struct {
+	Model     string                 `json:"model"`
+	Messages  []ollamaMessage        `json:"messages"`
+	Stream    bool                   `json:"stream"`
+	Tools     []Tool                 `json:"tools,omitempty"`
+	Format    interface{}            `json:"format,omitempty"` // "json" 或 JSON Schema，约束输出格式
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive interface{}            `json:"keep_alive,omitempty"`
+}
+
+// ollamaChatResponse /api/chat 响应体，流式响应的每一行也是这个结构
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	CreatedAt       string        `json:"created_at"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// NewOllamaAdapter 创建 Ollama 原生适配器
+// BaseURL 可以是服务根地址（http://localhost:11434）或带 /v1 的地址
+func NewOllamaAdapter(config *AdapterConfig) *OllamaAdapter {
+	return &OllamaAdapter{
+		SelfHostedAdapter: NewSelfHostedAdapter(ProviderOllama, config),
+	}
+}
+
+// ConvertRequest 将 OpenAI 请求转换为 /api/chat 请求
+//
+// allowlist 内的 extra_body.options 作为默认 options，标准参数优先；keep_alive 原样透传。
+func (oa *OllamaAdapter) ConvertRequest(req *OpenAIRequest) (interface{}, error) {
+	chatReq := &ollamaChatRequest{
+		Model:    req.Model,
+		Messages: make([]ollamaMessage, 0, len(req.Messages)),
+		Stream:   req.Stream,
+		Tools:    req.Tools,
+		Options:  make(map[string]interface{}),
+	}
+	for _, m := range req.Messages {
+		chatReq.Messages = append(chatReq.Messages, ollamaConvertMessage(m))
+	}
+
+	if oa.allowlist["options"] {
+		if options, ok := req.Extra["options"].(map[string]interface{}); ok {
+			for key, value := range options {
+				chatReq.Options[key] = value
+			}
+		}
+	}
+	if oa.allowlist["keep_alive"] {
+		chatReq.KeepAlive = req.Extra["keep_alive"]
+	}
+
+	if req.Temperature != 0 {
+		chatReq.Options["temperature"] = req.Temperature
+	}
+	if req.TopP != 0 {
+		chatReq.Options["top_p"] = req.TopP
+	}
+	if req.FrequencyPenalty != 0 {
+		chatReq.Options["frequency_penalty"] = req.FrequencyPenalty
+	}
+	if req.PresencePenalty != 0 {
+		chatReq.Options["presence_penalty"] = req.PresencePenalty
+	}
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = req.MaxCompletionTokens
+	}
+	if maxTokens > 0 {
+		chatReq.Options["num_predict"] = maxTokens
+	}
+	if len(req.Stop) > 0 {
+		chatReq.Options["stop"] = req.Stop
+	}
+	if schema, ok := jsonResponseFormat(req); ok {
+		chatReq.Format = "json"
+		if schema != nil {
+			chatReq.Format = schema
+		}
+	}
+	if len(chatReq.Options) == 0 {
+		chatReq.Options = nil
+	}
+
+	return chatReq, nil
+}
+
+// DroppedExtraBody 返回会被丢弃的 extra_body 字段：不在 allowlist 内，或 /api/chat 不支持
+func (oa *OllamaAdapter) DroppedExtraBody(req *OpenAIRequest) []string {
+	dropped := make([]string, 0)
+	for key := range req.Extra {
+		if !oa.allowlist[key] || (key != "options" && key != "keep_alive") {
+			dropped = append(dropped, key)
+		}
+	}
+	sort.Strings(dropped)
+	return dropped
+}
+
+// ollamaConvertMessage 文本片段拼接为 content，data URL 形式的图片放入 images
+func ollamaConvertMessage(m Message) ollamaMessage {
+	msg := ollamaMessage{Role: m.Role}
+	switch c := m.Content.(type) {
+	case string:
+		msg.Content = c
+	case []interface{}:
+		var text strings.Builder
+		for _, part := range geminiParts(c) {
+			if part.InlineData != nil {
+				msg.Images = append(msg.Images, part.InlineData.Data)
+				continue
+			}
+			if part.FileData != nil {
+				// /api/chat 只接受 base64 图片，外部 URL 无法转发
+				continue
+			}
+			if text.Len() > 0 {
+				text.WriteString("\n")
+			}
+			text.WriteString(part.Text)
+		}
+		msg.Content = text.String()
+	}
+	for _, call := range m.ToolCalls {
+		args := json.RawMessage(call.Function.Arguments)
+		if !json.Valid(args) {
+			args = json.RawMessage("{}")
+		}
+		msg.ToolCalls = append(msg.ToolCalls, ollamaToolCall{Function: ollamaToolCallFunction{Name: call.Function.Name, Arguments: args}})
+	}
+	return msg
+}
+
+// ollamaToolCalls 转换为 OpenAI 格式的工具调用，调用 ID 由 idPrefix 与序号 offset 起生成；
+// stream 时附带流式增量需要的序号
+func ollamaToolCalls(calls []ollamaToolCall, idPrefix string, offset int, stream bool) []ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	result := make([]ToolCall, len(calls))
+	for i, call := range calls {
+		args := string(call.Function.Arguments)
+		if args == "" || args == "null" {
+			args = "{}"
+		}
+		result[i] = ToolCall{
+			ID:       fmt.Sprintf("%s-call-%d", idPrefix, offset+i),
+			Type:     "function",
+			Function: ToolCallFunction{Name: call.Function.Name, Arguments: args},
+		}
+		if stream {
+			index := offset + i
+			result[i].Index = &index
+		}
+	}
+	return result
+}
+
+// DoRequest 发送请求到 /api/chat
+func (oa *OllamaAdapter) DoRequest(ctx context.Context, convertedReq interface{}) (*http.Response, error) {
+	chatReq, ok := convertedReq.(*ollamaChatRequest)
+	if !ok {
+		return nil, fmt.Errorf("invalid request type")
+	}
+
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal body: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", oa.rootURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	oa.prepareRequest(ctx, req)
+
+	return oa.do(req)
+}
+
+// ParseResponse 解析非流式 /api/chat 响应
+func (oa *OllamaAdapter) ParseResponse(resp *http.Response) (*OpenAIResponse, error) {
+	var result ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	if result.Error != "" {
+		return nil, NewAdapterError("upstream_error", result.Error)
+	}
+
+	id := fmt.Sprintf("ollama-%d", time.Now().UnixNano())
+	usage := result.usage()
+	choice := Choice{
+		Index: 0,
+		Message: Message{
+			Role:      "assistant",
+			Content:   result.Message.Content,
+			ToolCalls: ollamaToolCalls(result.Message.ToolCalls, id, 0, false),
+		},
+		FinishReason: result.finishReason(),
+	}
+	if len(choice.Message.ToolCalls) > 0 {
+		choice.FinishReason = "tool_calls"
+	}
+	return &OpenAIResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: result.created(),
+		Model:   result.Model,
+		Choices: []Choice{choice},
+		Usage:   *usage,
+	}, nil
+}
+
+// ParseStreamResponse 解析 NDJSON 格式的流式响应
+func (oa *OllamaAdapter) ParseStreamResponse(resp *http.Response) (<-chan *StreamChunk, error) {
+	ch := make(chan *StreamChunk, 1)
+
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		parseOllamaStream(resp.Body, ch)
+	}()
+
+	return ch, nil
+}
+
+// ExtractUsage 提取使用量
+//
+// 支持 /api/chat 原始响应、转换后的 OpenAIResponse 以及携带用量的流式数据块（最后一个）。
+func (oa *OllamaAdapter) ExtractUsage(resp interface{}) (*Usage, error) {
+	switch r := resp.(type) {
+	case *ollamaChatResponse:
+		return r.usage(), nil
+	case *OpenAIResponse:
+		return &r.Usage, nil
+	case *StreamChunk:
+		if r.Usage == nil {
+			return nil, fmt.Errorf("stream chunk carries no usage")
+		}
+		return r.Usage, nil
+	default:
+		return nil, fmt.Errorf("invalid response type")
+	}
+}
+
+// HealthCheck 健康检查：请求 /api/tags，并以当前加载的模型更新支持的模型列表
+func (oa *OllamaAdapter) HealthCheck(ctx context.Context) error {
+	models, err := oa.listOllamaTags(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Strings(models)
+	oa.SetSupportedModels(models)
+	return nil
+}
+
+// parseOllamaStream 解析 /api/chat 的 NDJSON 流并转换为 OpenAI 数据块
+//
+// 每行一个 JSON 对象，done 为 true 的最后一行携带 done_reason 与用量；工具调用整体出现在某一行中，
+// 输出过工具调用时结束原因为 tool_calls。
+// {"error": "..."} 行、连接中断以及没有 done 行的提前结束都以带 Err 的数据块结束。
+func parseOllamaStream(body io.Reader, ch chan<- *StreamChunk) {
+	id := fmt.Sprintf("ollama-%d", time.Now().UnixNano())
+	reader := bufio.NewReader(body)
+	first := true
+	toolCalls := 0
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 && (err == nil || err == io.EOF) {
+			var event ollamaChatResponse
+			if jsonErr := json.Unmarshal(line, &event); jsonErr == nil {
+				if event.Error != "" {
+					ch <- &StreamChunk{Err: &StreamError{Class: StreamErrorUpstream, Message: event.Error}}
+					return
+				}
+
+				delta := &Message{
+					Content:   event.Message.Content,
+					ToolCalls: ollamaToolCalls(event.Message.ToolCalls, id, toolCalls, true),
+				}
+				toolCalls += len(event.Message.ToolCalls)
+				if first {
+					delta.Role = "assistant"
+					first = false
+				}
+				chunk := &StreamChunk{
+					ID:      id,
+					Object:  "chat.completion.chunk",
+					Created: event.created(),
+					Model:   event.Model,
+					Choices: []Choice{{Index: 0, Delta: delta}},
+				}
+				if event.Done {
+					chunk.Choices[0].FinishReason = event.finishReason()
+					if toolCalls > 0 {
+						chunk.Choices[0].FinishReason = "tool_calls"
+					}
+					chunk.Usage = event.usage()
+				}
+				ch <- chunk
+				if event.Done {
+					return
+				}
+			}
+		}
+
+		if err == io.EOF {
+			ch <- &StreamChunk{Err: &StreamError{Class: StreamErrorIncomplete, Message: "upstream closed the stream before completion"}}
+			return
+		}
+		if err != nil {
+			ch <- &StreamChunk{Err: &StreamError{Class: StreamErrorConnection, Message: err.Error()}}
+			return
+		}
+	}
+}
+
+// finishReason done_reason 映射为 OpenAI finish_reason，length 以外的结束原因按 stop 处理
+func (r *ollamaChatResponse) finishReason() string {
+	if r.DoneReason == "length" {
+		return "length"
+	}
+	return "stop"
+}
+
+// usage 由 prompt_eval_count / eval_count 换算用量
+func (r *ollamaChatResponse) usage() *Usage {
+	return &Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+// created 解析 created_at，格式错误时使用当前时间
+func (r *ollamaChatResponse) created() int64 {
+	if t, err := time.Parse(time.RFC3339Nano, r.CreatedAt); err == nil {
+		return t.Unix()
+	}
+	return time.Now().Unix()
+}
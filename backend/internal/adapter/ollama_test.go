@@ -0,0 +1,263 @@
+package adapter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOllamaConvertRequest(t *testing.T) {
+	a := NewOllamaAdapter(&AdapterConfig{Type: "ollama", BaseURL: "http://localhost:11434/v1"})
+	req := &OpenAIRequest{
+		Model: "llama3.1:8b",
+		Messages: []Message{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: []interface{}{
+				map[string]interface{}{"type": "text", "text": "What is this?"},
+				map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/png;base64,iVBORw0KGgo="}},
+			}},
+		},
+		Temperature:         0.2,
+		MaxCompletionTokens: 128,
+		Stop:                []string{"\n\n"},
+		Extra: map[string]interface{}{
+			"keep_alive": "10m",
+			"options":    map[string]interface{}{"num_ctx": 8192, "temperature": 1.5},
+			"raw":        true,
+		},
+	}
+
+	converted, err := a.ConvertRequest(req)
+	if err != nil {
+		t.Fatalf("ConvertRequest failed: %v", err)
+	}
+	chatReq, ok := converted.(*ollamaChatRequest)
+	if !ok {
+		t.Fatalf("Expected *ollamaChatRequest, got %T", converted)
+	}
+
+	if chatReq.Stream {
+		t.Error("Expected stream to be false")
+	}
+	if len(chatReq.Messages) != 2 || chatReq.Messages[0].Role != "system" || chatReq.Messages[0].Content != "Be brief." {
+		t.Errorf("Unexpected messages %+v", chatReq.Messages)
+	}
+	user := chatReq.Messages[1]
+	if user.Content != "What is this?" || !reflect.DeepEqual(user.Images, []string{"iVBORw0KGgo="}) {
+		t.Errorf("Expected text content and base64 image, got %+v", user)
+	}
+	// 标准参数优先于 extra_body.options 中的同名参数
+	want := map[string]interface{}{
+		"temperature": float32(0.2),
+		"num_predict": 128,
+		"stop":        []string{"\n\n"},
+		"num_ctx":     8192,
+	}
+	if !reflect.DeepEqual(chatReq.Options, want) {
+		t.Errorf("Unexpected options %v", chatReq.Options)
+	}
+	if chatReq.KeepAlive != "10m" {
+		t.Errorf("Expected keep_alive to be forwarded, got %v", chatReq.KeepAlive)
+	}
+	if dropped := a.DroppedExtraBody(req); !reflect.DeepEqual(dropped, []string{"raw"}) {
+		t.Errorf("Expected raw to be dropped, got %v", dropped)
+	}
+
+	// 没有任何采样参数时不发送 options
+	converted, _ = a.ConvertRequest(&OpenAIRequest{Model: "llama3.1:8b", Stream: true,
+		Messages: []Message{{Role: "user", Content: "hi"}}})
+	if chatReq := converted.(*ollamaChatRequest); chatReq.Options != nil || !chatReq.Stream {
+		t.Errorf("Unexpected request %+v", chatReq)
+	}
+}
+
+func TestOllamaChatCompletion(t *testing.T) {
+	ollama := newFixtureServer(t, map[string]string{"/api/chat": "testdata/ollama/api_chat.json"})
+	a := NewOllamaAdapter(&AdapterConfig{Type: "ollama", BaseURL: ollama.URL, Timeout: 5 * time.Second})
+
+	converted, _ := a.ConvertRequest(&OpenAIRequest{Model: "llama3.1:8b", MaxTokens: 64,
+		Messages: []Message{{Role: "user", Content: "hi"}}})
+	resp, err := a.DoRequest(context.Background(), converted)
+	if err != nil {
+		t.Fatalf("DoRequest failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ollama.lastBody["stream"] != false {
+		t.Errorf("Expected stream:false to be sent explicitly, got %v", ollama.lastBody["stream"])
+	}
+	if opts, ok := ollama.lastBody["options"].(map[string]interface{}); !ok || opts["num_predict"] != float64(64) {
+		t.Errorf("Expected num_predict option, got %v", ollama.lastBody["options"])
+	}
+
+	result, err := a.ParseResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseResponse failed: %v", err)
+	}
+	if len(result.Choices) != 1 || result.Choices[0].Message.Content != "Hello! How can I help you today?" || result.Choices[0].FinishReason != "stop" {
+		t.Errorf("Unexpected choices %+v", result.Choices)
+	}
+	if result.Model != "llama3.1:8b" || result.Created != 1727335000 {
+		t.Errorf("Unexpected model/created %s %d", result.Model, result.Created)
+	}
+	usage, err := a.ExtractUsage(result)
+	if err != nil {
+		t.Fatalf("ExtractUsage failed: %v", err)
+	}
+	if usage.PromptTokens != 11 || usage.CompletionTokens != 10 || usage.TotalTokens != 21 {
+		t.Errorf("Expected usage from eval counts, got %+v", usage)
+	}
+}
+
+func TestOllamaParseStreamResponse(t *testing.T) {
+	ollama := newFixtureServer(t, map[string]string{"/api/chat": "testdata/ollama/api_chat_stream.ndjson"})
+	a := NewOllamaAdapter(&AdapterConfig{Type: "ollama", BaseURL: ollama.URL, Timeout: 5 * time.Second})
+
+	converted, _ := a.ConvertRequest(&OpenAIRequest{Model: "llama3.1:8b", Stream: true,
+		Messages: []Message{{Role: "user", Content: "hi"}}})
+	resp, err := a.DoRequest(context.Background(), converted)
+	if err != nil {
+		t.Fatalf("DoRequest failed: %v", err)
+	}
+	ch, err := a.ParseStreamResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseStreamResponse failed: %v", err)
+	}
+
+	var chunks []*StreamChunk
+	var text strings.Builder
+	for chunk := range ch {
+		if chunk.Err != nil {
+			t.Fatalf("Unexpected stream error: %v", chunk.Err)
+		}
+		chunks = append(chunks, chunk)
+		text.WriteString(chunk.DeltaText())
+	}
+
+	if len(chunks) != 4 {
+		t.Fatalf("Expected 4 chunks, got %d", len(chunks))
+	}
+	if chunks[0].Choices[0].Delta.Role != "assistant" || chunks[1].Choices[0].Delta.Role != "" {
+		t.Error("Expected role only on the first chunk")
+	}
+	if text.String() != "Hello! How can I help?" {
+		t.Errorf("Unexpected streamed text %q", text.String())
+	}
+	last := chunks[len(chunks)-1]
+	if last.Choices[0].FinishReason != "length" {
+		t.Errorf("Expected finish_reason length, got %q", last.Choices[0].FinishReason)
+	}
+	usage, err := a.ExtractUsage(last)
+	if err != nil || usage.PromptTokens != 11 || usage.CompletionTokens != 6 || usage.TotalTokens != 17 {
+		t.Errorf("Expected usage on the final chunk, got %+v (%v)", usage, err)
+	}
+	if chunks[0].ID != last.ID {
+		t.Error("Expected all chunks to share the same id")
+	}
+}
+
+func TestOllamaStreamErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		class string
+	}{
+		{"in-band error", `{"model":"llama3.1:8b","message":{"role":"assistant","content":"Hi"},"done":false}` + "\n" +
+			`{"error":"model runner has unexpectedly stopped"}` + "\n", StreamErrorUpstream},
+		{"truncated", `{"model":"llama3.1:8b","message":{"role":"assistant","content":"Hi"},"done":false}` + "\n", StreamErrorIncomplete},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := make(chan *StreamChunk, 8)
+			parseOllamaStream(strings.NewReader(tt.body), ch)
+			close(ch)
+
+			var last *StreamChunk
+			for chunk := range ch {
+				last = chunk
+			}
+			if last == nil || last.Err == nil || last.Err.Class != tt.class {
+				t.Errorf("Expected %s error as the final chunk, got %+v", tt.class, last)
+			}
+		})
+	}
+}
+
+func TestOllamaParseResponseError(t *testing.T) {
+	a := NewOllamaAdapter(&AdapterConfig{Type: "ollama"})
+	resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"error":"model \"llama9\" not found"}`))}
+	if _, err := a.ParseResponse(resp); err == nil {
+		t.Error("Expected error field to be reported")
+	}
+}
+
+func TestOllamaHealthCheck(t *testing.T) {
+	ollama := ollamaFixture(t)
+	a := NewOllamaAdapter(&AdapterConfig{Type: "ollama", BaseURL: ollama.URL, Timeout: 5 * time.Second})
+
+	if models := a.GetSupportedModels(); len(models) != 0 {
+		t.Errorf("Expected no models before discovery, got %v", models)
+	}
+	if err := a.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("HealthCheck failed: %v", err)
+	}
+	if models := a.GetSupportedModels(); !reflect.DeepEqual(models, []string{"llama3.1:8b", "qwen2.5:7b"}) {
+		t.Errorf("Expected models from /api/tags, got %v", models)
+	}
+}
+
+func TestOllamaToolCalls(t *testing.T) {
+	a := NewOllamaAdapter(&AdapterConfig{Type: "ollama", BaseURL: "http://localhost:11434"})
+	converted, err := a.ConvertRequest(&OpenAIRequest{
+		Model: "llama3.1:8b",
+		Messages: []Message{
+			{Role: "user", Content: "weather in Oslo?"},
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "call-1", Type: "function", Function: ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Oslo"}`}}}},
+			{Role: "tool", ToolCallID: "call-1", Content: `{"temp_c":4}`},
+		},
+		Tools: []Tool{{Type: "function", Function: ToolFunction{Name: "get_weather"}}},
+	})
+	if err != nil {
+		t.Fatalf("ConvertRequest failed: %v", err)
+	}
+	chatReq := converted.(*ollamaChatRequest)
+	if len(chatReq.Tools) != 1 {
+		t.Errorf("Expected tools to be forwarded, got %+v", chatReq.Tools)
+	}
+	calls := chatReq.Messages[1].ToolCalls
+	if len(calls) != 1 || calls[0].Function.Name != "get_weather" || string(calls[0].Function.Arguments) != `{"city":"Oslo"}` {
+		t.Errorf("Expected the assistant tool call with object arguments, got %+v", calls)
+	}
+	if chatReq.Messages[2].Role != "tool" || chatReq.Messages[2].Content != `{"temp_c":4}` {
+		t.Errorf("Expected the tool result message unchanged, got %+v", chatReq.Messages[2])
+	}
+
+	body := `{"model":"llama3.1:8b","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Paris"}}}]},"done":true,"done_reason":"stop"}`
+	resp, err := a.ParseResponse(&http.Response{Body: io.NopCloser(strings.NewReader(body))})
+	if err != nil {
+		t.Fatalf("ParseResponse failed: %v", err)
+	}
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" || len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("Expected one tool call with finish_reason tool_calls, got %+v", choice)
+	}
+	call := choice.Message.ToolCalls[0]
+	if call.ID == "" || call.Type != "function" || call.Function.Arguments != `{"city":"Paris"}` || call.Index != nil {
+		t.Errorf("Unexpected tool call %+v", call)
+	}
+
+	ch := make(chan *StreamChunk, 4)
+	parseOllamaStream(strings.NewReader(body+"\n"), ch)
+	chunk := <-ch
+	delta := chunk.Choices[0].Delta
+	if len(delta.ToolCalls) != 1 || delta.ToolCalls[0].Index == nil || *delta.ToolCalls[0].Index != 0 {
+		t.Errorf("Expected an indexed tool call delta, got %+v", delta.ToolCalls)
+	}
+	if chunk.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("Expected finish_reason tool_calls, got %q", chunk.Choices[0].FinishReason)
+	}
+}
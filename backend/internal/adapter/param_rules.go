@@ -0,0 +1,314 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 不支持参数的处理方式
+const (
+	ParamActionDrop   = "drop"   // 静默丢弃并在响应中给出警告
+	ParamActionReject = "reject" // 直接拒绝请求
+)
+
+// 可被规则约束的请求参数
+const (
+	ParamTemperature      = "temperature"
+	ParamTopP             = "top_p"
+	ParamFrequencyPenalty = "frequency_penalty"
+	ParamPresencePenalty  = "presence_penalty"
+	ParamStop             = "stop"
+	ParamTools            = "tools"
+)
+
+// MaxCompletionTokensField o 系列模型使用的最大输出 Token 字段
+const MaxCompletionTokensField = "max_completion_tokens"
+
+// ValidReasoningEfforts reasoning_effort 的合法取值
+var ValidReasoningEfforts = []string{"minimal", "low", "medium", "high"}
+
+// ModelParamRule 模型家族参数规则
+type ModelParamRule struct {
+	Family                  string            `json:"family"`
+	ModelPrefixes           []string          `json:"model_prefixes"`
+	MaxTokensField          string            `json:"max_tokens_field,omitempty"`
+	UnsupportedParams       map[string]string `json:"unsupported_params,omitempty"` // 参数 -> drop/reject
+	SupportsReasoningEffort bool              `json:"supports_reasoning_effort"`
+}
+
+// Matches 判断模型是否属于该家族（完整匹配或以 "前缀-" 开头）
+func (r *ModelParamRule) Matches(modelName string) (int, bool) {
+	name := strings.ToLower(modelName)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	best := -1
+	for _, prefix := range r.ModelPrefixes {
+		prefix = strings.ToLower(prefix)
+		if name == prefix || strings.HasPrefix(name, prefix+"-") {
+			if len(prefix) > best {
+				best = len(prefix)
+			}
+		}
+	}
+	return best, best >= 0
+}
+
+// Validate 校验规则
+func (r *ModelParamRule) Validate() error {
+	if r.Family == "" {
+		return fmt.Errorf("param rule family is required")
+	}
+	if len(r.ModelPrefixes) == 0 {
+		return fmt.Errorf("param rule %s has no model prefixes", r.Family)
+	}
+	for param, action := range r.UnsupportedParams {
+		if action != ParamActionDrop && action != ParamActionReject {
+			return fmt.Errorf("param rule %s: invalid action %q for %s", r.Family, action, param)
+		}
+	}
+	return nil
+}
+
+// DefaultParamRules 内置的模型家族参数规则
+func DefaultParamRules() []*ModelParamRule {
+	reasoningParams := map[string]string{
+		ParamTemperature:      ParamActionDrop,
+		ParamTopP:             ParamActionDrop,
+		ParamFrequencyPenalty: ParamActionDrop,
+		ParamPresencePenalty:  ParamActionDrop,
+	}
+	clone := func(m map[string]string) map[string]string {
+		out := make(map[string]string, len(m))
+		for k, v := range m {
+			out[k] = v
+		}
+		return out
+	}
+
+	o1Mini := clone(reasoningParams)
+	o1Mini[ParamTools] = ParamActionReject
+
+	return []*ModelParamRule{
+		{
+			Family:                  "o1",
+			ModelPrefixes:           []string{"o1", "o1-preview"},
+			MaxTokensField:          MaxCompletionTokensField,
+			UnsupportedParams:       clone(reasoningParams),
+			SupportsReasoningEffort: true,
+		},
+		{
+			// o1-mini 不支持 reasoning_effort 与工具调用
+			Family:            "o1-mini",
+			ModelPrefixes:     []string{"o1-mini"},
+			MaxTokensField:    MaxCompletionTokensField,
+			UnsupportedParams: o1Mini,
+		},
+		{
+			Family:                  "o3",
+			ModelPrefixes:           []string{"o3", "o3-mini", "o4-mini"},
+			MaxTokensField:          MaxCompletionTokensField,
+			UnsupportedParams:       clone(reasoningParams),
+			SupportsReasoningEffort: true,
+		},
+	}
+}
+
+// ParamRuleSet 参数规则集（可从配置或数据库热更新）
+type ParamRuleSet struct {
+	mu    sync.RWMutex
+	rules []*ModelParamRule
+}
+
+// NewParamRuleSet 创建规则集
+func NewParamRuleSet(rules []*ModelParamRule) *ParamRuleSet {
+	return &ParamRuleSet{rules: rules}
+}
+
+// Set 替换全部规则
+func (s *ParamRuleSet) Set(rules []*ModelParamRule) error {
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.rules = rules
+	s.mu.Unlock()
+	return nil
+}
+
+// Rules 返回当前规则
+func (s *ParamRuleSet) Rules() []*ModelParamRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rules
+}
+
+// Match 返回与模型匹配最精确（前缀最长）的规则，没有则返回 nil
+func (s *ParamRuleSet) Match(modelName string) *ModelParamRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matched *ModelParamRule
+	best := -1
+	for _, r := range s.rules {
+		if n, ok := r.Matches(modelName); ok && n > best {
+			matched, best = r, n
+		}
+	}
+	return matched
+}
+
+// ParseParamRules 从 JSON 解析规则列表
+func ParseParamRules(data []byte) ([]*ModelParamRule, error) {
+	var rules []*ModelParamRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid param rules: %w", err)
+	}
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// defaultParamRuleSet 全局规则集
+var defaultParamRuleSet = NewParamRuleSet(DefaultParamRules())
+
+// GetParamRuleSet 获取全局规则集
+func GetParamRuleSet() *ParamRuleSet {
+	return defaultParamRuleSet
+}
+
+// ParamError 请求参数与模型不兼容
+type ParamError struct {
+	Model string
+	Param string
+	Msg   string
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("model %s: %s", e.Model, e.Msg)
+}
+
+// AdaptParams 按规则调整请求参数，返回被静默丢弃参数的警告
+func AdaptParams(rules *ParamRuleSet, req *OpenAIRequest) ([]string, error) {
+	rule := rules.Match(req.Model)
+	warnings := make([]string, 0)
+
+	if rule == nil {
+		// 未知家族不转发 reasoning_effort，避免上游 400
+		if req.ReasoningEffort != "" {
+			warnings = append(warnings, fmt.Sprintf("reasoning_effort is not supported by model %s and was dropped", req.Model))
+			req.ReasoningEffort = ""
+		}
+		return warnings, nil
+	}
+
+	// 1. max_tokens -> max_completion_tokens
+	if rule.MaxTokensField == MaxCompletionTokensField && req.MaxTokens > 0 {
+		if req.MaxCompletionTokens == 0 {
+			req.MaxCompletionTokens = req.MaxTokens
+		}
+		req.MaxTokens = 0
+	}
+
+	// 2. reasoning_effort
+	if req.ReasoningEffort != "" {
+		if !rule.SupportsReasoningEffort {
+			warnings = append(warnings, fmt.Sprintf("reasoning_effort is not supported by model %s and was dropped", req.Model))
+			req.ReasoningEffort = ""
+		} else if !validReasoningEffort(req.ReasoningEffort) {
+			return nil, &ParamError{
+				Model: req.Model,
+				Param: "reasoning_effort",
+				Msg:   fmt.Sprintf("invalid reasoning_effort %q, expected one of %s", req.ReasoningEffort, strings.Join(ValidReasoningEfforts, ", ")),
+			}
+		}
+	}
+
+	// 3. 不支持的参数（按名称排序保证警告顺序稳定）
+	params := make([]string, 0, len(rule.UnsupportedParams))
+	for param := range rule.UnsupportedParams {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+
+	for _, param := range params {
+		if !paramSet(req, param) {
+			continue
+		}
+		if rule.UnsupportedParams[param] == ParamActionReject {
+			return nil, &ParamError{
+				Model: req.Model,
+				Param: param,
+				Msg:   fmt.Sprintf("parameter %s is not supported", param),
+			}
+		}
+		clearParam(req, param)
+		warnings = append(warnings, fmt.Sprintf("%s is not supported by model %s and was dropped", param, req.Model))
+	}
+
+	return warnings, nil
+}
+
+func validReasoningEffort(effort string) bool {
+	for _, v := range ValidReasoningEfforts {
+		if v == effort {
+			return true
+		}
+	}
+	return false
+}
+
+func paramSet(req *OpenAIRequest, param string) bool {
+	switch param {
+	case ParamTemperature:
+		// o 系列只接受默认值 1
+		return req.Temperature != 0 && req.Temperature != 1
+	case ParamTopP:
+		return req.TopP != 0 && req.TopP != 1
+	case ParamFrequencyPenalty:
+		return req.FrequencyPenalty != 0
+	case ParamPresencePenalty:
+		return req.PresencePenalty != 0
+	case ParamStop:
+		return len(req.Stop) > 0
+	case ParamTools:
+		return len(req.Tools) > 0
+	default:
+		_, ok := req.Extra[param]
+		return ok
+	}
+}
+
+func clearParam(req *OpenAIRequest, param string) {
+	switch param {
+	case ParamTemperature:
+		req.Temperature = 0
+	case ParamTopP:
+		req.TopP = 0
+	case ParamFrequencyPenalty:
+		req.FrequencyPenalty = 0
+	case ParamPresencePenalty:
+		req.PresencePenalty = 0
+	case ParamStop:
+		req.Stop = nil
+	case ParamTools:
+		// 没有工具定义时上游会拒绝 tool_choice 等参数，一并移除
+		req.Tools, req.ToolChoice, req.ParallelToolCalls = nil, nil, nil
+	default:
+		delete(req.Extra, param)
+	}
+}
+
+// ParamAdapter 支持按模型家族调整请求参数的适配器
+type ParamAdapter interface {
+	AdaptParams(req *OpenAIRequest) ([]string, error)
+}
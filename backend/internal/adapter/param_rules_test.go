@@ -0,0 +1,236 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAdaptParamsPerFamily(t *testing.T) {
+	cases := []struct {
+		name            string
+		model           string
+		wantMaxTokens   int
+		wantMaxCompl    int
+		wantTemperature float32
+		wantEffort      string
+		wantWarnings    int
+	}{
+		{"gpt-4 untouched", "gpt-4", 256, 0, 0.7, "", 1},
+		{"o1", "o1", 0, 256, 0, "high", 1},
+		{"o1 dated", "o1-2024-12-17", 0, 256, 0, "high", 1},
+		{"o1-preview", "o1-preview", 0, 256, 0, "high", 1},
+		{"o1-mini drops effort", "o1-mini", 0, 256, 0, "", 2},
+		{"o3-mini", "o3-mini", 0, 256, 0, "high", 1},
+		{"o4-mini", "o4-mini-2025-04-16", 0, 256, 0, "high", 1},
+		{"provider prefixed", "openai/o3", 0, 256, 0, "high", 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &OpenAIRequest{
+				Model:           tc.model,
+				MaxTokens:       256,
+				Temperature:     0.7,
+				ReasoningEffort: "high",
+			}
+
+			warnings, err := AdaptParams(NewParamRuleSet(DefaultParamRules()), req)
+			if err != nil {
+				t.Fatalf("AdaptParams failed: %v", err)
+			}
+			if req.MaxTokens != tc.wantMaxTokens {
+				t.Errorf("Expected max_tokens %d, got %d", tc.wantMaxTokens, req.MaxTokens)
+			}
+			if req.MaxCompletionTokens != tc.wantMaxCompl {
+				t.Errorf("Expected max_completion_tokens %d, got %d", tc.wantMaxCompl, req.MaxCompletionTokens)
+			}
+			if req.Temperature != tc.wantTemperature {
+				t.Errorf("Expected temperature %v, got %v", tc.wantTemperature, req.Temperature)
+			}
+			if req.ReasoningEffort != tc.wantEffort {
+				t.Errorf("Expected reasoning_effort %q, got %q", tc.wantEffort, req.ReasoningEffort)
+			}
+			if len(warnings) != tc.wantWarnings {
+				t.Errorf("Expected %d warnings, got %v", tc.wantWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestAdaptParamsKeepsExplicitMaxCompletionTokens(t *testing.T) {
+	req := &OpenAIRequest{Model: "o3", MaxTokens: 100, MaxCompletionTokens: 500}
+
+	if _, err := AdaptParams(NewParamRuleSet(DefaultParamRules()), req); err != nil {
+		t.Fatalf("AdaptParams failed: %v", err)
+	}
+	if req.MaxTokens != 0 || req.MaxCompletionTokens != 500 {
+		t.Errorf("Expected max_completion_tokens 500 and no max_tokens, got %d/%d", req.MaxCompletionTokens, req.MaxTokens)
+	}
+}
+
+func TestAdaptParamsDefaultTemperatureAllowed(t *testing.T) {
+	req := &OpenAIRequest{Model: "o1", Temperature: 1}
+
+	warnings, err := AdaptParams(NewParamRuleSet(DefaultParamRules()), req)
+	if err != nil {
+		t.Fatalf("AdaptParams failed: %v", err)
+	}
+	if len(warnings) != 0 || req.Temperature != 1 {
+		t.Errorf("Expected default temperature to pass through, got %v (warnings %v)", req.Temperature, warnings)
+	}
+}
+
+func TestAdaptParamsReject(t *testing.T) {
+	req := &OpenAIRequest{
+		Model: "o1-mini",
+		Tools: []Tool{{Type: "function", Function: ToolFunction{Name: "lookup"}}},
+	}
+
+	_, err := AdaptParams(NewParamRuleSet(DefaultParamRules()), req)
+	var paramErr *ParamError
+	if !errors.As(err, &paramErr) {
+		t.Fatalf("Expected ParamError, got %v", err)
+	}
+	if paramErr.Param != ParamTools {
+		t.Errorf("Expected tools to be rejected, got %s", paramErr.Param)
+	}
+}
+
+func TestAdaptParamsInvalidReasoningEffort(t *testing.T) {
+	req := &OpenAIRequest{Model: "o3", ReasoningEffort: "maximum"}
+
+	_, err := AdaptParams(NewParamRuleSet(DefaultParamRules()), req)
+	var paramErr *ParamError
+	if !errors.As(err, &paramErr) || paramErr.Param != "reasoning_effort" {
+		t.Fatalf("Expected reasoning_effort ParamError, got %v", err)
+	}
+}
+
+func TestParamRulesFromConfig(t *testing.T) {
+	// 新的模型家族只需增加规则数据
+	rules, err := ParseParamRules([]byte(`[
+		{"family": "r1", "model_prefixes": ["deepseek-reasoner"], "max_tokens_field": "max_completion_tokens",
+		 "unsupported_params": {"temperature": "reject"}, "supports_reasoning_effort": false}
+	]`))
+	if err != nil {
+		t.Fatalf("ParseParamRules failed: %v", err)
+	}
+
+	set := NewParamRuleSet(DefaultParamRules())
+	if err := set.Set(rules); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	if set.Match("o1") != nil {
+		t.Error("Expected built-in rules to be replaced")
+	}
+
+	req := &OpenAIRequest{Model: "deepseek-reasoner", MaxTokens: 64}
+	if _, err := AdaptParams(set, req); err != nil {
+		t.Fatalf("AdaptParams failed: %v", err)
+	}
+	if req.MaxCompletionTokens != 64 {
+		t.Errorf("Expected max_completion_tokens 64, got %d", req.MaxCompletionTokens)
+	}
+
+	req = &OpenAIRequest{Model: "deepseek-reasoner", Temperature: 0.3}
+	if _, err := AdaptParams(set, req); err == nil {
+		t.Error("Expected temperature to be rejected")
+	}
+
+	if _, err := ParseParamRules([]byte(`[{"family": "x", "model_prefixes": ["x"], "unsupported_params": {"top_p": "ignore"}}]`)); err == nil {
+		t.Error("Expected invalid action to fail validation")
+	}
+}
+
+func TestOpenAIConvertRequestWireFormat(t *testing.T) {
+	a := NewOpenAIAdapter(&AdapterConfig{Type: "openai"})
+
+	converted, err := a.ConvertRequest(&OpenAIRequest{
+		Model:           "o3-mini",
+		MaxTokens:       1024,
+		Temperature:     0.2,
+		TopP:            0.9,
+		ReasoningEffort: "low",
+	})
+	if err != nil {
+		t.Fatalf("ConvertRequest failed: %v", err)
+	}
+
+	body, _ := json.Marshal(converted)
+	var wire map[string]interface{}
+	_ = json.Unmarshal(body, &wire)
+
+	for _, field := range []string{"max_tokens", "temperature", "top_p"} {
+		if _, ok := wire[field]; ok {
+			t.Errorf("Expected %s not to be sent, body %s", field, body)
+		}
+	}
+	if wire["max_completion_tokens"] != float64(1024) {
+		t.Errorf("Expected max_completion_tokens 1024, body %s", body)
+	}
+	if wire["reasoning_effort"] != "low" {
+		t.Errorf("Expected reasoning_effort low, body %s", body)
+	}
+}
+
+func TestReasoningTokenAccounting(t *testing.T) {
+	a := NewOpenAIAdapter(&AdapterConfig{Type: "openai"})
+
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(`{
+		"id": "chatcmpl-1",
+		"model": "o1",
+		"choices": [],
+		"usage": {
+			"prompt_tokens": 20,
+			"completion_tokens": 500,
+			"total_tokens": 520,
+			"completion_tokens_details": {"reasoning_tokens": 448}
+		}
+	}`))}
+
+	parsed, err := a.ParseResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseResponse failed: %v", err)
+	}
+	usage, _ := a.ExtractUsage(parsed)
+	if usage.ReasoningTokens() != 448 {
+		t.Errorf("Expected 448 reasoning tokens, got %d", usage.ReasoningTokens())
+	}
+	// 推理 Token 已计入输出 Token，不能重复累加
+	if usage.CompletionTokens != 500 || usage.TotalTokens != 520 {
+		t.Errorf("Expected completion 500 / total 520, got %d / %d", usage.CompletionTokens, usage.TotalTokens)
+	}
+
+	var legacy Usage
+	_ = json.Unmarshal([]byte(`{"prompt_tokens": 1, "completion_tokens": 2, "total_tokens": 3}`), &legacy)
+	if legacy.ReasoningTokens() != 0 {
+		t.Errorf("Expected 0 reasoning tokens without details, got %d", legacy.ReasoningTokens())
+	}
+}
+
+func TestReasoningTokensInStream(t *testing.T) {
+	a := NewOpenAIAdapter(&AdapterConfig{Type: "openai"})
+
+	body := "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+		"data: {\"id\":\"c1\",\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":40,\"total_tokens\":45,\"completion_tokens_details\":{\"reasoning_tokens\":32}}}\n\n" +
+		"data: [DONE]\n\n"
+	chunks, err := a.ParseStreamResponse(&http.Response{Body: io.NopCloser(strings.NewReader(body))})
+	if err != nil {
+		t.Fatalf("ParseStreamResponse failed: %v", err)
+	}
+
+	var usage *Usage
+	for chunk := range chunks {
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+	if usage == nil || usage.ReasoningTokens() != 32 {
+		t.Fatalf("Expected 32 reasoning tokens from final chunk, got %+v", usage)
+	}
+}
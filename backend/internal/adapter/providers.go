@@ -1,13 +1,11 @@
 package adapter
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"strings"
 )
 
 // ==================== OpenAI 适配器 ====================
@@ -27,15 +25,28 @@ func NewOpenAIAdapter(config *AdapterConfig) *OpenAIAdapter {
 		"gpt-4", "gpt-4-turbo", "gpt-4-turbo-preview",
 		"gpt-3.5-turbo", "gpt-3.5-turbo-16k",
 	})
+	adapter.SetRequestIDHeaders("X-Request-Id")
 
 	return adapter
 }
 
 // ConvertRequest 转换请求
 func (oa *OpenAIAdapter) ConvertRequest(req *OpenAIRequest) (interface{}, error) {
+	if _, err := oa.AdaptParams(req); err != nil {
+		return nil, err
+	}
+	// 流式响应默认不带用量，要求上游在最后一个数据块中返回，计费时优先使用
+	if req.Stream && req.StreamOptions == nil {
+		req.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
 	return req, nil
 }
 
+// AdaptParams 按模型家族规则调整参数（如 o 系列的 max_completion_tokens）
+func (oa *OpenAIAdapter) AdaptParams(req *OpenAIRequest) ([]string, error) {
+	return AdaptParams(GetParamRuleSet(), req)
+}
+
 // DoRequest 发送请求
 func (oa *OpenAIAdapter) DoRequest(ctx context.Context, convertedReq interface{}) (*http.Response, error) {
 	req, ok := convertedReq.(*OpenAIRequest)
@@ -70,32 +81,7 @@ func (oa *OpenAIAdapter) ParseStreamResponse(resp *http.Response) (<-chan *Strea
 		defer close(ch)
 		defer resp.Body.Close()
 
-		reader := bufio.NewReader(resp.Body)
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				if err != io.EOF {
-					// ignore errors, stream will end
-				}
-				return
-			}
-
-			if !bytes.HasPrefix([]byte(line), []byte("data: ")) {
-				continue
-			}
-
-			data := bytes.TrimSpace(bytes.TrimPrefix([]byte(line), []byte("data: ")))
-			if bytes.Equal(data, []byte("[DONE]")) {
-				return
-			}
-
-			var chunk StreamChunk
-			if err := json.Unmarshal(data, &chunk); err != nil {
-				continue
-			}
-
-			ch <- &chunk
-		}
+		parseOpenAIStream(resp.Body, ch)
 	}()
 
 	return ch, nil
@@ -154,21 +140,66 @@ func NewClaudeAdapter(config *AdapterConfig) *ClaudeAdapter {
 		"claude-3-opus", "claude-3-sonnet", "claude-3-haiku",
 		"claude-2.1", "claude-2", "claude-instant-1.2",
 	})
+	adapter.SetRequestIDHeaders("Request-Id", "Anthropic-Request-Id")
+
+	// Anthropic API 通过 x-api-key 鉴权，并要求声明 API 版本
+	version := config.Version
+	if version == "" {
+		version = ClaudeAPIVersion
+	}
+	adapter.SetAuthHeader("x-api-key", "")
+	adapter.SetDefaultHeaders(map[string]string{"anthropic-version": version})
 
 	return adapter
 }
 
 // ConvertRequest 转换请求
+//
+// Claude 不接受 messages 中的 system 角色：所有 system 消息按顺序拼接为顶层 system 参数；
+// 其余消息必须 user/assistant 交替，连续的同角色消息合并为一条。image_url 片段转换为图片块。
+// max_tokens 为必填参数，调用方未指定时使用默认值。
 func (ca *ClaudeAdapter) ConvertRequest(req *OpenAIRequest) (interface{}, error) {
-	// 将 OpenAI 格式转换为 Claude 格式
+	var system []string
+	messages := make([]claudeMessage, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			if text := contentText(m.Content); text != "" {
+				system = append(system, text)
+			}
+			continue
+		}
+		content := claudeContent(m.Content)
+		if n := len(messages); n > 0 && messages[n-1].Role == m.Role {
+			messages[n-1].Content = mergeClaudeContent(messages[n-1].Content, content)
+			continue
+		}
+		messages = append(messages, claudeMessage{Role: m.Role, Content: content})
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = req.MaxCompletionTokens
+	}
+	if maxTokens <= 0 {
+		maxTokens = claudeDefaultMaxTokens
+	}
+
 	claudeReq := map[string]interface{}{
 		"model":       req.Model,
-		"max_tokens":  req.MaxTokens,
-		"system":      "", // Claude 使用 system 参数
-		"messages":    req.Messages,
+		"max_tokens":  maxTokens,
+		"messages":    messages,
 		"temperature": req.Temperature,
 		"top_p":       req.TopP,
 	}
+	if len(system) > 0 {
+		claudeReq["system"] = strings.Join(system, "\n\n")
+	}
+	if len(req.Stop) > 0 {
+		claudeReq["stop_sequences"] = req.Stop
+	}
+	if req.Stream {
+		claudeReq["stream"] = true
+	}
 
 	return claudeReq, nil
 }
@@ -204,16 +235,29 @@ func (ca *ClaudeAdapter) ParseStreamResponse(resp *http.Response) (<-chan *Strea
 		defer close(ch)
 		defer resp.Body.Close()
 
-		// Claude 使用不同的流式格式
+		// Claude 使用不同的流式格式，转换为 OpenAI 数据块
+		parseClaudeStream(resp.Body, ch)
 	}()
 
 	return ch, nil
 }
 
 // ExtractUsage 提取使用量
+//
+// 支持 Claude 原始响应、转换后的 OpenAIResponse 以及流式响应中携带用量的数据块（message_delta）。
 func (ca *ClaudeAdapter) ExtractUsage(resp interface{}) (*Usage, error) {
-	respMap, ok := resp.(map[string]interface{})
-	if !ok {
+	var respMap map[string]interface{}
+	switch r := resp.(type) {
+	case map[string]interface{}:
+		respMap = r
+	case *OpenAIResponse:
+		return &r.Usage, nil
+	case *StreamChunk:
+		if r.Usage == nil {
+			return nil, fmt.Errorf("stream chunk carries no usage")
+		}
+		return r.Usage, nil
+	default:
 		return nil, fmt.Errorf("invalid response type")
 	}
 
@@ -233,12 +277,19 @@ func (ca *ClaudeAdapter) ExtractUsage(resp interface{}) (*Usage, error) {
 	return usage, nil
 }
 
-// GetError 获取错误
+// GetError 获取错误，解析 Claude 的 {"type":"error","error":{...}} 错误体
 func (ca *ClaudeAdapter) GetError(resp *http.Response) error {
 	if resp.StatusCode < 400 {
 		return nil
 	}
 
+	var errResp struct {
+		Error *ErrorInfo `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err == nil && errResp.Error != nil && errResp.Error.Message != "" {
+		return NewAdapterError(errResp.Error.Type, errResp.Error.Message)
+	}
+
 	return fmt.Errorf("http %d", resp.StatusCode)
 }
 
@@ -264,22 +315,14 @@ func NewGeminiAdapter(config *AdapterConfig) *GeminiAdapter {
 		"gemini-pro", "gemini-pro-vision",
 		"gemini-1.5-pro", "gemini-1.5-flash",
 	})
+	adapter.SetRequestIDHeaders("X-Goog-Request-Id")
 
 	return adapter
 }
 
 // ConvertRequest 转换请求
 func (ga *GeminiAdapter) ConvertRequest(req *OpenAIRequest) (interface{}, error) {
-	geminiReq := map[string]interface{}{
-		"contents": convertMessagesToContents(req.Messages),
-		"generation_config": map[string]interface{}{
-			"temperature":     req.Temperature,
-			"topP":            req.TopP,
-			"maxOutputTokens": req.MaxTokens,
-		},
-	}
-
-	return geminiReq, nil
+	return convertGeminiRequest(req), nil
 }
 
 // DoRequest 发送请求
@@ -289,19 +332,13 @@ func (ga *GeminiAdapter) DoRequest(ctx context.Context, convertedReq interface{}
 
 // ParseResponse 解析响应
 func (ga *GeminiAdapter) ParseResponse(resp *http.Response) (*OpenAIResponse, error) {
-	var geminiResp map[string]interface{}
+	var geminiResp geminiResponse
 
 	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %v", err)
 	}
 
-	result := &OpenAIResponse{
-		ID:      "gemini-response",
-		Model:   "gemini-pro",
-		Created: int64(0),
-	}
-
-	return result, nil
+	return geminiResp.toOpenAI()
 }
 
 // ParseStreamResponse 解析流式响应
@@ -316,8 +353,34 @@ func (ga *GeminiAdapter) ParseStreamResponse(resp *http.Response) (<-chan *Strea
 }
 
 // ExtractUsage 提取使用量
+//
+// 支持 Gemini 原始响应（usageMetadata）、转换后的 OpenAIResponse 以及携带用量的流式数据块。
 func (ga *GeminiAdapter) ExtractUsage(resp interface{}) (*Usage, error) {
-	return &Usage{}, nil
+	switch r := resp.(type) {
+	case map[string]interface{}:
+		var geminiResp geminiResponse
+		raw, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &geminiResp); err != nil {
+			return nil, err
+		}
+		if geminiResp.UsageMetadata == nil {
+			return &Usage{}, nil
+		}
+		usage := geminiResp.UsageMetadata.toUsage()
+		return &usage, nil
+	case *OpenAIResponse:
+		return &r.Usage, nil
+	case *StreamChunk:
+		if r.Usage == nil {
+			return nil, fmt.Errorf("stream chunk carries no usage")
+		}
+		return r.Usage, nil
+	default:
+		return nil, fmt.Errorf("invalid response type")
+	}
 }
 
 // GetError 获取错误
@@ -350,6 +413,7 @@ func NewBaiduAdapter(config *AdapterConfig) *BaiduAdapter {
 	adapter.SetSupportedModels([]string{
 		"eb-4", "eb-3.5-turbo", "bge-large-zh", "bge-base-zh",
 	})
+	adapter.SetRequestIDHeaders("X-Bce-Request-Id")
 
 	return adapter
 }
@@ -436,6 +500,7 @@ func NewQwenAdapter(config *AdapterConfig) *QwenAdapter {
 		"qwen-turbo", "qwen-plus", "qwen-max",
 		"qwen-vl-plus", "qwen-vl-max",
 	})
+	adapter.SetRequestIDHeaders("X-Dashscope-Request-Id")
 
 	return adapter
 }
@@ -449,6 +514,7 @@ func (qa *QwenAdapter) ConvertRequest(req *OpenAIRequest) (interface{}, error) {
 		"top_p":       req.TopP,
 		"max_tokens":  req.MaxTokens,
 	}
+	setToolParams(qwenReq, req)
 
 	return qwenReq, nil
 }
@@ -504,11 +570,3 @@ func (qa *QwenAdapter) GetError(resp *http.Response) error {
 func (qa *QwenAdapter) HealthCheck(ctx context.Context) error {
 	return nil
 }
-
-// ==================== 辅助函数 ====================
-
-// convertMessagesToContents 将消息转换为 Gemini 格式
-func convertMessagesToContents(messages []Message) interface{} {
-	// 转换逻辑
-	return messages
-}
@@ -1,6 +1,15 @@
 package adapter
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -176,6 +185,113 @@ func TestClaudeConvertRequest(t *testing.T) {
 	}
 }
 
+func TestClaudeConvertRequestSystemMessages(t *testing.T) {
+	adapter := NewClaudeAdapter(&AdapterConfig{Type: "claude", BaseURL: "https://api.anthropic.com/v1"})
+
+	tests := []struct {
+		name         string
+		messages     []Message
+		wantSystem   string // 为空表示不发送 system
+		wantMessages []claudeMessage
+	}{
+		{
+			name:         "system only",
+			messages:     []Message{{Role: "system", Content: "Be brief."}},
+			wantSystem:   "Be brief.",
+			wantMessages: []claudeMessage{},
+		},
+		{
+			name: "mixed",
+			messages: []Message{
+				{Role: "system", Content: "Be brief."},
+				{Role: "user", Content: "Hi"},
+				{Role: "system", Content: "Answer in French."},
+				{Role: "user", Content: "Weather?"},
+				{Role: "assistant", Content: "Il fait beau."},
+			},
+			wantSystem: "Be brief.\n\nAnswer in French.",
+			wantMessages: []claudeMessage{
+				{Role: "user", Content: "Hi\n\nWeather?"},
+				{Role: "assistant", Content: "Il fait beau."},
+			},
+		},
+		{
+			name: "no system",
+			messages: []Message{
+				{Role: "user", Content: "Hi"},
+				{Role: "assistant", Content: "Hello"},
+				{Role: "assistant", Content: []interface{}{map[string]interface{}{"type": "text", "text": "How can I help?"}}},
+				{Role: "user", Content: "Weather?"},
+			},
+			wantMessages: []claudeMessage{
+				{Role: "user", Content: "Hi"},
+				{Role: "assistant", Content: []interface{}{
+					map[string]interface{}{"type": "text", "text": "Hello"},
+					map[string]interface{}{"type": "text", "text": "How can I help?"},
+				}},
+				{Role: "user", Content: "Weather?"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converted, err := adapter.ConvertRequest(&OpenAIRequest{Model: "claude-3-opus", Messages: tt.messages})
+			if err != nil {
+				t.Fatalf("ConvertRequest failed: %v", err)
+			}
+			claudeReq := converted.(map[string]interface{})
+
+			system, ok := claudeReq["system"]
+			if tt.wantSystem == "" && ok {
+				t.Errorf("Expected no system parameter, got %q", system)
+			}
+			if tt.wantSystem != "" && system != tt.wantSystem {
+				t.Errorf("Expected system %q, got %q", tt.wantSystem, system)
+			}
+			if got := claudeReq["messages"]; !reflect.DeepEqual(got, tt.wantMessages) {
+				t.Errorf("Expected messages %#v, got %#v", tt.wantMessages, got)
+			}
+			if claudeReq["max_tokens"] != claudeDefaultMaxTokens {
+				t.Errorf("Expected default max_tokens, got %v", claudeReq["max_tokens"])
+			}
+		})
+	}
+}
+
+func TestClaudeRequestHeaders(t *testing.T) {
+	var received *http.Request
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	adapter := NewClaudeAdapter(&AdapterConfig{Type: "claude", BaseURL: server.URL, APIKey: "sk-ant-test"})
+	converted, _ := adapter.ConvertRequest(&OpenAIRequest{Model: "claude-3-opus", MaxCompletionTokens: 300,
+		Messages: []Message{{Role: "user", Content: "Hi"}}})
+	resp, err := adapter.DoRequest(context.Background(), converted)
+	if err != nil {
+		t.Fatalf("DoRequest failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if got := received.Header.Get("anthropic-version"); got != ClaudeAPIVersion {
+		t.Errorf("Expected anthropic-version %s, got %q", ClaudeAPIVersion, got)
+	}
+	if got := received.Header.Get("x-api-key"); got != "sk-ant-test" {
+		t.Errorf("Expected x-api-key auth, got %q", got)
+	}
+	if received.Header.Get("Authorization") != "" {
+		t.Error("Claude requests must not send a bearer token")
+	}
+	if body["max_tokens"] != float64(300) {
+		t.Errorf("Expected max_completion_tokens to be used as max_tokens, got %v", body["max_tokens"])
+	}
+}
+
 func TestGeminiConvertRequest(t *testing.T) {
 	config := &AdapterConfig{
 		Type:    "gemini",
@@ -207,6 +323,140 @@ func TestGeminiConvertRequest(t *testing.T) {
 	}
 }
 
+func TestGeminiConvertRequestRoles(t *testing.T) {
+	adapter := NewGeminiAdapter(&AdapterConfig{Type: "gemini", BaseURL: "https://generativelanguage.googleapis.com/v1beta/models"})
+
+	converted, err := adapter.ConvertRequest(&OpenAIRequest{
+		Model:               "gemini-1.5-flash",
+		MaxCompletionTokens: 256,
+		Stop:                []string{"END"},
+		Messages: []Message{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "Hi"},
+			{Role: "assistant", Content: "Hello"},
+			{Role: "system", Content: "Answer in French."},
+			{Role: "user", Content: []interface{}{
+				map[string]interface{}{"type": "text", "text": "What is this?"},
+				map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/png;base64,iVBORw0KGgo="}},
+				map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "https://example.com/cat.png"}},
+			}},
+			{Role: "user", Content: "Weather?"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ConvertRequest failed: %v", err)
+	}
+
+	raw, err := json.Marshal(converted)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var body struct {
+		Contents          []geminiContent        `json:"contents"`
+		SystemInstruction *geminiContent         `json:"systemInstruction"`
+		GenerationConfig  map[string]interface{} `json:"generationConfig"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	wantSystem := &geminiContent{Parts: []geminiPart{{Text: "Be brief."}, {Text: "Answer in French."}}}
+	if !reflect.DeepEqual(body.SystemInstruction, wantSystem) {
+		t.Errorf("Expected systemInstruction %#v, got %#v", wantSystem, body.SystemInstruction)
+	}
+	wantContents := []geminiContent{
+		{Role: "user", Parts: []geminiPart{{Text: "Hi"}}},
+		{Role: "model", Parts: []geminiPart{{Text: "Hello"}}},
+		{Role: "user", Parts: []geminiPart{
+			{Text: "What is this?"},
+			{InlineData: &geminiInlineData{MimeType: "image/png", Data: "iVBORw0KGgo="}},
+			{FileData: &geminiFileData{MimeType: "image/png", FileURI: "https://example.com/cat.png"}},
+			{Text: "Weather?"},
+		}},
+	}
+	if !reflect.DeepEqual(body.Contents, wantContents) {
+		t.Errorf("Expected contents %#v, got %#v", wantContents, body.Contents)
+	}
+
+	// 未指定的参数不发送
+	wantConfig := map[string]interface{}{"maxOutputTokens": float64(256), "stopSequences": []interface{}{"END"}}
+	if !reflect.DeepEqual(body.GenerationConfig, wantConfig) {
+		t.Errorf("Expected generationConfig %v, got %v", wantConfig, body.GenerationConfig)
+	}
+}
+
+func TestGeminiParseResponse(t *testing.T) {
+	adapter := NewGeminiAdapter(&AdapterConfig{Type: "gemini"})
+
+	sample, err := os.ReadFile("testdata/gemini/generate_content.json")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	resp, err := adapter.ParseResponse(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(sample))})
+	if err != nil {
+		t.Fatalf("ParseResponse failed: %v", err)
+	}
+
+	if resp.ID != "mL5aZ5y7Jt6V1MkPxJm7yQI" || resp.Model != "gemini-1.5-flash-002" || resp.Object != "chat.completion" || resp.Created == 0 {
+		t.Errorf("Unexpected response metadata: %+v", resp)
+	}
+	if len(resp.Choices) != 1 {
+		t.Fatalf("Expected 1 choice, got %d", len(resp.Choices))
+	}
+	choice := resp.Choices[0]
+	if choice.Message.Role != "assistant" || choice.Message.Content != "The capital of France is Paris. It has been the capital since 508 AD." {
+		t.Errorf("Unexpected message: %+v", choice.Message)
+	}
+	if choice.FinishReason != "stop" {
+		t.Errorf("Expected finish_reason stop, got %q", choice.FinishReason)
+	}
+
+	wantUsage := Usage{PromptTokens: 14, CompletionTokens: 17, TotalTokens: 31}
+	if resp.Usage != wantUsage {
+		t.Errorf("Expected usage %+v, got %+v", wantUsage, resp.Usage)
+	}
+	usage, err := adapter.ExtractUsage(resp)
+	if err != nil || *usage != wantUsage {
+		t.Errorf("ExtractUsage(response) = %+v, %v", usage, err)
+	}
+
+	// 原始响应体同样可以提取用量
+	var raw map[string]interface{}
+	if err := json.Unmarshal(sample, &raw); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	usage, err = adapter.ExtractUsage(raw)
+	if err != nil || *usage != wantUsage {
+		t.Errorf("ExtractUsage(raw) = %+v, %v", usage, err)
+	}
+}
+
+func TestGeminiParseResponseFinishReasons(t *testing.T) {
+	adapter := NewGeminiAdapter(&AdapterConfig{Type: "gemini"})
+	parse := func(body string) (*OpenAIResponse, error) {
+		return adapter.ParseResponse(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))})
+	}
+
+	resp, err := parse(`{"candidates":[{"content":{"parts":[{"text":"Once upon"}],"role":"model"},"finishReason":"MAX_TOKENS"},
+		{"content":{"parts":[],"role":"model"},"finishReason":"SAFETY","index":1}],
+		"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":2,"thoughtsTokenCount":40,"totalTokenCount":47}}`)
+	if err != nil {
+		t.Fatalf("ParseResponse failed: %v", err)
+	}
+	if resp.Choices[0].FinishReason != "length" || resp.Choices[1].FinishReason != "content_filter" || resp.Choices[1].Index != 1 {
+		t.Errorf("Unexpected choices: %+v", resp.Choices)
+	}
+	// 思考 Token 计入输出 Token
+	if resp.Usage.CompletionTokens != 42 || resp.Usage.TotalTokens != 47 || resp.Usage.ReasoningTokens() != 40 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+
+	// 提示词被拦截时没有候选结果
+	if _, err := parse(`{"promptFeedback":{"blockReason":"SAFETY"},"usageMetadata":{"promptTokenCount":5,"totalTokenCount":5}}`); err == nil {
+		t.Error("Expected blocked prompt to return an error")
+	}
+}
+
 func TestBaiduConvertRequest(t *testing.T) {
 	config := &AdapterConfig{
 		Type:    "baidu",
@@ -332,3 +582,103 @@ func BenchmarkAllAdapters(b *testing.B) {
 	}
 }
 
+
+func TestConvertRequestResponseFormat(t *testing.T) {
+	schema := map[string]interface{}{"type": "object", "properties": map[string]interface{}{"colors": map[string]interface{}{"type": "array"}}}
+	jsonObject := map[string]interface{}{"type": "json_object"}
+	jsonSchema := map[string]interface{}{"type": "json_schema", "json_schema": map[string]interface{}{"name": "colors", "schema": schema}}
+	newReq := func(format interface{}) *OpenAIRequest {
+		return &OpenAIRequest{Model: "m", Messages: []Message{{Role: "user", Content: "Hi"}}, ResponseFormat: format}
+	}
+
+	deepseek, _ := NewDeepSeekAdapter(&AdapterConfig{Type: "deepseek"}).ConvertRequest(newReq(jsonObject))
+	if body := deepseek.(map[string]interface{}); !reflect.DeepEqual(body["response_format"], jsonObject) {
+		t.Errorf("Expected response_format to be forwarded, got %v", body["response_format"])
+	}
+	qwen, _ := NewQwenAdapter(&AdapterConfig{Type: "qwen"}).ConvertRequest(newReq(nil))
+	if _, ok := qwen.(map[string]interface{})["response_format"]; ok {
+		t.Error("Expected response_format to be omitted when unset")
+	}
+
+	ollama := NewOllamaAdapter(&AdapterConfig{Type: "ollama"})
+	for _, tc := range []struct {
+		format interface{}
+		want   interface{}
+	}{{jsonObject, "json"}, {jsonSchema, schema}, {map[string]interface{}{"type": "text"}, nil}} {
+		converted, _ := ollama.ConvertRequest(newReq(tc.format))
+		if got := converted.(*ollamaChatRequest).Format; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Ollama format for %v: expected %v, got %v", tc.format, tc.want, got)
+		}
+	}
+
+	gemini, _ := NewGeminiAdapter(&AdapterConfig{Type: "gemini"}).ConvertRequest(newReq(jsonSchema))
+	if mime := gemini.(*geminiRequest).GenerationConfig.ResponseMimeType; mime != "application/json" {
+		t.Errorf("Expected Gemini responseMimeType application/json, got %q", mime)
+	}
+}
+
+func TestConvertRequestImageParts(t *testing.T) {
+	const dataURL = "data:image/png;base64,iVBORw0KGgo="
+	const httpsURL = "https://example.com/photos/cat.webp?size=large"
+	newReq := func(url string) *OpenAIRequest {
+		return &OpenAIRequest{Model: "m", Messages: []Message{{Role: "user", Content: []interface{}{
+			map[string]interface{}{"type": "text", "text": "What is this?"},
+			map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": url, "detail": "low"}},
+		}}}}
+	}
+
+	tests := []struct {
+		name       string
+		url        string
+		wantClaude map[string]interface{}
+		wantGemini geminiPart
+	}{
+		{
+			name:       "data url",
+			url:        dataURL,
+			wantClaude: map[string]interface{}{"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo="},
+			wantGemini: geminiPart{InlineData: &geminiInlineData{MimeType: "image/png", Data: "iVBORw0KGgo="}},
+		},
+		{
+			name:       "https url",
+			url:        httpsURL,
+			wantClaude: map[string]interface{}{"type": "url", "url": httpsURL},
+			wantGemini: geminiPart{FileData: &geminiFileData{MimeType: "image/webp", FileURI: httpsURL}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// OpenAI 原样转发内容片段
+			openai, err := NewOpenAIAdapter(&AdapterConfig{Type: "openai"}).ConvertRequest(newReq(tt.url))
+			if err != nil {
+				t.Fatalf("OpenAI ConvertRequest failed: %v", err)
+			}
+			raw, _ := json.Marshal(openai)
+			if !strings.Contains(string(raw), `"image_url":{"detail":"low","url":"`+tt.url) {
+				t.Errorf("Expected OpenAI request to keep the image part, got %s", raw)
+			}
+
+			claude, err := NewClaudeAdapter(&AdapterConfig{Type: "claude"}).ConvertRequest(newReq(tt.url))
+			if err != nil {
+				t.Fatalf("Claude ConvertRequest failed: %v", err)
+			}
+			wantClaude := []claudeMessage{{Role: "user", Content: []interface{}{
+				map[string]interface{}{"type": "text", "text": "What is this?"},
+				map[string]interface{}{"type": "image", "source": tt.wantClaude},
+			}}}
+			if got := claude.(map[string]interface{})["messages"]; !reflect.DeepEqual(got, wantClaude) {
+				t.Errorf("Expected Claude messages %#v, got %#v", wantClaude, got)
+			}
+
+			gemini, err := NewGeminiAdapter(&AdapterConfig{Type: "gemini"}).ConvertRequest(newReq(tt.url))
+			if err != nil {
+				t.Fatalf("Gemini ConvertRequest failed: %v", err)
+			}
+			wantGemini := []geminiContent{{Role: "user", Parts: []geminiPart{{Text: "What is this?"}, tt.wantGemini}}}
+			if got := gemini.(*geminiRequest).Contents; !reflect.DeepEqual(got, wantGemini) {
+				t.Errorf("Expected Gemini contents %#v, got %#v", wantGemini, got)
+			}
+		})
+	}
+}
@@ -141,6 +141,14 @@ func registerCoreAdapters() {
 	globalRegistry.Register("qwen", func(config *AdapterConfig) Adapter {
 		return NewQwenAdapter(config)
 	}, "v1.0.0")
+
+	globalRegistry.Register("azure", func(config *AdapterConfig) Adapter {
+		return NewAzureOpenAIAdapter(config)
+	}, "v1.0.0")
+
+	globalRegistry.Register("ollama", func(config *AdapterConfig) Adapter {
+		return NewOllamaAdapter(config)
+	}, "v1.0.0")
 }
 
 // registerBatchAdapters 注册批量适配器
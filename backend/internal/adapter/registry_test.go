@@ -149,7 +149,7 @@ func TestGlobalRegistry(t *testing.T) {
 func TestCoreAdaptersRegistration(t *testing.T) {
 	registry := GetGlobalRegistry()
 
-	coreAdapters := []string{"openai", "claude", "gemini", "baidu", "qwen"}
+	coreAdapters := []string{"openai", "claude", "gemini", "baidu", "qwen", "azure", "ollama"}
 
 	for _, name := range coreAdapters {
 		version, err := registry.GetVersion(name)
@@ -0,0 +1,61 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+)
+
+// commonRequestIDHeaders 常见的上游请求 ID 响应头，在适配器未声明时兜底使用
+var commonRequestIDHeaders = []string{"X-Request-Id", "Request-Id"}
+
+// RequestIDExtractor 可从上游响应中提取提供方请求 ID 的适配器
+type RequestIDExtractor interface {
+	UpstreamRequestID(resp *http.Response) string
+}
+
+// WithRequestID 将本系统的请求 ID 放入上下文，发往上游时会按渠道配置透传
+//
+// 与日志共用同一个键，RequestIDMiddleware 写入的 ID 无需再次设置即可透传。
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return logger.ContextWithRequestID(ctx, requestID)
+}
+
+// RequestIDFromContext 从上下文中获取本系统的请求 ID
+func RequestIDFromContext(ctx context.Context) string {
+	return logger.RequestIDFromContext(ctx)
+}
+
+// DefaultRequestIDHeader 各提供方默认用于透传请求 ID 的请求头
+func DefaultRequestIDHeader(providerType ProviderType) string {
+	switch providerType {
+	case ProviderOpenAI, ProviderAzure:
+		// OpenAI 会在其日志中记录该请求头，便于工单关联
+		return "X-Client-Request-Id"
+	default:
+		return "X-Request-Id"
+	}
+}
+
+// UpstreamRequestID 提取上游响应中的提供方请求 ID
+// 只依赖响应头，因此流式响应在读取响应体之前即可调用
+func UpstreamRequestID(a Adapter, resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+	if extractor, ok := a.(RequestIDExtractor); ok {
+		return extractor.UpstreamRequestID(resp)
+	}
+	return headerValue(resp.Header, commonRequestIDHeaders)
+}
+
+// headerValue 按顺序返回第一个非空的响应头
+func headerValue(header http.Header, names []string) string {
+	for _, name := range names {
+		if v := header.Get(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,169 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+func TestUpstreamRequestIDPerAdapter(t *testing.T) {
+	cases := []struct {
+		name    string
+		adapter Adapter
+		header  string
+	}{
+		{"openai", NewOpenAIAdapter(&AdapterConfig{Type: "openai"}), "X-Request-Id"},
+		{"claude", NewClaudeAdapter(&AdapterConfig{Type: "claude"}), "Request-Id"},
+		{"claude-alt", NewClaudeAdapter(&AdapterConfig{Type: "claude"}), "Anthropic-Request-Id"},
+		{"gemini", NewGeminiAdapter(&AdapterConfig{Type: "gemini"}), "X-Goog-Request-Id"},
+		{"baidu", NewBaiduAdapter(&AdapterConfig{Type: "baidu"}), "X-Bce-Request-Id"},
+		{"qwen", NewQwenAdapter(&AdapterConfig{Type: "qwen"}), "X-Dashscope-Request-Id"},
+		{"deepseek", NewDeepSeekAdapter(&AdapterConfig{Type: "deepseek"}), "X-Request-Id"},
+		{"moonshot", NewMoonshotAdapter(&AdapterConfig{Type: "moonshot"}), "X-Request-Id"},
+		{"minimax", NewMinimaxAdapter(&AdapterConfig{Type: "minimax"}), "Trace-Id"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			resp.Header.Set(tc.header, "req_"+tc.name)
+
+			if got := UpstreamRequestID(tc.adapter, resp); got != "req_"+tc.name {
+				t.Errorf("Expected req_%s, got %q", tc.name, got)
+			}
+		})
+	}
+}
+
+func TestUpstreamRequestIDMissing(t *testing.T) {
+	a := NewOpenAIAdapter(&AdapterConfig{Type: "openai"})
+
+	if got := UpstreamRequestID(a, &http.Response{Header: http.Header{}}); got != "" {
+		t.Errorf("Expected empty request id, got %q", got)
+	}
+	if got := UpstreamRequestID(a, nil); got != "" {
+		t.Errorf("Expected empty request id for nil response, got %q", got)
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("X-Client-Request-Id")
+		w.Header().Set("X-Request-Id", "req_upstream")
+		fmt.Fprint(w, `{"id":"chatcmpl-1","choices":[]}`)
+	}))
+	defer server.Close()
+
+	a := NewOpenAIAdapter(&AdapterConfig{
+		Type:            "openai",
+		BaseURL:         server.URL,
+		Timeout:         5 * time.Second,
+		RequestIDHeader: "X-Client-Request-Id",
+	})
+
+	ctx := WithRequestID(context.Background(), "req_ours")
+	resp, err := a.DoRequest(ctx, &OpenAIRequest{Model: "gpt-4"})
+	if err != nil {
+		t.Fatalf("DoRequest failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if received != "req_ours" {
+		t.Errorf("Expected upstream to receive req_ours, got %q", received)
+	}
+	if got := UpstreamRequestID(a, resp); got != "req_upstream" {
+		t.Errorf("Expected req_upstream, got %q", got)
+	}
+}
+
+func TestRequestIDNotSentWhenDisabled(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	a := NewClaudeAdapter(&AdapterConfig{Type: "claude", BaseURL: server.URL, Timeout: 5 * time.Second})
+
+	resp, err := a.DoRequest(WithRequestID(context.Background(), "req_ours"), map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("DoRequest failed: %v", err)
+	}
+	resp.Body.Close()
+
+	for name, values := range headers {
+		for _, v := range values {
+			if v == "req_ours" {
+				t.Errorf("Expected request id not to be sent, found in header %s", name)
+			}
+		}
+	}
+}
+
+func TestUpstreamRequestIDStreamingBeforeBody(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("X-Request-Id", "req_stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		// 响应体在请求头之后才到达
+		<-release
+		fmt.Fprint(w, "data: {\"id\":\"chunk-1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+	defer close(release)
+
+	a := NewOpenAIAdapter(&AdapterConfig{Type: "openai", BaseURL: server.URL, Timeout: 5 * time.Second})
+
+	resp, err := a.DoRequest(context.Background(), &OpenAIRequest{Model: "gpt-4", Stream: true})
+	if err != nil {
+		t.Fatalf("DoRequest failed: %v", err)
+	}
+
+	// 此时响应体尚未发送，请求 ID 已可用
+	if got := UpstreamRequestID(a, resp); got != "req_stream" {
+		t.Fatalf("Expected req_stream before body, got %q", got)
+	}
+
+	chunks, err := a.ParseStreamResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseStreamResponse failed: %v", err)
+	}
+	release <- struct{}{}
+
+	count := 0
+	for range chunks {
+		count++
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 chunk, got %d", count)
+	}
+}
+
+func TestChannelRequestIDHeaderSetting(t *testing.T) {
+	ch := &model.Channel{Type: "openai"}
+	if got := ch.GetRequestIDHeader(DefaultRequestIDHeader(ProviderOpenAI)); got != "X-Client-Request-Id" {
+		t.Errorf("Expected default header, got %q", got)
+	}
+
+	ch.OtherSettings = `{"request_id_header": "X-Trace-Id"}`
+	if got := ch.GetRequestIDHeader("X-Request-Id"); got != "X-Trace-Id" {
+		t.Errorf("Expected configured header, got %q", got)
+	}
+
+	// 部分上游会拒绝未知请求头，允许按渠道关闭
+	ch.OtherSettings = `{"request_id_header": ""}`
+	if got := ch.GetRequestIDHeader("X-Request-Id"); got != "" {
+		t.Errorf("Expected disabled header, got %q", got)
+	}
+}
@@ -0,0 +1,283 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// ==================== 自托管适配器（Ollama / vLLM / LM Studio） ====================
+
+// ErrUpstreamUnavailable 自托管服务不可达（未启动、主机休眠等），属于预期内的离线
+var ErrUpstreamUnavailable = errors.New("self-hosted upstream unavailable")
+
+// DefaultSelfHostedExtraBody 自托管渠道默认允许透传的 extra_body 字段
+var DefaultSelfHostedExtraBody = []string{"keep_alive", "options"}
+
+// ModelLister 支持从上游发现可用模型的适配器
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// ExtraBodyAdapter 支持透传 extra_body 字段的适配器
+type ExtraBodyAdapter interface {
+	DroppedExtraBody(req *OpenAIRequest) []string
+}
+
+// SelfHostedAdapter 自托管的 OpenAI 兼容服务适配器
+//
+// 与 OpenAI 的差异：允许不配置密钥；Ollama 额外提供 /api/tags 模型列表；
+// 上游离线时返回 ErrUpstreamUnavailable，调用方据此降低日志级别。
+type SelfHostedAdapter struct {
+	*OpenAIAdapter
+	provider  ProviderType
+	rootURL   string
+	allowlist map[string]bool
+}
+
+// NewSelfHostedAdapter 创建自托管适配器
+// BaseURL 可以是服务根地址（http://localhost:11434）或带 /v1 的地址
+func NewSelfHostedAdapter(provider ProviderType, config *AdapterConfig) *SelfHostedAdapter {
+	root := strings.TrimSuffix(strings.TrimRight(config.BaseURL, "/"), "/v1")
+
+	cfg := *config
+	cfg.BaseURL = root + "/v1"
+
+	allowed := config.ExtraBodyAllowlist
+	if len(allowed) == 0 {
+		allowed = DefaultSelfHostedExtraBody
+	}
+	allowlist := make(map[string]bool, len(allowed))
+	for _, key := range allowed {
+		allowlist[key] = true
+	}
+
+	adapter := &SelfHostedAdapter{
+		OpenAIAdapter: NewOpenAIAdapter(&cfg),
+		provider:      provider,
+		rootURL:       root,
+		allowlist:     allowlist,
+	}
+	// 模型由运维加载，初始为空，通过 ListModels 发现
+	adapter.SetSupportedModels(nil)
+
+	return adapter
+}
+
+// Provider 返回提供商类型
+func (sa *SelfHostedAdapter) Provider() ProviderType {
+	return sa.provider
+}
+
+// ConvertRequest 转换请求：展开 allowlist 内的 extra_body 字段，其余字段丢弃
+func (sa *SelfHostedAdapter) ConvertRequest(req *OpenAIRequest) (interface{}, error) {
+	extra := req.Extra
+	req.Extra = nil
+	defer func() { req.Extra = extra }()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	body := make(map[string]interface{})
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("failed to build request body: %v", err)
+	}
+
+	for key, value := range extra {
+		if !sa.allowlist[key] {
+			continue
+		}
+		// 不允许覆盖标准字段
+		if _, exists := body[key]; exists {
+			continue
+		}
+		body[key] = value
+	}
+
+	return body, nil
+}
+
+// DroppedExtraBody 返回不在 allowlist 内、会被丢弃的 extra_body 字段
+func (sa *SelfHostedAdapter) DroppedExtraBody(req *OpenAIRequest) []string {
+	dropped := make([]string, 0)
+	for key := range req.Extra {
+		if !sa.allowlist[key] {
+			dropped = append(dropped, key)
+		}
+	}
+	sort.Strings(dropped)
+	return dropped
+}
+
+// DoRequest 发送请求
+func (sa *SelfHostedAdapter) DoRequest(ctx context.Context, convertedReq interface{}) (*http.Response, error) {
+	req, err := sa.NewRequest(ctx, "POST", "/chat/completions", convertedReq)
+	if err != nil {
+		return nil, err
+	}
+	return sa.do(req)
+}
+
+// GetError 获取错误（Ollama 的 error 字段为字符串）
+func (sa *SelfHostedAdapter) GetError(resp *http.Response) error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+
+	var errResp struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || len(errResp.Error) == 0 {
+		return fmt.Errorf("http %d", resp.StatusCode)
+	}
+
+	var message string
+	if err := json.Unmarshal(errResp.Error, &message); err == nil {
+		return NewAdapterError(fmt.Sprintf("http_%d", resp.StatusCode), message)
+	}
+	var info ErrorInfo
+	if err := json.Unmarshal(errResp.Error, &info); err == nil && info.Message != "" {
+		return NewAdapterError(info.Code, info.Message)
+	}
+	return fmt.Errorf("http %d", resp.StatusCode)
+}
+
+// ListModels 发现上游当前加载的模型
+// Ollama 优先使用 /api/tags，其余优先 /v1/models；首选端点不可用时尝试另一个
+func (sa *SelfHostedAdapter) ListModels(ctx context.Context) ([]string, error) {
+	endpoints := []func(context.Context) ([]string, error){sa.listOpenAIModels, sa.listOllamaTags}
+	if sa.provider == ProviderOllama {
+		endpoints[0], endpoints[1] = endpoints[1], endpoints[0]
+	}
+
+	var firstErr error
+	for _, list := range endpoints {
+		models, err := list(ctx)
+		if err == nil {
+			sort.Strings(models)
+			return models, nil
+		}
+		// 服务离线时另一个端点同样不可达
+		if errors.Is(err, ErrUpstreamUnavailable) {
+			return nil, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return nil, firstErr
+}
+
+// HealthCheck 健康检查：能列出模型即视为可用
+func (sa *SelfHostedAdapter) HealthCheck(ctx context.Context) error {
+	_, err := sa.ListModels(ctx)
+	return err
+}
+
+// listOpenAIModels GET /v1/models
+func (sa *SelfHostedAdapter) listOpenAIModels(ctx context.Context) ([]string, error) {
+	var result struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := sa.getJSON(ctx, sa.rootURL+"/v1/models", &result); err != nil {
+		return nil, err
+	}
+
+	models := make([]string, 0, len(result.Data))
+	for _, m := range result.Data {
+		if m.ID != "" {
+			models = append(models, m.ID)
+		}
+	}
+	return models, nil
+}
+
+// listOllamaTags GET /api/tags（Ollama 原生接口）
+func (sa *SelfHostedAdapter) listOllamaTags(ctx context.Context) ([]string, error) {
+	var result struct {
+		Models []struct {
+			Name  string `json:"name"`
+			Model string `json:"model"`
+		} `json:"models"`
+	}
+	if err := sa.getJSON(ctx, sa.rootURL+"/api/tags", &result); err != nil {
+		return nil, err
+	}
+
+	models := make([]string, 0, len(result.Models))
+	for _, m := range result.Models {
+		name := m.Name
+		if name == "" {
+			name = m.Model
+		}
+		if name != "" {
+			models = append(models, name)
+		}
+	}
+	return models, nil
+}
+
+// getJSON 请求并解析 JSON 响应
+func (sa *SelfHostedAdapter) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	sa.prepareRequest(ctx, req)
+
+	resp, err := sa.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: http %d", req.URL.Path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("GET %s: failed to decode response: %v", req.URL.Path, err)
+	}
+	return nil
+}
+
+// do 执行请求，将连接失败归类为 ErrUpstreamUnavailable
+func (sa *SelfHostedAdapter) do(req *http.Request) (*http.Response, error) {
+	resp, err := sa.httpClient.Do(req)
+	if err != nil {
+		if IsUnavailableError(err) {
+			return nil, fmt.Errorf("%w: %v", ErrUpstreamUnavailable, err)
+		}
+		return nil, fmt.Errorf("http request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// IsUnavailableError 判断错误是否表示上游离线（连接被拒绝、主机不可达、拨号超时）
+func IsUnavailableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrUpstreamUnavailable) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) {
+		return true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// fixtureServer 回放录制的自托管服务响应，记录收到的请求
+type fixtureServer struct {
+	*httptest.Server
+	routes   map[string]string // 路径 -> testdata 文件
+	lastAuth string
+	lastBody map[string]interface{}
+}
+
+func newFixtureServer(t *testing.T, routes map[string]string) *fixtureServer {
+	fs := &fixtureServer{routes: routes}
+	fs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fs.lastAuth = r.Header.Get("Authorization")
+		if r.Body != nil {
+			data, _ := io.ReadAll(r.Body)
+			fs.lastBody = nil
+			_ = json.Unmarshal(data, &fs.lastBody)
+		}
+
+		file, ok := fs.routes[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"404 page not found"}`))
+			return
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Errorf("failed to read fixture %s: %v", file, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	t.Cleanup(fs.Close)
+	return fs
+}
+
+func ollamaFixture(t *testing.T) *fixtureServer {
+	return newFixtureServer(t, map[string]string{
+		"/api/tags":            "testdata/ollama/api_tags.json",
+		"/v1/chat/completions": "testdata/ollama/chat_completion.json",
+	})
+}
+
+func TestSelfHostedListModels(t *testing.T) {
+	ollama := ollamaFixture(t)
+	vllm := newFixtureServer(t, map[string]string{
+		"/v1/models": "testdata/vllm/v1_models.json",
+	})
+
+	cases := []struct {
+		name     string
+		provider ProviderType
+		baseURL  string
+		expected []string
+	}{
+		{"ollama tags", ProviderOllama, ollama.URL, []string{"llama3.1:8b", "qwen2.5:7b"}},
+		{"vllm models", ProviderVLLM, vllm.URL + "/v1", []string{"meta-llama/Meta-Llama-3.1-8B-Instruct"}},
+		// LM Studio 优先 /v1/models，服务端只有 /api/tags 时回退
+		{"fallback to tags", ProviderLMStudio, ollama.URL + "/v1/", []string{"llama3.1:8b", "qwen2.5:7b"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewSelfHostedAdapter(tc.provider, &AdapterConfig{Type: tc.provider.String(), BaseURL: tc.baseURL, Timeout: 5 * time.Second})
+
+			models, err := a.ListModels(context.Background())
+			if err != nil {
+				t.Fatalf("ListModels failed: %v", err)
+			}
+			if !reflect.DeepEqual(models, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, models)
+			}
+		})
+	}
+
+	if ollama.lastAuth != "" {
+		t.Errorf("Expected no Authorization header without api key, got %q", ollama.lastAuth)
+	}
+}
+
+func TestSelfHostedChatCompletionExtraBody(t *testing.T) {
+	ollama := ollamaFixture(t)
+	a := NewSelfHostedAdapter(ProviderOllama, &AdapterConfig{Type: "ollama", BaseURL: ollama.URL, Timeout: 5 * time.Second})
+
+	req := &OpenAIRequest{
+		Model:    "llama3.1:8b",
+		Messages: []Message{{Role: "user", Content: "hi"}},
+		Extra: map[string]interface{}{
+			"keep_alive": "10m",
+			"options":    map[string]interface{}{"num_ctx": 8192},
+			"raw":        true,
+		},
+	}
+
+	if dropped := a.DroppedExtraBody(req); !reflect.DeepEqual(dropped, []string{"raw"}) {
+		t.Errorf("Expected raw to be dropped, got %v", dropped)
+	}
+
+	converted, err := a.ConvertRequest(req)
+	if err != nil {
+		t.Fatalf("ConvertRequest failed: %v", err)
+	}
+	resp, err := a.DoRequest(context.Background(), converted)
+	if err != nil {
+		t.Fatalf("DoRequest failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	result, err := a.ParseResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseResponse failed: %v", err)
+	}
+	if result.Usage.TotalTokens != 21 {
+		t.Errorf("Expected 21 total tokens, got %d", result.Usage.TotalTokens)
+	}
+
+	body := ollama.lastBody
+	if body["keep_alive"] != "10m" {
+		t.Errorf("Expected keep_alive to be forwarded, got %v", body["keep_alive"])
+	}
+	if opts, ok := body["options"].(map[string]interface{}); !ok || opts["num_ctx"] != float64(8192) {
+		t.Errorf("Expected options to be forwarded, got %v", body["options"])
+	}
+	if _, ok := body["raw"]; ok {
+		t.Error("Expected raw to be dropped")
+	}
+	if _, ok := body["extra"]; ok {
+		t.Error("Expected extra not to be sent as a field")
+	}
+	if req.Extra == nil {
+		t.Error("Expected ConvertRequest to leave the original request intact")
+	}
+}
+
+func TestSelfHostedCustomAllowlist(t *testing.T) {
+	a := NewSelfHostedAdapter(ProviderVLLM, &AdapterConfig{Type: "vllm", ExtraBodyAllowlist: []string{"guided_json"}})
+
+	req := &OpenAIRequest{Model: "m", Extra: map[string]interface{}{"guided_json": "{}", "keep_alive": "5m"}}
+	converted, err := a.ConvertRequest(req)
+	if err != nil {
+		t.Fatalf("ConvertRequest failed: %v", err)
+	}
+
+	body := converted.(map[string]interface{})
+	if _, ok := body["guided_json"]; !ok {
+		t.Error("Expected guided_json to be forwarded")
+	}
+	if _, ok := body["keep_alive"]; ok {
+		t.Error("Expected keep_alive to be dropped by custom allowlist")
+	}
+}
+
+func TestSelfHostedUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	a := NewSelfHostedAdapter(ProviderOllama, &AdapterConfig{Type: "ollama", BaseURL: url, Timeout: 2 * time.Second})
+
+	_, err := a.ListModels(context.Background())
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Fatalf("Expected ErrUpstreamUnavailable, got %v", err)
+	}
+	if !IsUnavailableError(err) {
+		t.Error("Expected IsUnavailableError to be true")
+	}
+	if err := a.HealthCheck(context.Background()); !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Errorf("Expected health check to report unavailable, got %v", err)
+	}
+
+	if IsUnavailableError(errors.New("http 500")) {
+		t.Error("Expected other errors not to be treated as unavailable")
+	}
+}
+
+func TestSelfHostedGetError(t *testing.T) {
+	a := NewSelfHostedAdapter(ProviderOllama, &AdapterConfig{Type: "ollama"})
+
+	resp := httptest.NewRecorder()
+	resp.WriteHeader(http.StatusNotFound)
+	resp.WriteString(`{"error":"model \"llama9\" not found, try pulling it first"}`)
+
+	err := a.GetError(resp.Result())
+	var adapterErr *AdapterError
+	if !errors.As(err, &adapterErr) || adapterErr.Message != `model "llama9" not found, try pulling it first` {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestGetAdapterByChannelSelfHosted(t *testing.T) {
+	settings := `{"extra_body_allowlist":["keep_alive"]}`
+	ch := &model.Channel{Type: "ollama", BaseURL: "http://localhost:11434", OtherSettings: settings}
+
+	a, err := GetAdapterByChannel(ch)
+	if err != nil {
+		t.Fatalf("GetAdapterByChannel failed: %v", err)
+	}
+	oa, ok := a.(*OllamaAdapter)
+	if !ok {
+		t.Fatalf("Expected *OllamaAdapter, got %T", a)
+	}
+	sa := oa.SelfHostedAdapter
+	if sa.Provider() != ProviderOllama || !sa.allowlist["keep_alive"] || sa.allowlist["options"] {
+		t.Errorf("Unexpected adapter setup: provider %s allowlist %v", sa.Provider(), sa.allowlist)
+	}
+	if _, ok := a.(ModelLister); !ok {
+		t.Error("Expected self-hosted adapter to implement ModelLister")
+	}
+	if !ParseProviderType("lm-studio").IsSelfHosted() || ProviderOpenAI.IsSelfHosted() {
+		t.Error("Unexpected IsSelfHosted result")
+	}
+}
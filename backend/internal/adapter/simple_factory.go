@@ -6,21 +6,49 @@ import (
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
 )
 
-// GetAdapterByChannel 根据渠道获取适配器
+// GetAdapterByChannel 根据渠道获取适配器，多密钥渠道使用第一个密钥
 func GetAdapterByChannel(channel *model.Channel) (Adapter, error) {
+	return GetAdapterByChannelKey(channel, "")
+}
+
+// GetAdapterByChannelKey 根据渠道获取使用指定密钥的适配器，key 为空时使用渠道的第一个密钥
+func GetAdapterByChannelKey(channel *model.Channel, key string) (Adapter, error) {
+	if key == "" {
+		key = channel.PrimaryKey()
+	}
+
 	providerType := ParseProviderType(channel.Type)
 
+	// 渠道配置的静态请求头格式错误时拒绝创建，避免请求缺少上游要求的请求头
+	headers, err := channel.GetHeaderOverride()
+	if err != nil {
+		return nil, err
+	}
+	settings := channel.GetSettings()
+
 	// 构建基本配置
 	config := &AdapterConfig{
-		Type:    channel.Type,
-		BaseURL: channel.BaseURL,
-		APIKey:  channel.APIKey,
-		Timeout: 30 * 1000000000, // 30s
+		Type:               channel.Type,
+		BaseURL:            channel.BaseURL,
+		APIKey:             key,
+		Timeout:            30 * 1000000000, // 30s
+		RequestIDHeader:    channel.GetRequestIDHeader(DefaultRequestIDHeader(providerType)),
+		ExtraBodyAllowlist: settings.ExtraBodyAllowlist,
+		Headers:            headers,
+		QueryParams:        settings.QueryParams,
+		Version:            settings.APIVersion,
+		Deployments:        settings.Deployments,
 	}
 
 	return CreateAdapterFactory(providerType, config)
 }
 
+// SupportsChannelType 渠道类型是否有对应的适配器实现
+func SupportsChannelType(channelType string) bool {
+	_, err := CreateAdapterFactory(ParseProviderType(channelType), &AdapterConfig{Type: channelType})
+	return err == nil
+}
+
 // CreateAdapterFactory 创建适配器实例
 func CreateAdapterFactory(providerType ProviderType, config *AdapterConfig) (Adapter, error) {
 	switch providerType {
@@ -34,9 +62,12 @@ func CreateAdapterFactory(providerType ProviderType, config *AdapterConfig) (Ada
 		return NewBaiduAdapter(config), nil
 	case ProviderQwen:
 		return NewQwenAdapter(config), nil
-	// Azure 暂时复用 OpenAI
 	case ProviderAzure:
-		return NewOpenAIAdapter(config), nil
+		return NewAzureOpenAIAdapter(config), nil
+	case ProviderOllama:
+		return NewOllamaAdapter(config), nil
+	case ProviderVLLM, ProviderLMStudio:
+		return NewSelfHostedAdapter(providerType, config), nil
 
 	// 对于尚未实现的适配器，暂时返回错误
 	default:
@@ -0,0 +1,271 @@
+package adapter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// 流式错误类型
+const (
+	StreamErrorOverloaded  = "overloaded"        // 上游过载
+	StreamErrorRateLimited = "rate_limited"      // 上游限流
+	StreamErrorUpstream    = "upstream_error"    // 上游在流中返回的其它错误
+	StreamErrorConnection  = "connection_error"  // 连接中断
+	StreamErrorIncomplete  = "incomplete_stream" // 流在结束标记之前关闭
+)
+
+// StreamError 流式响应中途的上游错误
+type StreamError struct {
+	Class   string // 错误类型，见 StreamError* 常量
+	Type    string // 上游原始错误类型，如 overloaded_error
+	Message string
+}
+
+// Error 实现 error 接口
+func (e *StreamError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("stream interrupted (%s, %s): %s", e.Class, e.Type, e.Message)
+	}
+	return fmt.Sprintf("stream interrupted (%s): %s", e.Class, e.Message)
+}
+
+// DeltaText 数据块中增量输出的文本，包括工具调用的函数名与参数片段（同样按输出 Token 计费）
+func (c *StreamChunk) DeltaText() string {
+	var sb strings.Builder
+	for _, choice := range c.Choices {
+		if choice.Delta == nil {
+			continue
+		}
+		if text, ok := choice.Delta.Content.(string); ok {
+			sb.WriteString(text)
+		}
+		for _, call := range choice.Delta.ToolCalls {
+			sb.WriteString(call.Function.Name)
+			sb.WriteString(call.Function.Arguments)
+		}
+	}
+	return sb.String()
+}
+
+// newInBandStreamError 根据上游在流中返回的错误构造 StreamError
+func newInBandStreamError(info *ErrorInfo) *StreamError {
+	class := StreamErrorUpstream
+	switch info.Type {
+	case "overloaded_error", "server_overloaded":
+		class = StreamErrorOverloaded
+	case "rate_limit_error", "rate_limit_exceeded", "requests", "tokens":
+		class = StreamErrorRateLimited
+	}
+	if info.Code == "rate_limit_exceeded" {
+		class = StreamErrorRateLimited
+	}
+	return &StreamError{Class: class, Type: info.Type, Message: info.Message}
+}
+
+// readSSE 逐个读取 SSE 事件，fn 返回 false 时停止读取
+//
+// 正常读到 EOF 时返回 nil，连接中断等读取错误原样返回。
+func readSSE(r io.Reader, fn func(event, data string) bool) error {
+	reader := bufio.NewReader(r)
+	var event string
+	var data []string
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// 不完整的最后一行说明流被截断，丢弃
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				if !fn(event, strings.Join(data, "\n")) {
+					return nil
+				}
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		}
+	}
+}
+
+// parseOpenAIStream 解析 OpenAI 格式的流式响应
+//
+// 流中的 {"error": {...}}、连接中断以及既没有 [DONE] 也没有 finish_reason 的提前结束，
+// 都会以带 Err 的数据块作为最后一个数据块发出。
+func parseOpenAIStream(body io.Reader, ch chan<- *StreamChunk) {
+	done := false
+	finished := false
+	var streamErr *StreamError
+
+	err := readSSE(body, func(event, data string) bool {
+		if data == "[DONE]" {
+			done = true
+			return false
+		}
+
+		var probe struct {
+			Error *ErrorInfo `json:"error"`
+		}
+		if json.Unmarshal([]byte(data), &probe) == nil && probe.Error != nil {
+			streamErr = newInBandStreamError(probe.Error)
+			return false
+		}
+
+		var chunk StreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return true
+		}
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != "" {
+				finished = true
+			}
+		}
+		ch <- &chunk
+		return true
+	})
+
+	switch {
+	case streamErr != nil:
+		ch <- &StreamChunk{Err: streamErr}
+	case err != nil:
+		ch <- &StreamChunk{Err: &StreamError{Class: StreamErrorConnection, Message: err.Error()}}
+	case !done && !finished:
+		ch <- &StreamChunk{Err: &StreamError{Class: StreamErrorIncomplete, Message: "upstream closed the stream before completion"}}
+	}
+}
+
+// claudeStopReasons Claude stop_reason 到 OpenAI finish_reason 的映射
+var claudeStopReasons = map[string]string{
+	"end_turn":      "stop",
+	"stop_sequence": "stop",
+	"max_tokens":    "length",
+	"tool_use":      "tool_calls",
+}
+
+// claudeStreamEvent Claude 流式事件（只解析需要的字段）
+type claudeStreamEvent struct {
+	Type    string `json:"type"`
+	Message *struct {
+		ID    string `json:"id"`
+		Model string `json:"model"`
+		Usage struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	ContentBlock *struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content_block"`
+	Delta *struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error *ErrorInfo `json:"error"`
+}
+
+// parseClaudeStream 解析 Claude Messages API 的流式响应并转换为 OpenAI 数据块
+//
+// message_start 转换为带 role 的首个数据块，文本增量转换为 delta.content，message_delta 携带
+// finish_reason 与完整用量（input_tokens 取自 message_start，message_delta 中给出时以其为准）。
+// Claude 在流中通过 error 事件（如 overloaded_error）报告错误，收到 message_stop 才算完整结束。
+func parseClaudeStream(body io.Reader, ch chan<- *StreamChunk) {
+	var id, model string
+	created := time.Now().Unix()
+	promptTokens := 0
+	done := false
+	var streamErr *StreamError
+
+	newChunk := func(choices ...Choice) *StreamChunk {
+		return &StreamChunk{ID: id, Object: "chat.completion.chunk", Created: created, Model: model, Choices: choices}
+	}
+
+	err := readSSE(body, func(event, data string) bool {
+		var ev claudeStreamEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return true
+		}
+		if ev.Type == "" {
+			ev.Type = event
+		}
+
+		switch ev.Type {
+		case "message_start":
+			if ev.Message != nil {
+				id, model = ev.Message.ID, ev.Message.Model
+				promptTokens = ev.Message.Usage.InputTokens
+			}
+			ch <- newChunk(Choice{Delta: &Message{Role: "assistant"}})
+
+		case "content_block_start":
+			if ev.ContentBlock != nil && ev.ContentBlock.Type == "text" && ev.ContentBlock.Text != "" {
+				ch <- newChunk(Choice{Delta: &Message{Content: ev.ContentBlock.Text}})
+			}
+
+		case "content_block_delta":
+			if ev.Delta != nil && ev.Delta.Type == "text_delta" {
+				ch <- newChunk(Choice{Delta: &Message{Content: ev.Delta.Text}})
+			}
+
+		case "message_delta":
+			chunk := newChunk()
+			if ev.Delta != nil && ev.Delta.StopReason != "" {
+				reason, ok := claudeStopReasons[ev.Delta.StopReason]
+				if !ok {
+					reason = ev.Delta.StopReason
+				}
+				chunk.Choices = []Choice{{Delta: &Message{}, FinishReason: reason}}
+			}
+			if ev.Usage != nil {
+				if ev.Usage.InputTokens > 0 {
+					promptTokens = ev.Usage.InputTokens
+				}
+				chunk.Usage = &Usage{
+					PromptTokens:     promptTokens,
+					CompletionTokens: ev.Usage.OutputTokens,
+					TotalTokens:      promptTokens + ev.Usage.OutputTokens,
+				}
+			}
+			ch <- chunk
+
+		case "message_stop":
+			done = true
+			return false
+
+		case "error":
+			info := ev.Error
+			if info == nil {
+				info = &ErrorInfo{Message: data}
+			}
+			streamErr = newInBandStreamError(info)
+			return false
+		}
+		return true
+	})
+
+	switch {
+	case streamErr != nil:
+		ch <- &StreamChunk{Err: streamErr}
+	case err != nil:
+		ch <- &StreamChunk{Err: &StreamError{Class: StreamErrorConnection, Message: err.Error()}}
+	case !done:
+		ch <- &StreamChunk{Err: &StreamError{Class: StreamErrorIncomplete, Message: "upstream closed the stream before message_stop"}}
+	}
+}
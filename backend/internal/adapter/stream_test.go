@@ -0,0 +1,347 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cutReader 模拟上游在输出 data 之后以 err 断开
+type cutReader struct {
+	data io.Reader
+	err  error
+}
+
+func (r *cutReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		if r.err == nil {
+			return n, io.EOF
+		}
+		return n, r.err
+	}
+	return n, err
+}
+
+// streamResult 收集流式数据块
+type streamResult struct {
+	text   string
+	finish string
+	usage  *Usage
+	err    *StreamError
+	chunks int
+}
+
+func collectStream(t *testing.T, ch <-chan *StreamChunk) streamResult {
+	t.Helper()
+	var res streamResult
+	for chunk := range ch {
+		if res.err != nil {
+			t.Fatalf("received chunk after terminal error chunk")
+		}
+		if chunk.Err != nil {
+			res.err = chunk.Err
+			continue
+		}
+		res.chunks++
+		res.text += chunk.DeltaText()
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != "" {
+				res.finish = choice.FinishReason
+			}
+		}
+		if chunk.Usage != nil {
+			res.usage = chunk.Usage
+		}
+	}
+	return res
+}
+
+func parseStream(t *testing.T, a Adapter, body io.Reader) streamResult {
+	t.Helper()
+	ch, err := a.ParseStreamResponse(&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(body)})
+	if err != nil {
+		t.Fatalf("ParseStreamResponse failed: %v", err)
+	}
+	return collectStream(t, ch)
+}
+
+const openAIStreamHead = "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"\"}}]}\n\n" +
+	"data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"}}]}\n\n" +
+	"data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\" world\"}}]}\n\n"
+
+const openAIStreamTail = "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+	"data: [DONE]\n\n"
+
+func TestOpenAIStreamPartialFailures(t *testing.T) {
+	a := NewOpenAIAdapter(&AdapterConfig{Type: "openai", BaseURL: "http://upstream"})
+
+	tests := []struct {
+		name      string
+		body      io.Reader
+		wantText  string
+		wantClass string // 为空表示完整结束
+	}{
+		{
+			name:     "complete",
+			body:     strings.NewReader(openAIStreamHead + openAIStreamTail),
+			wantText: "Hello world",
+		},
+		{
+			name:     "finish_reason without DONE",
+			body:     strings.NewReader(openAIStreamHead + "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n"),
+			wantText: "Hello world",
+		},
+		{
+			name:      "closed after first delta",
+			body:      strings.NewReader(openAIStreamHead[:strings.Index(openAIStreamHead, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\" world")]),
+			wantText:  "Hello",
+			wantClass: StreamErrorIncomplete,
+		},
+		{
+			name:      "closed mid-line",
+			body:      strings.NewReader(openAIStreamHead + "data: {\"id\":\"c1\",\"choi"),
+			wantText:  "Hello world",
+			wantClass: StreamErrorIncomplete,
+		},
+		{
+			name:      "connection reset",
+			body:      &cutReader{data: strings.NewReader(openAIStreamHead), err: errors.New("read: connection reset by peer")},
+			wantText:  "Hello world",
+			wantClass: StreamErrorConnection,
+		},
+		{
+			name:      "connection reset before any content",
+			body:      &cutReader{data: strings.NewReader(""), err: io.ErrUnexpectedEOF},
+			wantClass: StreamErrorConnection,
+		},
+		{
+			name:      "in-band error",
+			body:      strings.NewReader(openAIStreamHead + "data: {\"error\":{\"message\":\"server error\",\"type\":\"server_error\"}}\n\n"),
+			wantText:  "Hello world",
+			wantClass: StreamErrorUpstream,
+		},
+		{
+			name:      "in-band rate limit",
+			body:      strings.NewReader(openAIStreamHead + "data: {\"error\":{\"message\":\"slow down\",\"type\":\"requests\",\"code\":\"rate_limit_exceeded\"}}\n\n" + openAIStreamTail),
+			wantText:  "Hello world",
+			wantClass: StreamErrorRateLimited,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := parseStream(t, a, tt.body)
+			if res.text != tt.wantText {
+				t.Errorf("expected delivered text %q, got %q", tt.wantText, res.text)
+			}
+			if tt.wantClass == "" {
+				if res.err != nil {
+					t.Errorf("expected complete stream, got %v", res.err)
+				}
+				if res.finish != "stop" {
+					t.Errorf("expected finish_reason stop, got %q", res.finish)
+				}
+				return
+			}
+			if res.err == nil || res.err.Class != tt.wantClass {
+				t.Errorf("expected error class %s, got %v", tt.wantClass, res.err)
+			}
+		})
+	}
+}
+
+const claudeStreamHead = "event: message_start\n" +
+	"data: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"model\":\"claude-3-opus\",\"usage\":{\"input_tokens\":25,\"output_tokens\":1}}}\n\n" +
+	"event: content_block_start\n" +
+	"data: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+	"event: ping\n" +
+	"data: {\"type\":\"ping\"}\n\n" +
+	"event: content_block_delta\n" +
+	"data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n"
+
+const claudeStreamMid = "event: content_block_delta\n" +
+	"data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"!\"}}\n\n"
+
+const claudeStreamTail = "event: content_block_stop\n" +
+	"data: {\"type\":\"content_block_stop\",\"index\":0}\n\n" +
+	"event: message_delta\n" +
+	"data: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":15}}\n\n" +
+	"event: message_stop\n" +
+	"data: {\"type\":\"message_stop\"}\n\n"
+
+func TestClaudeStreamPartialFailures(t *testing.T) {
+	a := NewClaudeAdapter(&AdapterConfig{Type: "claude", BaseURL: "http://upstream"})
+
+	tests := []struct {
+		name      string
+		body      io.Reader
+		wantText  string
+		wantClass string
+		wantType  string
+	}{
+		{
+			name:     "complete",
+			body:     strings.NewReader(claudeStreamHead + claudeStreamMid + claudeStreamTail),
+			wantText: "Hello!",
+		},
+		{
+			name:      "overloaded_error event mid-stream",
+			body:      strings.NewReader(claudeStreamHead + "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"),
+			wantText:  "Hello",
+			wantClass: StreamErrorOverloaded,
+			wantType:  "overloaded_error",
+		},
+		{
+			name:      "api_error event before content",
+			body:      strings.NewReader("event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"api_error\",\"message\":\"Internal\"}}\n\n"),
+			wantClass: StreamErrorUpstream,
+			wantType:  "api_error",
+		},
+		{
+			name:      "closed before message_stop",
+			body:      strings.NewReader(claudeStreamHead + claudeStreamMid + "event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n"),
+			wantText:  "Hello!",
+			wantClass: StreamErrorIncomplete,
+		},
+		{
+			name:      "connection reset mid-event",
+			body:      &cutReader{data: strings.NewReader(claudeStreamHead + "event: content_block_delta\ndata: {\"type\":\"content_blo"), err: errors.New("read: connection reset by peer")},
+			wantText:  "Hello",
+			wantClass: StreamErrorConnection,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := parseStream(t, a, tt.body)
+			if res.text != tt.wantText {
+				t.Errorf("expected delivered text %q, got %q", tt.wantText, res.text)
+			}
+			if tt.wantClass == "" {
+				if res.err != nil {
+					t.Fatalf("expected complete stream, got %v", res.err)
+				}
+				if res.finish != "stop" {
+					t.Errorf("expected finish_reason stop, got %q", res.finish)
+				}
+				if res.usage == nil || res.usage.PromptTokens != 25 || res.usage.CompletionTokens != 15 {
+					t.Errorf("unexpected usage %+v", res.usage)
+				}
+				return
+			}
+			if res.err == nil || res.err.Class != tt.wantClass {
+				t.Fatalf("expected error class %s, got %v", tt.wantClass, res.err)
+			}
+			if res.err.Type != tt.wantType {
+				t.Errorf("expected upstream type %q, got %q", tt.wantType, res.err.Type)
+			}
+		})
+	}
+}
+
+func TestClaudeStreamChunkSequence(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(claudeStreamHead + claudeStreamMid))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(strings.Replace(claudeStreamTail, `"usage":{"output_tokens":15}`, `"usage":{"input_tokens":27,"output_tokens":15}`, 1)))
+	}))
+	defer srv.Close()
+
+	a := NewClaudeAdapter(&AdapterConfig{Type: "claude", BaseURL: srv.URL, Timeout: 5 * time.Second})
+	req, err := a.ConvertRequest(&OpenAIRequest{Model: "claude-3-opus", Messages: []Message{{Role: "user", Content: "hi"}}, Stream: true})
+	if err != nil {
+		t.Fatalf("ConvertRequest failed: %v", err)
+	}
+	resp, err := a.DoRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("DoRequest failed: %v", err)
+	}
+	ch, err := a.ParseStreamResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseStreamResponse failed: %v", err)
+	}
+
+	var chunks []*StreamChunk
+	for chunk := range ch {
+		if chunk.Err != nil {
+			t.Fatalf("unexpected stream error %v", chunk.Err)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	// role、两段文本增量、finish_reason 与用量
+	if len(chunks) != 4 {
+		t.Fatalf("expected 4 chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.ID != "msg_1" || chunk.Model != "claude-3-opus" || chunk.Object != "chat.completion.chunk" || chunk.Created != chunks[0].Created {
+			t.Errorf("chunk %d: unexpected envelope %+v", i, chunk)
+		}
+	}
+	if chunks[0].Choices[0].Delta.Role != "assistant" {
+		t.Errorf("expected role chunk first, got %+v", chunks[0].Choices[0].Delta)
+	}
+	if chunks[1].DeltaText() != "Hello" || chunks[2].DeltaText() != "!" {
+		t.Errorf("unexpected text deltas %q %q", chunks[1].DeltaText(), chunks[2].DeltaText())
+	}
+	last := chunks[3]
+	if len(last.Choices) != 1 || last.Choices[0].FinishReason != "stop" {
+		t.Errorf("expected finish_reason stop on the last chunk, got %+v", last.Choices)
+	}
+
+	// 流式请求同样可以通过 ExtractUsage 取得用量，message_delta 中的 input_tokens 优先
+	usage, err := a.ExtractUsage(last)
+	if err != nil {
+		t.Fatalf("ExtractUsage failed: %v", err)
+	}
+	if usage.PromptTokens != 27 || usage.CompletionTokens != 15 || usage.TotalTokens != 42 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+	if _, err := a.ExtractUsage(chunks[1]); err == nil {
+		t.Error("expected an error for a chunk without usage")
+	}
+}
+
+func TestStreamUpstreamDropsConnection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(openAIStreamHead))
+		w.(http.Flusher).Flush()
+
+		// 不发送结束标记，直接断开连接
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	a := NewOpenAIAdapter(&AdapterConfig{Type: "openai", BaseURL: srv.URL, Timeout: 5 * time.Second})
+	ch, err := a.ParseStreamResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseStreamResponse failed: %v", err)
+	}
+
+	res := collectStream(t, ch)
+	if res.text != "Hello world" {
+		t.Errorf("expected delivered text %q, got %q", "Hello world", res.text)
+	}
+	if res.err == nil {
+		t.Fatal("expected terminal error chunk after dropped connection")
+	}
+	if res.err.Class != StreamErrorConnection && res.err.Class != StreamErrorIncomplete {
+		t.Errorf("unexpected error class %s", res.err.Class)
+	}
+}
@@ -0,0 +1,146 @@
+package adapter
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// KeyPlaceholder 渠道请求头的值中引用渠道密钥的占位符，如 "Bearer {api_key}"
+const KeyPlaceholder = "{api_key}"
+
+// reservedHeaders 由 HTTP 客户端维护、不允许通过渠道配置覆盖的请求头
+var reservedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+}
+
+// secretHeaderWords 请求头名称（按 - 或 _ 分词）包含这些词时，值按密钥处理
+var secretHeaderWords = map[string]bool{
+	"auth": true, "authorization": true, "key": true, "apikey": true, "token": true, "secret": true,
+	"signature": true, "password": true, "cookie": true, "credential": true, "credentials": true,
+}
+
+// ValidateUpstreamOverrides 校验渠道配置的静态请求头与查询参数
+func ValidateUpstreamOverrides(headers, queryParams map[string]string) error {
+	for name, value := range headers {
+		if !validHeaderName(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if reservedHeaders[http.CanonicalHeaderKey(name)] {
+			return fmt.Errorf("header %s cannot be overridden", http.CanonicalHeaderKey(name))
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("header %s value must not contain line breaks", name)
+		}
+	}
+	for key := range queryParams {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("query parameter name must not be empty")
+		}
+	}
+	return nil
+}
+
+// validHeaderName 请求头名称只能由 RFC 7230 token 字符组成
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r > 0x7e || r <= 0x20 || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyUpstreamOverrides 追加渠道配置的查询参数并注入静态请求头
+func (ba *BaseAdapter) applyUpstreamOverrides(req *http.Request) {
+	if len(ba.config.QueryParams) > 0 {
+		query := req.URL.Query()
+		for key, value := range ba.config.QueryParams {
+			query.Set(key, value)
+		}
+		req.URL.RawQuery = query.Encode()
+	}
+
+	for name, value := range ba.config.Headers {
+		// 保存时已校验，这里再次跳过以防旧数据覆盖 Host/Content-Length
+		if reservedHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		req.Header.Set(name, strings.ReplaceAll(value, KeyPlaceholder, ba.config.APIKey))
+	}
+}
+
+// MaskHeaders 返回请求头配置的展示副本，疑似密钥的值被掩码，用于日志与管理接口
+func MaskHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(headers))
+	for name, value := range headers {
+		masked[name] = MaskHeaderValue(name, value)
+	}
+	return masked
+}
+
+// MaskHeaderValue 按请求头名称与值的形态判断是否为密钥，是则只保留首尾各 4 个字符
+//
+// 引用占位符的值（如 "Bearer {api_key}"）只在其余部分本身形如密钥时掩码。
+func MaskHeaderValue(name, value string) string {
+	if value == "" {
+		return value
+	}
+	if strings.Contains(value, KeyPlaceholder) {
+		if !looksLikeKey(strings.TrimSpace(strings.ReplaceAll(value, KeyPlaceholder, ""))) {
+			return value
+		}
+		return maskSecret(value)
+	}
+	if secretHeaderName(name) || looksLikeKey(value) {
+		return maskSecret(value)
+	}
+	return value
+}
+
+// secretHeaderName 请求头名称提示其值为凭证
+func secretHeaderName(name string) bool {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool { return r == '-' || r == '_' })
+	for _, word := range words {
+		if secretHeaderWords[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikeKey 值形如 API Key：已知密钥前缀，或较长且同时包含字母与数字、没有空格
+func looksLikeKey(value string) bool {
+	if strings.HasPrefix(value, "sk-") || strings.HasPrefix(strings.ToLower(value), "bearer ") {
+		return true
+	}
+	if len(value) < 20 || strings.ContainsRune(value, ' ') {
+		return false
+	}
+	var letters, digits bool
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			digits = true
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			letters = true
+		}
+	}
+	return letters && digits
+}
+
+// maskSecret 保留首尾各 4 个字符，过短的值整体掩码
+func maskSecret(value string) string {
+	if len(value) <= 12 {
+		return "****"
+	}
+	return value[:4] + "****" + value[len(value)-4:]
+}
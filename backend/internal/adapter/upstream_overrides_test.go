@@ -0,0 +1,207 @@
+package adapter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// overrideConfig 带静态请求头与查询参数的适配器配置
+func overrideConfig(baseURL string) *AdapterConfig {
+	return &AdapterConfig{
+		Type:    "openai",
+		BaseURL: baseURL,
+		APIKey:  "sk-channel-key",
+		Headers: map[string]string{
+			"X-Portkey-Provider": "openai",
+			"X-Tenant-Auth":      "tenant {api_key}",
+			"Host":               "evil.example.com", // 旧数据中的保留请求头不会生效
+		},
+		QueryParams: map[string]string{"api-version": "2024-06-01"},
+	}
+}
+
+// assertOverrides 断言上游收到了注入的请求头与查询参数
+func assertOverrides(t *testing.T, r *http.Request, serverHost string) {
+	t.Helper()
+	if got := r.Header.Get("X-Portkey-Provider"); got != "openai" {
+		t.Errorf("Expected X-Portkey-Provider openai, got %q", got)
+	}
+	if got := r.Header.Get("X-Tenant-Auth"); got != "tenant sk-channel-key" {
+		t.Errorf("Expected key placeholder to be replaced, got %q", got)
+	}
+	if got := r.Header.Get("Authorization"); got != "Bearer sk-channel-key" {
+		t.Errorf("Expected channel auth header, got %q", got)
+	}
+	if got := r.URL.Query().Get("api-version"); got != "2024-06-01" {
+		t.Errorf("Expected api-version query param, got %q", got)
+	}
+	if r.Host != serverHost {
+		t.Errorf("Host must not be overridden, got %q", r.Host)
+	}
+}
+
+func TestUpstreamOverridesJSONRequest(t *testing.T) {
+	var called bool
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		assertOverrides(t, r, strings.TrimPrefix(server.URL, "http://"))
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("tenant"); got != "acme" {
+			t.Errorf("Existing query params must be kept, got %q", got)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Expected JSON content type, got %q", got)
+		}
+		w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer server.Close()
+
+	cfg := overrideConfig(server.URL + "/v1")
+	a := NewOpenAIAdapter(cfg)
+	resp, err := a.DoHTTPRequest(context.Background(), "POST", "/chat/completions?tenant=acme", &OpenAIRequest{Model: "gpt-4"})
+	if err != nil {
+		t.Fatalf("DoHTTPRequest failed: %v", err)
+	}
+	resp.Body.Close()
+	if !called {
+		t.Fatal("Expected upstream to be called")
+	}
+}
+
+func TestUpstreamOverridesMultipartRequest(t *testing.T) {
+	var called bool
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		assertOverrides(t, r, strings.TrimPrefix(server.URL, "http://"))
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data; boundary=") {
+			t.Errorf("Expected multipart content type, got %q", r.Header.Get("Content-Type"))
+		}
+		if got := r.FormValue("model"); got != "whisper-1" {
+			t.Errorf("Expected model field, got %q", got)
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("Expected file field: %v", err)
+		}
+		defer file.Close()
+		data, _ := io.ReadAll(file)
+		if header.Filename != "audio.mp3" || string(data) != "ID3" {
+			t.Errorf("Unexpected file %s: %q", header.Filename, data)
+		}
+		w.Write([]byte(`{"text":"hello"}`))
+	}))
+	defer server.Close()
+
+	a := NewOpenAIAdapter(overrideConfig(server.URL))
+	resp, err := a.DoMultipartRequest(context.Background(), "/audio/transcriptions",
+		map[string]string{"model": "whisper-1"},
+		[]MultipartFile{{Field: "file", Filename: "audio.mp3", Content: strings.NewReader("ID3")}})
+	if err != nil {
+		t.Fatalf("DoMultipartRequest failed: %v", err)
+	}
+	resp.Body.Close()
+	if !called {
+		t.Fatal("Expected upstream to be called")
+	}
+}
+
+func TestGetAdapterByChannelOverrides(t *testing.T) {
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer server.Close()
+
+	headers := `{"X-Vendor-Tenant":"tenant-42"}`
+	channel := &model.Channel{
+		Type:           "openai",
+		BaseURL:        server.URL,
+		APIKey:         "sk-channel-key",
+		HeaderOverride: &headers,
+		OtherSettings:  `{"query_params":{"api-version":"2024-06-01"}}`,
+	}
+	a, err := GetAdapterByChannel(channel)
+	if err != nil {
+		t.Fatalf("GetAdapterByChannel failed: %v", err)
+	}
+	resp, err := a.DoRequest(context.Background(), &OpenAIRequest{Model: "gpt-4"})
+	if err != nil {
+		t.Fatalf("DoRequest failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if got := received.Header.Get("X-Vendor-Tenant"); got != "tenant-42" {
+		t.Errorf("Expected channel header, got %q", got)
+	}
+	if got := received.URL.Query().Get("api-version"); got != "2024-06-01" {
+		t.Errorf("Expected channel query param, got %q", got)
+	}
+
+	invalid := `{"X-Vendor-Tenant":`
+	channel.HeaderOverride = &invalid
+	if _, err := GetAdapterByChannel(channel); err == nil {
+		t.Error("Expected malformed header_override to be rejected")
+	}
+}
+
+func TestValidateUpstreamOverrides(t *testing.T) {
+	valid := map[string]string{"X-Portkey-Provider": "openai", "api-key": "{api_key}"}
+	if err := ValidateUpstreamOverrides(valid, map[string]string{"api-version": "2024-06-01"}); err != nil {
+		t.Errorf("Expected valid overrides, got %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		query   map[string]string
+	}{
+		{"host", map[string]string{"host": "example.com"}, nil},
+		{"content-length", map[string]string{"Content-Length": "0"}, nil},
+		{"transfer-encoding", map[string]string{"Transfer-Encoding": "chunked"}, nil},
+		{"invalid name", map[string]string{"X Tenant": "a"}, nil},
+		{"empty name", map[string]string{"": "a"}, nil},
+		{"header injection", map[string]string{"X-Tenant": "a\r\nX-Admin: 1"}, nil},
+		{"empty query key", nil, map[string]string{" ": "1"}},
+	}
+	for _, tc := range cases {
+		if err := ValidateUpstreamOverrides(tc.headers, tc.query); err == nil {
+			t.Errorf("%s: expected validation error", tc.name)
+		}
+	}
+}
+
+func TestMaskHeaderValue(t *testing.T) {
+	cases := []struct {
+		name, value, want string
+	}{
+		{"X-Portkey-Provider", "openai", "openai"},
+		{"Authorization", "Bearer {api_key}", "Bearer {api_key}"},
+		{"X-Api-Key", "short", "****"},
+		{"X-Api-Key", "tenant-secret-value", "tena****alue"},
+		{"X-Custom", "sk-live-0123456789abcdef", "sk-l****cdef"},
+		{"X-Custom", "a1b2c3d4e5f6g7h8i9j0k1", "a1b2****j0k1"},
+		{"X-Region", "us-east-1", "us-east-1"},
+		{"X-Signed", "{api_key}:0123456789abcdefghij", "{api****ghij"},
+	}
+	for _, tc := range cases {
+		if got := MaskHeaderValue(tc.name, tc.value); got != tc.want {
+			t.Errorf("MaskHeaderValue(%q, %q) = %q, want %q", tc.name, tc.value, got, tc.want)
+		}
+	}
+
+	masked := MaskHeaders(map[string]string{"X-Api-Key": "tenant-secret-value"})
+	if masked["X-Api-Key"] != "tena****alue" {
+		t.Errorf("Expected masked header, got %v", masked)
+	}
+}
@@ -165,11 +165,18 @@ func (ul *UsageLogger) Close() error {
 
 // Query 查询使用记录
 func (ul *UsageLogger) Query(filter *UsageFilter) ([]*UsageRecord, error) {
+	// 先刷新缓冲区，保证查询能看到最新记录
+	if err := ul.Flush(); err != nil {
+		return nil, err
+	}
 	return ul.store.Query(filter)
 }
 
 // GetAggregatedStats 获取聚合统计
 func (ul *UsageLogger) GetAggregatedStats(filter *AggregationFilter) (*AggregatedStats, error) {
+	if err := ul.Flush(); err != nil {
+		return nil, err
+	}
 	return ul.store.GetAggregated(filter)
 }
 
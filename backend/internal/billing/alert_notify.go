@@ -0,0 +1,259 @@
+package billing
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// 通知渠道
+const (
+	AlertChannelWebhook = "webhook"
+	AlertChannelEmail   = "email"
+)
+
+// 通知投递状态
+const (
+	AlertDeliverySent   = "sent"   // 已送达
+	AlertDeliveryFailed = "failed" // 重试耗尽仍失败
+)
+
+const (
+	// DefaultAlertCooldown 同一用户同一等级的预警在该时间内只发送一次
+	DefaultAlertCooldown = time.Hour
+
+	// alertNotifyAttempts 单个渠道的最大投递次数
+	alertNotifyAttempts = 3
+	// alertNotifyTimeout 单次投递的超时时间
+	alertNotifyTimeout = 10 * time.Second
+)
+
+// DefaultAlertThresholds 默认预警阈值：70% 警告、90% 严重、100% 耗尽
+func DefaultAlertThresholds() map[AlertLevel]float64 {
+	return map[AlertLevel]float64{
+		AlertLevelWarning:   70,
+		AlertLevelCritical:  90,
+		AlertLevelExhausted: 100,
+	}
+}
+
+// AlertPreference 用户在某个渠道上的预警通知偏好
+type AlertPreference struct {
+	UserID   string     `json:"user_id"`
+	Channel  string     `json:"channel"`   // webhook 或 email
+	Target   string     `json:"target"`    // Webhook 地址或邮箱
+	Secret   string     `json:"-"`         // Webhook 签名密钥
+	MinLevel AlertLevel `json:"min_level"` // 低于该等级的预警不通知
+	Enabled  bool       `json:"enabled"`
+}
+
+// AlertDelivery 一次预警通知在某个渠道上的投递结果
+type AlertDelivery struct {
+	AlertID     string     `json:"alert_id"`
+	UserID      string     `json:"user_id"`
+	Channel     string     `json:"channel"`
+	Target      string     `json:"target"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	Error       string     `json:"error,omitempty"` // 最后一次失败的原因
+	CreatedAt   time.Time  `json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+// AlertStore 预警通知偏好与投递记录的持久化存储
+type AlertStore interface {
+	// GetAlertPreferences 返回用户的全部通知偏好，没有时返回空列表
+	GetAlertPreferences(ctx context.Context, userID string) ([]*AlertPreference, error)
+	// RecordAlert 保存触发的预警
+	RecordAlert(ctx context.Context, alert *QuotaAlert) error
+	// RecordDelivery 保存一次投递结果
+	RecordDelivery(ctx context.Context, delivery *AlertDelivery) error
+}
+
+// AlertNotifier 预警通知渠道
+type AlertNotifier interface {
+	// Channel 渠道名称，与 AlertPreference.Channel 对应
+	Channel() string
+	// Notify 将预警发送到偏好中配置的目标
+	Notify(ctx context.Context, alert *QuotaAlert, pref *AlertPreference) error
+}
+
+// alertPayload Webhook 与邮件模板使用的预警内容
+type alertPayload struct {
+	Event          string    `json:"event"`
+	AlertID        string    `json:"alert_id"`
+	UserID         string    `json:"user_id"`
+	Level          string    `json:"level"`
+	UsageRate      float64   `json:"usage_rate"`
+	RemainingQuota float64   `json:"remaining_quota"`
+	UsedQuota      float64   `json:"used_quota"`
+	TotalQuota     float64   `json:"total_quota"`
+	Message        string    `json:"message"`
+	TriggeredAt    time.Time `json:"triggered_at"`
+}
+
+func newAlertPayload(alert *QuotaAlert) *alertPayload {
+	return &alertPayload{
+		Event:          "quota_alert",
+		AlertID:        alert.AlertID,
+		UserID:         alert.UserID,
+		Level:          alert.Level.String(),
+		UsageRate:      alert.UsageRate,
+		RemainingQuota: alert.RemainingQuota,
+		UsedQuota:      alert.UsedQuota,
+		TotalQuota:     alert.TotalQuota,
+		Message:        alert.Message,
+		TriggeredAt:    alert.TriggeredAt,
+	}
+}
+
+// WebhookNotifier 以 JSON POST 的方式发送预警
+//
+// 偏好设置了密钥时附带签名：X-Alert-Signature 为 HMAC-SHA256(secret, timestamp + "." + body) 的十六进制，
+// timestamp 为 X-Alert-Timestamp 中的 Unix 秒。
+type WebhookNotifier struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewWebhookNotifier 创建 Webhook 通知渠道
+func NewWebhookNotifier() *WebhookNotifier {
+	return &WebhookNotifier{
+		client: &http.Client{Timeout: alertNotifyTimeout},
+		now:    time.Now,
+	}
+}
+
+// Channel 渠道名称
+func (n *WebhookNotifier) Channel() string {
+	return AlertChannelWebhook
+}
+
+// Notify 发送预警，非 2xx 响应视为失败
+func (n *WebhookNotifier) Notify(ctx context.Context, alert *QuotaAlert, pref *AlertPreference) error {
+	body, err := json.Marshal(newAlertPayload(alert))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pref.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if pref.Secret != "" {
+		timestamp := strconv.FormatInt(n.now().Unix(), 10)
+		req.Header.Set("X-Alert-Timestamp", timestamp)
+		req.Header.Set("X-Alert-Signature", SignAlertWebhook(pref.Secret, timestamp, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignAlertWebhook 计算预警 Webhook 的签名，接收方用相同方式校验
+func SignAlertWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// MailSender 发送邮件，msg 为包含邮件头的完整内容
+type MailSender interface {
+	SendMail(from string, to []string, msg []byte) error
+}
+
+// SMTPSender 通过 SMTP 服务器发送邮件，Username 为空时不认证
+type SMTPSender struct {
+	Addr     string // host:port
+	Username string
+	Password string
+}
+
+// SendMail 发送邮件
+func (s *SMTPSender) SendMail(from string, to []string, msg []byte) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := strings.Cut(s.Addr, ":")
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	return smtp.SendMail(s.Addr, auth, from, to, msg)
+}
+
+// defaultAlertEmailTemplate 预警邮件模板，第一段为邮件头
+const defaultAlertEmailTemplate = `Subject: [Quota {{.Level}}] Quota usage reached {{printf "%.2f" .UsageRate}}%
+Content-Type: text/plain; charset=UTF-8
+
+Your quota usage has reached {{printf "%.2f" .UsageRate}}% (level: {{.Level}}).
+
+Used:      {{printf "%.2f" .UsedQuota}}
+Remaining: {{printf "%.2f" .RemainingQuota}}
+Total:     {{printf "%.2f" .TotalQuota}}
+
+Triggered at {{.TriggeredAt.UTC.Format "2006-01-02 15:04:05"}} UTC.
+`
+
+// EmailNotifier 按模板渲染邮件并发送预警
+type EmailNotifier struct {
+	sender MailSender
+	from   string
+	tmpl   *template.Template
+}
+
+// NewEmailNotifier 创建邮件通知渠道，tmpl 为空时使用默认模板
+func NewEmailNotifier(sender MailSender, from, tmpl string) (*EmailNotifier, error) {
+	if tmpl == "" {
+		tmpl = defaultAlertEmailTemplate
+	}
+	parsed, err := template.New("quota_alert").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("parse alert email template: %w", err)
+	}
+	return &EmailNotifier{sender: sender, from: from, tmpl: parsed}, nil
+}
+
+// Channel 渠道名称
+func (n *EmailNotifier) Channel() string {
+	return AlertChannelEmail
+}
+
+// Notify 渲染并发送预警邮件
+func (n *EmailNotifier) Notify(ctx context.Context, alert *QuotaAlert, pref *AlertPreference) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\nTo: %s\r\n", n.from, pref.Target)
+	if err := n.tmpl.Execute(&buf, newAlertPayload(alert)); err != nil {
+		return fmt.Errorf("render alert email: %w", err)
+	}
+
+	// net/smtp 不支持 context，超时前返回避免阻塞调用方
+	done := make(chan error, 1)
+	go func() {
+		done <- n.sender.SendMail(n.from, []string{pref.Target}, buf.Bytes())
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,215 @@
+package billing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryAlertStore 内存中的预警存储
+type memoryAlertStore struct {
+	mu         sync.Mutex
+	prefs      map[string][]*AlertPreference
+	alerts     []*QuotaAlert
+	deliveries []*AlertDelivery
+}
+
+func newMemoryAlertStore() *memoryAlertStore {
+	return &memoryAlertStore{prefs: make(map[string][]*AlertPreference)}
+}
+
+func (s *memoryAlertStore) GetAlertPreferences(ctx context.Context, userID string) ([]*AlertPreference, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prefs[userID], nil
+}
+
+func (s *memoryAlertStore) RecordAlert(ctx context.Context, alert *QuotaAlert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alerts = append(s.alerts, alert)
+	return nil
+}
+
+func (s *memoryAlertStore) RecordDelivery(ctx context.Context, delivery *AlertDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries = append(s.deliveries, delivery)
+	return nil
+}
+
+// mockMailSender 记录发送的邮件，fail 为 true 时返回错误
+type mockMailSender struct {
+	mu       sync.Mutex
+	fail     bool
+	messages []string
+	to       []string
+}
+
+func (m *mockMailSender) SendMail(from string, to []string, msg []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail {
+		return errors.New("421 service not available")
+	}
+	m.messages = append(m.messages, string(msg))
+	m.to = append(m.to, to...)
+	return nil
+}
+
+func newNotifyingAlertManager(t *testing.T, store AlertStore, used float64) (*AlertManager, *fakeClock) {
+	t.Helper()
+	quotaManager := NewQuotaManager()
+	quotaManager.CreateUserQuota("user-1", 100.0)
+	quotaManager.AddUsage("user-1", used)
+
+	clock := &fakeClock{t: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)}
+	am := NewAlertManager(quotaManager)
+	am.now = clock.Now
+	am.notifyBackoff = time.Millisecond
+	am.logFunc = func(level, msg string, args ...interface{}) {}
+	am.SetAlertStore(store)
+	if err := am.SetDefaultThresholds(DefaultAlertThresholds()); err != nil {
+		t.Fatalf("SetDefaultThresholds failed: %v", err)
+	}
+	return am, clock
+}
+
+func TestWebhookNotifierSignsAndRetries(t *testing.T) {
+	var calls int32
+	var received alertPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get("X-Alert-Timestamp")
+		if r.Header.Get("X-Alert-Signature") != SignAlertWebhook("s3cret", timestamp, body) {
+			t.Errorf("Invalid webhook signature")
+		}
+		// 第一次返回 500，验证重试
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	store := newMemoryAlertStore()
+	store.prefs["user-1"] = []*AlertPreference{
+		{UserID: "user-1", Channel: AlertChannelWebhook, Target: server.URL, Secret: "s3cret", MinLevel: AlertLevelWarning, Enabled: true},
+	}
+	am, _ := newNotifyingAlertManager(t, store, 75)
+	am.RegisterNotifier(AlertLevelWarning, NewWebhookNotifier())
+
+	if err := am.CheckQuotaUsage("user-1"); err != nil {
+		t.Fatalf("CheckQuotaUsage failed: %v", err)
+	}
+	am.WaitNotifications()
+
+	if len(store.alerts) != 1 || len(store.deliveries) != 1 {
+		t.Fatalf("Expected 1 alert and 1 delivery, got %d and %d", len(store.alerts), len(store.deliveries))
+	}
+	delivery := store.deliveries[0]
+	if delivery.Status != AlertDeliverySent || delivery.Attempts != 2 || delivery.DeliveredAt == nil {
+		t.Errorf("Unexpected delivery: %+v", delivery)
+	}
+	if received.Level != "warning" || received.UserID != "user-1" || received.UsageRate != 75 {
+		t.Errorf("Unexpected webhook payload: %+v", received)
+	}
+}
+
+func TestEmailNotifierDeliveryLog(t *testing.T) {
+	sender := &mockMailSender{fail: true}
+	email, err := NewEmailNotifier(sender, "alerts@example.com", "")
+	if err != nil {
+		t.Fatalf("NewEmailNotifier failed: %v", err)
+	}
+
+	store := newMemoryAlertStore()
+	store.prefs["user-1"] = []*AlertPreference{
+		{UserID: "user-1", Channel: AlertChannelEmail, Target: "user@example.com", MinLevel: AlertLevelWarning, Enabled: true},
+		// 只关心耗尽预警的 Webhook 不应收到通知
+		{UserID: "user-1", Channel: AlertChannelWebhook, Target: "http://127.0.0.1:1", MinLevel: AlertLevelExhausted, Enabled: true},
+	}
+	am, clock := newNotifyingAlertManager(t, store, 95)
+	for _, level := range []AlertLevel{AlertLevelWarning, AlertLevelCritical} {
+		am.RegisterNotifier(level, email)
+		am.RegisterNotifier(level, NewWebhookNotifier())
+	}
+
+	// 发送全部失败：记录失败的投递
+	am.CheckQuotaUsage("user-1")
+	am.WaitNotifications()
+	if len(store.deliveries) != 2 {
+		t.Fatalf("Expected warning and critical deliveries, got %d", len(store.deliveries))
+	}
+	for _, d := range store.deliveries {
+		if d.Channel != AlertChannelEmail || d.Status != AlertDeliveryFailed || d.Attempts != alertNotifyAttempts || d.Error == "" {
+			t.Errorf("Unexpected failed delivery: %+v", d)
+		}
+	}
+
+	// 冷却期过后再次触发，发送成功
+	clock.Advance(DefaultAlertCooldown)
+	sender.mu.Lock()
+	sender.fail = false
+	sender.mu.Unlock()
+	am.CheckQuotaUsage("user-1")
+	am.WaitNotifications()
+	if len(sender.messages) != 2 || sender.to[0] != "user@example.com" {
+		t.Fatalf("Expected 2 emails to user@example.com, got %d", len(sender.messages))
+	}
+	if !strings.Contains(sender.messages[0], "Subject: [Quota ") || !strings.Contains(sender.messages[0], "95.00%") {
+		t.Errorf("Unexpected email content: %s", sender.messages[0])
+	}
+}
+
+func TestAlertDuplicateSuppression(t *testing.T) {
+	sender := &mockMailSender{}
+	email, _ := NewEmailNotifier(sender, "alerts@example.com", "Subject: {{.Level}}\n\n{{.Message}}\n")
+
+	store := newMemoryAlertStore()
+	store.prefs["user-1"] = []*AlertPreference{
+		{UserID: "user-1", Channel: AlertChannelEmail, Target: "user@example.com", MinLevel: AlertLevelWarning, Enabled: true},
+	}
+	am, clock := newNotifyingAlertManager(t, store, 75)
+	am.RegisterNotifier(AlertLevelWarning, email)
+	am.SetCooldown(30 * time.Minute)
+
+	for i := 0; i < 5; i++ {
+		am.CheckQuotaUsage("user-1")
+		clock.Advance(time.Minute)
+	}
+	am.WaitNotifications()
+	if len(sender.messages) != 1 || len(am.GetUserAlerts("user-1")) != 1 {
+		t.Fatalf("Expected 1 alert within the cooldown, got %d emails", len(sender.messages))
+	}
+
+	// 冷却期结束后可以再次发送
+	clock.Advance(30 * time.Minute)
+	am.CheckQuotaUsage("user-1")
+	am.WaitNotifications()
+	if len(sender.messages) != 2 {
+		t.Errorf("Expected a second email after the cooldown, got %d", len(sender.messages))
+	}
+
+	// 其他等级不受冷却影响
+	am.quotaManager.AddUsage("user-1", 20)
+	am.CheckQuotaUsage("user-1")
+	am.WaitNotifications()
+	levels := make(map[string]int)
+	for _, alert := range store.alerts {
+		levels[alert.Level.String()]++
+	}
+	if levels["warning"] != 2 || levels["critical"] != 1 {
+		t.Errorf("Unexpected alert levels: %v", levels)
+	}
+}
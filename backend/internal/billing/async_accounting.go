@@ -3,10 +3,12 @@ package billing
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/metrics"
 )
 
 // BillingEvent 计费事件
@@ -29,12 +31,24 @@ type BillingEvent struct {
 	// 输出 token 数
 	OutputTokens int64 `json:"output_tokens"`
 
+	// 推理 token 数（已包含在输出中，按输出价格计费）
+	ReasoningTokens int64 `json:"reasoning_tokens"`
+
 	// 费用
 	Cost float64 `json:"cost"`
 
+	// 用户分组，对应同名价格组的倍率；为空时由消费者按用户 ID 查询
+	UserGroup string `json:"user_group,omitempty"`
+
 	// 请求 ID
 	RequestID string `json:"request_id"`
 
+	// 请求开始前的额度预留 ID，为空时直接计入用量
+	ReservationID string `json:"reservation_id,omitempty"`
+
+	// 重放的死信 ID，不是重放的事件为 0
+	DeadLetterID int64 `json:"-"`
+
 	// 时间戳
 	Timestamp time.Time `json:"timestamp"`
 
@@ -60,6 +74,10 @@ type BillingEventQueue struct {
 	// 停止信号
 	stopCh chan struct{}
 
+	// 队列中事件的入队时间（与事件顺序一致），用于计算最早事件的等待时长
+	enqueuedAt []time.Time
+	pendingMu  sync.Mutex
+
 	// 统计信息
 	enqueueCount  int64
 	dequeueCount  int64
@@ -76,6 +94,7 @@ func NewBillingEventQueue(queueName string, bufferSize int) *BillingEventQueue {
 		QueueName:  queueName,
 		events:     make(chan *BillingEvent, bufferSize),
 		bufferSize: bufferSize,
+		running:    true,
 		stopCh:     make(chan struct{}),
 		logFunc:    defaultLogFunc,
 	}
@@ -90,11 +109,16 @@ func (beq *BillingEventQueue) Enqueue(event *BillingEvent) error {
 	}
 	beq.runMu.Unlock()
 
+	// 入队与记录入队时间在同一把锁内完成，保证时间顺序与事件顺序一致
+	beq.pendingMu.Lock()
 	select {
 	case beq.events <- event:
+		beq.enqueuedAt = append(beq.enqueuedAt, time.Now())
+		beq.pendingMu.Unlock()
 		atomic.AddInt64(&beq.enqueueCount, 1)
 		return nil
 	default:
+		beq.pendingMu.Unlock()
 		atomic.AddInt64(&beq.discardCount, 1)
 		beq.logFunc("warn", fmt.Sprintf("Queue %s is full, event discarded", beq.QueueName))
 		return fmt.Errorf("queue is full")
@@ -105,6 +129,11 @@ func (beq *BillingEventQueue) Enqueue(event *BillingEvent) error {
 func (beq *BillingEventQueue) Dequeue(ctx context.Context) (*BillingEvent, error) {
 	select {
 	case event := <-beq.events:
+		beq.pendingMu.Lock()
+		if len(beq.enqueuedAt) > 0 {
+			beq.enqueuedAt = beq.enqueuedAt[1:]
+		}
+		beq.pendingMu.Unlock()
 		atomic.AddInt64(&beq.dequeueCount, 1)
 		return event, nil
 	case <-ctx.Done():
@@ -119,6 +148,16 @@ func (beq *BillingEventQueue) Size() int {
 	return len(beq.events)
 }
 
+// OldestEventAge 队列中最早事件已等待的时长，队列为空时为 0
+func (beq *BillingEventQueue) OldestEventAge() time.Duration {
+	beq.pendingMu.Lock()
+	defer beq.pendingMu.Unlock()
+	if len(beq.enqueuedAt) == 0 {
+		return 0
+	}
+	return time.Since(beq.enqueuedAt[0])
+}
+
 // GetStatistics 获取统计信息
 func (beq *BillingEventQueue) GetStatistics() map[string]interface{} {
 	return map[string]interface{}{
@@ -130,7 +169,25 @@ func (beq *BillingEventQueue) GetStatistics() map[string]interface{} {
 	}
 }
 
+const (
+	// consumerFlushInterval 消费者等待新事件的最长时间，到期后处理已攒下的批次
+	consumerFlushInterval = 100 * time.Millisecond
+	// maxRetryBackoff 重试间隔的上限
+	maxRetryBackoff = time.Minute
+)
+
+// retryEntry 等待重试的事件
+type retryEntry struct {
+	event         *BillingEvent
+	attempts      int // 已失败的次数
+	nextAttemptAt time.Time
+	lastErr       error
+}
+
 // BillingConsumer 计费消费者
+//
+// 处理失败的事件进入重试队列，由单独的协程按指数退避重试，不阻塞批处理循环；
+// 重试 maxRetries 次仍失败的事件进入死信队列。
 type BillingConsumer struct {
 	// 消费者 ID
 	ConsumerID string
@@ -144,17 +201,32 @@ type BillingConsumer struct {
 	// 定价管理器
 	pricingManager *PricingManager
 
+	// 查询用户分组，事件未携带分组时使用，为 nil 时按基础价格计费
+	groupResolver func(userID string) string
+
+	// 结算成功后检查用户的配额预警，为 nil 时不检查
+	alertManager *AlertManager
+
+	// 结算成功后异步检查自动充值，为 nil 时不检查
+	autoRecharge *AutoRechargeManager
+
 	// 最大重试次数
 	maxRetries int
 
-	// 重试间隔
+	// 首次重试间隔，之后每次翻倍，不超过 maxRetryBackoff
 	retryInterval time.Duration
 
+	// 重试队列
+	retries   []*retryEntry
+	retryMu   sync.Mutex
+	retryWake chan struct{}
+
 	// 批处理大小
 	batchSize int
 
-	// 死信队列
+	// 死信队列，设置了 deadLetters 时只在写入存储失败时使用
 	deadLetterQueue *BillingEventQueue
+	deadLetters     DeadLetterStore
 
 	// 是否运行
 	running bool
@@ -163,6 +235,10 @@ type BillingConsumer struct {
 	// 停止信号
 	stopCh chan struct{}
 
+	// 批处理与重试协程退出信号
+	runDone   chan struct{}
+	retryDone chan struct{}
+
 	// 统计信息
 	processedCount  int64
 	successCount    int64
@@ -192,6 +268,7 @@ func NewBillingConsumer(
 		retryInterval:  1 * time.Second,
 		batchSize:      100,
 		deadLetterQueue: dlq,
+		retryWake:      make(chan struct{}, 1),
 		stopCh:         make(chan struct{}),
 		logFunc:        defaultLogFunc,
 	}
@@ -205,16 +282,21 @@ func (bc *BillingConsumer) Start(ctx context.Context) {
 		return
 	}
 	bc.running = true
+	bc.runDone = make(chan struct{})
+	bc.retryDone = make(chan struct{})
 	bc.runMu.Unlock()
 
 	go bc.run(ctx)
+	go bc.runRetries(ctx)
 	bc.logFunc("info", fmt.Sprintf("Billing consumer %s started", bc.ConsumerID))
 }
 
 // run 消费者运行循环
 func (bc *BillingConsumer) run(ctx context.Context) {
+	defer close(bc.runDone)
+
 	batch := make([]*BillingEvent, 0, bc.batchSize)
-	ticker := time.NewTicker(100 * time.Millisecond)
+	ticker := time.NewTicker(consumerFlushInterval)
 	defer ticker.Stop()
 
 	for {
@@ -243,12 +325,11 @@ func (bc *BillingConsumer) run(ctx context.Context) {
 			}
 
 		default:
-			// 尝试从队列获取事件
-			event, err := bc.queue.Dequeue(ctx)
+			// 尝试从队列获取事件，最多等待一个处理周期，以便及时处理已攒下的批次与停止信号
+			dequeueCtx, cancel := context.WithTimeout(ctx, consumerFlushInterval)
+			event, err := bc.queue.Dequeue(dequeueCtx)
+			cancel()
 			if err != nil {
-				if err == context.DeadlineExceeded || err == context.Canceled {
-					continue
-				}
 				continue
 			}
 
@@ -261,47 +342,60 @@ func (bc *BillingConsumer) run(ctx context.Context) {
 	}
 }
 
-// processBatch 处理一批事件
+// processBatch 处理一批事件，失败的事件进入重试队列
 func (bc *BillingConsumer) processBatch(events []*BillingEvent) {
 	for _, event := range events {
-		if err := bc.processEvent(event, 0); err != nil {
-			atomic.AddInt64(&bc.failureCount, 1)
-			bc.logFunc("error", fmt.Sprintf("Failed to process event %s: %v", event.EventID, err))
-		} else {
-			atomic.AddInt64(&bc.successCount, 1)
+		if err := bc.processEvent(event); err != nil {
+			bc.logFunc("warn", fmt.Sprintf("Failed to process event %s, scheduling retry: %v", event.EventID, err))
+			bc.scheduleRetry(&retryEntry{event: event, attempts: 1, lastErr: err})
+			continue
 		}
-		atomic.AddInt64(&bc.processedCount, 1)
+		bc.succeeded(event)
+	}
+}
+
+// succeeded 记录处理成功的事件并检查配额预警与自动充值，重放的死信标记为已重放
+func (bc *BillingConsumer) succeeded(event *BillingEvent) {
+	atomic.AddInt64(&bc.successCount, 1)
+	atomic.AddInt64(&bc.processedCount, 1)
+	metrics.BillingEventProcessed("success")
+	if bc.alertManager != nil {
+		if err := bc.alertManager.CheckQuotaUsage(event.UserID); err != nil {
+			bc.logFunc("debug", fmt.Sprintf("Skipped quota alert check for user %s: %v", event.UserID, err))
+		}
+	}
+	if bc.autoRecharge != nil && bc.autoRecharge.HasConfig(event.UserID) {
+		// 扣款需要调用支付渠道，不阻塞批处理
+		go func(userID string) {
+			if err := bc.autoRecharge.CheckAndRecharge(userID); err != nil && !errors.Is(err, ErrRechargeInProgress) {
+				bc.logFunc("warn", fmt.Sprintf("Auto-recharge for user %s failed: %v", userID, err))
+			}
+		}(event.UserID)
+	}
+	if event.DeadLetterID == 0 || bc.deadLetters == nil {
+		return
+	}
+
+	ctx := context.Background()
+	dl, err := bc.deadLetters.GetDeadLetter(ctx, event.DeadLetterID)
+	if err == nil {
+		err = resolveDeadLetter(ctx, bc.deadLetters, dl, DeadLetterReplayed)
+	}
+	if err != nil {
+		bc.logFunc("error", fmt.Sprintf("Failed to mark dead letter %d as replayed: %v", event.DeadLetterID, err))
 	}
 }
 
 // processEvent 处理单个事件
-func (bc *BillingConsumer) processEvent(event *BillingEvent, retryCount int) error {
+func (bc *BillingConsumer) processEvent(event *BillingEvent) error {
 	// 计算费用
-	cost, err := bc.pricingManager.CalculatePrice(event.ModelName, event.InputTokens, event.OutputTokens)
+	cost, err := bc.price(event)
 	if err != nil {
-		if retryCount < bc.maxRetries {
-			atomic.AddInt64(&bc.retryCount, 1)
-			time.Sleep(bc.retryInterval)
-			return bc.processEvent(event, retryCount+1)
-		}
-		// 重试失败，发送到死信队列
-		_ = bc.deadLetterQueue.Enqueue(event)
-		atomic.AddInt64(&bc.dlqCount, 1)
 		return err
 	}
 
-	// 确认扣费
-	recordID := fmt.Sprintf("record-%s", event.EventID)
-	err = bc.quotaManager.ConfirmDeduction(event.UserID, recordID, cost)
-	if err != nil {
-		if retryCount < bc.maxRetries {
-			atomic.AddInt64(&bc.retryCount, 1)
-			time.Sleep(bc.retryInterval)
-			return bc.processEvent(event, retryCount+1)
-		}
-		// 重试失败，发送到死信队列
-		_ = bc.deadLetterQueue.Enqueue(event)
-		atomic.AddInt64(&bc.dlqCount, 1)
+	// 确认扣费：结算请求开始前的预留，没有预留时直接计入用量
+	if err := bc.settle(event, cost); err != nil {
 		return err
 	}
 
@@ -310,16 +404,196 @@ func (bc *BillingConsumer) processEvent(event *BillingEvent, retryCount int) err
 	return nil
 }
 
-// Stop 停止消费者
+// price 按用户分组计算费用：分组配置了适用于该模型的价格组时应用倍率，否则按基础价格
+func (bc *BillingConsumer) price(event *BillingEvent) (float64, error) {
+	if event.UserGroup == "" && bc.groupResolver != nil {
+		event.UserGroup = bc.groupResolver(event.UserID)
+	}
+
+	group := event.UserGroup
+	if group == "" || group == DefaultUserGroup {
+		return bc.pricingManager.CalculatePrice(event.ModelName, event.InputTokens, event.OutputTokens)
+	}
+
+	pg, err := bc.pricingManager.GetPriceGroup(group)
+	if err != nil || !pg.AppliesTo(event.ModelName) {
+		bc.logFunc("info", fmt.Sprintf("No price group %s for model %s, billing event %s at base price", group, event.ModelName, event.EventID))
+		return bc.pricingManager.CalculatePrice(event.ModelName, event.InputTokens, event.OutputTokens)
+	}
+
+	return bc.pricingManager.CalculatePriceWithGroup(event.ModelName, group, event.InputTokens, event.OutputTokens)
+}
+
+// retryBackoff 第 attempts 次失败后的重试间隔
+func (bc *BillingConsumer) retryBackoff(attempts int) time.Duration {
+	backoff := bc.retryInterval
+	for i := 1; i < attempts && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	return backoff
+}
+
+// scheduleRetry 失败次数未超过 maxRetries 时按退避时间加入重试队列，否则转入死信队列
+func (bc *BillingConsumer) scheduleRetry(entry *retryEntry) {
+	if entry.attempts > bc.maxRetries {
+		bc.deadLetter(entry)
+		return
+	}
+
+	metrics.BillingEventProcessed("retry")
+	entry.nextAttemptAt = time.Now().Add(bc.retryBackoff(entry.attempts))
+	bc.retryMu.Lock()
+	bc.retries = append(bc.retries, entry)
+	bc.retryMu.Unlock()
+
+	select {
+	case bc.retryWake <- struct{}{}:
+	default:
+	}
+}
+
+// deadLetter 事件转入死信队列，设置了死信存储时写入存储
+func (bc *BillingConsumer) deadLetter(entry *retryEntry) {
+	event := entry.event
+	atomic.AddInt64(&bc.dlqCount, 1)
+	atomic.AddInt64(&bc.failureCount, 1)
+	atomic.AddInt64(&bc.processedCount, 1)
+	metrics.BillingEventProcessed("dead_letter")
+	bc.logFunc("error", fmt.Sprintf("Event %s moved to dead letter queue after %d attempts: %v", event.EventID, entry.attempts, entry.lastErr))
+
+	if bc.deadLetters != nil {
+		reason := "unknown error"
+		if entry.lastErr != nil {
+			reason = entry.lastErr.Error()
+		}
+		err := recordDeadLetter(context.Background(), bc.deadLetters, event, reason, entry.attempts)
+		if err == nil {
+			return
+		}
+		bc.logFunc("error", fmt.Sprintf("Failed to persist dead letter for event %s: %v", event.EventID, err))
+	}
+	_ = bc.deadLetterQueue.Enqueue(event)
+}
+
+// runRetries 重试协程：在最早的 nextAttemptAt 到期时重试到期的事件
+func (bc *BillingConsumer) runRetries(ctx context.Context) {
+	defer close(bc.retryDone)
+
+	timer := time.NewTimer(maxRetryBackoff)
+	defer timer.Stop()
+
+	for {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if next, ok := bc.nextRetryAt(); ok {
+			timer.Reset(time.Until(next))
+		}
+
+		select {
+		case <-ctx.Done():
+			bc.abandonRetries()
+			return
+		case <-bc.stopCh:
+			bc.abandonRetries()
+			return
+		case <-bc.retryWake:
+		case <-timer.C:
+			bc.processDueRetries(time.Now())
+		}
+	}
+}
+
+// nextRetryAt 重试队列中最早的重试时间
+func (bc *BillingConsumer) nextRetryAt() (time.Time, bool) {
+	bc.retryMu.Lock()
+	defer bc.retryMu.Unlock()
+
+	var next time.Time
+	for _, entry := range bc.retries {
+		if next.IsZero() || entry.nextAttemptAt.Before(next) {
+			next = entry.nextAttemptAt
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// processDueRetries 重试到期的事件
+func (bc *BillingConsumer) processDueRetries(now time.Time) {
+	bc.retryMu.Lock()
+	var due []*retryEntry
+	pending := bc.retries[:0]
+	for _, entry := range bc.retries {
+		if entry.nextAttemptAt.After(now) {
+			pending = append(pending, entry)
+		} else {
+			due = append(due, entry)
+		}
+	}
+	bc.retries = pending
+	bc.retryMu.Unlock()
+
+	for _, entry := range due {
+		atomic.AddInt64(&bc.retryCount, 1)
+		if err := bc.processEvent(entry.event); err != nil {
+			bc.logFunc("warn", fmt.Sprintf("Retry %d of event %s failed: %v", entry.attempts, entry.event.EventID, err))
+			entry.attempts++
+			entry.lastErr = err
+			bc.scheduleRetry(entry)
+			continue
+		}
+		bc.succeeded(entry.event)
+	}
+}
+
+// abandonRetries 停止时等待批处理循环退出，仍在等待重试的事件转入死信队列
+func (bc *BillingConsumer) abandonRetries() {
+	<-bc.runDone
+
+	bc.retryMu.Lock()
+	pending := bc.retries
+	bc.retries = nil
+	bc.retryMu.Unlock()
+
+	for _, entry := range pending {
+		bc.deadLetter(entry)
+	}
+}
+
+// settle 按实际费用结算事件
+func (bc *BillingConsumer) settle(event *BillingEvent, cost float64) error {
+	if event.ReservationID == "" {
+		return bc.quotaManager.ConfirmDeduction(event.UserID, fmt.Sprintf("record-%s", event.EventID), cost)
+	}
+
+	err := bc.quotaManager.Confirm(event.ReservationID, cost)
+	if errors.Is(err, ErrReservationNotFound) {
+		// 预留已过期被释放，实际费用仍需计入
+		bc.logFunc("warn", fmt.Sprintf("Reservation %s of event %s not found, charging usage directly", event.ReservationID, event.EventID))
+		bc.quotaManager.AddUsage(event.UserID, cost)
+		return nil
+	}
+	return err
+}
+
+// Stop 停止消费者，等待当前批次处理完成
 func (bc *BillingConsumer) Stop() {
 	bc.runMu.Lock()
 	if !bc.running {
 		bc.runMu.Unlock()
 		return
 	}
+	retryDone := bc.retryDone
 	bc.runMu.Unlock()
 
 	close(bc.stopCh)
+	<-retryDone
 }
 
 // GetStatistics 获取统计信息
@@ -330,16 +604,50 @@ func (bc *BillingConsumer) GetStatistics() map[string]interface{} {
 		"failure_count":   atomic.LoadInt64(&bc.failureCount),
 		"retry_count":     atomic.LoadInt64(&bc.retryCount),
 		"dlq_count":       atomic.LoadInt64(&bc.dlqCount),
+		"retry_pending":   bc.pendingRetries(),
 	}
 }
 
-// GetDeadLetterQueueSize 获取死信队列大小
+// pendingRetries 等待重试的事件数
+func (bc *BillingConsumer) pendingRetries() int {
+	bc.retryMu.Lock()
+	defer bc.retryMu.Unlock()
+	return len(bc.retries)
+}
+
+// SetGroupResolver 设置用户分组查询，需在 Start 之前调用
+func (bc *BillingConsumer) SetGroupResolver(resolver func(userID string) string) {
+	bc.groupResolver = resolver
+}
+
+// SetAlertManager 设置配额预警，结算成功后检查用户是否触发预警，需在 Start 之前调用
+func (bc *BillingConsumer) SetAlertManager(am *AlertManager) {
+	bc.alertManager = am
+}
+
+// SetAutoRecharge 设置自动充值，结算成功后检查用户是否需要充值，需在 Start 之前调用
+func (bc *BillingConsumer) SetAutoRecharge(arm *AutoRechargeManager) {
+	bc.autoRecharge = arm
+}
+
+// SetDeadLetterStore 设置死信存储
+func (bc *BillingConsumer) SetDeadLetterStore(store DeadLetterStore) {
+	bc.deadLetters = store
+}
+
+// GetDeadLetterQueueSize 获取内存死信队列大小
 func (bc *BillingConsumer) GetDeadLetterQueueSize() int {
 	return bc.deadLetterQueue.Size()
 }
 
 // ProcessDeadLetterQueue 处理死信队列
+//
+// 设置了死信存储时逐条处理存储中待处理的死信：callback 成功的标记为已重放，失败的累加失败次数。
 func (bc *BillingConsumer) ProcessDeadLetterQueue(ctx context.Context, callback func(*BillingEvent) error) error {
+	if bc.deadLetters != nil {
+		return bc.processStoredDeadLetters(ctx, callback)
+	}
+
 	for {
 		event, err := bc.deadLetterQueue.Dequeue(ctx)
 		if err != nil {
@@ -365,6 +673,47 @@ func (bc *BillingConsumer) ProcessDeadLetterQueue(ctx context.Context, callback
 	return nil
 }
 
+// processStoredDeadLetters 处理存储中待处理的死信
+func (bc *BillingConsumer) processStoredDeadLetters(ctx context.Context, callback func(*BillingEvent) error) error {
+	if callback == nil {
+		return nil
+	}
+
+	// 先取出全部待处理的死信，处理失败的仍为待处理，不会在本轮重复处理
+	const pageSize = 100
+	var pending []*DeadLetter
+	for page := 1; ; page++ {
+		entries, _, err := bc.deadLetters.ListDeadLetters(ctx, DeadLetterFilter{Status: DeadLetterPending, Page: page, PageSize: pageSize})
+		if err != nil {
+			return err
+		}
+		pending = append(pending, entries...)
+		if len(entries) < pageSize {
+			break
+		}
+	}
+
+	for _, dl := range pending {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := callback(dl.Event); err != nil {
+			bc.logFunc("error", fmt.Sprintf("DLQ callback failed for dead letter %d: %v", dl.ID, err))
+			dl.Reason = err.Error()
+			dl.Attempts++
+			dl.UpdatedAt = time.Now()
+			if err := bc.deadLetters.UpdateDeadLetter(ctx, dl); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := resolveDeadLetter(ctx, bc.deadLetters, dl, DeadLetterReplayed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // BillingEventLogger 计费事件日志记录器
 type BillingEventLogger struct {
 	// 日志文件路径
@@ -421,16 +770,17 @@ func (bel *BillingEventLogger) flush() error {
 	data := make([]map[string]interface{}, len(bel.buffer))
 	for i, event := range bel.buffer {
 		data[i] = map[string]interface{}{
-			"event_id":      event.EventID,
-			"user_id":       event.UserID,
-			"event_type":    event.EventType,
-			"model_name":    event.ModelName,
-			"input_tokens":  event.InputTokens,
-			"output_tokens": event.OutputTokens,
-			"cost":          event.Cost,
-			"request_id":    event.RequestID,
-			"timestamp":     event.Timestamp,
-			"metadata":      event.Metadata,
+			"event_id":         event.EventID,
+			"user_id":          event.UserID,
+			"event_type":       event.EventType,
+			"model_name":       event.ModelName,
+			"input_tokens":     event.InputTokens,
+			"output_tokens":    event.OutputTokens,
+			"reasoning_tokens": event.ReasoningTokens,
+			"cost":             event.Cost,
+			"request_id":       event.RequestID,
+			"timestamp":        event.Timestamp,
+			"metadata":         event.Metadata,
 		}
 	}
 
@@ -472,6 +822,9 @@ type AsyncBillingService struct {
 	consumers []*BillingConsumer
 	consumersMu sync.RWMutex
 
+	// 死信存储，为 nil 时死信只保存在各消费者的内存队列中
+	deadLetters DeadLetterStore
+
 	// 事件日志记录器
 	logger *BillingEventLogger
 
@@ -503,9 +856,17 @@ func (abs *AsyncBillingService) AddConsumer(consumer *BillingConsumer) {
 	abs.consumersMu.Lock()
 	defer abs.consumersMu.Unlock()
 
+	if abs.deadLetters != nil {
+		consumer.SetDeadLetterStore(abs.deadLetters)
+	}
 	abs.consumers = append(abs.consumers, consumer)
 }
 
+// Queue 事件队列，用于创建消费者
+func (abs *AsyncBillingService) Queue() *BillingEventQueue {
+	return abs.queue
+}
+
 // PublishEvent 发布计费事件
 func (abs *AsyncBillingService) PublishEvent(event *BillingEvent) error {
 	return abs.queue.Enqueue(event)
@@ -514,7 +875,7 @@ func (abs *AsyncBillingService) PublishEvent(event *BillingEvent) error {
 // Start 启动服务
 func (abs *AsyncBillingService) Start() {
 	abs.consumersMu.RLock()
-	defer abs.consumersMu.RLock()
+	defer abs.consumersMu.RUnlock()
 
 	for _, consumer := range abs.consumers {
 		consumer.Start(abs.ctx)
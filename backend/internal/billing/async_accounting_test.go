@@ -2,6 +2,8 @@ package billing
 
 import (
 	"context"
+	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -107,6 +109,162 @@ func TestBillingConsumer(t *testing.T) {
 	}
 }
 
+func TestBillingConsumerSettlesReservation(t *testing.T) {
+	quotaManager := NewQuotaManager()
+	quotaManager.CreateUserQuota("user-1", 1000.0)
+	reservationID, err := quotaManager.Reserve("user-1", 100.0)
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+
+	pricingManager := NewPricingManager()
+	pricingManager.RegisterModelPrice("gpt-4", 0.03, 0.06, PricingByToken)
+	consumer := NewBillingConsumer("consumer-1", NewBillingEventQueue("test-queue", 100), quotaManager, pricingManager)
+
+	event := &BillingEvent{
+		EventID:       "evt-1",
+		UserID:        "user-1",
+		ModelName:     "gpt-4",
+		InputTokens:   1000,
+		OutputTokens:  1000,
+		ReservationID: reservationID,
+	}
+	if err := consumer.processEvent(event); err != nil {
+		t.Fatalf("processEvent failed: %v", err)
+	}
+	if quotaManager.GetReserved("user-1") != 0 {
+		t.Errorf("Expected reservation to be settled, got %f reserved", quotaManager.GetReserved("user-1"))
+	}
+	usage := quotaManager.GetUsage("user-1")
+	if usage <= 0 {
+		t.Errorf("Expected usage to be charged, got %f", usage)
+	}
+
+	// 预留已过期时仍按实际费用计入
+	event.EventID = "evt-2"
+	if err := consumer.processEvent(event); err != nil {
+		t.Fatalf("processEvent failed: %v", err)
+	}
+	if quotaManager.GetUsage("user-1") != 2*usage {
+		t.Errorf("Expected usage %f, got %f", 2*usage, quotaManager.GetUsage("user-1"))
+	}
+}
+
+func TestBillingConsumerAppliesUserGroupMultiplier(t *testing.T) {
+	quotaManager := NewQuotaManager()
+	pricingManager := NewPricingManager()
+	pricingManager.RegisterModelPrice("gpt-4", 0.03, 0.06, PricingByToken)
+	pricingManager.RegisterModelPrice("gpt-3.5", 0.001, 0.002, PricingByToken)
+	if err := pricingManager.CreatePriceGroup("vip", "VIP", []string{"gpt-4"}, 0.5); err != nil {
+		t.Fatalf("CreatePriceGroup failed: %v", err)
+	}
+
+	consumer := NewBillingConsumer("consumer-1", NewBillingEventQueue("test-queue", 100), quotaManager, pricingManager)
+	groups := map[string]string{"vip-user": "vip", "default-user": DefaultUserGroup}
+	consumer.SetGroupResolver(func(userID string) string { return groups[userID] })
+
+	usage := func(userID, modelName string) float64 {
+		event := &BillingEvent{EventID: "evt-" + userID + "-" + modelName, UserID: userID, ModelName: modelName, InputTokens: 1000, OutputTokens: 1000}
+		if err := consumer.processEvent(event); err != nil {
+			t.Fatalf("processEvent failed: %v", err)
+		}
+		return quotaManager.GetUsage(userID)
+	}
+
+	// 同样的用量：默认分组按 1x，vip 分组按 0.5x
+	if got := usage("default-user", "gpt-4"); got < 0.0899 || got > 0.0901 {
+		t.Errorf("Expected default group to be billed 0.09, got %f", got)
+	}
+	if got := usage("vip-user", "gpt-4"); got < 0.0449 || got > 0.0451 {
+		t.Errorf("Expected vip group to be billed 0.045, got %f", got)
+	}
+
+	// 价格组不包含的模型按基础价格
+	if got := usage("vip-user", "gpt-3.5") - 0.045; got < 0.00299 || got > 0.00301 {
+		t.Errorf("Expected model outside the group to be billed at base price 0.003, got %f", got)
+	}
+
+	// 事件携带的分组优先于查询结果，未配置价格组的分组按基础价格
+	event := &BillingEvent{EventID: "evt-gold", UserID: "gold-user", UserGroup: "gold", ModelName: "gpt-4", InputTokens: 1000, OutputTokens: 1000}
+	if err := consumer.processEvent(event); err != nil {
+		t.Fatalf("processEvent failed: %v", err)
+	}
+	if got := quotaManager.GetUsage("gold-user"); got < 0.0899 || got > 0.0901 {
+		t.Errorf("Expected unconfigured group to be billed at base price, got %f", got)
+	}
+}
+
+func TestBillingConsumerRetryDoesNotBlockHealthyEvents(t *testing.T) {
+	quotaManager := NewQuotaManager()
+	quotaManager.CreateUserQuota("user-1", 1000.0)
+
+	// 未登记价格的模型计算费用必然失败
+	pricingManager := NewPricingManager()
+	pricingManager.RegisterModelPrice("gpt-4", 0.03, 0.06, PricingByToken)
+
+	queue := NewBillingEventQueue("test-queue", 100)
+	consumer := NewBillingConsumer("consumer-1", queue, quotaManager, pricingManager)
+	consumer.retryInterval = 50 * time.Millisecond
+	consumer.maxRetries = 3
+
+	_ = queue.Enqueue(&BillingEvent{EventID: "poison", UserID: "user-1", ModelName: "unpriced-model", InputTokens: 1000, OutputTokens: 1000})
+	for i := 0; i < 5; i++ {
+		_ = queue.Enqueue(&BillingEvent{EventID: fmt.Sprintf("evt-%d", i), UserID: "user-1", ModelName: "gpt-4", InputTokens: 1000, OutputTokens: 1000})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Now()
+	consumer.Start(ctx)
+
+	// 正常事件在首个处理周期内结算，不等待失败事件的重试
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&consumer.successCount) < 5 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := atomic.LoadInt64(&consumer.successCount); n != 5 {
+		t.Fatalf("Expected 5 settled events, got %d", n)
+	}
+	if elapsed := time.Since(start); elapsed >= 3*consumerFlushInterval {
+		t.Errorf("Healthy events took %v to settle", elapsed)
+	}
+	if consumer.GetDeadLetterQueueSize() != 0 {
+		t.Errorf("Poisoned event should still be retrying")
+	}
+
+	// 失败事件按 50ms、100ms、200ms 退避重试 3 次后进入死信队列
+	deadline = time.Now().Add(2 * time.Second)
+	for consumer.GetDeadLetterQueueSize() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if consumer.GetDeadLetterQueueSize() != 1 {
+		t.Fatalf("Expected poisoned event in dead letter queue")
+	}
+	if elapsed := time.Since(start); elapsed < 350*time.Millisecond {
+		t.Errorf("Retries did not back off, dead-lettered after %v", elapsed)
+	}
+
+	consumer.Stop()
+	stats := consumer.GetStatistics()
+	if stats["retry_count"].(int64) != 3 || stats["failure_count"].(int64) != 1 || stats["processed_count"].(int64) != 6 {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+	if stats["retry_pending"].(int) != 0 {
+		t.Errorf("Expected no pending retries, got %v", stats["retry_pending"])
+	}
+}
+
+func TestBillingConsumerRetryBackoff(t *testing.T) {
+	consumer := NewBillingConsumer("consumer-1", NewBillingEventQueue("test-queue", 1), NewQuotaManager(), NewPricingManager())
+	consumer.retryInterval = time.Second
+
+	for attempts, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: maxRetryBackoff} {
+		if backoff := consumer.retryBackoff(attempts); backoff != expected {
+			t.Errorf("Attempt %d: expected backoff %v, got %v", attempts, expected, backoff)
+		}
+	}
+}
+
 func TestAsyncBillingService(t *testing.T) {
 	quotaManager := NewQuotaManager()
 	quotaManager.CreateUserQuota("user-1", 1000.0)
@@ -219,7 +377,7 @@ func TestDeadLetterQueue(t *testing.T) {
 	_ = queue.Enqueue(event)
 
 	// 处理会失败并进入 DLQ
-	consumer.processEvent(event, 0)
+	consumer.processEvent(event)
 
 	dlqSize := consumer.GetDeadLetterQueueSize()
 	if dlqSize < 1 {
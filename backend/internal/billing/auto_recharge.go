@@ -0,0 +1,78 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 自动充值记录状态
+const (
+	RechargePending   = "pending"   // 已发起扣款，结果未知，下次检查时以相同幂等键重试
+	RechargeCharged   = "charged"   // 扣款成功，额度尚未入账
+	RechargeCompleted = "completed" // 扣款成功且额度已入账
+	RechargeDeclined  = "declined"  // 扣款被拒绝，自动充值已暂停
+	RechargeRefunded  = "refunded"  // 额度入账失败，已退款
+)
+
+var (
+	// ErrPaymentNotConfigured 未配置支付渠道，不允许自动充值
+	ErrPaymentNotConfigured = errors.New("auto-recharge payment not configured")
+	// ErrNoPaymentMethod 用户没有保存的支付方式
+	ErrNoPaymentMethod = errors.New("no saved payment method")
+	// ErrAutoRechargeSuspended 上次扣款失败后自动充值已暂停，需更新支付方式
+	ErrAutoRechargeSuspended = errors.New("auto-recharge suspended until payment method is updated")
+	// ErrPaymentDeclined 扣款被拒绝（卡被拒、需要验证等），重试不会成功
+	ErrPaymentDeclined = errors.New("payment declined")
+	// ErrRechargeInProgress 该用户已有进行中的自动充值
+	ErrRechargeInProgress = errors.New("auto-recharge already in progress")
+)
+
+// PaymentMethod 用户保存的支付方式
+type PaymentMethod struct {
+	UserID          string
+	CustomerID      string // 支付平台的客户 ID
+	PaymentMethodID string
+	// Suspended 扣款失败后暂停自动充值，更新支付方式后恢复
+	Suspended     bool
+	SuspendReason string
+}
+
+// ChargeRequest 使用保存的支付方式发起的离线扣款
+type ChargeRequest struct {
+	UserID          string
+	CustomerID      string
+	PaymentMethodID string
+	AmountCents     int64
+	Currency        string
+	// IdempotencyKey 相同的键只会扣款一次
+	IdempotencyKey string
+	Description    string
+}
+
+// ChargeResult 扣款结果
+type ChargeResult struct {
+	PaymentIntentID string
+}
+
+// PaymentClient 支付渠道
+type PaymentClient interface {
+	// ChargeOffSession 离线扣款，被拒绝时返回包装了 ErrPaymentDeclined 的错误，其他错误视为结果未知
+	ChargeOffSession(ctx context.Context, req *ChargeRequest) (*ChargeResult, error)
+	// Refund 全额退款，用于额度入账失败时的补偿
+	Refund(ctx context.Context, paymentIntentID, idempotencyKey string) error
+}
+
+// AutoRechargeStore 支付方式与自动充值记录的持久化存储
+type AutoRechargeStore interface {
+	// GetPaymentMethod 没有保存的支付方式时返回 nil
+	GetPaymentMethod(ctx context.Context, userID string) (*PaymentMethod, error)
+	// SuspendAutoRecharge 暂停用户的自动充值，直到更新支付方式
+	SuspendAutoRecharge(ctx context.Context, userID, reason string) error
+	// SaveRecharge 按 RecordID 创建或更新充值记录
+	SaveRecharge(ctx context.Context, record *RechargeRecord) error
+	// PendingRecharge 返回用户最近一条未完成（pending 或 charged）的充值记录，没有时返回 nil
+	PendingRecharge(ctx context.Context, userID string) (*RechargeRecord, error)
+	// CountRecharges 统计 since 之后完成的充值次数
+	CountRecharges(ctx context.Context, userID string, since time.Time) (int, error)
+}
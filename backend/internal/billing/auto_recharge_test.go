@@ -0,0 +1,281 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockPaymentClient 按幂等键去重的模拟支付渠道
+type mockPaymentClient struct {
+	mu       sync.Mutex
+	decline  bool
+	failNext int // 接下来几次请求在扣款成功后返回网络错误（结果未知）
+	charges  map[string]string
+	requests []*ChargeRequest
+	refunds  []string
+}
+
+func newMockPaymentClient() *mockPaymentClient {
+	return &mockPaymentClient{charges: make(map[string]string)}
+}
+
+func (m *mockPaymentClient) ChargeOffSession(ctx context.Context, req *ChargeRequest) (*ChargeResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, req)
+
+	if m.decline {
+		return nil, fmt.Errorf("%w: card_declined", ErrPaymentDeclined)
+	}
+	id, ok := m.charges[req.IdempotencyKey]
+	if !ok {
+		id = fmt.Sprintf("pi_%d", len(m.charges)+1)
+		m.charges[req.IdempotencyKey] = id
+	}
+	if m.failNext > 0 {
+		m.failNext--
+		return nil, errors.New("connection reset by peer")
+	}
+	return &ChargeResult{PaymentIntentID: id}, nil
+}
+
+func (m *mockPaymentClient) Refund(ctx context.Context, paymentIntentID, idempotencyKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refunds = append(m.refunds, paymentIntentID)
+	return nil
+}
+
+// memoryAutoRechargeStore 内存中的自动充值存储
+type memoryAutoRechargeStore struct {
+	mu      sync.Mutex
+	methods map[string]*PaymentMethod
+	records map[string]*RechargeRecord
+}
+
+func newMemoryAutoRechargeStore() *memoryAutoRechargeStore {
+	return &memoryAutoRechargeStore{
+		methods: make(map[string]*PaymentMethod),
+		records: make(map[string]*RechargeRecord),
+	}
+}
+
+func (s *memoryAutoRechargeStore) GetPaymentMethod(ctx context.Context, userID string) (*PaymentMethod, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, ok := s.methods[userID]; ok {
+		copied := *m
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (s *memoryAutoRechargeStore) SuspendAutoRecharge(ctx context.Context, userID, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methods[userID].Suspended = true
+	s.methods[userID].SuspendReason = reason
+	return nil
+}
+
+func (s *memoryAutoRechargeStore) SaveRecharge(ctx context.Context, record *RechargeRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *record
+	s.records[record.RecordID] = &copied
+	return nil
+}
+
+func (s *memoryAutoRechargeStore) PendingRecharge(ctx context.Context, userID string) (*RechargeRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.records {
+		if r.UserID == userID && (r.Status == RechargePending || r.Status == RechargeCharged) {
+			copied := *r
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryAutoRechargeStore) CountRecharges(ctx context.Context, userID string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, r := range s.records {
+		if r.UserID == userID && r.Status == RechargeCompleted && !r.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memoryAutoRechargeStore) statuses() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make(map[string]int)
+	for _, r := range s.records {
+		result[r.Status]++
+	}
+	return result
+}
+
+// usePaymentMethod 为 userID 保存支付方式并配置模拟支付渠道
+func usePaymentMethod(arm *AutoRechargeManager, userID string) {
+	store := newMemoryAutoRechargeStore()
+	store.methods[userID] = &PaymentMethod{UserID: userID, CustomerID: "cus_" + userID, PaymentMethodID: "pm_" + userID}
+	arm.SetPayment(newMockPaymentClient(), store, "usd")
+}
+
+func newPaidAutoRecharge(t *testing.T) (*AutoRechargeManager, *QuotaManager, *mockPaymentClient, *memoryAutoRechargeStore) {
+	t.Helper()
+	quotaManager := NewQuotaManager()
+	quotaManager.CreateUserQuota("1", 100.0)
+	quotaManager.AddUsage("1", 95.0)
+
+	payments := newMockPaymentClient()
+	store := newMemoryAutoRechargeStore()
+	store.methods["1"] = &PaymentMethod{UserID: "1", CustomerID: "cus_1", PaymentMethodID: "pm_1"}
+
+	arm := NewAutoRechargeManager(quotaManager)
+	arm.logFunc = func(level, msg string, args ...interface{}) {}
+	arm.SetPayment(payments, store, "usd")
+	if err := arm.CreateAutoRechargeConfig("1", 90.0, 500.0, 3, 30); err != nil {
+		t.Fatalf("CreateAutoRechargeConfig failed: %v", err)
+	}
+	return arm, quotaManager, payments, store
+}
+
+func TestAutoRechargeChargesBeforeCredit(t *testing.T) {
+	arm, quotaManager, payments, store := newPaidAutoRecharge(t)
+
+	if err := arm.CheckAndRecharge("1"); err != nil {
+		t.Fatalf("CheckAndRecharge failed: %v", err)
+	}
+
+	if len(payments.requests) != 1 {
+		t.Fatalf("Expected 1 charge, got %d", len(payments.requests))
+	}
+	req := payments.requests[0]
+	if req.AmountCents != 500 || req.Currency != "usd" || req.PaymentMethodID != "pm_1" || req.IdempotencyKey == "" {
+		t.Errorf("Unexpected charge request: %+v", req)
+	}
+	if quota := quotaManager.GetQuota("1"); quota != 600.0 {
+		t.Errorf("Expected total quota 600 after recharge, got %f", quota)
+	}
+	if s := store.statuses(); s[RechargeCompleted] != 1 || len(s) != 1 {
+		t.Errorf("Expected one completed record, got %v", s)
+	}
+	history := arm.GetRechargeHistory("1")
+	if len(history) != 1 || history[0].PaymentIntentID != "pi_1" {
+		t.Errorf("Unexpected history: %+v", history)
+	}
+}
+
+func TestAutoRechargeDeclineSuspends(t *testing.T) {
+	arm, quotaManager, payments, store := newPaidAutoRecharge(t)
+	payments.decline = true
+
+	alerts := NewAlertManager(quotaManager)
+	alerts.logFunc = func(level, msg string, args ...interface{}) {}
+	arm.SetAlertManager(alerts)
+
+	err := arm.CheckAndRecharge("1")
+	if !errors.Is(err, ErrPaymentDeclined) {
+		t.Fatalf("Expected ErrPaymentDeclined, got %v", err)
+	}
+	if quota := quotaManager.GetQuota("1"); quota != 100.0 {
+		t.Errorf("Declined charge must not credit quota, got %f", quota)
+	}
+	if !store.methods["1"].Suspended {
+		t.Errorf("Auto-recharge should be suspended after a decline")
+	}
+	if s := store.statuses(); s[RechargeDeclined] != 1 {
+		t.Errorf("Expected a declined record, got %v", s)
+	}
+	userAlerts := alerts.GetUserAlerts("1")
+	if len(userAlerts) != 1 || userAlerts[0].Level != AlertLevelCritical {
+		t.Errorf("Expected a critical alert, got %+v", userAlerts)
+	}
+
+	// 暂停后不再尝试扣款
+	if err := arm.CheckAndRecharge("1"); !errors.Is(err, ErrAutoRechargeSuspended) {
+		t.Errorf("Expected ErrAutoRechargeSuspended, got %v", err)
+	}
+	if len(payments.requests) != 1 {
+		t.Errorf("Suspended auto-recharge must not charge again, got %d requests", len(payments.requests))
+	}
+}
+
+func TestAutoRechargeRetryIsIdempotent(t *testing.T) {
+	arm, quotaManager, payments, store := newPaidAutoRecharge(t)
+	payments.failNext = 1
+
+	// 扣款已成功但响应丢失：记录保持 pending，额度不入账
+	if err := arm.CheckAndRecharge("1"); err == nil {
+		t.Fatalf("Expected an error when the charge result is unknown")
+	}
+	if quota := quotaManager.GetQuota("1"); quota != 100.0 {
+		t.Errorf("Unknown charge must not credit quota, got %f", quota)
+	}
+	if s := store.statuses(); s[RechargePending] != 1 {
+		t.Errorf("Expected a pending record, got %v", s)
+	}
+
+	// 重试复用幂等键，不会重复扣款
+	if err := arm.CheckAndRecharge("1"); err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	if len(payments.requests) != 2 || payments.requests[0].IdempotencyKey != payments.requests[1].IdempotencyKey {
+		t.Errorf("Retry should reuse the idempotency key")
+	}
+	if len(payments.charges) != 1 {
+		t.Errorf("Expected exactly 1 charge, got %d", len(payments.charges))
+	}
+	if quota := quotaManager.GetQuota("1"); quota != 600.0 {
+		t.Errorf("Expected quota credited once, got %f", quota)
+	}
+	if s := store.statuses(); s[RechargeCompleted] != 1 || len(s) != 1 {
+		t.Errorf("Expected one completed record, got %v", s)
+	}
+}
+
+func TestAutoRechargeRefundsWhenCreditFails(t *testing.T) {
+	arm, quotaManager, payments, store := newPaidAutoRecharge(t)
+	arm.credit = func(userID string, amount float64) error {
+		return errors.New("quota store unavailable")
+	}
+
+	if err := arm.CheckAndRecharge("1"); err == nil {
+		t.Fatalf("Expected the credit failure to be returned")
+	}
+	if len(payments.refunds) != 1 || payments.refunds[0] != "pi_1" {
+		t.Errorf("Expected the charge to be refunded, got %v", payments.refunds)
+	}
+	if s := store.statuses(); s[RechargeRefunded] != 1 {
+		t.Errorf("Expected a refunded record, got %v", s)
+	}
+	if quota := quotaManager.GetQuota("1"); quota != 100.0 {
+		t.Errorf("Quota should be unchanged, got %f", quota)
+	}
+}
+
+func TestAutoRechargeRequiresPayment(t *testing.T) {
+	quotaManager := NewQuotaManager()
+	quotaManager.CreateUserQuota("1", 100.0)
+	quotaManager.AddUsage("1", 95.0)
+
+	arm := NewAutoRechargeManager(quotaManager)
+	arm.CreateAutoRechargeConfig("1", 90.0, 500.0, 3, 30)
+
+	if err := arm.CheckAndRecharge("1"); !errors.Is(err, ErrPaymentNotConfigured) {
+		t.Errorf("Expected ErrPaymentNotConfigured, got %v", err)
+	}
+	if quota := quotaManager.GetQuota("1"); quota != 100.0 {
+		t.Errorf("Auto-recharge without payment must not credit quota, got %f", quota)
+	}
+}
@@ -0,0 +1,167 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 死信状态
+const (
+	DeadLetterPending   = "pending"   // 等待处理
+	DeadLetterReplaying = "replaying" // 已重新入队，等待消费者处理
+	DeadLetterReplayed  = "replayed"  // 重放成功
+	DeadLetterDiscarded = "discarded" // 已人工丢弃
+)
+
+var (
+	// ErrDeadLetterNotFound 死信不存在
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	// ErrDeadLetterResolved 死信不处于待处理状态，不能重放或丢弃
+	ErrDeadLetterResolved = errors.New("dead letter is not pending")
+	// ErrDeadLetterStoreMissing 未配置死信存储
+	ErrDeadLetterStoreMissing = errors.New("dead letter store not configured")
+)
+
+// DeadLetter 重试耗尽后进入死信队列的计费事件
+type DeadLetter struct {
+	ID         int64         `json:"id"`
+	Event      *BillingEvent `json:"event"`
+	Reason     string        `json:"reason"`   // 最后一次失败的原因
+	Attempts   int           `json:"attempts"` // 累计失败次数，含重放
+	Status     string        `json:"status"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+	ResolvedAt *time.Time    `json:"resolved_at,omitempty"` // 重放成功或丢弃的时间
+}
+
+// DeadLetterFilter 死信查询条件，空值表示不过滤
+type DeadLetterFilter struct {
+	UserID    string
+	ModelName string
+	Status    string
+	Page      int
+	PageSize  int
+}
+
+// DeadLetterStore 死信的持久化存储
+type DeadLetterStore interface {
+	CreateDeadLetter(ctx context.Context, dl *DeadLetter) error
+	// GetDeadLetter 不存在时返回 ErrDeadLetterNotFound
+	GetDeadLetter(ctx context.Context, id int64) (*DeadLetter, error)
+	ListDeadLetters(ctx context.Context, filter DeadLetterFilter) ([]*DeadLetter, int64, error)
+	UpdateDeadLetter(ctx context.Context, dl *DeadLetter) error
+}
+
+// recordDeadLetter 持久化重试耗尽的事件；重放的事件再次失败时累加原记录的失败次数
+func recordDeadLetter(ctx context.Context, store DeadLetterStore, event *BillingEvent, reason string, attempts int) error {
+	if event.DeadLetterID != 0 {
+		dl, err := store.GetDeadLetter(ctx, event.DeadLetterID)
+		if err == nil {
+			dl.Reason = reason
+			dl.Attempts += attempts
+			dl.Status = DeadLetterPending
+			dl.UpdatedAt = time.Now()
+			return store.UpdateDeadLetter(ctx, dl)
+		}
+		if !errors.Is(err, ErrDeadLetterNotFound) {
+			return err
+		}
+	}
+
+	now := time.Now()
+	return store.CreateDeadLetter(ctx, &DeadLetter{
+		Event:     event,
+		Reason:    reason,
+		Attempts:  attempts,
+		Status:    DeadLetterPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+}
+
+// resolveDeadLetter 标记死信为已重放或已丢弃
+func resolveDeadLetter(ctx context.Context, store DeadLetterStore, dl *DeadLetter, status string) error {
+	now := time.Now()
+	dl.Status = status
+	dl.UpdatedAt = now
+	dl.ResolvedAt = &now
+	return store.UpdateDeadLetter(ctx, dl)
+}
+
+// SetDeadLetterStore 设置死信存储，已添加和之后添加的消费者共用
+func (abs *AsyncBillingService) SetDeadLetterStore(store DeadLetterStore) {
+	abs.consumersMu.Lock()
+	defer abs.consumersMu.Unlock()
+
+	abs.deadLetters = store
+	for _, consumer := range abs.consumers {
+		consumer.SetDeadLetterStore(store)
+	}
+}
+
+// ListDeadLetters 分页查询死信
+func (abs *AsyncBillingService) ListDeadLetters(ctx context.Context, filter DeadLetterFilter) ([]*DeadLetter, int64, error) {
+	if abs.deadLetters == nil {
+		return nil, 0, ErrDeadLetterStoreMissing
+	}
+	return abs.deadLetters.ListDeadLetters(ctx, filter)
+}
+
+// GetDeadLetter 查询单条死信
+func (abs *AsyncBillingService) GetDeadLetter(ctx context.Context, id int64) (*DeadLetter, error) {
+	if abs.deadLetters == nil {
+		return nil, ErrDeadLetterStoreMissing
+	}
+	return abs.deadLetters.GetDeadLetter(ctx, id)
+}
+
+// ReplayDeadLetter 将待处理的死信重新入队；消费者处理成功后标记为已重放，再次失败时回到待处理并累加失败次数
+func (abs *AsyncBillingService) ReplayDeadLetter(ctx context.Context, id int64) (*DeadLetter, error) {
+	dl, err := abs.pendingDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	dl.Status = DeadLetterReplaying
+	dl.UpdatedAt = time.Now()
+	if err := abs.deadLetters.UpdateDeadLetter(ctx, dl); err != nil {
+		return nil, err
+	}
+
+	event := *dl.Event
+	event.DeadLetterID = dl.ID
+	if err := abs.queue.Enqueue(&event); err != nil {
+		dl.Status = DeadLetterPending
+		if updateErr := abs.deadLetters.UpdateDeadLetter(ctx, dl); updateErr != nil {
+			abs.logFunc("error", fmt.Sprintf("Failed to reset dead letter %d after enqueue failure: %v", dl.ID, updateErr))
+		}
+		return nil, err
+	}
+	return dl, nil
+}
+
+// DiscardDeadLetter 人工丢弃待处理的死信
+func (abs *AsyncBillingService) DiscardDeadLetter(ctx context.Context, id int64) (*DeadLetter, error) {
+	dl, err := abs.pendingDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveDeadLetter(ctx, abs.deadLetters, dl, DeadLetterDiscarded); err != nil {
+		return nil, err
+	}
+	return dl, nil
+}
+
+// pendingDeadLetter 查询待处理的死信
+func (abs *AsyncBillingService) pendingDeadLetter(ctx context.Context, id int64) (*DeadLetter, error) {
+	dl, err := abs.GetDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dl.Status != DeadLetterPending {
+		return nil, fmt.Errorf("%w: dead letter %d is %s", ErrDeadLetterResolved, id, dl.Status)
+	}
+	return dl, nil
+}
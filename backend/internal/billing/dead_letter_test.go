@@ -0,0 +1,211 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryDeadLetterStore 内存中的死信存储
+type memoryDeadLetterStore struct {
+	mu      sync.Mutex
+	nextID  int64
+	entries map[int64]DeadLetter
+}
+
+func newMemoryDeadLetterStore() *memoryDeadLetterStore {
+	return &memoryDeadLetterStore{entries: make(map[int64]DeadLetter)}
+}
+
+func (s *memoryDeadLetterStore) CreateDeadLetter(ctx context.Context, dl *DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	dl.ID = s.nextID
+	s.entries[dl.ID] = *dl
+	return nil
+}
+
+func (s *memoryDeadLetterStore) GetDeadLetter(ctx context.Context, id int64) (*DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dl, ok := s.entries[id]
+	if !ok {
+		return nil, ErrDeadLetterNotFound
+	}
+	return &dl, nil
+}
+
+func (s *memoryDeadLetterStore) ListDeadLetters(ctx context.Context, filter DeadLetterFilter) ([]*DeadLetter, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []*DeadLetter
+	for _, dl := range s.entries {
+		if (filter.UserID == "" || dl.Event.UserID == filter.UserID) &&
+			(filter.ModelName == "" || dl.Event.ModelName == filter.ModelName) &&
+			(filter.Status == "" || dl.Status == filter.Status) {
+			copied := dl
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, int64(len(result)), nil
+}
+
+func (s *memoryDeadLetterStore) UpdateDeadLetter(ctx context.Context, dl *DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[dl.ID]; !ok {
+		return ErrDeadLetterNotFound
+	}
+	s.entries[dl.ID] = *dl
+	return nil
+}
+
+// waitDeadLetter 等待死信进入指定状态
+func waitDeadLetter(t *testing.T, store *memoryDeadLetterStore, id int64, status string) *DeadLetter {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		dl, err := store.GetDeadLetter(context.Background(), id)
+		if err == nil && dl.Status == status {
+			return dl
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Dead letter %d did not become %s: %+v", id, status, dl)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// newDeadLetterFixture 未登记价格的模型计算费用失败，重试 1 次后进入死信存储
+func newDeadLetterFixture(t *testing.T) (*AsyncBillingService, *memoryDeadLetterStore, *QuotaManager, *PricingManager) {
+	t.Helper()
+	quotaManager := NewQuotaManager()
+	quotaManager.CreateUserQuota("user-1", 1000.0)
+	pricingManager := NewPricingManager()
+	store := newMemoryDeadLetterStore()
+
+	abs := NewAsyncBillingService("billing-queue", 100)
+	abs.SetDeadLetterStore(store)
+	consumer := NewBillingConsumer("consumer-1", abs.Queue(), quotaManager, pricingManager)
+	consumer.retryInterval = 5 * time.Millisecond
+	consumer.maxRetries = 1
+	abs.AddConsumer(consumer)
+	abs.Start()
+	t.Cleanup(abs.Stop)
+
+	if err := abs.PublishEvent(&BillingEvent{EventID: "evt-1", UserID: "user-1", ModelName: "new-model", InputTokens: 1000, OutputTokens: 1000}); err != nil {
+		t.Fatalf("PublishEvent failed: %v", err)
+	}
+	dl := waitDeadLetter(t, store, 1, DeadLetterPending)
+	if dl.Attempts != 2 || dl.Event.EventID != "evt-1" || dl.Reason == "" {
+		t.Fatalf("Unexpected dead letter: %+v", dl)
+	}
+	if consumer.GetDeadLetterQueueSize() != 0 {
+		t.Errorf("Persisted dead letters should not use the in-memory queue")
+	}
+	return abs, store, quotaManager, pricingManager
+}
+
+func TestReplayDeadLetterSucceeds(t *testing.T) {
+	abs, store, quotaManager, pricingManager := newDeadLetterFixture(t)
+
+	// 登记价格后重放成功
+	pricingManager.RegisterModelPrice("new-model", 0.03, 0.06, PricingByToken)
+	dl, err := abs.ReplayDeadLetter(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("ReplayDeadLetter failed: %v", err)
+	}
+	if dl.Status != DeadLetterReplaying {
+		t.Errorf("Expected replaying, got %s", dl.Status)
+	}
+
+	dl = waitDeadLetter(t, store, 1, DeadLetterReplayed)
+	if dl.ResolvedAt == nil || dl.Attempts != 2 {
+		t.Errorf("Unexpected replayed dead letter: %+v", dl)
+	}
+	if quotaManager.GetUsage("user-1") <= 0 {
+		t.Errorf("Replayed event should be charged")
+	}
+
+	// 已重放的死信不能再次重放或丢弃
+	if _, err := abs.ReplayDeadLetter(context.Background(), 1); !errors.Is(err, ErrDeadLetterResolved) {
+		t.Errorf("Expected ErrDeadLetterResolved, got %v", err)
+	}
+	if _, err := abs.DiscardDeadLetter(context.Background(), 1); !errors.Is(err, ErrDeadLetterResolved) {
+		t.Errorf("Expected ErrDeadLetterResolved, got %v", err)
+	}
+	if _, err := abs.ReplayDeadLetter(context.Background(), 99); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("Expected ErrDeadLetterNotFound, got %v", err)
+	}
+}
+
+func TestReplayDeadLetterFailsAgain(t *testing.T) {
+	abs, store, quotaManager, _ := newDeadLetterFixture(t)
+
+	if _, err := abs.ReplayDeadLetter(context.Background(), 1); err != nil {
+		t.Fatalf("ReplayDeadLetter failed: %v", err)
+	}
+	waitDeadLetter(t, store, 1, DeadLetterReplaying)
+
+	// 再次失败时回到同一条记录，累加失败次数
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		dl, _ := store.GetDeadLetter(context.Background(), 1)
+		if dl.Status == DeadLetterPending && dl.Attempts == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected replayed dead letter to fail again with 4 attempts, got %+v", dl)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, total, _ := store.ListDeadLetters(context.Background(), DeadLetterFilter{}); total != 1 {
+		t.Errorf("Expected 1 dead letter, got %d", total)
+	}
+	if quotaManager.GetUsage("user-1") != 0 {
+		t.Errorf("Failed event should not be charged")
+	}
+
+	dl, err := abs.DiscardDeadLetter(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("DiscardDeadLetter failed: %v", err)
+	}
+	if dl.Status != DeadLetterDiscarded || dl.ResolvedAt == nil {
+		t.Errorf("Unexpected discarded dead letter: %+v", dl)
+	}
+}
+
+func TestProcessDeadLetterQueueReadsStore(t *testing.T) {
+	store := newMemoryDeadLetterStore()
+	ctx := context.Background()
+	for _, id := range []string{"evt-1", "evt-2"} {
+		_ = recordDeadLetter(ctx, store, &BillingEvent{EventID: id, UserID: "user-1", ModelName: "gpt-4"}, "pricing unavailable", 4)
+	}
+
+	consumer := NewBillingConsumer("consumer-1", NewBillingEventQueue("test-queue", 1), NewQuotaManager(), NewPricingManager())
+	consumer.SetDeadLetterStore(store)
+
+	err := consumer.ProcessDeadLetterQueue(ctx, func(event *BillingEvent) error {
+		if event.EventID == "evt-2" {
+			return errors.New("still failing")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ProcessDeadLetterQueue failed: %v", err)
+	}
+
+	first, _ := store.GetDeadLetter(ctx, 1)
+	second, _ := store.GetDeadLetter(ctx, 2)
+	if first.Status != DeadLetterReplayed {
+		t.Errorf("Expected first dead letter replayed, got %s", first.Status)
+	}
+	if second.Status != DeadLetterPending || second.Attempts != 5 || second.Reason != "still failing" {
+		t.Errorf("Unexpected second dead letter: %+v", second)
+	}
+}
@@ -2,6 +2,9 @@ package billing
 
 import (
 	"context"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type BillingEngine struct {
@@ -26,7 +29,13 @@ func (e *BillingEngine) CalculateCost(ctx context.Context, modelName string, inp
 }
 
 func (e *BillingEngine) RecordBilling(ctx context.Context, event *BillingEvent) error {
-	return e.accounting.Enqueue(event)
+	_, span := tracing.Start(ctx, "billing.enqueue",
+		attribute.String("model", event.ModelName),
+		attribute.Int64("prompt_tokens", event.InputTokens),
+		attribute.Int64("completion_tokens", event.OutputTokens))
+	err := e.accounting.Enqueue(event)
+	tracing.End(span, err)
+	return err
 }
 
 func (e *BillingEngine) CheckQuotaAlert(ctx context.Context, userID string) error {
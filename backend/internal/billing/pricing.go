@@ -1,10 +1,13 @@
 package billing
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/metrics"
 )
 
 // PricingType 定价类型
@@ -63,7 +66,22 @@ type ModelPrice struct {
 	Remark string
 }
 
+// DefaultUserGroup 未配置分组的用户所在的默认分组，按基础价格计费
+const DefaultUserGroup = "default"
+
+var (
+	// ErrPriceGroupNotFound 价格组不存在
+	ErrPriceGroupNotFound = errors.New("price group not found")
+	// ErrPriceGroupExists 价格组已存在
+	ErrPriceGroupExists = errors.New("price group already exists")
+	// ErrInvalidMultiplier 倍率必须为正数
+	ErrInvalidMultiplier = errors.New("multiplier must be a positive number")
+)
+
 // PriceGroup 价格组（分组定价）
+//
+// GroupID 与用户分组同名时，该分组用户的消费按倍率计费；Models 为空表示适用于所有模型。
+// 价格组创建后不再修改，更新时整体替换，读取方无需加锁。
 type PriceGroup struct {
 	// 组 ID
 	GroupID string
@@ -87,6 +105,27 @@ type PriceGroup struct {
 	Remark string
 }
 
+// AppliesTo 价格组是否适用于该模型
+func (g *PriceGroup) AppliesTo(modelName string) bool {
+	if len(g.Models) == 0 {
+		return true
+	}
+	for _, m := range g.Models {
+		if m == modelName {
+			return true
+		}
+	}
+	return false
+}
+
+// validateMultiplier 倍率必须为有限正数
+func validateMultiplier(multiplier float64) error {
+	if multiplier <= 0 || math.IsInf(multiplier, 0) || math.IsNaN(multiplier) {
+		return ErrInvalidMultiplier
+	}
+	return nil
+}
+
 // PricingStrategy 定价策略
 type PricingStrategy struct {
 	// 策略 ID
@@ -141,6 +180,11 @@ type PricingManager struct {
 	activeStrategy *PricingStrategy
 	activeMu       sync.RWMutex
 
+	// 策略调度协程，按有效期切换活跃策略
+	schedulerMu   sync.Mutex
+	schedulerStop chan struct{}
+	schedulerDone chan struct{}
+
 	// 定价历史（用于跟踪价格变化）
 	priceHistory map[string][]*PriceHistoryRecord
 	historyMu    sync.RWMutex
@@ -150,6 +194,8 @@ type PricingManager struct {
 
 	// 日志函数
 	logFunc func(level, msg string, args ...interface{})
+
+	now func() time.Time
 }
 
 // PriceHistoryRecord 价格历史记录
@@ -181,6 +227,7 @@ func NewPricingManager() *PricingManager {
 		strategies:   make(map[string]*PricingStrategy),
 		priceHistory: make(map[string][]*PriceHistoryRecord),
 		logFunc:      defaultLogFunc,
+		now:          time.Now,
 	}
 }
 
@@ -282,11 +329,15 @@ func (pm *PricingManager) GetModelPrice(modelName string) (*ModelPrice, error) {
 
 // CreatePriceGroup 创建价格组
 func (pm *PricingManager) CreatePriceGroup(groupID, groupName string, models []string, multiplier float64) error {
+	if err := validateMultiplier(multiplier); err != nil {
+		return err
+	}
+
 	pm.groupsMu.Lock()
 	defer pm.groupsMu.Unlock()
 
 	if _, exists := pm.priceGroups[groupID]; exists {
-		return fmt.Errorf("price group %s already exists", groupID)
+		return fmt.Errorf("%w: %s", ErrPriceGroupExists, groupID)
 	}
 
 	pm.priceGroups[groupID] = &PriceGroup{
@@ -310,50 +361,72 @@ func (pm *PricingManager) GetPriceGroup(groupID string) (*PriceGroup, error) {
 
 	group, ok := pm.priceGroups[groupID]
 	if !ok {
-		return nil, fmt.Errorf("price group %s not found", groupID)
+		return nil, fmt.Errorf("%w: %s", ErrPriceGroupNotFound, groupID)
 	}
 
 	return group, nil
 }
 
-// CreatePricingStrategy 创建定价策略
-func (pm *PricingManager) CreatePricingStrategy(strategyID, strategyName string, applicableModels []string) error {
-	pm.strategiesMu.Lock()
-	defer pm.strategiesMu.Unlock()
+// UpdatePriceGroup 更新价格组的名称、模型列表与倍率
+func (pm *PricingManager) UpdatePriceGroup(groupID, groupName string, models []string, multiplier float64) error {
+	if err := validateMultiplier(multiplier); err != nil {
+		return err
+	}
+
+	pm.groupsMu.Lock()
+	defer pm.groupsMu.Unlock()
 
-	if _, exists := pm.strategies[strategyID]; exists {
-		return fmt.Errorf("pricing strategy %s already exists", strategyID)
+	old, ok := pm.priceGroups[groupID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrPriceGroupNotFound, groupID)
+	}
+
+	pm.priceGroups[groupID] = &PriceGroup{
+		GroupID:    groupID,
+		GroupName:  groupName,
+		Models:     models,
+		Multiplier: multiplier,
+		CreatedAt:  old.CreatedAt,
+		UpdatedAt:  time.Now(),
+		Remark:     old.Remark,
 	}
 
-	pm.strategies[strategyID] = &PricingStrategy{
-		StrategyID:       strategyID,
-		StrategyName:     strategyName,
-		ApplicableModels: applicableModels,
-		BasePrices:       make(map[string]*ModelPrice),
-		Groups:           make([]*PriceGroup, 0),
-		EffectiveFrom:    time.Now(),
-		CreatedAt:        time.Now(),
-		UpdatedAt:        time.Now(),
+	pm.logFunc("info", fmt.Sprintf("Updated price group %s multiplier %.2f -> %.2f", groupID, old.Multiplier, multiplier))
+
+	return nil
+}
+
+// DeletePriceGroup 删除价格组，该分组的用户之后按基础价格计费
+func (pm *PricingManager) DeletePriceGroup(groupID string) error {
+	pm.groupsMu.Lock()
+	defer pm.groupsMu.Unlock()
+
+	if _, ok := pm.priceGroups[groupID]; !ok {
+		return fmt.Errorf("%w: %s", ErrPriceGroupNotFound, groupID)
 	}
+	delete(pm.priceGroups, groupID)
 
-	pm.logFunc("info", fmt.Sprintf("Created pricing strategy %s (%s) for %d models", strategyID, strategyName, len(applicableModels)))
+	pm.logFunc("info", fmt.Sprintf("Deleted price group %s", groupID))
 
 	return nil
 }
 
-// ActivatePricingStrategy 激活定价策略
-func (pm *PricingManager) ActivatePricingStrategy(strategyID string) error {
-	pm.strategiesMu.RLock()
-	strategy, exists := pm.strategies[strategyID]
-	pm.strategiesMu.RUnlock()
+// CreatePricingStrategy 创建定价策略，从当前时刻起长期有效
+func (pm *PricingManager) CreatePricingStrategy(strategyID, strategyName string, applicableModels []string) error {
+	return pm.CreateScheduledStrategy(strategyID, strategyName, applicableModels, pm.now(), nil)
+}
 
-	if !exists {
-		return fmt.Errorf("pricing strategy %s not found", strategyID)
+// ActivatePricingStrategy 立即激活定价策略，当前时间必须在策略有效期内
+func (pm *PricingManager) ActivatePricingStrategy(strategyID string) error {
+	strategy, err := pm.GetPricingStrategy(strategyID)
+	if err != nil {
+		return err
+	}
+	if !strategy.EffectiveAt(pm.now()) {
+		return fmt.Errorf("%w: %s", ErrStrategyNotEffective, strategyID)
 	}
 
-	pm.activeMu.Lock()
-	pm.activeStrategy = strategy
-	pm.activeMu.Unlock()
+	pm.switchStrategy(strategy, fmt.Sprintf("pricing strategy %s activated manually", strategyID))
 
 	pm.logFunc("info", fmt.Sprintf("Activated pricing strategy %s (%s)", strategyID, strategy.StrategyName))
 
@@ -368,9 +441,9 @@ func (pm *PricingManager) GetActiveStrategy() *PricingStrategy {
 	return pm.activeStrategy
 }
 
-// CalculatePrice 计算价格
+// CalculatePrice 计算价格，活跃策略在有效期内且为该模型单独定价时使用策略价格
 func (pm *PricingManager) CalculatePrice(modelName string, inputTokens, outputTokens int64) (float64, error) {
-	price, err := pm.GetModelPrice(modelName)
+	price, err := pm.effectivePrice(modelName)
 	if err != nil {
 		return 0, err
 	}
@@ -395,6 +468,16 @@ func (pm *PricingManager) CalculatePrice(modelName string, inputTokens, outputTo
 	return totalCost, nil
 }
 
+// effectivePrice 当前适用的模型价格：活跃策略的 BasePrices 优先，否则使用全局价格
+func (pm *PricingManager) effectivePrice(modelName string) (*ModelPrice, error) {
+	if strategy := pm.GetActiveStrategy(); strategy != nil && strategy.EffectiveAt(pm.now()) {
+		if price := strategy.basePrice(modelName); price != nil {
+			return price, nil
+		}
+	}
+	return pm.GetModelPrice(modelName)
+}
+
 // CalculatePriceWithGroup 计算带分组倍率的价格
 func (pm *PricingManager) CalculatePriceWithGroup(modelName string, groupID string, inputTokens, outputTokens int64) (float64, error) {
 	basePrice, err := pm.CalculatePrice(modelName, inputTokens, outputTokens)
@@ -408,15 +491,7 @@ func (pm *PricingManager) CalculatePriceWithGroup(modelName string, groupID stri
 	}
 
 	// 检查模型是否在组内
-	isInGroup := false
-	for _, m := range group.Models {
-		if m == modelName {
-			isInGroup = true
-			break
-		}
-	}
-
-	if !isInGroup {
+	if !group.AppliesTo(modelName) {
 		return 0, fmt.Errorf("model %s not in group %s", modelName, groupID)
 	}
 
@@ -535,11 +610,13 @@ func (pc *PricingCache) GetPrice(modelName string) (*ModelPrice, error) {
 
 		if exists && time.Since(lastUpdate) < pc.ttl {
 			atomic.AddInt64(&pc.hits, 1)
+			metrics.CacheLookup("pricing", true)
 			return cached.(*ModelPrice), nil
 		}
 	}
 
 	// 缓存未命中或已过期，从管理器获取
+	metrics.CacheLookup("pricing", false)
 	price, err := pc.manager.GetModelPrice(modelName)
 	if err != nil {
 		atomic.AddInt64(&pc.misses, 1)
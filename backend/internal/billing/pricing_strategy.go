@@ -0,0 +1,399 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrStrategyNotFound 定价策略不存在
+	ErrStrategyNotFound = errors.New("pricing strategy not found")
+	// ErrStrategyExists 定价策略已存在
+	ErrStrategyExists = errors.New("pricing strategy already exists")
+	// ErrStrategyOverlap 同一模型的两个策略有效期重叠
+	ErrStrategyOverlap = errors.New("pricing strategy window overlaps another strategy for the same models")
+	// ErrStrategyWindow 有效期结束时间不晚于开始时间
+	ErrStrategyWindow = errors.New("pricing strategy must end after it starts")
+	// ErrStrategyNotEffective 当前时间不在策略有效期内
+	ErrStrategyNotEffective = errors.New("pricing strategy is not effective now")
+)
+
+// EffectiveAt 策略在 at 时刻是否生效，有效期为 [EffectiveFrom, EffectiveTo)
+func (s *PricingStrategy) EffectiveAt(at time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.effectiveAtLocked(at)
+}
+
+func (s *PricingStrategy) effectiveFrom() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.EffectiveFrom
+}
+
+func (s *PricingStrategy) effectiveAtLocked(at time.Time) bool {
+	if at.Before(s.EffectiveFrom) {
+		return false
+	}
+	return s.EffectiveTo == nil || at.Before(*s.EffectiveTo)
+}
+
+// Window 策略的有效期
+func (s *PricingStrategy) Window() (time.Time, *time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.EffectiveFrom, s.EffectiveTo
+}
+
+// Prices 策略中单独定价的模型价格
+func (s *PricingStrategy) Prices() map[string]*ModelPrice {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[string]*ModelPrice, len(s.BasePrices))
+	for m, price := range s.BasePrices {
+		result[m] = price
+	}
+	return result
+}
+
+// basePrice 策略中模型的价格，没有单独定价时返回 nil
+func (s *PricingStrategy) basePrice(modelName string) *ModelPrice {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.BasePrices[modelName]
+}
+
+// overlaps 两个策略是否有共同的模型且有效期重叠
+func (s *PricingStrategy) overlaps(other *PricingStrategy) bool {
+	shared := false
+	for _, m := range s.ApplicableModels {
+		for _, o := range other.ApplicableModels {
+			if m == o {
+				shared = true
+				break
+			}
+		}
+	}
+	if !shared {
+		return false
+	}
+
+	// [a1, a2) 与 [b1, b2) 重叠当且仅当 a1 < b2 且 b1 < a2，nil 表示没有结束时间
+	if s.EffectiveTo != nil && !other.EffectiveFrom.Before(*s.EffectiveTo) {
+		return false
+	}
+	if other.EffectiveTo != nil && !s.EffectiveFrom.Before(*other.EffectiveTo) {
+		return false
+	}
+	return true
+}
+
+// ValidateStrategy 校验有效期，并拒绝与其他策略在相同模型上有效期重叠的策略
+func (pm *PricingManager) ValidateStrategy(strategy *PricingStrategy) error {
+	strategy.mu.RLock()
+	defer strategy.mu.RUnlock()
+
+	if strategy.EffectiveTo != nil && !strategy.EffectiveTo.After(strategy.EffectiveFrom) {
+		return ErrStrategyWindow
+	}
+
+	pm.strategiesMu.RLock()
+	defer pm.strategiesMu.RUnlock()
+	for id, other := range pm.strategies {
+		if id == strategy.StrategyID {
+			continue
+		}
+		other.mu.RLock()
+		overlap := strategy.overlaps(other)
+		other.mu.RUnlock()
+		if overlap {
+			return fmt.Errorf("%w: %s", ErrStrategyOverlap, id)
+		}
+	}
+	return nil
+}
+
+// CreateScheduledStrategy 创建在 [from, to) 内生效的定价策略，to 为 nil 表示长期有效，
+// 有效期与其他策略在相同模型上重叠时拒绝创建
+func (pm *PricingManager) CreateScheduledStrategy(strategyID, strategyName string, applicableModels []string, from time.Time, to *time.Time) error {
+	now := pm.now()
+	strategy := &PricingStrategy{
+		StrategyID:       strategyID,
+		StrategyName:     strategyName,
+		ApplicableModels: applicableModels,
+		BasePrices:       make(map[string]*ModelPrice),
+		Groups:           make([]*PriceGroup, 0),
+		EffectiveFrom:    from,
+		EffectiveTo:      to,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if err := pm.ValidateStrategy(strategy); err != nil {
+		return err
+	}
+
+	pm.strategiesMu.Lock()
+	defer pm.strategiesMu.Unlock()
+
+	if _, exists := pm.strategies[strategyID]; exists {
+		return fmt.Errorf("%w: %s", ErrStrategyExists, strategyID)
+	}
+	pm.strategies[strategyID] = strategy
+
+	pm.logFunc("info", fmt.Sprintf("Created pricing strategy %s (%s) for %d models", strategyID, strategyName, len(applicableModels)))
+
+	return nil
+}
+
+// SetStrategyWindow 设置策略有效期，to 为 nil 表示长期有效；与其他策略重叠时不修改
+func (pm *PricingManager) SetStrategyWindow(strategyID string, from time.Time, to *time.Time) error {
+	strategy, err := pm.GetPricingStrategy(strategyID)
+	if err != nil {
+		return err
+	}
+
+	candidate := &PricingStrategy{
+		StrategyID:       strategyID,
+		ApplicableModels: strategy.ApplicableModels,
+		EffectiveFrom:    from,
+		EffectiveTo:      to,
+	}
+	if err := pm.ValidateStrategy(candidate); err != nil {
+		return err
+	}
+
+	strategy.mu.Lock()
+	strategy.EffectiveFrom = from
+	strategy.EffectiveTo = to
+	strategy.UpdatedAt = pm.now()
+	strategy.mu.Unlock()
+	return nil
+}
+
+// SetStrategyPrice 设置策略中模型的价格，模型必须在策略的适用范围内
+func (pm *PricingManager) SetStrategyPrice(strategyID, modelName string, inputPrice, outputPrice float64, pricingType PricingType) error {
+	strategy, err := pm.GetPricingStrategy(strategyID)
+	if err != nil {
+		return err
+	}
+
+	strategy.mu.Lock()
+	defer strategy.mu.Unlock()
+
+	applicable := false
+	for _, m := range strategy.ApplicableModels {
+		if m == modelName {
+			applicable = true
+			break
+		}
+	}
+	if !applicable {
+		return fmt.Errorf("model %s is not applicable to pricing strategy %s", modelName, strategyID)
+	}
+
+	now := pm.now()
+	strategy.BasePrices[modelName] = &ModelPrice{
+		ModelName:   modelName,
+		InputPrice:  inputPrice,
+		OutputPrice: outputPrice,
+		PricingType: pricingType,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Version:     1,
+	}
+	strategy.UpdatedAt = now
+	return nil
+}
+
+// GetAllStrategies 获取所有定价策略，按开始时间排序
+func (pm *PricingManager) GetAllStrategies() []*PricingStrategy {
+	pm.strategiesMu.RLock()
+	defer pm.strategiesMu.RUnlock()
+
+	result := make([]*PricingStrategy, 0, len(pm.strategies))
+	for _, s := range pm.strategies {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		fi, fj := result[i].effectiveFrom(), result[j].effectiveFrom()
+		if !fi.Equal(fj) {
+			return fi.Before(fj)
+		}
+		return result[i].StrategyID < result[j].StrategyID
+	})
+	return result
+}
+
+// GetPricingStrategy 获取定价策略
+func (pm *PricingManager) GetPricingStrategy(strategyID string) (*PricingStrategy, error) {
+	pm.strategiesMu.RLock()
+	defer pm.strategiesMu.RUnlock()
+
+	strategy, ok := pm.strategies[strategyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrStrategyNotFound, strategyID)
+	}
+	return strategy, nil
+}
+
+// strategyAt at 时刻生效的策略；多个策略（模型不重叠）同时生效时取开始时间最晚的
+func (pm *PricingManager) strategyAt(at time.Time) *PricingStrategy {
+	var selected *PricingStrategy
+	for _, s := range pm.GetAllStrategies() {
+		if s.EffectiveAt(at) {
+			selected = s
+		}
+	}
+	return selected
+}
+
+// priceUnder 在 strategy 生效时模型的价格：策略单独定价优先，否则使用全局价格
+func (pm *PricingManager) priceUnder(strategy *PricingStrategy, modelName string) *ModelPrice {
+	if strategy != nil {
+		if price := strategy.basePrice(modelName); price != nil {
+			return price
+		}
+	}
+
+	pm.pricesMu.RLock()
+	defer pm.pricesMu.RUnlock()
+	return pm.modelPrices[modelName]
+}
+
+// switchStrategy 切换活跃策略，并为价格发生变化的模型记录价格历史
+func (pm *PricingManager) switchStrategy(next *PricingStrategy, reason string) {
+	pm.activeMu.Lock()
+	prev := pm.activeStrategy
+	pm.activeStrategy = next
+	pm.activeMu.Unlock()
+
+	if prev == next {
+		return
+	}
+
+	models := make(map[string]bool)
+	for _, s := range []*PricingStrategy{prev, next} {
+		if s == nil {
+			continue
+		}
+		s.mu.RLock()
+		for m := range s.BasePrices {
+			models[m] = true
+		}
+		s.mu.RUnlock()
+	}
+
+	now := pm.now()
+	var records []*PriceHistoryRecord
+	for m := range models {
+		oldPrice, newPrice := pm.priceUnder(prev, m), pm.priceUnder(next, m)
+		if oldPrice == newPrice {
+			continue
+		}
+		records = append(records, &PriceHistoryRecord{
+			ModelName: m,
+			OldPrice:  oldPrice,
+			NewPrice:  newPrice,
+			ChangedAt: now,
+			Reason:    reason,
+		})
+	}
+
+	pm.historyMu.Lock()
+	for _, r := range records {
+		r.Version = len(pm.priceHistory[r.ModelName]) + 1
+		pm.priceHistory[r.ModelName] = append(pm.priceHistory[r.ModelName], r)
+	}
+	pm.historyMu.Unlock()
+}
+
+// RefreshActiveStrategy 按当前时间激活有效期内的策略，停用已过期的策略，返回活跃策略是否变化
+func (pm *PricingManager) RefreshActiveStrategy() bool {
+	now := pm.now()
+	current := pm.GetActiveStrategy()
+	next := pm.strategyAt(now)
+	if next == current {
+		return false
+	}
+	// 手动激活且仍在有效期内的策略，只会被开始时间更晚的策略替换
+	if current != nil && current.EffectiveAt(now) && (next == nil || !next.effectiveFrom().After(current.effectiveFrom())) {
+		return false
+	}
+
+	var reason string
+	switch {
+	case next == nil:
+		reason = fmt.Sprintf("pricing strategy %s expired", current.StrategyID)
+	case current == nil:
+		reason = fmt.Sprintf("pricing strategy %s activated", next.StrategyID)
+	default:
+		reason = fmt.Sprintf("pricing strategy %s replaced %s", next.StrategyID, current.StrategyID)
+	}
+	pm.switchStrategy(next, reason)
+	pm.logFunc("info", reason)
+	return true
+}
+
+// StartStrategyScheduler 立即并按 interval 定期刷新活跃策略
+func (pm *PricingManager) StartStrategyScheduler(interval time.Duration) {
+	pm.schedulerMu.Lock()
+	defer pm.schedulerMu.Unlock()
+	if pm.schedulerStop != nil {
+		return
+	}
+	pm.schedulerStop = make(chan struct{})
+	pm.schedulerDone = make(chan struct{})
+
+	pm.RefreshActiveStrategy()
+	go func(stopCh, doneCh chan struct{}) {
+		defer close(doneCh)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				pm.RefreshActiveStrategy()
+			}
+		}
+	}(pm.schedulerStop, pm.schedulerDone)
+}
+
+// StopStrategyScheduler 停止策略调度协程
+func (pm *PricingManager) StopStrategyScheduler() {
+	pm.schedulerMu.Lock()
+	defer pm.schedulerMu.Unlock()
+	if pm.schedulerStop == nil {
+		return
+	}
+	close(pm.schedulerStop)
+	<-pm.schedulerDone
+	pm.schedulerStop, pm.schedulerDone = nil, nil
+}
+
+// PricePreview 某一时刻适用的价格
+type PricePreview struct {
+	At         time.Time              `json:"at"`
+	StrategyID string                 `json:"strategy_id,omitempty"` // 该时刻生效的策略，没有时为空
+	Prices     map[string]*ModelPrice `json:"prices"`
+}
+
+// PreviewPrices 预览 at 时刻各模型适用的价格（按调度器在该时刻会选择的策略计算）
+func (pm *PricingManager) PreviewPrices(at time.Time) *PricePreview {
+	strategy := pm.strategyAt(at)
+	preview := &PricePreview{At: at, Prices: pm.GetAllModelPrices()}
+	if strategy == nil {
+		return preview
+	}
+
+	preview.StrategyID = strategy.StrategyID
+	strategy.mu.RLock()
+	for m, price := range strategy.BasePrices {
+		preview.Prices[m] = price
+	}
+	strategy.mu.RUnlock()
+	return preview
+}
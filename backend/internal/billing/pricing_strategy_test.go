@@ -0,0 +1,161 @@
+package billing
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newStrategyManager(t *testing.T, clock *fakeClock) *PricingManager {
+	t.Helper()
+	manager := NewPricingManager()
+	manager.now = clock.Now
+	manager.logFunc = func(level, msg string, args ...interface{}) {}
+	if err := manager.RegisterModelPrice("gpt-4", 0.03, 0.06, PricingByToken); err != nil {
+		t.Fatalf("RegisterModelPrice failed: %v", err)
+	}
+	return manager
+}
+
+func TestStrategySchedulerActivatesAtBoundary(t *testing.T) {
+	start := time.Date(2026, 11, 11, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	clock := &fakeClock{t: start.Add(-time.Second)}
+	manager := newStrategyManager(t, clock)
+
+	if err := manager.CreateScheduledStrategy("sale", "Singles Day", []string{"gpt-4"}, start, &end); err != nil {
+		t.Fatalf("CreateScheduledStrategy failed: %v", err)
+	}
+	if err := manager.SetStrategyPrice("sale", "gpt-4", 0.015, 0.03, PricingByToken); err != nil {
+		t.Fatalf("SetStrategyPrice failed: %v", err)
+	}
+
+	// 开始前一秒：未生效，不允许手动激活
+	if manager.RefreshActiveStrategy() || manager.GetActiveStrategy() != nil {
+		t.Fatalf("Strategy should not be active before its window")
+	}
+	if err := manager.ActivatePricingStrategy("sale"); !errors.Is(err, ErrStrategyNotEffective) {
+		t.Errorf("Expected ErrStrategyNotEffective, got %v", err)
+	}
+	if cost, _ := manager.CalculatePrice("gpt-4", 1000, 1000); cost < 0.0899 || cost > 0.0901 {
+		t.Errorf("Expected base price 0.09 before the window, got %f", cost)
+	}
+
+	// 恰好到达开始时间：生效并按策略价格计费
+	clock.Advance(time.Second)
+	if !manager.RefreshActiveStrategy() {
+		t.Fatalf("Expected strategy to be activated at its start")
+	}
+	if active := manager.GetActiveStrategy(); active == nil || active.StrategyID != "sale" {
+		t.Fatalf("Expected sale to be active, got %v", active)
+	}
+	if cost, _ := manager.CalculatePrice("gpt-4", 1000, 1000); cost < 0.0449 || cost > 0.0451 {
+		t.Errorf("Expected strategy price 0.045, got %f", cost)
+	}
+	if manager.RefreshActiveStrategy() {
+		t.Errorf("Refreshing again should not change the active strategy")
+	}
+
+	// 到达结束时间（不含）：停用并恢复基础价格
+	clock.Advance(24 * time.Hour)
+	if cost, _ := manager.CalculatePrice("gpt-4", 1000, 1000); cost < 0.0899 || cost > 0.0901 {
+		t.Errorf("Expired strategy must not be applied even before the scheduler runs, got %f", cost)
+	}
+	if !manager.RefreshActiveStrategy() || manager.GetActiveStrategy() != nil {
+		t.Fatalf("Expected strategy to be deactivated at its end")
+	}
+
+	history := manager.GetPriceHistory("gpt-4")
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 price history records, got %d", len(history))
+	}
+	if history[0].NewPrice.InputPrice != 0.015 || history[0].Reason != "pricing strategy sale activated" {
+		t.Errorf("Unexpected activation record: %+v", history[0])
+	}
+	if history[1].NewPrice.InputPrice != 0.03 || history[1].Reason != "pricing strategy sale expired" {
+		t.Errorf("Unexpected expiry record: %+v", history[1])
+	}
+}
+
+func TestValidateStrategyRejectsOverlap(t *testing.T) {
+	start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{t: start}
+	manager := newStrategyManager(t, clock)
+
+	novEnd := start.AddDate(0, 1, 0)
+	if err := manager.CreateScheduledStrategy("november", "November", []string{"gpt-4", "gpt-3.5"}, start, &novEnd); err != nil {
+		t.Fatalf("CreateScheduledStrategy failed: %v", err)
+	}
+
+	// 与 november 在 gpt-4 上重叠
+	mid := start.AddDate(0, 0, 15)
+	err := manager.CreateScheduledStrategy("flash", "Flash", []string{"gpt-4"}, mid, nil)
+	if !errors.Is(err, ErrStrategyOverlap) {
+		t.Errorf("Expected ErrStrategyOverlap, got %v", err)
+	}
+
+	// 首尾相接不算重叠，不同模型也不算重叠
+	if err := manager.CreateScheduledStrategy("december", "December", []string{"gpt-4"}, novEnd, nil); err != nil {
+		t.Errorf("Adjacent windows should be allowed: %v", err)
+	}
+	if err := manager.CreateScheduledStrategy("claude", "Claude", []string{"claude-3"}, mid, nil); err != nil {
+		t.Errorf("Strategies for other models should be allowed: %v", err)
+	}
+
+	// 结束时间不晚于开始时间
+	if err := manager.CreateScheduledStrategy("empty", "Empty", []string{"gemini"}, start, &start); !errors.Is(err, ErrStrategyWindow) {
+		t.Errorf("Expected ErrStrategyWindow, got %v", err)
+	}
+
+	// 调整有效期时同样校验，失败时不修改
+	if err := manager.SetStrategyWindow("december", mid, nil); !errors.Is(err, ErrStrategyOverlap) {
+		t.Errorf("Expected ErrStrategyOverlap when moving december, got %v", err)
+	}
+	strategy, _ := manager.GetPricingStrategy("december")
+	if from, _ := strategy.Window(); !from.Equal(novEnd) {
+		t.Errorf("Window must not change after a rejected update, got %v", from)
+	}
+}
+
+func TestPreviewPrices(t *testing.T) {
+	start := time.Date(2026, 11, 11, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	manager := newStrategyManager(t, &fakeClock{t: start.AddDate(0, -1, 0)})
+
+	manager.CreateScheduledStrategy("sale", "Sale", []string{"gpt-4"}, start, &end)
+	manager.SetStrategyPrice("sale", "gpt-4", 0.015, 0.03, PricingByToken)
+
+	preview := manager.PreviewPrices(start.Add(time.Hour))
+	if preview.StrategyID != "sale" || preview.Prices["gpt-4"].InputPrice != 0.015 {
+		t.Errorf("Unexpected preview during the window: %+v", preview)
+	}
+
+	preview = manager.PreviewPrices(end)
+	if preview.StrategyID != "" || preview.Prices["gpt-4"].InputPrice != 0.03 {
+		t.Errorf("Unexpected preview after the window: %+v", preview)
+	}
+
+	// 预览不影响当前价格
+	if manager.GetActiveStrategy() != nil {
+		t.Errorf("Preview must not activate strategies")
+	}
+}
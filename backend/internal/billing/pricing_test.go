@@ -1,6 +1,7 @@
 package billing
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -101,6 +102,39 @@ func TestPriceGroup(t *testing.T) {
 	}
 }
 
+func TestPriceGroupValidation(t *testing.T) {
+	manager := NewPricingManager()
+	for _, multiplier := range []float64{0, -1} {
+		if err := manager.CreatePriceGroup("vip", "VIP", nil, multiplier); !errors.Is(err, ErrInvalidMultiplier) {
+			t.Errorf("Expected ErrInvalidMultiplier for %f, got %v", multiplier, err)
+		}
+	}
+
+	if err := manager.CreatePriceGroup("vip", "VIP", nil, 0.8); err != nil {
+		t.Fatalf("CreatePriceGroup failed: %v", err)
+	}
+	if err := manager.CreatePriceGroup("vip", "VIP", nil, 0.8); !errors.Is(err, ErrPriceGroupExists) {
+		t.Errorf("Expected ErrPriceGroupExists, got %v", err)
+	}
+	if err := manager.UpdatePriceGroup("vip", "VIP", []string{"gpt-4"}, -0.5); !errors.Is(err, ErrInvalidMultiplier) {
+		t.Errorf("Expected ErrInvalidMultiplier on update, got %v", err)
+	}
+	if err := manager.UpdatePriceGroup("vip", "VIP", []string{"gpt-4"}, 0.6); err != nil {
+		t.Fatalf("UpdatePriceGroup failed: %v", err)
+	}
+	group, _ := manager.GetPriceGroup("vip")
+	if group.Multiplier != 0.6 || group.AppliesTo("gpt-3.5") {
+		t.Errorf("Unexpected group after update: %+v", group)
+	}
+
+	if err := manager.DeletePriceGroup("vip"); err != nil {
+		t.Fatalf("DeletePriceGroup failed: %v", err)
+	}
+	if err := manager.DeletePriceGroup("vip"); !errors.Is(err, ErrPriceGroupNotFound) {
+		t.Errorf("Expected ErrPriceGroupNotFound, got %v", err)
+	}
+}
+
 func TestPricingStrategy(t *testing.T) {
 	manager := NewPricingManager()
 	manager.RegisterModelPrice("gpt-4", 0.03, 0.06, PricingByToken)
@@ -1,7 +1,23 @@
 package billing
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultReservationTTL 预留的默认有效期，超时未结算的预留由清理协程释放
+const DefaultReservationTTL = 10 * time.Minute
+
+var (
+	// ErrInsufficientQuota 可用额度不足以完成预留
+	ErrInsufficientQuota = errors.New("insufficient quota")
+	// ErrReservationNotFound 预留不存在，已结算、已释放或已过期
+	ErrReservationNotFound = errors.New("reservation not found")
 )
 
 // UserQuota 用户配额结构
@@ -10,19 +26,60 @@ type UserQuota struct {
 	UsedQuota      float64
 	TotalQuota     float64
 	AvailableQuota float64
+	ReservedQuota  float64 // 已预留、尚未结算的额度
+}
+
+// Reservation 一笔尚未结算的预留
+type Reservation struct {
+	ID        string
+	UserID    string
+	Amount    float64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// ReservationStore 预留的持久化存储，进程重启后据此恢复尚未结算的预留
+type ReservationStore interface {
+	SaveReservation(ctx context.Context, r *Reservation) error
+	DeleteReservations(ctx context.Context, ids []string) error
+	ListReservations(ctx context.Context) ([]*Reservation, error)
 }
 
 // QuotaManager 配额管理器
 type QuotaManager struct {
-	mu     sync.RWMutex
-	quotas map[string]*UserQuota
+	mu           sync.RWMutex
+	quotas       map[string]*UserQuota
+	reservations map[string]*Reservation // 预留 ID -> 预留
+
+	store   ReservationStore // 为 nil 时预留只保存在内存中
+	ttl     time.Duration
+	now     func() time.Time
+	sweepMu sync.Mutex
+	stopCh  chan struct{}
+	doneCh  chan struct{}
 }
 
 // NewQuotaManager 创建配额管理器
 func NewQuotaManager() *QuotaManager {
 	return &QuotaManager{
-		quotas: make(map[string]*UserQuota),
+		quotas:       make(map[string]*UserQuota),
+		reservations: make(map[string]*Reservation),
+		ttl:          DefaultReservationTTL,
+		now:          time.Now,
+	}
+}
+
+// SetReservationStore 设置预留的持久化存储
+func (qm *QuotaManager) SetReservationStore(store ReservationStore) {
+	qm.store = store
+}
+
+// SetReservationTTL 设置预留的有效期，ttl <= 0 时使用默认值
+func (qm *QuotaManager) SetReservationTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultReservationTTL
 	}
+	qm.ttl = ttl
 }
 
 // GetQuota 获取用户配额
@@ -98,7 +155,7 @@ func (qm *QuotaManager) AddUsage(userID string, amount float64) {
 	q.mu.Unlock()
 }
 
-// PreDeduct 预扣费
+// PreDeduct 预扣费：直接计入已使用额度并返回剩余可用额度；扣除已预留部分后不足 amount 时不扣减，返回 ErrInsufficientQuota
 func (qm *QuotaManager) PreDeduct(userID, transactionID string, amount float64, reason string) (float64, error) {
 	qm.mu.Lock()
 	defer qm.mu.Unlock()
@@ -111,12 +168,12 @@ func (qm *QuotaManager) PreDeduct(userID, transactionID string, amount float64,
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	if q.AvailableQuota < amount {
-		return q.AvailableQuota, nil
+	if q.AvailableQuota-q.ReservedQuota < amount {
+		return q.AvailableQuota, ErrInsufficientQuota
 	}
-
-	// 这里不实际扣减，只是返回剩余量作为检查
-	return q.AvailableQuota - amount, nil
+	q.UsedQuota += amount
+	q.AvailableQuota = q.TotalQuota - q.UsedQuota
+	return q.AvailableQuota, nil
 }
 
 // Recharge 充值
@@ -137,8 +194,250 @@ func (qm *QuotaManager) Recharge(userID string, amount float64) error {
 	return nil
 }
 
+// SyncQuota 按外部数据源（如数据库中的 Token 额度）同步总额度与已使用额度，不影响未结算的预留
+func (qm *QuotaManager) SyncQuota(userID string, total, used float64) {
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+
+	if _, ok := qm.quotas[userID]; !ok {
+		qm.quotas[userID] = &UserQuota{}
+	}
+
+	q := qm.quotas[userID]
+	q.mu.Lock()
+	q.TotalQuota = total
+	q.UsedQuota = used
+	q.AvailableQuota = q.TotalQuota - q.UsedQuota
+	q.mu.Unlock()
+}
+
+// Reserve 为用户预留估算额度，返回预留 ID；可用额度扣除已预留部分后不足 estimated 或已耗尽时返回 ErrInsufficientQuota
+//
+// 预留通过 Confirm 按实际费用结算，或通过 Release 放弃；超过有效期未结算的预留由 SweepExpired 释放。
+// 设置了持久化存储时预留同时写入存储，写入失败只记录日志，不影响本次预留。
+func (qm *QuotaManager) Reserve(userID string, estimated float64) (string, error) {
+	return qm.ReserveFor(userID, estimated, 0)
+}
+
+// ReserveFor 与 Reserve 相同，但预留的有效期为 ttl（如批量任务需要覆盖整个任务的执行时间），ttl <= 0 时使用默认有效期
+func (qm *QuotaManager) ReserveFor(userID string, estimated float64, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		ttl = qm.ttl
+	}
+	r := &Reservation{ID: uuid.NewString(), UserID: userID, Amount: estimated, CreatedAt: qm.now()}
+	r.ExpiresAt = r.CreatedAt.Add(ttl)
+	if err := qm.reserve(r); err != nil {
+		return "", err
+	}
+
+	if qm.store != nil {
+		if err := qm.store.SaveReservation(context.Background(), r); err != nil {
+			defaultLogFunc("warn", fmt.Sprintf("Failed to persist reservation %s: %v", r.ID, err))
+		}
+	}
+	return r.ID, nil
+}
+
+// reserve 检查可用额度并登记预留
+func (qm *QuotaManager) reserve(r *Reservation) error {
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+
+	if _, exists := qm.reservations[r.ID]; exists {
+		return fmt.Errorf("reservation %s already exists", r.ID)
+	}
+	q := qm.userQuotaLocked(r.UserID)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	free := q.AvailableQuota - q.ReservedQuota
+	if free <= 0 || free < r.Amount {
+		return ErrInsufficientQuota
+	}
+	q.ReservedQuota += r.Amount
+	qm.reservations[r.ID] = r
+	return nil
+}
+
+// Confirm 按实际费用 actual 结算预留：释放预留并计入用量，多预留的部分即退回可用额度
+//
+// 实际费用超过预留时同样全额计入。预留不存在时返回 ErrReservationNotFound，不计入用量。
+func (qm *QuotaManager) Confirm(reservationID string, actual float64) error {
+	qm.mu.Lock()
+	r := qm.releaseLocked(reservationID)
+	if r != nil {
+		q := qm.userQuotaLocked(r.UserID)
+		q.mu.Lock()
+		q.UsedQuota += actual
+		q.AvailableQuota = q.TotalQuota - q.UsedQuota
+		q.mu.Unlock()
+	}
+	qm.mu.Unlock()
+
+	if r == nil {
+		return ErrReservationNotFound
+	}
+	qm.forget(r.ID)
+	return nil
+}
+
+// Release 放弃预留，返回释放的额度，预留不存在时返回 0
+func (qm *QuotaManager) Release(reservationID string) float64 {
+	qm.mu.Lock()
+	r := qm.releaseLocked(reservationID)
+	qm.mu.Unlock()
+
+	if r == nil {
+		return 0
+	}
+	qm.forget(r.ID)
+	return r.Amount
+}
+
+// releaseLocked 移除预留并退回预留额度，调用方需持有 qm.mu
+func (qm *QuotaManager) releaseLocked(reservationID string) *Reservation {
+	r, ok := qm.reservations[reservationID]
+	if !ok {
+		return nil
+	}
+	delete(qm.reservations, reservationID)
+
+	if q, ok := qm.quotas[r.UserID]; ok {
+		q.mu.Lock()
+		q.ReservedQuota -= r.Amount
+		if q.ReservedQuota < 0 {
+			q.ReservedQuota = 0
+		}
+		q.mu.Unlock()
+	}
+	return r
+}
+
+// forget 从持久化存储中删除已结算或已释放的预留
+func (qm *QuotaManager) forget(ids ...string) {
+	if qm.store == nil || len(ids) == 0 {
+		return
+	}
+	if err := qm.store.DeleteReservations(context.Background(), ids); err != nil {
+		defaultLogFunc("warn", fmt.Sprintf("Failed to delete %d persisted reservations: %v", len(ids), err))
+	}
+}
+
+// userQuotaLocked 获取用户配额，不存在时创建，调用方需持有 qm.mu 写锁
+func (qm *QuotaManager) userQuotaLocked(userID string) *UserQuota {
+	q, ok := qm.quotas[userID]
+	if !ok {
+		q = &UserQuota{}
+		qm.quotas[userID] = q
+	}
+	return q
+}
+
+// RestoreReservations 从持久化存储恢复尚未结算的预留，返回恢复的数量
+//
+// 恢复的预留继续占用额度，直到结算、释放或过期。应在处理请求前调用。
+func (qm *QuotaManager) RestoreReservations(ctx context.Context) (int, error) {
+	if qm.store == nil {
+		return 0, nil
+	}
+	reservations, err := qm.store.ListReservations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+	restored := 0
+	for _, r := range reservations {
+		if _, exists := qm.reservations[r.ID]; exists {
+			continue
+		}
+		q := qm.userQuotaLocked(r.UserID)
+		q.mu.Lock()
+		q.ReservedQuota += r.Amount
+		q.mu.Unlock()
+		qm.reservations[r.ID] = r
+		restored++
+	}
+	return restored, nil
+}
+
+// SweepExpired 释放已过有效期的预留（如请求中途进程退出后遗留的预留），返回释放的数量
+func (qm *QuotaManager) SweepExpired() int {
+	now := qm.now()
+
+	qm.mu.Lock()
+	var expired []string
+	for id, r := range qm.reservations {
+		if now.After(r.ExpiresAt) {
+			qm.releaseLocked(id)
+			expired = append(expired, id)
+		}
+	}
+	qm.mu.Unlock()
+
+	qm.forget(expired...)
+	return len(expired)
+}
+
+// StartSweeper 按 interval 定期释放过期的预留
+func (qm *QuotaManager) StartSweeper(interval time.Duration) {
+	qm.sweepMu.Lock()
+	defer qm.sweepMu.Unlock()
+	if qm.stopCh != nil {
+		return
+	}
+	qm.stopCh = make(chan struct{})
+	qm.doneCh = make(chan struct{})
+
+	go func(stopCh, doneCh chan struct{}) {
+		defer close(doneCh)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				if n := qm.SweepExpired(); n > 0 {
+					defaultLogFunc("info", fmt.Sprintf("Released %d expired quota reservations", n))
+				}
+			}
+		}
+	}(qm.stopCh, qm.doneCh)
+}
+
+// StopSweeper 停止清理协程
+func (qm *QuotaManager) StopSweeper() {
+	qm.sweepMu.Lock()
+	defer qm.sweepMu.Unlock()
+	if qm.stopCh == nil {
+		return
+	}
+	close(qm.stopCh)
+	<-qm.doneCh
+	qm.stopCh, qm.doneCh = nil, nil
+}
+
+// GetReserved 获取用户已预留、尚未结算的额度
+func (qm *QuotaManager) GetReserved(userID string) float64 {
+	qm.mu.RLock()
+	defer qm.mu.RUnlock()
+	if q, ok := qm.quotas[userID]; ok {
+		q.mu.RLock()
+		defer q.mu.RUnlock()
+		return q.ReservedQuota
+	}
+	return 0
+}
+
 // ConfirmDeduction 确认扣费
+//
+// transactionID 为预留 ID 时等同 Confirm，否则直接按 amount 计入用量。
 func (qm *QuotaManager) ConfirmDeduction(userID string, transactionID string, amount float64) error {
+	if err := qm.Confirm(transactionID, amount); !errors.Is(err, ErrReservationNotFound) {
+		return err
+	}
 	qm.AddUsage(userID, amount)
 	return nil
 }
@@ -1,7 +1,10 @@
 package billing
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -37,6 +40,16 @@ func (al AlertLevel) String() string {
 	}
 }
 
+// ParseAlertLevel 解析预警等级名称
+func ParseAlertLevel(s string) (AlertLevel, error) {
+	for _, level := range []AlertLevel{AlertLevelNormal, AlertLevelWarning, AlertLevelCritical, AlertLevelExhausted} {
+		if level.String() == s {
+			return level, nil
+		}
+	}
+	return AlertLevelNormal, fmt.Errorf("unknown alert level %q", s)
+}
+
 // AlertRule 预警规则
 type AlertRule struct {
 	// 规则 ID
@@ -148,6 +161,22 @@ type AlertManager struct {
 	callbacks   map[AlertLevel][]func(*QuotaAlert)
 	callbacksMu sync.RWMutex
 
+	// 按等级注册的通知渠道，按用户偏好投递并记录到 store
+	notifiers     map[AlertLevel][]AlertNotifier
+	store         AlertStore
+	notifyMu      sync.RWMutex
+	notifyBackoff time.Duration
+	notifyWg      sync.WaitGroup
+
+	// 重复预警抑制：同一用户同一等级在 cooldown 内只触发一次
+	cooldown    time.Duration
+	lastAlerted map[string]time.Time
+
+	// 用户没有预警规则时使用的默认阈值
+	defaultThresholds map[AlertLevel]float64
+
+	now func() time.Time
+
 	// 统计信息
 	alertCount   int64
 	handledCount int64
@@ -164,6 +193,11 @@ func NewAlertManager(quotaManager *QuotaManager) *AlertManager {
 		expiryPolicies: make(map[string]*QuotaExpiryPolicy),
 		quotaManager:   quotaManager,
 		callbacks:      make(map[AlertLevel][]func(*QuotaAlert)),
+		notifiers:      make(map[AlertLevel][]AlertNotifier),
+		notifyBackoff:  time.Second,
+		cooldown:       DefaultAlertCooldown,
+		lastAlerted:    make(map[string]time.Time),
+		now:            time.Now,
 		logFunc:        defaultLogFunc,
 	}
 }
@@ -228,6 +262,8 @@ func (am *AlertManager) DisableAlertRule(ruleID string) error {
 }
 
 // CheckQuotaUsage 检查配额使用情况并触发预警
+//
+// 用户没有预警规则时使用默认阈值；同一用户同一等级的预警在 cooldown 内只触发一次。
 func (am *AlertManager) CheckQuotaUsage(userID string) error {
 	quota, err := am.quotaManager.GetUserQuota(userID)
 	if err != nil {
@@ -240,50 +276,149 @@ func (am *AlertManager) CheckQuotaUsage(userID string) error {
 	available := quota.AvailableQuota
 	quota.mu.RUnlock()
 
+	if total <= 0 {
+		return nil
+	}
 	usageRate := (used / total) * 100.0
 
-	// 获取用户的预警规则
+	// 检查是否触发预警
+	for _, rule := range am.userRules(userID) {
+		if usageRate < rule.Threshold {
+			continue
+		}
+
+		now := am.now()
+		if am.suppressed(userID, rule.Level, now) {
+			am.logFunc("debug", fmt.Sprintf("Alert suppressed for user %s (level: %s, cooldown: %s)", userID, rule.Level.String(), am.cooldown))
+			continue
+		}
+
+		alert := &QuotaAlert{
+			AlertID:        fmt.Sprintf("alert-%s-%d", userID, now.UnixNano()),
+			UserID:         userID,
+			Level:          rule.Level,
+			UsageRate:      usageRate,
+			RemainingQuota: available,
+			UsedQuota:      used,
+			TotalQuota:     total,
+			TriggeredAt:    now,
+			Message:        fmt.Sprintf("Quota usage reached %.2f%%", usageRate),
+		}
+
+		am.emit(alert)
+
+		am.logFunc("warn", fmt.Sprintf("Alert triggered for user %s: usage %.2f%% (level: %s)", userID, usageRate, rule.Level.String()))
+	}
+
+	return nil
+}
+
+// RaiseAlert 触发与用量阈值无关的预警（如自动充值扣款失败），同一用户同一 kind 在 cooldown 内只触发一次，被抑制时返回 nil
+func (am *AlertManager) RaiseAlert(userID, kind string, level AlertLevel, message string) *QuotaAlert {
+	now := am.now()
+	if am.suppressedKey(fmt.Sprintf("%s:%d:%s", userID, level, kind), now) {
+		am.logFunc("debug", fmt.Sprintf("Alert %s suppressed for user %s", kind, userID))
+		return nil
+	}
+
+	alert := &QuotaAlert{
+		AlertID:     fmt.Sprintf("alert-%s-%d", userID, now.UnixNano()),
+		UserID:      userID,
+		Level:       level,
+		TriggeredAt: now,
+		Message:     message,
+	}
+	if quota, err := am.quotaManager.GetUserQuota(userID); err == nil {
+		quota.mu.RLock()
+		alert.UsedQuota = quota.UsedQuota
+		alert.TotalQuota = quota.TotalQuota
+		alert.RemainingQuota = quota.AvailableQuota
+		if quota.TotalQuota > 0 {
+			alert.UsageRate = quota.UsedQuota / quota.TotalQuota * 100.0
+		}
+		quota.mu.RUnlock()
+	}
+
+	am.emit(alert)
+	am.logFunc("warn", fmt.Sprintf("Alert %s triggered for user %s: %s", kind, userID, message))
+	return alert
+}
+
+// emit 记录预警并触发回调与通知
+func (am *AlertManager) emit(alert *QuotaAlert) {
+	am.alertsMu.Lock()
+	if am.userAlerts[alert.UserID] == nil {
+		am.userAlerts[alert.UserID] = make([]*QuotaAlert, 0)
+	}
+	am.userAlerts[alert.UserID] = append(am.userAlerts[alert.UserID], alert)
+	am.alertsMu.Unlock()
+
+	atomic.AddInt64(&am.alertCount, 1)
+
+	am.triggerCallbacks(alert)
+}
+
+// userRules 返回用户启用的预警规则，没有规则时按默认阈值生成
+func (am *AlertManager) userRules(userID string) []*AlertRule {
 	am.rulesMu.RLock()
+	defer am.rulesMu.RUnlock()
+
 	var userRules []*AlertRule
 	for _, rule := range am.rules {
-		if rule.UserID == userID && rule.Enabled {
+		if rule.UserID == userID {
 			userRules = append(userRules, rule)
 		}
 	}
-	am.rulesMu.RUnlock()
-
-	// 检查是否触发预警
-	for _, rule := range userRules {
-		if usageRate >= rule.Threshold {
-			alert := &QuotaAlert{
-				AlertID:        fmt.Sprintf("alert-%s-%d", userID, time.Now().UnixNano()),
-				UserID:         userID,
-				Level:          rule.Level,
-				UsageRate:      usageRate,
-				RemainingQuota: available,
-				UsedQuota:      used,
-				TotalQuota:     total,
-				TriggeredAt:    time.Now(),
-				Message:        fmt.Sprintf("Quota usage reached %.2f%%", usageRate),
+	if len(userRules) > 0 {
+		enabled := userRules[:0]
+		for _, rule := range userRules {
+			if rule.Enabled {
+				enabled = append(enabled, rule)
 			}
+		}
+		return enabled
+	}
 
-			// 记录警告
-			am.alertsMu.Lock()
-			if am.userAlerts[userID] == nil {
-				am.userAlerts[userID] = make([]*QuotaAlert, 0)
-			}
-			am.userAlerts[userID] = append(am.userAlerts[userID], alert)
-			am.alertsMu.Unlock()
+	for level, threshold := range am.defaultThresholds {
+		userRules = append(userRules, &AlertRule{UserID: userID, Threshold: threshold, Level: level, Enabled: true})
+	}
+	return userRules
+}
 
-			atomic.AddInt64(&am.alertCount, 1)
+// suppressed 判断预警是否在冷却期内，未被抑制时记录本次触发时间
+func (am *AlertManager) suppressed(userID string, level AlertLevel, now time.Time) bool {
+	return am.suppressedKey(fmt.Sprintf("%s:%d", userID, level), now)
+}
 
-			// 触发回调
-			am.triggerCallbacks(alert)
+func (am *AlertManager) suppressedKey(key string, now time.Time) bool {
+	am.alertsMu.Lock()
+	defer am.alertsMu.Unlock()
+
+	if last, ok := am.lastAlerted[key]; ok && am.cooldown > 0 && now.Sub(last) < am.cooldown {
+		return true
+	}
+	am.lastAlerted[key] = now
+	return false
+}
+
+// SetCooldown 设置重复预警的冷却时间，0 表示不抑制
+func (am *AlertManager) SetCooldown(cooldown time.Duration) {
+	am.alertsMu.Lock()
+	defer am.alertsMu.Unlock()
+	am.cooldown = cooldown
+}
 
-			am.logFunc("warn", fmt.Sprintf("Alert triggered for user %s: usage %.2f%% (level: %s)", userID, usageRate, rule.Level.String()))
+// SetDefaultThresholds 设置用户没有预警规则时使用的阈值（百分比），为空表示不使用默认规则
+func (am *AlertManager) SetDefaultThresholds(thresholds map[AlertLevel]float64) error {
+	for level, threshold := range thresholds {
+		if threshold < 0 || threshold > 100 {
+			return fmt.Errorf("threshold for %s must be between 0 and 100, got %.2f", level.String(), threshold)
 		}
 	}
 
+	am.rulesMu.Lock()
+	defer am.rulesMu.Unlock()
+	am.defaultThresholds = thresholds
 	return nil
 }
 
@@ -299,18 +434,120 @@ func (am *AlertManager) RegisterCallback(level AlertLevel, callback func(*QuotaA
 	am.callbacks[level] = append(am.callbacks[level], callback)
 }
 
-// triggerCallbacks 触发预警回调
+// triggerCallbacks 触发预警回调并异步投递通知
 func (am *AlertManager) triggerCallbacks(alert *QuotaAlert) {
 	am.callbacksMu.RLock()
-	callbacks, ok := am.callbacks[alert.Level]
+	callbacks := am.callbacks[alert.Level]
 	am.callbacksMu.RUnlock()
 
-	if !ok {
+	for _, callback := range callbacks {
+		go callback(alert)
+	}
+
+	am.notifyMu.RLock()
+	store := am.store
+	am.notifyMu.RUnlock()
+	if store != nil {
+		am.notifyWg.Add(1)
+		go am.notify(store, alert)
+	}
+}
+
+// RegisterNotifier 为预警等级注册通知渠道
+func (am *AlertManager) RegisterNotifier(level AlertLevel, notifier AlertNotifier) {
+	am.notifyMu.Lock()
+	defer am.notifyMu.Unlock()
+	am.notifiers[level] = append(am.notifiers[level], notifier)
+}
+
+// SetAlertStore 设置通知偏好与投递记录的存储，未设置时只触发进程内回调
+func (am *AlertManager) SetAlertStore(store AlertStore) {
+	am.notifyMu.Lock()
+	defer am.notifyMu.Unlock()
+	am.store = store
+}
+
+// WaitNotifications 等待进行中的通知投递完成
+func (am *AlertManager) WaitNotifications() {
+	am.notifyWg.Wait()
+}
+
+// notify 保存预警，并按用户偏好调用该等级注册的通知渠道
+func (am *AlertManager) notify(store AlertStore, alert *QuotaAlert) {
+	defer am.notifyWg.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), alertNotifyTimeout)
+	defer cancel()
+
+	if err := store.RecordAlert(ctx, alert); err != nil {
+		am.logFunc("error", fmt.Sprintf("Failed to record alert %s: %v", alert.AlertID, err))
 		return
 	}
 
-	for _, callback := range callbacks {
-		go callback(alert)
+	am.notifyMu.RLock()
+	notifiers := am.notifiers[alert.Level]
+	am.notifyMu.RUnlock()
+	if len(notifiers) == 0 {
+		return
+	}
+
+	prefs, err := store.GetAlertPreferences(ctx, alert.UserID)
+	if err != nil {
+		am.logFunc("error", fmt.Sprintf("Failed to load alert preferences of user %s: %v", alert.UserID, err))
+		return
+	}
+
+	for _, pref := range prefs {
+		if !pref.Enabled || alert.Level < pref.MinLevel {
+			continue
+		}
+		for _, notifier := range notifiers {
+			if notifier.Channel() == pref.Channel {
+				am.deliver(store, notifier, alert, pref)
+			}
+		}
+	}
+}
+
+// deliver 投递到单个渠道，失败时按指数退避重试，并记录最终结果
+func (am *AlertManager) deliver(store AlertStore, notifier AlertNotifier, alert *QuotaAlert, pref *AlertPreference) {
+	delivery := &AlertDelivery{
+		AlertID:   alert.AlertID,
+		UserID:    alert.UserID,
+		Channel:   pref.Channel,
+		Target:    pref.Target,
+		CreatedAt: am.now(),
+	}
+
+	backoff := am.notifyBackoff
+	for attempt := 1; attempt <= alertNotifyAttempts; attempt++ {
+		delivery.Attempts = attempt
+
+		ctx, cancel := context.WithTimeout(context.Background(), alertNotifyTimeout)
+		err := notifier.Notify(ctx, alert, pref)
+		cancel()
+
+		if err == nil {
+			deliveredAt := am.now()
+			delivery.Status = AlertDeliverySent
+			delivery.Error = ""
+			delivery.DeliveredAt = &deliveredAt
+			break
+		}
+
+		delivery.Status = AlertDeliveryFailed
+		delivery.Error = err.Error()
+		am.logFunc("warn", fmt.Sprintf("Failed to deliver alert %s via %s (attempt %d): %v", alert.AlertID, pref.Channel, attempt, err))
+		if attempt < alertNotifyAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), alertNotifyTimeout)
+	defer cancel()
+	if err := store.RecordDelivery(ctx, delivery); err != nil {
+		am.logFunc("error", fmt.Sprintf("Failed to record delivery of alert %s: %v", alert.AlertID, err))
 	}
 }
 
@@ -410,6 +647,21 @@ type AutoRechargeManager struct {
 	// 配额管理器
 	quotaManager *QuotaManager
 
+	// 支付渠道与持久化存储，未设置时不允许自动充值
+	payments PaymentClient
+	store    AutoRechargeStore
+	currency string
+
+	// 额度入账，默认为 quotaManager.Recharge
+	credit func(userID string, amount float64) error
+
+	// 扣款失败时发出预警，为 nil 时只记录日志
+	alertManager *AlertManager
+
+	// 进行中的充值，同一用户同时只处理一次
+	inflight   map[string]bool
+	inflightMu sync.Mutex
+
 	// 统计信息
 	rechargeCount int64
 
@@ -455,11 +707,32 @@ type RechargeRecord struct {
 	// 充值金额
 	Amount float64
 
+	// 扣款金额（最小货币单位）
+	AmountCents int64
+
+	// 货币
+	Currency string
+
+	// 状态：pending、charged、completed、declined、refunded
+	Status string
+
+	// 幂等键，重试同一次充值时复用，避免重复扣款
+	IdempotencyKey string
+
+	// 支付 ID
+	PaymentIntentID string
+
+	// 最后一次失败的原因
+	Error string
+
 	// 触发原因
 	Reason string
 
 	// 充值时间
 	CreatedAt time.Time
+
+	// 更新时间
+	UpdatedAt time.Time
 }
 
 // NewAutoRechargeManager 创建自动充值管理器
@@ -468,10 +741,24 @@ func NewAutoRechargeManager(quotaManager *QuotaManager) *AutoRechargeManager {
 		configs:      make(map[string]*AutoRechargeConfig),
 		history:      make(map[string][]*RechargeRecord),
 		quotaManager: quotaManager,
+		credit:       quotaManager.Recharge,
+		inflight:     make(map[string]bool),
 		logFunc:      defaultLogFunc,
 	}
 }
 
+// SetPayment 设置支付渠道、存储与扣款货币，需在 CheckAndRecharge 之前调用
+func (arm *AutoRechargeManager) SetPayment(payments PaymentClient, store AutoRechargeStore, currency string) {
+	arm.payments = payments
+	arm.store = store
+	arm.currency = currency
+}
+
+// SetAlertManager 设置扣款失败时发出预警的预警管理器
+func (arm *AutoRechargeManager) SetAlertManager(am *AlertManager) {
+	arm.alertManager = am
+}
+
 // CreateAutoRechargeConfig 创建自动充值配置
 func (arm *AutoRechargeManager) CreateAutoRechargeConfig(userID string, triggerThreshold, rechargeAmount float64, maxRechargePerPeriod, periodDays int) error {
 	arm.configsMu.Lock()
@@ -498,6 +785,10 @@ func (arm *AutoRechargeManager) CreateAutoRechargeConfig(userID string, triggerT
 }
 
 // CheckAndRecharge 检查并执行自动充值
+//
+// 使用保存的支付方式离线扣款 RechargeAmount（额度以分计，按 1:1 换算为扣款金额），扣款成功后才入账额度；
+// 入账失败时退款补偿。扣款被拒绝时暂停该用户的自动充值并发出预警。结果未知的扣款保留为 pending，
+// 下次检查以相同的幂等键重试，不会重复扣款。
 func (arm *AutoRechargeManager) CheckAndRecharge(userID string) error {
 	arm.configsMu.RLock()
 	config, ok := arm.configs[userID]
@@ -517,61 +808,186 @@ func (arm *AutoRechargeManager) CheckAndRecharge(userID string) error {
 	total := quota.TotalQuota
 	quota.mu.RUnlock()
 
+	if total <= 0 {
+		return nil
+	}
 	usageRate := (used / total) * 100.0
+	if usageRate < config.TriggerThreshold {
+		return nil
+	}
 
-	if usageRate >= config.TriggerThreshold {
-		// 检查周期内的充值次数
-		arm.historyMu.RLock()
-		records := arm.history[userID]
-		arm.historyMu.RUnlock()
+	if arm.payments == nil || arm.store == nil {
+		return ErrPaymentNotConfigured
+	}
 
-		// 统计最近周期内的充值次数
-		rechargeCount := 0
-		cutoffTime := time.Now().AddDate(0, 0, -config.PeriodDays)
+	if !arm.acquire(userID) {
+		return ErrRechargeInProgress
+	}
+	defer arm.release(userID)
 
-		for _, record := range records {
-			if record.CreatedAt.After(cutoffTime) {
-				rechargeCount++
-			}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	record, err := arm.store.PendingRecharge(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		// 检查周期内的充值次数
+		count, err := arm.store.CountRecharges(ctx, userID, time.Now().AddDate(0, 0, -config.PeriodDays))
+		if err != nil {
+			return err
+		}
+		if count >= config.MaxRechargePerPeriod {
+			arm.logFunc("warn", fmt.Sprintf("Auto-recharge limit reached for user %s", userID))
+			return fmt.Errorf("auto-recharge limit reached for this period")
 		}
+	}
 
-		if rechargeCount < config.MaxRechargePerPeriod {
-			// 执行充值
-			err := arm.quotaManager.Recharge(userID, config.RechargeAmount)
-			if err != nil {
-				return err
-			}
+	method, err := arm.store.GetPaymentMethod(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if method == nil {
+		return ErrNoPaymentMethod
+	}
+	if method.Suspended {
+		return ErrAutoRechargeSuspended
+	}
 
-			// 记录充值
-			record := &RechargeRecord{
-				RecordID:  fmt.Sprintf("recharge-%s-%d", userID, time.Now().UnixNano()),
-				UserID:    userID,
-				Amount:    config.RechargeAmount,
-				Reason:    fmt.Sprintf("Auto-recharge triggered at usage %.2f%%", usageRate),
-				CreatedAt: time.Now(),
-			}
+	if record == nil {
+		now := time.Now()
+		recordID := fmt.Sprintf("recharge-%s-%d", userID, now.UnixNano())
+		record = &RechargeRecord{
+			RecordID:       recordID,
+			UserID:         userID,
+			Amount:         config.RechargeAmount,
+			AmountCents:    int64(math.Round(config.RechargeAmount)),
+			Currency:       arm.currency,
+			Status:         RechargePending,
+			IdempotencyKey: "auto-recharge-" + recordID,
+			Reason:         fmt.Sprintf("Auto-recharge triggered at usage %.2f%%", usageRate),
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		// 先落库再扣款，扣款后崩溃也能以相同幂等键恢复
+		if err := arm.store.SaveRecharge(ctx, record); err != nil {
+			return err
+		}
+	}
 
-			arm.historyMu.Lock()
-			if arm.history[userID] == nil {
-				arm.history[userID] = make([]*RechargeRecord, 0)
-			}
-			arm.history[userID] = append(arm.history[userID], record)
-			arm.historyMu.Unlock()
+	return arm.charge(ctx, method, record)
+}
 
-			atomic.AddInt64(&arm.rechargeCount, 1)
+// charge 扣款并入账，入账失败时退款
+func (arm *AutoRechargeManager) charge(ctx context.Context, method *PaymentMethod, record *RechargeRecord) error {
+	result, err := arm.payments.ChargeOffSession(ctx, &ChargeRequest{
+		UserID:          record.UserID,
+		CustomerID:      method.CustomerID,
+		PaymentMethodID: method.PaymentMethodID,
+		AmountCents:     record.AmountCents,
+		Currency:        record.Currency,
+		IdempotencyKey:  record.IdempotencyKey,
+		Description:     record.Reason,
+	})
+	if errors.Is(err, ErrPaymentDeclined) {
+		arm.saveRecord(ctx, record, RechargeDeclined, err)
+		if err := arm.store.SuspendAutoRecharge(ctx, record.UserID, err.Error()); err != nil {
+			arm.logFunc("error", fmt.Sprintf("Failed to suspend auto-recharge for user %s: %v", record.UserID, err))
+		}
+		if arm.alertManager != nil {
+			arm.alertManager.RaiseAlert(record.UserID, "auto_recharge_declined", AlertLevelCritical,
+				fmt.Sprintf("Auto-recharge payment declined, auto-recharge suspended until the payment method is updated: %v", err))
+		}
+		arm.logFunc("warn", fmt.Sprintf("Auto-recharge for user %s declined: %v", record.UserID, err))
+		return err
+	}
+	if err != nil {
+		// 结果未知，保持 pending，下次以相同幂等键重试
+		arm.saveRecord(ctx, record, RechargePending, err)
+		return err
+	}
 
-			arm.logFunc("info", fmt.Sprintf("Auto-recharged user %s with %.2f (usage: %.2f%%)", userID, config.RechargeAmount, usageRate))
+	record.PaymentIntentID = result.PaymentIntentID
+	if err := arm.saveRecord(ctx, record, RechargeCharged, nil); err != nil {
+		return err
+	}
 
-			return nil
+	if err := arm.credit(record.UserID, record.Amount); err != nil {
+		// 补偿：退回已扣的款项
+		if refundErr := arm.payments.Refund(ctx, record.PaymentIntentID, "refund-"+record.IdempotencyKey); refundErr != nil {
+			arm.logFunc("error", fmt.Sprintf("Failed to refund auto-recharge %s after credit failure: %v", record.RecordID, refundErr))
+			arm.saveRecord(ctx, record, RechargeCharged, fmt.Errorf("credit failed: %v; refund failed: %v", err, refundErr))
+			return err
 		}
+		arm.saveRecord(ctx, record, RechargeRefunded, err)
+		return err
+	}
+
+	if err := arm.saveRecord(ctx, record, RechargeCompleted, nil); err != nil {
+		arm.logFunc("error", fmt.Sprintf("Auto-recharge %s credited but not marked completed: %v", record.RecordID, err))
+	}
 
-		arm.logFunc("warn", fmt.Sprintf("Auto-recharge limit reached for user %s", userID))
-		return fmt.Errorf("auto-recharge limit reached for this period")
+	arm.historyMu.Lock()
+	if arm.history[record.UserID] == nil {
+		arm.history[record.UserID] = make([]*RechargeRecord, 0)
 	}
+	arm.history[record.UserID] = append(arm.history[record.UserID], record)
+	arm.historyMu.Unlock()
+
+	atomic.AddInt64(&arm.rechargeCount, 1)
+
+	arm.logFunc("info", fmt.Sprintf("Auto-recharged user %s with %.2f (payment: %s)", record.UserID, record.Amount, record.PaymentIntentID))
 
 	return nil
 }
 
+// saveRecord 更新充值记录的状态
+func (arm *AutoRechargeManager) saveRecord(ctx context.Context, record *RechargeRecord, status string, cause error) error {
+	record.Status = status
+	record.Error = ""
+	if cause != nil {
+		record.Error = cause.Error()
+	}
+	record.UpdatedAt = time.Now()
+	if err := arm.store.SaveRecharge(ctx, record); err != nil {
+		arm.logFunc("error", fmt.Sprintf("Failed to save auto-recharge %s (%s): %v", record.RecordID, status, err))
+		return err
+	}
+	return nil
+}
+
+func (arm *AutoRechargeManager) acquire(userID string) bool {
+	arm.inflightMu.Lock()
+	defer arm.inflightMu.Unlock()
+	if arm.inflight[userID] {
+		return false
+	}
+	arm.inflight[userID] = true
+	return true
+}
+
+func (arm *AutoRechargeManager) release(userID string) {
+	arm.inflightMu.Lock()
+	defer arm.inflightMu.Unlock()
+	delete(arm.inflight, userID)
+}
+
+// HasConfig 判断用户是否启用了自动充值
+func (arm *AutoRechargeManager) HasConfig(userID string) bool {
+	arm.configsMu.RLock()
+	defer arm.configsMu.RUnlock()
+	config, ok := arm.configs[userID]
+	return ok && config.Enabled
+}
+
+// RemoveAutoRechargeConfig 删除用户的自动充值配置
+func (arm *AutoRechargeManager) RemoveAutoRechargeConfig(userID string) {
+	arm.configsMu.Lock()
+	defer arm.configsMu.Unlock()
+	delete(arm.configs, userID)
+}
+
 // GetRechargeHistory 获取充值历史
 func (arm *AutoRechargeManager) GetRechargeHistory(userID string) []*RechargeRecord {
 	arm.historyMu.RLock()
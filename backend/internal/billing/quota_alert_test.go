@@ -133,6 +133,7 @@ func TestAutoRecharge(t *testing.T) {
 	quotaManager.CreateUserQuota("user-1", 100.0)
 
 	autoRechargeManager := NewAutoRechargeManager(quotaManager)
+	usePaymentMethod(autoRechargeManager, "user-1")
 	autoRechargeManager.CreateAutoRechargeConfig("user-1", 50.0, 50.0, 5, 7)
 
 	// 模拟使用 60% 配额
@@ -214,6 +215,7 @@ func TestRechargeHistoryTracking(t *testing.T) {
 	quotaManager.CreateUserQuota("user-1", 100.0)
 
 	autoRechargeManager := NewAutoRechargeManager(quotaManager)
+	usePaymentMethod(autoRechargeManager, "user-1")
 	autoRechargeManager.CreateAutoRechargeConfig("user-1", 50.0, 50.0, 5, 7)
 
 	// 执行多次充值
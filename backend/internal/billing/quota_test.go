@@ -0,0 +1,255 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQuotaReserveConcurrent(t *testing.T) {
+	qm := NewQuotaManager()
+	qm.SetQuota("token:1", 100)
+
+	var mu sync.Mutex
+	succeeded := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := qm.Reserve("token:1", 30)
+			if err != nil && !errors.Is(err, ErrInsufficientQuota) {
+				t.Errorf("Reserve failed: %v", err)
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 3 {
+		t.Errorf("Expected 3 reservations, got %d", succeeded)
+	}
+	if reserved := qm.GetReserved("token:1"); reserved != 90 {
+		t.Errorf("Expected 90 reserved, got %f", reserved)
+	}
+}
+
+func TestQuotaConfirmReservation(t *testing.T) {
+	qm := NewQuotaManager()
+	qm.SetQuota("token:1", 100)
+
+	id, err := qm.Reserve("token:1", 60)
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if _, err := qm.Reserve("token:1", 50); !errors.Is(err, ErrInsufficientQuota) {
+		t.Errorf("Expected ErrInsufficientQuota, got %v", err)
+	}
+
+	// 实际费用低于预留，差额退回
+	if err := qm.Confirm(id, 25); err != nil {
+		t.Fatalf("Confirm failed: %v", err)
+	}
+	if qm.GetUsage("token:1") != 25 || qm.GetReserved("token:1") != 0 {
+		t.Errorf("Expected usage 25 and nothing reserved, got %f / %f", qm.GetUsage("token:1"), qm.GetReserved("token:1"))
+	}
+	if err := qm.Confirm(id, 25); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("Expected second confirm to fail with ErrReservationNotFound, got %v", err)
+	}
+	if qm.GetUsage("token:1") != 25 {
+		t.Errorf("Second confirm should not charge, got usage %f", qm.GetUsage("token:1"))
+	}
+
+	id, err = qm.Reserve("token:1", 50)
+	if err != nil {
+		t.Errorf("Refunded quota should be reservable: %v", err)
+	}
+	if released := qm.Release(id); released != 50 {
+		t.Errorf("Expected 50 released, got %f", released)
+	}
+	if released := qm.Release(id); released != 0 {
+		t.Errorf("Expected second release to be a no-op, got %f", released)
+	}
+}
+
+func TestQuotaReserveExhausted(t *testing.T) {
+	qm := NewQuotaManager()
+	qm.SyncQuota("token:1", 100, 100)
+
+	// 额度耗尽时即使估算费用为 0 也拒绝
+	if _, err := qm.Reserve("token:1", 0); !errors.Is(err, ErrInsufficientQuota) {
+		t.Errorf("Expected ErrInsufficientQuota, got %v", err)
+	}
+}
+
+func TestQuotaReserveConfirmRace(t *testing.T) {
+	qm := NewQuotaManager()
+	qm.SetQuota("token:1", 1000)
+
+	// 每个请求预留 30、实际花费 20，并发结算后用量与剩余预留必须一致
+	var mu sync.Mutex
+	confirmed, released := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id, err := qm.Reserve("token:1", 30)
+			if errors.Is(err, ErrInsufficientQuota) {
+				return
+			}
+			if err != nil {
+				t.Errorf("Reserve failed: %v", err)
+				return
+			}
+			if reserved, used := qm.GetReserved("token:1"), qm.GetUsage("token:1"); reserved+used > 1000+30 {
+				t.Errorf("Over-reserved: reserved %f, used %f", reserved, used)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if i%5 == 0 {
+				qm.Release(id)
+				released++
+				return
+			}
+			if err := qm.Confirm(id, 20); err != nil {
+				t.Errorf("Confirm failed: %v", err)
+				return
+			}
+			confirmed++
+		}(i)
+	}
+	wg.Wait()
+
+	if qm.GetReserved("token:1") != 0 {
+		t.Errorf("Expected nothing reserved, got %f", qm.GetReserved("token:1"))
+	}
+	if usage := qm.GetUsage("token:1"); usage != float64(20*confirmed) || usage > 1000 {
+		t.Errorf("Expected usage %d, got %f", 20*confirmed, usage)
+	}
+	if confirmed+released == 0 {
+		t.Errorf("Expected some reservations to succeed")
+	}
+}
+
+// memoryReservationStore 内存中的预留存储
+type memoryReservationStore struct {
+	mu           sync.Mutex
+	reservations map[string]*Reservation
+}
+
+func newMemoryReservationStore() *memoryReservationStore {
+	return &memoryReservationStore{reservations: make(map[string]*Reservation)}
+}
+
+func (s *memoryReservationStore) SaveReservation(ctx context.Context, r *Reservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *r
+	s.reservations[r.ID] = &copied
+	return nil
+}
+
+func (s *memoryReservationStore) DeleteReservations(ctx context.Context, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.reservations, id)
+	}
+	return nil
+}
+
+func (s *memoryReservationStore) ListReservations(ctx context.Context) ([]*Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []*Reservation
+	for _, r := range s.reservations {
+		copied := *r
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+func (s *memoryReservationStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.reservations)
+}
+
+func TestQuotaReservationsSurviveRestart(t *testing.T) {
+	store := newMemoryReservationStore()
+	qm := NewQuotaManager()
+	qm.SetReservationStore(store)
+	qm.SetQuota("token:1", 100)
+
+	kept, err := qm.Reserve("token:1", 40)
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	settled, err := qm.Reserve("token:1", 30)
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if err := qm.Confirm(settled, 10); err != nil {
+		t.Fatalf("Confirm failed: %v", err)
+	}
+	if store.len() != 1 {
+		t.Fatalf("Expected 1 persisted reservation, got %d", store.len())
+	}
+
+	// 重启后恢复未结算的预留，继续占用额度
+	restarted := NewQuotaManager()
+	restarted.SetReservationStore(store)
+	if n, err := restarted.RestoreReservations(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Expected 1 restored reservation, got %d (%v)", n, err)
+	}
+	restarted.SyncQuota("token:1", 100, 10)
+	if restarted.GetReserved("token:1") != 40 {
+		t.Errorf("Expected 40 reserved after restart, got %f", restarted.GetReserved("token:1"))
+	}
+	if _, err := restarted.Reserve("token:1", 60); !errors.Is(err, ErrInsufficientQuota) {
+		t.Errorf("Restored reservation should hold quota, got %v", err)
+	}
+	if err := restarted.Confirm(kept, 35); err != nil {
+		t.Fatalf("Confirm of restored reservation failed: %v", err)
+	}
+	if restarted.GetUsage("token:1") != 45 || store.len() != 0 {
+		t.Errorf("Expected usage 45 and empty store, got %f / %d", restarted.GetUsage("token:1"), store.len())
+	}
+}
+
+func TestQuotaSweepExpiredReservations(t *testing.T) {
+	store := newMemoryReservationStore()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	qm := NewQuotaManager()
+	qm.now = func() time.Time { return now }
+	qm.SetReservationStore(store)
+	qm.SetReservationTTL(time.Minute)
+	qm.SetQuota("token:1", 100)
+
+	abandoned, _ := qm.Reserve("token:1", 50)
+	now = now.Add(45 * time.Second)
+	fresh, _ := qm.Reserve("token:1", 20)
+
+	now = now.Add(30 * time.Second)
+	if n := qm.SweepExpired(); n != 1 {
+		t.Errorf("Expected 1 expired reservation, got %d", n)
+	}
+	if qm.GetReserved("token:1") != 20 || store.len() != 1 {
+		t.Errorf("Expected 20 reserved and 1 persisted, got %f / %d", qm.GetReserved("token:1"), store.len())
+	}
+	if err := qm.Confirm(abandoned, 50); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("Expected swept reservation to be gone, got %v", err)
+	}
+	if err := qm.Confirm(fresh, 5); err != nil {
+		t.Errorf("Confirm failed: %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package billing
+
+import (
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/scaling"
+)
+
+// RegisterScalingSignals 导出计费事件队列的积压深度与最早事件等待时长
+//
+// 深度的硬上限为队列容量（超过即丢弃事件），等待时长的硬上限为 maxAge（0 表示不设上限）。
+func (beq *BillingEventQueue) RegisterScalingSignals(reg *scaling.Registry, maxAge time.Duration) {
+	labels := map[string]string{"queue": beq.QueueName}
+	reg.NewGaugeFunc(scaling.MetricBillingEventQueueDepth,
+		"Billing events waiting to be consumed.", labels, float64(beq.bufferSize),
+		func() float64 { return float64(beq.Size()) })
+	reg.NewGaugeFunc(scaling.MetricBillingEventQueueOldest,
+		"Age in seconds of the oldest billing event still waiting in the queue.", labels, maxAge.Seconds(),
+		func() float64 { return beq.OldestEventAge().Seconds() })
+}
@@ -0,0 +1,56 @@
+package billing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/scaling"
+)
+
+func TestBillingEventQueueScalingSignals(t *testing.T) {
+	q := NewBillingEventQueue("billing", 3)
+	reg := scaling.NewRegistry()
+	q.RegisterScalingSignals(reg, time.Minute)
+
+	signals := func() map[string]scaling.Hint {
+		hints := make(map[string]scaling.Hint)
+		for _, h := range reg.Hints() {
+			hints[h.Name] = h
+		}
+		return hints
+	}
+
+	if age := q.OldestEventAge(); age != 0 {
+		t.Fatalf("expected zero age for an empty queue, got %v", age)
+	}
+
+	q.Enqueue(&BillingEvent{EventID: "e1"})
+	time.Sleep(30 * time.Millisecond)
+	q.Enqueue(&BillingEvent{EventID: "e2"})
+
+	hints := signals()
+	depth := hints[scaling.MetricBillingEventQueueDepth]
+	if depth.Value != 2 || depth.HardLimit != 3 || depth.Labels["queue"] != "billing" {
+		t.Errorf("unexpected depth signal %+v", depth)
+	}
+	oldest := hints[scaling.MetricBillingEventQueueOldest]
+	if oldest.Value < 0.03 || oldest.Value > 1 || oldest.HardLimit != 60 {
+		t.Errorf("unexpected oldest-age signal %+v", oldest)
+	}
+
+	// 取出最早的事件后，最早等待时长变为第二个事件的等待时长
+	event, err := q.Dequeue(context.Background())
+	if err != nil || event.EventID != "e1" {
+		t.Fatalf("expected e1, got %v %v", event, err)
+	}
+	if age := q.OldestEventAge(); age >= 30*time.Millisecond {
+		t.Errorf("expected age of e2 to be below 30ms, got %v", age)
+	}
+
+	q.Dequeue(context.Background())
+	hints = signals()
+	if hints[scaling.MetricBillingEventQueueDepth].Value != 0 || hints[scaling.MetricBillingEventQueueOldest].Value != 0 {
+		t.Errorf("expected drained queue signals, got %+v", hints)
+	}
+}
@@ -289,10 +289,10 @@ func TestCacheInvalidator(t *testing.T) {
 	client.Set(ctx, "cache_key_1", "value1", 1*time.Hour)
 	client.Set(ctx, "cache_key_2", "value2", 1*time.Hour)
 
-	// 订阅失效事件
-	callCount := 0
+	// 订阅失效事件（回调异步执行）
+	called := make(chan struct{}, 2)
 	invalidator.Subscribe("cache_key_*", func() {
-		callCount++
+		called <- struct{}{}
 	})
 
 	// 失效缓存
@@ -301,8 +301,16 @@ func TestCacheInvalidator(t *testing.T) {
 		t.Fatalf("Invalidate failed: %v", err)
 	}
 
-	if callCount != 1 {
-		t.Errorf("Expected callback to be called once, got %d times", callCount)
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("Expected callback to be called")
+	}
+
+	select {
+	case <-called:
+		t.Error("Expected callback to be called once")
+	case <-time.After(50 * time.Millisecond):
 	}
 }
 
@@ -30,7 +30,7 @@ type CacheManager struct {
 	l1Cache sync.Map // key: string, value: *cacheEntry
 
 	// L2 缓存：Redis
-	l2Cache *redis.Client
+	l2Cache redis.UniversalClient
 
 	// 单次飞行控制（防止缓存击穿）
 	singleflight singleflight.Group
@@ -54,7 +54,7 @@ type cacheEntry struct {
 }
 
 // NewCacheManager 创建新的缓存管理器
-func NewCacheManager(redisClient *redis.Client, l1TTL, l2TTL time.Duration) *CacheManager {
+func NewCacheManager(redisClient redis.UniversalClient, l1TTL, l2TTL time.Duration) *CacheManager {
 	return &CacheManager{
 		l2Cache:     redisClient,
 		l1TTL:       l1TTL,
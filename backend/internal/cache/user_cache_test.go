@@ -206,7 +206,7 @@ func TestBloomFilter_Stats(t *testing.T) {
 
 	assert.NotNil(t, stats)
 	assert.Greater(t, stats["set_bits"].(int), 0)
-	assert.Greater(t, stats["utilization"].(float64), 0)
+	assert.Greater(t, stats["utilization"].(float64), 0.0)
 	assert.Less(t, stats["utilization"].(float64), 1.0)
 }
 
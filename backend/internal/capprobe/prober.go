@@ -0,0 +1,436 @@
+package capprobe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/adapter"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// Capability 探测的能力
+type Capability string
+
+const (
+	CapabilityJSONMode Capability = "json_mode"
+	CapabilityTools    Capability = "tools"
+	CapabilityVision   Capability = "vision"
+	CapabilityStream   Capability = "stream"
+)
+
+// Capabilities 按探测顺序排列的全部能力
+var Capabilities = []Capability{CapabilityJSONMode, CapabilityTools, CapabilityVision, CapabilityStream}
+
+// Outcome 单项能力的探测结果
+type Outcome string
+
+const (
+	// OutcomePass 上游接受参数且输出符合预期
+	OutcomePass Outcome = "pass"
+	// OutcomeFail 上游接受参数但未生效（如忽略 response_format 返回普通文本）
+	OutcomeFail Outcome = "fail"
+	// OutcomeUnsupported 上游以 4xx 拒绝该参数
+	OutcomeUnsupported Outcome = "unsupported"
+	// OutcomeError 探测请求本身失败（鉴权、限流、超时、5xx），不能说明能力是否支持
+	OutcomeError Outcome = "error"
+)
+
+// maxEvidence 记录的上游响应最大长度
+const maxEvidence = 2048
+
+// ErrUnsupportedChannel 渠道不是 OpenAI 兼容接口，无法用统一的请求探测
+var ErrUnsupportedChannel = errors.New("capability probing requires an OpenAI-compatible channel")
+
+// redPixelPNG 1x1 红色像素，用于图片输入探测
+const redPixelPNG = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAIAAACQd1PeAAAADElEQVR4nGP4z8AAAAMBAQDJ/pLvAAAAAElFTkSuQmCC"
+
+// Result 单项能力的探测结果与原始证据
+type Result struct {
+	Capability Capability     `json:"capability"`
+	Outcome    Outcome        `json:"outcome"`
+	Detail     string         `json:"detail"`
+	StatusCode int            `json:"status_code"`
+	Evidence   string         `json:"evidence"`
+	LatencyMs  int            `json:"latency_ms"`
+	Usage      *adapter.Usage `json:"usage,omitempty"`
+}
+
+// Target 探测目标
+type Target struct {
+	BaseURL string // 包含 /v1
+	APIKey  string
+	Model   string
+}
+
+// TargetFromChannel 从渠道构建探测目标
+func TargetFromChannel(ch *model.Channel, modelName string) (*Target, error) {
+	if !adapter.ParseProviderType(ch.Type).IsOpenAICompatible() {
+		return nil, ErrUnsupportedChannel
+	}
+	key, _ := ch.GetNextEnabledKey()
+	return &Target{
+		BaseURL: strings.TrimRight(ch.BaseURL, "/"),
+		APIKey:  key,
+		Model:   modelName,
+	}, nil
+}
+
+// Prober 向渠道发送一组低成本的测试请求，判断上游是否真正支持 JSON 模式、工具调用、图片输入与流式输出
+//
+// 请求直接按 OpenAI 格式发送，不经过适配器，避免适配器丢弃的参数被误判为上游不支持。
+type Prober struct {
+	client *http.Client
+}
+
+// NewProber 创建探测器，client 为 nil 时使用 30 秒超时的默认客户端
+func NewProber(client *http.Client) *Prober {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Prober{client: client}
+}
+
+// Probe 依次探测全部能力
+func (p *Prober) Probe(ctx context.Context, target *Target) []*Result {
+	results := make([]*Result, 0, len(Capabilities))
+	for _, capability := range Capabilities {
+		results = append(results, p.ProbeCapability(ctx, target, capability))
+	}
+	return results
+}
+
+// ProbeCapability 探测单项能力
+func (p *Prober) ProbeCapability(ctx context.Context, target *Target, capability Capability) *Result {
+	switch capability {
+	case CapabilityJSONMode:
+		return p.probeJSONMode(ctx, target)
+	case CapabilityTools:
+		return p.probeTools(ctx, target)
+	case CapabilityVision:
+		return p.probeVision(ctx, target)
+	case CapabilityStream:
+		return p.probeStream(ctx, target)
+	default:
+		return &Result{Capability: capability, Outcome: OutcomeError, Detail: "unknown capability"}
+	}
+}
+
+// probeJSONMode response_format=json_object 时输出必须是可解析的 JSON 对象
+func (p *Prober) probeJSONMode(ctx context.Context, target *Target) *Result {
+	body := map[string]interface{}{
+		"model": target.Model,
+		"messages": []map[string]interface{}{
+			{"role": "system", "content": "You reply with JSON only."},
+			{"role": "user", "content": `Return the JSON object {"ok": true}.`},
+		},
+		"response_format": map[string]string{"type": "json_object"},
+		"max_tokens":      32,
+		"temperature":     0,
+	}
+
+	result, message := p.complete(ctx, target, CapabilityJSONMode, body)
+	if message == nil {
+		return result
+	}
+
+	text := strings.TrimSpace(messageText(message.Content))
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+		result.Outcome = OutcomeFail
+		result.Detail = "response is not a JSON object: " + err.Error()
+		return result
+	}
+	result.Outcome = OutcomePass
+	result.Detail = "response parsed as a JSON object"
+	return result
+}
+
+// probeTools 强提示调用工具，响应中必须包含对应的 tool_calls
+func (p *Prober) probeTools(ctx context.Context, target *Target) *Result {
+	body := map[string]interface{}{
+		"model": target.Model,
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": "Call the get_weather tool for Paris."},
+		},
+		"tools": []adapter.Tool{{
+			Type: "function",
+			Function: adapter.ToolFunction{
+				Name:        "get_weather",
+				Description: "Get the current weather for a city",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"city": map[string]string{"type": "string"}},
+					"required":   []string{"city"},
+				},
+			},
+		}},
+		"max_tokens":  64,
+		"temperature": 0,
+	}
+
+	result, message := p.complete(ctx, target, CapabilityTools, body)
+	if message == nil {
+		return result
+	}
+
+	for _, call := range message.ToolCalls {
+		if call.Function.Name == "get_weather" {
+			result.Outcome = OutcomePass
+			result.Detail = "tool_calls returned for get_weather"
+			return result
+		}
+	}
+	result.Outcome = OutcomeFail
+	result.Detail = "response has no tool_calls for get_weather"
+	return result
+}
+
+// probeVision 发送 1x1 红色像素，回答需提到红色，忽略图片的上游无法答对
+func (p *Prober) probeVision(ctx context.Context, target *Target) *Result {
+	body := map[string]interface{}{
+		"model": target.Model,
+		"messages": []map[string]interface{}{{
+			"role": "user",
+			"content": []map[string]interface{}{
+				{"type": "text", "text": "What color is this image? Answer with one word."},
+				{"type": "image_url", "image_url": map[string]string{"url": redPixelPNG}},
+			},
+		}},
+		"max_tokens":  16,
+		"temperature": 0,
+	}
+
+	result, message := p.complete(ctx, target, CapabilityVision, body)
+	if message == nil {
+		return result
+	}
+
+	if strings.Contains(strings.ToLower(messageText(message.Content)), "red") {
+		result.Outcome = OutcomePass
+		result.Detail = "image color identified"
+		return result
+	}
+	result.Outcome = OutcomeFail
+	result.Detail = "response does not describe the image"
+	return result
+}
+
+// probeStream stream=true 时必须返回 SSE，且内容分多个数据块下发
+func (p *Prober) probeStream(ctx context.Context, target *Target) *Result {
+	body := map[string]interface{}{
+		"model": target.Model,
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": "Count from 1 to 5, separated by spaces."},
+		},
+		"stream":      true,
+		"max_tokens":  32,
+		"temperature": 0,
+	}
+
+	result := &Result{Capability: CapabilityStream}
+	resp, err := p.send(ctx, target, body, result)
+	if err != nil {
+		return result
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		p.classifyStatus(resp, result)
+		return result
+	}
+
+	var evidence strings.Builder
+	chunks := 0
+	done := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if evidence.Len() < maxEvidence {
+			evidence.WriteString(line)
+			evidence.WriteByte('\n')
+		}
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+		var chunk adapter.StreamChunk
+		if json.Unmarshal([]byte(data), &chunk) == nil && chunk.DeltaText() != "" {
+			chunks++
+		}
+	}
+	result.Evidence = truncate(evidence.String())
+	if err := scanner.Err(); err != nil {
+		result.Outcome = OutcomeError
+		result.Detail = "stream read failed: " + err.Error()
+		return result
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	switch {
+	case !strings.HasPrefix(contentType, "text/event-stream"):
+		result.Outcome = OutcomeFail
+		result.Detail = "response is not an event stream: " + contentType
+	case chunks < 2:
+		result.Outcome = OutcomeFail
+		result.Detail = fmt.Sprintf("expected incremental chunks, got %d", chunks)
+	case !done:
+		result.Outcome = OutcomeFail
+		result.Detail = "stream ended without [DONE]"
+	default:
+		result.Outcome = OutcomePass
+		result.Detail = fmt.Sprintf("received %d content chunks", chunks)
+	}
+	return result
+}
+
+// probeMessage 非流式响应中需要检查的字段（adapter.Message 不含 tool_calls）
+type probeMessage struct {
+	Content   interface{} `json:"content"`
+	ToolCalls []struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls"`
+}
+
+type probeResponse struct {
+	Choices []struct {
+		Message probeMessage `json:"message"`
+	} `json:"choices"`
+	Usage *adapter.Usage `json:"usage"`
+}
+
+// complete 发送非流式请求，成功时返回第一条消息，否则结果已填好
+func (p *Prober) complete(ctx context.Context, target *Target, capability Capability, body map[string]interface{}) (*Result, *probeMessage) {
+	result := &Result{Capability: capability}
+	resp, err := p.send(ctx, target, body, result)
+	if err != nil {
+		return result, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		p.classifyStatus(resp, result)
+		return result, nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	result.Evidence = truncate(string(data))
+	if err != nil {
+		result.Outcome = OutcomeError
+		result.Detail = "failed to read response: " + err.Error()
+		return result, nil
+	}
+
+	var parsed probeResponse
+	if err := json.Unmarshal(data, &parsed); err != nil || len(parsed.Choices) == 0 {
+		result.Outcome = OutcomeFail
+		result.Detail = "response is not a chat completion"
+		return result, nil
+	}
+	result.Usage = parsed.Usage
+	return result, &parsed.Choices[0].Message
+}
+
+// send 发送请求并记录耗时，网络错误时结果记为 error
+func (p *Prober) send(ctx context.Context, target *Target, body map[string]interface{}, result *Result) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		result.Outcome = OutcomeError
+		result.Detail = err.Error()
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.BaseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		result.Outcome = OutcomeError
+		result.Detail = err.Error()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if target.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+target.APIKey)
+	}
+
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	result.LatencyMs = int(time.Since(start).Milliseconds())
+	if err != nil {
+		result.Outcome = OutcomeError
+		result.Detail = "request failed: " + err.Error()
+		return nil, err
+	}
+	result.StatusCode = resp.StatusCode
+	return resp, nil
+}
+
+// classifyStatus 非 200 响应：参数错误类的 4xx 视为不支持，其余视为探测失败
+func (p *Prober) classifyStatus(resp *http.Response, result *Result) {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxEvidence))
+	result.Evidence = string(data)
+
+	switch resp.StatusCode {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusNotImplemented:
+		result.Outcome = OutcomeUnsupported
+		result.Detail = fmt.Sprintf("upstream rejected the request with %d", resp.StatusCode)
+	default:
+		result.Outcome = OutcomeError
+		result.Detail = fmt.Sprintf("upstream returned %d", resp.StatusCode)
+	}
+}
+
+// messageText 提取消息文本，兼容字符串与内容片段数组
+func messageText(content interface{}) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var sb strings.Builder
+		for _, part := range v {
+			if m, ok := part.(map[string]interface{}); ok {
+				if text, ok := m["text"].(string); ok {
+					sb.WriteString(text)
+				}
+			}
+		}
+		return sb.String()
+	default:
+		return ""
+	}
+}
+
+func truncate(s string) string {
+	if len(s) <= maxEvidence {
+		return s
+	}
+	return s[:maxEvidence]
+}
+
+// Features 由探测结果得出的特性标记，error 的能力不给出结论
+func Features(results []*Result) map[Capability]bool {
+	features := make(map[Capability]bool, len(results))
+	for _, r := range results {
+		switch r.Outcome {
+		case OutcomePass:
+			features[r.Capability] = true
+		case OutcomeFail, OutcomeUnsupported:
+			features[r.Capability] = false
+		}
+	}
+	return features
+}
@@ -0,0 +1,240 @@
+package capprobe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 假上游对每项能力的行为
+const (
+	behaveOK     = "ok"     // 正确支持
+	behaveIgnore = "ignore" // 接受参数但忽略
+	behaveReject = "reject" // 以 400 拒绝
+)
+
+// fakeUpstream OpenAI 兼容的假上游，可按能力配置为忽略或拒绝
+func fakeUpstream(t *testing.T, behavior map[Capability]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"message":"invalid api key"}}`)
+			return
+		}
+
+		var req map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		capability := CapabilityStream
+		switch {
+		case req["response_format"] != nil:
+			capability = CapabilityJSONMode
+		case req["tools"] != nil:
+			capability = CapabilityTools
+		case req["stream"] != true:
+			capability = CapabilityVision
+		}
+
+		behave := behavior[capability]
+		if behave == "" {
+			behave = behaveOK
+		}
+		if behave == behaveReject {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error":{"message":"unsupported parameter for %s"}}`, capability)
+			return
+		}
+
+		if capability == CapabilityStream && behave == behaveOK {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, n := range []string{"1", " 2", " 3"} {
+				fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", n)
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+
+		message := map[string]interface{}{"role": "assistant", "content": "Sure, here you go."}
+		switch {
+		case capability == CapabilityJSONMode && behave == behaveOK:
+			message["content"] = `{"ok": true}`
+		case capability == CapabilityTools && behave == behaveOK:
+			message["content"] = nil
+			message["tool_calls"] = []map[string]interface{}{{
+				"id":       "call_1",
+				"type":     "function",
+				"function": map[string]string{"name": "get_weather", "arguments": `{"city":"Paris"}`},
+			}}
+		case capability == CapabilityVision && behave == behaveOK:
+			message["content"] = "Red."
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      "chatcmpl-1",
+			"choices": []map[string]interface{}{{"index": 0, "message": message, "finish_reason": "stop"}},
+			"usage":   map[string]int{"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15},
+		})
+	}))
+}
+
+func outcomes(results []*Result) map[Capability]Outcome {
+	m := make(map[Capability]Outcome, len(results))
+	for _, r := range results {
+		m[r.Capability] = r.Outcome
+	}
+	return m
+}
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		name     string
+		behavior map[Capability]string
+		want     map[Capability]Outcome
+	}{
+		{
+			name: "all supported",
+			want: map[Capability]Outcome{
+				CapabilityJSONMode: OutcomePass,
+				CapabilityTools:    OutcomePass,
+				CapabilityVision:   OutcomePass,
+				CapabilityStream:   OutcomePass,
+			},
+		},
+		{
+			name: "silently ignores response_format and tools",
+			behavior: map[Capability]string{
+				CapabilityJSONMode: behaveIgnore,
+				CapabilityTools:    behaveIgnore,
+			},
+			want: map[Capability]Outcome{
+				CapabilityJSONMode: OutcomeFail,
+				CapabilityTools:    OutcomeFail,
+				CapabilityVision:   OutcomePass,
+				CapabilityStream:   OutcomePass,
+			},
+		},
+		{
+			name: "ignores images and streaming",
+			behavior: map[Capability]string{
+				CapabilityVision: behaveIgnore,
+				CapabilityStream: behaveIgnore,
+			},
+			want: map[Capability]Outcome{
+				CapabilityJSONMode: OutcomePass,
+				CapabilityTools:    OutcomePass,
+				CapabilityVision:   OutcomeFail,
+				CapabilityStream:   OutcomeFail,
+			},
+		},
+		{
+			name: "rejects tools and images",
+			behavior: map[Capability]string{
+				CapabilityTools:  behaveReject,
+				CapabilityVision: behaveReject,
+			},
+			want: map[Capability]Outcome{
+				CapabilityJSONMode: OutcomePass,
+				CapabilityTools:    OutcomeUnsupported,
+				CapabilityVision:   OutcomeUnsupported,
+				CapabilityStream:   OutcomePass,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakeUpstream(t, tt.behavior)
+			defer server.Close()
+
+			results := NewProber(nil).Probe(context.Background(), &Target{BaseURL: server.URL + "/v1", APIKey: "sk-test", Model: "gpt-test"})
+			require.Len(t, results, len(Capabilities))
+			assert.Equal(t, tt.want, outcomes(results))
+
+			for _, r := range results {
+				assert.NotEmpty(t, r.Detail, r.Capability)
+				assert.NotEmpty(t, r.Evidence, "raw evidence must be kept for %s", r.Capability)
+				if r.Outcome == OutcomeUnsupported {
+					assert.Equal(t, http.StatusBadRequest, r.StatusCode)
+					assert.Contains(t, r.Evidence, "unsupported parameter")
+				}
+			}
+		})
+	}
+}
+
+func TestProbeRecordsUsage(t *testing.T) {
+	server := fakeUpstream(t, nil)
+	defer server.Close()
+
+	result := NewProber(nil).ProbeCapability(context.Background(), &Target{BaseURL: server.URL + "/v1", APIKey: "sk-test", Model: "gpt-test"}, CapabilityJSONMode)
+	require.NotNil(t, result.Usage)
+	assert.Equal(t, 12, result.Usage.PromptTokens)
+	assert.Equal(t, 3, result.Usage.CompletionTokens)
+}
+
+func TestProbeAuthFailureIsInconclusive(t *testing.T) {
+	server := fakeUpstream(t, nil)
+	defer server.Close()
+
+	results := NewProber(nil).Probe(context.Background(), &Target{BaseURL: server.URL + "/v1", APIKey: "sk-wrong", Model: "gpt-test"})
+	for _, r := range results {
+		assert.Equal(t, OutcomeError, r.Outcome, r.Capability)
+		assert.Equal(t, http.StatusUnauthorized, r.StatusCode)
+	}
+	assert.Empty(t, Features(results), "errors must not change feature flags")
+}
+
+func TestFeatures(t *testing.T) {
+	features := Features([]*Result{
+		{Capability: CapabilityJSONMode, Outcome: OutcomePass},
+		{Capability: CapabilityTools, Outcome: OutcomeFail},
+		{Capability: CapabilityVision, Outcome: OutcomeUnsupported},
+		{Capability: CapabilityStream, Outcome: OutcomeError},
+	})
+
+	assert.Equal(t, map[Capability]bool{
+		CapabilityJSONMode: true,
+		CapabilityTools:    false,
+		CapabilityVision:   false,
+	}, features)
+}
+
+func TestTargetFromChannel(t *testing.T) {
+	target, err := TargetFromChannel(&model.Channel{Type: "openai", BaseURL: "https://api.example.com/v1/", APIKey: "sk-test"}, "gpt-4o")
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.example.com/v1", target.BaseURL)
+	assert.Equal(t, "sk-test", target.APIKey)
+
+	_, err = TargetFromChannel(&model.Channel{Type: "anthropic"}, "claude-3")
+	assert.ErrorIs(t, err, ErrUnsupportedChannel)
+}
+
+func TestThrottle(t *testing.T) {
+	throttle := NewThrottle(5 * time.Minute)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttle.now = func() time.Time { return now }
+
+	require.NoError(t, throttle.Acquire(1))
+
+	now = now.Add(time.Minute)
+	err := throttle.Acquire(1)
+	var limited *RateLimitedError
+	require.ErrorAs(t, err, &limited)
+	assert.Equal(t, 4*time.Minute, limited.RetryAfter)
+
+	// 其他渠道不受影响
+	assert.NoError(t, throttle.Acquire(2))
+
+	now = now.Add(4 * time.Minute)
+	assert.NoError(t, throttle.Acquire(1))
+}
@@ -0,0 +1,53 @@
+package capprobe
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimitedError 渠道在间隔内已探测过
+type RateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("channel was probed recently, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// Throttle 按渠道限制探测频率，避免重复探测消耗上游额度
+type Throttle struct {
+	interval time.Duration
+	now      func() time.Time
+
+	mu   sync.Mutex
+	last map[int]time.Time
+}
+
+// NewThrottle 创建限流器，interval <= 0 时不限制
+func NewThrottle(interval time.Duration) *Throttle {
+	return &Throttle{
+		interval: interval,
+		now:      time.Now,
+		last:     make(map[int]time.Time),
+	}
+}
+
+// Acquire 占用渠道的探测配额，间隔未到时返回 *RateLimitedError
+func (t *Throttle) Acquire(channelID int) error {
+	if t.interval <= 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if last, ok := t.last[channelID]; ok {
+		if wait := t.interval - now.Sub(last); wait > 0 {
+			return &RateLimitedError{RetryAfter: wait}
+		}
+	}
+	t.last[channelID] = now
+	return nil
+}
@@ -0,0 +1,109 @@
+package chat
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ForkAgent 复制 Agent 给 userID
+//
+// 提示词以新的提示词 ID 克隆（版本历史从 1 开始），工具定义与模型配置深拷贝，
+// 工具调用历史与统计清零，之后修改副本不会影响原 Agent。私有 Agent 只有所有者可以 Fork。
+func (am *AgentManager) ForkAgent(originalID string, newAgentID string, name string, userID string) (*Agent, error) {
+	original, err := am.GetAgent(originalID)
+	if err != nil {
+		return nil, err
+	}
+
+	original.mu.RLock()
+	if !original.IsPublic && original.OwnerID != userID {
+		original.mu.RUnlock()
+		return nil, fmt.Errorf("permission denied")
+	}
+	prompt := cloneSystemPrompt(original.SystemPrompt)
+	tools := make([]*ToolDefinition, len(original.Tools))
+	for i, tool := range original.Tools {
+		tools[i] = cloneToolDefinition(tool)
+	}
+	modelConfig := cloneMap(original.ModelConfig)
+	original.mu.RUnlock()
+
+	am.agentsMu.Lock()
+	defer am.agentsMu.Unlock()
+
+	if _, exists := am.agents[newAgentID]; exists {
+		return nil, fmt.Errorf("agent %s already exists", newAgentID)
+	}
+
+	if prompt != nil {
+		prompt.ID = fmt.Sprintf("%s:prompt", newAgentID)
+		if err := am.promptManager.AddPromptBy(prompt, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	fork := NewAgent(newAgentID, name, prompt)
+	fork.Tools = tools
+	fork.ModelConfig = modelConfig
+	fork.OwnerID = userID
+	fork.ForkedFrom = originalID
+
+	am.agents[newAgentID] = fork
+	atomic.AddInt64(&am.totalAgents, 1)
+	atomic.AddInt64(&original.ForkCount, 1)
+
+	am.logFunc("info", fmt.Sprintf("Forked agent %s as %s for %s", originalID, newAgentID, userID))
+
+	return fork, nil
+}
+
+// cloneSystemPrompt 复制提示词，版本与时间由 AddPrompt 重新设置
+func cloneSystemPrompt(prompt *SystemPrompt) *SystemPrompt {
+	if prompt == nil {
+		return nil
+	}
+	clone := *prompt
+	clone.Tags = append([]string(nil), prompt.Tags...)
+	clone.Version = 0
+	clone.CreatedAt = time.Time{}
+	clone.UpdatedAt = time.Time{}
+	return &clone
+}
+
+// cloneToolDefinition 深拷贝工具定义，参数定义中的嵌套 map 与 slice 都会复制
+func cloneToolDefinition(tool *ToolDefinition) *ToolDefinition {
+	clone := *tool
+	clone.Parameters = cloneMap(tool.Parameters)
+	return &clone
+}
+
+// cloneMap 深拷贝 map[string]interface{}
+func cloneMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	clone := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		clone[k] = cloneValue(v)
+	}
+	return clone
+}
+
+// cloneValue 深拷贝 JSON 风格的值
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return cloneMap(v)
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneValue(item)
+		}
+		return clone
+	case []string:
+		return append([]string(nil), v...)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,113 @@
+package chat
+
+import (
+	"testing"
+)
+
+// newForkTestManager 创建一个带提示词与工具的公开 Agent
+func newForkTestManager(t *testing.T) (*AgentManager, *SystemPromptManager) {
+	t.Helper()
+
+	spm := NewSystemPromptManager()
+	tr := NewToolRegistry()
+	am := NewAgentManager(spm, tr)
+
+	spm.AddPromptBy(&SystemPrompt{ID: "prompt-1", Content: "You are helpful", Tags: []string{"general"}}, "alice")
+	original, err := am.CreateAgent("agent-1", "Helper", "prompt-1")
+	if err != nil {
+		t.Fatalf("CreateAgent failed: %v", err)
+	}
+	original.OwnerID = "alice"
+	original.IsPublic = true
+	original.ModelConfig["model"] = "gpt-4o"
+
+	tr.RegisterTool(&ToolDefinition{
+		Name: "search",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"query": map[string]interface{}{"type": "string"}},
+			"required":   []interface{}{"query"},
+		},
+	}, func(map[string]interface{}) (interface{}, error) { return "ok", nil })
+	am.BindTool("agent-1", "search")
+	am.ExecuteToolCall("agent-1", "search", nil)
+
+	return am, spm
+}
+
+func TestForkAgentIsolatesMutations(t *testing.T) {
+	am, spm := newForkTestManager(t)
+	original, _ := am.GetAgent("agent-1")
+
+	fork, err := am.ForkAgent("agent-1", "agent-2", "My Helper", "bob")
+	if err != nil {
+		t.Fatalf("ForkAgent failed: %v", err)
+	}
+
+	if fork.OwnerID != "bob" || fork.IsPublic || fork.ForkedFrom != "agent-1" {
+		t.Errorf("Unexpected fork attribution: owner=%s public=%v from=%s", fork.OwnerID, fork.IsPublic, fork.ForkedFrom)
+	}
+	if len(fork.GetToolCalls()) != 0 {
+		t.Errorf("Expected fork to start without tool call history")
+	}
+	if fork.SystemPrompt == original.SystemPrompt || fork.SystemPrompt.ID == "prompt-1" {
+		t.Fatalf("Expected fork to get its own prompt, got %s", fork.SystemPrompt.ID)
+	}
+	if fork.SystemPrompt.Version != 1 {
+		t.Errorf("Expected cloned prompt to start at version 1, got %d", fork.SystemPrompt.Version)
+	}
+
+	// 修改副本的提示词、工具与配置
+	if err := am.HotUpdatePrompt("agent-2", "You are terse", "bob"); err != nil {
+		t.Fatalf("HotUpdatePrompt failed: %v", err)
+	}
+	fork.SystemPrompt.Tags[0] = "changed"
+	fork.Tools[0].Description = "changed"
+	fork.Tools[0].Parameters["required"].([]interface{})[0] = "changed"
+	fork.Tools[0].Parameters["properties"].(map[string]interface{})["query"].(map[string]interface{})["type"] = "number"
+	fork.ModelConfig["model"] = "gpt-4o-mini"
+	fork.AddTool(&ToolDefinition{Name: "extra"})
+
+	prompt, _ := spm.GetPrompt("prompt-1")
+	if prompt.Content != "You are helpful" || prompt.Version != 1 || prompt.Tags[0] != "general" {
+		t.Errorf("Original prompt was mutated: %+v", prompt)
+	}
+	tool := original.Tools[0]
+	if len(original.Tools) != 1 || tool.Description != "" {
+		t.Errorf("Original tools were mutated: %+v", original.Tools)
+	}
+	if tool.Parameters["required"].([]interface{})[0] != "query" {
+		t.Errorf("Original tool required list was mutated")
+	}
+	if tool.Parameters["properties"].(map[string]interface{})["query"].(map[string]interface{})["type"] != "string" {
+		t.Errorf("Original tool parameter schema was mutated")
+	}
+	if original.ModelConfig["model"] != "gpt-4o" {
+		t.Errorf("Original model config was mutated")
+	}
+	if len(original.GetToolCalls()) != 1 {
+		t.Errorf("Original tool call history changed")
+	}
+
+	stats, _ := am.GetAgentStatistics("agent-1")
+	if stats["fork_count"] != int64(1) {
+		t.Errorf("Expected fork_count 1, got %v", stats["fork_count"])
+	}
+}
+
+func TestForkPrivateAgentRequiresOwnership(t *testing.T) {
+	am, _ := newForkTestManager(t)
+	original, _ := am.GetAgent("agent-1")
+	original.IsPublic = false
+
+	if _, err := am.ForkAgent("agent-1", "agent-2", "Stolen", "mallory"); err == nil {
+		t.Error("Expected forking another user's private agent to fail")
+	}
+	if _, err := am.GetAgent("agent-2"); err == nil {
+		t.Error("Expected rejected fork not to be registered")
+	}
+
+	if _, err := am.ForkAgent("agent-1", "agent-3", "Mine", "alice"); err != nil {
+		t.Errorf("Expected owner to fork private agent, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package chat
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -63,7 +64,7 @@ type ToolDefinition struct {
 	// 超时时间（毫秒）
 	TimeoutMS int `json:"timeout_ms"`
 
-	// 重试次数
+	// 重试次数（仅对 RetryableToolError 重试）
 	RetryCount int `json:"retry_count"`
 }
 
@@ -90,6 +91,9 @@ type ToolCall struct {
 	// 状态
 	Status string `json:"status"` // pending, running, completed, failed
 
+	// 尝试次数（含重试）
+	Attempts int `json:"attempts"`
+
 	// 创建时间
 	CreatedAt time.Time `json:"created_at"`
 
@@ -97,13 +101,46 @@ type ToolCall struct {
 	CompletedAt *time.Time `json:"completed_at"`
 }
 
+// PromptVersion 提示词的一个历史版本，历史只追加不修改
+type PromptVersion struct {
+	// 提示词 ID
+	PromptID string `json:"prompt_id"`
+
+	// 版本号，每个提示词从 1 开始递增
+	Version int64 `json:"version"`
+
+	// 该版本的内容
+	Content string `json:"content"`
+
+	// 修改人
+	Editor string `json:"editor"`
+
+	// 回滚产生的版本记录回滚到的版本号
+	RolledBackFrom int64 `json:"rolled_back_from,omitempty"`
+
+	// 创建时间
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PromptHistoryStore 提示词历史的持久化存储，FindPromptVersions 按版本号升序返回
+type PromptHistoryStore interface {
+	SavePromptVersion(version *PromptVersion) error
+	FindPromptVersions(promptID string) ([]*PromptVersion, error)
+}
+
 // SystemPromptManager 系统提示词管理器
 type SystemPromptManager struct {
 	// 提示词存储
 	prompts map[string]*SystemPrompt
 	promptsMu sync.RWMutex
 
-	// 当前版本
+	// 版本历史（受 promptsMu 保护）
+	history map[string][]*PromptVersion
+
+	// 历史持久化存储，为 nil 时只保存在内存中
+	store PromptHistoryStore
+
+	// 累计版本数
 	currentVersion int64
 
 	// 统计信息
@@ -118,12 +155,27 @@ type SystemPromptManager struct {
 func NewSystemPromptManager() *SystemPromptManager {
 	return &SystemPromptManager{
 		prompts: make(map[string]*SystemPrompt),
+		history: make(map[string][]*PromptVersion),
 		logFunc: defaultLogFunc,
 	}
 }
 
+// NewPersistentSystemPromptManager 创建将版本历史保存到 store 的提示词管理器
+//
+// 重启后内存中没有的提示词会按历史中的最新版本恢复。
+func NewPersistentSystemPromptManager(store PromptHistoryStore) *SystemPromptManager {
+	spm := NewSystemPromptManager()
+	spm.store = store
+	return spm
+}
+
 // AddPrompt 添加提示词
 func (spm *SystemPromptManager) AddPrompt(prompt *SystemPrompt) error {
+	return spm.AddPromptBy(prompt, "")
+}
+
+// AddPromptBy 添加提示词并记录创建人；历史中已有该提示词时版本号接着历史递增
+func (spm *SystemPromptManager) AddPromptBy(prompt *SystemPrompt, editor string) error {
 	spm.promptsMu.Lock()
 	defer spm.promptsMu.Unlock()
 
@@ -131,9 +183,14 @@ func (spm *SystemPromptManager) AddPrompt(prompt *SystemPrompt) error {
 		return fmt.Errorf("prompt %s already exists", prompt.ID)
 	}
 
-	prompt.Version = atomic.AddInt64(&spm.currentVersion, 1)
-	prompt.CreatedAt = time.Now()
-	prompt.UpdatedAt = time.Now()
+	version, err := spm.appendVersionLocked(prompt.ID, prompt.Content, editor, 0)
+	if err != nil {
+		return err
+	}
+
+	prompt.Version = version.Version
+	prompt.CreatedAt = version.CreatedAt
+	prompt.UpdatedAt = version.CreatedAt
 
 	spm.prompts[prompt.ID] = prompt
 	atomic.AddInt64(&spm.totalPrompts, 1)
@@ -145,17 +202,24 @@ func (spm *SystemPromptManager) AddPrompt(prompt *SystemPrompt) error {
 
 // UpdatePrompt 更新提示词
 func (spm *SystemPromptManager) UpdatePrompt(promptID string, content string) (*SystemPrompt, error) {
+	return spm.UpdatePromptBy(promptID, content, "")
+}
+
+// UpdatePromptBy 更新提示词并记录修改人，旧内容保留在版本历史中
+func (spm *SystemPromptManager) UpdatePromptBy(promptID string, content string, editor string) (*SystemPrompt, error) {
 	spm.promptsMu.Lock()
 	defer spm.promptsMu.Unlock()
 
-	prompt, exists := spm.prompts[promptID]
-	if !exists {
-		return nil, fmt.Errorf("prompt %s not found", promptID)
+	prompt, err := spm.promptLocked(promptID)
+	if err != nil {
+		return nil, err
 	}
 
-	prompt.Content = content
-	prompt.Version = atomic.AddInt64(&spm.currentVersion, 1)
-	prompt.UpdatedAt = time.Now()
+	version, err := spm.appendVersionLocked(promptID, content, editor, 0)
+	if err != nil {
+		return nil, err
+	}
+	spm.applyVersionLocked(prompt, version)
 
 	atomic.AddInt64(&spm.totalUpdates, 1)
 
@@ -164,17 +228,177 @@ func (spm *SystemPromptManager) UpdatePrompt(promptID string, content string) (*
 	return prompt, nil
 }
 
+// RollbackPrompt 回滚到指定版本：以该版本的内容创建一个新版本，历史不会被删除
+func (spm *SystemPromptManager) RollbackPrompt(promptID string, version int64, editor string) (*SystemPrompt, error) {
+	spm.promptsMu.Lock()
+	defer spm.promptsMu.Unlock()
+
+	prompt, err := spm.promptLocked(promptID)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := spm.versionLocked(promptID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := spm.appendVersionLocked(promptID, target.Content, editor, target.Version)
+	if err != nil {
+		return nil, err
+	}
+	spm.applyVersionLocked(prompt, next)
+
+	atomic.AddInt64(&spm.totalUpdates, 1)
+
+	spm.logFunc("info", fmt.Sprintf("Rolled back prompt %s to version %d (version %d)", promptID, version, prompt.Version))
+
+	return prompt, nil
+}
+
+// GetPromptVersion 获取提示词的指定版本
+func (spm *SystemPromptManager) GetPromptVersion(promptID string, version int64) (*PromptVersion, error) {
+	spm.promptsMu.Lock()
+	defer spm.promptsMu.Unlock()
+
+	return spm.versionLocked(promptID, version)
+}
+
+// GetPromptVersions 获取提示词的全部历史版本，按版本号升序
+func (spm *SystemPromptManager) GetPromptVersions(promptID string) ([]*PromptVersion, error) {
+	spm.promptsMu.Lock()
+	defer spm.promptsMu.Unlock()
+
+	history, err := spm.historyLocked(promptID)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("prompt %s not found", promptID)
+	}
+
+	versions := make([]*PromptVersion, len(history))
+	copy(versions, history)
+
+	return versions, nil
+}
+
+// historyLocked 获取版本历史，内存中没有时从存储加载
+func (spm *SystemPromptManager) historyLocked(promptID string) ([]*PromptVersion, error) {
+	if history, ok := spm.history[promptID]; ok || spm.store == nil {
+		return history, nil
+	}
+
+	history, err := spm.store.FindPromptVersions(promptID)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) > 0 {
+		spm.history[promptID] = history
+	}
+
+	return history, nil
+}
+
+// versionLocked 查找指定版本
+func (spm *SystemPromptManager) versionLocked(promptID string, version int64) (*PromptVersion, error) {
+	history, err := spm.historyLocked(promptID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range history {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("prompt %s version %d not found", promptID, version)
+}
+
+// promptLocked 获取提示词，内存中没有但存储中有历史时按最新版本恢复
+func (spm *SystemPromptManager) promptLocked(promptID string) (*SystemPrompt, error) {
+	if prompt, exists := spm.prompts[promptID]; exists {
+		return prompt, nil
+	}
+
+	history, err := spm.historyLocked(promptID)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("prompt %s not found", promptID)
+	}
+
+	latest := history[len(history)-1]
+	prompt := &SystemPrompt{
+		ID:        promptID,
+		Content:   latest.Content,
+		Version:   latest.Version,
+		CreatedAt: history[0].CreatedAt,
+		UpdatedAt: latest.CreatedAt,
+		Enabled:   true,
+	}
+	spm.prompts[promptID] = prompt
+
+	return prompt, nil
+}
+
+// appendVersionLocked 追加一个版本，先写入存储，成功后再更新内存
+func (spm *SystemPromptManager) appendVersionLocked(promptID, content, editor string, rolledBackFrom int64) (*PromptVersion, error) {
+	history, err := spm.historyLocked(promptID)
+	if err != nil {
+		return nil, err
+	}
+
+	version := &PromptVersion{
+		PromptID:       promptID,
+		Version:        1,
+		Content:        content,
+		Editor:         editor,
+		RolledBackFrom: rolledBackFrom,
+		CreatedAt:      time.Now(),
+	}
+	if len(history) > 0 {
+		version.Version = history[len(history)-1].Version + 1
+	}
+
+	if spm.store != nil {
+		if err := spm.store.SavePromptVersion(version); err != nil {
+			return nil, fmt.Errorf("failed to save prompt version: %w", err)
+		}
+	}
+
+	spm.history[promptID] = append(history, version)
+	atomic.AddInt64(&spm.currentVersion, 1)
+
+	return version, nil
+}
+
+// applyVersionLocked 将版本内容应用到提示词
+func (spm *SystemPromptManager) applyVersionLocked(prompt *SystemPrompt, version *PromptVersion) {
+	prompt.Content = version.Content
+	prompt.Version = version.Version
+	prompt.UpdatedAt = version.CreatedAt
+}
+
 // GetPrompt 获取提示词
 func (spm *SystemPromptManager) GetPrompt(promptID string) (*SystemPrompt, error) {
 	spm.promptsMu.RLock()
-	defer spm.promptsMu.RUnlock()
-
 	prompt, exists := spm.prompts[promptID]
-	if !exists {
+	spm.promptsMu.RUnlock()
+
+	if exists {
+		return prompt, nil
+	}
+	if spm.store == nil {
 		return nil, fmt.Errorf("prompt %s not found", promptID)
 	}
 
-	return prompt, nil
+	spm.promptsMu.Lock()
+	defer spm.promptsMu.Unlock()
+
+	return spm.promptLocked(promptID)
 }
 
 // GetAllPrompts 获取所有提示词
@@ -318,17 +542,37 @@ func (tr *ToolRegistry) GetAllTools() []*ToolDefinition {
 
 // CallTool 调用工具
 func (tr *ToolRegistry) CallTool(toolName string, arguments map[string]interface{}) (interface{}, error) {
+	result, _, err := tr.CallToolContext(context.Background(), toolName, arguments)
+	return result, err
+}
+
+// CallToolContext 调用工具，每次尝试受 TimeoutMS 限制，可重试的错误最多重试 RetryCount 次，返回结果与尝试次数
+func (tr *ToolRegistry) CallToolContext(ctx context.Context, toolName string, arguments map[string]interface{}) (interface{}, int, error) {
 	tr.handlersMu.RLock()
 	handler, exists := tr.handlers[toolName]
 	tr.handlersMu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("tool %s not found", toolName)
+		return nil, 0, fmt.Errorf("tool %s not found", toolName)
+	}
+
+	def, err := tr.GetTool(toolName)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	atomic.AddInt64(&tr.totalCalls, 1)
 
-	return handler(arguments)
+	timeout := time.Duration(def.TimeoutMS) * time.Millisecond
+	attempts := 0
+	for {
+		attempts++
+		result, err := invokeTool(ctx, toolName, handler, arguments, timeout)
+		if err == nil || !IsRetryableToolError(err) || attempts > def.RetryCount || ctx.Err() != nil {
+			return result, attempts, err
+		}
+		tr.logFunc("warn", fmt.Sprintf("Tool %s failed on attempt %d, retrying: %v", toolName, attempts, err))
+	}
 }
 
 // GetStatistics 获取统计信息
@@ -359,6 +603,18 @@ type Agent struct {
 	// 工具调用历史
 	ToolCalls []*ToolCall `json:"tool_calls"`
 
+	// 所有者
+	OwnerID string `json:"owner_id"`
+
+	// 是否公开（公开的 Agent 可被他人 Fork）
+	IsPublic bool `json:"is_public"`
+
+	// Fork 来源 Agent ID
+	ForkedFrom string `json:"forked_from,omitempty"`
+
+	// 被 Fork 的次数
+	ForkCount int64 `json:"fork_count"`
+
 	// 创建时间
 	CreatedAt time.Time `json:"created_at"`
 
@@ -392,6 +648,17 @@ func (a *Agent) AddTool(tool *ToolDefinition) {
 	a.UpdatedAt = time.Now()
 }
 
+// GetTools 获取 Agent 可用的工具
+func (a *Agent) GetTools() []*ToolDefinition {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	tools := make([]*ToolDefinition, len(a.Tools))
+	copy(tools, a.Tools)
+
+	return tools
+}
+
 // RecordToolCall 记录工具调用
 func (a *Agent) RecordToolCall(call *ToolCall) {
 	a.mu.Lock()
@@ -535,31 +802,34 @@ func (am *AgentManager) ExecuteToolCall(agentID string, toolName string, argumen
 
 	start := time.Now()
 
-	result, err := am.toolRegistry.CallTool(toolName, arguments)
-
-	executionTime := time.Since(start).Milliseconds()
-
+	// 先以 running 状态记录，执行期间可在调用历史中看到
 	call := &ToolCall{
-		ID:            fmt.Sprintf("%s_%d", agentID, time.Now().UnixNano()),
-		ToolName:      toolName,
-		Arguments:     arguments,
-		ExecutionTime: executionTime,
-		CreatedAt:     time.Now(),
-		Status:        "completed",
+		ID:        fmt.Sprintf("%s_%d", agentID, start.UnixNano()),
+		ToolName:  toolName,
+		Arguments: arguments,
+		CreatedAt: start,
+		Status:    "running",
 	}
+	agent.RecordToolCall(call)
 
+	result, attempts, err := am.toolRegistry.CallToolContext(context.Background(), toolName, arguments)
+
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	call.Attempts = attempts
+	call.ExecutionTime = time.Since(start).Milliseconds()
 	if err != nil {
 		call.Error = err.Error()
 		call.Status = "failed"
 	} else {
 		call.Result = result
+		call.Status = "completed"
 	}
 
 	now := time.Now()
 	call.CompletedAt = &now
 
-	agent.RecordToolCall(call)
-
 	return call, nil
 }
 
@@ -589,6 +859,7 @@ func (am *AgentManager) GetAgentStatistics(agentID string) (map[string]interface
 		"call_count":     len(calls),
 		"success_count":  successCount,
 		"failed_count":   failedCount,
+		"fork_count":     atomic.LoadInt64(&agent.ForkCount),
 		"created_at":     agent.CreatedAt,
 		"updated_at":     agent.UpdatedAt,
 	}, nil
@@ -603,15 +874,15 @@ func (am *AgentManager) GetStatistics() map[string]interface{} {
 	}
 }
 
-// HotUpdatePrompt 热更新提示词
-func (am *AgentManager) HotUpdatePrompt(agentID string, newContent string) error {
+// HotUpdatePrompt 热更新提示词，editor 记录在提示词的版本历史中
+func (am *AgentManager) HotUpdatePrompt(agentID string, newContent string, editor string) error {
 	agent, err := am.GetAgent(agentID)
 	if err != nil {
 		return err
 	}
 
 	// 更新系统提示词管理器中的提示词
-	prompt, err := am.promptManager.UpdatePrompt(agent.SystemPrompt.ID, newContent)
+	prompt, err := am.promptManager.UpdatePromptBy(agent.SystemPrompt.ID, newContent, editor)
 	if err != nil {
 		return err
 	}
@@ -619,7 +890,7 @@ func (am *AgentManager) HotUpdatePrompt(agentID string, newContent string) error
 	// 更新 Agent 中的提示词
 	agent.UpdateSystemPrompt(prompt)
 
-	am.logFunc("info", fmt.Sprintf("Hot updated prompt for agent %s", agentID))
+	am.logFunc("info", fmt.Sprintf("Hot updated prompt for agent %s by %s", agentID, editor))
 
 	return nil
 }
@@ -325,7 +325,7 @@ func TestAgentManagerHotUpdatePrompt(t *testing.T) {
 	spm.AddPrompt(prompt)
 	am.CreateAgent("agent-1", "Test Agent", "prompt-1")
 
-	err := am.HotUpdatePrompt("agent-1", "New content")
+	err := am.HotUpdatePrompt("agent-1", "New content", "alice")
 	if err != nil {
 		t.Errorf("HotUpdatePrompt failed: %v", err)
 	}
@@ -428,3 +428,112 @@ func BenchmarkAgentCreation(b *testing.B) {
 		_ = NewAgent("agent-"+string(rune(i)), "Test", prompt)
 	}
 }
+
+// memoryPromptStore 内存中的提示词历史存储
+type memoryPromptStore struct {
+	versions []*PromptVersion
+}
+
+func (s *memoryPromptStore) SavePromptVersion(version *PromptVersion) error {
+	saved := *version
+	s.versions = append(s.versions, &saved)
+	return nil
+}
+
+func (s *memoryPromptStore) FindPromptVersions(promptID string) ([]*PromptVersion, error) {
+	var versions []*PromptVersion
+	for _, v := range s.versions {
+		if v.PromptID == promptID {
+			saved := *v
+			versions = append(versions, &saved)
+		}
+	}
+	return versions, nil
+}
+
+func TestSystemPromptManagerRollback(t *testing.T) {
+	spm := NewSystemPromptManager()
+	spm.AddPromptBy(&SystemPrompt{ID: "prompt-1", Content: "A"}, "alice")
+	spm.UpdatePromptBy("prompt-1", "B", "bob")
+
+	prompt, err := spm.RollbackPrompt("prompt-1", 1, "carol")
+	if err != nil {
+		t.Fatalf("RollbackPrompt failed: %v", err)
+	}
+	if prompt.Content != "A" || prompt.Version != 3 {
+		t.Errorf("Expected version 3 with content A, got version %d %q", prompt.Version, prompt.Content)
+	}
+
+	// 回滚到由回滚产生的版本，以及撤销回滚
+	spm.UpdatePromptBy("prompt-1", "C", "dave")
+	if prompt, _ = spm.RollbackPrompt("prompt-1", 3, "erin"); prompt.Content != "A" || prompt.Version != 5 {
+		t.Errorf("Expected version 5 with content A, got version %d %q", prompt.Version, prompt.Content)
+	}
+	if prompt, _ = spm.RollbackPrompt("prompt-1", 2, "erin"); prompt.Content != "B" || prompt.Version != 6 {
+		t.Errorf("Expected version 6 with content B, got version %d %q", prompt.Version, prompt.Content)
+	}
+
+	versions, err := spm.GetPromptVersions("prompt-1")
+	if err != nil || len(versions) != 6 {
+		t.Fatalf("Expected 6 versions, got %d (%v)", len(versions), err)
+	}
+	want := []struct {
+		content, editor string
+		from            int64
+	}{{"A", "alice", 0}, {"B", "bob", 0}, {"A", "carol", 1}, {"C", "dave", 0}, {"A", "erin", 3}, {"B", "erin", 2}}
+	for i, w := range want {
+		v := versions[i]
+		if v.Version != int64(i+1) || v.Content != w.content || v.Editor != w.editor || v.RolledBackFrom != w.from {
+			t.Errorf("Version %d: unexpected %+v", i+1, v)
+		}
+	}
+
+	if _, err := spm.RollbackPrompt("prompt-1", 42, "erin"); err == nil {
+		t.Error("Expected error when rolling back to a missing version")
+	}
+}
+
+func TestSystemPromptManagerHistorySurvivesRestart(t *testing.T) {
+	store := &memoryPromptStore{}
+	spm := NewPersistentSystemPromptManager(store)
+	spm.AddPromptBy(&SystemPrompt{ID: "prompt-1", Content: "A"}, "alice")
+	spm.UpdatePromptBy("prompt-1", "B", "bob")
+
+	restarted := NewPersistentSystemPromptManager(store)
+	prompt, err := restarted.GetPrompt("prompt-1")
+	if err != nil {
+		t.Fatalf("GetPrompt after restart failed: %v", err)
+	}
+	if prompt.Content != "B" || prompt.Version != 2 {
+		t.Errorf("Expected latest version to be restored, got version %d %q", prompt.Version, prompt.Content)
+	}
+
+	v1, err := restarted.GetPromptVersion("prompt-1", 1)
+	if err != nil || v1.Content != "A" || v1.Editor != "alice" {
+		t.Errorf("Expected version 1 from store, got %+v (%v)", v1, err)
+	}
+
+	if prompt, _ = restarted.RollbackPrompt("prompt-1", 1, "carol"); prompt.Version != 3 {
+		t.Errorf("Expected rollback to continue numbering, got version %d", prompt.Version)
+	}
+	if len(store.versions) != 3 {
+		t.Errorf("Expected 3 persisted versions, got %d", len(store.versions))
+	}
+}
+
+func TestAgentManagerHotUpdatePromptRecordsEditor(t *testing.T) {
+	spm := NewSystemPromptManager()
+	am := NewAgentManager(spm, NewToolRegistry())
+
+	spm.AddPrompt(&SystemPrompt{ID: "prompt-1", Content: "Old content"})
+	am.CreateAgent("agent-1", "Test Agent", "prompt-1")
+
+	if err := am.HotUpdatePrompt("agent-1", "New content", "ops-bot"); err != nil {
+		t.Fatalf("HotUpdatePrompt failed: %v", err)
+	}
+
+	v, err := spm.GetPromptVersion("prompt-1", 2)
+	if err != nil || v.Editor != "ops-bot" || v.Content != "New content" {
+		t.Errorf("Expected version 2 by ops-bot, got %+v (%v)", v, err)
+	}
+}
@@ -0,0 +1,70 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RetryableToolError 标记可重试的工具错误，工具处理函数返回该错误时 ToolRegistry 会按 RetryCount 重试
+type RetryableToolError struct {
+	Err error
+}
+
+func (e *RetryableToolError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryableToolError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable 将错误标记为可重试
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryableToolError{Err: err}
+}
+
+// IsRetryableToolError 判断错误是否可重试，超时与 panic 不重试
+func IsRetryableToolError(err error) bool {
+	var retryable *RetryableToolError
+	return errors.As(err, &retryable)
+}
+
+// invokeTool 在独立 goroutine 中执行一次工具调用
+//
+// 处理函数不接收 context，超时后调用方立即返回，处理函数在后台运行到结束；
+// 处理函数 panic 时转换为错误，不影响调用方。
+func invokeTool(ctx context.Context, toolName string, handler func(map[string]interface{}) (interface{}, error), arguments map[string]interface{}, timeout time.Duration) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("tool %s panicked: %v", toolName, r)}
+			}
+		}()
+		result, err := handler(arguments)
+		done <- outcome{result: result, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("tool %s timed out after %s", toolName, timeout)
+	case out := <-done:
+		return out.result, out.err
+	}
+}
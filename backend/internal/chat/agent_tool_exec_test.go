@@ -0,0 +1,111 @@
+package chat
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newToolTestAgent 创建绑定了指定工具的 Agent 管理器
+func newToolTestAgent(t *testing.T, def *ToolDefinition, handler func(map[string]interface{}) (interface{}, error)) *AgentManager {
+	t.Helper()
+
+	spm := NewSystemPromptManager()
+	tr := NewToolRegistry()
+	am := NewAgentManager(spm, tr)
+
+	spm.AddPrompt(&SystemPrompt{ID: "prompt-1", Content: "You are helpful"})
+	if _, err := am.CreateAgent("agent-1", "Test Agent", "prompt-1"); err != nil {
+		t.Fatalf("CreateAgent failed: %v", err)
+	}
+	if err := tr.RegisterTool(def, handler); err != nil {
+		t.Fatalf("RegisterTool failed: %v", err)
+	}
+	if err := am.BindTool("agent-1", def.Name); err != nil {
+		t.Fatalf("BindTool failed: %v", err)
+	}
+	return am
+}
+
+func TestExecuteToolCallTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	am := newToolTestAgent(t, &ToolDefinition{Name: "slow", TimeoutMS: 20}, func(map[string]interface{}) (interface{}, error) {
+		<-release
+		return "too late", nil
+	})
+
+	start := time.Now()
+	call, err := am.ExecuteToolCall("agent-1", "slow", nil)
+	if err != nil {
+		t.Fatalf("ExecuteToolCall failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected call to return at the timeout, took %s", elapsed)
+	}
+	if call.Status != "failed" || !strings.Contains(call.Error, "timed out") {
+		t.Errorf("Expected timed out failure, got status=%s error=%q", call.Status, call.Error)
+	}
+	if call.Attempts != 1 {
+		t.Errorf("Expected timeouts not to be retried, got %d attempts", call.Attempts)
+	}
+}
+
+func TestExecuteToolCallPanic(t *testing.T) {
+	am := newToolTestAgent(t, &ToolDefinition{Name: "broken"}, func(map[string]interface{}) (interface{}, error) {
+		panic("nil map write")
+	})
+
+	call, err := am.ExecuteToolCall("agent-1", "broken", nil)
+	if err != nil {
+		t.Fatalf("ExecuteToolCall failed: %v", err)
+	}
+	if call.Status != "failed" || !strings.Contains(call.Error, "panicked: nil map write") {
+		t.Errorf("Expected panic to be recorded as failure, got status=%s error=%q", call.Status, call.Error)
+	}
+
+	agent, _ := am.GetAgent("agent-1")
+	if calls := agent.GetToolCalls(); len(calls) != 1 || calls[0] != call {
+		t.Errorf("Expected failed call in history, got %d calls", len(calls))
+	}
+}
+
+func TestExecuteToolCallRetriesRetryableErrors(t *testing.T) {
+	var attempts int32
+	am := newToolTestAgent(t, &ToolDefinition{Name: "flaky", RetryCount: 2}, func(map[string]interface{}) (interface{}, error) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			return nil, Retryable(errors.New("connection reset"))
+		}
+		return "ok", nil
+	})
+
+	call, err := am.ExecuteToolCall("agent-1", "flaky", nil)
+	if err != nil {
+		t.Fatalf("ExecuteToolCall failed: %v", err)
+	}
+	if call.Status != "completed" || call.Result != "ok" {
+		t.Errorf("Expected success on second attempt, got status=%s error=%q", call.Status, call.Error)
+	}
+	if call.Attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", call.Attempts)
+	}
+}
+
+func TestCallToolDoesNotRetryPlainErrors(t *testing.T) {
+	var attempts int32
+	tr := NewToolRegistry()
+	tr.RegisterTool(&ToolDefinition{Name: "invalid", RetryCount: 3}, func(map[string]interface{}) (interface{}, error) {
+		atomic.AddInt32(&attempts, 1)
+		return nil, errors.New("bad arguments")
+	})
+
+	if _, err := tr.CallTool("invalid", nil); err == nil || err.Error() != "bad arguments" {
+		t.Errorf("Expected handler error, got %v", err)
+	}
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Errorf("Expected a single attempt for non-retryable error, got %d", n)
+	}
+}
@@ -178,11 +178,11 @@ func (cc *ConversationContext) IsContextFull() bool {
 	return cc.totalTokens >= cc.maxContextSize
 }
 
-// IsMaxRoundsReached 检查是否达到最大轮数
+// IsMaxRoundsReached 检查是否达到最大轮数：maxRounds 轮对话均允许，超出 maxRounds 轮时返回 true
 func (cc *ConversationContext) IsMaxRoundsReached() bool {
 	cc.messagesMu.RLock()
 	defer cc.messagesMu.RUnlock()
-	return cc.currentRound >= cc.maxRounds
+	return cc.currentRound > cc.maxRounds
 }
 
 // updateTime 更新时间戳
@@ -200,6 +200,7 @@ func (cc *ConversationContext) Clear() {
 	cc.messages = make([]*Message, 0)
 	cc.totalTokens = 0
 	cc.currentRound = 0
+	atomic.StoreInt64(&cc.messageCount, 0)
 
 	cc.updateTime()
 
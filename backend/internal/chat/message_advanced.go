@@ -1,8 +1,9 @@
 package chat
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -105,6 +106,13 @@ type MessageExport struct {
 	ExportedAt time.Time `json:"exported_at"`
 }
 
+// BranchStore 分支持久化存储，FindBranch 在分支不存在时返回 nil, nil
+type BranchStore interface {
+	SaveBranch(branch *MessageBranch) error
+	FindBranch(branchID string) (*MessageBranch, error)
+	FindBranchesForMessage(messageID string) ([]*MessageBranch, error)
+}
+
 // BranchManager 分支管理器
 type BranchManager struct {
 	// 分支存储
@@ -115,6 +123,9 @@ type BranchManager struct {
 	messageBranches map[string][]string // messageID -> branchIDs
 	messageBranchesMu sync.RWMutex
 
+	// 持久化存储，为空时只保存在内存中
+	store BranchStore
+
 	// 统计信息
 	totalBranches int64
 
@@ -131,6 +142,13 @@ func NewBranchManager() *BranchManager {
 	}
 }
 
+// NewPersistentBranchManager 创建写入 store 的分支管理器，内存中的分支作为缓存
+func NewPersistentBranchManager(store BranchStore) *BranchManager {
+	bm := NewBranchManager()
+	bm.store = store
+	return bm
+}
+
 // CreateBranch 创建分支
 func (bm *BranchManager) CreateBranch(sessionID, name, parentMessageID string) (*MessageBranch, error) {
 	bm.branchesMu.Lock()
@@ -148,6 +166,12 @@ func (bm *BranchManager) CreateBranch(sessionID, name, parentMessageID string) (
 		UpdatedAt:       time.Now(),
 	}
 
+	if bm.store != nil {
+		if err := bm.store.SaveBranch(branch); err != nil {
+			return nil, fmt.Errorf("failed to save branch: %w", err)
+		}
+	}
+
 	bm.branches[branchID] = branch
 
 	// 更新消息分支索引
@@ -162,17 +186,32 @@ func (bm *BranchManager) CreateBranch(sessionID, name, parentMessageID string) (
 	return branch, nil
 }
 
-// GetBranch 获取分支
+// GetBranch 获取分支，缓存中没有时从存储加载
 func (bm *BranchManager) GetBranch(branchID string) (*MessageBranch, error) {
-	bm.branchesMu.RLock()
-	defer bm.branchesMu.RUnlock()
+	bm.branchesMu.Lock()
+	defer bm.branchesMu.Unlock()
 
-	branch, exists := bm.branches[branchID]
-	if !exists {
-		return nil, fmt.Errorf("branch %s not found", branchID)
+	return bm.loadBranch(branchID)
+}
+
+// loadBranch 查找分支，调用方需持有 branchesMu 写锁
+func (bm *BranchManager) loadBranch(branchID string) (*MessageBranch, error) {
+	if branch, exists := bm.branches[branchID]; exists {
+		return branch, nil
 	}
 
-	return branch, nil
+	if bm.store != nil {
+		branch, err := bm.store.FindBranch(branchID)
+		if err != nil {
+			return nil, err
+		}
+		if branch != nil {
+			bm.branches[branchID] = branch
+			return branch, nil
+		}
+	}
+
+	return nil, fmt.Errorf("branch %s not found", branchID)
 }
 
 // AddMessageToBranch 添加消息到分支
@@ -180,19 +219,39 @@ func (bm *BranchManager) AddMessageToBranch(branchID, messageID string) error {
 	bm.branchesMu.Lock()
 	defer bm.branchesMu.Unlock()
 
-	branch, exists := bm.branches[branchID]
-	if !exists {
-		return fmt.Errorf("branch %s not found", branchID)
+	branch, err := bm.loadBranch(branchID)
+	if err != nil {
+		return err
+	}
+
+	updated := *branch
+	updated.MessageIDs = append(append(make([]string, 0, len(branch.MessageIDs)+1), branch.MessageIDs...), messageID)
+	updated.UpdatedAt = time.Now()
+
+	if bm.store != nil {
+		if err := bm.store.SaveBranch(&updated); err != nil {
+			return fmt.Errorf("failed to save branch: %w", err)
+		}
 	}
 
-	branch.MessageIDs = append(branch.MessageIDs, messageID)
-	branch.UpdatedAt = time.Now()
+	*branch = updated
 
 	return nil
 }
 
 // GetBranchesForMessage 获取消息的所有分支
+//
+// 使用持久化存储时从存储读取并刷新缓存，读取失败时返回缓存中的分支。
 func (bm *BranchManager) GetBranchesForMessage(messageID string) []*MessageBranch {
+	if bm.store != nil {
+		branches, err := bm.store.FindBranchesForMessage(messageID)
+		if err == nil {
+			bm.cacheBranches(messageID, branches)
+			return branches
+		}
+		bm.logFunc("warn", fmt.Sprintf("Failed to load branches for message %s: %v", messageID, err))
+	}
+
 	bm.messageBranchesMu.RLock()
 	branchIDs := bm.messageBranches[messageID]
 	bm.messageBranchesMu.RUnlock()
@@ -211,6 +270,22 @@ func (bm *BranchManager) GetBranchesForMessage(messageID string) []*MessageBranc
 	return branches
 }
 
+// cacheBranches 用存储中的分支刷新消息的分支缓存
+func (bm *BranchManager) cacheBranches(messageID string, branches []*MessageBranch) {
+	ids := make([]string, len(branches))
+
+	bm.branchesMu.Lock()
+	for i, branch := range branches {
+		ids[i] = branch.ID
+		bm.branches[branch.ID] = branch
+	}
+	bm.branchesMu.Unlock()
+
+	bm.messageBranchesMu.Lock()
+	bm.messageBranches[messageID] = ids
+	bm.messageBranchesMu.Unlock()
+}
+
 // GetStatistics 获取统计信息
 func (bm *BranchManager) GetStatistics() map[string]interface{} {
 	return map[string]interface{}{
@@ -451,39 +526,49 @@ func (em *ExportManager) ExportMessages(sessionID string, messages []*Message, f
 
 // formatMessages 格式化消息
 func (em *ExportManager) formatMessages(messages []*Message, format ExportFormat) (string, error) {
-	switch format {
-	case ExportFormatJSON:
-		data, err := json.MarshalIndent(messages, "", "  ")
-		if err != nil {
+	var buf strings.Builder
+	ew, err := NewExportWriter(&buf, format)
+	if err != nil {
+		return "", err
+	}
+	for _, msg := range messages {
+		if err := ew.WriteMessage(msg); err != nil {
 			return "", err
 		}
-		return string(data), nil
+	}
+	if err := ew.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
 
-	case ExportFormatMarkdown:
-		var result string
-		for _, msg := range messages {
-			result += fmt.Sprintf("### %s\n\n%s\n\n", msg.Role, msg.Content)
+// StreamMessages 将 next 返回的消息逐条导出到 w，next 返回 nil 时结束，返回导出的消息数
+//
+// 与 ExportMessages 不同，导出内容不保存在内存中，适合消息很多的会话。
+func (em *ExportManager) StreamMessages(w io.Writer, format ExportFormat, next func() (*Message, error)) (int, error) {
+	ew, err := NewExportWriter(w, format)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		msg, err := next()
+		if err != nil {
+			return ew.Count(), err
 		}
-		return result, nil
-
-	case ExportFormatHTML:
-		result := "<html><body>"
-		for _, msg := range messages {
-			result += fmt.Sprintf("<div class='message %s'><strong>%s:</strong> %s</div>", msg.Role, msg.Role, msg.Content)
+		if msg == nil {
+			break
 		}
-		result += "</body></html>"
-		return result, nil
-
-	case ExportFormatCSV:
-		result := "Role,Content,Tokens,Timestamp\n"
-		for _, msg := range messages {
-			result += fmt.Sprintf("%s,\"%s\",%d,%s\n", msg.Role, msg.Content, msg.Tokens, msg.Timestamp.Format(time.RFC3339))
+		if err := ew.WriteMessage(msg); err != nil {
+			return ew.Count(), err
 		}
-		return result, nil
-
-	default:
-		return "", fmt.Errorf("unsupported format: %s", format)
 	}
+	if err := ew.Close(); err != nil {
+		return ew.Count(), err
+	}
+
+	em.logFunc("info", fmt.Sprintf("Streamed %d messages to %s", ew.Count(), format))
+
+	return ew.Count(), nil
 }
 
 // GetExport 获取导出
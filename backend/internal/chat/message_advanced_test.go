@@ -1,6 +1,7 @@
 package chat
 
 import (
+	"fmt"
 	"testing"
 	"time"
 )
@@ -328,3 +329,87 @@ func BenchmarkExportJSON(b *testing.B) {
 	}
 }
 
+// memoryBranchStore 模拟数据库的分支存储
+type memoryBranchStore struct {
+	branches map[string]MessageBranch
+	failSave bool
+}
+
+func newMemoryBranchStore() *memoryBranchStore {
+	return &memoryBranchStore{branches: make(map[string]MessageBranch)}
+}
+
+func (s *memoryBranchStore) SaveBranch(branch *MessageBranch) error {
+	if s.failSave {
+		return fmt.Errorf("database unavailable")
+	}
+	stored := *branch
+	stored.MessageIDs = append([]string(nil), branch.MessageIDs...)
+	s.branches[branch.ID] = stored
+	return nil
+}
+
+func (s *memoryBranchStore) FindBranch(branchID string) (*MessageBranch, error) {
+	branch, ok := s.branches[branchID]
+	if !ok {
+		return nil, nil
+	}
+	return &branch, nil
+}
+
+func (s *memoryBranchStore) FindBranchesForMessage(messageID string) ([]*MessageBranch, error) {
+	var branches []*MessageBranch
+	for _, branch := range s.branches {
+		if branch.ParentMessageID == messageID {
+			b := branch
+			branches = append(branches, &b)
+		}
+	}
+	return branches, nil
+}
+
+func TestPersistentBranchManagerSurvivesRestart(t *testing.T) {
+	store := newMemoryBranchStore()
+	bm := NewPersistentBranchManager(store)
+
+	branch, err := bm.CreateBranch("session-1", "Regenerate", "msg-1")
+	if err != nil {
+		t.Fatalf("CreateBranch failed: %v", err)
+	}
+	if err := bm.AddMessageToBranch(branch.ID, "msg-2"); err != nil {
+		t.Fatalf("AddMessageToBranch failed: %v", err)
+	}
+
+	// 新的管理器（如服务重启后）从存储读取分支
+	restarted := NewPersistentBranchManager(store)
+	loaded, err := restarted.GetBranch(branch.ID)
+	if err != nil {
+		t.Fatalf("GetBranch after restart failed: %v", err)
+	}
+	if len(loaded.MessageIDs) != 1 || loaded.MessageIDs[0] != "msg-2" {
+		t.Errorf("Expected persisted message IDs [msg-2], got %v", loaded.MessageIDs)
+	}
+	if branches := restarted.GetBranchesForMessage("msg-1"); len(branches) != 1 || branches[0].ID != branch.ID {
+		t.Errorf("Expected persisted branch for msg-1, got %v", branches)
+	}
+	if _, err := restarted.GetBranch("missing"); err == nil {
+		t.Error("Expected error for unknown branch")
+	}
+}
+
+func TestPersistentBranchManagerSaveFailure(t *testing.T) {
+	store := newMemoryBranchStore()
+	bm := NewPersistentBranchManager(store)
+	branch, _ := bm.CreateBranch("session-1", "Regenerate", "msg-1")
+
+	store.failSave = true
+	if err := bm.AddMessageToBranch(branch.ID, "msg-2"); err == nil {
+		t.Fatal("Expected AddMessageToBranch to fail when the store fails")
+	}
+	if cached, _ := bm.GetBranch(branch.ID); len(cached.MessageIDs) != 0 {
+		t.Errorf("Expected cached branch to stay unchanged after a failed save, got %v", cached.MessageIDs)
+	}
+	if _, err := bm.CreateBranch("session-1", "Another", "msg-1"); err == nil {
+		t.Error("Expected CreateBranch to fail when the store fails")
+	}
+}
@@ -0,0 +1,146 @@
+package chat
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ParseExportFormat 解析导出格式，空字符串视为 Markdown
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch format := ExportFormat(s); format {
+	case "":
+		return ExportFormatMarkdown, nil
+	case ExportFormatJSON, ExportFormatMarkdown, ExportFormatHTML, ExportFormatCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s", s)
+	}
+}
+
+// ContentType 导出内容的 MIME 类型
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportFormatJSON:
+		return "application/json; charset=utf-8"
+	case ExportFormatHTML:
+		return "text/html; charset=utf-8"
+	case ExportFormatCSV:
+		return "text/csv; charset=utf-8"
+	default:
+		return "text/markdown; charset=utf-8"
+	}
+}
+
+// Extension 导出文件的扩展名
+func (f ExportFormat) Extension() string {
+	switch f {
+	case ExportFormatJSON:
+		return "json"
+	case ExportFormatHTML:
+		return "html"
+	case ExportFormatCSV:
+		return "csv"
+	default:
+		return "md"
+	}
+}
+
+// ExportWriter 将消息逐条写入 io.Writer，大会话导出时不必拼接完整内容
+//
+// 写完所有消息后必须调用 Close 写入结尾并刷新缓冲。
+type ExportWriter struct {
+	w      *bufio.Writer
+	csv    *csv.Writer
+	format ExportFormat
+	count  int
+}
+
+// NewExportWriter 创建指定格式的导出写入器
+func NewExportWriter(w io.Writer, format ExportFormat) (*ExportWriter, error) {
+	ew := &ExportWriter{w: bufio.NewWriter(w), format: format}
+	var err error
+	switch format {
+	case ExportFormatJSON, ExportFormatMarkdown:
+	case ExportFormatHTML:
+		_, err = ew.w.WriteString("<html><head><meta charset=\"utf-8\"></head><body>")
+	case ExportFormatCSV:
+		ew.csv = csv.NewWriter(ew.w)
+		err = ew.csv.Write([]string{"Role", "Content", "Tokens", "Timestamp"})
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ew, nil
+}
+
+// WriteMessage 写入一条消息
+func (ew *ExportWriter) WriteMessage(msg *Message) error {
+	var err error
+	switch ew.format {
+	case ExportFormatJSON:
+		var data []byte
+		data, err = json.MarshalIndent(msg, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		sep := ",\n  "
+		if ew.count == 0 {
+			sep = "[\n  "
+		}
+		if _, err = ew.w.WriteString(sep); err == nil {
+			_, err = ew.w.Write(data)
+		}
+
+	case ExportFormatMarkdown:
+		_, err = fmt.Fprintf(ew.w, "### %s\n\n%s\n\n", msg.Role, msg.Content)
+
+	case ExportFormatHTML:
+		role := html.EscapeString(msg.Role)
+		_, err = fmt.Fprintf(ew.w, "<div class='message %s'><strong>%s:</strong> %s</div>", role, role, html.EscapeString(msg.Content))
+
+	case ExportFormatCSV:
+		// encoding/csv 负责转义内容中的引号、逗号与换行
+		err = ew.csv.Write([]string{msg.Role, msg.Content, strconv.FormatInt(msg.Tokens, 10), msg.Timestamp.Format(time.RFC3339)})
+	}
+	if err != nil {
+		return err
+	}
+
+	ew.count++
+	return nil
+}
+
+// Count 已写入的消息数
+func (ew *ExportWriter) Count() int {
+	return ew.count
+}
+
+// Close 写入结尾并刷新缓冲，不关闭底层 io.Writer
+func (ew *ExportWriter) Close() error {
+	var err error
+	switch ew.format {
+	case ExportFormatJSON:
+		if ew.count == 0 {
+			_, err = ew.w.WriteString("[]")
+		} else {
+			_, err = ew.w.WriteString("\n]")
+		}
+	case ExportFormatHTML:
+		_, err = ew.w.WriteString("</body></html>")
+	case ExportFormatCSV:
+		ew.csv.Flush()
+		err = ew.csv.Error()
+	}
+	if err != nil {
+		return err
+	}
+	return ew.w.Flush()
+}
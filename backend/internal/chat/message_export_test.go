@@ -0,0 +1,150 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exportFixture 生成 n 条内容包含引号、逗号、换行与 HTML 的消息
+func exportFixture(n int) []*Message {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	messages := make([]*Message, n)
+	for i := range messages {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages[i] = &Message{
+			ID:        fmt.Sprintf("msg-%d", i),
+			Role:      role,
+			Content:   fmt.Sprintf("He said \"hi\", then left.\nLine 2 of #%d <b>bold</b>", i),
+			Tokens:    int64(i),
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+		}
+	}
+	return messages
+}
+
+// sliceIterator 按顺序返回消息，结束后返回 nil
+func sliceIterator(messages []*Message) func() (*Message, error) {
+	i := 0
+	return func() (*Message, error) {
+		if i >= len(messages) {
+			return nil, nil
+		}
+		i++
+		return messages[i-1], nil
+	}
+}
+
+// countingWriter 记录写入次数，用于确认导出是分段写出的
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestStreamMessagesCSVLargeSession(t *testing.T) {
+	messages := exportFixture(10000)
+	em := NewExportManager()
+
+	var out countingWriter
+	n, err := em.StreamMessages(&out, ExportFormatCSV, sliceIterator(messages))
+	if err != nil {
+		t.Fatalf("StreamMessages failed: %v", err)
+	}
+	if n != len(messages) {
+		t.Fatalf("Expected %d exported messages, got %d", len(messages), n)
+	}
+	if out.writes < 2 {
+		t.Errorf("Expected output to be written in several chunks, got %d writes", out.writes)
+	}
+
+	records, err := csv.NewReader(&out.Buffer).ReadAll()
+	if err != nil {
+		t.Fatalf("Export is not valid CSV: %v", err)
+	}
+	if len(records) != len(messages)+1 {
+		t.Fatalf("Expected %d CSV records, got %d", len(messages)+1, len(records))
+	}
+	for i, msg := range messages {
+		rec := records[i+1]
+		if rec[0] != msg.Role || rec[1] != msg.Content || rec[2] != fmt.Sprint(msg.Tokens) || rec[3] != msg.Timestamp.Format(time.RFC3339) {
+			t.Fatalf("Record %d does not round-trip: %q", i, rec)
+		}
+	}
+}
+
+func TestStreamMessagesJSONMatchesExport(t *testing.T) {
+	messages := exportFixture(10000)
+	em := NewExportManager()
+
+	var out bytes.Buffer
+	if _, err := em.StreamMessages(&out, ExportFormatJSON, sliceIterator(messages)); err != nil {
+		t.Fatalf("StreamMessages failed: %v", err)
+	}
+
+	want, _ := json.MarshalIndent(messages, "", "  ")
+	if out.String() != string(want) {
+		t.Error("Expected streamed JSON to match json.MarshalIndent output")
+	}
+
+	out.Reset()
+	if _, err := em.StreamMessages(&out, ExportFormatJSON, sliceIterator(nil)); err != nil || out.String() != "[]" {
+		t.Errorf("Expected empty JSON array, got %q (%v)", out.String(), err)
+	}
+}
+
+func TestStreamMessagesEscapesHTML(t *testing.T) {
+	var out bytes.Buffer
+	if _, err := NewExportManager().StreamMessages(&out, ExportFormatHTML, sliceIterator(exportFixture(1))); err != nil {
+		t.Fatalf("StreamMessages failed: %v", err)
+	}
+	html := out.String()
+	if strings.Contains(html, "<b>") || !strings.Contains(html, "&lt;b&gt;bold&lt;/b&gt;") {
+		t.Errorf("Expected message content to be HTML-escaped, got %s", html)
+	}
+	if !strings.HasSuffix(html, "</body></html>") {
+		t.Errorf("Expected closing tags, got %s", html)
+	}
+}
+
+func TestStreamMessagesStopsOnError(t *testing.T) {
+	calls := 0
+	next := func() (*Message, error) {
+		calls++
+		if calls > 3 {
+			return nil, fmt.Errorf("database gone")
+		}
+		return &Message{Role: "user", Content: "hi"}, nil
+	}
+
+	n, err := NewExportManager().StreamMessages(&bytes.Buffer{}, ExportFormatMarkdown, next)
+	if err == nil || n != 3 {
+		t.Errorf("Expected error after 3 messages, got n=%d err=%v", n, err)
+	}
+}
+
+func TestParseExportFormat(t *testing.T) {
+	if f, err := ParseExportFormat(""); err != nil || f != ExportFormatMarkdown {
+		t.Errorf("Expected markdown by default, got %q (%v)", f, err)
+	}
+	if f, _ := ParseExportFormat("csv"); f.ContentType() != "text/csv; charset=utf-8" || f.Extension() != "csv" {
+		t.Errorf("Unexpected CSV content type or extension")
+	}
+	if _, err := ParseExportFormat("pdf"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+	if _, err := NewExportWriter(&bytes.Buffer{}, "pdf"); err == nil {
+		t.Error("Expected NewExportWriter to reject unsupported format")
+	}
+}
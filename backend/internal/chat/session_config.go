@@ -28,6 +28,16 @@ type SessionConfig struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// snapshot 复制一份配置用于版本历史，避免后续修改影响历史版本
+func (sc *SessionConfig) snapshot() *SessionConfig {
+	copied := *sc
+	copied.Config = make(map[string]interface{}, len(sc.Config))
+	for k, v := range sc.Config {
+		copied.Config[k] = v
+	}
+	return &copied
+}
+
 // SessionConfigManager 会话配置管理器
 type SessionConfigManager struct {
 	// 配置存储
@@ -74,7 +84,7 @@ func (scm *SessionConfigManager) CreateConfig(sessionID string, config map[strin
 
 	// 记录版本历史
 	scm.versionsMu.Lock()
-	scm.versions[sessionID] = append(scm.versions[sessionID], cfg)
+	scm.versions[sessionID] = append(scm.versions[sessionID], cfg.snapshot())
 	scm.versionsMu.Unlock()
 
 	scm.logFunc("info", fmt.Sprintf("Created config for session %s", sessionID))
@@ -111,7 +121,7 @@ func (scm *SessionConfigManager) UpdateConfig(sessionID string, config map[strin
 
 	// 记录版本历史
 	scm.versionsMu.Lock()
-	scm.versions[sessionID] = append(scm.versions[sessionID], cfg)
+	scm.versions[sessionID] = append(scm.versions[sessionID], cfg.snapshot())
 	scm.versionsMu.Unlock()
 
 	scm.logFunc("info", fmt.Sprintf("Updated config for session %s (version %d)", sessionID, cfg.Version))
@@ -139,7 +149,7 @@ func (scm *SessionConfigManager) MergeConfig(sessionID string, updates map[strin
 
 	// 记录版本历史
 	scm.versionsMu.Lock()
-	scm.versions[sessionID] = append(scm.versions[sessionID], cfg)
+	scm.versions[sessionID] = append(scm.versions[sessionID], cfg.snapshot())
 	scm.versionsMu.Unlock()
 
 	return cfg, nil
@@ -245,7 +255,7 @@ func (scm *SessionConfigManager) SetConfigValue(sessionID string, key string, va
 
 	// 记录版本历史
 	scm.versionsMu.Lock()
-	scm.versions[sessionID] = append(scm.versions[sessionID], cfg)
+	scm.versions[sessionID] = append(scm.versions[sessionID], cfg.snapshot())
 	scm.versionsMu.Unlock()
 
 	return cfg, nil
@@ -0,0 +1,321 @@
+package clientexample
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// 支持的语言
+const (
+	LangCurl   = "curl"
+	LangPython = "python"
+	LangNode   = "node"
+	LangGo     = "go"
+)
+
+// Languages 支持的语言，按展示顺序
+var Languages = []string{LangCurl, LangPython, LangNode, LangGo}
+
+// 示例类型
+const (
+	KindChat       = "chat"
+	KindChatStream = "chat_stream"
+	KindEmbeddings = "embeddings"
+)
+
+// ErrUnsupportedLanguage 不支持的语言
+var ErrUnsupportedLanguage = errors.New("unsupported language")
+
+// Params 生成示例所需的部署与调用方信息
+type Params struct {
+	BaseURL        string // 对外 API 地址，如 https://api.example.com/v1
+	Model          string // Chat 示例使用的模型
+	EmbeddingModel string // Embedding 示例使用的模型
+	APIKey         string // 密钥占位符或调用方最新 Token 的掩码预览
+	KeyNote        string // 关于密钥的提示，写入示例注释，可为空
+}
+
+// Snippet 一段可直接粘贴的示例代码
+type Snippet struct {
+	Kind     string `json:"kind"`
+	Title    string `json:"title"`
+	Language string `json:"language"`
+	Code     string `json:"code"`
+}
+
+// snippetTitles 示例标题
+var snippetTitles = map[string]string{
+	KindChat:       "Chat Completion",
+	KindChatStream: "Chat Completion（流式）",
+	KindEmbeddings: "Embeddings",
+}
+
+// Render 按语言生成 Chat（非流式、流式）与 Embedding 示例
+func Render(lang string, p Params) ([]Snippet, error) {
+	tmpl, ok := templates[lang]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, lang)
+	}
+
+	p.BaseURL = strings.TrimRight(p.BaseURL, "/")
+	snippets := make([]Snippet, 0, 3)
+	for _, kind := range []string{KindChat, KindChatStream, KindEmbeddings} {
+		data := &templateData{
+			Params:   p,
+			Stream:   kind == KindChatStream,
+			Endpoint: "/chat/completions",
+		}
+		body := chatBody(p.Model, data.Stream)
+		if kind == KindEmbeddings {
+			data.Endpoint = "/embeddings"
+			body = embeddingBody(p.EmbeddingModel)
+		}
+		raw, err := json.MarshalIndent(body, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		data.Body = string(raw)
+
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, kind, data); err != nil {
+			return nil, fmt.Errorf("render %s %s example: %w", lang, kind, err)
+		}
+		snippets = append(snippets, Snippet{
+			Kind:     kind,
+			Title:    snippetTitles[kind],
+			Language: lang,
+			Code:     buf.String(),
+		})
+	}
+	return snippets, nil
+}
+
+// templateData 模板变量
+type templateData struct {
+	Params
+	Stream   bool
+	Endpoint string
+	Body     string // 请求体 JSON
+}
+
+// chatBody Chat Completion 请求体，字段顺序固定以便示例稳定
+func chatBody(modelName string, stream bool) interface{} {
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	return struct {
+		Model    string    `json:"model"`
+		Messages []message `json:"messages"`
+		Stream   bool      `json:"stream,omitempty"`
+	}{
+		Model:    modelName,
+		Messages: []message{{Role: "user", Content: "Hello!"}},
+		Stream:   stream,
+	}
+}
+
+// embeddingBody Embedding 请求体
+func embeddingBody(modelName string) interface{} {
+	return struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}{
+		Model: modelName,
+		Input: []string{"The quick brown fox"},
+	}
+}
+
+// templateFuncs 模板中的转义函数
+var templateFuncs = template.FuncMap{
+	// shell 单引号字符串
+	"shell": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	},
+	// Python、JavaScript、Go 通用的双引号字符串
+	"quote": func(s string) string {
+		data, _ := json.Marshal(s)
+		return string(data)
+	},
+	// Go 原始字符串不能包含反引号
+	"raw": func(s string) string {
+		return "`" + strings.ReplaceAll(s, "`", "` + \"`\" + `") + "`"
+	},
+}
+
+// templates 每种语言一组模板，模板名为示例类型
+var templates = map[string]*template.Template{
+	LangCurl:   template.Must(template.New(LangCurl).Funcs(templateFuncs).Parse(curlTemplates)),
+	LangPython: template.Must(template.New(LangPython).Funcs(templateFuncs).Parse(pythonTemplates)),
+	LangNode:   template.Must(template.New(LangNode).Funcs(templateFuncs).Parse(nodeTemplates)),
+	LangGo:     template.Must(template.New(LangGo).Funcs(templateFuncs).Parse(goTemplates)),
+}
+
+const curlTemplates = `
+{{- define "request" -}}
+{{if .KeyNote}}# {{.KeyNote}}
+{{end}}curl {{if .Stream}}-N {{end}}{{shell (print .BaseURL .Endpoint)}} \
+  -H "Content-Type: application/json" \
+  -H {{shell (print "Authorization: Bearer " .APIKey)}} \
+  -d {{shell .Body}}
+{{end -}}
+{{define "chat"}}{{template "request" .}}{{end}}
+{{- define "chat_stream"}}{{template "request" .}}{{end}}
+{{- define "embeddings"}}{{template "request" .}}{{end}}`
+
+const pythonTemplates = `
+{{- define "client" -}}
+from openai import OpenAI
+
+{{if .KeyNote}}# {{.KeyNote}}
+{{end}}client = OpenAI(base_url={{quote .BaseURL}}, api_key={{quote .APIKey}})
+{{end -}}
+{{define "chat"}}{{template "client" .}}
+resp = client.chat.completions.create(
+    model={{quote .Model}},
+    messages=[{"role": "user", "content": "Hello!"}],
+)
+print(resp.choices[0].message.content)
+{{end}}
+{{- define "chat_stream"}}{{template "client" .}}
+stream = client.chat.completions.create(
+    model={{quote .Model}},
+    messages=[{"role": "user", "content": "Hello!"}],
+    stream=True,
+)
+for chunk in stream:
+    if chunk.choices and chunk.choices[0].delta.content:
+        print(chunk.choices[0].delta.content, end="", flush=True)
+print()
+{{end}}
+{{- define "embeddings"}}{{template "client" .}}
+resp = client.embeddings.create(
+    model={{quote .EmbeddingModel}},
+    input=["The quick brown fox"],
+)
+print(len(resp.data[0].embedding))
+{{end}}`
+
+const nodeTemplates = `
+{{- define "client" -}}
+import OpenAI from "openai";
+
+{{if .KeyNote}}// {{.KeyNote}}
+{{end}}const client = new OpenAI({ baseURL: {{quote .BaseURL}}, apiKey: {{quote .APIKey}} });
+{{end -}}
+{{define "chat"}}{{template "client" .}}
+const resp = await client.chat.completions.create({
+  model: {{quote .Model}},
+  messages: [{ role: "user", content: "Hello!" }],
+});
+console.log(resp.choices[0].message.content);
+{{end}}
+{{- define "chat_stream"}}{{template "client" .}}
+const stream = await client.chat.completions.create({
+  model: {{quote .Model}},
+  messages: [{ role: "user", content: "Hello!" }],
+  stream: true,
+});
+for await (const chunk of stream) {
+  process.stdout.write(chunk.choices[0]?.delta?.content ?? "");
+}
+process.stdout.write("\n");
+{{end}}
+{{- define "embeddings"}}{{template "client" .}}
+const resp = await client.embeddings.create({
+  model: {{quote .EmbeddingModel}},
+  input: ["The quick brown fox"],
+});
+console.log(resp.data[0].embedding.length);
+{{end}}`
+
+const goTemplates = `
+{{- define "chat" -}}
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+func main() {
+	body := {{raw .Body}}
+	req, err := http.NewRequest("POST", {{quote (print .BaseURL .Endpoint)}}, strings.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+{{- if .KeyNote}}
+	// {{.KeyNote}}
+{{- end}}
+	req.Header.Set("Authorization", {{quote (print "Bearer " .APIKey)}})
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(data))
+}
+{{end}}
+{{- define "chat_stream" -}}
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func main() {
+	body := {{raw .Body}}
+	req, err := http.NewRequest("POST", {{quote (print .BaseURL .Endpoint)}}, strings.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+{{- if .KeyNote}}
+	// {{.KeyNote}}
+{{- end}}
+	req.Header.Set("Authorization", {{quote (print "Bearer " .APIKey)}})
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "data: ")
+		if line == "" || line == "[DONE]" {
+			continue
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string ` + "`json:\"content\"`" + `
+				} ` + "`json:\"delta\"`" + `
+			} ` + "`json:\"choices\"`" + `
+		}
+		if json.Unmarshal([]byte(line), &chunk) == nil && len(chunk.Choices) > 0 {
+			fmt.Print(chunk.Choices[0].Delta.Content)
+		}
+	}
+	fmt.Println()
+}
+{{end}}
+{{- define "embeddings"}}{{template "chat" .}}{{end}}`
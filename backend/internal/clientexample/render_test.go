@@ -0,0 +1,118 @@
+package clientexample
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testParams() Params {
+	return Params{
+		BaseURL:        "https://api.example.com/v1/",
+		Model:          "gpt-4o-mini",
+		EmbeddingModel: "text-embedding-3-small",
+		APIKey:         "sk-ab12...9xyz",
+		KeyNote:        "完整密钥请在令牌页面查看",
+	}
+}
+
+// requestBody 从示例中截取请求体 JSON
+func requestBody(t *testing.T, lang, code string) map[string]interface{} {
+	t.Helper()
+	var raw string
+	switch lang {
+	case LangCurl:
+		start := strings.Index(code, "-d '")
+		require.NotEqual(t, -1, start)
+		raw = strings.TrimSuffix(strings.TrimSpace(code[start+len("-d '"):]), "'")
+		raw = strings.ReplaceAll(raw, `'\''`, "'")
+	case LangGo:
+		start := strings.Index(code, "body := `")
+		require.NotEqual(t, -1, start)
+		rest := code[start+len("body := `"):]
+		raw = rest[:strings.Index(rest, "`")]
+	}
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(raw), &body), "request body must be valid JSON:\n%s", raw)
+	return body
+}
+
+func TestRenderAllLanguages(t *testing.T) {
+	for _, lang := range Languages {
+		snippets, err := Render(lang, testParams())
+		require.NoError(t, err, lang)
+		require.Len(t, snippets, 3, lang)
+
+		kinds := []string{KindChat, KindChatStream, KindEmbeddings}
+		for i, s := range snippets {
+			assert.Equal(t, kinds[i], s.Kind)
+			assert.Equal(t, lang, s.Language)
+			assert.NotEmpty(t, s.Title)
+			assert.Contains(t, s.Code, "sk-ab12...9xyz", "%s %s uses the key preview", lang, s.Kind)
+			assert.Contains(t, s.Code, "完整密钥请在令牌页面查看")
+			assert.NotContains(t, s.Code, "v1//", "base url is normalized")
+		}
+		assert.Contains(t, snippets[0].Code, "gpt-4o-mini")
+		assert.Contains(t, snippets[2].Code, "text-embedding-3-small")
+	}
+}
+
+func TestRenderGoCompiles(t *testing.T) {
+	snippets, err := Render(LangGo, testParams())
+	require.NoError(t, err)
+
+	for _, s := range snippets {
+		_, err := parser.ParseFile(token.NewFileSet(), s.Kind+".go", s.Code, parser.AllErrors)
+		require.NoError(t, err, "%s:\n%s", s.Kind, s.Code)
+
+		body := requestBody(t, LangGo, s.Code)
+		switch s.Kind {
+		case KindChat:
+			assert.Equal(t, "gpt-4o-mini", body["model"])
+			assert.NotContains(t, body, "stream")
+			assert.Contains(t, s.Code, `"https://api.example.com/v1/chat/completions"`)
+		case KindChatStream:
+			assert.Equal(t, true, body["stream"])
+		case KindEmbeddings:
+			assert.Equal(t, "text-embedding-3-small", body["model"])
+			assert.Contains(t, s.Code, `"https://api.example.com/v1/embeddings"`)
+		}
+	}
+}
+
+func TestRenderCurlBodies(t *testing.T) {
+	p := testParams()
+	p.Model = "team's-model" // 需要 shell 转义
+	snippets, err := Render(LangCurl, p)
+	require.NoError(t, err)
+
+	body := requestBody(t, LangCurl, snippets[0].Code)
+	assert.Equal(t, "team's-model", body["model"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"role": "user", "content": "Hello!"}}, body["messages"])
+
+	assert.Contains(t, snippets[1].Code, "curl -N ")
+	assert.Equal(t, true, requestBody(t, LangCurl, snippets[1].Code)["stream"])
+	assert.Equal(t, []interface{}{"The quick brown fox"}, requestBody(t, LangCurl, snippets[2].Code)["input"])
+}
+
+func TestRenderWithoutKeyNote(t *testing.T) {
+	p := testParams()
+	p.APIKey = "YOUR_API_KEY"
+	p.KeyNote = ""
+
+	snippets, err := Render(LangPython, p)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(snippets[0].Code, "from openai import OpenAI\n\nclient = OpenAI("), snippets[0].Code)
+	assert.Contains(t, snippets[1].Code, "stream=True")
+}
+
+func TestRenderUnsupportedLanguage(t *testing.T) {
+	_, err := Render("ruby", testParams())
+	assert.ErrorIs(t, err, ErrUnsupportedLanguage)
+}
@@ -1,19 +1,52 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	App      AppConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	Services ServicesConfig
+	App            AppConfig
+	Log            LogConfig
+	Database       DatabaseConfig
+	Redis          RedisConfig
+	JWT            JWTConfig
+	Services       ServicesConfig
+	LogArchive     LogArchiveConfig
+	Discovery      ModelDiscoveryConfig
+	Internal       InternalConfig
+	QuotaCheck     QuotaCheckConfig
+	QuotaReserve   QuotaReserveConfig
+	AbilityCheck   AbilityCheckConfig
+	Failover       FailoverConfig
+	LogTail        LogTailConfig
+	RelayLog       RelayLogConfig
+	Chat           ChatConfig
+	CapProbe       CapabilityProbeConfig
+	DataExport     DataExportConfig
+	RateLimit      RateLimitConfig
+	ClientExamples ClientExamplesConfig
+	Scaling        ScalingConfig
+	File           FileConfig
+	Stripe         StripeConfig
+	Alert          AlertConfig
+	TokenExpiry    TokenExpiryConfig
+	Shutdown       ShutdownConfig
+	Tracing        TracingConfig
+	ResponseCache  ResponseCacheConfig
+	Batch          BatchConfig
+	EmailVerify    EmailVerificationConfig
+	OAuth          OAuthConfig
 }
 
 type AppConfig struct {
@@ -22,6 +55,11 @@ type AppConfig struct {
 	Port int
 }
 
+// LogConfig 日志配置，可通过 SIGHUP 热加载
+type LogConfig struct {
+	Level string // debug、info、warn、error，为空时按 APP_ENV 使用默认级别
+}
+
 type DatabaseConfig struct {
 	Host         string
 	Port         int
@@ -33,12 +71,72 @@ type DatabaseConfig struct {
 	MaxIdleConns int
 }
 
+// Redis 部署模式
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
 type RedisConfig struct {
-	Host     string
-	Port     int
-	Password string
-	DB       int
-	PoolSize int
+	Mode         string
+	Host         string
+	Port         int
+	Addrs        []string // 集群模式的种子节点
+	Username     string
+	Password     string
+	DB           int
+	PoolSize     int
+	MinIdleConns int
+
+	// Sentinel 模式
+	MasterName       string
+	SentinelAddrs    []string
+	SentinelPassword string
+
+	// TLS
+	TLSEnabled    bool
+	TLSSkipVerify bool
+	TLSServerName string
+	TLSCAFile     string
+
+	DialTimeout         time.Duration
+	ReadTimeout         time.Duration
+	WriteTimeout        time.Duration
+	MaxRetries          int
+	HealthCheckInterval time.Duration // 连接健康检查间隔，0 表示关闭
+}
+
+// Addr 单机模式地址
+func (r *RedisConfig) Addr() string {
+	return fmt.Sprintf("%s:%d", r.Host, r.Port)
+}
+
+// Validate 校验 Redis 配置
+func (r *RedisConfig) Validate() error {
+	switch r.Mode {
+	case RedisModeStandalone:
+		if r.Host == "" {
+			return fmt.Errorf("REDIS_HOST must be set in standalone mode")
+		}
+	case RedisModeSentinel:
+		if r.MasterName == "" {
+			return fmt.Errorf("REDIS_MASTER_NAME must be set in sentinel mode")
+		}
+		if len(r.SentinelAddrs) == 0 {
+			return fmt.Errorf("REDIS_SENTINEL_ADDRS must be set in sentinel mode")
+		}
+	case RedisModeCluster:
+		if len(r.Addrs) == 0 {
+			return fmt.Errorf("REDIS_ADDRS must be set in cluster mode")
+		}
+		if r.DB != 0 {
+			return fmt.Errorf("REDIS_DB is not supported in cluster mode")
+		}
+	default:
+		return fmt.Errorf("invalid REDIS_MODE %q, expected standalone, sentinel or cluster", r.Mode)
+	}
+	return nil
 }
 
 type JWTConfig struct {
@@ -52,18 +150,257 @@ type ServicesConfig struct {
 	ChatServiceURL    string
 	RelayServiceURL   string
 	BillingServiceURL string
+	KBServiceURL      string // 对话检索知识库时调用的知识库服务
+}
+
+// LogArchiveConfig 统一日志分区归档配置
+type LogArchiveConfig struct {
+	Enabled       bool
+	HotMonths     int    // 在线保留的完整月数
+	FutureMonths  int    // 提前创建的分区月数
+	StorageDir    string // 归档文件目录（本地对象存储）
+	IntervalHours int    // 维护任务执行间隔
 }
 
+// ModelDiscoveryConfig 自托管渠道（Ollama/vLLM/LM Studio）模型发现配置
+type ModelDiscoveryConfig struct {
+	Enabled         bool
+	IntervalSeconds int // 默认轮询间隔，渠道可在 other_settings 中单独覆盖
+}
+
+// InternalConfig 服务间内部调用配置
+type InternalConfig struct {
+	Secret string // 内部请求签名密钥
+}
+
+// QuotaCheckConfig 网关转发前的额度预检配置
+type QuotaCheckConfig struct {
+	Enabled         bool
+	TimeoutMs       int  // 调用计费服务的超时
+	FailOpen        bool // 计费服务不可用时是否放行
+	CacheTTLSeconds int  // 单个用户检查结果的缓存时间
+	EstimatedCost   int  // 单次请求的预估费用（额度单位）
+}
+
+// AbilityCheckConfig 渠道能力一致性定时检查配置（只读，不自动修复）
+type AbilityCheckConfig struct {
+	Enabled        bool
+	IntervalHours  int    // 检查间隔，默认每周一次
+	DriftThreshold int    // 不一致数量超过该值时发送通知
+	WebhookURL     string // 通知地址，为空时只记录告警日志
+}
+
+// QuotaReserveConfig 中转额度预检配置：上游调用前预留估算费用
+type QuotaReserveConfig struct {
+	Enabled          bool
+	DefaultMaxTokens int // 请求未指定 max_tokens 时预估的输出 Token 数
+	TTLSeconds       int // 预留有效期（秒），超时未结算的预留被释放
+}
+
+// FailoverConfig 中转渠道故障转移配置
+type FailoverConfig struct {
+	MaxRetries           int     // 单次请求最多切换的渠道数
+	PartialFailureWeight float64 // 流式响应中途中断计入断路器的权重，1 表示等同一次完整失败
+	HealthCheckSeconds   int     // 渠道健康探测间隔（秒），0 表示只在管理员手动触发时探测
+	QueueTimeoutMillis   int     // 渠道并发已满且没有其它可用渠道时排队等待的最长时间，0 表示直接返回 429
+}
+
+// LogTailConfig 管理员实时跟踪用户请求的配置
+type LogTailConfig struct {
+	BufferSize  int // 每个跟踪缓存的事件数
+	IdleMinutes int // 无新请求超过该时长自动结束
+	MaxPerAdmin int // 每个管理员同时打开的跟踪数
+}
+
+// RelayLogConfig 中转统一日志的异步批量写入配置
+type RelayLogConfig struct {
+	BufferSize   int // 待写入日志的缓冲数，满时丢弃新日志
+	BatchSize    int // 每批写入的最大条数
+	FlushMillis  int // 未攒满一批时的最长等待（毫秒）
+	MaxBodyBytes int // 开启请求体日志的渠道，请求体与响应体保留的最大字节数
+}
+
+// ChatConfig 对话上下文裁剪配置
+type ChatConfig struct {
+	ContextStrategy      string // tokens 或 sliding_window
+	ContextWindowSize    int    // sliding_window 策略保留的最近消息数
+	DefaultContextWindow int    // 渠道未声明上下文窗口时使用的窗口，0 表示不裁剪
+	CompletionReserve    int    // 会话未设置 max_tokens 时为输出预留的 Token 数
+	TitleModel           string // 自动生成会话标题使用的模型，为空时不生成
+	SessionRetentionDays int    // 已删除会话的保留天数，之后永久删除
+}
+
+// CapabilityProbeConfig 渠道能力探测配置
+type CapabilityProbeConfig struct {
+	SystemUserID    int // 探测用量记入的系统账号
+	IntervalSeconds int // 同一渠道两次探测的最小间隔
+}
+
+// DataExportConfig 用户数据导出配置
+type DataExportConfig struct {
+	StorageDir    string // 导出归档目录（本地对象存储）
+	FilesDir      string // 知识库文档原始文件所在目录
+	RetentionDays int    // 归档保留天数，过期后清理
+	LinkTTLHours  int    // 下载链接有效期
+	PublicURL     string // 下载链接的对外地址
+	WebhookURL    string // 导出完成通知的 Webhook，为空时只记录日志
+}
+
+// RateLimitConfig 网关限流配置（滑动窗口，计数保存在 Redis）
+type RateLimitConfig struct {
+	Enabled       bool
+	WindowSeconds int         // 滑动窗口长度
+	PublicLimit   int         // 公开接口每个 IP 在窗口内的请求数
+	UserLimit     int         // 鉴权接口每个用户或 API Token 在窗口内的请求数
+	RoleLimits    map[int]int // 角色下限 → 窗口内请求数，覆盖 UserLimit
+}
+
+// FileConfig 文件服务配置
+type FileConfig struct {
+	StorageBackend   string   // local 或 s3
+	StorageDir       string   // 本地存储目录
+	S3Endpoint       string   // S3 兼容服务地址，如 https://s3.amazonaws.com 或 MinIO 地址
+	S3Region         string   // 签名使用的区域
+	S3Bucket         string   // 存储桶
+	S3AccessKey      string   // 访问密钥 ID
+	S3SecretKey      string   // 访问密钥
+	S3PathStyle      bool     // 使用路径风格（MinIO 等需要）
+	MaxUploadMB      int      // 单个文件的最大大小
+	AllowedMIMETypes []string // 允许上传的 MIME 类型，支持 image/* 形式，为空时使用默认列表
+}
+
+// StripeConfig 预付费充值的 Stripe Checkout 配置，SecretKey 为空时不启用
+type StripeConfig struct {
+	SecretKey     string
+	WebhookSecret string // Webhook 签名密钥（whsec_...）
+	APIBase       string // Stripe API 地址
+	Currency      string // 套餐计价货币
+	Packages      string // 额度套餐，格式 "id:quota:amount_cents"，逗号分隔
+	SuccessURL    string // 支付完成后的跳转地址
+	CancelURL     string // 取消支付后的跳转地址
+}
+
+// AlertConfig 配额预警通知配置，SMTPAddr 为空时不发送邮件
+type AlertConfig struct {
+	CooldownMinutes   int    // 同一用户同一等级的预警在该时间内只发送一次
+	SMTPAddr          string // SMTP 服务器地址 host:port
+	SMTPUsername      string // 为空时不认证
+	SMTPPassword      string
+	SMTPFrom          string // 发件人
+	EmailTemplateFile string // 邮件模板（text/template，含邮件头），为空时使用默认模板
+}
+
+// EmailVerificationConfig 注册邮箱验证配置，关闭时注册的账号立即激活；验证邮件使用 Alert 的 SMTP 配置发送
+type EmailVerificationConfig struct {
+	Enabled               bool
+	TokenTTLMinutes       int    // 验证链接的有效期
+	ResendIntervalSeconds int    // 同一邮箱重发验证邮件的最短间隔
+	PublicURL             string // 验证链接的对外地址前缀，链接为 PublicURL/api/v1/verify-email?token=...
+}
+
+// OAuthConfig 第三方账号登录配置，提供方在设置了 Client ID 时启用
+type OAuthConfig struct {
+	GitHubClientID     string
+	GitHubClientSecret string
+	GoogleClientID     string
+	GoogleClientSecret string
+	RedirectBaseURL    string // 对外访问的服务地址，回调为 RedirectBaseURL/api/v1/oauth/{provider}/callback
+	StateTTLSeconds    int    // 授权状态的有效期
+}
+
+// Enabled 是否启用了任一提供方
+func (c OAuthConfig) Enabled() bool {
+	return c.GitHubClientID != "" || c.GoogleClientID != ""
+}
+
+// TokenExpiryConfig API Token 过期检查配置
+type TokenExpiryConfig struct {
+	Enabled         bool
+	IntervalMinutes int // 检查间隔
+	SessionIdleDays int // 登录会话超过该天数未使用时由过期检查删除，0 表示不清理
+}
+
+// ShutdownConfig 优雅关闭配置
+type ShutdownConfig struct {
+	DrainTimeoutSeconds int // 收到退出信号后等待进行中请求完成的最长时间
+}
+
+// TracingConfig 分布式追踪配置，span 通过 OTLP/HTTP 导出
+type TracingConfig struct {
+	Enabled      bool
+	OTLPEndpoint string  // OTLP/HTTP 接收端地址，如 http://otel-collector:4318，为空时使用 OTEL_EXPORTER_OTLP_ENDPOINT
+	SampleRate   float64 // 没有上游决定时新建追踪的采样比例，已有父 span 时沿用父 span 的采样决定
+}
+
+// ResponseCacheConfig 中转非流式 Chat Completion 的响应缓存配置，请求需通过 cache 字段或 X-Relay-Cache 请求头开启
+type ResponseCacheConfig struct {
+	Enabled        bool
+	TTLSeconds     int     // 缓存条目有效期
+	MaxEntries     int     // Redis 中的最大条目数，超出时删除最早写入的条目，0 表示不限制
+	LocalSize      int     // 进程内 LRU 的最大条目数，0 表示不使用
+	MaxTemperature float64 // temperature 超过该值的请求不缓存（X-Relay-Cache: force 除外）
+	UserIDs        []int   // 允许使用缓存的用户，为空表示所有用户
+}
+
+// BatchConfig 批量 Chat Completion 任务配置
+type BatchConfig struct {
+	Concurrency         int // 同时处理的条目数（所有任务共享）
+	MaxItems            int // 单个任务的最大条目数
+	ReservationTTLHours int // 创建任务时预留额度的有效期，需覆盖任务的执行时间
+}
+
+// ScalingConfig 并发准入与扩缩容信号的上限（软上限为硬上限的 80%）
+type ScalingConfig struct {
+	RelayMaxInFlight       int // 中转同时执行的请求数，0 表示不限制
+	RelayMaxQueue          int // 中转每个优先级的等待队列长度
+	BillingMaxEventAgeSecs int // 计费事件最长可接受的积压时长
+	ChatMaxStreams         int // 对话服务单实例可承受的流式响应数
+}
+
+// ClientExamplesConfig 接入引导页的 API 调用示例配置
+type ClientExamplesConfig struct {
+	BaseURL        string // 示例中使用的对外 API 地址
+	Model          string // 覆盖推荐的示例模型，为空时按调用方推荐
+	EmbeddingModel string // 覆盖 Embedding 示例模型
+}
+
+// loadMu 保护一次加载过程中的配置文件内容与解析错误
+var (
+	loadMu       sync.Mutex
+	fileValues   map[string]string
+	loadProblems []string
+)
+
+// Load 加载并校验配置，任一配置项无效时返回列出全部问题的 *ValidationError
+//
+// 配置来源按优先级从高到低为：环境变量、.env 文件、CONFIG_FILE 指定的 YAML 文件、默认值。
+// YAML 的嵌套键按下划线连接并转为大写后与环境变量同名，如 rate_limit.user_limit 对应 RATE_LIMIT_USER_LIMIT。
 func Load() (*Config, error) {
 	// 尝试加载 .env 文件
 	_ = godotenv.Load()
 
+	loadMu.Lock()
+	defer loadMu.Unlock()
+	fileValues, loadProblems = nil, nil
+	defer func() { fileValues, loadProblems = nil, nil }()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		values, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		fileValues = values
+	}
+
 	cfg := &Config{
 		App: AppConfig{
 			Name: getEnv("APP_NAME", "Oblivious"),
 			Env:  getEnv("APP_ENV", "development"),
 			Port: getEnvAsInt("APP_PORT", 8080),
 		},
+		Log: LogConfig{
+			Level: strings.ToLower(getEnv("LOG_LEVEL", "")),
+		},
 		Database: DatabaseConfig{
 			Host:         getEnv("DATABASE_HOST", "localhost"),
 			Port:         getEnvAsInt("DATABASE_PORT", 5432),
@@ -74,13 +411,7 @@ func Load() (*Config, error) {
 			MaxOpenConns: getEnvAsInt("DATABASE_MAX_OPEN_CONNS", 100),
 			MaxIdleConns: getEnvAsInt("DATABASE_MAX_IDLE_CONNS", 10),
 		},
-		Redis: RedisConfig{
-			Host:     getEnv("REDIS_HOST", "localhost"),
-			Port:     getEnvAsInt("REDIS_PORT", 6379),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       getEnvAsInt("REDIS_DB", 0),
-			PoolSize: getEnvAsInt("REDIS_POOL_SIZE", 10),
-		},
+		Redis: loadRedisConfig(),
 		JWT: JWTConfig{
 			Secret:            getEnv("JWT_SECRET", "your-secret-key"),
 			ExpireHours:       getEnvAsInt("JWT_EXPIRE_HOURS", 2),
@@ -91,33 +422,475 @@ func Load() (*Config, error) {
 			ChatServiceURL:    getEnv("CHAT_SERVICE_URL", "http://localhost:8082"),
 			RelayServiceURL:   getEnv("RELAY_SERVICE_URL", "http://localhost:8083"),
 			BillingServiceURL: getEnv("BILLING_SERVICE_URL", "http://localhost:8088"),
+			KBServiceURL:      getEnv("KB_SERVICE_URL", "http://localhost:8085"),
+		},
+		LogArchive: LogArchiveConfig{
+			Enabled:       getEnvAsBool("LOG_ARCHIVE_ENABLED", false),
+			HotMonths:     getEnvAsInt("LOG_ARCHIVE_HOT_MONTHS", 3),
+			FutureMonths:  getEnvAsInt("LOG_ARCHIVE_FUTURE_MONTHS", 3),
+			StorageDir:    getEnv("LOG_ARCHIVE_STORAGE_DIR", "./data/log-archive"),
+			IntervalHours: getEnvAsInt("LOG_ARCHIVE_INTERVAL_HOURS", 6),
+		},
+		Discovery: ModelDiscoveryConfig{
+			Enabled:         getEnvAsBool("MODEL_DISCOVERY_ENABLED", true),
+			IntervalSeconds: getEnvAsInt("MODEL_DISCOVERY_INTERVAL_SECONDS", 60),
+		},
+		Internal: InternalConfig{
+			Secret: getEnv("INTERNAL_API_SECRET", ""),
+		},
+		QuotaCheck: QuotaCheckConfig{
+			Enabled:         getEnvAsBool("QUOTA_CHECK_ENABLED", true),
+			TimeoutMs:       getEnvAsInt("QUOTA_CHECK_TIMEOUT_MS", 200),
+			FailOpen:        getEnvAsBool("QUOTA_CHECK_FAIL_OPEN", true),
+			CacheTTLSeconds: getEnvAsInt("QUOTA_CHECK_CACHE_TTL_SECONDS", 5),
+			EstimatedCost:   getEnvAsInt("QUOTA_CHECK_ESTIMATED_COST", 1),
+		},
+		QuotaReserve: QuotaReserveConfig{
+			Enabled:          getEnvAsBool("RELAY_QUOTA_RESERVE_ENABLED", true),
+			DefaultMaxTokens: getEnvAsInt("RELAY_QUOTA_RESERVE_DEFAULT_MAX_TOKENS", 1024),
+			TTLSeconds:       getEnvAsInt("RELAY_QUOTA_RESERVE_TTL_SECONDS", 600),
+		},
+		AbilityCheck: AbilityCheckConfig{
+			Enabled:        getEnvAsBool("ABILITY_CHECK_ENABLED", true),
+			IntervalHours:  getEnvAsInt("ABILITY_CHECK_INTERVAL_HOURS", 168),
+			DriftThreshold: getEnvAsInt("ABILITY_CHECK_DRIFT_THRESHOLD", 0),
+			WebhookURL:     getEnv("ABILITY_CHECK_WEBHOOK_URL", ""),
+		},
+		Failover: FailoverConfig{
+			MaxRetries:           getEnvAsInt("RELAY_MAX_RETRIES", 3),
+			PartialFailureWeight: getEnvAsFloat("RELAY_PARTIAL_FAILURE_WEIGHT", 0.2),
+			HealthCheckSeconds:   getEnvAsInt("RELAY_HEALTH_CHECK_SECONDS", 0),
+			QueueTimeoutMillis:   getEnvAsInt("RELAY_CHANNEL_QUEUE_TIMEOUT_MS", 2000),
+		},
+		LogTail: LogTailConfig{
+			BufferSize:  getEnvAsInt("LOG_TAIL_BUFFER_SIZE", 100),
+			IdleMinutes: getEnvAsInt("LOG_TAIL_IDLE_MINUTES", 15),
+			MaxPerAdmin: getEnvAsInt("LOG_TAIL_MAX_PER_ADMIN", 3),
+		},
+		RelayLog: RelayLogConfig{
+			BufferSize:   getEnvAsInt("RELAY_LOG_BUFFER_SIZE", 10000),
+			BatchSize:    getEnvAsInt("RELAY_LOG_BATCH_SIZE", 100),
+			FlushMillis:  getEnvAsInt("RELAY_LOG_FLUSH_MILLIS", 1000),
+			MaxBodyBytes: getEnvAsInt("RELAY_LOG_MAX_BODY_BYTES", 4096),
+		},
+		Chat: ChatConfig{
+			ContextStrategy:      getEnv("CHAT_CONTEXT_STRATEGY", "tokens"),
+			ContextWindowSize:    getEnvAsInt("CHAT_CONTEXT_WINDOW_MESSAGES", 20),
+			DefaultContextWindow: getEnvAsInt("CHAT_DEFAULT_CONTEXT_WINDOW", 0),
+			CompletionReserve:    getEnvAsInt("CHAT_COMPLETION_RESERVE_TOKENS", 1024),
+			TitleModel:           getEnv("CHAT_TITLE_MODEL", "gpt-3.5-turbo"),
+			SessionRetentionDays: getEnvAsInt("CHAT_SESSION_RETENTION_DAYS", 30),
+		},
+		CapProbe: CapabilityProbeConfig{
+			SystemUserID:    getEnvAsInt("CAPABILITY_PROBE_SYSTEM_USER_ID", 1),
+			IntervalSeconds: getEnvAsInt("CAPABILITY_PROBE_INTERVAL_SECONDS", 300),
+		},
+		DataExport: DataExportConfig{
+			StorageDir:    getEnv("DATA_EXPORT_STORAGE_DIR", "./data/exports"),
+			FilesDir:      getEnv("DATA_EXPORT_FILES_DIR", "./data/files"),
+			RetentionDays: getEnvAsInt("DATA_EXPORT_RETENTION_DAYS", 7),
+			LinkTTLHours:  getEnvAsInt("DATA_EXPORT_LINK_TTL_HOURS", 24),
+			PublicURL:     getEnv("DATA_EXPORT_PUBLIC_URL", "http://localhost:8080"),
+			WebhookURL:    getEnv("DATA_EXPORT_WEBHOOK_URL", ""),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:       getEnvAsBool("RATE_LIMIT_ENABLED", true),
+			WindowSeconds: getEnvAsInt("RATE_LIMIT_WINDOW_SECONDS", 60),
+			PublicLimit:   getEnvAsInt("RATE_LIMIT_PUBLIC_LIMIT", 600),
+			UserLimit:     getEnvAsInt("RATE_LIMIT_USER_LIMIT", 6000),
+			RoleLimits:    getEnvAsIntMap("RATE_LIMIT_ROLE_LIMITS"),
+		},
+		ClientExamples: ClientExamplesConfig{
+			BaseURL:        getEnv("CLIENT_EXAMPLES_BASE_URL", "http://localhost:8083/v1"),
+			Model:          getEnv("CLIENT_EXAMPLES_MODEL", ""),
+			EmbeddingModel: getEnv("CLIENT_EXAMPLES_EMBEDDING_MODEL", ""),
+		},
+		Scaling: ScalingConfig{
+			RelayMaxInFlight:       getEnvAsInt("SCALING_RELAY_MAX_INFLIGHT", 0),
+			RelayMaxQueue:          getEnvAsInt("SCALING_RELAY_MAX_QUEUE", 100),
+			BillingMaxEventAgeSecs: getEnvAsInt("SCALING_BILLING_MAX_EVENT_AGE_SECONDS", 60),
+			ChatMaxStreams:         getEnvAsInt("SCALING_CHAT_MAX_STREAMS", 500),
+		},
+		File: FileConfig{
+			StorageBackend:   getEnv("FILE_STORAGE_BACKEND", "local"),
+			StorageDir:       getEnv("FILE_STORAGE_DIR", "./data/uploads"),
+			S3Endpoint:       getEnv("FILE_S3_ENDPOINT", ""),
+			S3Region:         getEnv("FILE_S3_REGION", "us-east-1"),
+			S3Bucket:         getEnv("FILE_S3_BUCKET", ""),
+			S3AccessKey:      getEnv("FILE_S3_ACCESS_KEY", ""),
+			S3SecretKey:      getEnv("FILE_S3_SECRET_KEY", ""),
+			S3PathStyle:      getEnvAsBool("FILE_S3_PATH_STYLE", true),
+			MaxUploadMB:      getEnvAsInt("FILE_MAX_UPLOAD_MB", 20),
+			AllowedMIMETypes: getEnvAsSlice("FILE_ALLOWED_MIME_TYPES"),
+		},
+		Stripe: StripeConfig{
+			SecretKey:     getEnv("STRIPE_SECRET_KEY", ""),
+			WebhookSecret: getEnv("STRIPE_WEBHOOK_SECRET", ""),
+			APIBase:       getEnv("STRIPE_API_BASE", "https://api.stripe.com"),
+			Currency:      getEnv("STRIPE_CURRENCY", "usd"),
+			Packages:      getEnv("STRIPE_QUOTA_PACKAGES", "starter:500:500,standard:2200:2000,pro:12000:10000"),
+			SuccessURL:    getEnv("STRIPE_SUCCESS_URL", "http://localhost:3000/billing?checkout=success"),
+			CancelURL:     getEnv("STRIPE_CANCEL_URL", "http://localhost:3000/billing?checkout=cancel"),
+		},
+		Alert: AlertConfig{
+			CooldownMinutes:   getEnvAsInt("ALERT_COOLDOWN_MINUTES", 60),
+			SMTPAddr:          getEnv("ALERT_SMTP_ADDR", ""),
+			SMTPUsername:      getEnv("ALERT_SMTP_USERNAME", ""),
+			SMTPPassword:      getEnv("ALERT_SMTP_PASSWORD", ""),
+			SMTPFrom:          getEnv("ALERT_SMTP_FROM", "noreply@localhost"),
+			EmailTemplateFile: getEnv("ALERT_EMAIL_TEMPLATE_FILE", ""),
+		},
+		EmailVerify: EmailVerificationConfig{
+			Enabled:               getEnvAsBool("EMAIL_VERIFICATION_ENABLED", false),
+			TokenTTLMinutes:       getEnvAsInt("EMAIL_VERIFICATION_TOKEN_TTL_MINUTES", 1440),
+			ResendIntervalSeconds: getEnvAsInt("EMAIL_VERIFICATION_RESEND_INTERVAL_SECONDS", 60),
+			PublicURL:             getEnv("EMAIL_VERIFICATION_PUBLIC_URL", "http://localhost:8080"),
+		},
+		OAuth: OAuthConfig{
+			GitHubClientID:     getEnv("OAUTH_GITHUB_CLIENT_ID", ""),
+			GitHubClientSecret: getEnv("OAUTH_GITHUB_CLIENT_SECRET", ""),
+			GoogleClientID:     getEnv("OAUTH_GOOGLE_CLIENT_ID", ""),
+			GoogleClientSecret: getEnv("OAUTH_GOOGLE_CLIENT_SECRET", ""),
+			RedirectBaseURL:    getEnv("OAUTH_REDIRECT_BASE_URL", "http://localhost:8080"),
+			StateTTLSeconds:    getEnvAsInt("OAUTH_STATE_TTL_SECONDS", 600),
+		},
+		TokenExpiry: TokenExpiryConfig{
+			Enabled:         getEnvAsBool("TOKEN_EXPIRY_SWEEP_ENABLED", true),
+			IntervalMinutes: getEnvAsInt("TOKEN_EXPIRY_SWEEP_INTERVAL_MINUTES", 10),
+			SessionIdleDays: getEnvAsInt("USER_SESSION_IDLE_DAYS", 30),
+		},
+		Shutdown: ShutdownConfig{
+			DrainTimeoutSeconds: getEnvAsInt("SHUTDOWN_DRAIN_TIMEOUT_SECONDS", 30),
+		},
+		Tracing: TracingConfig{
+			Enabled:      getEnvAsBool("TRACING_ENABLED", false),
+			OTLPEndpoint: getEnv("TRACING_OTLP_ENDPOINT", ""),
+			SampleRate:   getEnvAsFloat("TRACING_SAMPLE_RATE", 0.1),
+		},
+		ResponseCache: ResponseCacheConfig{
+			Enabled:        getEnvAsBool("RESPONSE_CACHE_ENABLED", false),
+			TTLSeconds:     getEnvAsInt("RESPONSE_CACHE_TTL_SECONDS", 3600),
+			MaxEntries:     getEnvAsInt("RESPONSE_CACHE_MAX_ENTRIES", 100000),
+			LocalSize:      getEnvAsInt("RESPONSE_CACHE_LOCAL_SIZE", 1000),
+			MaxTemperature: getEnvAsFloat("RESPONSE_CACHE_MAX_TEMPERATURE", 0.5),
+			UserIDs:        getEnvAsIntSlice("RESPONSE_CACHE_USER_IDS"),
+		},
+		Batch: BatchConfig{
+			Concurrency:         getEnvAsInt("BATCH_CONCURRENCY", 4),
+			MaxItems:            getEnvAsInt("BATCH_MAX_ITEMS", 1000),
+			ReservationTTLHours: getEnvAsInt("BATCH_RESERVATION_TTL_HOURS", 24),
 		},
 	}
 
-	// 验证必要配置
-	if cfg.JWT.Secret == "your-secret-key" && cfg.App.Env == "production" {
-		return nil, fmt.Errorf("JWT_SECRET must be set in production")
+	// 格式错误的环境变量与无效的配置一并报告，避免逐个修复后反复启动
+	problems := loadProblems
+	var invalid *ValidationError
+	if err := cfg.Validate(); errors.As(err, &invalid) {
+		problems = append(problems, invalid.Problems...)
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
 	}
 
 	return cfg, nil
 }
 
-func getEnv(key, defaultValue string) string {
+// ValidationError 配置校验失败，Problems 列出所有无效的配置项
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration (%d problems):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Validate 校验必填项、端口范围与服务地址等，返回列出全部问题的 *ValidationError
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.JWT.Secret == "" {
+		add("JWT_SECRET must be set")
+	} else if c.JWT.Secret == "your-secret-key" && c.App.Env == "production" {
+		add("JWT_SECRET must be set in production")
+	}
+	if c.JWT.ExpireHours <= 0 {
+		add("JWT_EXPIRE_HOURS must be positive, got %d", c.JWT.ExpireHours)
+	}
+	if c.Database.Host == "" {
+		add("DATABASE_HOST must be set")
+	}
+	if c.Database.Database == "" {
+		add("DATABASE_NAME must be set")
+	}
+
+	for _, p := range []struct {
+		key  string
+		port int
+	}{
+		{"APP_PORT", c.App.Port},
+		{"DATABASE_PORT", c.Database.Port},
+		{"REDIS_PORT", c.Redis.Port},
+	} {
+		if p.port < 1 || p.port > 65535 {
+			add("%s must be between 1 and 65535, got %d", p.key, p.port)
+		}
+	}
+
+	if err := c.Redis.Validate(); err != nil {
+		add("%s", err.Error())
+	}
+
+	// 服务间调用地址必须是完整的 http(s) 地址，可选地址只在设置时校验
+	for _, u := range []struct {
+		key      string
+		value    string
+		required bool
+	}{
+		{"USER_SERVICE_URL", c.Services.UserServiceURL, true},
+		{"CHAT_SERVICE_URL", c.Services.ChatServiceURL, true},
+		{"RELAY_SERVICE_URL", c.Services.RelayServiceURL, true},
+		{"BILLING_SERVICE_URL", c.Services.BillingServiceURL, true},
+		{"KB_SERVICE_URL", c.Services.KBServiceURL, true},
+		{"ABILITY_CHECK_WEBHOOK_URL", c.AbilityCheck.WebhookURL, false},
+		{"DATA_EXPORT_WEBHOOK_URL", c.DataExport.WebhookURL, false},
+		{"TRACING_OTLP_ENDPOINT", c.Tracing.OTLPEndpoint, false},
+		{"OAUTH_REDIRECT_BASE_URL", c.OAuth.RedirectBaseURL, c.OAuth.Enabled()},
+	} {
+		if u.value == "" {
+			if u.required {
+				add("%s must be set", u.key)
+			}
+			continue
+		}
+		if err := validateHTTPURL(u.value); err != nil {
+			add("%s: %v", u.key, err)
+		}
+	}
+
+	switch c.Log.Level {
+	case "", "debug", "info", "warn", "error":
+	default:
+		add("LOG_LEVEL must be one of debug, info, warn, error, got %q", c.Log.Level)
+	}
+
+	if c.RateLimit.Enabled {
+		if c.RateLimit.WindowSeconds <= 0 {
+			add("RATE_LIMIT_WINDOW_SECONDS must be positive, got %d", c.RateLimit.WindowSeconds)
+		}
+		if c.RateLimit.PublicLimit <= 0 {
+			add("RATE_LIMIT_PUBLIC_LIMIT must be positive, got %d", c.RateLimit.PublicLimit)
+		}
+		if c.RateLimit.UserLimit <= 0 {
+			add("RATE_LIMIT_USER_LIMIT must be positive, got %d", c.RateLimit.UserLimit)
+		}
+	}
+	if c.Failover.HealthCheckSeconds < 0 {
+		add("RELAY_HEALTH_CHECK_SECONDS must not be negative, got %d", c.Failover.HealthCheckSeconds)
+	}
+	if c.Tracing.SampleRate < 0 || c.Tracing.SampleRate > 1 {
+		add("TRACING_SAMPLE_RATE must be between 0 and 1, got %v", c.Tracing.SampleRate)
+	}
+	if c.Chat.ContextStrategy != "tokens" && c.Chat.ContextStrategy != "sliding_window" {
+		add("CHAT_CONTEXT_STRATEGY must be tokens or sliding_window, got %q", c.Chat.ContextStrategy)
+	}
+	if c.File.StorageBackend != "local" && c.File.StorageBackend != "s3" {
+		add("FILE_STORAGE_BACKEND must be local or s3, got %q", c.File.StorageBackend)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateHTTPURL 校验地址是带主机名的 http 或 https 地址
+func validateHTTPURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must use http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", raw)
+	}
+	return nil
+}
+
+// readConfigFile 读取 YAML 配置文件并展开为与环境变量同名的键
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	values := make(map[string]string)
+	flattenConfigFile("", doc, values)
+	return values, nil
+}
+
+// flattenConfigFile 将嵌套的键按下划线连接，列表按逗号连接（与 getEnvAsSlice 的格式一致）
+func flattenConfigFile(prefix string, node map[string]interface{}, out map[string]string) {
+	keys := make([]string, 0, len(node))
+	for k := range node {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		key := strings.ToUpper(k)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		switch v := node[k].(type) {
+		case map[string]interface{}:
+			flattenConfigFile(key, v, out)
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			out[key] = strings.Join(items, ",")
+		case nil:
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// loadRedisConfig 从环境变量加载 Redis 配置
+func loadRedisConfig() RedisConfig {
+	cfg := RedisConfig{
+		Mode:                strings.ToLower(getEnv("REDIS_MODE", RedisModeStandalone)),
+		Host:                getEnv("REDIS_HOST", "localhost"),
+		Port:                getEnvAsInt("REDIS_PORT", 6379),
+		Addrs:               getEnvAsSlice("REDIS_ADDRS"),
+		Username:            getEnv("REDIS_USERNAME", ""),
+		Password:            getEnv("REDIS_PASSWORD", ""),
+		DB:                  getEnvAsInt("REDIS_DB", 0),
+		PoolSize:            getEnvAsInt("REDIS_POOL_SIZE", 10),
+		MinIdleConns:        getEnvAsInt("REDIS_MIN_IDLE_CONNS", 0),
+		MasterName:          getEnv("REDIS_MASTER_NAME", ""),
+		SentinelAddrs:       getEnvAsSlice("REDIS_SENTINEL_ADDRS"),
+		SentinelPassword:    getEnv("REDIS_SENTINEL_PASSWORD", ""),
+		TLSEnabled:          getEnvAsBool("REDIS_TLS_ENABLED", false),
+		TLSSkipVerify:       getEnvAsBool("REDIS_TLS_SKIP_VERIFY", false),
+		TLSServerName:       getEnv("REDIS_TLS_SERVER_NAME", ""),
+		TLSCAFile:           getEnv("REDIS_TLS_CA_FILE", ""),
+		DialTimeout:         time.Duration(getEnvAsInt("REDIS_DIAL_TIMEOUT_MS", 5000)) * time.Millisecond,
+		ReadTimeout:         time.Duration(getEnvAsInt("REDIS_READ_TIMEOUT_MS", 3000)) * time.Millisecond,
+		WriteTimeout:        time.Duration(getEnvAsInt("REDIS_WRITE_TIMEOUT_MS", 3000)) * time.Millisecond,
+		MaxRetries:          getEnvAsInt("REDIS_MAX_RETRIES", 3),
+		HealthCheckInterval: time.Duration(getEnvAsInt("REDIS_HEALTH_CHECK_INTERVAL_SECONDS", 15)) * time.Second,
+	}
+
+	// 集群模式未配置节点列表时，以 REDIS_HOST:REDIS_PORT 作为种子节点
+	if cfg.Mode == RedisModeCluster && len(cfg.Addrs) == 0 {
+		cfg.Addrs = []string{cfg.Addr()}
+	}
+
+	return cfg
+}
+
+// lookupEnv 读取配置项，环境变量优先，其次为配置文件
+func lookupEnv(key string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
+	return fileValues[key]
+}
+
+// invalidEnv 记录格式错误的配置项，由 Load 汇总返回
+func invalidEnv(key, value, expected string) {
+	loadProblems = append(loadProblems, fmt.Sprintf("%s: %q is not a valid %s", key, value, expected))
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := lookupEnv(key); value != "" {
+		return value
+	}
 	return defaultValue
 }
 
 func getEnvAsInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
+	if value := lookupEnv(key); value != "" {
+		intValue, err := strconv.Atoi(strings.TrimSpace(value))
+		if err == nil {
 			return intValue
 		}
+		invalidEnv(key, value, "integer")
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := lookupEnv(key); value != "" {
+		boolValue, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err == nil {
+			return boolValue
+		}
+		invalidEnv(key, value, "boolean")
 	}
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := lookupEnv(key); value != "" {
+		floatValue, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err == nil {
+			return floatValue
+		}
+		invalidEnv(key, value, "number")
+	}
+	return defaultValue
+}
+
+// getEnvAsSlice 读取逗号分隔的环境变量
+func getEnvAsSlice(key string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(lookupEnv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// getEnvAsIntSlice 读取逗号分隔的整数列表，格式错误的项被忽略
+func getEnvAsIntSlice(key string) []int {
+	values := make([]int, 0)
+	for _, v := range getEnvAsSlice(key) {
+		if i, err := strconv.Atoi(v); err == nil {
+			values = append(values, i)
+		}
+	}
+	return values
+}
+
+// getEnvAsIntMap 读取 "k:v,k:v" 形式的整数映射，格式错误的项被忽略
+func getEnvAsIntMap(key string) map[int]int {
+	values := make(map[int]int)
+	for _, pair := range getEnvAsSlice(key) {
+		k, v, ok := strings.Cut(pair, ":")
+		if !ok {
+			continue
+		}
+		ki, err1 := strconv.Atoi(strings.TrimSpace(k))
+		vi, err2 := strconv.Atoi(strings.TrimSpace(v))
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		values[ki] = vi
+	}
+	return values
+}
+
 // DSN 生成数据库连接字符串
 func (d *DatabaseConfig) DSN() string {
 	return fmt.Sprintf(
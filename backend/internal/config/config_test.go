@@ -0,0 +1,246 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadRedisConfigStandalone(t *testing.T) {
+	t.Setenv("REDIS_HOST", "redis.internal")
+	t.Setenv("REDIS_PORT", "6380")
+	t.Setenv("REDIS_DB", "2")
+	t.Setenv("REDIS_POOL_SIZE", "50")
+	t.Setenv("REDIS_MIN_IDLE_CONNS", "5")
+	t.Setenv("REDIS_READ_TIMEOUT_MS", "1500")
+
+	cfg := loadRedisConfig()
+
+	if cfg.Mode != RedisModeStandalone {
+		t.Errorf("Expected standalone mode by default, got %s", cfg.Mode)
+	}
+	if cfg.Addr() != "redis.internal:6380" {
+		t.Errorf("Expected redis.internal:6380, got %s", cfg.Addr())
+	}
+	if cfg.DB != 2 || cfg.PoolSize != 50 || cfg.MinIdleConns != 5 {
+		t.Errorf("Unexpected pool config: db=%d pool=%d idle=%d", cfg.DB, cfg.PoolSize, cfg.MinIdleConns)
+	}
+	if cfg.ReadTimeout != 1500*time.Millisecond {
+		t.Errorf("Expected 1.5s read timeout, got %v", cfg.ReadTimeout)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+}
+
+func TestLoadRedisConfigSentinel(t *testing.T) {
+	t.Setenv("REDIS_MODE", "Sentinel")
+	t.Setenv("REDIS_MASTER_NAME", "mymaster")
+	t.Setenv("REDIS_SENTINEL_ADDRS", "s1:26379, s2:26379 ,s3:26379,")
+	t.Setenv("REDIS_SENTINEL_PASSWORD", "sentinel-secret")
+	t.Setenv("REDIS_USERNAME", "app")
+	t.Setenv("REDIS_PASSWORD", "secret")
+	t.Setenv("REDIS_TLS_ENABLED", "true")
+	t.Setenv("REDIS_TLS_SERVER_NAME", "redis.example.com")
+
+	cfg := loadRedisConfig()
+
+	if cfg.Mode != RedisModeSentinel {
+		t.Fatalf("Expected sentinel mode, got %s", cfg.Mode)
+	}
+	if len(cfg.SentinelAddrs) != 3 || cfg.SentinelAddrs[1] != "s2:26379" {
+		t.Errorf("Unexpected sentinel addrs: %v", cfg.SentinelAddrs)
+	}
+	if cfg.MasterName != "mymaster" || cfg.SentinelPassword != "sentinel-secret" {
+		t.Errorf("Unexpected sentinel settings: %+v", cfg)
+	}
+	if cfg.Username != "app" || cfg.Password != "secret" {
+		t.Errorf("Unexpected auth: %s/%s", cfg.Username, cfg.Password)
+	}
+	if !cfg.TLSEnabled || cfg.TLSServerName != "redis.example.com" {
+		t.Errorf("Unexpected TLS settings: %+v", cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+
+	cfg.MasterName = ""
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected missing master name to fail validation")
+	}
+	cfg.MasterName = "mymaster"
+	cfg.SentinelAddrs = nil
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected missing sentinel addrs to fail validation")
+	}
+}
+
+func TestLoadRedisConfigCluster(t *testing.T) {
+	t.Setenv("REDIS_MODE", "cluster")
+	t.Setenv("REDIS_ADDRS", "n1:7000,n2:7001,n3:7002")
+
+	cfg := loadRedisConfig()
+
+	if cfg.Mode != RedisModeCluster {
+		t.Fatalf("Expected cluster mode, got %s", cfg.Mode)
+	}
+	if len(cfg.Addrs) != 3 {
+		t.Errorf("Expected 3 cluster nodes, got %v", cfg.Addrs)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+
+	cfg.DB = 1
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected non-zero DB to fail validation in cluster mode")
+	}
+}
+
+func TestLoadRedisConfigClusterSeedFallback(t *testing.T) {
+	t.Setenv("REDIS_MODE", "cluster")
+	t.Setenv("REDIS_HOST", "seed")
+	t.Setenv("REDIS_PORT", "7000")
+
+	cfg := loadRedisConfig()
+
+	if len(cfg.Addrs) != 1 || cfg.Addrs[0] != "seed:7000" {
+		t.Errorf("Expected host:port as seed node, got %v", cfg.Addrs)
+	}
+}
+
+func TestRedisConfigInvalidMode(t *testing.T) {
+	t.Setenv("REDIS_MODE", "replicated")
+
+	cfg := loadRedisConfig()
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected invalid mode to fail validation")
+	}
+}
+
+func TestGetEnvAsIntMap(t *testing.T) {
+	t.Setenv("RATE_LIMIT_ROLE_LIMITS", "100:60000, 10:12000,bad,20:x")
+
+	got := getEnvAsIntMap("RATE_LIMIT_ROLE_LIMITS")
+	if len(got) != 2 || got[100] != 60000 || got[10] != 12000 {
+		t.Errorf("Expected {100:60000 10:12000}, got %v", got)
+	}
+}
+
+func TestLoadAggregatesValidationProblems(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("APP_PORT", "70000")
+	t.Setenv("DATABASE_PORT", "five")
+	t.Setenv("USER_SERVICE_URL", "user-service:8081")
+	t.Setenv("BILLING_SERVICE_URL", "ftp://billing")
+	t.Setenv("RATE_LIMIT_USER_LIMIT", "-1")
+	t.Setenv("LOG_LEVEL", "verbose")
+
+	_, err := Load()
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+
+	want := []string{
+		"DATABASE_PORT",
+		"JWT_SECRET",
+		"APP_PORT",
+		"USER_SERVICE_URL",
+		"BILLING_SERVICE_URL",
+		"LOG_LEVEL",
+		"RATE_LIMIT_USER_LIMIT",
+	}
+	if len(invalid.Problems) != len(want) {
+		t.Fatalf("Expected %d problems, got %d: %v", len(want), len(invalid.Problems), invalid.Problems)
+	}
+	for i, key := range want {
+		if !strings.HasPrefix(invalid.Problems[i], key) {
+			t.Errorf("Expected problem %d to be about %s, got %q", i, key, invalid.Problems[i])
+		}
+	}
+	if !strings.Contains(err.Error(), "7 problems") {
+		t.Errorf("Expected error message to count problems, got %q", err.Error())
+	}
+}
+
+func TestLoadConfigFileUnderEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+app:
+  name: from-file
+  port: 9000
+rate_limit:
+  user_limit: 1200
+  role_limits: ["100:5000"]
+redis:
+  sentinel_addrs: [s1:26379, s2:26379]
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("APP_PORT", "9100")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.App.Name != "from-file" {
+		t.Errorf("Expected app name from file, got %s", cfg.App.Name)
+	}
+	if cfg.App.Port != 9100 {
+		t.Errorf("Expected APP_PORT env to override the file, got %d", cfg.App.Port)
+	}
+	if cfg.RateLimit.UserLimit != 1200 || cfg.RateLimit.RoleLimits[100] != 5000 {
+		t.Errorf("Unexpected rate limit %+v", cfg.RateLimit)
+	}
+	if len(cfg.Redis.SentinelAddrs) != 2 {
+		t.Errorf("Expected 2 sentinel addrs from file, got %v", cfg.Redis.SentinelAddrs)
+	}
+}
+
+func TestReloaderAppliesOnlyReloadableFields(t *testing.T) {
+	current := &Config{
+		Database:  DatabaseConfig{Host: "db-1"},
+		RateLimit: RateLimitConfig{Enabled: true, UserLimit: 100},
+		Failover:  FailoverConfig{HealthCheckSeconds: 30},
+	}
+	next := &Config{
+		Database:  DatabaseConfig{Host: "db-2"},
+		RateLimit: RateLimitConfig{Enabled: false, UserLimit: 200},
+		Failover:  FailoverConfig{HealthCheckSeconds: 60},
+		Log:       LogConfig{Level: "debug"},
+	}
+
+	reloader := NewReloader(current, func() (*Config, error) { return next, nil })
+	var notified *Config
+	reloader.Subscribe("test", func(prev, cfg *Config) {
+		if prev != current {
+			t.Errorf("Expected prev to be the config before reload")
+		}
+		notified = cfg
+	})
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	applied := reloader.Current()
+	if notified != applied {
+		t.Fatal("Expected subscriber to receive the applied config")
+	}
+	if applied.RateLimit.UserLimit != 200 || applied.Failover.HealthCheckSeconds != 60 || applied.Log.Level != "debug" {
+		t.Errorf("Expected reloadable fields to be applied, got %+v", applied)
+	}
+	if applied.Database.Host != "db-1" || !applied.RateLimit.Enabled {
+		t.Errorf("Expected non-reloadable fields to keep their values, got %+v", applied)
+	}
+
+	changed := changedStaticFields(current, next)
+	if len(changed) != 2 || changed[0] != "Database.Host" || changed[1] != "RateLimit.Enabled" {
+		t.Errorf("Expected Database.Host and RateLimit.Enabled to be reported, got %v", changed)
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"go.uber.org/zap"
+)
+
+// ReloadFunc 配置热加载后的回调，prev 为加载前的配置；回调不得修改两份配置
+type ReloadFunc func(prev, next *Config)
+
+type reloadSubscriber struct {
+	name string
+	fn   ReloadFunc
+}
+
+// Reloader 收到 SIGHUP 时重新加载配置，并通知订阅的组件
+//
+// 只有限流、日志级别与健康检查间隔可在运行中生效；其余配置变化时记录警告并保持原值，需重启服务生效。
+// 新配置校验失败时保留当前配置。
+type Reloader struct {
+	reloadMu    sync.Mutex // 串行执行 Reload，回调在 mu 之外调用，可以读取 Current
+	mu          sync.Mutex
+	current     *Config
+	load        func() (*Config, error)
+	subscribers []reloadSubscriber
+	signals     chan os.Signal
+	done        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// NewReloader 创建配置热加载器，load 通常为 Load
+func NewReloader(cfg *Config, load func() (*Config, error)) *Reloader {
+	return &Reloader{current: cfg, load: load}
+}
+
+// Current 当前生效的配置
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Subscribe 注册配置热加载后的回调，按注册顺序调用
+func (r *Reloader) Subscribe(name string, fn ReloadFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, reloadSubscriber{name: name, fn: fn})
+}
+
+// Reload 重新加载配置，应用可热加载的配置并通知订阅者
+func (r *Reloader) Reload() error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	next, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	prev := r.current
+	applied := *prev
+	copyReloadable(&applied, next)
+	r.current = &applied
+	subscribers := append([]reloadSubscriber(nil), r.subscribers...)
+	r.mu.Unlock()
+
+	for _, field := range changedStaticFields(prev, next) {
+		logger.Warn("Config field changed but requires a restart to take effect", zap.String("field", field))
+	}
+	for _, sub := range subscribers {
+		logger.Debug("Applying reloaded config", zap.String("subscriber", sub.name))
+		sub.fn(prev, &applied)
+	}
+	logger.Info("Config reloaded", zap.Int("subscribers", len(subscribers)))
+	return nil
+}
+
+// Start 开始监听 SIGHUP
+func (r *Reloader) Start() {
+	r.signals = make(chan os.Signal, 1)
+	r.done = make(chan struct{})
+	signal.Notify(r.signals, syscall.SIGHUP)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-r.signals:
+				if err := r.Reload(); err != nil {
+					logger.Error("Failed to reload config, keeping the current config", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止监听 SIGHUP，只在 Start 之后调用
+func (r *Reloader) Stop() {
+	signal.Stop(r.signals)
+	close(r.done)
+	r.wg.Wait()
+}
+
+// copyReloadable 将可在运行中生效的配置从 src 复制到 dst
+func copyReloadable(dst, src *Config) {
+	dst.Log.Level = src.Log.Level
+	dst.RateLimit.WindowSeconds = src.RateLimit.WindowSeconds
+	dst.RateLimit.PublicLimit = src.RateLimit.PublicLimit
+	dst.RateLimit.UserLimit = src.RateLimit.UserLimit
+	dst.RateLimit.RoleLimits = src.RateLimit.RoleLimits
+	dst.Failover.HealthCheckSeconds = src.Failover.HealthCheckSeconds
+}
+
+// changedStaticFields 列出两份配置中不可热加载且发生变化的字段，如 Database.Host；不输出字段值，避免泄露密钥
+func changedStaticFields(prev, next *Config) []string {
+	// 先把可热加载的字段对齐，剩下的差异即为需要重启的变更
+	masked := *next
+	copyReloadable(&masked, prev)
+
+	var changed []string
+	pv, nv := reflect.ValueOf(*prev), reflect.ValueOf(masked)
+	for i := 0; i < pv.NumField(); i++ {
+		section := pv.Type().Field(i).Name
+		ps, ns := pv.Field(i), nv.Field(i)
+		if ps.Kind() != reflect.Struct {
+			if !reflect.DeepEqual(ps.Interface(), ns.Interface()) {
+				changed = append(changed, section)
+			}
+			continue
+		}
+		for j := 0; j < ps.NumField(); j++ {
+			if !reflect.DeepEqual(ps.Field(j).Interface(), ns.Field(j).Interface()) {
+				changed = append(changed, section+"."+ps.Type().Field(j).Name)
+			}
+		}
+	}
+	return changed
+}
@@ -2,42 +2,237 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"go.uber.org/zap"
 )
 
-var RedisClient *redis.Client
+// RedisClient 全局 Redis 客户端（单机、Sentinel、Cluster 共用同一接口）
+var RedisClient redis.UniversalClient
+
+var redisMonitor *redisHealthMonitor
+
+// NewRedisClient 按配置的部署模式创建 Redis 客户端（不检查连通性）
+func NewRedisClient(cfg *config.RedisConfig) (redis.UniversalClient, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := redisTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Mode {
+	case config.RedisModeSentinel:
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.SentinelAddrs,
+			SentinelPassword: cfg.SentinelPassword,
+			Username:         cfg.Username,
+			Password:         cfg.Password,
+			DB:               cfg.DB,
+			PoolSize:         cfg.PoolSize,
+			MinIdleConns:     cfg.MinIdleConns,
+			DialTimeout:      cfg.DialTimeout,
+			ReadTimeout:      cfg.ReadTimeout,
+			WriteTimeout:     cfg.WriteTimeout,
+			MaxRetries:       cfg.MaxRetries,
+			TLSConfig:        tlsConfig,
+		}), nil
+	case config.RedisModeCluster:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        cfg.Addrs,
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			MaxRetries:   cfg.MaxRetries,
+			TLSConfig:    tlsConfig,
+		}), nil
+	default:
+		return redis.NewClient(&redis.Options{
+			Addr:         cfg.Addr(),
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			DB:           cfg.DB,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			MaxRetries:   cfg.MaxRetries,
+			TLSConfig:    tlsConfig,
+		}), nil
+	}
+}
+
+// redisTLSConfig 构造 TLS 配置，未启用时返回 nil
+func redisTLSConfig(cfg *config.RedisConfig) (*tls.Config, error) {
+	if !cfg.TLSEnabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.TLSServerName,
+		InsecureSkipVerify: cfg.TLSSkipVerify, // #nosec G402 -- 仅用于自签名证书的测试环境
+	}
+
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read redis CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid redis CA file: %s", cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
 
 func InitRedis(cfg *config.RedisConfig) error {
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		Password: cfg.Password,
-		DB:       cfg.DB,
-		PoolSize: cfg.PoolSize,
-	})
+	client, err := NewRedisClient(cfg)
+	if err != nil {
+		return err
+	}
 
 	// 测试连接
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
-		return fmt.Errorf("failed to connect redis: %w", err)
+		client.Close()
+		return fmt.Errorf("failed to connect redis (%s mode): %w", cfg.Mode, err)
 	}
 
 	RedisClient = client
+
+	if cfg.HealthCheckInterval > 0 {
+		redisMonitor = newRedisHealthMonitor(client, cfg.HealthCheckInterval)
+		redisMonitor.Start()
+	}
+
 	return nil
 }
 
 func CloseRedis() error {
+	if redisMonitor != nil {
+		redisMonitor.Stop()
+		redisMonitor = nil
+	}
 	if RedisClient == nil {
 		return nil
 	}
 	return RedisClient.Close()
 }
 
+// RedisHealthy 最近一次健康检查是否成功（未启用检查时始终为 true）
+func RedisHealthy() bool {
+	if redisMonitor == nil {
+		return RedisClient != nil
+	}
+	return redisMonitor.Healthy()
+}
+
+// redisHealthMonitor 定期探测 Redis 连接
+//
+// go-redis 会丢弃出错的连接并在下次使用时重新拨号，Sentinel 客户端会跟随主节点切换；
+// 这里负责探测并在故障与恢复时记录日志，集群模式下连续失败时主动刷新槽位拓扑。
+type redisHealthMonitor struct {
+	client   redis.UniversalClient
+	interval time.Duration
+
+	healthy  atomic.Bool
+	failures int
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newRedisHealthMonitor(client redis.UniversalClient, interval time.Duration) *redisHealthMonitor {
+	m := &redisHealthMonitor{
+		client:   client,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+	m.healthy.Store(true)
+	return m
+}
+
+// Start 启动健康检查
+func (m *redisHealthMonitor) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stopCh:
+				return
+			case <-ticker.C:
+				m.check()
+			}
+		}
+	}()
+}
+
+// Stop 停止健康检查
+func (m *redisHealthMonitor) Stop() {
+	close(m.stopCh)
+	m.wg.Wait()
+}
+
+// Healthy 当前健康状态
+func (m *redisHealthMonitor) Healthy() bool {
+	return m.healthy.Load()
+}
+
+// check 执行一次探测
+func (m *redisHealthMonitor) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), m.interval)
+	defer cancel()
+
+	err := m.client.Ping(ctx).Err()
+	if err == nil {
+		if !m.healthy.Load() {
+			logger.Info("redis connection recovered", zap.Int("failed_checks", m.failures))
+		}
+		m.failures = 0
+		m.healthy.Store(true)
+		return
+	}
+
+	m.failures++
+	if m.healthy.Load() {
+		logger.Warn("redis health check failed", zap.Error(err))
+	}
+	m.healthy.Store(false)
+
+	// 集群节点故障转移后，主动刷新槽位拓扑而不是等待下一次 MOVED
+	if cluster, ok := m.client.(*redis.ClusterClient); ok && m.failures%3 == 0 {
+		cluster.ReloadState(ctx)
+	}
+}
+
 // 封装常用操作
 func RedisSet(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	return RedisClient.Set(ctx, key, value, expiration).Err()
@@ -47,12 +242,39 @@ func RedisGet(ctx context.Context, key string) (string, error) {
 	return RedisClient.Get(ctx, key).Result()
 }
 
+// RedisDel 删除多个键
+// 集群模式下多键命令要求所有键位于同一个槽，这里逐键删除以兼容所有部署模式
 func RedisDel(ctx context.Context, keys ...string) error {
-	return RedisClient.Del(ctx, keys...).Err()
+	if len(keys) <= 1 {
+		return RedisClient.Del(ctx, keys...).Err()
+	}
+
+	pipe := RedisClient.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
+// RedisExists 统计存在的键数量（同 RedisDel，逐键执行以避免跨槽错误）
 func RedisExists(ctx context.Context, keys ...string) (int64, error) {
-	return RedisClient.Exists(ctx, keys...).Result()
-}
+	if len(keys) <= 1 {
+		return RedisClient.Exists(ctx, keys...).Result()
+	}
 
+	pipe := RedisClient.Pipeline()
+	cmds := make([]*redis.IntCmd, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, pipe.Exists(ctx, key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
 
+	var total int64
+	for _, cmd := range cmds {
+		total += cmd.Val()
+	}
+	return total, nil
+}
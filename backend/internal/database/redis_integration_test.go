@@ -0,0 +1,113 @@
+//go:build integration
+
+package database
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+)
+
+// 依赖 deploy/docker-compose.redis-sentinel.yml 启动的 Sentinel 环境
+func sentinelTestConfig(t *testing.T) *config.RedisConfig {
+	addrs := os.Getenv("REDIS_SENTINEL_ADDRS")
+	if addrs == "" {
+		addrs = "127.0.0.1:26390,127.0.0.1:26391,127.0.0.1:26392"
+	}
+
+	return &config.RedisConfig{
+		Mode:                config.RedisModeSentinel,
+		MasterName:          "mymaster",
+		SentinelAddrs:       strings.Split(addrs, ","),
+		PoolSize:            10,
+		DialTimeout:         2 * time.Second,
+		ReadTimeout:         2 * time.Second,
+		WriteTimeout:        2 * time.Second,
+		MaxRetries:          3,
+		HealthCheckInterval: time.Second,
+	}
+}
+
+func TestSentinelConsumers(t *testing.T) {
+	cfg := sentinelTestConfig(t)
+	if err := InitRedis(cfg); err != nil {
+		t.Fatalf("InitRedis failed: %v", err)
+	}
+	defer CloseRedis()
+
+	ctx := context.Background()
+
+	if err := RedisSet(ctx, "it:a", "1", time.Minute); err != nil {
+		t.Fatalf("RedisSet failed: %v", err)
+	}
+	if err := RedisSet(ctx, "it:b", "2", time.Minute); err != nil {
+		t.Fatalf("RedisSet failed: %v", err)
+	}
+	if v, err := RedisGet(ctx, "it:a"); err != nil || v != "1" {
+		t.Fatalf("RedisGet returned %q, %v", v, err)
+	}
+	if n, err := RedisExists(ctx, "it:a", "it:b", "it:missing"); err != nil || n != 2 {
+		t.Fatalf("RedisExists returned %d, %v", n, err)
+	}
+	if err := RedisDel(ctx, "it:a", "it:b"); err != nil {
+		t.Fatalf("RedisDel failed: %v", err)
+	}
+
+	// 限流中间件使用的单键 Lua 脚本
+	script := `redis.call('HSET', KEYS[1], 'tokens', ARGV[1]) return redis.call('HGET', KEYS[1], 'tokens')`
+	if v, err := RedisClient.Eval(ctx, script, []string{"it:rate"}, 5).Result(); err != nil || v != "5" {
+		t.Fatalf("Eval returned %v, %v", v, err)
+	}
+	RedisClient.Del(ctx, "it:rate")
+}
+
+func TestSentinelFailoverReconnect(t *testing.T) {
+	cfg := sentinelTestConfig(t)
+	if err := InitRedis(cfg); err != nil {
+		t.Fatalf("InitRedis failed: %v", err)
+	}
+	defer CloseRedis()
+
+	ctx := context.Background()
+	sentinel := redis.NewSentinelClient(&redis.Options{Addr: cfg.SentinelAddrs[0]})
+	defer sentinel.Close()
+
+	before, err := sentinel.GetMasterAddrByName(ctx, cfg.MasterName).Result()
+	if err != nil {
+		t.Fatalf("failed to resolve master: %v", err)
+	}
+
+	if err := sentinel.Failover(ctx, cfg.MasterName).Err(); err != nil {
+		t.Fatalf("failed to trigger failover: %v", err)
+	}
+
+	// 故障转移期间写入可能短暂失败，客户端应自动切换到新主节点
+	deadline := time.Now().Add(30 * time.Second)
+	var after []string
+	for time.Now().Before(deadline) {
+		after, _ = sentinel.GetMasterAddrByName(ctx, cfg.MasterName).Result()
+		if len(after) == 2 && after[1] != before[1] {
+			if err := RedisSet(ctx, "it:failover", "ok", time.Minute); err == nil {
+				break
+			}
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	if len(after) != 2 || after[1] == before[1] {
+		t.Fatalf("master did not change: before %v after %v", before, after)
+	}
+	if v, err := RedisGet(ctx, "it:failover"); err != nil || v != "ok" {
+		t.Fatalf("write after failover not readable: %q, %v", v, err)
+	}
+
+	time.Sleep(2 * cfg.HealthCheckInterval)
+	if !RedisHealthy() {
+		t.Error("Expected health monitor to report healthy after failover")
+	}
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+)
+
+func TestNewRedisClientModes(t *testing.T) {
+	base := config.RedisConfig{
+		Host:        "localhost",
+		Port:        6379,
+		PoolSize:    20,
+		DialTimeout: time.Second,
+	}
+
+	standalone := base
+	standalone.Mode = config.RedisModeStandalone
+	client, err := NewRedisClient(&standalone)
+	if err != nil {
+		t.Fatalf("NewRedisClient failed: %v", err)
+	}
+	c, ok := client.(*redis.Client)
+	if !ok {
+		t.Fatalf("Expected *redis.Client, got %T", client)
+	}
+	if c.Options().PoolSize != 20 || c.Options().Addr != "localhost:6379" {
+		t.Errorf("Unexpected options: %+v", c.Options())
+	}
+	client.Close()
+
+	sentinel := base
+	sentinel.Mode = config.RedisModeSentinel
+	sentinel.MasterName = "mymaster"
+	sentinel.SentinelAddrs = []string{"localhost:26379"}
+	client, err = NewRedisClient(&sentinel)
+	if err != nil {
+		t.Fatalf("NewRedisClient failed: %v", err)
+	}
+	// Sentinel 模式返回跟随主节点切换的 failover 客户端
+	if _, ok := client.(*redis.Client); !ok {
+		t.Errorf("Expected failover *redis.Client, got %T", client)
+	}
+	client.Close()
+
+	cluster := base
+	cluster.Mode = config.RedisModeCluster
+	cluster.Addrs = []string{"localhost:7000", "localhost:7001"}
+	client, err = NewRedisClient(&cluster)
+	if err != nil {
+		t.Fatalf("NewRedisClient failed: %v", err)
+	}
+	cc, ok := client.(*redis.ClusterClient)
+	if !ok {
+		t.Fatalf("Expected *redis.ClusterClient, got %T", client)
+	}
+	if len(cc.Options().Addrs) != 2 {
+		t.Errorf("Expected 2 seed nodes, got %v", cc.Options().Addrs)
+	}
+	client.Close()
+}
+
+func TestNewRedisClientInvalidConfig(t *testing.T) {
+	if _, err := NewRedisClient(&config.RedisConfig{Mode: config.RedisModeSentinel}); err == nil {
+		t.Error("Expected sentinel config without master name to fail")
+	}
+}
+
+func TestRedisTLSConfig(t *testing.T) {
+	cfg := &config.RedisConfig{Mode: config.RedisModeStandalone, Host: "localhost"}
+	tlsConfig, err := redisTLSConfig(cfg)
+	if err != nil || tlsConfig != nil {
+		t.Fatalf("Expected no TLS config when disabled, got %v / %v", tlsConfig, err)
+	}
+
+	cfg.TLSEnabled = true
+	cfg.TLSServerName = "redis.example.com"
+	tlsConfig, err = redisTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("redisTLSConfig failed: %v", err)
+	}
+	if tlsConfig.ServerName != "redis.example.com" || tlsConfig.InsecureSkipVerify {
+		t.Errorf("Unexpected TLS config: %+v", tlsConfig)
+	}
+
+	badCA := filepath.Join(t.TempDir(), "ca.pem")
+	_ = os.WriteFile(badCA, []byte("not a certificate"), 0o600)
+	cfg.TLSCAFile = badCA
+	if _, err := redisTLSConfig(cfg); err == nil {
+		t.Error("Expected invalid CA file to fail")
+	}
+}
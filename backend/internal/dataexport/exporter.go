@@ -0,0 +1,356 @@
+package dataexport
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/storage"
+)
+
+// Source 导出数据来源，所有列表都以回调方式逐条返回，避免一次性载入内存
+type Source interface {
+	// Profile 用户资料与偏好设置，设置不存在时返回 nil
+	Profile(ctx context.Context, userID int) (*model.User, *model.UserSettings, error)
+	EachSession(ctx context.Context, userID int, fn func(*model.Session) error) error
+	// EachMessage 按时间顺序返回会话消息
+	EachMessage(ctx context.Context, sessionID uuid.UUID, fn func(*model.Message) error) error
+	EachKnowledgeBase(ctx context.Context, userID int, fn func(*model.KnowledgeBase) error) error
+	EachDocument(ctx context.Context, kbID int, fn func(*model.Document) error) error
+	EachAgent(ctx context.Context, userID int, fn func(*model.Agent) error) error
+	EachUsageLog(ctx context.Context, userID int, fn func(*model.UnifiedLog) error) error
+	EachInvoice(ctx context.Context, userID int, fn func(*model.Invoice) error) error
+}
+
+// messageStatusDeleted 用户已删除的消息，不导出
+const messageStatusDeleted = 3
+
+// Exporter 将用户数据逐部分写入 zip
+type Exporter struct {
+	source Source
+	files  storage.ObjectStore // 知识库文档原始文件所在存储，为空时只导出文档元数据
+}
+
+// NewExporter 创建导出器
+func NewExporter(source Source, files storage.ObjectStore) *Exporter {
+	return &Exporter{source: source, files: files}
+}
+
+// WriteSection 将一个组成部分写入 zip，返回该部分的清单
+func (e *Exporter) WriteSection(ctx context.Context, zw *zip.Writer, userID int, section Section) (*SectionManifest, error) {
+	m := &SectionManifest{Section: section}
+	var err error
+	switch section {
+	case SectionProfile:
+		err = e.writeProfile(ctx, zw, userID, m)
+	case SectionSessions:
+		err = e.writeSessions(ctx, zw, userID, m)
+	case SectionKnowledgeBases:
+		err = e.writeKnowledgeBases(ctx, zw, userID, m)
+	case SectionAgents:
+		err = e.writeAgents(ctx, zw, userID, m)
+	case SectionUsage:
+		err = e.writeUsage(ctx, zw, userID, m)
+	case SectionInvoices:
+		err = e.writeInvoices(ctx, zw, userID, m)
+	default:
+		err = fmt.Errorf("unknown export section: %s", section)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to export %s: %w", section, err)
+	}
+	return m, nil
+}
+
+// create 在 zip 中新建文件并计数
+func create(zw *zip.Writer, m *SectionManifest, name string) (io.Writer, error) {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	m.Files++
+	return w, nil
+}
+
+// writeJSON 写入一个缩进的 JSON 文件
+func writeJSON(zw *zip.Writer, m *SectionManifest, name string, v interface{}) error {
+	w, err := create(zw, m, name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// jsonArray 逐条写入 JSON 数组
+type jsonArray struct {
+	w io.Writer
+	n int
+}
+
+func (a *jsonArray) add(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	sep := "\n  "
+	if a.n > 0 {
+		sep = ",\n  "
+	}
+	if _, err := io.WriteString(a.w, sep); err != nil {
+		return err
+	}
+	a.n++
+	_, err = a.w.Write(data)
+	return err
+}
+
+func (a *jsonArray) close() error {
+	_, err := io.WriteString(a.w, "\n]")
+	return err
+}
+
+// writeStream 写入由 each 逐条产生的 JSON 数组文件
+func writeStream(zw *zip.Writer, m *SectionManifest, name string, each func(add func(interface{}) error) error) error {
+	w, err := create(zw, m, name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	arr := &jsonArray{w: w}
+	if err := each(arr.add); err != nil {
+		return err
+	}
+	return arr.close()
+}
+
+func (e *Exporter) writeProfile(ctx context.Context, zw *zip.Writer, userID int, m *SectionManifest) error {
+	user, settings, err := e.source.Profile(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+	m.Items = 1
+	return writeJSON(zw, m, "profile/profile.json", map[string]interface{}{
+		"user":     user,
+		"settings": settings,
+	})
+}
+
+// messageFlags 消息元数据中的内容保存标记
+type messageFlags struct {
+	Encrypted bool `json:"encrypted"`
+	NoStore   bool `json:"no_store"`
+}
+
+// exclusionReason 消息内容不能导出的原因，可导出时返回空
+func exclusionReason(msg *model.Message) string {
+	if msg.Metadata == "" {
+		return ""
+	}
+	var flags messageFlags
+	if err := json.Unmarshal([]byte(msg.Metadata), &flags); err != nil {
+		return ""
+	}
+	switch {
+	case flags.Encrypted:
+		return ReasonEncrypted
+	case flags.NoStore:
+		return ReasonNoStore
+	}
+	return ""
+}
+
+// exportedMessage 导出的消息，内容被排除时清空并注明原因
+type exportedMessage struct {
+	*model.Message
+	ContentExcluded string `json:"content_excluded,omitempty"`
+}
+
+func (e *Exporter) writeSessions(ctx context.Context, zw *zip.Writer, userID int, m *SectionManifest) error {
+	return e.source.EachSession(ctx, userID, func(session *model.Session) error {
+		m.Items++
+		base := "sessions/" + session.ID.String()
+
+		// JSON：会话信息 + 消息数组，消息逐条写入
+		w, err := create(zw, m, base+".json")
+		if err != nil {
+			return err
+		}
+		header, err := json.Marshal(session)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "{\"session\": %s,\n\"messages\": [", header); err != nil {
+			return err
+		}
+		arr := &jsonArray{w: w}
+		err = e.source.EachMessage(ctx, session.ID, func(msg *model.Message) error {
+			if msg.Status == messageStatusDeleted {
+				return nil
+			}
+			out := &exportedMessage{Message: msg}
+			if reason := exclusionReason(msg); reason != "" {
+				copied := *msg
+				copied.Content = ""
+				out = &exportedMessage{Message: &copied, ContentExcluded: reason}
+				m.exclude(msg.ID.String(), reason, "message content in session "+session.ID.String())
+			}
+			return arr.add(out)
+		})
+		if err != nil {
+			return err
+		}
+		if err := arr.close(); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "}\n"); err != nil {
+			return err
+		}
+
+		// Markdown：便于直接阅读的对话记录（zip 不能同时写两个文件，消息再读取一遍）
+		w, err = create(zw, m, base+".md")
+		if err != nil {
+			return err
+		}
+		title := session.Title
+		if title == "" {
+			title = "Untitled session"
+		}
+		if _, err := fmt.Fprintf(w, "# %s\n\n- Created: %s\n- Model: %s\n", title, session.CreatedAt.UTC().Format(time.RFC3339), session.Model); err != nil {
+			return err
+		}
+		return e.source.EachMessage(ctx, session.ID, func(msg *model.Message) error {
+			if msg.Status == messageStatusDeleted {
+				return nil
+			}
+			content := msg.Content
+			if reason := exclusionReason(msg); reason != "" {
+				content = fmt.Sprintf("_[content not exported: %s]_", reason)
+			}
+			_, err := fmt.Fprintf(w, "\n## %s · %s\n\n%s\n", msg.Role, msg.CreatedAt.UTC().Format(time.RFC3339), content)
+			return err
+		})
+	})
+}
+
+func (e *Exporter) writeKnowledgeBases(ctx context.Context, zw *zip.Writer, userID int, m *SectionManifest) error {
+	return e.source.EachKnowledgeBase(ctx, userID, func(kb *model.KnowledgeBase) error {
+		m.Items++
+		base := fmt.Sprintf("knowledge_bases/%d", kb.ID)
+		if err := writeJSON(zw, m, base+"/knowledge_base.json", kb); err != nil {
+			return err
+		}
+
+		return e.source.EachDocument(ctx, kb.ID, func(doc *model.Document) error {
+			docBase := base + "/documents/" + doc.ID.String()
+			if err := writeJSON(zw, m, docBase+".json", doc); err != nil {
+				return err
+			}
+			return e.writeOriginal(ctx, zw, m, docBase, doc)
+		})
+	})
+}
+
+// writeOriginal 复制文档的原始文件，文件未保存在存储中时记入清单
+func (e *Exporter) writeOriginal(ctx context.Context, zw *zip.Writer, m *SectionManifest, docBase string, doc *model.Document) error {
+	if e.files == nil || doc.FileURL == "" || strings.Contains(doc.FileURL, "://") {
+		m.exclude(doc.ID.String(), ReasonOriginalNotStored, doc.Title)
+		return nil
+	}
+
+	rc, err := e.files.Get(ctx, doc.FileURL)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		m.exclude(doc.ID.String(), ReasonOriginalNotStored, doc.Title)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	w, err := create(zw, m, docBase+"/"+path.Base(doc.FileURL))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+func (e *Exporter) writeAgents(ctx context.Context, zw *zip.Writer, userID int, m *SectionManifest) error {
+	return writeStream(zw, m, "agents/agents.json", func(add func(interface{}) error) error {
+		return e.source.EachAgent(ctx, userID, func(agent *model.Agent) error {
+			m.Items++
+			return add(agent)
+		})
+	})
+}
+
+// usageHeader 用量 CSV 的列
+var usageHeader = []string{
+	"created_at", "request_id", "model", "token_name", "project_id", "log_type",
+	"prompt_tokens", "completion_tokens", "reasoning_tokens", "quota", "use_time_ms", "is_stream",
+}
+
+func (e *Exporter) writeUsage(ctx context.Context, zw *zip.Writer, userID int, m *SectionManifest) error {
+	w, err := create(zw, m, "usage/usage.csv")
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(usageHeader); err != nil {
+		return err
+	}
+
+	err = e.source.EachUsageLog(ctx, userID, func(l *model.UnifiedLog) error {
+		m.Items++
+		return cw.Write([]string{
+			l.CreatedAt.UTC().Format(time.RFC3339),
+			l.RequestID,
+			l.ModelName,
+			l.TokenName,
+			strconv.Itoa(l.ProjectID),
+			strconv.Itoa(l.LogType),
+			strconv.Itoa(l.PromptTokens),
+			strconv.Itoa(l.CompletionTokens),
+			strconv.Itoa(l.ReasoningTokens),
+			strconv.Itoa(l.Quota),
+			strconv.Itoa(l.UseTime),
+			strconv.FormatBool(l.IsStream),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	m.exclude("", ReasonMetadataOnly, "usage history records request metadata; request and response content is not included")
+	return nil
+}
+
+func (e *Exporter) writeInvoices(ctx context.Context, zw *zip.Writer, userID int, m *SectionManifest) error {
+	return writeStream(zw, m, "invoices/invoices.json", func(add func(interface{}) error) error {
+		return e.source.EachInvoice(ctx, userID, func(invoice *model.Invoice) error {
+			m.Items++
+			return add(invoice)
+		})
+	})
+}
@@ -0,0 +1,76 @@
+package dataexport
+
+import "time"
+
+// Section 导出归档的组成部分，每部分单独生成、单独记录断点
+type Section string
+
+const (
+	SectionProfile        Section = "profile"         // 用户资料与偏好设置
+	SectionSessions       Section = "sessions"        // 会话与消息（JSON + Markdown）
+	SectionKnowledgeBases Section = "knowledge_bases" // 知识库与文档
+	SectionAgents         Section = "agents"          // 自建助手
+	SectionUsage          Section = "usage"           // 用量记录（CSV）
+	SectionInvoices       Section = "invoices"        // 账单
+)
+
+// Sections 按导出顺序排列的全部组成部分
+var Sections = []Section{
+	SectionProfile,
+	SectionSessions,
+	SectionKnowledgeBases,
+	SectionAgents,
+	SectionUsage,
+	SectionInvoices,
+}
+
+// 内容未导出的原因
+const (
+	ReasonEncrypted         = "encrypted"           // 内容加密存储，平台无法解密
+	ReasonNoStore           = "no_store"            // 按不保存策略未保留内容
+	ReasonOriginalNotStored = "original_not_stored" // 文档原始文件未保存在存储中
+	ReasonMetadataOnly      = "metadata_only"       // 只保留了元数据
+)
+
+// maxListedExclusions 每部分在清单中逐条列出的排除项上限，超出部分只计数
+const maxListedExclusions = 1000
+
+// ManifestFile 归档内清单文件名
+const ManifestFile = "manifest.json"
+
+// FormatVersion 归档格式版本
+const FormatVersion = 1
+
+// Exclusion 未导出的内容
+type Exclusion struct {
+	Section Section `json:"section"`
+	ItemID  string  `json:"item_id,omitempty"`
+	Reason  string  `json:"reason"`
+	Detail  string  `json:"detail,omitempty"`
+}
+
+// SectionManifest 单个组成部分的清单
+type SectionManifest struct {
+	Section       Section      `json:"section"`
+	Items         int          `json:"items"` // 导出的记录数
+	Files         int          `json:"files"` // 写入归档的文件数
+	ExcludedCount int          `json:"excluded_count"`
+	Excluded      []*Exclusion `json:"excluded,omitempty"` // 最多列出 maxListedExclusions 条
+}
+
+// exclude 记录一条排除项
+func (m *SectionManifest) exclude(itemID, reason, detail string) {
+	m.ExcludedCount++
+	if len(m.Excluded) < maxListedExclusions {
+		m.Excluded = append(m.Excluded, &Exclusion{Section: m.Section, ItemID: itemID, Reason: reason, Detail: detail})
+	}
+}
+
+// Manifest 归档清单，说明归档包含的内容以及未导出的内容和原因
+type Manifest struct {
+	Format      int                `json:"format"`
+	ExportID    string             `json:"export_id"`
+	UserID      int                `json:"user_id"`
+	GeneratedAt time.Time          `json:"generated_at"`
+	Sections    []*SectionManifest `json:"sections"`
+}
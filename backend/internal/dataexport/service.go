@@ -0,0 +1,599 @@
+package dataexport
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/storage"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrExportNotFound 导出任务不存在或不属于当前用户
+	ErrExportNotFound = errors.New("data export not found")
+	// ErrExportNotReady 导出尚未完成
+	ErrExportNotReady = errors.New("data export is not ready")
+	// ErrExportExpired 导出归档已过期并被清理
+	ErrExportExpired = errors.New("data export has expired")
+	// ErrInvalidSignature 下载链接签名无效或已过期
+	ErrInvalidSignature = errors.New("invalid or expired download link")
+)
+
+// dailyWindow 两次导出之间的最小间隔
+const dailyWindow = 24 * time.Hour
+
+// stageAssemble 合并各部分为最终归档的阶段名
+const stageAssemble = "assemble"
+
+// LimitError 导出过于频繁
+type LimitError struct {
+	ExportID uuid.UUID // 窗口内已有的导出
+	RetryAt  time.Time // 下一次允许导出的时间
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("only one data export per day is allowed, next export available at %s", e.RetryAt.UTC().Format(time.RFC3339))
+}
+
+// JobStore 导出任务持久化
+type JobStore interface {
+	Create(ctx context.Context, job *model.DataExport) error
+	// FindByID 任务不存在时返回 nil, nil
+	FindByID(ctx context.Context, id uuid.UUID) (*model.DataExport, error)
+	// FindLatestByUser 用户最近一次未失败的导出，不存在时返回 nil, nil
+	FindLatestByUser(ctx context.Context, userID int) (*model.DataExport, error)
+	Save(ctx context.Context, job *model.DataExport) error
+	ListByStatus(ctx context.Context, statuses ...string) ([]*model.DataExport, error)
+	// ListExpired 已完成且在 before 之前过期的导出
+	ListExpired(ctx context.Context, before time.Time) ([]*model.DataExport, error)
+}
+
+// Notifier 导出完成后的通知，downloadURL 为限时签名链接
+type Notifier func(ctx context.Context, job *model.DataExport, downloadURL string) error
+
+// Config 数据导出配置
+type Config struct {
+	Retention time.Duration // 归档保留时长，过期后清理
+	LinkTTL   time.Duration // 下载链接有效期（不超过归档保留时长）
+	Interval  time.Duration // 待处理任务与过期归档的检查间隔
+	PublicURL string        // 下载链接的对外地址（网关）
+	Secret    []byte        // 下载链接签名密钥
+}
+
+// DefaultConfig 默认保留 7 天，下载链接 24 小时有效
+func DefaultConfig() *Config {
+	return &Config{
+		Retention: 7 * 24 * time.Hour,
+		LinkTTL:   24 * time.Hour,
+		Interval:  time.Minute,
+		PublicURL: "http://localhost:8080",
+	}
+}
+
+// checkpoint 已完成的部分及其清单，任务中断后从这里续跑
+type checkpoint struct {
+	Sections map[Section]*SectionManifest `json:"sections"`
+}
+
+// Service 用户数据导出任务
+//
+// 每个部分单独写成一个临时 zip 并记录断点，全部完成后合并为最终归档并附上清单；
+// 所有数据逐条流式写入存储，内存占用与数据量无关。
+type Service struct {
+	jobs     JobStore
+	exporter *Exporter
+	store    storage.ObjectStore
+	notify   Notifier
+	cfg      *Config
+	now      func() time.Time
+
+	createMu sync.Mutex
+	wake     chan struct{}
+	stopCh   chan struct{}
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewService 创建导出服务，notifier 为空时只记录日志
+func NewService(jobs JobStore, exporter *Exporter, store storage.ObjectStore, notifier Notifier, cfg *Config) *Service {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	return &Service{
+		jobs:     jobs,
+		exporter: exporter,
+		store:    store,
+		notify:   notifier,
+		cfg:      cfg,
+		now:      time.Now,
+		wake:     make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Create 为用户创建导出任务，24 小时内已有未失败的导出时返回 *LimitError
+func (s *Service) Create(ctx context.Context, userID int) (*model.DataExport, error) {
+	s.createMu.Lock()
+	defer s.createMu.Unlock()
+
+	now := s.now()
+	latest, err := s.jobs.FindLatestByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && now.Sub(latest.CreatedAt) < dailyWindow {
+		return nil, &LimitError{ExportID: latest.ID, RetryAt: latest.CreatedAt.Add(dailyWindow)}
+	}
+
+	job := &model.DataExport{
+		ID:         uuid.New(),
+		UserID:     userID,
+		Status:     model.DataExportStatusPending,
+		Checkpoint: "{}",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.jobs.Create(ctx, job); err != nil {
+		return nil, err
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Get 获取用户自己的导出任务
+func (s *Service) Get(ctx context.Context, userID int, id uuid.UUID) (*model.DataExport, error) {
+	job, err := s.jobs.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil || job.UserID != userID {
+		return nil, ErrExportNotFound
+	}
+	return job, nil
+}
+
+// sign 计算下载链接签名
+func (s *Service) sign(id string, expires int64) string {
+	mac := hmac.New(sha256.New, s.cfg.Secret)
+	fmt.Fprintf(mac, "%s:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// DownloadURL 已完成导出的限时签名下载链接，未完成时返回空
+func (s *Service) DownloadURL(job *model.DataExport) string {
+	if job.Status != model.DataExportStatusCompleted || job.ExpiresAt == nil {
+		return ""
+	}
+	expires := s.now().Add(s.cfg.LinkTTL)
+	if job.ExpiresAt.Before(expires) {
+		expires = *job.ExpiresAt
+	}
+
+	id := job.ID.String()
+	q := url.Values{}
+	q.Set("expires", fmt.Sprintf("%d", expires.Unix()))
+	q.Set("signature", s.sign(id, expires.Unix()))
+	return fmt.Sprintf("%s/api/v1/user/export/%s/download?%s", strings.TrimRight(s.cfg.PublicURL, "/"), id, q.Encode())
+}
+
+// Verify 校验下载链接签名与有效期
+func (s *Service) Verify(id string, expires int64, signature string) error {
+	if expires < s.now().Unix() {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(s.sign(id, expires)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Open 校验签名后打开导出归档，调用方负责关闭
+func (s *Service) Open(ctx context.Context, id uuid.UUID, expires int64, signature string) (*model.DataExport, io.ReadCloser, error) {
+	if err := s.Verify(id.String(), expires, signature); err != nil {
+		return nil, nil, err
+	}
+
+	job, err := s.jobs.FindByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job == nil {
+		return nil, nil, ErrExportNotFound
+	}
+	switch job.Status {
+	case model.DataExportStatusCompleted:
+	case model.DataExportStatusExpired:
+		return nil, nil, ErrExportExpired
+	default:
+		return nil, nil, ErrExportNotReady
+	}
+
+	rc, err := s.store.Get(ctx, job.ObjectKey)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return nil, nil, ErrExportExpired
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return job, rc, nil
+}
+
+// Start 启动后台任务：先续跑上次中断的导出，之后处理新任务并定时清理过期归档
+func (s *Service) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+
+		s.processJobs(ctx, model.DataExportStatusRunning, model.DataExportStatusPending)
+		s.purgeExpired(ctx)
+
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-s.wake:
+				s.processJobs(ctx, model.DataExportStatusPending)
+			case <-ticker.C:
+				s.processJobs(ctx, model.DataExportStatusPending)
+				s.purgeExpired(ctx)
+			}
+		}
+	}()
+}
+
+// Stop 停止后台任务，进行中的导出保留断点，下次启动时续跑
+func (s *Service) Stop() {
+	close(s.stopCh)
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// processJobs 逐个执行指定状态的任务
+func (s *Service) processJobs(ctx context.Context, statuses ...string) {
+	jobs, err := s.jobs.ListByStatus(ctx, statuses...)
+	if err != nil {
+		logger.Error("failed to list data exports", zap.Error(err))
+		return
+	}
+
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.Run(ctx, job); err != nil {
+			if ctx.Err() != nil {
+				// 服务停止，保留进行中状态与断点
+				return
+			}
+			s.fail(job, err)
+		}
+	}
+}
+
+// fail 标记任务失败并清理临时文件，失败的导出不占用每日次数
+func (s *Service) fail(job *model.DataExport, cause error) {
+	ctx := context.Background()
+	logger.Error("data export failed",
+		zap.String("export_id", job.ID.String()),
+		zap.Int("user_id", job.UserID),
+		zap.Error(cause))
+
+	job.Status = model.DataExportStatusFailed
+	job.Error = cause.Error()
+	if err := s.jobs.Save(ctx, job); err != nil {
+		logger.Error("failed to save data export", zap.String("export_id", job.ID.String()), zap.Error(err))
+	}
+	s.deleteParts(ctx, job)
+}
+
+// partKey 部分归档的对象键
+func partKey(job *model.DataExport, section Section) string {
+	return fmt.Sprintf("exports/%d/%s/parts/%s.zip", job.UserID, job.ID, section)
+}
+
+// archiveKey 最终归档的对象键
+func archiveKey(job *model.DataExport) string {
+	return fmt.Sprintf("exports/%d/%s/export.zip", job.UserID, job.ID)
+}
+
+// Run 执行导出任务，已完成的部分（断点中记录且临时文件仍在）不会重复导出
+func (s *Service) Run(ctx context.Context, job *model.DataExport) error {
+	var cp checkpoint
+	if job.Checkpoint != "" {
+		if err := json.Unmarshal([]byte(job.Checkpoint), &cp); err != nil {
+			return fmt.Errorf("invalid export checkpoint: %w", err)
+		}
+	}
+	if cp.Sections == nil {
+		cp.Sections = make(map[Section]*SectionManifest)
+	}
+
+	job.Status = model.DataExportStatusRunning
+	job.Error = ""
+	if err := s.jobs.Save(ctx, job); err != nil {
+		return err
+	}
+
+	for i, section := range Sections {
+		if _, done := cp.Sections[section]; done {
+			if ok, err := s.store.Exists(ctx, partKey(job, section)); err == nil && ok {
+				continue
+			}
+		}
+
+		job.Stage = string(section)
+		if err := s.jobs.Save(ctx, job); err != nil {
+			return err
+		}
+
+		m, err := s.writePart(ctx, job, section)
+		if err != nil {
+			return err
+		}
+		cp.Sections[section] = m
+
+		data, err := json.Marshal(&cp)
+		if err != nil {
+			return err
+		}
+		job.Checkpoint = string(data)
+		job.Progress = (i + 1) * 90 / len(Sections)
+		if err := s.jobs.Save(ctx, job); err != nil {
+			return err
+		}
+	}
+
+	job.Stage = stageAssemble
+	if err := s.jobs.Save(ctx, job); err != nil {
+		return err
+	}
+	size, err := s.assemble(ctx, job, &cp)
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	expiresAt := now.Add(s.cfg.Retention)
+	job.Status = model.DataExportStatusCompleted
+	job.Progress = 100
+	job.Stage = ""
+	job.ObjectKey = archiveKey(job)
+	job.SizeBytes = size
+	job.CompletedAt = &now
+	job.ExpiresAt = &expiresAt
+	if err := s.jobs.Save(ctx, job); err != nil {
+		return err
+	}
+	s.deleteParts(ctx, job)
+
+	logger.Info("data export completed",
+		zap.String("export_id", job.ID.String()),
+		zap.Int("user_id", job.UserID),
+		zap.Int64("size_bytes", size))
+
+	if s.notify != nil {
+		if err := s.notify(ctx, job, s.DownloadURL(job)); err != nil {
+			logger.Warn("failed to send data export notification",
+				zap.String("export_id", job.ID.String()),
+				zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// pipeToStore 将 write 产生的内容边生成边写入存储
+func (s *Service) pipeToStore(ctx context.Context, key string, write func(w io.Writer) error) (int64, error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := write(pw)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	n, putErr := s.store.Put(ctx, key, pr)
+	// 存储提前失败时让生成方退出
+	pr.CloseWithError(putErr)
+	writeErr := <-done
+
+	if writeErr != nil && !errors.Is(writeErr, io.ErrClosedPipe) {
+		return 0, writeErr
+	}
+	if putErr != nil {
+		return 0, putErr
+	}
+	return n, writeErr
+}
+
+// writePart 将一个部分写成临时 zip
+func (s *Service) writePart(ctx context.Context, job *model.DataExport, section Section) (*SectionManifest, error) {
+	var m *SectionManifest
+	_, err := s.pipeToStore(ctx, partKey(job, section), func(w io.Writer) error {
+		zw := zip.NewWriter(w)
+		var err error
+		m, err = s.exporter.WriteSection(ctx, zw, job.UserID, section)
+		if closeErr := zw.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// assemble 合并各部分并写入清单，返回归档大小
+func (s *Service) assemble(ctx context.Context, job *model.DataExport, cp *checkpoint) (int64, error) {
+	manifest := &Manifest{
+		Format:      FormatVersion,
+		ExportID:    job.ID.String(),
+		UserID:      job.UserID,
+		GeneratedAt: s.now().UTC(),
+	}
+
+	return s.pipeToStore(ctx, archiveKey(job), func(w io.Writer) error {
+		zw := zip.NewWriter(w)
+		for _, section := range Sections {
+			manifest.Sections = append(manifest.Sections, cp.Sections[section])
+			if err := s.copyPart(ctx, zw, partKey(job, section)); err != nil {
+				return fmt.Errorf("failed to merge %s: %w", section, err)
+			}
+		}
+
+		mw, err := zw.Create(ManifestFile)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(mw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(manifest); err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+}
+
+// copyPart 将部分归档中的文件原样（不重新压缩）复制到最终归档
+func (s *Service) copyPart(ctx context.Context, zw *zip.Writer, key string) error {
+	rc, err := s.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// zip 读取需要随机访问，先落到临时文件
+	tmp, err := os.CreateTemp("", "data-export-part-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, rc)
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if err := zw.Copy(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteParts 删除部分归档
+func (s *Service) deleteParts(ctx context.Context, job *model.DataExport) {
+	for _, section := range Sections {
+		if err := s.store.Delete(ctx, partKey(job, section)); err != nil {
+			logger.Warn("failed to delete data export part",
+				zap.String("export_id", job.ID.String()),
+				zap.String("section", string(section)),
+				zap.Error(err))
+		}
+	}
+}
+
+// PurgeExpired 删除过期归档并将任务标记为已过期，返回清理的数量
+func (s *Service) PurgeExpired(ctx context.Context) (int, error) {
+	jobs, err := s.jobs.ListExpired(ctx, s.now())
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, job := range jobs {
+		if err := s.store.Delete(ctx, job.ObjectKey); err != nil {
+			logger.Warn("failed to delete expired data export",
+				zap.String("export_id", job.ID.String()),
+				zap.Error(err))
+			continue
+		}
+		job.Status = model.DataExportStatusExpired
+		job.ObjectKey = ""
+		if err := s.jobs.Save(ctx, job); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (s *Service) purgeExpired(ctx context.Context) {
+	n, err := s.PurgeExpired(ctx)
+	if err != nil {
+		logger.Error("failed to purge expired data exports", zap.Error(err))
+		return
+	}
+	if n > 0 {
+		logger.Info("expired data exports purged", zap.Int("count", n))
+	}
+}
+
+// NewWebhookNotifier 以 JSON POST 的方式将导出完成事件发送到 Webhook（如邮件服务）
+func NewWebhookNotifier(webhookURL string) Notifier {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return func(ctx context.Context, job *model.DataExport, downloadURL string) error {
+		body, err := json.Marshal(map[string]interface{}{
+			"event":        "data_export_completed",
+			"user_id":      job.UserID,
+			"export_id":    job.ID,
+			"size_bytes":   job.SizeBytes,
+			"expires_at":   job.ExpiresAt,
+			"download_url": downloadURL,
+		})
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,471 @@
+package dataexport
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource 内存中的导出数据
+type fakeSource struct {
+	user      *model.User
+	settings  *model.UserSettings
+	sessions  []*model.Session
+	messages  map[uuid.UUID][]*model.Message
+	kbs       []*model.KnowledgeBase
+	documents map[int][]*model.Document
+	agents    []*model.Agent
+	logs      []*model.UnifiedLog
+	invoices  []*model.Invoice
+
+	failUsage    bool // 读取用量时失败，用于模拟中断
+	sessionReads int
+}
+
+func (f *fakeSource) Profile(ctx context.Context, userID int) (*model.User, *model.UserSettings, error) {
+	return f.user, f.settings, nil
+}
+
+func (f *fakeSource) EachSession(ctx context.Context, userID int, fn func(*model.Session) error) error {
+	f.sessionReads++
+	for _, s := range f.sessions {
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeSource) EachMessage(ctx context.Context, sessionID uuid.UUID, fn func(*model.Message) error) error {
+	for _, m := range f.messages[sessionID] {
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeSource) EachKnowledgeBase(ctx context.Context, userID int, fn func(*model.KnowledgeBase) error) error {
+	for _, kb := range f.kbs {
+		if err := fn(kb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeSource) EachDocument(ctx context.Context, kbID int, fn func(*model.Document) error) error {
+	for _, d := range f.documents[kbID] {
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeSource) EachAgent(ctx context.Context, userID int, fn func(*model.Agent) error) error {
+	for _, a := range f.agents {
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeSource) EachUsageLog(ctx context.Context, userID int, fn func(*model.UnifiedLog) error) error {
+	if f.failUsage {
+		return errors.New("connection reset")
+	}
+	for _, l := range f.logs {
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeSource) EachInvoice(ctx context.Context, userID int, fn func(*model.Invoice) error) error {
+	for _, i := range f.invoices {
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memJobs 内存中的任务存储
+type memJobs struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*model.DataExport
+}
+
+func newMemJobs() *memJobs {
+	return &memJobs{jobs: make(map[uuid.UUID]*model.DataExport)}
+}
+
+func (m *memJobs) Create(ctx context.Context, job *model.DataExport) error {
+	return m.Save(ctx, job)
+}
+
+func (m *memJobs) FindByID(ctx context.Context, id uuid.UUID) (*model.DataExport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job, ok := m.jobs[id]; ok {
+		copied := *job
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (m *memJobs) FindLatestByUser(ctx context.Context, userID int) (*model.DataExport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var latest *model.DataExport
+	for _, job := range m.jobs {
+		if job.UserID != userID || job.Status == model.DataExportStatusFailed {
+			continue
+		}
+		if latest == nil || job.CreatedAt.After(latest.CreatedAt) {
+			latest = job
+		}
+	}
+	return latest, nil
+}
+
+func (m *memJobs) Save(ctx context.Context, job *model.DataExport) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *job
+	m.jobs[job.ID] = &copied
+	return nil
+}
+
+func (m *memJobs) ListByStatus(ctx context.Context, statuses ...string) ([]*model.DataExport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*model.DataExport
+	for _, job := range m.jobs {
+		for _, s := range statuses {
+			if job.Status == s {
+				copied := *job
+				out = append(out, &copied)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (m *memJobs) ListExpired(ctx context.Context, before time.Time) ([]*model.DataExport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*model.DataExport
+	for _, job := range m.jobs {
+		if job.Status == model.DataExportStatusCompleted && job.ExpiresAt != nil && job.ExpiresAt.Before(before) {
+			copied := *job
+			out = append(out, &copied)
+		}
+	}
+	return out, nil
+}
+
+func newTestSource(t *testing.T, files storage.ObjectStore) *fakeSource {
+	t.Helper()
+	created := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	sessionID := uuid.New()
+	msgOK, msgEncrypted, msgNoStore, msgDeleted := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	docStored, docMissing := uuid.New(), uuid.New()
+
+	_, err := files.Put(context.Background(), "kb/7/handbook.pdf", strings.NewReader("%PDF-original"))
+	require.NoError(t, err)
+
+	userID := 42
+	return &fakeSource{
+		user:     &model.User{ID: userID, Username: "alice", Email: "alice@example.com", PasswordHash: "secret-hash"},
+		settings: &model.UserSettings{UserID: userID, Language: "en-US"},
+		sessions: []*model.Session{{ID: sessionID, UserID: userID, Title: "Trip planning", Model: "gpt-4o", CreatedAt: created}},
+		messages: map[uuid.UUID][]*model.Message{
+			sessionID: {
+				{ID: msgOK, SessionID: sessionID, Role: "user", Content: "Where should I go?", Status: 1, CreatedAt: created},
+				{ID: msgEncrypted, SessionID: sessionID, Role: "assistant", Content: "ciphertext", Metadata: `{"encrypted": true}`, Status: 1, CreatedAt: created.Add(time.Second)},
+				{ID: msgNoStore, SessionID: sessionID, Role: "user", Content: "private", Metadata: `{"no_store": true}`, Status: 1, CreatedAt: created.Add(2 * time.Second)},
+				{ID: msgDeleted, SessionID: sessionID, Role: "user", Content: "deleted text", Status: messageStatusDeleted, CreatedAt: created.Add(3 * time.Second)},
+			},
+		},
+		kbs: []*model.KnowledgeBase{{ID: 7, UserID: userID, Name: "Docs"}},
+		documents: map[int][]*model.Document{
+			7: {
+				{ID: docStored, KnowledgeBaseID: 7, Title: "Handbook", FileURL: "kb/7/handbook.pdf"},
+				{ID: docMissing, KnowledgeBaseID: 7, Title: "Lost", FileURL: "kb/7/lost.pdf"},
+			},
+		},
+		agents: []*model.Agent{{ID: 3, UserID: &userID, Name: "Travel agent"}},
+		logs: []*model.UnifiedLog{
+			{ID: 1, UserID: userID, ModelName: "gpt-4o", PromptTokens: 10, CompletionTokens: 5, RequestID: "req-1", CreatedAt: created},
+			{ID: 2, UserID: userID, ModelName: "gpt-4o", PromptTokens: 20, CompletionTokens: 8, RequestID: "req-2", CreatedAt: created},
+		},
+		invoices: []*model.Invoice{{ID: "inv-1", UserID: "42", InvoiceNumber: "INV-0001"}},
+	}
+}
+
+func newTestService(t *testing.T) (*Service, *fakeSource, *memJobs, storage.ObjectStore) {
+	t.Helper()
+	store, err := storage.NewLocalObjectStore(t.TempDir())
+	require.NoError(t, err)
+	files, err := storage.NewLocalObjectStore(t.TempDir())
+	require.NoError(t, err)
+	source := newTestSource(t, files)
+
+	jobs := newMemJobs()
+	cfg := DefaultConfig()
+	cfg.Secret = []byte("test-secret")
+	return NewService(jobs, NewExporter(source, files), store, nil, cfg), source, jobs, store
+}
+
+// readArchive 读取最终归档的全部文件
+func readArchive(t *testing.T, store storage.ObjectStore, key string) map[string]string {
+	t.Helper()
+	rc, err := store.Get(context.Background(), key)
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	files := make(map[string]string, len(zr.File))
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		r.Close()
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestExportManifest(t *testing.T) {
+	svc, source, _, store := newTestService(t)
+	ctx := context.Background()
+	job, err := svc.Create(ctx, 42)
+	require.NoError(t, err)
+	require.NoError(t, svc.Run(ctx, job))
+
+	assert.Equal(t, model.DataExportStatusCompleted, job.Status)
+	assert.Equal(t, 100, job.Progress)
+	require.NotNil(t, job.ExpiresAt)
+	assert.Equal(t, 7*24*time.Hour, job.ExpiresAt.Sub(*job.CompletedAt))
+
+	archive := readArchive(t, store, job.ObjectKey)
+	sessionID := source.sessions[0].ID.String()
+	docStored := source.documents[7][0].ID.String()
+	docMissing := source.documents[7][1].ID.String()
+
+	for _, name := range []string{
+		"profile/profile.json",
+		"sessions/" + sessionID + ".json",
+		"sessions/" + sessionID + ".md",
+		"knowledge_bases/7/knowledge_base.json",
+		"knowledge_bases/7/documents/" + docStored + ".json",
+		"knowledge_bases/7/documents/" + docStored + "/handbook.pdf",
+		"knowledge_bases/7/documents/" + docMissing + ".json",
+		"agents/agents.json",
+		"usage/usage.csv",
+		"invoices/invoices.json",
+		ManifestFile,
+	} {
+		assert.Contains(t, archive, name)
+	}
+	assert.Len(t, archive, 11, "no other files expected")
+
+	// 密码哈希、加密、不保存和已删除的内容都不能出现在归档中
+	for name, content := range archive {
+		for _, secret := range []string{"secret-hash", "ciphertext", "private", "deleted text"} {
+			assert.NotContains(t, content, secret, name)
+		}
+	}
+	assert.Equal(t, "%PDF-original", archive["knowledge_bases/7/documents/"+docStored+"/handbook.pdf"])
+	assert.Contains(t, archive["sessions/"+sessionID+".md"], "Where should I go?")
+	assert.Equal(t, 3, strings.Count(archive["usage/usage.csv"], "\n"), "header plus two rows")
+
+	var session struct {
+		Messages []map[string]interface{} `json:"messages"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(archive["sessions/"+sessionID+".json"]), &session))
+	require.Len(t, session.Messages, 3)
+	assert.Nil(t, session.Messages[0]["content_excluded"])
+	assert.Equal(t, ReasonEncrypted, session.Messages[1]["content_excluded"])
+	assert.Equal(t, ReasonNoStore, session.Messages[2]["content_excluded"])
+
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal([]byte(archive[ManifestFile]), &manifest))
+	assert.Equal(t, job.ID.String(), manifest.ExportID)
+	assert.Equal(t, 42, manifest.UserID)
+	require.Len(t, manifest.Sections, len(Sections))
+
+	bySection := make(map[Section]*SectionManifest)
+	for i, m := range manifest.Sections {
+		assert.Equal(t, Sections[i], m.Section, "sections must be in export order")
+		bySection[m.Section] = m
+	}
+
+	assert.Equal(t, 1, bySection[SectionProfile].Items)
+	assert.Equal(t, 0, bySection[SectionProfile].ExcludedCount)
+
+	sessions := bySection[SectionSessions]
+	assert.Equal(t, 1, sessions.Items)
+	assert.Equal(t, 2, sessions.Files)
+	assert.Equal(t, 2, sessions.ExcludedCount)
+	assert.Equal(t, []*Exclusion{
+		{Section: SectionSessions, ItemID: source.messages[source.sessions[0].ID][1].ID.String(), Reason: ReasonEncrypted, Detail: "message content in session " + sessionID},
+		{Section: SectionSessions, ItemID: source.messages[source.sessions[0].ID][2].ID.String(), Reason: ReasonNoStore, Detail: "message content in session " + sessionID},
+	}, sessions.Excluded)
+
+	kbs := bySection[SectionKnowledgeBases]
+	assert.Equal(t, 1, kbs.Items)
+	assert.Equal(t, 4, kbs.Files)
+	require.Len(t, kbs.Excluded, 1)
+	assert.Equal(t, docMissing, kbs.Excluded[0].ItemID)
+	assert.Equal(t, ReasonOriginalNotStored, kbs.Excluded[0].Reason)
+
+	assert.Equal(t, 1, bySection[SectionAgents].Items)
+	assert.Equal(t, 2, bySection[SectionUsage].Items)
+	require.Len(t, bySection[SectionUsage].Excluded, 1)
+	assert.Equal(t, ReasonMetadataOnly, bySection[SectionUsage].Excluded[0].Reason)
+	assert.Equal(t, 1, bySection[SectionInvoices].Items)
+
+	// 临时部分在合并后删除
+	for _, section := range Sections {
+		exists, err := store.Exists(ctx, partKey(job, section))
+		require.NoError(t, err)
+		assert.False(t, exists, section)
+	}
+}
+
+func TestCreateOncePerDay(t *testing.T) {
+	svc, _, jobs, _ := newTestService(t)
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	first, err := svc.Create(ctx, 42)
+	require.NoError(t, err)
+	assert.Equal(t, model.DataExportStatusPending, first.Status)
+
+	// 同一天再次导出被拒绝，并告知下一次可导出的时间
+	now = now.Add(23 * time.Hour)
+	_, err = svc.Create(ctx, 42)
+	var limited *LimitError
+	require.ErrorAs(t, err, &limited)
+	assert.Equal(t, first.ID, limited.ExportID)
+	assert.Equal(t, first.CreatedAt.Add(24*time.Hour), limited.RetryAt)
+
+	// 其他用户不受影响
+	_, err = svc.Create(ctx, 43)
+	require.NoError(t, err)
+
+	// 失败的导出不占用次数
+	first.Status = model.DataExportStatusFailed
+	require.NoError(t, jobs.Save(ctx, first))
+	second, err := svc.Create(ctx, 42)
+	require.NoError(t, err)
+
+	now = now.Add(time.Hour)
+	_, err = svc.Create(ctx, 42)
+	require.ErrorAs(t, err, &limited)
+	assert.Equal(t, second.ID, limited.ExportID)
+
+	now = now.Add(23 * time.Hour)
+	_, err = svc.Create(ctx, 42)
+	assert.NoError(t, err)
+}
+
+func TestRunResumesFromCheckpoint(t *testing.T) {
+	svc, source, jobs, store := newTestService(t)
+	source.failUsage = true
+	ctx := context.Background()
+
+	job, err := svc.Create(ctx, 42)
+	require.NoError(t, err)
+	require.Error(t, svc.Run(ctx, job))
+
+	saved, err := jobs.FindByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, string(SectionUsage), saved.Stage)
+	assert.Equal(t, 4*90/len(Sections), saved.Progress)
+
+	// 续跑时已完成的部分不会重新读取
+	source.failUsage = false
+	require.NoError(t, svc.Run(ctx, saved))
+	assert.Equal(t, 1, source.sessionReads)
+	assert.Equal(t, model.DataExportStatusCompleted, saved.Status)
+
+	archive := readArchive(t, store, saved.ObjectKey)
+	assert.Contains(t, archive, "sessions/"+source.sessions[0].ID.String()+".json")
+	assert.Contains(t, archive, "usage/usage.csv")
+}
+
+func TestSignedDownload(t *testing.T) {
+	svc, _, _, _ := newTestService(t)
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	job, err := svc.Create(ctx, 42)
+	require.NoError(t, err)
+	assert.Empty(t, svc.DownloadURL(job), "no link before completion")
+	require.NoError(t, svc.Run(ctx, job))
+
+	link := svc.DownloadURL(job)
+	require.NotEmpty(t, link)
+	assert.True(t, strings.HasPrefix(link, "http://localhost:8080/api/v1/user/export/"+job.ID.String()+"/download?"))
+
+	expires := now.Add(24 * time.Hour).Unix()
+	signature := svc.sign(job.ID.String(), expires)
+
+	_, rc, err := svc.Open(ctx, job.ID, expires, signature)
+	require.NoError(t, err)
+	rc.Close()
+
+	tampered := []byte(signature)
+	tampered[0] ^= 1
+	_, _, err = svc.Open(ctx, job.ID, expires, string(tampered))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, _, err = svc.Open(ctx, job.ID, expires+1, signature)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// 链接过期
+	now = now.Add(25 * time.Hour)
+	_, _, err = svc.Open(ctx, job.ID, expires, signature)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// 归档保留期满后清理
+	now = now.Add(7 * 24 * time.Hour)
+	purged, err := svc.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	expired, err := svc.Get(ctx, 42, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.DataExportStatusExpired, expired.Status)
+
+	fresh := now.Add(time.Hour).Unix()
+	_, _, err = svc.Open(ctx, job.ID, fresh, svc.sign(job.ID.String(), fresh))
+	assert.ErrorIs(t, err, ErrExportExpired)
+
+	_, err = svc.Get(ctx, 43, job.ID)
+	assert.ErrorIs(t, err, ErrExportNotFound)
+}
@@ -0,0 +1,119 @@
+package dataexport
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// batchSize 每批读取的记录数
+const batchSize = 500
+
+// DBSource 从数据库分批读取导出数据
+type DBSource struct {
+	db *gorm.DB
+}
+
+// NewDBSource 创建数据库数据来源
+func NewDBSource(db *gorm.DB) *DBSource {
+	return &DBSource{db: db}
+}
+
+// eachInBatches 按主键分批读取并逐条回调
+func eachInBatches[T any](ctx context.Context, q *gorm.DB, fn func(*T) error) error {
+	var batch []T
+	return q.WithContext(ctx).FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return ctx.Err()
+	}).Error
+}
+
+// Profile 用户资料与偏好设置
+func (s *DBSource) Profile(ctx context.Context, userID int) (*model.User, *model.UserSettings, error) {
+	var user model.User
+	if err := s.db.WithContext(ctx).First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	var settings model.UserSettings
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &user, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, &settings, nil
+}
+
+// EachSession 用户的全部会话（含已归档）
+func (s *DBSource) EachSession(ctx context.Context, userID int, fn func(*model.Session) error) error {
+	return eachInBatches(ctx, s.db.Where("user_id = ?", userID), fn)
+}
+
+// EachMessage 按 (created_at, id) 游标分页读取会话消息，保证时间顺序
+func (s *DBSource) EachMessage(ctx context.Context, sessionID uuid.UUID, fn func(*model.Message) error) error {
+	var (
+		lastTime time.Time
+		lastID   uuid.UUID
+		first    = true
+	)
+	for {
+		q := s.db.WithContext(ctx).Where("session_id = ?", sessionID)
+		if !first {
+			q = q.Where("(created_at, id) > (?, ?)", lastTime, lastID)
+		}
+
+		var batch []model.Message
+		if err := q.Order("created_at ASC, id ASC").Limit(batchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		first = false
+		lastTime, lastID = batch[len(batch)-1].CreatedAt, batch[len(batch)-1].ID
+	}
+}
+
+// EachKnowledgeBase 用户未删除的知识库
+func (s *DBSource) EachKnowledgeBase(ctx context.Context, userID int, fn func(*model.KnowledgeBase) error) error {
+	return eachInBatches(ctx, s.db.Where("user_id = ? AND deleted_at IS NULL", userID), fn)
+}
+
+// EachDocument 知识库中未删除的文档
+func (s *DBSource) EachDocument(ctx context.Context, kbID int, fn func(*model.Document) error) error {
+	return eachInBatches(ctx, s.db.Where("knowledge_base_id = ? AND deleted_at IS NULL", kbID), fn)
+}
+
+// EachAgent 用户自建的助手
+func (s *DBSource) EachAgent(ctx context.Context, userID int, fn func(*model.Agent) error) error {
+	return eachInBatches(ctx, s.db.Where("user_id = ? AND deleted_at IS NULL", userID), fn)
+}
+
+// EachUsageLog 用户在线保留的用量记录
+func (s *DBSource) EachUsageLog(ctx context.Context, userID int, fn func(*model.UnifiedLog) error) error {
+	return eachInBatches(ctx, s.db.Where("user_id = ?", userID), fn)
+}
+
+// EachInvoice 用户的账单
+func (s *DBSource) EachInvoice(ctx context.Context, userID int, fn func(*model.Invoice) error) error {
+	return eachInBatches(ctx, s.db.Where("user_id = ?", strconv.Itoa(userID)), fn)
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/abilitycheck"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+// AbilityCheckHandler 渠道能力一致性检查与修复Handler
+type AbilityCheckHandler struct {
+	checker  *abilitycheck.Checker
+	onRepair func(ctx context.Context) error
+}
+
+// NewAbilityCheckHandler 创建一致性检查Handler，onRepair 在修复产生变更后调用（如重新加载渠道）
+func NewAbilityCheckHandler(checker *abilitycheck.Checker, onRepair func(ctx context.Context) error) *AbilityCheckHandler {
+	return &AbilityCheckHandler{
+		checker:  checker,
+		onRepair: onRepair,
+	}
+}
+
+// VerifyAbilities 检查 channel_abilities 与渠道数据的一致性
+// GET /v1/admin/channels/abilities/verify
+// POST /v1/admin/channels/abilities/verify?repair=true
+func (h *AbilityCheckHandler) VerifyAbilities(c *gin.Context) {
+	repair := false
+	if c.Request.Method == http.MethodPost {
+		repair, _ = strconv.ParseBool(c.DefaultQuery("repair", "false"))
+	}
+
+	report, err := h.checker.Run(c.Request.Context(), repair)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	if report.Repaired && report.Deleted+report.Disabled > 0 {
+		logger.Info("channel abilities repaired",
+			zap.Int("deleted", report.Deleted),
+			zap.Int("disabled", report.Disabled))
+		if h.onRepair != nil {
+			if err := h.onRepair(c.Request.Context()); err != nil {
+				logger.Error("failed to reload channels after ability repair", zap.Error(err))
+			}
+		}
+	}
+
+	utils.Success(c, report, "")
+}
+
+// RegisterRoutes 注册路由
+func (h *AbilityCheckHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/channels/abilities/verify", h.VerifyAbilities)
+	r.POST("/channels/abilities/verify", h.VerifyAbilities)
+}
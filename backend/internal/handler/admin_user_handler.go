@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// AdminUserHandler 管理后台用户管理
+type AdminUserHandler struct {
+	users *service.AdminUserService
+}
+
+// NewAdminUserHandler 创建管理后台用户 Handler
+func NewAdminUserHandler(users *service.AdminUserService) *AdminUserHandler {
+	return &AdminUserHandler{users: users}
+}
+
+// UpdateUserStatusRequest 停用或启用用户
+type UpdateUserStatusRequest struct {
+	Status *int   `json:"status" binding:"required"` // 0 停用，1 启用
+	Reason string `json:"reason"`
+}
+
+// UpdateUserRoleRequest 修改用户角色
+type UpdateUserRoleRequest struct {
+	Role *int `json:"role" binding:"required"`
+}
+
+// AdjustQuotaRequest 调整用户额度，amount 为正时增加、为负时扣减
+type AdjustQuotaRequest struct {
+	Amount int64  `json:"amount" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
+}
+
+// ListUsers 分页查询用户
+// GET /api/v1/admin/users?q=&role=&status=&registered_from=&registered_to=&page=1&page_size=20
+func (h *AdminUserHandler) ListUsers(c *gin.Context) {
+	filter := &repository.UserFilter{Search: c.Query("q")}
+	filter.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	filter.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	var ok bool
+	if filter.Role, ok = optionalIntQuery(c, "role"); !ok {
+		return
+	}
+	if filter.Status, ok = optionalIntQuery(c, "status"); !ok {
+		return
+	}
+	if filter.CreatedFrom, ok = optionalDateQuery(c, "registered_from", false); !ok {
+		return
+	}
+	if filter.CreatedTo, ok = optionalDateQuery(c, "registered_to", true); !ok {
+		return
+	}
+
+	users, total, err := h.users.List(c.Request.Context(), filter)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"users":     users,
+		"total":     total,
+		"page":      filter.Page,
+		"page_size": filter.PageSize,
+	}, "")
+}
+
+// UpdateStatus 停用或重新启用用户，停用后其访问令牌立即失效
+// PUT /api/v1/admin/users/:id/status
+func (h *AdminUserHandler) UpdateStatus(c *gin.Context) {
+	id, ok := adminTargetUserID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateUserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	user, err := h.users.SetStatus(c.Request.Context(), adminActor(c), id, *req.Status, req.Reason)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+
+	utils.Success(c, user, "用户状态已更新")
+}
+
+// UpdateRole 修改用户角色
+// PUT /api/v1/admin/users/:id/role
+func (h *AdminUserHandler) UpdateRole(c *gin.Context) {
+	id, ok := adminTargetUserID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	user, err := h.users.SetRole(c.Request.Context(), adminActor(c), id, *req.Role)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+
+	utils.Success(c, user, "用户角色已更新")
+}
+
+// AdjustQuota 增加或扣减用户额度，原因记录到额度变更日志
+// POST /api/v1/admin/users/:id/quota
+func (h *AdminUserHandler) AdjustQuota(c *gin.Context) {
+	id, ok := adminTargetUserID(c)
+	if !ok {
+		return
+	}
+
+	var req AdjustQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	quotaLog, err := h.users.AdjustQuota(c.Request.Context(), adminActor(c), id, req.Amount, req.Reason)
+	if err != nil {
+		utils.RespondError(c, err)
+		return
+	}
+
+	utils.Success(c, quotaLog, "额度已调整")
+}
+
+// RegisterRoutes 注册路由，r 需已挂载认证与管理员角色校验
+func (h *AdminUserHandler) RegisterRoutes(r *gin.RouterGroup) {
+	users := r.Group("/users")
+	{
+		users.GET("", h.ListUsers)
+		users.PUT("/:id/status", h.UpdateStatus)
+		users.PUT("/:id/role", h.UpdateRole)
+		users.POST("/:id/quota", h.AdjustQuota)
+	}
+}
+
+// adminActor 发起请求的管理员
+func adminActor(c *gin.Context) service.AdminActor {
+	userID, _ := middleware.UserIDFromContext(c)
+	return service.AdminActor{UserID: userID, IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+}
+
+// adminTargetUserID 解析路径中的用户 ID，无效时返回 400
+func adminTargetUserID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		utils.BadRequest(c, "无效的用户 ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// optionalIntQuery 解析可选的整数查询参数，格式错误时返回 400
+func optionalIntQuery(c *gin.Context, name string) (*int, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		utils.BadRequest(c, "无效的 "+name)
+		return nil, false
+	}
+	return &v, true
+}
+
+// optionalDateQuery 解析可选的日期（YYYY-MM-DD）或 RFC3339 时间，endOfDay 为真时日期取次日零点作为开区间上界
+func optionalDateQuery(c *gin.Context, name string, endOfDay bool) (*time.Time, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, true
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		utils.BadRequest(c, "无效的 "+name+"，应为 YYYY-MM-DD 或 RFC3339 时间")
+		return nil, false
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, true
+}
@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// adminTestUsers 内存中的用户与额度日志
+type adminTestUsers struct {
+	mu        sync.Mutex
+	users     map[int]*model.User
+	quotaLogs []*model.QuotaLog
+}
+
+func (s *adminTestUsers) List(ctx context.Context, filter *repository.UserFilter) ([]*model.User, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matched []*model.User
+	for _, user := range s.users {
+		if filter.Search != "" && !strings.Contains(user.Username, filter.Search) && !strings.Contains(user.Email, filter.Search) {
+			continue
+		}
+		if filter.Role != nil && user.Role != *filter.Role {
+			continue
+		}
+		if filter.Status != nil && user.Status != *filter.Status {
+			continue
+		}
+		if filter.CreatedFrom != nil && user.CreatedAt.Before(*filter.CreatedFrom) {
+			continue
+		}
+		if filter.CreatedTo != nil && !user.CreatedAt.Before(*filter.CreatedTo) {
+			continue
+		}
+		copied := *user
+		matched = append(matched, &copied)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].CreatedAt.After(matched[j].CreatedAt) })
+	return matched, int64(len(matched)), nil
+}
+
+func (s *adminTestUsers) FindByID(ctx context.Context, id int) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *adminTestUsers) UpdateStatus(ctx context.Context, userID, status int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[userID].Status = status
+	return nil
+}
+
+func (s *adminTestUsers) UpdateRole(ctx context.Context, userID, role int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[userID].Role = role
+	return nil
+}
+
+func (s *adminTestUsers) AdjustQuota(ctx context.Context, userID int, delta int64, reason string) (*model.QuotaLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, nil
+	}
+	if user.Quota+delta < 0 {
+		return nil, repository.ErrQuotaWouldBeNegative
+	}
+	quotaLog := &model.QuotaLog{
+		ID:            len(s.quotaLogs) + 1,
+		UserID:        userID,
+		OperationType: "adjust",
+		Amount:        delta,
+		Reason:        reason,
+		BalanceBefore: user.Quota,
+		BalanceAfter:  user.Quota + delta,
+	}
+	user.Quota += delta
+	s.quotaLogs = append(s.quotaLogs, quotaLog)
+	return quotaLog, nil
+}
+
+// status 供 AuthMiddlewareWithStatus 查询用户状态
+func (s *adminTestUsers) status(ctx context.Context, userID int) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[userID]
+	if !ok {
+		return 0, false, nil
+	}
+	return user.Status, true, nil
+}
+
+func TestAdminUserHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtCfg := &config.JWTConfig{Secret: "admin-secret"}
+	utils.InitJWT(jwtCfg)
+
+	day := func(d int) time.Time { return time.Date(2026, 5, d, 8, 0, 0, 0, time.UTC) }
+	store := &adminTestUsers{users: map[int]*model.User{
+		1: {ID: 1, Username: "root", Email: "root@example.com", Role: model.UserRoleAdmin, Status: model.UserStatusActive, CreatedAt: day(1)},
+		2: {ID: 2, Username: "alice", Email: "alice@example.com", Role: model.UserRoleUser, Status: model.UserStatusActive, Quota: 1000, CreatedAt: day(3)},
+		3: {ID: 3, Username: "bob", Email: "bob@corp.example", Role: model.UserRoleUser, Status: model.UserStatusActive, CreatedAt: day(5)},
+	}}
+	var audits []*model.PermissionAuditLog
+	users := service.NewAdminUserService(store, func(ctx context.Context, entry *model.PermissionAuditLog) error {
+		audits = append(audits, entry)
+		return nil
+	})
+
+	r := gin.New()
+	auth := r.Group("/api/v1", middleware.AuthMiddlewareWithStatus([]byte(jwtCfg.Secret), store.status))
+	auth.GET("/user/profile", func(c *gin.Context) { utils.Success(c, nil, "") })
+	NewAdminUserHandler(users).RegisterRoutes(auth.Group("/admin", middleware.RoleMiddleware(model.UserRoleAdmin)))
+
+	call := func(userID int, method, path string, body interface{}) *httptest.ResponseRecorder {
+		user := store.users[userID]
+		token, err := utils.GenerateAccessToken(user.ID, user.Username, user.Role, 1)
+		require.NoError(t, err)
+		var payload []byte
+		if body != nil {
+			payload, _ = json.Marshal(body)
+		}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	errorCode := func(w *httptest.ResponseRecorder) utils.ErrorCode {
+		var resp utils.Response
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.NotNil(t, resp.Error, w.Body.String())
+		return resp.Error.ErrorCode
+	}
+
+	t.Run("list filters", func(t *testing.T) {
+		var page struct {
+			Users []model.User `json:"users"`
+			Total int64        `json:"total"`
+		}
+		require.NoError(t, json.Unmarshal(mustData(t, call(1, http.MethodGet, "/api/v1/admin/users?q=example.com&role=1", nil)), &page))
+		require.EqualValues(t, 1, page.Total)
+		assert.Equal(t, "alice", page.Users[0].Username)
+
+		require.NoError(t, json.Unmarshal(mustData(t, call(1, http.MethodGet, "/api/v1/admin/users?registered_from=2026-05-02&registered_to=2026-05-05", nil)), &page))
+		require.EqualValues(t, 2, page.Total, "registered_to is inclusive of the whole day")
+		assert.Equal(t, "bob", page.Users[0].Username)
+
+		assert.Equal(t, http.StatusBadRequest, call(1, http.MethodGet, "/api/v1/admin/users?status=x", nil).Code)
+		assert.Equal(t, http.StatusForbidden, call(2, http.MethodGet, "/api/v1/admin/users", nil).Code, "admin role required")
+	})
+
+	t.Run("suspend blocks access tokens", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, call(3, http.MethodGet, "/api/v1/user/profile", nil).Code)
+
+		w := call(1, http.MethodPut, "/api/v1/admin/users/3/status", gin.H{"status": 0, "reason": "abuse"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = call(3, http.MethodGet, "/api/v1/user/profile", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, utils.CodeUserSuspended, errorCode(w))
+
+		require.Equal(t, http.StatusOK, call(1, http.MethodPut, "/api/v1/admin/users/3/status", gin.H{"status": 1}).Code)
+		assert.Equal(t, http.StatusOK, call(3, http.MethodGet, "/api/v1/user/profile", nil).Code)
+
+		assert.Equal(t, http.StatusBadRequest, call(1, http.MethodPut, "/api/v1/admin/users/3/status", gin.H{"status": 2}).Code)
+		assert.Equal(t, http.StatusForbidden, call(1, http.MethodPut, "/api/v1/admin/users/1/status", gin.H{"status": 0}).Code, "admins cannot suspend themselves")
+		assert.Equal(t, http.StatusNotFound, call(1, http.MethodPut, "/api/v1/admin/users/99/status", gin.H{"status": 0}).Code)
+	})
+
+	t.Run("role and quota", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, call(1, http.MethodPut, "/api/v1/admin/users/2/role", gin.H{"role": 100}).Code)
+		assert.Equal(t, model.UserRoleAdmin, store.users[2].Role)
+		assert.Equal(t, http.StatusBadRequest, call(1, http.MethodPut, "/api/v1/admin/users/2/role", gin.H{"role": 0}).Code)
+
+		var quotaLog model.QuotaLog
+		require.NoError(t, json.Unmarshal(mustData(t, call(1, http.MethodPost, "/api/v1/admin/users/2/quota", gin.H{"amount": -400, "reason": "chargeback"})), &quotaLog))
+		assert.EqualValues(t, 1000, quotaLog.BalanceBefore)
+		assert.EqualValues(t, 600, quotaLog.BalanceAfter)
+		assert.Equal(t, "chargeback", quotaLog.Reason)
+
+		w := call(1, http.MethodPost, "/api/v1/admin/users/2/quota", gin.H{"amount": -601, "reason": "too much"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, http.StatusBadRequest, call(1, http.MethodPost, "/api/v1/admin/users/2/quota", gin.H{"amount": 100}).Code, "reason is required")
+		assert.EqualValues(t, 600, store.users[2].Quota)
+	})
+
+	// 每次实际生效的变更都写入一条审计日志
+	ops := make([]string, 0, len(audits))
+	for _, entry := range audits {
+		assert.Equal(t, 1, entry.UserID)
+		require.NotNil(t, entry.TargetUserID)
+		ops = append(ops, entry.Operation)
+	}
+	assert.Equal(t, []string{
+		service.AuditOpSuspendUser,
+		service.AuditOpReactivateUser,
+		service.AuditOpChangeUserRole,
+		service.AuditOpAdjustQuota,
+	}, ops)
+
+	var details struct {
+		Before map[string]int `json:"before"`
+		After  map[string]int `json:"after"`
+		Reason string         `json:"reason"`
+	}
+	require.NoError(t, json.Unmarshal(audits[0].Details, &details))
+	assert.Equal(t, map[string]int{"status": 1}, details.Before)
+	assert.Equal(t, map[string]int{"status": 0}, details.After)
+	assert.Equal(t, "abuse", details.Reason)
+}
@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
 )
@@ -234,7 +236,14 @@ func (h *AgentHandler) ForkAgent(c *gin.Context) {
 
 	agent, err := h.agentService.ForkAgent(c.Request.Context(), userID, id, req.ForkName)
 	if err != nil {
-		utils.InternalError(c, err.Error())
+		switch err.Error() {
+		case "permission denied":
+			c.JSON(http.StatusForbidden, gin.H{"error": "无权限操作"})
+		case "original agent not found":
+			utils.NotFound(c, "助手不存在")
+		default:
+			utils.InternalError(c, err.Error())
+		}
 		return
 	}
 
@@ -259,3 +268,98 @@ func (h *AgentHandler) GetAgentStats(c *gin.Context) {
 	utils.Success(c, stats, "")
 }
 
+// respondAgentPromptError 将提示词版本相关的错误映射为 HTTP 响应
+func respondAgentPromptError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrPromptVersionNotFound):
+		utils.NotFound(c, "版本不存在")
+	case err.Error() == "agent not found":
+		utils.NotFound(c, "助手不存在")
+	case err.Error() == "permission denied":
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限操作"})
+	default:
+		utils.InternalError(c, err.Error())
+	}
+}
+
+// GetPromptVersions 获取助手系统提示词的历史版本
+// GET /api/v1/agents/:id/prompt/versions
+func (h *AgentHandler) GetPromptVersions(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid agent ID")
+		return
+	}
+
+	versions, err := h.agentService.GetAgentPromptVersions(c.Request.Context(), userID, id)
+	if err != nil {
+		respondAgentPromptError(c, err)
+		return
+	}
+
+	utils.Success(c, versions, "")
+}
+
+// GetPromptVersion 获取助手系统提示词的指定版本
+// GET /api/v1/agents/:id/prompt/versions/:version
+func (h *AgentHandler) GetPromptVersion(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid agent ID")
+		return
+	}
+	version, err := strconv.ParseInt(c.Param("version"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid version")
+		return
+	}
+
+	v, err := h.agentService.GetAgentPromptVersion(c.Request.Context(), userID, id, version)
+	if err != nil {
+		respondAgentPromptError(c, err)
+		return
+	}
+
+	utils.Success(c, v, "")
+}
+
+// RollbackPrompt 将助手系统提示词回滚到指定版本
+// POST /api/v1/agents/:id/prompt/rollback
+func (h *AgentHandler) RollbackPrompt(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid agent ID")
+		return
+	}
+
+	var req struct {
+		Version int64 `json:"version" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	agent, err := h.agentService.RollbackAgentPrompt(c.Request.Context(), userID, id, req.Version)
+	if err != nil {
+		respondAgentPromptError(c, err)
+		return
+	}
+
+	utils.Success(c, agent, "提示词已回滚")
+}
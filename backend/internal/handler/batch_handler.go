@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// maxBatchBodySize 批量任务请求体的大小上限
+const maxBatchBodySize = 64 << 20
+
+// BatchRelayer 批量 Chat Completion 任务（由 service.RelayBatchService 实现）
+type BatchRelayer interface {
+	Create(ctx context.Context, token *model.Token, clientIP string, reqs []*relay.ChatCompletionRequest) (*model.RelayBatch, error)
+	Get(ctx context.Context, userID int, id uuid.UUID) (*model.RelayBatch, error)
+	Results(ctx context.Context, userID int, id uuid.UUID) ([]service.RelayBatchResult, error)
+}
+
+// BatchHandler 批量 Chat Completion 接口
+type BatchHandler struct {
+	batches BatchRelayer
+}
+
+// NewBatchHandler 创建批量任务 Handler
+func NewBatchHandler(batches BatchRelayer) *BatchHandler {
+	return &BatchHandler{batches: batches}
+}
+
+// BatchResponse 批量任务状态
+type BatchResponse struct {
+	*model.RelayBatch
+	Object     string `json:"object"`
+	ResultsURL string `json:"results_url"` // 按输入顺序下载已处理条目的结果（JSONL）
+}
+
+func newBatchResponse(batch *model.RelayBatch) *BatchResponse {
+	return &BatchResponse{
+		RelayBatch: batch,
+		Object:     "batch",
+		ResultsURL: fmt.Sprintf("/v1/batches/%s/results", batch.ID),
+	}
+}
+
+// CreateBatch 提交一批 Chat Completion 请求，后台以有限并发处理
+// POST /v1/batches
+//
+// 请求体为请求对象的 JSON 数组，或每行一个请求对象的 JSONL。
+func (h *BatchHandler) CreateBatch(c *gin.Context) {
+	reqs, err := parseBatchRequests(http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchBodySize))
+	if err != nil {
+		utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInvalidRequest, err))
+		return
+	}
+
+	batch, err := h.batches.Create(c.Request.Context(), middleware.APITokenFromContext(c), c.ClientIP(), reqs)
+	if err != nil {
+		if errors.Is(err, service.ErrBatchInvalid) {
+			utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInvalidRequest, err))
+			return
+		}
+		utils.RespondOpenAIError(c, RelayError(err, nil))
+		return
+	}
+	c.JSON(http.StatusOK, newBatchResponse(batch))
+}
+
+// GetBatch 查询批量任务的状态与成功、失败数
+// GET /v1/batches/:id
+func (h *BatchHandler) GetBatch(c *gin.Context) {
+	id, userID, ok := batchTarget(c)
+	if !ok {
+		return
+	}
+	batch, err := h.batches.Get(c.Request.Context(), userID, id)
+	if err != nil {
+		respondBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newBatchResponse(batch))
+}
+
+// GetBatchResults 按输入顺序下载已处理条目的结果，每行一个 {"index", "response" | "error"}
+// GET /v1/batches/:id/results
+func (h *BatchHandler) GetBatchResults(c *gin.Context) {
+	id, userID, ok := batchTarget(c)
+	if !ok {
+		return
+	}
+	results, err := h.batches.Results(c.Request.Context(), userID, id)
+	if err != nil {
+		respondBatchError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	for _, result := range results {
+		if err := enc.Encode(result); err != nil {
+			return
+		}
+	}
+}
+
+// parseBatchRequests 解析 JSON 数组或 JSONL 格式的请求列表
+func parseBatchRequests(body io.Reader) ([]*relay.ChatCompletionRequest, error) {
+	r := bufio.NewReader(body)
+	first, err := peekNonSpace(r)
+	if err == io.EOF {
+		return nil, errors.New("batch must contain at least one request")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(r)
+	var reqs []*relay.ChatCompletionRequest
+	if first == '[' {
+		if err := dec.Decode(&reqs); err != nil {
+			return nil, err
+		}
+		return reqs, nil
+	}
+	for {
+		var req relay.ChatCompletionRequest
+		if err := dec.Decode(&req); err == io.EOF {
+			return reqs, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("request %d: %w", len(reqs), err)
+		}
+		reqs = append(reqs, &req)
+	}
+}
+
+// peekNonSpace 返回第一个非空白字符而不消耗它
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, r.UnreadByte()
+	}
+}
+
+// batchTarget 解析任务 ID 与调用方，失败时已写入错误响应
+func batchTarget(c *gin.Context) (uuid.UUID, int, bool) {
+	token := middleware.APITokenFromContext(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil || token == nil {
+		respondBatchError(c, service.ErrBatchNotFound)
+		return uuid.Nil, 0, false
+	}
+	return id, token.UserID, true
+}
+
+// respondBatchError 任务不存在或不属于调用方时返回 404
+func respondBatchError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrBatchNotFound) {
+		utils.RespondOpenAIError(c, utils.WrapError(utils.CodeNotFound, err))
+		return
+	}
+	utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInternal, err))
+}
+
+// RegisterRoutes 注册路由
+func (h *BatchHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.POST("/batches", h.CreateBatch)
+	r.GET("/batches/:id", h.GetBatch)
+	r.GET("/batches/:id/results", h.GetBatchResults)
+}
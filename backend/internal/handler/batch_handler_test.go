@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBatchRelayer 保存创建的任务，结果固定为一个成功与一个失败的条目
+type fakeBatchRelayer struct {
+	batch *model.RelayBatch
+	reqs  []*relay.ChatCompletionRequest
+}
+
+func (f *fakeBatchRelayer) Create(ctx context.Context, token *model.Token, clientIP string, reqs []*relay.ChatCompletionRequest) (*model.RelayBatch, error) {
+	if len(reqs) > 2 {
+		return nil, fmt.Errorf("%w: batch has %d requests, the limit is 2", service.ErrBatchInvalid, len(reqs))
+	}
+	f.reqs = reqs
+	f.batch = &model.RelayBatch{ID: uuid.New(), UserID: token.UserID, Status: model.RelayBatchStatusPending, Total: len(reqs)}
+	return f.batch, nil
+}
+
+func (f *fakeBatchRelayer) Get(ctx context.Context, userID int, id uuid.UUID) (*model.RelayBatch, error) {
+	if f.batch == nil || f.batch.ID != id || f.batch.UserID != userID {
+		return nil, service.ErrBatchNotFound
+	}
+	return f.batch, nil
+}
+
+func (f *fakeBatchRelayer) Results(ctx context.Context, userID int, id uuid.UUID) ([]service.RelayBatchResult, error) {
+	if _, err := f.Get(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	return []service.RelayBatchResult{
+		{Index: 0, Response: json.RawMessage(`{"id":"chatcmpl-1"}`)},
+		{Index: 1, Error: json.RawMessage(`{"message":"Invalid prompt.","type":"invalid_request_error"}`)},
+	}, nil
+}
+
+func serveBatch(relayer BatchRelayer, userID int, method, path, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(middleware.APITokenKey, &model.Token{ID: 3, UserID: userID})
+	})
+	NewBatchHandler(relayer).RegisterRoutes(r.Group("/v1"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateBatchAcceptsArrayAndJSONL(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"json array", ` [{"model":"gpt-4","messages":[{"role":"user","content":"one"}]},{"model":"gpt-4","messages":[{"role":"user","content":"two"}]}]`},
+		{"jsonl", "{\"model\":\"gpt-4\",\"messages\":[{\"role\":\"user\",\"content\":\"one\"}]}\n{\"model\":\"gpt-4\",\"messages\":[{\"role\":\"user\",\"content\":\"two\"}]}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relayer := &fakeBatchRelayer{}
+			w := serveBatch(relayer, 42, http.MethodPost, "/v1/batches", tt.body)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			require.Len(t, relayer.reqs, 2)
+			assert.Equal(t, "two", relayer.reqs[1].Messages[0].Content)
+			var resp map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, "batch", resp["object"])
+			assert.Equal(t, relayer.batch.ID.String(), resp["id"])
+			assert.Equal(t, "/v1/batches/"+relayer.batch.ID.String()+"/results", resp["results_url"])
+		})
+	}
+}
+
+func TestCreateBatchRejectsInvalidInput(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"empty body", "  \n"},
+		{"malformed line", "{\"model\":\"gpt-4\"}\nnot json\n"},
+		{"too many requests", `[{"model":"gpt-4"},{"model":"gpt-4"},{"model":"gpt-4"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveBatch(&fakeBatchRelayer{}, 42, http.MethodPost, "/v1/batches", tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, "invalid_request_error", openAIErrorBody(t, w)["type"])
+		})
+	}
+}
+
+func TestGetBatchAndResults(t *testing.T) {
+	relayer := &fakeBatchRelayer{}
+	w := serveBatch(relayer, 42, http.MethodPost, "/v1/batches", `[{"model":"gpt-4"}]`)
+	require.Equal(t, http.StatusOK, w.Code)
+	id := relayer.batch.ID.String()
+
+	w = serveBatch(relayer, 42, http.MethodGet, "/v1/batches/"+id, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var status map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, "pending", status["status"])
+	assert.EqualValues(t, 1, status["total"])
+
+	w = serveBatch(relayer, 42, http.MethodGet, "/v1/batches/"+id+"/results", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 2)
+	assert.EqualValues(t, 0, lines[0]["index"])
+	assert.NotNil(t, lines[0]["response"])
+	assert.EqualValues(t, 1, lines[1]["index"])
+	assert.NotNil(t, lines[1]["error"])
+	assert.NotContains(t, lines[1], "response")
+
+	// 其它用户的任务与无效的 ID 均返回 404
+	for _, path := range []string{"/v1/batches/" + id, "/v1/batches/" + id + "/results"} {
+		w = serveBatch(relayer, 7, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	}
+	w = serveBatch(relayer, 42, http.MethodGet, "/v1/batches/not-a-uuid", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
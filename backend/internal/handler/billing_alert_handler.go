@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// BillingAlertHandler 配额预警历史与通知偏好
+type BillingAlertHandler struct {
+	alerts *service.QuotaAlertService
+}
+
+// NewBillingAlertHandler 创建配额预警 Handler
+func NewBillingAlertHandler(alerts *service.QuotaAlertService) *BillingAlertHandler {
+	return &BillingAlertHandler{alerts: alerts}
+}
+
+// AlertPreferenceRequest 设置某个渠道的通知偏好
+type AlertPreferenceRequest struct {
+	Target   string `json:"target" binding:"required"`
+	Secret   string `json:"secret"`    // Webhook 签名密钥
+	MinLevel string `json:"min_level"` // warning（默认）、critical、exhausted
+	Enabled  *bool  `json:"enabled"`   // 默认启用
+}
+
+// ListAlerts 分页查询当前用户的预警历史及各渠道的投递状态
+// GET /api/v1/billing/alerts?page=1&page_size=20
+func (h *BillingAlertHandler) ListAlerts(c *gin.Context) {
+	userID, ok := currentBillingUser(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	alerts, total, err := h.alerts.ListAlerts(c.Request.Context(), userID, page, pageSize)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{
+		"alerts":    alerts,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}, "")
+}
+
+// ListPreferences 查询当前用户的通知偏好
+// GET /api/v1/billing/alerts/preferences
+func (h *BillingAlertHandler) ListPreferences(c *gin.Context) {
+	userID, ok := currentBillingUser(c)
+	if !ok {
+		return
+	}
+	prefs, err := h.alerts.ListPreferences(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+	utils.Success(c, prefs, "")
+}
+
+// SavePreference 设置当前用户在某个渠道（webhook 或 email）上的通知偏好
+// PUT /api/v1/billing/alerts/preferences/:channel
+func (h *BillingAlertHandler) SavePreference(c *gin.Context) {
+	userID, ok := currentBillingUser(c)
+	if !ok {
+		return
+	}
+	var req AlertPreferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	pref, err := h.alerts.SavePreference(c.Request.Context(), userID, service.AlertPreferenceInput{
+		Channel:  c.Param("channel"),
+		Target:   req.Target,
+		Secret:   req.Secret,
+		MinLevel: req.MinLevel,
+		Enabled:  enabled,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAlertPreference) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalError(c, err.Error())
+		return
+	}
+	utils.Success(c, pref, "通知偏好已保存")
+}
+
+// DeletePreference 删除当前用户在某个渠道上的通知偏好
+// DELETE /api/v1/billing/alerts/preferences/:channel
+func (h *BillingAlertHandler) DeletePreference(c *gin.Context) {
+	userID, ok := currentBillingUser(c)
+	if !ok {
+		return
+	}
+	found, err := h.alerts.DeletePreference(c.Request.Context(), userID, c.Param("channel"))
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+	if !found {
+		utils.NotFound(c, "通知偏好不存在")
+		return
+	}
+	utils.Success(c, nil, "通知偏好已删除")
+}
+
+// currentBillingUser 计费模块以字符串形式使用用户 ID，未登录时返回 401 并返回 false
+func currentBillingUser(c *gin.Context) (string, bool) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return "", false
+	}
+	return strconv.Itoa(userID), true
+}
+
+// RegisterRoutes 注册路由
+func (h *BillingAlertHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/billing/alerts", h.ListAlerts)
+	r.GET("/billing/alerts/preferences", h.ListPreferences)
+	r.PUT("/billing/alerts/preferences/:channel", h.SavePreference)
+	r.DELETE("/billing/alerts/preferences/:channel", h.DeletePreference)
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// BillingAutoRechargeHandler 自动充值配置与保存的支付方式
+type BillingAutoRechargeHandler struct {
+	autoRecharge *service.AutoRechargeService
+}
+
+// NewBillingAutoRechargeHandler 创建自动充值 Handler
+func NewBillingAutoRechargeHandler(autoRecharge *service.AutoRechargeService) *BillingAutoRechargeHandler {
+	return &BillingAutoRechargeHandler{autoRecharge: autoRecharge}
+}
+
+// AutoRechargeRequest 更新自动充值配置
+type AutoRechargeRequest struct {
+	Enabled          bool    `json:"enabled"`
+	TriggerThreshold float64 `json:"trigger_threshold" binding:"required"` // 使用率百分比
+	RechargeAmount   int64   `json:"recharge_amount" binding:"required"`   // 每次充值额度（分）
+	MaxPerPeriod     int     `json:"max_per_period" binding:"required"`
+	PeriodDays       int     `json:"period_days" binding:"required"`
+}
+
+// GetAutoRecharge 查询当前用户的自动充值配置、支付方式状态与最近的充值记录
+// GET /api/v1/billing/auto-recharge
+func (h *BillingAutoRechargeHandler) GetAutoRecharge(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+	status, err := h.autoRecharge.GetStatus(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+	utils.Success(c, status, "")
+}
+
+// UpdateAutoRecharge 更新当前用户的自动充值配置，启用前需保存支付方式
+// PUT /api/v1/billing/auto-recharge
+func (h *BillingAutoRechargeHandler) UpdateAutoRecharge(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+	var req AutoRechargeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	setting, err := h.autoRecharge.UpdateSettings(c.Request.Context(), userID, service.AutoRechargeInput{
+		Enabled:          req.Enabled,
+		TriggerThreshold: req.TriggerThreshold,
+		RechargeAmount:   req.RechargeAmount,
+		MaxPerPeriod:     req.MaxPerPeriod,
+		PeriodDays:       req.PeriodDays,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAutoRecharge) || errors.Is(err, billing.ErrNoPaymentMethod) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalError(c, err.Error())
+		return
+	}
+	utils.Success(c, setting, "自动充值配置已更新")
+}
+
+// SetupPaymentMethod 创建保存支付方式的 Checkout 页面，保存后恢复被暂停的自动充值
+// POST /api/v1/billing/payment-method/setup
+func (h *BillingAutoRechargeHandler) SetupPaymentMethod(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+	url, err := h.autoRecharge.CreatePaymentMethodSetup(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"checkout_url": url}, "")
+}
+
+// RegisterRoutes 注册路由
+func (h *BillingAutoRechargeHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/billing/auto-recharge", h.GetAutoRecharge)
+	r.PUT("/billing/auto-recharge", h.UpdateAutoRecharge)
+	r.POST("/billing/payment-method/setup", h.SetupPaymentMethod)
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+// AuditOpDiscardDeadLetter 人工丢弃计费死信的审计操作类型
+const AuditOpDiscardDeadLetter = "discard_billing_dead_letter"
+
+// BillingDLQHandler 计费死信管理：查询、重放与人工丢弃
+type BillingDLQHandler struct {
+	billing *billing.AsyncBillingService
+	audit   func(ctx context.Context, entry *model.PermissionAuditLog) error
+}
+
+// NewBillingDLQHandler 创建计费死信 Handler
+func NewBillingDLQHandler(abs *billing.AsyncBillingService) *BillingDLQHandler {
+	return &BillingDLQHandler{
+		billing: abs,
+		audit:   repository.NewAuditLogRepository().Create,
+	}
+}
+
+// ListDeadLetters 分页查询死信
+// GET /api/v1/billing/dlq?user_id=&model=&status=&page=1&page_size=20
+func (h *BillingDLQHandler) ListDeadLetters(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	dls, total, err := h.billing.ListDeadLetters(c.Request.Context(), billing.DeadLetterFilter{
+		UserID:    c.Query("user_id"),
+		ModelName: c.Query("model"),
+		Status:    c.Query("status"),
+		Page:      page,
+		PageSize:  pageSize,
+	})
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"dead_letters": dls,
+		"total":        total,
+		"page":         page,
+		"page_size":    pageSize,
+	}, "")
+}
+
+// ReplayDeadLetter 将死信重新入队，由消费者异步处理
+// POST /api/v1/billing/dlq/:id/replay
+func (h *BillingDLQHandler) ReplayDeadLetter(c *gin.Context) {
+	id, ok := deadLetterID(c)
+	if !ok {
+		return
+	}
+
+	dl, err := h.billing.ReplayDeadLetter(c.Request.Context(), id)
+	if err != nil {
+		respondDeadLetterError(c, err)
+		return
+	}
+	utils.Accepted(c, dl, "死信已重新入队")
+}
+
+// DiscardDeadLetter 人工丢弃死信，reason 必填并写入审计日志
+// DELETE /api/v1/billing/dlq/:id?reason=...
+func (h *BillingDLQHandler) DiscardDeadLetter(c *gin.Context) {
+	adminID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+	id, ok := deadLetterID(c)
+	if !ok {
+		return
+	}
+	reason := strings.TrimSpace(c.Query("reason"))
+	if reason == "" {
+		utils.BadRequest(c, "reason is required")
+		return
+	}
+
+	ctx := c.Request.Context()
+	dl, err := h.billing.GetDeadLetter(ctx, id)
+	if err != nil {
+		respondDeadLetterError(c, err)
+		return
+	}
+	if dl.Status != billing.DeadLetterPending {
+		respondDeadLetterError(c, billing.ErrDeadLetterResolved)
+		return
+	}
+
+	// 审计日志写入失败时不丢弃
+	if err := h.recordAudit(c, adminID, dl, reason); err != nil {
+		utils.InternalError(c, "failed to record audit log")
+		return
+	}
+
+	dl, err = h.billing.DiscardDeadLetter(ctx, id)
+	if err != nil {
+		respondDeadLetterError(c, err)
+		return
+	}
+	utils.Success(c, dl, "死信已丢弃")
+}
+
+// recordAudit 写入丢弃死信的审计日志
+func (h *BillingDLQHandler) recordAudit(c *gin.Context, adminID int, dl *billing.DeadLetter, reason string) error {
+	details, _ := json.Marshal(map[string]interface{}{
+		"dead_letter_id": dl.ID,
+		"event_id":       dl.Event.EventID,
+		"model":          dl.Event.ModelName,
+		"attempts":       dl.Attempts,
+		"reason":         reason,
+	})
+	entry := &model.PermissionAuditLog{
+		UserID:    adminID,
+		Operation: AuditOpDiscardDeadLetter,
+		Details:   details,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		CreatedAt: time.Now(),
+	}
+	if userID, err := strconv.Atoi(dl.Event.UserID); err == nil {
+		entry.TargetUserID = &userID
+	}
+
+	err := h.audit(c.Request.Context(), entry)
+	if err != nil {
+		logger.Error("failed to record dead letter audit log",
+			zap.Int64("dead_letter_id", dl.ID),
+			zap.Int("admin_id", adminID),
+			zap.Error(err))
+	}
+	return err
+}
+
+// deadLetterID 解析路径中的死信 ID，无效时返回 400
+func deadLetterID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		utils.BadRequest(c, "无效的死信 ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// respondDeadLetterError 将死信操作的错误转换为响应
+func respondDeadLetterError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, billing.ErrDeadLetterNotFound):
+		utils.NotFound(c, "死信不存在")
+	case errors.Is(err, billing.ErrDeadLetterResolved):
+		utils.RespondError(c, utils.WrapError(utils.CodeConflict, err))
+	default:
+		utils.InternalError(c, err.Error())
+	}
+}
+
+// RegisterRoutes 注册路由（需挂载在管理员鉴权的路由组下）
+func (h *BillingDLQHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/billing/dlq", h.ListDeadLetters)
+	r.POST("/billing/dlq/:id/replay", h.ReplayDeadLetter)
+	r.DELETE("/billing/dlq/:id", h.DiscardDeadLetter)
+}
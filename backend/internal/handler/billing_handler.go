@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
 )
@@ -288,8 +289,7 @@ func (h *BillingHandler) GetSubscriptionPlans(c *gin.Context) {
 // @Failure 401 {object} gin.H
 // @Router /v1/billing/settings [put]
 func (h *BillingHandler) UpdateBillingSettings(c *gin.Context) {
-	userID, err := ExtractUserID(c)
-	if err != nil {
+	if _, err := ExtractUserID(c); err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
@@ -322,17 +322,12 @@ func (h *BillingHandler) UpdateBillingSettings(c *gin.Context) {
 // 辅助函数
 
 func ExtractUserID(c *gin.Context) (string, error) {
-	userID, ok := c.Get("user_id")
-	if !ok {
-		return "", ErrUnauthorized
-	}
-
-	userIDStr, ok := userID.(string)
+	userID, ok := middleware.UserIDFromContext(c)
 	if !ok {
 		return "", ErrUnauthorized
 	}
 
-	return userIDStr, nil
+	return strconv.Itoa(userID), nil
 }
 
 var ErrUnauthorized = &RequestError{
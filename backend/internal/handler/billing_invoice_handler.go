@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// maxStripeWebhookBytes Webhook 请求体的最大字节数
+const maxStripeWebhookBytes = 64 << 10
+
+// BillingInvoiceHandler 预付费充值：购买额度套餐、查询发票与 Stripe Webhook
+type BillingInvoiceHandler struct {
+	checkout *service.CheckoutService
+}
+
+// NewBillingInvoiceHandler 创建充值发票 Handler
+func NewBillingInvoiceHandler(checkout *service.CheckoutService) *BillingInvoiceHandler {
+	return &BillingInvoiceHandler{checkout: checkout}
+}
+
+// ListPackages 可购买的额度套餐
+// GET /api/v1/billing/packages
+func (h *BillingInvoiceHandler) ListPackages(c *gin.Context) {
+	utils.Success(c, h.checkout.Packages(), "")
+}
+
+// CreateInvoice 为所选套餐创建 Checkout Session，返回待支付发票与支付地址
+// POST /api/v1/billing/invoices
+func (h *BillingInvoiceHandler) CreateInvoice(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	var req struct {
+		PackageID string `json:"package_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	invoice, err := h.checkout.CreateInvoice(c.Request.Context(), userID, req.PackageID)
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownQuotaPackage) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalError(c, err.Error())
+		return
+	}
+	utils.Success(c, invoice, "")
+}
+
+// ListInvoices 分页查询当前用户的充值发票
+// GET /api/v1/billing/invoices?page=1&page_size=20
+func (h *BillingInvoiceHandler) ListInvoices(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	invoices, total, err := h.checkout.ListInvoices(c.Request.Context(), userID, page, pageSize)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{
+		"invoices":  invoices,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}, "")
+}
+
+// StripeWebhook 接收 Stripe 事件，签名无效时返回 400，处理失败时返回 500 由 Stripe 重试
+// POST /webhooks/stripe
+func (h *BillingInvoiceHandler) StripeWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxStripeWebhookBytes))
+	if err != nil {
+		utils.BadRequest(c, "failed to read body")
+		return
+	}
+
+	if err := h.checkout.HandleWebhook(c.Request.Context(), payload, c.GetHeader("Stripe-Signature")); err != nil {
+		if errors.Is(err, service.ErrInvalidStripeSignature) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalError(c, "failed to process webhook")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// RegisterRoutes 注册需要登录的路由
+func (h *BillingInvoiceHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/billing/packages", h.ListPackages)
+	r.GET("/billing/invoices", h.ListInvoices)
+	r.POST("/billing/invoices", h.CreateInvoice)
+}
+
+// RegisterWebhook 注册 Stripe Webhook，不经过 JWT 鉴权，依赖签名校验
+func (h *BillingInvoiceHandler) RegisterWebhook(r gin.IRoutes) {
+	r.POST("/webhooks/stripe", h.StripeWebhook)
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// invoiceTestStore 内存中的充值发票
+type invoiceTestStore struct {
+	mu       sync.Mutex
+	invoices []*model.RechargeInvoice
+}
+
+func (s *invoiceTestStore) CreateInvoice(ctx context.Context, invoice *model.RechargeInvoice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invoice.ID = int64(len(s.invoices) + 1)
+	copied := *invoice
+	s.invoices = append(s.invoices, &copied)
+	return nil
+}
+
+func (s *invoiceTestStore) ListInvoices(ctx context.Context, userID int, limit, offset int) ([]*model.RechargeInvoice, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []*model.RechargeInvoice
+	for _, invoice := range s.invoices {
+		if invoice.UserID == userID {
+			copied := *invoice
+			result = append(result, &copied)
+		}
+	}
+	return result, int64(len(result)), nil
+}
+
+func (s *invoiceTestStore) MarkPaid(ctx context.Context, sessionID, paymentIntentID, receiptURL string, verify func(invoice *model.RechargeInvoice) error) (*model.RechargeInvoice, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, invoice := range s.invoices {
+		if invoice.CheckoutSessionID != sessionID || invoice.Status != model.RechargeInvoicePending {
+			continue
+		}
+		updated := *invoice
+		if err := verify(&updated); err != nil {
+			updated.Status = model.RechargeInvoiceFailed
+			*invoice = updated
+			return &updated, false, nil
+		}
+		updated.Status = model.RechargeInvoicePaid
+		*invoice = updated
+		return &updated, true, nil
+	}
+	return nil, false, nil
+}
+
+// TestBillingInvoiceHandlerUsesAuthenticatedUser 经过真实的鉴权中间件，发票与入账额度都归属令牌中的用户
+func TestBillingInvoiceHandlerUsesAuthenticatedUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtCfg := &config.JWTConfig{Secret: "invoice-secret"}
+	utils.InitJWT(jwtCfg)
+
+	sessions := 0
+	stripe := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/checkout/sessions":
+			sessions++
+			id := fmt.Sprintf("cs_test_%d", sessions)
+			_ = json.NewEncoder(w).Encode(map[string]string{"id": id, "url": "https://checkout.stripe.test/" + id})
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{})
+		}
+	}))
+	t.Cleanup(stripe.Close)
+
+	packages, err := service.ParseQuotaPackages("starter:500:500", "usd")
+	require.NoError(t, err)
+	quotas := billing.NewQuotaManager()
+	checkout := service.NewCheckoutService(&invoiceTestStore{}, quotas, service.NewStripeClient("sk_test", "whsec_test", stripe.URL),
+		packages, service.CheckoutConfig{SuccessURL: "https://app.test/ok", CancelURL: "https://app.test/cancel"})
+	h := NewBillingInvoiceHandler(checkout)
+
+	r := gin.New()
+	h.RegisterRoutes(r.Group("/api/v1", middleware.AuthMiddleware([]byte(jwtCfg.Secret))))
+	h.RegisterWebhook(r)
+
+	// call 以 userID 的访问令牌发起请求，返回响应中的 data
+	call := func(userID int, method, path string, body interface{}) json.RawMessage {
+		token, err := utils.GenerateAccessToken(userID, "user"+strconv.Itoa(userID), 1, 1)
+		require.NoError(t, err)
+		payload, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			Data json.RawMessage `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data
+	}
+
+	var invoice model.RechargeInvoice
+	require.NoError(t, json.Unmarshal(call(42, http.MethodPost, "/api/v1/billing/invoices", gin.H{"package_id": "starter"}), &invoice))
+	assert.Equal(t, 42, invoice.UserID)
+
+	var listed struct {
+		Total int64 `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(call(42, http.MethodGet, "/api/v1/billing/invoices", nil), &listed))
+	assert.EqualValues(t, 1, listed.Total)
+	require.NoError(t, json.Unmarshal(call(7, http.MethodGet, "/api/v1/billing/invoices", nil), &listed))
+	assert.EqualValues(t, 0, listed.Total, "other users do not see the invoice")
+
+	// 支付完成后额度记入下单用户，而不是用户 0
+	object, _ := json.Marshal(map[string]interface{}{
+		"id":             invoice.CheckoutSessionID,
+		"payment_status": "paid",
+		"amount_total":   500,
+		"currency":       "usd",
+	})
+	event, _ := json.Marshal(map[string]interface{}{
+		"id":   "evt_1",
+		"type": "checkout.session.completed",
+		"data": map[string]json.RawMessage{"object": object},
+	})
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/stripe", bytes.NewReader(event))
+	req.Header.Set("Stripe-Signature", "t="+ts+",v1="+service.StripeSignature("whsec_test", ts, event))
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	assert.Equal(t, float64(500), quotas.GetQuota("42"))
+	assert.Equal(t, float64(0), quotas.GetQuota("0"))
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// BillingPriceGroupHandler 管理员维护价格组：与用户分组同名的价格组按倍率计费
+type BillingPriceGroupHandler struct {
+	pricing *billing.PricingManager
+}
+
+// NewBillingPriceGroupHandler 创建价格组 Handler
+func NewBillingPriceGroupHandler(pricing *billing.PricingManager) *BillingPriceGroupHandler {
+	return &BillingPriceGroupHandler{pricing: pricing}
+}
+
+// PriceGroupRequest 创建或更新价格组的请求，models 为空表示适用于所有模型
+type PriceGroupRequest struct {
+	GroupID    string   `json:"group_id"`
+	GroupName  string   `json:"group_name"`
+	Models     []string `json:"models"`
+	Multiplier float64  `json:"multiplier" binding:"required"`
+}
+
+// ListPriceGroups 查询全部价格组，按 ID 排序
+// GET /api/v1/billing/price-groups
+func (h *BillingPriceGroupHandler) ListPriceGroups(c *gin.Context) {
+	groups := make([]*billing.PriceGroup, 0)
+	for _, g := range h.pricing.GetAllPriceGroups() {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].GroupID < groups[j].GroupID })
+	utils.Success(c, groups, "")
+}
+
+// CreatePriceGroup 创建价格组，group_id 应与用户分组同名
+// POST /api/v1/billing/price-groups
+func (h *BillingPriceGroupHandler) CreatePriceGroup(c *gin.Context) {
+	var req PriceGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	req.GroupID = strings.TrimSpace(req.GroupID)
+	if req.GroupID == "" || req.GroupID == billing.DefaultUserGroup {
+		utils.BadRequest(c, "group_id is required and must not be \""+billing.DefaultUserGroup+"\"")
+		return
+	}
+
+	if err := h.pricing.CreatePriceGroup(req.GroupID, req.GroupName, req.Models, req.Multiplier); err != nil {
+		respondPriceGroupError(c, err)
+		return
+	}
+	group, _ := h.pricing.GetPriceGroup(req.GroupID)
+	utils.Success(c, group, "价格组已创建")
+}
+
+// UpdatePriceGroup 更新价格组的名称、模型列表与倍率
+// PUT /api/v1/billing/price-groups/:id
+func (h *BillingPriceGroupHandler) UpdatePriceGroup(c *gin.Context) {
+	var req PriceGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	groupID := c.Param("id")
+	if err := h.pricing.UpdatePriceGroup(groupID, req.GroupName, req.Models, req.Multiplier); err != nil {
+		respondPriceGroupError(c, err)
+		return
+	}
+	group, _ := h.pricing.GetPriceGroup(groupID)
+	utils.Success(c, group, "价格组已更新")
+}
+
+// DeletePriceGroup 删除价格组，该分组的用户之后按基础价格计费
+// DELETE /api/v1/billing/price-groups/:id
+func (h *BillingPriceGroupHandler) DeletePriceGroup(c *gin.Context) {
+	if err := h.pricing.DeletePriceGroup(c.Param("id")); err != nil {
+		respondPriceGroupError(c, err)
+		return
+	}
+	utils.Success(c, nil, "价格组已删除")
+}
+
+// respondPriceGroupError 将价格组操作的错误转换为响应
+func respondPriceGroupError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, billing.ErrPriceGroupNotFound):
+		utils.NotFound(c, "价格组不存在")
+	case errors.Is(err, billing.ErrInvalidMultiplier):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, billing.ErrPriceGroupExists):
+		utils.RespondError(c, utils.WrapError(utils.CodeConflict, err))
+	default:
+		utils.InternalError(c, err.Error())
+	}
+}
+
+// RegisterRoutes 注册路由（需挂载在管理员鉴权的路由组下）
+func (h *BillingPriceGroupHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/billing/price-groups", h.ListPriceGroups)
+	r.POST("/billing/price-groups", h.CreatePriceGroup)
+	r.PUT("/billing/price-groups/:id", h.UpdatePriceGroup)
+	r.DELETE("/billing/price-groups/:id", h.DeletePriceGroup)
+}
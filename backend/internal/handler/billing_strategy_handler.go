@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// BillingStrategyHandler 管理员维护限时定价策略并预览任意时刻适用的价格
+type BillingStrategyHandler struct {
+	pricing *billing.PricingManager
+}
+
+// NewBillingStrategyHandler 创建定价策略 Handler
+func NewBillingStrategyHandler(pricing *billing.PricingManager) *BillingStrategyHandler {
+	return &BillingStrategyHandler{pricing: pricing}
+}
+
+// StrategyPriceRequest 策略中单个模型的价格（美元/1K tokens）
+type StrategyPriceRequest struct {
+	InputPrice  float64 `json:"input_price"`
+	OutputPrice float64 `json:"output_price"`
+}
+
+// CreateStrategyRequest 创建限时定价策略，effective_to 为空表示长期有效
+type CreateStrategyRequest struct {
+	StrategyID    string                          `json:"strategy_id" binding:"required"`
+	StrategyName  string                          `json:"strategy_name"`
+	Models        []string                        `json:"models" binding:"required,min=1"`
+	EffectiveFrom time.Time                       `json:"effective_from" binding:"required"`
+	EffectiveTo   *time.Time                      `json:"effective_to"`
+	Prices        map[string]StrategyPriceRequest `json:"prices"`
+}
+
+// StrategyResponse 定价策略
+type StrategyResponse struct {
+	StrategyID    string                          `json:"strategy_id"`
+	StrategyName  string                          `json:"strategy_name"`
+	Models        []string                        `json:"models"`
+	EffectiveFrom time.Time                       `json:"effective_from"`
+	EffectiveTo   *time.Time                      `json:"effective_to,omitempty"`
+	Prices        map[string]StrategyPriceRequest `json:"prices"`
+	Active        bool                            `json:"active"`
+}
+
+// ListStrategies 查询全部定价策略，按开始时间排序
+// GET /api/v1/billing/pricing/strategies
+func (h *BillingStrategyHandler) ListStrategies(c *gin.Context) {
+	active := h.pricing.GetActiveStrategy()
+	strategies := h.pricing.GetAllStrategies()
+	result := make([]*StrategyResponse, 0, len(strategies))
+	for _, s := range strategies {
+		result = append(result, strategyResponse(s, s == active))
+	}
+	utils.Success(c, result, "")
+}
+
+// CreateStrategy 创建限时定价策略，有效期与相同模型的其他策略重叠时返回 409
+// POST /api/v1/billing/pricing/strategies
+func (h *BillingStrategyHandler) CreateStrategy(c *gin.Context) {
+	var req CreateStrategyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	models := make(map[string]bool, len(req.Models))
+	for _, m := range req.Models {
+		models[m] = true
+	}
+	for m, price := range req.Prices {
+		if !models[m] {
+			utils.BadRequest(c, "price for model "+m+" is not in models")
+			return
+		}
+		if price.InputPrice < 0 || price.OutputPrice < 0 {
+			utils.BadRequest(c, "prices must not be negative")
+			return
+		}
+	}
+
+	err := h.pricing.CreateScheduledStrategy(req.StrategyID, req.StrategyName, req.Models, req.EffectiveFrom, req.EffectiveTo)
+	if err != nil {
+		respondStrategyError(c, err)
+		return
+	}
+	for m, price := range req.Prices {
+		if err := h.pricing.SetStrategyPrice(req.StrategyID, m, price.InputPrice, price.OutputPrice, billing.PricingByToken); err != nil {
+			respondStrategyError(c, err)
+			return
+		}
+	}
+
+	// 新策略可能立即生效
+	h.pricing.RefreshActiveStrategy()
+
+	strategy, err := h.pricing.GetPricingStrategy(req.StrategyID)
+	if err != nil {
+		respondStrategyError(c, err)
+		return
+	}
+	utils.Success(c, strategyResponse(strategy, strategy == h.pricing.GetActiveStrategy()), "定价策略已创建")
+}
+
+// PreviewPrices 预览某一时刻适用的价格，at 为 RFC3339 时间，默认当前时间
+// GET /api/v1/billing/pricing/preview?at=2026-11-11T00:00:00Z
+func (h *BillingStrategyHandler) PreviewPrices(c *gin.Context) {
+	at := time.Now()
+	if raw := c.Query("at"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			utils.BadRequest(c, "at must be an RFC3339 timestamp")
+			return
+		}
+		at = parsed
+	}
+	utils.Success(c, h.pricing.PreviewPrices(at), "")
+}
+
+// respondStrategyError 将定价策略操作的错误转换为响应
+func respondStrategyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, billing.ErrStrategyExists), errors.Is(err, billing.ErrStrategyOverlap):
+		utils.RespondError(c, utils.WrapError(utils.CodeConflict, err))
+	case errors.Is(err, billing.ErrStrategyWindow):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, billing.ErrStrategyNotFound):
+		utils.NotFound(c, "定价策略不存在")
+	default:
+		utils.InternalError(c, err.Error())
+	}
+}
+
+func strategyResponse(s *billing.PricingStrategy, active bool) *StrategyResponse {
+	resp := &StrategyResponse{
+		StrategyID:   s.StrategyID,
+		StrategyName: s.StrategyName,
+		Models:       s.ApplicableModels,
+		Prices:       make(map[string]StrategyPriceRequest),
+		Active:       active,
+	}
+	resp.EffectiveFrom, resp.EffectiveTo = s.Window()
+	for m, price := range s.Prices() {
+		resp.Prices[m] = StrategyPriceRequest{InputPrice: price.InputPrice, OutputPrice: price.OutputPrice}
+	}
+	return resp
+}
+
+// RegisterRoutes 注册路由（需挂载在管理员鉴权的路由组下）
+func (h *BillingStrategyHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/billing/pricing/strategies", h.ListStrategies)
+	r.POST("/billing/pricing/strategies", h.CreateStrategy)
+	r.GET("/billing/pricing/preview", h.PreviewPrices)
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+// usageAdminRole 可查询任意用户用量的最小角色
+const usageAdminRole = 100
+
+// BillingUsageHandler 按模型、天或渠道汇总的消费报表
+type BillingUsageHandler struct {
+	reports *service.UsageReportService
+}
+
+// NewBillingUsageHandler 创建用量报表 Handler
+func NewBillingUsageHandler(reports *service.UsageReportService) *BillingUsageHandler {
+	return &BillingUsageHandler{reports: reports}
+}
+
+// GetUsage 查询当前用户（管理员可指定 user_id）的消费汇总，format=csv 时下载全部分组
+// GET /api/v1/billing/usage?group_by=model|day|channel&start=&end=&page=1&page_size=20&format=csv
+// start、end 为 RFC3339 时间或 YYYY-MM-DD 日期，范围为 [start, end)，默认最近 30 天
+func (h *BillingUsageHandler) GetUsage(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+	if raw := c.Query("user_id"); raw != "" {
+		target, err := strconv.Atoi(raw)
+		if err != nil || target <= 0 {
+			utils.BadRequest(c, "invalid user_id")
+			return
+		}
+		if target != userID && c.GetInt(middleware.RoleKey) < usageAdminRole {
+			utils.Forbidden(c)
+			return
+		}
+		userID = target
+	}
+
+	start, err := parseUsageTime(c.Query("start"))
+	if err != nil {
+		utils.BadRequest(c, "invalid start: "+err.Error())
+		return
+	}
+	end, err := parseUsageTime(c.Query("end"))
+	if err != nil {
+		utils.BadRequest(c, "invalid end: "+err.Error())
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	query := service.UsageQuery{
+		UserID:   userID,
+		GroupBy:  c.Query("group_by"),
+		Start:    start,
+		End:      end,
+		Page:     page,
+		PageSize: pageSize,
+	}
+
+	if c.Query("format") == "csv" {
+		h.writeCSV(c, query)
+		return
+	}
+
+	report, err := h.reports.Report(c.Request.Context(), query)
+	if err != nil {
+		respondUsageError(c, err)
+		return
+	}
+	utils.Success(c, report, "")
+}
+
+// writeCSV 以附件形式流式输出报表；表头写出后出错只能记录日志并中断
+func (h *BillingUsageHandler) writeCSV(c *gin.Context, query service.UsageQuery) {
+	if query.GroupBy == "" {
+		query.GroupBy = repository.UsageGroupByModel
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="usage_%d_%s.csv"`, query.UserID, query.GroupBy))
+
+	if err := h.reports.WriteCSV(c.Request.Context(), c.Writer, query); err != nil {
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			respondUsageError(c, err)
+			return
+		}
+		logger.Error("failed to write usage csv",
+			zap.Int("user_id", query.UserID),
+			zap.Error(err))
+		c.Abort()
+	}
+}
+
+// RegisterRoutes 注册需要登录的路由
+func (h *BillingUsageHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/billing/usage", h.GetUsage)
+}
+
+func respondUsageError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrInvalidUsageQuery) {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	utils.InternalError(c, err.Error())
+}
+
+// parseUsageTime 解析 RFC3339 时间或 YYYY-MM-DD 日期（UTC），空串返回零值
+func parseUsageTime(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, errors.New("expected RFC3339 or YYYY-MM-DD")
+	}
+	return t, nil
+}
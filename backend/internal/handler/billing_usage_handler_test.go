@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// usageByUser 每个用户返回一个以用户 ID 区分的模型分组
+type usageByUser struct{}
+
+func (usageByUser) AggregateUsage(ctx context.Context, filter *repository.UsageFilter) ([]*repository.UsageAggregate, int64, *repository.UsageAggregate, error) {
+	row := &repository.UsageAggregate{Key: "gpt-4", Requests: int64(filter.UserID), Cost: 10}
+	if filter.Offset > 0 {
+		return nil, 1, row, nil
+	}
+	return []*repository.UsageAggregate{row}, 1, row, nil
+}
+
+func usageRouter(userID, role int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		// 与 JWT 声明一致以字符串写入，处理器需经 UserIDFromContext 解析
+		c.Set(middleware.UserIDKey, strconv.Itoa(userID))
+		c.Set(middleware.RoleKey, role)
+	})
+	NewBillingUsageHandler(service.NewUsageReportService(usageByUser{})).RegisterRoutes(&r.RouterGroup)
+	return r
+}
+
+func getUsage(r *gin.Engine, query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/billing/usage"+query, nil))
+	return w
+}
+
+func TestBillingUsageHandlerUserScope(t *testing.T) {
+	var resp struct {
+		Data service.UsageReport `json:"data"`
+	}
+
+	w := getUsage(usageRouter(7, 1), "?group_by=model&start=2026-01-01&end=2026-02-01")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data.Items, 1)
+	assert.Equal(t, int64(7), resp.Data.Items[0].Requests)
+
+	// 普通用户不能查看他人
+	w = getUsage(usageRouter(7, 1), "?user_id=8")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// 管理员可以
+	w = getUsage(usageRouter(7, 100), "?user_id=8")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, int64(8), resp.Data.Items[0].Requests)
+}
+
+func TestBillingUsageHandlerRequiresUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewBillingUsageHandler(service.NewUsageReportService(usageByUser{})).RegisterRoutes(&r.RouterGroup)
+	assert.Equal(t, http.StatusUnauthorized, getUsage(r, "").Code)
+}
+
+func TestBillingUsageHandlerInvalidQuery(t *testing.T) {
+	r := usageRouter(7, 1)
+	assert.Equal(t, http.StatusBadRequest, getUsage(r, "?group_by=token").Code)
+	assert.Equal(t, http.StatusBadRequest, getUsage(r, "?start=yesterday").Code)
+
+	w := getUsage(r, "?format=csv&start=2026-02-01&end=2026-01-01")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+}
+
+func TestBillingUsageHandlerCSV(t *testing.T) {
+	w := getUsage(usageRouter(7, 1), "?format=csv&group_by=model")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "usage_7_model.csv")
+	assert.Equal(t, "model,name,requests,cost,input_tokens,output_tokens\ngpt-4,,7,10,0,0\n", w.Body.String())
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/capprobe"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// CapabilityProbeHandler 渠道能力探测Handler
+type CapabilityProbeHandler struct {
+	probeService *service.CapabilityProbeService
+}
+
+// NewCapabilityProbeHandler 创建能力探测Handler
+func NewCapabilityProbeHandler(probeService *service.CapabilityProbeService) *CapabilityProbeHandler {
+	return &CapabilityProbeHandler{probeService: probeService}
+}
+
+// ProbeCapabilitiesRequest 探测请求
+type ProbeCapabilitiesRequest struct {
+	Model string `json:"model"`
+}
+
+// ProbeCapabilities 对渠道的指定模型探测 JSON 模式、工具调用、图片输入与流式输出
+// POST /api/v1/channels/:id/probe-capabilities
+//
+// 只保存结果，不修改渠道能力；确认后通过 apply 接口写入特性标记。
+func (h *CapabilityProbeHandler) ProbeCapabilities(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "invalid id")
+		return
+	}
+
+	var req ProbeCapabilitiesRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+	report, err := h.probeService.Probe(c.Request.Context(), id, req.Model, userID)
+	if err != nil {
+		var limited *capprobe.RateLimitedError
+		switch {
+		case errors.As(err, &limited):
+			c.Header("Retry-After", strconv.Itoa(int(limited.RetryAfter.Seconds())+1))
+			utils.Error(c, http.StatusTooManyRequests, utils.ErrRateLimitExceeded, err.Error(), nil)
+		case errors.Is(err, capprobe.ErrUnsupportedChannel), errors.Is(err, service.ErrProbeModelRequired):
+			utils.BadRequest(c, err.Error())
+		default:
+			utils.InternalError(c, err.Error())
+		}
+		return
+	}
+	if report == nil {
+		utils.NotFound(c, "channel not found")
+		return
+	}
+
+	utils.Success(c, report, "")
+}
+
+// ListCapabilityProbes 渠道的探测历史，用于发现上游变更后的能力回退
+// GET /api/v1/channels/:id/capability-probes?model=&limit=
+func (h *CapabilityProbeHandler) ListCapabilityProbes(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "invalid id")
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	probes, err := h.probeService.History(c.Request.Context(), id, c.Query("model"), limit)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+	utils.Success(c, probes, "")
+}
+
+// ApplyFeaturesRequest 应用特性标记请求
+type ApplyFeaturesRequest struct {
+	Confirm bool `json:"confirm"`
+}
+
+// ApplyFeatures 将某次探测的结果写入渠道能力的特性标记，需显式确认
+// POST /api/v1/channels/:id/capability-probes/:run_id/apply
+func (h *CapabilityProbeHandler) ApplyFeatures(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "invalid id")
+		return
+	}
+
+	var req ApplyFeaturesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if !req.Confirm {
+		utils.BadRequest(c, "confirm must be true to update ability features")
+		return
+	}
+
+	features, rows, err := h.probeService.ApplyFeatures(c.Request.Context(), id, c.Param("run_id"))
+	if err != nil {
+		if errors.Is(err, service.ErrProbeRunNotFound) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"features":          features,
+		"abilities_updated": rows,
+	}, "渠道能力特性已更新")
+}
+
+// RegisterRoutes 注册路由（需挂载在管理员鉴权的路由组下）
+func (h *CapabilityProbeHandler) RegisterRoutes(r *gin.RouterGroup) {
+	channels := r.Group("/channels")
+	{
+		channels.POST("/:id/probe-capabilities", h.ProbeCapabilities)
+		channels.GET("/:id/capability-probes", h.ListCapabilityProbes)
+		channels.POST("/:id/capability-probes/:run_id/apply", h.ApplyFeatures)
+	}
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// ChannelConcurrencySource 渠道并发快照的查询（由 RelayService 实现）
+type ChannelConcurrencySource interface {
+	ChannelConcurrency() []relay.ChannelConcurrency
+}
+
+// ChannelConcurrencyHandler 渠道并发统计接口
+type ChannelConcurrencyHandler struct {
+	source ChannelConcurrencySource
+}
+
+// NewChannelConcurrencyHandler 创建渠道并发统计Handler
+func NewChannelConcurrencyHandler(source ChannelConcurrencySource) *ChannelConcurrencyHandler {
+	return &ChannelConcurrencyHandler{source: source}
+}
+
+// ListChannelConcurrency 获取每个渠道的最大并发数、进行中与排队的请求数
+// GET /v1/admin/channels/concurrency
+func (h *ChannelConcurrencyHandler) ListChannelConcurrency(c *gin.Context) {
+	utils.Success(c, gin.H{
+		"channels": h.source.ChannelConcurrency(),
+	}, "")
+}
+
+// RegisterRoutes 注册路由（需挂载在管理员鉴权的路由组下）
+func (h *ChannelConcurrencyHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/channels/concurrency", h.ListChannelConcurrency)
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConcurrencySource []relay.ChannelConcurrency
+
+func (f fakeConcurrencySource) ChannelConcurrency() []relay.ChannelConcurrency { return f }
+
+func TestChannelConcurrencyHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	source := fakeConcurrencySource{{ChannelID: "1", MaxConcurrency: 5, Active: 5, QueueDepth: 3}}
+	NewChannelConcurrencyHandler(source).RegisterRoutes(r.Group("/v1/admin"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/admin/channels/concurrency", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data struct {
+			Channels []map[string]interface{} `json:"channels"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data.Channels, 1)
+	assert.Equal(t, float64(3), resp.Data.Channels[0]["queue_depth"])
+}
+
+func TestRelayErrorChannelBusy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	utils.RespondOpenAIError(c, RelayError(&relay.ChannelBusyError{ChannelID: "1", RetryAfter: 1500 * time.Millisecond}, nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "rate_limit_error")
+}
@@ -1,12 +1,14 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
 )
 
@@ -14,6 +16,7 @@ import (
 type ChannelHandler struct {
 	channelService        *service.ChannelService
 	channelAbilityService service.ChannelAbilityService
+	keyHealth             func(channelID int) []relay.KeyHealth
 }
 
 // NewChannelHandler 创建渠道Handler
@@ -27,6 +30,11 @@ func NewChannelHandler(
 	}
 }
 
+// SetKeyHealthSource 设置多密钥渠道实时密钥状态的来源（通常为 RelayService.ChannelKeyHealth）
+func (h *ChannelHandler) SetKeyHealthSource(source func(channelID int) []relay.KeyHealth) {
+	h.keyHealth = source
+}
+
 // ListChannelsRequest 查询请求
 type ListChannelsRequest struct {
 	Page     int    `form:"page" binding:"min=1"`
@@ -74,11 +82,17 @@ func (h *ChannelHandler) ListChannels(c *gin.Context) {
 		return
 	}
 
+	// 静态请求头中疑似密钥的值不在列表中明文返回
+	redacted := make([]*model.Channel, 0, len(channels))
+	for _, ch := range channels {
+		redacted = append(redacted, service.RedactChannel(ch))
+	}
+
 	c.JSON(http.StatusOK, ListChannelsResponse{
 		Total:    total,
 		Page:     req.Page,
 		PageSize: req.PageSize,
-		Data:     channels,
+		Data:     redacted,
 	})
 }
 
@@ -97,30 +111,31 @@ type CreateChannelRequest struct {
 	Timeout       int     `json:"timeout"`
 	ProxyURL      *string `json:"proxy_url"`
 	Enabled       bool    `json:"enabled"`
-}
 
-// CreateChannel 创建渠道
-// @Summary 创建渠道
-// @Tags channel
-// @Accept json
-// @Produce json
-// @Param channel body CreateChannelRequest true "渠道信息"
-// @Success 201 {object} model.Channel
-// @Router /api/admin/channels [post]
-func (h *ChannelHandler) CreateChannel(c *gin.Context) {
-	var req CreateChannelRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+	// 注入每个上游请求的静态请求头，值中可用 {api_key} 引用渠道密钥
+	HeaderOverride map[string]string `json:"header_override"`
+	// 追加到每个上游请求 URL 的静态查询参数
+	QueryParams map[string]string `json:"query_params"`
+	// Azure 渠道的模型到部署名映射与 api-version
+	Deployments map[string]string `json:"deployments"`
+	APIVersion  string            `json:"api_version"`
+	// 用户未设置时填充的请求参数，以及替换或限制用户值的强制参数
+	ParamDefaults  map[string]interface{}         `json:"param_defaults"`
+	ParamOverrides map[string]model.ParamOverride `json:"param_overrides"`
+	// 同时转发到该渠道的最大请求数，0 表示不限制
+	MaxConcurrency int `json:"max_concurrency"`
+	// 渠道额外支持的能力：images、audio
+	Capabilities []string `json:"capabilities"`
+}
 
+// toChannel 按创建请求构建渠道并填充默认值
+func (req *CreateChannelRequest) toChannel() (*model.Channel, error) {
 	// 构建Channel对象
 	channel := &model.Channel{
 		Name:          req.Name,
 		Type:          req.Type,
 		Group:         req.Group,
 		BaseURL:       req.BaseURL,
-		APIKey:        req.APIKeys,
 		SupportModels: req.SupportModels,
 		Priority:      int64(req.Priority),
 		Weight:        req.Weight,
@@ -131,6 +146,23 @@ func (h *ChannelHandler) CreateChannel(c *gin.Context) {
 		Enabled: req.Enabled,
 		Status:  1, // 1:启用
 	}
+	// 多个密钥（换行或逗号分隔）按轮询使用
+	channel.SetKeys(model.ParseKeys(req.APIKeys))
+
+	if err := channel.SetHeaderOverride(req.HeaderOverride); err != nil {
+		return nil, err
+	}
+	if err := channel.SetSettings(model.ChannelSettings{
+		QueryParams:    req.QueryParams,
+		Deployments:    req.Deployments,
+		APIVersion:     req.APIVersion,
+		ParamDefaults:  req.ParamDefaults,
+		ParamOverrides: req.ParamOverrides,
+		MaxConcurrency: req.MaxConcurrency,
+		Capabilities:   req.Capabilities,
+	}); err != nil {
+		return nil, err
+	}
 
 	// 设置默认值
 	if channel.Group == "" {
@@ -142,10 +174,33 @@ func (h *ChannelHandler) CreateChannel(c *gin.Context) {
 	if channel.Weight == 0 {
 		channel.Weight = 10
 	}
+	return channel, nil
+}
+
+// CreateChannel 创建渠道
+// @Summary 创建渠道
+// @Tags channel
+// @Accept json
+// @Produce json
+// @Param channel body CreateChannelRequest true "渠道信息"
+// @Success 201 {object} model.Channel
+// @Router /api/admin/channels [post]
+func (h *ChannelHandler) CreateChannel(c *gin.Context) {
+	var req CreateChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	channel, err := req.toChannel()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 创建渠道
 	if err := h.channelService.Create(c.Request.Context(), channel); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(channelErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -155,48 +210,29 @@ func (h *ChannelHandler) CreateChannel(c *gin.Context) {
 		// log.Printf("failed to sync abilities: %v", err)
 	}
 
-	c.JSON(http.StatusCreated, channel)
+	c.JSON(http.StatusCreated, service.RedactChannel(channel))
 }
 
-// UpdateChannelRequest 更新请求
-type UpdateChannelRequest struct {
-	Name          *string `json:"name"`
-	BaseURL       *string `json:"base_url"`
-	APIKeys       *string `json:"api_keys"`
-	SupportModels *string `json:"support_models"`
-	Priority      *int    `json:"priority"`
-	Weight        *int    `json:"weight"`
-	MaxRPM        *int    `json:"max_rpm"`
-	MaxRPD        *int    `json:"max_rpd"`
-	Timeout       *int    `json:"timeout"`
-	ProxyURL      *string `json:"proxy_url"`
-	Enabled       *bool   `json:"enabled"`
-	Status        *int    `json:"status"`
+// ChannelDetail 渠道详情，密钥只保留末 4 位
+type ChannelDetail struct {
+	*model.Channel
+	KeyHealth []relay.KeyHealth `json:"key_health"`
 }
 
-// UpdateChannel 更新渠道
-// @Summary 更新渠道
+// GetChannel 获取渠道详情
+// @Summary 获取渠道详情（含各密钥健康状态）
 // @Tags channel
-// @Accept json
 // @Produce json
 // @Param id path int true "渠道ID"
-// @Param channel body UpdateChannelRequest true "更新信息"
-// @Success 200 {object} model.Channel
-// @Router /api/admin/channels/{id} [put]
-func (h *ChannelHandler) UpdateChannel(c *gin.Context) {
+// @Success 200 {object} ChannelDetail
+// @Router /v1/admin/channels/{id} [get]
+func (h *ChannelHandler) GetChannel(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
 
-	var req UpdateChannelRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// 获取现有渠道
 	channel, err := h.channelService.GetByID(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -207,6 +243,44 @@ func (h *ChannelHandler) UpdateChannel(c *gin.Context) {
 		return
 	}
 
+	var live []relay.KeyHealth
+	if h.keyHealth != nil {
+		live = h.keyHealth(channel.ID)
+	}
+	c.JSON(http.StatusOK, ChannelDetail{
+		Channel:   service.RedactChannel(channel),
+		KeyHealth: service.ChannelKeyHealth(channel, live),
+	})
+}
+
+// UpdateChannelRequest 更新请求
+type UpdateChannelRequest struct {
+	Name          *string `json:"name"`
+	BaseURL       *string `json:"base_url"`
+	APIKeys       *string `json:"api_keys"`
+	SupportModels *string `json:"support_models"`
+	Priority      *int    `json:"priority"`
+	Weight        *int    `json:"weight"`
+	MaxRPM        *int    `json:"max_rpm"`
+	MaxRPD        *int    `json:"max_rpd"`
+	Timeout       *int    `json:"timeout"`
+	ProxyURL      *string `json:"proxy_url"`
+	Enabled       *bool   `json:"enabled"`
+	Status        *int    `json:"status"`
+
+	// 为 nil 时不修改，空对象表示清除；回传列表中的掩码值表示保留原值
+	HeaderOverride map[string]string              `json:"header_override"`
+	QueryParams    map[string]string              `json:"query_params"`
+	Deployments    map[string]string              `json:"deployments"`
+	APIVersion     *string                        `json:"api_version"`
+	ParamDefaults  map[string]interface{}         `json:"param_defaults"`
+	ParamOverrides map[string]model.ParamOverride `json:"param_overrides"`
+	MaxConcurrency *int                           `json:"max_concurrency"`
+	Capabilities   []string                       `json:"capabilities"`
+}
+
+// applyTo 将更新请求中非空的字段应用到渠道
+func (req *UpdateChannelRequest) applyTo(channel *model.Channel) error {
 	// 应用更新
 	if req.Name != nil {
 		channel.Name = *req.Name
@@ -215,7 +289,7 @@ func (h *ChannelHandler) UpdateChannel(c *gin.Context) {
 		channel.BaseURL = *req.BaseURL
 	}
 	if req.APIKeys != nil {
-		channel.APIKey = *req.APIKeys
+		channel.SetKeys(service.MergeMaskedKeys(model.ParseKeys(*req.APIKeys), channel.GetKeys()))
 	}
 	if req.SupportModels != nil {
 		channel.SupportModels = *req.SupportModels
@@ -240,10 +314,83 @@ func (h *ChannelHandler) UpdateChannel(c *gin.Context) {
 	if req.Status != nil {
 		channel.Status = *req.Status
 	}
+	if req.HeaderOverride != nil {
+		existing, _ := channel.GetHeaderOverride()
+		if err := channel.SetHeaderOverride(service.MergeMaskedHeaders(req.HeaderOverride, existing)); err != nil {
+			return err
+		}
+	}
+	if req.QueryParams != nil || req.Deployments != nil || req.APIVersion != nil || req.ParamDefaults != nil || req.ParamOverrides != nil || req.MaxConcurrency != nil || req.Capabilities != nil {
+		settings := channel.GetSettings()
+		if req.QueryParams != nil {
+			settings.QueryParams = req.QueryParams
+		}
+		if req.Deployments != nil {
+			settings.Deployments = req.Deployments
+		}
+		if req.APIVersion != nil {
+			settings.APIVersion = *req.APIVersion
+		}
+		if req.ParamDefaults != nil {
+			settings.ParamDefaults = req.ParamDefaults
+		}
+		if req.ParamOverrides != nil {
+			settings.ParamOverrides = req.ParamOverrides
+		}
+		if req.MaxConcurrency != nil {
+			settings.MaxConcurrency = *req.MaxConcurrency
+		}
+		if req.Capabilities != nil {
+			settings.Capabilities = req.Capabilities
+		}
+		if err := channel.SetSettings(settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateChannel 更新渠道
+// @Summary 更新渠道
+// @Tags channel
+// @Accept json
+// @Produce json
+// @Param id path int true "渠道ID"
+// @Param channel body UpdateChannelRequest true "更新信息"
+// @Success 200 {object} model.Channel
+// @Router /api/admin/channels/{id} [put]
+func (h *ChannelHandler) UpdateChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req UpdateChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 获取现有渠道
+	channel, err := h.channelService.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if channel == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "channel not found"})
+		return
+	}
+
+	if err := req.applyTo(channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 保存更新
 	if err := h.channelService.Update(c.Request.Context(), channel); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(channelErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -254,7 +401,7 @@ func (h *ChannelHandler) UpdateChannel(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, channel)
+	c.JSON(http.StatusOK, service.RedactChannel(channel))
 }
 
 // DeleteChannel 删除渠道
@@ -288,7 +435,8 @@ func (h *ChannelHandler) DeleteChannel(c *gin.Context) {
 // @Summary 测试渠道连接
 // @Tags channel
 // @Param id path int true "渠道ID"
-// @Success 200 {object} map[string]interface{}
+// @Param model query string false "测试使用的模型，默认为渠道的第一个模型"
+// @Success 200 {object} service.ChannelTestResult
 // @Router /api/admin/channels/{id}/test [post]
 func (h *ChannelHandler) TestChannel(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -297,19 +445,32 @@ func (h *ChannelHandler) TestChannel(c *gin.Context) {
 		return
 	}
 
-	// TODO: 实现健康检查
-	// result, err := h.healthCheckService.CheckChannel(c.Request.Context(), id)
-	// if err != nil {
-	// 	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-	// 	return
-	// }
+	channel, err := h.channelService.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if channel == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "channel not found"})
+		return
+	}
+
+	// 与中转请求走同一个适配器，请求头与查询参数的注入保持一致
+	result, err := h.channelService.TestChannel(c.Request.Context(), channel, c.Query("model"))
+	if err != nil {
+		c.JSON(channelErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"channel_id": id,
-		"status":     "healthy",
-		"latency_ms": 100,
-		"message":    "连接正常",
-	})
+// channelErrorStatus 配置错误返回 400，其余返回 500
+func channelErrorStatus(err error) int {
+	if errors.Is(err, service.ErrInvalidChannelConfig) || errors.Is(err, service.ErrChannelTestModelRequired) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
 }
 
 // BatchOperationRequest 批量操作请求
@@ -368,6 +529,7 @@ func (h *ChannelHandler) RegisterRoutes(r *gin.RouterGroup) {
 	{
 		channels.GET("", h.ListChannels)
 		channels.POST("", h.CreateChannel)
+		channels.GET("/:id", h.GetChannel)
 		channels.PUT("/:id", h.UpdateChannel)
 		channels.DELETE("/:id", h.DeleteChannel)
 		channels.POST("/:id/test", h.TestChannel)
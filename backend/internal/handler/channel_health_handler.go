@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// ChannelHealthSource 渠道健康检查的查询与手动触发（由 RelayService 实现）
+type ChannelHealthSource interface {
+	ChannelHealth(ctx context.Context, channelID int) (*relay.ChannelHealth, error)
+	CheckChannelHealth(ctx context.Context, channelID int) (*relay.HealthCheckResult, error)
+}
+
+// ChannelHealthHandler 渠道健康检查管理接口
+type ChannelHealthHandler struct {
+	source ChannelHealthSource
+}
+
+// NewChannelHealthHandler 创建渠道健康检查Handler
+func NewChannelHealthHandler(source ChannelHealthSource) *ChannelHealthHandler {
+	return &ChannelHealthHandler{source: source}
+}
+
+// GetChannelHealth 获取渠道当前的健康状态、连续失败次数、是否处于恢复模式以及最近的检查结果
+// GET /v1/admin/channels/:id/health
+func (h *ChannelHealthHandler) GetChannelHealth(c *gin.Context) {
+	channelID, ok := channelHealthID(c)
+	if !ok {
+		return
+	}
+
+	health, err := h.source.ChannelHealth(c.Request.Context(), channelID)
+	if err != nil {
+		channelHealthError(c, err)
+		return
+	}
+	utils.Success(c, health, "")
+}
+
+// CheckChannelHealth 立即探测渠道并同步返回结果
+// POST /v1/admin/channels/:id/health/check
+func (h *ChannelHealthHandler) CheckChannelHealth(c *gin.Context) {
+	channelID, ok := channelHealthID(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.source.CheckChannelHealth(c.Request.Context(), channelID)
+	if err != nil {
+		channelHealthError(c, err)
+		return
+	}
+	utils.Success(c, result, "")
+}
+
+// channelHealthID 解析路径中的渠道 ID，无效时返回 400
+func channelHealthID(c *gin.Context) (int, bool) {
+	channelID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || channelID <= 0 {
+		utils.BadRequest(c, "无效的渠道 ID")
+		return 0, false
+	}
+	return channelID, true
+}
+
+// channelHealthError 渠道不存在返回 404，其余返回 500
+func channelHealthError(c *gin.Context, err error) {
+	if errors.Is(err, relay.ErrChannelNotFound) {
+		utils.NotFound(c, err.Error())
+		return
+	}
+	utils.InternalError(c, err.Error())
+}
+
+// RegisterRoutes 注册路由（需挂载在管理员鉴权的路由组下）
+func (h *ChannelHealthHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/channels/:id/health", h.GetChannelHealth)
+	r.POST("/channels/:id/health/check", h.CheckChannelHealth)
+}
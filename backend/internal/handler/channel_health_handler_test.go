@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChannelHealthSource 渠道 1 存在，手动检查追加到历史
+type fakeChannelHealthSource struct {
+	history []relay.HealthCheckResult
+}
+
+func (f *fakeChannelHealthSource) ChannelHealth(ctx context.Context, channelID int) (*relay.ChannelHealth, error) {
+	if channelID != 1 {
+		return nil, fmt.Errorf("%w: %d", relay.ErrChannelNotFound, channelID)
+	}
+	return &relay.ChannelHealth{ChannelID: "1", Status: relay.ChannelStatusUnavailable, ConsecutiveFailures: 1,
+		RecoveryMode: true, History: f.history}, nil
+}
+
+func (f *fakeChannelHealthSource) CheckChannelHealth(ctx context.Context, channelID int) (*relay.HealthCheckResult, error) {
+	if channelID != 1 {
+		return nil, fmt.Errorf("%w: %d", relay.ErrChannelNotFound, channelID)
+	}
+	result := relay.HealthCheckResult{ChannelID: "1", CheckTime: time.Now(), Healthy: false, Latency: 12,
+		Reason: relay.HealthReasonInvalidCredentials, Status: relay.ChannelStatusUnavailable}
+	f.history = append(f.history, result)
+	return &result, nil
+}
+
+func TestChannelHealthHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	source := &fakeChannelHealthSource{}
+	r := gin.New()
+	NewChannelHealthHandler(source).RegisterRoutes(r.Group("/v1/admin"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/admin/channels/1/health/check", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var checked struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &checked))
+	assert.Equal(t, "invalid_credentials", checked.Data["reason"])
+	assert.Equal(t, float64(12), checked.Data["latency_ms"])
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/admin/channels/1/health", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var health struct {
+		Data struct {
+			Status       string                   `json:"status"`
+			RecoveryMode bool                     `json:"recovery_mode"`
+			History      []map[string]interface{} `json:"history"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+	assert.Equal(t, "unavailable", health.Data.Status)
+	assert.True(t, health.Data.RecoveryMode)
+	assert.Len(t, health.Data.History, 1)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/admin/channels/7/health", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/admin/channels/x/health/check", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gorilla/websocket"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/server"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = wsPongWait * 9 / 10
+	wsAuthWait       = 10 * time.Second // 未在查询参数中携带令牌时等待 auth 帧的时间
+	wsMaxMessageSize = 64 << 10         // 客户端单帧的最大字节数
+)
+
+// ChatStreamer 流式发送消息（由 ChatService 实现）
+type ChatStreamer interface {
+	SendMessageStream(ctx context.Context, userID int, req *service.SendMessageRequest, writer io.Writer) error
+}
+
+// ChatWSHandler 对话的 WebSocket 接口，供无法保持 SSE 连接的客户端使用
+//
+// 客户端帧：{"type":"auth","token":...} 鉴权（也可用查询参数 token）；type 为空或 message 的帧
+// 按 SendMessageRequest 解析并发送消息；{"type":"cancel"} 取消进行中的回复。
+// 服务端帧：{"type":"delta","content":...}、{"type":"done","message_id":...,"usage":{...}}、
+// {"type":"citations",...}、{"type":"cancelled"} 与 {"type":"error","code":...,"message":...}。
+// 同一连接的消息按顺序回复，回复进行中时最多再排队一条。
+type ChatWSHandler struct {
+	chat       ChatStreamer
+	signingKey []byte
+	upgrader   websocket.Upgrader
+}
+
+// NewChatWSHandler 创建对话 WebSocket Handler
+func NewChatWSHandler(chat ChatStreamer, signingKey []byte) *ChatWSHandler {
+	return &ChatWSHandler{
+		chat:       chat,
+		signingKey: signingKey,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			// 鉴权使用令牌而非 Cookie，允许跨域连接
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// wsClientFrame 客户端帧的类型与鉴权令牌
+type wsClientFrame struct {
+	Type  string `json:"type"`
+	Token string `json:"token"`
+}
+
+// Connect 升级为 WebSocket 连接并处理消息
+// GET /api/v1/chat/ws?token=...
+func (h *ChatWSHandler) Connect(c *gin.Context) {
+	// 查询参数中的令牌在升级前校验，失败时直接返回 401
+	userID := 0
+	if token := c.Query("token"); token != "" {
+		var err error
+		if userID, err = h.parseUserID(token); err != nil {
+			utils.Unauthorized(c, "invalid token")
+			return
+		}
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade 已返回错误响应
+		return
+	}
+	session := &wsSession{conn: conn, log: logger.Ctx(c.Request.Context())}
+	defer conn.Close()
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	if userID == 0 {
+		if userID, err = h.authenticate(conn); err != nil {
+			session.writeError(utils.WrapError(utils.CodeUnauthorized, err))
+			session.close(websocket.ClosePolicyViolation, "unauthorized")
+			return
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	go session.keepalive(done, server.ShutdownNotify(c.Request.Context()))
+
+	h.readLoop(c.Request.Context(), session, userID)
+}
+
+// authenticate 等待第一帧的 auth 帧并校验令牌
+func (h *ChatWSHandler) authenticate(conn *websocket.Conn) (int, error) {
+	conn.SetReadDeadline(time.Now().Add(wsAuthWait))
+	var frame wsClientFrame
+	if err := conn.ReadJSON(&frame); err != nil {
+		return 0, errors.New("auth frame required")
+	}
+	if frame.Type != "auth" || frame.Token == "" {
+		return 0, errors.New("first frame must be an auth frame")
+	}
+	return h.parseUserID(frame.Token)
+}
+
+// parseUserID 校验 JWT 并返回用户 ID
+func (h *ChatWSHandler) parseUserID(token string) (int, error) {
+	claims, err := middleware.ParseToken(token, h.signingKey)
+	if err != nil {
+		return 0, err
+	}
+	userID, ok := middleware.ClaimsUserID(claims)
+	if !ok {
+		return 0, errors.New("invalid token")
+	}
+	return userID, nil
+}
+
+// readLoop 读取客户端帧直到连接关闭；消息交给后台按顺序回复，连接关闭时取消进行中的回复
+func (h *ChatWSHandler) readLoop(ctx context.Context, session *wsSession, userID int) {
+	ctx, cancelAll := context.WithCancel(ctx)
+	queue := make(chan *service.SendMessageRequest, 1)
+	var (
+		wg            sync.WaitGroup
+		mu            sync.Mutex
+		cancelCurrent context.CancelFunc
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for req := range queue {
+			if ctx.Err() != nil {
+				continue
+			}
+			streamCtx, cancel := context.WithCancel(ctx)
+			mu.Lock()
+			cancelCurrent = cancel
+			mu.Unlock()
+
+			h.stream(streamCtx, session, userID, req)
+
+			mu.Lock()
+			cancelCurrent = nil
+			mu.Unlock()
+			cancel()
+		}
+	}()
+	defer func() {
+		cancelAll()
+		close(queue)
+		wg.Wait()
+	}()
+
+	for {
+		_, data, err := session.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				session.log.Debug("chat websocket closed", zap.Error(err))
+			}
+			return
+		}
+
+		var frame wsClientFrame
+		if err := json.Unmarshal(data, &frame); err != nil {
+			session.writeError(utils.NewAppError(utils.CodeInvalidRequest, "invalid frame: "+err.Error()))
+			continue
+		}
+
+		switch frame.Type {
+		case "cancel":
+			mu.Lock()
+			if cancelCurrent != nil {
+				cancelCurrent()
+			}
+			mu.Unlock()
+
+		case "", "message":
+			var req service.SendMessageRequest
+			if err := json.Unmarshal(data, &req); err != nil {
+				session.writeError(utils.NewAppError(utils.CodeInvalidRequest, "invalid message: "+err.Error()))
+				continue
+			}
+			if err := binding.Validator.ValidateStruct(&req); err != nil {
+				session.writeError(utils.WrapError(utils.CodeInvalidRequest, err))
+				continue
+			}
+			select {
+			case queue <- &req:
+			default:
+				session.writeError(utils.NewAppError(utils.CodeConflict, "a reply is already in progress"))
+			}
+
+		default:
+			session.writeError(utils.NewAppError(utils.CodeInvalidRequest, "unknown frame type: "+frame.Type))
+		}
+	}
+}
+
+// stream 流式回复一条消息，取消时上游请求随上下文中断
+func (h *ChatWSHandler) stream(ctx context.Context, session *wsSession, userID int, req *service.SendMessageRequest) {
+	err := h.chat.SendMessageStream(ctx, userID, req, &wsEventWriter{session: session})
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		session.writeJSON(gin.H{"type": "cancelled"})
+	default:
+		session.log.Error("chat websocket stream error", zap.Error(err))
+		session.writeError(err)
+	}
+}
+
+// wsSession 一个 WebSocket 连接，数据帧的写入需要串行
+type wsSession struct {
+	conn *websocket.Conn
+	log  *zap.Logger
+	mu   sync.Mutex
+}
+
+// writeJSON 写入一个 JSON 帧，连接已关闭时忽略错误
+func (s *wsSession) writeJSON(v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return s.conn.WriteJSON(v)
+}
+
+// writeError 写入带稳定错误码的错误帧
+func (s *wsSession) writeError(err error) {
+	appErr := utils.AsAppError(err)
+	s.writeJSON(gin.H{"type": "error", "code": appErr.Code, "message": err.Error()})
+}
+
+// close 发送关闭帧
+func (s *wsSession) close(code int, reason string) {
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+}
+
+// keepalive 定期发送 ping，服务关闭时以 server_shutdown 关闭连接，客户端据此重新连接
+func (s *wsSession) keepalive(done <-chan struct{}, shutdown <-chan struct{}) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case <-shutdown:
+			s.close(websocket.CloseGoingAway, "server_shutdown")
+			s.conn.Close()
+			return
+		case <-done:
+			return
+		}
+	}
+}
+
+// wsEventWriter 将对话服务的流式事件转换为 WebSocket 帧
+type wsEventWriter struct {
+	session *wsSession
+}
+
+// Write 对话服务按事件输出，不会调用 Write
+func (w *wsEventWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("chat websocket only accepts events")
+}
+
+// WriteEvent chunk 转换为 delta 帧，complete 转换为附带用量的 done 帧，其它事件原样发送
+func (w *wsEventWriter) WriteEvent(event map[string]interface{}) error {
+	switch event["type"] {
+	case "chunk":
+		return w.session.writeJSON(gin.H{"type": "delta", "content": event["content"]})
+	case "complete":
+		frame := gin.H{
+			"type":       "done",
+			"message_id": event["message_id"],
+			"usage": gin.H{
+				"input_tokens":     event["input_tokens"],
+				"output_tokens":    event["output_tokens"],
+				"reasoning_tokens": event["reasoning_tokens"],
+				"total_tokens":     event["total_tokens"],
+			},
+		}
+		for _, key := range []string{"partial", "error_class", "trimmed_messages"} {
+			if value, ok := event[key]; ok {
+				frame[key] = value
+			}
+		}
+		return w.session.writeJSON(frame)
+	}
+	return w.session.writeJSON(event)
+}
+
+// RegisterRoutes 注册路由（不经过 JWT 中间件，鉴权在连接内完成）
+func (h *ChatWSHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/chat/ws", h.Connect)
+}
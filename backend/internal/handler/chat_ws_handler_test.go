@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wsTestSecret 测试用 JWT 密钥
+var wsTestSecret = []byte("ws-test-secret")
+
+// fakeChatStreamer 按内容分段输出回复，内容为 wait 时阻塞到取消
+type fakeChatStreamer struct {
+	mu       sync.Mutex
+	userID   int
+	canceled bool
+}
+
+func (f *fakeChatStreamer) SendMessageStream(ctx context.Context, userID int, req *service.SendMessageRequest, writer io.Writer) error {
+	f.mu.Lock()
+	f.userID = userID
+	f.mu.Unlock()
+
+	events := writer.(service.ChatEventWriter)
+	if req.Content == "wait" {
+		events.WriteEvent(map[string]interface{}{"type": "chunk", "content": "thinking"})
+		<-ctx.Done()
+		f.mu.Lock()
+		f.canceled = true
+		f.mu.Unlock()
+		return ctx.Err()
+	}
+	for _, part := range strings.Fields(req.Content) {
+		events.WriteEvent(map[string]interface{}{"type": "chunk", "content": part, "model": "gpt-4o"})
+	}
+	return events.WriteEvent(map[string]interface{}{
+		"type":             "complete",
+		"message_id":       "msg-1",
+		"content":          req.Content,
+		"input_tokens":     3,
+		"output_tokens":    2,
+		"reasoning_tokens": 0,
+		"total_tokens":     5,
+	})
+}
+
+func wsTestToken(t *testing.T, userID int) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	s, err := token.SignedString(wsTestSecret)
+	require.NoError(t, err)
+	return s
+}
+
+func TestChatWSHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	chat := &fakeChatStreamer{}
+	r := gin.New()
+	NewChatWSHandler(chat, wsTestSecret).RegisterRoutes(r.Group("/api/v1"))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/chat/ws"
+
+	dial := func(t *testing.T, query string) *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL+query, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return conn
+	}
+	read := func(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+		t.Helper()
+		var frame map[string]interface{}
+		require.NoError(t, conn.ReadJSON(&frame))
+		return frame
+	}
+	message := map[string]interface{}{
+		"session_id": "6f1c1c52-8d4e-4d2a-9d55-1f0e5d1f0a01",
+		"content":    "hello world",
+	}
+
+	t.Run("query token streams deltas and done", func(t *testing.T) {
+		conn := dial(t, "?token="+wsTestToken(t, 7))
+		require.NoError(t, conn.WriteJSON(message))
+
+		assert.Equal(t, map[string]interface{}{"type": "delta", "content": "hello"}, read(t, conn))
+		assert.Equal(t, map[string]interface{}{"type": "delta", "content": "world"}, read(t, conn))
+		done := read(t, conn)
+		assert.Equal(t, "done", done["type"])
+		assert.Equal(t, "msg-1", done["message_id"])
+		assert.Equal(t, float64(5), done["usage"].(map[string]interface{})["total_tokens"])
+
+		chat.mu.Lock()
+		assert.Equal(t, 7, chat.userID)
+		chat.mu.Unlock()
+	})
+
+	t.Run("auth frame", func(t *testing.T) {
+		conn := dial(t, "")
+		require.NoError(t, conn.WriteJSON(map[string]string{"type": "auth", "token": wsTestToken(t, 8)}))
+		require.NoError(t, conn.WriteJSON(message))
+		assert.Equal(t, "delta", read(t, conn)["type"])
+	})
+
+	t.Run("invalid query token is rejected before upgrade", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL+"?token=bad", nil)
+		require.Error(t, err)
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("missing auth frame closes connection", func(t *testing.T) {
+		conn := dial(t, "")
+		require.NoError(t, conn.WriteJSON(message))
+		frame := read(t, conn)
+		assert.Equal(t, "error", frame["type"])
+		assert.Equal(t, "UNAUTHORIZED", frame["code"])
+		_, _, err := conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), err)
+	})
+
+	t.Run("invalid message", func(t *testing.T) {
+		conn := dial(t, "?token="+wsTestToken(t, 7))
+		require.NoError(t, conn.WriteJSON(map[string]string{"content": "no session"}))
+		frame := read(t, conn)
+		assert.Equal(t, "error", frame["type"])
+		assert.Equal(t, "INVALID_REQUEST", frame["code"])
+	})
+
+	t.Run("cancel aborts the in-flight reply", func(t *testing.T) {
+		conn := dial(t, "?token="+wsTestToken(t, 7))
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{"session_id": message["session_id"], "content": "wait"}))
+		assert.Equal(t, "delta", read(t, conn)["type"])
+
+		// 回复进行中最多排队一条消息
+		require.NoError(t, conn.WriteJSON(message))
+		require.NoError(t, conn.WriteJSON(message))
+		assert.Equal(t, "CONFLICT", read(t, conn)["code"])
+
+		require.NoError(t, conn.WriteJSON(map[string]string{"type": "cancel"}))
+		assert.Equal(t, map[string]interface{}{"type": "cancelled"}, read(t, conn))
+		chat.mu.Lock()
+		assert.True(t, chat.canceled)
+		chat.mu.Unlock()
+
+		// 取消后继续回复排队的消息
+		assert.Equal(t, map[string]interface{}{"type": "delta", "content": "hello"}, read(t, conn))
+	})
+
+	t.Run("oversized frame closes connection", func(t *testing.T) {
+		conn := dial(t, "?token="+wsTestToken(t, 7))
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("x", wsMaxMessageSize+1))))
+		_, _, err := conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig), err)
+	})
+
+	t.Run("answers ping with pong", func(t *testing.T) {
+		conn := dial(t, "?token="+wsTestToken(t, 7))
+		pong := make(chan struct{}, 1)
+		conn.SetPongHandler(func(string) error {
+			pong <- struct{}{}
+			return nil
+		})
+		require.NoError(t, conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)))
+		go conn.ReadMessage()
+		select {
+		case <-pong:
+		case <-time.After(2 * time.Second):
+			t.Fatal("no pong")
+		}
+	})
+}
+
+func TestChatWSParseUserIDRejectsMalformedClaims(t *testing.T) {
+	h := &ChatWSHandler{signingKey: wsTestSecret}
+	sign := func(userID interface{}) string {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user_id": userID,
+			"exp":     time.Now().Add(time.Hour).Unix(),
+		}).SignedString(wsTestSecret)
+		require.NoError(t, err)
+		return s
+	}
+
+	userID, err := h.parseUserID(sign(12))
+	require.NoError(t, err)
+	assert.Equal(t, 12, userID)
+
+	for _, claim := range []interface{}{"12abc", "0", -3} {
+		_, err := h.parseUserID(sign(claim))
+		assert.Error(t, err, claim)
+	}
+}
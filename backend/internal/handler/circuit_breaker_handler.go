@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// CircuitBreakerSource 渠道断路器状态的查询与重置（由 RelayService 实现）
+type CircuitBreakerSource interface {
+	CircuitBreakerStates() []relay.CircuitBreakerState
+	ResetCircuitBreaker(channelID int) error
+}
+
+// CircuitBreakerHandler 渠道断路器管理接口
+type CircuitBreakerHandler struct {
+	source CircuitBreakerSource
+}
+
+// NewCircuitBreakerHandler 创建断路器管理Handler
+func NewCircuitBreakerHandler(source CircuitBreakerSource) *CircuitBreakerHandler {
+	return &CircuitBreakerHandler{source: source}
+}
+
+// ListCircuitBreakers 获取每个渠道的断路器状态
+// GET /v1/admin/circuit-breakers
+func (h *CircuitBreakerHandler) ListCircuitBreakers(c *gin.Context) {
+	utils.Success(c, gin.H{
+		"circuit_breakers": h.source.CircuitBreakerStates(),
+	}, "")
+}
+
+// ResetCircuitBreaker 强制关闭渠道的断路器
+// POST /v1/admin/circuit-breakers/:channel_id/reset
+func (h *CircuitBreakerHandler) ResetCircuitBreaker(c *gin.Context) {
+	channelID, err := strconv.Atoi(c.Param("channel_id"))
+	if err != nil || channelID <= 0 {
+		utils.BadRequest(c, "无效的渠道 ID")
+		return
+	}
+
+	if err := h.source.ResetCircuitBreaker(channelID); err != nil {
+		if errors.Is(err, relay.ErrChannelNotFound) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalError(c, err.Error())
+		return
+	}
+	utils.Success(c, nil, "断路器已重置")
+}
+
+// RegisterRoutes 注册路由（需挂载在管理员鉴权的路由组下）
+func (h *CircuitBreakerHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/circuit-breakers", h.ListCircuitBreakers)
+	r.POST("/circuit-breakers/:channel_id/reset", h.ResetCircuitBreaker)
+}
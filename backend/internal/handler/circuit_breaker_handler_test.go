@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCircuitBreakerSource 记录重置的渠道，重置后状态变为关闭
+type fakeCircuitBreakerSource struct {
+	states map[int]relay.CircuitState
+	resets []int
+}
+
+func (f *fakeCircuitBreakerSource) CircuitBreakerStates() []relay.CircuitBreakerState {
+	states := make([]relay.CircuitBreakerState, 0, len(f.states))
+	for id := 1; id <= len(f.states); id++ {
+		states = append(states, relay.CircuitBreakerState{ChannelID: fmt.Sprint(id), State: f.states[id]})
+	}
+	return states
+}
+
+func (f *fakeCircuitBreakerSource) ResetCircuitBreaker(channelID int) error {
+	if _, ok := f.states[channelID]; !ok {
+		return fmt.Errorf("%w: %d", relay.ErrChannelNotFound, channelID)
+	}
+	f.resets = append(f.resets, channelID)
+	f.states[channelID] = relay.CircuitClosed
+	return nil
+}
+
+func TestCircuitBreakerHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	source := &fakeCircuitBreakerSource{states: map[int]relay.CircuitState{1: relay.CircuitOpen, 2: relay.CircuitClosed}}
+	r := gin.New()
+	NewCircuitBreakerHandler(source).RegisterRoutes(r.Group("/v1/admin"))
+
+	list := func() []map[string]interface{} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/admin/circuit-breakers", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data struct {
+				CircuitBreakers []map[string]interface{} `json:"circuit_breakers"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data.CircuitBreakers
+	}
+
+	states := list()
+	require.Len(t, states, 2)
+	assert.Equal(t, "open", states[0]["state"])
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/admin/circuit-breakers/1/reset", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []int{1}, source.resets)
+	assert.Equal(t, "closed", list()[0]["state"])
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/admin/circuit-breakers/9/reset", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/admin/circuit-breakers/abc/reset", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, []int{1}, source.resets)
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/clientexample"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// ClientExampleHandler API 调用示例Handler
+type ClientExampleHandler struct {
+	exampleService *service.ClientExampleService
+}
+
+// NewClientExampleHandler 创建调用示例Handler
+func NewClientExampleHandler(exampleService *service.ClientExampleService) *ClientExampleHandler {
+	return &ClientExampleHandler{exampleService: exampleService}
+}
+
+// GetClientExamples 按部署的实际地址与调用方的推荐模型生成调用示例
+// GET /api/v1/config/client-examples?lang=curl|python|node|go
+func (h *ClientExampleHandler) GetClientExamples(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	examples, err := h.exampleService.Examples(c.Request.Context(), userID, c.DefaultQuery("lang", clientexample.LangCurl))
+	if err != nil {
+		if errors.Is(err, clientexample.ErrUnsupportedLanguage) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, examples, "")
+}
+
+// RegisterRoutes 注册路由
+func (h *ClientExampleHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/config/client-examples", h.GetClientExamples)
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/dataexport"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+// DataExportHandler 用户数据导出Handler
+type DataExportHandler struct {
+	exportService *dataexport.Service
+}
+
+// NewDataExportHandler 创建数据导出Handler
+func NewDataExportHandler(exportService *dataexport.Service) *DataExportHandler {
+	return &DataExportHandler{exportService: exportService}
+}
+
+// DataExportResponse 导出任务状态
+type DataExportResponse struct {
+	*model.DataExport
+	DownloadURL string `json:"download_url,omitempty"` // 已完成时的限时签名下载链接
+}
+
+// currentUserID 当前登录用户的 ID
+func currentUserID(c *gin.Context) (int, bool) {
+	raw, err := ExtractUserID(c)
+	if err != nil {
+		return 0, false
+	}
+	userID, err := strconv.Atoi(raw)
+	if err != nil || userID <= 0 {
+		return 0, false
+	}
+	return userID, true
+}
+
+// CreateExport 创建数据导出任务，每个用户每天一次
+// POST /api/v1/user/export
+func (h *DataExportHandler) CreateExport(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	job, err := h.exportService.Create(c.Request.Context(), userID)
+	if err != nil {
+		var limited *dataexport.LimitError
+		if errors.As(err, &limited) {
+			retryAfter := int(time.Until(limited.RetryAt).Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			utils.Error(c, http.StatusTooManyRequests, utils.ErrRateLimitExceeded, err.Error(), gin.H{
+				"export_id": limited.ExportID,
+				"retry_at":  limited.RetryAt,
+			})
+			return
+		}
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, &DataExportResponse{DataExport: job}, "导出任务已创建")
+}
+
+// GetExport 查询导出进度，完成后返回下载链接
+// GET /api/v1/user/export/:id
+func (h *DataExportHandler) GetExport(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "invalid id")
+		return
+	}
+
+	job, err := h.exportService.Get(c.Request.Context(), userID, id)
+	if err != nil {
+		if errors.Is(err, dataexport.ErrExportNotFound) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, &DataExportResponse{DataExport: job, DownloadURL: h.exportService.DownloadURL(job)}, "")
+}
+
+// DownloadExport 通过签名链接下载导出归档，不需要登录
+// GET /api/v1/user/export/:id/download?expires=&signature=
+func (h *DataExportHandler) DownloadExport(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "invalid id")
+		return
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "invalid expires")
+		return
+	}
+
+	job, rc, err := h.exportService.Open(c.Request.Context(), id, expires, c.Query("signature"))
+	if err != nil {
+		switch {
+		case errors.Is(err, dataexport.ErrInvalidSignature):
+			utils.Forbidden(c)
+		case errors.Is(err, dataexport.ErrExportNotFound):
+			utils.NotFound(c, err.Error())
+		case errors.Is(err, dataexport.ErrExportExpired):
+			utils.Error(c, http.StatusGone, utils.ErrNotFound, err.Error(), nil)
+		case errors.Is(err, dataexport.ErrExportNotReady):
+			utils.RespondError(c, utils.WrapError(utils.CodeConflict, err))
+		default:
+			utils.InternalError(c, err.Error())
+		}
+		return
+	}
+	defer rc.Close()
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%s.zip"`, job.CreatedAt.UTC().Format("20060102")))
+	if job.SizeBytes > 0 {
+		c.Header("Content-Length", strconv.FormatInt(job.SizeBytes, 10))
+	}
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, rc); err != nil {
+		logger.Warn("data export download interrupted", zap.String("export_id", id.String()), zap.Error(err))
+	}
+}
+
+// RegisterRoutes 注册需要登录的导出路由
+func (h *DataExportHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.POST("/user/export", h.CreateExport)
+	r.GET("/user/export/:id", h.GetExport)
+}
+
+// RegisterDownloadRoute 注册签名下载路由（链接本身即凭证，不经过登录鉴权）
+func (h *DataExportHandler) RegisterDownloadRoute(r *gin.RouterGroup) {
+	r.GET("/user/export/:id/download", h.DownloadExport)
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// EmbeddingRelayer 中转 Embedding 请求（由 RelayService 实现）
+type EmbeddingRelayer interface {
+	RelayEmbeddings(ctx context.Context, req *relay.EmbeddingRequest) (*relay.EmbeddingResponse, error)
+}
+
+// EmbeddingHandler OpenAI 兼容的 Embedding 接口
+type EmbeddingHandler struct {
+	relayer EmbeddingRelayer
+}
+
+// NewEmbeddingHandler 创建 Embedding Handler
+func NewEmbeddingHandler(relayer EmbeddingRelayer) *EmbeddingHandler {
+	return &EmbeddingHandler{relayer: relayer}
+}
+
+// CreateEmbeddings 生成文本向量，input 可以是字符串或字符串数组
+// POST /v1/embeddings
+func (h *EmbeddingHandler) CreateEmbeddings(c *gin.Context) {
+	var req relay.EmbeddingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInvalidRequest, err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInvalidRequest, err))
+		return
+	}
+
+	token := middleware.APITokenFromContext(c)
+	if token != nil && !token.ValidateModel(req.Model) {
+		utils.RespondOpenAIError(c, utils.NewAppError(utils.CodeModelNotAllowed, "model not allowed for this token: "+req.Model))
+		return
+	}
+	rc, err := relay.NewRelayContextBuilder(c.GetString("request_id"), relay.EndpointEmbeddings).
+		Token(token).
+		Model(req.Model, false).
+		Headers(c.Request.Header).
+		Affinity(c.GetHeader(relay.SessionHeader)).
+		Admission(middleware.AdmissionTiming(c)).
+		Build()
+	if err != nil {
+		utils.RespondOpenAIError(c, err)
+		return
+	}
+
+	resp, err := h.relayer.RelayEmbeddings(relay.WithRelayContext(c.Request.Context(), rc), &req)
+	if rc.UpstreamRequestID != "" {
+		c.Header("X-Upstream-Request-ID", rc.UpstreamRequestID)
+	}
+	if err != nil {
+		// 错误按 OpenAI 格式返回，上游限流与参数错误沿用上游状态码，便于调用方区分
+		utils.RespondOpenAIError(c, RelayError(err, rc))
+		return
+	}
+
+	// 与 OpenAI 一致直接返回 {object, data, model, usage}，不经过统一响应包装
+	c.JSON(http.StatusOK, resp)
+}
+
+// RegisterRoutes 注册路由
+func (h *EmbeddingHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.POST("/embeddings", h.CreateEmbeddings)
+}
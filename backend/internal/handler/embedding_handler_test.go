@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEmbeddingRelayer 为每条输入返回一个向量，或返回指定错误
+type fakeEmbeddingRelayer struct {
+	err  error
+	reqs []*relay.EmbeddingRequest
+}
+
+func (f *fakeEmbeddingRelayer) RelayEmbeddings(ctx context.Context, req *relay.EmbeddingRequest) (*relay.EmbeddingResponse, error) {
+	f.reqs = append(f.reqs, req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	resp := &relay.EmbeddingResponse{Object: "list", Model: req.Model}
+	for i := range req.Input {
+		resp.Data = append(resp.Data, relay.EmbeddingData{Object: "embedding", Embedding: []float64{float64(i), 0.5}, Index: i})
+	}
+	resp.Usage = relay.EmbeddingUsage{PromptTokens: 3 * len(req.Input), TotalTokens: 3 * len(req.Input)}
+	return resp, nil
+}
+
+func serveEmbeddings(relayer EmbeddingRelayer, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewEmbeddingHandler(relayer).RegisterRoutes(r.Group("/v1"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateEmbeddings(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantInput []string
+	}{
+		{"single string", `{"model":"text-embedding-3-small","input":"hello"}`, []string{"hello"}},
+		{"array", `{"model":"text-embedding-3-small","input":["hello","world","again"]}`, []string{"hello", "world", "again"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relayer := &fakeEmbeddingRelayer{}
+			w := serveEmbeddings(relayer, tt.body)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			require.Len(t, relayer.reqs, 1)
+			assert.Equal(t, relay.EmbeddingInput(tt.wantInput), relayer.reqs[0].Input)
+
+			var resp relay.EmbeddingResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, "list", resp.Object)
+			require.Len(t, resp.Data, len(tt.wantInput))
+			for i, d := range resp.Data {
+				assert.Equal(t, i, d.Index)
+				assert.NotEmpty(t, d.Embedding)
+			}
+			assert.Equal(t, 3*len(tt.wantInput), resp.Usage.PromptTokens)
+		})
+	}
+}
+
+func TestCreateEmbeddingsValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"missing input", `{"model":"text-embedding-3-small"}`},
+		{"empty string", `{"model":"text-embedding-3-small","input":""}`},
+		{"empty array", `{"model":"text-embedding-3-small","input":[]}`},
+		{"blank element", `{"model":"text-embedding-3-small","input":["ok","  "]}`},
+		{"non-string input", `{"model":"text-embedding-3-small","input":[1,2]}`},
+		{"missing model", `{"input":"hello"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relayer := &fakeEmbeddingRelayer{}
+			w := serveEmbeddings(relayer, tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Empty(t, relayer.reqs, "invalid request must not reach upstream")
+		})
+	}
+}
+
+func TestCreateEmbeddingsUpstreamError(t *testing.T) {
+	relayer := &fakeEmbeddingRelayer{err: &relay.FailoverError{
+		Attempted: []string{"1", "2"},
+		Err:       &relay.UpstreamError{StatusCode: http.StatusTooManyRequests, Message: "rate limited"},
+	}}
+
+	w := serveEmbeddings(relayer, `{"model":"text-embedding-3-small","input":"hello"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	var body struct {
+		Error struct {
+			Message string                 `json:"message"`
+			Details map[string]interface{} `json:"details"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "rate limited", body.Error.Message)
+	assert.EqualValues(t, http.StatusTooManyRequests, body.Error.Details["upstream_status"])
+	assert.Len(t, body.Error.Details["attempted_channels"], 2)
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// multipartOverhead multipart 请求中除文件内容外的边界与表单头开销
+const multipartOverhead = 1 << 20
+
+// FileHandler 文件上传与下载接口
+type FileHandler struct {
+	fileService *service.FileService
+}
+
+// NewFileHandler 创建文件 Handler
+func NewFileHandler(fileService *service.FileService) *FileHandler {
+	return &FileHandler{fileService: fileService}
+}
+
+// Upload 上传文件，表单字段为 file
+// POST /api/v1/upload
+func (h *FileHandler) Upload(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.fileService.MaxUploadSize()+multipartOverhead)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.fileError(c, service.ErrFileTooLarge)
+			return
+		}
+		utils.BadRequest(c, "缺少上传文件")
+		return
+	}
+
+	result, err := h.fileService.Upload(c.Request.Context(), userID, header)
+	if err != nil {
+		h.fileError(c, err)
+		return
+	}
+
+	utils.Success(c, result, "上传成功")
+}
+
+// Download 下载文件，仅文件所有者可访问
+// GET /api/v1/download/:id
+func (h *FileHandler) Download(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "无效的文件ID")
+		return
+	}
+
+	file, content, err := h.fileService.Open(c.Request.Context(), userID, id)
+	if err != nil {
+		h.fileError(c, err)
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, file.Size, file.MimeType, content, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}),
+		"ETag":                   `"` + file.Hash + `"`,
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// ListFiles 分页获取当前用户的文件
+// GET /api/v1/files
+func (h *FileHandler) ListFiles(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	files, total, err := h.fileService.ListFiles(c.Request.Context(), userID, page, pageSize)
+	if err != nil {
+		utils.InternalError(c, "获取文件列表失败")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"files":     files,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}, "")
+}
+
+// fileError 将文件服务的错误映射为 HTTP 状态码
+func (h *FileHandler) fileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrFileNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, service.ErrFileForbidden):
+		utils.Error(c, http.StatusForbidden, utils.ErrForbidden, err.Error(), nil)
+	case errors.Is(err, service.ErrFileTooLarge):
+		utils.Error(c, http.StatusRequestEntityTooLarge, utils.ErrInvalidRequest, err.Error(),
+			gin.H{"max_size": h.fileService.MaxUploadSize()})
+	case errors.Is(err, service.ErrFileTypeNotAllowed):
+		utils.Error(c, http.StatusUnsupportedMediaType, utils.ErrInvalidRequest, err.Error(), nil)
+	default:
+		utils.InternalError(c, err.Error())
+	}
+}
+
+// RegisterRoutes 注册路由（需挂载在鉴权的路由组下）
+func (h *FileHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.POST("/upload", h.Upload)
+	r.GET("/download/:id", h.Download)
+	r.GET("/files", h.ListFiles)
+}
@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryFileStore 内存中的文件元数据
+type memoryFileStore struct {
+	mu    sync.Mutex
+	files []*model.File
+}
+
+func (s *memoryFileStore) Create(ctx context.Context, file *model.File) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file.ID = uuid.New()
+	file.CreatedAt = time.Now()
+	copied := *file
+	s.files = append(s.files, &copied)
+	return nil
+}
+
+func (s *memoryFileStore) FindByID(ctx context.Context, id uuid.UUID) (*model.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.files {
+		if f.ID == id {
+			copied := *f
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryFileStore) FindByUserAndHash(ctx context.Context, userID int, hash string) (*model.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.files {
+		if f.UserID == userID && f.Hash == hash {
+			copied := *f
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryFileStore) FindByUserID(ctx context.Context, userID int, page, pageSize int) ([]*model.File, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var owned []*model.File
+	for i := len(s.files) - 1; i >= 0; i-- {
+		if s.files[i].UserID == userID {
+			copied := *s.files[i]
+			owned = append(owned, &copied)
+		}
+	}
+	start := min((page-1)*pageSize, len(owned))
+	end := min(start+pageSize, len(owned))
+	return owned[start:end], int64(len(owned)), nil
+}
+
+// fileTestSecret 测试用 JWT 密钥
+var fileTestSecret = []byte("file-test-secret")
+
+// newFileRouter 使用临时目录作为本地存储挂载文件路由
+func newFileRouter(t *testing.T, maxSize int64) (*gin.Engine, *memoryFileStore, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	objects, err := storage.NewLocalObjectStore(dir)
+	require.NoError(t, err)
+	records := &memoryFileStore{}
+
+	r := gin.New()
+	v1 := r.Group("/api/v1")
+	v1.Use(middleware.AuthMiddleware(fileTestSecret))
+	NewFileHandler(service.NewFileService(records, objects, maxSize, nil)).RegisterRoutes(v1)
+	return r, records, dir
+}
+
+// fileToken 签发指定用户的访问令牌
+func fileToken(t *testing.T, userID int) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"role":    1,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	s, err := token.SignedString(fileTestSecret)
+	require.NoError(t, err)
+	return s
+}
+
+// uploadFile 以 multipart 表单上传文件
+func uploadFile(t *testing.T, r *gin.Engine, userID int, name string, content []byte) (int, map[string]interface{}) {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", name)
+	require.NoError(t, err)
+	part.Write(content)
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+fileToken(t, userID))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Code, resp
+}
+
+// fileRequest 以指定用户发送请求
+func fileRequest(t *testing.T, r *gin.Engine, userID int, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+fileToken(t, userID))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestFileHandlerUploadAndDownload(t *testing.T) {
+	r, records, dir := newFileRouter(t, 0)
+	content := []byte("# Notes\n\nhello world\n")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	code, resp := uploadFile(t, r, 1, "../../notes.md", content)
+	require.Equal(t, http.StatusOK, code, resp)
+	data := resp["data"].(map[string]interface{})
+	assert.Equal(t, "notes.md", data["name"])
+	assert.Equal(t, "text/markdown", data["mime_type"])
+	assert.Equal(t, hash, data["hash"])
+	assert.Equal(t, float64(len(content)), data["size"])
+	assert.Equal(t, false, data["duplicate"])
+	assert.NotContains(t, data, "storage_key")
+
+	stored, err := os.ReadFile(filepath.Join(dir, "files", hash[:2], hash))
+	require.NoError(t, err)
+	assert.Equal(t, content, stored)
+
+	// 同一用户重复上传返回已有记录
+	code, resp = uploadFile(t, r, 1, "copy.md", content)
+	require.Equal(t, http.StatusOK, code, resp)
+	assert.Equal(t, true, resp["data"].(map[string]interface{})["duplicate"])
+	assert.Equal(t, data["id"], resp["data"].(map[string]interface{})["id"])
+
+	// 其他用户上传相同内容时有独立的记录，但共用存储对象
+	code, resp = uploadFile(t, r, 2, "other.md", content)
+	require.Equal(t, http.StatusOK, code, resp)
+	assert.Equal(t, false, resp["data"].(map[string]interface{})["duplicate"])
+	assert.Len(t, records.files, 2)
+
+	w := fileRequest(t, r, 1, "/api/v1/download/"+data["id"].(string))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, content, w.Body.Bytes())
+	assert.Equal(t, "text/markdown", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=notes.md`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, `"`+hash+`"`, w.Header().Get("ETag"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, fmt.Sprint(len(content)), w.Header().Get("Content-Length"))
+
+	// 只有所有者可以下载
+	w = fileRequest(t, r, 2, "/api/v1/download/"+data["id"].(string))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = fileRequest(t, r, 1, "/api/v1/download/"+uuid.NewString())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = fileRequest(t, r, 1, "/api/v1/download/not-a-uuid")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestFileHandlerUploadLimits(t *testing.T) {
+	r, records, _ := newFileRouter(t, 1024)
+
+	code, _ := uploadFile(t, r, 1, "big.txt", bytes.Repeat([]byte("a"), 2048))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+
+	// 扩展名不能让二进制内容通过类型检查
+	code, resp := uploadFile(t, r, 1, "app.txt", []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xff\xff"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, code)
+	assert.Contains(t, resp["error"].(map[string]interface{})["message"], "application/octet-stream")
+
+	code, resp = uploadFile(t, r, 1, "page.html", []byte("<!DOCTYPE html><html><body>hi</body></html>"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, code, resp)
+
+	code, resp = uploadFile(t, r, 1, "pixel.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"))
+	require.Equal(t, http.StatusOK, code, resp)
+	assert.Equal(t, "image/png", resp["data"].(map[string]interface{})["mime_type"])
+
+	assert.Len(t, records.files, 1)
+}
+
+func TestFileHandlerListFiles(t *testing.T) {
+	r, _, _ := newFileRouter(t, 0)
+
+	for i := 0; i < 3; i++ {
+		code, resp := uploadFile(t, r, 1, fmt.Sprintf("file-%d.txt", i), []byte(fmt.Sprintf("content %d", i)))
+		require.Equal(t, http.StatusOK, code, resp)
+	}
+	code, resp := uploadFile(t, r, 2, "other.txt", []byte("other user"))
+	require.Equal(t, http.StatusOK, code, resp)
+
+	w := fileRequest(t, r, 1, "/api/v1/files?page=1&page_size=2")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	data := resp["data"].(map[string]interface{})
+	assert.Equal(t, float64(3), data["total"])
+	assert.Equal(t, float64(2), data["page_size"])
+	files := data["files"].([]interface{})
+	require.Len(t, files, 2)
+	assert.Equal(t, "file-2.txt", files[0].(map[string]interface{})["name"])
+
+	w = fileRequest(t, r, 1, "/api/v1/files?page=2&page_size=2")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	files = resp["data"].(map[string]interface{})["files"].([]interface{})
+	require.Len(t, files, 1)
+	assert.Equal(t, "file-0.txt", files[0].(map[string]interface{})["name"])
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/quota"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// InternalQuotaHandler 供网关调用的内部额度预检Handler
+type InternalQuotaHandler struct {
+	userRepo *repository.UserRepository
+}
+
+// NewInternalQuotaHandler 创建内部额度Handler
+func NewInternalQuotaHandler() *InternalQuotaHandler {
+	return &InternalQuotaHandler{
+		userRepo: repository.NewUserRepository(),
+	}
+}
+
+// CheckQuota 额度预检
+// @Summary 额度预检（内部接口，需服务间签名）
+// @Tags internal
+// @Param user_id query int true "用户ID"
+// @Param estimated_cost query number false "预估费用"
+// @Success 200 {object} quota.CheckResult
+// @Router /internal/quota/check [get]
+func (h *InternalQuotaHandler) CheckQuota(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Query("user_id"))
+	if err != nil || userID <= 0 {
+		utils.BadRequest(c, "invalid user_id")
+		return
+	}
+
+	estimatedCost := 0.0
+	if v := c.Query("estimated_cost"); v != "" {
+		estimatedCost, err = strconv.ParseFloat(v, 64)
+		if err != nil || estimatedCost < 0 {
+			utils.BadRequest(c, "invalid estimated_cost")
+			return
+		}
+	}
+
+	user, err := h.userRepo.FindByID(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+	if user == nil {
+		utils.NotFound(c, "user not found")
+		return
+	}
+
+	balance := float64(user.Quota)
+	utils.Success(c, &quota.CheckResult{
+		UserID:           userID,
+		Allow:            quota.Allows(balance, estimatedCost),
+		RemainingBalance: balance,
+		EstimatedCost:    estimatedCost,
+	}, "")
+}
+
+// RegisterRoutes 注册路由
+func (h *InternalQuotaHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/quota/check", h.CheckQuota)
+}
@@ -1,15 +1,24 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"path/filepath"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
 )
 
+// maxDocumentUploadSize 上传到知识库的单个文件的最大大小
+const maxDocumentUploadSize = 20 << 20
+
 // KBHandler 处理知识库相关的 HTTP 请求
 type KBHandler struct {
 	ragService *service.RAGService
@@ -35,6 +44,10 @@ func (h *KBHandler) CreateKnowledgeBase(c *gin.Context) {
 
 	kb, err := h.ragService.CreateKnowledgeBase(c.Request.Context(), userID, &req)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidChunkingConfig) {
+			utils.BadRequest(c, err.Error())
+			return
+		}
 		utils.InternalError(c, err.Error())
 		return
 	}
@@ -42,7 +55,7 @@ func (h *KBHandler) CreateKnowledgeBase(c *gin.Context) {
 	utils.Success(c, kb, "知识库创建成功")
 }
 
-// GetKnowledgeBase 获取知识库详情
+// GetKnowledgeBase 获取知识库详情，chunking 字段为生效的分块配置
 // GET /api/v1/knowledge-bases/:id
 func (h *KBHandler) GetKnowledgeBase(c *gin.Context) {
 	userID := c.GetInt("user_id")
@@ -67,7 +80,10 @@ func (h *KBHandler) GetKnowledgeBase(c *gin.Context) {
 		return
 	}
 
-	utils.Success(c, kb, "")
+	utils.Success(c, service.KnowledgeBaseDetail{
+		KnowledgeBase: kb,
+		Chunking:      service.KnowledgeBaseChunking(kb),
+	}, "")
 }
 
 // ListKnowledgeBases 获取用户的知识库列表
@@ -115,6 +131,10 @@ func (h *KBHandler) DeleteKnowledgeBase(c *gin.Context) {
 
 // UploadDocument 上传文档
 // POST /api/v1/knowledge-bases/:id/documents
+//
+// JSON 请求直接提交文本内容；multipart/form-data 请求上传文件（字段 file，可选 title、on_duplicate），
+// 按内容类型解析 PDF、DOCX、Markdown、HTML 等格式。两种请求都可以覆盖知识库的分块配置：
+// JSON 中的 chunking 对象，或表单字段 chunk_strategy、chunk_size、chunk_overlap。
 func (h *KBHandler) UploadDocument(c *gin.Context) {
 	userID := c.GetInt("user_id")
 	kbID, err := strconv.Atoi(c.Param("id"))
@@ -123,9 +143,16 @@ func (h *KBHandler) UploadDocument(c *gin.Context) {
 		return
 	}
 
+	if c.ContentType() == "multipart/form-data" {
+		h.uploadDocumentFile(c, userID, kbID)
+		return
+	}
+
 	var req struct {
-		Title       string `json:"title" binding:"required"`
-		FileContent string `json:"file_content" binding:"required"`
+		Title       string                    `json:"title" binding:"required"`
+		FileContent string                    `json:"file_content" binding:"required"`
+		OnDuplicate string                    `json:"on_duplicate"` // skip（默认）、replace、force
+		Chunking    *service.ChunkingOverride `json:"chunking"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -133,17 +160,140 @@ func (h *KBHandler) UploadDocument(c *gin.Context) {
 		return
 	}
 
-	doc, err := h.ragService.UploadDocument(c.Request.Context(), userID, kbID, req.Title, req.FileContent)
+	onDuplicate, err := service.ParseDuplicateAction(req.OnDuplicate)
 	if err != nil {
-		if err.Error() == "permission denied" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "无权限操作"})
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	result, err := h.ragService.UploadDocument(c.Request.Context(), userID, kbID, req.Title, req.FileContent, onDuplicate, req.Chunking)
+	if err != nil {
+		uploadDocumentError(c, err)
+		return
+	}
+
+	uploadDocumentSuccess(c, result)
+}
+
+// uploadDocumentFile 处理 multipart 文件上传，解析失败的文档以失败状态返回而不是 500
+func (h *KBHandler) uploadDocumentFile(c *gin.Context, userID, kbID int) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDocumentUploadSize+multipartOverhead)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.Error(c, http.StatusRequestEntityTooLarge, utils.ErrInvalidRequest, "file too large",
+				gin.H{"max_size": maxDocumentUploadSize})
 			return
 		}
+		utils.BadRequest(c, "缺少上传文件")
+		return
+	}
+	if header.Size > maxDocumentUploadSize {
+		utils.Error(c, http.StatusRequestEntityTooLarge, utils.ErrInvalidRequest, "file too large",
+			gin.H{"max_size": maxDocumentUploadSize})
+		return
+	}
+
+	onDuplicate, err := service.ParseDuplicateAction(c.PostForm("on_duplicate"))
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	chunking, err := chunkingOverrideFromForm(c)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	title := c.PostForm("title")
+	if title == "" {
+		title = filepath.Base(header.Filename)
+	}
+
+	file, err := header.Open()
+	if err != nil {
 		utils.InternalError(c, err.Error())
 		return
 	}
+	defer file.Close()
 
-	utils.Success(c, doc, "文档上传成功，正在处理中...")
+	data, err := io.ReadAll(file)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	result, err := h.ragService.UploadDocumentFile(c.Request.Context(), userID, kbID, title, header.Filename, data, onDuplicate, chunking)
+	if err != nil {
+		uploadDocumentError(c, err)
+		return
+	}
+
+	uploadDocumentSuccess(c, result)
+}
+
+// chunkingOverrideFromForm 从表单字段读取分块配置覆盖，没有相关字段时返回 nil
+func chunkingOverrideFromForm(c *gin.Context) (*service.ChunkingOverride, error) {
+	var override service.ChunkingOverride
+	set := false
+	if strategy := c.PostForm("chunk_strategy"); strategy != "" {
+		override.Strategy = service.ChunkStrategy(strategy)
+		set = true
+	}
+	for field, target := range map[string]**int{
+		"chunk_size":    &override.ChunkSize,
+		"chunk_overlap": &override.ChunkOverlap,
+	} {
+		value := c.PostForm(field)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %q", field, value)
+		}
+		*target = &n
+		set = true
+	}
+	if !set {
+		return nil, nil
+	}
+	return &override, nil
+}
+
+// uploadDocumentSuccess 返回上传结果，解析失败的文档附带错误信息
+func uploadDocumentSuccess(c *gin.Context, result *service.UploadDocumentResult) {
+	switch {
+	case result.Status == model.DocumentStatusFailed:
+		utils.Success(c, result, "文档解析失败: "+result.ErrorMessage)
+	case result.Replaced:
+		utils.Accepted(c, result, "文档已替换，正在重新处理...")
+	default:
+		utils.Accepted(c, result, "文档上传成功，正在处理中...")
+	}
+}
+
+// uploadDocumentError 将上传文档的错误映射为 HTTP 状态码
+func uploadDocumentError(c *gin.Context, err error) {
+	var dupErr *service.DuplicateDocumentError
+	switch {
+	case errors.As(err, &dupErr):
+		utils.RespondError(c, utils.WrapError(utils.CodeConflict, err).WithDetails(gin.H{
+			"existing_document": dupErr.Existing,
+			"options":           service.DuplicateActions,
+		}))
+	case errors.Is(err, service.ErrDocumentProcessing):
+		utils.RespondError(c, utils.WrapError(utils.CodeConflict, err))
+	case errors.Is(err, service.ErrInvalidChunkingConfig):
+		utils.BadRequest(c, err.Error())
+	case err.Error() == "permission denied":
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限操作"})
+	default:
+		utils.InternalError(c, err.Error())
+	}
 }
 
 // GetDocumentList 获取文档列表
@@ -206,8 +356,48 @@ func (h *KBHandler) DeleteDocument(c *gin.Context) {
 	utils.Success(c, nil, "文档删除成功")
 }
 
+// GetDocumentStatus 获取文档的索引状态与进度
+// GET /api/v1/knowledge-bases/:id/documents/:doc_id/status
+func (h *KBHandler) GetDocumentStatus(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+	kbID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid knowledge base ID")
+		return
+	}
+
+	docID, err := uuid.Parse(c.Param("doc_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid document ID")
+		return
+	}
+
+	status, err := h.ragService.GetDocumentIndexStatus(c.Request.Context(), userID, kbID, docID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrDocumentNotFound), err.Error() == "knowledge base not found":
+			utils.NotFound(c, err.Error())
+		case err.Error() == "permission denied":
+			c.JSON(http.StatusForbidden, gin.H{"error": "无权限操作"})
+		default:
+			utils.InternalError(c, err.Error())
+		}
+		return
+	}
+
+	utils.Success(c, status, "")
+}
+
 // SearchDocuments 搜索文档
 // POST /api/v1/knowledge-bases/:id/search
+//
+// mode 可选 vector、keyword、hybrid（默认），top_k 为返回的结果数（默认 10，最多 100）。
+// rerank 为 true 时以 top_k 个结果作为候选重新排序，返回前 rerank_top_n 个（默认与 top_k 相同）；
+// 重排序失败时保持检索顺序，metadata.rerank 中记录耗时与错误。
 func (h *KBHandler) SearchDocuments(c *gin.Context) {
 	userID := c.GetInt("user_id")
 	kbID, err := strconv.Atoi(c.Param("id"))
@@ -218,7 +408,12 @@ func (h *KBHandler) SearchDocuments(c *gin.Context) {
 
 	var req struct {
 		Query string `json:"query" binding:"required"`
-		Limit int    `json:"limit"`
+		Mode  string `json:"mode"`
+		TopK  int    `json:"top_k"`
+		Limit int    `json:"limit"` // 旧字段，未设置 top_k 时使用
+
+		Rerank     bool `json:"rerank"`
+		RerankTopN int  `json:"rerank_top_n"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -226,17 +421,34 @@ func (h *KBHandler) SearchDocuments(c *gin.Context) {
 		return
 	}
 
-	if req.Limit <= 0 {
-		req.Limit = 10
+	mode, err := service.ParseSearchMode(req.Mode)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
 	}
 
-	results, err := h.ragService.SearchDocuments(c.Request.Context(), userID, kbID, req.Query, req.Limit)
+	topK := req.TopK
+	if topK <= 0 {
+		topK = req.Limit
+	}
+
+	results, err := h.ragService.Search(c.Request.Context(), userID, kbID, req.Query, service.SearchOptions{
+		Mode:       mode,
+		TopK:       topK,
+		Rerank:     req.Rerank,
+		RerankTopN: req.RerankTopN,
+	})
 	if err != nil {
-		if err.Error() == "permission denied" {
+		switch {
+		case errors.Is(err, service.ErrEmptySearchQuery):
+			utils.BadRequest(c, err.Error())
+		case err.Error() == "knowledge base not found":
+			utils.NotFound(c, "知识库不存在")
+		case err.Error() == "permission denied":
 			c.JSON(http.StatusForbidden, gin.H{"error": "无权限操作"})
-			return
+		default:
+			utils.InternalError(c, err.Error())
 		}
-		utils.InternalError(c, err.Error())
 		return
 	}
 
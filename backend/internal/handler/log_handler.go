@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/logarchive"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+)
+
+// LogHandler 日志查询Handler
+type LogHandler struct {
+	logRepo  *repository.UnifiedLogRepository
+	archiver *logarchive.Archiver // 未启用归档时为 nil
+}
+
+// NewLogHandler 创建日志Handler
+func NewLogHandler(archiver *logarchive.Archiver) *LogHandler {
+	return &LogHandler{
+		logRepo:  repository.NewUnifiedLogRepository(),
+		archiver: archiver,
+	}
+}
+
+// ListLogsRequest 查询请求
+type ListLogsRequest struct {
+	Page              int    `form:"page" binding:"min=1"`
+	PageSize          int    `form:"page_size" binding:"min=1,max=100"`
+	UserID            int    `form:"user_id"`
+	ChannelID         int    `form:"channel_id"`
+	ProjectID         int    `form:"project_id"`
+	LogType           int    `form:"log_type"`
+	Model             string `form:"model"`
+	Status            string `form:"status" binding:"omitempty,oneof=success partial error"`
+	RequestID         string `form:"request_id"`
+	UpstreamRequestID string `form:"upstream_request_id"`
+	StartTime         int64  `form:"start_time"` // Unix 时间戳（秒）
+	EndTime           int64  `form:"end_time"`
+}
+
+// ListLogsResponse 查询响应
+type ListLogsResponse struct {
+	Total    int64               `json:"total"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"page_size"`
+	Data     []*model.UnifiedLog `json:"data"`
+	// Archived 查询范围内已归档、不在在线库中的分区
+	Archived []*model.LogArchive `json:"archived,omitempty"`
+	Notice   string              `json:"notice,omitempty"`
+}
+
+// ListLogs 分页查询统一日志
+// @Summary 分页查询统一日志
+// @Tags logs
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Param user_id query int false "用户 ID"
+// @Param channel_id query int false "渠道 ID"
+// @Param model query string false "模型"
+// @Param status query string false "中转结果：success、partial、error"
+// @Param start_time query int false "开始时间（Unix 秒）"
+// @Param end_time query int false "结束时间（Unix 秒，不含）"
+// @Param request_id query string false "本系统请求 ID"
+// @Param upstream_request_id query string false "上游提供方请求 ID"
+// @Success 200 {object} ListLogsResponse
+// @Router /v1/admin/logs [get]
+func (h *LogHandler) ListLogs(c *gin.Context) {
+	var req ListLogsRequest
+	req.Page = 1
+	req.PageSize = 20
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := &repository.LogFilter{
+		UserID:            req.UserID,
+		ChannelID:         req.ChannelID,
+		ProjectID:         req.ProjectID,
+		LogType:           req.LogType,
+		ModelName:         req.Model,
+		Status:            req.Status,
+		RequestID:         req.RequestID,
+		UpstreamRequestID: req.UpstreamRequestID,
+		Page:              req.Page,
+		PageSize:          req.PageSize,
+	}
+	if req.StartTime > 0 {
+		start := time.Unix(req.StartTime, 0)
+		filter.StartTime = &start
+	}
+	if req.EndTime > 0 {
+		end := time.Unix(req.EndTime, 0)
+		filter.EndTime = &end
+	}
+
+	logs, total, err := h.logRepo.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := ListLogsResponse{
+		Total:    total,
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+		Data:     logs,
+	}
+
+	// 查询范围跨越热窗口时，标注已归档的部分
+	if h.archiver != nil && filter.StartTime != nil {
+		end := time.Now()
+		if filter.EndTime != nil {
+			end = *filter.EndTime
+		}
+		cold, _, _ := logarchive.SplitRange(*filter.StartTime, end, h.archiver.HotCutoff())
+		if len(cold) > 0 {
+			archived, err := h.archiver.ArchivedRanges(c.Request.Context(), *filter.StartTime, end)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if len(archived) > 0 {
+				resp.Archived = archived
+				resp.Notice = "archived — request an export"
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListArchives 列出日志归档
+// @Summary 列出日志归档分区
+// @Tags logs
+// @Produce json
+// @Router /v1/admin/logs/archives [get]
+func (h *LogHandler) ListArchives(c *gin.Context) {
+	archives, err := h.archiver.ListArchives(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": archives})
+}
+
+// RestoreArchive 将归档分区恢复到在线库以便排查
+// @Summary 恢复日志归档分区
+// @Tags logs
+// @Produce json
+// @Param partition path string true "分区名，如 unified_logs_p202401"
+// @Router /v1/admin/logs/archives/{partition}/restore [post]
+func (h *LogHandler) RestoreArchive(c *gin.Context) {
+	record, err := h.archiver.RestorePartition(c.Request.Context(), c.Param("partition"))
+	if err != nil {
+		if errors.Is(err, logarchive.ErrArchiveNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": record})
+}
+
+// RegisterRoutes 注册路由
+func (h *LogHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/logs", h.ListLogs)
+	if h.archiver != nil {
+		r.GET("/logs/archives", h.ListArchives)
+		r.POST("/logs/archives/:partition/restore", h.RestoreArchive)
+	}
+}
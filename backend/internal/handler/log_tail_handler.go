@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/logtail"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+// 实时跟踪的审计操作类型
+const (
+	AuditOpTailRequests = "tail_user_requests" // 查看用户请求元数据
+	AuditOpTailContent  = "tail_user_content"  // 查看用户消息内容
+)
+
+// LogTailHandler 管理员实时跟踪单个用户的请求，用于与客户共同排查问题
+type LogTailHandler struct {
+	registry  *logtail.Registry
+	settings  func(ctx context.Context, userID int) (*model.UserSettings, error)
+	audit     func(ctx context.Context, entry *model.PermissionAuditLog) error
+	heartbeat time.Duration
+}
+
+// NewLogTailHandler 创建实时跟踪Handler
+func NewLogTailHandler(registry *logtail.Registry) *LogTailHandler {
+	return &LogTailHandler{
+		registry:  registry,
+		settings:  repository.NewUserRepository().FindSettings,
+		audit:     repository.NewAuditLogRepository().Create,
+		heartbeat: 30 * time.Second,
+	}
+}
+
+// TailUser 以 SSE 推送用户请求完成后的元数据
+// GET /api/v1/admin/users/:id/tail?reason=...&include_content=true
+//
+// reason 必填并写入审计日志；include_content 需要用户的保留策略允许，且单独记录一条审计日志。
+func (h *LogTailHandler) TailUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || userID <= 0 {
+		utils.BadRequest(c, "无效的用户 ID")
+		return
+	}
+	reason := strings.TrimSpace(c.Query("reason"))
+	if reason == "" {
+		utils.BadRequest(c, "reason is required")
+		return
+	}
+	includeContent, _ := strconv.ParseBool(c.DefaultQuery("include_content", "false"))
+
+	ctx := c.Request.Context()
+	if includeContent {
+		settings, err := h.settings(ctx, userID)
+		if err != nil {
+			utils.InternalError(c, err.Error())
+			return
+		}
+		if !settings.AllowsContent() {
+			utils.Error(c, http.StatusForbidden, utils.ErrForbidden, "user's retention policy does not allow viewing message content", nil)
+			return
+		}
+	}
+
+	adminID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+	sub, err := h.registry.Subscribe(adminID, userID, includeContent)
+	if err != nil {
+		if errors.Is(err, logtail.ErrTooManyTails) {
+			utils.Error(c, http.StatusTooManyRequests, utils.ErrRateLimitExceeded, err.Error(), nil)
+			return
+		}
+		utils.InternalError(c, err.Error())
+		return
+	}
+	defer h.registry.Unsubscribe(sub)
+
+	// 审计日志写入失败时不开始跟踪
+	if err := h.recordAudit(c, AuditOpTailRequests, adminID, userID, reason); err != nil {
+		utils.InternalError(c, "failed to record audit log")
+		return
+	}
+	if includeContent {
+		if err := h.recordAudit(c, AuditOpTailContent, adminID, userID, reason); err != nil {
+			utils.InternalError(c, "failed to record audit log")
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	w := c.Writer
+	fmt.Fprintf(w, "event: ready\ndata: {\"user_id\":%d,\"include_content\":%t}\n\n", userID, includeContent)
+	w.Flush()
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.Done():
+			fmt.Fprintf(w, "event: expired\ndata: {\"reason\":\"inactive\"}\n\n")
+			w.Flush()
+			return
+		case ev := <-sub.Events():
+			data, err := json.Marshal(ev)
+			if err != nil {
+				logger.Warn("failed to marshal tail event", zap.Error(err))
+				continue
+			}
+			fmt.Fprintf(w, "event: request\ndata: %s\n\n", data)
+			w.Flush()
+		case <-ticker.C:
+			// 心跳，同时告知因缓存已满被丢弃的事件数
+			fmt.Fprintf(w, ": ping dropped=%d\n\n", sub.Dropped())
+			w.Flush()
+		}
+	}
+}
+
+// recordAudit 写入审计日志
+func (h *LogTailHandler) recordAudit(c *gin.Context, operation string, adminID, userID int, reason string) error {
+	details, _ := json.Marshal(map[string]interface{}{"reason": reason})
+	err := h.audit(c.Request.Context(), &model.PermissionAuditLog{
+		UserID:       adminID,
+		Operation:    operation,
+		TargetUserID: &userID,
+		Details:      details,
+		IPAddress:    c.ClientIP(),
+		UserAgent:    c.Request.UserAgent(),
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		logger.Error("failed to record tail audit log",
+			zap.String("operation", operation),
+			zap.Int("admin_id", adminID),
+			zap.Int("user_id", userID),
+			zap.Error(err))
+	}
+	return err
+}
+
+// RegisterRoutes 注册路由（需挂载在管理员鉴权的路由组下）
+func (h *LogTailHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/users/:id/tail", h.TailUser)
+}
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/logtail"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tailFixture 使用内存中的保留策略与审计记录
+type tailFixture struct {
+	registry *logtail.Registry
+	handler  *LogTailHandler
+	router   *gin.Engine
+
+	mu     sync.Mutex
+	audits []*model.PermissionAuditLog
+}
+
+func newTailFixture(retention string) *tailFixture {
+	f := &tailFixture{registry: logtail.NewRegistry(nil)}
+	f.handler = &LogTailHandler{
+		registry: f.registry,
+		settings: func(ctx context.Context, userID int) (*model.UserSettings, error) {
+			return &model.UserSettings{UserID: userID, ContentRetention: retention}, nil
+		},
+		audit: func(ctx context.Context, entry *model.PermissionAuditLog) error {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.audits = append(f.audits, entry)
+			return nil
+		},
+		heartbeat: time.Minute,
+	}
+
+	gin.SetMode(gin.TestMode)
+	f.router = gin.New()
+	admin := f.router.Group("/api/v1/admin")
+	admin.Use(func(c *gin.Context) { c.Set("user_id", 1) })
+	f.handler.RegisterRoutes(admin)
+	return f
+}
+
+func (f *tailFixture) auditOps() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ops []string
+	for _, a := range f.audits {
+		ops = append(ops, a.Operation)
+	}
+	return ops
+}
+
+// stream 发起跟踪请求，推送一个事件后断开，返回收到的 request 事件
+func (f *tailFixture) stream(t *testing.T, query string) *logtail.Event {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/users/42/tail?"+query, nil).WithContext(ctx)
+	pr, pw := newPipeRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.router.ServeHTTP(pw, req)
+		pw.Close()
+	}()
+
+	reader := bufio.NewReader(pr)
+	require.Equal(t, "event: ready\n", readLine(t, reader))
+
+	content := func() *logtail.Content {
+		return &logtail.Content{Prompt: "hello", Completion: "world"}
+	}
+	f.registry.Publish(42, &logtail.Event{RequestID: "req-1", Model: "gpt-4o", Status: "success"}, content)
+
+	var ev *logtail.Event
+	for ev == nil {
+		line := readLine(t, reader)
+		if strings.HasPrefix(line, "data: ") && strings.Contains(line, "req-1") {
+			ev = &logtail.Event{}
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "data: ")), ev))
+		}
+	}
+
+	cancel()
+	go func() {
+		for {
+			if _, err := reader.ReadByte(); err != nil {
+				return
+			}
+		}
+	}()
+	<-done
+	return ev
+}
+
+func TestTailUserRequiresReason(t *testing.T) {
+	f := newTailFixture(model.ContentRetentionFull)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/users/42/tail", nil)
+	f.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, f.auditOps())
+}
+
+func TestTailUserContentForbiddenByRetention(t *testing.T) {
+	f := newTailFixture(model.ContentRetentionMetadata)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/users/42/tail?reason=ticket-1&include_content=true", nil)
+	f.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, f.auditOps(), "rejected tails must not be audited as content access")
+
+	// 未占用管理员的并发配额
+	for i := 0; i < logtail.DefaultConfig().MaxPerAdmin; i++ {
+		_, err := f.registry.Subscribe(1, 42, false)
+		require.NoError(t, err)
+	}
+}
+
+func TestTailUserMetadataOnly(t *testing.T) {
+	f := newTailFixture(model.ContentRetentionFull)
+
+	ev := f.stream(t, "reason=ticket-1")
+	assert.Equal(t, "gpt-4o", ev.Model)
+	assert.Nil(t, ev.Content)
+	assert.Equal(t, []string{AuditOpTailRequests}, f.auditOps())
+}
+
+func TestTailUserWithContent(t *testing.T) {
+	f := newTailFixture(model.ContentRetentionFull)
+
+	ev := f.stream(t, "reason=ticket-1&include_content=true")
+	require.NotNil(t, ev.Content)
+	assert.Equal(t, "hello", ev.Content.Prompt)
+	assert.Equal(t, "world", ev.Content.Completion)
+	assert.Equal(t, []string{AuditOpTailRequests, AuditOpTailContent}, f.auditOps())
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	assert.Equal(t, 1, f.audits[0].UserID)
+	require.NotNil(t, f.audits[0].TargetUserID)
+	assert.Equal(t, 42, *f.audits[0].TargetUserID)
+	assert.Contains(t, string(f.audits[0].Details), "ticket-1")
+}
+
+// pipeRecorder 将 SSE 输出写入管道，便于边写边读
+type pipeRecorder struct {
+	*httptest.ResponseRecorder
+	pw *io.PipeWriter
+}
+
+func newPipeRecorder() (*io.PipeReader, *pipeRecorder) {
+	pr, pw := io.Pipe()
+	return pr, &pipeRecorder{ResponseRecorder: httptest.NewRecorder(), pw: pw}
+}
+
+func (p *pipeRecorder) Write(b []byte) (int, error) {
+	return p.pw.Write(b)
+}
+
+func (p *pipeRecorder) WriteString(s string) (int, error) {
+	return p.pw.Write([]byte(s))
+}
+
+func (p *pipeRecorder) Flush() {}
+
+func (p *pipeRecorder) Close() error {
+	return p.pw.Close()
+}
+
+func readLine(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	return line
+}
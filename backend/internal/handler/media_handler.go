@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// maxFormFieldSize 转写表单中单个文本字段的大小上限
+const maxFormFieldSize = 64 << 10
+
+// MediaRelayer 中转图像生成与语音请求（由 RelayService 实现）
+type MediaRelayer interface {
+	RelayImageGeneration(ctx context.Context, req *relay.ImageGenerationRequest) (*relay.ImageGenerationResponse, error)
+	RelaySpeech(ctx context.Context, req *relay.SpeechRequest) (*relay.MediaResponse, error)
+	RelayTranscription(ctx context.Context, req *relay.TranscriptionRequest) (*relay.MediaResponse, error)
+}
+
+// MediaHandler OpenAI 兼容的图像生成、语音合成与语音转写接口
+type MediaHandler struct {
+	relayer MediaRelayer
+}
+
+// NewMediaHandler 创建图像与语音 Handler
+func NewMediaHandler(relayer MediaRelayer) *MediaHandler {
+	return &MediaHandler{relayer: relayer}
+}
+
+// CreateImage 根据提示词生成图片，按生成的图片数计费
+// POST /v1/images/generations
+func (h *MediaHandler) CreateImage(c *gin.Context) {
+	var req relay.ImageGenerationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInvalidRequest, err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInvalidRequest, err))
+		return
+	}
+
+	rc, ok := h.relayContext(c, relay.EndpointImages, req.Model)
+	if !ok {
+		return
+	}
+	resp, err := h.relayer.RelayImageGeneration(relay.WithRelayContext(c.Request.Context(), rc), &req)
+	if !respondRelayResult(c, rc, err) {
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateSpeech 将文本合成为语音，直接返回音频数据
+// POST /v1/audio/speech
+func (h *MediaHandler) CreateSpeech(c *gin.Context) {
+	var req relay.SpeechRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInvalidRequest, err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInvalidRequest, err))
+		return
+	}
+
+	rc, ok := h.relayContext(c, relay.EndpointSpeech, req.Model)
+	if !ok {
+		return
+	}
+	resp, err := h.relayer.RelaySpeech(relay.WithRelayContext(c.Request.Context(), rc), &req)
+	if !respondRelayResult(c, rc, err) {
+		return
+	}
+	c.Data(http.StatusOK, resp.ContentType, resp.Body)
+}
+
+// CreateTranscription 将音频文件转写为文本，音频以流的形式转发给上游
+// POST /v1/audio/transcriptions
+//
+// model 出现在 file 之前时（多数 SDK 的顺序）文件边读边转发，file 之后的字段被忽略；
+// file 在前时先写入临时文件，读完其余字段后再转发，同样不在内存中缓存整个文件。
+func (h *MediaHandler) CreateTranscription(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, relay.MaxTranscriptionFileSize+1<<20)
+	form, err := c.Request.MultipartReader()
+	if err != nil {
+		utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInvalidRequest, err))
+		return
+	}
+
+	req := &relay.TranscriptionRequest{Fields: make(map[string]string)}
+	var file *uploadLimit
+	for file == nil || req.Model == "" {
+		part, err := form.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInvalidRequest, err))
+			return
+		}
+
+		name := part.FormName()
+		if name != "file" {
+			value, err := io.ReadAll(io.LimitReader(part, maxFormFieldSize))
+			if err != nil {
+				utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInvalidRequest, err))
+				return
+			}
+			if name == "model" {
+				req.Model = strings.TrimSpace(string(value))
+			} else if name != "" {
+				req.Fields[name] = string(value)
+			}
+			continue
+		}
+		if file != nil {
+			utils.RespondOpenAIError(c, utils.NewAppError(utils.CodeInvalidRequest, "only one file is allowed"))
+			return
+		}
+
+		req.Filename = part.FileName()
+		file = &uploadLimit{r: part}
+		if req.Model == "" {
+			// model 在 file 之后，先把文件写入临时文件，继续读取其余字段
+			spooled, err := spoolUpload(file)
+			if spooled != nil {
+				defer func() {
+					spooled.Close()
+					os.Remove(spooled.Name())
+				}()
+			}
+			if err != nil {
+				respondUploadError(c, file, err)
+				return
+			}
+			file = &uploadLimit{r: spooled}
+		}
+	}
+	if file != nil {
+		req.File = file
+	}
+	if err := req.Validate(); err != nil {
+		utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInvalidRequest, err))
+		return
+	}
+
+	rc, ok := h.relayContext(c, relay.EndpointTranscriptions, req.Model)
+	if !ok {
+		return
+	}
+	resp, err := h.relayer.RelayTranscription(relay.WithRelayContext(c.Request.Context(), rc), req)
+	if err != nil && file.exceeded {
+		respondUploadError(c, file, err)
+		return
+	}
+	if !respondRelayResult(c, rc, err) {
+		return
+	}
+	c.Data(http.StatusOK, resp.ContentType, resp.Body)
+}
+
+// relayContext 校验 Token 的模型白名单并构造中转上下文，失败时已写入错误响应
+func (h *MediaHandler) relayContext(c *gin.Context, endpoint, modelName string) (*relay.RelayContext, bool) {
+	token := middleware.APITokenFromContext(c)
+	if token != nil && !token.ValidateModel(modelName) {
+		utils.RespondOpenAIError(c, utils.NewAppError(utils.CodeModelNotAllowed, "model not allowed for this token: "+modelName))
+		return nil, false
+	}
+	rc, err := relay.NewRelayContextBuilder(c.GetString("request_id"), endpoint).
+		Token(token).
+		Model(modelName, false).
+		Headers(c.Request.Header).
+		Affinity(c.GetHeader(relay.SessionHeader)).
+		Admission(middleware.AdmissionTiming(c)).
+		Build()
+	if err != nil {
+		utils.RespondOpenAIError(c, err)
+		return nil, false
+	}
+	return rc, true
+}
+
+// respondRelayResult 写入上游请求 ID，中转失败时按 OpenAI 格式返回错误并返回 false
+func respondRelayResult(c *gin.Context, rc *relay.RelayContext, err error) bool {
+	if rc.UpstreamRequestID != "" {
+		c.Header("X-Upstream-Request-ID", rc.UpstreamRequestID)
+	}
+	if err != nil {
+		utils.RespondOpenAIError(c, RelayError(err, rc))
+		return false
+	}
+	return true
+}
+
+// uploadLimit 限制上传文件的大小，超出时读取返回错误并记录
+type uploadLimit struct {
+	r        io.Reader
+	read     int64
+	exceeded bool
+}
+
+var errUploadTooLarge = fmt.Errorf("file exceeds the %d MB limit", relay.MaxTranscriptionFileSize>>20)
+
+func (u *uploadLimit) Read(p []byte) (int, error) {
+	if u.exceeded {
+		return 0, errUploadTooLarge
+	}
+	n, err := u.r.Read(p)
+	u.read += int64(n)
+	if u.read > relay.MaxTranscriptionFileSize {
+		u.exceeded = true
+		return 0, errUploadTooLarge
+	}
+	return n, err
+}
+
+// spoolUpload 将上传文件写入临时文件并回到开头
+func spoolUpload(file io.Reader) (*os.File, error) {
+	tmp, err := os.CreateTemp("", "transcription-*")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(tmp, file); err != nil {
+		return tmp, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return tmp, err
+	}
+	return tmp, nil
+}
+
+// respondUploadError 上传文件超出大小上限时返回 413，否则按请求错误返回
+func respondUploadError(c *gin.Context, file *uploadLimit, err error) {
+	var maxBytes *http.MaxBytesError
+	if file.exceeded || errors.As(err, &maxBytes) {
+		utils.RespondOpenAIError(c, &utils.AppError{Code: utils.CodeInvalidRequest, HTTPStatus: http.StatusRequestEntityTooLarge, Message: errUploadTooLarge.Error()})
+		return
+	}
+	utils.RespondOpenAIError(c, utils.WrapError(utils.CodeInvalidRequest, err))
+}
+
+// RegisterRoutes 注册路由
+func (h *MediaHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.POST("/images/generations", h.CreateImage)
+	r.POST("/audio/speech", h.CreateSpeech)
+	r.POST("/audio/transcriptions", h.CreateTranscription)
+}
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMediaRelayer 记录收到的请求并返回固定结果；转写时读取完整的文件内容
+type fakeMediaRelayer struct {
+	err           error
+	image         *relay.ImageGenerationRequest
+	speech        *relay.SpeechRequest
+	transcription *relay.TranscriptionRequest
+	file          string
+}
+
+func (f *fakeMediaRelayer) RelayImageGeneration(ctx context.Context, req *relay.ImageGenerationRequest) (*relay.ImageGenerationResponse, error) {
+	f.image = req
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &relay.ImageGenerationResponse{Created: 1700000000, Data: []relay.ImageData{{URL: "https://img.example/1"}}}, nil
+}
+
+func (f *fakeMediaRelayer) RelaySpeech(ctx context.Context, req *relay.SpeechRequest) (*relay.MediaResponse, error) {
+	f.speech = req
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &relay.MediaResponse{ContentType: "audio/mpeg", Body: []byte("ID3-fake-mp3")}, nil
+}
+
+func (f *fakeMediaRelayer) RelayTranscription(ctx context.Context, req *relay.TranscriptionRequest) (*relay.MediaResponse, error) {
+	f.transcription = req
+	data, err := io.ReadAll(req.File)
+	if err != nil {
+		return nil, fmt.Errorf("upstream request failed: %w", err)
+	}
+	f.file = string(data)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &relay.MediaResponse{ContentType: "application/json", Body: []byte(`{"text":"hello world"}`)}, nil
+}
+
+func serveMedia(relayer MediaRelayer, path, contentType string, body io.Reader) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewMediaHandler(relayer).RegisterRoutes(r.Group("/v1"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, path, body)
+	req.Header.Set("Content-Type", contentType)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// openAIErrorBody 解析 OpenAI 格式的错误响应
+func openAIErrorBody(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body struct {
+		Error map[string]interface{} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	require.NotNil(t, body.Error)
+	return body.Error
+}
+
+func TestCreateImage(t *testing.T) {
+	relayer := &fakeMediaRelayer{}
+	w := serveMedia(relayer, "/v1/images/generations", "application/json",
+		strings.NewReader(`{"model":"dall-e-3","prompt":"a lighthouse at dawn","n":1,"size":"1024x1024"}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	require.NotNil(t, relayer.image)
+	assert.Equal(t, "1024x1024", relayer.image.Size)
+	var resp relay.ImageGenerationResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	assert.Equal(t, "https://img.example/1", resp.Data[0].URL)
+}
+
+func TestCreateMediaValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"image without prompt", "/v1/images/generations", `{"model":"dall-e-3"}`},
+		{"too many images", "/v1/images/generations", `{"model":"dall-e-3","prompt":"x","n":11}`},
+		{"speech without voice", "/v1/audio/speech", `{"model":"tts-1","input":"hi"}`},
+		{"speech speed out of range", "/v1/audio/speech", `{"model":"tts-1","input":"hi","voice":"alloy","speed":5}`},
+		{"transcription not multipart", "/v1/audio/transcriptions", `{"model":"whisper-1"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relayer := &fakeMediaRelayer{}
+			w := serveMedia(relayer, tt.path, "application/json", strings.NewReader(tt.body))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, "invalid_request_error", openAIErrorBody(t, w)["type"])
+			assert.Nil(t, relayer.image)
+			assert.Nil(t, relayer.speech)
+		})
+	}
+}
+
+func TestCreateSpeech(t *testing.T) {
+	relayer := &fakeMediaRelayer{}
+	w := serveMedia(relayer, "/v1/audio/speech", "application/json",
+		strings.NewReader(`{"model":"tts-1","input":"Hello there","voice":"alloy"}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "audio/mpeg", w.Header().Get("Content-Type"))
+	assert.Equal(t, "ID3-fake-mp3", w.Body.String())
+}
+
+func TestCreateMediaRelayError(t *testing.T) {
+	relayer := &fakeMediaRelayer{err: &relay.UpstreamError{StatusCode: http.StatusBadRequest, Message: "Your request was rejected by the safety system."}}
+	w := serveMedia(relayer, "/v1/images/generations", "application/json",
+		strings.NewReader(`{"model":"dall-e-3","prompt":"something"}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "Your request was rejected by the safety system.", openAIErrorBody(t, w)["message"])
+}
+
+// transcriptionForm 按给定顺序写入表单字段，file 字段写入音频内容
+func transcriptionForm(t *testing.T, fields [][2]string, audio string) (string, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	for _, f := range fields {
+		if f[0] == "file" {
+			part, err := form.CreateFormFile("file", f[1])
+			require.NoError(t, err)
+			part.Write([]byte(audio))
+			continue
+		}
+		require.NoError(t, form.WriteField(f[0], f[1]))
+	}
+	require.NoError(t, form.Close())
+	return form.FormDataContentType(), &buf
+}
+
+func TestCreateTranscription(t *testing.T) {
+	audio := strings.Repeat("RIFF-wave-data-", 1024)
+	tests := []struct {
+		name   string
+		fields [][2]string
+	}{
+		{"model before file", [][2]string{{"model", "whisper-1"}, {"language", "en"}, {"file", "meeting.wav"}}},
+		{"file before model", [][2]string{{"file", "meeting.wav"}, {"language", "en"}, {"model", "whisper-1"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relayer := &fakeMediaRelayer{}
+			contentType, body := transcriptionForm(t, tt.fields, audio)
+			w := serveMedia(relayer, "/v1/audio/transcriptions", contentType, body)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.JSONEq(t, `{"text":"hello world"}`, w.Body.String())
+
+			require.NotNil(t, relayer.transcription)
+			assert.Equal(t, "whisper-1", relayer.transcription.Model)
+			assert.Equal(t, "meeting.wav", relayer.transcription.Filename)
+			assert.Equal(t, map[string]string{"language": "en"}, relayer.transcription.Fields)
+			assert.Equal(t, audio, relayer.file)
+		})
+	}
+}
+
+func TestCreateTranscriptionRejectsMissingFileAndOversize(t *testing.T) {
+	relayer := &fakeMediaRelayer{}
+	contentType, body := transcriptionForm(t, [][2]string{{"model", "whisper-1"}}, "")
+	w := serveMedia(relayer, "/v1/audio/transcriptions", contentType, body)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, openAIErrorBody(t, w)["message"], "file is required")
+
+	// 超出上限的文件在转发过程中被截断，返回 413
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		form.WriteField("model", "whisper-1")
+		part, _ := form.CreateFormFile("file", "long.wav")
+		io.Copy(part, io.LimitReader(zeroReader{}, relay.MaxTranscriptionFileSize+1))
+		pw.CloseWithError(form.Close())
+	}()
+	w = serveMedia(relayer, "/v1/audio/transcriptions", form.FormDataContentType(), pr)
+	pr.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, openAIErrorBody(t, w)["message"], "25 MB")
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
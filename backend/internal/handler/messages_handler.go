@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/adapter"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/server"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+// MessagesRelayer 中转 Anthropic Messages 请求（由 RelayService 实现）
+type MessagesRelayer interface {
+	RelayMessages(ctx context.Context, req *relay.MessagesRequest) (*relay.MessagesResponse, error)
+	RelayMessagesStream(ctx context.Context, req *relay.MessagesRequest, handler func(event *relay.MessagesStreamEvent) error) error
+}
+
+// MessagesHandler Anthropic 原生 Messages 接口，供 Anthropic SDK 直接接入
+//
+// 请求、响应、流式事件与错误都使用 Anthropic 的格式，不经过统一响应包装。
+type MessagesHandler struct {
+	relayer MessagesRelayer
+}
+
+// NewMessagesHandler 创建 Messages Handler
+func NewMessagesHandler(relayer MessagesRelayer) *MessagesHandler {
+	return &MessagesHandler{relayer: relayer}
+}
+
+// CreateMessage 创建消息，stream 为 true 时以 Anthropic 流式事件返回
+// POST /v1/messages
+func (h *MessagesHandler) CreateMessage(c *gin.Context) {
+	var req relay.MessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		messagesErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := req.Validate(); err != nil {
+		messagesErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	token := middleware.APITokenFromContext(c)
+	if token != nil && !token.ValidateModel(req.Model) {
+		messagesErrorResponse(c, http.StatusForbidden, "model not allowed for this token: "+req.Model)
+		return
+	}
+	rc, err := relay.NewRelayContextBuilder(c.GetString("request_id"), relay.EndpointMessages).
+		Token(token).
+		Client(c.ClientIP()).
+		Headers(c.Request.Header).
+		Affinity(c.GetHeader(relay.SessionHeader)).
+		Admission(middleware.AdmissionTiming(c)).
+		Build()
+	if err != nil {
+		messagesErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx := relay.WithRelayContext(c.Request.Context(), rc)
+
+	if req.Stream {
+		h.stream(ctx, c, rc, &req)
+		return
+	}
+
+	resp, err := h.relayer.RelayMessages(ctx, &req)
+	setUpstreamRequestID(c, rc)
+	if err != nil {
+		status, message := messagesErrorStatus(err)
+		messagesErrorResponse(c, status, message)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// stream 输出 Anthropic 流式事件，响应头在首个事件前写入，预检失败时仍可返回 JSON 错误
+func (h *MessagesHandler) stream(ctx context.Context, c *gin.Context, rc *relay.RelayContext, req *relay.MessagesRequest) {
+	w := c.Writer
+	headerSent := false
+	sendHeaders := func() {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		setUpstreamRequestID(c, rc)
+		utils.WriteSSERequestID(c)
+		headerSent = true
+	}
+	writeEvent := func(event string, data interface{}) {
+		payload, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		w.Flush()
+	}
+
+	// 服务关闭时中断流式输出，客户端收到 server_shutdown 事件后重新连接
+	ctx, stop := server.StreamContext(ctx)
+	defer stop()
+
+	err := h.relayer.RelayMessagesStream(ctx, req, func(event *relay.MessagesStreamEvent) error {
+		if !headerSent {
+			sendHeaders()
+		}
+		writeEvent(event.Type, event.Data)
+		return nil
+	})
+	if err == nil {
+		return
+	}
+	if server.Interrupted(ctx) {
+		if !headerSent {
+			sendHeaders()
+		}
+		server.WriteShutdownEvent(w)
+		return
+	}
+
+	status, message := messagesErrorStatus(err)
+	if !headerSent {
+		messagesErrorResponse(c, status, message)
+		return
+	}
+	logger.Error("messages stream error",
+		zap.String("request_id", rc.RequestID),
+		zap.String("upstream_request_id", rc.UpstreamRequestID),
+		zap.Error(err))
+	writeEvent("error", relay.NewAnthropicError(relay.AnthropicErrorType(status), message))
+}
+
+// RegisterRoutes 注册路由
+func (h *MessagesHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.POST("/messages", h.CreateMessage)
+}
+
+// messagesErrorStatus 将中转错误转换为状态码与错误描述，上游错误沿用上游状态码便于 SDK 按类型重试
+func messagesErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, relay.ErrUnsupportedContent):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, billing.ErrInsufficientQuota):
+		return http.StatusPaymentRequired, err.Error()
+	case errors.Is(err, service.ErrQuotaTokenRejected):
+		return http.StatusForbidden, err.Error()
+	}
+
+	var paramErr *adapter.ParamError
+	if errors.As(err, &paramErr) {
+		return http.StatusBadRequest, paramErr.Error()
+	}
+
+	var upstreamErr *relay.UpstreamError
+	if errors.As(err, &upstreamErr) {
+		message := upstreamErr.Message
+		var failoverErr *relay.FailoverError
+		if errors.As(err, &failoverErr) {
+			message = failoverErr.Error()
+		}
+		return upstreamErr.StatusCode, message
+	}
+
+	var interrupted *relay.StreamInterruptedError
+	if errors.As(err, &interrupted) && interrupted.Class == adapter.StreamErrorOverloaded {
+		return 529, err.Error()
+	}
+	return http.StatusInternalServerError, err.Error()
+}
+
+// messagesErrorResponse 返回 Anthropic 格式的错误
+func messagesErrorResponse(c *gin.Context, status int, message string) {
+	c.JSON(status, relay.NewAnthropicError(relay.AnthropicErrorType(status), message))
+}
+
+// setUpstreamRequestID 在响应头中返回上游请求 ID
+func setUpstreamRequestID(c *gin.Context, rc *relay.RelayContext) {
+	if rc.UpstreamRequestID != "" {
+		c.Header("X-Upstream-Request-ID", rc.UpstreamRequestID)
+	}
+}
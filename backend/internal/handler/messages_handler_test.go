@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMessagesRelayer 返回固定响应，流式请求按 Encoder 输出一条文本
+type fakeMessagesRelayer struct {
+	err       error
+	streamErr error // 输出事件后返回的错误
+	reqs      []*relay.MessagesRequest
+}
+
+func (f *fakeMessagesRelayer) RelayMessages(ctx context.Context, req *relay.MessagesRequest) (*relay.MessagesResponse, error) {
+	f.reqs = append(f.reqs, req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return relay.MessagesResponseFromChat(&relay.ChatCompletionResponse{ID: "msg_1", Model: req.Model}), nil
+}
+
+func (f *fakeMessagesRelayer) RelayMessagesStream(ctx context.Context, req *relay.MessagesRequest, handler func(event *relay.MessagesStreamEvent) error) error {
+	f.reqs = append(f.reqs, req)
+	if f.err != nil {
+		return f.err
+	}
+	encoder := relay.NewMessagesStreamEncoder(req.Model, 3)
+	chunk := &relay.ChatCompletionResponse{ID: "msg_1"}
+	chunk.Choices = append(chunk.Choices, struct {
+		Index        int                `json:"index"`
+		Message      relay.ChatMessage  `json:"message"`
+		Delta        *relay.ChatMessage `json:"delta,omitempty"`
+		FinishReason string             `json:"finish_reason"`
+	}{Delta: &relay.ChatMessage{Content: "hi"}})
+	for _, ev := range encoder.Encode(chunk) {
+		if err := handler(ev); err != nil {
+			return err
+		}
+	}
+	if f.streamErr != nil {
+		return f.streamErr
+	}
+	for _, ev := range encoder.Close(nil) {
+		if err := handler(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func serveMessages(relayer MessagesRelayer, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	NewMessagesHandler(relayer).RegisterRoutes(r.Group("/v1"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// sseEvents 解析 SSE 响应中的事件名
+func sseEvents(t *testing.T, body string) []string {
+	t.Helper()
+	var events []string
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		lines := strings.SplitN(block, "\n", 2)
+		require.Len(t, lines, 2, block)
+		name := strings.TrimPrefix(lines[0], "event: ")
+		var data map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &data))
+		assert.Equal(t, name, data["type"])
+		events = append(events, name)
+	}
+	return events
+}
+
+func TestCreateMessage(t *testing.T) {
+	relayer := &fakeMessagesRelayer{}
+	w := serveMessages(relayer, `{"model":"claude-3-5-sonnet","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet","content":[],"stop_reason":"end_turn","stop_sequence":null,"usage":{"input_tokens":0,"output_tokens":0}}`, w.Body.String())
+	require.Len(t, relayer.reqs, 1)
+	assert.Equal(t, 16, relayer.reqs[0].MaxTokens)
+}
+
+func TestCreateMessageStream(t *testing.T) {
+	w := serveMessages(&fakeMessagesRelayer{}, `{"model":"claude-3-5-sonnet","max_tokens":16,"stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, []string{"message_start", "content_block_start", "content_block_delta",
+		"content_block_stop", "message_delta", "message_stop"}, sseEvents(t, w.Body.String()))
+
+	// 已开始输出后的错误以 error 事件结束流
+	w = serveMessages(&fakeMessagesRelayer{streamErr: &relay.StreamInterruptedError{Class: "overloaded", Message: "busy", Partial: true}},
+		`{"model":"claude-3-5-sonnet","max_tokens":16,"stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	events := sseEvents(t, w.Body.String())
+	assert.Equal(t, "error", events[len(events)-1])
+	assert.Contains(t, w.Body.String(), `"type":"overloaded_error"`)
+}
+
+func TestCreateMessageErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		body       string
+		wantStatus int
+		wantType   string
+	}{
+		{"missing max_tokens", nil, `{"model":"claude-3-5-sonnet","messages":[{"role":"user","content":"hi"}]}`, http.StatusBadRequest, "invalid_request_error"},
+		{"malformed json", nil, `{"model":`, http.StatusBadRequest, "invalid_request_error"},
+		{"unsupported content", fmt.Errorf("%w: image blocks require a Claude channel", relay.ErrUnsupportedContent), "", http.StatusBadRequest, "invalid_request_error"},
+		{"quota", fmt.Errorf("%w: token 3 quota exhausted", billing.ErrInsufficientQuota), "", http.StatusPaymentRequired, "billing_error"},
+		{"upstream rate limit", &relay.UpstreamError{StatusCode: http.StatusTooManyRequests, Message: "slow down"}, "", http.StatusTooManyRequests, "rate_limit_error"},
+		{"upstream overloaded", &relay.UpstreamError{StatusCode: 529, Message: "overloaded"}, "", 529, "overloaded_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := tt.body
+			if body == "" {
+				body = `{"model":"claude-3-5-sonnet","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+			}
+			for _, stream := range []bool{false, true} {
+				if stream {
+					body = strings.Replace(body, `"max_tokens"`, `"stream":true,"max_tokens"`, 1)
+				}
+				w := serveMessages(&fakeMessagesRelayer{err: tt.err}, body)
+				assert.Equal(t, tt.wantStatus, w.Code)
+
+				var resp relay.AnthropicError
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, "error", resp.Type)
+				assert.Equal(t, tt.wantType, resp.Error.Type)
+				assert.NotEmpty(t, resp.Error.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/adapter"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+)
+
+// ModelDiscoveryHandler 渠道连通性测试与模型发现Handler
+type ModelDiscoveryHandler struct {
+	channelRepo      *repository.ChannelRepository
+	discoveryService *service.ModelDiscoveryService
+}
+
+// NewModelDiscoveryHandler 创建模型发现Handler
+func NewModelDiscoveryHandler(discoveryService *service.ModelDiscoveryService) *ModelDiscoveryHandler {
+	return &ModelDiscoveryHandler{
+		channelRepo:      repository.NewChannelRepository(),
+		discoveryService: discoveryService,
+	}
+}
+
+// TestChannel 测试渠道连接
+// @Summary 测试渠道连接
+// @Tags channel
+// @Param id path int true "渠道ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /v1/admin/channels/{id}/test [post]
+func (h *ModelDiscoveryHandler) TestChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	channel, err := h.channelRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if channel == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "channel not found"})
+		return
+	}
+
+	adaptor, err := adapter.GetAdapterByChannel(channel)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	checkErr := adaptor.HealthCheck(ctx)
+	latency := time.Since(start).Milliseconds()
+
+	resp := gin.H{
+		"channel_id": id,
+		"status":     "healthy",
+		"latency_ms": latency,
+		"message":    "连接正常",
+	}
+	switch {
+	case checkErr == nil:
+	case errors.Is(checkErr, adapter.ErrUpstreamUnavailable):
+		resp["status"] = "offline"
+		resp["message"] = checkErr.Error()
+	default:
+		resp["status"] = "unhealthy"
+		resp["message"] = checkErr.Error()
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// DiscoverModels 立即从自托管渠道发现模型并同步渠道能力
+// @Summary 发现渠道模型
+// @Tags channel
+// @Param id path int true "渠道ID"
+// @Success 200 {object} service.ModelDiscoveryStatus
+// @Router /v1/admin/channels/{id}/discover [post]
+func (h *ModelDiscoveryHandler) DiscoverModels(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	channel, err := h.channelRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if channel == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "channel not found"})
+		return
+	}
+
+	status, err := h.discoveryService.DiscoverChannel(c.Request.Context(), channel)
+	if status == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil && !errors.Is(err, adapter.ErrUpstreamUnavailable) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "data": status})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": status})
+}
+
+// ListDiscoveryStatus 列出自托管渠道的模型发现状态
+// @Summary 模型发现状态
+// @Tags channel
+// @Success 200 {object} map[string]interface{}
+// @Router /v1/admin/channels/discovery [get]
+func (h *ModelDiscoveryHandler) ListDiscoveryStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": h.discoveryService.ListStatus()})
+}
+
+// RegisterRoutes 注册路由
+func (h *ModelDiscoveryHandler) RegisterRoutes(r *gin.RouterGroup) {
+	channels := r.Group("/channels")
+	{
+		channels.GET("/discovery", h.ListDiscoveryStatus)
+		channels.POST("/:id/test", h.TestChannel)
+		channels.POST("/:id/discover", h.DiscoverModels)
+	}
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// ModelFallbackRuleStore 模型降级规则表的读写（由 ModelFallbackRuleRepository 实现）
+type ModelFallbackRuleStore interface {
+	List(ctx context.Context) ([]*model.ModelFallbackRule, error)
+	GetByID(ctx context.Context, id int) (*model.ModelFallbackRule, error)
+	Create(ctx context.Context, rule *model.ModelFallbackRule) error
+	Update(ctx context.Context, rule *model.ModelFallbackRule) error
+	Delete(ctx context.Context, id int) error
+}
+
+// ModelFallbackRuntime 中转使用的模型降级规则（由 RelayService 实现）
+type ModelFallbackRuntime interface {
+	ReloadFallbackRules(ctx context.Context) error
+}
+
+// ModelFallbackHandler 模型降级规则管理接口，写入规则表后立即刷新中转使用的规则
+type ModelFallbackHandler struct {
+	store   ModelFallbackRuleStore
+	runtime ModelFallbackRuntime
+}
+
+// NewModelFallbackHandler 创建模型降级规则管理Handler
+func NewModelFallbackHandler(store ModelFallbackRuleStore, runtime ModelFallbackRuntime) *ModelFallbackHandler {
+	return &ModelFallbackHandler{store: store, runtime: runtime}
+}
+
+// modelFallbackRuleRequest 创建或更新规则的请求，更新时只修改出现的字段
+type modelFallbackRuleRequest struct {
+	ModelPattern   *string  `json:"model_pattern"`
+	UserGroup      *string  `json:"user_group"`
+	FallbackModels []string `json:"fallback_models"`
+	Enabled        *bool    `json:"enabled"`
+}
+
+// applyTo 将请求中出现的字段写入规则并校验
+func (req *modelFallbackRuleRequest) applyTo(rule *model.ModelFallbackRule) error {
+	if req.ModelPattern != nil {
+		rule.ModelPattern = strings.TrimSpace(*req.ModelPattern)
+	}
+	if req.UserGroup != nil {
+		rule.UserGroup = strings.TrimSpace(*req.UserGroup)
+	}
+	if req.FallbackModels != nil {
+		models := make([]string, 0, len(req.FallbackModels))
+		for _, m := range req.FallbackModels {
+			models = append(models, strings.TrimSpace(m))
+		}
+		rule.FallbackModels = strings.Join(models, ",")
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+
+	check := relay.FallbackRule{ModelPattern: rule.ModelPattern, UserGroup: rule.UserGroup, Models: rule.GetFallbackModels()}
+	return check.Validate()
+}
+
+// ListRules 获取所有降级规则
+// GET /v1/admin/model-fallbacks
+func (h *ModelFallbackHandler) ListRules(c *gin.Context) {
+	rules, err := h.store.List(c.Request.Context())
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"rules": rules}, "")
+}
+
+// CreateRule 创建降级规则，未指定 enabled 时默认启用
+// POST /v1/admin/model-fallbacks
+func (h *ModelFallbackHandler) CreateRule(c *gin.Context) {
+	var req modelFallbackRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	rule := &model.ModelFallbackRule{Enabled: true}
+	if err := req.applyTo(rule); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err := h.store.Create(c.Request.Context(), rule); err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	if !h.reload(c) {
+		return
+	}
+	utils.Created(c, rule, "降级规则已创建")
+}
+
+// UpdateRule 更新降级规则
+// PUT /v1/admin/model-fallbacks/:id
+func (h *ModelFallbackHandler) UpdateRule(c *gin.Context) {
+	rule, ok := h.getRule(c)
+	if !ok {
+		return
+	}
+
+	var req modelFallbackRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err := req.applyTo(rule); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err := h.store.Update(c.Request.Context(), rule); err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	if !h.reload(c) {
+		return
+	}
+	utils.Success(c, rule, "")
+}
+
+// DeleteRule 删除降级规则
+// DELETE /v1/admin/model-fallbacks/:id
+func (h *ModelFallbackHandler) DeleteRule(c *gin.Context) {
+	rule, ok := h.getRule(c)
+	if !ok {
+		return
+	}
+	if err := h.store.Delete(c.Request.Context(), rule.ID); err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	if !h.reload(c) {
+		return
+	}
+	utils.Success(c, nil, "降级规则已删除")
+}
+
+// getRule 获取路径中的规则，ID 无效时返回 400，不存在时返回 404
+func (h *ModelFallbackHandler) getRule(c *gin.Context) (*model.ModelFallbackRule, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		utils.BadRequest(c, "无效的规则 ID")
+		return nil, false
+	}
+	rule, err := h.store.GetByID(c.Request.Context(), id)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return nil, false
+	}
+	if rule == nil {
+		utils.NotFound(c, "降级规则不存在")
+		return nil, false
+	}
+	return rule, true
+}
+
+// reload 刷新中转使用的降级规则，失败时规则表已修改，返回 500 提示稍后重新加载
+func (h *ModelFallbackHandler) reload(c *gin.Context) bool {
+	if err := h.runtime.ReloadFallbackRules(c.Request.Context()); err != nil {
+		utils.InternalError(c, "降级规则已保存，但刷新失败，请稍后重新加载渠道: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// RegisterRoutes 注册路由（需挂载在管理员鉴权的路由组下）
+func (h *ModelFallbackHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/model-fallbacks", h.ListRules)
+	r.POST("/model-fallbacks", h.CreateRule)
+	r.PUT("/model-fallbacks/:id", h.UpdateRule)
+	r.DELETE("/model-fallbacks/:id", h.DeleteRule)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFallbackStore 内存中的降级规则表
+type fakeFallbackStore struct {
+	rules  map[int]*model.ModelFallbackRule
+	nextID int
+}
+
+func (f *fakeFallbackStore) List(ctx context.Context) ([]*model.ModelFallbackRule, error) {
+	rules := make([]*model.ModelFallbackRule, 0, len(f.rules))
+	for _, r := range f.rules {
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func (f *fakeFallbackStore) GetByID(ctx context.Context, id int) (*model.ModelFallbackRule, error) {
+	r, ok := f.rules[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *r
+	return &copied, nil
+}
+
+func (f *fakeFallbackStore) Create(ctx context.Context, rule *model.ModelFallbackRule) error {
+	f.nextID++
+	rule.ID = f.nextID
+	stored := *rule
+	f.rules[rule.ID] = &stored
+	return nil
+}
+
+func (f *fakeFallbackStore) Update(ctx context.Context, rule *model.ModelFallbackRule) error {
+	stored := *rule
+	f.rules[rule.ID] = &stored
+	return nil
+}
+
+func (f *fakeFallbackStore) Delete(ctx context.Context, id int) error {
+	delete(f.rules, id)
+	return nil
+}
+
+// fakeFallbackRuntime 记录降级规则的刷新次数
+type fakeFallbackRuntime struct {
+	reloads int
+}
+
+func (f *fakeFallbackRuntime) ReloadFallbackRules(ctx context.Context) error {
+	f.reloads++
+	return nil
+}
+
+func TestModelFallbackHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &fakeFallbackStore{rules: map[int]*model.ModelFallbackRule{}}
+	runtime := &fakeFallbackRuntime{}
+	r := gin.New()
+	NewModelFallbackHandler(store, runtime).RegisterRoutes(r.Group("/v1/admin"))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/v1/admin/model-fallbacks", `{"model_pattern":"gpt-4*","fallback_models":["gpt-4o-mini"," gpt-3.5-turbo "]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.Contains(t, store.rules, 1)
+	assert.Equal(t, "gpt-4o-mini,gpt-3.5-turbo", store.rules[1].FallbackModels)
+	assert.True(t, store.rules[1].Enabled)
+	assert.Equal(t, 1, runtime.reloads)
+
+	for name, body := range map[string]string{
+		"no pattern":         `{"fallback_models":["gpt-4o-mini"]}`,
+		"no fallbacks":       `{"model_pattern":"gpt-4o"}`,
+		"wildcard target":    `{"model_pattern":"gpt-4o","fallback_models":["gpt-3*"]}`,
+		"falls back to self": `{"model_pattern":"gpt-4o","fallback_models":["gpt-4o"]}`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/v1/admin/model-fallbacks", body).Code, name)
+	}
+	assert.Equal(t, 1, runtime.reloads)
+
+	w = do(http.MethodPut, "/v1/admin/model-fallbacks/1", `{"user_group":"vip","enabled":false}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "vip", store.rules[1].UserGroup)
+	assert.False(t, store.rules[1].Enabled)
+	assert.Equal(t, "gpt-4*", store.rules[1].ModelPattern)
+	assert.Equal(t, 2, runtime.reloads)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/v1/admin/model-fallbacks/2", "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/v1/admin/model-fallbacks/1", "").Code)
+	assert.Empty(t, store.rules)
+	assert.Equal(t, 3, runtime.reloads)
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/oauth"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// OAuthHandler 处理 GitHub、Google 等第三方账号登录与绑定的 HTTP 请求
+type OAuthHandler struct {
+	oauth *service.OAuthService
+}
+
+// NewOAuthHandler 创建第三方登录 Handler
+func NewOAuthHandler(oauthService *service.OAuthService) *OAuthHandler {
+	return &OAuthHandler{oauth: oauthService}
+}
+
+// Login 跳转到提供方授权页
+// GET /api/v1/oauth/:provider/login
+func (h *OAuthHandler) Login(c *gin.Context) {
+	authURL, err := h.oauth.BeginLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		if !respondOAuthError(c, err) {
+			utils.InternalError(c, err.Error())
+		}
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback 提供方回调：登录流程返回登录令牌，绑定流程返回绑定结果
+// GET /api/v1/oauth/:provider/callback
+func (h *OAuthHandler) Callback(c *gin.Context) {
+	if errMsg := c.Query("error"); errMsg != "" {
+		utils.Unauthorized(c, "第三方登录失败: "+errMsg)
+		return
+	}
+
+	resp, err := h.oauth.Callback(c.Request.Context(), c.Param("provider"), c.Query("state"), c.Query("code"), clientInfoFromContext(c))
+	if err != nil {
+		if !respondOAuthError(c, err) {
+			utils.Unauthorized(c, "第三方登录失败: "+err.Error())
+		}
+		return
+	}
+
+	if resp.Bound {
+		utils.Success(c, resp, "绑定成功")
+		return
+	}
+	utils.Success(c, resp, "登录成功")
+}
+
+// ListBindings 列出当前用户绑定的第三方账号及可绑定的提供方
+// GET /api/v1/user/oauth/bindings
+func (h *OAuthHandler) ListBindings(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	bindings, err := h.oauth.Bindings(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"bindings":  bindings,
+		"providers": h.oauth.Providers(),
+	}, "")
+}
+
+// Bind 为当前用户发起绑定，前端跳转到返回的 auth_url 完成授权
+// POST /api/v1/user/oauth/:provider/bind
+func (h *OAuthHandler) Bind(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	authURL, err := h.oauth.BeginBind(c.Request.Context(), c.Param("provider"), userID)
+	if err != nil {
+		if !respondOAuthError(c, err) {
+			utils.InternalError(c, err.Error())
+		}
+		return
+	}
+
+	utils.Success(c, gin.H{"auth_url": authURL}, "")
+}
+
+// Unbind 解除当前用户与第三方账号的绑定
+// DELETE /api/v1/user/oauth/:provider
+func (h *OAuthHandler) Unbind(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	if err := h.oauth.Unbind(c.Request.Context(), userID, c.Param("provider")); err != nil {
+		if !respondOAuthError(c, err) {
+			utils.InternalError(c, err.Error())
+		}
+		return
+	}
+
+	utils.Success(c, nil, "已解除绑定")
+}
+
+// RegisterRoutes 注册无需登录的登录与回调路由
+func (h *OAuthHandler) RegisterRoutes(r *gin.RouterGroup) {
+	group := r.Group("/oauth/:provider")
+	{
+		group.GET("/login", h.Login)
+		group.GET("/callback", h.Callback)
+	}
+}
+
+// RegisterBindingRoutes 注册需要登录的绑定管理路由
+func (h *OAuthHandler) RegisterBindingRoutes(r *gin.RouterGroup) {
+	group := r.Group("/user/oauth")
+	{
+		group.GET("/bindings", h.ListBindings)
+		group.POST("/:provider/bind", h.Bind)
+		group.DELETE("/:provider", h.Unbind)
+	}
+}
+
+// respondOAuthError 将第三方登录的已知错误映射为 HTTP 响应，未知错误返回 false 由调用方处理
+func respondOAuthError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, oauth.ErrUnknownProvider):
+		utils.NotFound(c, "不支持的登录方式")
+	case errors.Is(err, oauth.ErrNotBound):
+		utils.NotFound(c, "尚未绑定该账号")
+	case errors.Is(err, oauth.ErrInvalidState):
+		utils.BadRequest(c, "授权状态无效或已过期，请重新发起")
+	case errors.Is(err, oauth.ErrIdentityConflict):
+		utils.RespondError(c, utils.NewAppError(utils.CodeOAuthIdentityConflict, "该第三方账号已绑定到其他用户"))
+	case errors.Is(err, oauth.ErrAlreadyBound):
+		utils.RespondError(c, utils.NewAppError(utils.CodeConflict, "已绑定该平台的其他账号，请先解除绑定"))
+	case errors.Is(err, oauth.ErrEmailNotVerified), errors.Is(err, oauth.ErrUserDisabled):
+		utils.Error(c, http.StatusForbidden, utils.ErrForbidden, err.Error(), nil)
+	default:
+		return false
+	}
+	return true
+}
+
+// clientInfoFromContext 登录请求的客户端信息，用于记录登录会话
+func clientInfoFromContext(c *gin.Context) service.ClientInfo {
+	return service.ClientInfo{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
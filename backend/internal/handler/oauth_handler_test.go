@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/oauth"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// oauthTestStore 内存中的第三方身份与用户
+type oauthTestStore struct {
+	mu         sync.Mutex
+	users      map[int]*model.User
+	identities []*model.UserIdentity
+}
+
+func (s *oauthTestStore) FindIdentity(ctx context.Context, issuer, subject string) (*model.UserIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, identity := range s.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *oauthTestStore) ListIdentities(ctx context.Context, userID int) ([]*model.UserIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var identities []*model.UserIdentity
+	for _, identity := range s.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+func (s *oauthTestStore) CreateIdentity(ctx context.Context, identity *model.UserIdentity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.identities = append(s.identities, identity)
+	return nil
+}
+
+func (s *oauthTestStore) DeleteIdentity(ctx context.Context, userID int, issuer string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, identity := range s.identities {
+		if identity.UserID == userID && identity.Issuer == issuer {
+			s.identities = append(s.identities[:i], s.identities[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *oauthTestStore) FindUserByID(ctx context.Context, id int) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.users[id], nil
+}
+
+func (s *oauthTestStore) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *oauthTestStore) CreateUser(ctx context.Context, user *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user.ID = len(s.users) + 1
+	s.users[user.ID] = user
+	return nil
+}
+
+func (s *oauthTestStore) ActivateUser(ctx context.Context, user *model.User) error {
+	return nil
+}
+
+func (s *oauthTestStore) Update(ctx context.Context, user *model.User) error {
+	return nil
+}
+
+// newStubGoogle 模拟 Google 的令牌与用户信息接口，每个授权码对应一个 Google 账号
+func newStubGoogle(t *testing.T, accounts map[string]string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if _, ok := accounts[r.PostForm.Get("code")]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": r.PostForm.Get("code")})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		code := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"sub":            accounts[code],
+			"email":          accounts[code] + "@gmail.example",
+			"email_verified": true,
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOAuthHandlerBindConflict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtCfg := &config.JWTConfig{Secret: "oauth-secret", ExpireHours: 2, RefreshExpireDays: 7}
+	utils.InitJWT(jwtCfg)
+
+	store := &oauthTestStore{users: map[int]*model.User{
+		1: {ID: 1, Username: "alice", Email: "alice@example.com", Status: model.UserStatusActive},
+		2: {ID: 2, Username: "bob", Email: "bob@example.com", Status: model.UserStatusActive},
+	}}
+	stub := newStubGoogle(t, map[string]string{"alice-code": "g-1", "bob-code": "g-1"})
+	google := oauth.Google("google-client", "google-secret")
+	google.Endpoints = oauth.Endpoints{
+		AuthURL:     stub.URL + "/auth",
+		TokenURL:    stub.URL + "/token",
+		UserInfoURL: stub.URL + "/userinfo",
+	}
+	manager := oauth.NewManager(store, oauth.NewMemoryStateStore(), "https://api.example.com", stub.Client())
+	manager.Register(google)
+
+	sessions := service.NewSessionService(&memoryUserSessions{},
+		sessionTestUsers{1: store.users[1], 2: store.users[2]}, jwtCfg)
+	h := NewOAuthHandler(service.NewOAuthService(manager, sessions, store))
+
+	r := gin.New()
+	api := r.Group("/api/v1")
+	h.RegisterRoutes(api)
+	h.RegisterBindingRoutes(r.Group("/api/v1", middleware.AuthMiddleware([]byte(jwtCfg.Secret))))
+
+	call := func(method, path string, user *model.User) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		if user != nil {
+			token, err := utils.GenerateAccessToken(user.ID, user.Username, user.Role, 2)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	// bind 发起绑定并以给定授权码完成回调
+	bind := func(user *model.User, code string) *httptest.ResponseRecorder {
+		w := call(http.MethodPost, "/api/v1/user/oauth/google/bind", user)
+		var started struct {
+			AuthURL string `json:"auth_url"`
+		}
+		require.NoError(t, json.Unmarshal(mustData(t, w), &started))
+		u, err := url.Parse(started.AuthURL)
+		require.NoError(t, err)
+		return call(http.MethodGet, "/api/v1/oauth/google/callback?state="+u.Query().Get("state")+"&code="+code, nil)
+	}
+
+	w := bind(store.users[1], "alice-code")
+	var bound struct {
+		Bound       bool   `json:"bound"`
+		AccessToken string `json:"access_token"`
+	}
+	require.NoError(t, json.Unmarshal(mustData(t, w), &bound))
+	assert.True(t, bound.Bound)
+	assert.Empty(t, bound.AccessToken, "binding does not issue new tokens")
+
+	w = bind(store.users[2], "bob-code")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var failed utils.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &failed))
+	require.NotNil(t, failed.Error)
+	assert.Equal(t, utils.ErrOAuthIdentityConflict, failed.Error.Code)
+	assert.Equal(t, utils.CodeOAuthIdentityConflict, failed.Error.ErrorCode)
+
+	w = call(http.MethodGet, "/api/v1/user/oauth/bindings", store.users[1])
+	var listed struct {
+		Bindings []oauth.Binding `json:"bindings"`
+	}
+	require.NoError(t, json.Unmarshal(mustData(t, w), &listed))
+	require.Len(t, listed.Bindings, 1)
+	assert.Equal(t, oauth.ProviderGoogle, listed.Bindings[0].Provider)
+
+	assert.Equal(t, http.StatusOK, call(http.MethodDelete, "/api/v1/user/oauth/google", store.users[1]).Code)
+	assert.Equal(t, http.StatusNotFound, call(http.MethodDelete, "/api/v1/user/oauth/google", store.users[1]).Code)
+	assert.Equal(t, http.StatusNotFound, call(http.MethodGet, "/api/v1/oauth/github/login", nil).Code)
+
+	// 解除绑定后，该 Google 账号的邮箱不属于任何用户，登录时即时开通新账号
+	w = call(http.MethodGet, "/api/v1/oauth/google/login", nil)
+	require.Equal(t, http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	w = call(http.MethodGet, "/api/v1/oauth/google/callback?state="+u.Query().Get("state")+"&code=alice-code", nil)
+	var login struct {
+		AccessToken string `json:"access_token"`
+		Provisioned bool   `json:"provisioned"`
+	}
+	require.NoError(t, json.Unmarshal(mustData(t, w), &login))
+	assert.NotEmpty(t, login.AccessToken)
+	assert.True(t, login.Provisioned)
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// PluginHandler MCP 插件注册与调用接口
+type PluginHandler struct {
+	pluginService *service.PluginService
+}
+
+// NewPluginHandler 创建插件 Handler
+func NewPluginHandler(pluginService *service.PluginService) *PluginHandler {
+	return &PluginHandler{pluginService: pluginService}
+}
+
+// ExecuteToolRequest 工具调用请求
+type ExecuteToolRequest struct {
+	ToolName  string                 `json:"tool_name" binding:"required"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// RegisterPlugin 注册插件
+// POST /api/v1/plugins
+func (h *PluginHandler) RegisterPlugin(c *gin.Context) {
+	var req service.RegisterPluginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	userID, _ := currentUserID(c)
+	plugin, err := h.pluginService.RegisterPlugin(c.Request.Context(), userID, &req)
+	if err != nil {
+		pluginError(c, err)
+		return
+	}
+
+	utils.Success(c, plugin, "插件注册成功")
+}
+
+// ListPlugins 获取插件列表及运行状态
+// GET /api/v1/plugins
+func (h *PluginHandler) ListPlugins(c *gin.Context) {
+	utils.Success(c, h.pluginService.ListPlugins(), "")
+}
+
+// GetPlugin 获取插件状态与工具列表
+// GET /api/v1/plugins/:id
+func (h *PluginHandler) GetPlugin(c *gin.Context) {
+	plugin, err := h.pluginService.GetPlugin(c.Param("id"))
+	if err != nil {
+		pluginError(c, err)
+		return
+	}
+
+	utils.Success(c, plugin, "")
+}
+
+// StartPlugin 启动插件
+// POST /api/v1/plugins/:id/start
+func (h *PluginHandler) StartPlugin(c *gin.Context) {
+	plugin, err := h.pluginService.StartPlugin(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		pluginError(c, err)
+		return
+	}
+
+	utils.Success(c, plugin, "插件已启动")
+}
+
+// StopPlugin 停止插件
+// POST /api/v1/plugins/:id/stop
+func (h *PluginHandler) StopPlugin(c *gin.Context) {
+	plugin, err := h.pluginService.StopPlugin(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		pluginError(c, err)
+		return
+	}
+
+	utils.Success(c, plugin, "插件已停止")
+}
+
+// ExecuteTool 调用插件工具，工具本身返回的错误以 502 返回
+// POST /api/v1/plugins/:id/execute
+func (h *PluginHandler) ExecuteTool(c *gin.Context) {
+	var req ExecuteToolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	result, err := h.pluginService.ExecuteTool(c.Request.Context(), c.Param("id"), req.ToolName, req.Arguments)
+	if err != nil {
+		if errors.Is(err, service.ErrPluginNotFound) || errors.Is(err, service.ErrPluginNotRunning) {
+			pluginError(c, err)
+			return
+		}
+		utils.Error(c, http.StatusBadGateway, utils.ErrInternal, err.Error(), gin.H{"tool_name": req.ToolName})
+		return
+	}
+
+	utils.Success(c, gin.H{"tool_name": req.ToolName, "result": result}, "")
+}
+
+// pluginError 将插件服务的错误映射为 HTTP 状态码
+func pluginError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrPluginNotFound):
+		utils.NotFound(c, err.Error())
+	case errors.Is(err, service.ErrInvalidPluginConfig):
+		utils.BadRequest(c, err.Error())
+	case errors.Is(err, service.ErrPluginExists), errors.Is(err, service.ErrPluginNotRunning):
+		utils.RespondError(c, utils.WrapError(utils.CodeConflict, err))
+	default:
+		utils.InternalError(c, err.Error())
+	}
+}
+
+// RegisterRoutes 注册路由（需挂载在管理员鉴权的路由组下）
+func (h *PluginHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.POST("/plugins", h.RegisterPlugin)
+	r.GET("/plugins", h.ListPlugins)
+	r.GET("/plugins/:id", h.GetPlugin)
+	r.POST("/plugins/:id/start", h.StartPlugin)
+	r.POST("/plugins/:id/stop", h.StopPlugin)
+	r.POST("/plugins/:id/execute", h.ExecuteTool)
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryPluginStore 内存中的插件注册表
+type memoryPluginStore struct {
+	mu      sync.Mutex
+	plugins []*model.Plugin
+}
+
+func (s *memoryPluginStore) Create(ctx context.Context, plugin *model.Plugin) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plugin.ID = len(s.plugins) + 1
+	copied := *plugin
+	s.plugins = append(s.plugins, &copied)
+	return nil
+}
+
+func (s *memoryPluginStore) FindAll(ctx context.Context) ([]*model.Plugin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plugins := make([]*model.Plugin, len(s.plugins))
+	for i, p := range s.plugins {
+		copied := *p
+		plugins[i] = &copied
+	}
+	return plugins, nil
+}
+
+func (s *memoryPluginStore) UpdateEnabled(ctx context.Context, name string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.plugins {
+		if p.Name == name {
+			p.Enabled = enabled
+		}
+	}
+	return nil
+}
+
+// fakeHTTPPlugin 提供 /health、/tools 与 /call 的 HTTP MCP 插件
+func fakeHTTPPlugin(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/tools":
+			w.Write([]byte(`[{"name": "echo", "description": "Echo the text back"}]`))
+		case "/call":
+			var msg tools.MCPMessage
+			json.NewDecoder(r.Body).Decode(&msg)
+			resp := tools.MCPMessage{Type: "tool_result", ID: msg.ID}
+			if msg.ToolCall.ToolName == "echo" {
+				resp.Result = msg.ToolCall.Arguments["text"]
+			} else {
+				resp.Error = "unknown tool " + msg.ToolCall.ToolName
+			}
+			json.NewEncoder(w).Encode(resp)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// pluginTestSecret 测试用 JWT 密钥
+var pluginTestSecret = []byte("plugin-test-secret")
+
+// pluginToken 签发指定角色的访问令牌
+func pluginToken(t *testing.T, role int) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 1,
+		"role":    role,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	s, err := token.SignedString(pluginTestSecret)
+	require.NoError(t, err)
+	return s
+}
+
+// newPluginRouter 按插件服务的方式挂载路由：鉴权后仅管理员可访问
+func newPluginRouter(store service.PluginStore) (*gin.Engine, *tools.MCPPluginManager) {
+	gin.SetMode(gin.TestMode)
+	manager := tools.NewMCPPluginManager()
+
+	r := gin.New()
+	v1 := r.Group("/api/v1")
+	v1.Use(middleware.AuthMiddleware(pluginTestSecret), middleware.RoleMiddleware(100))
+	NewPluginHandler(service.NewPluginService(store, manager)).RegisterRoutes(v1)
+	return r, manager
+}
+
+// pluginRequest 以管理员身份发送请求并解析响应
+func pluginRequest(t *testing.T, r *gin.Engine, method, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+pluginToken(t, 100))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Code, resp
+}
+
+func TestPluginHandlerLifecycle(t *testing.T) {
+	server := fakeHTTPPlugin(t)
+	store := &memoryPluginStore{}
+	r, manager := newPluginRouter(store)
+	defer manager.StopPlugin("echo-http")
+
+	code, resp := pluginRequest(t, r, http.MethodPost, "/api/v1/plugins", map[string]interface{}{
+		"name": "echo-http", "mode": "http", "url": server.URL, "timeout_seconds": 5,
+	})
+	require.Equal(t, http.StatusOK, code, resp)
+	data := resp["data"].(map[string]interface{})
+	assert.Equal(t, "stopped", data["status"].(map[string]interface{})["state"])
+	require.NotNil(t, store.plugins[0].CreatedBy)
+	assert.Equal(t, 1, *store.plugins[0].CreatedBy)
+
+	code, _ = pluginRequest(t, r, http.MethodPost, "/api/v1/plugins", map[string]interface{}{
+		"name": "echo-http", "mode": "http", "url": server.URL,
+	})
+	assert.Equal(t, http.StatusConflict, code)
+
+	// 未启动时不能调用
+	code, _ = pluginRequest(t, r, http.MethodPost, "/api/v1/plugins/echo-http/execute", map[string]interface{}{"tool_name": "echo"})
+	assert.Equal(t, http.StatusConflict, code)
+
+	code, resp = pluginRequest(t, r, http.MethodPost, "/api/v1/plugins/echo-http/start", nil)
+	require.Equal(t, http.StatusOK, code, resp)
+	data = resp["data"].(map[string]interface{})
+	assert.Equal(t, true, data["enabled"])
+	assert.Equal(t, "running", data["status"].(map[string]interface{})["state"])
+	require.Len(t, data["tools"], 1)
+	assert.True(t, store.plugins[0].Enabled, "start should be persisted")
+
+	code, resp = pluginRequest(t, r, http.MethodGet, "/api/v1/plugins", nil)
+	require.Equal(t, http.StatusOK, code)
+	list := resp["data"].([]interface{})
+	require.Len(t, list, 1)
+	assert.Equal(t, float64(1), list[0].(map[string]interface{})["status"].(map[string]interface{})["tool_count"])
+
+	code, resp = pluginRequest(t, r, http.MethodPost, "/api/v1/plugins/echo-http/execute", map[string]interface{}{
+		"tool_name": "echo", "arguments": map[string]interface{}{"text": "hello"},
+	})
+	require.Equal(t, http.StatusOK, code, resp)
+	assert.Equal(t, "hello", resp["data"].(map[string]interface{})["result"])
+
+	code, resp = pluginRequest(t, r, http.MethodPost, "/api/v1/plugins/echo-http/execute", map[string]interface{}{"tool_name": "missing"})
+	assert.Equal(t, http.StatusBadGateway, code)
+	assert.Contains(t, resp["error"].(map[string]interface{})["message"], "unknown tool")
+
+	code, resp = pluginRequest(t, r, http.MethodPost, "/api/v1/plugins/echo-http/stop", nil)
+	require.Equal(t, http.StatusOK, code, resp)
+	assert.Equal(t, false, resp["data"].(map[string]interface{})["enabled"])
+	assert.False(t, store.plugins[0].Enabled)
+}
+
+func TestPluginHandlerValidation(t *testing.T) {
+	r, _ := newPluginRouter(&memoryPluginStore{})
+
+	cases := []map[string]interface{}{
+		{"name": "no-command", "mode": "stdio"},
+		{"name": "bad-url", "mode": "http", "url": "ftp://example.com"},
+		{"name": "relative", "mode": "http", "url": "/plugin"},
+		{"name": "bad mode", "mode": "grpc"},
+		{"name": "../escape", "mode": "stdio", "command": "node"},
+		{"mode": "stdio", "command": "node"},
+	}
+	for _, body := range cases {
+		code, _ := pluginRequest(t, r, http.MethodPost, "/api/v1/plugins", body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+	}
+
+	code, _ := pluginRequest(t, r, http.MethodGet, "/api/v1/plugins/missing", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = pluginRequest(t, r, http.MethodPost, "/api/v1/plugins/missing/start", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestPluginHandlerRequiresAdmin(t *testing.T) {
+	r, _ := newPluginRouter(&memoryPluginStore{})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/plugins", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/plugins", bytes.NewReader([]byte(`{"name":"x","mode":"stdio","command":"sh"}`)))
+	req.Header.Set("Authorization", "Bearer "+pluginToken(t, 1))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// ProjectHandler 处理中转项目相关的 HTTP 请求
+type ProjectHandler struct {
+	projectService *service.ProjectService
+}
+
+// NewProjectHandler 创建新的项目 Handler
+func NewProjectHandler() *ProjectHandler {
+	return &ProjectHandler{
+		projectService: service.NewProjectService(),
+	}
+}
+
+// CreateProject 创建项目
+// POST /api/v1/projects
+func (h *ProjectHandler) CreateProject(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	var req service.ProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	project, err := h.projectService.CreateProject(c.Request.Context(), userID, &req)
+	if err != nil {
+		handleProjectError(c, err)
+		return
+	}
+
+	utils.Success(c, project, "项目创建成功")
+}
+
+// ListProjects 获取当前用户的项目列表
+// GET /api/v1/projects
+func (h *ProjectHandler) ListProjects(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	projects, err := h.projectService.ListProjects(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, projects, "")
+}
+
+// GetProject 获取项目详情
+// GET /api/v1/projects/:id
+func (h *ProjectHandler) GetProject(c *gin.Context) {
+	userID, id, ok := projectParams(c)
+	if !ok {
+		return
+	}
+
+	project, err := h.projectService.GetProject(c.Request.Context(), userID, id)
+	if err != nil {
+		handleProjectError(c, err)
+		return
+	}
+
+	utils.Success(c, project, "")
+}
+
+// UpdateProject 更新项目配置
+// PUT /api/v1/projects/:id
+func (h *ProjectHandler) UpdateProject(c *gin.Context) {
+	userID, id, ok := projectParams(c)
+	if !ok {
+		return
+	}
+
+	var req service.ProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	project, err := h.projectService.UpdateProject(c.Request.Context(), userID, id, &req)
+	if err != nil {
+		handleProjectError(c, err)
+		return
+	}
+
+	utils.Success(c, project, "项目更新成功")
+}
+
+// DeleteProject 删除项目
+// DELETE /api/v1/projects/:id
+func (h *ProjectHandler) DeleteProject(c *gin.Context) {
+	userID, id, ok := projectParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.projectService.DeleteProject(c.Request.Context(), userID, id); err != nil {
+		handleProjectError(c, err)
+		return
+	}
+
+	utils.Success(c, nil, "项目删除成功")
+}
+
+// CreateProjectToken 创建绑定到项目的 API Token
+// POST /api/v1/projects/:id/tokens
+func (h *ProjectHandler) CreateProjectToken(c *gin.Context) {
+	userID, id, ok := projectParams(c)
+	if !ok {
+		return
+	}
+
+	var req service.CreateProjectTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	token, err := h.projectService.CreateProjectToken(c.Request.Context(), userID, id, &req)
+	if err != nil {
+		handleProjectError(c, err)
+		return
+	}
+
+	utils.Success(c, token, "Token 创建成功，请妥善保存，Key 只显示一次")
+}
+
+// ListProjectTokens 列出项目的 API Token
+// GET /api/v1/projects/:id/tokens
+func (h *ProjectHandler) ListProjectTokens(c *gin.Context) {
+	userID, id, ok := projectParams(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.projectService.ListProjectTokens(c.Request.Context(), userID, id)
+	if err != nil {
+		handleProjectError(c, err)
+		return
+	}
+
+	utils.Success(c, tokens, "")
+}
+
+// GetProjectUsage 获取项目用量
+// GET /api/v1/projects/:id/usage
+func (h *ProjectHandler) GetProjectUsage(c *gin.Context) {
+	userID, id, ok := projectParams(c)
+	if !ok {
+		return
+	}
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+
+	usage, err := h.projectService.GetProjectUsage(c.Request.Context(), userID, id, days)
+	if err != nil {
+		handleProjectError(c, err)
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"project_id": id,
+		"days":       days,
+		"models":     usage,
+	}, "")
+}
+
+// RegisterRoutes 注册路由
+func (h *ProjectHandler) RegisterRoutes(r *gin.RouterGroup) {
+	projects := r.Group("/projects")
+	{
+		projects.POST("", h.CreateProject)
+		projects.GET("", h.ListProjects)
+		projects.GET("/:id", h.GetProject)
+		projects.PUT("/:id", h.UpdateProject)
+		projects.DELETE("/:id", h.DeleteProject)
+		projects.POST("/:id/tokens", h.CreateProjectToken)
+		projects.GET("/:id/tokens", h.ListProjectTokens)
+		projects.GET("/:id/usage", h.GetProjectUsage)
+	}
+}
+
+// projectParams 解析当前用户与路径中的项目 ID，失败时已写入响应
+func projectParams(c *gin.Context) (int, int, bool) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return 0, 0, false
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return 0, 0, false
+	}
+
+	return userID, id, true
+}
+
+// handleProjectError 将项目错误映射为 HTTP 响应
+func handleProjectError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProjectNotFound):
+		utils.NotFound(c, "项目不存在")
+	case errors.Is(err, service.ErrProjectPermissionDenied):
+		utils.Forbidden(c)
+	case errors.Is(err, service.ErrProjectInvalidKB):
+		utils.BadRequest(c, err.Error())
+	default:
+		utils.InternalError(c, err.Error())
+	}
+}
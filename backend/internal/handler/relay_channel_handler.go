@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+// ChannelStore 渠道表的读写（由 ChannelService 实现）
+type ChannelStore interface {
+	Create(ctx context.Context, channel *model.Channel) error
+	Update(ctx context.Context, channel *model.Channel) error
+	Delete(ctx context.Context, id int) error
+	GetByID(ctx context.Context, id int) (*model.Channel, error)
+}
+
+// ChannelRuntime 中转选择器中的渠道（由 RelayService 实现）
+type ChannelRuntime interface {
+	ReloadChannels(ctx context.Context) error
+	TripCircuitBreaker(channelID int)
+	ResetCircuitBreaker(channelID int) error
+}
+
+// RelayChannelHandler 中转服务的渠道管理接口
+//
+// 写入渠道表后立即刷新中转的渠道缓存，修改无需重启即可生效；禁用或删除的渠道同时打开断路器，
+// 不再被选中。响应中的密钥与疑似密钥的请求头均已掩码。
+type RelayChannelHandler struct {
+	store     ChannelStore
+	runtime   ChannelRuntime
+	abilities service.ChannelAbilityService // 可为 nil
+}
+
+// NewRelayChannelHandler 创建中转渠道管理Handler，abilities 为 nil 时不同步渠道能力表
+func NewRelayChannelHandler(store ChannelStore, runtime ChannelRuntime, abilities service.ChannelAbilityService) *RelayChannelHandler {
+	return &RelayChannelHandler{store: store, runtime: runtime, abilities: abilities}
+}
+
+// createRelayChannelRequest 与 CreateChannelRequest 相同，未指定 enabled 时默认启用
+type createRelayChannelRequest struct {
+	CreateChannelRequest
+	Enabled *bool `json:"enabled"`
+}
+
+// CreateChannel 创建渠道
+// POST /v1/admin/channels
+func (h *RelayChannelHandler) CreateChannel(c *gin.Context) {
+	var req createRelayChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	req.CreateChannelRequest.Enabled = req.Enabled == nil || *req.Enabled
+
+	channel, err := req.toChannel()
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err := service.ValidateRelayChannel(channel); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err := h.store.Create(c.Request.Context(), channel); err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+	if !channel.Enabled {
+		channel.Status = model.ChannelStatusDisabled
+		if err := h.store.Update(c.Request.Context(), channel); err != nil {
+			h.respondStoreError(c, err)
+			return
+		}
+	}
+	h.syncAbilities(c.Request.Context(), channel)
+
+	if !h.reload(c) {
+		return
+	}
+	utils.Created(c, service.RedactChannel(channel), "渠道已创建")
+}
+
+// UpdateChannel 更新渠道，只修改请求中出现的字段
+// PUT /v1/admin/channels/:id
+func (h *RelayChannelHandler) UpdateChannel(c *gin.Context) {
+	id, ok := channelIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	channel, ok := h.getChannel(c, id)
+	if !ok {
+		return
+	}
+	wasEnabled := channel.Enabled
+	if err := req.applyTo(channel); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	// 只修改 status 时同样视为禁用，渠道加载只读取 enabled
+	if channel.Status != model.ChannelStatusEnabled {
+		channel.Enabled = false
+	}
+	if err := service.ValidateRelayChannel(channel); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err := h.store.Update(c.Request.Context(), channel); err != nil {
+		h.respondStoreError(c, err)
+		return
+	}
+	if req.SupportModels != nil {
+		h.syncAbilities(c.Request.Context(), channel)
+	}
+
+	if !h.reload(c) {
+		return
+	}
+	switch {
+	case !channel.Enabled:
+		h.runtime.TripCircuitBreaker(channel.ID)
+	case !wasEnabled:
+		// 重新启用的渠道不受禁用时打开的断路器影响
+		h.runtime.ResetCircuitBreaker(channel.ID)
+	}
+	utils.Success(c, service.RedactChannel(channel), "")
+}
+
+// DeleteChannel 删除渠道（软删除）
+// DELETE /v1/admin/channels/:id
+func (h *RelayChannelHandler) DeleteChannel(c *gin.Context) {
+	id, ok := channelIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := h.getChannel(c, id); !ok {
+		return
+	}
+
+	if err := h.store.Delete(c.Request.Context(), id); err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+	if h.abilities != nil {
+		if err := h.abilities.DeleteByChannel(c.Request.Context(), id); err != nil {
+			logger.Ctx(c.Request.Context()).Warn("failed to delete channel abilities", zap.Int("channel_id", id), zap.Error(err))
+		}
+	}
+
+	if !h.reload(c) {
+		return
+	}
+	h.runtime.TripCircuitBreaker(id)
+	utils.Success(c, nil, "渠道已删除")
+}
+
+// ReloadChannels 从渠道表重新加载中转的渠道缓存（直接修改渠道表或自动刷新失败后使用）
+// POST /v1/admin/channels/reload
+func (h *RelayChannelHandler) ReloadChannels(c *gin.Context) {
+	if err := h.runtime.ReloadChannels(c.Request.Context()); err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+	utils.Success(c, nil, "渠道已重新加载")
+}
+
+// channelIDParam 解析路径中的渠道 ID，无效时返回 400
+func channelIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		utils.BadRequest(c, "无效的渠道 ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// getChannel 获取渠道，不存在时返回 404
+func (h *RelayChannelHandler) getChannel(c *gin.Context, id int) (*model.Channel, bool) {
+	channel, err := h.store.GetByID(c.Request.Context(), id)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return nil, false
+	}
+	if channel == nil {
+		utils.NotFound(c, "渠道不存在")
+		return nil, false
+	}
+	return channel, true
+}
+
+// reload 刷新中转的渠道缓存，失败时渠道表已修改，返回 500 提示稍后手动重新加载
+func (h *RelayChannelHandler) reload(c *gin.Context) bool {
+	if err := h.runtime.ReloadChannels(c.Request.Context()); err != nil {
+		utils.InternalError(c, "渠道已保存，但刷新渠道缓存失败，请稍后重新加载: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// syncAbilities 同步渠道能力表，失败只记录日志
+func (h *RelayChannelHandler) syncAbilities(ctx context.Context, channel *model.Channel) {
+	if h.abilities == nil {
+		return
+	}
+	if err := h.abilities.SyncFromChannel(ctx, channel); err != nil {
+		logger.Ctx(ctx).Warn("failed to sync channel abilities", zap.Int("channel_id", channel.ID), zap.Error(err))
+	}
+}
+
+// respondStoreError 配置错误返回 400，其余返回 500
+func (h *RelayChannelHandler) respondStoreError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrInvalidChannelConfig) {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	utils.InternalError(c, err.Error())
+}
+
+// RegisterRoutes 注册路由（需挂载在管理员鉴权的路由组下）
+func (h *RelayChannelHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.POST("/channels", h.CreateChannel)
+	r.PUT("/channels/:id", h.UpdateChannel)
+	r.DELETE("/channels/:id", h.DeleteChannel)
+	r.POST("/channels/reload", h.ReloadChannels)
+}
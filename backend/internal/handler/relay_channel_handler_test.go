@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChannelStore 内存中的渠道表
+type fakeChannelStore struct {
+	channels map[int]*model.Channel
+	nextID   int
+}
+
+func (f *fakeChannelStore) Create(ctx context.Context, channel *model.Channel) error {
+	f.nextID++
+	channel.ID = f.nextID
+	channel.Status = model.ChannelStatusEnabled
+	stored := *channel
+	f.channels[channel.ID] = &stored
+	return nil
+}
+
+func (f *fakeChannelStore) Update(ctx context.Context, channel *model.Channel) error {
+	stored := *channel
+	f.channels[channel.ID] = &stored
+	return nil
+}
+
+func (f *fakeChannelStore) Delete(ctx context.Context, id int) error {
+	delete(f.channels, id)
+	return nil
+}
+
+func (f *fakeChannelStore) GetByID(ctx context.Context, id int) (*model.Channel, error) {
+	ch, ok := f.channels[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *ch
+	return &copied, nil
+}
+
+// fakeChannelRuntime 记录缓存刷新与断路器操作
+type fakeChannelRuntime struct {
+	reloads int
+	tripped []int
+	reset   []int
+}
+
+func (f *fakeChannelRuntime) ReloadChannels(ctx context.Context) error {
+	f.reloads++
+	return nil
+}
+
+func (f *fakeChannelRuntime) TripCircuitBreaker(channelID int) {
+	f.tripped = append(f.tripped, channelID)
+}
+
+func (f *fakeChannelRuntime) ResetCircuitBreaker(channelID int) error {
+	f.reset = append(f.reset, channelID)
+	return nil
+}
+
+func TestRelayChannelHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &fakeChannelStore{channels: map[int]*model.Channel{}}
+	runtime := &fakeChannelRuntime{}
+	r := gin.New()
+	NewRelayChannelHandler(store, runtime, nil).RegisterRoutes(r.Group("/v1/admin"))
+
+	do := func(method, path, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		var resp struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp.Data
+	}
+
+	t.Run("create writes through, reloads and masks the key", func(t *testing.T) {
+		w, data := do(http.MethodPost, "/v1/admin/channels",
+			`{"name":"openai-main","type":"openai","base_url":"https://api.openai.com","api_keys":"sk-secret-123456","support_models":"gpt-4o,gpt-4o-mini"}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		assert.NotContains(t, w.Body.String(), "sk-secret-123456")
+		assert.Equal(t, float64(1), data["id"])
+		assert.Equal(t, true, data["enabled"])
+		assert.Equal(t, 1, runtime.reloads)
+		require.Contains(t, store.channels, 1)
+		assert.Equal(t, "sk-secret-123456", store.channels[1].PrimaryKey())
+	})
+
+	t.Run("create validation", func(t *testing.T) {
+		for name, body := range map[string]string{
+			"unknown type":    `{"name":"x","type":"unknown","api_keys":"sk-1","support_models":"m"}`,
+			"invalid base":    `{"name":"x","type":"openai","base_url":"not a url","api_keys":"sk-1","support_models":"m"}`,
+			"no models":       `{"name":"x","type":"openai","api_keys":"sk-1","support_models":" , "}`,
+			"missing api key": `{"name":"x","type":"openai","support_models":"m"}`,
+		} {
+			w, _ := do(http.MethodPost, "/v1/admin/channels", body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+		}
+		assert.Len(t, store.channels, 1)
+		assert.Equal(t, 1, runtime.reloads)
+	})
+
+	t.Run("disable opens the circuit breaker", func(t *testing.T) {
+		w, data := do(http.MethodPut, "/v1/admin/channels/1", `{"enabled":false}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, false, data["enabled"])
+		assert.False(t, store.channels[1].Enabled)
+		assert.Equal(t, []int{1}, runtime.tripped)
+		assert.Equal(t, 2, runtime.reloads)
+
+		w, _ = do(http.MethodPut, "/v1/admin/channels/1", `{"enabled":true}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []int{1}, runtime.reset)
+	})
+
+	t.Run("update validation keeps the stored channel", func(t *testing.T) {
+		w, _ := do(http.MethodPut, "/v1/admin/channels/1", `{"base_url":"ftp://example.com"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "https://api.openai.com", store.channels[1].BaseURL)
+
+		w, _ = do(http.MethodPut, "/v1/admin/channels/9", `{"name":"x"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		w, _ := do(http.MethodDelete, "/v1/admin/channels/1", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, store.channels)
+		assert.Equal(t, []int{1, 1}, runtime.tripped)
+
+		w, _ = do(http.MethodDelete, "/v1/admin/channels/1", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w, _ = do(http.MethodDelete, "/v1/admin/channels/abc", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("manual reload", func(t *testing.T) {
+		before := runtime.reloads
+		w, _ := do(http.MethodPost, "/v1/admin/channels/reload", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, before+1, runtime.reloads)
+	})
+}
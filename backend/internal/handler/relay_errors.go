@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/adapter"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// RelayError 将中转错误映射为带稳定错误码的 AppError，上游错误附带双方请求 ID 与尝试过的渠道
+//
+// 上游限流按 429 返回；上游认为请求本身有误时沿用上游状态码，其它上游错误返回 502。
+func RelayError(err error, rc *relay.RelayContext) *utils.AppError {
+	var upstreamErr *relay.UpstreamError
+	var paramErr *adapter.ParamError
+	var busyErr *relay.ChannelBusyError
+	var featureErr *relay.FeatureUnsupportedError
+	var invalidJSON *relay.InvalidJSONError
+	switch {
+	case errors.Is(err, billing.ErrInsufficientQuota):
+		return utils.WrapError(utils.CodeQuotaExceeded, err)
+	case errors.Is(err, service.ErrQuotaTokenRejected):
+		return utils.WrapError(utils.CodeInvalidToken, err)
+	case errors.Is(err, model.ErrModelNotAllowed):
+		return utils.WrapError(utils.CodeModelNotAllowed, err)
+	case errors.Is(err, relay.ErrProjectNotFound), errors.Is(err, relay.ErrProjectForbidden):
+		return utils.WrapError(utils.CodeForbidden, err)
+	case errors.As(err, &featureErr):
+		return utils.WrapError(utils.CodeModelFeatureUnsupported, err)
+	case errors.Is(err, relay.ErrModelNotSupported):
+		return utils.WrapError(utils.CodeModelNotSupported, err)
+	case errors.Is(err, relay.ErrNoAvailableChannel):
+		return utils.WrapError(utils.CodeChannelUnavailable, err)
+	case errors.As(err, &busyErr):
+		// 渠道并发已满属于临时过载，客户端按 Retry-After 重试
+		appErr := utils.WrapError(utils.CodeRateLimited, err)
+		appErr.RetryAfter = busyErr.RetryAfter
+		return appErr
+	case errors.As(err, &paramErr):
+		return utils.WrapError(utils.CodeInvalidRequest, err)
+	case errors.As(err, &invalidJSON):
+		appErr := utils.WrapError(utils.CodeInvalidJSONOutput, err)
+		if rc != nil {
+			appErr.Details = relay.ErrorDetails(err, rc)
+		}
+		return appErr
+	case errors.As(err, &upstreamErr):
+		appErr := utils.WrapError(utils.CodeUpstreamError, err)
+		switch status := upstreamErr.StatusCode; {
+		case status == http.StatusTooManyRequests:
+			appErr = utils.WrapError(utils.CodeRateLimited, err)
+		case status >= http.StatusBadRequest && status < http.StatusInternalServerError &&
+			status != http.StatusUnauthorized && status != http.StatusForbidden:
+			// 渠道密钥失效（401/403）属于本系统的问题，不透传给调用方
+			appErr = utils.WrapError(utils.CodeInvalidRequest, err)
+			appErr.HTTPStatus = status
+		}
+		appErr.Message = upstreamErr.Message
+		if rc != nil {
+			appErr.Details = relay.ErrorDetails(err, rc)
+		}
+		return appErr
+	}
+	return utils.WrapError(utils.CodeInternal, err)
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelayErrorFeatureUnsupported(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	err := fmt.Errorf("relay failed: %w", &relay.FeatureUnsupportedError{
+		Model:    "gpt-4",
+		Features: []relay.ChannelAbilityFeature{relay.FeatureFunctionCalling},
+		Channels: []relay.ChannelFeatureGap{{ChannelID: "9", Missing: []relay.ChannelAbilityFeature{relay.FeatureFunctionCalling}}},
+	})
+	utils.RespondOpenAIError(c, RelayError(err, nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	body := openAIErrorBody(t, w)
+	assert.Equal(t, "model_feature_unsupported", body["code"])
+	assert.Equal(t, "tools", body["param"])
+	assert.Contains(t, body["message"], "channel 9 lacks function_calling")
+}
+
+func TestRelayErrorInvalidJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	err := &relay.InvalidJSONError{Model: "gpt-4", Attempts: 2, Err: fmt.Errorf("%w: unexpected end of JSON input", relay.ErrInvalidJSONOutput)}
+	utils.RespondOpenAIError(c, RelayError(err, nil))
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	body := openAIErrorBody(t, w)
+	assert.Equal(t, "invalid_json_output", body["code"])
+	assert.Equal(t, "response_format", body["param"])
+	assert.Equal(t, "model gpt-4 returned invalid JSON after 2 attempts: unexpected end of JSON input", body["message"])
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/metrics"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/scaling"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// ScalingHandler 扩缩容信号接口
+//
+// 信号随服务的 /metrics 供 Prometheus 抓取（再经 Prometheus Adapter 提供给 HPA），/internal/scaling-hints
+// 返回当前值与软/硬上限的摘要。两者与 /health 一样只应在集群内部暴露。
+type ScalingHandler struct {
+	registry *scaling.Registry
+}
+
+// NewScalingHandler 创建扩缩容信号 Handler，信号同时加入服务的 /metrics 输出
+func NewScalingHandler(registry *scaling.Registry) *ScalingHandler {
+	metrics.Register(registry.Gatherer())
+	return &ScalingHandler{registry: registry}
+}
+
+// GetScalingHints 获取扩缩容信号摘要
+// GET /internal/scaling-hints
+func (h *ScalingHandler) GetScalingHints(c *gin.Context) {
+	utils.Success(c, gin.H{
+		"signals": h.registry.Hints(),
+	}, "")
+}
+
+// RegisterRoutes 注册路由
+func (h *ScalingHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/internal/scaling-hints", h.GetScalingHints)
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/metrics"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/scaling"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScalingHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reg := scaling.NewRegistry()
+	streams := reg.NewGauge(scaling.MetricChatActiveStreams, "active streams", nil, 10)
+	streams.Set(9)
+
+	r := gin.New()
+	NewScalingHandler(reg).RegisterRoutes(&r.RouterGroup)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internal/scaling-hints", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Data struct {
+			Signals []scaling.Hint `json:"signals"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data.Signals, 1)
+	assert.Equal(t, scaling.Hint{Name: scaling.MetricChatActiveStreams, Value: 9, SoftLimit: 8, HardLimit: 10}, resp.Data.Signals[0])
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), scaling.MetricChatActiveStreams+" 9")
+}
@@ -1,13 +1,18 @@
 package handler
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
 )
 
 // StatsHandler 统计监控Handler
@@ -207,6 +212,194 @@ func (h *StatsHandler) GetModelStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// ProjectStats 项目统计
+type ProjectStats struct {
+	ProjectID   int     `json:"project_id"`
+	ProjectName string  `json:"project_name"`
+	UserID      int     `json:"user_id"`
+	Requests    int64   `json:"requests"`
+	Tokens      int64   `json:"tokens"`
+	Quota       int64   `json:"quota"`
+	AvgLatency  float64 `json:"avg_latency"`
+}
+
+// GetProjectStats 获取中转项目统计（含已归档分区的按天汇总）
+// @Summary 获取项目统计
+// @Tags stats
+// @Produce json
+// @Param days query int false "统计天数" default(7)
+// @Success 200 {array} ProjectStats
+// @Router /api/admin/stats/projects [get]
+func (h *StatsHandler) GetProjectStats(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days <= 0 {
+		days = 7
+	}
+	startTime := time.Now().AddDate(0, 0, -days)
+
+	type ProjectGroup struct {
+		ProjectID int
+		Count     int64
+		Tokens    int64
+		Quota     int64
+		TotalTime int64
+	}
+
+	var results []ProjectGroup
+	h.db.Model(&model.UnifiedLog{}).
+		Select("project_id, COUNT(*) as count, SUM(prompt_tokens + completion_tokens) as tokens, SUM(quota) as quota, SUM(use_time) as total_time").
+		Where("project_id > 0 AND created_at >= ?", startTime).
+		Group("project_id").
+		Scan(&results)
+
+	// 已归档的日期只存在于按天汇总表
+	var rollups []ProjectGroup
+	h.db.Model(&model.UnifiedLogRollup{}).
+		Select("project_id, SUM(requests) as count, SUM(prompt_tokens + completion_tokens) as tokens, SUM(quota) as quota, SUM(total_use_time) as total_time").
+		Where("project_id > 0 AND day >= ? AND day NOT IN (?)", startTime.Format("2006-01-02"),
+			h.db.Model(&model.UnifiedLog{}).Select("DISTINCT DATE(created_at)").Where("created_at >= ?", startTime)).
+		Group("project_id").
+		Scan(&rollups)
+
+	merged := make(map[int]*ProjectGroup, len(results))
+	for _, group := range append(results, rollups...) {
+		r, ok := merged[group.ProjectID]
+		if !ok {
+			g := group
+			merged[group.ProjectID] = &g
+			continue
+		}
+		r.Count += group.Count
+		r.Tokens += group.Tokens
+		r.Quota += group.Quota
+		r.TotalTime += group.TotalTime
+	}
+
+	ids := make([]int, 0, len(merged))
+	for id := range merged {
+		ids = append(ids, id)
+	}
+	var projects []model.Project
+	if len(ids) > 0 {
+		h.db.Where("id IN ?", ids).Find(&projects)
+	}
+	names := make(map[int]model.Project, len(projects))
+	for _, p := range projects {
+		names[p.ID] = p
+	}
+
+	stats := make([]ProjectStats, 0, len(merged))
+	for id, r := range merged {
+		stat := ProjectStats{
+			ProjectID:   id,
+			ProjectName: names[id].Name,
+			UserID:      names[id].UserID,
+			Requests:    r.Count,
+			Tokens:      r.Tokens,
+			Quota:       r.Quota,
+		}
+		if r.Count > 0 {
+			stat.AvgLatency = float64(r.TotalTime) / float64(r.Count)
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Requests > stats[j].Requests
+	})
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// PhaseLatency 单个阶段的耗时分位数（毫秒）
+type PhaseLatency struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+}
+
+// LatencyStats 模型的分阶段耗时统计
+type LatencyStats struct {
+	Model    string                  `json:"model"`
+	Requests int64                   `json:"requests"`
+	Phases   map[string]PhaseLatency `json:"phases"` // 阶段名称见 relay.LatencyPhaseNames，另含 total
+}
+
+// latencyFields 分阶段耗时统计的字段，依次为各阶段与总耗时
+func latencyFields() []string {
+	return append(append([]string{}, relay.LatencyPhaseNames()...), "total")
+}
+
+// GetLatencyStats 获取按模型分组的分阶段耗时分位数（p50/p95）
+// @Summary 获取分阶段耗时统计
+// @Tags stats
+// @Produce json
+// @Param days query int false "统计天数" default(7)
+// @Success 200 {array} LatencyStats
+// @Router /api/admin/stats/latency [get]
+func (h *StatsHandler) GetLatencyStats(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days <= 0 {
+		days = 7
+	}
+	startTime := time.Now().AddDate(0, 0, -days)
+
+	// 分阶段耗时只记录在每个请求最后一次尝试的日志上（other.latency）
+	columns := []string{"model_name", "COUNT(*) AS count"}
+	for _, field := range latencyFields() {
+		value := fmt.Sprintf("(other->'latency'->>'%s_ms')::float8", field)
+		columns = append(columns,
+			fmt.Sprintf("percentile_cont(0.5) WITHIN GROUP (ORDER BY %s) AS %s_p50", value, field),
+			fmt.Sprintf("percentile_cont(0.95) WITHIN GROUP (ORDER BY %s) AS %s_p95", value, field))
+	}
+
+	var rows []map[string]interface{}
+	if err := h.db.Model(&model.UnifiedLog{}).
+		Select(strings.Join(columns, ", ")).
+		Where("created_at >= ? AND other->'latency' IS NOT NULL", startTime).
+		Group("model_name").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats := make([]LatencyStats, 0, len(rows))
+	for _, row := range rows {
+		stat := LatencyStats{
+			Model:    fmt.Sprint(row["model_name"]),
+			Requests: int64(numericValue(row["count"])),
+			Phases:   make(map[string]PhaseLatency),
+		}
+		for _, field := range latencyFields() {
+			stat.Phases[field] = PhaseLatency{
+				P50: numericValue(row[field+"_p50"]),
+				P95: numericValue(row[field+"_p95"]),
+			}
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Requests > stats[j].Requests
+	})
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// numericValue 将数据库驱动返回的数值（整数、浮点或 numeric 文本）转换为 float64
+func numericValue(v interface{}) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	case []byte:
+		f, _ := strconv.ParseFloat(string(n), 64)
+		return f
+	case string:
+		f, _ := strconv.ParseFloat(n, 64)
+		return f
+	}
+	return 0
+}
+
 // TimeSeriesData 时间序列数据
 type TimeSeriesData struct {
 	Date     string `json:"date"`
@@ -242,6 +435,27 @@ func (h *StatsHandler) GetTimeSeries(c *gin.Context) {
 		Order("date ASC").
 		Scan(&results)
 
+	// 已归档分区的日期从按天汇总表补齐
+	var rollups []DailyGroup
+	h.db.Model(&model.UnifiedLogRollup{}).
+		Select("TO_CHAR(day, 'YYYY-MM-DD') as date, SUM(requests) as count, SUM(prompt_tokens + completion_tokens) as tokens, SUM(quota) as quota").
+		Where("day >= ?", startTime.Format("2006-01-02")).
+		Group("day").
+		Scan(&rollups)
+
+	live := make(map[string]bool, len(results))
+	for _, r := range results {
+		live[r.Date[:min(len(r.Date), 10)]] = true
+	}
+	for _, r := range rollups {
+		if !live[r.Date] {
+			results = append(results, r)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Date < results[j].Date
+	})
+
 	series := make([]TimeSeriesData, 0, len(results))
 	for _, r := range results {
 		series = append(series, TimeSeriesData{
@@ -262,6 +476,8 @@ func (h *StatsHandler) RegisterRoutes(r *gin.RouterGroup) {
 		stats.GET("/overview", h.GetOverview)
 		stats.GET("/channels", h.GetChannelStats)
 		stats.GET("/models", h.GetModelStats)
+		stats.GET("/projects", h.GetProjectStats)
+		stats.GET("/latency", h.GetLatencyStats)
 		stats.GET("/timeseries", h.GetTimeSeries)
 	}
 }
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// TokenHandler 处理用户 API Token 管理相关的 HTTP 请求
+type TokenHandler struct {
+	tokenService *service.TokenService
+}
+
+// NewTokenHandler 创建新的 Token Handler
+func NewTokenHandler(tokenService *service.TokenService) *TokenHandler {
+	return &TokenHandler{tokenService: tokenService}
+}
+
+// RotateTokenRequest 轮换 Token 的请求结构
+type RotateTokenRequest struct {
+	// GraceMinutes 旧 Key 继续有效的分钟数，未提供时使用默认宽限期，0 表示立即失效
+	GraceMinutes *int `json:"grace_minutes"`
+}
+
+// CreateToken 创建 API Token
+// POST /api/v1/user/tokens
+func (h *TokenHandler) CreateToken(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	var req service.CreateTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	token, err := h.tokenService.CreateUserToken(c.Request.Context(), userID, &req)
+	if err != nil {
+		handleTokenError(c, err)
+		return
+	}
+
+	utils.Success(c, token, "Token 创建成功，请妥善保存，Key 只显示一次")
+}
+
+// ListTokens 获取当前用户的 API Token 列表
+// GET /api/v1/user/tokens
+func (h *TokenHandler) ListTokens(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	tokens, err := h.tokenService.ListUserTokens(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, tokens, "")
+}
+
+// UpdateToken 更新名称、额度上限与 IP/模型白名单
+// PUT /api/v1/user/tokens/:id
+func (h *TokenHandler) UpdateToken(c *gin.Context) {
+	userID, id, ok := tokenParams(c)
+	if !ok {
+		return
+	}
+
+	var req service.UpdateTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	token, err := h.tokenService.UpdateUserToken(c.Request.Context(), userID, id, &req)
+	if err != nil {
+		handleTokenError(c, err)
+		return
+	}
+
+	utils.Success(c, token, "Token 更新成功")
+}
+
+// RotateToken 轮换 Token Key，旧 Key 在宽限期内仍然有效
+// POST /api/v1/user/tokens/:id/rotate
+func (h *TokenHandler) RotateToken(c *gin.Context) {
+	userID, id, ok := tokenParams(c)
+	if !ok {
+		return
+	}
+
+	var req RotateTokenRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	grace := time.Duration(-1)
+	if req.GraceMinutes != nil {
+		if *req.GraceMinutes < 0 {
+			utils.BadRequest(c, "grace_minutes 不能为负数")
+			return
+		}
+		grace = time.Duration(*req.GraceMinutes) * time.Minute
+	}
+
+	token, err := h.tokenService.RotateUserToken(c.Request.Context(), userID, id, grace)
+	if err != nil {
+		handleTokenError(c, err)
+		return
+	}
+
+	utils.Success(c, token, "Token 已轮换，请妥善保存，新 Key 只显示一次")
+}
+
+// DeleteToken 删除 API Token（软删除）
+// DELETE /api/v1/user/tokens/:id
+func (h *TokenHandler) DeleteToken(c *gin.Context) {
+	userID, id, ok := tokenParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.tokenService.DeleteUserToken(c.Request.Context(), userID, id); err != nil {
+		handleTokenError(c, err)
+		return
+	}
+
+	utils.Success(c, nil, "Token 删除成功")
+}
+
+// RegisterRoutes 注册路由
+func (h *TokenHandler) RegisterRoutes(r *gin.RouterGroup) {
+	tokens := r.Group("/user/tokens")
+	{
+		tokens.POST("", h.CreateToken)
+		tokens.GET("", h.ListTokens)
+		tokens.PUT("/:id", h.UpdateToken)
+		tokens.POST("/:id/rotate", h.RotateToken)
+		tokens.DELETE("/:id", h.DeleteToken)
+	}
+}
+
+// tokenParams 解析当前用户与路径中的 Token ID，失败时已写入响应
+func tokenParams(c *gin.Context) (int, int, bool) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return 0, 0, false
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		utils.BadRequest(c, "无效的 Token ID")
+		return 0, 0, false
+	}
+
+	return userID, id, true
+}
+
+// handleTokenError 将 Token 错误映射为 HTTP 响应
+func handleTokenError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTokenNotFound):
+		utils.NotFound(c, "Token 不存在")
+	case errors.Is(err, service.ErrInvalidTokenRequest):
+		utils.BadRequest(c, err.Error())
+	default:
+		utils.InternalError(c, err.Error())
+	}
+}
+
+// TokenExpiryHandler 管理员查看 Token 过期检查的运行状态
+type TokenExpiryHandler struct {
+	sweeper *service.TokenExpirySweeper
+}
+
+// NewTokenExpiryHandler 创建 Token 过期检查状态 Handler
+func NewTokenExpiryHandler(sweeper *service.TokenExpirySweeper) *TokenExpiryHandler {
+	return &TokenExpiryHandler{sweeper: sweeper}
+}
+
+// GetExpiryStatus 获取最近一次过期检查的时间与数量
+// GET /api/v1/admin/tokens/expiry-status
+func (h *TokenExpiryHandler) GetExpiryStatus(c *gin.Context) {
+	utils.Success(c, h.sweeper.Status(), "")
+}
+
+// RegisterRoutes 注册路由，需挂载在管理员路由组下
+func (h *TokenExpiryHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/tokens/expiry-status", h.GetExpiryStatus)
+}
@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryTokens 内存中的 Token 仓储
+type memoryTokens struct {
+	mu     sync.Mutex
+	nextID int
+	tokens map[int]*model.Token
+}
+
+func newMemoryTokens() *memoryTokens {
+	return &memoryTokens{tokens: make(map[int]*model.Token)}
+}
+
+func (r *memoryTokens) Create(ctx context.Context, token *model.Token) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	token.ID = r.nextID
+	copied := *token
+	r.tokens[token.ID] = &copied
+	return nil
+}
+
+func (r *memoryTokens) GetByID(ctx context.Context, id int) (*model.Token, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if token, ok := r.tokens[id]; ok {
+		copied := *token
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *memoryTokens) find(match func(*model.Token) bool) *model.Token {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if match(token) {
+			copied := *token
+			return &copied
+		}
+	}
+	return nil
+}
+
+func (r *memoryTokens) GetByHash(ctx context.Context, tokenHash string) (*model.Token, error) {
+	return r.find(func(t *model.Token) bool { return t.TokenHash == tokenHash }), nil
+}
+
+func (r *memoryTokens) GetByPreviousHash(ctx context.Context, tokenHash string) (*model.Token, error) {
+	return r.find(func(t *model.Token) bool { return t.PreviousTokenHash.Valid && t.PreviousTokenHash.String == tokenHash }), nil
+}
+
+func (r *memoryTokens) Update(ctx context.Context, token *model.Token) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *token
+	r.tokens[token.ID] = &copied
+	return nil
+}
+
+func (r *memoryTokens) ListByUserID(ctx context.Context, userID int) ([]*model.Token, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []*model.Token
+	for _, token := range r.tokens {
+		if token.UserID == userID {
+			copied := *token
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryTokens) ListByProjectID(ctx context.Context, projectID int) ([]*model.Token, error) {
+	return nil, nil
+}
+
+func (r *memoryTokens) CheckAndUpdateExpiredTokens(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (r *memoryTokens) ListExpiringTokens(ctx context.Context, from, to time.Time) ([]*model.Token, error) {
+	return nil, nil
+}
+
+func (r *memoryTokens) MarkExpiryNotified(ctx context.Context, id int, at time.Time) (bool, error) {
+	return false, nil
+}
+
+func (r *memoryTokens) LogAudit(ctx context.Context, log *model.TokenAuditLog) error {
+	return nil
+}
+
+func (r *memoryTokens) LogRenewal(ctx context.Context, log *model.TokenRenewalLog) error {
+	return nil
+}
+
+// expirePreviousKey 模拟轮换宽限期结束
+func (r *memoryTokens) expirePreviousKey(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[id].PreviousHashExpireAt = sql.NullTime{Time: time.Now().Add(-time.Second), Valid: true}
+}
+
+// tokenTestRouter 管理接口以 userID 登录；/v1/chat 模拟中转入口，使用 API Key 鉴权并检查模型白名单
+func tokenTestRouter(tokens *service.TokenService, userID int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	api := r.Group("", func(c *gin.Context) {
+		c.Set(middleware.UserIDKey, userID)
+	})
+	NewTokenHandler(tokens).RegisterRoutes(api)
+
+	r.POST("/v1/chat", middleware.APITokenMiddleware(tokens.AuthenticateKey), func(c *gin.Context) {
+		token := middleware.APITokenFromContext(c)
+		if token == nil {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		if _, err := tokens.ValidateToken(c.Request.Context(), token.TokenHash, c.ClientIP(), c.Query("model")); err != nil {
+			c.Status(http.StatusForbidden)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"token_id": token.ID})
+	})
+	return r
+}
+
+func doTokenRequest(t *testing.T, r *gin.Engine, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decodeUserToken(t *testing.T, w *httptest.ResponseRecorder) service.UserToken {
+	t.Helper()
+	var resp struct {
+		Data service.UserToken `json:"data"`
+	}
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+// callRelay 以 API Key 从指定 IP 调用中转入口
+func callRelay(r *gin.Engine, key, ip, modelName string) int {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat?model="+modelName, nil)
+	req.RemoteAddr = ip + ":52000"
+	req.Header.Set("Authorization", "Bearer "+key)
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestTokenHandlerCreateAndList(t *testing.T) {
+	repo := newMemoryTokens()
+	r := tokenTestRouter(service.NewTokenService(repo), 7)
+
+	created := decodeUserToken(t, doTokenRequest(t, r, http.MethodPost, "/user/tokens", gin.H{"name": "ci", "quota_limit": 1000}))
+	require.True(t, strings.HasPrefix(created.Key, "sk-"))
+	assert.Equal(t, int64(1000), created.QuotaLimit)
+
+	// 数据库只保存 Key 的哈希
+	stored, _ := repo.GetByID(context.Background(), created.ID)
+	assert.Equal(t, service.HashTokenKey(created.Key), stored.TokenHash)
+	assert.NotContains(t, stored.TokenHash, created.Key)
+
+	w := doTokenRequest(t, r, http.MethodGet, "/user/tokens", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Key, "list never returns the full key")
+	assert.Contains(t, w.Body.String(), "****")
+
+	assert.Equal(t, http.StatusOK, callRelay(r, created.Key, "10.0.0.1", "gpt-4o"))
+	assert.Equal(t, http.StatusUnauthorized, callRelay(r, stored.TokenHash, "10.0.0.1", "gpt-4o"), "the stored hash is not a credential")
+
+	// 他人的 Token 不可修改
+	other := tokenTestRouter(service.NewTokenService(repo), 8)
+	w = doTokenRequest(t, other, http.MethodPut, "/user/tokens/1", gin.H{"name": "stolen"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 删除后立即失效
+	w = doTokenRequest(t, r, http.MethodDelete, "/user/tokens/1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, callRelay(r, created.Key, "10.0.0.1", "gpt-4o"))
+	w = doTokenRequest(t, r, http.MethodGet, "/user/tokens", nil)
+	assert.JSONEq(t, `[]`, string(mustData(t, w)))
+}
+
+func TestTokenHandlerWhitelistEnforcement(t *testing.T) {
+	repo := newMemoryTokens()
+	r := tokenTestRouter(service.NewTokenService(repo), 7)
+	created := decodeUserToken(t, doTokenRequest(t, r, http.MethodPost, "/user/tokens", gin.H{"name": "prod"}))
+
+	w := doTokenRequest(t, r, http.MethodPut, "/user/tokens/1", gin.H{"ip_whitelist": []string{"not-an-ip"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	updated := decodeUserToken(t, doTokenRequest(t, r, http.MethodPut, "/user/tokens/1", gin.H{
+		"name":            "prod-eu",
+		"ip_whitelist":    []string{"10.0.0.1"},
+		"model_whitelist": []string{"gpt-4o"},
+	}))
+	assert.Equal(t, "prod-eu", updated.Name)
+	assert.Equal(t, []string{"10.0.0.1"}, updated.IPWhitelist)
+
+	assert.Equal(t, http.StatusOK, callRelay(r, created.Key, "10.0.0.1", "gpt-4o"))
+	assert.Equal(t, http.StatusUnauthorized, callRelay(r, created.Key, "10.0.0.2", "gpt-4o"), "ip not in whitelist")
+	assert.Equal(t, http.StatusForbidden, callRelay(r, created.Key, "10.0.0.1", "claude-3-opus"), "model not in whitelist")
+
+	// 清空白名单后不再限制
+	doTokenRequest(t, r, http.MethodPut, "/user/tokens/1", gin.H{"ip_whitelist": []string{}, "model_whitelist": []string{}})
+	assert.Equal(t, http.StatusOK, callRelay(r, created.Key, "10.0.0.2", "claude-3-opus"))
+}
+
+func TestTokenHandlerRotationGracePeriod(t *testing.T) {
+	repo := newMemoryTokens()
+	r := tokenTestRouter(service.NewTokenService(repo), 7)
+	created := decodeUserToken(t, doTokenRequest(t, r, http.MethodPost, "/user/tokens", gin.H{"name": "ci"}))
+
+	w := doTokenRequest(t, r, http.MethodPost, "/user/tokens/1/rotate", gin.H{"grace_minutes": 24*60 + 1})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	rotated := decodeUserToken(t, doTokenRequest(t, r, http.MethodPost, "/user/tokens/1/rotate", gin.H{"grace_minutes": 5}))
+	require.NotEqual(t, created.Key, rotated.Key)
+	require.NotNil(t, rotated.PreviousKeyExpireAt)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), *rotated.PreviousKeyExpireAt, time.Minute)
+
+	// 宽限期内新旧 Key 都可用
+	assert.Equal(t, http.StatusOK, callRelay(r, rotated.Key, "10.0.0.1", "gpt-4o"))
+	assert.Equal(t, http.StatusOK, callRelay(r, created.Key, "10.0.0.1", "gpt-4o"))
+
+	// 宽限期结束后旧 Key 失效
+	repo.expirePreviousKey(1)
+	assert.Equal(t, http.StatusUnauthorized, callRelay(r, created.Key, "10.0.0.1", "gpt-4o"))
+	assert.Equal(t, http.StatusOK, callRelay(r, rotated.Key, "10.0.0.1", "gpt-4o"))
+
+	// grace_minutes 为 0 时旧 Key 立即失效
+	again := decodeUserToken(t, doTokenRequest(t, r, http.MethodPost, "/user/tokens/1/rotate", gin.H{"grace_minutes": 0}))
+	assert.Nil(t, again.PreviousKeyExpireAt)
+	assert.Equal(t, http.StatusUnauthorized, callRelay(r, rotated.Key, "10.0.0.1", "gpt-4o"))
+	assert.Equal(t, http.StatusOK, callRelay(r, again.Key, "10.0.0.1", "gpt-4o"))
+}
+
+func TestTokenHandlerLegacyKey(t *testing.T) {
+	repo := newMemoryTokens()
+	tokens := service.NewTokenService(repo)
+	r := tokenTestRouter(tokens, 7)
+
+	// 早期 Token 直接保存 Key
+	legacy := &model.Token{UserID: 7, Name: "legacy", TokenHash: "legacy-raw-key-0001", Status: model.TokenStatusNormal}
+	require.NoError(t, repo.Create(context.Background(), legacy))
+	assert.Equal(t, http.StatusOK, callRelay(r, legacy.TokenHash, "10.0.0.1", "gpt-4o"))
+
+	// 轮换后旧 Key 按宽限期处理
+	rotated := decodeUserToken(t, doTokenRequest(t, r, http.MethodPost, "/user/tokens/1/rotate", nil))
+	assert.Equal(t, http.StatusOK, callRelay(r, legacy.TokenHash, "10.0.0.1", "gpt-4o"))
+	repo.expirePreviousKey(1)
+	assert.Equal(t, http.StatusUnauthorized, callRelay(r, legacy.TokenHash, "10.0.0.1", "gpt-4o"))
+	assert.Equal(t, http.StatusOK, callRelay(r, rotated.Key, "10.0.0.1", "gpt-4o"))
+}
+
+func mustData(t *testing.T, w *httptest.ResponseRecorder) json.RawMessage {
+	t.Helper()
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// UserSessionHandler 处理用户查看与踢出登录设备的 HTTP 请求
+type UserSessionHandler struct {
+	sessions *service.SessionService
+}
+
+// NewUserSessionHandler 创建登录会话 Handler
+func NewUserSessionHandler(sessions *service.SessionService) *UserSessionHandler {
+	return &UserSessionHandler{sessions: sessions}
+}
+
+// ListSessions 列出当前用户的登录会话，current 标记发起请求的会话
+// GET /api/v1/user/sessions
+func (h *UserSessionHandler) ListSessions(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	sessions, err := h.sessions.List(c.Request.Context(), userID, c.GetString(middleware.SessionKey))
+	if err != nil {
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, sessions, "")
+}
+
+// RevokeSession 撤销登录会话，该设备的刷新令牌随即失效
+// DELETE /api/v1/user/sessions/:id
+func (h *UserSessionHandler) RevokeSession(c *gin.Context) {
+	userID, ok := middleware.UserIDFromContext(c)
+	if !ok {
+		utils.Unauthorized(c, "未登录")
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		utils.BadRequest(c, "无效的会话 ID")
+		return
+	}
+
+	if err := h.sessions.Revoke(c.Request.Context(), userID, id); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			utils.NotFound(c, "会话不存在")
+			return
+		}
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil, "会话已退出")
+}
+
+// RegisterRoutes 注册路由
+func (h *UserSessionHandler) RegisterRoutes(r *gin.RouterGroup) {
+	sessions := r.Group("/user/sessions")
+	{
+		sessions.GET("", h.ListSessions)
+		sessions.DELETE("/:id", h.RevokeSession)
+	}
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryUserSessions 内存中的登录会话仓储，按创建顺序列出
+type memoryUserSessions struct {
+	mu       sync.Mutex
+	sessions []*model.UserSession
+}
+
+func (r *memoryUserSessions) Create(ctx context.Context, session *model.UserSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session.ID = len(r.sessions) + 1
+	copied := *session
+	r.sessions = append(r.sessions, &copied)
+	return nil
+}
+
+func (r *memoryUserSessions) GetByFamily(ctx context.Context, familyID string) (*model.UserSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if session.FamilyID == familyID {
+			copied := *session
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryUserSessions) Touch(ctx context.Context, id int, at time.Time, ip string) error {
+	return nil
+}
+
+func (r *memoryUserSessions) ListActive(ctx context.Context, userID int) ([]*model.UserSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sessions []*model.UserSession
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *memoryUserSessions) Revoke(ctx context.Context, userID, id int, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if session.ID == id && session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryUserSessions) DeleteIdle(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+// sessionTestUsers 内存中的用户
+type sessionTestUsers map[int]*model.User
+
+func (u sessionTestUsers) FindByID(ctx context.Context, id int) (*model.User, error) {
+	return u[id], nil
+}
+
+func TestUserSessionHandlerRevoke(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtCfg := &config.JWTConfig{Secret: "session-secret", ExpireHours: 2, RefreshExpireDays: 7}
+	utils.InitJWT(jwtCfg)
+	sessions := service.NewSessionService(&memoryUserSessions{},
+		sessionTestUsers{3: {ID: 3, Username: "carol", Status: model.UserStatusActive}}, jwtCfg)
+
+	ctx := context.Background()
+	carol := &model.User{ID: 3, Username: "carol"}
+	desktop, err := sessions.IssueTokens(ctx, carol, service.ClientInfo{UserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"})
+	require.NoError(t, err)
+	tablet, err := sessions.IssueTokens(ctx, carol, service.ClientInfo{UserAgent: "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) Version/17.0 Safari/604.1"})
+	require.NoError(t, err)
+
+	r := gin.New()
+	auth := r.Group("/api/v1", middleware.AuthMiddleware([]byte(jwtCfg.Secret)))
+	NewUserSessionHandler(sessions).RegisterRoutes(auth)
+	call := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+desktop.AccessToken)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := call(http.MethodGet, "/api/v1/user/sessions")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var listed []struct {
+		ID      int    `json:"id"`
+		Device  string `json:"device"`
+		Current bool   `json:"current"`
+	}
+	require.NoError(t, json.Unmarshal(mustData(t, w), &listed))
+	require.Len(t, listed, 2)
+	assert.Equal(t, "Firefox on Linux", listed[0].Device)
+	assert.True(t, listed[0].Current)
+	assert.Equal(t, "Safari on iPadOS", listed[1].Device)
+	assert.False(t, listed[1].Current)
+	assert.NotContains(t, w.Body.String(), "family", "the refresh family is not exposed")
+
+	assert.Equal(t, http.StatusOK, call(http.MethodDelete, "/api/v1/user/sessions/2").Code)
+	assert.Equal(t, http.StatusNotFound, call(http.MethodDelete, "/api/v1/user/sessions/2").Code)
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodDelete, "/api/v1/user/sessions/abc").Code)
+
+	_, err = sessions.Refresh(ctx, tablet.RefreshToken, service.ClientInfo{})
+	assert.ErrorIs(t, err, service.ErrSessionRevoked)
+	_, err = sessions.Refresh(ctx, desktop.RefreshToken, service.ClientInfo{})
+	assert.NoError(t, err)
+}
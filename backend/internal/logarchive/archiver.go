@@ -0,0 +1,426 @@
+package logarchive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/storage"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrArchiveNotFound 归档记录不存在或不处于已归档状态
+var ErrArchiveNotFound = errors.New("archived partition not found")
+
+// Config 日志归档配置
+type Config struct {
+	HotMonths        int           // 热数据保留的完整月数
+	FutureMonths     int           // 提前创建的未来分区月数
+	Interval         time.Duration // 维护任务执行间隔
+	RestoreRetention time.Duration // 恢复的分区保留时长，过期后重新归档
+}
+
+// DefaultConfig 默认归档配置
+func DefaultConfig() *Config {
+	return &Config{
+		HotMonths:        3,
+		FutureMonths:     3,
+		Interval:         6 * time.Hour,
+		RestoreRetention: 7 * 24 * time.Hour,
+	}
+}
+
+// Archiver 统一日志分区维护与冷存储归档
+type Archiver struct {
+	db    *gorm.DB
+	store storage.ObjectStore
+	cfg   *Config
+	now   func() time.Time
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+	mu     sync.Mutex // 串行化分区 DDL
+}
+
+// NewArchiver 创建归档器
+func NewArchiver(db *gorm.DB, store storage.ObjectStore, cfg *Config) *Archiver {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	return &Archiver{
+		db:     db,
+		store:  store,
+		cfg:    cfg,
+		now:    time.Now,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start 启动定时维护任务
+func (a *Archiver) Start() {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ticker := time.NewTicker(a.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), a.cfg.Interval)
+			if err := a.RunMaintenance(ctx); err != nil {
+				logger.Error("log archive maintenance failed", zap.Error(err))
+			}
+			cancel()
+
+			select {
+			case <-a.stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop 停止定时维护任务
+func (a *Archiver) Stop() {
+	close(a.stopCh)
+	a.wg.Wait()
+}
+
+// HotCutoff 当前热数据窗口的起点
+func (a *Archiver) HotCutoff() time.Time {
+	return HotCutoff(a.now(), a.cfg.HotMonths)
+}
+
+// RunMaintenance 创建未来分区并归档过期分区
+func (a *Archiver) RunMaintenance(ctx context.Context) error {
+	if err := a.EnsurePartitions(ctx); err != nil {
+		return err
+	}
+	_, err := a.ArchiveExpired(ctx)
+	return err
+}
+
+// EnsurePartitions 提前创建当前及未来的月度分区
+func (a *Archiver) EnsurePartitions(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, p := range UpcomingPartitions(a.now(), a.cfg.FutureMonths) {
+		if err := a.db.WithContext(ctx).Exec(p.CreateSQL()).Error; err != nil {
+			return fmt.Errorf("failed to create partition %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// attachedPartitions 查询当前挂载在父表上的分区名
+func (a *Archiver) attachedPartitions(ctx context.Context) ([]string, error) {
+	var names []string
+	err := a.db.WithContext(ctx).Raw(`
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = ?`, ParentTable).Scan(&names).Error
+	return names, err
+}
+
+// ArchiveExpired 归档所有早于热窗口的分区（近期被恢复用于排查的分区除外）
+func (a *Archiver) ArchiveExpired(ctx context.Context) ([]*model.LogArchive, error) {
+	names, err := a.attachedPartitions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	archived := make([]*model.LogArchive, 0)
+	for _, p := range ExpiredPartitions(names, a.HotCutoff()) {
+		var existing model.LogArchive
+		err := a.db.WithContext(ctx).Where("partition_name = ?", p.Name).First(&existing).Error
+		if err == nil && existing.Status == model.LogArchiveStatusRestored &&
+			existing.RestoredAt != nil && a.now().Sub(*existing.RestoredAt) < a.cfg.RestoreRetention {
+			continue
+		}
+
+		record, err := a.ArchivePartition(ctx, p)
+		if err != nil {
+			return archived, err
+		}
+		archived = append(archived, record)
+	}
+
+	return archived, nil
+}
+
+// ArchivePartition 汇总、卸载并转储单个分区到对象存储，成功后删除分区
+func (a *Archiver) ArchivePartition(ctx context.Context, p Partition) (*model.LogArchive, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// 1. 先写入按天汇总，保证统计分析在归档后仍然可用
+	if err := a.db.WithContext(ctx).Exec(rollupSQL(p.Name)).Error; err != nil {
+		return nil, fmt.Errorf("failed to rollup partition %s: %w", p.Name, err)
+	}
+
+	// 2. 从父表卸载，之后的写入与查询不再触及该分区
+	if err := a.db.WithContext(ctx).Exec(fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", ParentTable, p.Name)).Error; err != nil {
+		return nil, fmt.Errorf("failed to detach partition %s: %w", p.Name, err)
+	}
+
+	// 3. 转储为 gzip 压缩的 JSON Lines
+	rowCount, size, err := a.dumpPartition(ctx, p)
+	if err != nil {
+		// 转储失败时重新挂载，避免数据不可见
+		if attachErr := a.db.WithContext(ctx).Exec(p.AttachSQL()).Error; attachErr != nil {
+			logger.Error("failed to reattach partition after dump failure",
+				zap.String("partition", p.Name), zap.Error(attachErr))
+		}
+		return nil, fmt.Errorf("failed to dump partition %s: %w", p.Name, err)
+	}
+
+	record := &model.LogArchive{
+		PartitionName: p.Name,
+		RangeStart:    p.Start,
+		RangeEnd:      p.End,
+		ObjectKey:     p.ObjectKey(),
+		RowCount:      rowCount,
+		SizeBytes:     size,
+		Status:        model.LogArchiveStatusArchived,
+		ArchivedAt:    a.now(),
+	}
+	err = a.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "partition_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"object_key", "row_count", "size_bytes", "status", "archived_at", "restored_at"}),
+	}).Create(record).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to record archive %s: %w", p.Name, err)
+	}
+
+	// 4. 删除已转储的分区
+	if err := a.db.WithContext(ctx).Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", p.Name)).Error; err != nil {
+		return nil, fmt.Errorf("failed to drop partition %s: %w", p.Name, err)
+	}
+
+	logger.Info("log partition archived",
+		zap.String("partition", p.Name),
+		zap.Int64("rows", rowCount),
+		zap.Int64("bytes", size))
+
+	return record, nil
+}
+
+// dumpPartition 以流的方式把分区数据写入对象存储
+func (a *Archiver) dumpPartition(ctx context.Context, p Partition) (int64, int64, error) {
+	rows, err := a.db.WithContext(ctx).Table(p.Name).Order("id").Rows()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	pr, pw := io.Pipe()
+	var rowCount int64
+	go func() {
+		aw := newArchiveWriter(pw)
+		for rows.Next() {
+			var entry model.UnifiedLog
+			if err := a.db.ScanRows(rows, &entry); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if err := aw.Write(&entry); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		rowCount = aw.count
+		pw.CloseWithError(aw.Close())
+	}()
+
+	size, err := a.store.Put(ctx, p.ObjectKey(), pr)
+	if err != nil {
+		pr.CloseWithError(err)
+		return 0, 0, err
+	}
+	return rowCount, size, nil
+}
+
+// RestorePartition 将已归档的分区恢复到数据库以便排查
+func (a *Archiver) RestorePartition(ctx context.Context, partitionName string) (*model.LogArchive, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var record model.LogArchive
+	err := a.db.WithContext(ctx).
+		Where("partition_name = ? AND status = ?", partitionName, model.LogArchiveStatusArchived).
+		First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrArchiveNotFound
+		}
+		return nil, err
+	}
+
+	p, ok := ParsePartitionName(record.PartitionName)
+	if !ok {
+		return nil, fmt.Errorf("invalid partition name: %s", record.PartitionName)
+	}
+
+	reader, err := a.store.Get(ctx, record.ObjectKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive %s: %w", record.ObjectKey, err)
+	}
+	defer reader.Close()
+
+	createSQL := fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS)", p.Name, ParentTable)
+	if err := a.db.WithContext(ctx).Exec(createSQL).Error; err != nil {
+		return nil, fmt.Errorf("failed to create partition table %s: %w", p.Name, err)
+	}
+
+	if err := a.loadArchive(ctx, p.Name, reader); err != nil {
+		_ = a.db.WithContext(ctx).Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", p.Name)).Error
+		return nil, err
+	}
+
+	if err := a.db.WithContext(ctx).Exec(p.AttachSQL()).Error; err != nil {
+		_ = a.db.WithContext(ctx).Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", p.Name)).Error
+		return nil, fmt.Errorf("failed to attach partition %s: %w", p.Name, err)
+	}
+
+	now := a.now()
+	record.Status = model.LogArchiveStatusRestored
+	record.RestoredAt = &now
+	if err := a.db.WithContext(ctx).Save(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// loadArchive 读取归档文件并批量写入分区表
+func (a *Archiver) loadArchive(ctx context.Context, table string, r io.Reader) error {
+	const batchSize = 500
+	batch := make([]*model.UnifiedLog, 0, batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := a.db.WithContext(ctx).Table(table).Create(batch).Error; err != nil {
+			return fmt.Errorf("failed to restore rows into %s: %w", table, err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err := readArchive(r, func(entry *model.UnifiedLog) error {
+		batch = append(batch, entry)
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// ListArchives 列出所有归档记录
+func (a *Archiver) ListArchives(ctx context.Context) ([]*model.LogArchive, error) {
+	var archives []*model.LogArchive
+	err := a.db.WithContext(ctx).Order("range_start DESC").Find(&archives).Error
+	return archives, err
+}
+
+// ArchivedRanges 查询与时间范围重叠、当前仍处于归档状态的分区
+func (a *Archiver) ArchivedRanges(ctx context.Context, start, end time.Time) ([]*model.LogArchive, error) {
+	var archives []*model.LogArchive
+	err := a.db.WithContext(ctx).
+		Where("status = ? AND range_start < ? AND range_end > ?", model.LogArchiveStatusArchived, end, start).
+		Order("range_start ASC").
+		Find(&archives).Error
+	return archives, err
+}
+
+// rollupSQL 生成分区按天汇总的 SQL，重复执行结果一致
+func rollupSQL(table string) string {
+	return fmt.Sprintf(`
+		INSERT INTO unified_log_daily_rollups
+			(day, channel_id, model_name, project_id, requests, prompt_tokens, completion_tokens, quota, total_use_time)
+		SELECT created_at::date, COALESCE(channel_id, 0), COALESCE(model_name, ''), COALESCE(project_id, 0), COUNT(*),
+			COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0),
+			COALESCE(SUM(quota), 0), COALESCE(SUM(use_time), 0)
+		FROM %s
+		GROUP BY 1, 2, 3, 4
+		ON CONFLICT (day, channel_id, model_name, project_id) DO UPDATE SET
+			requests = EXCLUDED.requests,
+			prompt_tokens = EXCLUDED.prompt_tokens,
+			completion_tokens = EXCLUDED.completion_tokens,
+			quota = EXCLUDED.quota,
+			total_use_time = EXCLUDED.total_use_time`, table)
+}
+
+// archiveWriter gzip 压缩的 JSON Lines 写入器
+type archiveWriter struct {
+	gz    *gzip.Writer
+	enc   *json.Encoder
+	count int64
+}
+
+func newArchiveWriter(w io.Writer) *archiveWriter {
+	gz := gzip.NewWriter(w)
+	return &archiveWriter{gz: gz, enc: json.NewEncoder(gz)}
+}
+
+// Write 写入一条日志
+func (aw *archiveWriter) Write(entry *model.UnifiedLog) error {
+	if err := aw.enc.Encode(entry); err != nil {
+		return err
+	}
+	aw.count++
+	return nil
+}
+
+// Close 刷新并关闭压缩流
+func (aw *archiveWriter) Close() error {
+	return aw.gz.Close()
+}
+
+// readArchive 逐条读取归档文件
+func readArchive(r io.Reader, fn func(*model.UnifiedLog) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("invalid archive: %w", err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry model.UnifiedLog
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("invalid archive line: %w", err)
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,58 @@
+package logarchive
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	created := time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)
+	logs := []*model.UnifiedLog{
+		{ID: 1, UserID: 7, ChannelID: 3, LogType: model.LogTypeConsume, ModelName: "gpt-4",
+			Content: "line one\nline two", PromptTokens: 10, CompletionTokens: 20, Quota: 30,
+			RequestID: "req_1", UpstreamRequestID: "up_1", Other: "{}", CreatedAt: created},
+		{ID: 2, UserID: 8, LogType: model.LogTypeError, Content: strings.Repeat("x", 100*1024), CreatedAt: created},
+	}
+
+	var buf bytes.Buffer
+	aw := newArchiveWriter(&buf)
+	for _, l := range logs {
+		require.NoError(t, aw.Write(l))
+	}
+	require.NoError(t, aw.Close())
+	assert.Equal(t, int64(2), aw.count)
+
+	var restored []*model.UnifiedLog
+	err := readArchive(&buf, func(l *model.UnifiedLog) error {
+		restored = append(restored, l)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, restored, 2)
+
+	assert.Equal(t, logs[0].Content, restored[0].Content)
+	assert.Equal(t, logs[0].UpstreamRequestID, restored[0].UpstreamRequestID)
+	assert.True(t, created.Equal(restored[0].CreatedAt))
+	assert.Len(t, restored[1].Content, 100*1024)
+}
+
+func TestReadArchiveRejectsUncompressed(t *testing.T) {
+	err := readArchive(strings.NewReader(`{"id":1}`), func(*model.UnifiedLog) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestRollupSQLIsIdempotent(t *testing.T) {
+	sql := rollupSQL("unified_logs_p202401")
+	assert.Contains(t, sql, "FROM unified_logs_p202401")
+	// 重复归档同一分区不能累加汇总值
+	assert.Contains(t, sql, "requests = EXCLUDED.requests")
+	assert.NotContains(t, sql, "requests + EXCLUDED")
+	// 归档后仍保留项目维度
+	assert.Contains(t, sql, "ON CONFLICT (day, channel_id, model_name, project_id)")
+}
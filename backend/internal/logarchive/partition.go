@@ -0,0 +1,148 @@
+package logarchive
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// ParentTable 分区父表
+	ParentTable = "unified_logs"
+	// DefaultPartition 兜底分区，接收超出已建分区范围的数据
+	DefaultPartition = "unified_logs_default"
+
+	partitionPrefix = ParentTable + "_p"
+	partitionLayout = "200601"
+)
+
+// Partition 一个月度分区
+type Partition struct {
+	Name  string
+	Start time.Time // 含
+	End   time.Time // 不含
+}
+
+// MonthStart 返回时间所在 UTC 自然月的第一天
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// PartitionFor 返回写入时间对应的分区，与 Postgres 的范围分区路由规则一致
+func PartitionFor(t time.Time) Partition {
+	start := MonthStart(t)
+	return Partition{
+		Name:  partitionPrefix + start.Format(partitionLayout),
+		Start: start,
+		End:   start.AddDate(0, 1, 0),
+	}
+}
+
+// ParsePartitionName 解析分区名，非月度分区（如默认分区）返回 false
+func ParsePartitionName(name string) (Partition, bool) {
+	if !strings.HasPrefix(name, partitionPrefix) {
+		return Partition{}, false
+	}
+	start, err := time.Parse(partitionLayout, strings.TrimPrefix(name, partitionPrefix))
+	if err != nil {
+		return Partition{}, false
+	}
+	return PartitionFor(start), true
+}
+
+// Contains 判断时间是否落在分区范围内
+func (p Partition) Contains(t time.Time) bool {
+	t = t.UTC()
+	return !t.Before(p.Start) && t.Before(p.End)
+}
+
+// CreateSQL 生成创建分区的 DDL
+func (p Partition) CreateSQL() string {
+	return fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+		p.Name, ParentTable, p.Start.Format("2006-01-02"), p.End.Format("2006-01-02"),
+	)
+}
+
+// AttachSQL 生成重新挂载分区的 DDL
+func (p Partition) AttachSQL() string {
+	return fmt.Sprintf(
+		"ALTER TABLE %s ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')",
+		ParentTable, p.Name, p.Start.Format("2006-01-02"), p.End.Format("2006-01-02"),
+	)
+}
+
+// ObjectKey 分区归档文件在对象存储中的键
+func (p Partition) ObjectKey() string {
+	return fmt.Sprintf("%s/%d/%s.jsonl.gz", ParentTable, p.Start.Year(), p.Name)
+}
+
+// UpcomingPartitions 返回从当前月起（含）往后 monthsAhead 个月的分区
+func UpcomingPartitions(now time.Time, monthsAhead int) []Partition {
+	start := MonthStart(now)
+	partitions := make([]Partition, 0, monthsAhead+1)
+	for i := 0; i <= monthsAhead; i++ {
+		partitions = append(partitions, PartitionFor(start.AddDate(0, i, 0)))
+	}
+	return partitions
+}
+
+// HotCutoff 热数据窗口的起点：保留当前月及之前 hotMonths 个完整月
+func HotCutoff(now time.Time, hotMonths int) time.Time {
+	return MonthStart(now).AddDate(0, -hotMonths, 0)
+}
+
+// ExpiredPartitions 从现有分区名中筛选出整体早于热窗口的分区，按时间升序
+func ExpiredPartitions(names []string, cutoff time.Time) []Partition {
+	expired := make([]Partition, 0)
+	for _, name := range names {
+		p, ok := ParsePartitionName(name)
+		if !ok {
+			continue
+		}
+		if !p.End.After(cutoff) {
+			expired = append(expired, p)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].Start.Before(expired[j].Start)
+	})
+	return expired
+}
+
+// MonthsInRange 返回与 [start, end) 有交集的所有月度分区
+func MonthsInRange(start, end time.Time) []Partition {
+	partitions := make([]Partition, 0)
+	if !end.After(start) {
+		return partitions
+	}
+	for m := MonthStart(start); m.Before(end.UTC()); m = m.AddDate(0, 1, 0) {
+		partitions = append(partitions, PartitionFor(m))
+	}
+	return partitions
+}
+
+// SplitRange 将查询范围 [start, end) 按热窗口切分：cutoff 之前的部分可能已归档
+func SplitRange(start, end, cutoff time.Time) (cold []Partition, hotStart time.Time, hasHot bool) {
+	cold = MonthsInRange(start, minTime(end, cutoff))
+	if end.After(cutoff) {
+		return cold, maxTime(start, cutoff), true
+	}
+	return cold, time.Time{}, false
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,141 @@
+package logarchive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionForMonthBoundaries(t *testing.T) {
+	cases := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{"first instant of month", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), "unified_logs_p202403"},
+		{"last instant of month", time.Date(2024, 3, 31, 23, 59, 59, 999999999, time.UTC), "unified_logs_p202403"},
+		{"leap day", time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC), "unified_logs_p202402"},
+		{"year rollover", time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC), "unified_logs_p202312"},
+		{"new year", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "unified_logs_p202401"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := PartitionFor(tc.at)
+			assert.Equal(t, tc.want, p.Name)
+			assert.True(t, p.Contains(tc.at))
+			assert.False(t, p.Contains(p.End))
+		})
+	}
+}
+
+func TestPartitionForUsesUTC(t *testing.T) {
+	// 北京时间 4 月 1 日 02:00 实际写入的是 UTC 3 月 31 日
+	shanghai := time.FixedZone("CST", 8*3600)
+	at := time.Date(2024, 4, 1, 2, 0, 0, 0, shanghai)
+	assert.Equal(t, "unified_logs_p202403", PartitionFor(at).Name)
+
+	// 西五区 3 月 31 日 22:00 已是 UTC 4 月 1 日
+	eastern := time.FixedZone("EST", -5*3600)
+	at = time.Date(2024, 3, 31, 22, 0, 0, 0, eastern)
+	assert.Equal(t, "unified_logs_p202404", PartitionFor(at).Name)
+}
+
+func TestParsePartitionName(t *testing.T) {
+	p, ok := ParsePartitionName("unified_logs_p202402")
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), p.Start)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), p.End)
+
+	for _, name := range []string{DefaultPartition, "unified_logs_p2024", "other_p202402", "unified_logs_pabcdef"} {
+		_, ok := ParsePartitionName(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestPartitionSQL(t *testing.T) {
+	p := PartitionFor(time.Date(2024, 12, 15, 0, 0, 0, 0, time.UTC))
+
+	assert.Equal(t,
+		"CREATE TABLE IF NOT EXISTS unified_logs_p202412 PARTITION OF unified_logs FOR VALUES FROM ('2024-12-01') TO ('2025-01-01')",
+		p.CreateSQL())
+	assert.Equal(t,
+		"ALTER TABLE unified_logs ATTACH PARTITION unified_logs_p202412 FOR VALUES FROM ('2024-12-01') TO ('2025-01-01')",
+		p.AttachSQL())
+	assert.Equal(t, "unified_logs/2024/unified_logs_p202412.jsonl.gz", p.ObjectKey())
+}
+
+func TestUpcomingPartitions(t *testing.T) {
+	parts := UpcomingPartitions(time.Date(2024, 11, 20, 0, 0, 0, 0, time.UTC), 3)
+
+	names := make([]string, 0, len(parts))
+	for _, p := range parts {
+		names = append(names, p.Name)
+	}
+	assert.Equal(t, []string{
+		"unified_logs_p202411",
+		"unified_logs_p202412",
+		"unified_logs_p202501",
+		"unified_logs_p202502",
+	}, names)
+}
+
+func TestExpiredPartitions(t *testing.T) {
+	now := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	cutoff := HotCutoff(now, 3)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), cutoff)
+
+	names := []string{
+		"unified_logs_p202403",
+		DefaultPartition,
+		"unified_logs_p202402",
+		"unified_logs_p202406",
+		"unified_logs_p202312",
+	}
+	expired := ExpiredPartitions(names, cutoff)
+
+	got := make([]string, 0, len(expired))
+	for _, p := range expired {
+		got = append(got, p.Name)
+	}
+	// 3 月分区仍在热窗口内，默认分区永不归档
+	assert.Equal(t, []string{"unified_logs_p202312", "unified_logs_p202402"}, got)
+}
+
+func TestSplitRangeAcrossCutoff(t *testing.T) {
+	cutoff := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("spans archive boundary", func(t *testing.T) {
+		start := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+		end := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+		cold, hotStart, hasHot := SplitRange(start, end, cutoff)
+		assert.Len(t, cold, 2)
+		assert.Equal(t, "unified_logs_p202401", cold[0].Name)
+		assert.Equal(t, "unified_logs_p202402", cold[1].Name)
+		assert.True(t, hasHot)
+		assert.Equal(t, cutoff, hotStart)
+	})
+
+	t.Run("entirely hot", func(t *testing.T) {
+		start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		end := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+		cold, hotStart, hasHot := SplitRange(start, end, cutoff)
+		assert.Empty(t, cold)
+		assert.True(t, hasHot)
+		assert.Equal(t, start, hotStart)
+	})
+
+	t.Run("entirely archived", func(t *testing.T) {
+		start := time.Date(2023, 11, 20, 0, 0, 0, 0, time.UTC)
+		end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		cold, _, hasHot := SplitRange(start, end, cutoff)
+		assert.False(t, hasHot)
+		assert.Len(t, cold, 2)
+		assert.Equal(t, "unified_logs_p202311", cold[0].Name)
+		assert.Equal(t, "unified_logs_p202312", cold[1].Name)
+	})
+}
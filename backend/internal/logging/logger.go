@@ -3,6 +3,7 @@ package logging
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"go.uber.org/zap"
@@ -34,6 +35,7 @@ type LogEntry struct {
 type Logger struct {
 	logger *zap.Logger
 	sugar  *zap.SugaredLogger
+	level  zap.AtomicLevel // 运行中可修改的日志级别，Wrap 创建的 Logger 为零值，不支持修改
 }
 
 // NewLogger 创建新的 Logger
@@ -59,14 +61,52 @@ func NewLogger(development bool) (*Logger, error) {
 	return &Logger{
 		logger: logger,
 		sugar:  logger.Sugar(),
+		level:  config.Level,
 	}, nil
 }
 
+// SetLevel 修改日志级别（debug、info、warn、error），立即对该 Logger 及其派生的 Logger 生效
+func (l *Logger) SetLevel(level string) error {
+	parsed, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	if l.level == (zap.AtomicLevel{}) {
+		return fmt.Errorf("logger does not support changing level")
+	}
+	l.level.SetLevel(parsed)
+	return nil
+}
+
+// Level 当前日志级别
+func (l *Logger) Level() zapcore.Level {
+	return l.logger.Level()
+}
+
+// requestIDKey 请求 ID 在 context 中的键
+type requestIDKey struct{}
+
+// ContextWithRequestID 将请求 ID 放入 context，经 Ctx 记录的日志与发往上游的请求都会带上该 ID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext 从 context 中获取请求 ID，也兼容 gin.Context 中以 request_id 保存的值
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	id, _ := ctx.Value("request_id").(string)
+	return id
+}
+
 // WithContext 从 context 提取追踪信息
 func (l *Logger) WithContext(ctx context.Context) *Logger {
-	requestID := ctx.Value("request_id")
-	if requestID != nil {
-		l.logger = l.logger.With(zap.String("request_id", requestID.(string)))
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		l.logger = l.logger.With(zap.String("request_id", requestID))
 	}
 
 	userID := ctx.Value("user_id")
@@ -227,6 +267,30 @@ func Init(env string) error {
 	return err
 }
 
+// SetLevel 修改全局 Logger 的日志级别，未初始化时返回错误
+func SetLevel(level string) error {
+	if DefaultLogger == nil {
+		return fmt.Errorf("logger is not initialized")
+	}
+	return DefaultLogger.SetLevel(level)
+}
+
+// Wrap 使用已有的 zap.Logger 创建 Logger（如测试中记录日志的 observer）
+func Wrap(l *zap.Logger) *Logger {
+	return &Logger{logger: l, sugar: l.Sugar()}
+}
+
+// Ctx 返回带有 context 中请求 ID 的全局 zap.Logger，未初始化时返回空实现
+func Ctx(ctx context.Context) *zap.Logger {
+	if DefaultLogger == nil {
+		return zap.NewNop()
+	}
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		return DefaultLogger.logger.With(zap.String("request_id", requestID))
+	}
+	return DefaultLogger.logger
+}
+
 // Sync 同步日志 (兼容旧 API)
 func Sync() {
 	if DefaultLogger != nil {
@@ -0,0 +1,266 @@
+package logtail
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTooManyTails 管理员同时打开的实时跟踪数超过上限
+var ErrTooManyTails = errors.New("too many concurrent tails for this admin")
+
+// Event 用户单个请求完成后的元数据，默认不包含任何消息内容
+type Event struct {
+	Timestamp        time.Time `json:"timestamp"`
+	RequestID        string    `json:"request_id"`
+	Model            string    `json:"model"`
+	ChannelID        int       `json:"channel_id"`
+	ChannelName      string    `json:"channel_name"`
+	Status           string    `json:"status"` // success、error、partial
+	LatencyMs        int       `json:"latency_ms"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	ReasoningTokens  int       `json:"reasoning_tokens"`
+	ErrorClass       string    `json:"error_class,omitempty"`
+	Content          *Content  `json:"content,omitempty"` // 仅推送给允许查看内容的订阅
+}
+
+// Content 请求与响应的消息内容
+type Content struct {
+	Prompt     string `json:"prompt"`
+	Completion string `json:"completion,omitempty"`
+}
+
+// Config 实时跟踪配置
+type Config struct {
+	BufferSize    int           // 每个订阅缓存的事件数，满时丢弃最旧的事件
+	IdleTimeout   time.Duration // 超过该时长没有新事件时自动结束订阅
+	MaxPerAdmin   int           // 每个管理员同时打开的订阅数上限
+	SweepInterval time.Duration // 检查过期订阅的间隔
+}
+
+// DefaultConfig 默认配置：缓存 100 条，15 分钟无活动过期，每个管理员最多 3 个
+func DefaultConfig() *Config {
+	return &Config{
+		BufferSize:    100,
+		IdleTimeout:   15 * time.Minute,
+		MaxPerAdmin:   3,
+		SweepInterval: time.Minute,
+	}
+}
+
+// Subscription 管理员对单个用户请求的实时订阅
+type Subscription struct {
+	AdminID        int
+	UserID         int
+	IncludeContent bool
+
+	events     chan *Event
+	done       chan struct{}
+	closeOnce  sync.Once
+	lastActive time.Time // 由 Registry.mu 保护
+	dropped    int64
+}
+
+// Events 推送的事件
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+// Done 订阅结束（过期或取消）时关闭
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Dropped 因缓存已满被丢弃的事件数
+func (s *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+func (s *Subscription) close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// deliver 非阻塞推送，缓存已满时丢弃最旧的事件，保证慢速客户端不会阻塞请求链路
+func (s *Subscription) deliver(ev *Event) {
+	for {
+		select {
+		case s.events <- ev:
+			return
+		default:
+		}
+		select {
+		case <-s.events:
+			atomic.AddInt64(&s.dropped, 1)
+		default:
+		}
+	}
+}
+
+// Registry 按用户维护实时跟踪订阅
+type Registry struct {
+	cfg *Config
+	now func() time.Time
+
+	mu       sync.Mutex
+	subs     map[int]map[*Subscription]struct{} // 用户 ID -> 订阅
+	perAdmin map[int]int                        // 管理员 ID -> 订阅数
+	count    int32                              // 订阅总数，无订阅时 Publish 直接返回
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewRegistry 创建订阅注册表
+func NewRegistry(cfg *Config) *Registry {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	return &Registry{
+		cfg:      cfg,
+		now:      time.Now,
+		subs:     make(map[int]map[*Subscription]struct{}),
+		perAdmin: make(map[int]int),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Subscribe 订阅用户的请求，includeContent 需由调用方事先确认用户的保留策略允许
+func (r *Registry) Subscribe(adminID, userID int, includeContent bool) (*Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cfg.MaxPerAdmin > 0 && r.perAdmin[adminID] >= r.cfg.MaxPerAdmin {
+		return nil, ErrTooManyTails
+	}
+
+	sub := &Subscription{
+		AdminID:        adminID,
+		UserID:         userID,
+		IncludeContent: includeContent,
+		events:         make(chan *Event, r.cfg.BufferSize),
+		done:           make(chan struct{}),
+		lastActive:     r.now(),
+	}
+	if r.subs[userID] == nil {
+		r.subs[userID] = make(map[*Subscription]struct{})
+	}
+	r.subs[userID][sub] = struct{}{}
+	r.perAdmin[adminID]++
+	atomic.AddInt32(&r.count, 1)
+	return sub, nil
+}
+
+// Unsubscribe 取消订阅，可重复调用
+func (r *Registry) Unsubscribe(sub *Subscription) {
+	r.mu.Lock()
+	r.remove(sub)
+	r.mu.Unlock()
+}
+
+// remove 调用方需持有 r.mu
+func (r *Registry) remove(sub *Subscription) {
+	userSubs, ok := r.subs[sub.UserID]
+	if !ok {
+		return
+	}
+	if _, ok := userSubs[sub]; !ok {
+		return
+	}
+
+	delete(userSubs, sub)
+	if len(userSubs) == 0 {
+		delete(r.subs, sub.UserID)
+	}
+	if r.perAdmin[sub.AdminID]--; r.perAdmin[sub.AdminID] <= 0 {
+		delete(r.perAdmin, sub.AdminID)
+	}
+	atomic.AddInt32(&r.count, -1)
+	sub.close()
+}
+
+// Publish 向订阅了该用户的管理员推送事件
+//
+// content 只在存在允许查看内容的订阅时才会调用，其余订阅收到的事件不包含内容。
+func (r *Registry) Publish(userID int, ev *Event, content func() *Content) {
+	if atomic.LoadInt32(&r.count) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userSubs := r.subs[userID]
+	if len(userSubs) == 0 {
+		return
+	}
+
+	var withContent *Event
+	now := r.now()
+	for sub := range userSubs {
+		sub.lastActive = now
+		if !sub.IncludeContent || content == nil {
+			sub.deliver(ev)
+			continue
+		}
+		if withContent == nil {
+			e := *ev
+			e.Content = content()
+			withContent = &e
+		}
+		sub.deliver(withContent)
+	}
+}
+
+// Sweep 结束超过 IdleTimeout 没有新事件的订阅，返回结束的数量
+func (r *Registry) Sweep() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	expired := 0
+	for _, userSubs := range r.subs {
+		for sub := range userSubs {
+			if now.Sub(sub.lastActive) >= r.cfg.IdleTimeout {
+				r.remove(sub)
+				expired++
+			}
+		}
+	}
+	return expired
+}
+
+// Start 启动过期订阅清理
+func (r *Registry) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.cfg.SweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stopCh:
+				return
+			case <-ticker.C:
+				r.Sweep()
+			}
+		}
+	}()
+}
+
+// Stop 停止清理并结束所有订阅
+func (r *Registry) Stop() {
+	close(r.stopCh)
+	r.wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, userSubs := range r.subs {
+		for sub := range userSubs {
+			r.remove(sub)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package logtail
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRegistry 使用可控时钟的注册表
+func newTestRegistry(cfg *Config) (*Registry, *time.Time) {
+	r := NewRegistry(cfg)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	return r, &now
+}
+
+func TestRegistryAutoExpiry(t *testing.T) {
+	r, now := newTestRegistry(nil)
+
+	sub, err := r.Subscribe(1, 42, false)
+	require.NoError(t, err)
+
+	*now = now.Add(10 * time.Minute)
+	assert.Equal(t, 0, r.Sweep(), "subscription must survive before the idle timeout")
+
+	// 新事件刷新活跃时间
+	r.Publish(42, &Event{RequestID: "req-1"}, nil)
+	*now = now.Add(10 * time.Minute)
+	assert.Equal(t, 0, r.Sweep(), "publish must refresh the idle timer")
+
+	*now = now.Add(5 * time.Minute)
+	assert.Equal(t, 1, r.Sweep())
+
+	select {
+	case <-sub.Done():
+	default:
+		t.Fatal("expired subscription must be closed")
+	}
+
+	// 过期后释放管理员的并发配额
+	for i := 0; i < DefaultConfig().MaxPerAdmin; i++ {
+		_, err := r.Subscribe(1, 42, false)
+		require.NoError(t, err)
+	}
+}
+
+func TestRegistryContentGating(t *testing.T) {
+	r, _ := newTestRegistry(nil)
+
+	metaOnly, err := r.Subscribe(1, 42, false)
+	require.NoError(t, err)
+
+	calls := 0
+	content := func() *Content {
+		calls++
+		return &Content{Prompt: "secret prompt", Completion: "secret answer"}
+	}
+
+	r.Publish(42, &Event{RequestID: "req-1"}, content)
+	assert.Equal(t, 0, calls, "content must not be built without a content subscriber")
+
+	ev := <-metaOnly.Events()
+	assert.Equal(t, "req-1", ev.RequestID)
+	assert.Nil(t, ev.Content)
+
+	withContent, err := r.Subscribe(2, 42, true)
+	require.NoError(t, err)
+
+	r.Publish(42, &Event{RequestID: "req-2"}, content)
+	assert.Equal(t, 1, calls)
+
+	ev = <-metaOnly.Events()
+	assert.Nil(t, ev.Content)
+
+	ev = <-withContent.Events()
+	require.NotNil(t, ev.Content)
+	assert.Equal(t, "secret prompt", ev.Content.Prompt)
+
+	// 其他用户的请求不会推送
+	r.Publish(7, &Event{RequestID: "req-3"}, content)
+	assert.Empty(t, metaOnly.Events())
+	assert.Empty(t, withContent.Events())
+}
+
+func TestRegistryPerAdminLimit(t *testing.T) {
+	r, _ := newTestRegistry(&Config{BufferSize: 1, IdleTimeout: time.Minute, MaxPerAdmin: 2})
+
+	first, err := r.Subscribe(1, 10, false)
+	require.NoError(t, err)
+	_, err = r.Subscribe(1, 11, false)
+	require.NoError(t, err)
+
+	_, err = r.Subscribe(1, 12, false)
+	assert.ErrorIs(t, err, ErrTooManyTails)
+
+	// 其他管理员不受影响
+	_, err = r.Subscribe(2, 12, false)
+	assert.NoError(t, err)
+
+	r.Unsubscribe(first)
+	r.Unsubscribe(first)
+	_, err = r.Subscribe(1, 12, false)
+	assert.NoError(t, err)
+}
+
+func TestRegistryDropsOldest(t *testing.T) {
+	r, _ := newTestRegistry(&Config{BufferSize: 2, IdleTimeout: time.Minute})
+
+	sub, err := r.Subscribe(1, 42, false)
+	require.NoError(t, err)
+
+	for _, id := range []string{"a", "b", "c"} {
+		r.Publish(42, &Event{RequestID: id}, nil)
+	}
+
+	assert.Equal(t, int64(1), sub.Dropped())
+	assert.Equal(t, "b", (<-sub.Events()).RequestID)
+	assert.Equal(t, "c", (<-sub.Events()).RequestID)
+}
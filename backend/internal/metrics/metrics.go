@@ -0,0 +1,191 @@
+// Package metrics 服务运行指标
+//
+// 每个服务的路由由 server.NewRouter 创建，统一挂载 HTTP 指标中间件并在 /metrics 输出 Prometheus
+// 格式的指标（同时包含通过 Register 加入的其它 Gatherer，如扩缩容信号）。指标名称统一以 oblivious_
+// 开头，标签只使用取值有限的维度（路由模板、渠道 ID、模型、结果），不包含用户 ID 等高基数字段：
+//
+//	oblivious_http_requests_total{method,route,status}              HTTP 请求数，route 为路由模板
+//	oblivious_http_request_duration_seconds{method,route}           HTTP 请求耗时
+//	oblivious_relay_upstream_requests_total{channel,model,outcome}  中转每次渠道尝试的结果（success/partial/error/cancelled）
+//	oblivious_relay_upstream_duration_seconds{channel,model}        中转每次渠道尝试的耗时
+//	oblivious_relay_tokens_total{model,type}                        中转计费的 Token 数（prompt / completion）
+//	oblivious_billing_events_processed_total{result}                计费事件的处理结果（success / retry / dead_letter）
+//	oblivious_relay_circuit_breaker_state{channel,state}            渠道断路器状态，当前状态为 1
+//	oblivious_cache_requests_total{cache,result}                    缓存查询次数（hit / miss），命中率 = hit / 总数
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// registry 本进程的指标，附带 Go 运行时与进程指标
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "oblivious_http_requests_total",
+		Help: "HTTP requests by route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "oblivious_http_request_duration_seconds",
+		Help: "HTTP request latency in seconds, including streamed responses.",
+		// 覆盖普通请求与长时间的流式响应
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"method", "route"})
+
+	relayUpstreamRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "oblivious_relay_upstream_requests_total",
+		Help: "Relay upstream attempts by channel, model and outcome.",
+	}, []string{"channel", "model", "outcome"})
+
+	relayUpstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "oblivious_relay_upstream_duration_seconds",
+		Help:    "Relay upstream attempt latency in seconds.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"channel", "model"})
+
+	relayTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "oblivious_relay_tokens_total",
+		Help: "Billed relay tokens by model and type (prompt or completion).",
+	}, []string{"model", "type"})
+
+	billingEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "oblivious_billing_events_processed_total",
+		Help: "Billing events processed by result.",
+	}, []string{"result"})
+
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "oblivious_cache_requests_total",
+		Help: "Cache lookups by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+
+	breakers = &circuitBreakerCollector{
+		desc: prometheus.NewDesc("oblivious_relay_circuit_breaker_state",
+			"Relay channel circuit breaker state, 1 for the current state.", []string{"channel", "state"}, nil),
+	}
+)
+
+func init() {
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		relayUpstreamRequests, relayUpstreamDuration, relayTokens,
+		billingEvents, cacheRequests, breakers,
+	)
+}
+
+var (
+	gatherersMu sync.RWMutex
+	gatherers   []prometheus.Gatherer
+)
+
+// Register 将其它 Gatherer（如扩缩容信号的 Registry）的指标加入 /metrics 输出
+func Register(g prometheus.Gatherer) {
+	gatherersMu.Lock()
+	gatherers = append(gatherers, g)
+	gatherersMu.Unlock()
+}
+
+// Handler /metrics 的处理器，输出本进程指标与已注册的 Gatherer
+func Handler() http.Handler {
+	return promhttp.HandlerFor(prometheus.GathererFunc(gather), promhttp.HandlerOpts{})
+}
+
+func gather() ([]*dto.MetricFamily, error) {
+	gatherersMu.RLock()
+	all := append(prometheus.Gatherers{registry}, gatherers...)
+	gatherersMu.RUnlock()
+	return all.Gather()
+}
+
+// Middleware 按路由模板记录 HTTP 请求数与耗时，未匹配路由的请求记为 unmatched
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveRelayAttempt 记录一次中转渠道尝试的结果与耗时
+func ObserveRelayAttempt(channel, model, outcome string, latency time.Duration) {
+	relayUpstreamRequests.WithLabelValues(channel, model, outcome).Inc()
+	relayUpstreamDuration.WithLabelValues(channel, model).Observe(latency.Seconds())
+}
+
+// AddRelayTokens 累加中转计费的 Token 数
+func AddRelayTokens(model string, promptTokens, completionTokens int) {
+	relayTokens.WithLabelValues(model, "prompt").Add(float64(promptTokens))
+	relayTokens.WithLabelValues(model, "completion").Add(float64(completionTokens))
+}
+
+// BillingEventProcessed 记录一个计费事件的处理结果
+func BillingEventProcessed(result string) {
+	billingEvents.WithLabelValues(result).Inc()
+}
+
+// CacheLookup 记录一次缓存查询是否命中
+func CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+// circuitBreakerStates 断路器的全部状态，每个渠道每种状态输出一个序列
+var circuitBreakerStates = []string{"closed", "open", "half_open"}
+
+// CircuitBreakerStates 设置抓取时读取断路器状态的函数，返回渠道 ID 到状态（closed / open / half_open）的映射
+func CircuitBreakerStates(fn func() map[string]string) {
+	breakers.mu.Lock()
+	breakers.read = fn
+	breakers.mu.Unlock()
+}
+
+// circuitBreakerCollector 抓取时读取断路器状态，渠道增删后不会残留旧序列
+type circuitBreakerCollector struct {
+	desc *prometheus.Desc
+	mu   sync.RWMutex
+	read func() map[string]string
+}
+
+func (c *circuitBreakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *circuitBreakerCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	read := c.read
+	c.mu.RUnlock()
+	if read == nil {
+		return
+	}
+
+	for channel, current := range read() {
+		for _, state := range circuitBreakerStates {
+			value := 0.0
+			if current == state {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, value, channel, state)
+		}
+	}
+}
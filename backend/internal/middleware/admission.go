@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// PriorityHeader 调用方声明请求优先级的请求头（interactive / batch）
+const PriorityHeader = "X-Relay-Priority"
+
+// 准入等待在 gin.Context 中的键，由 AdmissionTiming 读取
+const (
+	admissionStartKey = "admission_start"
+	admissionWaitKey  = "admission_wait"
+)
+
+// Admitter 并发准入控制（由 relay.AdmissionLimiter 实现）
+type Admitter interface {
+	Acquire(ctx context.Context, priority string) (func(), error)
+}
+
+// AdmissionMiddleware 请求执行期间（含流式输出）占用一个并发名额，名额已满时按优先级排队
+//
+// 等待队列已满时返回 503 与 Retry-After，客户端在排队期间断开时直接结束请求。
+func AdmissionMiddleware(admitter Admitter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		release, err := admitter.Acquire(c.Request.Context(), c.GetHeader(PriorityHeader))
+		if err != nil {
+			if c.Request.Context().Err() != nil {
+				c.Abort()
+				return
+			}
+			c.Header("Retry-After", "1")
+			utils.Error(c, http.StatusServiceUnavailable, utils.ErrRateLimitExceeded, err.Error(), nil)
+			c.Abort()
+			return
+		}
+		defer release()
+
+		c.Set(admissionStartKey, start)
+		c.Set(admissionWaitKey, time.Since(start))
+		c.Next()
+	}
+}
+
+// AdmissionTiming 开始等待准入的时间与等待时长，未经过准入时 start 为零值
+func AdmissionTiming(c *gin.Context) (start time.Time, wait time.Duration) {
+	return c.GetTime(admissionStartKey), c.GetDuration(admissionWaitKey)
+}
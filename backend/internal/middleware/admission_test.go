@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubAdmitter 记录请求的优先级，按需拒绝
+type stubAdmitter struct {
+	err        error
+	priorities []string
+	released   int
+}
+
+func (a *stubAdmitter) Acquire(ctx context.Context, priority string) (func(), error) {
+	a.priorities = append(a.priorities, priority)
+	if a.err != nil {
+		return nil, a.err
+	}
+	return func() { a.released++ }, nil
+}
+
+func TestAdmissionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	admitter := &stubAdmitter{}
+
+	r := gin.New()
+	var admittedAt time.Time
+	r.POST("/v1/chat/completions", AdmissionMiddleware(admitter), func(c *gin.Context) {
+		admittedAt, _ = AdmissionTiming(c)
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	do := func(priority string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		if priority != "" {
+			req.Header.Set(PriorityHeader, priority)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("batch")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"batch"}, admitter.priorities)
+	assert.Equal(t, 1, admitter.released)
+	assert.False(t, admittedAt.IsZero(), "admission wait is recorded for the relay context")
+
+	// 等待队列已满：503 并提示稍后重试，不进入处理函数
+	admitter.err = errors.New("relay admission queue is full")
+	w = do("")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.NotContains(t, w.Body.String(), `"ok"`)
+	assert.Equal(t, 1, admitter.released)
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// TokenIDKey API Token ID 在上下文中的键，限流与额度检查按该值区分调用方
+const TokenIDKey = "token_id"
+
+// apiKeyPrefix 平台签发的 API Key 前缀
+const apiKeyPrefix = "sk-"
+
+// maxModelPeekBytes 读取请求体中 model 字段时允许的最大请求体
+const maxModelPeekBytes = 8 << 20
+
+// APIKeyValidator 校验 API Key 并返回对应的 Token，modelName 为空时不检查模型白名单
+type APIKeyValidator func(ctx context.Context, key string, ip string, modelName string) (*model.Token, error)
+
+// APIKeyAuth 中转接口的 API Key 鉴权中间件
+//
+// 请求必须携带 Authorization: Bearer sk-...，按请求体中的 model 字段检查模型白名单，
+// 校验通过后将 Token、用户 ID 与 Token ID 写入上下文，下游的限流与额度检查按 Token ID 计数。
+// 失败时返回 OpenAI 格式的错误，模型不在白名单中返回 403。
+func APIKeyAuth(validate APIKeyValidator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			abortOpenAIError(c, http.StatusUnauthorized, "missing_api_key", "",
+				"You didn't provide an API key. You need to provide your API key in an Authorization header using Bearer auth (i.e. Authorization: Bearer YOUR_KEY).")
+			return
+		}
+		key := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+		if key == "" || key == authHeader {
+			abortOpenAIError(c, http.StatusUnauthorized, "invalid_api_key", "", "Invalid Authorization header, expected: Bearer YOUR_KEY.")
+			return
+		}
+
+		// 不是平台签发的 Key（如把令牌哈希或其它服务的密钥当作 Key）不查询数据库，
+		// 早期没有前缀的 Key 交给校验函数，仅在 Token 没有 KeyHint 时接受
+		if !strings.HasPrefix(key, apiKeyPrefix) && !isLegacyAPIKey(key) {
+			abortOpenAIError(c, http.StatusUnauthorized, "invalid_api_key", "", "Incorrect API key provided: "+maskAPIKey(key)+".")
+			return
+		}
+
+		modelName, err := requestedModel(c)
+		if err != nil {
+			abortOpenAIError(c, http.StatusBadRequest, "invalid_request_body", "", err.Error())
+			return
+		}
+
+		token, err := validate(c.Request.Context(), key, c.ClientIP(), modelName)
+		if errors.Is(err, model.ErrModelNotAllowed) {
+			abortOpenAIError(c, http.StatusForbidden, "model_not_allowed", "model",
+				"The model `"+modelName+"` is not allowed for this API key.")
+			return
+		}
+		if err != nil || token == nil {
+			abortOpenAIError(c, http.StatusUnauthorized, "invalid_api_key", "", "Incorrect API key provided: "+maskAPIKey(key)+".")
+			return
+		}
+
+		c.Set(APITokenKey, token)
+		c.Set(UserIDKey, token.UserID)
+		c.Set(TokenIDKey, token.ID)
+		c.Next()
+	}
+}
+
+// requestedModel 读取请求的模型：JSON 请求取请求体的 model 字段（读取后还原请求体），其它请求取查询参数
+func requestedModel(c *gin.Context) (string, error) {
+	if c.Request.Body == nil || c.Request.Method == http.MethodGet || !strings.Contains(c.ContentType(), "json") {
+		return c.Query("model"), nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxModelPeekBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > maxModelPeekBytes {
+		return "", errors.New("request body too large")
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	// 请求体格式错误时交给接口返回具体的参数错误
+	var payload struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(body, &payload)
+	return payload.Model, nil
+}
+
+// abortOpenAIError 以 OpenAI 格式返回错误并终止请求，错误码沿用 OpenAI 的鉴权错误码便于 SDK 识别
+func abortOpenAIError(c *gin.Context, status int, code string, param string, message string) {
+	detail := utils.OpenAIErrorDetail{Message: message, Type: "invalid_request_error", Code: code}
+	if param != "" {
+		detail.Param = &param
+	}
+	c.AbortWithStatusJSON(status, utils.OpenAIError{Error: detail})
+}
+
+// isLegacyAPIKey 早期 Token 的 Key 为 64 位十六进制字符串，没有 sk- 前缀
+func isLegacyAPIKey(key string) bool {
+	if len(key) != 64 {
+		return false
+	}
+	for _, r := range key {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// maskAPIKey 错误信息中只显示 Key 的首尾字符
+func maskAPIKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:3] + "****" + key[len(key)-4:]
+}
@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tokens := map[string]*model.Token{
+		"sk-aaaaaaaaaaaaaaaa": {ID: 1, UserID: 7, ModelWhitelist: pq.StringArray{"gpt-4o"}},
+		"sk-bbbbbbbbbbbbbbbb": {ID: 2, UserID: 8, IPWhitelist: pq.StringArray{"10.0.0.1"}},
+	}
+	legacyKey := strings.Repeat("0f", 32)
+	tokens[legacyKey] = &model.Token{ID: 3, UserID: 9}
+	var seenIP string
+	validations := 0
+	validate := func(ctx context.Context, key, ip, modelName string) (*model.Token, error) {
+		validations++
+		seenIP = ip
+		token, ok := tokens[key]
+		if !ok {
+			return nil, model.ErrTokenInvalid
+		}
+		if !token.ValidateIPAddress(ip) {
+			return nil, errors.New("ip address not in whitelist")
+		}
+		if modelName != "" && !token.ValidateModel(modelName) {
+			return nil, fmt.Errorf("%w: %s", model.ErrModelNotAllowed, modelName)
+		}
+		return token, nil
+	}
+
+	r := gin.New()
+	echo := func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.JSON(http.StatusOK, gin.H{
+			"token_id": c.GetInt(TokenIDKey),
+			"user_id":  c.GetInt(UserIDKey),
+			"body":     string(body),
+		})
+	}
+	r.POST("/v1/chat/completions", APIKeyAuth(validate), echo)
+	r.GET("/v1/models", APIKeyAuth(validate), echo)
+
+	do := func(method, path, auth, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "10.0.0.2:1234"
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	openAIError := func(t *testing.T, w *httptest.ResponseRecorder) utils.OpenAIErrorDetail {
+		t.Helper()
+		var resp utils.OpenAIError
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "invalid_request_error", resp.Error.Type)
+		return resp.Error
+	}
+
+	t.Run("missing key", func(t *testing.T) {
+		w := do(http.MethodPost, "/v1/chat/completions", "", `{"model":"gpt-4o"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "missing_api_key", openAIError(t, w).Code)
+	})
+
+	t.Run("malformed header", func(t *testing.T) {
+		for _, auth := range []string{"sk-aaaaaaaaaaaaaaaa", "Bearer ", "Basic abc"} {
+			w := do(http.MethodPost, "/v1/chat/completions", auth, `{"model":"gpt-4o"}`)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, auth)
+			assert.Equal(t, "invalid_api_key", openAIError(t, w).Code, auth)
+		}
+	})
+
+	t.Run("unknown key is masked", func(t *testing.T) {
+		w := do(http.MethodPost, "/v1/chat/completions", "Bearer sk-unknown-secret-key", `{"model":"gpt-4o"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		detail := openAIError(t, w)
+		assert.Equal(t, "invalid_api_key", detail.Code)
+		assert.Nil(t, detail.Param)
+		assert.NotContains(t, detail.Message, "unknown-secret")
+	})
+
+	t.Run("key without sk- prefix is rejected before validation", func(t *testing.T) {
+		before := validations
+		for _, key := range []string{"not-a-platform-key", strings.Repeat("z", 64), strings.ToUpper(legacyKey)} {
+			w := do(http.MethodPost, "/v1/chat/completions", "Bearer "+key, `{"model":"gpt-4o"}`)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, key)
+			assert.Equal(t, "invalid_api_key", openAIError(t, w).Code, key)
+		}
+		assert.Equal(t, before, validations)
+
+		// 早期没有前缀的 Key 仍交给校验函数
+		w := do(http.MethodGet, "/v1/models", "Bearer "+legacyKey, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, before+1, validations)
+	})
+
+	t.Run("ip not in whitelist", func(t *testing.T) {
+		w := do(http.MethodPost, "/v1/chat/completions", "Bearer sk-bbbbbbbbbbbbbbbb", `{"model":"gpt-4o"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "10.0.0.2", seenIP)
+	})
+
+	t.Run("model not in whitelist", func(t *testing.T) {
+		w := do(http.MethodPost, "/v1/chat/completions", "Bearer sk-aaaaaaaaaaaaaaaa", `{"model":"claude-3-opus"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		detail := openAIError(t, w)
+		assert.Equal(t, "model_not_allowed", detail.Code)
+		require.NotNil(t, detail.Param)
+		assert.Equal(t, "model", *detail.Param)
+		assert.Contains(t, detail.Message, "claude-3-opus")
+	})
+
+	t.Run("valid key sets context and keeps body", func(t *testing.T) {
+		body := `{"model":"gpt-4o","messages":[]}`
+		w := do(http.MethodPost, "/v1/chat/completions", "Bearer sk-aaaaaaaaaaaaaaaa", body)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			TokenID int    `json:"token_id"`
+			UserID  int    `json:"user_id"`
+			Body    string `json:"body"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 1, resp.TokenID)
+		assert.Equal(t, 7, resp.UserID)
+		assert.Equal(t, body, resp.Body)
+	})
+
+	t.Run("listing models skips model whitelist", func(t *testing.T) {
+		w := do(http.MethodGet, "/v1/models", "Bearer sk-aaaaaaaaaaaaaaaa", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/v1/models", "", "").Code)
+	})
+
+	t.Run("rate limit keys off token id", func(t *testing.T) {
+		var key string
+		r := gin.New()
+		r.POST("/v1/embeddings", APIKeyAuth(validate), func(c *gin.Context) {
+			key = TokenKeyFunc(c)
+		})
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{"model":"gpt-4o"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer sk-aaaaaaaaaaaaaaaa")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, "token:1", key)
+	})
+}
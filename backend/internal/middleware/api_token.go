@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// APITokenKey API Token 在上下文中的键
+const APITokenKey = "api_token"
+
+// APITokenAuthenticator 校验 API Key 并返回对应的 Token
+type APITokenAuthenticator func(ctx context.Context, key string, ip string) (*model.Token, error)
+
+// APITokenMiddleware API Token 鉴权中间件
+//
+// 未携带 Authorization 的请求保持匿名访问；携带时必须是有效的 API Token，
+// 校验通过后将 Token 与其所有者写入上下文。Anthropic SDK 通过 x-api-key 传递密钥，
+// 没有 Authorization 时使用该请求头。
+func APITokenMiddleware(authenticate APITokenAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		key := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+		if authHeader == "" {
+			key = strings.TrimSpace(c.GetHeader("x-api-key"))
+			if key == "" {
+				c.Next()
+				return
+			}
+		} else if key == "" || key == authHeader {
+			utils.Unauthorized(c, "无效的 API Key")
+			c.Abort()
+			return
+		}
+
+		token, err := authenticate(c.Request.Context(), key, c.ClientIP())
+		if err != nil || token == nil {
+			utils.Unauthorized(c, "无效的 API Key")
+			c.Abort()
+			return
+		}
+
+		c.Set(APITokenKey, token)
+		c.Set(UserIDKey, token.UserID)
+		c.Next()
+	}
+}
+
+// APITokenFromContext 获取当前请求的 API Token，匿名访问时返回 nil
+func APITokenFromContext(c *gin.Context) *model.Token {
+	v, ok := c.Get(APITokenKey)
+	if !ok {
+		return nil
+	}
+	token, _ := v.(*model.Token)
+	return token
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPITokenMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tokens := map[string]*model.Token{
+		"key-a": {ID: 1, UserID: 7, ProjectID: sql.NullInt64{Int64: 3, Valid: true}},
+		"key-b": {ID: 2, UserID: 8},
+	}
+	authenticate := func(ctx context.Context, key, ip string) (*model.Token, error) {
+		if token, ok := tokens[key]; ok {
+			return token, nil
+		}
+		return nil, errors.New("token not found")
+	}
+
+	r := gin.New()
+	r.POST("/v1/chat/completions", APITokenMiddleware(authenticate), func(c *gin.Context) {
+		token := APITokenFromContext(c)
+		if token == nil {
+			c.JSON(http.StatusOK, gin.H{"anonymous": true})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"token_id": token.ID, "user_id": c.GetInt(UserIDKey)})
+	})
+
+	do := func(auth string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("anonymous", func(t *testing.T) {
+		w := do("")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"anonymous":true}`, w.Body.String())
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, do("Bearer key-x").Code)
+	})
+
+	t.Run("malformed header", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, do("key-a").Code)
+		assert.Equal(t, http.StatusUnauthorized, do("Bearer ").Code)
+	})
+
+	t.Run("each key maps to its own token", func(t *testing.T) {
+		w := do("Bearer key-a")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"token_id":1,"user_id":7}`, w.Body.String())
+
+		w = do("Bearer key-b")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"token_id":2,"user_id":8}`, w.Body.String())
+	})
+
+	t.Run("anthropic x-api-key header", func(t *testing.T) {
+		doKey := func(key string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			req.Header.Set("x-api-key", key)
+			r.ServeHTTP(w, req)
+			return w
+		}
+		w := doKey("key-b")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"token_id":2,"user_id":8}`, w.Body.String())
+		assert.Equal(t, http.StatusUnauthorized, doKey("key-x").Code)
+	})
+}
@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
 )
 
 const (
@@ -13,28 +18,65 @@ const (
 	UserIDKey = "user_id"
 	// TokenKey 令牌在上下文中的键
 	TokenKey = "token"
+	// RoleKey 用户角色在上下文中的键
+	RoleKey = "role"
+	// SessionKey 登录会话 ID 在上下文中的键
+	SessionKey = "session_id"
 )
 
 // Claims JWT 声明
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Role      int    `json:"role"`
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// ExtractUserID 从上下文中提取用户 ID
+// UnmarshalJSON 兼容数字形式的 user_id（用户服务签发的令牌使用整数 ID）
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	type alias Claims
+	aux := struct {
+		*alias
+		UserID json.RawMessage `json:"user_id"`
+	}{alias: (*alias)(c)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	c.UserID = ""
+	if len(aux.UserID) == 0 || string(aux.UserID) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(aux.UserID, &c.UserID); err == nil {
+		return nil
+	}
+	var id json.Number
+	if err := json.Unmarshal(aux.UserID, &id); err != nil {
+		return fmt.Errorf("invalid user_id claim: %w", err)
+	}
+	c.UserID = id.String()
+	return nil
+}
+
+// ExtractUserID 从上下文中提取字符串形式的用户 ID
 func ExtractUserID(c *gin.Context) (string, error) {
-	userID, ok := c.Get(UserIDKey)
-	if !ok {
+	if _, ok := c.Get(UserIDKey); !ok {
 		return "", errors.New("user id not found in context")
 	}
 
-	userIDStr, ok := userID.(string)
+	userID, ok := UserIDFromContext(c)
 	if !ok {
-		return "", errors.New("invalid user id type")
+		return "", errors.New("invalid user id")
 	}
 
-	return userIDStr, nil
+	return strconv.Itoa(userID), nil
+}
+
+// ClaimsUserID 令牌中的用户 ID，用户 ID 均为正整数，其他值视为无效令牌
+func ClaimsUserID(claims *Claims) (int, bool) {
+	userID, err := strconv.Atoi(claims.UserID)
+	return userID, err == nil && userID > 0
 }
 
 // ExtractToken 从上下文中提取令牌
@@ -75,8 +117,17 @@ func ParseToken(tokenString string, signingKey []byte) (*Claims, error) {
 	return claims, nil
 }
 
+// UserStatusLookup 查询用户当前状态，用户不存在时 exists 为 false
+type UserStatusLookup func(ctx context.Context, userID int) (status int, exists bool, err error)
+
 // AuthMiddleware JWT 认证中间件
 func AuthMiddleware(signingKey []byte) gin.HandlerFunc {
+	return AuthMiddlewareWithStatus(signingKey, nil)
+}
+
+// AuthMiddlewareWithStatus JWT 认证中间件，lookup 不为空时每次请求校验用户状态，
+// 被停用的用户即使持有未过期的访问令牌也会被拒绝
+func AuthMiddlewareWithStatus(signingKey []byte, lookup UserStatusLookup) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 从请求头中获取令牌
 		tokenString, err := extractTokenFromHeader(c)
@@ -94,14 +145,46 @@ func AuthMiddleware(signingKey []byte) gin.HandlerFunc {
 			return
 		}
 
-		// 将用户 ID 存储在上下文中
-		c.Set(UserIDKey, claims.UserID)
+		userID, ok := ClaimsUserID(claims)
+		if !ok {
+			c.JSON(401, gin.H{"error": "invalid token"})
+			c.Abort()
+			return
+		}
+
+		if lookup != nil && !checkUserStatus(c, userID, lookup) {
+			c.Abort()
+			return
+		}
+
+		// 将用户 ID 以 int 存储在上下文中，c.GetInt(UserIDKey) 与 UserIDFromContext 均可读取
+		c.Set(UserIDKey, userID)
+		c.Set(RoleKey, claims.Role)
 		c.Set(TokenKey, tokenString)
+		c.Set(SessionKey, claims.SessionID)
 
 		c.Next()
 	}
 }
 
+// checkUserStatus 校验令牌所属用户仍处于可用状态，不可用时写入响应并返回 false
+func checkUserStatus(c *gin.Context, userID int, lookup UserStatusLookup) bool {
+	status, exists, err := lookup(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalError(c, "failed to verify user status")
+		return false
+	}
+	if !exists {
+		utils.Unauthorized(c, "用户不存在")
+		return false
+	}
+	if status == model.UserStatusDisabled {
+		utils.RespondError(c, utils.NewAppError(utils.CodeUserSuspended, "账号已被停用"))
+		return false
+	}
+	return true
+}
+
 // extractTokenFromHeader 从请求头中提取令牌
 func extractTokenFromHeader(c *gin.Context) (string, error) {
 	const bearerSchema = "Bearer "
@@ -162,9 +245,15 @@ func OptionalAuthMiddleware(signingKey []byte) gin.HandlerFunc {
 			c.Next()
 			return
 		}
+		userID, ok := ClaimsUserID(claims)
+		if !ok {
+			c.Next()
+			return
+		}
 
 		// 将用户 ID 存储在上下文中
-		c.Set(UserIDKey, claims.UserID)
+		c.Set(UserIDKey, userID)
+		c.Set(RoleKey, claims.Role)
 		c.Set(TokenKey, tokenString)
 
 		c.Next()
@@ -3,6 +3,9 @@ package middleware
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -68,7 +71,8 @@ func CachedAuthMiddleware(signingKey []byte, cacheManager *cache.CacheManager) g
 		}
 
 		// 将用户信息和令牌存储在上下文中
-		c.Set(UserIDKey, claims.UserID)
+		c.Set(UserIDKey, userID)
+		c.Set(RoleKey, claims.Role)
 		c.Set(TokenKey, tokenString)
 		c.Set("user_cache", userCache)
 
@@ -164,7 +168,8 @@ func MultiAuthMiddleware(signingKey []byte, cacheManager *cache.CacheManager) gi
 		}
 
 		// 设置上下文
-		c.Set(UserIDKey, claims.UserID)
+		c.Set(UserIDKey, userID)
+		c.Set(RoleKey, claims.Role)
 		c.Set(TokenKey, tokenString)
 		c.Set("user_cache", userCache)
 		// 认证方法名称已经在其他地方设置
@@ -177,7 +182,8 @@ func MultiAuthMiddleware(signingKey []byte, cacheManager *cache.CacheManager) gi
 
 // getAuthMethodName 获取认证方法的名称
 func getAuthMethodName(method func(*gin.Context) (string, error)) string {
-	switch fmt.Sprintf("%v", method) {
+	name := runtime.FuncForPC(reflect.ValueOf(method).Pointer()).Name()
+	switch name[strings.LastIndex(name, ".")+1:] {
 	case "ExtractTokenFromBearer":
 		return "bearer"
 	case "ExtractTokenFromClaudeHeader":
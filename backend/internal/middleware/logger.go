@@ -25,6 +25,7 @@ func LoggerMiddleware() gin.HandlerFunc {
 
 		// 记录日志
 		fields := []zap.Field{
+			zap.String("request_id", c.GetString("request_id")),
 			zap.String("method", c.Request.Method),
 			zap.String("path", path),
 			zap.String("query", query),
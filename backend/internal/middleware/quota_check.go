@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/quota"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+// QuotaCheckConfig 网关额度预检配置
+type QuotaCheckConfig struct {
+	BillingURL    string        // 计费服务地址
+	Secret        string        // 内部请求签名密钥
+	Timeout       time.Duration // 调用超时，应远小于上游请求耗时
+	FailOpen      bool          // 计费服务不可用时放行
+	CacheTTL      time.Duration // 单个用户余额的缓存时间
+	EstimatedCost float64       // 单次请求的预估费用
+	Client        *http.Client
+}
+
+type quotaCacheEntry struct {
+	balance   float64
+	expiresAt time.Time
+}
+
+// QuotaChecker 在转发前调用计费服务检查额度，避免无额度的请求穿过多个服务才返回 402
+type QuotaChecker struct {
+	cfg    QuotaCheckConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	cache     map[int]quotaCacheEntry
+	lastSweep time.Time
+}
+
+// NewQuotaChecker 创建额度预检器
+func NewQuotaChecker(cfg QuotaCheckConfig) *QuotaChecker {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 200 * time.Millisecond
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Timeout}
+	}
+
+	return &QuotaChecker{
+		cfg:    cfg,
+		client: client,
+		cache:  make(map[int]quotaCacheEntry),
+	}
+}
+
+// Check 检查用户额度，余额在 CacheTTL 内复用缓存
+func (q *QuotaChecker) Check(ctx context.Context, userID int, estimatedCost float64) (*quota.CheckResult, error) {
+	if balance, ok := q.cached(userID); ok {
+		return &quota.CheckResult{
+			UserID:           userID,
+			Allow:            quota.Allows(balance, estimatedCost),
+			RemainingBalance: balance,
+			EstimatedCost:    estimatedCost,
+		}, nil
+	}
+
+	result, err := q.fetch(ctx, userID, estimatedCost)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.cfg.CacheTTL > 0 {
+		q.store(userID, result.RemainingBalance)
+	}
+
+	return result, nil
+}
+
+// store 写入余额缓存，每过一个 CacheTTL 顺带清理一次过期条目，
+// 避免只访问过一次的用户一直留在内存里
+func (q *QuotaChecker) store(userID int, balance float64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(q.lastSweep) >= q.cfg.CacheTTL {
+		for id, entry := range q.cache {
+			if now.After(entry.expiresAt) {
+				delete(q.cache, id)
+			}
+		}
+		q.lastSweep = now
+	}
+	q.cache[userID] = quotaCacheEntry{balance: balance, expiresAt: now.Add(q.cfg.CacheTTL)}
+}
+
+// Invalidate 使用户的缓存失效（收到余额变更通知时调用）
+func (q *QuotaChecker) Invalidate(userID int) {
+	q.mu.Lock()
+	delete(q.cache, userID)
+	q.mu.Unlock()
+}
+
+// ListenBalanceChanges 订阅结算路径发布的余额变更通知，返回取消订阅函数
+func (q *QuotaChecker) ListenBalanceChanges(ctx context.Context, client redis.UniversalClient) func() {
+	return quota.SubscribeBalanceChanges(ctx, client, q.Invalidate)
+}
+
+// Middleware 额度预检中间件，需放在鉴权中间件之后
+func (q *QuotaChecker) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := UserIDFromContext(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), q.cfg.Timeout)
+		result, err := q.Check(ctx, userID, q.cfg.EstimatedCost)
+		cancel()
+
+		if err != nil {
+			if q.cfg.FailOpen {
+				logger.Warn("quota pre-check unavailable, failing open", zap.Int("user_id", userID), zap.Error(err))
+				c.Next()
+				return
+			}
+			utils.Error(c, http.StatusServiceUnavailable, utils.ErrInternal, "额度检查暂不可用，请稍后再试", nil)
+			c.Abort()
+			return
+		}
+
+		if !result.Allow {
+			utils.Error(c, http.StatusPaymentRequired, utils.ErrInsufficientQuota, "", gin.H{
+				"remaining_balance": result.RemainingBalance,
+				"estimated_cost":    result.EstimatedCost,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// cached 读取未过期的余额缓存
+func (q *QuotaChecker) cached(userID int) (float64, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, ok := q.cache[userID]
+	if !ok {
+		return 0, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(q.cache, userID)
+		return 0, false
+	}
+	return entry.balance, true
+}
+
+// fetch 调用计费服务的内部预检接口
+func (q *QuotaChecker) fetch(ctx context.Context, userID int, estimatedCost float64) (*quota.CheckResult, error) {
+	query := url.Values{}
+	query.Set("user_id", strconv.Itoa(userID))
+	query.Set("estimated_cost", strconv.FormatFloat(estimatedCost, 'f', -1, 64))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, q.cfg.BillingURL+"/internal/quota/check?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	SignInternalRequest(req, q.cfg.Secret, nil)
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("quota check request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("quota check returned http %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data *quota.CheckResult `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode quota check response: %w", err)
+	}
+	if body.Data == nil {
+		return nil, fmt.Errorf("empty quota check response")
+	}
+
+	return body.Data, nil
+}
+
+// UserIDFromContext 读取鉴权中间件写入的用户 ID（兼容字符串与整数）
+func UserIDFromContext(c *gin.Context) (int, bool) {
+	v, ok := c.Get(UserIDKey)
+	if !ok {
+		return 0, false
+	}
+
+	switch id := v.(type) {
+	case int:
+		return id, id > 0
+	case int64:
+		return int(id), id > 0
+	case string:
+		n, err := strconv.Atoi(id)
+		return n, err == nil && n > 0
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,49 @@
+//go:build integration
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/quota"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 需要本地 Redis：REDIS_ADDR（默认 localhost:6379）
+func TestQuotaCheckInvalidatedByBalanceNotification(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	require.NoError(t, client.Ping(context.Background()).Err())
+
+	billing := newFakeBillingService(t, 0)
+	checker := NewQuotaChecker(QuotaCheckConfig{
+		BillingURL:    billing.URL,
+		Secret:        testInternalSecret,
+		Timeout:       time.Second,
+		CacheTTL:      time.Minute,
+		EstimatedCost: 1,
+	})
+	stop := checker.ListenBalanceChanges(context.Background(), client)
+	defer stop()
+
+	r := newGatewayRouter(checker)
+	assert.Equal(t, http.StatusPaymentRequired, doChat(r).Code)
+
+	// 模拟结算路径：充值后发布余额变更
+	billing.balance.Store(500)
+	require.NoError(t, quota.NewRedisBalanceNotifier(client).NotifyBalanceChanged(context.Background(), 42))
+
+	assert.Eventually(t, func() bool {
+		return doChat(r).Code == http.StatusOK
+	}, 2*time.Second, 50*time.Millisecond)
+}
@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/quota"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testInternalSecret = "internal-secret"
+
+// fakeBillingService 模拟计费服务的内部预检接口
+type fakeBillingService struct {
+	*httptest.Server
+	balance atomic.Int64
+	calls   atomic.Int32
+}
+
+func newFakeBillingService(t *testing.T, balance int64) *fakeBillingService {
+	gin.SetMode(gin.TestMode)
+	fb := &fakeBillingService{}
+	fb.balance.Store(balance)
+
+	r := gin.New()
+	internal := r.Group("/internal")
+	internal.Use(InternalAuthMiddleware(testInternalSecret))
+	internal.GET("/quota/check", func(c *gin.Context) {
+		fb.calls.Add(1)
+		userID, _ := strconv.Atoi(c.Query("user_id"))
+		cost, _ := strconv.ParseFloat(c.Query("estimated_cost"), 64)
+		b := float64(fb.balance.Load())
+		utils.Success(c, &quota.CheckResult{
+			UserID:           userID,
+			Allow:            quota.Allows(b, cost),
+			RemainingBalance: b,
+			EstimatedCost:    cost,
+		}, "")
+	})
+
+	fb.Server = httptest.NewServer(r)
+	t.Cleanup(fb.Close)
+	return fb
+}
+
+// newGatewayRouter 模拟网关：鉴权后经过额度预检再转发
+func newGatewayRouter(checker *QuotaChecker) *gin.Engine {
+	r := gin.New()
+	r.POST("/chat/messages", func(c *gin.Context) {
+		c.Set(UserIDKey, "42")
+		c.Next()
+	}, checker.Middleware(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"proxied": true})
+	})
+	return r
+}
+
+func doChat(r *gin.Engine) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/chat/messages", nil)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestQuotaCheckAllowAndDeny(t *testing.T) {
+	billing := newFakeBillingService(t, 100)
+	checker := NewQuotaChecker(QuotaCheckConfig{
+		BillingURL:    billing.URL,
+		Secret:        testInternalSecret,
+		Timeout:       time.Second,
+		EstimatedCost: 10,
+	})
+	r := newGatewayRouter(checker)
+
+	w := doChat(r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	billing.balance.Store(5)
+	checker.Invalidate(42)
+
+	w = doChat(r)
+	assert.Equal(t, http.StatusPaymentRequired, w.Code)
+
+	var resp utils.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, utils.ErrInsufficientQuota, resp.Error.Code)
+}
+
+func TestQuotaCheckFailOpenOnOutage(t *testing.T) {
+	billing := newFakeBillingService(t, 100)
+	url := billing.URL
+	billing.Close()
+
+	t.Run("fail_open", func(t *testing.T) {
+		checker := NewQuotaChecker(QuotaCheckConfig{BillingURL: url, Secret: testInternalSecret, FailOpen: true, Timeout: 100 * time.Millisecond})
+		w := doChat(newGatewayRouter(checker))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("fail_closed", func(t *testing.T) {
+		checker := NewQuotaChecker(QuotaCheckConfig{BillingURL: url, Secret: testInternalSecret, FailOpen: false, Timeout: 100 * time.Millisecond})
+		w := doChat(newGatewayRouter(checker))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+}
+
+func TestQuotaCheckCacheInvalidatedByRecharge(t *testing.T) {
+	billing := newFakeBillingService(t, 0)
+	checker := NewQuotaChecker(QuotaCheckConfig{
+		BillingURL:    billing.URL,
+		Secret:        testInternalSecret,
+		Timeout:       time.Second,
+		CacheTTL:      time.Minute,
+		EstimatedCost: 1,
+	})
+	r := newGatewayRouter(checker)
+
+	assert.Equal(t, http.StatusPaymentRequired, doChat(r).Code)
+	assert.Equal(t, http.StatusPaymentRequired, doChat(r).Code)
+	assert.Equal(t, int32(1), billing.calls.Load(), "second check should be served from cache")
+
+	// 充值后结算路径发布余额变更，网关收到通知后清除缓存
+	billing.balance.Store(500)
+	assert.Equal(t, http.StatusPaymentRequired, doChat(r).Code, "stale cache until notified")
+
+	checker.Invalidate(42)
+	assert.Equal(t, http.StatusOK, doChat(r).Code)
+	assert.Equal(t, int32(2), billing.calls.Load())
+}
+
+func TestQuotaCheckCacheSweepsExpiredEntries(t *testing.T) {
+	billing := newFakeBillingService(t, 100)
+	checker := NewQuotaChecker(QuotaCheckConfig{
+		BillingURL: billing.URL,
+		Secret:     testInternalSecret,
+		Timeout:    time.Second,
+		CacheTTL:   20 * time.Millisecond,
+	})
+
+	for userID := 1; userID <= 50; userID++ {
+		_, err := checker.Check(context.Background(), userID, 1)
+		require.NoError(t, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	_, err := checker.Check(context.Background(), 51, 1)
+	require.NoError(t, err)
+
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+	assert.Len(t, checker.cache, 1, "expired entries should be swept on the next write")
+}
+
+func TestInternalAuthMiddleware(t *testing.T) {
+	billing := newFakeBillingService(t, 100)
+
+	t.Run("unsigned", func(t *testing.T) {
+		resp, err := http.Get(billing.URL + "/internal/quota/check?user_id=1")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("tampered_query", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, billing.URL+"/internal/quota/check?user_id=1", nil)
+		SignInternalRequest(req, testInternalSecret, nil)
+		req.URL.RawQuery = "user_id=2"
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("wrong_secret", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, billing.URL+"/internal/quota/check?user_id=1", nil)
+		SignInternalRequest(req, "other-secret", nil)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("signed", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, billing.URL+"/internal/quota/check?user_id=1", nil)
+		SignInternalRequest(req, testInternalSecret, nil)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
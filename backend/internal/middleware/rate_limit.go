@@ -2,41 +2,94 @@ package middleware
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
 )
 
+// RateLimitKeyFunc 返回请求的限流维度（如客户端 IP、API Token、用户）
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// RateLimitResult 一次限流检查的结果
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // 被拒绝时距离窗口内最早一次请求过期的时间
+}
+
+// RateLimiter 限流计数存储
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error)
+}
+
 type RateLimitConfig struct {
-	Rate  int           // 每秒请求数
-	Burst int           // 突发容量
-	TTL   time.Duration // 过期时间
+	Limit      int              // 窗口内允许的请求数
+	Window     time.Duration    // 滑动窗口长度，默认 1 分钟
+	KeyFunc    RateLimitKeyFunc // 限流维度，默认客户端 IP
+	RoleLimits map[int]int      // 角色下限 → 窗口内请求数，按不超过用户角色的最高档位生效
+	Limiter    RateLimiter      // 计数存储，默认使用全局 Redis
+
+	mu sync.RWMutex // 保护 Limit、Window、RoleLimits，运行中通过 Update 修改
 }
 
-// RateLimitMiddleware 限流中间件
+// Update 运行中修改限额与窗口（配置热加载），window 不大于 0 时保持原窗口
+func (cfg *RateLimitConfig) Update(limit int, window time.Duration, roleLimits map[int]int) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.Limit = limit
+	if window > 0 {
+		cfg.Window = window
+	}
+	cfg.RoleLimits = roleLimits
+}
+
+// RateLimitMiddleware 滑动窗口限流中间件
+//
+// 计数保存在 Redis 中，多个网关副本共享同一限额；响应带 X-RateLimit-Limit、
+// X-RateLimit-Remaining，被拒绝时带 Retry-After。
 func RateLimitMiddleware(cfg *RateLimitConfig) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 获取限流 Key（优先使用 user_id，否则使用 IP）
-		var key string
-		if userID, exists := c.Get("user_id"); exists {
-			key = fmt.Sprintf("rate_limit:user:%d", userID.(int))
-		} else {
-			key = fmt.Sprintf("rate_limit:ip:%s", c.ClientIP())
-		}
+	keyFunc := cfg.KeyFunc
+	if keyFunc == nil {
+		keyFunc = IPKeyFunc
+	}
+	limiter := cfg.Limiter
+	if limiter == nil {
+		limiter = NewRedisRateLimiter(database.RedisClient)
+	}
 
-		// 检查是否允许请求
-		allowed, err := checkRateLimit(c.Request.Context(), key, cfg)
+	return func(c *gin.Context) {
+		limit, window := cfg.limitFor(c)
+		result, err := limiter.Allow(c.Request.Context(), "rate_limit:"+keyFunc(c), limit, window)
 		if err != nil {
 			utils.InternalError(c, "限流检查失败")
 			c.Abort()
 			return
 		}
 
-		if !allowed {
-			utils.Error(c, 429, utils.ErrRateLimitExceeded, "请求过于频繁，请稍后再试", nil)
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			utils.Error(c, http.StatusTooManyRequests, utils.ErrRateLimitExceeded, "请求过于频繁，请稍后再试", nil)
 			c.Abort()
 			return
 		}
@@ -45,60 +98,131 @@ func RateLimitMiddleware(cfg *RateLimitConfig) gin.HandlerFunc {
 	}
 }
 
-// checkRateLimit 使用令牌桶算法检查限流
-func checkRateLimit(ctx context.Context, key string, cfg *RateLimitConfig) (bool, error) {
-	now := time.Now().Unix()
-
-	// Lua 脚本实现令牌桶算法
-	script := `
-        local key = KEYS[1]
-        local rate = tonumber(ARGV[1])
-        local burst = tonumber(ARGV[2])
-        local now = tonumber(ARGV[3])
-        local ttl = tonumber(ARGV[4])
-        
-        local last = redis.call('HGET', key, 'last')
-        local tokens = redis.call('HGET', key, 'tokens')
-        
-        if last == false then
-            last = now
-            tokens = burst
-        else
-            last = tonumber(last)
-            tokens = tonumber(tokens)
-            
-            -- 计算新增令牌
-            local elapsed = now - last
-            local newTokens = math.min(burst, tokens + elapsed * rate)
-            tokens = newTokens
-        end
-        
-        -- 消费一个令牌
-        if tokens >= 1 then
-            tokens = tokens - 1
-            redis.call('HMSET', key, 'last', now, 'tokens', tokens)
-            redis.call('EXPIRE', key, ttl)
-            return 1
-        else
-            return 0
-        end
-    `
-
-	result, err := database.RedisClient.Eval(
-		ctx,
-		script,
-		[]string{key},
-		cfg.Rate,
-		cfg.Burst,
-		now,
-		int(cfg.TTL.Seconds()),
-	).Result()
+// limitFor 按用户角色选择限额与窗口，未登录或未配置角色限额时使用默认限额
+func (cfg *RateLimitConfig) limitFor(c *gin.Context) (int, time.Duration) {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
 
-	if err != nil {
-		return false, err
+	window := cfg.Window
+	if window <= 0 {
+		window = time.Minute
+	}
+	if len(cfg.RoleLimits) == 0 {
+		return cfg.Limit, window
+	}
+	role, ok := c.Get(RoleKey)
+	if !ok {
+		return cfg.Limit, window
+	}
+	r, ok := role.(int)
+	if !ok {
+		return cfg.Limit, window
+	}
+
+	thresholds := make([]int, 0, len(cfg.RoleLimits))
+	for minRole := range cfg.RoleLimits {
+		thresholds = append(thresholds, minRole)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(thresholds)))
+	for _, minRole := range thresholds {
+		if r >= minRole {
+			return cfg.RoleLimits[minRole], window
+		}
+	}
+	return cfg.Limit, window
+}
+
+// IPKeyFunc 按客户端 IP 限流
+func IPKeyFunc(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// TokenKeyFunc 按 API Token 或登录用户限流，匿名请求按客户端 IP
+//
+// 优先使用鉴权中间件写入上下文的 Token 与用户；未经鉴权时使用请求携带的
+// API Key（只保存摘要），因此应放在鉴权中间件之后，否则伪造的 Key 各自计数。
+func TokenKeyFunc(c *gin.Context) string {
+	if token := APITokenFromContext(c); token != nil {
+		return fmt.Sprintf("token:%d", token.ID)
+	}
+	if userID, ok := c.Get(UserIDKey); ok {
+		return fmt.Sprintf("user:%v", userID)
 	}
 
-	return result.(int64) == 1, nil
+	key := c.GetHeader("X-API-Key")
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		key = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	if key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:16])
+	}
+	return IPKeyFunc(c)
 }
 
+// slidingWindowScript 滑动窗口计数：有序集合保存窗口内每次请求的时间（使用 Redis 服务器时间，多副本一致）
+const slidingWindowScript = `
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local member = ARGV[3]
+
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
 
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+if count < limit then
+	redis.call('ZADD', key, now, member)
+	redis.call('PEXPIRE', key, window)
+	return {1, limit - count - 1, 0}
+end
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+local retry = window
+if oldest[2] then
+	retry = tonumber(oldest[2]) + window - now
+end
+return {0, 0, retry}
+`
+
+// RedisRateLimiter 基于 Redis 有序集合的滑动窗口限流
+type RedisRateLimiter struct {
+	client redis.UniversalClient
+	script *redis.Script
+}
+
+// NewRedisRateLimiter 创建 Redis 滑动窗口限流
+func NewRedisRateLimiter(client redis.UniversalClient) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client: client,
+		script: redis.NewScript(slidingWindowScript),
+	}
+}
+
+// Allow 在窗口内计数未达上限时记录本次请求
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	if l.client == nil {
+		return nil, fmt.Errorf("redis client not initialized")
+	}
+
+	res, err := l.script.Run(ctx, l.client, []string{key}, limit, window.Milliseconds(), uuid.NewString()).Result()
+	if err != nil {
+		return nil, err
+	}
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit result: %v", res)
+	}
+
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(int64)
+	retry, _ := values[2].(int64)
+	return &RateLimitResult{
+		Allowed:    allowed == 1,
+		Limit:      limit,
+		Remaining:  int(remaining),
+		RetryAfter: time.Duration(retry) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,54 @@
+//go:build integration
+
+package middleware
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 需要本地 Redis：REDIS_ADDR（默认 localhost:6379）
+func TestRedisRateLimiterSlidingWindow(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	ctx := context.Background()
+	require.NoError(t, client.Ping(ctx).Err())
+
+	key := "rate_limit:test:" + uuid.NewString()
+	defer client.Del(ctx, key)
+
+	// 两个副本共享同一计数
+	a, b := NewRedisRateLimiter(client), NewRedisRateLimiter(client)
+	window := 500 * time.Millisecond
+
+	res, err := a.Allow(ctx, key, 2, window)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+
+	res, err = b.Allow(ctx, key, 2, window)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	res, err = a.Allow(ctx, key, 2, window)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.True(t, res.RetryAfter > 0 && res.RetryAfter <= window, "retry after %v", res.RetryAfter)
+
+	time.Sleep(res.RetryAfter + 50*time.Millisecond)
+	res, err = b.Allow(ctx, key, 2, window)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed, "window slides once the oldest request expires")
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingLimiter 按 Key 计数的固定限额
+type countingLimiter struct {
+	counts map[string]int
+}
+
+func newCountingLimiter() *countingLimiter {
+	return &countingLimiter{counts: make(map[string]int)}
+}
+
+func (l *countingLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	if l.counts[key] >= limit {
+		return &RateLimitResult{Allowed: false, Limit: limit, RetryAfter: 1500 * time.Millisecond}, nil
+	}
+	l.counts[key]++
+	return &RateLimitResult{Allowed: true, Limit: limit, Remaining: limit - l.counts[key]}, nil
+}
+
+func TestRateLimitMiddlewareHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := newCountingLimiter()
+
+	r := gin.New()
+	r.GET("/ping", RateLimitMiddleware(&RateLimitConfig{Limit: 2, Limiter: limiter}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, do().Code)
+
+	w = do()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "2", w.Header().Get("Retry-After"), "retry-after rounds up to whole seconds")
+
+	assert.Equal(t, 2, limiter.counts["rate_limit:ip:10.0.0.1"], "default key is the client IP")
+}
+
+func TestTokenKeyFunc(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	keyFor := func(setup func(c *gin.Context), header map[string]string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest(http.MethodGet, "/", nil)
+		c.Request.RemoteAddr = "203.0.113.9:5000"
+		for k, v := range header {
+			c.Request.Header.Set(k, v)
+		}
+		if setup != nil {
+			setup(c)
+		}
+		return TokenKeyFunc(c)
+	}
+
+	assert.Equal(t, "token:7", keyFor(func(c *gin.Context) {
+		c.Set(APITokenKey, &model.Token{ID: 7, UserID: 42})
+		c.Set(UserIDKey, 42)
+	}, nil))
+	assert.Equal(t, "user:42", keyFor(func(c *gin.Context) { c.Set(UserIDKey, "42") }, nil))
+
+	// 未经鉴权的 Key 只保存摘要
+	keyA := keyFor(nil, map[string]string{"Authorization": "Bearer sk-aaa"})
+	keyB := keyFor(nil, map[string]string{"X-API-Key": "sk-bbb"})
+	assert.Regexp(t, `^key:[0-9a-f]{32}$`, keyA)
+	assert.NotContains(t, keyA, "sk-aaa")
+	assert.NotEqual(t, keyA, keyB)
+	assert.Equal(t, keyA, keyFor(nil, map[string]string{"Authorization": "Bearer sk-aaa"}))
+
+	assert.Equal(t, "ip:203.0.113.9", keyFor(nil, nil))
+}
+
+func TestRateLimitPerTokenBehindSharedIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := newCountingLimiter()
+
+	r := gin.New()
+	r.GET("/chat", func(c *gin.Context) {
+		c.Set(UserIDKey, c.GetHeader("X-Test-User"))
+		c.Next()
+	}, RateLimitMiddleware(&RateLimitConfig{Limit: 1, KeyFunc: TokenKeyFunc, Limiter: limiter}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func(user string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/chat", nil)
+		req.RemoteAddr = "198.51.100.1:80" // 同一 NAT 出口
+		req.Header.Set("X-Test-User", user)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do("1"))
+	assert.Equal(t, http.StatusTooManyRequests, do("1"))
+	assert.Equal(t, http.StatusOK, do("2"), "other users behind the same IP keep their own limit")
+}
+
+func TestRateLimitRoleLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := newCountingLimiter()
+	cfg := &RateLimitConfig{
+		Limit:      10,
+		KeyFunc:    TokenKeyFunc,
+		RoleLimits: map[int]int{10: 100, 100: 1000},
+		Limiter:    limiter,
+	}
+
+	r := gin.New()
+	r.GET("/", func(c *gin.Context) {
+		c.Set(UserIDKey, c.GetHeader("X-Test-User"))
+		if role, err := strconv.Atoi(c.GetHeader("X-Test-Role")); err == nil {
+			c.Set(RoleKey, role)
+		}
+		c.Next()
+	}, RateLimitMiddleware(cfg), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	limitFor := func(user, role string) string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Test-User", user)
+		if role != "" {
+			req.Header.Set("X-Test-Role", role)
+		}
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Header().Get("X-RateLimit-Limit")
+	}
+
+	assert.Equal(t, "10", limitFor("1", ""))
+	assert.Equal(t, "10", limitFor("2", "1"))
+	assert.Equal(t, "100", limitFor("3", "10"))
+	assert.Equal(t, "100", limitFor("4", "50"))
+	assert.Equal(t, "1000", limitFor("5", "100"))
+}
@@ -3,16 +3,20 @@ package middleware
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
 )
 
 const RequestIDKey = "X-Request-ID"
 
 // RequestIDMiddleware 为每个请求生成唯一 ID
+//
+// 请求已携带 X-Request-ID（如经网关转发）时沿用该 ID，只在缺失时生成新的；
+// ID 同时写入请求的 context，供日志与发往上游的请求使用。
 func RequestIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 尝试从 Header 获取 Request ID
 		requestID := c.GetHeader(RequestIDKey)
-		
+
 		// 如果没有，生成新的
 		if requestID == "" {
 			requestID = uuid.New().String()
@@ -21,8 +25,8 @@ func RequestIDMiddleware() gin.HandlerFunc {
 		// 设置到 Context 和响应 Header
 		c.Set("request_id", requestID)
 		c.Header(RequestIDKey, requestID)
+		c.Request = c.Request.WithContext(logger.ContextWithRequestID(c.Request.Context(), requestID))
 
 		c.Next()
 	}
 }
-
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// RoleMiddleware 要求 AuthMiddleware 解析出的角色不低于 minRole，需挂载在 AuthMiddleware 之后
+func RoleMiddleware(minRole int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get(RoleKey)
+		role, ok := value.(int)
+		if !exists || !ok {
+			utils.Error(c, http.StatusForbidden, utils.ErrForbidden, "role claim missing", gin.H{
+				"required_role": minRole,
+			})
+			c.Abort()
+			return
+		}
+
+		if role < minRole {
+			utils.Error(c, http.StatusForbidden, utils.ErrForbidden, "insufficient role", gin.H{
+				"required_role": minRole,
+				"role":          role,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
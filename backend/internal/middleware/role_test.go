@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serve := func(setRole func(c *gin.Context)) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.GET("/admin", func(c *gin.Context) {
+			setRole(c)
+			c.Next()
+		}, RoleMiddleware(100), func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/admin", nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	errorBody := func(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+		var body struct {
+			Success bool `json:"success"`
+			Error   struct {
+				Message string                 `json:"message"`
+				Details map[string]interface{} `json:"details"`
+			} `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.False(t, body.Success)
+		assert.NotEmpty(t, body.Error.Message)
+		return body.Error.Details
+	}
+
+	t.Run("missing claim", func(t *testing.T) {
+		w := serve(func(c *gin.Context) {})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.EqualValues(t, 100, errorBody(t, w)["required_role"])
+	})
+
+	t.Run("insufficient role", func(t *testing.T) {
+		w := serve(func(c *gin.Context) { c.Set(RoleKey, 1) })
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		details := errorBody(t, w)
+		assert.EqualValues(t, 100, details["required_role"])
+		assert.EqualValues(t, 1, details["role"])
+	})
+
+	t.Run("success", func(t *testing.T) {
+		w := serve(func(c *gin.Context) { c.Set(RoleKey, 100) })
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"ok":true}`, w.Body.String())
+	})
+}
+
+func TestAuthMiddlewareSetsRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := []byte("test-secret")
+
+	// 用户服务签发的令牌：整数 user_id 与 role
+	sign := func(role int) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user_id": 42,
+			"role":    role,
+			"exp":     time.Now().Add(time.Hour).Unix(),
+		})
+		s, err := token.SignedString(secret)
+		require.NoError(t, err)
+		return s
+	}
+
+	r := gin.New()
+	r.GET("/admin", AuthMiddleware(secret), RoleMiddleware(100), func(c *gin.Context) {
+		userID, err := ExtractUserID(c)
+		require.NoError(t, err)
+		c.JSON(http.StatusOK, gin.H{"user_id": userID})
+	})
+
+	do := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(sign(100))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id":"42"}`, w.Body.String())
+
+	assert.Equal(t, http.StatusForbidden, do(sign(1)).Code)
+}
+
+func TestAuthMiddlewareStoresNumericUserID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := []byte("test-secret")
+
+	sign := func(userID interface{}) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user_id": userID,
+			"exp":     time.Now().Add(time.Hour).Unix(),
+		})
+		s, err := token.SignedString(secret)
+		require.NoError(t, err)
+		return s
+	}
+
+	r := gin.New()
+	r.GET("/me", AuthMiddleware(secret), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetInt(UserIDKey)})
+	})
+
+	do := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 整数与字符串形式的 user_id 都以 int 写入上下文
+	for _, claim := range []interface{}{42, "42"} {
+		w := do(sign(claim))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"user_id":42}`, w.Body.String())
+	}
+
+	// 无法解析为正整数的用户 ID 不能混入用户 0
+	for _, claim := range []interface{}{"alice", 0, ""} {
+		assert.Equal(t, http.StatusUnauthorized, do(sign(claim)).Code, "claim %v", claim)
+	}
+}
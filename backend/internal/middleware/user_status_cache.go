@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserStatusCacheTTL 各服务缓存用户状态的时间，管理员停用账号后最长经过该时间在所有服务生效
+const UserStatusCacheTTL = 10 * time.Second
+
+// AuthMiddlewareWithCachedStatus JWT 认证中间件，按用户状态拒绝被停用的用户，状态在进程内缓存 UserStatusCacheTTL；
+// 用于用户服务之外的各服务，避免每个请求都查询用户表
+func AuthMiddlewareWithCachedStatus(signingKey []byte, lookup UserStatusLookup) gin.HandlerFunc {
+	return AuthMiddlewareWithStatus(signingKey, CacheUserStatus(lookup, UserStatusCacheTTL))
+}
+
+type userStatusEntry struct {
+	status    int
+	exists    bool
+	expiresAt time.Time
+}
+
+// userStatusCache 用户状态的进程内缓存，写入时每过一个 ttl 清理一次过期条目
+type userStatusCache struct {
+	lookup UserStatusLookup
+	ttl    time.Duration
+
+	mu        sync.Mutex
+	entries   map[int]userStatusEntry
+	lastSweep time.Time
+}
+
+// CacheUserStatus 为 lookup 增加 ttl 内的进程内缓存，查询出错时不缓存
+func CacheUserStatus(lookup UserStatusLookup, ttl time.Duration) UserStatusLookup {
+	cache := &userStatusCache{lookup: lookup, ttl: ttl, entries: make(map[int]userStatusEntry)}
+	return cache.get
+}
+
+func (c *userStatusCache) get(ctx context.Context, userID int) (int, bool, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.status, entry.exists, nil
+	}
+
+	status, exists, err := c.lookup(ctx, userID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastSweep) >= c.ttl {
+		for id, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, id)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.entries[userID] = userStatusEntry{status: status, exists: exists, expiresAt: now.Add(c.ttl)}
+	return status, exists, nil
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheUserStatus(t *testing.T) {
+	statuses := map[int]int{1: model.UserStatusActive}
+	calls := 0
+	var failNext error
+	lookup := CacheUserStatus(func(ctx context.Context, userID int) (int, bool, error) {
+		calls++
+		if failNext != nil {
+			err := failNext
+			failNext = nil
+			return 0, false, err
+		}
+		status, ok := statuses[userID]
+		return status, ok, nil
+	}, 30*time.Millisecond)
+	ctx := context.Background()
+
+	status, exists, err := lookup(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, model.UserStatusActive, status)
+
+	// 缓存期内停用不会立即生效，过期后重新查询
+	statuses[1] = model.UserStatusDisabled
+	status, _, _ = lookup(ctx, 1)
+	assert.Equal(t, model.UserStatusActive, status)
+	assert.Equal(t, 1, calls)
+
+	time.Sleep(40 * time.Millisecond)
+	status, _, _ = lookup(ctx, 1)
+	assert.Equal(t, model.UserStatusDisabled, status)
+	assert.Equal(t, 2, calls)
+
+	// 查询出错不缓存
+	failNext = errors.New("db down")
+	_, _, err = lookup(ctx, 2)
+	assert.Error(t, err)
+	_, exists, err = lookup(ctx, 2)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, 4, calls)
+}
@@ -4,25 +4,27 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
 )
 
 // BillingLog 计费日志
 type BillingLog struct {
-	ID           int        `gorm:"primaryKey" json:"id"`
-	UserID       int        `gorm:"index" json:"user_id"`              // 用户 ID
-	SessionID    *uuid.UUID `gorm:"type:uuid;index" json:"session_id"` // 会话 ID（可选）
-	MessageID    *uuid.UUID `gorm:"type:uuid;index" json:"message_id"` // 消息 ID（可选）
-	Model        string     `gorm:"size:100;index" json:"model"`       // 模型名称
-	InputTokens  int        `json:"input_tokens"`                      // 输入 Token 数
-	OutputTokens int        `json:"output_tokens"`                     // 输出 Token 数
-	TotalTokens  int        `json:"total_tokens"`                      // 总 Token 数
-	Cost         int64      `json:"cost"`                              // 费用（分）
-	CostUSD      float64    `json:"cost_usd"`                          // 费用（美元）
-	Status       int        `gorm:"default:1" json:"status"`           // 状态: 1=已记录 2=已计费 3=已退款
-	ErrorMessage string     `gorm:"type:text" json:"error_message"`    // 错误消息
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
-	DeletedAt    *time.Time `json:"deleted_at"`
+	ID              int        `gorm:"primaryKey" json:"id"`
+	UserID          int        `gorm:"index" json:"user_id"`              // 用户 ID
+	SessionID       *uuid.UUID `gorm:"type:uuid;index" json:"session_id"` // 会话 ID（可选）
+	MessageID       *uuid.UUID `gorm:"type:uuid;index" json:"message_id"` // 消息 ID（可选）
+	Model           string     `gorm:"size:100;index" json:"model"`       // 模型名称
+	InputTokens     int        `json:"input_tokens"`                      // 输入 Token 数
+	OutputTokens    int        `json:"output_tokens"`                     // 输出 Token 数
+	ReasoningTokens int        `json:"reasoning_tokens"`                  // 推理 Token 数（已包含在输出中）
+	TotalTokens     int        `json:"total_tokens"`                      // 总 Token 数
+	Cost            int64      `json:"cost"`                              // 费用（分）
+	CostUSD         float64    `json:"cost_usd"`                          // 费用（美元）
+	Status          int        `gorm:"default:1" json:"status"`           // 状态: 1=已记录 2=已计费 3=已退款
+	ErrorMessage    string     `gorm:"type:text" json:"error_message"`    // 错误消息
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	DeletedAt       *time.Time `json:"deleted_at"`
 }
 
 func (BillingLog) TableName() string {
@@ -63,4 +65,161 @@ func (QuotaLog) TableName() string {
 	return "quota_logs"
 }
 
+// QuotaReservation 中转请求开始前的额度预留，结算或释放后删除
+type QuotaReservation struct {
+	ID        string    `gorm:"size:64;primaryKey" json:"id"`
+	QuotaKey  string    `gorm:"size:64;index" json:"quota_key"` // QuotaManager 中的额度键，如 token:3
+	Amount    float64   `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+}
+
+func (QuotaReservation) TableName() string {
+	return "quota_reservations"
+}
+
+// BillingDeadLetter 重试耗尽的计费事件
+type BillingDeadLetter struct {
+	ID         int64          `gorm:"primaryKey" json:"id"`
+	EventID    string         `gorm:"size:100;index" json:"event_id"`
+	UserID     string         `gorm:"size:100;index" json:"user_id"`
+	ModelName  string         `gorm:"size:100" json:"model_name"`
+	Event      datatypes.JSON `gorm:"type:jsonb" json:"event"` // 原始事件 JSON
+	Reason     string         `gorm:"type:text" json:"reason"`
+	Attempts   int            `json:"attempts"`
+	Status     string         `gorm:"size:20;index" json:"status"` // pending, replaying, replayed, discarded
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	ResolvedAt *time.Time     `json:"resolved_at"`
+}
+
+func (BillingDeadLetter) TableName() string {
+	return "billing_dead_letters"
+}
+
+// 充值发票状态
+const (
+	RechargeInvoicePending = "pending" // 等待支付
+	RechargeInvoicePaid    = "paid"    // 已支付并入账
+	RechargeInvoiceFailed  = "failed"  // 支付金额或币种与发票不符，未入账，需人工处理
+)
+
+// RechargeInvoice 预付费充值发票，对应一次 Stripe Checkout 购买额度套餐
+type RechargeInvoice struct {
+	ID                int64      `gorm:"primaryKey" json:"id"`
+	UserID            int        `gorm:"index" json:"user_id"`
+	PackageID         string     `gorm:"size:50" json:"package_id"`
+	Quota             int64      `json:"quota"`        // 入账额度（分）
+	AmountCents       int64      `json:"amount_cents"` // 支付金额（最小货币单位）
+	Currency          string     `gorm:"size:10" json:"currency"`
+	Status            string     `gorm:"size:20;index" json:"status"` // pending, paid, failed
+	CheckoutSessionID string     `gorm:"size:255;uniqueIndex" json:"checkout_session_id"`
+	CheckoutURL       string     `gorm:"type:text" json:"checkout_url,omitempty"`
+	PaymentIntentID   string     `gorm:"size:255" json:"payment_intent_id,omitempty"`
+	ReceiptURL        string     `gorm:"type:text" json:"receipt_url,omitempty"`
+	FailureReason     string     `gorm:"type:text" json:"failure_reason,omitempty"`
+	PaidAt            *time.Time `json:"paid_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+func (RechargeInvoice) TableName() string {
+	return "recharge_invoices"
+}
+
+// AlertPreference 用户在某个渠道上的配额预警通知偏好，每个渠道一条
+type AlertPreference struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	UserID    string    `gorm:"size:100;uniqueIndex:idx_alert_preferences_user_channel" json:"user_id"`
+	Channel   string    `gorm:"size:20;uniqueIndex:idx_alert_preferences_user_channel" json:"channel"` // webhook, email
+	Target    string    `gorm:"size:500" json:"target"`                                                // Webhook 地址或邮箱
+	Secret    string    `gorm:"size:255" json:"-"`                                                     // Webhook 签名密钥
+	MinLevel  string    `gorm:"size:20" json:"min_level"`                                              // warning, critical, exhausted
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (AlertPreference) TableName() string {
+	return "alert_preferences"
+}
+
+// QuotaAlertRecord 触发的配额预警
+type QuotaAlertRecord struct {
+	ID             int64     `gorm:"primaryKey" json:"id"`
+	AlertID        string    `gorm:"size:100;uniqueIndex" json:"alert_id"`
+	UserID         string    `gorm:"size:100;index" json:"user_id"`
+	Level          string    `gorm:"size:20" json:"level"`
+	UsageRate      float64   `json:"usage_rate"` // 百分比
+	RemainingQuota float64   `json:"remaining_quota"`
+	UsedQuota      float64   `json:"used_quota"`
+	TotalQuota     float64   `json:"total_quota"`
+	Message        string    `gorm:"type:text" json:"message"`
+	TriggeredAt    time.Time `json:"triggered_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (QuotaAlertRecord) TableName() string {
+	return "quota_alerts"
+}
+
+// QuotaAlertDelivery 配额预警在某个渠道上的投递结果
+type QuotaAlertDelivery struct {
+	ID          int64      `gorm:"primaryKey" json:"id"`
+	AlertID     string     `gorm:"size:100;index" json:"alert_id"`
+	UserID      string     `gorm:"size:100" json:"user_id"`
+	Channel     string     `gorm:"size:20" json:"channel"`
+	Target      string     `gorm:"size:500" json:"target"`
+	Status      string     `gorm:"size:20" json:"status"` // sent, failed
+	Attempts    int        `json:"attempts"`
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (QuotaAlertDelivery) TableName() string {
+	return "quota_alert_deliveries"
+}
+
+// AutoRechargeSetting 用户的自动充值配置与保存的 Stripe 支付方式
+type AutoRechargeSetting struct {
+	UserID           int       `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	StripeCustomerID string    `gorm:"size:255" json:"-"`
+	PaymentMethodID  string    `gorm:"size:255" json:"-"`
+	Enabled          bool      `json:"enabled"`
+	TriggerThreshold float64   `json:"trigger_threshold"` // 使用率达到该百分比时充值
+	RechargeAmount   int64     `json:"recharge_amount"`   // 每次充值额度（分），按 1:1 扣款
+	MaxPerPeriod     int       `json:"max_per_period"`
+	PeriodDays       int       `json:"period_days"`
+	Suspended        bool      `json:"suspended"` // 扣款失败后暂停，更新支付方式后恢复
+	SuspendReason    string    `gorm:"type:text" json:"suspend_reason,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+func (AutoRechargeSetting) TableName() string {
+	return "auto_recharge_settings"
+}
+
+// AutoRechargeRecord 一次自动充值：离线扣款与额度入账
+type AutoRechargeRecord struct {
+	ID              int64     `gorm:"primaryKey" json:"id"`
+	RecordID        string    `gorm:"size:100;uniqueIndex" json:"record_id"`
+	UserID          int       `gorm:"index" json:"user_id"`
+	Amount          float64   `json:"amount"`       // 入账额度（分）
+	AmountCents     int64     `json:"amount_cents"` // 扣款金额（最小货币单位）
+	Currency        string    `gorm:"size:10" json:"currency"`
+	Status          string    `gorm:"size:20;index" json:"status"` // pending, charged, completed, declined, refunded
+	IdempotencyKey  string    `gorm:"size:255;uniqueIndex" json:"-"`
+	PaymentIntentID string    `gorm:"size:255" json:"payment_intent_id,omitempty"`
+	Error           string    `gorm:"type:text" json:"error,omitempty"`
+	Reason          string    `gorm:"type:text" json:"reason"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (AutoRechargeRecord) TableName() string {
+	return "auto_recharge_records"
+}
+
 // Invoice 已在 billing_models.go 中定义
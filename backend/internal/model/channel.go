@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math/rand"
 	"strings"
 	"sync/atomic"
@@ -119,22 +120,48 @@ func (c *Channel) GetKeys() []string {
 		return c.Keys
 	}
 
-	// 从 APIKey 解析（格式：key1,key2,key3 或 key1\nkey2\nkey3）
-	var keys []string
-	if strings.Contains(c.APIKey, "\n") {
-		keys = strings.Split(c.APIKey, "\n")
+	c.Keys = ParseKeys(c.APIKey)
+	return c.Keys
+}
+
+// PrimaryKey 第一个密钥，单密钥渠道即 APIKey（不写入 Keys 缓存，可并发调用）
+func (c *Channel) PrimaryKey() string {
+	if !c.ChannelInfo.IsMultiKey {
+		return c.APIKey
+	}
+	if keys := ParseKeys(c.APIKey); len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// SetKeys 设置渠道密钥，多于一个密钥时以换行分隔保存并开启多密钥模式
+func (c *Channel) SetKeys(keys []string) {
+	c.APIKey = strings.Join(keys, "\n")
+	c.ChannelInfo.IsMultiKey = len(keys) > 1
+	c.ChannelInfo.MultiKeySize = len(keys)
+	if c.ChannelInfo.IsMultiKey && c.ChannelInfo.MultiKeyMode == 0 {
+		c.ChannelInfo.MultiKeyMode = MultiKeyModePolling
+	}
+	c.Keys = nil
+}
+
+// ParseKeys 解析密钥列表（格式：key1,key2,key3 或 key1\nkey2\nkey3），忽略空白项
+func ParseKeys(raw string) []string {
+	var parts []string
+	if strings.Contains(raw, "\n") {
+		parts = strings.Split(raw, "\n")
 	} else {
-		keys = strings.Split(c.APIKey, ",")
+		parts = strings.Split(raw, ",")
 	}
 
-	c.Keys = make([]string, 0, len(keys))
-	for _, key := range keys {
+	keys := make([]string, 0, len(parts))
+	for _, key := range parts {
 		if trimmed := strings.TrimSpace(key); trimmed != "" {
-			c.Keys = append(c.Keys, trimmed)
+			keys = append(keys, trimmed)
 		}
 	}
-
-	return c.Keys
+	return keys
 }
 
 // GetNextEnabledKey 获取下一个可用密钥（并发安全）
@@ -199,3 +226,105 @@ func (c *Channel) GetSupportedModels() []string {
 
 	return models
 }
+
+// ChannelSettings 渠道的附加设置（存储于 OtherSettings）
+type ChannelSettings struct {
+	// RequestIDHeader 透传请求 ID 的请求头，未设置时使用提供方默认值，设为空字符串则不透传
+	RequestIDHeader *string `json:"request_id_header,omitempty"`
+
+	// ExtraBodyAllowlist 允许透传到上游的 extra_body 字段（为空时使用适配器默认值）
+	ExtraBodyAllowlist []string `json:"extra_body_allowlist,omitempty"`
+
+	// ModelDiscoveryIntervalSeconds 自托管渠道的模型发现间隔（0 表示使用全局配置，负数关闭）
+	ModelDiscoveryIntervalSeconds int `json:"model_discovery_interval_seconds,omitempty"`
+
+	// QueryParams 追加到每个上游请求 URL 的静态查询参数（如 api-version）
+	QueryParams map[string]string `json:"query_params,omitempty"`
+
+	// Deployments Azure 渠道的模型到部署名映射，未配置的模型去掉 "." 后作为部署名
+	Deployments map[string]string `json:"deployments,omitempty"`
+
+	// APIVersion Azure 渠道的 api-version，为空时使用适配器默认值
+	APIVersion string `json:"api_version,omitempty"`
+
+	// LogBodies 在统一日志中记录脱敏并截断后的请求头、请求体与响应体，用于排查上游失败
+	LogBodies bool `json:"log_bodies,omitempty"`
+
+	// ParamDefaults 用户未设置时填充的请求参数（如 {"temperature": 0.7}）
+	ParamDefaults map[string]interface{} `json:"param_defaults,omitempty"`
+
+	// ParamOverrides 强制的请求参数，替换或限制用户设置的值（如 {"max_tokens": {"max": 2048}}）
+	ParamOverrides map[string]ParamOverride `json:"param_overrides,omitempty"`
+
+	// MaxConcurrency 同时转发到该渠道的最大请求数，0 表示不限制
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+
+	// Capabilities 渠道额外支持的能力（images 图像生成、audio 语音），只有标记了对应能力的渠道才会接收这类请求
+	Capabilities []string `json:"capabilities,omitempty"`
+
+	// DisabledFeatures 关闭渠道类型默认具备的 Chat 能力（function_calling 工具调用、parallel_functions 并行工具调用、
+	// json_mode JSON 输出、vision 图片输入），用于上游模型不支持这些功能的渠道；关闭工具调用同时关闭并行工具调用
+	DisabledFeatures []string `json:"disabled_features,omitempty"`
+}
+
+// ParamOverride 渠道强制的请求参数：设置 Value 时替换用户的值，否则将用户设置的数值限制在 [Min, Max] 内
+type ParamOverride struct {
+	Value interface{} `json:"value,omitempty"`
+	Min   *float64    `json:"min,omitempty"`
+	Max   *float64    `json:"max,omitempty"`
+}
+
+// GetSettings 解析渠道附加设置，格式错误时返回默认设置
+func (c *Channel) GetSettings() ChannelSettings {
+	var settings ChannelSettings
+	if c.OtherSettings != "" {
+		_ = json.Unmarshal([]byte(c.OtherSettings), &settings)
+	}
+	return settings
+}
+
+// SetSettings 写入渠道附加设置
+func (c *Channel) SetSettings(settings ChannelSettings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	c.OtherSettings = string(data)
+	return nil
+}
+
+// GetHeaderOverride 解析注入上游请求的静态请求头（HeaderOverride，JSON 对象），未配置时返回 nil
+func (c *Channel) GetHeaderOverride() (map[string]string, error) {
+	if c.HeaderOverride == nil || strings.TrimSpace(*c.HeaderOverride) == "" {
+		return nil, nil
+	}
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(*c.HeaderOverride), &headers); err != nil {
+		return nil, fmt.Errorf("invalid header_override: %w", err)
+	}
+	return headers, nil
+}
+
+// SetHeaderOverride 写入静态请求头，空表示清除
+func (c *Channel) SetHeaderOverride(headers map[string]string) error {
+	if len(headers) == 0 {
+		c.HeaderOverride = nil
+		return nil
+	}
+	data, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+	value := string(data)
+	c.HeaderOverride = &value
+	return nil
+}
+
+// GetRequestIDHeader 获取透传请求 ID 的请求头
+func (c *Channel) GetRequestIDHeader(defaultHeader string) string {
+	settings := c.GetSettings()
+	if settings.RequestIDHeader == nil {
+		return defaultHeader
+	}
+	return strings.TrimSpace(*settings.RequestIDHeader)
+}
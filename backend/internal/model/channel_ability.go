@@ -14,6 +14,13 @@ type ChannelAbility struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
+	// 能力探测确认的特性（nil 表示未探测）
+	SupportsJSONMode *bool      `json:"supports_json_mode"`
+	SupportsTools    *bool      `json:"supports_tools"`
+	SupportsVision   *bool      `json:"supports_vision"`
+	SupportsStream   *bool      `json:"supports_stream"`
+	FeaturesProbedAt *time.Time `json:"features_probed_at"`
+
 	// 关联
 	Channel *Channel `gorm:"foreignKey:ChannelID" json:"channel,omitempty"`
 }
@@ -21,3 +28,12 @@ type ChannelAbility struct {
 func (ChannelAbility) TableName() string {
 	return "channel_abilities"
 }
+
+// CopyFeatures 复制特性标记（重建能力时保留探测结果）
+func (a *ChannelAbility) CopyFeatures(from *ChannelAbility) {
+	a.SupportsJSONMode = from.SupportsJSONMode
+	a.SupportsTools = from.SupportsTools
+	a.SupportsVision = from.SupportsVision
+	a.SupportsStream = from.SupportsStream
+	a.FeaturesProbedAt = from.FeaturesProbedAt
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// ChannelCapabilityProbe 渠道能力探测记录（每次探测每项能力一行，按时间保留以便发现上游的能力回退）
+type ChannelCapabilityProbe struct {
+	ID         int64     `gorm:"primaryKey" json:"id"`
+	RunID      string    `gorm:"size:36;not null;index" json:"run_id"`
+	ChannelID  int       `gorm:"not null;index" json:"channel_id"`
+	Model      string    `gorm:"size:100;not null" json:"model"`
+	Capability string    `gorm:"size:32;not null" json:"capability"`
+	Outcome    string    `gorm:"size:16;not null" json:"outcome"`
+	Detail     string    `gorm:"type:text" json:"detail"`
+	StatusCode int       `json:"status_code"`
+	Evidence   string    `gorm:"type:text" json:"evidence"` // 上游原始响应（截断）
+	LatencyMs  int       `json:"latency_ms"`
+	ProbedBy   int       `json:"probed_by"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+func (ChannelCapabilityProbe) TableName() string {
+	return "channel_capability_probes"
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 数据导出任务状态
+const (
+	DataExportStatusPending   = "pending"   // 等待处理
+	DataExportStatusRunning   = "running"   // 处理中
+	DataExportStatusCompleted = "completed" // 已完成，可下载
+	DataExportStatusFailed    = "failed"    // 失败
+	DataExportStatusExpired   = "expired"   // 已过期，归档已清理
+)
+
+// DataExport 用户数据导出任务
+type DataExport struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID      int        `gorm:"not null;index" json:"user_id"`
+	Status      string     `gorm:"size:20;not null;index" json:"status"`
+	Progress    int        `gorm:"default:0" json:"progress"` // 0-100
+	Stage       string     `gorm:"size:32" json:"stage"`      // 当前处理的部分
+	Checkpoint  string     `gorm:"type:jsonb" json:"-"`       // 已完成部分及其清单条目，用于中断后续跑
+	ObjectKey   string     `gorm:"size:255" json:"-"`
+	SizeBytes   int64      `gorm:"default:0" json:"size_bytes"`
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (DataExport) TableName() string {
+	return "data_exports"
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// File 用户上传的文件，内容保存在对象存储的 StorageKey 下
+type File struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	UserID     int       `gorm:"not null;index:idx_files_user_created" json:"user_id"`
+	Name       string    `gorm:"size:255;not null" json:"name"`
+	Size       int64     `gorm:"not null" json:"size"`
+	MimeType   string    `gorm:"size:100;not null" json:"mime_type"`
+	Hash       string    `gorm:"size:64;not null" json:"hash"` // 内容的 SHA-256（十六进制）
+	StorageKey string    `gorm:"size:255;not null" json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (File) TableName() string {
+	return "files"
+}
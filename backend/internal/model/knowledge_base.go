@@ -16,6 +16,7 @@ type KnowledgeBase struct {
 	EmbeddingModel  string    `gorm:"size:100;default:text-embedding-3-small" json:"embedding_model"`
 	ChunkSize       int       `gorm:"default:512" json:"chunk_size"`
 	ChunkOverlap    int       `gorm:"default:50" json:"chunk_overlap"`
+	ChunkStrategy   string    `gorm:"size:20;default:fixed" json:"chunk_strategy"` // fixed, sentence, recursive
 	DocumentCount   int       `gorm:"default:0" json:"document_count"`
 	TotalChunks     int       `gorm:"default:0" json:"total_chunks"`
 	Status          int       `gorm:"default:1" json:"status"` // 1: 启用, 2: 禁用
@@ -40,6 +41,11 @@ type Document struct {
 	Status              int       `gorm:"default:1" json:"status"` // 1: 待处理, 2: 处理中, 3: 完成, 4: 失败
 	ChunkCount          int       `gorm:"default:0" json:"chunk_count"`
 	ErrorMessage        string    `gorm:"type:text" json:"error_message"`
+	ContentDigest       string    `gorm:"size:64;index" json:"content_digest"` // 归一化文本的 SHA-256，用于重复检测
+	SimHash             int64     `gorm:"column:simhash" json:"-"`             // 归一化文本的 simhash 指纹，用于近似重复检测
+	ChunkStrategy       string    `gorm:"size:20" json:"chunk_strategy"`       // 分块时实际使用的配置，为空表示按字符分块的旧文档
+	ChunkSize           int       `json:"chunk_size"`
+	ChunkOverlap        int       `json:"chunk_overlap"`
 	ProcessingStartedAt *time.Time `json:"processing_started_at"`
 	ProcessingCompletedAt *time.Time `json:"processing_completed_at"`
 	CreatedAt           time.Time `json:"created_at"`
@@ -67,13 +73,46 @@ func (DocumentChunk) TableName() string {
 	return "chunks"
 }
 
+// 文档索引任务状态
+const (
+	IndexJobQueued     = "queued"     // 等待工作协程处理
+	IndexJobProcessing = "processing" // 分块与向量化中
+	IndexJobReady      = "ready"      // 文本块已保存，可检索
+	IndexJobFailed     = "failed"     // 失败，原因见 Error
+)
+
+// DocumentIndexJob 文档分块与向量化任务
+type DocumentIndexJob struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primaryKey" json:"job_id"`
+	DocumentID      uuid.UUID  `gorm:"type:uuid;index" json:"document_id"`
+	KnowledgeBaseID int        `gorm:"column:kb_id" json:"kb_id"`
+	State           string     `gorm:"size:20;not null" json:"state"`
+	Replace         bool       `json:"-"`                       // 替换文档已有的文本块
+	Sections        *string    `gorm:"type:jsonb" json:"-"`     // 解析后的文本段落，完成后清空
+	ChunksDone      int        `json:"chunks_done"`             // 已向量化的文本块数
+	ChunksTotal     int        `json:"chunks_total"`            // 分块后的文本块总数，分块前为 0
+	Error           string     `gorm:"type:text" json:"error,omitempty"`
+	StartedAt       *time.Time `json:"started_at"`
+	CompletedAt     *time.Time `json:"completed_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (DocumentIndexJob) TableName() string {
+	return "document_index_jobs"
+}
+
 // KBSearchResult 知识库搜索结果
 type KBSearchResult struct {
 	ChunkID     uuid.UUID `json:"chunk_id"`
 	DocumentID  uuid.UUID `json:"document_id"`
 	DocumentTitle string   `json:"document_title"`
 	Content     string    `json:"content"`
-	Similarity  float64   `json:"similarity"`
+	Similarity  float64   `json:"similarity"`    // 向量余弦相似度，0 表示未出现在向量检索结果中
+	KeywordScore float64  `json:"keyword_score"` // 关键词相关度，0 表示未出现在关键词检索结果中
+	RerankScore float64   `json:"rerank_score"`  // 重排序相关度，0 表示未重排序
+	Score       float64   `json:"score"`         // 排序使用的分数，混合搜索时为倒数排名融合分数，重排序后为重排序相关度
 	Metadata    string    `json:"metadata"`
 }
 
@@ -91,5 +130,6 @@ const (
 	FileTypeTXT      = "txt"
 	FileTypeMarkdown = "markdown"
 	FileTypeDocx     = "docx"
+	FileTypeHTML     = "html"
 )
 
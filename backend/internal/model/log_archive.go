@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// LogArchiveStatus 日志归档状态
+const (
+	LogArchiveStatusArchived = "archived" // 已归档到对象存储
+	LogArchiveStatusRestored = "restored" // 已临时恢复到数据库
+)
+
+// LogArchive 统一日志分区归档记录
+type LogArchive struct {
+	ID            int        `gorm:"primaryKey" json:"id"`
+	PartitionName string     `gorm:"uniqueIndex;size:64" json:"partition_name"`
+	RangeStart    time.Time  `json:"range_start"`
+	RangeEnd      time.Time  `json:"range_end"`
+	ObjectKey     string     `gorm:"size:255" json:"object_key"`
+	RowCount      int64      `json:"row_count"`
+	SizeBytes     int64      `json:"size_bytes"`
+	Status        string     `gorm:"size:20;index" json:"status"`
+	ArchivedAt    time.Time  `json:"archived_at"`
+	RestoredAt    *time.Time `json:"restored_at"`
+}
+
+// TableName 指定表名
+func (LogArchive) TableName() string {
+	return "log_archives"
+}
+
+// UnifiedLogRollup 统一日志按天汇总（归档后仍可用于统计分析）
+type UnifiedLogRollup struct {
+	Day              time.Time `gorm:"primaryKey;type:date" json:"day"`
+	ChannelID        int       `gorm:"primaryKey" json:"channel_id"`
+	ModelName        string    `gorm:"primaryKey;size:100" json:"model_name"`
+	ProjectID        int       `gorm:"primaryKey" json:"project_id"`
+	Requests         int64     `json:"requests"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	Quota            int64     `json:"quota"`
+	TotalUseTime     int64     `json:"total_use_time"` // 毫秒
+}
+
+// TableName 指定表名
+func (UnifiedLogRollup) TableName() string {
+	return "unified_log_daily_rollups"
+}
@@ -4,30 +4,35 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type Message struct {
-	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
-	SessionID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"session_id"`
-	TopicID      *uuid.UUID `gorm:"type:uuid" json:"topic_id"`
-	ParentID     *uuid.UUID `gorm:"type:uuid" json:"parent_id"`
-	Role         string     `gorm:"size:20;not null" json:"role"` // user, assistant, system, tool
-	Content      string     `gorm:"type:text;not null" json:"content"`
-	Model        string     `gorm:"size:100" json:"model"`
-	InputTokens  int        `gorm:"default:0" json:"input_tokens"`
-	OutputTokens int        `gorm:"default:0" json:"output_tokens"`
-	TotalTokens  int        `gorm:"default:0" json:"total_tokens"`
-	Cost         int64      `gorm:"default:0" json:"cost"` // 花费（分）
-	Metadata     string     `gorm:"type:jsonb" json:"metadata"`
-	Files        string     `gorm:"type:jsonb" json:"files"`
-	ToolCalls    string     `gorm:"type:jsonb" json:"tool_calls"`
-	Status       int        `gorm:"default:1" json:"status"` // 1: 正常, 2: 错误, 3: 已删除
-	ErrorMessage string     `gorm:"type:text" json:"error_message"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ID              uuid.UUID  `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()" json:"id"`
+	SessionID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"session_id"`
+	TopicID         *uuid.UUID `gorm:"type:uuid" json:"topic_id"`
+	ParentID        *uuid.UUID `gorm:"type:uuid" json:"parent_id"`
+	BranchID        *string    `gorm:"size:100;index" json:"branch_id,omitempty"` // 所属分支，为空时属于主线对话
+	Role            string     `gorm:"size:20;not null" json:"role"`              // user, assistant, system, tool
+	Content         string     `gorm:"type:text;not null" json:"content"`
+	Model           string     `gorm:"size:100" json:"model"`
+	InputTokens     int        `gorm:"default:0" json:"input_tokens"`
+	OutputTokens    int        `gorm:"default:0" json:"output_tokens"`
+	ReasoningTokens int        `gorm:"default:0" json:"reasoning_tokens"` // 推理 Token，已包含在 OutputTokens 中
+	TotalTokens     int        `gorm:"default:0" json:"total_tokens"`
+	Cost            int64      `gorm:"default:0" json:"cost"` // 花费（分）
+	Metadata        string     `gorm:"type:jsonb" json:"metadata"`
+	Files           string     `gorm:"type:jsonb" json:"files"`
+	ToolCalls       string     `gorm:"type:jsonb" json:"tool_calls"`
+	Status          int        `gorm:"default:1" json:"status"` // 1: 正常, 2: 错误, 3: 已删除
+	ErrorMessage    string     `gorm:"type:text" json:"error_message"`
+	Latency         *string    `gorm:"type:jsonb" json:"-"` // 生成该消息的中转请求分阶段耗时，只在管理员消息详情中返回
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"` // 随会话软删除，恢复会话时一并恢复
 }
 
 func (Message) TableName() string {
 	return "messages"
 }
-
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// MessageBranch 消息分支，重新生成回复时从父消息（用户消息）分出，保留原回复
+type MessageBranch struct {
+	ID              string         `gorm:"size:100;primaryKey" json:"id"`
+	SessionID       uuid.UUID      `gorm:"type:uuid;not null;index" json:"session_id"`
+	Name            string         `gorm:"size:100" json:"name"`
+	ParentMessageID uuid.UUID      `gorm:"type:uuid;not null;index" json:"parent_message_id"`
+	MessageIDs      pq.StringArray `gorm:"type:text[]" json:"message_ids"` // 分支内的消息，按创建顺序
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+func (MessageBranch) TableName() string {
+	return "message_branches"
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MessageEdit 消息编辑记录，按编辑顺序保存每次修改前后的内容
+type MessageEdit struct {
+	ID                int64     `gorm:"primaryKey" json:"id"`
+	MessageID         uuid.UUID `gorm:"type:uuid;not null;index" json:"message_id"`
+	SessionID         uuid.UUID `gorm:"type:uuid;not null" json:"session_id"`
+	EditorID          int       `gorm:"not null" json:"editor_id"`
+	OriginalContent   string    `gorm:"type:text;not null" json:"original_content"`
+	NewContent        string    `gorm:"type:text;not null" json:"new_content"`
+	Reason            string    `gorm:"size:500" json:"reason"`
+	TruncatedMessages int       `gorm:"default:0" json:"truncated_messages"` // 编辑时作废的后续消息数
+	EditedAt          time.Time `gorm:"autoCreateTime" json:"edited_at"`
+}
+
+func (MessageEdit) TableName() string {
+	return "message_edits"
+}
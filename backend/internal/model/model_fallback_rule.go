@@ -0,0 +1,33 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// ModelFallbackRule 模型降级路由规则：请求的模型没有可用渠道时依次改用降级模型
+type ModelFallbackRule struct {
+	ID             int       `gorm:"primaryKey" json:"id"`
+	ModelPattern   string    `gorm:"size:128;uniqueIndex:idx_model_fallback_rule" json:"model_pattern"` // 支持 * 通配符
+	UserGroup      string    `gorm:"size:64;uniqueIndex:idx_model_fallback_rule" json:"user_group"`     // 为空表示所有分组
+	FallbackModels string    `gorm:"type:text" json:"fallback_models"`                                  // 逗号分隔，按顺序尝试
+	Enabled        bool      `gorm:"default:true" json:"enabled"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ModelFallbackRule) TableName() string {
+	return "model_fallback_rules"
+}
+
+// GetFallbackModels 获取降级模型列表
+func (r *ModelFallbackRule) GetFallbackModels() []string {
+	models := make([]string, 0)
+	for _, m := range strings.Split(r.FallbackModels, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			models = append(models, m)
+		}
+	}
+	return models
+}
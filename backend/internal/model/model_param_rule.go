@@ -0,0 +1,48 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// ModelParamRule 模型家族参数适配规则
+type ModelParamRule struct {
+	ID                      int            `gorm:"primaryKey" json:"id"`
+	Family                  string         `gorm:"size:64;uniqueIndex" json:"family"`
+	ModelPrefixes           string         `gorm:"type:text" json:"model_prefixes"` // 逗号分隔
+	MaxTokensField          string         `gorm:"size:64" json:"max_tokens_field"`
+	UnsupportedParams       datatypes.JSON `gorm:"type:jsonb" json:"unsupported_params"` // 参数 -> drop/reject
+	SupportsReasoningEffort bool           `json:"supports_reasoning_effort"`
+	Enabled                 bool           `gorm:"default:true" json:"enabled"`
+	CreatedAt               time.Time      `json:"created_at"`
+	UpdatedAt               time.Time      `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ModelParamRule) TableName() string {
+	return "model_param_rules"
+}
+
+// GetModelPrefixes 获取模型名前缀列表
+func (r *ModelParamRule) GetModelPrefixes() []string {
+	prefixes := make([]string, 0)
+	for _, p := range strings.Split(r.ModelPrefixes, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+// GetUnsupportedParams 获取不支持参数的处理方式
+func (r *ModelParamRule) GetUnsupportedParams() map[string]string {
+	params := make(map[string]string)
+	if len(r.UnsupportedParams) == 0 {
+		return params
+	}
+	_ = json.Unmarshal(r.UnsupportedParams, &params)
+	return params
+}
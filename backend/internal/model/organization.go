@@ -0,0 +1,109 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Organization 组织模型
+type Organization struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	Slug      string    `gorm:"uniqueIndex;size:64" json:"slug"`
+	Name      string    `gorm:"size:100" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// OrganizationMember 组织成员模型
+type OrganizationMember struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	OrgID     int       `gorm:"uniqueIndex:idx_org_member" json:"org_id"`
+	UserID    int       `gorm:"uniqueIndex:idx_org_member;index" json:"user_id"`
+	Role      int       `gorm:"default:1" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (OrganizationMember) TableName() string {
+	return "organization_members"
+}
+
+// OrgSSOConfig 组织 OIDC 单点登录配置
+type OrgSSOConfig struct {
+	ID               int            `gorm:"primaryKey" json:"id"`
+	OrgID            int            `gorm:"uniqueIndex" json:"org_id"`
+	IssuerURL        string         `gorm:"size:255" json:"issuer_url"`
+	ClientID         string         `gorm:"size:255" json:"client_id"`
+	ClientSecret     string         `gorm:"size:255" json:"-"`
+	RedirectURI      string         `gorm:"size:255" json:"redirect_uri"`
+	Scopes           string         `gorm:"size:255;default:'openid email profile'" json:"scopes"`
+	AllowedDomains   string         `json:"allowed_domains"`                              // 允许的邮箱域名（逗号分隔）
+	GroupsClaim      string         `gorm:"size:64;default:'groups'" json:"groups_claim"` // IdP 组信息所在的声明
+	GroupRoleMapping datatypes.JSON `gorm:"type:jsonb" json:"group_role_mapping"`         // IdP 组 -> 角色
+	DefaultRole      int            `gorm:"default:1" json:"default_role"`
+	Enabled          bool           `gorm:"default:true" json:"enabled"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (OrgSSOConfig) TableName() string {
+	return "org_sso_configs"
+}
+
+// GetAllowedDomains 获取允许的邮箱域名列表
+func (c *OrgSSOConfig) GetAllowedDomains() []string {
+	if c.AllowedDomains == "" {
+		return []string{}
+	}
+	domains := make([]string, 0)
+	for _, d := range strings.Split(c.AllowedDomains, ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// GetGroupRoleMapping 获取 IdP 组到角色的映射
+func (c *OrgSSOConfig) GetGroupRoleMapping() map[string]int {
+	mapping := make(map[string]int)
+	if len(c.GroupRoleMapping) == 0 {
+		return mapping
+	}
+	_ = json.Unmarshal(c.GroupRoleMapping, &mapping)
+	return mapping
+}
+
+// GetScopes 获取授权范围
+func (c *OrgSSOConfig) GetScopes() string {
+	if strings.TrimSpace(c.Scopes) == "" {
+		return "openid email profile"
+	}
+	return c.Scopes
+}
+
+// UserIdentity 外部身份与本地用户的绑定
+type UserIdentity struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	UserID    int       `gorm:"index" json:"user_id"`
+	Issuer    string    `gorm:"uniqueIndex:idx_identity_subject;size:255" json:"issuer"`
+	Subject   string    `gorm:"uniqueIndex:idx_identity_subject;size:255" json:"subject"`
+	Email     string    `gorm:"size:100" json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
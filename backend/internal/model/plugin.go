@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Plugin 已注册的 MCP 插件配置
+type Plugin struct {
+	ID                 int                         `gorm:"primaryKey" json:"id"`
+	Name               string                      `gorm:"size:64;not null;uniqueIndex:idx_plugins_name" json:"name"`
+	Description        string                      `gorm:"type:text" json:"description"`
+	Mode               string                      `gorm:"size:10;not null" json:"mode"` // stdio 或 http
+	Command            string                      `gorm:"type:text" json:"command,omitempty"`
+	Args               datatypes.JSONSlice[string] `gorm:"type:jsonb" json:"args,omitempty"`
+	URL                string                      `gorm:"type:text" json:"url,omitempty"`
+	TimeoutSeconds     int                         `gorm:"default:0" json:"timeout_seconds"`
+	MaxRetries         int                         `gorm:"default:0" json:"max_retries"`
+	AutoRestart        bool                        `gorm:"default:false" json:"auto_restart"`
+	ToolRefreshSeconds int                         `gorm:"default:0" json:"tool_refresh_seconds"`
+	Enabled            bool                        `gorm:"default:false" json:"enabled"` // 服务启动时是否自动启动
+	CreatedBy          *int                        `json:"created_by,omitempty"`
+	CreatedAt          time.Time                   `json:"created_at"`
+	UpdatedAt          time.Time                   `json:"updated_at"`
+}
+
+func (Plugin) TableName() string {
+	return "plugins"
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ProjectStatus 项目状态
+const (
+	ProjectStatusActive   = 1 // 启用
+	ProjectStatusDisabled = 2 // 禁用
+)
+
+// Project 中转项目：绑定到 API Token 的服务端配置（系统提示词、知识库、默认模型与参数）
+//
+// 使用项目 Token 调用 /v1/chat/completions 时，客户端只需发送用户消息；
+// 客户端传入的字段仅在对应字段未锁定时覆盖项目配置。
+type Project struct {
+	ID               int           `gorm:"primaryKey" json:"id"`
+	UserID           int           `gorm:"not null;index" json:"user_id"`
+	Name             string        `gorm:"size:100;not null" json:"name"`
+	Description      string        `gorm:"type:text" json:"description"`
+	SystemPrompt     string        `gorm:"type:text" json:"system_prompt"`
+	DefaultModel     string        `gorm:"size:100" json:"default_model"`
+	Params           ProjectParams `gorm:"type:jsonb;serializer:json" json:"params"`
+	KnowledgeBaseIDs pq.Int64Array `gorm:"type:integer[]" json:"knowledge_base_ids"`
+	MetadataTags     ProjectTags   `gorm:"type:jsonb;serializer:json" json:"metadata_tags"`
+	Locks            ProjectLocks  `gorm:"type:jsonb;serializer:json" json:"locks"`
+	Status           int           `gorm:"default:1" json:"status"` // 1: 启用, 2: 禁用
+	CreatedAt        time.Time     `json:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+	DeletedAt        *time.Time    `gorm:"index" json:"deleted_at"`
+}
+
+// TableName 指定表名
+func (Project) TableName() string {
+	return "relay_projects"
+}
+
+// IsActive 项目是否可用
+func (p *Project) IsActive() bool {
+	return p.Status == ProjectStatusActive && p.DeletedAt == nil
+}
+
+// ProjectParams 项目的参数默认值，nil 表示不设置
+type ProjectParams struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	MaxTokens        *int     `json:"max_tokens,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	ReasoningEffort  string   `json:"reasoning_effort,omitempty"`
+}
+
+// ProjectLocks 字段锁定标记，锁定的字段忽略客户端传入的值
+type ProjectLocks struct {
+	Model            bool `json:"model"`
+	SystemPrompt     bool `json:"system_prompt"`
+	Temperature      bool `json:"temperature"`
+	TopP             bool `json:"top_p"`
+	MaxTokens        bool `json:"max_tokens"`
+	FrequencyPenalty bool `json:"frequency_penalty"`
+	PresencePenalty  bool `json:"presence_penalty"`
+	ReasoningEffort  bool `json:"reasoning_effort"`
+}
+
+// ProjectTags 强制附加到每次请求日志的元数据标签
+type ProjectTags map[string]string
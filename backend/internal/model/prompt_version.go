@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// PromptVersion 系统提示词的历史版本，只追加不修改
+type PromptVersion struct {
+	ID             int64     `gorm:"primaryKey" json:"id"`
+	PromptID       string    `gorm:"size:100;not null;uniqueIndex:idx_prompt_versions_prompt_version" json:"prompt_id"`
+	Version        int64     `gorm:"not null;uniqueIndex:idx_prompt_versions_prompt_version" json:"version"`
+	Content        string    `gorm:"type:text;not null" json:"content"`
+	Editor         string    `gorm:"size:100" json:"editor"`
+	RolledBackFrom int64     `gorm:"default:0" json:"rolled_back_from,omitempty"` // 回滚产生的版本记录回滚到的版本号
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (PromptVersion) TableName() string {
+	return "prompt_versions"
+}
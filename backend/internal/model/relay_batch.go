@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// 批量任务与条目状态
+const (
+	RelayBatchStatusPending   = "pending"   // 等待处理
+	RelayBatchStatusRunning   = "running"   // 处理中
+	RelayBatchStatusCompleted = "completed" // 全部条目已处理（条目可能失败）
+	RelayBatchStatusFailed    = "failed"    // 条目处理失败，只用于条目
+)
+
+// RelayBatch 批量 Chat Completion 任务
+type RelayBatch struct {
+	ID           uuid.UUID   `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID       int         `gorm:"not null;index" json:"-"`
+	TokenID      int         `gorm:"not null" json:"-"`
+	ProjectID    int         `gorm:"default:0" json:"-"`
+	MetadataTags ProjectTags `gorm:"type:jsonb;serializer:json" json:"-"`
+	ClientIP     string      `gorm:"size:64" json:"-"`
+	Status       string      `gorm:"size:20;not null;index" json:"status"`
+	Total        int         `gorm:"not null" json:"total"`
+	Completed    int         `gorm:"default:0" json:"completed"` // 成功的条目数
+	Failed       int         `gorm:"default:0" json:"failed"`    // 失败的条目数
+	CompletedAt  *time.Time  `json:"completed_at"`
+	CreatedAt    time.Time   `gorm:"index" json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
+
+func (RelayBatch) TableName() string {
+	return "relay_batches"
+}
+
+// RelayBatchItem 批量任务中的单个请求，Index 为其在输入中的位置
+type RelayBatchItem struct {
+	BatchID       uuid.UUID      `gorm:"type:uuid;primaryKey"`
+	Index         int            `gorm:"column:idx;primaryKey"`
+	Status        string         `gorm:"size:20;not null"`
+	Request       datatypes.JSON `gorm:"type:jsonb;not null"`
+	Response      datatypes.JSON `gorm:"type:jsonb"`
+	Error         datatypes.JSON `gorm:"type:jsonb"`
+	ReservationID string         `gorm:"size:64"`
+	Reserved      int64          `gorm:"default:0"`
+	CompletedAt   *time.Time
+}
+
+func (RelayBatchItem) TableName() string {
+	return "relay_batch_items"
+}
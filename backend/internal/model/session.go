@@ -14,8 +14,10 @@ type Session struct {
 	AgentID          *int           `json:"agent_id"`
 	GroupID          *uuid.UUID     `gorm:"type:uuid" json:"group_id"`
 	Title            string         `gorm:"size:200" json:"title"`
+	AutoTitle        bool           `gorm:"default:false" json:"auto_title"` // 标题待自动生成，生成或用户重命名后清除
 	Description      string         `gorm:"type:text" json:"description"`
 	Pinned           bool           `gorm:"default:false" json:"pinned"`
+	SortOrder        int            `gorm:"default:0" json:"sort_order"` // 置顶会话之间的顺序，越小越靠前
 	Archived         bool           `gorm:"default:false" json:"archived"`
 	Model            string         `gorm:"size:100" json:"model"`
 	Temperature      float64        `gorm:"default:0.7" json:"temperature"`
@@ -34,3 +36,10 @@ func (Session) TableName() string {
 	return "sessions"
 }
 
+// 会话列表的筛选状态
+const (
+	SessionStateActive   = "active"   // 未归档、未删除
+	SessionStateArchived = "archived" // 已归档、未删除
+	SessionStateDeleted  = "deleted"  // 已删除，保留期内可恢复
+)
+
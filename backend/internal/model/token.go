@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 // 错误定义
@@ -11,6 +13,7 @@ var (
 	ErrTokenInvalid        = errors.New("token is invalid")
 	ErrQuotaExceeded       = errors.New("quota exceeded")
 	ErrInvalidRefundAmount = errors.New("invalid refund amount")
+	ErrModelNotAllowed     = errors.New("model not allowed for this token")
 )
 
 // TokenStatus Token 状态枚举
@@ -37,7 +40,9 @@ var TokenStatusName = map[TokenStatus]string{
 type Token struct {
 	ID             int
 	UserID         int
-	TokenHash      string
+	ProjectID      sql.NullInt64 // 绑定的中转项目，为空表示普通 Token
+	TokenHash      string        // API Key 的 SHA-256 哈希
+	KeyHint        string        // 脱敏后的 Key，用于列表展示；为空表示早期直接保存 Key 的 Token
 	Name           string
 	Description    sql.NullString
 	Status         TokenStatus
@@ -48,10 +53,20 @@ type Token struct {
 	RenewedAt      sql.NullTime
 	DeletedAt      sql.NullTime
 	LastUsedAt     sql.NullTime
-	IPWhitelist    []string
-	ModelWhitelist []string
-	Metadata       map[string]interface{}
+	IPWhitelist    pq.StringArray         `gorm:"type:text[]"`
+	ModelWhitelist pq.StringArray         `gorm:"type:text[]"`
+	Metadata       map[string]interface{} `gorm:"type:jsonb;serializer:json"`
 	UpdatedAt      time.Time
+	// 轮换前的 Key 哈希，在 PreviousHashExpireAt 之前仍可用于鉴权
+	PreviousTokenHash    sql.NullString
+	PreviousHashExpireAt sql.NullTime
+	// ExpiryNotifiedAt 已发送即将过期通知的时间，续期后清空
+	ExpiryNotifiedAt sql.NullTime
+}
+
+// TableName 指定表名
+func (Token) TableName() string {
+	return "tokens"
 }
 
 // TokenAuditLog Token 审计日志
@@ -62,12 +77,17 @@ type TokenAuditLog struct {
 	Operation string
 	OldStatus *TokenStatus
 	NewStatus *TokenStatus
-	Details   map[string]interface{}
+	Details   map[string]interface{} `gorm:"type:jsonb;serializer:json"`
 	CreatedAt time.Time
 	IPAddress sql.NullString
 	UserAgent sql.NullString
 }
 
+// TableName 指定表名
+func (TokenAuditLog) TableName() string {
+	return "token_audit_log"
+}
+
 // TokenRenewalLog Token 续期日志
 type TokenRenewalLog struct {
 	ID            int64
@@ -78,6 +98,11 @@ type TokenRenewalLog struct {
 	CreatedAt     time.Time
 }
 
+// TableName 指定表名
+func (TokenRenewalLog) TableName() string {
+	return "token_renewal_log"
+}
+
 // TokenQuotaThreshold Token 配额预警阈值
 type TokenQuotaThreshold struct {
 	ID               int
@@ -135,13 +160,20 @@ func (t *Token) GetQuotaPercentage() float64 {
 	return float64(t.QuotaUsed) / float64(t.QuotaLimit.Int64) * 100
 }
 
+// TokenExpiringSoonWindow 过期前多久视为即将过期
+const TokenExpiringSoonWindow = 7 * 24 * time.Hour
+
 // IsExpiringSoon 检查 Token 是否即将过期（在 7 天内）
 func (t *Token) IsExpiringSoon() bool {
+	return t.IsExpiringSoonAt(time.Now())
+}
+
+// IsExpiringSoonAt 检查 Token 在 now 时是否即将过期
+func (t *Token) IsExpiringSoonAt(now time.Time) bool {
 	if !t.ExpireAt.Valid {
 		return false
 	}
-	sevenDaysLater := time.Now().AddDate(0, 0, 7)
-	return t.ExpireAt.Time.Before(sevenDaysLater) && t.ExpireAt.Time.After(time.Now())
+	return t.ExpireAt.Time.Before(now.Add(TokenExpiringSoonWindow)) && t.ExpireAt.Time.After(now)
 }
 
 // CanRenew 检查 Token 是否可以续期
@@ -236,5 +268,6 @@ const (
 	TokenOpDisable  TokenOperationType = "disable"
 	TokenOpEnable   TokenOperationType = "enable"
 	TokenOpExpire   TokenOperationType = "expire"
+	TokenOpRotate   TokenOperationType = "rotate"
 	TokenOpUseQuota TokenOperationType = "use_quota"
 )
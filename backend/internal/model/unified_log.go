@@ -12,29 +12,42 @@ const (
 	LogTypeRefund  = 6 // 退款
 )
 
+// 中转日志状态
+const (
+	LogStatusSuccess   = "success"   // 成功
+	LogStatusPartial   = "partial"   // 已输出部分内容后中断
+	LogStatusError     = "error"     // 失败
+	LogStatusCancelled = "cancelled" // 客户端在完成前断开
+)
+
 // UnifiedLog 统一日志
 type UnifiedLog struct {
-	ID               int64     `gorm:"primaryKey" json:"id"`
-	UserID           int       `gorm:"not null;index" json:"user_id"`
-	Username         string    `gorm:"size:100" json:"username"`
-	TokenID          int       `json:"token_id"`
-	TokenName        string    `gorm:"size:100" json:"token_name"`
-	ChannelID        int       `gorm:"index" json:"channel_id"`
-	ChannelName      string    `gorm:"size:100" json:"channel_name"`
-	LogType          int       `gorm:"not null;index" json:"log_type"`
-	ModelName        string    `gorm:"size:100;index" json:"model_name"`
-	Content          string    `gorm:"type:text" json:"content"`
-	Quota            int       `json:"quota"`
-	PromptTokens     int       `json:"prompt_tokens"`
-	CompletionTokens int       `json:"completion_tokens"`
-	UseTime          int       `json:"use_time"` // 毫秒
-	IsStream         bool      `json:"is_stream"`
-	Group            string    `gorm:"size:64" json:"group"`
-	IP               string    `gorm:"size:45" json:"ip"`
-	UserAgent        string    `gorm:"type:text" json:"user_agent"`
-	RequestID        string    `gorm:"size:100;index" json:"request_id"`
-	Other            string    `gorm:"type:jsonb" json:"other"`
-	CreatedAt        time.Time `gorm:"index" json:"created_at"`
+	ID                int64     `gorm:"primaryKey" json:"id"`
+	UserID            int       `gorm:"not null;index" json:"user_id"`
+	Username          string    `gorm:"size:100" json:"username"`
+	TokenID           int       `json:"token_id"`
+	TokenName         string    `gorm:"size:100" json:"token_name"`
+	ProjectID         int       `gorm:"index" json:"project_id"` // 中转项目，0 表示未使用项目 Token
+	ChannelID         int       `gorm:"index" json:"channel_id"`
+	ChannelName       string    `gorm:"size:100" json:"channel_name"`
+	LogType           int       `gorm:"not null;index" json:"log_type"`
+	ModelName         string    `gorm:"size:100;index" json:"model_name"`
+	Content           string    `gorm:"type:text" json:"content"`
+	Quota             int       `json:"quota"`
+	PromptTokens      int       `json:"prompt_tokens"`
+	CompletionTokens  int       `json:"completion_tokens"`
+	ReasoningTokens   int       `json:"reasoning_tokens"` // 推理 Token，已包含在 CompletionTokens 中
+	UseTime           int       `json:"use_time"`         // 毫秒
+	IsStream          bool      `json:"is_stream"`
+	Group             string    `gorm:"size:64" json:"group"`
+	IP                string    `gorm:"size:45" json:"ip"`
+	UserAgent         string    `gorm:"type:text" json:"user_agent"`
+	RequestID         string    `gorm:"size:100;index" json:"request_id"`
+	UpstreamRequestID string    `gorm:"size:128;index" json:"upstream_request_id"` // 上游提供方的请求 ID
+	Status            string    `gorm:"size:16;index" json:"status"`               // 中转结果，见 LogStatus*
+	StatusCode        int       `json:"status_code"`                               // 上游 HTTP 状态码，未收到响应为 0
+	Other             string    `gorm:"type:jsonb" json:"other"`
+	CreatedAt         time.Time `gorm:"index" json:"created_at"`
 }
 
 func (UnifiedLog) TableName() string {
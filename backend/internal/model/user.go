@@ -14,6 +14,7 @@ type User struct {
 	DisplayName  string         `gorm:"size:100" json:"display_name"`
 	AvatarURL    string         `gorm:"type:text" json:"avatar_url"`
 	Role         int            `gorm:"default:1" json:"role"`
+	Group        string         `gorm:"size:64;default:'default'" json:"group"` // 用户分组，对应计费价格组
 	Quota        int64          `gorm:"default:0" json:"quota"`
 	TotalQuota   int64          `gorm:"default:0" json:"total_quota"`
 	UsedQuota    int64          `gorm:"default:0" json:"used_quota"`
@@ -31,23 +32,48 @@ func (User) TableName() string {
 	return "users"
 }
 
+// 用户状态
+const (
+	UserStatusDisabled = 0
+	UserStatusActive   = 1
+	UserStatusPending  = 2 // 已注册、等待验证邮箱
+)
+
+// 用户角色，数值越大权限越高
+const (
+	UserRoleUser  = 1
+	UserRoleAdmin = 100
+)
+
+// 用户内容保留策略
+const (
+	ContentRetentionMetadata = "metadata" // 只保留请求元数据（默认）
+	ContentRetentionFull     = "full"     // 允许保留并在排查问题时查看消息内容
+)
+
 type UserSettings struct {
-	UserID       int       `gorm:"primaryKey" json:"user_id"`
-	Language     string    `gorm:"size:10;default:zh-CN" json:"language"`
-	Theme        string    `gorm:"size:20;default:auto" json:"theme"`
-	FontSize     int       `gorm:"default:14" json:"font_size"`
-	TTSEnabled   bool      `gorm:"default:false" json:"tts_enabled"`
-	TTSVoice     string    `gorm:"size:50" json:"tts_voice"`
-	TTSSpeed     float64   `gorm:"default:1.0" json:"tts_speed"`
-	STTEnabled   bool      `gorm:"default:false" json:"stt_enabled"`
-	SendKey      string    `gorm:"size:20;default:Enter" json:"send_key"`
-	CustomConfig string    `gorm:"type:jsonb" json:"custom_config"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	UserID           int       `gorm:"primaryKey" json:"user_id"`
+	Language         string    `gorm:"size:10;default:zh-CN" json:"language"`
+	Theme            string    `gorm:"size:20;default:auto" json:"theme"`
+	FontSize         int       `gorm:"default:14" json:"font_size"`
+	TTSEnabled       bool      `gorm:"default:false" json:"tts_enabled"`
+	TTSVoice         string    `gorm:"size:50" json:"tts_voice"`
+	TTSSpeed         float64   `gorm:"default:1.0" json:"tts_speed"`
+	STTEnabled       bool      `gorm:"default:false" json:"stt_enabled"`
+	SendKey          string    `gorm:"size:20;default:Enter" json:"send_key"`
+	CustomConfig     string    `gorm:"type:jsonb" json:"custom_config"`
+	ContentRetention string    `gorm:"size:20;default:metadata" json:"content_retention"` // 内容保留策略
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 func (UserSettings) TableName() string {
 	return "user_settings"
 }
 
+// AllowsContent 用户的保留策略是否允许查看消息内容
+func (s *UserSettings) AllowsContent() bool {
+	return s != nil && s.ContentRetention == ContentRetentionFull
+}
+
 
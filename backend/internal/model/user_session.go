@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// UserSession 用户的一次登录，对应一条刷新令牌链（FamilyID），每次刷新更新最近使用时间与 IP
+type UserSession struct {
+	ID         int        `gorm:"primaryKey" json:"id"`
+	UserID     int        `gorm:"index;not null" json:"-"`
+	FamilyID   string     `gorm:"uniqueIndex;size:64;not null" json:"-"` // 写入访问令牌与刷新令牌的 sid 声明
+	Device     string     `gorm:"size:128" json:"device"`                // 由 User-Agent 解析的浏览器与系统
+	UserAgent  string     `gorm:"type:text" json:"user_agent"`
+	IP         string     `gorm:"size:64" json:"ip"`
+	Location   string     `gorm:"size:128" json:"location,omitempty"` // 配置了 IP 地理位置查询时填写
+	LastUsedAt time.Time  `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Current    bool       `gorm:"-" json:"current"` // 是否为发起查询的会话
+}
+
+func (UserSession) TableName() string {
+	return "user_sessions"
+}
@@ -0,0 +1,350 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+var (
+	// ErrUnknownProvider 提供方不存在或未启用
+	ErrUnknownProvider = errors.New("oauth provider is not enabled")
+	// ErrInvalidState state 无效、已过期或与提供方不匹配
+	ErrInvalidState = errors.New("invalid or expired oauth state")
+	// ErrEmailNotVerified 提供方未返回已验证的邮箱
+	ErrEmailNotVerified = errors.New("email is missing or not verified by the oauth provider")
+	// ErrIdentityConflict 提供方账号已绑定到其他用户
+	ErrIdentityConflict = errors.New("oauth identity is already bound to another account")
+	// ErrAlreadyBound 当前用户已绑定该提供方的其他账号
+	ErrAlreadyBound = errors.New("another account of this provider is already bound")
+	// ErrNotBound 当前用户未绑定该提供方
+	ErrNotBound = errors.New("oauth provider is not bound")
+	// ErrUserDisabled 用户已被禁用
+	ErrUserDisabled = errors.New("user account is disabled")
+)
+
+// issuerPrefix 外部身份表中 OAuth 身份的发行方前缀，与组织 SSO 的 issuer URL 区分
+const issuerPrefix = "oauth:"
+
+// Issuer 提供方在外部身份表中的发行方
+func Issuer(provider string) string {
+	return issuerPrefix + provider
+}
+
+// Store OAuth 依赖的持久化接口
+type Store interface {
+	FindIdentity(ctx context.Context, issuer, subject string) (*model.UserIdentity, error)
+	ListIdentities(ctx context.Context, userID int) ([]*model.UserIdentity, error)
+	CreateIdentity(ctx context.Context, identity *model.UserIdentity) error
+	// DeleteIdentity 删除用户在某发行方下的身份，返回是否删除了记录
+	DeleteIdentity(ctx context.Context, userID int, issuer string) (bool, error)
+	FindUserByID(ctx context.Context, id int) (*model.User, error)
+	FindUserByEmail(ctx context.Context, email string) (*model.User, error)
+	// CreateUser 创建即时开通的用户，实现方负责补全用户名、密码等字段
+	CreateUser(ctx context.Context, user *model.User) error
+	// ActivateUser 保存激活后的待验证用户（状态与作废的密码）
+	ActivateUser(ctx context.Context, user *model.User) error
+}
+
+// Result 回调处理结果
+type Result struct {
+	User        *model.User
+	Provider    string
+	Bound       bool // 本次是已登录用户的绑定流程
+	Provisioned bool // 本次登录即时创建了用户
+	Linked      bool // 本次登录按邮箱绑定了已有用户
+}
+
+// Binding 用户已绑定的提供方账号
+type Binding struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Manager GitHub、Google 等 OAuth 登录与绑定流程管理器
+type Manager struct {
+	store        Store
+	states       StateStore
+	stateTTL     time.Duration
+	redirectBase string
+	httpClient   *http.Client
+	providers    map[string]*Provider
+}
+
+// NewManager 创建 OAuth 管理器，redirectBase 为对外访问的服务地址
+func NewManager(store Store, states StateStore, redirectBase string, httpClient *http.Client) *Manager {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Manager{
+		store:        store,
+		states:       states,
+		stateTTL:     10 * time.Minute,
+		redirectBase: strings.TrimRight(redirectBase, "/"),
+		httpClient:   httpClient,
+		providers:    make(map[string]*Provider),
+	}
+}
+
+// Register 启用提供方
+func (m *Manager) Register(p *Provider) {
+	m.providers[p.Name] = p
+}
+
+// SetStateTTL 设置授权状态的有效期
+func (m *Manager) SetStateTTL(ttl time.Duration) {
+	if ttl > 0 {
+		m.stateTTL = ttl
+	}
+}
+
+// Providers 已启用的提供方名称
+func (m *Manager) Providers() []string {
+	names := make([]string, 0, len(m.providers))
+	for name := range m.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RedirectURI 提供方回调地址，需与提供方后台登记的一致
+func (m *Manager) RedirectURI(provider string) string {
+	return m.redirectBase + "/api/v1/oauth/" + provider + "/callback"
+}
+
+// BeginLogin 发起登录，返回提供方授权地址
+func (m *Manager) BeginLogin(ctx context.Context, provider string) (string, error) {
+	return m.begin(ctx, provider, 0)
+}
+
+// BeginBind 为已登录用户发起绑定，返回提供方授权地址
+func (m *Manager) BeginBind(ctx context.Context, provider string, userID int) (string, error) {
+	return m.begin(ctx, provider, userID)
+}
+
+func (m *Manager) begin(ctx context.Context, provider string, userID int) (string, error) {
+	p, ok := m.providers[provider]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	state, err := randomToken(24)
+	if err != nil {
+		return "", err
+	}
+	if err := m.states.Save(ctx, state, &State{Provider: provider, UserID: userID}, m.stateTTL); err != nil {
+		return "", err
+	}
+
+	return p.AuthCodeURL(state, m.RedirectURI(provider)), nil
+}
+
+// Complete 处理提供方回调：换取令牌、读取资料，再按 state 记录的流程登录或绑定
+func (m *Manager) Complete(ctx context.Context, provider, state, code string) (*Result, error) {
+	p, ok := m.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	saved, ok, err := m.states.Take(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || saved.Provider != provider {
+		return nil, ErrInvalidState
+	}
+
+	accessToken, err := p.exchange(ctx, m.httpClient, code, m.RedirectURI(provider))
+	if err != nil {
+		return nil, err
+	}
+	profile, err := p.profile(ctx, m.httpClient, p, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	profile.Email = strings.ToLower(strings.TrimSpace(profile.Email))
+
+	if saved.UserID != 0 {
+		return m.bind(ctx, provider, saved.UserID, profile)
+	}
+	return m.login(ctx, provider, profile)
+}
+
+// login 已绑定的身份直接登录；首次登录按已验证邮箱绑定已有账号或即时开通
+func (m *Manager) login(ctx context.Context, provider string, profile *Profile) (*Result, error) {
+	result := &Result{Provider: provider}
+
+	identity, err := m.store.FindIdentity(ctx, Issuer(provider), profile.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if identity != nil {
+		user, err := m.store.FindUserByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, errors.New("linked user no longer exists")
+		}
+		result.User = user
+	} else {
+		if profile.Email == "" || !profile.EmailVerified {
+			return nil, ErrEmailNotVerified
+		}
+
+		user, err := m.store.FindUserByEmail(ctx, profile.Email)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			if err := m.ensureNotBound(ctx, user.ID, provider); err != nil {
+				return nil, err
+			}
+			result.Linked = true
+			// 提供方已验证该邮箱，注册后尚未验证邮箱的账号直接激活。待验证账号的密码由未证明拥有该邮箱的
+			// 注册者设置（可能是抢注邮箱的攻击者），激活时作废，此后只能通过已绑定的第三方账号登录
+			if user.Status == model.UserStatusPending {
+				user.Status = model.UserStatusActive
+				user.PasswordHash = ""
+				if err := m.store.ActivateUser(ctx, user); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			user = &model.User{
+				Email:       profile.Email,
+				Username:    usernameFromProfile(profile),
+				DisplayName: displayNameFromProfile(profile),
+				AvatarURL:   profile.AvatarURL,
+				Role:        1,
+				Status:      model.UserStatusActive,
+			}
+			if err := m.store.CreateUser(ctx, user); err != nil {
+				return nil, err
+			}
+			result.Provisioned = true
+		}
+
+		if err := m.store.CreateIdentity(ctx, &model.UserIdentity{
+			UserID:  user.ID,
+			Issuer:  Issuer(provider),
+			Subject: profile.Subject,
+			Email:   profile.Email,
+		}); err != nil {
+			return nil, err
+		}
+		result.User = user
+	}
+
+	if result.User.Status != model.UserStatusActive {
+		return nil, ErrUserDisabled
+	}
+	return result, nil
+}
+
+// bind 将提供方账号绑定到发起绑定的用户，重复绑定同一账号视为成功
+func (m *Manager) bind(ctx context.Context, provider string, userID int, profile *Profile) (*Result, error) {
+	user, err := m.store.FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Status != model.UserStatusActive {
+		return nil, ErrUserDisabled
+	}
+	result := &Result{User: user, Provider: provider, Bound: true}
+
+	identity, err := m.store.FindIdentity(ctx, Issuer(provider), profile.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		if identity.UserID != userID {
+			return nil, ErrIdentityConflict
+		}
+		return result, nil
+	}
+
+	if err := m.ensureNotBound(ctx, userID, provider); err != nil {
+		return nil, err
+	}
+	if err := m.store.CreateIdentity(ctx, &model.UserIdentity{
+		UserID:  userID,
+		Issuer:  Issuer(provider),
+		Subject: profile.Subject,
+		Email:   profile.Email,
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ensureNotBound 每个用户在同一提供方下只能绑定一个账号
+func (m *Manager) ensureNotBound(ctx context.Context, userID int, provider string) error {
+	identities, err := m.store.ListIdentities(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, identity := range identities {
+		if identity.Issuer == Issuer(provider) {
+			return ErrAlreadyBound
+		}
+	}
+	return nil
+}
+
+// Bindings 列出用户已绑定的提供方账号，不含组织 SSO 身份
+func (m *Manager) Bindings(ctx context.Context, userID int) ([]Binding, error) {
+	identities, err := m.store.ListIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	bindings := make([]Binding, 0, len(identities))
+	for _, identity := range identities {
+		if !strings.HasPrefix(identity.Issuer, issuerPrefix) {
+			continue
+		}
+		bindings = append(bindings, Binding{
+			Provider:  strings.TrimPrefix(identity.Issuer, issuerPrefix),
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+	return bindings, nil
+}
+
+// Unbind 解除用户与提供方账号的绑定
+func (m *Manager) Unbind(ctx context.Context, userID int, provider string) error {
+	if _, ok := m.providers[provider]; !ok {
+		return ErrUnknownProvider
+	}
+	deleted, err := m.store.DeleteIdentity(ctx, userID, Issuer(provider))
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotBound
+	}
+	return nil
+}
+
+func usernameFromProfile(profile *Profile) string {
+	if profile.Username != "" {
+		return profile.Username
+	}
+	return profile.Email[:strings.LastIndex(profile.Email, "@")]
+}
+
+func displayNameFromProfile(profile *Profile) string {
+	if profile.DisplayName != "" {
+		return profile.DisplayName
+	}
+	return usernameFromProfile(profile)
+}
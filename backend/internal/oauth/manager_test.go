@@ -0,0 +1,329 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubGitHub 模拟 GitHub 的令牌、用户与邮箱接口
+type stubGitHub struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	users    map[string]map[string]interface{} // code -> /user 响应
+	verified map[string]bool                   // code -> 主邮箱是否已验证
+	tokens   map[string]string                 // access_token -> code
+}
+
+func newStubGitHub(t *testing.T) *stubGitHub {
+	s := &stubGitHub{
+		users:    make(map[string]map[string]interface{}),
+		verified: make(map[string]bool),
+		tokens:   make(map[string]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		code := r.PostForm.Get("code")
+		s.mu.Lock()
+		_, ok := s.users[code]
+		s.mu.Unlock()
+		// 与 GitHub 一致：授权码无效时仍返回 200
+		if !ok || r.PostForm.Get("client_secret") != "gh-secret" {
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		s.mu.Lock()
+		s.tokens["token-"+code] = code
+		s.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "token-" + code, "token_type": "bearer"})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		code, ok := s.codeFor(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(s.users[code])
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		code, ok := s.codeFor(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		_ = json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"email": "noreply@users.github.example", "primary": false, "verified": true},
+			{"email": s.users[code]["email"], "primary": true, "verified": s.verified[code]},
+		})
+	})
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *stubGitHub) codeFor(r *http.Request) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token := r.Header.Get("Authorization")
+	if len(token) <= len("Bearer ") {
+		return "", false
+	}
+	code, ok := s.tokens[token[len("Bearer "):]]
+	return code, ok
+}
+
+// expect 登记一个授权码及其对应的 GitHub 账号
+func (s *stubGitHub) expect(code string, id int64, login, email string, verified bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[code] = map[string]interface{}{"id": id, "login": login, "name": login, "email": email}
+	s.verified[code] = verified
+}
+
+func (s *stubGitHub) provider() *Provider {
+	p := GitHub("gh-client", "gh-secret")
+	p.Endpoints = Endpoints{
+		AuthURL:     s.server.URL + "/login/oauth/authorize",
+		TokenURL:    s.server.URL + "/login/oauth/access_token",
+		UserInfoURL: s.server.URL + "/user",
+		EmailsURL:   s.server.URL + "/user/emails",
+	}
+	return p
+}
+
+// memoryStore 内存中的 OAuth 存储
+type memoryStore struct {
+	mu         sync.Mutex
+	users      map[int]*model.User
+	identities []*model.UserIdentity
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{users: make(map[int]*model.User)}
+}
+
+func (s *memoryStore) FindIdentity(ctx context.Context, issuer, subject string) (*model.UserIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, identity := range s.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryStore) ListIdentities(ctx context.Context, userID int) ([]*model.UserIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var identities []*model.UserIdentity
+	for _, identity := range s.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+func (s *memoryStore) CreateIdentity(ctx context.Context, identity *model.UserIdentity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	identity.ID = len(s.identities) + 1
+	s.identities = append(s.identities, identity)
+	return nil
+}
+
+func (s *memoryStore) DeleteIdentity(ctx context.Context, userID int, issuer string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, identity := range s.identities {
+		if identity.UserID == userID && identity.Issuer == issuer {
+			s.identities = append(s.identities[:i], s.identities[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryStore) FindUserByID(ctx context.Context, id int) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.users[id], nil
+}
+
+func (s *memoryStore) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryStore) CreateUser(ctx context.Context, user *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user.ID = len(s.users) + 1
+	s.users[user.ID] = user
+	return nil
+}
+
+func (s *memoryStore) ActivateUser(ctx context.Context, user *model.User) error {
+	return nil
+}
+
+func newTestManager(t *testing.T) (*Manager, *memoryStore, *stubGitHub) {
+	stub := newStubGitHub(t)
+	store := newMemoryStore()
+	m := NewManager(store, NewMemoryStateStore(), "https://api.example.com/", stub.server.Client())
+	m.Register(stub.provider())
+	return m, store, stub
+}
+
+// authorize 发起流程并取回授权地址中的 state
+func authorize(t *testing.T, authURL string) string {
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.example.com/api/v1/oauth/github/callback", u.Query().Get("redirect_uri"))
+	assert.Equal(t, "gh-client", u.Query().Get("client_id"))
+	state := u.Query().Get("state")
+	require.NotEmpty(t, state)
+	return state
+}
+
+func TestOAuthLoginProvisionsThenReuses(t *testing.T) {
+	m, store, stub := newTestManager(t)
+	ctx := context.Background()
+	stub.expect("code-1", 42, "octocat", "Octo@Example.com", true)
+
+	authURL, err := m.BeginLogin(ctx, ProviderGitHub)
+	require.NoError(t, err)
+	result, err := m.Complete(ctx, ProviderGitHub, authorize(t, authURL), "code-1")
+	require.NoError(t, err)
+	assert.True(t, result.Provisioned)
+	assert.Equal(t, "octocat", result.User.Username)
+	assert.Equal(t, "octo@example.com", result.User.Email)
+	require.Len(t, store.identities, 1)
+	assert.Equal(t, "oauth:github", store.identities[0].Issuer)
+	assert.Equal(t, "42", store.identities[0].Subject)
+
+	// state 只能使用一次
+	_, err = m.Complete(ctx, ProviderGitHub, authorize(t, authURL), "code-1")
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	stub.expect("code-2", 42, "octocat", "octo@example.com", true)
+	authURL, err = m.BeginLogin(ctx, ProviderGitHub)
+	require.NoError(t, err)
+	again, err := m.Complete(ctx, ProviderGitHub, authorize(t, authURL), "code-2")
+	require.NoError(t, err)
+	assert.False(t, again.Provisioned)
+	assert.Equal(t, result.User.ID, again.User.ID)
+	assert.Len(t, store.users, 1)
+}
+
+func TestOAuthLoginLinksExistingEmail(t *testing.T) {
+	m, store, stub := newTestManager(t)
+	ctx := context.Background()
+	store.users[1] = &model.User{ID: 1, Username: "alice", Email: "alice@example.com", Status: model.UserStatusPending, PasswordHash: "squatter-hash"}
+
+	stub.expect("unverified", 7, "alice-gh", "alice@example.com", false)
+	authURL, err := m.BeginLogin(ctx, ProviderGitHub)
+	require.NoError(t, err)
+	_, err = m.Complete(ctx, ProviderGitHub, authorize(t, authURL), "unverified")
+	assert.ErrorIs(t, err, ErrEmailNotVerified, "unverified emails never link to existing accounts")
+
+	stub.expect("verified", 7, "alice-gh", "alice@example.com", true)
+	authURL, err = m.BeginLogin(ctx, ProviderGitHub)
+	require.NoError(t, err)
+	result, err := m.Complete(ctx, ProviderGitHub, authorize(t, authURL), "verified")
+	require.NoError(t, err)
+	assert.True(t, result.Linked)
+	assert.Equal(t, 1, result.User.ID)
+	assert.Equal(t, model.UserStatusActive, result.User.Status, "a verified provider email activates a pending account")
+	// 抢先用该邮箱注册的人设置的密码在激活时作废
+	assert.Empty(t, store.users[1].PasswordHash, "password set before the email was verified must not survive activation")
+}
+
+func TestOAuthLoginKeepsPasswordOfActiveAccount(t *testing.T) {
+	m, store, stub := newTestManager(t)
+	ctx := context.Background()
+	store.users[1] = &model.User{ID: 1, Username: "alice", Email: "alice@example.com", Status: model.UserStatusActive, PasswordHash: "alice-hash"}
+
+	stub.expect("verified", 7, "alice-gh", "alice@example.com", true)
+	authURL, err := m.BeginLogin(ctx, ProviderGitHub)
+	require.NoError(t, err)
+	result, err := m.Complete(ctx, ProviderGitHub, authorize(t, authURL), "verified")
+	require.NoError(t, err)
+	assert.True(t, result.Linked)
+	assert.Equal(t, "alice-hash", store.users[1].PasswordHash)
+}
+
+func TestOAuthBindConflictAndUnbind(t *testing.T) {
+	m, store, stub := newTestManager(t)
+	ctx := context.Background()
+	store.users[1] = &model.User{ID: 1, Username: "alice", Email: "alice@example.com", Status: model.UserStatusActive}
+	store.users[2] = &model.User{ID: 2, Username: "bob", Email: "bob@example.com", Status: model.UserStatusActive}
+
+	stub.expect("alice-code", 100, "alice-gh", "alice@personal.example", true)
+	authURL, err := m.BeginBind(ctx, ProviderGitHub, 1)
+	require.NoError(t, err)
+	result, err := m.Complete(ctx, ProviderGitHub, authorize(t, authURL), "alice-code")
+	require.NoError(t, err)
+	assert.True(t, result.Bound)
+	assert.Equal(t, 1, result.User.ID)
+
+	bindings, err := m.Bindings(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, bindings, 1)
+	assert.Equal(t, ProviderGitHub, bindings[0].Provider)
+	assert.Equal(t, "alice@personal.example", bindings[0].Email)
+
+	stub.expect("bob-code", 100, "alice-gh", "alice@personal.example", true)
+	authURL, err = m.BeginBind(ctx, ProviderGitHub, 2)
+	require.NoError(t, err)
+	_, err = m.Complete(ctx, ProviderGitHub, authorize(t, authURL), "bob-code")
+	assert.ErrorIs(t, err, ErrIdentityConflict)
+
+	stub.expect("other-code", 200, "alice-work", "alice@work.example", true)
+	authURL, err = m.BeginBind(ctx, ProviderGitHub, 1)
+	require.NoError(t, err)
+	_, err = m.Complete(ctx, ProviderGitHub, authorize(t, authURL), "other-code")
+	assert.ErrorIs(t, err, ErrAlreadyBound)
+
+	require.NoError(t, m.Unbind(ctx, 1, ProviderGitHub))
+	assert.ErrorIs(t, m.Unbind(ctx, 1, ProviderGitHub), ErrNotBound)
+	assert.ErrorIs(t, m.Unbind(ctx, 1, ProviderGoogle), ErrUnknownProvider)
+}
+
+func TestOAuthRejectsBadCodeAndMismatchedState(t *testing.T) {
+	m, _, _ := newTestManager(t)
+	ctx := context.Background()
+
+	authURL, err := m.BeginLogin(ctx, ProviderGitHub)
+	require.NoError(t, err)
+	_, err = m.Complete(ctx, ProviderGitHub, authorize(t, authURL), "unknown-code")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad_verification_code")
+
+	_, err = m.BeginLogin(ctx, ProviderGoogle)
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+	_, err = m.Complete(ctx, ProviderGitHub, "forged-state", "code")
+	assert.ErrorIs(t, err, ErrInvalidState)
+}
@@ -0,0 +1,226 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// 内置的提供方名称
+const (
+	ProviderGitHub = "github"
+	ProviderGoogle = "google"
+)
+
+// Profile 提供方返回的用户资料
+type Profile struct {
+	Subject       string // 提供方内的稳定用户 ID
+	Email         string
+	EmailVerified bool
+	Username      string
+	DisplayName   string
+	AvatarURL     string
+}
+
+// Endpoints 提供方的授权、令牌与用户信息地址，测试时可指向模拟服务
+type Endpoints struct {
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	EmailsURL   string // GitHub 的邮箱列表接口，/user 不一定返回邮箱
+}
+
+// Provider OAuth 2.0 授权码流程的提供方
+type Provider struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Endpoints    Endpoints
+
+	profile func(ctx context.Context, c *http.Client, p *Provider, accessToken string) (*Profile, error)
+}
+
+// GitHub 创建 GitHub 提供方
+func GitHub(clientID, clientSecret string) *Provider {
+	return &Provider{
+		Name:         ProviderGitHub,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       []string{"read:user", "user:email"},
+		Endpoints: Endpoints{
+			AuthURL:     "https://github.com/login/oauth/authorize",
+			TokenURL:    "https://github.com/login/oauth/access_token",
+			UserInfoURL: "https://api.github.com/user",
+			EmailsURL:   "https://api.github.com/user/emails",
+		},
+		profile: githubProfile,
+	}
+}
+
+// Google 创建 Google 提供方
+func Google(clientID, clientSecret string) *Provider {
+	return &Provider{
+		Name:         ProviderGoogle,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       []string{"openid", "email", "profile"},
+		Endpoints: Endpoints{
+			AuthURL:     "https://accounts.google.com/o/oauth2/v2/auth",
+			TokenURL:    "https://oauth2.googleapis.com/token",
+			UserInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+		},
+		profile: googleProfile,
+	}
+}
+
+// AuthCodeURL 提供方的授权地址
+func (p *Provider) AuthCodeURL(state, redirectURI string) string {
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	sep := "?"
+	if strings.Contains(p.Endpoints.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.Endpoints.AuthURL + sep + q.Encode()
+}
+
+// exchange 使用授权码换取访问令牌
+func (p *Provider) exchange(ctx context.Context, c *http.Client, code, redirectURI string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoints.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	// GitHub 在授权码无效时同样返回 200，错误放在 error 字段中
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil && resp.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("decode token response: %w", err)
+	}
+	if token.Error != "" {
+		return "", fmt.Errorf("token endpoint returned %s: %s", token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("token response does not contain an access_token")
+	}
+	return token.AccessToken, nil
+}
+
+// githubProfile 读取 GitHub 用户资料，邮箱取已验证的主邮箱
+func githubProfile(ctx context.Context, c *http.Client, p *Provider, accessToken string) (*Profile, error) {
+	var user struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := getJSON(ctx, c, p.Endpoints.UserInfoURL, accessToken, &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("github user response does not contain an id")
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, c, p.Endpoints.EmailsURL, accessToken, &emails); err != nil {
+		return nil, err
+	}
+	profile := &Profile{
+		Subject:     strconv.FormatInt(user.ID, 10),
+		Username:    user.Login,
+		DisplayName: user.Name,
+		AvatarURL:   user.AvatarURL,
+	}
+	for _, e := range emails {
+		if e.Primary {
+			profile.Email, profile.EmailVerified = e.Email, e.Verified
+			break
+		}
+	}
+	return profile, nil
+}
+
+// googleProfile 读取 Google OpenID Connect 用户信息
+func googleProfile(ctx context.Context, c *http.Client, p *Provider, accessToken string) (*Profile, error) {
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+		Picture       string `json:"picture"`
+	}
+	if err := getJSON(ctx, c, p.Endpoints.UserInfoURL, accessToken, &info); err != nil {
+		return nil, err
+	}
+	if info.Sub == "" {
+		return nil, errors.New("google userinfo response does not contain a subject")
+	}
+	return &Profile{
+		Subject:       info.Sub,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified,
+		DisplayName:   info.Name,
+		AvatarURL:     info.Picture,
+	}, nil
+}
+
+func getJSON(ctx context.Context, c *http.Client, rawURL, accessToken string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, rawURL)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
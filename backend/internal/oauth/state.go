@@ -0,0 +1,123 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// State 发起授权时保存、回调时取回的上下文
+type State struct {
+	Provider string `json:"provider"`
+	UserID   int    `json:"user_id,omitempty"` // 绑定流程的当前用户，登录流程为 0
+}
+
+// StateStore 授权状态存储，取出即删除，保证一次性使用
+type StateStore interface {
+	Save(ctx context.Context, state string, data *State, ttl time.Duration) error
+	Take(ctx context.Context, state string) (*State, bool, error)
+}
+
+// redisStateKeyPrefix Redis 中授权状态的键前缀
+const redisStateKeyPrefix = "oauth_state:"
+
+// RedisStateStore 基于 Redis 的授权状态存储，多实例部署时回调可以落在任意实例
+type RedisStateStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisStateStore 创建 Redis 授权状态存储
+func NewRedisStateStore(client redis.UniversalClient) *RedisStateStore {
+	return &RedisStateStore{client: client}
+}
+
+// Save 保存状态，ttl 后过期
+func (s *RedisStateStore) Save(ctx context.Context, state string, data *State, ttl time.Duration) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, redisStateKeyPrefix+state, value, ttl).Err()
+}
+
+// Take 在同一事务中读取并删除状态
+func (s *RedisStateStore) Take(ctx context.Context, state string) (*State, bool, error) {
+	key := redisStateKeyPrefix + state
+	pipe := s.client.TxPipeline()
+	get := pipe.Get(ctx, key)
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, false, err
+	}
+	value, err := get.Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var data State
+	if err := json.Unmarshal(value, &data); err != nil {
+		return nil, false, err
+	}
+	return &data, true, nil
+}
+
+// MemoryStateStore 基于内存的授权状态存储，只适用于单实例部署与测试
+type MemoryStateStore struct {
+	mu      sync.Mutex
+	states  map[string]*State
+	expires map[string]time.Time
+}
+
+// NewMemoryStateStore 创建内存授权状态存储
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{states: make(map[string]*State), expires: make(map[string]time.Time)}
+}
+
+// Save 保存状态，并顺带清理已过期的条目
+func (s *MemoryStateStore) Save(ctx context.Context, state string, data *State, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, expiresAt := range s.expires {
+		if now.After(expiresAt) {
+			delete(s.states, key)
+			delete(s.expires, key)
+		}
+	}
+	s.states[state] = data
+	s.expires[state] = now.Add(ttl)
+	return nil
+}
+
+// Take 取出并删除状态
+func (s *MemoryStateStore) Take(ctx context.Context, state string) (*State, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.states[state]
+	expiresAt := s.expires[state]
+	delete(s.states, state)
+	delete(s.expires, state)
+	if !ok || time.Now().After(expiresAt) {
+		return nil, false, nil
+	}
+	return data, true, nil
+}
+
+// randomToken 生成 URL 安全的随机字符串
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -2,6 +2,7 @@ package performance
 
 import (
 	"runtime"
+	"runtime/debug"
 	"sync"
 	"time"
 )
@@ -11,12 +11,12 @@ import (
 
 // RedisQuotaCache Redis配额缓存实现
 type RedisQuotaCache struct {
-	client *redis.Client
+	client redis.UniversalClient
 	ttl    time.Duration
 }
 
 // NewRedisQuotaCache 创建Redis配额缓存
-func NewRedisQuotaCache(client *redis.Client) *RedisQuotaCache {
+func NewRedisQuotaCache(client redis.UniversalClient) *RedisQuotaCache {
 	return &RedisQuotaCache{
 		client: client,
 		ttl:    15 * time.Minute, // 预扣费记录15分钟过期
@@ -0,0 +1,14 @@
+package quota
+
+// CheckResult 额度预检结果（计费服务内部接口与网关共用）
+type CheckResult struct {
+	UserID           int     `json:"user_id"`
+	Allow            bool    `json:"allow"`
+	RemainingBalance float64 `json:"remaining_balance"`
+	EstimatedCost    float64 `json:"estimated_cost"`
+}
+
+// Allows 余额是否足以支付预估费用（余额为 0 时始终拒绝）
+func Allows(balance, estimatedCost float64) bool {
+	return balance > 0 && balance >= estimatedCost
+}
@@ -8,7 +8,7 @@ import (
 )
 
 // ExampleUsage 配额服务使用示例
-func ExampleUsage(db *gorm.DB, redisClient *redis.Client) {
+func ExampleUsage(db *gorm.DB, redisClient redis.UniversalClient) {
 	// 1. 创建组件
 	calculator := NewDefaultQuotaCalculator(db)
 	cache := NewRedisQuotaCache(redisClient)
@@ -64,7 +64,7 @@ func ExampleUsage(db *gorm.DB, redisClient *redis.Client) {
 }
 
 // ExampleStreamUsage 流式请求使用示例
-func ExampleStreamUsage(db *gorm.DB, redisClient *redis.Client) {
+func ExampleStreamUsage(db *gorm.DB, redisClient redis.UniversalClient) {
 	calculator := NewDefaultQuotaCalculator(db)
 	cache := NewRedisQuotaCache(redisClient)
 	quotaService := NewDefaultQuotaService(db, cache, calculator)
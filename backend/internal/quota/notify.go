@@ -0,0 +1,70 @@
+package quota
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+)
+
+// BalanceChangedChannel 余额变更通知的 Redis 频道，消息体为用户 ID
+const BalanceChangedChannel = "quota:balance_changed"
+
+// BalanceNotifier 余额变更通知
+type BalanceNotifier interface {
+	NotifyBalanceChanged(ctx context.Context, userID int) error
+}
+
+// RedisBalanceNotifier 通过 Redis Pub/Sub 广播余额变更
+type RedisBalanceNotifier struct {
+	client redis.UniversalClient
+}
+
+// NewRedisBalanceNotifier 创建通知器，client 为 nil 时在发布时使用全局 Redis 客户端
+func NewRedisBalanceNotifier(client redis.UniversalClient) *RedisBalanceNotifier {
+	return &RedisBalanceNotifier{client: client}
+}
+
+// NotifyBalanceChanged 发布余额变更，未初始化 Redis 时静默跳过
+func (n *RedisBalanceNotifier) NotifyBalanceChanged(ctx context.Context, userID int) error {
+	client := n.client
+	if client == nil {
+		client = database.RedisClient
+	}
+	if client == nil {
+		return nil
+	}
+	return client.Publish(ctx, BalanceChangedChannel, strconv.Itoa(userID)).Err()
+}
+
+// SubscribeBalanceChanges 订阅余额变更，返回的函数用于取消订阅
+func SubscribeBalanceChanges(ctx context.Context, client redis.UniversalClient, handler func(userID int)) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	pubsub := client.Subscribe(ctx, BalanceChangedChannel)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				if userID, err := strconv.Atoi(msg.Payload); err == nil {
+					handler(userID)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		pubsub.Close()
+		<-done
+	}
+}
@@ -1,6 +1,7 @@
 package quota
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -14,6 +15,7 @@ type DefaultQuotaService struct {
 	db         *gorm.DB
 	cache      QuotaCache
 	calculator QuotaCalculator
+	notifier   BalanceNotifier
 }
 
 // NewDefaultQuotaService 创建默认配额服务
@@ -22,9 +24,15 @@ func NewDefaultQuotaService(db *gorm.DB, cache QuotaCache, calculator QuotaCalcu
 		db:         db,
 		cache:      cache,
 		calculator: calculator,
+		notifier:   NewRedisBalanceNotifier(nil),
 	}
 }
 
+// SetNotifier 设置余额变更通知器
+func (s *DefaultQuotaService) SetNotifier(notifier BalanceNotifier) {
+	s.notifier = notifier
+}
+
 // PreConsumeQuota 预扣费
 func (s *DefaultQuotaService) PreConsumeQuota(req *PreConsumeRequest) (*PreConsumeResponse, error) {
 	// 1. 获取用户余额
@@ -148,16 +156,19 @@ func (s *DefaultQuotaService) PostConsumeQuota(req *PostConsumeRequest) error {
 
 	// 4. 记录消费日志
 	log := &model.UnifiedLog{
-		UserID:           req.UserID,
-		ChannelID:        req.ChannelID,
-		LogType:          1, // 1:消费
-		ModelName:        req.Model,
-		PromptTokens:     req.PromptTokens,
-		CompletionTokens: req.CompletionTokens,
-		Quota:            int(req.ActualQuota),
-		IsStream:         req.IsStream,
-		UseTime:          int(req.ResponseTime),
-		CreatedAt:        time.Now(),
+		UserID:            req.UserID,
+		ChannelID:         req.ChannelID,
+		LogType:           1, // 1:消费
+		ModelName:         req.Model,
+		PromptTokens:      req.PromptTokens,
+		CompletionTokens:  req.CompletionTokens,
+		ReasoningTokens:   req.ReasoningTokens,
+		Quota:             int(req.ActualQuota),
+		IsStream:          req.IsStream,
+		UseTime:           int(req.ResponseTime),
+		RequestID:         req.RequestID,
+		UpstreamRequestID: req.UpstreamRequestID,
+		CreatedAt:         time.Now(),
 	}
 
 	if err := s.db.Create(log).Error; err != nil {
@@ -165,8 +176,9 @@ func (s *DefaultQuotaService) PostConsumeQuota(req *PostConsumeRequest) error {
 		fmt.Printf("failed to create consume log: %v\n", err)
 	}
 
-	// 5. 失效用户余额缓存
+	// 5. 失效用户余额缓存，并通知网关等订阅方
 	_ = s.cache.InvalidateUserBalance(req.UserID)
+	s.notifyBalanceChanged(req.UserID)
 
 	return nil
 }
@@ -187,6 +199,7 @@ func (s *DefaultQuotaService) RefundQuota(req *RefundRequest) error {
 
 	_ = s.db.Create(log)
 	_ = s.cache.InvalidateUserBalance(req.UserID)
+	s.notifyBalanceChanged(req.UserID)
 
 	return nil
 }
@@ -215,6 +228,14 @@ func (s *DefaultQuotaService) GetPreConsumedRecord(requestID string) (*PreConsum
 	return s.cache.GetPreConsumed(requestID)
 }
 
+// notifyBalanceChanged 广播余额变更（通知失败只影响订阅方缓存的时效）
+func (s *DefaultQuotaService) notifyBalanceChanged(userID int) {
+	if s.notifier == nil {
+		return
+	}
+	_ = s.notifier.NotifyBalanceChanged(context.Background(), userID)
+}
+
 // deductQuota 扣除配额（内部方法）
 func (s *DefaultQuotaService) deductQuota(userID int, quota float64) error {
 	result := s.db.Model(&model.User{}).
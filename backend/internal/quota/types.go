@@ -32,16 +32,18 @@ type PreConsumeResponse struct {
 
 // PostConsumeRequest 后扣费请求
 type PostConsumeRequest struct {
-	RequestID        string  `json:"request_id"`        // 请求ID
-	UserID           int     `json:"user_id"`           // 用户ID
-	ChannelID        int     `json:"channel_id"`        // 渠道ID
-	Model            string  `json:"model"`             // 模型名称
-	PromptTokens     int     `json:"prompt_tokens"`     // 实际Prompt Tokens
-	CompletionTokens int     `json:"completion_tokens"` // 实际Completion Tokens
-	TotalTokens      int     `json:"total_tokens"`      // 总Tokens
-	ActualQuota      float64 `json:"actual_quota"`      // 实际配额消耗
-	IsStream         bool    `json:"is_stream"`         // 是否流式
-	ResponseTime     int64   `json:"response_time"`     // 响应时间（毫秒）
+	RequestID         string  `json:"request_id"`          // 请求ID
+	UserID            int     `json:"user_id"`             // 用户ID
+	ChannelID         int     `json:"channel_id"`          // 渠道ID
+	Model             string  `json:"model"`               // 模型名称
+	PromptTokens      int     `json:"prompt_tokens"`       // 实际Prompt Tokens
+	CompletionTokens  int     `json:"completion_tokens"`   // 实际Completion Tokens
+	ReasoningTokens   int     `json:"reasoning_tokens"`    // 推理Tokens（已包含在Completion Tokens中）
+	TotalTokens       int     `json:"total_tokens"`        // 总Tokens
+	ActualQuota       float64 `json:"actual_quota"`        // 实际配额消耗
+	IsStream          bool    `json:"is_stream"`           // 是否流式
+	ResponseTime      int64   `json:"response_time"`       // 响应时间（毫秒）
+	UpstreamRequestID string  `json:"upstream_request_id"` // 上游提供方请求ID
 }
 
 // RefundRequest 退款请求
@@ -0,0 +1,215 @@
+package rag
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseFixture 通过注册表按内容类型解析 testdata 中的文件
+func parseFixture(t *testing.T, name string) *ParsedContent {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	parsed, err := NewDocumentParserRegistry().ParseBytes(data, name)
+	if err != nil {
+		t.Fatalf("ParseBytes(%s) failed: %v", name, err)
+	}
+	return parsed
+}
+
+// sectionHeadings 各章节的标题路径
+func sectionHeadings(sections []Section) [][]string {
+	paths := make([][]string, len(sections))
+	for i, s := range sections {
+		paths[i] = s.HeadingPath
+	}
+	return paths
+}
+
+func TestParsePDFFixture(t *testing.T) {
+	parsed := parseFixture(t, "sample.pdf")
+
+	if parsed.ContentType != "application/pdf" {
+		t.Errorf("expected application/pdf, got %s", parsed.ContentType)
+	}
+	if parsed.Pages != 2 {
+		t.Errorf("expected 2 pages, got %d", parsed.Pages)
+	}
+	if len(parsed.Sections) != 2 {
+		t.Fatalf("expected one section per page, got %d", len(parsed.Sections))
+	}
+
+	first := parsed.Sections[0]
+	if first.Page != 1 {
+		t.Errorf("expected page 1, got %d", first.Page)
+	}
+	want := "Quarterly Report\nRevenue grew by 12 percent in Q3).\nCosts stayed flat."
+	if first.Text != want {
+		t.Errorf("page 1 text mismatch:\n got: %q\nwant: %q", first.Text, want)
+	}
+
+	// 第二页使用带 ToUnicode 映射的双字节字体，资源直接定义在页面上
+	second := parsed.Sections[1]
+	if second.Page != 2 || second.Text != "知识库\nABC" {
+		t.Errorf("unexpected page 2 section: %+v", second)
+	}
+}
+
+func TestParsePDFObjectStreams(t *testing.T) {
+	parsed := parseFixture(t, "objstm.pdf")
+
+	if parsed.Pages != 1 {
+		t.Fatalf("expected 1 page, got %d", parsed.Pages)
+	}
+	if parsed.Content != "Compressed object streams\nare supported." {
+		t.Errorf("unexpected content: %q", parsed.Content)
+	}
+}
+
+func TestParsePDFInvalid(t *testing.T) {
+	parser := &PDFParser{}
+
+	if _, err := parser.Parse(strings.NewReader("not a pdf"), "fake.pdf"); err == nil {
+		t.Error("expected error for missing header")
+	}
+	if _, err := parser.Parse(strings.NewReader("%PDF-1.4\ntrailer << /Root 1 0 R /Encrypt 2 0 R >>"), "locked.pdf"); err == nil {
+		t.Error("expected error for encrypted pdf")
+	}
+
+	// 只有图像没有文本层的 PDF：解析成功但没有文本
+	scanned := "%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 >>\nendobj\n" +
+		"3 0 obj\n<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>\nendobj\n" +
+		"4 0 obj\n<< /Length 31 >>\nstream\nq 612 0 0 792 0 0 cm /Im1 Do Q\nendstream\nendobj\n"
+	_, err := NewDocumentParserRegistry().ParseBytes([]byte(scanned), "scan.pdf")
+	if !errors.Is(err, ErrNoTextContent) {
+		t.Errorf("expected ErrNoTextContent, got %v", err)
+	}
+}
+
+func TestParseDOCXFixture(t *testing.T) {
+	parsed := parseFixture(t, "sample.docx")
+
+	if parsed.ContentType != DOCXContentType {
+		t.Errorf("expected docx content type, got %s", parsed.ContentType)
+	}
+
+	wantHeadings := [][]string{
+		{"Employee Handbook"},
+		{"Leave Policy"},
+		{"Leave Policy", "Carry-over"},
+		{"Security"},
+	}
+	if got := sectionHeadings(parsed.Sections); !reflect.DeepEqual(got, wantHeadings) {
+		t.Fatalf("heading paths mismatch:\n got: %v\nwant: %v", got, wantHeadings)
+	}
+
+	if parsed.Sections[0].Text != "Employee Handbook\nWelcome to the company." {
+		t.Errorf("runs should be joined within a paragraph: %q", parsed.Sections[0].Text)
+	}
+	if !strings.Contains(parsed.Sections[2].Text, "carry over & expire") {
+		t.Errorf("entities should be decoded: %q", parsed.Sections[2].Text)
+	}
+	if parsed.Sections[3].Text != "Security\nBadge\nAlways visible\nReport incidents\timmediately." {
+		t.Errorf("unexpected table section: %q", parsed.Sections[3].Text)
+	}
+}
+
+func TestParseDOCXInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("readme.txt")
+	w.Write([]byte("not a word document"))
+	zw.Close()
+
+	if _, err := (&DOCXParser{}).Parse(bytes.NewReader(buf.Bytes()), "fake.docx"); err == nil {
+		t.Error("expected error for zip without word/document.xml")
+	}
+
+	// 普通 ZIP 不会被识别为 DOCX
+	_, err := NewDocumentParserRegistry().ParseBytes(buf.Bytes(), "fake.docx")
+	if !errors.Is(err, ErrUnsupportedDocument) {
+		t.Errorf("expected ErrUnsupportedDocument, got %v", err)
+	}
+}
+
+func TestParseMarkdownFixture(t *testing.T) {
+	parsed := parseFixture(t, "sample.md")
+
+	if parsed.ContentType != "text/markdown" {
+		t.Errorf("expected text/markdown, got %s", parsed.ContentType)
+	}
+
+	wantHeadings := [][]string{
+		nil,
+		{"Installation"},
+		{"Installation", "Configuration"},
+		{"Installation", "Upgrading"},
+		{"Troubleshooting"},
+	}
+	if got := sectionHeadings(parsed.Sections); !reflect.DeepEqual(got, wantHeadings) {
+		t.Fatalf("heading paths mismatch:\n got: %v\nwant: %v", got, wantHeadings)
+	}
+
+	// 代码块中的 # 不是标题，代码缩进保留
+	config := parsed.Sections[2].Text
+	if !strings.Contains(config, "# this is a comment, not a heading") || !strings.HasPrefix(config, "## Configuration") {
+		t.Errorf("unexpected configuration section: %q", config)
+	}
+}
+
+func TestParseHTMLFixture(t *testing.T) {
+	parsed := parseFixture(t, "sample.html")
+
+	if parsed.ContentType != "text/html" {
+		t.Errorf("expected text/html, got %s", parsed.ContentType)
+	}
+	for _, hidden := range []string{"color: red", "do not index", "internal note", "FAQ", "<b>"} {
+		if strings.Contains(parsed.Content, hidden) {
+			t.Errorf("content should not contain %q: %q", hidden, parsed.Content)
+		}
+	}
+
+	wantHeadings := [][]string{nil, {"Billing"}, {"Billing", "Refunds"}, {"Accounts"}}
+	if got := sectionHeadings(parsed.Sections); !reflect.DeepEqual(got, wantHeadings) {
+		t.Fatalf("heading paths mismatch:\n got: %v\nwant: %v", got, wantHeadings)
+	}
+
+	if parsed.Sections[0].Text != "Frequently asked questions & answers." {
+		t.Errorf("unexpected intro: %q", parsed.Sections[0].Text)
+	}
+	if parsed.Sections[1].Text != "Billing\nInvoices are sent monthly." {
+		t.Errorf("unexpected billing section: %q", parsed.Sections[1].Text)
+	}
+	if parsed.Sections[3].Text != "Accounts\nUse <Settings> to change your password." {
+		t.Errorf("unexpected accounts section: %q", parsed.Sections[3].Text)
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	cases := []struct {
+		data     string
+		filename string
+		want     string
+	}{
+		{"%PDF-1.7\n", "report.txt", "application/pdf"},
+		{"# Title\n", "notes.md", "text/markdown"},
+		{"# Title\n", "notes.txt", "text/plain"},
+		{"a,b\n1,2\n", "data.CSV", "text/csv"},
+		{"<html><body>hi</body></html>", "page.txt", "text/html"},
+		{"\x00\x01\x02\x03", "blob.txt", "application/octet-stream"},
+	}
+	for _, c := range cases {
+		if got := DetectContentType([]byte(c.data), c.filename); got != c.want {
+			t.Errorf("DetectContentType(%q, %s) = %s, want %s", c.data, c.filename, got, c.want)
+		}
+	}
+}
@@ -1,14 +1,31 @@
 package rag
 
 import (
+	"archive/zip"
+	"bytes"
+	"errors"
 	"fmt"
+	"html"
 	"io"
+	"mime"
 	"mime/multipart"
+	"net/http"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 )
 
+var (
+	// ErrUnsupportedDocument 没有对应文件类型的解析器
+	ErrUnsupportedDocument = errors.New("unsupported document type")
+	// ErrNoTextContent 文档中没有可提取的文本（如扫描版 PDF）
+	ErrNoTextContent = errors.New("no text content extracted from document")
+)
+
+// DOCXContentType Word 文档（.docx）的 MIME 类型
+const DOCXContentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
 // ParsedContent 解析后的内容
 type ParsedContent struct {
 	// 文档标题
@@ -31,6 +48,19 @@ type ParsedContent struct {
 
 	// 语言检测
 	Language string `json:"language"`
+
+	// 检测到的内容类型
+	ContentType string `json:"content_type"`
+
+	// 按页或章节划分的内容，分块时每段单独切分以记录位置
+	Sections []Section `json:"sections"`
+}
+
+// Section 文档中的一段连续内容及其位置，用于引用时显示页码或标题路径
+type Section struct {
+	Text        string   `json:"text"`
+	Page        int      `json:"page,omitempty"`         // 从 1 开始的页码（PDF）
+	HeadingPath []string `json:"heading_path,omitempty"` // 所在章节的标题路径
 }
 
 // DocumentParser 文档解析器接口
@@ -38,6 +68,9 @@ type DocumentParser interface {
 	// 支持的文件类型
 	SupportedTypes() []string
 
+	// 支持的 MIME 类型，用于按检测到的内容类型选择解析器
+	ContentTypes() []string
+
 	// 解析文件
 	Parse(file io.Reader, filename string) (*ParsedContent, error)
 
@@ -53,7 +86,11 @@ func (tp *TextParser) Name() string {
 }
 
 func (tp *TextParser) SupportedTypes() []string {
-	return []string{".txt", ".md", ".markdown"}
+	return []string{".txt"}
+}
+
+func (tp *TextParser) ContentTypes() []string {
+	return []string{"text/plain"}
 }
 
 func (tp *TextParser) Parse(file io.Reader, filename string) (*ParsedContent, error) {
@@ -95,6 +132,10 @@ func (jp *JSONParser) SupportedTypes() []string {
 	return []string{".json"}
 }
 
+func (jp *JSONParser) ContentTypes() []string {
+	return []string{"application/json"}
+}
+
 func (jp *JSONParser) Parse(file io.Reader, filename string) (*ParsedContent, error) {
 	start := time.Now()
 
@@ -126,6 +167,10 @@ func (cp *CSVParser) SupportedTypes() []string {
 	return []string{".csv"}
 }
 
+func (cp *CSVParser) ContentTypes() []string {
+	return []string{"text/csv"}
+}
+
 func (cp *CSVParser) Parse(file io.Reader, filename string) (*ParsedContent, error) {
 	start := time.Now()
 
@@ -158,6 +203,10 @@ func (hp *HTMLParser) SupportedTypes() []string {
 	return []string{".html", ".htm"}
 }
 
+func (hp *HTMLParser) ContentTypes() []string {
+	return []string{"text/html"}
+}
+
 func (hp *HTMLParser) Parse(file io.Reader, filename string) (*ParsedContent, error) {
 	start := time.Now()
 
@@ -166,9 +215,7 @@ func (hp *HTMLParser) Parse(file io.Reader, filename string) (*ParsedContent, er
 		return nil, err
 	}
 
-	content := string(data)
-	// 简单提取文本内容（去除HTML标签）
-	text := hp.stripHTMLTags(content)
+	text, sections := hp.extractText(string(data))
 
 	return &ParsedContent{
 		Title:         filename,
@@ -177,26 +224,137 @@ func (hp *HTMLParser) Parse(file io.Reader, filename string) (*ParsedContent, er
 		Metadata:      map[string]interface{}{"type": "html"},
 		ParseDuration: time.Since(start),
 		Language:      "unknown",
+		Sections:      sections,
 	}, nil
 }
 
-func (hp *HTMLParser) stripHTMLTags(html string) string {
-	// 简单的HTML标签移除（生产环境应使用htmlquery）
-	result := ""
-	inTag := false
+// htmlSkippedTags 内容不参与正文的标签
+var htmlSkippedTags = map[string]bool{"script": true, "style": true, "noscript": true, "template": true, "head": true}
+
+// htmlBlockTags 前后需要换行的块级标签
+var htmlBlockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "section": true, "article": true,
+	"header": true, "footer": true, "blockquote": true, "pre": true, "hr": true, "table": true,
+	"ul": true, "ol": true, "dt": true, "dd": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true,
+}
+
+// extractText 去除标签与脚本、解码实体，并按 h1-h6 标题划分章节
+func (hp *HTMLParser) extractText(doc string) (string, []Section) {
+	var (
+		sections []Section
+		current  strings.Builder
+		heading  strings.Builder
+		headings headingPath
+		level    int // 正在读取的标题级别，0 表示不在标题中
+		skip     string
+	)
+	flush := func() {
+		if text := collapseBlankLines(current.String()); text != "" {
+			sections = append(sections, Section{Text: text, HeadingPath: headings.current()})
+		}
+		current.Reset()
+	}
 
-	for _, c := range html {
-		if c == '<' {
-			inTag = true
-		} else if c == '>' {
-			inTag = false
-			result += " "
-		} else if !inTag {
-			result += string(c)
+	for len(doc) > 0 {
+		lt := strings.IndexByte(doc, '<')
+		if lt != 0 {
+			if lt < 0 {
+				lt = len(doc)
+			}
+			if skip == "" {
+				text := collapseHTMLSpace(html.UnescapeString(doc[:lt]))
+				if level > 0 {
+					heading.WriteString(text)
+				}
+				// 行首不保留空白
+				if end := current.String(); end == "" || strings.HasSuffix(end, "\n") || strings.HasSuffix(end, " ") {
+					text = strings.TrimLeft(text, " ")
+				}
+				current.WriteString(text)
+			}
+			doc = doc[lt:]
+			continue
+		}
+
+		if strings.HasPrefix(doc, "<!--") {
+			end := strings.Index(doc, "-->")
+			if end < 0 {
+				break
+			}
+			doc = doc[end+3:]
+			continue
+		}
+
+		gt := strings.IndexByte(doc, '>')
+		if gt < 0 {
+			break
+		}
+		tag := doc[1:gt]
+		doc = doc[gt+1:]
+
+		closing := strings.HasPrefix(tag, "/")
+		name := strings.ToLower(strings.TrimLeft(tag, "/"))
+		if i := strings.IndexAny(name, " \t\r\n/"); i >= 0 {
+			name = name[:i]
+		}
+
+		if skip != "" {
+			if closing && name == skip {
+				skip = ""
+			}
+			continue
+		}
+		if htmlSkippedTags[name] && !closing && !strings.HasSuffix(tag, "/") {
+			skip = name
+			continue
+		}
+
+		if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' {
+			if !closing {
+				flush()
+				level = int(name[1] - '0')
+				heading.Reset()
+			} else if level > 0 {
+				// 标题文本已写入新章节的开头，标题路径在章节结束时记录
+				headings.push(level, strings.TrimSpace(heading.String()))
+				level = 0
+			}
+		}
+		if htmlBlockTags[name] {
+			if end := current.String(); end != "" && !strings.HasSuffix(end, "\n") {
+				current.WriteString("\n")
+			}
+		} else if name == "td" || name == "th" {
+			current.WriteString(" ")
 		}
 	}
+	flush()
 
-	return result
+	texts := make([]string, len(sections))
+	for i, section := range sections {
+		texts[i] = section.Text
+	}
+	return strings.Join(texts, "\n\n"), sections
+}
+
+// collapseHTMLSpace 将连续空白合并为一个空格，首尾的空白同样保留为一个空格
+func collapseHTMLSpace(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		if text != "" {
+			return " "
+		}
+		return ""
+	}
+	collapsed := strings.Join(fields, " ")
+	if strings.TrimLeft(text, " \t\r\n\f") != text {
+		collapsed = " " + collapsed
+	}
+	if strings.TrimRight(text, " \t\r\n\f") != text {
+		collapsed += " "
+	}
+	return collapsed
 }
 
 // XMLParser XML文件解析器
@@ -210,6 +368,10 @@ func (xp *XMLParser) SupportedTypes() []string {
 	return []string{".xml"}
 }
 
+func (xp *XMLParser) ContentTypes() []string {
+	return []string{"text/xml", "application/xml"}
+}
+
 func (xp *XMLParser) Parse(file io.Reader, filename string) (*ParsedContent, error) {
 	start := time.Now()
 
@@ -241,6 +403,10 @@ func (yp *YAMLParser) SupportedTypes() []string {
 	return []string{".yaml", ".yml"}
 }
 
+func (yp *YAMLParser) ContentTypes() []string {
+	return []string{"application/yaml"}
+}
+
 func (yp *YAMLParser) Parse(file io.Reader, filename string) (*ParsedContent, error) {
 	start := time.Now()
 
@@ -263,14 +429,16 @@ func (yp *YAMLParser) Parse(file io.Reader, filename string) (*ParsedContent, er
 
 // DocumentParserRegistry 文档解析器注册表
 type DocumentParserRegistry struct {
-	parsers map[string]DocumentParser
-	mu      sync.RWMutex
+	parsers        map[string]DocumentParser
+	contentParsers map[string]DocumentParser // 按 MIME 类型索引
+	mu             sync.RWMutex
 }
 
 // NewDocumentParserRegistry 创建解析器注册表
 func NewDocumentParserRegistry() *DocumentParserRegistry {
 	registry := &DocumentParserRegistry{
-		parsers: make(map[string]DocumentParser),
+		parsers:        make(map[string]DocumentParser),
+		contentParsers: make(map[string]DocumentParser),
 	}
 
 	// 注册默认解析器
@@ -280,6 +448,9 @@ func NewDocumentParserRegistry() *DocumentParserRegistry {
 	registry.Register(&HTMLParser{})
 	registry.Register(&XMLParser{})
 	registry.Register(&YAMLParser{})
+	registry.Register(&MarkdownParser{})
+	registry.Register(&PDFParser{})
+	registry.Register(&DOCXParser{})
 
 	return registry
 }
@@ -292,6 +463,86 @@ func (dpr *DocumentParserRegistry) Register(parser DocumentParser) {
 	for _, ext := range parser.SupportedTypes() {
 		dpr.parsers[ext] = parser
 	}
+	for _, contentType := range parser.ContentTypes() {
+		dpr.contentParsers[contentType] = parser
+	}
+}
+
+// ParseBytes 按检测到的内容类型选择解析器解析文件
+//
+// 与 Parse 不同，解析器由文件内容决定，扩展名只用于区分内容检测无法区分的文本格式。
+// 解析结果至少包含一个章节；没有可提取的文本时返回 ErrNoTextContent。
+func (dpr *DocumentParserRegistry) ParseBytes(data []byte, filename string) (*ParsedContent, error) {
+	contentType := DetectContentType(data, filename)
+
+	dpr.mu.RLock()
+	parser, exists := dpr.contentParsers[contentType]
+	dpr.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDocument, contentType)
+	}
+
+	parsed, err := parser.Parse(bytes.NewReader(data), filename)
+	if err != nil {
+		return nil, err
+	}
+	parsed.ContentType = contentType
+
+	if strings.TrimSpace(parsed.Content) == "" {
+		return nil, ErrNoTextContent
+	}
+	if len(parsed.Sections) == 0 {
+		parsed.Sections = []Section{{Text: parsed.Content}}
+	}
+	return parsed, nil
+}
+
+// textContentTypes 内容检测为纯文本时按扩展名细分的类型
+var textContentTypes = map[string]string{
+	".md":       "text/markdown",
+	".markdown": "text/markdown",
+	".csv":      "text/csv",
+	".json":     "application/json",
+	".yaml":     "application/yaml",
+	".yml":      "application/yaml",
+	".xml":      "text/xml",
+	".html":     "text/html",
+	".htm":      "text/html",
+}
+
+// DetectContentType 根据文件内容判断 MIME 类型
+//
+// PDF 与 HTML 由内容识别；ZIP 中包含 word/document.xml 时视为 DOCX；
+// 纯文本再按扩展名细分为 Markdown、CSV 等。
+func DetectContentType(data []byte, filename string) string {
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+
+	switch contentType {
+	case "application/zip":
+		if isDOCX(data) {
+			return DOCXContentType
+		}
+	case "text/plain":
+		if byExt, ok := textContentTypes[strings.ToLower(filepath.Ext(filename))]; ok {
+			return byExt
+		}
+	}
+	return contentType
+}
+
+// isDOCX 检查 ZIP 包是否为 Word 文档
+func isDOCX(data []byte) bool {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return false
+	}
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			return true
+		}
+	}
+	return false
 }
 
 // Parse 解析文件
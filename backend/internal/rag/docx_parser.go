@@ -0,0 +1,180 @@
+package rag
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DOCXParser Word 文档（.docx）解析器
+//
+// 读取 word/document.xml 中的段落文本，标题样式（Title、Heading1-9）
+// 或大纲级别的段落开始新章节。
+type DOCXParser struct{}
+
+func (dp *DOCXParser) Name() string {
+	return "DOCXParser"
+}
+
+func (dp *DOCXParser) SupportedTypes() []string {
+	return []string{".docx"}
+}
+
+func (dp *DOCXParser) ContentTypes() []string {
+	return []string{DOCXContentType}
+}
+
+// maxDOCXDocumentSize 解压后 document.xml 的最大大小，防止压缩炸弹
+const maxDOCXDocumentSize = 64 << 20
+
+func (dp *DOCXParser) Parse(file io.Reader, filename string) (*ParsedContent, error) {
+	start := time.Now()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid docx file: %w", err)
+	}
+
+	var document *zip.File
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			document = f
+			break
+		}
+	}
+	if document == nil {
+		return nil, fmt.Errorf("invalid docx file: word/document.xml not found")
+	}
+
+	rc, err := document.Open()
+	if err != nil {
+		return nil, fmt.Errorf("invalid docx file: %w", err)
+	}
+	defer rc.Close()
+
+	sections, paragraphs, err := dp.readParagraphs(io.LimitReader(rc, maxDOCXDocumentSize))
+	if err != nil {
+		return nil, fmt.Errorf("invalid docx file: %w", err)
+	}
+
+	texts := make([]string, len(sections))
+	for i, section := range sections {
+		texts[i] = section.Text
+	}
+	content := strings.Join(texts, "\n\n")
+
+	return &ParsedContent{
+		Title:         filename,
+		Content:       content,
+		Summary:       content[:min(len(content), 200)],
+		Metadata:      map[string]interface{}{"type": "docx", "paragraphs": paragraphs},
+		ParseDuration: time.Since(start),
+		Language:      "unknown",
+		Sections:      sections,
+	}, nil
+}
+
+// readParagraphs 读取段落并按标题划分章节，返回章节与段落数
+func (dp *DOCXParser) readParagraphs(r io.Reader) ([]Section, int, error) {
+	var (
+		sections   []Section
+		current    []string
+		headings   headingPath
+		paragraph  strings.Builder
+		level      int // 当前段落的标题级别，0 表示正文
+		inText     bool
+		paragraphs int
+	)
+	flush := func() {
+		if text := collapseBlankLines(strings.Join(current, "\n")); text != "" {
+			sections = append(sections, Section{Text: text, HeadingPath: headings.current()})
+		}
+		current = current[:0]
+	}
+
+	decoder := xml.NewDecoder(r)
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				paragraph.Reset()
+				level = 0
+			case "pStyle":
+				level = docxHeadingLevel(docxAttr(t, "val"))
+			case "outlineLvl":
+				if n, err := strconv.Atoi(docxAttr(t, "val")); err == nil && n < 9 && level == 0 {
+					level = n + 1
+				}
+			case "t":
+				inText = true
+			case "tab":
+				paragraph.WriteString("\t")
+			case "br", "cr":
+				paragraph.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				paragraphs++
+				text := strings.TrimSpace(paragraph.String())
+				if level > 0 && text != "" {
+					flush()
+					headings.push(level, text)
+				}
+				current = append(current, text)
+			}
+		case xml.CharData:
+			if inText {
+				paragraph.Write(t)
+			}
+		}
+	}
+	flush()
+
+	return sections, paragraphs, nil
+}
+
+// docxHeadingLevel 段落样式对应的标题级别，非标题样式返回 0
+func docxHeadingLevel(style string) int {
+	style = strings.ToLower(strings.ReplaceAll(style, " ", ""))
+	if style == "title" {
+		return 1
+	}
+	if rest, ok := strings.CutPrefix(style, "heading"); ok {
+		if n, err := strconv.Atoi(rest); err == nil && n >= 1 && n <= 9 {
+			return n
+		}
+	}
+	return 0
+}
+
+// docxAttr 读取元素属性（忽略命名空间前缀）
+func docxAttr(el xml.StartElement, name string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,137 @@
+package rag
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MarkdownParser Markdown 文件解析器，按标题切分章节
+type MarkdownParser struct{}
+
+func (mp *MarkdownParser) Name() string {
+	return "MarkdownParser"
+}
+
+func (mp *MarkdownParser) SupportedTypes() []string {
+	return []string{".md", ".markdown"}
+}
+
+func (mp *MarkdownParser) ContentTypes() []string {
+	return []string{"text/markdown"}
+}
+
+// markdownHeading ATX 标题行，如 "## 安装"
+var markdownHeading = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*$`)
+
+func (mp *MarkdownParser) Parse(file io.Reader, filename string) (*ParsedContent, error) {
+	start := time.Now()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	content := string(data)
+
+	return &ParsedContent{
+		Title:         filename,
+		Content:       content,
+		Summary:       content[:min(len(content), 200)],
+		Metadata:      map[string]interface{}{"type": "markdown"},
+		ParseDuration: time.Since(start),
+		Language:      "unknown",
+		Sections:      mp.split(content),
+	}, nil
+}
+
+// split 在每个标题处开始新章节，代码块中的 # 不视为标题
+func (mp *MarkdownParser) split(content string) []Section {
+	var (
+		sections []Section
+		current  []string
+		headings headingPath
+		fence    string
+	)
+	flush := func() {
+		if text := collapseBlankLines(strings.Join(current, "\n")); text != "" {
+			sections = append(sections, Section{Text: text, HeadingPath: headings.current()})
+		}
+		current = current[:0]
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			current = append(current, line)
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			current = append(current, line)
+			continue
+		}
+
+		if m := markdownHeading.FindStringSubmatch(line); m != nil {
+			flush()
+			headings.push(len(m[1]), strings.TrimSpace(m[2]))
+		}
+		current = append(current, line)
+	}
+	flush()
+
+	return sections
+}
+
+// headingPath 当前所在的标题层级
+type headingPath struct {
+	levels []int
+	titles []string
+}
+
+// push 进入新标题，弹出同级及更低层级的标题
+func (hp *headingPath) push(level int, title string) {
+	for len(hp.levels) > 0 && hp.levels[len(hp.levels)-1] >= level {
+		hp.levels = hp.levels[:len(hp.levels)-1]
+		hp.titles = hp.titles[:len(hp.titles)-1]
+	}
+	if title == "" {
+		return
+	}
+	hp.levels = append(hp.levels, level)
+	hp.titles = append(hp.titles, title)
+}
+
+// current 返回当前标题路径的副本
+func (hp *headingPath) current() []string {
+	if len(hp.titles) == 0 {
+		return nil
+	}
+	return append([]string(nil), hp.titles...)
+}
+
+// collapseBlankLines 去掉行尾空白并合并连续空行，保留缩进（代码块）
+func collapseBlankLines(text string) string {
+	var b strings.Builder
+	blank := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.TrimSpace(line) == "" {
+			blank = b.Len() > 0
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+			if blank {
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString(line)
+		blank = false
+	}
+	return b.String()
+}
@@ -0,0 +1,954 @@
+package rag
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// PDFParser PDF 文本提取
+//
+// 只使用标准库：读取文件中的全部对象定义（包括对象流中的压缩对象），按页面树顺序解码
+// 每页的内容流，提取文本绘制操作中的字符串。支持 FlateDecode 与字体的 ToUnicode 映射；
+// 扫描件等没有文本层的 PDF 提取结果为空。
+type PDFParser struct{}
+
+func (pp *PDFParser) Name() string {
+	return "PDFParser"
+}
+
+func (pp *PDFParser) SupportedTypes() []string {
+	return []string{".pdf"}
+}
+
+func (pp *PDFParser) ContentTypes() []string {
+	return []string{"application/pdf"}
+}
+
+// maxPDFStreamSize 单个流解压后的最大大小，防止压缩炸弹
+const maxPDFStreamSize = 64 << 20
+
+func (pp *PDFParser) Parse(file io.Reader, filename string) (*ParsedContent, error) {
+	start := time.Now()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF-")) {
+		return nil, errors.New("invalid pdf file: missing header")
+	}
+
+	doc := loadPDF(data)
+	if doc.encrypted {
+		return nil, errors.New("encrypted pdf files are not supported")
+	}
+
+	pages := doc.pages()
+	if len(pages) == 0 {
+		return nil, errors.New("invalid pdf file: no pages found")
+	}
+
+	sections := make([]Section, 0, len(pages))
+	texts := make([]string, 0, len(pages))
+	for i, page := range pages {
+		text := collapseBlankLines(doc.pageText(page))
+		if text == "" {
+			continue
+		}
+		sections = append(sections, Section{Text: text, Page: i + 1})
+		texts = append(texts, text)
+	}
+	content := strings.Join(texts, "\n\n")
+
+	return &ParsedContent{
+		Title:         filename,
+		Content:       content,
+		Summary:       content[:min(len(content), 200)],
+		Metadata:      map[string]interface{}{"type": "pdf"},
+		ParseDuration: time.Since(start),
+		Pages:         len(pages),
+		Language:      "unknown",
+		Sections:      sections,
+	}, nil
+}
+
+// PDF 对象类型：数字为 float64，字符串（字面量与十六进制）为原始字节组成的 string
+type (
+	pdfName    string
+	pdfKeyword string
+	pdfArray   []interface{}
+	pdfDict    map[string]interface{}
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		data []byte
+	}
+)
+
+// pdfDocument 已读取的对象表
+type pdfDocument struct {
+	objects   map[int]interface{}
+	trailer   pdfDict
+	encrypted bool
+	fonts     map[pdfRef]*pdfFont // 按字体对象缓存解码器
+}
+
+// pdfObjectHeader 间接对象定义的开头，如 "12 0 obj"
+var pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// pdfTrailer 传统交叉引用表之后的 trailer 字典
+var pdfTrailer = regexp.MustCompile(`trailer\s*<<`)
+
+// loadPDF 顺序扫描文件中的对象定义，后出现的定义覆盖先前的（增量更新）
+func loadPDF(data []byte) *pdfDocument {
+	doc := &pdfDocument{objects: make(map[int]interface{}), fonts: make(map[pdfRef]*pdfFont)}
+
+	next := 0
+	for _, m := range pdfObjectHeader.FindAllSubmatchIndex(data, -1) {
+		if m[0] < next {
+			continue // 位于上一个对象（通常是流数据）内部
+		}
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+
+		lex := &pdfLexer{data: data, pos: m[1], refs: true}
+		value, err := lex.value()
+		if err != nil {
+			continue
+		}
+		lex.skipSpace()
+		if dict, ok := value.(pdfDict); ok && bytes.HasPrefix(data[lex.pos:], []byte("stream")) {
+			stream, end := readPDFStream(data, lex.pos+len("stream"), dict)
+			value = stream
+			lex.pos = end
+		}
+		doc.objects[num] = value
+		next = lex.pos
+	}
+
+	for _, m := range pdfTrailer.FindAllIndex(data, -1) {
+		lex := &pdfLexer{data: data, pos: m[1] - 2, refs: true}
+		if dict, err := lex.value(); err == nil {
+			if d, ok := dict.(pdfDict); ok {
+				doc.trailer = d
+			}
+		}
+	}
+
+	// 对象流中的对象（PDF 1.5+），文件中直接定义的对象优先
+	for _, obj := range doc.objects {
+		stream, ok := obj.(*pdfStream)
+		if !ok || stream.dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		doc.loadObjectStream(stream)
+	}
+
+	for _, obj := range doc.objects {
+		stream, ok := obj.(*pdfStream)
+		if ok && stream.dict["Type"] == pdfName("XRef") && doc.trailer == nil {
+			doc.trailer = stream.dict
+		}
+	}
+	if doc.trailer != nil && doc.trailer["Encrypt"] != nil {
+		doc.encrypted = true
+	}
+	return doc
+}
+
+// readPDFStream 读取 stream 关键字之后的数据，Length 不是直接数值时查找 endstream
+func readPDFStream(data []byte, pos int, dict pdfDict) (*pdfStream, int) {
+	if bytes.HasPrefix(data[pos:], []byte("\r\n")) {
+		pos += 2
+	} else if pos < len(data) && (data[pos] == '\n' || data[pos] == '\r') {
+		pos++
+	}
+
+	if length, ok := dict["Length"].(float64); ok {
+		end := pos + int(length)
+		if length >= 0 && end <= len(data) {
+			rest := bytes.TrimLeft(data[end:], "\r\n \t")
+			if bytes.HasPrefix(rest, []byte("endstream")) {
+				return &pdfStream{dict: dict, data: data[pos:end]}, len(data) - len(rest) + len("endstream")
+			}
+		}
+	}
+
+	end := bytes.Index(data[pos:], []byte("endstream"))
+	if end < 0 {
+		return &pdfStream{dict: dict, data: data[pos:]}, len(data)
+	}
+	raw := bytes.TrimRight(data[pos:pos+end], "\r\n")
+	return &pdfStream{dict: dict, data: raw}, pos + end + len("endstream")
+}
+
+// loadObjectStream 读取对象流中压缩存放的对象
+func (d *pdfDocument) loadObjectStream(stream *pdfStream) {
+	data, err := d.decodeStream(stream)
+	if err != nil {
+		return
+	}
+	n, _ := d.resolve(stream.dict["N"]).(float64)
+	first, _ := d.resolve(stream.dict["First"]).(float64)
+	if int(first) > len(data) {
+		return
+	}
+
+	header := &pdfLexer{data: data[:int(first)]}
+	for i := 0; i < int(n); i++ {
+		num, err1 := header.value()
+		offset, err2 := header.value()
+		if err1 != nil || err2 != nil {
+			return
+		}
+		objNum, ok1 := num.(float64)
+		objOffset, ok2 := offset.(float64)
+		if !ok1 || !ok2 || int(first+objOffset) >= len(data) {
+			return
+		}
+		if _, exists := d.objects[int(objNum)]; exists {
+			continue
+		}
+		lex := &pdfLexer{data: data, pos: int(first + objOffset), refs: true}
+		if value, err := lex.value(); err == nil {
+			d.objects[int(objNum)] = value
+		}
+	}
+}
+
+// resolve 解引用间接对象
+func (d *pdfDocument) resolve(v interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = d.objects[ref.num]
+	}
+	return nil
+}
+
+// dict 解引用并返回字典（流返回其字典）
+func (d *pdfDocument) dict(v interface{}) pdfDict {
+	switch t := d.resolve(v).(type) {
+	case pdfDict:
+		return t
+	case *pdfStream:
+		return t.dict
+	}
+	return nil
+}
+
+// decodeStream 按 Filter 解码流数据，支持 FlateDecode
+func (d *pdfDocument) decodeStream(stream *pdfStream) ([]byte, error) {
+	var filters []interface{}
+	switch f := d.resolve(stream.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []interface{}{f}
+	case pdfArray:
+		filters = f
+	}
+
+	data := stream.data
+	for _, filter := range filters {
+		switch d.resolve(filter) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			decoded, err := inflatePDF(data)
+			if err != nil {
+				return nil, err
+			}
+			data = decoded
+		default:
+			return nil, fmt.Errorf("unsupported pdf filter: %v", filter)
+		}
+	}
+	return data, nil
+}
+
+// inflatePDF 解压 zlib 数据；部分生成器省略 zlib 头或校验和，按原始 deflate 重试
+func inflatePDF(data []byte) ([]byte, error) {
+	var r io.Reader
+	if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		r = zr
+	} else {
+		r = flate.NewReader(bytes.NewReader(data))
+	}
+
+	out, err := io.ReadAll(io.LimitReader(r, maxPDFStreamSize+1))
+	if len(out) > maxPDFStreamSize {
+		return nil, errors.New("pdf stream too large")
+	}
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+// pdfPage 页面对象及其（可能继承的）资源
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages 按页面树顺序返回页面；找不到目录时按对象编号收集 /Type /Page 对象
+func (d *pdfDocument) pages() []pdfPage {
+	var pages []pdfPage
+	visited := make(map[interface{}]bool)
+
+	var walk func(node interface{}, resources pdfDict, depth int)
+	walk = func(node interface{}, resources pdfDict, depth int) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref] {
+				return
+			}
+			visited[ref] = true
+		}
+		dict := d.dict(node)
+		if dict == nil || depth > 64 {
+			return
+		}
+		if r := d.dict(dict["Resources"]); r != nil {
+			resources = r
+		}
+
+		if kids, ok := d.resolve(dict["Kids"]).(pdfArray); ok && dict["Type"] != pdfName("Page") {
+			for _, kid := range kids {
+				walk(kid, resources, depth+1)
+			}
+			return
+		}
+		pages = append(pages, pdfPage{dict: dict, resources: resources})
+	}
+
+	var root pdfDict
+	if d.trailer != nil {
+		root = d.dict(d.trailer["Root"])
+	}
+	if root == nil {
+		for _, num := range d.objectNumbers() {
+			if dict, ok := d.objects[num].(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+				root = dict
+				break
+			}
+		}
+	}
+	if root != nil {
+		walk(root["Pages"], nil, 0)
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	for _, num := range d.objectNumbers() {
+		if dict, ok := d.objects[num].(pdfDict); ok && dict["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{dict: dict, resources: d.dict(dict["Resources"])})
+		}
+	}
+	return pages
+}
+
+// objectNumbers 按编号排序的对象编号
+func (d *pdfDocument) objectNumbers() []int {
+	nums := make([]int, 0, len(d.objects))
+	for num := range d.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// pageText 解码页面的全部内容流并提取文本
+func (d *pdfDocument) pageText(page pdfPage) string {
+	var contents []interface{}
+	switch c := d.resolve(page.dict["Contents"]).(type) {
+	case *pdfStream:
+		contents = []interface{}{c}
+	case pdfArray:
+		contents = c
+	}
+
+	var data []byte
+	for _, c := range contents {
+		stream, ok := d.resolve(c).(*pdfStream)
+		if !ok {
+			continue
+		}
+		decoded, err := d.decodeStream(stream)
+		if err != nil {
+			continue
+		}
+		data = append(data, decoded...)
+		data = append(data, '\n')
+	}
+
+	fonts := d.dict(page.resources["Font"])
+	return d.extractText(data, fonts)
+}
+
+// extractText 执行内容流中的文本操作符
+//
+// Tf 切换字体；Tj、TJ、'、" 输出字符串；换行由 T*、TD/Td 与 Tm 的纵向移动推断，
+// TJ 中较大的负偏移视为词间空格。
+func (d *pdfDocument) extractText(content []byte, fonts pdfDict) string {
+	var (
+		out      strings.Builder
+		operands []interface{}
+		font     *pdfFont
+		lastY    float64
+		hasY     bool
+	)
+	newline := func() {
+		if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+			out.WriteString("\n")
+		}
+	}
+	space := func() {
+		s := out.String()
+		if len(s) > 0 && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+			out.WriteString(" ")
+		}
+	}
+	show := func(v interface{}) {
+		if s, ok := v.(string); ok {
+			out.WriteString(font.decode(s))
+		}
+	}
+	number := func(i int) float64 {
+		if i < len(operands) {
+			f, _ := operands[i].(float64)
+			return f
+		}
+		return 0
+	}
+
+	lex := &pdfLexer{data: content}
+	for {
+		value, err := lex.value()
+		if err != nil {
+			break
+		}
+		op, ok := value.(pdfKeyword)
+		if !ok {
+			operands = append(operands, value)
+			continue
+		}
+
+		switch op {
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(pdfName); ok && fonts != nil {
+					font = d.font(fonts[string(name)])
+				}
+			}
+		case "Tj":
+			if len(operands) > 0 {
+				show(operands[len(operands)-1])
+			}
+		case "'", "\"":
+			newline()
+			if len(operands) > 0 {
+				show(operands[len(operands)-1])
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				arr, _ := operands[len(operands)-1].(pdfArray)
+				for _, item := range arr {
+					if offset, ok := item.(float64); ok {
+						if offset < -200 {
+							space()
+						}
+						continue
+					}
+					show(item)
+				}
+			}
+		case "T*":
+			newline()
+		case "Td", "TD":
+			if number(1) != 0 {
+				newline()
+			} else if number(0) > 0 {
+				space()
+			}
+		case "Tm":
+			y := number(5)
+			if hasY && y != lastY {
+				newline()
+			} else if hasY {
+				space()
+			}
+			lastY, hasY = y, true
+		case "BT":
+			hasY = false
+		case "ET":
+			space()
+		case "ID":
+			lex.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+
+	return out.String()
+}
+
+// pdfFont 字体的字符编码，codeBytes 为每个字符码的字节数
+type pdfFont struct {
+	codeBytes int
+	toUnicode map[uint32]string
+}
+
+// font 根据字体字典构建解码器：优先使用 ToUnicode，其次按 WinAnsi 处理单字节字体
+func (d *pdfDocument) font(ref interface{}) *pdfFont {
+	key, cacheable := ref.(pdfRef)
+	if font, ok := d.fonts[key]; ok && cacheable {
+		return font
+	}
+
+	font := &pdfFont{codeBytes: 1}
+	dict := d.dict(ref)
+	if dict != nil && dict["Subtype"] == pdfName("Type0") {
+		font.codeBytes = 2
+	}
+	if dict != nil {
+		if stream, ok := d.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+			if data, err := d.decodeStream(stream); err == nil {
+				font.toUnicode, font.codeBytes = parseToUnicode(data, font.codeBytes)
+			}
+		}
+	}
+
+	if cacheable {
+		d.fonts[key] = font
+	}
+	return font
+}
+
+// decode 将字符串中的字符码转换为文本
+func (f *pdfFont) decode(s string) string {
+	if f == nil {
+		return decodeWinAnsi(s)
+	}
+	if f.toUnicode == nil {
+		if f.codeBytes == 1 {
+			return decodeWinAnsi(s)
+		}
+		return "" // 没有 ToUnicode 的复合字体无法还原文本
+	}
+
+	var b strings.Builder
+	for i := 0; i+f.codeBytes <= len(s); i += f.codeBytes {
+		var code uint32
+		for j := 0; j < f.codeBytes; j++ {
+			code = code<<8 | uint32(s[i+j])
+		}
+		if text, ok := f.toUnicode[code]; ok {
+			b.WriteString(text)
+		} else if f.codeBytes == 1 {
+			b.WriteString(decodeWinAnsi(s[i : i+1]))
+		}
+	}
+	return b.String()
+}
+
+// winAnsiHigh WinAnsiEncoding 中 0x80-0x9F 与 Latin-1 不同的字符
+var winAnsiHigh = map[byte]rune{
+	0x80: '€', 0x85: '…', 0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—', 0x99: '™',
+}
+
+// decodeWinAnsi 按 WinAnsiEncoding（近似 Latin-1）解码单字节字符串
+func decodeWinAnsi(s string) string {
+	runes := make([]rune, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if r, ok := winAnsiHigh[c]; ok {
+			runes = append(runes, r)
+		} else if c >= 0x20 || c == '\t' {
+			runes = append(runes, rune(c))
+		}
+	}
+	return string(runes)
+}
+
+// parseToUnicode 解析 ToUnicode CMap 中的 codespacerange、bfchar 与 bfrange
+func parseToUnicode(data []byte, codeBytes int) (map[uint32]string, int) {
+	mapping := make(map[uint32]string)
+	lex := &pdfLexer{data: data}
+
+	var operands []interface{}
+	var section string
+	for {
+		value, err := lex.value()
+		if err != nil {
+			break
+		}
+		kw, ok := value.(pdfKeyword)
+		if !ok {
+			operands = append(operands, value)
+			continue
+		}
+
+		switch kw {
+		case "begincodespacerange", "beginbfchar", "beginbfrange":
+			section = string(kw)
+			operands = operands[:0]
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				if lo, ok := operands[0].(string); ok && len(lo) > 0 {
+					codeBytes = len(lo)
+				}
+			}
+			section = ""
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(string)
+				dst, ok2 := operands[i+1].(string)
+				if ok1 && ok2 {
+					mapping[pdfCode(src)] = decodeUTF16BE(dst)
+				}
+			}
+			section = ""
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(string)
+				hi, ok2 := operands[i+1].(string)
+				if !ok1 || !ok2 {
+					continue
+				}
+				start, end := pdfCode(lo), pdfCode(hi)
+				if end < start || end-start > 0xFFFF {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case string:
+					base := []rune(decodeUTF16BE(dst))
+					if len(base) == 0 {
+						continue
+					}
+					for code := start; code <= end; code++ {
+						r := append([]rune(nil), base...)
+						r[len(r)-1] += rune(code - start)
+						mapping[code] = string(r)
+					}
+				case pdfArray:
+					for j, item := range dst {
+						if s, ok := item.(string); ok && start+uint32(j) <= end {
+							mapping[start+uint32(j)] = decodeUTF16BE(s)
+						}
+					}
+				}
+			}
+			section = ""
+		default:
+			if section == "" {
+				operands = operands[:0]
+			}
+		}
+	}
+	return mapping, codeBytes
+}
+
+// pdfCode 将字符码字节转换为整数
+func pdfCode(s string) uint32 {
+	var code uint32
+	for i := 0; i < len(s); i++ {
+		code = code<<8 | uint32(s[i])
+	}
+	return code
+}
+
+// decodeUTF16BE 解码 ToUnicode 目标中的 UTF-16BE 文本
+func decodeUTF16BE(s string) string {
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// pdfLexer PDF 对象与内容流的词法解析
+type pdfLexer struct {
+	data []byte
+	pos  int
+	refs bool // 是否识别 "n g R" 形式的间接引用（内容流中不存在）
+}
+
+// errPDFEOF 数据已读完
+var errPDFEOF = errors.New("unexpected end of pdf data")
+
+// isPDFDelimiter 分隔符
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// isPDFSpace 空白字符
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+// skipSpace 跳过空白与注释
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFSpace(c) {
+			l.pos++
+			continue
+		}
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+// value 读取下一个值；操作符与其他关键字以 pdfKeyword 返回
+func (l *pdfLexer) value() (interface{}, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, errPDFEOF
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		return l.name(), nil
+	case c == '(':
+		return l.literalString()
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		return l.dictionary()
+	case c == '<':
+		return l.hexString()
+	case c == '[':
+		return l.array()
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+		return pdfKeyword(c), nil
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	token := string(l.data[start:l.pos])
+
+	if n, err := strconv.ParseFloat(token, 64); err == nil {
+		if l.refs && !strings.ContainsAny(token, ".+-") {
+			if ref, ok := l.reference(int(n)); ok {
+				return ref, nil
+			}
+		}
+		return n, nil
+	}
+
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfKeyword(token), nil
+}
+
+// reference 尝试读取 "num gen R"，失败时回退位置
+func (l *pdfLexer) reference(num int) (pdfRef, bool) {
+	saved := l.pos
+	l.skipSpace()
+	start := l.pos
+	for l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '9' {
+		l.pos++
+	}
+	if l.pos > start {
+		gen, _ := strconv.Atoi(string(l.data[start:l.pos]))
+		l.skipSpace()
+		if l.pos < len(l.data) && l.data[l.pos] == 'R' &&
+			(l.pos+1 == len(l.data) || isPDFSpace(l.data[l.pos+1]) || isPDFDelimiter(l.data[l.pos+1])) {
+			l.pos++
+			return pdfRef{num: num, gen: gen}, true
+		}
+	}
+	l.pos = saved
+	return pdfRef{}, false
+}
+
+// name 读取名称对象，解码 #xx 转义
+func (l *pdfLexer) name() pdfName {
+	l.pos++
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	raw := string(l.data[start:l.pos])
+	if !strings.Contains(raw, "#") {
+		return pdfName(raw)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && i+2 < len(raw) {
+			if decoded, err := hex.DecodeString(raw[i+1 : i+3]); err == nil {
+				b.Write(decoded)
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(raw[i])
+	}
+	return pdfName(b.String())
+}
+
+// literalString 读取 (...) 字符串，处理嵌套括号与转义
+func (l *pdfLexer) literalString() (string, error) {
+	l.pos++
+	var b []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return string(b), nil
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return string(b), nil
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				b = append(b, '\n')
+			case 'r':
+				b = append(b, '\r')
+			case 't':
+				b = append(b, '\t')
+			case 'b':
+				b = append(b, '\b')
+			case 'f':
+				b = append(b, '\f')
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					b = append(b, byte(v))
+				} else {
+					b = append(b, e)
+				}
+			}
+			continue
+		}
+		b = append(b, c)
+	}
+	return string(b), nil
+}
+
+// hexString 读取 <...> 十六进制字符串，奇数位补 0
+func (l *pdfLexer) hexString() (string, error) {
+	l.pos++
+	end := bytes.IndexByte(l.data[l.pos:], '>')
+	if end < 0 {
+		return "", errPDFEOF
+	}
+	digits := make([]byte, 0, end)
+	for _, c := range l.data[l.pos : l.pos+end] {
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	l.pos += end + 1
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	decoded, err := hex.DecodeString(string(digits))
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+// array 读取 [...] 数组
+func (l *pdfLexer) array() (pdfArray, error) {
+	l.pos++
+	var arr pdfArray
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			return arr, errPDFEOF
+		}
+		if l.data[l.pos] == ']' {
+			l.pos++
+			return arr, nil
+		}
+		v, err := l.value()
+		if err != nil {
+			return arr, err
+		}
+		arr = append(arr, v)
+	}
+}
+
+// dictionary 读取 <<...>> 字典
+func (l *pdfLexer) dictionary() (pdfDict, error) {
+	l.pos += 2
+	dict := make(pdfDict)
+	for {
+		l.skipSpace()
+		if l.pos+1 >= len(l.data) {
+			return dict, errPDFEOF
+		}
+		if l.data[l.pos] == '>' && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return dict, nil
+		}
+		key, err := l.value()
+		if err != nil {
+			return dict, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			continue
+		}
+		v, err := l.value()
+		if err != nil {
+			return dict, err
+		}
+		dict[string(name)] = v
+	}
+}
+
+// skipInlineImage 跳过内联图像 ID 与 EI 之间的二进制数据
+func (l *pdfLexer) skipInlineImage() {
+	for i := l.pos; i+2 < len(l.data); i++ {
+		if isPDFSpace(l.data[i]) && l.data[i+1] == 'E' && l.data[i+2] == 'I' &&
+			(i+3 == len(l.data) || isPDFSpace(l.data[i+3])) {
+			l.pos = i + 3
+			return
+		}
+	}
+	l.pos = len(l.data)
+}
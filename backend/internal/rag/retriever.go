@@ -9,6 +9,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode"
 )
 
 // SearchResult 搜索结果
@@ -63,9 +64,10 @@ func (bm25 *BM25) calculateIDF(term string, docCount, docFreq int) float32 {
 		return 0
 	}
 
-	numerator := float64(docCount - docFreq + 1)
-	denominator := float64(docFreq + 1)
-	idf := float32(math.Log(numerator / denominator))
+	// 使用 Lucene 的平滑形式，保证出现在大多数文档中的词 IDF 仍为正
+	numerator := float64(docCount-docFreq) + 0.5
+	denominator := float64(docFreq) + 0.5
+	idf := float32(math.Log(1 + numerator/denominator))
 	return idf
 }
 
@@ -81,6 +83,11 @@ type Retriever struct {
 	chunkStore map[string]*Chunk
 	chunkMu    sync.RWMutex
 
+	// BM25 倒排统计（受 chunkMu 保护）
+	chunkTerms map[string][]string
+	docFreq    map[string]int
+	totalTerms int
+
 	// BM25 算法
 	bm25 *BM25
 
@@ -103,6 +110,8 @@ func NewRetriever(vectorStore VectorStore, embeddingService *EmbeddingService) *
 		vectorStore:      vectorStore,
 		embeddingService: embeddingService,
 		chunkStore:       make(map[string]*Chunk),
+		chunkTerms:       make(map[string][]string),
+		docFreq:          make(map[string]int),
 		bm25:             NewBM25(),
 		logFunc:          defaultLogFuncRet,
 	}
@@ -125,7 +134,7 @@ func (r *Retriever) IndexChunk(ctx context.Context, chunk *Chunk) error {
 
 	// 保存块到本地存储
 	r.chunkMu.Lock()
-	r.chunkStore[chunk.ID] = chunk
+	r.storeChunkLocked(chunk)
 	r.chunkMu.Unlock()
 
 	atomic.AddInt64(&r.totalVectorOps, 1)
@@ -161,7 +170,7 @@ func (r *Retriever) IndexChunks(ctx context.Context, chunks []*Chunk) error {
 	// 保存块到本地存储
 	r.chunkMu.Lock()
 	for _, chunk := range chunks {
-		r.chunkStore[chunk.ID] = chunk
+		r.storeChunkLocked(chunk)
 	}
 	r.chunkMu.Unlock()
 
@@ -172,6 +181,52 @@ func (r *Retriever) IndexChunks(ctx context.Context, chunks []*Chunk) error {
 	return nil
 }
 
+// storeChunkLocked 保存块并更新 BM25 统计（调用方需持有 chunkMu 写锁）
+func (r *Retriever) storeChunkLocked(chunk *Chunk) {
+	r.removeChunkLocked(chunk.ID)
+
+	terms := tokenize(chunk.Content)
+	r.chunkStore[chunk.ID] = chunk
+	r.chunkTerms[chunk.ID] = terms
+	r.totalTerms += len(terms)
+
+	for term := range uniqueTerms(terms) {
+		r.docFreq[term]++
+	}
+
+	r.updateAvgDocLengthLocked()
+}
+
+// removeChunkLocked 删除块并回退 BM25 统计（调用方需持有 chunkMu 写锁）
+func (r *Retriever) removeChunkLocked(chunkID string) {
+	terms, exists := r.chunkTerms[chunkID]
+	if !exists {
+		delete(r.chunkStore, chunkID)
+		return
+	}
+
+	r.totalTerms -= len(terms)
+	for term := range uniqueTerms(terms) {
+		r.docFreq[term]--
+		if r.docFreq[term] <= 0 {
+			delete(r.docFreq, term)
+		}
+	}
+
+	delete(r.chunkStore, chunkID)
+	delete(r.chunkTerms, chunkID)
+	r.updateAvgDocLengthLocked()
+}
+
+// updateAvgDocLengthLocked 更新平均文档长度
+func (r *Retriever) updateAvgDocLengthLocked() {
+	if len(r.chunkTerms) == 0 {
+		r.bm25.avgDocLength = 0
+		return
+	}
+	r.bm25.avgDocLength = float32(r.totalTerms) / float32(len(r.chunkTerms))
+}
+
 // VectorSearch 向量搜索
 func (r *Retriever) VectorSearch(ctx context.Context, query string, topK int) ([]*SearchResult, error) {
 	start := time.Now()
@@ -225,7 +280,7 @@ func (r *Retriever) BM25Search(ctx context.Context, query string, topK int) ([]*
 	start := time.Now()
 
 	// 分词
-	queryTerms := strings.Fields(strings.ToLower(query))
+	queryTerms := tokenize(query)
 
 	var results []*SearchResult
 
@@ -243,7 +298,7 @@ func (r *Retriever) BM25Search(ctx context.Context, query string, topK int) ([]*
 	docCount := len(r.chunkStore)
 
 	for id, chunk := range r.chunkStore {
-		score := r.calculateBM25Score(chunk.Content, queryTerms, docCount)
+		score := r.calculateBM25Score(r.chunkTerms[id], queryTerms, docCount)
 
 		if score > 0 {
 			scores = append(scores, scoreItem{id, chunk, score})
@@ -276,12 +331,11 @@ func (r *Retriever) BM25Search(ctx context.Context, query string, topK int) ([]*
 	return results, nil
 }
 
-// calculateBM25Score 计算 BM25 分数
-func (r *Retriever) calculateBM25Score(doc string, queryTerms []string, docCount int) float32 {
-	docTerms := strings.Fields(strings.ToLower(doc))
+// calculateBM25Score 计算 BM25 分数（调用方需持有 chunkMu 读锁）
+func (r *Retriever) calculateBM25Score(docTerms []string, queryTerms []string, docCount int) float32 {
 	docLength := len(docTerms)
 
-	if docLength == 0 {
+	if docLength == 0 || r.bm25.avgDocLength == 0 {
 		return 0
 	}
 
@@ -300,17 +354,7 @@ func (r *Retriever) calculateBM25Score(doc string, queryTerms []string, docCount
 			continue
 		}
 
-		// 计算 IDF
-		docFreq := 0
-		r.chunkMu.RLock()
-		for _, chunk := range r.chunkStore {
-			if strings.Contains(strings.ToLower(chunk.Content), term) {
-				docFreq++
-			}
-		}
-		r.chunkMu.RUnlock()
-
-		idf := r.bm25.calculateIDF(term, docCount, docFreq)
+		idf := r.bm25.calculateIDF(term, docCount, r.docFreq[term])
 
 		// BM25 公式
 		numerator := float32(termFreq) * (r.bm25.K1 + 1)
@@ -322,6 +366,30 @@ func (r *Retriever) calculateBM25Score(doc string, queryTerms []string, docCount
 	return score
 }
 
+// tokenize 小写分词并去除首尾标点
+func tokenize(text string) []string {
+	fields := strings.Fields(strings.ToLower(text))
+	terms := make([]string, 0, len(fields))
+	for _, f := range fields {
+		f = strings.TrimFunc(f, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if f != "" {
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
+
+// uniqueTerms 去重
+func uniqueTerms(terms []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(terms))
+	for _, term := range terms {
+		set[term] = struct{}{}
+	}
+	return set
+}
+
 // HybridSearch 混合搜索（向量 + BM25）
 func (r *Retriever) HybridSearch(ctx context.Context, query string, topK int, vectorWeight float32) ([]*SearchResult, error) {
 	// 执行向量搜索
@@ -398,7 +466,7 @@ func (r *Retriever) DeleteChunk(ctx context.Context, chunkID string) error {
 
 	// 从本地存储删除
 	r.chunkMu.Lock()
-	delete(r.chunkStore, chunkID)
+	r.removeChunkLocked(chunkID)
 	r.chunkMu.Unlock()
 
 	return nil
@@ -111,6 +111,60 @@ func TestRetrieverVectorSearch(t *testing.T) {
 	}
 }
 
+func TestBM25CalculateIDFSmoothing(t *testing.T) {
+	bm25 := NewBM25()
+
+	if idf := bm25.calculateIDF("term", 10, 0); idf != 0 {
+		t.Errorf("Expected IDF 0 for unseen term, got %f", idf)
+	}
+
+	// 出现在所有文档中的词 IDF 仍应为正
+	if idf := bm25.calculateIDF("term", 2, 2); idf <= 0 {
+		t.Errorf("Expected positive IDF for common term, got %f", idf)
+	}
+
+	// 越少见的词 IDF 越高
+	if bm25.calculateIDF("rare", 10, 1) <= bm25.calculateIDF("common", 10, 5) {
+		t.Errorf("Expected rare term to have higher IDF")
+	}
+}
+
+func TestRetrieverBM25Ranking(t *testing.T) {
+	store := NewInMemoryVectorStore()
+	client := &MockEmbeddingClient{model: ModelAdaV2}
+	service := NewEmbeddingService(ModelAdaV2, client)
+	retriever := NewRetriever(store, service)
+
+	ctx := context.Background()
+
+	chunks := []*Chunk{
+		{ID: "chunk-1", Content: "Machine learning, machine vision."},
+		{ID: "chunk-2", Content: "Deep learning algorithms"},
+		{ID: "chunk-3", Content: "Machinery maintenance guide"},
+	}
+	retriever.IndexChunks(ctx, chunks)
+
+	results, err := retriever.BM25Search(ctx, "machine", 5)
+	if err != nil {
+		t.Fatalf("BM25Search failed: %v", err)
+	}
+
+	// "machinery" 不应按子串命中 "machine"，标点不影响分词
+	if len(results) != 1 || results[0].ChunkID != "chunk-1" {
+		t.Fatalf("Expected only chunk-1 to match, got %v", results)
+	}
+
+	// 删除后统计应同步回退
+	retriever.DeleteChunk(ctx, "chunk-1")
+	results, _ = retriever.BM25Search(ctx, "machine", 5)
+	if len(results) != 0 {
+		t.Errorf("Expected no results after delete, got %d", len(results))
+	}
+	if retriever.docFreq["machine"] != 0 {
+		t.Errorf("Expected docFreq to be cleared, got %d", retriever.docFreq["machine"])
+	}
+}
+
 func TestRetrieverBM25Search(t *testing.T) {
 	store := NewInMemoryVectorStore()
 	client := &MockEmbeddingClient{model: ModelAdaV2}
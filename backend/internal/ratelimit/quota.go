@@ -166,14 +166,10 @@ func (qm *QuotaManager) GetQuotaStatus(userID string) map[string]interface{} {
 	qm.mu.RLock()
 	defer qm.mu.RUnlock()
 
+	// 尚无使用记录的用户按零用量返回，保持与已有用户相同的结构
 	quota, exists := qm.quotas[userID]
 	if !exists {
-		return map[string]interface{}{
-			"daily":   map[string]int64{"used": 0, "quota": qm.dailyQuota},
-			"monthly": map[string]int64{"used": 0, "quota": qm.monthlyQuota},
-			"hourly":  map[string]int64{"used": 0, "quota": qm.hourlyQuota},
-			"locked":  false,
-		}
+		quota = &UserQuota{UserID: userID}
 	}
 
 	return map[string]interface{}{
@@ -0,0 +1,156 @@
+package relay
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/scaling"
+)
+
+// ErrAdmissionQueueFull 并发已满且该优先级的等待队列也已满
+var ErrAdmissionQueueFull = errors.New("relay admission queue is full")
+
+// admissionPriorities 等待队列的服务顺序：交互式请求优先于批量任务
+var admissionPriorities = []string{PriorityInteractive, PriorityBatch}
+
+// AdmissionLimiter 中转并发准入控制
+//
+// 同时执行的请求不超过 maxInFlight，超出的请求按优先级排队，每个优先级最多 maxQueue 个。
+// 释放名额时直接交给下一个等待者（交互式优先），执行数与各队列长度都在同一把锁内更新到
+// 扩缩容信号，因此导出的指标与实际状态一致。
+type AdmissionLimiter struct {
+	mu          sync.Mutex
+	maxInFlight int
+	maxQueue    int
+	running     int
+	waiters     map[string]*list.List
+
+	inFlight *scaling.Gauge
+	queued   map[string]*scaling.Gauge
+}
+
+// NewAdmissionLimiter 创建准入控制，maxInFlight 为 0 表示不限制并发（仍统计执行数）
+func NewAdmissionLimiter(maxInFlight, maxQueue int, reg *scaling.Registry) *AdmissionLimiter {
+	if reg == nil {
+		reg = scaling.NewRegistry()
+	}
+	if maxQueue < 0 {
+		maxQueue = 0
+	}
+
+	l := &AdmissionLimiter{
+		maxInFlight: maxInFlight,
+		maxQueue:    maxQueue,
+		waiters:     make(map[string]*list.List),
+		queued:      make(map[string]*scaling.Gauge),
+		inFlight: reg.NewGauge(scaling.MetricRelayInFlight,
+			"Relay requests currently being executed upstream.", nil, float64(maxInFlight)),
+	}
+	for _, priority := range admissionPriorities {
+		l.waiters[priority] = list.New()
+		l.queued[priority] = reg.NewGauge(scaling.MetricRelayAdmissionQueue,
+			"Relay requests waiting for an execution slot, by priority class.",
+			map[string]string{"priority": priority}, float64(maxQueue))
+	}
+	return l
+}
+
+// Acquire 获取执行名额，返回的 release 必须调用（可重复调用）
+//
+// 名额已满时排队等待，ctx 结束时放弃等待并返回 ctx 的错误；队列已满时立即返回 ErrAdmissionQueueFull。
+func (l *AdmissionLimiter) Acquire(ctx context.Context, priority string) (func(), error) {
+	priority = admissionPriority(priority)
+
+	l.mu.Lock()
+	if l.maxInFlight <= 0 || (l.running < l.maxInFlight && l.queueLen() == 0) {
+		l.running++
+		l.inFlight.Set(int64(l.running))
+		l.mu.Unlock()
+		return l.releaseFunc(), nil
+	}
+
+	queue := l.waiters[priority]
+	if queue.Len() >= l.maxQueue {
+		l.mu.Unlock()
+		return nil, ErrAdmissionQueueFull
+	}
+	ready := make(chan struct{})
+	elem := queue.PushBack(ready)
+	l.queued[priority].Set(int64(queue.Len()))
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return l.releaseFunc(), nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	select {
+	case <-ready:
+		// 放弃等待的同时已被分配名额，交还给下一个等待者
+		l.mu.Unlock()
+		l.releaseFunc()()
+	default:
+		queue.Remove(elem)
+		l.queued[priority].Set(int64(queue.Len()))
+		l.mu.Unlock()
+	}
+	return nil, ctx.Err()
+}
+
+// InFlight 正在执行的请求数
+func (l *AdmissionLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.running
+}
+
+// Queued 指定优先级正在等待的请求数
+func (l *AdmissionLimiter) Queued(priority string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.waiters[admissionPriority(priority)].Len()
+}
+
+// releaseFunc 释放名额：有等待者时直接交给优先级最高的等待者，执行数不变
+func (l *AdmissionLimiter) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			for _, priority := range admissionPriorities {
+				queue := l.waiters[priority]
+				if front := queue.Front(); front != nil {
+					queue.Remove(front)
+					l.queued[priority].Set(int64(queue.Len()))
+					close(front.Value.(chan struct{}))
+					return
+				}
+			}
+			l.running--
+			l.inFlight.Set(int64(l.running))
+		})
+	}
+}
+
+// queueLen 所有优先级等待的请求总数，调用方需持有锁
+func (l *AdmissionLimiter) queueLen() int {
+	n := 0
+	for _, queue := range l.waiters {
+		n += queue.Len()
+	}
+	return n
+}
+
+// admissionPriority 未指定或未知的优先级按交互式处理
+func admissionPriority(priority string) string {
+	if priority == PriorityBatch {
+		return PriorityBatch
+	}
+	return PriorityInteractive
+}
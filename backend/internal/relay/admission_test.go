@@ -0,0 +1,196 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/scaling"
+)
+
+// admissionGauges 从扩缩容信号中读取执行数与各优先级队列长度
+func admissionGauges(reg *scaling.Registry) (inFlight float64, queued map[string]float64) {
+	queued = make(map[string]float64)
+	for _, h := range reg.Hints() {
+		switch h.Name {
+		case scaling.MetricRelayInFlight:
+			inFlight = h.Value
+		case scaling.MetricRelayAdmissionQueue:
+			queued[h.Labels["priority"]] = h.Value
+		}
+	}
+	return inFlight, queued
+}
+
+// waitFor 等待条件成立（排队的 goroutine 需要时间进入队列）
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAdmissionLimiterPriorityAndGauges(t *testing.T) {
+	reg := scaling.NewRegistry()
+	l := NewAdmissionLimiter(2, 1, reg)
+	ctx := context.Background()
+
+	r1, _ := l.Acquire(ctx, "")
+	r2, _ := l.Acquire(ctx, PriorityBatch)
+
+	// 名额已满：先排一个批量任务，再排一个交互式请求
+	order := make(chan string, 2)
+	var wg sync.WaitGroup
+	for _, priority := range []string{PriorityBatch, PriorityInteractive} {
+		priority := priority
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.Acquire(ctx, priority)
+			if err != nil {
+				t.Errorf("Acquire(%s) failed: %v", priority, err)
+				return
+			}
+			order <- priority
+			release()
+		}()
+		waitFor(t, func() bool { return l.Queued(priority) == 1 })
+	}
+
+	inFlight, queued := admissionGauges(reg)
+	if inFlight != 2 || queued[PriorityInteractive] != 1 || queued[PriorityBatch] != 1 {
+		t.Fatalf("unexpected gauges in_flight=%v queued=%v", inFlight, queued)
+	}
+
+	// 该优先级队列已满
+	if _, err := l.Acquire(ctx, PriorityBatch); err == nil {
+		t.Fatal("expected batch queue to be full")
+	}
+	// 释放一个名额：交互式请求先于更早排队的批量任务获得名额
+	r1()
+	r1() // 重复释放无效
+	if first := <-order; first != PriorityInteractive {
+		t.Errorf("expected interactive to be admitted first, got %s", first)
+	}
+	r2()
+	wg.Wait()
+
+	inFlight, queued = admissionGauges(reg)
+	if inFlight != 0 || queued[PriorityInteractive] != 0 || queued[PriorityBatch] != 0 {
+		t.Errorf("expected all gauges to drain, got in_flight=%v queued=%v", inFlight, queued)
+	}
+}
+
+func TestAdmissionLimiterCancelledWait(t *testing.T) {
+	reg := scaling.NewRegistry()
+	l := NewAdmissionLimiter(1, 5, reg)
+	release, err := l.Acquire(context.Background(), PriorityInteractive)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		waitFor(t, func() bool { return l.Queued(PriorityInteractive) == 1 })
+		cancel()
+	}()
+	if _, err := l.Acquire(ctx, PriorityInteractive); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled wait, got %v", err)
+	}
+
+	// 放弃等待的请求离开队列，不占用名额
+	inFlight, queued := admissionGauges(reg)
+	if inFlight != 1 || queued[PriorityInteractive] != 0 {
+		t.Errorf("unexpected gauges in_flight=%v queued=%v", inFlight, queued)
+	}
+	release()
+	if l.InFlight() != 0 {
+		t.Errorf("expected no request in flight, got %d", l.InFlight())
+	}
+}
+
+func TestAdmissionLimiterGaugesUnderLoad(t *testing.T) {
+	const (
+		maxInFlight = 8
+		workers     = 200
+		// 采样时两次读取之间可能有请求完成，允许信号值比实际执行数少这么多
+		tolerance = 2
+	)
+	reg := scaling.NewRegistry()
+	l := NewAdmissionLimiter(maxInFlight, workers, reg)
+
+	// running 为调用方自己统计的执行数，在拿到名额之后加、释放之前减
+	var running, peak int64
+	stop := make(chan struct{})
+	samplerDone := make(chan struct{})
+	var violations int64
+	go func() {
+		defer close(samplerDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			inFlight, queued := admissionGauges(reg)
+			actual := float64(atomic.LoadInt64(&running))
+			if inFlight > maxInFlight || inFlight+tolerance < actual || queued[PriorityInteractive]+queued[PriorityBatch] > workers {
+				atomic.AddInt64(&violations, 1)
+			}
+			time.Sleep(50 * time.Microsecond)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		priority := PriorityInteractive
+		if i%3 == 0 {
+			priority = PriorityBatch
+		}
+		wg.Add(1)
+		go func(d time.Duration) {
+			defer wg.Done()
+			release, err := l.Acquire(context.Background(), priority)
+			if err != nil {
+				t.Errorf("Acquire failed: %v", err)
+				return
+			}
+			n := atomic.AddInt64(&running, 1)
+			for {
+				p := atomic.LoadInt64(&peak)
+				if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(d)
+			atomic.AddInt64(&running, -1)
+			release()
+		}(time.Duration(rand.Intn(2000)) * time.Microsecond)
+	}
+
+	// 负载进行中执行数达到上限，采样期间信号始终不超过上限且不少于实际执行数
+	waitFor(t, func() bool { return l.InFlight() == maxInFlight })
+
+	wg.Wait()
+	close(stop)
+	<-samplerDone
+
+	if v := atomic.LoadInt64(&violations); v > 0 {
+		t.Errorf("gauges disagreed with the limiter state in %d samples", v)
+	}
+	if peak > maxInFlight {
+		t.Errorf("observed %d concurrent requests, limit is %d", peak, maxInFlight)
+	}
+	inFlight, queued := admissionGauges(reg)
+	if inFlight != 0 || queued[PriorityInteractive] != 0 || queued[PriorityBatch] != 0 {
+		t.Errorf("expected gauges to return to zero, got in_flight=%v queued=%v", inFlight, queued)
+	}
+}
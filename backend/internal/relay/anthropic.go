@@ -0,0 +1,441 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrUnsupportedContent 请求包含只有 Claude 渠道能处理的内容块（图片、工具调用等）
+var ErrUnsupportedContent = errors.New("unsupported content block")
+
+// AnthropicContentBlock Anthropic 内容块
+//
+// 只解析 type 与 text，同时保留原始 JSON，原生透传时图片、工具调用等内容块不会丢失字段。
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+
+	raw json.RawMessage
+}
+
+// UnmarshalJSON 解析内容块并保留原始 JSON
+func (b *AnthropicContentBlock) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	b.Type, b.Text = fields.Type, fields.Text
+	b.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalJSON 解析得到的内容块原样输出，构造的内容块只输出 type 与 text
+func (b AnthropicContentBlock) MarshalJSON() ([]byte, error) {
+	if b.raw != nil {
+		return b.raw, nil
+	}
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}{b.Type, b.Text})
+}
+
+// AnthropicContent 消息内容，请求中可以是字符串或内容块数组
+type AnthropicContent []AnthropicContentBlock
+
+// UnmarshalJSON 字符串按单个文本块处理
+func (c *AnthropicContent) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = AnthropicContent{{Type: "text", Text: text}}
+		return nil
+	}
+	var blocks []AnthropicContentBlock
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return fmt.Errorf("content must be a string or an array of content blocks")
+	}
+	*c = blocks
+	return nil
+}
+
+// Text 按顺序拼接文本块，忽略其它内容块
+func (c AnthropicContent) Text() string {
+	var sb strings.Builder
+	for _, block := range c {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	return sb.String()
+}
+
+// AnthropicMessage Anthropic 对话消息，role 只能是 user 或 assistant
+type AnthropicMessage struct {
+	Role    string           `json:"role"`
+	Content AnthropicContent `json:"content"`
+}
+
+// MessagesRequest Anthropic Messages API 请求（POST /v1/messages）
+type MessagesRequest struct {
+	Model         string                 `json:"model"`
+	System        AnthropicContent       `json:"system,omitempty"`
+	Messages      []AnthropicMessage     `json:"messages"`
+	MaxTokens     int                    `json:"max_tokens"`
+	Temperature   *float64               `json:"temperature,omitempty"`
+	TopP          *float64               `json:"top_p,omitempty"`
+	TopK          *int                   `json:"top_k,omitempty"`
+	StopSequences []string               `json:"stop_sequences,omitempty"`
+	Stream        bool                   `json:"stream,omitempty"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+
+	// raw 客户端原始请求体，原生透传时使用，保留 tools 等未建模的字段
+	raw json.RawMessage
+}
+
+// UnmarshalJSON 解析请求并保留原始请求体
+func (r *MessagesRequest) UnmarshalJSON(data []byte) error {
+	type plain MessagesRequest
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*r = MessagesRequest(p)
+	r.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// Validate 校验必填参数
+func (r *MessagesRequest) Validate() error {
+	if r.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if r.MaxTokens <= 0 {
+		return fmt.Errorf("max_tokens is required and must be positive")
+	}
+	if len(r.Messages) == 0 {
+		return fmt.Errorf("messages must not be empty")
+	}
+	for i, m := range r.Messages {
+		if m.Role != "user" && m.Role != "assistant" {
+			return fmt.Errorf("messages.%d.role must be user or assistant", i)
+		}
+		if len(m.Content) == 0 {
+			return fmt.Errorf("messages.%d.content must not be empty", i)
+		}
+	}
+	return nil
+}
+
+// NativeBody 原生透传到 Claude 渠道的请求体，优先使用客户端原始请求体
+func (r *MessagesRequest) NativeBody() ([]byte, error) {
+	if r.raw != nil {
+		return r.raw, nil
+	}
+	return json.Marshal(r)
+}
+
+// TextOnly 检查请求是否只包含文本内容块，只有这样的请求才能转换为 OpenAI 格式
+func (r *MessagesRequest) TextOnly() error {
+	check := func(content AnthropicContent) error {
+		for _, block := range content {
+			if block.Type != "text" {
+				return fmt.Errorf("%w: %s blocks require a Claude channel", ErrUnsupportedContent, block.Type)
+			}
+		}
+		return nil
+	}
+	if err := check(r.System); err != nil {
+		return err
+	}
+	for _, m := range r.Messages {
+		if err := check(m.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChatRequest 转换为 OpenAI 格式请求：system 作为首条 system 消息，文本内容块按顺序拼接
+//
+// 非文本内容块与 top_k、stop_sequences 没有对应参数，转换时丢弃。
+func (r *MessagesRequest) ChatRequest() *ChatCompletionRequest {
+	req := &ChatCompletionRequest{
+		Model:     r.Model,
+		MaxTokens: r.MaxTokens,
+		Stream:    r.Stream,
+		Messages:  make([]ChatMessage, 0, len(r.Messages)+1),
+	}
+	if r.Temperature != nil {
+		req.Temperature = *r.Temperature
+	}
+	if r.TopP != nil {
+		req.TopP = *r.TopP
+	}
+	if system := r.System.Text(); system != "" {
+		req.Messages = append(req.Messages, ChatMessage{Role: "system", Content: system})
+	}
+	for _, m := range r.Messages {
+		req.Messages = append(req.Messages, ChatMessage{Role: m.Role, Content: m.Content.Text()})
+	}
+	return req
+}
+
+// MessagesUsage Anthropic 格式的 Token 用量
+type MessagesUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// ChatUsage 转换为统一的用量，用于日志与计费
+func (u MessagesUsage) ChatUsage() *ChatUsage {
+	return &ChatUsage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+// MessagesResponse Anthropic Messages API 响应
+type MessagesResponse struct {
+	ID           string           `json:"id"`
+	Type         string           `json:"type"`
+	Role         string           `json:"role"`
+	Model        string           `json:"model"`
+	Content      AnthropicContent `json:"content"`
+	StopReason   *string          `json:"stop_reason"` // 流式 message_start 中为 null
+	StopSequence *string          `json:"stop_sequence"`
+	Usage        MessagesUsage    `json:"usage"`
+}
+
+// stopReasons OpenAI finish_reason 到 Anthropic stop_reason 的映射
+var stopReasons = map[string]string{
+	"stop":           "end_turn",
+	"length":         "max_tokens",
+	"tool_calls":     "tool_use",
+	"function_call":  "tool_use",
+	"content_filter": "refusal",
+}
+
+// StopReason 将 OpenAI 的 finish_reason 转换为 Anthropic 的 stop_reason
+func StopReason(finishReason string) string {
+	if reason, ok := stopReasons[finishReason]; ok {
+		return reason
+	}
+	return "end_turn"
+}
+
+// MessagesResponseFromChat 将 OpenAI 格式响应转换为 Anthropic 响应
+func MessagesResponseFromChat(resp *ChatCompletionResponse) *MessagesResponse {
+	out := &MessagesResponse{
+		ID:      resp.ID,
+		Type:    "message",
+		Role:    "assistant",
+		Model:   resp.Model,
+		Content: AnthropicContent{},
+		Usage: MessagesUsage{
+			InputTokens:  resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.CompletionTokens,
+		},
+	}
+	stopReason := ""
+	if len(resp.Choices) > 0 {
+		choice := resp.Choices[0]
+		if choice.Message.Content != "" {
+			out.Content = AnthropicContent{{Type: "text", Text: choice.Message.Content}}
+		}
+		stopReason = choice.FinishReason
+	}
+	reason := StopReason(stopReason)
+	out.StopReason = &reason
+	return out
+}
+
+// MessagesStreamEvent Anthropic 流式事件，Type 为 SSE 事件名，Data 序列化为 SSE 的 data
+type MessagesStreamEvent struct {
+	Type string
+	Data interface{}
+}
+
+// ObserveMessagesEvent 从 Claude 原生流式事件中读取用量，返回事件携带的增量文本
+func ObserveMessagesEvent(eventType string, data []byte, usage *MessagesUsage) string {
+	var ev struct {
+		Message *struct {
+			Usage MessagesUsage `json:"usage"`
+		} `json:"message"`
+		Delta *struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"delta"`
+		Usage *MessagesUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return ""
+	}
+
+	switch eventType {
+	case "message_start":
+		if ev.Message != nil {
+			*usage = ev.Message.Usage
+		}
+	case "message_delta":
+		// message_delta 中的用量是累计值，input_tokens 可能缺省
+		if ev.Usage != nil {
+			usage.OutputTokens = ev.Usage.OutputTokens
+			if ev.Usage.InputTokens > 0 {
+				usage.InputTokens = ev.Usage.InputTokens
+			}
+		}
+	case "content_block_delta":
+		if ev.Delta != nil && ev.Delta.Type == "text_delta" {
+			return ev.Delta.Text
+		}
+	}
+	return ""
+}
+
+// MessagesStreamEncoder 将 OpenAI 流式数据块转换为 Anthropic 流式事件
+//
+// 事件顺序：message_start、content_block_start、若干 content_block_delta、
+// content_block_stop、message_delta（stop_reason 与用量）、message_stop。
+type MessagesStreamEncoder struct {
+	model       string
+	inputTokens int
+	id          string
+	started     bool
+	stopReason  string
+	usage       *ChatUsage
+}
+
+// NewMessagesStreamEncoder 创建流式事件转换器，inputTokens 为 message_start 中报告的输入 Token 估算值
+func NewMessagesStreamEncoder(model string, inputTokens int) *MessagesStreamEncoder {
+	return &MessagesStreamEncoder{model: model, inputTokens: inputTokens}
+}
+
+// Encode 转换一个数据块，首个数据块前补发 message_start 与 content_block_start
+func (e *MessagesStreamEncoder) Encode(chunk *ChatCompletionResponse) []*MessagesStreamEvent {
+	var events []*MessagesStreamEvent
+	if !e.started {
+		e.id = chunk.ID
+		events = e.start()
+	}
+	if chunk.Usage.TotalTokens > 0 {
+		usage := chunk.Usage
+		e.usage = &usage
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Delta != nil && choice.Delta.Content != "" {
+			events = append(events, &MessagesStreamEvent{Type: "content_block_delta", Data: map[string]interface{}{
+				"type":  "content_block_delta",
+				"index": 0,
+				"delta": map[string]interface{}{"type": "text_delta", "text": choice.Delta.Content},
+			}})
+		}
+		if choice.FinishReason != "" {
+			e.stopReason = StopReason(choice.FinishReason)
+		}
+	}
+	return events
+}
+
+// Close 结束事件流，上游没有在流中报告用量时使用 fallback（可为 nil）
+func (e *MessagesStreamEncoder) Close(fallback *ChatUsage) []*MessagesStreamEvent {
+	var events []*MessagesStreamEvent
+	if !e.started {
+		events = e.start()
+	}
+
+	usage := e.usage
+	if usage == nil {
+		usage = fallback
+	}
+	out := MessagesUsage{InputTokens: e.inputTokens}
+	if usage != nil {
+		out = MessagesUsage{InputTokens: usage.PromptTokens, OutputTokens: usage.CompletionTokens}
+	}
+	stopReason := e.stopReason
+	if stopReason == "" {
+		stopReason = "end_turn"
+	}
+
+	return append(events,
+		&MessagesStreamEvent{Type: "content_block_stop", Data: map[string]interface{}{"type": "content_block_stop", "index": 0}},
+		&MessagesStreamEvent{Type: "message_delta", Data: map[string]interface{}{
+			"type":  "message_delta",
+			"delta": map[string]interface{}{"stop_reason": stopReason, "stop_sequence": nil},
+			"usage": out,
+		}},
+		&MessagesStreamEvent{Type: "message_stop", Data: map[string]interface{}{"type": "message_stop"}},
+	)
+}
+
+// start message_start 与唯一文本块的 content_block_start
+func (e *MessagesStreamEncoder) start() []*MessagesStreamEvent {
+	e.started = true
+	return []*MessagesStreamEvent{
+		{Type: "message_start", Data: map[string]interface{}{
+			"type": "message_start",
+			"message": &MessagesResponse{
+				ID:      e.id,
+				Type:    "message",
+				Role:    "assistant",
+				Model:   e.model,
+				Content: AnthropicContent{},
+				Usage:   MessagesUsage{InputTokens: e.inputTokens},
+			},
+		}},
+		{Type: "content_block_start", Data: map[string]interface{}{
+			"type":          "content_block_start",
+			"index":         0,
+			"content_block": AnthropicContentBlock{Type: "text"},
+		}},
+	}
+}
+
+// AnthropicError Anthropic 格式的错误响应，流式响应中作为 error 事件的内容
+type AnthropicError struct {
+	Type  string               `json:"type"` // 固定为 error
+	Error AnthropicErrorDetail `json:"error"`
+}
+
+// AnthropicErrorDetail 错误类型与描述，类型如 invalid_request_error、rate_limit_error
+type AnthropicErrorDetail struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// NewAnthropicError 创建 Anthropic 格式的错误响应
+func NewAnthropicError(errType, message string) *AnthropicError {
+	return &AnthropicError{Type: "error", Error: AnthropicErrorDetail{Type: errType, Message: message}}
+}
+
+// AnthropicErrorType 按 HTTP 状态码返回 Anthropic 错误类型
+func AnthropicErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusPaymentRequired:
+		return "billing_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case 529:
+		return "overloaded_error"
+	}
+	if status < http.StatusInternalServerError {
+		return "invalid_request_error"
+	}
+	return "api_error"
+}
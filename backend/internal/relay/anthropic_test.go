@@ -0,0 +1,185 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const messagesRequestJSON = `{
+	"model": "claude-3-5-sonnet",
+	"max_tokens": 256,
+	"system": [{"type": "text", "text": "You are terse."}],
+	"messages": [
+		{"role": "user", "content": "Hello"},
+		{"role": "assistant", "content": [{"type": "text", "text": "Hi."}]},
+		{"role": "user", "content": [{"type": "text", "text": "Weather?"}, {"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "iVBOR"}}]}
+	],
+	"temperature": 0.2,
+	"tools": [{"name": "get_weather", "input_schema": {"type": "object"}}],
+	"stream": true
+}`
+
+func TestMessagesRequestParse(t *testing.T) {
+	var req MessagesRequest
+	if err := json.Unmarshal([]byte(messagesRequestJSON), &req); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("Expected valid request, got %v", err)
+	}
+	if req.System.Text() != "You are terse." {
+		t.Errorf("Unexpected system %q", req.System.Text())
+	}
+	if len(req.Messages[0].Content) != 1 || req.Messages[0].Content[0].Text != "Hello" {
+		t.Errorf("Expected string content to become a text block, got %+v", req.Messages[0].Content)
+	}
+
+	// 原生透传保留未建模的字段与非文本内容块
+	body, err := req.NativeBody()
+	if err != nil {
+		t.Fatalf("NativeBody failed: %v", err)
+	}
+	if !strings.Contains(string(body), `"get_weather"`) {
+		t.Errorf("Expected tools to be kept, got %s", body)
+	}
+	image, _ := json.Marshal(req.Messages[2].Content[1])
+	if !strings.Contains(string(image), `"media_type":"image/png"`) {
+		t.Errorf("Expected image block to round-trip, got %s", image)
+	}
+
+	if err := req.TextOnly(); !errors.Is(err, ErrUnsupportedContent) {
+		t.Errorf("Expected ErrUnsupportedContent, got %v", err)
+	}
+}
+
+func TestMessagesRequestValidate(t *testing.T) {
+	cases := map[string]string{
+		"missing max_tokens": `{"model":"claude-3-5-sonnet","messages":[{"role":"user","content":"hi"}]}`,
+		"missing model":      `{"max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`,
+		"empty messages":     `{"model":"claude-3-5-sonnet","max_tokens":10,"messages":[]}`,
+		"system role":        `{"model":"claude-3-5-sonnet","max_tokens":10,"messages":[{"role":"system","content":"hi"}]}`,
+	}
+	for name, body := range cases {
+		var req MessagesRequest
+		if err := json.Unmarshal([]byte(body), &req); err != nil {
+			t.Fatalf("%s: unmarshal failed: %v", name, err)
+		}
+		if err := req.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+
+	var req MessagesRequest
+	if err := json.Unmarshal([]byte(`{"messages":[{"role":"user","content":42}]}`), &req); err == nil {
+		t.Error("Expected invalid content to be rejected")
+	}
+}
+
+func TestMessagesRequestChatRequest(t *testing.T) {
+	var req MessagesRequest
+	if err := json.Unmarshal([]byte(messagesRequestJSON), &req); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	chat := req.ChatRequest()
+	if chat.Model != "claude-3-5-sonnet" || chat.MaxTokens != 256 || chat.Temperature != 0.2 || !chat.Stream {
+		t.Errorf("Unexpected parameters %+v", chat)
+	}
+	want := []ChatMessage{
+		{Role: "system", Content: "You are terse."},
+		{Role: "user", Content: "Hello"},
+		{Role: "assistant", Content: "Hi."},
+		{Role: "user", Content: "Weather?"},
+	}
+	if len(chat.Messages) != len(want) {
+		t.Fatalf("Expected %d messages, got %+v", len(want), chat.Messages)
+	}
+	for i, m := range want {
+		if !reflect.DeepEqual(chat.Messages[i], m) {
+			t.Errorf("Message %d: expected %+v, got %+v", i, m, chat.Messages[i])
+		}
+	}
+}
+
+func TestMessagesResponseFromChat(t *testing.T) {
+	var resp ChatCompletionResponse
+	if err := json.Unmarshal([]byte(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Sunny."},"finish_reason":"length"}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`), &resp); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	out, _ := json.Marshal(MessagesResponseFromChat(&resp))
+	want := `{"id":"chatcmpl-1","type":"message","role":"assistant","model":"gpt-4o","content":[{"type":"text","text":"Sunny."}],"stop_reason":"max_tokens","stop_sequence":null,"usage":{"input_tokens":12,"output_tokens":3}}`
+	if string(out) != want {
+		t.Errorf("Unexpected response\n got: %s\nwant: %s", out, want)
+	}
+
+	for finish, reason := range map[string]string{"stop": "end_turn", "tool_calls": "tool_use", "": "end_turn"} {
+		if got := StopReason(finish); got != reason {
+			t.Errorf("StopReason(%q) = %q, want %q", finish, got, reason)
+		}
+	}
+}
+
+func TestMessagesStreamEncoder(t *testing.T) {
+	chunk := func(data string) *ChatCompletionResponse {
+		var c ChatCompletionResponse
+		if err := json.Unmarshal([]byte(data), &c); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		return &c
+	}
+
+	encoder := NewMessagesStreamEncoder("gpt-4o", 9)
+	var events []*MessagesStreamEvent
+	events = append(events, encoder.Encode(chunk(`{"id":"c1","choices":[{"index":0,"delta":{"role":"assistant"}}]}`))...)
+	events = append(events, encoder.Encode(chunk(`{"id":"c1","choices":[{"index":0,"delta":{"content":"Sun"}}]}`))...)
+	events = append(events, encoder.Encode(chunk(`{"id":"c1","choices":[{"index":0,"delta":{"content":"ny."},"finish_reason":"stop"}]}`))...)
+	events = append(events, encoder.Encode(chunk(`{"id":"c1","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":2,"total_tokens":14}}`))...)
+	events = append(events, encoder.Close(nil)...)
+
+	var types []string
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	want := "message_start content_block_start content_block_delta content_block_delta content_block_stop message_delta message_stop"
+	if strings.Join(types, " ") != want {
+		t.Fatalf("Unexpected event sequence %v", types)
+	}
+
+	start, _ := json.Marshal(events[0].Data)
+	if !strings.Contains(string(start), `"id":"c1"`) || !strings.Contains(string(start), `"stop_reason":null`) ||
+		!strings.Contains(string(start), `"input_tokens":9`) {
+		t.Errorf("Unexpected message_start %s", start)
+	}
+	delta, _ := json.Marshal(events[2].Data)
+	if string(delta) != `{"delta":{"text":"Sun","type":"text_delta"},"index":0,"type":"content_block_delta"}` {
+		t.Errorf("Unexpected content_block_delta %s", delta)
+	}
+	messageDelta, _ := json.Marshal(events[5].Data)
+	if string(messageDelta) != `{"delta":{"stop_reason":"end_turn","stop_sequence":null},"type":"message_delta","usage":{"input_tokens":12,"output_tokens":2}}` {
+		t.Errorf("Unexpected message_delta %s", messageDelta)
+	}
+
+	// 上游没有输出任何数据块时仍输出完整的事件序列
+	events = NewMessagesStreamEncoder("gpt-4o", 9).Close(&ChatUsage{PromptTokens: 9, CompletionTokens: 0})
+	if len(events) != 5 || events[0].Type != "message_start" || events[4].Type != "message_stop" {
+		t.Errorf("Unexpected empty stream events %+v", events)
+	}
+}
+
+func TestObserveMessagesEvent(t *testing.T) {
+	var usage MessagesUsage
+	ObserveMessagesEvent("message_start", []byte(`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":25,"output_tokens":1}}}`), &usage)
+	text := ObserveMessagesEvent("content_block_delta", []byte(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`), &usage)
+	ObserveMessagesEvent("message_delta", []byte(`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":15}}`), &usage)
+
+	if text != "Hello" {
+		t.Errorf("Expected delta text, got %q", text)
+	}
+	if usage.InputTokens != 25 || usage.OutputTokens != 15 {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+}
@@ -38,7 +38,9 @@ type BodyCache struct {
 
 	// 内存缓存（key=cacheID, value=[]byte）
 	memoryCache map[string][]byte
-	memoryCacheMu sync.RWMutex
+	// 内存缓存创建时间（key=cacheID）
+	memoryCacheTimes map[string]time.Time
+	memoryCacheMu    sync.RWMutex
 
 	// 磁盘缓存元数据（key=cacheID, value={filepath, size, hash}）
 	diskCacheMetadata map[string]*DiskCacheEntry
@@ -77,6 +79,7 @@ func NewBodyCache(diskCachePath string) *BodyCache {
 		memoryThreshold:    1024 * 1024,        // 默认 1MB
 		diskCachePath:      diskCachePath,
 		memoryCache:        make(map[string][]byte),
+		memoryCacheTimes:   make(map[string]time.Time),
 		diskCacheMetadata:  make(map[string]*DiskCacheEntry),
 		maxCacheSize:       10 * 1024 * 1024 * 1024, // 10GB
 		maxCacheDuration:   24 * time.Hour,
@@ -191,7 +194,10 @@ func (bc *BodyCache) InvalidateCache(cacheID string) error {
 	bc.memoryCacheMu.Lock()
 	if data, ok := bc.memoryCache[cacheID]; ok {
 		delete(bc.memoryCache, cacheID)
+		delete(bc.memoryCacheTimes, cacheID)
 		atomic.AddInt64(&bc.totalCacheSize, -int64(len(data)))
+		bc.memoryCacheMu.Unlock()
+		return nil
 	}
 	bc.memoryCacheMu.Unlock()
 
@@ -247,6 +253,7 @@ func (bc *BodyCache) cacheInMemory(cacheID string, data []byte) {
 	defer bc.memoryCacheMu.Unlock()
 
 	bc.memoryCache[cacheID] = data
+	bc.memoryCacheTimes[cacheID] = time.Now()
 	atomic.AddInt64(&bc.totalCacheSize, int64(len(data)))
 }
 
@@ -300,6 +307,18 @@ func (bc *BodyCache) cleanupRoutine() {
 func (bc *BodyCache) cleanup() {
 	now := time.Now()
 
+	// 清理过期的内存缓存
+	bc.memoryCacheMu.Lock()
+	for cacheID, createdAt := range bc.memoryCacheTimes {
+		if now.Sub(createdAt) > bc.maxCacheDuration {
+			atomic.AddInt64(&bc.totalCacheSize, -int64(len(bc.memoryCache[cacheID])))
+			atomic.AddInt64(&bc.cacheEvictions, 1)
+			delete(bc.memoryCache, cacheID)
+			delete(bc.memoryCacheTimes, cacheID)
+		}
+	}
+	bc.memoryCacheMu.Unlock()
+
 	// 清理过期的磁盘缓存
 	bc.diskCacheMu.Lock()
 	var toDelete []string
@@ -354,12 +373,12 @@ func (bc *BodyCache) evictOldestCaches() {
 	}
 	bc.diskCacheMu.RUnlock()
 
-	// 收集内存缓存（估计时间为现在）
+	// 收集内存缓存
 	bc.memoryCacheMu.RLock()
 	for cacheID, data := range bc.memoryCache {
 		items = append(items, cacheItem{
 			cacheID:   cacheID,
-			createdAt: time.Now(),
+			createdAt: bc.memoryCacheTimes[cacheID],
 			size:      int64(len(data)),
 		})
 	}
@@ -394,6 +413,7 @@ func (bc *BodyCache) PurgeAll() error {
 	bc.memoryCacheMu.Lock()
 	memCount := len(bc.memoryCache)
 	bc.memoryCache = make(map[string][]byte)
+	bc.memoryCacheTimes = make(map[string]time.Time)
 	bc.memoryCacheMu.Unlock()
 
 	// 清空磁盘缓存
@@ -3,6 +3,8 @@ package relay
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -100,6 +102,11 @@ func NewChannelAbilityManager() *ChannelAbilityManager {
 	}
 }
 
+// SetLogFunc 设置日志函数
+func (cam *ChannelAbilityManager) SetLogFunc(logFunc func(level, msg string, args ...interface{})) {
+	cam.logFunc = logFunc
+}
+
 // RegisterAbility 注册渠道能力
 func (cam *ChannelAbilityManager) RegisterAbility(channelID string, ability *ChannelAbilityVersion) error {
 	if channelID == "" || ability == nil {
@@ -224,7 +231,7 @@ func (cam *ChannelAbilityManager) SupportsModel(channelID, model, version string
 	}
 
 	for _, m := range ability.SupportedModels {
-		if m == model {
+		if matchPattern(model, m) {
 			return true, nil
 		}
 	}
@@ -262,7 +269,7 @@ func (cam *ChannelAbilityManager) GetFeatureConfig(channelID, version string, fe
 	return &config, nil
 }
 
-// FilterChannelsByModel 按模型过滤渠道
+// FilterChannelsByModel 按模型过滤渠道，支持的模型可以是通配符（如 "*"、"gpt-4*"）
 func (cam *ChannelAbilityManager) FilterChannelsByModel(model string) ([]string, error) {
 	cam.abilitiesMu.RLock()
 	defer cam.abilitiesMu.RUnlock()
@@ -286,7 +293,7 @@ func (cam *ChannelAbilityManager) FilterChannelsByModel(model string) ([]string,
 		}
 
 		for _, m := range ability.SupportedModels {
-			if m == model {
+			if matchPattern(model, m) {
 				result = append(result, channelID)
 				break
 			}
@@ -296,6 +303,40 @@ func (cam *ChannelAbilityManager) FilterChannelsByModel(model string) ([]string,
 	return result, nil
 }
 
+// ContextWindowLimitKey FeatureContextWindow 的 Limits 中上下文窗口 Token 数的键
+const ContextWindowLimitKey = "max_tokens"
+
+// ContextWindow 返回最新版本支持该模型、且声明了上下文窗口的渠道中最小的窗口（Token 数），
+// 任一渠道都可能被选中，所以取最小值；没有渠道声明时返回 0
+func (cam *ChannelAbilityManager) ContextWindow(model string) int {
+	channelIDs, _ := cam.FilterChannelsByModel(model)
+
+	window := 0
+	for _, channelID := range channelIDs {
+		ability, err := cam.GetLatestAbility(channelID)
+		if err != nil {
+			continue
+		}
+		feature, ok := ability.Features[FeatureContextWindow]
+		if !ok || !feature.Supported {
+			continue
+		}
+		limit := 0
+		switch v := feature.Limits[ContextWindowLimitKey].(type) {
+		case int:
+			limit = v
+		case int64:
+			limit = int(v)
+		case float64:
+			limit = int(v)
+		}
+		if limit > 0 && (window == 0 || limit < window) {
+			window = limit
+		}
+	}
+	return window
+}
+
 // FilterChannelsByFeature 按功能过滤渠道
 func (cam *ChannelAbilityManager) FilterChannelsByFeature(feature ChannelAbilityFeature) ([]string, error) {
 	cam.abilitiesMu.RLock()
@@ -513,6 +554,67 @@ func (cav *ChannelAbilityValidator) GetCompatibleChannels(model string, features
 	return compatible, nil
 }
 
+// FeatureGaps 最新版本支持该模型、但缺少 features 中任一必需功能的渠道及其缺少的功能，按渠道 ID 排序
+func (cav *ChannelAbilityValidator) FeatureGaps(model string, features map[ChannelAbilityFeature]bool) []ChannelFeatureGap {
+	channels, _ := cav.manager.FilterChannelsByModel(model)
+
+	var gaps []ChannelFeatureGap
+	for _, channelID := range channels {
+		var missing []ChannelAbilityFeature
+		for feature, required := range features {
+			if !required {
+				continue
+			}
+			if supported, err := cav.manager.SupportsFeature(channelID, "", feature); err != nil || !supported {
+				missing = append(missing, feature)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+			gaps = append(gaps, ChannelFeatureGap{ChannelID: channelID, Missing: missing})
+		}
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i].ChannelID < gaps[j].ChannelID })
+	return gaps
+}
+
+// ChannelFeatureGap 渠道缺少的功能
+type ChannelFeatureGap struct {
+	ChannelID string
+	Missing   []ChannelAbilityFeature
+}
+
+// FeatureUnsupportedError 提供该模型的渠道都不支持请求依赖的功能（如工具调用）
+//
+// 匹配 ErrModelNotSupported，因此同样会触发模型降级规则。
+type FeatureUnsupportedError struct {
+	Model    string
+	Features []ChannelAbilityFeature // 请求依赖的功能
+	Channels []ChannelFeatureGap     // 提供该模型的渠道各自缺少的功能
+}
+
+// Error 实现 error 接口，列出每个渠道缺少的功能
+func (e *FeatureUnsupportedError) Error() string {
+	gaps := make([]string, len(e.Channels))
+	for i, gap := range e.Channels {
+		missing := make([]string, len(gap.Missing))
+		for j, feature := range gap.Missing {
+			missing[j] = string(feature)
+		}
+		gaps[i] = fmt.Sprintf("channel %s lacks %s", gap.ChannelID, strings.Join(missing, ", "))
+	}
+	required := make([]string, len(e.Features))
+	for i, feature := range e.Features {
+		required[i] = string(feature)
+	}
+	return fmt.Sprintf("no channel for model %s supports %s (%s)", e.Model, strings.Join(required, ", "), strings.Join(gaps, "; "))
+}
+
+// Unwrap 返回 ErrModelNotSupported
+func (e *FeatureUnsupportedError) Unwrap() error {
+	return ErrModelNotSupported
+}
+
 // ChannelAbilityConfig 渠道能力配置助手
 type ChannelAbilityConfig struct {
 	// 渠道 ID
@@ -354,3 +354,29 @@ func TestChannelAbilityConfig(t *testing.T) {
 	}
 }
 
+func TestContextWindow(t *testing.T) {
+	manager := NewChannelAbilityManager()
+	window := func(models []string, limit interface{}) *ChannelAbilityVersion {
+		return &ChannelAbilityVersion{
+			Version:         "v1",
+			SupportedModels: models,
+			Features: map[ChannelAbilityFeature]FeatureConfig{
+				FeatureContextWindow: {Supported: true, Limits: map[string]interface{}{ContextWindowLimitKey: limit}},
+			},
+		}
+	}
+
+	manager.RegisterAbility("ch-1", window([]string{"gpt-4", "gpt-4o"}, 8192))
+	manager.RegisterAbility("ch-2", window([]string{"gpt-4"}, float64(4096))) // 从 JSON 导入时为 float64
+	manager.RegisterAbility("ch-3", &ChannelAbilityVersion{Version: "v1", SupportedModels: []string{"gpt-4"}})
+
+	if got := manager.ContextWindow("gpt-4"); got != 4096 {
+		t.Errorf("Expected the smallest declared window, got %d", got)
+	}
+	if got := manager.ContextWindow("gpt-4o"); got != 8192 {
+		t.Errorf("Expected 8192 for gpt-4o, got %d", got)
+	}
+	if got := manager.ContextWindow("claude-3"); got != 0 {
+		t.Errorf("Expected 0 for a model without declared window, got %d", got)
+	}
+}
@@ -3,6 +3,7 @@ package relay
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -47,6 +48,9 @@ type ChannelCache struct {
 	lastUpdateTime time.Time
 	updateMu       sync.RWMutex
 
+	// 渠道集合或渠道配置每次变更时递增
+	version uint64
+
 	// 统计信息
 	cacheHits   int64
 	cacheMisses int64
@@ -246,6 +250,7 @@ func (cc *ChannelCache) ClearCache() {
 	defer cc.memoryCacheMu.Unlock()
 
 	cc.memoryCache = make(map[string]*Channel)
+	atomic.AddUint64(&cc.version, 1)
 
 	cc.indexByTypeMu.Lock()
 	cc.indexByType = make(map[string][]*Channel)
@@ -282,8 +287,15 @@ func (cc *ChannelCache) RefreshCache(channels []*Channel) error {
 	return nil
 }
 
+// Version 缓存版本，渠道增删、更新或刷新后递增
+func (cc *ChannelCache) Version() uint64 {
+	return atomic.LoadUint64(&cc.version)
+}
+
 // updateIndices 重建所有索引
 func (cc *ChannelCache) updateIndices() {
+	atomic.AddUint64(&cc.version, 1)
+
 	// 清空索引
 	newIndexByType := make(map[string][]*Channel)
 	newIndexByModel := make(map[string][]*Channel)
@@ -0,0 +1,225 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 密钥状态
+const (
+	KeyStatusActive = "active" // 参与轮询
+	KeyStatusParked = "parked" // 连续鉴权失败或限流，暂停使用直到 parked_until
+)
+
+// KeyHealth 单个密钥的健康状态，密钥只保留末 4 位
+type KeyHealth struct {
+	Index               int        `json:"index"`
+	Key                 string     `json:"key"`
+	Status              string     `json:"status"`
+	ParkedUntil         *time.Time `json:"parked_until,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastStatusCode      int        `json:"last_status_code,omitempty"`
+	Requests            int64      `json:"requests"`
+	Failures            int64      `json:"failures"`
+}
+
+// pooledKey 密钥及其失败统计
+type pooledKey struct {
+	key                 string
+	consecutiveFailures int
+	parkedUntil         time.Time
+	lastStatusCode      int
+	requests            int64
+	failures            int64
+}
+
+// ChannelKeyPool 渠道的多密钥池
+//
+// 按轮询顺序为每次请求选择一个密钥；同一个密钥连续 parkAfter 次返回 401/403/429 时
+// 暂停使用 parkFor，其间请求落到其它密钥上，成功一次即清零连续失败计数。
+type ChannelKeyPool struct {
+	mu        sync.Mutex
+	keys      []*pooledKey
+	next      int
+	parkAfter int
+	parkFor   time.Duration
+}
+
+// NewChannelKeyPool 创建密钥池，parkAfter <= 0 时不暂停密钥
+func NewChannelKeyPool(keys []string, parkAfter int, parkFor time.Duration) *ChannelKeyPool {
+	p := &ChannelKeyPool{parkAfter: parkAfter, parkFor: parkFor}
+	p.SetKeys(keys)
+	return p
+}
+
+// SetKeys 替换密钥列表，未变化的密钥保留失败统计与暂停状态
+func (p *ChannelKeyPool) SetKeys(keys []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	existing := make(map[string]*pooledKey, len(p.keys))
+	for _, k := range p.keys {
+		existing[k.key] = k
+	}
+	p.keys = make([]*pooledKey, 0, len(keys))
+	for _, key := range keys {
+		if k, ok := existing[key]; ok {
+			p.keys = append(p.keys, k)
+			continue
+		}
+		p.keys = append(p.keys, &pooledKey{key: key})
+	}
+	if p.next >= len(p.keys) {
+		p.next = 0
+	}
+}
+
+// Len 密钥数量
+func (p *ChannelKeyPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.keys)
+}
+
+// Available 是否还有未暂停的密钥
+func (p *ChannelKeyPool) Available(now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, k := range p.keys {
+		if !now.Before(k.parkedUntil) {
+			return true
+		}
+	}
+	return len(p.keys) == 0
+}
+
+// Next 按轮询顺序选择下一个未暂停的密钥，返回其序号；全部暂停时选择最早恢复的密钥，
+// 密钥池为空时返回 -1
+func (p *ChannelKeyPool) Next(now time.Time) (int, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := len(p.keys)
+	if n == 0 {
+		return -1, ""
+	}
+
+	earliest := -1
+	for i := 0; i < n; i++ {
+		idx := (p.next + i) % n
+		k := p.keys[idx]
+		if !now.Before(k.parkedUntil) {
+			p.next = (idx + 1) % n
+			k.requests++
+			return idx, k.key
+		}
+		if earliest < 0 || k.parkedUntil.Before(p.keys[earliest].parkedUntil) {
+			earliest = idx
+		}
+	}
+
+	p.keys[earliest].requests++
+	return earliest, p.keys[earliest].key
+}
+
+// Record 记录使用 index 号密钥的请求结果
+//
+// 只有鉴权失败（401/403）与限流（429）计入密钥的连续失败，其它错误与密钥无关，不影响计数。
+func (p *ChannelKeyPool) Record(index int, err error, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if index < 0 || index >= len(p.keys) {
+		return
+	}
+	k := p.keys[index]
+
+	if err == nil {
+		k.consecutiveFailures = 0
+		k.lastStatusCode = http.StatusOK
+		return
+	}
+
+	status, ok := keyFailureStatus(err)
+	if !ok {
+		return
+	}
+	k.failures++
+	k.lastStatusCode = status
+	k.consecutiveFailures++
+	if p.parkAfter > 0 && k.consecutiveFailures >= p.parkAfter {
+		k.parkedUntil = now.Add(p.parkFor)
+		k.consecutiveFailures = 0
+	}
+}
+
+// Health 各密钥的健康状态（按配置顺序）
+func (p *ChannelKeyPool) Health(now time.Time) []KeyHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	health := make([]KeyHealth, 0, len(p.keys))
+	for i, k := range p.keys {
+		h := KeyHealth{
+			Index:               i,
+			Key:                 MaskKey(k.key),
+			Status:              KeyStatusActive,
+			ConsecutiveFailures: k.consecutiveFailures,
+			LastStatusCode:      k.lastStatusCode,
+			Requests:            k.requests,
+			Failures:            k.failures,
+		}
+		if now.Before(k.parkedUntil) {
+			until := k.parkedUntil
+			h.Status = KeyStatusParked
+			h.ParkedUntil = &until
+		}
+		health = append(health, h)
+	}
+	return health
+}
+
+// keyFailureStatus 错误是否由密钥本身引起（失效、无权限或该密钥被限流）
+func keyFailureStatus(err error) (int, bool) {
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		return 0, false
+	}
+	switch upstreamErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return upstreamErr.StatusCode, true
+	}
+	return 0, false
+}
+
+// MaskKey 只保留密钥末 4 位，如 "****abcd"；不超过 4 位的密钥全部掩码
+func MaskKey(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
+
+// channelKeyContextKey 本次尝试选中的渠道密钥在 context 中的键
+type channelKeyContextKey struct{}
+
+// ChannelKeySelection 本次尝试使用的渠道密钥
+type ChannelKeySelection struct {
+	Index int
+	Key   string
+}
+
+// WithChannelKey 记录本次尝试选中的渠道密钥
+func WithChannelKey(ctx context.Context, sel ChannelKeySelection) context.Context {
+	return context.WithValue(ctx, channelKeyContextKey{}, sel)
+}
+
+// ChannelKeyFromContext 获取本次尝试选中的渠道密钥，未选择（单密钥渠道或直接调用）时 ok 为 false
+func ChannelKeyFromContext(ctx context.Context) (ChannelKeySelection, bool) {
+	sel, ok := ctx.Value(channelKeyContextKey{}).(ChannelKeySelection)
+	return sel, ok
+}
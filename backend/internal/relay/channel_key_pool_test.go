@@ -0,0 +1,178 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestChannelKeyPoolRoundRobin(t *testing.T) {
+	pool := NewChannelKeyPool([]string{"sk-aaaa1111", "sk-bbbb2222", "sk-cccc3333"}, 3, time.Minute)
+	now := time.Now()
+
+	var got []string
+	for i := 0; i < 6; i++ {
+		_, key := pool.Next(now)
+		got = append(got, key)
+	}
+	want := []string{"sk-aaaa1111", "sk-bbbb2222", "sk-cccc3333", "sk-aaaa1111", "sk-bbbb2222", "sk-cccc3333"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected round-robin order %v, got %v", want, got)
+		}
+	}
+}
+
+func TestChannelKeyPoolParksFailingKey(t *testing.T) {
+	pool := NewChannelKeyPool([]string{"sk-aaaa1111", "sk-bbbb2222"}, 3, time.Minute)
+	now := time.Now()
+	rateLimited := &UpstreamError{StatusCode: http.StatusTooManyRequests, Message: "rate limited"}
+
+	// 与密钥无关的错误不计入
+	pool.Record(0, &UpstreamError{StatusCode: http.StatusBadRequest}, now)
+	pool.Record(0, errors.New("connection reset"), now)
+	// 成功一次清零连续失败
+	pool.Record(0, rateLimited, now)
+	pool.Record(0, rateLimited, now)
+	pool.Record(0, nil, now)
+	pool.Record(0, rateLimited, now)
+	pool.Record(0, rateLimited, now)
+	if h := pool.Health(now)[0]; h.Status != KeyStatusActive || h.ConsecutiveFailures != 2 {
+		t.Fatalf("Expected key to stay active with 2 consecutive failures, got %+v", h)
+	}
+
+	pool.Record(0, rateLimited, now)
+	health := pool.Health(now)
+	if health[0].Status != KeyStatusParked || health[0].ParkedUntil == nil || !health[0].ParkedUntil.Equal(now.Add(time.Minute)) {
+		t.Fatalf("Expected key 0 to be parked for a minute, got %+v", health[0])
+	}
+	if health[0].Failures != 5 || health[0].LastStatusCode != http.StatusTooManyRequests {
+		t.Errorf("Unexpected failure stats %+v", health[0])
+	}
+
+	// 暂停期间只使用其它密钥
+	for i := 0; i < 3; i++ {
+		if idx, _ := pool.Next(now); idx != 1 {
+			t.Fatalf("Expected parked key to be skipped, got key %d", idx)
+		}
+	}
+	if !pool.Available(now) {
+		t.Error("Expected pool to be available while another key is active")
+	}
+
+	// 全部暂停：不可用，但 Next 仍返回最早恢复的密钥
+	unauthorized := &UpstreamError{StatusCode: http.StatusUnauthorized, Message: "invalid api key"}
+	later := now.Add(10 * time.Second)
+	for i := 0; i < 3; i++ {
+		pool.Record(1, unauthorized, later)
+	}
+	if pool.Available(later) {
+		t.Error("Expected pool to be unavailable when every key is parked")
+	}
+	if idx, _ := pool.Next(later); idx != 0 {
+		t.Errorf("Expected the earliest recovering key, got %d", idx)
+	}
+
+	// 到期后恢复
+	if h := pool.Health(now.Add(time.Minute)); h[0].Status != KeyStatusActive {
+		t.Errorf("Expected key 0 to recover after parking, got %+v", h[0])
+	}
+}
+
+func TestChannelKeyPoolSetKeysKeepsState(t *testing.T) {
+	pool := NewChannelKeyPool([]string{"sk-aaaa1111", "sk-bbbb2222"}, 1, time.Minute)
+	now := time.Now()
+	pool.Record(1, &UpstreamError{StatusCode: http.StatusForbidden}, now)
+
+	pool.SetKeys([]string{"sk-bbbb2222", "sk-dddd4444"})
+	health := pool.Health(now)
+	if len(health) != 2 || health[0].Status != KeyStatusParked || health[1].Status != KeyStatusActive {
+		t.Errorf("Expected unchanged key to keep its parked state, got %+v", health)
+	}
+}
+
+func TestMaskKey(t *testing.T) {
+	tests := map[string]string{
+		"sk-proj-1234567890abcd": "****abcd",
+		"abcd":                   "****",
+		"":                       "****",
+	}
+	for key, want := range tests {
+		if got := MaskKey(key); got != want {
+			t.Errorf("MaskKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestExecuteWithFailoverAttributesKeyFailures(t *testing.T) {
+	lb := newFailoverTestBalancer(1, 0)
+	lb.SyncChannelKeys(map[string][]string{"1": {"sk-good-0001", "sk-bad-0002"}})
+
+	// sk-bad 每次都返回 401，sk-good 成功
+	var used []string
+	attempt := func(ctx context.Context, ch *Channel) error {
+		sel, ok := ChannelKeyFromContext(ctx)
+		if !ok {
+			t.Fatal("Expected a key to be selected for a multi-key channel")
+		}
+		used = append(used, sel.Key)
+		if sel.Key == "sk-bad-0002" {
+			return &UpstreamError{StatusCode: http.StatusUnauthorized, Message: "invalid api key"}
+		}
+		return nil
+	}
+
+	for i := 0; i < 10; i++ {
+		_ = lb.ExecuteWithFailover(context.Background(), &ChannelSelectOptions{Model: "gpt-4"}, attempt)
+	}
+
+	// 连续 3 次 401 后 sk-bad 被暂停，之后的请求都使用 sk-good
+	bad := 0
+	for _, key := range used {
+		if key == "sk-bad-0002" {
+			bad++
+		}
+	}
+	if bad != 3 {
+		t.Errorf("Expected the failing key to be used exactly 3 times before parking, got %d (%v)", bad, used)
+	}
+	health := lb.ChannelKeyHealth("1")
+	if len(health) != 2 || health[1].Status != KeyStatusParked || health[1].Key != "****0002" || health[0].Status != KeyStatusActive {
+		t.Errorf("Unexpected key health %+v", health)
+	}
+
+	// 单密钥渠道不使用密钥池
+	lb.SyncChannelKeys(map[string][]string{"1": {"sk-only"}})
+	if lb.ChannelKeyHealth("1") != nil {
+		t.Error("Expected single-key channels to have no key pool")
+	}
+	_ = lb.ExecuteWithFailover(context.Background(), &ChannelSelectOptions{Model: "gpt-4"}, func(ctx context.Context, ch *Channel) error {
+		if _, ok := ChannelKeyFromContext(ctx); ok {
+			t.Error("Expected no key selection for a single-key channel")
+		}
+		return nil
+	})
+}
+
+func TestSelectChannelSkipsChannelWithAllKeysParked(t *testing.T) {
+	lb := newFailoverTestBalancer(2, 0)
+	lb.SyncChannelKeys(map[string][]string{"1": {"sk-a-0001", "sk-b-0002"}})
+
+	rateLimited := &UpstreamError{StatusCode: http.StatusTooManyRequests}
+	for i := 0; i < 3; i++ {
+		_ = lb.RecordKeyRequest("1", 0, rateLimited, 0)
+		_ = lb.RecordKeyRequest("1", 1, rateLimited, 0)
+	}
+
+	for i := 0; i < 20; i++ {
+		ch, err := lb.SelectChannel(&ChannelSelectOptions{Model: "gpt-4"})
+		if err != nil {
+			t.Fatalf("SelectChannel failed: %v", err)
+		}
+		if ch.ID == "1" {
+			t.Fatal("Expected channel with every key parked to be skipped")
+		}
+	}
+}
@@ -62,6 +62,11 @@ func (cs ChannelStatus) String() string {
 	}
 }
 
+// MarshalJSON 以字符串形式输出渠道状态
+func (cs ChannelStatus) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + cs.String() + `"`), nil
+}
+
 // ChannelMetrics 渠道指标
 type ChannelMetrics struct {
 	// 总请求数
@@ -87,6 +92,18 @@ type ChannelMetrics struct {
 
 	// 当前并发数
 	CurrentConcurrency int64 `json:"current_concurrency"`
+
+	// 最近一次健康探测的延迟（毫秒）
+	ProbeLatency int64 `json:"probe_latency_ms"`
+}
+
+// SupportFeature 渠道能力中是否标记了指定功能
+func (ch *Channel) SupportFeature(feature string) bool {
+	if ch.Ability == nil {
+		return false
+	}
+	enabled, _ := ch.Ability.Features[feature].(bool)
+	return enabled
 }
 
 // GetSuccessRate 计算成功率
@@ -146,9 +163,12 @@ type Channel struct {
 	// 优先级（数字越小优先级越高）
 	Priority int `json:"priority"`
 
-	// 权重（用于负载均衡）
+	// 权重（用于负载均衡），启用权重自适应时为调整后的有效权重
 	Weight int `json:"weight"`
 
+	// 配置的基础权重，权重自适应以它为基准计算有效权重；为 0 时取首次调整前的 Weight
+	BaseWeight int `json:"base_weight"`
+
 	// 当前状态
 	Status atomic.Value // ChannelStatus
 
@@ -281,7 +301,7 @@ func (ch *Channel) SupportModel(model string) bool {
 		return false
 	}
 	for _, m := range ch.Ability.SupportedModels {
-		if m == model {
+		if matchPattern(model, m) {
 			return true
 		}
 	}
@@ -314,6 +334,7 @@ func (ch *Channel) GetMetricsSnapshot() map[string]interface{} {
 		"consecutive_failures": atomic.LoadInt64(&ch.Metrics.ConsecutiveFailures),
 		"last_success_time":    atomic.LoadInt64(&ch.Metrics.LastSuccessTime),
 		"last_failure_time":    atomic.LoadInt64(&ch.Metrics.LastFailureTime),
+		"probe_latency_ms":     atomic.LoadInt64(&ch.Metrics.ProbeLatency),
 	}
 }
 
@@ -325,6 +346,9 @@ type ChannelFilter struct {
 	// 支持的模型
 	Model string
 
+	// 渠道能力中标记的功能，见 Feature*
+	Feature string
+
 	// 地区
 	Region string
 
@@ -362,6 +386,11 @@ func (ch *Channel) Matches(filter *ChannelFilter) bool {
 		return false
 	}
 
+	// 检查功能
+	if filter.Feature != "" && !ch.SupportFeature(filter.Feature) {
+		return false
+	}
+
 	// 检查地区
 	if filter.Region != "" && ch.Region != filter.Region {
 		return false
@@ -0,0 +1,395 @@
+package relay
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// SelectorStrategy 渠道选择策略
+type SelectorStrategy int
+
+const (
+	// 随机
+	SelectorStrategyRandom SelectorStrategy = iota
+	// 轮询
+	SelectorStrategyRoundRobin
+	// 加权轮询
+	SelectorStrategyWeightedRoundRobin
+	// 最少连接
+	SelectorStrategyLeastConnection
+	// 最低延迟
+	SelectorStrategyLowestLatency
+)
+
+// String 返回策略的字符串表示
+func (s SelectorStrategy) String() string {
+	switch s {
+	case SelectorStrategyRandom:
+		return "random"
+	case SelectorStrategyRoundRobin:
+		return "round_robin"
+	case SelectorStrategyWeightedRoundRobin:
+		return "weighted_round_robin"
+	case SelectorStrategyLeastConnection:
+		return "least_connection"
+	case SelectorStrategyLowestLatency:
+		return "lowest_latency"
+	default:
+		return "unknown"
+	}
+}
+
+// WildcardRule 通配符路由规则（如 gpt-* 优先走指定渠道）
+type WildcardRule struct {
+	// 规则 ID
+	ID string `json:"id"`
+
+	// 模型匹配模式（支持 *）
+	Pattern string `json:"pattern"`
+
+	// 渠道类型（为空表示不限）
+	ChannelType string `json:"channel_type"`
+
+	// 优先渠道 ID 列表
+	PriorityChannels []string `json:"priority_channels"`
+
+	// 规则权重（越大越优先）
+	Weight int `json:"weight"`
+
+	// 是否启用
+	Enabled bool `json:"enabled"`
+}
+
+// WildcardRuleManager 通配符规则管理器
+type WildcardRuleManager struct {
+	rules map[string]*WildcardRule
+	mu    sync.RWMutex
+}
+
+// NewWildcardRuleManager 创建通配符规则管理器
+func NewWildcardRuleManager() *WildcardRuleManager {
+	return &WildcardRuleManager{
+		rules: make(map[string]*WildcardRule),
+	}
+}
+
+// AddRule 添加规则
+func (wrm *WildcardRuleManager) AddRule(rule *WildcardRule) error {
+	if rule == nil {
+		return fmt.Errorf("rule cannot be nil")
+	}
+	if rule.ID == "" {
+		return fmt.Errorf("rule ID is required")
+	}
+	if rule.Pattern == "" {
+		return fmt.Errorf("rule pattern is required")
+	}
+
+	wrm.mu.Lock()
+	defer wrm.mu.Unlock()
+
+	wrm.rules[rule.ID] = rule
+	return nil
+}
+
+// RemoveRule 移除规则
+func (wrm *WildcardRuleManager) RemoveRule(ruleID string) error {
+	wrm.mu.Lock()
+	defer wrm.mu.Unlock()
+
+	if _, ok := wrm.rules[ruleID]; !ok {
+		return fmt.Errorf("rule %s not found", ruleID)
+	}
+
+	delete(wrm.rules, ruleID)
+	return nil
+}
+
+// GetRule 获取规则
+func (wrm *WildcardRuleManager) GetRule(ruleID string) (*WildcardRule, error) {
+	wrm.mu.RLock()
+	defer wrm.mu.RUnlock()
+
+	rule, ok := wrm.rules[ruleID]
+	if !ok {
+		return nil, fmt.Errorf("rule %s not found", ruleID)
+	}
+
+	return rule, nil
+}
+
+// GetAllRules 获取所有规则（按权重降序）
+func (wrm *WildcardRuleManager) GetAllRules() []*WildcardRule {
+	wrm.mu.RLock()
+	defer wrm.mu.RUnlock()
+
+	rules := make([]*WildcardRule, 0, len(wrm.rules))
+	for _, rule := range wrm.rules {
+		rules = append(rules, rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Weight != rules[j].Weight {
+			return rules[i].Weight > rules[j].Weight
+		}
+		return rules[i].ID < rules[j].ID
+	})
+
+	return rules
+}
+
+// ChannelSelector 基于渠道缓存的渠道选择器
+type ChannelSelector struct {
+	// 缓存
+	cache *ChannelCache
+
+	// 策略
+	strategy SelectorStrategy
+
+	// 通配符规则
+	rules *WildcardRuleManager
+
+	// 轮询计数器
+	roundRobinCounter uint64
+
+	// 统计信息
+	totalSelections  int64
+	failedSelections int64
+	ruleHits         int64
+}
+
+// NewChannelSelector 创建渠道选择器
+func NewChannelSelector(cache *ChannelCache, strategy SelectorStrategy) *ChannelSelector {
+	return newChannelSelectorWithRules(cache, strategy, NewWildcardRuleManager())
+}
+
+func newChannelSelectorWithRules(cache *ChannelCache, strategy SelectorStrategy, rules *WildcardRuleManager) *ChannelSelector {
+	return &ChannelSelector{
+		cache:    cache,
+		strategy: strategy,
+		rules:    rules,
+	}
+}
+
+// AddWildcardRule 添加通配符规则
+func (cs *ChannelSelector) AddWildcardRule(rule *WildcardRule) error {
+	return cs.rules.AddRule(rule)
+}
+
+// SelectChannel 选择一个渠道
+func (cs *ChannelSelector) SelectChannel(options *ChannelSelectOptions) (*Channel, error) {
+	atomic.AddInt64(&cs.totalSelections, 1)
+
+	candidates := cs.getCandidates(options)
+	if len(candidates) == 0 {
+		atomic.AddInt64(&cs.failedSelections, 1)
+		return nil, fmt.Errorf("no available channels for model %s", options.Model)
+	}
+
+	// 通配符规则命中时缩小候选集
+	if preferred := cs.applyRules(candidates, options); len(preferred) > 0 {
+		atomic.AddInt64(&cs.ruleHits, 1)
+		candidates = preferred
+	}
+
+	var selected *Channel
+	switch cs.strategy {
+	case SelectorStrategyRandom:
+		selected = candidates[randInt(len(candidates))]
+	case SelectorStrategyRoundRobin:
+		idx := atomic.AddUint64(&cs.roundRobinCounter, 1) - 1
+		selected = candidates[idx%uint64(len(candidates))]
+	case SelectorStrategyWeightedRoundRobin:
+		selected = cs.selectWeighted(candidates)
+	case SelectorStrategyLeastConnection:
+		selected = candidates[0]
+		for _, ch := range candidates[1:] {
+			if atomic.LoadInt64(&ch.Metrics.CurrentConcurrency) < atomic.LoadInt64(&selected.Metrics.CurrentConcurrency) {
+				selected = ch
+			}
+		}
+	case SelectorStrategyLowestLatency:
+		selected = candidates[0]
+		for _, ch := range candidates[1:] {
+			if ch.Metrics.AvgLatency < selected.Metrics.AvgLatency {
+				selected = ch
+			}
+		}
+	default:
+		atomic.AddInt64(&cs.failedSelections, 1)
+		return nil, fmt.Errorf("unknown selector strategy: %d", cs.strategy)
+	}
+
+	return selected, nil
+}
+
+// getCandidates 获取候选渠道（按 ID 排序保证轮询稳定）
+func (cs *ChannelSelector) getCandidates(options *ChannelSelectOptions) []*Channel {
+	filter := &ChannelFilter{
+		Type:            options.ChannelType,
+		Model:           options.Model,
+		Region:          options.Region,
+		MinAvailability: options.MinAvailability,
+		OnlyEnabled:     true,
+	}
+
+	candidates := cs.cache.FilterChannels(filter)
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].ID < candidates[j].ID
+	})
+
+	return candidates
+}
+
+// applyRules 应用通配符规则，返回规则指定的优先渠道
+func (cs *ChannelSelector) applyRules(candidates []*Channel, options *ChannelSelectOptions) []*Channel {
+	for _, rule := range cs.rules.GetAllRules() {
+		if !rule.Enabled || !cs.matchPattern(options.Model, rule.Pattern) {
+			continue
+		}
+		if rule.ChannelType != "" && options.ChannelType != "" && rule.ChannelType != options.ChannelType {
+			continue
+		}
+
+		priority := make(map[string]bool, len(rule.PriorityChannels))
+		for _, id := range rule.PriorityChannels {
+			priority[id] = true
+		}
+
+		preferred := make([]*Channel, 0)
+		for _, ch := range candidates {
+			if priority[ch.ID] {
+				preferred = append(preferred, ch)
+			}
+		}
+		if len(preferred) > 0 {
+			return preferred
+		}
+	}
+
+	return nil
+}
+
+// selectWeighted 按权重随机选择
+func (cs *ChannelSelector) selectWeighted(candidates []*Channel) *Channel {
+	totalWeight := 0
+	for _, ch := range candidates {
+		totalWeight += ch.Weight
+	}
+	if totalWeight <= 0 {
+		return candidates[randInt(len(candidates))]
+	}
+
+	target := randInt(totalWeight)
+	current := 0
+	for _, ch := range candidates {
+		current += ch.Weight
+		if target < current {
+			return ch
+		}
+	}
+
+	return candidates[len(candidates)-1]
+}
+
+// matchPattern 通配符匹配（* 匹配任意长度字符）
+func (cs *ChannelSelector) matchPattern(text, pattern string) bool {
+	return matchPattern(text, pattern)
+}
+
+// matchPattern 通配符匹配（* 匹配任意长度字符）
+func matchPattern(text, pattern string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if !strings.Contains(pattern, "*") {
+		return text == pattern
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(text, parts[0]) {
+		return false
+	}
+	text = text[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(text, part)
+		if idx < 0 {
+			return false
+		}
+		text = text[idx+len(part):]
+	}
+
+	return strings.HasSuffix(text, last)
+}
+
+// GetStatistics 获取统计信息
+func (cs *ChannelSelector) GetStatistics() map[string]interface{} {
+	return map[string]interface{}{
+		"strategy":          cs.strategy.String(),
+		"total_selections":  atomic.LoadInt64(&cs.totalSelections),
+		"failed_selections": atomic.LoadInt64(&cs.failedSelections),
+		"rule_hits":         atomic.LoadInt64(&cs.ruleHits),
+	}
+}
+
+// ChannelSelectorManager 按渠道类型管理选择器
+type ChannelSelectorManager struct {
+	cache           *ChannelCache
+	rules           *WildcardRuleManager
+	selectors       map[string]*ChannelSelector
+	defaultStrategy SelectorStrategy
+	mu              sync.RWMutex
+}
+
+// NewChannelSelectorManager 创建选择器管理器
+func NewChannelSelectorManager(cache *ChannelCache) *ChannelSelectorManager {
+	return &ChannelSelectorManager{
+		cache:           cache,
+		rules:           NewWildcardRuleManager(),
+		selectors:       make(map[string]*ChannelSelector),
+		defaultStrategy: SelectorStrategyWeightedRoundRobin,
+	}
+}
+
+// SetStrategy 设置指定渠道类型的选择策略
+func (csm *ChannelSelectorManager) SetStrategy(channelType string, strategy SelectorStrategy) {
+	csm.mu.Lock()
+	defer csm.mu.Unlock()
+
+	csm.selectors[channelType] = newChannelSelectorWithRules(csm.cache, strategy, csm.rules)
+}
+
+// SelectChannel 选择渠道
+func (csm *ChannelSelectorManager) SelectChannel(options *ChannelSelectOptions) (*Channel, error) {
+	return csm.getSelector(options.ChannelType).SelectChannel(options)
+}
+
+// getSelector 获取或创建选择器
+func (csm *ChannelSelectorManager) getSelector(channelType string) *ChannelSelector {
+	csm.mu.RLock()
+	selector, ok := csm.selectors[channelType]
+	csm.mu.RUnlock()
+	if ok {
+		return selector
+	}
+
+	csm.mu.Lock()
+	defer csm.mu.Unlock()
+
+	if selector, ok := csm.selectors[channelType]; ok {
+		return selector
+	}
+	selector = newChannelSelectorWithRules(csm.cache, csm.defaultStrategy, csm.rules)
+	csm.selectors[channelType] = selector
+	return selector
+}
+
+// GetRuleManager 获取规则管理器
+func (csm *ChannelSelectorManager) GetRuleManager() *WildcardRuleManager {
+	return csm.rules
+}
@@ -2,7 +2,6 @@ package relay
 
 import (
 	"testing"
-	"time"
 )
 
 func TestChannelSelectorRandom(t *testing.T) {
@@ -65,7 +65,7 @@ func TestChannelMetrics(t *testing.T) {
 	}
 
 	if ch.GetSuccessRate() != 100 {
-		t.Errorf("Expected 100% success rate")
+		t.Errorf("Expected 100%% success rate")
 	}
 
 	// 记录失败
@@ -0,0 +1,191 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrChannelBusy 渠道的并发数已达上限
+var ErrChannelBusy = errors.New("channel concurrency limit reached")
+
+// ChannelBusyError 候选渠道的并发数都已达上限且排队超时，调用方应返回 429 并在 RetryAfter 后重试
+type ChannelBusyError struct {
+	ChannelID  string
+	RetryAfter time.Duration
+}
+
+// Error 实现 error 接口
+func (e *ChannelBusyError) Error() string {
+	return fmt.Sprintf("channel %s is at its concurrency limit, retry after %s", e.ChannelID, e.RetryAfter)
+}
+
+// Unwrap 返回 ErrChannelBusy
+func (e *ChannelBusyError) Unwrap() error {
+	return ErrChannelBusy
+}
+
+// ConcurrencyLimiter 单个渠道的并发槽位（信号量），上限随渠道配置变化
+//
+// 上限不大于 0 时不限制，但仍统计进行中的请求数，供最少连接策略使用。
+type ConcurrencyLimiter struct {
+	mu      sync.Mutex
+	active  int
+	waiting int
+	freed   chan struct{} // 有槽位释放时关闭并替换，唤醒所有排队的请求
+}
+
+// NewConcurrencyLimiter 创建并发槽位
+func NewConcurrencyLimiter() *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{freed: make(chan struct{})}
+}
+
+// Acquire 占用一个槽位，返回的 release 可重复调用但只释放一次
+//
+// 槽位已满时最多排队等待 wait，wait 不大于 0 时不等待；超时返回 ErrChannelBusy，ctx 结束时返回 ctx 的错误。
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, limit int, wait time.Duration) (func(), error) {
+	var deadline <-chan time.Time
+	for {
+		l.mu.Lock()
+		if limit <= 0 || l.active < limit {
+			l.active++
+			l.mu.Unlock()
+			var once sync.Once
+			return func() { once.Do(l.release) }, nil
+		}
+		if wait <= 0 {
+			l.mu.Unlock()
+			return nil, ErrChannelBusy
+		}
+		if deadline == nil {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+		l.waiting++
+		freed := l.freed
+		l.mu.Unlock()
+
+		var err error
+		select {
+		case <-freed:
+		case <-deadline:
+			err = ErrChannelBusy
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		l.mu.Lock()
+		l.waiting--
+		l.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (l *ConcurrencyLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	close(l.freed)
+	l.freed = make(chan struct{})
+}
+
+// Active 进行中的请求数
+func (l *ConcurrencyLimiter) Active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active
+}
+
+// Queued 排队等待槽位的请求数
+func (l *ConcurrencyLimiter) Queued() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.waiting
+}
+
+// ChannelConcurrency 渠道的并发快照
+type ChannelConcurrency struct {
+	ChannelID      string `json:"channel_id"`
+	ChannelName    string `json:"channel_name,omitempty"`
+	MaxConcurrency int    `json:"max_concurrency"` // 0 表示不限制
+	Active         int    `json:"active"`
+	QueueDepth     int    `json:"queue_depth"`
+}
+
+// concurrencyLimiter 渠道的并发槽位，不存在时创建
+//
+// 槽位按渠道 ID 保存在负载均衡器中，渠道缓存刷新（渠道对象被替换）时进行中的请求仍占用原来的槽位。
+func (lb *LoadBalancer) concurrencyLimiter(channelID string) *ConcurrencyLimiter {
+	lb.limitersMu.Lock()
+	defer lb.limitersMu.Unlock()
+	limiter, ok := lb.limiters[channelID]
+	if !ok {
+		limiter = NewConcurrencyLimiter()
+		lb.limiters[channelID] = limiter
+	}
+	return limiter
+}
+
+// activeRequests 渠道进行中的请求数
+func (lb *LoadBalancer) activeRequests(channelID string) int {
+	lb.limitersMu.Lock()
+	limiter, ok := lb.limiters[channelID]
+	lb.limitersMu.Unlock()
+	if !ok {
+		return 0
+	}
+	return limiter.Active()
+}
+
+// acquireSlot 占用渠道的并发槽位，最多排队等待 wait
+func (lb *LoadBalancer) acquireSlot(ctx context.Context, ch *Channel, wait time.Duration) (func(), error) {
+	release, err := lb.concurrencyLimiter(ch.ID).Acquire(ctx, channelMaxConcurrency(ch), wait)
+	if errors.Is(err, ErrChannelBusy) {
+		return nil, &ChannelBusyError{ChannelID: ch.ID, RetryAfter: busyRetryAfter(ch)}
+	}
+	return release, err
+}
+
+// GetChannelConcurrency 获取每个渠道的并发快照，按渠道 ID 排序
+func (lb *LoadBalancer) GetChannelConcurrency() []ChannelConcurrency {
+	channels := lb.cache.GetAllChannels()
+	states := make([]ChannelConcurrency, 0, len(channels))
+	for _, ch := range channels {
+		state := ChannelConcurrency{ChannelID: ch.ID, ChannelName: ch.Name, MaxConcurrency: channelMaxConcurrency(ch)}
+		lb.limitersMu.Lock()
+		limiter, ok := lb.limiters[ch.ID]
+		lb.limitersMu.Unlock()
+		if ok {
+			state.Active = limiter.Active()
+			state.QueueDepth = limiter.Queued()
+		}
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ChannelID < states[j].ChannelID })
+	return states
+}
+
+// channelMaxConcurrency 渠道的最大并发数，0 表示不限制
+func channelMaxConcurrency(ch *Channel) int {
+	if ch.Ability == nil {
+		return 0
+	}
+	return ch.Ability.MaxConcurrency
+}
+
+// busyRetryAfter 建议的重试等待时间：渠道的平均延迟（约为一个槽位释放的时间），至少 1 秒
+func busyRetryAfter(ch *Channel) time.Duration {
+	retryAfter := time.Second
+	if ch.Metrics != nil {
+		if latency := time.Duration(ch.Metrics.AvgLatency) * time.Millisecond; latency > retryAfter {
+			retryAfter = latency
+		}
+	}
+	return retryAfter
+}
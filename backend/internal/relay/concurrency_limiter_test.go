@@ -0,0 +1,147 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newLimitedTestBalancer 创建 n 个渠道，第一个渠道的最大并发数为 limit
+func newLimitedTestBalancer(n, limit int, queueTimeout time.Duration) *LoadBalancer {
+	lb := newFailoverTestBalancer(n, 2)
+	lb.config.ChannelQueueTimeout = queueTimeout
+	ch, _ := lb.cache.GetChannel("1")
+	ch.Ability.MaxConcurrency = limit
+	return lb
+}
+
+func TestConcurrencyLimitNeverExceeded(t *testing.T) {
+	const limit = 5
+	lb := newLimitedTestBalancer(1, limit, 10*time.Second)
+
+	var inFlight, peak, succeeded int64
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := lb.ExecuteWithFailover(context.Background(), &ChannelSelectOptions{Model: "gpt-4"}, func(ctx context.Context, ch *Channel) error {
+				n := atomic.AddInt64(&inFlight, 1)
+				defer atomic.AddInt64(&inFlight, -1)
+				for {
+					p := atomic.LoadInt64(&peak)
+					if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				return nil
+			})
+			if err == nil {
+				atomic.AddInt64(&succeeded, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak > limit {
+		t.Errorf("concurrency limit violated: peak %d > %d", peak, limit)
+	}
+	if succeeded != 100 {
+		t.Errorf("expected all queued requests to succeed, got %d", succeeded)
+	}
+	if state := lb.GetChannelConcurrency()[0]; state.Active != 0 || state.QueueDepth != 0 {
+		t.Errorf("expected all slots released, got %+v", state)
+	}
+}
+
+// holdSlots 占满渠道的槽位，返回释放函数
+func holdSlots(t *testing.T, lb *LoadBalancer, channelID string, n int) func() {
+	t.Helper()
+	ch, _ := lb.cache.GetChannel(channelID)
+	releases := make([]func(), 0, n)
+	for i := 0; i < n; i++ {
+		release, err := lb.acquireSlot(context.Background(), ch, 0)
+		if err != nil {
+			t.Fatalf("acquireSlot failed: %v", err)
+		}
+		releases = append(releases, release)
+	}
+	return func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+}
+
+func TestConcurrencyLimitFailsOverToIdleChannel(t *testing.T) {
+	lb := newLimitedTestBalancer(2, 1, time.Second)
+	defer holdSlots(t, lb, "1", 1)()
+
+	for i := 0; i < 5; i++ {
+		upstream := &fakeAdapter{}
+		if err := lb.ExecuteWithFailover(context.Background(), &ChannelSelectOptions{Model: "gpt-4"}, upstream.Do); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(upstream.calls) != 1 || upstream.calls[0] != "2" {
+			t.Fatalf("expected the idle channel, got %v", upstream.calls)
+		}
+	}
+	busy, _ := lb.cache.GetChannel("1")
+	if atomic.LoadInt64(&busy.Metrics.FailedRequests) != 0 {
+		t.Error("a busy channel must not be recorded as failed")
+	}
+}
+
+func TestConcurrencyLimitShedsWithRetryAfter(t *testing.T) {
+	lb := newLimitedTestBalancer(1, 1, 20*time.Millisecond)
+	defer holdSlots(t, lb, "1", 1)()
+
+	upstream := &fakeAdapter{}
+	err := lb.ExecuteWithFailover(context.Background(), &ChannelSelectOptions{Model: "gpt-4"}, upstream.Do)
+	var busyErr *ChannelBusyError
+	if !errors.As(err, &busyErr) || !errors.Is(err, ErrChannelBusy) {
+		t.Fatalf("expected ChannelBusyError, got %v", err)
+	}
+	if busyErr.RetryAfter < time.Second {
+		t.Errorf("expected Retry-After of at least 1s, got %s", busyErr.RetryAfter)
+	}
+	if len(upstream.calls) != 0 {
+		t.Errorf("upstream must not be called, got %v", upstream.calls)
+	}
+}
+
+func TestConcurrencyLimitQueueDepthAndRelease(t *testing.T) {
+	lb := newLimitedTestBalancer(1, 1, 10*time.Second)
+	release := holdSlots(t, lb, "1", 1)
+
+	// 排队中的请求出现在并发快照中，客户端取消时离开队列
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- lb.ExecuteWithFailover(ctx, &ChannelSelectOptions{Model: "gpt-4"}, (&fakeAdapter{}).Do)
+	}()
+	waitFor(t, func() bool { return lb.GetChannelConcurrency()[0].QueueDepth == 1 })
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if state := lb.GetChannelConcurrency()[0]; state.QueueDepth != 0 || state.Active != 1 || state.MaxConcurrency != 1 {
+		t.Errorf("unexpected state after cancellation: %+v", state)
+	}
+	release()
+
+	// attempt panic 时槽位同样释放
+	func() {
+		defer func() { recover() }()
+		lb.ExecuteWithFailover(context.Background(), &ChannelSelectOptions{Model: "gpt-4"}, func(ctx context.Context, ch *Channel) error {
+			panic("boom")
+		})
+	}()
+	if active := lb.activeRequests("1"); active != 0 {
+		t.Errorf("expected the slot to be released after a panic, got %d active", active)
+	}
+}
@@ -0,0 +1,151 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 消息内容片段类型
+const (
+	ContentPartText  = "text"
+	ContentPartImage = "image_url"
+)
+
+// ChatContentPart 多模态消息的内容片段（OpenAI 格式），目前支持文本与图片
+type ChatContentPart struct {
+	Type     string        `json:"type"`
+	Text     string        `json:"text,omitempty"`
+	ImageURL *ChatImageURL `json:"image_url,omitempty"`
+}
+
+// ChatImageURL 图片片段：https URL 或 data:<mime>;base64,<data> 形式的内联图片
+type ChatImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"` // auto、low 或 high，影响 OpenAI 的图片计费
+}
+
+// chatMessageJSON ChatMessage 的默认 JSON 编码，Content 由 UnmarshalJSON/MarshalJSON 单独处理
+type chatMessageJSON ChatMessage
+
+// UnmarshalJSON content 可以是字符串或内容片段数组
+//
+// 片段数组保存在 Parts 中，Content 为其中文本片段以换行拼接的结果，只处理文本的逻辑（日志、知识库检索等）无需区分两种格式。
+func (m *ChatMessage) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		*chatMessageJSON
+		Content json.RawMessage `json:"content"`
+	}{chatMessageJSON: (*chatMessageJSON)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	m.Content, m.Parts = "", nil
+	content := bytes.TrimSpace(aux.Content)
+	if len(content) == 0 || bytes.Equal(content, []byte("null")) {
+		return nil
+	}
+	if content[0] != '[' {
+		if err := json.Unmarshal(content, &m.Content); err != nil {
+			return fmt.Errorf("content must be a string or an array of content parts")
+		}
+		return nil
+	}
+
+	var parts []ChatContentPart
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return fmt.Errorf("content must be a string or an array of content parts: %w", err)
+	}
+	for i, part := range parts {
+		switch part.Type {
+		case ContentPartText:
+		case ContentPartImage:
+			if part.ImageURL == nil || part.ImageURL.URL == "" {
+				return fmt.Errorf("content.%d.image_url.url is required", i)
+			}
+		default:
+			return fmt.Errorf("content.%d: unsupported content part type %q", i, part.Type)
+		}
+	}
+	m.Parts = parts
+	m.Content = partsText(parts)
+	return nil
+}
+
+// MarshalJSON 带内容片段的消息输出片段数组，其余输出字符串
+func (m ChatMessage) MarshalJSON() ([]byte, error) {
+	aux := struct {
+		chatMessageJSON
+		Content interface{} `json:"content"`
+	}{chatMessageJSON: chatMessageJSON(m), Content: m.Content}
+	if len(m.Parts) > 0 {
+		aux.Content = m.Parts
+	}
+	return json.Marshal(aux)
+}
+
+// HasImages 消息是否包含图片片段
+func (m *ChatMessage) HasImages() bool {
+	for _, part := range m.Parts {
+		if part.Type == ContentPartImage {
+			return true
+		}
+	}
+	return false
+}
+
+// AppendText 在消息末尾追加文本：带内容片段的消息追加一个文本片段，否则以空行连接
+func (m *ChatMessage) AppendText(text string) {
+	if len(m.Parts) > 0 {
+		m.Parts = append(m.Parts, ChatContentPart{Type: ContentPartText, Text: text})
+		m.Content = partsText(m.Parts)
+		return
+	}
+	if m.Content != "" {
+		m.Content += "\n\n"
+	}
+	m.Content += text
+}
+
+// OpenAIContent 适配器使用的消息内容：纯文本为字符串，带内容片段时为 OpenAI 格式的片段数组
+func (m *ChatMessage) OpenAIContent() interface{} {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+	parts := make([]interface{}, 0, len(m.Parts))
+	for _, part := range m.Parts {
+		switch part.Type {
+		case ContentPartText:
+			parts = append(parts, map[string]interface{}{"type": ContentPartText, "text": part.Text})
+		case ContentPartImage:
+			image := map[string]interface{}{"url": part.ImageURL.URL}
+			if part.ImageURL.Detail != "" {
+				image["detail"] = part.ImageURL.Detail
+			}
+			parts = append(parts, map[string]interface{}{"type": ContentPartImage, "image_url": image})
+		}
+	}
+	return parts
+}
+
+// partsText 以换行拼接文本片段
+func partsText(parts []ChatContentPart) string {
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type == ContentPartText {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// HasImages 请求中是否有消息包含图片
+func HasImages(req *ChatCompletionRequest) bool {
+	for i := range req.Messages {
+		if req.Messages[i].HasImages() {
+			return true
+		}
+	}
+	return false
+}
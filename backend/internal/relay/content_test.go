@@ -0,0 +1,71 @@
+package relay
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChatMessageContentParts(t *testing.T) {
+	var req ChatCompletionRequest
+	body := `{"model":"gpt-4o","messages":[
+		{"role":"system","content":"Be brief."},
+		{"role":"user","content":[{"type":"text","text":"What is this?"},{"type":"image_url","image_url":{"url":"https://example.com/cat.png","detail":"high"}},{"type":"text","text":"And this?"}]},
+		{"role":"assistant","content":null}]}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if req.Messages[0].Content != "Be brief." || req.Messages[0].Parts != nil {
+		t.Errorf("Expected plain string content, got %+v", req.Messages[0])
+	}
+	user := req.Messages[1]
+	if user.Content != "What is this?\nAnd this?" {
+		t.Errorf("Expected text parts joined by newline, got %q", user.Content)
+	}
+	if len(user.Parts) != 3 || !user.HasImages() || !HasImages(&req) {
+		t.Fatalf("Expected three parts with an image, got %+v", user.Parts)
+	}
+	if features := ChatAbilityFeatures(&req); !features[FeatureVision] {
+		t.Errorf("Expected the request to require vision, got %v", features)
+	}
+
+	want := []interface{}{
+		map[string]interface{}{"type": "text", "text": "What is this?"},
+		map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "https://example.com/cat.png", "detail": "high"}},
+		map[string]interface{}{"type": "text", "text": "And this?"},
+	}
+	if got := user.OpenAIContent(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected OpenAI content %#v, got %#v", want, got)
+	}
+
+	// 序列化后仍是片段数组，纯文本消息仍是字符串
+	raw, err := json.Marshal(req.Messages)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(raw), `"content":[{"type":"text","text":"What is this?"},{"type":"image_url"`) ||
+		!strings.Contains(string(raw), `"content":"Be brief."`) {
+		t.Errorf("Unexpected JSON %s", raw)
+	}
+
+	// 追加知识库上下文时保留图片
+	user.AppendText("Context")
+	if len(user.Parts) != 4 || !strings.HasSuffix(user.Content, "\nContext") {
+		t.Errorf("Expected a text part to be appended, got %+v", user)
+	}
+}
+
+func TestChatMessageContentPartsInvalid(t *testing.T) {
+	for _, content := range []string{
+		`[{"type":"input_audio","input_audio":{"data":"AAA=","format":"wav"}}]`,
+		`[{"type":"image_url","image_url":{}}]`,
+		`42`,
+	} {
+		var m ChatMessage
+		if err := json.Unmarshal([]byte(`{"role":"user","content":`+content+`}`), &m); err == nil {
+			t.Errorf("Expected content %s to be rejected", content)
+		}
+	}
+}
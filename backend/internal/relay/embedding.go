@@ -0,0 +1,72 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// EmbeddingInput Embedding 输入，兼容 OpenAI 的单个字符串与字符串数组两种写法
+type EmbeddingInput []string
+
+// UnmarshalJSON 同时接受 "text" 与 ["a", "b"]
+func (in *EmbeddingInput) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*in = EmbeddingInput{single}
+		return nil
+	}
+
+	var batch []string
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return fmt.Errorf("input must be a string or an array of strings")
+	}
+	*in = batch
+	return nil
+}
+
+// EmbeddingRequest OpenAI 兼容的 Embedding 请求
+type EmbeddingRequest struct {
+	Model          string         `json:"model"`
+	Input          EmbeddingInput `json:"input"`
+	EncodingFormat string         `json:"encoding_format,omitempty"`
+	Dimensions     int            `json:"dimensions,omitempty"`
+	User           string         `json:"user,omitempty"`
+}
+
+// Validate 校验必填字段，空输入与空字符串都会被上游拒绝，提前返回 400
+func (r *EmbeddingRequest) Validate() error {
+	if r.Model == "" {
+		return ErrInvalidRequest{Message: "model is required"}
+	}
+	if len(r.Input) == 0 {
+		return ErrInvalidRequest{Message: "input must not be empty"}
+	}
+	for i, text := range r.Input {
+		if strings.TrimSpace(text) == "" {
+			return ErrInvalidRequest{Message: fmt.Sprintf("input[%d] must not be empty", i)}
+		}
+	}
+	return nil
+}
+
+// EmbeddingData 单条输入的向量
+type EmbeddingData struct {
+	Object    string    `json:"object"`
+	Embedding []float64 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+// EmbeddingUsage Embedding 使用量
+type EmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// EmbeddingResponse OpenAI 兼容的 Embedding 响应
+type EmbeddingResponse struct {
+	Object string          `json:"object"`
+	Data   []EmbeddingData `json:"data"`
+	Model  string          `json:"model"`
+	Usage  EmbeddingUsage  `json:"usage"`
+}
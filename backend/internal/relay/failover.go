@@ -0,0 +1,287 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// FailoverError 所有候选渠道均失败，Attempted 按尝试顺序记录渠道 ID
+type FailoverError struct {
+	Attempted []string
+	Err       error // 最后一次尝试的错误
+}
+
+// Error 实现 error 接口
+func (e *FailoverError) Error() string {
+	return fmt.Sprintf("all %d channel attempts failed (attempted channels: %s): %v",
+		len(e.Attempted), strings.Join(e.Attempted, ", "), e.Err)
+}
+
+// Unwrap 返回最后一次尝试的错误，便于调用方识别 UpstreamError
+func (e *FailoverError) Unwrap() error {
+	return e.Err
+}
+
+// StreamInterruptedError 流式响应中途被上游中断
+//
+// Partial 为 true 表示已经向客户端输出了内容，此时只按已输出的 Token 计费，
+// 且不再切换渠道；尚未输出任何内容时可以像普通上游错误一样切换渠道。
+type StreamInterruptedError struct {
+	Class           string // 错误类型，如 overloaded、connection_error
+	Message         string
+	Partial         bool
+	DeliveredTokens int // 已输出给客户端的 Token 数
+	PromptTokens    int // 上游已报告的输入 Token 数，未报告时为 0
+}
+
+// Error 实现 error 接口
+func (e *StreamInterruptedError) Error() string {
+	if e.Partial {
+		return fmt.Sprintf("stream interrupted after %d tokens (%s): %s", e.DeliveredTokens, e.Class, e.Message)
+	}
+	return fmt.Sprintf("stream interrupted (%s): %s", e.Class, e.Message)
+}
+
+// ClientCancelledError 客户端在响应完成前断开（或请求被取消），上游请求随之中断
+//
+// 只按取消前已收到的 Token 计费，统一日志记为 cancelled 而不是错误。
+type ClientCancelledError struct {
+	DeliveredTokens int   // 取消前已输出给客户端的 Token 数
+	PromptTokens    int   // 已开始输出时计费的输入 Token 数，尚未输出时为 0
+	Cause           error // 取消原因，如 context.Canceled 或写入客户端失败
+}
+
+// Error 实现 error 接口
+func (e *ClientCancelledError) Error() string {
+	return fmt.Sprintf("client cancelled after %d tokens: %v", e.DeliveredTokens, e.Cause)
+}
+
+// Unwrap 返回取消原因
+func (e *ClientCancelledError) Unwrap() error {
+	return e.Cause
+}
+
+// noFailoverError 标记不应切换渠道的错误
+type noFailoverError struct {
+	err error
+}
+
+func (e *noFailoverError) Error() string { return e.err.Error() }
+func (e *noFailoverError) Unwrap() error { return e.err }
+
+// NoFailover 标记错误不触发故障转移，例如流式响应已经开始向客户端输出
+func NoFailover(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &noFailoverError{err: err}
+}
+
+// ShouldFailover 判断错误是否应切换到其它渠道重试
+//
+// 上游 429、408 与 5xx、网络错误及单次请求超时会切换渠道；
+// 其余 4xx（如 400、401、413）与请求本身有关，换渠道也无济于事。
+func ShouldFailover(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var noFailover *noFailoverError
+	if errors.As(err, &noFailover) {
+		return false
+	}
+
+	var interrupted *StreamInterruptedError
+	if errors.As(err, &interrupted) {
+		return !interrupted.Partial
+	}
+
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.StatusCode == http.StatusTooManyRequests ||
+			upstreamErr.StatusCode == http.StatusRequestTimeout ||
+			upstreamErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// isChannelFailure 错误是否计入渠道的失败统计（断路器）
+//
+// 可故障转移的错误以及鉴权失败（渠道密钥失效）计入；请求本身的问题不计入。
+func isChannelFailure(err error) bool {
+	if ShouldFailover(err) {
+		return true
+	}
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.StatusCode == http.StatusUnauthorized || upstreamErr.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// ExecuteWithFailover 选择渠道执行 attempt，可重试的失败会排除已尝试的渠道后重新选择，
+// 最多重试 MaxRetries 次。每次尝试的结果都会通过 RecordKeyRequest 计入渠道指标、断路器
+// 以及多密钥渠道中本次使用的密钥。
+//
+// 每次尝试前占用渠道的并发槽位：并发已满的渠道被跳过（不计入失败与重试次数），没有其它可用渠道时
+// 在最先跳过的渠道上排队最多 ChannelQueueTimeout，仍未获得槽位时返回 ChannelBusyError。
+// 槽位在 attempt 返回、panic 或客户端取消后都会释放。
+func (lb *LoadBalancer) ExecuteWithFailover(ctx context.Context, options *ChannelSelectOptions, attempt func(ctx context.Context, ch *Channel) error) error {
+	opts := *options
+	opts.ExcludeIDs = append([]string(nil), options.ExcludeIDs...)
+
+	attempted := make([]string, 0, lb.config.MaxRetries+1)
+	var lastErr error
+	var busy *Channel // 因并发已满而跳过的第一个渠道
+
+	for len(attempted) <= lb.config.MaxRetries {
+		_, span := tracing.Start(ctx, "relay.select_channel", attribute.String("model", opts.Model), attribute.Int("attempt", len(attempted)+1))
+		ch, err := lb.SelectChannel(&opts)
+		if err == nil {
+			span.SetAttributes(attribute.String("channel_id", ch.ID))
+		}
+		tracing.End(span, err)
+
+		var release func()
+		switch {
+		case err == nil:
+			if release, err = lb.acquireSlot(ctx, ch, 0); err != nil {
+				if busy == nil {
+					busy = ch
+				}
+				opts.ExcludeIDs = append(opts.ExcludeIDs, ch.ID)
+				continue
+			}
+		case busy != nil:
+			// 其它渠道都不可用，在并发已满的渠道上排队等待
+			ch, busy = busy, nil
+			if release, err = lb.acquireSlot(ctx, ch, lb.config.ChannelQueueTimeout); err != nil {
+				if lastErr != nil {
+					return &FailoverError{Attempted: attempted, Err: lastErr}
+				}
+				return err
+			}
+		case lastErr == nil:
+			return fmt.Errorf("failed to select channel: %w", err)
+		default:
+			// 没有其它可用渠道
+			return &FailoverError{Attempted: attempted, Err: lastErr}
+		}
+
+		attempted = append(attempted, ch.ID)
+		opts.ExcludeIDs = append(opts.ExcludeIDs, ch.ID)
+
+		// 多密钥渠道按轮询选择本次使用的密钥，结果计入该密钥
+		attemptCtx := ctx
+		key, pooled := lb.selectKey(ch.ID)
+		if pooled {
+			attemptCtx = WithChannelKey(ctx, key)
+		}
+
+		start := time.Now()
+		err = runAttempt(attemptCtx, ch, release, attempt)
+
+		if err == nil {
+			_ = lb.RecordKeyRequest(ch.ID, key.Index, nil, time.Since(start).Milliseconds())
+			return nil
+		}
+		var interrupted *StreamInterruptedError
+		if errors.As(err, &interrupted) && interrupted.Partial {
+			_ = lb.RecordPartialFailure(ch.ID)
+		} else {
+			_ = lb.RecordKeyRequest(ch.ID, key.Index, err, 0)
+		}
+
+		lastErr = err
+		// 客户端已取消或整体超时时不再切换渠道
+		if !ShouldFailover(err) || ctx.Err() != nil {
+			return err
+		}
+
+		lb.logFunc("warn", fmt.Sprintf("channel %s failed, failing over: %v", ch.ID, err))
+		if lb.config.RetryInterval > 0 && len(attempted) <= lb.config.MaxRetries {
+			select {
+			case <-ctx.Done():
+				return &FailoverError{Attempted: attempted, Err: lastErr}
+			case <-time.After(lb.config.RetryInterval):
+			}
+		}
+	}
+
+	return &FailoverError{Attempted: attempted, Err: lastErr}
+}
+
+// runAttempt 在已占用的并发槽位中执行一次尝试，attempt 返回或 panic 时都释放槽位
+func runAttempt(ctx context.Context, ch *Channel, release func(), attempt func(ctx context.Context, ch *Channel) error) error {
+	ch.RecordConcurrency(1)
+	defer func() {
+		ch.RecordConcurrency(-1)
+		release()
+	}()
+	return attempt(ctx, ch)
+}
+
+// ErrorClass 将中转错误归类，用于日志与实时跟踪（不包含任何请求内容）
+func ErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var interrupted *StreamInterruptedError
+	if errors.As(err, &interrupted) {
+		return interrupted.Class
+	}
+
+	var cancelled *ClientCancelledError
+	if errors.As(err, &cancelled) {
+		return "canceled"
+	}
+
+	var invalidJSON *InvalidJSONError
+	if errors.As(err, &invalidJSON) {
+		return "invalid_json"
+	}
+
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		switch {
+		case upstreamErr.StatusCode == http.StatusTooManyRequests:
+			return "rate_limited"
+		case upstreamErr.StatusCode == http.StatusUnauthorized || upstreamErr.StatusCode == http.StatusForbidden:
+			return "auth_error"
+		case upstreamErr.StatusCode == http.StatusRequestTimeout || upstreamErr.StatusCode == http.StatusGatewayTimeout:
+			return "timeout"
+		case upstreamErr.StatusCode >= http.StatusInternalServerError:
+			return "upstream_error"
+		default:
+			return "invalid_request"
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return "connection_error"
+	}
+	return "internal_error"
+}
@@ -0,0 +1,241 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeAdapter 模拟上游：前 failures 次调用返回 err，之后成功
+type fakeAdapter struct {
+	err      error
+	failures int
+	calls    []string
+}
+
+func (f *fakeAdapter) Do(ctx context.Context, ch *Channel) error {
+	f.calls = append(f.calls, ch.ID)
+	if len(f.calls) <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+// timeoutError 模拟 HTTP 客户端超时
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func newFailoverTestBalancer(n, maxRetries int) *LoadBalancer {
+	cache := NewChannelCache(ChannelCacheLevelMemory)
+	for i := 1; i <= n; i++ {
+		ch := NewChannel(fmt.Sprintf("%d", i), fmt.Sprintf("Channel %d", i), "https://api.test.com", "openai")
+		ch.Ability.SupportedModels = []string{"gpt-4"}
+		cache.AddChannel(ch)
+	}
+
+	config := DefaultLoadBalancerConfig()
+	config.EnableHealthCheck = false
+	config.EnableAdaptiveWeight = false
+	config.MaxRetries = maxRetries
+	config.RetryInterval = 0
+	return NewLoadBalancer(cache, config)
+}
+
+func TestExecuteWithFailover(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantAttempts int
+		wantErr      bool
+		wantFailure  bool // 第一个渠道是否计入失败
+	}{
+		{"503 fails over", &UpstreamError{StatusCode: 503, Message: "overloaded"}, 2, false, true},
+		{"500 fails over", &UpstreamError{StatusCode: 500, Message: "internal"}, 2, false, true},
+		{"429 fails over", &UpstreamError{StatusCode: 429, Message: "rate limited"}, 2, false, true},
+		{"timeout fails over", fmt.Errorf("upstream request failed: %w", timeoutError{}), 2, false, true},
+		{"deadline fails over", fmt.Errorf("upstream request failed: %w", context.DeadlineExceeded), 2, false, true},
+		{"400 does not fail over", &UpstreamError{StatusCode: 400, Message: "bad request"}, 1, true, false},
+		{"401 does not fail over", &UpstreamError{StatusCode: 401, Message: "invalid key"}, 1, true, true},
+		{"413 does not fail over", &UpstreamError{StatusCode: 413, Message: "too large"}, 1, true, false},
+		{"stream already started", NoFailover(errors.New("broken pipe")), 1, true, false},
+		{"stream interrupted before output fails over", &StreamInterruptedError{Class: "overloaded"}, 2, false, true},
+		{"partial stream does not fail over", &StreamInterruptedError{Class: "overloaded", Partial: true, DeliveredTokens: 12}, 1, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := newFailoverTestBalancer(3, 2)
+			upstream := &fakeAdapter{err: tt.err, failures: 1}
+
+			err := lb.ExecuteWithFailover(context.Background(), &ChannelSelectOptions{Model: "gpt-4"}, upstream.Do)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(upstream.calls) != tt.wantAttempts {
+				t.Fatalf("expected %d attempts, got %v", tt.wantAttempts, upstream.calls)
+			}
+			if tt.wantAttempts == 2 && upstream.calls[0] == upstream.calls[1] {
+				t.Errorf("failover retried the same channel %s", upstream.calls[0])
+			}
+
+			first, _ := lb.cache.GetChannel(upstream.calls[0])
+			failed := atomic.LoadInt64(&first.Metrics.FailedRequests) == 1
+			if failed != tt.wantFailure {
+				t.Errorf("expected failure recorded=%v on channel %s", tt.wantFailure, first.ID)
+			}
+			if !tt.wantErr {
+				last, _ := lb.cache.GetChannel(upstream.calls[len(upstream.calls)-1])
+				if atomic.LoadInt64(&last.Metrics.SuccessfulRequests) != 1 {
+					t.Errorf("expected success recorded on channel %s", last.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteWithFailoverBoundedByMaxRetries(t *testing.T) {
+	lb := newFailoverTestBalancer(5, 2)
+	upstream := &fakeAdapter{err: &UpstreamError{StatusCode: 502, Message: "bad gateway"}, failures: 100}
+
+	err := lb.ExecuteWithFailover(context.Background(), &ChannelSelectOptions{Model: "gpt-4"}, upstream.Do)
+
+	var failoverErr *FailoverError
+	if !errors.As(err, &failoverErr) {
+		t.Fatalf("expected FailoverError, got %v", err)
+	}
+	if len(upstream.calls) != 3 {
+		t.Fatalf("expected 1 attempt + 2 retries, got %v", upstream.calls)
+	}
+	seen := make(map[string]bool)
+	for _, id := range upstream.calls {
+		if seen[id] {
+			t.Errorf("channel %s attempted twice", id)
+		}
+		seen[id] = true
+	}
+	for _, id := range upstream.calls {
+		if !strings.Contains(err.Error(), id) {
+			t.Errorf("error %q does not list attempted channel %s", err.Error(), id)
+		}
+	}
+
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != 502 {
+		t.Errorf("expected last upstream error to be unwrappable, got %v", err)
+	}
+}
+
+func TestExecuteWithFailoverRunsOutOfChannels(t *testing.T) {
+	lb := newFailoverTestBalancer(2, 5)
+	upstream := &fakeAdapter{err: &UpstreamError{StatusCode: 503, Message: "overloaded"}, failures: 100}
+
+	err := lb.ExecuteWithFailover(context.Background(), &ChannelSelectOptions{Model: "gpt-4"}, upstream.Do)
+
+	var failoverErr *FailoverError
+	if !errors.As(err, &failoverErr) {
+		t.Fatalf("expected FailoverError, got %v", err)
+	}
+	if len(failoverErr.Attempted) != 2 || len(upstream.calls) != 2 {
+		t.Errorf("expected both channels attempted once, got %v", upstream.calls)
+	}
+}
+
+func TestExecuteWithFailoverNoChannels(t *testing.T) {
+	lb := newFailoverTestBalancer(1, 2)
+	upstream := &fakeAdapter{}
+
+	err := lb.ExecuteWithFailover(context.Background(), &ChannelSelectOptions{Model: "claude-3"}, upstream.Do)
+	if err == nil || len(upstream.calls) != 0 {
+		t.Fatalf("expected selection error without attempts, got %v (%v)", err, upstream.calls)
+	}
+}
+
+func TestSelectChannelExcludeIDs(t *testing.T) {
+	lb := newFailoverTestBalancer(3, 0)
+
+	for i := 0; i < 50; i++ {
+		ch, err := lb.SelectChannel(&ChannelSelectOptions{Model: "gpt-4", ExcludeIDs: []string{"1", "2"}})
+		if err != nil {
+			t.Fatalf("selection failed: %v", err)
+		}
+		if ch.ID != "3" {
+			t.Fatalf("expected excluded channels to be skipped, got %s", ch.ID)
+		}
+	}
+
+	if _, err := lb.SelectChannel(&ChannelSelectOptions{Model: "gpt-4", ExcludeIDs: []string{"1", "2", "3"}}); err == nil {
+		t.Error("expected error when all channels are excluded")
+	}
+}
+
+func TestRecordPartialFailureWeight(t *testing.T) {
+	lb := newFailoverTestBalancer(1, 0)
+	lb.config.PartialFailureWeight = 0.2
+	lb.config.CircuitBreakerFailureThreshold = 1
+	ch, _ := lb.cache.GetChannel("1")
+
+	// 五次中途中断才相当于一次完整失败
+	for i := 0; i < 4; i++ {
+		if err := lb.RecordPartialFailure("1"); err != nil {
+			t.Fatalf("RecordPartialFailure failed: %v", err)
+		}
+	}
+	if n := atomic.LoadInt64(&ch.Metrics.FailedRequests); n != 0 {
+		t.Fatalf("expected no failure after 4 partial failures, got %d", n)
+	}
+	if !lb.isCircuitBreakerAvailable("1") {
+		t.Fatal("circuit breaker tripped by partial failures below weight 1")
+	}
+
+	_ = lb.RecordPartialFailure("1")
+	if n := atomic.LoadInt64(&ch.Metrics.FailedRequests); n != 1 {
+		t.Errorf("expected 1 failure after 5 partial failures, got %d", n)
+	}
+	if lb.isCircuitBreakerAvailable("1") {
+		t.Error("expected circuit breaker to open once accumulated weight reaches 1")
+	}
+}
+
+func TestResetCircuitBreaker(t *testing.T) {
+	lb := newFailoverTestBalancer(2, 0)
+	lb.config.CircuitBreakerFailureThreshold = 1
+	_ = lb.RecordRequest("1", false, 0)
+
+	states := lb.GetCircuitBreakerStates()
+	if len(states) != 2 {
+		t.Fatalf("Expected a state per channel, got %+v", states)
+	}
+	if states[0].ChannelID != "1" || states[0].State != CircuitOpen || states[0].ChannelName != "Channel 1" || states[0].HalfOpenInMs <= 0 {
+		t.Errorf("Expected channel 1 to be open, got %+v", states[0])
+	}
+	if states[1].ChannelID != "2" || states[1].State != CircuitClosed || states[1].FailureThreshold != 1 {
+		t.Errorf("Expected channel 2 without failures to be closed, got %+v", states[1])
+	}
+
+	if err := lb.ResetCircuitBreaker("1"); err != nil {
+		t.Fatalf("ResetCircuitBreaker failed: %v", err)
+	}
+	if !lb.isCircuitBreakerAvailable("1") {
+		t.Error("Expected channel 1 to be available after reset")
+	}
+	if state := lb.GetCircuitBreakerStates()[0]; state.State != CircuitClosed || state.FailureCount != 0 {
+		t.Errorf("Expected reset breaker to be closed, got %+v", state)
+	}
+
+	// 没有断路器的渠道视为已关闭，不存在的渠道返回错误
+	if err := lb.ResetCircuitBreaker("2"); err != nil {
+		t.Errorf("Expected reset of a closed channel to succeed, got %v", err)
+	}
+	if err := lb.ResetCircuitBreaker("404"); !errors.Is(err, ErrChannelNotFound) {
+		t.Errorf("Expected ErrChannelNotFound, got %v", err)
+	}
+}
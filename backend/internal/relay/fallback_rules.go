@@ -0,0 +1,128 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FallbackRule 模型降级规则：匹配 ModelPattern 的模型没有可用渠道时依次改用 Models
+type FallbackRule struct {
+	ModelPattern string   // 支持 * 通配符，如 gpt-4*
+	UserGroup    string   // 只对该用户分组生效，为空表示所有分组
+	Models       []string // 降级模型，按顺序尝试
+}
+
+// Validate 校验规则
+func (r *FallbackRule) Validate() error {
+	if strings.TrimSpace(r.ModelPattern) == "" {
+		return errors.New("model_pattern is required")
+	}
+	if len(r.Models) == 0 {
+		return errors.New("fallback_models is required")
+	}
+	for _, m := range r.Models {
+		if m == "" || strings.Contains(m, "*") {
+			return fmt.Errorf("invalid fallback model %q", m)
+		}
+		if m == r.ModelPattern {
+			return fmt.Errorf("model %s cannot fall back to itself", m)
+		}
+	}
+	return nil
+}
+
+// FallbackRules 模型降级规则集合，可在运行时整体替换
+//
+// 每个模型只使用优先级最高的一条匹配规则：指定分组的规则优先于所有分组的规则，
+// 精确匹配优先于通配符，通配符中更长（更具体）的优先。降级模型自身也有规则时依次展开，
+// 形成 A→B→C 的降级链，链中重复的模型只尝试一次。
+type FallbackRules struct {
+	mu    sync.RWMutex
+	rules []*FallbackRule
+}
+
+// NewFallbackRules 创建模型降级规则集合
+func NewFallbackRules() *FallbackRules {
+	return &FallbackRules{}
+}
+
+// Set 校验并替换全部规则，校验失败时保留原规则
+func (fr *FallbackRules) Set(rules []*FallbackRule) error {
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("fallback rule %s: %w", r.ModelPattern, err)
+		}
+	}
+
+	sorted := make([]*FallbackRule, len(rules))
+	copy(sorted, rules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return fallbackRuleRank(sorted[i]) > fallbackRuleRank(sorted[j])
+	})
+
+	fr.mu.Lock()
+	fr.rules = sorted
+	fr.mu.Unlock()
+	return nil
+}
+
+// fallbackRuleRank 规则的优先级，数值越大越优先
+func fallbackRuleRank(r *FallbackRule) int {
+	rank := len(r.ModelPattern)
+	if !strings.Contains(r.ModelPattern, "*") {
+		rank += 1 << 16
+	}
+	if r.UserGroup != "" {
+		rank += 1 << 17
+	}
+	return rank
+}
+
+// Rules 当前的规则，按优先级排序
+func (fr *FallbackRules) Rules() []*FallbackRule {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+	return fr.rules
+}
+
+// Resolve 按顺序返回模型的降级链（不含模型本身），没有匹配规则时返回 nil
+func (fr *FallbackRules) Resolve(modelName, group string) []string {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	var chain []string
+	visited := map[string]bool{modelName: true}
+	var expand func(name string)
+	expand = func(name string) {
+		rule := fr.match(name, group)
+		if rule == nil {
+			return
+		}
+		for _, m := range rule.Models {
+			if visited[m] {
+				continue
+			}
+			visited[m] = true
+			chain = append(chain, m)
+			expand(m)
+		}
+	}
+	expand(modelName)
+	return chain
+}
+
+// match 返回优先级最高的匹配规则
+func (fr *FallbackRules) match(modelName, group string) *FallbackRule {
+	for _, r := range fr.rules {
+		if r.UserGroup != "" && r.UserGroup != group {
+			continue
+		}
+		if matchPattern(modelName, r.ModelPattern) {
+			return r
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package relay
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFallbackRulesResolve(t *testing.T) {
+	rules := NewFallbackRules()
+	err := rules.Set([]*FallbackRule{
+		{ModelPattern: "gpt-4*", Models: []string{"gpt-3.5-turbo"}},
+		{ModelPattern: "gpt-4o", Models: []string{"gpt-4o-mini"}},
+		{ModelPattern: "gpt-4o", UserGroup: "vip", Models: []string{"claude-3-5-sonnet"}},
+		{ModelPattern: "gpt-3.5-turbo", Models: []string{"gpt-4o"}},
+	})
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		model, group string
+		want         []string
+	}{
+		// 精确规则优先于通配符，gpt-4o-mini 再经通配符降级，环路中已出现的模型跳过
+		{"gpt-4o", "default", []string{"gpt-4o-mini", "gpt-3.5-turbo"}},
+		{"gpt-4-turbo", "default", []string{"gpt-3.5-turbo", "gpt-4o", "gpt-4o-mini"}},
+		// 指定分组的规则优先
+		{"gpt-4o", "vip", []string{"claude-3-5-sonnet"}},
+		{"claude-3-opus", "default", nil},
+	} {
+		if got := rules.Resolve(tc.model, tc.group); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Resolve(%s, %s) = %v, want %v", tc.model, tc.group, got, tc.want)
+		}
+	}
+
+	// 校验失败时保留原规则
+	if err := rules.Set([]*FallbackRule{{ModelPattern: "gpt-4o"}}); err == nil {
+		t.Error("expected a rule without fallback models to be rejected")
+	}
+	if len(rules.Rules()) != 4 {
+		t.Errorf("expected the previous rules to be kept, got %d", len(rules.Rules()))
+	}
+}
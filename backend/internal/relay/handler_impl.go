@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"net/http"
 )
 
 // ChatHandler Chat 处理器
@@ -141,10 +142,7 @@ func (eh *EmbeddingHandler) Handle(ctx context.Context, req *HandlerRequest) (*H
 
 	if err != nil {
 		eh.RecordFailure()
-		return &HandlerResponse{
-			StatusCode: 500,
-			Error:      err.Error(),
-		}, err
+		return upstreamFailure(respBody, respHeaders, err), err
 	}
 
 	eh.RecordSuccess(int64(len(req.Body)))
@@ -176,6 +174,26 @@ func (eh *EmbeddingHandler) HandleStream(ctx context.Context, req *HandlerReques
 	return ErrUnsupportedType{Type: RequestTypeEmbedding}
 }
 
+// upstreamFailure 请求失败时的响应，保留上游状态码、响应头与错误响应体，由调用方原样返回给客户端
+func upstreamFailure(respBody []byte, respHeaders http.Header, err error) *HandlerResponse {
+	statusCode := 500
+	if retryErr, ok := err.(*RetryableError); ok && retryErr.StatusCode > 0 {
+		statusCode = retryErr.StatusCode
+	}
+	headerMap := make(map[string]string)
+	for k, v := range respHeaders {
+		if len(v) > 0 {
+			headerMap[k] = v[0]
+		}
+	}
+	return &HandlerResponse{
+		StatusCode: statusCode,
+		Body:       respBody,
+		Headers:    headerMap,
+		Error:      err.Error(),
+	}
+}
+
 // ImageHandler Image 处理器
 type ImageHandler struct {
 	*BaseRelayHandler
@@ -214,10 +232,7 @@ func (ih *ImageHandler) Handle(ctx context.Context, req *HandlerRequest) (*Handl
 
 	if err != nil {
 		ih.RecordFailure()
-		return &HandlerResponse{
-			StatusCode: 500,
-			Error:      err.Error(),
-		}, err
+		return upstreamFailure(respBody, respHeaders, err), err
 	}
 
 	ih.RecordSuccess(int64(len(req.Body)))
@@ -280,20 +295,19 @@ func (ah *AudioHandler) Handle(ctx context.Context, req *HandlerRequest) (*Handl
 		headers["Content-Type"] = "application/octet-stream"
 	}
 
-	respBody, respHeaders, err := ah.client.DoRequest(
-		ctx,
-		"POST",
-		req.Endpoint,
-		bytes.NewReader(req.Body),
-		headers,
-	)
+	// 设置了 BodyReader 时（如上传的音频文件）直接转发，不缓存请求体也不重试
+	var respBody []byte
+	var respHeaders http.Header
+	var err error
+	if req.BodyReader != nil {
+		respBody, respHeaders, err = ah.client.DoRequestOnce(ctx, "POST", req.Endpoint, req.BodyReader, headers)
+	} else {
+		respBody, respHeaders, err = ah.client.DoRequest(ctx, "POST", req.Endpoint, bytes.NewReader(req.Body), headers)
+	}
 
 	if err != nil {
 		ah.RecordFailure()
-		return &HandlerResponse{
-			StatusCode: 500,
-			Error:      err.Error(),
-		}, err
+		return upstreamFailure(respBody, respHeaders, err), err
 	}
 
 	ah.RecordSuccess(int64(len(req.Body)))
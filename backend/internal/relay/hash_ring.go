@@ -0,0 +1,96 @@
+package relay
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// DefaultHashRingReplicas 每个渠道在哈希环上的虚拟节点数
+const DefaultHashRingReplicas = 160
+
+// HashRing 带虚拟节点的一致性哈希环
+//
+// 每个渠道在环上放置 replicas 个虚拟节点，键顺时针找到的第一个节点即为其渠道。
+// 增删一个渠道只影响落在该渠道虚拟节点上的键（约 1/n），其余键的归属不变。
+type HashRing struct {
+	replicas int
+	hashes   []uint64          // 已排序的虚拟节点哈希
+	owners   map[uint64]string // 虚拟节点哈希 -> 渠道 ID
+	ids      []string          // 已排序的渠道 ID，用于判断是否需要重建
+}
+
+// NewHashRing 以渠道 ID 创建哈希环，replicas 不大于 0 时使用 DefaultHashRingReplicas
+func NewHashRing(replicas int, ids []string) *HashRing {
+	if replicas <= 0 {
+		replicas = DefaultHashRingReplicas
+	}
+
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+
+	ring := &HashRing{
+		replicas: replicas,
+		hashes:   make([]uint64, 0, len(sorted)*replicas),
+		owners:   make(map[uint64]string, len(sorted)*replicas),
+		ids:      sorted,
+	}
+	for _, id := range sorted {
+		for i := 0; i < replicas; i++ {
+			h := ringHash(id + "#" + strconv.Itoa(i))
+			// 哈希冲突时保留 ID 较小的渠道，保证与添加顺序无关
+			if _, ok := ring.owners[h]; ok {
+				continue
+			}
+			ring.owners[h] = id
+			ring.hashes = append(ring.hashes, h)
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+
+	return ring
+}
+
+// Get 返回键对应的渠道 ID：从键的位置顺时针查找第一个 accept 接受的渠道
+// accept 为 nil 时接受任意渠道；环为空或没有渠道被接受时返回空字符串
+func (r *HashRing) Get(key string, accept func(id string) bool) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+
+	h := ringHash(key)
+	start := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	for i := 0; i < len(r.hashes); i++ {
+		id := r.owners[r.hashes[(start+i)%len(r.hashes)]]
+		if accept == nil || accept(id) {
+			return id
+		}
+	}
+	return ""
+}
+
+// sameChannels 环上的渠道是否与 ids 相同（ids 需已排序）
+func (r *HashRing) sameChannels(ids []string) bool {
+	if len(r.ids) != len(ids) {
+		return false
+	}
+	for i := range ids {
+		if r.ids[i] != ids[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ringHash FNV-1a 后再做一次 64 位混合，使相近的虚拟节点名在环上均匀分布
+func ringHash(s string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(s))
+	h := f.Sum64()
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,157 @@
+package relay
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// ringChannelIDs 生成 n 个渠道 ID
+func ringChannelIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%d", i+1)
+	}
+	return ids
+}
+
+func TestHashRingRemovalOnlyRemapsRemovedChannel(t *testing.T) {
+	ids := ringChannelIDs(10)
+	before := NewHashRing(0, ids)
+	after := NewHashRing(0, ids[1:])
+
+	counts := make(map[string]int)
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("session-%d", i)
+		owner := before.Get(key, nil)
+		counts[owner]++
+		if owner == ids[0] {
+			moved++
+			continue
+		}
+		if got := after.Get(key, nil); got != owner {
+			t.Fatalf("Expected key %s to stay on channel %s after removing channel %s, got %s", key, owner, ids[0], got)
+		}
+	}
+
+	// 虚拟节点使键大致均匀地分布在各渠道上
+	for _, id := range ids {
+		if counts[id] < 500 || counts[id] > 1600 {
+			t.Errorf("Expected roughly 1000 keys on channel %s, got %d", id, counts[id])
+		}
+	}
+	if moved == 0 || moved > 2000 {
+		t.Errorf("Expected about a tenth of the keys to move, got %d", moved)
+	}
+}
+
+func TestHashRingAcceptSkipsToNextChannel(t *testing.T) {
+	ring := NewHashRing(0, ringChannelIDs(5))
+	key := "session-42"
+	owner := ring.Get(key, nil)
+
+	next := ring.Get(key, func(id string) bool { return id != owner })
+	if next == "" || next == owner {
+		t.Fatalf("Expected another channel when %s is not accepted, got %q", owner, next)
+	}
+	// 与构造顺序无关
+	reversed := ringChannelIDs(5)
+	sort.Sort(sort.Reverse(sort.StringSlice(reversed)))
+	if got := NewHashRing(0, reversed).Get(key, nil); got != owner {
+		t.Errorf("Expected ring to be independent of channel order, got %s want %s", got, owner)
+	}
+	if got := ring.Get(key, func(string) bool { return false }); got != "" {
+		t.Errorf("Expected no channel when none is accepted, got %s", got)
+	}
+	if got := NewHashRing(0, nil).Get(key, nil); got != "" {
+		t.Errorf("Expected empty ring to return no channel, got %s", got)
+	}
+}
+
+func TestLoadBalancerConsistentHashStickySessions(t *testing.T) {
+	lb := newFailoverTestBalancer(5, 0)
+	lb.config.Strategy = LBStrategyConsistentHash
+
+	// 同一模型下不同会话分散到多个渠道，同一会话始终选择同一渠道
+	owners := make(map[string]string)
+	used := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("session:%d", i)
+		ch, err := lb.SelectChannel(&ChannelSelectOptions{Model: "gpt-4", HashKey: key})
+		if err != nil {
+			t.Fatalf("SelectChannel failed: %v", err)
+		}
+		owners[key] = ch.ID
+		used[ch.ID] = true
+	}
+	if len(used) < 3 {
+		t.Errorf("Expected sessions on the same model to spread across channels, got %v", used)
+	}
+
+	// 移除一个渠道后环随缓存重建，其余会话保持原渠道
+	removed := owners["session:0"]
+	if err := lb.cache.RemoveChannel(removed); err != nil {
+		t.Fatal(err)
+	}
+	for key, owner := range owners {
+		ch, err := lb.SelectChannel(&ChannelSelectOptions{Model: "gpt-4", HashKey: key})
+		if err != nil {
+			t.Fatalf("SelectChannel failed: %v", err)
+		}
+		if owner != removed && ch.ID != owner {
+			t.Errorf("Expected %s to stay on channel %s, got %s", key, owner, ch.ID)
+		}
+		if ch.ID == removed {
+			t.Errorf("Expected removed channel %s not to be selected", removed)
+		}
+	}
+
+	// 排除（如故障转移）也只影响该渠道上的会话
+	for key, owner := range owners {
+		if owner == removed {
+			continue
+		}
+		ch, _ := lb.SelectChannel(&ChannelSelectOptions{Model: "gpt-4", HashKey: key, ExcludeIDs: []string{owner}})
+		if ch.ID == owner {
+			t.Errorf("Expected excluded channel %s to be skipped", owner)
+		}
+		break
+	}
+}
+
+// BenchmarkConsistentHashRemap 比较移除一个渠道后键的重新映射比例：
+// 取模（旧实现）几乎重排所有键，哈希环只移动被移除渠道上的键
+func BenchmarkConsistentHashRemap(b *testing.B) {
+	const channels, keys = 10, 10000
+	ids := ringChannelIDs(channels)
+
+	b.Run("modulo", func(b *testing.B) {
+		var remapped int
+		for n := 0; n < b.N; n++ {
+			remapped = 0
+			for i := 0; i < keys; i++ {
+				h := ringHash(fmt.Sprintf("session-%d", i))
+				if ids[h%uint64(len(ids))] != ids[1:][h%uint64(len(ids)-1)] {
+					remapped++
+				}
+			}
+		}
+		b.ReportMetric(float64(remapped)/keys*100, "remap%")
+	})
+
+	b.Run("ring", func(b *testing.B) {
+		var remapped int
+		for n := 0; n < b.N; n++ {
+			before, after := NewHashRing(0, ids), NewHashRing(0, ids[1:])
+			remapped = 0
+			for i := 0; i < keys; i++ {
+				key := fmt.Sprintf("session-%d", i)
+				if before.Get(key, nil) != after.Get(key, nil) {
+					remapped++
+				}
+			}
+		}
+		b.ReportMetric(float64(remapped)/keys*100, "remap%")
+	})
+}
@@ -1,8 +1,11 @@
 package relay
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -31,8 +34,79 @@ type HealthCheckConfig struct {
 	// 恢复检查间隔（用于不可用的渠道）
 	RecoveryInterval time.Duration
 
-	// 健康检查端点
+	// 健康检查端点（没有对应探测定义的渠道类型使用，不携带密钥）
 	HealthCheckEndpoint string
+
+	// 按渠道类型的探测定义，键为小写的渠道类型
+	Probes map[string]*HealthProbe
+
+	// 每个渠道保留的最近检查结果数
+	HistorySize int
+}
+
+// HealthProbe 渠道类型的健康探测定义
+//
+// 优先使用提供方的模型列表接口（携带渠道密钥），没有列表接口的提供方发送一个只生成 1 个 token 的补全请求。
+type HealthProbe struct {
+	// 请求方法，为空时使用 GET
+	Method string
+
+	// 相对于渠道 BaseURL 的路径，可带查询参数
+	Path string
+
+	// 为 true 时 Path 相对于去掉末尾 /v1 的服务根地址（自托管服务的原生接口）
+	Root bool
+
+	// 请求体（POST 探测），其中的 "{model}" 替换为渠道支持的第一个模型
+	Body string
+
+	// 携带渠道密钥的请求头及值前缀，AuthHeader 为空时不携带密钥
+	AuthHeader string
+	AuthPrefix string
+
+	// 其它固定请求头
+	Headers map[string]string
+}
+
+// 健康检查失败原因
+const (
+	HealthReasonInvalidCredentials = "invalid_credentials" // 上游返回 401/403，密钥无效或无权限
+	HealthReasonNetwork            = "network_error"       // 连接失败或超时
+	HealthReasonHTTPStatus         = "http_error"          // 上游返回其它非 2xx 状态码
+)
+
+// probeModelPlaceholder 探测请求体中的模型占位符
+const probeModelPlaceholder = `"{model}"`
+
+// DefaultHealthProbes 已有适配器类型的默认探测定义
+func DefaultHealthProbes() map[string]*HealthProbe {
+	bearer := func(path string) *HealthProbe {
+		return &HealthProbe{Path: path, AuthHeader: "Authorization", AuthPrefix: "Bearer "}
+	}
+	anthropic := &HealthProbe{Path: "/models", AuthHeader: "x-api-key",
+		Headers: map[string]string{"anthropic-version": "2023-06-01"}}
+	gemini := &HealthProbe{Path: "/models", AuthHeader: "x-goog-api-key"}
+	// 百度千帆没有 OpenAI 兼容的模型列表接口，发送 1 个 token 的补全
+	baidu := &HealthProbe{Method: http.MethodPost, Path: "/chat/completions", AuthHeader: "Authorization", AuthPrefix: "Bearer ",
+		Body: `{"model":"{model}","messages":[{"role":"user","content":"ping"}],"max_tokens":1}`}
+	selfHosted := bearer("/v1/models")
+	selfHosted.Root = true
+
+	return map[string]*HealthProbe{
+		"openai":    bearer("/models"),
+		"qwen":      bearer("/models"),
+		"tongyi":    bearer("/models"),
+		"anthropic": anthropic,
+		"claude":    anthropic,
+		"google":    gemini,
+		"gemini":    gemini,
+		"baidu":     baidu,
+		"wenxin":    baidu,
+		"azure":     {Path: "/openai/models?api-version=2024-10-21", AuthHeader: "api-key"},
+		"ollama":    {Path: "/api/tags", Root: true},
+		"vllm":      selfHosted,
+		"lmstudio":  selfHosted,
+	}
 }
 
 // DefaultHealthCheckConfig 默认配置
@@ -46,31 +120,47 @@ func DefaultHealthCheckConfig() *HealthCheckConfig {
 		MaxConsecutiveFailures: 5,
 		RecoveryInterval:       1 * time.Minute,
 		HealthCheckEndpoint:    "/health",
+		Probes:                 DefaultHealthProbes(),
+		HistorySize:            20,
 	}
 }
 
 // HealthCheckResult 健康检查结果
 type HealthCheckResult struct {
 	// 渠道 ID
-	ChannelID string
+	ChannelID string `json:"channel_id"`
 
 	// 检查时间
-	CheckTime time.Time
+	CheckTime time.Time `json:"check_time"`
 
 	// 是否健康
-	Healthy bool
+	Healthy bool `json:"healthy"`
 
 	// 成功率
-	SuccessRate float64
+	SuccessRate float64 `json:"success_rate"`
 
 	// 延迟（毫秒）
-	Latency int64
+	Latency int64 `json:"latency_ms"`
 
 	// 错误信息
-	Error string
+	Error string `json:"error,omitempty"`
+
+	// 失败原因，见 HealthReason*
+	Reason string `json:"reason,omitempty"`
 
 	// 状态
-	Status ChannelStatus
+	Status ChannelStatus `json:"status"`
+}
+
+// ChannelHealth 渠道的健康检查状态与最近的检查结果
+type ChannelHealth struct {
+	ChannelID           string              `json:"channel_id"`
+	Status              ChannelStatus       `json:"status"`
+	ConsecutiveFailures int64               `json:"consecutive_failures"`
+	RecoveryMode        bool                `json:"recovery_mode"`
+	LastCheckTime       *time.Time          `json:"last_check_time,omitempty"`
+	LastReason          string              `json:"last_reason,omitempty"`
+	History             []HealthCheckResult `json:"history"`
 }
 
 // HealthChecker 健康检查器
@@ -88,6 +178,10 @@ type HealthChecker struct {
 	checkStates map[string]*channelCheckState
 	statesMu    sync.RWMutex
 
+	// 探测间隔（纳秒），可通过 SetInterval 在运行中修改
+	interval   atomic.Int64
+	intervalCh chan time.Duration
+
 	// 停止信号
 	stopCh chan struct{}
 
@@ -121,6 +215,14 @@ type channelCheckState struct {
 	// 上次状态
 	lastStatus ChannelStatus
 
+	// 上次失败原因，见 HealthReason*
+	lastReason string
+
+	// 最近的检查结果（环形缓冲区），historyNext 为下一个写入位置
+	history     []HealthCheckResult
+	historyNext int
+	checked     bool
+
 	// 互斥锁
 	mu sync.RWMutex
 }
@@ -131,15 +233,37 @@ func NewHealthChecker(cache *ChannelCache, config *HealthCheckConfig) *HealthChe
 		config = DefaultHealthCheckConfig()
 	}
 
-	return &HealthChecker{
+	hc := &HealthChecker{
 		cache:          cache,
 		config:         config,
 		httpClient:     &http.Client{Timeout: config.Timeout},
 		checkStates:    make(map[string]*channelCheckState),
+		intervalCh:     make(chan time.Duration, 1),
 		stopCh:         make(chan struct{}),
 		logFunc:        defaultLogFunc,
 		resultCallback: nil,
 	}
+	hc.interval.Store(int64(config.Interval))
+	return hc
+}
+
+// SetInterval 修改探测间隔，从下一次探测起生效
+func (hc *HealthChecker) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	hc.interval.Store(int64(interval))
+	// 只保留最新的间隔，检查循环未运行时在启动后生效
+	select {
+	case <-hc.intervalCh:
+	default:
+	}
+	hc.intervalCh <- interval
+}
+
+// checkInterval 当前探测间隔
+func (hc *HealthChecker) checkInterval() time.Duration {
+	return time.Duration(hc.interval.Load())
 }
 
 // Start 启动健康检查
@@ -164,13 +288,15 @@ func (hc *HealthChecker) run() {
 	// 立即执行第一次检查
 	hc.checkAll()
 
-	ticker := time.NewTicker(hc.config.Interval)
+	ticker := time.NewTicker(hc.checkInterval())
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-hc.stopCh:
 			return
+		case interval := <-hc.intervalCh:
+			ticker.Reset(interval)
 		case <-ticker.C:
 			hc.checkAll()
 		}
@@ -202,12 +328,29 @@ func (hc *HealthChecker) checkChannel(ch *Channel) {
 		return
 	}
 
+	hc.runCheck(ch, state)
+}
+
+// CheckNow 立即检查渠道（不受检查间隔限制）并返回结果，渠道不存在时返回 ErrChannelNotFound
+func (hc *HealthChecker) CheckNow(channelID string) (*HealthCheckResult, error) {
+	ch, err := hc.cache.GetChannel(channelID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrChannelNotFound, channelID)
+	}
+
+	result := hc.runCheck(ch, hc.getOrCreateState(ch.ID))
+	return result, nil
+}
+
+// runCheck 执行检查并更新状态、历史、统计与回调
+func (hc *HealthChecker) runCheck(ch *Channel, state *channelCheckState) *HealthCheckResult {
 	// 执行检查
 	result := hc.performCheck(ch)
 	result.CheckTime = time.Now()
 
 	// 更新状态
 	hc.updateChannelStatus(ch, result, state)
+	hc.recordHistory(state, result)
 
 	// 记录统计
 	atomic.AddInt64(&hc.totalChecks, 1)
@@ -224,6 +367,82 @@ func (hc *HealthChecker) checkChannel(ch *Channel) {
 
 	hc.logFunc("info", fmt.Sprintf("Health check for %s: %v (status: %s, latency: %dms)",
 		ch.ID, result.Healthy, result.Status, result.Latency))
+	return result
+}
+
+// recordHistory 将结果写入渠道的环形缓冲区，超出 HistorySize 时覆盖最旧的结果
+func (hc *HealthChecker) recordHistory(state *channelCheckState, result *HealthCheckResult) {
+	size := hc.config.HistorySize
+	if size <= 0 {
+		return
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.checked = true
+	if len(state.history) < size {
+		state.history = append(state.history, *result)
+		return
+	}
+	state.history[state.historyNext] = *result
+	state.historyNext = (state.historyNext + 1) % len(state.history)
+}
+
+// GetHistory 获取渠道最近的检查结果，按时间从旧到新排列
+func (hc *HealthChecker) GetHistory(channelID string) []HealthCheckResult {
+	hc.statesMu.RLock()
+	state, ok := hc.checkStates[channelID]
+	hc.statesMu.RUnlock()
+	if !ok {
+		return []HealthCheckResult{}
+	}
+
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	return state.orderedHistory()
+}
+
+// orderedHistory 按时间顺序复制环形缓冲区（调用方持有锁）
+func (s *channelCheckState) orderedHistory() []HealthCheckResult {
+	history := make([]HealthCheckResult, 0, len(s.history))
+	history = append(history, s.history[s.historyNext:]...)
+	return append(history, s.history[:s.historyNext]...)
+}
+
+// GetChannelHealth 获取渠道的健康检查状态与最近的检查结果，渠道不存在时返回 ErrChannelNotFound
+func (hc *HealthChecker) GetChannelHealth(channelID string) (*ChannelHealth, error) {
+	ch, err := hc.cache.GetChannel(channelID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrChannelNotFound, channelID)
+	}
+
+	health := &ChannelHealth{
+		ChannelID: ch.ID,
+		Status:    ch.GetStatus(),
+		History:   []HealthCheckResult{},
+	}
+
+	hc.statesMu.RLock()
+	state, ok := hc.checkStates[channelID]
+	hc.statesMu.RUnlock()
+	if !ok {
+		return health, nil
+	}
+
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	health.ConsecutiveFailures = atomic.LoadInt64(&state.consecutiveFailures)
+	health.RecoveryMode = state.inRecovery
+	health.LastReason = state.lastReason
+	if state.checked {
+		lastCheck := state.lastCheckTime
+		health.LastCheckTime = &lastCheck
+	}
+	health.History = state.orderedHistory()
+	return health, nil
 }
 
 // shouldCheck 是否应该检查
@@ -239,7 +458,7 @@ func (hc *HealthChecker) shouldCheck(state *channelCheckState) bool {
 	}
 
 	// 否则使用标准间隔
-	return now.Sub(state.lastCheckTime) >= hc.config.Interval
+	return now.Sub(state.lastCheckTime) >= hc.checkInterval()
 }
 
 // performCheck 执行检查
@@ -257,10 +476,9 @@ func (hc *HealthChecker) performCheck(ch *Channel) *HealthCheckResult {
 		Status:    status,
 	}
 
-	// 构建检查 URL
-	checkURL := ch.BaseURL + hc.config.HealthCheckEndpoint
-	if checkURL == hc.config.HealthCheckEndpoint {
-		// 如果没有配置 BaseURL，使用默认的健康检查逻辑
+	req, ok := hc.probeRequest(ch)
+	if !ok {
+		// 没有可探测的地址（未配置 BaseURL 或探测模型），使用默认的健康检查逻辑
 		result.Healthy = hc.defaultHealthCheck(ch)
 		result.SuccessRate = ch.Metrics.GetSuccessRate()
 		result.Status = hc.determineStatus(result.SuccessRate)
@@ -269,7 +487,7 @@ func (hc *HealthChecker) performCheck(ch *Channel) *HealthCheckResult {
 
 	// 执行 HTTP 请求
 	start := time.Now()
-	resp, err := hc.httpClient.Get(checkURL)
+	resp, err := hc.httpClient.Do(req)
 	latency := time.Since(start)
 
 	result.Latency = latency.Milliseconds()
@@ -277,26 +495,118 @@ func (hc *HealthChecker) performCheck(ch *Channel) *HealthCheckResult {
 	if err != nil {
 		result.Healthy = false
 		result.Error = err.Error()
+		result.Reason = HealthReasonNetwork
 		result.Status = ChannelStatusUnavailable
 		return result
 	}
 
 	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	atomic.StoreInt64(&ch.Metrics.ProbeLatency, result.Latency)
 
 	// 检查响应状态码
 	result.Healthy = resp.StatusCode >= 200 && resp.StatusCode < 300
 	result.SuccessRate = ch.Metrics.GetSuccessRate()
 
-	if result.Healthy {
+	switch {
+	case result.Healthy:
 		result.Status = hc.determineStatus(result.SuccessRate)
-	} else {
+		if atomic.LoadInt64(&ch.Metrics.TotalRequests) == 0 {
+			// 尚无请求记录时以探测结果为准
+			result.Status = ChannelStatusHealthy
+		}
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
 		result.Status = ChannelStatusUnavailable
+		result.Reason = HealthReasonInvalidCredentials
+		result.Error = fmt.Sprintf("invalid credentials (HTTP %d)", resp.StatusCode)
+	default:
+		result.Status = ChannelStatusUnavailable
+		result.Reason = HealthReasonHTTPStatus
 		result.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
 	}
 
 	return result
 }
 
+// probeRequest 构造渠道的探测请求
+//
+// 有对应探测定义的渠道类型携带第一个可用密钥请求提供方接口，其余渠道不带密钥请求 HealthCheckEndpoint；
+// 未配置 BaseURL 或补全探测找不到模型时返回 false。
+func (hc *HealthChecker) probeRequest(ch *Channel) (*http.Request, bool) {
+	baseURL := strings.TrimRight(ch.BaseURL, "/")
+	if baseURL == "" {
+		return nil, false
+	}
+
+	probe := hc.config.Probes[strings.ToLower(ch.Type)]
+	if probe == nil {
+		req, err := http.NewRequest(http.MethodGet, baseURL+hc.config.HealthCheckEndpoint, nil)
+		return req, err == nil
+	}
+
+	if probe.Root {
+		baseURL = strings.TrimSuffix(baseURL, "/v1")
+	}
+	method := probe.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	if probe.Body != "" {
+		payload := probe.Body
+		if strings.Contains(payload, probeModelPlaceholder) {
+			model := probeModel(ch)
+			if model == "" {
+				return nil, false
+			}
+			quoted, _ := json.Marshal(model)
+			payload = strings.ReplaceAll(payload, probeModelPlaceholder, string(quoted))
+		}
+		body = strings.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, baseURL+probe.Path, body)
+	if err != nil {
+		return nil, false
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if probe.AuthHeader != "" {
+		if key := probeKey(ch); key != "" {
+			req.Header.Set(probe.AuthHeader, probe.AuthPrefix+key)
+		}
+	}
+	for name, value := range probe.Headers {
+		req.Header.Set(name, value)
+	}
+	return req, true
+}
+
+// probeKey 探测使用的密钥：第一个可用的渠道密钥
+func probeKey(ch *Channel) string {
+	for _, key := range ch.Keys {
+		if key != nil && key.Enabled && key.APIKey != "" {
+			return key.APIKey
+		}
+	}
+	return ""
+}
+
+// probeModel 补全探测使用的模型：渠道支持的第一个非通配模型
+func probeModel(ch *Channel) string {
+	if ch.Ability == nil {
+		return ""
+	}
+	for _, model := range ch.Ability.SupportedModels {
+		if model != "" && !strings.Contains(model, "*") {
+			return model
+		}
+	}
+	return ""
+}
+
 // defaultHealthCheck 默认健康检查逻辑
 func (hc *HealthChecker) defaultHealthCheck(ch *Channel) bool {
 	// 基于成功率判定
@@ -326,11 +636,15 @@ func (hc *HealthChecker) updateChannelStatus(ch *Channel, result *HealthCheckRes
 	if !result.Healthy {
 		atomic.AddInt64(&state.consecutiveFailures, 1)
 
-		// 如果连续失败过多，标记为不可用
-		if atomic.LoadInt64(&state.consecutiveFailures) >= int64(hc.config.MaxConsecutiveFailures) {
+		// 密钥无效不会自行恢复，立即标记为不可用；其它失败连续过多时标记为不可用
+		if result.Reason == HealthReasonInvalidCredentials ||
+			atomic.LoadInt64(&state.consecutiveFailures) >= int64(hc.config.MaxConsecutiveFailures) {
 			result.Status = ChannelStatusUnavailable
 			state.inRecovery = true
+			ch.SetStatus(ChannelStatusUnavailable)
+			state.lastStatus = ChannelStatusUnavailable
 		}
+		state.lastReason = result.Reason
 
 		// 记录渠道失败
 		ch.RecordFailure()
@@ -339,6 +653,7 @@ func (hc *HealthChecker) updateChannelStatus(ch *Channel, result *HealthCheckRes
 
 	// 检查成功，重置连续失败计数
 	atomic.StoreInt64(&state.consecutiveFailures, 0)
+	state.lastReason = ""
 
 	// 如果之前是不可用状态，现在恢复
 	if state.lastStatus == ChannelStatusUnavailable && result.Status != ChannelStatusUnavailable {
@@ -373,7 +688,7 @@ func (hc *HealthChecker) GetStatistics() map[string]interface{} {
 		"success_count": success,
 		"failure_count": failure,
 		"success_rate":  successRate,
-		"interval":      hc.config.Interval.String(),
+		"interval":      hc.checkInterval().String(),
 		"timeout":       hc.config.Timeout.String(),
 		"check_states":  len(hc.checkStates),
 	}
@@ -389,7 +704,7 @@ func (hc *HealthChecker) getOrCreateState(channelID string) *channelCheckState {
 	}
 
 	state := &channelCheckState{
-		lastCheckTime:       time.Now().Add(-hc.config.Interval),
+		lastCheckTime:       time.Now().Add(-hc.checkInterval()),
 		consecutiveFailures: 0,
 		inRecovery:          false,
 	}
@@ -502,6 +817,10 @@ type CircuitBreaker struct {
 	// 最后状态变更时间
 	lastStateChangeTime time.Time
 
+	// 半开状态下是否有探测请求尚未回报结果，以及探测开始时间
+	probing        bool
+	probeStartedAt time.Time
+
 	// 互斥锁
 	mu sync.RWMutex
 
@@ -521,6 +840,39 @@ const (
 	CircuitHalfOpen
 )
 
+// String 返回断路器状态的字符串表示
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalJSON 以字符串形式输出断路器状态
+func (s CircuitState) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+// CircuitBreakerState 断路器状态快照
+type CircuitBreakerState struct {
+	ChannelID           string       `json:"channel_id"`
+	ChannelName         string       `json:"channel_name,omitempty"`
+	State               CircuitState `json:"state"`
+	FailureCount        int64        `json:"failure_count"`
+	SuccessCount        int64        `json:"success_count"`
+	FailureThreshold    int64        `json:"failure_threshold"`
+	SuccessThreshold    int64        `json:"success_threshold"`
+	LastStateChangeTime time.Time    `json:"last_state_change_time"`
+	// HalfOpenInMs 距离放行探测请求（转为半开）的剩余毫秒数，仅打开状态下非零
+	HalfOpenInMs int64 `json:"half_open_in_ms"`
+}
+
 // NewCircuitBreaker 创建断路器
 func NewCircuitBreaker(channelID string, failureThreshold, successThreshold int64, timeout time.Duration) *CircuitBreaker {
 	return &CircuitBreaker{
@@ -555,7 +907,8 @@ func (cb *CircuitBreaker) RecordSuccess() {
 		}
 
 	case CircuitHalfOpen:
-		// 累计成功计数
+		// 探测成功，累计成功计数
+		cb.probing = false
 		cb.successCount++
 		if cb.successCount >= cb.successThreshold {
 			cb.state = CircuitClosed
@@ -583,19 +936,46 @@ func (cb *CircuitBreaker) RecordFailure() {
 		}
 
 	case CircuitHalfOpen:
-		// 直接打开
+		// 探测失败，直接打开
+		cb.probing = false
 		cb.state = CircuitOpen
 		cb.lastStateChangeTime = time.Now()
 		cb.logFunc("info", fmt.Sprintf("Circuit breaker %s half-open -> open", cb.channelID))
+
+	case CircuitOpen:
+		// 超时后的探测请求仍然失败，重新计时
+		cb.lastStateChangeTime = time.Now()
 	}
 }
 
-// IsAvailable 是否可用
+// IsAvailable 是否可用。熔断超时后转为半开，半开状态同一时刻只放行一个探测请求，
+// 探测成功累计到阈值后关闭、失败则重新打开；探测一直未回报结果（如选中后未实际发送）时，
+// 超过 timeout 再放行下一个探测
 func (cb *CircuitBreaker) IsAvailable() bool {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
 
-	return cb.state != CircuitOpen
+	now := time.Now()
+	switch cb.state {
+	case CircuitClosed:
+		return true
+
+	case CircuitOpen:
+		if now.Sub(cb.lastStateChangeTime) < cb.timeout {
+			return false
+		}
+		cb.state = CircuitHalfOpen
+		cb.successCount = 0
+		cb.lastStateChangeTime = now
+		cb.logFunc("info", fmt.Sprintf("Circuit breaker %s opened -> half-open", cb.channelID))
+	}
+
+	if cb.probing && now.Sub(cb.probeStartedAt) < cb.timeout {
+		return false
+	}
+	cb.probing = true
+	cb.probeStartedAt = now
+	return true
 }
 
 // GetState 获取状态
@@ -605,3 +985,52 @@ func (cb *CircuitBreaker) GetState() CircuitState {
 
 	return cb.state
 }
+
+// Snapshot 获取断路器状态快照
+func (cb *CircuitBreaker) Snapshot(now time.Time) CircuitBreakerState {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	state := CircuitBreakerState{
+		ChannelID:           cb.channelID,
+		State:               cb.state,
+		FailureCount:        cb.failureCount,
+		SuccessCount:        cb.successCount,
+		FailureThreshold:    cb.failureThreshold,
+		SuccessThreshold:    cb.successThreshold,
+		LastStateChangeTime: cb.lastStateChangeTime,
+	}
+	if cb.state == CircuitOpen {
+		if remaining := cb.lastStateChangeTime.Add(cb.timeout).Sub(now); remaining > 0 {
+			state.HalfOpenInMs = remaining.Milliseconds()
+		}
+	}
+	return state
+}
+
+// Trip 强制打开断路器（如渠道被禁用），超时后照常转为半开探测
+func (cb *CircuitBreaker) Trip() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	previous := cb.state
+	cb.state = CircuitOpen
+	cb.successCount = 0
+	cb.probing = false
+	cb.lastStateChangeTime = time.Now()
+	cb.logFunc("info", fmt.Sprintf("Circuit breaker %s %s -> open (manual trip)", cb.channelID, previous))
+}
+
+// Reset 强制关闭断路器并清零计数
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	previous := cb.state
+	cb.state = CircuitClosed
+	cb.failureCount = 0
+	cb.successCount = 0
+	cb.probing = false
+	cb.lastStateChangeTime = time.Now()
+	cb.logFunc("info", fmt.Sprintf("Circuit breaker %s %s -> closed (manual reset)", cb.channelID, previous))
+}
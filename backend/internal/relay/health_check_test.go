@@ -1,6 +1,14 @@
 package relay
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -36,7 +44,11 @@ func TestHealthChecker(t *testing.T) {
 func TestHealthCheckerStatus(t *testing.T) {
 	cache := NewChannelCache(ChannelCacheLevelMemory)
 
-	ch := NewChannel("ch-1", "Channel 1", "https://api.test.com", "test")
+	// 探测本地的上游，不依赖外部网络
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	ch := NewChannel("ch-1", "Channel 1", upstream.URL, "test")
 	cache.AddChannel(ch)
 
 	config := DefaultHealthCheckConfig()
@@ -62,6 +74,185 @@ func TestHealthCheckerStatus(t *testing.T) {
 	}
 }
 
+func TestHealthCheckerProbeRequests(t *testing.T) {
+	checker := NewHealthChecker(NewChannelCache(ChannelCacheLevelMemory), DefaultHealthCheckConfig())
+
+	tests := []struct {
+		channelType string
+		baseURL     string
+		wantMethod  string
+		wantURL     string
+		wantHeaders map[string]string
+		wantBody    string
+	}{
+		{"openai", "https://api.openai.com/v1", "GET", "https://api.openai.com/v1/models",
+			map[string]string{"Authorization": "Bearer sk-test"}, ""},
+		{"claude", "https://api.anthropic.com/v1/", "GET", "https://api.anthropic.com/v1/models",
+			map[string]string{"x-api-key": "sk-test", "anthropic-version": "2023-06-01"}, ""},
+		{"gemini", "https://generativelanguage.googleapis.com/v1beta", "GET", "https://generativelanguage.googleapis.com/v1beta/models",
+			map[string]string{"x-goog-api-key": "sk-test"}, ""},
+		{"azure", "https://res.openai.azure.com", "GET", "https://res.openai.azure.com/openai/models?api-version=2024-10-21",
+			map[string]string{"api-key": "sk-test"}, ""},
+		{"ollama", "http://localhost:11434/v1", "GET", "http://localhost:11434/api/tags", nil, ""},
+		{"baidu", "https://qianfan.baidubce.com/v2", "POST", "https://qianfan.baidubce.com/v2/chat/completions",
+			map[string]string{"Authorization": "Bearer sk-test"},
+			`{"model":"ernie-4.0","messages":[{"role":"user","content":"ping"}],"max_tokens":1}`},
+		{"custom", "https://llm.internal", "GET", "https://llm.internal/health", map[string]string{"Authorization": ""}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.channelType, func(t *testing.T) {
+			ch := NewChannel("ch-1", "Channel 1", tt.baseURL, tt.channelType)
+			ch.Keys = []*ChannelKey{{APIKey: "sk-test", Enabled: true}}
+			ch.Ability.SupportedModels = []string{"ernie-*", "ernie-4.0"}
+
+			req, ok := checker.probeRequest(ch)
+			if !ok {
+				t.Fatal("Expected a probe request")
+			}
+			if req.Method != tt.wantMethod || req.URL.String() != tt.wantURL {
+				t.Errorf("Expected %s %s, got %s %s", tt.wantMethod, tt.wantURL, req.Method, req.URL)
+			}
+			for name, value := range tt.wantHeaders {
+				if got := req.Header.Get(name); got != value {
+					t.Errorf("Expected header %s=%q, got %q", name, value, got)
+				}
+			}
+			if tt.wantBody != "" {
+				body, _ := io.ReadAll(req.Body)
+				if string(body) != tt.wantBody {
+					t.Errorf("Unexpected probe body %s", body)
+				}
+			}
+		})
+	}
+
+	// 补全探测找不到具体模型时退回成功率判定
+	ch := NewChannel("ch-1", "Channel 1", "https://qianfan.baidubce.com/v2", "baidu")
+	ch.Ability.SupportedModels = []string{"*"}
+	if _, ok := checker.probeRequest(ch); ok {
+		t.Error("Expected no probe without a concrete model")
+	}
+}
+
+func TestHealthCheckerProbeResults(t *testing.T) {
+	var status int
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(status)
+		w.Write([]byte(`{"object":"list","data":[]}`))
+	}))
+	defer server.Close()
+
+	cache := NewChannelCache(ChannelCacheLevelMemory)
+	ch := NewChannel("ch-1", "Channel 1", server.URL+"/v1", "openai")
+	ch.Keys = []*ChannelKey{{APIKey: "sk-live", Enabled: true}}
+	cache.AddChannel(ch)
+	checker := NewHealthChecker(cache, DefaultHealthCheckConfig())
+
+	// 探测成功：携带渠道密钥，记录探测延迟
+	status = http.StatusOK
+	result := checker.performCheck(ch)
+	if !result.Healthy || result.Status != ChannelStatusHealthy || result.Reason != "" {
+		t.Fatalf("Expected Healthy status, got %+v", result)
+	}
+	if auth != "Bearer sk-live" {
+		t.Errorf("Expected probe to use the channel key, got %q", auth)
+	}
+	if latency := atomic.LoadInt64(&ch.Metrics.ProbeLatency); latency < 5 {
+		t.Errorf("Expected probe latency to be recorded, got %dms", latency)
+	}
+
+	// 401：密钥无效，立即标记为不可用
+	status = http.StatusUnauthorized
+	checker.checkChannel(ch)
+	if ch.GetStatus() != ChannelStatusUnavailable {
+		t.Errorf("Expected invalid credentials to mark the channel unavailable, got %s", ch.GetStatus())
+	}
+	state := checker.getOrCreateState(ch.ID)
+	if state.lastReason != HealthReasonInvalidCredentials {
+		t.Errorf("Expected invalid credentials reason, got %q", state.lastReason)
+	}
+
+	// 网络错误与密钥无效区分开，单次失败不直接标记为不可用
+	other := NewChannel("ch-2", "Channel 2", "http://127.0.0.1:1", "openai")
+	result = checker.performCheck(other)
+	if result.Healthy || result.Reason != HealthReasonNetwork {
+		t.Errorf("Expected network error reason, got %+v", result)
+	}
+	checker.checkChannel(other)
+	if other.GetStatus() != ChannelStatusHealthy {
+		t.Errorf("Expected a single network failure to keep the channel status, got %s", other.GetStatus())
+	}
+}
+
+func TestHealthCheckerHistoryAndCheckNow(t *testing.T) {
+	statuses := []int{http.StatusOK, http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK, http.StatusUnauthorized}
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statuses[calls])
+		calls++
+	}))
+	defer server.Close()
+
+	cache := NewChannelCache(ChannelCacheLevelMemory)
+	ch := NewChannel("ch-1", "Channel 1", server.URL+"/v1", "openai")
+	ch.Keys = []*ChannelKey{{APIKey: "sk-live", Enabled: true}}
+	cache.AddChannel(ch)
+	config := DefaultHealthCheckConfig()
+	config.HistorySize = 3
+	checker := NewHealthChecker(cache, config)
+
+	// 尚未检查：没有历史
+	health, err := checker.GetChannelHealth("ch-1")
+	if err != nil || len(health.History) != 0 || health.LastCheckTime != nil || health.RecoveryMode {
+		t.Fatalf("Expected no history before the first check, got %+v (%v)", health, err)
+	}
+
+	for range statuses {
+		if _, err := checker.CheckNow("ch-1"); err != nil {
+			t.Fatalf("CheckNow failed: %v", err)
+		}
+	}
+	if calls != len(statuses) {
+		t.Fatalf("Expected CheckNow to ignore the check interval, got %d probes", calls)
+	}
+
+	// 只保留最近 3 次，按时间从旧到新
+	history := checker.GetHistory("ch-1")
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 results in history, got %d", len(history))
+	}
+	wantReasons := []string{HealthReasonHTTPStatus, "", HealthReasonInvalidCredentials}
+	for i, result := range history {
+		if result.Reason != wantReasons[i] {
+			t.Errorf("history[%d]: expected reason %q, got %q", i, wantReasons[i], result.Reason)
+		}
+		if i > 0 && result.CheckTime.Before(history[i-1].CheckTime) {
+			t.Error("Expected history in chronological order")
+		}
+	}
+
+	health, _ = checker.GetChannelHealth("ch-1")
+	if health.Status != ChannelStatusUnavailable || !health.RecoveryMode || health.ConsecutiveFailures != 1 ||
+		health.LastReason != HealthReasonInvalidCredentials || health.LastCheckTime == nil {
+		t.Errorf("Unexpected channel health %+v", health)
+	}
+	data, _ := json.Marshal(health)
+	if !strings.Contains(string(data), `"status":"unavailable"`) || !strings.Contains(string(data), `"recovery_mode":true`) {
+		t.Errorf("Unexpected JSON %s", data)
+	}
+
+	if _, err := checker.CheckNow("missing"); !errors.Is(err, ErrChannelNotFound) {
+		t.Errorf("Expected ErrChannelNotFound, got %v", err)
+	}
+	if len(checker.GetHistory("missing")) != 0 {
+		t.Error("Expected empty history for an unknown channel")
+	}
+}
+
 func TestCircuitBreakerClosed(t *testing.T) {
 	cb := NewCircuitBreaker("ch-1", 5, 3, 1*time.Second)
 
@@ -145,6 +336,83 @@ func TestCircuitBreakerRecovery(t *testing.T) {
 	}
 }
 
+func TestCircuitBreakerAllowsProbeAfterTimeout(t *testing.T) {
+	cb := NewCircuitBreaker("ch-1", 1, 1, 100*time.Millisecond)
+	cb.RecordFailure()
+
+	if cb.IsAvailable() {
+		t.Errorf("Expected circuit breaker to be unavailable")
+	}
+
+	// 超时后放行探测请求
+	time.Sleep(150 * time.Millisecond)
+	if !cb.IsAvailable() {
+		t.Errorf("Expected circuit breaker to allow a probe after timeout")
+	}
+
+	// 探测失败后重新计时
+	cb.RecordFailure()
+	if cb.IsAvailable() {
+		t.Errorf("Expected circuit breaker to be unavailable after failed probe")
+	}
+}
+
+func TestCircuitBreakerHalfOpenAllowsSingleProbe(t *testing.T) {
+	cb := NewCircuitBreaker("ch-1", 1, 1, 100*time.Millisecond)
+	cb.RecordFailure()
+	time.Sleep(150 * time.Millisecond)
+
+	if !cb.IsAvailable() {
+		t.Fatal("Expected the first caller after timeout to get the probe")
+	}
+	if cb.GetState() != CircuitHalfOpen {
+		t.Fatalf("Expected HalfOpen state, got %s", cb.GetState())
+	}
+
+	// 探测请求尚未回报结果时，其它并发调用方被拒绝
+	var wg sync.WaitGroup
+	var allowed atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if cb.IsAvailable() {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := allowed.Load(); n != 0 {
+		t.Fatalf("Expected concurrent callers to be refused while the probe is in flight, %d allowed", n)
+	}
+
+	// 探测成功后关闭
+	cb.RecordSuccess()
+	if cb.GetState() != CircuitClosed || !cb.IsAvailable() {
+		t.Errorf("Expected the breaker to close after a successful probe, got %s", cb.GetState())
+	}
+}
+
+func TestCircuitBreakerHalfOpenReopensOnFailedProbe(t *testing.T) {
+	cb := NewCircuitBreaker("ch-1", 1, 1, 100*time.Millisecond)
+	cb.RecordFailure()
+	time.Sleep(150 * time.Millisecond)
+
+	if !cb.IsAvailable() {
+		t.Fatal("Expected a probe after timeout")
+	}
+	cb.RecordFailure()
+	if cb.GetState() != CircuitOpen || cb.IsAvailable() {
+		t.Errorf("Expected the breaker to re-open after a failed probe, got %s", cb.GetState())
+	}
+
+	// 重新计时后再次放行单个探测
+	time.Sleep(150 * time.Millisecond)
+	if !cb.IsAvailable() || cb.IsAvailable() {
+		t.Error("Expected exactly one probe after the next timeout")
+	}
+}
+
 func TestHealthCheckConfig(t *testing.T) {
 	config := DefaultHealthCheckConfig()
 
@@ -178,6 +446,38 @@ func TestHealthCheckManager(t *testing.T) {
 	}
 }
 
+func TestCircuitBreakerSnapshotAndReset(t *testing.T) {
+	cb := NewCircuitBreaker("ch-1", 2, 2, time.Minute)
+	cb.RecordFailure()
+	cb.RecordFailure()
+
+	snapshot := cb.Snapshot(cb.lastStateChangeTime.Add(20 * time.Second))
+	if snapshot.State != CircuitOpen || snapshot.FailureCount != 2 {
+		t.Fatalf("Expected open breaker with 2 failures, got %+v", snapshot)
+	}
+	if snapshot.HalfOpenInMs != 40000 {
+		t.Errorf("Expected 40s until half-open, got %dms", snapshot.HalfOpenInMs)
+	}
+	if data, _ := json.Marshal(snapshot.State); string(data) != `"open"` {
+		t.Errorf("Expected state to marshal as a string, got %s", data)
+	}
+
+	cb.Reset()
+	snapshot = cb.Snapshot(time.Now())
+	if snapshot.State != CircuitClosed || snapshot.FailureCount != 0 || snapshot.SuccessCount != 0 || snapshot.HalfOpenInMs != 0 {
+		t.Errorf("Expected reset breaker to be closed with zeroed counters, got %+v", snapshot)
+	}
+	if !cb.IsAvailable() {
+		t.Error("Expected reset breaker to be available")
+	}
+
+	// 重置后需要重新累计到阈值才会再次打开
+	cb.RecordFailure()
+	if cb.GetState() != CircuitClosed {
+		t.Error("Expected a single failure after reset to keep the breaker closed")
+	}
+}
+
 func BenchmarkCircuitBreakerSuccess(b *testing.B) {
 	cb := NewCircuitBreaker("ch-1", 5, 3, 1*time.Second)
 
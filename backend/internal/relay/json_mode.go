@@ -0,0 +1,85 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// JSON 输出格式（response_format.type）
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// JSONNudge strict_json 校验失败后重试时追加的系统提示
+const JSONNudge = "Respond with valid JSON only. Do not include any explanation, markdown or code fences."
+
+// ResponseFormatType 请求的 response_format.type，未设置时为空
+func ResponseFormatType(req *ChatCompletionRequest) string {
+	format, _ := req.ResponseFormat["type"].(string)
+	return format
+}
+
+// WantsJSON 请求是否要求 JSON 输出（json_object 或 json_schema）
+func WantsJSON(req *ChatCompletionRequest) bool {
+	switch ResponseFormatType(req) {
+	case ResponseFormatJSONObject, ResponseFormatJSONSchema:
+		return true
+	default:
+		return false
+	}
+}
+
+// WithJSONNudge 复制请求并在末尾追加要求只输出 JSON 的系统消息，不修改原请求的消息列表
+func WithJSONNudge(req *ChatCompletionRequest) *ChatCompletionRequest {
+	nudged := *req
+	nudged.Messages = make([]ChatMessage, len(req.Messages), len(req.Messages)+1)
+	copy(nudged.Messages, req.Messages)
+	nudged.Messages = append(nudged.Messages, ChatMessage{Role: "system", Content: JSONNudge})
+	return &nudged
+}
+
+// ErrInvalidJSONOutput 模型输出不是合法的 JSON
+var ErrInvalidJSONOutput = errors.New("model output is not valid JSON")
+
+// ValidateJSONOutput 校验模型输出是否为合法的 JSON，json_object 格式还要求顶层是对象
+//
+// 只去掉首尾空白，不剥离 markdown 代码块：strict_json 的调用方期望可以直接解析的输出。
+func ValidateJSONOutput(content, format string) error {
+	trimmed := bytes.TrimSpace([]byte(content))
+	if len(trimmed) == 0 {
+		return fmt.Errorf("%w: empty output", ErrInvalidJSONOutput)
+	}
+	var value interface{}
+	if err := json.Unmarshal(trimmed, &value); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJSONOutput, err)
+	}
+	if _, ok := value.(map[string]interface{}); !ok && format == ResponseFormatJSONObject {
+		return fmt.Errorf("%w: top-level value is not an object", ErrInvalidJSONOutput)
+	}
+	return nil
+}
+
+// InvalidJSONError strict_json 请求在追加提示重试后仍未得到合法的 JSON
+//
+// 不切换渠道：输出格式错误不是渠道故障，也不计入断路器。
+type InvalidJSONError struct {
+	Model    string
+	Attempts int   // 校验的响应数（含追加提示后的重试）
+	Err      error // 最后一次校验的错误
+}
+
+// Error 实现 error 接口
+func (e *InvalidJSONError) Error() string {
+	return fmt.Sprintf("model %s returned invalid JSON after %d attempts: %s", e.Model, e.Attempts,
+		strings.TrimPrefix(e.Err.Error(), ErrInvalidJSONOutput.Error()+": "))
+}
+
+// Unwrap 返回最后一次校验的错误
+func (e *InvalidJSONError) Unwrap() error {
+	return e.Err
+}
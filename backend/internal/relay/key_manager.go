@@ -18,6 +18,8 @@ const (
 	KeyStrategyRoundRobin
 	// 故障感知轮询
 	KeyStrategyFailureAware
+	// 加权轮询
+	KeyStrategyWeightedRoundRobin
 )
 
 // String 返回策略的字符串表示
@@ -29,6 +31,8 @@ func (s KeyRotationStrategy) String() string {
 		return "round_robin"
 	case KeyStrategyFailureAware:
 		return "failure_aware"
+	case KeyStrategyWeightedRoundRobin:
+		return "weighted_round_robin"
 	default:
 		return "unknown"
 	}
@@ -156,6 +160,10 @@ type KeyManager struct {
 	// 轮询计数器
 	roundRobinCounter int64
 
+	// 加权轮询的当前权重（key=密钥 ID）
+	currentWeights map[string]int
+	weightMu       sync.Mutex
+
 	// 统计信息
 	totalSelections      int64
 	successfulSelections int64
@@ -172,6 +180,7 @@ func NewKeyManager(strategy KeyRotationStrategy) *KeyManager {
 		strategy:           strategy,
 		keys:               make(map[string][]*APIKey),
 		roundRobinCounter:  0,
+		currentWeights:     make(map[string]int),
 		logFunc:            defaultLogFunc,
 	}
 }
@@ -205,6 +214,10 @@ func (km *KeyManager) RemoveKey(channelType, keyID string) error {
 	for i, k := range keys {
 		if k.ID == keyID {
 			km.keys[channelType] = append(keys[:i], keys[i+1:]...)
+
+			km.weightMu.Lock()
+			delete(km.currentWeights, keyID)
+			km.weightMu.Unlock()
 			return nil
 		}
 	}
@@ -248,6 +261,9 @@ func (km *KeyManager) SelectKey(channelType string) (*APIKey, error) {
 	case KeyStrategyFailureAware:
 		selected = km.selectFailureAware(available)
 
+	case KeyStrategyWeightedRoundRobin:
+		selected = km.selectWeighted(available)
+
 	default:
 		return nil, fmt.Errorf("unknown strategy: %d", km.strategy)
 	}
@@ -282,6 +298,33 @@ func (km *KeyManager) selectRoundRobin(keys []*APIKey) *APIKey {
 	return keys[idx]
 }
 
+// selectWeighted 平滑加权轮询（nginx 算法），按权重交替选择密钥
+func (km *KeyManager) selectWeighted(keys []*APIKey) *APIKey {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	km.weightMu.Lock()
+	defer km.weightMu.Unlock()
+
+	totalWeight := 0
+	var selected *APIKey
+	for _, k := range keys {
+		weight := k.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		totalWeight += weight
+		km.currentWeights[k.ID] += weight
+		if selected == nil || km.currentWeights[k.ID] > km.currentWeights[selected.ID] {
+			selected = k
+		}
+	}
+
+	km.currentWeights[selected.ID] -= totalWeight
+	return selected
+}
+
 // selectFailureAware 故障感知轮询
 func (km *KeyManager) selectFailureAware(keys []*APIKey) *APIKey {
 	if len(keys) == 0 {
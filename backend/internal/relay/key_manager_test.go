@@ -134,6 +134,34 @@ func TestKeyManagerAddRemove(t *testing.T) {
 	}
 }
 
+func TestKeyManagerWeightedRoundRobin(t *testing.T) {
+	km := NewKeyManager(KeyStrategyWeightedRoundRobin)
+
+	k1 := NewAPIKey("k1", "secret1", "bearer")
+	k1.Weight = 2
+	k2 := NewAPIKey("k2", "secret2", "bearer")
+	k2.Weight = 1
+	km.AddKey("openai", k1)
+	km.AddKey("openai", k2)
+
+	sequence := make([]string, 0, 6)
+	for i := 0; i < 6; i++ {
+		key, err := km.SelectKey("openai")
+		if err != nil {
+			t.Fatalf("Selection failed: %v", err)
+		}
+		sequence = append(sequence, key.ID)
+	}
+
+	// 平滑加权轮询：权重 2:1 交替出现，不会连续选中同一密钥两次以上
+	expected := []string{"k1", "k2", "k1", "k1", "k2", "k1"}
+	for i := range expected {
+		if sequence[i] != expected[i] {
+			t.Fatalf("Expected sequence %v, got %v", expected, sequence)
+		}
+	}
+}
+
 func TestKeyPool(t *testing.T) {
 	pool := NewKeyPool()
 
@@ -168,7 +196,7 @@ func TestKeyRecordUsage(t *testing.T) {
 	// 记录成功
 	key.RecordUsage(true, 100)
 	if key.GetSuccessRate() != 100 {
-		t.Errorf("Expected 100% success rate")
+		t.Errorf("Expected 100%% success rate")
 	}
 
 	// 记录失败
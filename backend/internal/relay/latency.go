@@ -0,0 +1,137 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// LatencyPhase 请求耗时的阶段
+//
+// 各阶段首尾相接覆盖请求的整个时间线，相加等于总耗时：
+//
+//	admission       等待并发准入名额
+//	selection       渠道选择与请求准备（参数适配、格式转换，以及失败尝试之后的重新选择）
+//	connect         发出上游请求到获得连接（含 DNS、TCP 与 TLS 握手）
+//	ttft            获得连接到收到首个输出 Token；非流式请求为收到响应头
+//	generation      首个输出 Token 到上游响应结束；非流式请求为读取与解析响应体
+//	post_processing 上游响应结束到持久化（统一日志、额度结算；对话请求还包括保存消息之前的处理）
+type LatencyPhase int
+
+// 请求耗时阶段
+const (
+	PhaseAdmission LatencyPhase = iota
+	PhaseSelection
+	PhaseConnect
+	PhaseFirstToken
+	PhaseGeneration
+	PhasePostProcess
+
+	latencyPhaseCount
+)
+
+// latencyPhaseNames 阶段名称，同时是 JSON 字段名（加 _ms 后缀）的前缀
+var latencyPhaseNames = [latencyPhaseCount]string{
+	"admission", "selection", "connect", "ttft", "generation", "post_processing",
+}
+
+// String 阶段名称
+func (p LatencyPhase) String() string {
+	if p < 0 || p >= latencyPhaseCount {
+		return "unknown"
+	}
+	return latencyPhaseNames[p]
+}
+
+// LatencyPhaseNames 按时间顺序排列的阶段名称
+func LatencyPhaseNames() []string {
+	return latencyPhaseNames[:]
+}
+
+// LatencyBreakdown 单次请求的分阶段耗时
+type LatencyBreakdown struct {
+	Phases [latencyPhaseCount]time.Duration
+	Total  time.Duration
+}
+
+// Phase 指定阶段的耗时
+func (b LatencyBreakdown) Phase(p LatencyPhase) time.Duration {
+	if p < 0 || p >= latencyPhaseCount {
+		return 0
+	}
+	return b.Phases[p]
+}
+
+// Sum 各阶段耗时之和
+func (b LatencyBreakdown) Sum() time.Duration {
+	var sum time.Duration
+	for _, d := range b.Phases {
+		sum += d
+	}
+	return sum
+}
+
+// MarshalJSON 输出紧凑的毫秒值，如 {"admission_ms":0.012,...,"total_ms":812.5}
+func (b LatencyBreakdown) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 0, 160)
+	buf = append(buf, '{')
+	for i, d := range b.Phases {
+		buf = appendLatencyField(buf, latencyPhaseNames[i], d)
+		buf = append(buf, ',')
+	}
+	buf = appendLatencyField(buf, "total", b.Total)
+	return append(buf, '}'), nil
+}
+
+// UnmarshalJSON 解析 MarshalJSON 的输出，未知字段忽略
+func (b *LatencyBreakdown) UnmarshalJSON(data []byte) error {
+	var fields map[string]float64
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("invalid latency breakdown: %w", err)
+	}
+	for i, name := range latencyPhaseNames {
+		b.Phases[i] = msToDuration(fields[name+"_ms"])
+	}
+	b.Total = msToDuration(fields["total_ms"])
+	return nil
+}
+
+// appendLatencyField 追加 "<name>_ms":<毫秒>，保留微秒精度
+func appendLatencyField(buf []byte, name string, d time.Duration) []byte {
+	buf = append(buf, '"')
+	buf = append(buf, name...)
+	buf = append(buf, `_ms":`...)
+	return strconv.AppendFloat(buf, float64(d.Microseconds())/1000, 'f', -1, 64)
+}
+
+func msToDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// enterPhase 结束当前阶段并进入 p，now 之前的时间计入当前阶段
+func (t *RelayTimings) enterPhase(p LatencyPhase, now time.Time) {
+	if t.mark.IsZero() {
+		// 内部调用补建的上下文没有经过准入，从开始时间进入渠道选择
+		if t.StartedAt.IsZero() {
+			return
+		}
+		t.mark = t.StartedAt
+		t.phase = PhaseSelection
+	}
+	if now.After(t.mark) {
+		t.phases[t.phase] += now.Sub(t.mark)
+		t.mark = now
+	}
+	t.phase = p
+}
+
+// breakdown 截至 now 的分阶段耗时，当前阶段计算到 now
+func (t *RelayTimings) breakdown(now time.Time) LatencyBreakdown {
+	if t.StartedAt.IsZero() {
+		return LatencyBreakdown{}
+	}
+	snapshot := *t
+	snapshot.enterPhase(snapshot.phase, now)
+	return LatencyBreakdown{Phases: snapshot.phases, Total: now.Sub(t.StartedAt)}
+}
@@ -0,0 +1,100 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRelayContextLatencyPhases(t *testing.T) {
+	admitted := time.Now().Add(-30 * time.Millisecond)
+	rc, err := NewRelayContextBuilder("req-1", EndpointChatCompletions).
+		Admission(admitted, 20*time.Millisecond).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if !rc.Timings.StartedAt.Equal(admitted) {
+		t.Fatalf("expected request timing to start when admission began")
+	}
+
+	steps := []LatencyPhase{PhaseConnect, PhaseFirstToken, PhaseGeneration}
+	for _, phase := range steps {
+		time.Sleep(2 * time.Millisecond)
+		rc.EnterPhase(phase)
+	}
+	time.Sleep(2 * time.Millisecond)
+	rc.EndAttempt(time.Now(), nil, nil, nil)
+	rc.Finish(nil)
+
+	b := rc.Latency(rc.Timings.StartedAt.Add(rc.Timings.Total))
+	if b.Total != rc.Timings.Total || b.Sum() != b.Total {
+		t.Errorf("phases sum to %v, total %v, request total %v", b.Sum(), b.Total, rc.Timings.Total)
+	}
+	if b.Phase(PhaseAdmission) != 20*time.Millisecond {
+		t.Errorf("expected 20ms admission wait, got %v", b.Phase(PhaseAdmission))
+	}
+	// 准入结束到构造上下文之间的约 10ms 计入渠道选择
+	if b.Phase(PhaseSelection) < 10*time.Millisecond {
+		t.Errorf("expected selection to include time after admission, got %v", b.Phase(PhaseSelection))
+	}
+	for _, phase := range steps {
+		if b.Phase(phase) < 2*time.Millisecond {
+			t.Errorf("expected %s to be at least 2ms, got %v", phase, b.Phase(phase))
+		}
+	}
+}
+
+func TestRelayContextLatencyRetry(t *testing.T) {
+	rc, err := NewRelayContextBuilder("req-1", EndpointEmbeddings).Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	// 第一次尝试失败：之后到重新发起请求的时间回到渠道选择
+	rc.EnterPhase(PhaseConnect)
+	time.Sleep(2 * time.Millisecond)
+	rc.EndAttempt(time.Now(), nil, errors.New("connection refused"), nil)
+	time.Sleep(3 * time.Millisecond)
+	rc.EnterPhase(PhaseConnect)
+	rc.EnterPhase(PhaseFirstToken)
+	rc.EndAttempt(time.Now(), nil, nil, nil)
+
+	b := rc.Latency(time.Now())
+	if b.Sum() != b.Total {
+		t.Errorf("phases sum to %v, total %v", b.Sum(), b.Total)
+	}
+	if b.Phase(PhaseSelection) < 3*time.Millisecond || b.Phase(PhaseConnect) < 2*time.Millisecond {
+		t.Errorf("unexpected retry accounting: %+v", b)
+	}
+	if b.Phase(PhaseAdmission) != 0 {
+		t.Errorf("expected no admission wait without the admission middleware, got %v", b.Phase(PhaseAdmission))
+	}
+}
+
+func TestLatencyBreakdownJSON(t *testing.T) {
+	b := LatencyBreakdown{Total: 812500 * time.Microsecond}
+	b.Phases[PhaseAdmission] = 12 * time.Microsecond
+	b.Phases[PhaseFirstToken] = 800 * time.Millisecond
+	b.Phases[PhasePostProcess] = 12488 * time.Microsecond
+
+	data, err := json.Marshal(map[string]interface{}{"latency": b})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `{"latency":{"admission_ms":0.012,"selection_ms":0,"connect_ms":0,"ttft_ms":800,"generation_ms":0,"post_processing_ms":12.488,"total_ms":812.5}}`
+	if string(data) != want {
+		t.Errorf("unexpected JSON:\n got %s\nwant %s", data, want)
+	}
+
+	var decoded struct {
+		Latency LatencyBreakdown `json:"latency"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.Latency != b {
+		t.Errorf("round trip mismatch: %+v != %+v", decoded.Latency, b)
+	}
+}
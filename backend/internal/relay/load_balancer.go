@@ -1,6 +1,7 @@
 package relay
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"sort"
@@ -9,14 +10,27 @@ import (
 	"time"
 )
 
+// ErrChannelNotFound 渠道不存在
+var ErrChannelNotFound = errors.New("channel not found")
+
+var (
+	// ErrModelNotSupported 没有任何渠道提供请求的模型
+	ErrModelNotSupported = errors.New("model not supported")
+	// ErrNoAvailableChannel 提供该模型的渠道都已禁用、熔断或暂时不可用
+	ErrNoAvailableChannel = errors.New("no available channels")
+)
+
 // ChannelSelectOptions 渠道选择选项
 type ChannelSelectOptions struct {
 	ChannelType     string
 	Model           string
+	Feature         string // 渠道能力中必须标记的功能（如图像生成、语音），见 Feature*
 	UserGroup       string
-	ExcludeIDs      []int
+	ExcludeIDs      []string // 排除的渠道 ID（如故障转移时已尝试过的渠道）
+	ChannelIDs      []string // 限定的候选渠道 ID（如具备请求所需能力的渠道），为空时不限
 	Region          string
 	MinAvailability float64
+	HashKey         string // 一致性哈希的键（会话 ID 或用户 ID），为空时按模型哈希
 }
 
 // LoadBalanceStrategy 负载均衡策略
@@ -86,11 +100,30 @@ type LoadBalancerConfig struct {
 	// 重试间隔
 	RetryInterval time.Duration
 
+	// 流式响应中途失败计入渠道失败的权重（0~1），累计达到 1 时记一次失败
+	PartialFailureWeight float64
+
+	// 渠道并发数达到上限（ChannelAbility.MaxConcurrency）且没有其它可用渠道时排队等待的最长时间，0 表示不等待
+	ChannelQueueTimeout time.Duration
+
+	// 多密钥渠道中同一密钥连续鉴权失败或限流多少次后暂停使用（0 表示不暂停）
+	KeyParkThreshold int
+
+	// 密钥暂停时长
+	KeyParkDuration time.Duration
+
 	// 是否启用权重自适应
 	EnableAdaptiveWeight bool
 
 	// 权重调整间隔
 	WeightAdjustInterval time.Duration
+
+	// 权重系数的下限与上限，有效权重 = 基础权重 × 系数
+	MinFactor float64
+	MaxFactor float64
+
+	// 一致性哈希环上每个渠道的虚拟节点数（不大于 0 时使用 DefaultHashRingReplicas）
+	HashRingReplicas int
 }
 
 // DefaultLoadBalancerConfig 默认配置
@@ -105,8 +138,14 @@ func DefaultLoadBalancerConfig() *LoadBalancerConfig {
 		CircuitBreakerTimeout:          1 * time.Minute,
 		MaxRetries:                     3,
 		RetryInterval:                  100 * time.Millisecond,
+		PartialFailureWeight:           0.2,
+		KeyParkThreshold:               3,
+		KeyParkDuration:                5 * time.Minute,
 		EnableAdaptiveWeight:           true,
 		WeightAdjustInterval:           5 * time.Minute,
+		MinFactor:                      0.25,
+		MaxFactor:                      2.0,
+		HashRingReplicas:               DefaultHashRingReplicas,
 	}
 }
 
@@ -128,10 +167,35 @@ type LoadBalancer struct {
 	// 轮询计数器
 	roundRobinCounter int64
 
+	// 平滑加权轮询中各渠道的当前权重
+	currentWeights map[string]int
+	rrMu           sync.Mutex
+
+	// 一致性哈希环，渠道缓存变更后重建
+	ring        *HashRing
+	ringVersion uint64
+	ringMu      sync.Mutex
+
+	// 各渠道累计的部分失败权重
+	partialFailures   map[string]float64
+	partialFailuresMu sync.Mutex
+
+	// 多密钥渠道的密钥池
+	keyPools   map[string]*ChannelKeyPool
+	keyPoolsMu sync.RWMutex
+
+	// 各渠道的并发槽位
+	limiters   map[string]*ConcurrencyLimiter
+	limitersMu sync.Mutex
+
 	// 权重调整定时器
 	weightAdjustTicker *time.Ticker
 	weightAdjustStopCh chan struct{}
 
+	// 各渠道的权重系数与上次调整时的请求计数
+	weightFactors map[string]*weightFactor
+	weightsMu     sync.Mutex
+
 	// 统计信息
 	totalRequests int64
 	successCount  int64
@@ -158,6 +222,11 @@ func NewLoadBalancer(cache *ChannelCache, config *LoadBalancerConfig) *LoadBalan
 		cache:              cache,
 		config:             config,
 		circuitBreakers:    make(map[string]*CircuitBreaker),
+		partialFailures:    make(map[string]float64),
+		keyPools:           make(map[string]*ChannelKeyPool),
+		limiters:           make(map[string]*ConcurrencyLimiter),
+		weightFactors:      make(map[string]*weightFactor),
+		currentWeights:     make(map[string]int),
 		roundRobinCounter:  0,
 		weightAdjustStopCh: make(chan struct{}),
 		logFunc:            defaultLogFunc,
@@ -208,7 +277,10 @@ func (lb *LoadBalancer) SelectChannel(options *ChannelSelectOptions) (*Channel,
 	candidates := lb.getAvailableChannels(options)
 	if len(candidates) == 0 {
 		atomic.AddInt64(&lb.failureCount, 1)
-		return nil, fmt.Errorf("no available channels")
+		if options.Model != "" && len(lb.cache.FilterChannels(&ChannelFilter{Type: options.ChannelType, Model: options.Model, Feature: options.Feature})) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrModelNotSupported, options.Model)
+		}
+		return nil, ErrNoAvailableChannel
 	}
 
 	// 根据策略选择
@@ -230,7 +302,11 @@ func (lb *LoadBalancer) SelectChannel(options *ChannelSelectOptions) (*Channel,
 		selected = lb.selectWeightedByLatency(candidates)
 
 	case LBStrategyConsistentHash:
-		selected = lb.selectConsistentHash(candidates, options.Model)
+		key := options.HashKey
+		if key == "" {
+			key = options.Model
+		}
+		selected = lb.selectConsistentHash(candidates, key)
 
 	default:
 		return nil, fmt.Errorf("unknown strategy: %d", lb.config.Strategy)
@@ -252,55 +328,75 @@ func (lb *LoadBalancer) getAvailableChannels(options *ChannelSelectOptions) []*C
 	filter := &ChannelFilter{
 		Type:            options.ChannelType,
 		Model:           options.Model,
+		Feature:         options.Feature,
 		Region:          options.Region,
 		MinAvailability: options.MinAvailability,
 		OnlyEnabled:     true,
 	}
 
-	candidates := lb.cache.FilterChannels(filter)
+	excluded := make(map[string]bool, len(options.ExcludeIDs))
+	for _, id := range options.ExcludeIDs {
+		excluded[id] = true
+	}
+	var allowed map[string]bool
+	if len(options.ChannelIDs) > 0 {
+		allowed = make(map[string]bool, len(options.ChannelIDs))
+		for _, id := range options.ChannelIDs {
+			allowed[id] = true
+		}
+	}
 
-	// 过滤掉被断路器标记为不可用的渠道
-	if lb.config.EnableCircuitBreaker {
-		filtered := make([]*Channel, 0)
-		for _, ch := range candidates {
-			if lb.isCircuitBreakerAvailable(ch.ID) {
-				filtered = append(filtered, ch)
-			}
+	// 过滤掉已排除、不在候选范围内的渠道以及被断路器标记为不可用的渠道
+	filtered := make([]*Channel, 0)
+	for _, ch := range lb.cache.FilterChannels(filter) {
+		if excluded[ch.ID] || (allowed != nil && !allowed[ch.ID]) {
+			continue
+		}
+		if lb.config.EnableCircuitBreaker && !lb.isCircuitBreakerAvailable(ch.ID) {
+			continue
+		}
+		// 健康检查判定为不可用（如密钥无效）的渠道在恢复前不参与选择
+		if lb.healthChecker != nil && ch.GetStatus() == ChannelStatusUnavailable {
+			continue
+		}
+		// 所有密钥都被暂停的渠道暂时不可用
+		if pool := lb.keyPool(ch.ID); pool != nil && !pool.Available(time.Now()) {
+			continue
 		}
-		return filtered
+		filtered = append(filtered, ch)
 	}
 
-	return candidates
+	return filtered
 }
 
-// selectWeightedRoundRobin 加权轮询选择
+// selectWeightedRoundRobin 平滑加权轮询（nginx 算法）：按权重比例交替选择，权重为 0 的渠道不参与；所有权重均为 0 时随机选择
 func (lb *LoadBalancer) selectWeightedRoundRobin(candidates []*Channel) *Channel {
 	if len(candidates) == 0 {
 		return nil
 	}
 
-	// 计算总权重
+	lb.rrMu.Lock()
+	defer lb.rrMu.Unlock()
+
 	totalWeight := 0
+	var selected *Channel
 	for _, ch := range candidates {
-		totalWeight += ch.Weight
+		weight := ch.Weight
+		if weight <= 0 {
+			continue
+		}
+		totalWeight += weight
+		lb.currentWeights[ch.ID] += weight
+		if selected == nil || lb.currentWeights[ch.ID] > lb.currentWeights[selected.ID] {
+			selected = ch
+		}
 	}
 
-	if totalWeight == 0 {
+	if selected == nil {
 		return lb.selectRandom(candidates)
 	}
-
-	// 根据权重随机选择
-	target := randInt(totalWeight)
-	current := 0
-
-	for _, ch := range candidates {
-		current += ch.Weight
-		if target < current {
-			return ch
-		}
-	}
-
-	return candidates[len(candidates)-1]
+	lb.currentWeights[selected.ID] -= totalWeight
+	return selected
 }
 
 // selectRandom 随机选择
@@ -312,16 +408,18 @@ func (lb *LoadBalancer) selectRandom(candidates []*Channel) *Channel {
 	return candidates[idx]
 }
 
-// selectLeastConnection 最少连接选择
+// selectLeastConnection 最少连接选择，按并发槽位中进行中的请求数比较
 func (lb *LoadBalancer) selectLeastConnection(candidates []*Channel) *Channel {
 	if len(candidates) == 0 {
 		return nil
 	}
 
-	sort.Slice(candidates, func(i, j int) bool {
-		iConcurrency := atomic.LoadInt64(&candidates[i].Metrics.CurrentConcurrency)
-		jConcurrency := atomic.LoadInt64(&candidates[j].Metrics.CurrentConcurrency)
-		return iConcurrency < jConcurrency
+	active := make(map[string]int, len(candidates))
+	for _, ch := range candidates {
+		active[ch.ID] = lb.activeRequests(ch.ID)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return active[candidates[i].ID] < active[candidates[j].ID]
 	})
 
 	return candidates[0]
@@ -395,6 +493,9 @@ func (lb *LoadBalancer) selectWeightedByLatency(candidates []*Channel) *Channel
 }
 
 // selectConsistentHash 一致性哈希选择
+//
+// 在全部渠道构成的哈希环上从键的位置顺时针查找第一个候选渠道，
+// 因此候选集合变化（渠道熔断、被排除或下线）时只有落在该渠道上的键会改变归属。
 func (lb *LoadBalancer) selectConsistentHash(candidates []*Channel, key string) *Channel {
 	if len(candidates) == 0 {
 		return nil
@@ -404,10 +505,42 @@ func (lb *LoadBalancer) selectConsistentHash(candidates []*Channel, key string)
 		return lb.selectRandom(candidates)
 	}
 
-	// 简单的哈希选择
-	hash := hashString(key)
-	idx := int(hash) % len(candidates)
-	return candidates[idx]
+	byID := make(map[string]*Channel, len(candidates))
+	for _, ch := range candidates {
+		byID[ch.ID] = ch
+	}
+	id := lb.hashRing().Get(key, func(id string) bool {
+		_, ok := byID[id]
+		return ok
+	})
+	if ch, ok := byID[id]; ok {
+		return ch
+	}
+	// 候选渠道尚未进入哈希环（缓存之外的渠道），退化为随机选择
+	return lb.selectRandom(candidates)
+}
+
+// hashRing 返回与渠道缓存一致的哈希环，缓存版本变化且渠道集合不同时重建
+func (lb *LoadBalancer) hashRing() *HashRing {
+	lb.ringMu.Lock()
+	defer lb.ringMu.Unlock()
+
+	version := lb.cache.Version()
+	if lb.ring != nil && lb.ringVersion == version {
+		return lb.ring
+	}
+
+	channels := lb.cache.GetAllChannels()
+	ids := make([]string, 0, len(channels))
+	for _, ch := range channels {
+		ids = append(ids, ch.ID)
+	}
+	sort.Strings(ids)
+	if lb.ring == nil || !lb.ring.sameChannels(ids) {
+		lb.ring = NewHashRing(lb.config.HashRingReplicas, ids)
+	}
+	lb.ringVersion = version
+	return lb.ring
 }
 
 // RecordRequest 记录请求
@@ -432,6 +565,96 @@ func (lb *LoadBalancer) RecordRequest(channelID string, success bool, latency in
 	return nil
 }
 
+// RecordKeyRequest 记录使用渠道中 keyIndex 号密钥的请求结果
+//
+// 鉴权失败与限流计入该密钥的连续失败（达到阈值时暂停该密钥），并与 RecordRequest 一样
+// 计入渠道指标与断路器；与渠道无关的错误（如请求参数错误）不计入渠道失败。
+// keyIndex 为负数时只记录渠道。
+func (lb *LoadBalancer) RecordKeyRequest(channelID string, keyIndex int, err error, latency int64) error {
+	if pool := lb.keyPool(channelID); pool != nil && keyIndex >= 0 {
+		pool.Record(keyIndex, err, time.Now())
+	}
+
+	if err == nil {
+		return lb.RecordRequest(channelID, true, latency)
+	}
+	if isChannelFailure(err) {
+		return lb.RecordRequest(channelID, false, 0)
+	}
+	return nil
+}
+
+// SyncChannelKeys 按渠道 ID 同步多密钥渠道的密钥池
+//
+// 只有多于一个密钥的渠道使用密钥池；未变化的密钥保留失败统计与暂停状态，
+// 不再出现的渠道的密钥池被移除。
+func (lb *LoadBalancer) SyncChannelKeys(keys map[string][]string) {
+	lb.keyPoolsMu.Lock()
+	defer lb.keyPoolsMu.Unlock()
+
+	pools := make(map[string]*ChannelKeyPool, len(keys))
+	for channelID, channelKeys := range keys {
+		if len(channelKeys) < 2 {
+			continue
+		}
+		if pool, ok := lb.keyPools[channelID]; ok {
+			pool.SetKeys(channelKeys)
+			pools[channelID] = pool
+			continue
+		}
+		pools[channelID] = NewChannelKeyPool(channelKeys, lb.config.KeyParkThreshold, lb.config.KeyParkDuration)
+	}
+	lb.keyPools = pools
+}
+
+// ChannelKeyHealth 渠道各密钥的健康状态，单密钥渠道返回 nil
+func (lb *LoadBalancer) ChannelKeyHealth(channelID string) []KeyHealth {
+	pool := lb.keyPool(channelID)
+	if pool == nil {
+		return nil
+	}
+	return pool.Health(time.Now())
+}
+
+// selectKey 为本次尝试从渠道的密钥池中选择密钥，单密钥渠道返回 false
+func (lb *LoadBalancer) selectKey(channelID string) (ChannelKeySelection, bool) {
+	pool := lb.keyPool(channelID)
+	if pool == nil {
+		return ChannelKeySelection{Index: -1}, false
+	}
+	index, key := pool.Next(time.Now())
+	return ChannelKeySelection{Index: index, Key: key}, index >= 0
+}
+
+// keyPool 渠道的密钥池，单密钥渠道为 nil
+func (lb *LoadBalancer) keyPool(channelID string) *ChannelKeyPool {
+	lb.keyPoolsMu.RLock()
+	defer lb.keyPoolsMu.RUnlock()
+	return lb.keyPools[channelID]
+}
+
+// RecordPartialFailure 记录流式响应中途失败，按 PartialFailureWeight 折算为失败次数，
+// 避免一个长流偶发中断与多次完整失败对断路器产生同样的影响
+func (lb *LoadBalancer) RecordPartialFailure(channelID string) error {
+	weight := lb.config.PartialFailureWeight
+	if weight <= 0 {
+		return nil
+	}
+
+	lb.partialFailuresMu.Lock()
+	lb.partialFailures[channelID] += weight
+	trip := lb.partialFailures[channelID] >= 1
+	if trip {
+		lb.partialFailures[channelID] -= 1
+	}
+	lb.partialFailuresMu.Unlock()
+
+	if !trip {
+		return nil
+	}
+	return lb.RecordRequest(channelID, false, 0)
+}
+
 // recordCircuitBreakerSuccess 记录断路器成功
 func (lb *LoadBalancer) recordCircuitBreakerSuccess(channelID string) {
 	breaker := lb.getOrCreateCircuitBreaker(channelID)
@@ -484,6 +707,72 @@ func (lb *LoadBalancer) isCircuitBreakerAvailable(channelID string) bool {
 	return breaker.IsAvailable()
 }
 
+// GetCircuitBreakerStates 获取每个渠道的断路器状态快照，按渠道 ID 排序
+// 尚未创建断路器的渠道（从未失败过）按关闭状态返回
+func (lb *LoadBalancer) GetCircuitBreakerStates() []CircuitBreakerState {
+	lb.breakersMu.RLock()
+	breakers := make(map[string]*CircuitBreaker, len(lb.circuitBreakers))
+	for id, breaker := range lb.circuitBreakers {
+		breakers[id] = breaker
+	}
+	lb.breakersMu.RUnlock()
+
+	now := time.Now()
+	names := make(map[string]string)
+	states := make([]CircuitBreakerState, 0, len(breakers))
+	for _, ch := range lb.cache.GetAllChannels() {
+		names[ch.ID] = ch.Name
+		if _, ok := breakers[ch.ID]; !ok {
+			states = append(states, CircuitBreakerState{
+				ChannelID:        ch.ID,
+				State:            CircuitClosed,
+				FailureThreshold: lb.config.CircuitBreakerFailureThreshold,
+				SuccessThreshold: lb.config.CircuitBreakerSuccessThreshold,
+			})
+		}
+	}
+	for _, breaker := range breakers {
+		states = append(states, breaker.Snapshot(now))
+	}
+	for i := range states {
+		states[i].ChannelName = names[states[i].ChannelID]
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].ChannelID < states[j].ChannelID
+	})
+	return states
+}
+
+// ResetCircuitBreaker 强制关闭渠道的断路器
+// 渠道不存在时返回错误；渠道尚未创建断路器时视为已关闭
+func (lb *LoadBalancer) ResetCircuitBreaker(channelID string) error {
+	lb.breakersMu.RLock()
+	breaker, ok := lb.circuitBreakers[channelID]
+	lb.breakersMu.RUnlock()
+
+	if ok {
+		breaker.Reset()
+		return nil
+	}
+	if _, err := lb.cache.GetChannel(channelID); err != nil {
+		return fmt.Errorf("%w: %s", ErrChannelNotFound, channelID)
+	}
+	return nil
+}
+
+// TripCircuitBreaker 强制打开渠道的断路器，渠道已不在缓存中（如刚被禁用或删除）时同样生效
+func (lb *LoadBalancer) TripCircuitBreaker(channelID string) {
+	lb.getOrCreateCircuitBreaker(channelID).Trip()
+}
+
+// IsChannelAvailable 渠道的断路器是否允许请求（未启用断路器时始终可用）
+func (lb *LoadBalancer) IsChannelAvailable(channelID string) bool {
+	if !lb.config.EnableCircuitBreaker {
+		return true
+	}
+	return lb.isCircuitBreakerAvailable(channelID)
+}
+
 // SetStrategy 设置策略
 func (lb *LoadBalancer) SetStrategy(strategy LoadBalanceStrategy) {
 	lb.config.Strategy = strategy
@@ -532,34 +821,140 @@ func (lb *LoadBalancer) runWeightAdjustment() {
 	}
 }
 
+// weightFactor 渠道的权重系数，以及上次调整时的累计请求数（用于计算本周期的成功率）
+type weightFactor struct {
+	factor      float64
+	lastTotal   int64
+	lastSuccess int64
+}
+
+// EffectiveWeight 渠道的基础权重与自适应调整后的有效权重
+type EffectiveWeight struct {
+	ChannelID       string  `json:"channel_id"`
+	BaseWeight      int     `json:"base_weight"`
+	Factor          float64 `json:"factor"`
+	EffectiveWeight int     `json:"effective_weight"`
+}
+
 // adjustWeights 调整权重
+//
+// 按上一周期（而非累计）的成功率调整每个渠道的系数：优秀时上调，良好或没有请求时向 1.0 回归，
+// 一般或较差时下调；系数限制在 [MinFactor, MaxFactor]，有效权重始终由基础权重乘以系数得出，
+// 因此调整不会累积放大，渠道恢复后会回到配置的权重。
 func (lb *LoadBalancer) adjustWeights() {
 	channels := lb.cache.GetAllChannels()
 
+	lb.weightsMu.Lock()
+	defer lb.weightsMu.Unlock()
+
 	for _, ch := range channels {
-		successRate := ch.Metrics.GetSuccessRate()
-
-		// 根据成功率调整权重
-		baseWeight := ch.Weight
-
-		if successRate >= 95 {
-			// 优秀，增加权重
-			ch.Weight = int(math.Ceil(float64(baseWeight) * 1.1))
-		} else if successRate >= 80 {
-			// 良好，保持
-			ch.Weight = baseWeight
-		} else if successRate >= 50 {
-			// 一般，降低权重
-			ch.Weight = int(math.Floor(float64(baseWeight) * 0.9))
+		if ch.BaseWeight <= 0 {
+			ch.BaseWeight = ch.Weight
+		}
+
+		wf, ok := lb.weightFactors[ch.ID]
+		if !ok {
+			wf = &weightFactor{factor: 1}
+			lb.weightFactors[ch.ID] = wf
+		}
+
+		total := atomic.LoadInt64(&ch.Metrics.TotalRequests)
+		success := atomic.LoadInt64(&ch.Metrics.SuccessfulRequests)
+		if total < wf.lastTotal {
+			// 渠道重新加载后指标从零开始
+			wf.lastTotal, wf.lastSuccess = 0, 0
+		}
+		requests := total - wf.lastTotal
+		succeeded := success - wf.lastSuccess
+		wf.lastTotal, wf.lastSuccess = total, success
+
+		if requests <= 0 {
+			// 没有新请求，向 1.0 回归
+			wf.factor = decayFactor(wf.factor)
 		} else {
-			// 差，大幅降低
-			ch.Weight = int(math.Max(1, float64(baseWeight)*0.5))
+			// 根据本周期成功率调整系数
+			successRate := float64(succeeded) / float64(requests) * 100
+			if successRate >= 95 {
+				// 优秀，提高系数；低于 1.0 时先回归到 1.0
+				if wf.factor < 1 {
+					wf.factor = decayFactor(wf.factor)
+				} else {
+					wf.factor *= 1.1
+				}
+			} else if successRate >= 80 {
+				// 良好，向 1.0 回归
+				wf.factor = decayFactor(wf.factor)
+			} else if successRate >= 50 {
+				// 一般，降低系数
+				wf.factor *= 0.9
+			} else {
+				// 差，大幅降低
+				wf.factor *= 0.5
+			}
 		}
+		wf.factor = lb.clampFactor(wf.factor)
+
+		ch.Weight = effectiveWeight(ch.BaseWeight, wf.factor)
 	}
 
 	lb.logFunc("info", "Weight adjustment completed")
 }
 
+// clampFactor 将系数限制在 [MinFactor, MaxFactor]，未配置（非正数）的一侧不限制
+func (lb *LoadBalancer) clampFactor(factor float64) float64 {
+	if lb.config.MinFactor > 0 {
+		factor = math.Max(lb.config.MinFactor, factor)
+	}
+	if lb.config.MaxFactor > 0 {
+		factor = math.Min(lb.config.MaxFactor, factor)
+	}
+	return factor
+}
+
+// decayFactor 将系数向 1.0 回归一半，足够接近时直接回到 1.0
+func decayFactor(factor float64) float64 {
+	factor = 1 + (factor-1)/2
+	if math.Abs(factor-1) < 0.05 {
+		return 1
+	}
+	return factor
+}
+
+// effectiveWeight 基础权重乘以系数，至少为 1
+func effectiveWeight(base int, factor float64) int {
+	return int(math.Max(1, math.Round(float64(base)*factor)))
+}
+
+// GetEffectiveWeights 获取每个渠道的基础权重、系数与有效权重，按渠道 ID 排序
+func (lb *LoadBalancer) GetEffectiveWeights() []EffectiveWeight {
+	channels := lb.cache.GetAllChannels()
+
+	lb.weightsMu.Lock()
+	defer lb.weightsMu.Unlock()
+
+	weights := make([]EffectiveWeight, 0, len(channels))
+	for _, ch := range channels {
+		base := ch.BaseWeight
+		if base <= 0 {
+			base = ch.Weight
+		}
+		factor := 1.0
+		if wf, ok := lb.weightFactors[ch.ID]; ok {
+			factor = wf.factor
+		}
+		weights = append(weights, EffectiveWeight{
+			ChannelID:       ch.ID,
+			BaseWeight:      base,
+			Factor:          factor,
+			EffectiveWeight: ch.Weight,
+		})
+	}
+	sort.Slice(weights, func(i, j int) bool {
+		return weights[i].ChannelID < weights[j].ChannelID
+	})
+	return weights
+}
+
 // LoadBalancerManager 负载均衡器管理器
 type LoadBalancerManager struct {
 	// 负载均衡器映射
@@ -628,12 +1023,3 @@ func (lbm *LoadBalancerManager) GetAllStatistics() map[string]map[string]interfa
 
 	return result
 }
-
-// hashString 计算字符串哈希值
-func hashString(s string) uint64 {
-	hash := uint64(5381)
-	for _, c := range s {
-		hash = ((hash << 5) + hash) + uint64(c)
-	}
-	return hash
-}
@@ -1,8 +1,9 @@
 package relay
 
 import (
+	"context"
+	"sync/atomic"
 	"testing"
-	"time"
 )
 
 func TestLoadBalancerWeightedRoundRobin(t *testing.T) {
@@ -86,11 +87,9 @@ func TestLoadBalancerLeastConnection(t *testing.T) {
 
 	ch1 := NewChannel("ch-1", "Channel 1", "https://api1.test.com", "openai")
 	ch1.Ability.SupportedModels = []string{"gpt-4"}
-	ch1.RecordConcurrency(5)
 
 	ch2 := NewChannel("ch-2", "Channel 2", "https://api2.test.com", "openai")
 	ch2.Ability.SupportedModels = []string{"gpt-4"}
-	ch2.RecordConcurrency(2)
 
 	cache.AddChannel(ch1)
 	cache.AddChannel(ch2)
@@ -100,6 +99,15 @@ func TestLoadBalancerLeastConnection(t *testing.T) {
 
 	lb := NewLoadBalancer(cache, config)
 
+	// 按并发槽位中进行中的请求数选择
+	for ch, n := range map[*Channel]int{ch1: 5, ch2: 2} {
+		for i := 0; i < n; i++ {
+			if _, err := lb.acquireSlot(context.Background(), ch, 0); err != nil {
+				t.Fatalf("acquireSlot failed: %v", err)
+			}
+		}
+	}
+
 	options := &ChannelSelectOptions{
 		ChannelType: "openai",
 		Model:       "gpt-4",
@@ -309,6 +317,83 @@ func TestLoadBalancerConfig(t *testing.T) {
 	}
 }
 
+// recordInterval 模拟一个调整周期内的请求结果
+func recordInterval(ch *Channel, success, failure int64) {
+	atomic.AddInt64(&ch.Metrics.TotalRequests, success+failure)
+	atomic.AddInt64(&ch.Metrics.SuccessfulRequests, success)
+	atomic.AddInt64(&ch.Metrics.FailedRequests, failure)
+}
+
+func TestAdjustWeightsFailThenRecover(t *testing.T) {
+	cache := NewChannelCache(ChannelCacheLevelMemory)
+	ch := NewChannel("ch-1", "Channel 1", "https://api1.test.com", "openai")
+	ch.Weight = 10
+	cache.AddChannel(ch)
+
+	config := DefaultLoadBalancerConfig()
+	config.EnableHealthCheck = false
+	lb := NewLoadBalancer(cache, config)
+
+	// 持续失败：系数下降并停在 MinFactor
+	for i := 0; i < 5; i++ {
+		recordInterval(ch, 0, 10)
+		lb.adjustWeights()
+	}
+	weights := lb.GetEffectiveWeights()
+	if len(weights) != 1 || weights[0].BaseWeight != 10 || weights[0].Factor != config.MinFactor || weights[0].EffectiveWeight != 3 {
+		t.Fatalf("Expected failing channel to bottom out at MinFactor, got %+v", weights)
+	}
+
+	// 恢复后回到配置的权重，而不是在降低后的权重上继续调整
+	for i := 0; i < 10 && ch.Weight != 10; i++ {
+		recordInterval(ch, 100, 0)
+		lb.adjustWeights()
+	}
+	if ch.Weight != 10 || lb.GetEffectiveWeights()[0].Factor != 1 {
+		t.Fatalf("Expected recovered channel to return to its base weight, got %+v", lb.GetEffectiveWeights()[0])
+	}
+
+	// 一直健康也不会无限增长
+	for i := 0; i < 50; i++ {
+		recordInterval(ch, 100, 0)
+		lb.adjustWeights()
+	}
+	if ch.Weight != 20 {
+		t.Errorf("Expected weight to be capped at base * MaxFactor, got %d", ch.Weight)
+	}
+
+	// 指标回归正常（没有新请求）后系数回到 1.0
+	for i := 0; i < 10; i++ {
+		lb.adjustWeights()
+	}
+	if w := lb.GetEffectiveWeights()[0]; w.Factor != 1 || w.EffectiveWeight != 10 {
+		t.Errorf("Expected factor to decay back to 1.0, got %+v", w)
+	}
+}
+
+func TestAdjustWeightsReloadedChannel(t *testing.T) {
+	cache := NewChannelCache(ChannelCacheLevelMemory)
+	ch := NewChannel("ch-1", "Channel 1", "https://api1.test.com", "openai")
+	ch.Weight = 4
+	cache.AddChannel(ch)
+	lb := NewLoadBalancer(cache, &LoadBalancerConfig{MinFactor: 0.25, MaxFactor: 2})
+
+	recordInterval(ch, 0, 10)
+	lb.adjustWeights()
+	if ch.Weight != 2 {
+		t.Fatalf("Expected weight 2 after a failing interval, got %d", ch.Weight)
+	}
+
+	// 重新加载的渠道以新配置的权重为基准，指标从零开始
+	reloaded := NewChannel("ch-1", "Channel 1", "https://api1.test.com", "openai")
+	reloaded.Weight = 8
+	cache.AddChannel(reloaded)
+	lb.adjustWeights()
+	if w := lb.GetEffectiveWeights()[0]; w.BaseWeight != 8 || w.Factor != 0.75 || w.EffectiveWeight != 6 {
+		t.Errorf("Expected reloaded channel to use its new base weight, got %+v", w)
+	}
+}
+
 func BenchmarkLoadBalancerSelection(b *testing.B) {
 	cache := NewChannelCache(ChannelCacheLevelMemory)
 
@@ -0,0 +1,121 @@
+package relay
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// 媒体请求的上限，与 OpenAI 接口保持一致
+const (
+	MaxImagesPerRequest      = 10
+	MaxSpeechInputChars      = 4096
+	MaxTranscriptionFileSize = 25 << 20 // 转写音频文件大小上限（字节）
+)
+
+// ImageGenerationRequest OpenAI 兼容的图像生成请求
+type ImageGenerationRequest struct {
+	Model          string `json:"model"`
+	Prompt         string `json:"prompt"`
+	N              int    `json:"n,omitempty"`
+	Size           string `json:"size,omitempty"`
+	Quality        string `json:"quality,omitempty"`
+	Style          string `json:"style,omitempty"`
+	ResponseFormat string `json:"response_format,omitempty"`
+	User           string `json:"user,omitempty"`
+}
+
+// Validate 校验必填字段与取值范围
+func (r *ImageGenerationRequest) Validate() error {
+	if r.Model == "" {
+		return ErrInvalidRequest{Message: "model is required"}
+	}
+	if strings.TrimSpace(r.Prompt) == "" {
+		return ErrInvalidRequest{Message: "prompt must not be empty"}
+	}
+	if r.N < 0 || r.N > MaxImagesPerRequest {
+		return ErrInvalidRequest{Message: fmt.Sprintf("n must be between 1 and %d", MaxImagesPerRequest)}
+	}
+	switch r.ResponseFormat {
+	case "", "url", "b64_json":
+	default:
+		return ErrInvalidRequest{Message: fmt.Sprintf("unsupported response_format %q", r.ResponseFormat)}
+	}
+	return nil
+}
+
+// Images 请求生成的图片数，未指定时为 1
+func (r *ImageGenerationRequest) Images() int {
+	if r.N <= 0 {
+		return 1
+	}
+	return r.N
+}
+
+// ImageData 单张生成的图片
+type ImageData struct {
+	URL           string `json:"url,omitempty"`
+	B64JSON       string `json:"b64_json,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+// ImageGenerationResponse OpenAI 兼容的图像生成响应
+type ImageGenerationResponse struct {
+	Created int64       `json:"created"`
+	Data    []ImageData `json:"data"`
+}
+
+// SpeechRequest OpenAI 兼容的语音合成请求
+type SpeechRequest struct {
+	Model          string  `json:"model"`
+	Input          string  `json:"input"`
+	Voice          string  `json:"voice"`
+	ResponseFormat string  `json:"response_format,omitempty"`
+	Speed          float64 `json:"speed,omitempty"`
+}
+
+// Validate 校验必填字段与取值范围
+func (r *SpeechRequest) Validate() error {
+	if r.Model == "" {
+		return ErrInvalidRequest{Message: "model is required"}
+	}
+	if strings.TrimSpace(r.Input) == "" {
+		return ErrInvalidRequest{Message: "input must not be empty"}
+	}
+	if utf8.RuneCountInString(r.Input) > MaxSpeechInputChars {
+		return ErrInvalidRequest{Message: fmt.Sprintf("input must not exceed %d characters", MaxSpeechInputChars)}
+	}
+	if r.Voice == "" {
+		return ErrInvalidRequest{Message: "voice is required"}
+	}
+	if r.Speed != 0 && (r.Speed < 0.25 || r.Speed > 4) {
+		return ErrInvalidRequest{Message: "speed must be between 0.25 and 4.0"}
+	}
+	return nil
+}
+
+// TranscriptionRequest 语音转写请求，音频文件以流的形式转发给上游，不在内存中缓存
+type TranscriptionRequest struct {
+	Model    string            `json:"model"`
+	Filename string            `json:"filename"`
+	File     io.Reader         `json:"-"`      // 音频文件内容，只能读取一次
+	Fields   map[string]string `json:"fields"` // 其它表单字段（language、prompt、response_format 等），原样转发
+}
+
+// Validate 校验必填字段
+func (r *TranscriptionRequest) Validate() error {
+	if r.Model == "" {
+		return ErrInvalidRequest{Message: "model is required"}
+	}
+	if r.File == nil {
+		return ErrInvalidRequest{Message: "file is required"}
+	}
+	return nil
+}
+
+// MediaResponse 上游返回的非 JSON 对象响应（音频数据或转写文本），按上游的 Content-Type 原样返回
+type MediaResponse struct {
+	ContentType string
+	Body        []byte
+}
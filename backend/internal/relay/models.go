@@ -0,0 +1,80 @@
+package relay
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// ModelInfo OpenAI 兼容的模型对象
+type ModelInfo struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+
+	// Channels 提供该模型的渠道 ID（仅在调试时返回）
+	Channels []int `json:"channels,omitempty"`
+}
+
+// ModelList OpenAI 兼容的模型列表
+type ModelList struct {
+	Object string       `json:"object"`
+	Data   []*ModelInfo `json:"data"`
+}
+
+// CollectModels 汇总可用渠道的模型，按模型名去重
+//
+// 模型来自渠道的 SupportModels 与能力注册表中渠道默认版本的模型列表；
+// 已禁用的渠道以及 available 返回 false（如断路器打开）的渠道不参与汇总。
+// owned_by 与 created 取 ID 最小的渠道，保证多次调用结果一致。
+func CollectModels(channels []*model.Channel, registry *ChannelAbilityManager, available func(channelID string) bool, includeChannels bool) *ModelList {
+	sorted := make([]*model.Channel, len(channels))
+	copy(sorted, channels)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	byID := make(map[string]*ModelInfo)
+	for _, ch := range sorted {
+		if !ch.IsEnabled() {
+			continue
+		}
+		channelID := strconv.Itoa(ch.ID)
+		if available != nil && !available(channelID) {
+			continue
+		}
+
+		models := ch.GetSupportedModels()
+		if registry != nil {
+			if ability, err := registry.GetAbility(channelID, ""); err == nil {
+				models = append(models, ability.SupportedModels...)
+			}
+		}
+
+		for _, name := range models {
+			if name == "" || name == "*" {
+				continue
+			}
+			info, ok := byID[name]
+			if !ok {
+				info = &ModelInfo{
+					ID:      name,
+					Object:  "model",
+					Created: ch.CreatedAt.Unix(),
+					OwnedBy: ch.Type,
+				}
+				byID[name] = info
+			}
+			if includeChannels && (len(info.Channels) == 0 || info.Channels[len(info.Channels)-1] != ch.ID) {
+				info.Channels = append(info.Channels, ch.ID)
+			}
+		}
+	}
+
+	list := &ModelList{Object: "list", Data: make([]*ModelInfo, 0, len(byID))}
+	for _, info := range byID {
+		list.Data = append(list.Data, info)
+	}
+	sort.Slice(list.Data, func(i, j int) bool { return list.Data[i].ID < list.Data[j].ID })
+	return list
+}
@@ -0,0 +1,75 @@
+package relay
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+func TestCollectModels(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	channels := []*model.Channel{
+		{ID: 3, Type: "deepseek", SupportModels: "deepseek-chat, gpt-4o", Status: model.ChannelStatusEnabled, CreatedAt: created.Add(time.Hour)},
+		{ID: 1, Type: "openai", SupportModels: "gpt-4o,gpt-4o-mini", Status: model.ChannelStatusEnabled, CreatedAt: created},
+		{ID: 2, Type: "anthropic", SupportModels: "claude-3-opus", Status: model.ChannelStatusDisabled},
+		{ID: 4, Type: "openai", SupportModels: "o1", Status: model.ChannelStatusEnabled},
+		{ID: 5, Type: "ollama", Status: model.ChannelStatusEnabled},
+	}
+
+	// 渠道 5 未配置模型列表，模型来自能力注册表
+	registry := NewChannelAbilityManager()
+	if err := registry.RegisterAbility("5", &ChannelAbilityVersion{Version: "v1", SupportedModels: []string{"llama3", "gpt-4o"}}); err != nil {
+		t.Fatalf("RegisterAbility failed: %v", err)
+	}
+
+	// 渠道 4 断路器打开
+	available := func(id string) bool { return id != "4" }
+
+	list := CollectModels(channels, registry, available, true)
+	if list.Object != "list" {
+		t.Errorf("expected object list, got %s", list.Object)
+	}
+
+	got := make(map[string]*ModelInfo)
+	var ids []string
+	for _, m := range list.Data {
+		got[m.ID] = m
+		ids = append(ids, m.ID)
+		if m.Object != "model" {
+			t.Errorf("%s: expected object model, got %s", m.ID, m.Object)
+		}
+	}
+
+	wantIDs := []string{"deepseek-chat", "gpt-4o", "gpt-4o-mini", "llama3"}
+	if !reflect.DeepEqual(ids, wantIDs) {
+		t.Fatalf("expected models %v, got %v", wantIDs, ids)
+	}
+
+	gpt4o := got["gpt-4o"]
+	if gpt4o.OwnedBy != "openai" || gpt4o.Created != created.Unix() {
+		t.Errorf("gpt-4o should be attributed to the lowest channel ID, got owned_by=%s created=%d", gpt4o.OwnedBy, gpt4o.Created)
+	}
+	if !reflect.DeepEqual(gpt4o.Channels, []int{1, 3, 5}) {
+		t.Errorf("expected gpt-4o channels [1 3 5], got %v", gpt4o.Channels)
+	}
+	if got["llama3"].OwnedBy != "ollama" {
+		t.Errorf("expected llama3 owned by ollama, got %s", got["llama3"].OwnedBy)
+	}
+}
+
+func TestCollectModelsWithoutChannels(t *testing.T) {
+	channels := []*model.Channel{
+		{ID: 1, Type: "openai", SupportModels: "gpt-4o", Status: model.ChannelStatusEnabled},
+		{ID: 2, Type: "openai", Status: model.ChannelStatusEnabled}, // 支持所有模型，不列出通配符
+	}
+
+	list := CollectModels(channels, nil, nil, false)
+	if len(list.Data) != 1 || list.Data[0].ID != "gpt-4o" {
+		t.Fatalf("expected only gpt-4o, got %+v", list.Data)
+	}
+	if list.Data[0].Channels != nil {
+		t.Errorf("channels must only be included on request, got %v", list.Data[0].Channels)
+	}
+}
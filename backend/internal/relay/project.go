@@ -0,0 +1,191 @@
+package relay
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// 项目解析错误
+var (
+	ErrProjectNotFound  = errors.New("project not found or disabled")
+	ErrProjectForbidden = errors.New("project does not belong to token owner")
+)
+
+// ProjectLoader 按 ID 加载项目，不存在时返回 nil
+type ProjectLoader func(ctx context.Context, id int) (*model.Project, error)
+
+// ResolveProject 解析 Token 绑定的项目
+//
+// 项目只由 Token 决定，客户端无法通过请求参数切换项目；
+// 项目必须属于 Token 的所有者，否则拒绝请求。
+func ResolveProject(ctx context.Context, token *model.Token, load ProjectLoader) (*model.Project, error) {
+	if token == nil || !token.ProjectID.Valid {
+		return nil, nil
+	}
+
+	project, err := load(ctx, int(token.ProjectID.Int64))
+	if err != nil {
+		return nil, err
+	}
+	if project == nil || !project.IsActive() {
+		return nil, ErrProjectNotFound
+	}
+	if project.UserID != token.UserID {
+		return nil, ErrProjectForbidden
+	}
+
+	return project, nil
+}
+
+// ApplyProject 将项目配置合并到请求中，返回因字段锁定而被忽略的客户端字段
+//
+// 未锁定的字段：客户端传入的值优先，未传入时使用项目默认值；
+// 锁定的字段：始终使用项目的值，项目未设置时清空客户端的值（使用上游默认）。
+func ApplyProject(req *ChatCompletionRequest, project *model.Project) []string {
+	var ignored []string
+	locks := project.Locks
+	params := project.Params
+
+	if project.DefaultModel != "" && (locks.Model || req.Model == "") {
+		if req.Model != "" && req.Model != project.DefaultModel {
+			ignored = append(ignored, "model")
+		}
+		req.Model = project.DefaultModel
+	}
+
+	if applySystemPrompt(req, project.SystemPrompt, locks.SystemPrompt) {
+		ignored = append(ignored, "system_prompt")
+	}
+
+	if applyFloat(&req.Temperature, params.Temperature, locks.Temperature) {
+		ignored = append(ignored, "temperature")
+	}
+	if applyFloat(&req.TopP, params.TopP, locks.TopP) {
+		ignored = append(ignored, "top_p")
+	}
+	if applyFloat(&req.FrequencyPenalty, params.FrequencyPenalty, locks.FrequencyPenalty) {
+		ignored = append(ignored, "frequency_penalty")
+	}
+	if applyFloat(&req.PresencePenalty, params.PresencePenalty, locks.PresencePenalty) {
+		ignored = append(ignored, "presence_penalty")
+	}
+	if applyMaxTokens(req, params.MaxTokens, locks.MaxTokens) {
+		ignored = append(ignored, "max_tokens")
+	}
+	if applyString(&req.ReasoningEffort, params.ReasoningEffort, locks.ReasoningEffort) {
+		ignored = append(ignored, "reasoning_effort")
+	}
+
+	return ignored
+}
+
+// AppendSystemContext 将额外上下文（如知识库检索结果）追加到系统消息，没有系统消息时插入一条
+func AppendSystemContext(req *ChatCompletionRequest, text string) {
+	if text == "" {
+		return
+	}
+
+	for i := range req.Messages {
+		if req.Messages[i].Role == "system" {
+			req.Messages[i].AppendText(text)
+			return
+		}
+	}
+
+	req.Messages = append([]ChatMessage{{Role: "system", Content: text}}, req.Messages...)
+}
+
+// LastUserMessage 获取最后一条用户消息，用作知识库检索的查询
+func LastUserMessage(req *ChatCompletionRequest) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			return req.Messages[i].Content
+		}
+	}
+	return ""
+}
+
+// applySystemPrompt 合并系统提示词，返回客户端的系统消息是否被忽略
+func applySystemPrompt(req *ChatCompletionRequest, prompt string, locked bool) bool {
+	hasClientPrompt := false
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			hasClientPrompt = true
+			break
+		}
+	}
+
+	if !locked {
+		if prompt != "" && !hasClientPrompt {
+			req.Messages = append([]ChatMessage{{Role: "system", Content: prompt}}, req.Messages...)
+		}
+		return false
+	}
+
+	messages := make([]ChatMessage, 0, len(req.Messages)+1)
+	if prompt != "" {
+		messages = append(messages, ChatMessage{Role: "system", Content: prompt})
+	}
+	for _, m := range req.Messages {
+		if m.Role != "system" {
+			messages = append(messages, m)
+		}
+	}
+	req.Messages = messages
+
+	return hasClientPrompt
+}
+
+// applyFloat 合并浮点参数，返回客户端的值是否被忽略
+func applyFloat(field *float64, value *float64, locked bool) bool {
+	if !locked {
+		if *field == 0 && value != nil {
+			*field = *value
+		}
+		return false
+	}
+
+	var enforced float64
+	if value != nil {
+		enforced = *value
+	}
+	ignored := *field != 0 && *field != enforced
+	*field = enforced
+	return ignored
+}
+
+// applyMaxTokens 合并输出长度，锁定时同时约束 max_completion_tokens
+func applyMaxTokens(req *ChatCompletionRequest, value *int, locked bool) bool {
+	if !locked {
+		if req.MaxTokens == 0 && req.MaxCompletionTokens == 0 && value != nil {
+			req.MaxTokens = *value
+		}
+		return false
+	}
+
+	var enforced int
+	if value != nil {
+		enforced = *value
+	}
+	ignored := (req.MaxTokens != 0 && req.MaxTokens != enforced) ||
+		(req.MaxCompletionTokens != 0 && req.MaxCompletionTokens != enforced)
+	req.MaxTokens = enforced
+	req.MaxCompletionTokens = 0
+	return ignored
+}
+
+// applyString 合并字符串参数，返回客户端的值是否被忽略
+func applyString(field *string, value string, locked bool) bool {
+	if !locked {
+		if *field == "" {
+			*field = value
+		}
+		return false
+	}
+
+	ignored := *field != "" && *field != value
+	*field = value
+	return ignored
+}
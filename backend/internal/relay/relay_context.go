@@ -0,0 +1,415 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+type relayContextKey struct{}
+
+// 中转入口
+const (
+	EndpointChatCompletions = "chat.completions"
+	EndpointEmbeddings      = "embeddings"
+	EndpointMessages        = "messages" // Anthropic 原生 Messages API
+	EndpointImages          = "images.generations"
+	EndpointSpeech          = "audio.speech"
+	EndpointTranscriptions  = "audio.transcriptions"
+)
+
+// 请求优先级，空表示默认
+const (
+	PriorityInteractive = "interactive" // 交互式请求，对延迟敏感
+	PriorityBatch       = "batch"       // 批量任务，可排队
+)
+
+// SessionHeader 调用方声明会话 ID 的请求头，一致性哈希策略下同一会话尽量路由到同一渠道
+const SessionHeader = "X-Session-ID"
+
+// 路由特征：请求依赖的渠道能力
+const (
+	FeatureStream = "stream"
+	FeatureTools  = "tools"
+	FeatureImages = "images" // 图像生成，渠道需在能力中显式标记
+	FeatureAudio  = "audio"  // 语音合成与转写，渠道需在能力中显式标记
+)
+
+// RelayPolicy 请求的内容策略
+type RelayPolicy struct {
+	NoStore    bool   // 不保留请求与响应内容
+	Retention  string // 内容保留策略，见 model.ContentRetention*
+	Moderation string // 内容审核级别，空表示使用默认策略
+}
+
+// RelayAttempt 一次渠道尝试的结果
+type RelayAttempt struct {
+	ChannelID         int
+	ChannelName       string
+	UpstreamRequestID string
+	StartedAt         time.Time
+	Latency           time.Duration
+	Usage             *ChatUsage    // 计费用量，未产生用量时为 nil
+	UsageEstimated    bool          // Usage 按请求与已输出内容估算（流式响应上游未报告用量）
+	EstimatedUsage    *ChatUsage    // 上游报告了用量时同时记录的估算值，用于监控估算偏差
+	Err               error         // 尝试失败的原因，成功为 nil
+	Output            func() string // 已输出给客户端的内容，只在需要时生成
+}
+
+// RelayTimings 请求耗时
+//
+// 除总耗时外按阶段（见 LatencyPhase）累计：每次切换阶段只记录一次当前时间，
+// 之前的时间计入上一个阶段，因此各阶段相加等于总耗时。
+type RelayTimings struct {
+	StartedAt time.Time
+	FirstByte time.Duration // 流式请求首个数据块输出给客户端的耗时
+	Total     time.Duration
+
+	phases [latencyPhaseCount]time.Duration
+	phase  LatencyPhase // 当前所处的阶段
+	mark   time.Time    // 当前阶段的开始时间
+}
+
+// RelayContext 单次中转请求的上下文
+//
+// 在入口处由 RelayContextBuilder 创建，按指针贯穿渠道选择、参数适配与上游请求，
+// 各阶段把结果累积到输出字段中；请求结束时由统一日志与计费发布在同一处消费。
+type RelayContext struct {
+	RequestID string // 本系统请求 ID
+	Endpoint  string // 中转入口，见 Endpoint*
+
+	// 身份
+	UserID    int         // API Token 所有者，匿名调用为 0
+	TokenID   int         // 使用的 API Token
+	TokenName string      // API Token 名称
+	Group     string      // 用户分组
+	OrgID     int         // 所属组织，未加入组织为 0
+	ProjectID int         // Token 绑定的中转项目
+	ClientIP  string      // 调用方 IP，用于 Token IP 白名单校验
+	Headers   http.Header // 调用方请求头，只在渠道开启请求体日志时脱敏后记录
+	tokenKey  string      // API Token 密钥，只用于预检时重新校验 Token，不写入日志
+
+	admissionStart time.Time     // 开始等待准入的时间，未经过准入为零值
+	admissionWait  time.Duration // 等待准入的时长
+
+	// 路由输入
+	Model       string
+	Stream      bool
+	Features    []string          // 请求依赖的渠道能力，见 Feature*
+	Region      string            // 期望的渠道区域
+	Tags        map[string]string // 项目强制附加的元数据标签
+	AffinityKey string            // 会话亲和键，相同键尽量路由到同一渠道
+	Priority    string            // 请求优先级，见 Priority*
+
+	Policy RelayPolicy
+
+	// Request 调用方请求，由中转服务写入，只在渠道开启请求体日志时序列化记录
+	Request interface{}
+
+	// 累积输出
+	ChannelID         int             // 实际使用的渠道
+	ChannelName       string          // 实际使用的渠道名称
+	UpstreamRequestID string          // 上游提供方返回的请求 ID
+	CacheHit          bool            // 响应来自中转的响应缓存，没有调用上游
+	RequestedModel    string          // 请求的模型没有可用渠道而降级时为原模型，Model 为实际使用的模型
+	Warnings          []string        // 参数适配产生的警告（如被丢弃的参数）
+	Attempts          []*RelayAttempt // 按顺序记录的渠道尝试
+	Usage             *ChatUsage      // 最终计费用量
+	UsageEstimated    bool            // 计费用量为估算值，上游未报告用量
+	ReservationID     string          // 额度预留的交易 ID，未预留或已结算为空
+	Reserved          int64           // 上游调用前预留的额度
+	Cost              int64           // 计费额度，由计费发布方结算后回填
+	Timings           RelayTimings
+	Err               error // 请求最终的错误，成功为 nil
+}
+
+// WithRelayContext 将中转上下文放入 context，中转完成后调用方可读取累积的输出
+func WithRelayContext(ctx context.Context, rc *RelayContext) context.Context {
+	return context.WithValue(ctx, relayContextKey{}, rc)
+}
+
+// RelayContextFromContext 从 context 获取中转上下文
+func RelayContextFromContext(ctx context.Context) *RelayContext {
+	rc, _ := ctx.Value(relayContextKey{}).(*RelayContext)
+	return rc
+}
+
+// HashKey 一致性哈希使用的键：会话亲和键优先，其次为 API Token 所有者，匿名调用为空
+func (rc *RelayContext) HashKey() string {
+	if rc.AffinityKey != "" {
+		return "session:" + rc.AffinityKey
+	}
+	if rc.UserID > 0 {
+		return "user:" + strconv.Itoa(rc.UserID)
+	}
+	return ""
+}
+
+// TokenKey 入口处设置的 API Token 密钥，匿名调用为空
+func (rc *RelayContext) TokenKey() string {
+	return rc.tokenKey
+}
+
+// BeginAttempt 开始一次渠道尝试，之前尝试产生的渠道与警告会被覆盖
+func (rc *RelayContext) BeginAttempt(channelID int, channelName string, baseWarnings int) {
+	rc.ChannelID = channelID
+	rc.ChannelName = channelName
+	rc.Warnings = rc.Warnings[:baseWarnings]
+}
+
+// EnterPhase 进入请求耗时的下一个阶段
+func (rc *RelayContext) EnterPhase(p LatencyPhase) {
+	rc.Timings.enterPhase(p, time.Now())
+}
+
+// Latency 截至 now 的分阶段耗时，请求结束后调用方可继续计入自己的后处理时间
+func (rc *RelayContext) Latency(now time.Time) LatencyBreakdown {
+	return rc.Timings.breakdown(now)
+}
+
+// EndAttempt 记录一次渠道尝试的结果：成功进入后处理阶段，失败则回到渠道选择（可能切换渠道重试）
+func (rc *RelayContext) EndAttempt(start time.Time, usage *ChatUsage, err error, output func() string) *RelayAttempt {
+	if err == nil {
+		rc.EnterPhase(PhasePostProcess)
+	} else {
+		rc.EnterPhase(PhaseSelection)
+	}
+	attempt := &RelayAttempt{
+		ChannelID:         rc.ChannelID,
+		ChannelName:       rc.ChannelName,
+		UpstreamRequestID: rc.UpstreamRequestID,
+		StartedAt:         start,
+		Latency:           time.Since(start),
+		Usage:             usage,
+		Err:               err,
+		Output:            output,
+	}
+	rc.Attempts = append(rc.Attempts, attempt)
+	return attempt
+}
+
+// MarkFirstByte 记录首个数据块的输出时间，只记录一次
+func (rc *RelayContext) MarkFirstByte() {
+	if rc.Timings.FirstByte == 0 && !rc.Timings.StartedAt.IsZero() {
+		rc.Timings.FirstByte = time.Since(rc.Timings.StartedAt)
+	}
+}
+
+// Finish 结束请求，以最后一次尝试的用量作为计费用量
+func (rc *RelayContext) Finish(err error) {
+	rc.Err = err
+	now := time.Now()
+	rc.Timings.enterPhase(PhasePostProcess, now)
+	if !rc.Timings.StartedAt.IsZero() {
+		rc.Timings.Total = now.Sub(rc.Timings.StartedAt)
+	}
+	if n := len(rc.Attempts); n > 0 {
+		rc.Usage = rc.Attempts[n-1].Usage
+		rc.UsageEstimated = rc.Attempts[n-1].UsageEstimated
+	}
+}
+
+// RelayContextBuilder 在入口处构造中转上下文
+type RelayContextBuilder struct {
+	rc *RelayContext
+}
+
+// NewRelayContextBuilder 创建中转上下文构造器
+func NewRelayContextBuilder(requestID, endpoint string) *RelayContextBuilder {
+	return &RelayContextBuilder{rc: &RelayContext{RequestID: requestID, Endpoint: endpoint}}
+}
+
+// Token 设置 API Token 及其所有者，匿名调用传 nil
+func (b *RelayContextBuilder) Token(token *model.Token) *RelayContextBuilder {
+	if token == nil {
+		return b
+	}
+	b.rc.UserID = token.UserID
+	b.rc.TokenID = token.ID
+	b.rc.TokenName = token.Name
+	b.rc.tokenKey = token.TokenHash
+	return b
+}
+
+// Client 设置调用方 IP
+func (b *RelayContextBuilder) Client(ip string) *RelayContextBuilder {
+	b.rc.ClientIP = ip
+	return b
+}
+
+// Headers 设置调用方请求头
+func (b *RelayContextBuilder) Headers(header http.Header) *RelayContextBuilder {
+	b.rc.Headers = header
+	return b
+}
+
+// Group 设置用户分组与所属组织
+func (b *RelayContextBuilder) Group(group string, orgID int) *RelayContextBuilder {
+	b.rc.Group = group
+	b.rc.OrgID = orgID
+	return b
+}
+
+// Model 设置请求的模型，使用项目 Token 时可在项目默认模型补全后再设置
+func (b *RelayContextBuilder) Model(name string, stream bool, features ...string) *RelayContextBuilder {
+	b.rc.Model = name
+	b.rc.Stream = stream
+	b.rc.Features = features
+	return b
+}
+
+// Routing 设置区域、会话亲和键与优先级
+func (b *RelayContextBuilder) Routing(region, affinityKey, priority string) *RelayContextBuilder {
+	b.rc.Region = region
+	b.rc.AffinityKey = affinityKey
+	b.rc.Priority = priority
+	return b
+}
+
+// Affinity 设置会话亲和键
+func (b *RelayContextBuilder) Affinity(key string) *RelayContextBuilder {
+	b.rc.AffinityKey = key
+	return b
+}
+
+// Tags 设置元数据标签
+func (b *RelayContextBuilder) Tags(tags map[string]string) *RelayContextBuilder {
+	b.rc.Tags = tags
+	return b
+}
+
+// Admission 设置并发准入的等待：start 为开始等待的时间，请求耗时从此时算起
+func (b *RelayContextBuilder) Admission(start time.Time, wait time.Duration) *RelayContextBuilder {
+	b.rc.admissionStart = start
+	b.rc.admissionWait = wait
+	return b
+}
+
+// Policy 设置内容策略
+func (b *RelayContextBuilder) Policy(policy RelayPolicy) *RelayContextBuilder {
+	b.rc.Policy = policy
+	return b
+}
+
+// Build 校验并返回中转上下文
+func (b *RelayContextBuilder) Build() (*RelayContext, error) {
+	rc := b.rc
+	switch rc.Endpoint {
+	case EndpointChatCompletions, EndpointEmbeddings, EndpointMessages, EndpointImages, EndpointSpeech, EndpointTranscriptions:
+	default:
+		return nil, fmt.Errorf("unknown relay endpoint %q", rc.Endpoint)
+	}
+	switch rc.Priority {
+	case "", PriorityInteractive, PriorityBatch:
+	default:
+		return nil, fmt.Errorf("unknown relay priority %q", rc.Priority)
+	}
+	if rc.UserID < 0 || rc.TokenID < 0 || rc.OrgID < 0 {
+		return nil, errors.New("relay identity ids must not be negative")
+	}
+	if rc.TokenID > 0 && rc.UserID == 0 {
+		return nil, errors.New("relay token has no owner")
+	}
+	for key := range rc.Tags {
+		if key == "" {
+			return nil, errors.New("relay tag key must not be empty")
+		}
+	}
+	now := time.Now()
+	rc.Timings.StartedAt = now
+	rc.Timings.mark = now
+	if !rc.admissionStart.IsZero() && rc.admissionStart.Before(now) {
+		rc.Timings.StartedAt = rc.admissionStart
+		rc.Timings.phases[PhaseAdmission] = rc.admissionWait
+		rc.Timings.mark = rc.admissionStart.Add(rc.admissionWait)
+	}
+	// 准入之后到此时的请求解析计入渠道选择阶段
+	rc.Timings.phase = PhaseSelection
+	return rc, nil
+}
+
+// ChatFeatures Chat Completion 请求依赖的渠道能力
+func ChatFeatures(req *ChatCompletionRequest) []string {
+	var features []string
+	if req.Stream {
+		features = append(features, FeatureStream)
+	}
+	if len(req.Tools) > 0 || len(req.Functions) > 0 {
+		features = append(features, FeatureTools)
+	}
+	return features
+}
+
+// ChatAbilityFeatures Chat Completion 请求要求渠道具备的能力：带工具定义时需要 function_calling，
+// parallel_tool_calls 为 true 时还需要 parallel_functions，要求 JSON 输出时需要 json_mode，
+// 消息包含图片时需要 vision；不依赖这些能力时返回 nil
+func ChatAbilityFeatures(req *ChatCompletionRequest) map[ChannelAbilityFeature]bool {
+	features := make(map[ChannelAbilityFeature]bool)
+	if len(req.Tools) > 0 || len(req.Functions) > 0 {
+		features[FeatureFunctionCalling] = true
+		if req.ParallelToolCalls != nil && *req.ParallelToolCalls {
+			features[FeatureParallelFunctions] = true
+		}
+	}
+	if WantsJSON(req) {
+		features[FeatureJSONMode] = true
+	}
+	if HasImages(req) {
+		features[FeatureVision] = true
+	}
+	if len(features) == 0 {
+		return nil
+	}
+	return features
+}
+
+// UpstreamError 上游返回的错误，携带提供方请求 ID 以便用户报障时引用
+type UpstreamError struct {
+	StatusCode        int
+	Message           string
+	RequestID         string
+	ProviderRequestID string
+}
+
+// Error 实现 error 接口
+func (e *UpstreamError) Error() string {
+	if e.ProviderRequestID != "" {
+		return fmt.Sprintf("upstream error (status %d, provider request id %s): %s", e.StatusCode, e.ProviderRequestID, e.Message)
+	}
+	return fmt.Sprintf("upstream error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// ErrorDetails 构造错误详情，包含双方的请求 ID 以便用户报障时引用
+func ErrorDetails(err error, rc *RelayContext) map[string]interface{} {
+	payload := map[string]interface{}{
+		"message":    err.Error(),
+		"request_id": rc.RequestID,
+	}
+
+	var failoverErr *FailoverError
+	if errors.As(err, &failoverErr) {
+		payload["attempted_channels"] = failoverErr.Attempted
+	}
+
+	var interrupted *StreamInterruptedError
+	if errors.As(err, &interrupted) {
+		payload["error_class"] = interrupted.Class
+		payload["partial"] = interrupted.Partial
+		if interrupted.Partial {
+			payload["delivered_tokens"] = interrupted.DeliveredTokens
+		}
+	}
+
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		payload["upstream_status"] = upstreamErr.StatusCode
+		payload["provider_request_id"] = upstreamErr.ProviderRequestID
+	}
+
+	return payload
+}
@@ -160,6 +160,7 @@ func (rc *RequestClient) DoRequest(
 				return fmt.Errorf("no available channel")
 			}
 
+			var err error
 			respBody, respHeader, err = rc.doSingleRequest(ctx, channel, method, path, bytes.NewReader(bodyBytes), headers)
 			if err != nil {
 				lastErr = err
@@ -176,7 +177,32 @@ func (rc *RequestClient) DoRequest(
 
 	if err != nil {
 		atomic.AddInt64(&rc.failedRequests, 1)
-		return nil, nil, lastErr
+		// 返回最后一次响应，便于调用方读取上游的错误详情
+		return respBody, respHeader, lastErr
+	}
+
+	atomic.AddInt64(&rc.successRequests, 1)
+	return respBody, respHeader, nil
+}
+
+// DoRequestOnce 向首个可用渠道发送一次请求，不缓存请求体也不重试，
+// 用于上传文件等只能读取一次、不应整体读入内存的请求体
+func (rc *RequestClient) DoRequestOnce(
+	ctx context.Context,
+	method string,
+	path string,
+	body io.Reader,
+	headers map[string]string,
+) ([]byte, http.Header, error) {
+	channel := rc.SelectChannel("")
+	if channel == nil {
+		return nil, nil, fmt.Errorf("no available channel")
+	}
+
+	respBody, respHeader, err := rc.doSingleRequest(ctx, channel, method, path, body, headers)
+	if err != nil {
+		atomic.AddInt64(&rc.failedRequests, 1)
+		return respBody, respHeader, err
 	}
 
 	atomic.AddInt64(&rc.successRequests, 1)
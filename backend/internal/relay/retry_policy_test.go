@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
 )
 
 func TestRetryPolicyCalculateDelay(t *testing.T) {
@@ -222,7 +221,7 @@ func TestRetryWithCallback(t *testing.T) {
 					assert.True(t, retryCtx.IsLastAttempt)
 				}
 
-				if callCount < 5 {
+				if callCount < 4 {
 					return &RetryableError{StatusCode: 503}
 				}
 
@@ -274,7 +274,8 @@ func TestSSEManagerConcurrency(t *testing.T) {
 	wg.Wait()
 
 	stats := manager.GetStatistics()
-	assert.Equal(t, int64(10), stats["total_messages"])
+	// 每条广播消息都会投递给所有客户端
+	assert.Equal(t, int64(10*numGoroutines), stats["total_messages"])
 }
 
 func BenchmarkSSEManagerBroadcast(b *testing.B) {
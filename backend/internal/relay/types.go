@@ -1,18 +1,40 @@
 package relay
 
 // ChatMessage 代表对话中的一条消息
+//
+// content 可以是字符串或内容片段数组（文本与图片），片段数组的解析见 UnmarshalJSON。
 type ChatMessage struct {
-	Role    string `json:"role"`    // "system", "user", "assistant"
-	Content string `json:"content"`
+	Role       string            `json:"role"`    // "system", "user", "assistant", "tool"
+	Content    string            `json:"content"` // 文本内容，内容为片段数组时是其中文本片段的拼接
+	Parts      []ChatContentPart `json:"-"`       // 内容为片段数组时的全部片段（含图片），序列化时优先输出
+	Name       string            `json:"name,omitempty"`
+	ToolCalls  []ChatToolCall    `json:"tool_calls,omitempty"`   // 助手请求调用的工具
+	ToolCallID string            `json:"tool_call_id,omitempty"` // tool 消息对应的调用 ID
+}
+
+// ChatToolCall 助手消息中的一次工具调用
+type ChatToolCall struct {
+	Index    *int             `json:"index,omitempty"` // 流式增量中工具调用的序号，同一调用的参数分多个数据块输出
+	ID       string           `json:"id,omitempty"`   // 流式增量中只在调用的第一个数据块出现
+	Type     string           `json:"type,omitempty"` // 目前只有 "function"
+	Function ChatFunctionCall `json:"function"`
+}
+
+// ChatFunctionCall 工具调用的函数名与 JSON 编码的参数
+type ChatFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 // ChatCompletionRequest 标准的 OpenAI 格式请求
 type ChatCompletionRequest struct {
-	Model            string                 `json:"model" binding:"required"`
+	Model            string                 `json:"model"` // 使用项目 Token 时可省略，由项目默认模型补全
 	Messages         []ChatMessage          `json:"messages" binding:"required"`
 	Temperature      float64                `json:"temperature"`
 	TopP             float64                `json:"top_p"`
 	MaxTokens        int                    `json:"max_tokens"`
+	MaxCompletionTokens int                 `json:"max_completion_tokens"`
+	ReasoningEffort  string                 `json:"reasoning_effort"` // o 系列推理强度：low/medium/high
 	Stream           bool                   `json:"stream"`
 	FrequencyPenalty float64                `json:"frequency_penalty"`
 	PresencePenalty  float64                `json:"presence_penalty"`
@@ -20,6 +42,11 @@ type ChatCompletionRequest struct {
 	FunctionCall     interface{}            `json:"function_call"`
 	Tools            []map[string]interface{} `json:"tools"`
 	ToolChoice       interface{}            `json:"tool_choice"`
+	ParallelToolCalls *bool                 `json:"parallel_tool_calls,omitempty"` // 是否允许一次返回多个工具调用，设置为 true 时只选择支持并行调用的渠道
+	ResponseFormat   map[string]interface{} `json:"response_format,omitempty"` // 输出格式，type 为 json_object 或 json_schema 时只选择支持 JSON 模式的渠道
+	ExtraBody        map[string]interface{} `json:"extra_body,omitempty"` // 提供方特有参数，按渠道 allowlist 透传
+	Cache            bool                   `json:"cache,omitempty"`      // 使用中转的响应缓存，不转发给上游
+	StrictJSON       bool                   `json:"strict_json,omitempty"` // 由中转校验输出是否为合法 JSON，不合法时追加提示重试一次，不转发给上游
 }
 
 // ChatCompletionResponse 标准的 OpenAI 格式响应
@@ -34,12 +61,34 @@ type ChatCompletionResponse struct {
 		Delta        *ChatMessage `json:"delta,omitempty"`
 		FinishReason string      `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage ChatUsage `json:"usage"`
 	Error *ErrorResponse `json:"error,omitempty"`
+	// Warnings 因模型不支持而被丢弃的参数等提示
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ChatUsage Token 使用量
+type ChatUsage struct {
+	PromptTokens            int                      `json:"prompt_tokens"`
+	CompletionTokens        int                      `json:"completion_tokens"`
+	TotalTokens             int                      `json:"total_tokens"`
+	CompletionTokensDetails *CompletionTokensDetails `json:"completion_tokens_details,omitempty"`
+
+	// Units 按次计费的计费单位数（如生成的图片数），0 视为 1，不返回给客户端
+	Units int `json:"-"`
+}
+
+// CompletionTokensDetails 输出 Token 明细
+type CompletionTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
+// ReasoningTokens 推理 Token 数（已包含在 CompletionTokens 中）
+func (u *ChatUsage) ReasoningTokens() int {
+	if u.CompletionTokensDetails == nil {
+		return 0
+	}
+	return u.CompletionTokensDetails.ReasoningTokens
 }
 
 // ErrorResponse 错误响应
@@ -0,0 +1,161 @@
+package relaylog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxBodyBytes 请求体与响应体默认保留的最大字节数
+const DefaultMaxBodyBytes = 4096
+
+// Redacted 替换敏感内容的占位符
+const Redacted = "[REDACTED]"
+
+// sensitiveFields 需要整体替换的请求头与 JSON 字段（小写，"-" 视为 "_"）
+var sensitiveFields = map[string]bool{
+	"authorization":       true,
+	"proxy_authorization": true,
+	"cookie":              true,
+	"set_cookie":          true,
+	"x_api_key":           true,
+	"x_goog_api_key":      true,
+	"api_key":             true,
+	"apikey":              true,
+	"key":                 true,
+	"token":               true,
+	"access_token":        true,
+	"refresh_token":       true,
+	"id_token":            true,
+	"secret":              true,
+	"client_secret":       true,
+	"password":            true,
+}
+
+// sensitiveSuffixes 以这些后缀结尾的字段同样视为敏感字段（如 openai_api_key、webhook_secret）
+var sensitiveSuffixes = []string{"_api_key", "_secret", "_password"}
+
+// credentialPattern 出现在任意文本中的 Bearer / Basic 凭证
+var credentialPattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=\-]+`)
+
+// keyPatterns 出现在任意文本中的 API Key 格式
+var keyPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{8,}`),
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{20,}`),
+}
+
+// isSensitive 字段名是否需要脱敏
+func isSensitive(name string) bool {
+	name = strings.ReplaceAll(strings.ToLower(name), "-", "_")
+	if sensitiveFields[name] {
+		return true
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactHeaders 复制请求头并替换敏感请求头的值，多个值以 ", " 连接
+func RedactHeaders(header http.Header) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+	redacted := make(map[string]string, len(header))
+	for name, values := range header {
+		if isSensitive(name) {
+			redacted[name] = Redacted
+			continue
+		}
+		redacted[name] = RedactText(strings.Join(values, ", "))
+	}
+	return redacted
+}
+
+// RedactText 替换文本中形如密钥的内容（Bearer 凭证、sk- 与 AIza 开头的 API Key）
+func RedactText(text string) string {
+	text = credentialPattern.ReplaceAllString(text, "$1 "+Redacted)
+	for _, pattern := range keyPatterns {
+		text = pattern.ReplaceAllString(text, Redacted)
+	}
+	return text
+}
+
+// RedactJSON 替换 JSON 中敏感字段的值，并对其余字符串值做文本脱敏；不是合法 JSON 时按文本脱敏
+func RedactJSON(data []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return RedactText(string(data))
+	}
+
+	out, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return RedactText(string(data))
+	}
+	return string(out)
+}
+
+// redactValue 递归脱敏 JSON 值
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isSensitive(key) {
+				v[key] = Redacted
+				continue
+			}
+			v[key] = redactValue(field)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+		return v
+	case string:
+		return RedactText(v)
+	default:
+		return v
+	}
+}
+
+// Truncate 将文本截断到 max 字节以内（不截断多字节字符），并注明截掉的字节数
+func Truncate(text string, max int) string {
+	if max <= 0 || len(text) <= max {
+		return text
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s...[truncated %d bytes]", text[:cut], len(text)-cut)
+}
+
+// Bodies 渠道开启请求体日志时记录的内容，均已脱敏和截断
+type Bodies struct {
+	RequestHeaders map[string]string `json:"request_headers,omitempty"`
+	RequestBody    string            `json:"request_body,omitempty"`
+	ResponseBody   string            `json:"response_body,omitempty"`
+}
+
+// Capture 序列化并脱敏请求，response 为响应文本或错误信息
+func Capture(header http.Header, request interface{}, response string, maxBytes int) *Bodies {
+	bodies := &Bodies{RequestHeaders: RedactHeaders(header)}
+	if request != nil {
+		if data, err := json.Marshal(request); err == nil {
+			bodies.RequestBody = Truncate(RedactJSON(data), maxBytes)
+		}
+	}
+	if response != "" {
+		bodies.ResponseBody = Truncate(RedactText(response), maxBytes)
+	}
+	return bodies
+}
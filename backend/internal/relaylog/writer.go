@@ -0,0 +1,169 @@
+package relaylog
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"go.uber.org/zap"
+)
+
+// Store 批量写入统一日志
+type Store interface {
+	CreateBatch(ctx context.Context, logs []*model.UnifiedLog) error
+}
+
+// Config 异步日志写入配置
+type Config struct {
+	BufferSize    int           // 待写入日志的缓冲数，满时丢弃新日志，不阻塞中转
+	BatchSize     int           // 每批写入的最大条数
+	FlushInterval time.Duration // 未攒满一批时的最长等待
+	MaxBodyBytes  int           // 记录请求体与响应体时每个字段保留的最大字节数
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		BufferSize:    10000,
+		BatchSize:     100,
+		FlushInterval: time.Second,
+		MaxBodyBytes:  DefaultMaxBodyBytes,
+	}
+}
+
+// Writer 中转日志的异步批量写入器
+//
+// Write 只把日志放入缓冲区，后台协程按批写入数据库；写入失败只记录告警，不重试。
+type Writer struct {
+	store Store
+	cfg   *Config
+
+	entries  chan *model.UnifiedLog
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+// NewWriter 创建异步日志写入器，需要调用 Start 启动后台写入
+func NewWriter(store Store, cfg *Config) *Writer {
+	defaults := DefaultConfig()
+	if cfg == nil {
+		cfg = defaults
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaults.BufferSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaults.BatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaults.FlushInterval
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = defaults.MaxBodyBytes
+	}
+
+	return &Writer{
+		store:   store,
+		cfg:     cfg,
+		entries: make(chan *model.UnifiedLog, cfg.BufferSize),
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// MaxBodyBytes 请求体与响应体的截断长度
+func (w *Writer) MaxBodyBytes() int {
+	return w.cfg.MaxBodyBytes
+}
+
+// Write 提交一条日志，缓冲区已满或写入器已停止时丢弃并返回 false
+func (w *Writer) Write(entry *model.UnifiedLog) bool {
+	select {
+	case <-w.stopCh:
+		w.dropped.Add(1)
+		return false
+	default:
+	}
+
+	select {
+	case w.entries <- entry:
+		return true
+	default:
+		w.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped 因缓冲区已满而丢弃的日志数
+func (w *Writer) Dropped() int64 {
+	return w.dropped.Load()
+}
+
+// Failed 写入数据库失败的日志数
+func (w *Writer) Failed() int64 {
+	return w.failed.Load()
+}
+
+// Start 启动后台批量写入
+func (w *Writer) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		ticker := time.NewTicker(w.cfg.FlushInterval)
+		defer ticker.Stop()
+
+		batch := make([]*model.UnifiedLog, 0, w.cfg.BatchSize)
+		for {
+			select {
+			case entry := <-w.entries:
+				batch = append(batch, entry)
+				if len(batch) >= w.cfg.BatchSize {
+					batch = w.flush(batch)
+				}
+			case <-ticker.C:
+				batch = w.flush(batch)
+			case <-w.stopCh:
+				// 写完缓冲区中剩余的日志
+				for {
+					select {
+					case entry := <-w.entries:
+						batch = append(batch, entry)
+						if len(batch) >= w.cfg.BatchSize {
+							batch = w.flush(batch)
+						}
+					default:
+						w.flush(batch)
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Stop 停止接收新日志，写完缓冲区后返回，可重复调用
+func (w *Writer) Stop() {
+	w.stopOnce.Do(func() { close(w.stopCh) })
+	w.wg.Wait()
+}
+
+// flush 写入一批日志，返回清空后的批次以便复用
+func (w *Writer) flush(batch []*model.UnifiedLog) []*model.UnifiedLog {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := w.store.CreateBatch(context.Background(), batch); err != nil {
+		w.failed.Add(int64(len(batch)))
+		logger.Warn("failed to write relay logs",
+			zap.Int("count", len(batch)),
+			zap.Error(err))
+	}
+	return batch[:0]
+}
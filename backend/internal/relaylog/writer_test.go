@@ -0,0 +1,106 @@
+package relaylog
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore 记录每一批写入，fail 为 true 时写入失败
+type fakeStore struct {
+	mu      sync.Mutex
+	batches [][]string
+	fail    bool
+	block   chan struct{}
+}
+
+func (f *fakeStore) CreateBatch(ctx context.Context, logs []*model.UnifiedLog) error {
+	if f.block != nil {
+		<-f.block
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return errors.New("database unavailable")
+	}
+	ids := make([]string, 0, len(logs))
+	for _, l := range logs {
+		ids = append(ids, l.RequestID)
+	}
+	f.batches = append(f.batches, ids)
+	return nil
+}
+
+func (f *fakeStore) written() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string(nil), f.batches...)
+}
+
+func TestWriterBatchesAndFlushesOnStop(t *testing.T) {
+	store := &fakeStore{}
+	w := NewWriter(store, &Config{BufferSize: 100, BatchSize: 3, FlushInterval: time.Hour})
+	w.Start()
+
+	for _, id := range []string{"r1", "r2", "r3", "r4", "r5"} {
+		require.True(t, w.Write(&model.UnifiedLog{RequestID: id}))
+	}
+	// 攒满一批立即写入，剩余的不足一批要等到间隔或停止
+	require.Eventually(t, func() bool { return len(store.written()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"r1", "r2", "r3"}, store.written()[0])
+
+	w.Stop()
+	assert.Equal(t, [][]string{{"r1", "r2", "r3"}, {"r4", "r5"}}, store.written())
+
+	// 停止后不再接收
+	assert.False(t, w.Write(&model.UnifiedLog{RequestID: "r6"}))
+	assert.EqualValues(t, 1, w.Dropped())
+	w.Stop()
+}
+
+func TestWriterFlushesOnInterval(t *testing.T) {
+	store := &fakeStore{}
+	w := NewWriter(store, &Config{BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+	w.Start()
+	defer w.Stop()
+
+	w.Write(&model.UnifiedLog{RequestID: "r1"})
+	require.Eventually(t, func() bool { return len(store.written()) == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestWriterDropsWhenBufferFull(t *testing.T) {
+	store := &fakeStore{block: make(chan struct{})}
+	w := NewWriter(store, &Config{BufferSize: 2, BatchSize: 1, FlushInterval: time.Hour})
+	w.Start()
+
+	// 第一条被后台取出后阻塞在写入中，缓冲区再放两条，之后的写入被丢弃
+	require.True(t, w.Write(&model.UnifiedLog{RequestID: "r1"}))
+	require.Eventually(t, func() bool { return len(w.entries) == 0 }, time.Second, time.Millisecond)
+	assert.True(t, w.Write(&model.UnifiedLog{RequestID: "r2"}))
+	assert.True(t, w.Write(&model.UnifiedLog{RequestID: "r3"}))
+	assert.False(t, w.Write(&model.UnifiedLog{RequestID: "r4"}))
+	assert.EqualValues(t, 1, w.Dropped())
+
+	close(store.block)
+	w.Stop()
+	assert.Equal(t, [][]string{{"r1"}, {"r2"}, {"r3"}}, store.written())
+}
+
+func TestWriterCountsFailedWrites(t *testing.T) {
+	store := &fakeStore{fail: true}
+	w := NewWriter(store, &Config{BatchSize: 10, FlushInterval: time.Hour})
+	w.Start()
+	w.Write(&model.UnifiedLog{RequestID: "r1"})
+	w.Write(&model.UnifiedLog{RequestID: "r2"})
+	w.Stop()
+
+	assert.EqualValues(t, 2, w.Failed())
+	assert.Empty(t, store.written())
+	assert.Equal(t, DefaultMaxBodyBytes, w.MaxBodyBytes())
+}
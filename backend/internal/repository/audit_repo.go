@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// AuditLogRepository 管理员操作审计日志（permission_audit_log）
+type AuditLogRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditLogRepository 创建审计日志 Repository
+func NewAuditLogRepository() *AuditLogRepository {
+	return &AuditLogRepository{
+		db: database.DB,
+	}
+}
+
+// Create 写入审计日志
+func (r *AuditLogRepository) Create(ctx context.Context, entry *model.PermissionAuditLog) error {
+	if err := r.db.WithContext(ctx).Create(entry).Error; err != nil {
+		logger.Error("Failed to create audit log", zap.Error(err), zap.String("operation", entry.Operation))
+		return err
+	}
+	return nil
+}
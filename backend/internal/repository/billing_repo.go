@@ -3,10 +3,13 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // BillingLogRepository 计费日志仓储
@@ -95,6 +98,184 @@ func (r *QuotaLogRepository) FindByUserID(ctx context.Context, userID int, limit
 	return logs, total, nil
 }
 
+// QuotaReservationRepository 额度预留仓储
+type QuotaReservationRepository struct {
+	db *gorm.DB
+}
+
+func NewQuotaReservationRepository() *QuotaReservationRepository {
+	return &QuotaReservationRepository{
+		db: database.DB,
+	}
+}
+
+// Create 保存预留
+func (r *QuotaReservationRepository) Create(ctx context.Context, reservation *model.QuotaReservation) error {
+	return r.db.WithContext(ctx).Create(reservation).Error
+}
+
+// DeleteByIDs 删除已结算或已释放的预留
+func (r *QuotaReservationRepository) DeleteByIDs(ctx context.Context, ids []string) error {
+	return r.db.WithContext(ctx).Where("id IN ?", ids).Delete(&model.QuotaReservation{}).Error
+}
+
+// FindAll 查询全部未结算的预留
+func (r *QuotaReservationRepository) FindAll(ctx context.Context) ([]*model.QuotaReservation, error) {
+	var reservations []*model.QuotaReservation
+	err := r.db.WithContext(ctx).Order("created_at").Find(&reservations).Error
+	return reservations, err
+}
+
+// BillingDeadLetterRepository 计费死信仓储
+type BillingDeadLetterRepository struct {
+	db *gorm.DB
+}
+
+func NewBillingDeadLetterRepository() *BillingDeadLetterRepository {
+	return &BillingDeadLetterRepository{
+		db: database.DB,
+	}
+}
+
+// Create 创建死信
+func (r *BillingDeadLetterRepository) Create(ctx context.Context, dl *model.BillingDeadLetter) error {
+	return r.db.WithContext(ctx).Create(dl).Error
+}
+
+// FindByID 根据 ID 查询
+func (r *BillingDeadLetterRepository) FindByID(ctx context.Context, id int64) (*model.BillingDeadLetter, error) {
+	var dl model.BillingDeadLetter
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&dl).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &dl, nil
+}
+
+// List 按用户、模型、状态过滤并分页查询，最早的在前
+func (r *BillingDeadLetterRepository) List(ctx context.Context, userID, modelName, status string, limit, offset int) ([]*model.BillingDeadLetter, int64, error) {
+	var dls []*model.BillingDeadLetter
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&model.BillingDeadLetter{})
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if modelName != "" {
+		query = query.Where("model_name = ?", modelName)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order("id").Limit(limit).Offset(offset).Find(&dls).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return dls, total, nil
+}
+
+// Update 更新死信
+func (r *BillingDeadLetterRepository) Update(ctx context.Context, dl *model.BillingDeadLetter) error {
+	return r.db.WithContext(ctx).Save(dl).Error
+}
+
+// RechargeInvoiceRepository 预付费充值发票仓储
+type RechargeInvoiceRepository struct {
+	db *gorm.DB
+}
+
+func NewRechargeInvoiceRepository() *RechargeInvoiceRepository {
+	return &RechargeInvoiceRepository{
+		db: database.DB,
+	}
+}
+
+// CreateInvoice 创建充值发票
+func (r *RechargeInvoiceRepository) CreateInvoice(ctx context.Context, invoice *model.RechargeInvoice) error {
+	return r.db.WithContext(ctx).Create(invoice).Error
+}
+
+// ListInvoices 分页查询用户的充值发票，最新的在前
+func (r *RechargeInvoiceRepository) ListInvoices(ctx context.Context, userID int, limit, offset int) ([]*model.RechargeInvoice, int64, error) {
+	var invoices []*model.RechargeInvoice
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&model.RechargeInvoice{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&invoices).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return invoices, total, nil
+}
+
+// MarkPaid 在事务中结算待支付的发票：verify 返回错误时将发票标记为 failed 并记录原因，不入账；
+// 否则标记为已支付并增加用户额度。发票不存在时返回 nil；已结算（paid 或 failed）的发票不再处理，
+// 只有本次调用完成入账时 credited 为 true
+func (r *RechargeInvoiceRepository) MarkPaid(ctx context.Context, sessionID, paymentIntentID, receiptURL string, verify func(invoice *model.RechargeInvoice) error) (*model.RechargeInvoice, bool, error) {
+	var invoice model.RechargeInvoice
+	credited := false
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("checkout_session_id = ?", sessionID).
+			First(&invoice).Error
+		if err != nil {
+			return err
+		}
+		if invoice.Status != model.RechargeInvoicePending {
+			return nil
+		}
+
+		invoice.PaymentIntentID = paymentIntentID
+		invoice.ReceiptURL = receiptURL
+		if err := verify(&invoice); err != nil {
+			invoice.Status = model.RechargeInvoiceFailed
+			invoice.FailureReason = err.Error()
+			return tx.Save(&invoice).Error
+		}
+
+		now := time.Now()
+		invoice.Status = model.RechargeInvoicePaid
+		invoice.PaidAt = &now
+		if err := tx.Save(&invoice).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&model.User{}).Where("id = ?", invoice.UserID).Updates(map[string]interface{}{
+			"quota":       gorm.Expr("quota + ?", invoice.Quota),
+			"total_quota": gorm.Expr("total_quota + ?", invoice.Quota),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("user %d not found", invoice.UserID)
+		}
+
+		credited = true
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &invoice, credited, nil
+}
+
 // InvoiceRepository 发票仓储
 type InvoiceRepository struct {
 	db *gorm.DB
@@ -171,3 +352,162 @@ func (r *PricingPlanRepository) GetActivePlan(ctx context.Context) (*model.Prici
 	return &plan, nil
 }
 
+
+// AlertRepository 配额预警通知偏好与投递记录仓储
+type AlertRepository struct {
+	db *gorm.DB
+}
+
+func NewAlertRepository() *AlertRepository {
+	return &AlertRepository{
+		db: database.DB,
+	}
+}
+
+// ListPreferences 查询用户的通知偏好
+func (r *AlertRepository) ListPreferences(ctx context.Context, userID string) ([]*model.AlertPreference, error) {
+	var prefs []*model.AlertPreference
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("channel").Find(&prefs).Error
+	return prefs, err
+}
+
+// UpsertPreference 创建或更新用户在某个渠道上的通知偏好
+func (r *AlertRepository) UpsertPreference(ctx context.Context, pref *model.AlertPreference) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "channel"}},
+		DoUpdates: clause.AssignmentColumns([]string{"target", "secret", "min_level", "enabled", "updated_at"}),
+	}).Create(pref).Error
+}
+
+// DeletePreference 删除用户在某个渠道上的通知偏好，返回是否存在
+func (r *AlertRepository) DeletePreference(ctx context.Context, userID, channel string) (bool, error) {
+	result := r.db.WithContext(ctx).Where("user_id = ? AND channel = ?", userID, channel).Delete(&model.AlertPreference{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// CreateAlert 保存触发的预警
+func (r *AlertRepository) CreateAlert(ctx context.Context, alert *model.QuotaAlertRecord) error {
+	return r.db.WithContext(ctx).Create(alert).Error
+}
+
+// CreateDelivery 保存投递结果
+func (r *AlertRepository) CreateDelivery(ctx context.Context, delivery *model.QuotaAlertDelivery) error {
+	return r.db.WithContext(ctx).Create(delivery).Error
+}
+
+// ListAlerts 分页查询用户的预警，最新的在前
+func (r *AlertRepository) ListAlerts(ctx context.Context, userID string, limit, offset int) ([]*model.QuotaAlertRecord, int64, error) {
+	var alerts []*model.QuotaAlertRecord
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&model.QuotaAlertRecord{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order("triggered_at DESC, id DESC").Limit(limit).Offset(offset).Find(&alerts).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return alerts, total, nil
+}
+
+// ListDeliveries 查询一组预警的投递记录
+func (r *AlertRepository) ListDeliveries(ctx context.Context, alertIDs []string) ([]*model.QuotaAlertDelivery, error) {
+	var deliveries []*model.QuotaAlertDelivery
+	if len(alertIDs) == 0 {
+		return deliveries, nil
+	}
+	err := r.db.WithContext(ctx).Where("alert_id IN ?", alertIDs).Order("id").Find(&deliveries).Error
+	return deliveries, err
+}
+
+// AutoRechargeRepository 自动充值配置与记录仓储
+type AutoRechargeRepository struct {
+	db *gorm.DB
+}
+
+func NewAutoRechargeRepository() *AutoRechargeRepository {
+	return &AutoRechargeRepository{
+		db: database.DB,
+	}
+}
+
+// FindSetting 查询用户的自动充值配置，不存在时返回 nil
+func (r *AutoRechargeRepository) FindSetting(ctx context.Context, userID int) (*model.AutoRechargeSetting, error) {
+	var setting model.AutoRechargeSetting
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&setting).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &setting, nil
+}
+
+// SaveSetting 创建或更新用户的自动充值配置
+func (r *AutoRechargeRepository) SaveSetting(ctx context.Context, setting *model.AutoRechargeSetting) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		UpdateAll: true,
+	}).Create(setting).Error
+}
+
+// ListEnabledSettings 查询启用了自动充值的配置
+func (r *AutoRechargeRepository) ListEnabledSettings(ctx context.Context) ([]*model.AutoRechargeSetting, error) {
+	var settings []*model.AutoRechargeSetting
+	err := r.db.WithContext(ctx).Where("enabled = ?", true).Find(&settings).Error
+	return settings, err
+}
+
+// Suspend 暂停用户的自动充值
+func (r *AutoRechargeRepository) Suspend(ctx context.Context, userID int, reason string) error {
+	return r.db.WithContext(ctx).Model(&model.AutoRechargeSetting{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]interface{}{
+			"suspended":      true,
+			"suspend_reason": reason,
+			"updated_at":     time.Now(),
+		}).Error
+}
+
+// SaveRecord 按 record_id 创建或更新充值记录
+func (r *AutoRechargeRepository) SaveRecord(ctx context.Context, record *model.AutoRechargeRecord) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "record_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "payment_intent_id", "error", "updated_at"}),
+	}).Create(record).Error
+}
+
+// FindUnfinishedRecord 查询用户最近一条 pending 或 charged 的充值记录，不存在时返回 nil
+func (r *AutoRechargeRepository) FindUnfinishedRecord(ctx context.Context, userID int) (*model.AutoRechargeRecord, error) {
+	var record model.AutoRechargeRecord
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND status IN ?", userID, []string{"pending", "charged"}).
+		Order("created_at DESC").
+		First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// CountCompleted 统计 since 之后完成的充值次数
+func (r *AutoRechargeRepository) CountCompleted(ctx context.Context, userID int, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.AutoRechargeRecord{}).
+		Where("user_id = ? AND status = ? AND created_at >= ?", userID, "completed", since).
+		Count(&count).Error
+	return count, err
+}
+
+// ListRecords 查询用户最近的充值记录
+func (r *AutoRechargeRepository) ListRecords(ctx context.Context, userID, limit int) ([]*model.AutoRechargeRecord, error) {
+	var records []*model.AutoRechargeRecord
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(&records).Error
+	return records, err
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// CapabilityProbeRepository 渠道能力探测记录
+type CapabilityProbeRepository struct {
+	db *gorm.DB
+}
+
+// NewCapabilityProbeRepository 创建能力探测 Repository
+func NewCapabilityProbeRepository() *CapabilityProbeRepository {
+	return &CapabilityProbeRepository{
+		db: database.DB,
+	}
+}
+
+// CreateBatch 保存一次探测的全部结果
+func (r *CapabilityProbeRepository) CreateBatch(ctx context.Context, probes []*model.ChannelCapabilityProbe) error {
+	if len(probes) == 0 {
+		return nil
+	}
+	if err := r.db.WithContext(ctx).Create(&probes).Error; err != nil {
+		logger.Error("Failed to save capability probes", zap.Error(err), zap.String("run_id", probes[0].RunID))
+		return err
+	}
+	return nil
+}
+
+// FindByRun 获取渠道某次探测的结果
+func (r *CapabilityProbeRepository) FindByRun(ctx context.Context, channelID int, runID string) ([]*model.ChannelCapabilityProbe, error) {
+	var probes []*model.ChannelCapabilityProbe
+	if err := r.db.WithContext(ctx).
+		Where("channel_id = ? AND run_id = ?", channelID, runID).
+		Order("id ASC").
+		Find(&probes).Error; err != nil {
+		logger.Error("Failed to find capability probes", zap.Error(err), zap.String("run_id", runID))
+		return nil, err
+	}
+	return probes, nil
+}
+
+// ListByChannel 按时间倒序获取渠道的探测历史，modelName 为空时返回所有模型
+func (r *CapabilityProbeRepository) ListByChannel(ctx context.Context, channelID int, modelName string, limit int) ([]*model.ChannelCapabilityProbe, error) {
+	query := r.db.WithContext(ctx).Where("channel_id = ?", channelID)
+	if modelName != "" {
+		query = query.Where("model = ?", modelName)
+	}
+
+	var probes []*model.ChannelCapabilityProbe
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&probes).Error; err != nil {
+		logger.Error("Failed to list capability probes", zap.Error(err), zap.Int("channel_id", channelID))
+		return nil, err
+	}
+	return probes, nil
+}
@@ -28,6 +28,9 @@ type ChannelAbilityRepository interface {
 
 	// FindEnabledByModel 查找指定模型的所有启用渠道
 	FindEnabledByModel(ctx context.Context, modelName string) ([]*model.ChannelAbility, error)
+
+	// UpdateFeatures 更新渠道某个模型（所有分组）的特性标记
+	UpdateFeatures(ctx context.Context, channelID int, modelName string, features map[string]interface{}) (int64, error)
 }
 
 // DefaultChannelAbilityRepository 默认实现
@@ -138,3 +141,15 @@ func (r *DefaultChannelAbilityRepository) FindEnabledByModel(ctx context.Context
 
 	return abilities, nil
 }
+
+// UpdateFeatures 更新渠道某个模型（所有分组）的特性标记，返回更新的行数
+func (r *DefaultChannelAbilityRepository) UpdateFeatures(ctx context.Context, channelID int, modelName string, features map[string]interface{}) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.ChannelAbility{}).
+		Where("channel_id = ? AND model = ?", channelID, modelName).
+		Updates(features)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to update ability features: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
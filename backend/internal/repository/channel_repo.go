@@ -132,6 +132,11 @@ func (r *ChannelRepository) Update(ctx context.Context, channel *model.Channel)
 	return r.db.WithContext(ctx).Save(channel).Error
 }
 
+// UpdateSupportModels 仅更新渠道支持的模型列表（模型发现使用，避免覆盖其他字段）
+func (r *ChannelRepository) UpdateSupportModels(ctx context.Context, id int, supportModels string) error {
+	return r.db.WithContext(ctx).Model(&model.Channel{}).Where("id = ?", id).Update("support_models", supportModels).Error
+}
+
 // Delete 软删除渠道
 func (r *ChannelRepository) Delete(ctx context.Context, id int) error {
 	return r.db.WithContext(ctx).Model(&model.Channel{}).Where("id = ?", id).Update("deleted_at", gorm.Expr("CURRENT_TIMESTAMP")).Error
@@ -197,3 +202,76 @@ func (r *ModelPriceRepository) Update(ctx context.Context, price *model.ModelPri
 func (r *ModelPriceRepository) Delete(ctx context.Context, id int) error {
 	return r.db.WithContext(ctx).Model(&model.ModelPrice{}).Where("id = ?", id).Update("deleted_at", gorm.Expr("CURRENT_TIMESTAMP")).Error
 }
+
+// ModelParamRuleRepository 模型参数适配规则仓库
+type ModelParamRuleRepository struct {
+	db *gorm.DB
+}
+
+// NewModelParamRuleRepository 创建模型参数适配规则仓库
+func NewModelParamRuleRepository() *ModelParamRuleRepository {
+	return &ModelParamRuleRepository{
+		db: database.DB,
+	}
+}
+
+// ListEnabled 获取所有启用的规则
+func (r *ModelParamRuleRepository) ListEnabled(ctx context.Context) ([]*model.ModelParamRule, error) {
+	var rules []*model.ModelParamRule
+	err := r.db.WithContext(ctx).Where("enabled = ?", true).Order("id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// ModelFallbackRuleRepository 模型降级路由规则仓库
+type ModelFallbackRuleRepository struct {
+	db *gorm.DB
+}
+
+// NewModelFallbackRuleRepository 创建模型降级路由规则仓库
+func NewModelFallbackRuleRepository() *ModelFallbackRuleRepository {
+	return &ModelFallbackRuleRepository{
+		db: database.DB,
+	}
+}
+
+// List 获取所有规则（包括禁用的）
+func (r *ModelFallbackRuleRepository) List(ctx context.Context) ([]*model.ModelFallbackRule, error) {
+	var rules []*model.ModelFallbackRule
+	err := r.db.WithContext(ctx).Order("id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// ListEnabled 获取所有启用的规则
+func (r *ModelFallbackRuleRepository) ListEnabled(ctx context.Context) ([]*model.ModelFallbackRule, error) {
+	var rules []*model.ModelFallbackRule
+	err := r.db.WithContext(ctx).Where("enabled = ?", true).Order("id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// GetByID 根据 ID 获取规则，不存在时返回 nil
+func (r *ModelFallbackRuleRepository) GetByID(ctx context.Context, id int) (*model.ModelFallbackRule, error) {
+	var rule model.ModelFallbackRule
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&rule).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// Create 创建规则
+func (r *ModelFallbackRuleRepository) Create(ctx context.Context, rule *model.ModelFallbackRule) error {
+	return r.db.WithContext(ctx).Create(rule).Error
+}
+
+// Update 更新规则
+func (r *ModelFallbackRuleRepository) Update(ctx context.Context, rule *model.ModelFallbackRule) error {
+	return r.db.WithContext(ctx).Save(rule).Error
+}
+
+// Delete 删除规则
+func (r *ModelFallbackRuleRepository) Delete(ctx context.Context, id int) error {
+	return r.db.WithContext(ctx).Delete(&model.ModelFallbackRule{}, id).Error
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// DataExportRepository 用户数据导出任务
+type DataExportRepository struct {
+	db *gorm.DB
+}
+
+// NewDataExportRepository 创建数据导出 Repository
+func NewDataExportRepository() *DataExportRepository {
+	return &DataExportRepository{
+		db: database.DB,
+	}
+}
+
+// Create 创建导出任务
+func (r *DataExportRepository) Create(ctx context.Context, job *model.DataExport) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+// FindByID 根据 ID 查询导出任务
+func (r *DataExportRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.DataExport, error) {
+	var job model.DataExport
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// FindLatestByUser 用户最近一次未失败的导出
+func (r *DataExportRepository) FindLatestByUser(ctx context.Context, userID int) (*model.DataExport, error) {
+	var job model.DataExport
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND status <> ?", userID, model.DataExportStatusFailed).
+		Order("created_at DESC").
+		First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Save 保存导出任务的状态与进度
+func (r *DataExportRepository) Save(ctx context.Context, job *model.DataExport) error {
+	return r.db.WithContext(ctx).Save(job).Error
+}
+
+// ListByStatus 按创建时间列出指定状态的导出任务
+func (r *DataExportRepository) ListByStatus(ctx context.Context, statuses ...string) ([]*model.DataExport, error) {
+	var jobs []*model.DataExport
+	err := r.db.WithContext(ctx).
+		Where("status IN ?", statuses).
+		Order("created_at ASC").
+		Find(&jobs).Error
+	return jobs, err
+}
+
+// ListExpired 已完成且在 before 之前过期的导出
+func (r *DataExportRepository) ListExpired(ctx context.Context, before time.Time) ([]*model.DataExport, error) {
+	var jobs []*model.DataExport
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND expires_at < ?", model.DataExportStatusCompleted, before).
+		Find(&jobs).Error
+	return jobs, err
+}
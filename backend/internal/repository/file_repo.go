@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// FileRepository 上传文件的元数据
+type FileRepository struct {
+	db *gorm.DB
+}
+
+// NewFileRepository 创建文件 Repository
+func NewFileRepository() *FileRepository {
+	return &FileRepository{
+		db: database.DB,
+	}
+}
+
+// Create 保存文件元数据，(user_id, hash) 唯一
+func (r *FileRepository) Create(ctx context.Context, file *model.File) error {
+	return r.db.WithContext(ctx).Create(file).Error
+}
+
+// FindByID 根据 ID 查询文件，不存在时返回 nil
+func (r *FileRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.File, error) {
+	var file model.File
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&file).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &file, nil
+}
+
+// FindByUserAndHash 查询用户已上传的相同内容，不存在时返回 nil
+func (r *FileRepository) FindByUserAndHash(ctx context.Context, userID int, hash string) (*model.File, error) {
+	var file model.File
+	err := r.db.WithContext(ctx).Where("user_id = ? AND hash = ?", userID, hash).First(&file).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &file, nil
+}
+
+// FindByUserID 按上传时间倒序分页查询用户的文件
+func (r *FileRepository) FindByUserID(ctx context.Context, userID int, page, pageSize int) ([]*model.File, int64, error) {
+	var files []*model.File
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&model.File{}).Where("user_id = ?", userID)
+
+	// 统计总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&files).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return files, total, nil
+}
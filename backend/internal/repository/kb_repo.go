@@ -110,6 +110,35 @@ func (r *KnowledgeBaseRepository) FindDocumentByID(ctx context.Context, id uuid.
 	return &doc, nil
 }
 
+// FindDocumentByDigest 根据内容摘要查找知识库中的文档
+func (r *KnowledgeBaseRepository) FindDocumentByDigest(ctx context.Context, kbID int, digest string) (*model.Document, error) {
+	var doc model.Document
+	if err := r.db.WithContext(ctx).
+		Where("kb_id = ? AND content_digest = ? AND deleted_at IS NULL", kbID, digest).
+		Order("created_at ASC").
+		First(&doc).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		logger.Error("Failed to find document by digest", zap.Error(err))
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// FindDocumentFingerprints 获取知识库中带 simhash 指纹的文档（仅查询去重所需字段）
+func (r *KnowledgeBaseRepository) FindDocumentFingerprints(ctx context.Context, kbID int) ([]*model.Document, error) {
+	var docs []*model.Document
+	if err := r.db.WithContext(ctx).
+		Select("id", "title", "content_digest", "simhash").
+		Where("kb_id = ? AND simhash IS NOT NULL AND deleted_at IS NULL", kbID).
+		Find(&docs).Error; err != nil {
+		logger.Error("Failed to find document fingerprints", zap.Error(err))
+		return nil, err
+	}
+	return docs, nil
+}
+
 // FindDocumentsByKBID 获取知识库的文档列表
 func (r *KnowledgeBaseRepository) FindDocumentsByKBID(ctx context.Context, kbID int, page, pageSize int) ([]*model.Document, int64, error) {
 	var docs []*model.Document
@@ -171,6 +200,24 @@ func (r *KnowledgeBaseRepository) CreateChunks(ctx context.Context, chunks []*mo
 	return nil
 }
 
+// ReplaceChunks 在同一事务中用新的文本块替换文档的全部文本块，沿用 ID 的文本块保持引用有效
+func (r *KnowledgeBaseRepository) ReplaceChunks(ctx context.Context, docID uuid.UUID, chunks []*model.DocumentChunk) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", docID).Delete(&model.DocumentChunk{}).Error; err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(chunks, 100).Error
+	})
+	if err != nil {
+		logger.Error("Failed to replace chunks", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
 // SearchChunksByVector 根据向量进行相似度搜索
 func (r *KnowledgeBaseRepository) SearchChunksByVector(ctx context.Context, kbID int, embedding []float64, limit int) ([]*model.KBSearchResult, error) {
 	var results []*model.KBSearchResult
@@ -200,6 +247,55 @@ func (r *KnowledgeBaseRepository) SearchChunksByVector(ctx context.Context, kbID
 	return results, nil
 }
 
+// SearchChunksByKeyword 按关键词搜索知识库中的文本块，结果的 KeywordScore 为 0 到 1 之间的相关度
+//
+// 默认使用 tsvector 全文搜索（ts_rank_cd 按词频与词距打分）；substring 为 true 时改用 pg_trgm 子串匹配，
+// 用于 simple 配置无法切分的中日韩文本，按三元组相似度打分。
+func (r *KnowledgeBaseRepository) SearchChunksByKeyword(ctx context.Context, kbID int, query string, substring bool, limit int) ([]*model.KBSearchResult, error) {
+	var results []*model.KBSearchResult
+
+	var err error
+	if substring {
+		err = r.db.WithContext(ctx).Raw(`
+		SELECT
+			c.id as chunk_id,
+			c.document_id,
+			d.title as document_title,
+			c.content,
+			word_similarity(?, c.content) as keyword_score,
+			c.metadata
+		FROM chunks c
+		INNER JOIN documents d ON c.document_id = d.id
+		WHERE d.kb_id = ? AND d.deleted_at IS NULL AND c.content ILIKE ? ESCAPE '\'
+		ORDER BY keyword_score DESC
+		LIMIT ?
+	`, query, kbID, "%"+escapeLike(query)+"%", limit).Scan(&results).Error
+	} else {
+		// ts_rank_cd 没有上界，rank / (rank + 1) 映射到 0 到 1 之间
+		err = r.db.WithContext(ctx).Raw(`
+		SELECT
+			c.id as chunk_id,
+			c.document_id,
+			d.title as document_title,
+			c.content,
+			ts_rank_cd(c.content_tsv, q) / (ts_rank_cd(c.content_tsv, q) + 1) as keyword_score,
+			c.metadata
+		FROM chunks c
+		INNER JOIN documents d ON c.document_id = d.id,
+			plainto_tsquery('simple', ?) q
+		WHERE d.kb_id = ? AND d.deleted_at IS NULL AND c.content_tsv @@ q
+		ORDER BY keyword_score DESC
+		LIMIT ?
+	`, query, kbID, limit).Scan(&results).Error
+	}
+	if err != nil {
+		logger.Error("Failed to search chunks by keyword", zap.Error(err))
+		return nil, err
+	}
+
+	return results, nil
+}
+
 // GetChunksByDocumentID 获取文档的所有文本块
 func (r *KnowledgeBaseRepository) GetChunksByDocumentID(ctx context.Context, docID uuid.UUID) ([]*model.DocumentChunk, error) {
 	var chunks []*model.DocumentChunk
@@ -247,3 +343,76 @@ func (r *KnowledgeBaseRepository) IncrementTotalChunks(ctx context.Context, kbID
 	}
 	return nil
 }
+
+// CreateIndexJob 创建文档索引任务
+func (r *KnowledgeBaseRepository) CreateIndexJob(ctx context.Context, job *model.DocumentIndexJob) error {
+	if err := r.db.WithContext(ctx).Create(job).Error; err != nil {
+		logger.Error("Failed to create index job", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// FindIndexJobByID 根据 ID 获取索引任务，不存在时返回 nil
+func (r *KnowledgeBaseRepository) FindIndexJobByID(ctx context.Context, id uuid.UUID) (*model.DocumentIndexJob, error) {
+	var job model.DocumentIndexJob
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		logger.Error("Failed to find index job", zap.Error(err))
+		return nil, err
+	}
+	return &job, nil
+}
+
+// FindLatestIndexJob 获取文档最近一次的索引任务，没有任务时返回 nil
+func (r *KnowledgeBaseRepository) FindLatestIndexJob(ctx context.Context, docID uuid.UUID) (*model.DocumentIndexJob, error) {
+	var job model.DocumentIndexJob
+	if err := r.db.WithContext(ctx).
+		Where("document_id = ?", docID).
+		Order("created_at DESC").
+		First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		logger.Error("Failed to find latest index job", zap.Error(err))
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListUnfinishedIndexJobs 按创建时间列出排队中与处理中的索引任务
+func (r *KnowledgeBaseRepository) ListUnfinishedIndexJobs(ctx context.Context) ([]*model.DocumentIndexJob, error) {
+	var jobs []*model.DocumentIndexJob
+	if err := r.db.WithContext(ctx).
+		Select("id", "document_id", "kb_id", "state", "created_at").
+		Where("state IN ?", []string{model.IndexJobQueued, model.IndexJobProcessing}).
+		Order("created_at ASC").
+		Find(&jobs).Error; err != nil {
+		logger.Error("Failed to list unfinished index jobs", zap.Error(err))
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// UpdateIndexJob 更新索引任务
+func (r *KnowledgeBaseRepository) UpdateIndexJob(ctx context.Context, job *model.DocumentIndexJob) error {
+	if err := r.db.WithContext(ctx).Save(job).Error; err != nil {
+		logger.Error("Failed to update index job", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// UpdateIndexJobProgress 更新索引任务已向量化的文本块数
+func (r *KnowledgeBaseRepository) UpdateIndexJobProgress(ctx context.Context, id uuid.UUID, chunksDone int) error {
+	if err := r.db.WithContext(ctx).
+		Model(&model.DocumentIndexJob{}).
+		Where("id = ?", id).
+		Update("chunks_done", chunksDone).Error; err != nil {
+		logger.Error("Failed to update index job progress", zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+type UnifiedLogRepository struct {
+	db *gorm.DB
+}
+
+func NewUnifiedLogRepository() *UnifiedLogRepository {
+	return &UnifiedLogRepository{
+		db: database.DB,
+	}
+}
+
+// LogFilter 日志查询条件
+type LogFilter struct {
+	UserID            int
+	ChannelID         int
+	ProjectID         int
+	LogType           int
+	ModelName         string
+	Status            string
+	RequestID         string
+	UpstreamRequestID string
+	StartTime         *time.Time
+	EndTime           *time.Time
+	Page              int
+	PageSize          int
+}
+
+// Create 写入日志
+func (r *UnifiedLogRepository) Create(ctx context.Context, log *model.UnifiedLog) error {
+	return r.db.WithContext(ctx).Create(log).Error
+}
+
+// CreateBatch 批量写入日志
+func (r *UnifiedLogRepository) CreateBatch(ctx context.Context, logs []*model.UnifiedLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(logs, len(logs)).Error
+}
+
+// List 分页查询日志
+func (r *UnifiedLogRepository) List(ctx context.Context, filter *LogFilter) ([]*model.UnifiedLog, int64, error) {
+	query := r.db.WithContext(ctx).Model(&model.UnifiedLog{})
+
+	if filter.UserID > 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.ChannelID > 0 {
+		query = query.Where("channel_id = ?", filter.ChannelID)
+	}
+	if filter.ProjectID > 0 {
+		query = query.Where("project_id = ?", filter.ProjectID)
+	}
+	if filter.LogType > 0 {
+		query = query.Where("log_type = ?", filter.LogType)
+	}
+	if filter.ModelName != "" {
+		query = query.Where("model_name = ?", filter.ModelName)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.UpstreamRequestID != "" {
+		query = query.Where("upstream_request_id = ?", filter.UpstreamRequestID)
+	}
+	if filter.StartTime != nil {
+		query = query.Where("created_at >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("created_at < ?", *filter.EndTime)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 || filter.PageSize > 100 {
+		filter.PageSize = 20
+	}
+
+	var logs []*model.UnifiedLog
+	err := query.Order("created_at DESC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&logs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
+
+// 用量报表的分组维度
+const (
+	UsageGroupByModel   = "model"
+	UsageGroupByDay     = "day"
+	UsageGroupByChannel = "channel"
+)
+
+// usageGroupExprs 各分组维度对应的 SQL 表达式，按天分组的键为 YYYY-MM-DD
+var usageGroupExprs = map[string]string{
+	UsageGroupByModel:   "model_name",
+	UsageGroupByDay:     "to_char(date_trunc('day', created_at), 'YYYY-MM-DD')",
+	UsageGroupByChannel: "channel_id::text",
+}
+
+// UsageFilter 用量聚合条件，时间范围为 [StartTime, EndTime)
+type UsageFilter struct {
+	UserID    int
+	GroupBy   string
+	StartTime time.Time
+	EndTime   time.Time
+	Limit     int
+	Offset    int
+}
+
+// UsageAggregate 一个分组的用量汇总，Cost 为额度（分）
+type UsageAggregate struct {
+	Key          string `json:"key"`
+	Name         string `json:"name,omitempty"` // 按渠道分组时的渠道名称
+	Requests     int64  `json:"requests"`
+	Cost         int64  `json:"cost"`
+	InputTokens  int64  `json:"input_tokens"`
+	OutputTokens int64  `json:"output_tokens"`
+}
+
+// AggregateUsage 按分组汇总用户的消费日志，分组按键升序分页；返回当前页、分组总数与整个范围的合计
+func (r *UnifiedLogRepository) AggregateUsage(ctx context.Context, filter *UsageFilter) ([]*UsageAggregate, int64, *UsageAggregate, error) {
+	expr, ok := usageGroupExprs[filter.GroupBy]
+	if !ok {
+		return nil, 0, nil, fmt.Errorf("unsupported group_by %q", filter.GroupBy)
+	}
+
+	// 命中 (user_id, log_type, created_at) 前缀的复合索引
+	base := func() *gorm.DB {
+		return r.db.WithContext(ctx).Model(&model.UnifiedLog{}).
+			Where("user_id = ? AND log_type = ? AND created_at >= ? AND created_at < ?",
+				filter.UserID, model.LogTypeConsume, filter.StartTime, filter.EndTime)
+	}
+
+	var totals UsageAggregate
+	err := base().Select("COUNT(*) AS requests, COALESCE(SUM(quota), 0) AS cost, " +
+		"COALESCE(SUM(prompt_tokens), 0) AS input_tokens, COALESCE(SUM(completion_tokens), 0) AS output_tokens").
+		Scan(&totals).Error
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	var groups int64
+	if err := base().Select("COUNT(DISTINCT " + expr + ")").Scan(&groups).Error; err != nil {
+		return nil, 0, nil, err
+	}
+
+	name := "''"
+	if filter.GroupBy == UsageGroupByChannel {
+		name = "MAX(channel_name)"
+	}
+
+	var rows []*UsageAggregate
+	err = base().Select(expr + " AS key, " + name + " AS name, COUNT(*) AS requests, COALESCE(SUM(quota), 0) AS cost, " +
+		"COALESCE(SUM(prompt_tokens), 0) AS input_tokens, COALESCE(SUM(completion_tokens), 0) AS output_tokens").
+		Group(expr).
+		Order(expr).
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	return rows, groups, &totals, nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// MessageBranchRepository 消息分支
+type MessageBranchRepository struct {
+	db *gorm.DB
+}
+
+// NewMessageBranchRepository 创建消息分支 Repository
+func NewMessageBranchRepository() *MessageBranchRepository {
+	return &MessageBranchRepository{
+		db: database.DB,
+	}
+}
+
+// Save 创建或更新分支
+func (r *MessageBranchRepository) Save(ctx context.Context, branch *model.MessageBranch) error {
+	return r.db.WithContext(ctx).Save(branch).Error
+}
+
+// FindByID 根据 ID 查询分支，不存在时返回 nil
+func (r *MessageBranchRepository) FindByID(ctx context.Context, id string) (*model.MessageBranch, error) {
+	var branch model.MessageBranch
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&branch).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &branch, nil
+}
+
+// FindByParentMessageID 获取从同一条消息分出的所有分支，按创建时间排序
+func (r *MessageBranchRepository) FindByParentMessageID(ctx context.Context, parentMessageID uuid.UUID) ([]*model.MessageBranch, error) {
+	var branches []*model.MessageBranch
+	err := r.db.WithContext(ctx).
+		Where("parent_message_id = ?", parentMessageID).
+		Order("created_at ASC").
+		Find(&branches).Error
+	return branches, err
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MessageEditRepository 消息编辑记录
+type MessageEditRepository struct {
+	db *gorm.DB
+}
+
+// NewMessageEditRepository 创建消息编辑 Repository
+func NewMessageEditRepository() *MessageEditRepository {
+	return &MessageEditRepository{
+		db: database.DB,
+	}
+}
+
+// Apply 在事务中修改消息内容并记录编辑
+//
+// 消息行以 SELECT ... FOR UPDATE 锁定，并发编辑依次执行，每条记录的原内容都是上一次编辑后的内容，
+// 不会丢失历史。truncateAfter 时将主线对话中该消息之后的消息标记为已删除（status = 3）。
+// 消息不存在时返回 nil。
+func (r *MessageEditRepository) Apply(ctx context.Context, edit *model.MessageEdit, truncateAfter bool) (*model.Message, error) {
+	var message model.Message
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", edit.MessageID).
+			First(&message).Error; err != nil {
+			return err
+		}
+
+		edit.SessionID = message.SessionID
+		edit.OriginalContent = message.Content
+
+		if truncateAfter {
+			result := tx.Model(&model.Message{}).
+				Where("session_id = ? AND branch_id IS NULL AND created_at > ? AND status <> 3", message.SessionID, message.CreatedAt).
+				Update("status", 3)
+			if result.Error != nil {
+				return result.Error
+			}
+			edit.TruncatedMessages = int(result.RowsAffected)
+		}
+
+		if err := tx.Create(edit).Error; err != nil {
+			return err
+		}
+
+		message.Content = edit.NewContent
+		message.UpdatedAt = time.Now()
+		return tx.Model(&message).Updates(map[string]interface{}{
+			"content":    message.Content,
+			"updated_at": message.UpdatedAt,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &message, nil
+}
+
+// ListByMessageID 按编辑顺序获取消息的编辑历史
+func (r *MessageEditRepository) ListByMessageID(ctx context.Context, messageID uuid.UUID) ([]*model.MessageEdit, error) {
+	edits := []*model.MessageEdit{}
+	err := r.db.WithContext(ctx).
+		Where("message_id = ?", messageID).
+		Order("edited_at ASC, id ASC").
+		Find(&edits).Error
+	return edits, err
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
@@ -38,12 +40,36 @@ func (r *MessageRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.
 	return &message, nil
 }
 
-// FindBySessionID 根据会话 ID 查询所有消息
+// FindByIDs 根据 ID 批量查询消息，按创建时间排序
+func (r *MessageRepository) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.Message, error) {
+	messages := []*model.Message{}
+	if len(ids) == 0 {
+		return messages, nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Order("created_at ASC").Find(&messages).Error
+	return messages, err
+}
+
+// FindBySessionID 根据会话 ID 查询主线对话的消息（不含分支中的消息）
 func (r *MessageRepository) FindBySessionID(ctx context.Context, sessionID uuid.UUID, page, pageSize int) ([]*model.Message, int64, error) {
+	query := r.db.WithContext(ctx).Where("session_id = ? AND branch_id IS NULL", sessionID)
+	return r.findPage(query, page, pageSize)
+}
+
+// FindBranchView 查询分支视图：主线中截至分支父消息的消息，加上分支内的消息
+func (r *MessageRepository) FindBranchView(ctx context.Context, sessionID uuid.UUID, parentCreatedAt time.Time, branchID string, page, pageSize int) ([]*model.Message, int64, error) {
+	query := r.db.WithContext(ctx).
+		Where("session_id = ?", sessionID).
+		Where("(branch_id IS NULL AND created_at <= ?) OR branch_id = ?", parentCreatedAt, branchID)
+	return r.findPage(query, page, pageSize)
+}
+
+// findPage 按创建时间分页查询消息
+func (r *MessageRepository) findPage(query *gorm.DB, page, pageSize int) ([]*model.Message, int64, error) {
 	var messages []*model.Message
 	var total int64
 
-	query := r.db.WithContext(ctx).Where("session_id = ?", sessionID).Order("created_at ASC")
+	query = query.Order("created_at ASC")
 
 	// 统计总数
 	if err := query.Model(&model.Message{}).Count(&total).Error; err != nil {
@@ -63,12 +89,40 @@ func (r *MessageRepository) FindBySessionID(ctx context.Context, sessionID uuid.
 	return messages, total, nil
 }
 
-// GetContextMessages 获取最近的 N 条上下文消息
+// GetContextMessages 获取主线对话最近的 N 条上下文消息
 func (r *MessageRepository) GetContextMessages(ctx context.Context, sessionID uuid.UUID, limit int) ([]*model.Message, error) {
-	var messages []*model.Message
+	query := r.db.WithContext(ctx).Where("session_id = ? AND status = 1 AND branch_id IS NULL", sessionID)
+	return r.latestMessages(query, limit)
+}
 
+// GetContextMessagesUntil 获取主线对话中截至 until（含）的最近 N 条上下文消息
+func (r *MessageRepository) GetContextMessagesUntil(ctx context.Context, sessionID uuid.UUID, until time.Time, limit int) ([]*model.Message, error) {
+	query := r.db.WithContext(ctx).
+		Where("session_id = ? AND status = 1 AND branch_id IS NULL AND created_at <= ?", sessionID, until)
+	return r.latestMessages(query, limit)
+}
+
+// FindPreviousUserMessage 主线对话中 before 之前的最后一条用户消息，不存在时返回 nil
+func (r *MessageRepository) FindPreviousUserMessage(ctx context.Context, sessionID uuid.UUID, before time.Time) (*model.Message, error) {
+	var message model.Message
 	err := r.db.WithContext(ctx).
-		Where("session_id = ? AND status = 1", sessionID).
+		Where("session_id = ? AND role = ? AND branch_id IS NULL AND created_at < ?", sessionID, "user", before).
+		Order("created_at DESC").
+		First(&message).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &message, nil
+}
+
+// latestMessages 查询最近的 N 条消息，按从旧到新返回
+func (r *MessageRepository) latestMessages(query *gorm.DB, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+
+	err := query.
 		Order("created_at DESC").
 		Limit(limit).
 		Find(&messages).Error
@@ -98,3 +152,98 @@ func (r *MessageRepository) UpdateStatus(ctx context.Context, id uuid.UUID, stat
 		Update("status", status).Error
 }
 
+// SessionModelUsage 会话按模型统计的 Token 用量
+type SessionModelUsage struct {
+	Model           string `json:"model"`
+	Messages        int64  `json:"messages"`
+	InputTokens     int64  `json:"input_tokens"`
+	OutputTokens    int64  `json:"output_tokens"`
+	ReasoningTokens int64  `json:"reasoning_tokens"`
+	TotalTokens     int64  `json:"total_tokens"`
+	Cost            int64  `json:"cost"`
+}
+
+// GetSessionUsage 按模型汇总会话的 Token 用量
+func (r *MessageRepository) GetSessionUsage(ctx context.Context, sessionID uuid.UUID) ([]*SessionModelUsage, error) {
+	var usage []*SessionModelUsage
+	err := r.db.WithContext(ctx).
+		Model(&model.Message{}).
+		Select("model, COUNT(*) AS messages, SUM(input_tokens) AS input_tokens, SUM(output_tokens) AS output_tokens, "+
+			"SUM(reasoning_tokens) AS reasoning_tokens, SUM(total_tokens) AS total_tokens, SUM(cost) AS cost").
+		Where("session_id = ? AND role = ? AND status = 1", sessionID, "assistant").
+		Group("model").
+		Order("model ASC").
+		Scan(&usage).Error
+	return usage, err
+}
+
+// MessageSearchHit 消息搜索结果
+type MessageSearchHit struct {
+	MessageID    uuid.UUID `json:"message_id"`
+	SessionID    uuid.UUID `json:"session_id"`
+	SessionTitle string    `json:"session_title"`
+	Role         string    `json:"role"`
+	Content      string    `json:"-"`       // 子串匹配时用于生成摘要
+	Snippet      string    `json:"snippet"` // 匹配内容以 <mark></mark> 标出
+	Rank         float64   `json:"rank"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// messageSearchScope 用户自己未删除会话中的正常消息
+const messageSearchScope = `FROM messages m
+JOIN sessions s ON s.id = m.session_id
+WHERE s.user_id = ? AND s.deleted_at IS NULL AND m.deleted_at IS NULL AND m.status = 1`
+
+// HasSearchLexemes 查询在 simple 全文搜索配置下是否产生词位（只有标点或空白时不产生）
+func (r *MessageRepository) HasSearchLexemes(ctx context.Context, query string) (bool, error) {
+	var nodes int
+	err := r.db.WithContext(ctx).Raw("SELECT numnode(plainto_tsquery('simple', ?))", query).Scan(&nodes).Error
+	return nodes > 0, err
+}
+
+// SearchFullText 按 tsvector 全文搜索用户的消息，按相关度排序
+func (r *MessageRepository) SearchFullText(ctx context.Context, userID int, query string, limit, offset int) ([]*MessageSearchHit, int64, error) {
+	var total int64
+	if err := r.db.WithContext(ctx).
+		Raw("SELECT COUNT(*) "+messageSearchScope+" AND m.content_tsv @@ plainto_tsquery('simple', ?)", userID, query).
+		Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	hits := []*MessageSearchHit{}
+	err := r.db.WithContext(ctx).Raw(`SELECT m.id AS message_id, m.session_id, s.title AS session_title, m.role, m.created_at,
+	ts_rank(m.content_tsv, plainto_tsquery('simple', ?)) AS rank,
+	ts_headline('simple', m.content, plainto_tsquery('simple', ?),
+		'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2') AS snippet
+`+messageSearchScope+` AND m.content_tsv @@ plainto_tsquery('simple', ?)
+ORDER BY rank DESC, m.created_at DESC
+LIMIT ? OFFSET ?`, query, query, userID, query, limit, offset).Scan(&hits).Error
+	return hits, total, err
+}
+
+// SearchSubstring 按子串匹配搜索用户的消息（使用 pg_trgm 索引），按三元组相似度排序
+//
+// 用于 simple 配置无法切分的中日韩文本，摘要由调用方根据 Content 生成。
+func (r *MessageRepository) SearchSubstring(ctx context.Context, userID int, query string, limit, offset int) ([]*MessageSearchHit, int64, error) {
+	pattern := "%" + escapeLike(query) + "%"
+
+	var total int64
+	if err := r.db.WithContext(ctx).
+		Raw("SELECT COUNT(*) "+messageSearchScope+` AND m.content ILIKE ? ESCAPE '\'`, userID, pattern).
+		Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	hits := []*MessageSearchHit{}
+	err := r.db.WithContext(ctx).Raw(`SELECT m.id AS message_id, m.session_id, s.title AS session_title, m.role, m.created_at,
+	m.content, word_similarity(?, m.content) AS rank
+`+messageSearchScope+` AND m.content ILIKE ? ESCAPE '\'
+ORDER BY rank DESC, m.created_at DESC
+LIMIT ? OFFSET ?`, query, userID, pattern, limit, offset).Scan(&hits).Error
+	return hits, total, err
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
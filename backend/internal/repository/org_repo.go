@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OrganizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository() *OrganizationRepository {
+	return &OrganizationRepository{
+		db: database.DB,
+	}
+}
+
+// Create 创建组织
+func (r *OrganizationRepository) Create(ctx context.Context, org *model.Organization) error {
+	return r.db.WithContext(ctx).Create(org).Error
+}
+
+// FindBySlug 根据标识查询组织
+func (r *OrganizationRepository) FindBySlug(ctx context.Context, slug string) (*model.Organization, error) {
+	var org model.Organization
+	err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&org).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+// UpsertMember 添加组织成员或更新其角色
+func (r *OrganizationRepository) UpsertMember(ctx context.Context, member *model.OrganizationMember) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "org_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "updated_at"}),
+	}).Create(member).Error
+}
+
+// FindSSOConfig 查询组织的 SSO 配置
+func (r *OrganizationRepository) FindSSOConfig(ctx context.Context, orgID int) (*model.OrgSSOConfig, error) {
+	var cfg model.OrgSSOConfig
+	err := r.db.WithContext(ctx).Where("org_id = ?", orgID).First(&cfg).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// SaveSSOConfig 保存组织的 SSO 配置
+func (r *OrganizationRepository) SaveSSOConfig(ctx context.Context, cfg *model.OrgSSOConfig) error {
+	return r.db.WithContext(ctx).Save(cfg).Error
+}
+
+// FindIdentity 根据发行方和主体查询外部身份
+func (r *OrganizationRepository) FindIdentity(ctx context.Context, issuer, subject string) (*model.UserIdentity, error) {
+	var identity model.UserIdentity
+	err := r.db.WithContext(ctx).Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// CreateIdentity 绑定外部身份
+func (r *OrganizationRepository) CreateIdentity(ctx context.Context, identity *model.UserIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+// ListIdentities 列出用户绑定的外部身份
+func (r *OrganizationRepository) ListIdentities(ctx context.Context, userID int) ([]*model.UserIdentity, error) {
+	var identities []*model.UserIdentity
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&identities).Error
+	return identities, err
+}
+
+// DeleteIdentity 解除用户在某发行方下的外部身份，返回是否删除了记录
+func (r *OrganizationRepository) DeleteIdentity(ctx context.Context, userID int, issuer string) (bool, error) {
+	result := r.db.WithContext(ctx).Where("user_id = ? AND issuer = ?", userID, issuer).Delete(&model.UserIdentity{})
+	return result.RowsAffected > 0, result.Error
+}
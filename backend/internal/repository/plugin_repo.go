@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// PluginRepository MCP 插件注册表
+type PluginRepository struct {
+	db *gorm.DB
+}
+
+// NewPluginRepository 创建插件 Repository
+func NewPluginRepository() *PluginRepository {
+	return &PluginRepository{
+		db: database.DB,
+	}
+}
+
+// Create 保存插件配置，name 唯一
+func (r *PluginRepository) Create(ctx context.Context, plugin *model.Plugin) error {
+	return r.db.WithContext(ctx).Create(plugin).Error
+}
+
+// FindAll 按名称获取全部插件配置
+func (r *PluginRepository) FindAll(ctx context.Context) ([]*model.Plugin, error) {
+	plugins := []*model.Plugin{}
+	err := r.db.WithContext(ctx).Order("name ASC").Find(&plugins).Error
+	return plugins, err
+}
+
+// UpdateEnabled 更新插件是否在服务启动时自动启动
+func (r *PluginRepository) UpdateEnabled(ctx context.Context, name string, enabled bool) error {
+	return r.db.WithContext(ctx).
+		Model(&model.Plugin{}).
+		Where("name = ?", name).
+		Update("enabled", enabled).Error
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ProjectRepository 处理中转项目相关的数据库操作
+type ProjectRepository struct {
+	db *gorm.DB
+}
+
+// NewProjectRepository 创建新的项目 Repository
+func NewProjectRepository() *ProjectRepository {
+	return &ProjectRepository{
+		db: database.DB,
+	}
+}
+
+// ProjectUsage 项目用量汇总（按模型）
+type ProjectUsage struct {
+	ModelName        string  `json:"model_name"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Quota            int64   `json:"quota"`
+	AvgUseTime       float64 `json:"avg_use_time"`
+}
+
+// Create 创建项目
+func (r *ProjectRepository) Create(ctx context.Context, project *model.Project) error {
+	if err := r.db.WithContext(ctx).Create(project).Error; err != nil {
+		logger.Error("Failed to create project", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// FindByID 根据 ID 获取未删除的项目
+func (r *ProjectRepository) FindByID(ctx context.Context, id int) (*model.Project, error) {
+	var project model.Project
+	if err := r.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", id).First(&project).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		logger.Error("Failed to find project by ID", zap.Error(err), zap.Int("id", id))
+		return nil, err
+	}
+	return &project, nil
+}
+
+// FindByUserID 获取用户的所有项目
+func (r *ProjectRepository) FindByUserID(ctx context.Context, userID int) ([]*model.Project, error) {
+	var projects []*model.Project
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND deleted_at IS NULL", userID).
+		Order("created_at DESC").
+		Find(&projects).Error; err != nil {
+		logger.Error("Failed to find projects by user ID", zap.Error(err))
+		return nil, err
+	}
+	return projects, nil
+}
+
+// Update 更新项目
+func (r *ProjectRepository) Update(ctx context.Context, project *model.Project) error {
+	if err := r.db.WithContext(ctx).Save(project).Error; err != nil {
+		logger.Error("Failed to update project", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// Delete 软删除项目
+func (r *ProjectRepository) Delete(ctx context.Context, id int) error {
+	if err := r.db.WithContext(ctx).Model(&model.Project{}).
+		Where("id = ?", id).
+		Update("deleted_at", time.Now()).Error; err != nil {
+		logger.Error("Failed to delete project", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// GetUsage 按模型汇总项目在时间范围内的用量
+func (r *ProjectRepository) GetUsage(ctx context.Context, projectID int, since time.Time) ([]*ProjectUsage, error) {
+	var usage []*ProjectUsage
+	if err := r.db.WithContext(ctx).Model(&model.UnifiedLog{}).
+		Select("model_name, COUNT(*) as requests, SUM(prompt_tokens) as prompt_tokens, SUM(completion_tokens) as completion_tokens, SUM(quota) as quota, AVG(use_time) as avg_use_time").
+		Where("project_id = ? AND created_at >= ?", projectID, since).
+		Group("model_name").
+		Order("requests DESC").
+		Scan(&usage).Error; err != nil {
+		logger.Error("Failed to get project usage", zap.Error(err))
+		return nil, err
+	}
+	return usage, nil
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// PromptVersionRepository 系统提示词版本历史
+type PromptVersionRepository struct {
+	db *gorm.DB
+}
+
+// NewPromptVersionRepository 创建提示词版本 Repository
+func NewPromptVersionRepository() *PromptVersionRepository {
+	return &PromptVersionRepository{
+		db: database.DB,
+	}
+}
+
+// Create 追加一个版本，(prompt_id, version) 唯一，并发写入同一版本号时失败
+func (r *PromptVersionRepository) Create(ctx context.Context, version *model.PromptVersion) error {
+	return r.db.WithContext(ctx).Create(version).Error
+}
+
+// FindByPromptID 按版本号升序获取提示词的全部版本
+func (r *PromptVersionRepository) FindByPromptID(ctx context.Context, promptID string) ([]*model.PromptVersion, error) {
+	versions := []*model.PromptVersion{}
+	err := r.db.WithContext(ctx).
+		Where("prompt_id = ?", promptID).
+		Order("version ASC").
+		Find(&versions).Error
+	return versions, err
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// relayBatchInsertSize 批量写入条目时每条 INSERT 的行数
+const relayBatchInsertSize = 200
+
+// RelayBatchRepository 批量 Chat Completion 任务
+type RelayBatchRepository struct {
+	db *gorm.DB
+}
+
+// NewRelayBatchRepository 创建批量任务 Repository
+func NewRelayBatchRepository() *RelayBatchRepository {
+	return &RelayBatchRepository{
+		db: database.DB,
+	}
+}
+
+// Create 在一个事务中创建任务及其全部条目
+func (r *RelayBatchRepository) Create(ctx context.Context, batch *model.RelayBatch, items []*model.RelayBatchItem) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(batch).Error; err != nil {
+			return err
+		}
+		return tx.CreateInBatches(items, relayBatchInsertSize).Error
+	})
+}
+
+// FindByID 根据 ID 查询任务
+func (r *RelayBatchRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.RelayBatch, error) {
+	var batch model.RelayBatch
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&batch).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// Save 保存任务状态
+func (r *RelayBatchRepository) Save(ctx context.Context, batch *model.RelayBatch) error {
+	return r.db.WithContext(ctx).Save(batch).Error
+}
+
+// ListByStatus 按创建时间列出指定状态的任务
+func (r *RelayBatchRepository) ListByStatus(ctx context.Context, statuses ...string) ([]*model.RelayBatch, error) {
+	var batches []*model.RelayBatch
+	err := r.db.WithContext(ctx).
+		Where("status IN ?", statuses).
+		Order("created_at ASC").
+		Find(&batches).Error
+	return batches, err
+}
+
+// ListItems 按输入顺序列出任务的条目，指定 statuses 时只列出这些状态的条目
+func (r *RelayBatchRepository) ListItems(ctx context.Context, batchID uuid.UUID, statuses ...string) ([]*model.RelayBatchItem, error) {
+	query := r.db.WithContext(ctx).Where("batch_id = ?", batchID)
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	var items []*model.RelayBatchItem
+	err := query.Order("idx ASC").Find(&items).Error
+	return items, err
+}
+
+// FinishItem 保存条目结果，并在同一事务中累加任务的成功或失败数
+func (r *RelayBatchRepository) FinishItem(ctx context.Context, item *model.RelayBatchItem) error {
+	counter := "completed"
+	if item.Status == model.RelayBatchStatusFailed {
+		counter = "failed"
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(item).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.RelayBatch{}).
+			Where("id = ?", item.BatchID).
+			UpdateColumn(counter, gorm.Expr(counter+" + 1")).Error
+	})
+}
+
+// SaveItem 保存条目
+func (r *RelayBatchRepository) SaveItem(ctx context.Context, item *model.RelayBatchItem) error {
+	return r.db.WithContext(ctx).Save(item).Error
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
@@ -38,12 +40,15 @@ func (r *SessionRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.
 	return &session, nil
 }
 
-// FindByUserID 查询用户的所有会话
-func (r *SessionRepository) FindByUserID(ctx context.Context, userID int, page, pageSize int) ([]*model.Session, int64, error) {
+// FindByUserID 按状态查询用户的会话，state 为 model.SessionState* 之一
+func (r *SessionRepository) FindByUserID(ctx context.Context, userID int, state string, page, pageSize int) ([]*model.Session, int64, error) {
 	var sessions []*model.Session
 	var total int64
 
-	query := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("updated_at DESC")
+	query, err := sessionStateQuery(r.db.WithContext(ctx), userID, state)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	// 统计总数
 	if err := query.Model(&model.Session{}).Count(&total).Error; err != nil {
@@ -64,9 +69,119 @@ func (r *SessionRepository) Update(ctx context.Context, session *model.Session)
 	return r.db.WithContext(ctx).Save(session).Error
 }
 
-// Delete 软删除会话
+// pinnedFirstOrder 置顶会话按 sort_order 排在前面，其余按更新时间倒序
+const pinnedFirstOrder = "pinned DESC, CASE WHEN pinned THEN sort_order END ASC, updated_at DESC"
+
+// sessionStateQuery 按状态筛选用户会话的查询，已删除的会话按删除时间倒序，其余置顶优先
+func sessionStateQuery(db *gorm.DB, userID int, state string) (*gorm.DB, error) {
+	query := db.Where("user_id = ?", userID)
+	switch state {
+	case "", model.SessionStateActive:
+		return query.Where("archived = ?", false).Order(pinnedFirstOrder), nil
+	case model.SessionStateArchived:
+		return query.Where("archived = ?", true).Order(pinnedFirstOrder), nil
+	case model.SessionStateDeleted:
+		return query.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC"), nil
+	default:
+		return nil, fmt.Errorf("unknown session state %q", state)
+	}
+}
+
+// Delete 软删除会话及其消息
+//
+// 会话与消息使用同一个 deleted_at，恢复时只恢复随会话删除的消息。
 func (r *SessionRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&model.Session{}, "id = ?", id).Error
+	now := time.Now().Truncate(time.Microsecond) // 与 Postgres 的时间精度一致，便于恢复时按值匹配
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Message{}).
+			Where("session_id = ?", id).
+			Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Session{}).
+			Where("id = ?", id).
+			Update("deleted_at", now).Error
+	})
+}
+
+// FindDeletedByID 查询已软删除的会话，不存在或未删除时返回 nil
+func (r *SessionRepository) FindDeletedByID(ctx context.Context, id uuid.UUID) (*model.Session, error) {
+	var session model.Session
+	err := r.db.WithContext(ctx).Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Restore 恢复软删除的会话及随其删除的消息
+func (r *SessionRepository) Restore(ctx context.Context, session *model.Session) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&model.Message{}).
+			Where("session_id = ? AND deleted_at = ?", session.ID, session.DeletedAt.Time).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&model.Session{}).
+			Where("id = ?", session.ID).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		session.DeletedAt = gorm.DeletedAt{}
+		return nil
+	})
+}
+
+// PurgeDeleted 永久删除在 before 之前软删除的会话，消息与分支随外键级联删除，返回删除的会话数
+func (r *SessionRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Delete(&model.Session{})
+	return result.RowsAffected, result.Error
+}
+
+// UpdateColumns 更新会话的指定字段，不修改 updated_at，置顶等操作不影响会话的最近排序
+func (r *SessionRepository) UpdateColumns(ctx context.Context, id uuid.UUID, columns map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&model.Session{}).
+		Where("id = ?", id).
+		UpdateColumns(columns).Error
+}
+
+// UpdateSortOrders 批量更新用户会话的 sort_order
+//
+// 任一会话不存在或不属于该用户时不做修改并返回 false。
+func (r *SessionRepository) UpdateSortOrders(ctx context.Context, userID int, orders map[uuid.UUID]int) (bool, error) {
+	ids := make([]uuid.UUID, 0, len(orders))
+	for id := range orders {
+		ids = append(ids, id)
+	}
+
+	owned := true
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.Session{}).
+			Where("user_id = ? AND id IN ?", userID, ids).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count != int64(len(ids)) {
+			owned = false
+			return nil
+		}
+
+		for id, order := range orders {
+			if err := tx.Model(&model.Session{}).
+				Where("id = ? AND user_id = ?", id, userID).
+				UpdateColumn("sort_order", order).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return owned, err
 }
 
 // UpdateTitle 更新会话标题
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// TokenRepository Token 仓储接口
+type TokenRepository interface {
+	// Create 创建 Token
+	Create(ctx context.Context, token *model.Token) error
+	// GetByID 根据 ID 获取 Token
+	GetByID(ctx context.Context, id int) (*model.Token, error)
+	// GetByHash 根据哈希获取 Token
+	GetByHash(ctx context.Context, tokenHash string) (*model.Token, error)
+	// GetByPreviousHash 根据轮换前的哈希获取 Token，宽限期由调用方检查
+	GetByPreviousHash(ctx context.Context, tokenHash string) (*model.Token, error)
+	// Update 更新 Token
+	Update(ctx context.Context, token *model.Token) error
+	// ListByUserID 列出用户的 Token
+	ListByUserID(ctx context.Context, userID int) ([]*model.Token, error)
+	// ListByProjectID 列出绑定到项目的 Token
+	ListByProjectID(ctx context.Context, projectID int) ([]*model.Token, error)
+	// CheckAndUpdateExpiredTokens 将已过期的 Token 标记为过期，返回更新数量
+	CheckAndUpdateExpiredTokens(ctx context.Context) (int, error)
+	// ListExpiringTokens 列出在 (from, to] 内过期且尚未通知的正常 Token
+	ListExpiringTokens(ctx context.Context, from, to time.Time) ([]*model.Token, error)
+	// MarkExpiryNotified 标记已发送即将过期通知，已被标记时返回 false
+	MarkExpiryNotified(ctx context.Context, id int, at time.Time) (bool, error)
+	// LogAudit 记录审计日志
+	LogAudit(ctx context.Context, log *model.TokenAuditLog) error
+	// LogRenewal 记录续期日志
+	LogRenewal(ctx context.Context, log *model.TokenRenewalLog) error
+}
+
+// DefaultTokenRepository 默认实现
+type DefaultTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewTokenRepository 创建仓储
+func NewTokenRepository(db *gorm.DB) TokenRepository {
+	return &DefaultTokenRepository{db: db}
+}
+
+// Create 创建 Token
+func (r *DefaultTokenRepository) Create(ctx context.Context, token *model.Token) error {
+	if err := r.db.WithContext(ctx).Create(token).Error; err != nil {
+		return fmt.Errorf("failed to create token: %w", err)
+	}
+	return nil
+}
+
+// GetByID 根据 ID 获取 Token，不存在时返回 nil
+func (r *DefaultTokenRepository) GetByID(ctx context.Context, id int) (*model.Token, error) {
+	var token model.Token
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find token: %w", err)
+	}
+	return &token, nil
+}
+
+// GetByHash 根据哈希获取 Token，不存在时返回 nil
+func (r *DefaultTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*model.Token, error) {
+	var token model.Token
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find token: %w", err)
+	}
+	return &token, nil
+}
+
+// GetByPreviousHash 根据轮换前的哈希获取 Token，不存在时返回 nil
+func (r *DefaultTokenRepository) GetByPreviousHash(ctx context.Context, tokenHash string) (*model.Token, error) {
+	var token model.Token
+	if err := r.db.WithContext(ctx).Where("previous_token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find token: %w", err)
+	}
+	return &token, nil
+}
+
+// Update 更新 Token
+func (r *DefaultTokenRepository) Update(ctx context.Context, token *model.Token) error {
+	if err := r.db.WithContext(ctx).Save(token).Error; err != nil {
+		return fmt.Errorf("failed to update token: %w", err)
+	}
+	return nil
+}
+
+// ListByUserID 列出用户的 Token
+func (r *DefaultTokenRepository) ListByUserID(ctx context.Context, userID int) ([]*model.Token, error) {
+	var tokens []*model.Token
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// ListByProjectID 列出绑定到项目的 Token
+func (r *DefaultTokenRepository) ListByProjectID(ctx context.Context, projectID int) ([]*model.Token, error) {
+	var tokens []*model.Token
+	if err := r.db.WithContext(ctx).
+		Where("project_id = ?", projectID).
+		Order("id DESC").
+		Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to list project tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// CheckAndUpdateExpiredTokens 将已过期的 Token 标记为过期，返回更新数量
+func (r *DefaultTokenRepository) CheckAndUpdateExpiredTokens(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.WithContext(ctx).
+		Raw("SELECT updated_count FROM check_and_update_expired_tokens()").
+		Scan(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to check expired tokens: %w", err)
+	}
+	return count, nil
+}
+
+// ListExpiringTokens 列出在 (from, to] 内过期且尚未通知的正常 Token
+func (r *DefaultTokenRepository) ListExpiringTokens(ctx context.Context, from, to time.Time) ([]*model.Token, error) {
+	var tokens []*model.Token
+	if err := r.db.WithContext(ctx).
+		Where("status = ? AND deleted_at IS NULL AND expiry_notified_at IS NULL", model.TokenStatusNormal).
+		Where("expire_at > ? AND expire_at <= ?", from, to).
+		Order("expire_at").
+		Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to list expiring tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// MarkExpiryNotified 标记已发送即将过期通知，多实例同时运行时只有一个能标记成功
+func (r *DefaultTokenRepository) MarkExpiryNotified(ctx context.Context, id int, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&model.Token{}).
+		Where("id = ? AND expiry_notified_at IS NULL", id).
+		Update("expiry_notified_at", at)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to mark token expiry notified: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// LogAudit 记录审计日志
+func (r *DefaultTokenRepository) LogAudit(ctx context.Context, log *model.TokenAuditLog) error {
+	return r.db.WithContext(ctx).Create(log).Error
+}
+
+// LogRenewal 记录续期日志
+func (r *DefaultTokenRepository) LogRenewal(ctx context.Context, log *model.TokenRenewalLog) error {
+	return r.db.WithContext(ctx).Create(log).Error
+}
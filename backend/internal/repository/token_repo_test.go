@@ -0,0 +1,92 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// capturedUpdate DryRun 模式下生成的 UPDATE 语句
+type capturedUpdate struct {
+	sql  string
+	vars []interface{}
+}
+
+// column 返回 SET 子句中指定列绑定的值，经过 driver.Valuer 转换
+func (u capturedUpdate) column(t *testing.T, name string) driver.Value {
+	t.Helper()
+	set := u.sql[strings.Index(u.sql, " SET ")+5 : strings.Index(u.sql, " WHERE ")]
+	for i, assignment := range strings.Split(set, ",") {
+		if strings.HasPrefix(assignment, `"`+name+`"=`) {
+			v := u.vars[i]
+			if valuer, ok := v.(driver.Valuer); ok {
+				value, err := valuer.Value()
+				require.NoError(t, err)
+				return value
+			}
+			return v
+		}
+	}
+	t.Fatalf("column %s not in update: %s", name, u.sql)
+	return nil
+}
+
+// newDryRunTokenRepository 不连接数据库，只记录 Update 生成的 SQL
+func newDryRunTokenRepository(t *testing.T) (repository.TokenRepository, *[]capturedUpdate) {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 user=test dbname=test sslmode=disable"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+
+	var updates []capturedUpdate
+	require.NoError(t, db.Callback().Update().After("gorm:update").Register("test:capture", func(tx *gorm.DB) {
+		updates = append(updates, capturedUpdate{sql: tx.Statement.SQL.String(), vars: tx.Statement.Vars})
+	}))
+	return repository.NewTokenRepository(db), &updates
+}
+
+// deletedToken 查询返回一个已软删除的 Token，其余操作走真实仓储
+type deletedToken struct {
+	repository.TokenRepository
+	token model.Token
+}
+
+func (r *deletedToken) GetByID(ctx context.Context, id int) (*model.Token, error) {
+	token := r.token
+	return &token, nil
+}
+
+func TestRestoreTokenClearsDeletedAt(t *testing.T) {
+	repo, updates := newDryRunTokenRepository(t)
+	tokens := service.NewTokenService(&deletedToken{
+		TokenRepository: repo,
+		token: model.Token{
+			ID:        3,
+			UserID:    7,
+			TokenHash: "hash",
+			Status:    model.TokenStatusDeleted,
+			DeletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		},
+	})
+
+	require.NoError(t, tokens.RestoreToken(context.Background(), 3))
+
+	require.Len(t, *updates, 1)
+	update := (*updates)[0]
+	assert.Nil(t, update.column(t, "deleted_at"), "deleted_at must be written as NULL")
+	assert.Equal(t, model.TokenStatusNormal, update.column(t, "status"))
+}
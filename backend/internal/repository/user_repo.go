@@ -3,12 +3,17 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrQuotaWouldBeNegative 扣减额度后余额将为负
+var ErrQuotaWouldBeNegative = errors.New("quota adjustment would make the balance negative")
+
 type UserRepository struct {
 	db *gorm.DB
 }
@@ -37,6 +42,19 @@ func (r *UserRepository) FindByID(ctx context.Context, id int) (*model.User, err
 	return &user, nil
 }
 
+// FindStatus 查询用户状态，用户不存在时 exists 为 false（供鉴权中间件校验账号是否被停用）
+func (r *UserRepository) FindStatus(ctx context.Context, id int) (status int, exists bool, err error) {
+	var user model.User
+	err = r.db.WithContext(ctx).Select("status").Where("id = ?", id).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return user.Status, true, nil
+}
+
 // FindByUsername 根据用户名查询
 func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*model.User, error) {
 	var user model.User
@@ -107,4 +125,129 @@ func (r *UserRepository) AddQuota(ctx context.Context, userID int, amount int64)
 		UpdateColumn("quota", gorm.Expr("quota + ?", amount)).Error
 }
 
+// UserFilter 管理后台用户查询条件，注册时间范围为 [CreatedFrom, CreatedTo)
+type UserFilter struct {
+	Search      string // 用户名或邮箱子串
+	Role        *int
+	Status      *int
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Page        int
+	PageSize    int
+}
+
+// List 分页查询用户，按注册时间倒序
+func (r *UserRepository) List(ctx context.Context, filter *UserFilter) ([]*model.User, int64, error) {
+	query := r.db.WithContext(ctx).Model(&model.User{})
+
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		query = query.Where(`(username ILIKE ? ESCAPE '\' OR email ILIKE ? ESCAPE '\')`, pattern, pattern)
+	}
+	if filter.Role != nil {
+		query = query.Where("role = ?", *filter.Role)
+	}
+	if filter.Status != nil {
+		query = query.Where("status = ?", *filter.Status)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 || filter.PageSize > 100 {
+		filter.PageSize = 20
+	}
+
+	var users []*model.User
+	err := query.Order("created_at DESC, id DESC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// UpdateStatus 更新用户状态
+func (r *UserRepository) UpdateStatus(ctx context.Context, userID, status int) error {
+	return r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", userID).
+		Update("status", status).Error
+}
+
+// UpdateRole 更新用户角色
+func (r *UserRepository) UpdateRole(ctx context.Context, userID, role int) error {
+	return r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", userID).
+		Update("role", role).Error
+}
+
+// AdjustQuota 在事务中调整用户额度并写入额度变更日志，delta 为正时同时计入总额度；
+// 用户不存在时返回 nil，扣减后余额为负时返回 ErrQuotaWouldBeNegative
+func (r *UserRepository) AdjustQuota(ctx context.Context, userID int, delta int64, reason string) (*model.QuotaLog, error) {
+	var quotaLog *model.QuotaLog
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", userID).
+			First(&user).Error
+		if err != nil {
+			return err
+		}
+		if user.Quota+delta < 0 {
+			return ErrQuotaWouldBeNegative
+		}
+
+		updates := map[string]interface{}{"quota": gorm.Expr("quota + ?", delta)}
+		if delta > 0 {
+			updates["total_quota"] = gorm.Expr("total_quota + ?", delta)
+		}
+		if err := tx.Model(&model.User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		quotaLog = &model.QuotaLog{
+			UserID:        userID,
+			OperationType: "adjust",
+			Amount:        delta,
+			Reason:        reason,
+			BalanceBefore: user.Quota,
+			BalanceAfter:  user.Quota + delta,
+		}
+		return tx.Create(quotaLog).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return quotaLog, nil
+}
 
+// FindSettings 查询用户设置，未设置时返回 nil
+func (r *UserRepository) FindSettings(ctx context.Context, userID int) (*model.UserSettings, error) {
+	var settings model.UserSettings
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&settings).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &settings, nil
+}
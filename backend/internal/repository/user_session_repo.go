@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// UserSessionRepository 用户登录会话仓储接口
+type UserSessionRepository interface {
+	// Create 创建会话
+	Create(ctx context.Context, session *model.UserSession) error
+	// GetByFamily 根据刷新令牌链 ID 获取会话，不存在时返回 nil
+	GetByFamily(ctx context.Context, familyID string) (*model.UserSession, error)
+	// Touch 记录会话的最近使用时间与 IP
+	Touch(ctx context.Context, id int, at time.Time, ip string) error
+	// ListActive 列出用户未撤销的会话，最近使用的在前
+	ListActive(ctx context.Context, userID int) ([]*model.UserSession, error)
+	// Revoke 撤销用户的会话，会话不存在或已撤销时返回 false
+	Revoke(ctx context.Context, userID, id int, at time.Time) (bool, error)
+	// DeleteIdle 删除最近使用时间早于 before 的会话，返回删除数量
+	DeleteIdle(ctx context.Context, before time.Time) (int64, error)
+}
+
+// DefaultUserSessionRepository 默认实现
+type DefaultUserSessionRepository struct {
+	db *gorm.DB
+}
+
+// NewUserSessionRepository 创建仓储
+func NewUserSessionRepository(db *gorm.DB) UserSessionRepository {
+	return &DefaultUserSessionRepository{db: db}
+}
+
+// Create 创建会话
+func (r *DefaultUserSessionRepository) Create(ctx context.Context, session *model.UserSession) error {
+	if err := r.db.WithContext(ctx).Create(session).Error; err != nil {
+		return fmt.Errorf("failed to create user session: %w", err)
+	}
+	return nil
+}
+
+// GetByFamily 根据刷新令牌链 ID 获取会话，不存在时返回 nil
+func (r *DefaultUserSessionRepository) GetByFamily(ctx context.Context, familyID string) (*model.UserSession, error) {
+	var session model.UserSession
+	if err := r.db.WithContext(ctx).Where("family_id = ?", familyID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find user session: %w", err)
+	}
+	return &session, nil
+}
+
+// Touch 记录会话的最近使用时间与 IP
+func (r *DefaultUserSessionRepository) Touch(ctx context.Context, id int, at time.Time, ip string) error {
+	if err := r.db.WithContext(ctx).Model(&model.UserSession{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"last_used_at": at, "ip": ip}).Error; err != nil {
+		return fmt.Errorf("failed to touch user session: %w", err)
+	}
+	return nil
+}
+
+// ListActive 列出用户未撤销的会话，最近使用的在前
+func (r *DefaultUserSessionRepository) ListActive(ctx context.Context, userID int) ([]*model.UserSession, error) {
+	var sessions []*model.UserSession
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list user sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// Revoke 撤销用户的会话，会话不存在或已撤销时返回 false
+func (r *DefaultUserSessionRepository) Revoke(ctx context.Context, userID, id int, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&model.UserSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to revoke user session: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteIdle 删除最近使用时间早于 before 的会话，返回删除数量
+func (r *DefaultUserSessionRepository) DeleteIdle(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("last_used_at < ?", before).Delete(&model.UserSession{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete idle user sessions: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,158 @@
+// Package scaling 扩缩容信号
+//
+// 每个信号是一个由业务代码直接维护的原子计数（或读取实时状态的函数），同时导出为
+// Prometheus 指标（随服务的 /metrics 输出，供 Prometheus Adapter 为 HPA 提供自定义指标）和 JSON 摘要
+// （GET /internal/scaling-hints，供运维脚本自行扩缩容）。
+//
+// 指标名称是稳定接口，HPA 配置直接引用，修改需要同步部署配置：
+//
+//	oblivious_relay_inflight_requests                       中转正在执行的请求数
+//	oblivious_relay_admission_queue_depth{priority}         中转准入队列中等待的请求数（interactive / batch）
+//	oblivious_billing_event_queue_depth{queue}              计费事件队列中未消费的事件数
+//	oblivious_billing_event_queue_oldest_age_seconds{queue} 计费事件队列中最早事件已等待的秒数
+//	oblivious_chat_active_streams                           对话服务正在输出的流式响应数
+package scaling
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 稳定的指标名称
+const (
+	MetricRelayInFlight           = "oblivious_relay_inflight_requests"
+	MetricRelayAdmissionQueue     = "oblivious_relay_admission_queue_depth"
+	MetricBillingEventQueueDepth  = "oblivious_billing_event_queue_depth"
+	MetricBillingEventQueueOldest = "oblivious_billing_event_queue_oldest_age_seconds"
+	MetricChatActiveStreams       = "oblivious_chat_active_streams"
+)
+
+// SoftLimitRatio 软上限占硬上限的比例，达到软上限即应扩容
+const SoftLimitRatio = 0.8
+
+// Gauge 一个扩缩容信号
+//
+// 计数型信号通过 Inc/Dec/Set 维护，值保存在原子变量中，Prometheus 抓取与 JSON 摘要读取的是同一个值；
+// 函数型信号在读取时调用函数取值。
+type Gauge struct {
+	name      string
+	help      string
+	labels    map[string]string
+	hardLimit float64
+	value     int64
+	read      func() float64
+}
+
+// Inc 计数加一
+func (g *Gauge) Inc() { atomic.AddInt64(&g.value, 1) }
+
+// Dec 计数减一
+func (g *Gauge) Dec() { atomic.AddInt64(&g.value, -1) }
+
+// Set 设置计数
+func (g *Gauge) Set(n int64) { atomic.StoreInt64(&g.value, n) }
+
+// Value 当前值
+func (g *Gauge) Value() float64 {
+	if g.read != nil {
+		return g.read()
+	}
+	return float64(atomic.LoadInt64(&g.value))
+}
+
+// Hint 信号的 JSON 摘要，上限为 0 表示未配置
+type Hint struct {
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Value     float64           `json:"value"`
+	SoftLimit float64           `json:"soft_limit"`
+	HardLimit float64           `json:"hard_limit"`
+}
+
+// Hint 生成信号摘要
+func (g *Gauge) Hint() Hint {
+	return Hint{
+		Name:      g.name,
+		Labels:    g.labels,
+		Value:     g.Value(),
+		SoftLimit: math.Floor(g.hardLimit * SoftLimitRatio),
+		HardLimit: g.hardLimit,
+	}
+}
+
+// Registry 一个服务的扩缩容信号集合
+type Registry struct {
+	mu     sync.RWMutex
+	gauges []*Gauge
+	prom   *prometheus.Registry
+}
+
+// NewRegistry 创建信号集合，Prometheus 指标注册在独立的 Registry 中，
+// 经 metrics.Register 加入服务的 /metrics（Go 运行时与进程指标由 metrics 输出）
+func NewRegistry() *Registry {
+	return &Registry{prom: prometheus.NewRegistry()}
+}
+
+// NewGauge 注册计数型信号，hardLimit 为 0 表示没有上限
+func (r *Registry) NewGauge(name, help string, labels map[string]string, hardLimit float64) *Gauge {
+	return r.register(&Gauge{name: name, help: help, labels: labels, hardLimit: hardLimit})
+}
+
+// NewGaugeFunc 注册函数型信号，每次读取时调用 fn 取值
+func (r *Registry) NewGaugeFunc(name, help string, labels map[string]string, hardLimit float64, fn func() float64) *Gauge {
+	return r.register(&Gauge{name: name, help: help, labels: labels, hardLimit: hardLimit, read: fn})
+}
+
+func (r *Registry) register(g *Gauge) *Gauge {
+	r.prom.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        g.name,
+		Help:        g.help,
+		ConstLabels: g.labels,
+	}, g.Value))
+
+	r.mu.Lock()
+	r.gauges = append(r.gauges, g)
+	r.mu.Unlock()
+	return g
+}
+
+// Hints 所有信号的摘要，按名称与标签排序
+func (r *Registry) Hints() []Hint {
+	r.mu.RLock()
+	hints := make([]Hint, 0, len(r.gauges))
+	for _, g := range r.gauges {
+		hints = append(hints, g.Hint())
+	}
+	r.mu.RUnlock()
+
+	sort.SliceStable(hints, func(i, j int) bool {
+		if hints[i].Name != hints[j].Name {
+			return hints[i].Name < hints[j].Name
+		}
+		return labelKey(hints[i].Labels) < labelKey(hints[j].Labels)
+	})
+	return hints
+}
+
+// Gatherer 供 /metrics 输出的 Prometheus Gatherer
+func (r *Registry) Gatherer() prometheus.Gatherer {
+	return r.prom
+}
+
+// labelKey 标签排序用的键
+func labelKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var key string
+	for _, k := range keys {
+		key += k + "=" + labels[k] + ","
+	}
+	return key
+}
@@ -0,0 +1,50 @@
+package scaling
+
+import (
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryHintsAndPrometheus(t *testing.T) {
+	reg := NewRegistry()
+	inFlight := reg.NewGauge(MetricRelayInFlight, "in flight", nil, 100)
+	batch := reg.NewGauge(MetricRelayAdmissionQueue, "queued", map[string]string{"priority": "batch"}, 50)
+	reg.NewGauge(MetricRelayAdmissionQueue, "queued", map[string]string{"priority": "interactive"}, 50)
+	age := 12.5
+	reg.NewGaugeFunc(MetricBillingEventQueueOldest, "age", map[string]string{"queue": "billing"}, 0, func() float64 { return age })
+
+	inFlight.Inc()
+	inFlight.Inc()
+	inFlight.Dec()
+	batch.Set(7)
+
+	hints := reg.Hints()
+	require.Len(t, hints, 4)
+	assert.Equal(t, Hint{Name: MetricBillingEventQueueOldest, Labels: map[string]string{"queue": "billing"}, Value: 12.5}, hints[0])
+	assert.Equal(t, Hint{Name: MetricRelayAdmissionQueue, Labels: map[string]string{"priority": "batch"}, Value: 7, SoftLimit: 40, HardLimit: 50}, hints[1])
+	assert.Equal(t, "interactive", hints[2].Labels["priority"])
+	assert.Equal(t, Hint{Name: MetricRelayInFlight, Value: 1, SoftLimit: 80, HardLimit: 100}, hints[3])
+
+	// Prometheus 读取的是同一个原子值
+	families, err := reg.Gatherer().Gather()
+	require.NoError(t, err)
+	values := make(map[string][]*dto.Metric)
+	for _, f := range families {
+		values[f.GetName()] = f.GetMetric()
+	}
+	require.Len(t, values[MetricRelayInFlight], 1)
+	assert.Equal(t, 1.0, values[MetricRelayInFlight][0].GetGauge().GetValue())
+	assert.Len(t, values[MetricRelayAdmissionQueue], 2)
+
+	age = 30
+	families, err = reg.Gatherer().Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		if f.GetName() == MetricBillingEventQueueOldest {
+			assert.Equal(t, 30.0, f.GetMetric()[0].GetGauge().GetValue())
+		}
+	}
+}
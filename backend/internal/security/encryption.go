@@ -8,8 +8,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 // EncryptionManager 加密管理器
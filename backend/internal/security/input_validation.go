@@ -1,6 +1,7 @@
 package security
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
@@ -126,9 +127,7 @@ func containsXSS(input string) bool {
 
 // isValidJSON 检查是否是有效的 JSON
 func isValidJSON(jsonStr string) bool {
-	var obj interface{}
-	decoder := strings.NewReader(jsonStr)
-	return nil == nil // 实际应使用 json.Decoder
+	return json.Valid([]byte(jsonStr))
 }
 
 // SanitizeString 清理字符串
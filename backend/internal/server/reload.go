@@ -0,0 +1,40 @@
+package server
+
+import (
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"go.uber.org/zap"
+)
+
+// WatchConfig 按配置设置日志级别，并在收到 SIGHUP 时重新加载配置
+//
+// 日志级别由此处订阅；限流、健康检查间隔等由使用它们的组件通过返回的 Reloader 订阅。服务停止时不再监听 SIGHUP。
+func (s *Server) WatchConfig(cfg *config.Config) *config.Reloader {
+	applyLogLevel(cfg)
+
+	reloader := config.NewReloader(cfg, config.Load)
+	reloader.Subscribe("log_level", func(prev, next *config.Config) {
+		if prev.Log.Level != next.Log.Level {
+			applyLogLevel(next)
+		}
+	})
+	reloader.Start()
+	s.OnStop(reloader.Stop)
+	return reloader
+}
+
+// applyLogLevel 设置全局日志级别，未配置时使用 APP_ENV 对应的默认级别
+func applyLogLevel(cfg *config.Config) {
+	level := cfg.Log.Level
+	if level == "" {
+		level = "info"
+		if cfg.App.Env == "development" {
+			level = "debug"
+		}
+	}
+	if err := logger.SetLevel(level); err != nil {
+		logger.Warn("Failed to set log level", zap.String("level", level), zap.Error(err))
+		return
+	}
+	logger.Info("Log level set", zap.String("level", level))
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestWatchConfigReloadsLogLevelOnSIGHUP(t *testing.T) {
+	previous := logger.DefaultLogger
+	t.Cleanup(func() { logger.DefaultLogger = previous })
+	require.NoError(t, logger.Init("production"))
+
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("JWT_SECRET", "reload-secret")
+	t.Setenv("LOG_LEVEL", "warn")
+	cfg, err := config.Load()
+	require.NoError(t, err)
+
+	srv := New("test service", time.Second)
+	reloader := srv.WatchConfig(cfg)
+	t.Cleanup(reloader.Stop)
+	assert.Equal(t, zapcore.WarnLevel, logger.DefaultLogger.Level())
+
+	// 修改环境变量后发送 SIGHUP，日志级别在运行中生效；数据库地址不可热加载，保持原值
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("DATABASE_HOST", "db.internal")
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+
+	require.Eventually(t, func() bool {
+		return logger.DefaultLogger.Level() == zapcore.DebugLevel
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "debug", reloader.Current().Log.Level)
+	assert.Equal(t, cfg.Database.Host, reloader.Current().Database.Host)
+
+	// 新配置无效时保留当前配置
+	t.Setenv("LOG_LEVEL", "verbose")
+	assert.Error(t, reloader.Reload())
+	assert.Equal(t, zapcore.DebugLevel, logger.DefaultLogger.Level())
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/metrics"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/middleware"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tracing"
+)
+
+// NewRouter 创建服务的 gin 路由并挂载所有服务共用的中间件：恢复、追踪、请求 ID、访问日志、CORS 与 HTTP 指标，
+// 同时注册 Prometheus 抓取的 /metrics（与 /health 一样只应在集群内部暴露）
+func NewRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(tracing.Middleware())
+	r.Use(middleware.RequestIDMiddleware())
+	r.Use(middleware.LoggerMiddleware())
+	r.Use(middleware.CORSMiddleware())
+	r.Use(metrics.Middleware())
+
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	return r
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := NewRouter()
+	r.GET("/api/v1/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for _, path := range []string{"/api/v1/users/1001", "/api/v1/users/1002", "/missing"} {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	metrics.ObserveRelayAttempt("7", "gpt-4o", "success", 800*time.Millisecond)
+	metrics.AddRelayTokens("gpt-4o", 12, 30)
+	metrics.BillingEventProcessed("success")
+	metrics.CacheLookup("pricing", true)
+	metrics.CircuitBreakerStates(func() map[string]string { return map[string]string{"7": "open"} })
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	body := string(data)
+
+	// 按路由模板聚合，路径中的用户 ID 不会成为标签
+	assert.Contains(t, body, `oblivious_http_requests_total{method="GET",route="/api/v1/users/:id",status="204"} 2`)
+	assert.Contains(t, body, `oblivious_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.NotContains(t, body, "1001")
+	assert.Contains(t, body, `oblivious_http_request_duration_seconds_count{method="GET",route="/api/v1/users/:id"} 2`)
+
+	assert.Contains(t, body, `oblivious_relay_upstream_requests_total{channel="7",model="gpt-4o",outcome="success"} 1`)
+	assert.Contains(t, body, `oblivious_relay_upstream_duration_seconds_count{channel="7",model="gpt-4o"} 1`)
+	assert.Contains(t, body, `oblivious_relay_tokens_total{model="gpt-4o",type="completion"} 30`)
+	assert.Contains(t, body, `oblivious_billing_events_processed_total{result="success"} 1`)
+	assert.Contains(t, body, `oblivious_cache_requests_total{cache="pricing",result="hit"} 1`)
+	assert.Contains(t, body, `oblivious_relay_circuit_breaker_state{channel="7",state="open"} 1`)
+	assert.Contains(t, body, `oblivious_relay_circuit_breaker_state{channel="7",state="closed"} 0`)
+	assert.Contains(t, body, "go_goroutines")
+}
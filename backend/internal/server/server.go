@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"go.uber.org/zap"
+)
+
+// ErrShuttingDown 服务正在关闭，流式响应因此被中断
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// shutdownKey 请求上下文中服务关闭通知通道的键
+type shutdownKey struct{}
+
+// Server HTTP 服务的启动与优雅关闭
+//
+// 收到 SIGINT/SIGTERM 后停止接收新连接并通知进行中的流式响应结束，等待其余请求完成（最长 drainTimeout），
+// 然后停止后台组件，最后关闭数据库与 Redis 等资源。
+type Server struct {
+	name         string
+	drainTimeout time.Duration
+	httpServer   *http.Server
+	listener     net.Listener
+	shutdown     chan struct{}
+	signals      chan os.Signal
+	serveErr     chan error
+	stops        []func()
+	closers      []func() error
+}
+
+// New 创建服务，drainTimeout 不大于 0 时使用 30 秒
+func New(name string, drainTimeout time.Duration) *Server {
+	if drainTimeout <= 0 {
+		drainTimeout = 30 * time.Second
+	}
+	return &Server{
+		name:         name,
+		drainTimeout: drainTimeout,
+		shutdown:     make(chan struct{}),
+		signals:      make(chan os.Signal, 1),
+		serveErr:     make(chan error, 1),
+	}
+}
+
+// OnStop 注册在请求处理完后停止的后台组件，按注册的相反顺序停止（与 defer 相同，后启动的先停止）
+func (s *Server) OnStop(stop func()) {
+	s.stops = append(s.stops, stop)
+}
+
+// OnClose 注册在后台组件停止后关闭的资源（数据库、Redis 等），按注册的相反顺序关闭
+func (s *Server) OnClose(close func() error) {
+	s.closers = append(s.closers, close)
+}
+
+// Run 在 addr 上启动服务并阻塞到收到退出信号，返回前完成优雅关闭
+func (s *Server) Run(addr string, handler http.Handler) error {
+	if err := s.Start(addr, handler); err != nil {
+		return err
+	}
+	return s.Wait()
+}
+
+// Start 监听 addr 并在后台处理请求，同时开始监听退出信号
+func (s *Server) Start(addr string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", addr, err)
+	}
+	s.listener = listener
+	s.httpServer = &http.Server{
+		Handler: handler,
+		// 请求上下文携带关闭通知，流式响应据此提前结束
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), shutdownKey{}, (<-chan struct{})(s.shutdown))
+		},
+	}
+
+	signal.Notify(s.signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.serveErr <- err
+		}
+	}()
+
+	logger.Info(s.name+" starting", zap.String("addr", listener.Addr().String()))
+	return nil
+}
+
+// Addr 实际监听的地址，监听 :0 时可用于获取随机端口
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Wait 等待退出信号后优雅关闭；服务异常退出时同样执行关闭流程并返回该错误
+func (s *Server) Wait() error {
+	defer signal.Stop(s.signals)
+
+	var serveErr error
+	select {
+	case sig := <-s.signals:
+		logger.Info(s.name+" shutting down", zap.String("signal", sig.String()), zap.Duration("drain_timeout", s.drainTimeout))
+	case serveErr = <-s.serveErr:
+		logger.Error(s.name+" stopped unexpectedly", zap.Error(serveErr))
+	}
+
+	err := s.shutdownGracefully()
+	if serveErr != nil {
+		return serveErr
+	}
+	return err
+}
+
+// shutdownGracefully 依次通知流式响应、等待请求完成、停止后台组件并关闭资源
+func (s *Server) shutdownGracefully() error {
+	close(s.shutdown)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.drainTimeout)
+	defer cancel()
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		// 超过等待时间仍未完成的请求直接断开
+		logger.Warn(s.name+" drain timed out, closing remaining connections", zap.Error(err))
+		s.httpServer.Close()
+	}
+
+	for i := len(s.stops) - 1; i >= 0; i-- {
+		s.stops[i]()
+	}
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		if err := s.closers[i](); err != nil {
+			logger.Warn(s.name+" failed to close resource", zap.Error(err))
+		}
+	}
+	logger.Info(s.name + " exited")
+	return err
+}
+
+// ShutdownNotify 返回服务开始关闭时关闭的通道，请求不是由 Server 处理时返回 nil
+func ShutdownNotify(ctx context.Context) <-chan struct{} {
+	ch, _ := ctx.Value(shutdownKey{}).(<-chan struct{})
+	return ch
+}
+
+// StreamContext 派生流式响应使用的上下文，服务开始关闭时以 ErrShuttingDown 取消，
+// 流式输出结束后用 Interrupted 判断是否需要发送 server_shutdown 事件
+func StreamContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	streamCtx, cancel := context.WithCancelCause(ctx)
+	if notify := ShutdownNotify(ctx); notify != nil {
+		go func() {
+			select {
+			case <-notify:
+				cancel(ErrShuttingDown)
+			case <-streamCtx.Done():
+			}
+		}()
+	}
+	return streamCtx, func() { cancel(context.Canceled) }
+}
+
+// Interrupted 流式上下文是否因服务关闭而取消
+func Interrupted(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrShuttingDown)
+}
+
+// ShutdownEvent 服务关闭时流式响应的最后一个事件，客户端收到后应重新连接
+const ShutdownEvent = "event: server_shutdown\ndata: {\"reconnect\":true}\n\n"
+
+// WriteShutdownEvent 写入 server_shutdown 事件
+func WriteShutdownEvent(w io.Writer) {
+	io.WriteString(w, ShutdownEvent)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
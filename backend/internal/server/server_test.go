@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	started := make(chan struct{}, 2)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		time.Sleep(300 * time.Millisecond)
+		fmt.Fprint(w, "done")
+	})
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+
+		ctx, stop := StreamContext(r.Context())
+		defer stop()
+		started <- struct{}{}
+		<-ctx.Done()
+		if Interrupted(ctx) {
+			WriteShutdownEvent(w)
+		}
+	})
+
+	var order []string
+	srv := New("test service", 5*time.Second)
+	srv.OnClose(func() error { order = append(order, "db"); return nil })
+	srv.OnClose(func() error { order = append(order, "redis"); return nil })
+	srv.OnStop(func() { order = append(order, "first component") })
+	srv.OnStop(func() { order = append(order, "second component") })
+	require.NoError(t, srv.Start("127.0.0.1:0", mux))
+	baseURL := "http://" + srv.Addr().String()
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	get := func(path string) <-chan result {
+		ch := make(chan result, 1)
+		go func() {
+			resp, err := http.Get(baseURL + path)
+			if err != nil {
+				ch <- result{err: err}
+				return
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			ch <- result{status: resp.StatusCode, body: string(body), err: err}
+		}()
+		return ch
+	}
+	slow := get("/slow")
+	stream := get("/stream")
+	<-started
+	<-started
+
+	waitErr := make(chan error, 1)
+	go func() { waitErr <- srv.Wait() }()
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+	select {
+	case err := <-waitErr:
+		require.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+
+	res := <-slow
+	require.NoError(t, res.err)
+	assert.Equal(t, http.StatusOK, res.status)
+	assert.Equal(t, "done", res.body)
+
+	res = <-stream
+	require.NoError(t, res.err)
+	assert.True(t, strings.HasSuffix(res.body, "event: server_shutdown\ndata: {\"reconnect\":true}\n\n"), res.body)
+
+	// 后台组件先于资源停止，各自与 defer 一样按注册的相反顺序执行
+	assert.Equal(t, []string{"second component", "first component", "redis", "db"}, order)
+
+	_, err := http.Get(baseURL + "/slow")
+	assert.Error(t, err, "no new connections after shutdown")
+}
+
+func TestStreamContextOutsideServer(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, err)
+
+	ctx, stop := StreamContext(req.Context())
+	assert.Nil(t, ShutdownNotify(req.Context()))
+	stop()
+	<-ctx.Done()
+	assert.False(t, Interrupted(ctx))
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrAdminUserNotFound 目标用户不存在
+	ErrAdminUserNotFound = utils.NewAppError(utils.CodeNotFound, "user not found")
+	// ErrInvalidUserStatus 只能在停用与启用之间切换
+	ErrInvalidUserStatus = utils.NewAppError(utils.CodeInvalidRequest, "status must be 0 (suspended) or 1 (active)")
+	// ErrInvalidUserRole 角色超出允许范围
+	ErrInvalidUserRole = utils.NewAppError(utils.CodeInvalidRequest, "role must be between 1 and 100")
+	// ErrAdminSelfModify 管理员不能停用自己或修改自己的角色，避免锁死后台
+	ErrAdminSelfModify = utils.NewAppError(utils.CodeForbidden, "administrators cannot change their own status or role")
+	// ErrInvalidQuotaAdjustment 调整额度必须非零且填写原因
+	ErrInvalidQuotaAdjustment = utils.NewAppError(utils.CodeInvalidRequest, "amount must be non-zero and reason is required")
+	// ErrQuotaAdjustmentNegative 扣减超过用户余额
+	ErrQuotaAdjustmentNegative = utils.NewAppError(utils.CodeInvalidRequest, "debit exceeds the user's remaining quota")
+)
+
+// 管理员操作用户的审计操作类型
+const (
+	AuditOpSuspendUser    = "suspend_user"
+	AuditOpReactivateUser = "reactivate_user"
+	AuditOpChangeUserRole = "change_user_role"
+	AuditOpAdjustQuota    = "adjust_user_quota"
+)
+
+// AdminUserStore 管理后台用户操作所需的仓储
+type AdminUserStore interface {
+	List(ctx context.Context, filter *repository.UserFilter) ([]*model.User, int64, error)
+	FindByID(ctx context.Context, id int) (*model.User, error)
+	UpdateStatus(ctx context.Context, userID, status int) error
+	UpdateRole(ctx context.Context, userID, role int) error
+	AdjustQuota(ctx context.Context, userID int, delta int64, reason string) (*model.QuotaLog, error)
+}
+
+// AdminActor 发起操作的管理员，写入审计日志
+type AdminActor struct {
+	UserID    int
+	IP        string
+	UserAgent string
+}
+
+// AdminUserService 管理后台用户管理：查询、停用、调整角色与额度，每次变更都写入审计日志
+type AdminUserService struct {
+	users AdminUserStore
+	audit func(ctx context.Context, entry *model.PermissionAuditLog) error
+}
+
+// NewAdminUserService 创建管理后台用户服务
+func NewAdminUserService(users AdminUserStore, audit func(ctx context.Context, entry *model.PermissionAuditLog) error) *AdminUserService {
+	return &AdminUserService{users: users, audit: audit}
+}
+
+// List 分页查询用户
+func (s *AdminUserService) List(ctx context.Context, filter *repository.UserFilter) ([]*model.User, int64, error) {
+	return s.users.List(ctx, filter)
+}
+
+// SetStatus 停用或重新启用用户，状态未变化时不写审计日志。
+// 用户服务的鉴权中间件实时校验状态，其余服务带缓存校验，停用最迟在 middleware.UserStatusCacheTTL 后全部生效
+func (s *AdminUserService) SetStatus(ctx context.Context, actor AdminActor, userID, status int, reason string) (*model.User, error) {
+	if status != model.UserStatusDisabled && status != model.UserStatusActive {
+		return nil, ErrInvalidUserStatus
+	}
+	if userID == actor.UserID {
+		return nil, ErrAdminSelfModify
+	}
+
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	before := user.Status
+	if before == status {
+		return user, nil
+	}
+
+	if err := s.users.UpdateStatus(ctx, userID, status); err != nil {
+		return nil, err
+	}
+	user.Status = status
+
+	op := AuditOpReactivateUser
+	if status == model.UserStatusDisabled {
+		op = AuditOpSuspendUser
+	}
+	s.record(ctx, actor, op, userID, map[string]interface{}{
+		"before": map[string]int{"status": before},
+		"after":  map[string]int{"status": status},
+		"reason": reason,
+	})
+	return user, nil
+}
+
+// SetRole 修改用户角色
+func (s *AdminUserService) SetRole(ctx context.Context, actor AdminActor, userID, role int) (*model.User, error) {
+	if role < model.UserRoleUser || role > model.UserRoleAdmin {
+		return nil, ErrInvalidUserRole
+	}
+	if userID == actor.UserID {
+		return nil, ErrAdminSelfModify
+	}
+
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	before := user.Role
+	if before == role {
+		return user, nil
+	}
+
+	if err := s.users.UpdateRole(ctx, userID, role); err != nil {
+		return nil, err
+	}
+	user.Role = role
+
+	s.record(ctx, actor, AuditOpChangeUserRole, userID, map[string]interface{}{
+		"before": map[string]int{"role": before},
+		"after":  map[string]int{"role": role},
+	})
+	return user, nil
+}
+
+// AdjustQuota 增加（amount > 0）或扣减（amount < 0）用户额度，原因写入额度变更日志
+func (s *AdminUserService) AdjustQuota(ctx context.Context, actor AdminActor, userID int, amount int64, reason string) (*model.QuotaLog, error) {
+	if amount == 0 || reason == "" {
+		return nil, ErrInvalidQuotaAdjustment
+	}
+
+	quotaLog, err := s.users.AdjustQuota(ctx, userID, amount, reason)
+	if err != nil {
+		if errors.Is(err, repository.ErrQuotaWouldBeNegative) {
+			return nil, ErrQuotaAdjustmentNegative
+		}
+		return nil, err
+	}
+	if quotaLog == nil {
+		return nil, ErrAdminUserNotFound
+	}
+
+	s.record(ctx, actor, AuditOpAdjustQuota, userID, map[string]interface{}{
+		"before":       map[string]int64{"quota": quotaLog.BalanceBefore},
+		"after":        map[string]int64{"quota": quotaLog.BalanceAfter},
+		"amount":       amount,
+		"reason":       reason,
+		"quota_log_id": quotaLog.ID,
+	})
+	return quotaLog, nil
+}
+
+func (s *AdminUserService) findUser(ctx context.Context, userID int) (*model.User, error) {
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrAdminUserNotFound
+	}
+	return user, nil
+}
+
+// record 写入审计日志；变更已生效，写入失败只记录错误日志
+func (s *AdminUserService) record(ctx context.Context, actor AdminActor, op string, targetID int, details map[string]interface{}) {
+	raw, _ := json.Marshal(details)
+	err := s.audit(ctx, &model.PermissionAuditLog{
+		UserID:       actor.UserID,
+		Operation:    op,
+		TargetUserID: &targetID,
+		Details:      raw,
+		IPAddress:    actor.IP,
+		UserAgent:    actor.UserAgent,
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		logger.Error("failed to record admin user audit log",
+			zap.String("operation", op),
+			zap.Int("admin_id", actor.UserID),
+			zap.Int("target_user_id", targetID),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForkAgentModelCopiesReferences(t *testing.T) {
+	owner := 1
+	maxTokens := 512
+	original := &model.Agent{
+		ID:               7,
+		UserID:           &owner,
+		Name:             "Translator",
+		SystemRole:       "Translate to French.",
+		Model:            "gpt-4o",
+		MaxTokens:        &maxTokens,
+		Tools:            json.RawMessage(`[{"name":"dictionary"}]`),
+		PluginIDs:        pq.Int64Array{3, 4},
+		KnowledgeBaseIDs: pq.Int64Array{9},
+		IsPublic:         true,
+		Views:            100,
+		Likes:            10,
+		Forks:            2,
+	}
+
+	fork := forkAgentModel(original, 2, "My Translator")
+
+	assert.Equal(t, 2, *fork.UserID)
+	assert.False(t, fork.IsPublic)
+	assert.Zero(t, fork.Views)
+	assert.Zero(t, fork.Likes)
+	assert.Zero(t, fork.Forks)
+	assert.Equal(t, original.SystemRole, fork.SystemRole)
+	assert.Equal(t, original.PluginIDs, fork.PluginIDs)
+
+	// 修改副本不影响原助手
+	*fork.MaxTokens = 64
+	fork.Tools[2] = 'X'
+	fork.PluginIDs[0] = 99
+	fork.KnowledgeBaseIDs[0] = 99
+
+	assert.Equal(t, 512, *original.MaxTokens)
+	assert.JSONEq(t, `[{"name":"dictionary"}]`, string(original.Tools))
+	assert.Equal(t, pq.Int64Array{3, 4}, original.PluginIDs)
+	assert.Equal(t, pq.Int64Array{9}, original.KnowledgeBaseIDs)
+	assert.Equal(t, 1, *original.UserID)
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/chat"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+// ErrPromptVersionNotFound 提示词版本不存在
+var ErrPromptVersionNotFound = errors.New("prompt version not found")
+
+// agentPromptID 助手系统提示词在版本历史中的 ID
+func agentPromptID(agentID int) string {
+	return fmt.Sprintf("agent:%d", agentID)
+}
+
+// recordAgentPrompt 将助手当前的系统提示词记录到版本历史，内容未变化时不产生新版本
+//
+// 早于版本历史创建的助手在第一次记录时以当前内容作为版本 1。
+func (s *AgentService) recordAgentPrompt(agent *model.Agent, editor string) error {
+	promptID := agentPromptID(agent.ID)
+
+	prompt, err := s.prompts.GetPrompt(promptID)
+	if err != nil {
+		return s.prompts.AddPromptBy(&chat.SystemPrompt{
+			ID:          promptID,
+			Content:     agent.SystemRole,
+			Model:       agent.Model,
+			Temperature: agent.Temperature,
+			Enabled:     true,
+		}, editor)
+	}
+	if prompt.Content == agent.SystemRole {
+		return nil
+	}
+
+	_, err = s.prompts.UpdatePromptBy(promptID, agent.SystemRole, editor)
+	return err
+}
+
+// ownedAgent 获取用户自己的助手
+func (s *AgentService) ownedAgent(ctx context.Context, userID int, id int) (*model.Agent, error) {
+	agent, err := s.agentRepo.FindByID(ctx, id)
+	if err != nil {
+		logger.Error("Failed to find agent", zap.Error(err))
+		return nil, err
+	}
+
+	if agent == nil {
+		return nil, fmt.Errorf("agent not found")
+	}
+
+	// 检查权限
+	if agent.UserID == nil || *agent.UserID != userID {
+		return nil, fmt.Errorf("permission denied")
+	}
+
+	return agent, nil
+}
+
+// GetAgentPromptVersions 获取助手系统提示词的全部历史版本
+func (s *AgentService) GetAgentPromptVersions(ctx context.Context, userID int, id int) ([]*chat.PromptVersion, error) {
+	agent, err := s.ownedAgent(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.agentPromptVersions(agent)
+}
+
+// GetAgentPromptVersion 获取助手系统提示词的指定版本
+func (s *AgentService) GetAgentPromptVersion(ctx context.Context, userID int, id int, version int64) (*chat.PromptVersion, error) {
+	agent, err := s.ownedAgent(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.agentPromptVersion(agent, version)
+}
+
+// RollbackAgentPrompt 将助手系统提示词回滚到指定版本，回滚本身也会产生一个新版本
+func (s *AgentService) RollbackAgentPrompt(ctx context.Context, userID int, id int, version int64) (*model.Agent, error) {
+	agent, err := s.ownedAgent(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.agentPromptVersion(agent, version); err != nil {
+		return nil, err
+	}
+
+	prompt, err := s.prompts.RollbackPrompt(agentPromptID(agent.ID), version, strconv.Itoa(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	agent.SystemRole = prompt.Content
+	if err := s.agentRepo.Update(ctx, agent); err != nil {
+		logger.Error("Failed to update agent", zap.Error(err))
+		return nil, err
+	}
+
+	return agent, nil
+}
+
+// agentPromptVersions 获取助手的提示词历史，没有历史时以当前内容创建版本 1
+func (s *AgentService) agentPromptVersions(agent *model.Agent) ([]*chat.PromptVersion, error) {
+	if err := s.recordAgentPrompt(agent, ""); err != nil {
+		return nil, err
+	}
+
+	return s.prompts.GetPromptVersions(agentPromptID(agent.ID))
+}
+
+// agentPromptVersion 获取助手提示词的指定版本
+func (s *AgentService) agentPromptVersion(agent *model.Agent, version int64) (*chat.PromptVersion, error) {
+	versions, err := s.agentPromptVersions(agent)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range versions {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+
+	return nil, ErrPromptVersionNotFound
+}
+
+// promptVersionStore 将提示词版本保存到 Postgres，实现 chat.PromptHistoryStore
+type promptVersionStore struct {
+	repo *repository.PromptVersionRepository
+}
+
+// SavePromptVersion 实现 chat.PromptHistoryStore
+func (s *promptVersionStore) SavePromptVersion(version *chat.PromptVersion) error {
+	return s.repo.Create(context.Background(), &model.PromptVersion{
+		PromptID:       version.PromptID,
+		Version:        version.Version,
+		Content:        version.Content,
+		Editor:         version.Editor,
+		RolledBackFrom: version.RolledBackFrom,
+		CreatedAt:      version.CreatedAt,
+	})
+}
+
+// FindPromptVersions 实现 chat.PromptHistoryStore
+func (s *promptVersionStore) FindPromptVersions(promptID string) ([]*chat.PromptVersion, error) {
+	records, err := s.repo.FindByPromptID(context.Background(), promptID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]*chat.PromptVersion, len(records))
+	for i, record := range records {
+		versions[i] = &chat.PromptVersion{
+			PromptID:       record.PromptID,
+			Version:        record.Version,
+			Content:        record.Content,
+			Editor:         record.Editor,
+			RolledBackFrom: record.RolledBackFrom,
+			CreatedAt:      record.CreatedAt,
+		}
+	}
+	return versions, nil
+}
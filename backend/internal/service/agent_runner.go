@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/chat"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+)
+
+// DefaultAgentMaxIterations Agent 对话循环默认的最大轮数（每轮一次模型调用）
+const DefaultAgentMaxIterations = 8
+
+// ErrAgentMaxIterations 达到最大轮数仍未得到最终回复
+var ErrAgentMaxIterations = errors.New("agent did not produce a final answer within the iteration limit")
+
+// AgentRunResult Agent 对话循环的结果
+type AgentRunResult struct {
+	Message    relay.ChatMessage   `json:"message"`  // 最终的助手回复
+	Messages   []relay.ChatMessage `json:"messages"` // 完整对话，包含工具调用与工具结果
+	ToolCalls  []*chat.ToolCall    `json:"tool_calls"`
+	Iterations int                 `json:"iterations"`
+	Usage      relay.ChatUsage     `json:"usage"`
+}
+
+// AgentRunner 驱动 Agent 的工具调用循环：把 Agent 的工具以 OpenAI tools 格式发给模型，
+// 执行模型返回的 tool_calls 并把结果追加到对话中，直到模型给出最终回复
+type AgentRunner struct {
+	client        chatCompleter
+	agents        *chat.AgentManager
+	maxIterations int
+}
+
+// NewAgentRunner 创建 Agent 运行器，maxIterations 不大于 0 时使用 DefaultAgentMaxIterations
+func NewAgentRunner(client chatCompleter, agents *chat.AgentManager, maxIterations int) *AgentRunner {
+	if maxIterations <= 0 {
+		maxIterations = DefaultAgentMaxIterations
+	}
+	return &AgentRunner{client: client, agents: agents, maxIterations: maxIterations}
+}
+
+// Run 运行 Agent 对话循环并返回最终回复
+func (r *AgentRunner) Run(ctx context.Context, agentID string, messages []relay.ChatMessage) (*AgentRunResult, error) {
+	return r.run(ctx, agentID, messages, func(map[string]interface{}) {})
+}
+
+// RunStream 运行 Agent 对话循环，并以 SSE 事件输出工具调用进度与最终回复
+//
+// 事件类型：tool_call（开始执行）、tool_result（执行结束）、done（最终回复）。
+func (r *AgentRunner) RunStream(ctx context.Context, agentID string, messages []relay.ChatMessage, writer io.Writer) (*AgentRunResult, error) {
+	var mu sync.Mutex
+	emit := func(event map[string]interface{}) {
+		jsonData, _ := json.Marshal(event)
+
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(writer, "data: %s\n\n", string(jsonData))
+		if flusher, ok := writer.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+	return r.run(ctx, agentID, messages, emit)
+}
+
+// run 对话循环，emit 可能被多个 goroutine 同时调用
+func (r *AgentRunner) run(ctx context.Context, agentID string, messages []relay.ChatMessage, emit func(map[string]interface{})) (*AgentRunResult, error) {
+	agent, err := r.agents.GetAgent(agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := agentRequest(agent)
+	if err != nil {
+		return nil, err
+	}
+	tools := agent.GetTools()
+	base.Tools = toolSchemas(tools)
+
+	conversation := make([]relay.ChatMessage, 0, len(messages)+1)
+	if prompt := agent.SystemPrompt; prompt != nil && prompt.Content != "" && (len(messages) == 0 || messages[0].Role != "system") {
+		conversation = append(conversation, relay.ChatMessage{Role: "system", Content: prompt.Content})
+	}
+	conversation = append(conversation, messages...)
+
+	result := &AgentRunResult{}
+	for result.Iterations < r.maxIterations {
+		result.Iterations++
+
+		req := *base
+		req.Messages = conversation
+		resp, err := r.client.RelayChatCompletion(ctx, &req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Choices) == 0 {
+			return nil, errors.New("empty agent response")
+		}
+		result.Usage.PromptTokens += resp.Usage.PromptTokens
+		result.Usage.CompletionTokens += resp.Usage.CompletionTokens
+		result.Usage.TotalTokens += resp.Usage.TotalTokens
+
+		reply := resp.Choices[0].Message
+		if reply.Role == "" {
+			reply.Role = "assistant"
+		}
+		conversation = append(conversation, reply)
+
+		if len(reply.ToolCalls) == 0 {
+			result.Message = reply
+			result.Messages = conversation
+			emit(map[string]interface{}{
+				"type":       "done",
+				"content":    reply.Content,
+				"iterations": result.Iterations,
+				"usage":      result.Usage,
+			})
+			return result, nil
+		}
+
+		toolMessages, calls := r.executeToolCalls(agentID, tools, reply.ToolCalls, result.Iterations, emit)
+		conversation = append(conversation, toolMessages...)
+		result.ToolCalls = append(result.ToolCalls, calls...)
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Messages = conversation
+	return result, ErrAgentMaxIterations
+}
+
+// executeToolCalls 并行执行一轮中的所有工具调用，按调用顺序返回 tool 消息
+func (r *AgentRunner) executeToolCalls(agentID string, tools []*chat.ToolDefinition, toolCalls []relay.ChatToolCall, iteration int, emit func(map[string]interface{})) ([]relay.ChatMessage, []*chat.ToolCall) {
+	available := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		available[tool.Name] = true
+	}
+
+	messages := make([]relay.ChatMessage, len(toolCalls))
+	calls := make([]*chat.ToolCall, len(toolCalls))
+
+	var wg sync.WaitGroup
+	for i, tc := range toolCalls {
+		wg.Add(1)
+		go func(i int, tc relay.ChatToolCall) {
+			defer wg.Done()
+
+			emit(map[string]interface{}{
+				"type":         "tool_call",
+				"iteration":    iteration,
+				"tool_call_id": tc.ID,
+				"name":         tc.Function.Name,
+				"arguments":    tc.Function.Arguments,
+				"status":       "running",
+			})
+
+			call := r.executeToolCall(agentID, available, tc)
+			calls[i] = call
+			messages[i] = relay.ChatMessage{
+				Role:       "tool",
+				Name:       tc.Function.Name,
+				ToolCallID: tc.ID,
+				Content:    toolResultContent(call),
+			}
+
+			emit(map[string]interface{}{
+				"type":           "tool_result",
+				"iteration":      iteration,
+				"tool_call_id":   tc.ID,
+				"name":           tc.Function.Name,
+				"status":         call.Status,
+				"error":          call.Error,
+				"attempts":       call.Attempts,
+				"execution_time": call.ExecutionTime,
+			})
+		}(i, tc)
+	}
+	wg.Wait()
+
+	return messages, calls
+}
+
+// executeToolCall 执行单个工具调用；工具不可用或参数无法解析时返回失败记录，交由模型自行纠正
+func (r *AgentRunner) executeToolCall(agentID string, available map[string]bool, tc relay.ChatToolCall) *chat.ToolCall {
+	name := tc.Function.Name
+	failed := func(msg string) *chat.ToolCall {
+		return &chat.ToolCall{ID: tc.ID, ToolName: name, Status: "failed", Error: msg}
+	}
+
+	if !available[name] {
+		return failed(fmt.Sprintf("tool %s is not available to this agent", name))
+	}
+
+	arguments := make(map[string]interface{})
+	if tc.Function.Arguments != "" {
+		if err := json.Unmarshal([]byte(tc.Function.Arguments), &arguments); err != nil {
+			return failed(fmt.Sprintf("invalid arguments for tool %s: %v", name, err))
+		}
+	}
+
+	call, err := r.agents.ExecuteToolCall(agentID, name, arguments)
+	if err != nil {
+		return failed(err.Error())
+	}
+	return call
+}
+
+// toolResultContent 工具结果作为 tool 消息的内容，字符串原样返回，其余编码为 JSON
+func toolResultContent(call *chat.ToolCall) string {
+	if call.Status != "completed" {
+		data, _ := json.Marshal(map[string]string{"error": call.Error})
+		return string(data)
+	}
+	if s, ok := call.Result.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(call.Result)
+	if err != nil {
+		return fmt.Sprintf("%v", call.Result)
+	}
+	return string(data)
+}
+
+// agentRequest 根据 Agent 的提示词与模型配置构造请求，ModelConfig 中的 model 优先
+func agentRequest(agent *chat.Agent) (*relay.ChatCompletionRequest, error) {
+	req := &relay.ChatCompletionRequest{}
+	if prompt := agent.SystemPrompt; prompt != nil {
+		req.Model = prompt.Model
+		req.Temperature = prompt.Temperature
+		req.MaxTokens = prompt.MaxTokens
+	}
+	if model, ok := agent.ModelConfig["model"].(string); ok && model != "" {
+		req.Model = model
+	}
+	if req.Model == "" {
+		return nil, fmt.Errorf("agent %s has no model configured", agent.ID)
+	}
+	return req, nil
+}
+
+// toolSchemas 将工具定义转换为 OpenAI tools 格式
+func toolSchemas(tools []*chat.ToolDefinition) []map[string]interface{} {
+	if len(tools) == 0 {
+		return nil
+	}
+	schemas := make([]map[string]interface{}, len(tools))
+	for i, tool := range tools {
+		parameters := tool.Parameters
+		if parameters == nil {
+			parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		schemas[i] = map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  parameters,
+			},
+		}
+	}
+	return schemas
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/chat"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedRelay 依次返回预设回复的对话客户端，记录收到的请求
+type scriptedRelay struct {
+	mu        sync.Mutex
+	responses []relay.ChatMessage
+	requests  []relay.ChatCompletionRequest
+}
+
+func (f *scriptedRelay) RelayChatCompletion(ctx context.Context, req *relay.ChatCompletionRequest) (*relay.ChatCompletionResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// 复制消息，避免调用方后续追加影响记录
+	recorded := *req
+	recorded.Messages = append([]relay.ChatMessage(nil), req.Messages...)
+	f.requests = append(f.requests, recorded)
+
+	reply := f.responses[0]
+	if len(f.responses) > 1 {
+		f.responses = f.responses[1:]
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"choices": []map[string]interface{}{{"message": reply}},
+		"usage":   map[string]int{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+	})
+	resp := &relay.ChatCompletionResponse{}
+	err := json.Unmarshal(body, resp)
+	return resp, err
+}
+
+// weatherToolCall 调用 get_weather 工具的助手消息
+func weatherToolCall(ids ...string) relay.ChatMessage {
+	msg := relay.ChatMessage{Role: "assistant"}
+	for _, id := range ids {
+		msg.ToolCalls = append(msg.ToolCalls, relay.ChatToolCall{
+			ID:       id,
+			Type:     "function",
+			Function: relay.ChatFunctionCall{Name: "get_weather", Arguments: `{"city":"` + id + `"}`},
+		})
+	}
+	return msg
+}
+
+// newWeatherAgent 创建绑定 get_weather 工具的 Agent
+func newWeatherAgent(t *testing.T) *chat.AgentManager {
+	t.Helper()
+
+	spm := chat.NewSystemPromptManager()
+	tr := chat.NewToolRegistry()
+	am := chat.NewAgentManager(spm, tr)
+
+	require.NoError(t, spm.AddPrompt(&chat.SystemPrompt{ID: "weather", Content: "You report the weather.", Model: "gpt-4o-mini"}))
+	_, err := am.CreateAgent("agent-1", "Weather", "weather")
+	require.NoError(t, err)
+
+	require.NoError(t, tr.RegisterTool(&chat.ToolDefinition{
+		Name:        "get_weather",
+		Description: "Current weather for a city",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"city": map[string]string{"type": "string"}},
+		},
+	}, func(args map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{"city": args["city"], "temp_c": 21}, nil
+	}))
+	require.NoError(t, am.BindTool("agent-1", "get_weather"))
+	return am
+}
+
+func TestAgentRunnerExecutesToolCalls(t *testing.T) {
+	client := &scriptedRelay{responses: []relay.ChatMessage{
+		weatherToolCall("paris", "tokyo"),
+		{Role: "assistant", Content: "Both cities are at 21°C."},
+	}}
+	runner := NewAgentRunner(client, newWeatherAgent(t), 0)
+
+	result, err := runner.Run(context.Background(), "agent-1", []relay.ChatMessage{{Role: "user", Content: "Weather in Paris and Tokyo?"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Both cities are at 21°C.", result.Message.Content)
+	assert.Equal(t, 2, result.Iterations)
+	assert.Equal(t, 30, result.Usage.TotalTokens)
+	require.Len(t, result.ToolCalls, 2)
+	assert.Equal(t, "completed", result.ToolCalls[0].Status)
+
+	require.Len(t, client.requests, 2)
+	first := client.requests[0]
+	assert.Equal(t, "gpt-4o-mini", first.Model)
+	require.Len(t, first.Tools, 1)
+	assert.Equal(t, "get_weather", first.Tools[0]["function"].(map[string]interface{})["name"])
+	assert.Equal(t, "system", first.Messages[0].Role)
+
+	// 第二次请求带上助手的工具调用和两条按顺序排列的工具结果
+	second := client.requests[1].Messages
+	require.Len(t, second, 5)
+	assert.Len(t, second[2].ToolCalls, 2)
+	assert.Equal(t, "tool", second[3].Role)
+	assert.Equal(t, "paris", second[3].ToolCallID)
+	assert.JSONEq(t, `{"city":"paris","temp_c":21}`, second[3].Content)
+	assert.Equal(t, "tokyo", second[4].ToolCallID)
+}
+
+func TestAgentRunnerReportsToolErrorsToModel(t *testing.T) {
+	bad := relay.ChatMessage{Role: "assistant", ToolCalls: []relay.ChatToolCall{
+		{ID: "a", Type: "function", Function: relay.ChatFunctionCall{Name: "get_weather", Arguments: "{not json"}},
+		{ID: "b", Type: "function", Function: relay.ChatFunctionCall{Name: "delete_everything", Arguments: "{}"}},
+	}}
+	client := &scriptedRelay{responses: []relay.ChatMessage{bad, {Role: "assistant", Content: "Sorry."}}}
+
+	result, err := NewAgentRunner(client, newWeatherAgent(t), 0).Run(context.Background(), "agent-1", []relay.ChatMessage{{Role: "user", Content: "hi"}})
+	require.NoError(t, err)
+
+	second := client.requests[1].Messages
+	assert.Contains(t, second[3].Content, "invalid arguments")
+	assert.Contains(t, second[4].Content, "not available")
+	assert.Equal(t, "failed", result.ToolCalls[1].Status)
+}
+
+func TestAgentRunnerStopsAtMaxIterations(t *testing.T) {
+	client := &scriptedRelay{responses: []relay.ChatMessage{weatherToolCall("paris")}}
+
+	result, err := NewAgentRunner(client, newWeatherAgent(t), 3).Run(context.Background(), "agent-1", []relay.ChatMessage{{Role: "user", Content: "loop"}})
+	assert.ErrorIs(t, err, ErrAgentMaxIterations)
+	assert.Equal(t, 3, result.Iterations)
+	assert.Len(t, client.requests, 3)
+}
+
+func TestAgentRunnerStreamEmitsToolEvents(t *testing.T) {
+	client := &scriptedRelay{responses: []relay.ChatMessage{
+		weatherToolCall("paris"),
+		{Role: "assistant", Content: "21°C in Paris."},
+	}}
+
+	var out bytes.Buffer
+	_, err := NewAgentRunner(client, newWeatherAgent(t), 0).RunStream(context.Background(), "agent-1", []relay.ChatMessage{{Role: "user", Content: "Paris?"}}, &out)
+	require.NoError(t, err)
+
+	var types []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var event map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+		types = append(types, event["type"].(string))
+		if event["type"] == "done" {
+			assert.Equal(t, "21°C in Paris.", event["content"])
+		}
+	}
+	assert.Equal(t, []string{"tool_call", "tool_result", "done"}, types)
+}
@@ -2,11 +2,15 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/lib/pq"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/chat"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
 	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
@@ -16,12 +20,14 @@ import (
 // AgentService 处理助手相关的业务逻辑
 type AgentService struct {
 	agentRepo *repository.AgentRepository
+	prompts   *chat.SystemPromptManager // 系统提示词版本历史
 }
 
 // NewAgentService 创建新的 Agent Service
 func NewAgentService() *AgentService {
 	return &AgentService{
 		agentRepo: repository.NewAgentRepository(),
+		prompts:   chat.NewPersistentSystemPromptManager(&promptVersionStore{repo: repository.NewPromptVersionRepository()}),
 	}
 }
 
@@ -88,6 +94,10 @@ func (s *AgentService) CreateAgent(ctx context.Context, userID int, req *CreateA
 		return nil, err
 	}
 
+	if err := s.recordAgentPrompt(agent, strconv.Itoa(userID)); err != nil {
+		logger.Warn("Failed to record agent prompt version", zap.Error(err), zap.Int("agent_id", agent.ID))
+	}
+
 	return agent, nil
 }
 
@@ -205,7 +215,11 @@ func (s *AgentService) UpdateAgent(ctx context.Context, userID int, id int, req
 	if req.Category != "" {
 		agent.Category = req.Category
 	}
-	if req.SystemRole != "" {
+	if req.SystemRole != "" && req.SystemRole != agent.SystemRole {
+		// 先记录修改前的内容，早于版本历史创建的助手也能回滚
+		if err := s.recordAgentPrompt(agent, ""); err != nil {
+			logger.Warn("Failed to record agent prompt version", zap.Error(err), zap.Int("agent_id", agent.ID))
+		}
 		agent.SystemRole = req.SystemRole
 	}
 	if req.Model != "" {
@@ -228,6 +242,10 @@ func (s *AgentService) UpdateAgent(ctx context.Context, userID int, id int, req
 		return nil, err
 	}
 
+	if err := s.recordAgentPrompt(agent, strconv.Itoa(userID)); err != nil {
+		logger.Warn("Failed to record agent prompt version", zap.Error(err), zap.Int("agent_id", agent.ID))
+	}
+
 	return agent, nil
 }
 
@@ -296,29 +314,24 @@ func (s *AgentService) ForkAgent(ctx context.Context, userID int, originalID int
 		return nil, fmt.Errorf("original agent not found")
 	}
 
-	// 创建新的助手（Fork）
-	identifier := generateIdentifier(forkName)
-	newAgent := &model.Agent{
-		UserID:       &userID,
-		Identifier:   identifier,
-		Name:         forkName,
-		Avatar:       originalAgent.Avatar,
-		Description:  fmt.Sprintf("Fork of %s", originalAgent.Name),
-		Category:     originalAgent.Category,
-		SystemRole:   originalAgent.SystemRole,
-		Model:        originalAgent.Model,
-		Temperature:  originalAgent.Temperature,
-		TopP:         originalAgent.TopP,
-		MaxTokens:    originalAgent.MaxTokens,
-		IsPublic:     false, // Fork 默认为私有
-		Status:       1,
+	// 私有助手只有所有者可以 Fork
+	if !originalAgent.IsPublic && (originalAgent.UserID == nil || *originalAgent.UserID != userID) {
+		return nil, fmt.Errorf("permission denied")
 	}
 
+	// 创建新的助手（Fork）
+	newAgent := forkAgentModel(originalAgent, userID, forkName)
+
 	if err := s.agentRepo.Create(ctx, newAgent); err != nil {
 		logger.Error("Failed to fork agent", zap.Error(err))
 		return nil, err
 	}
 
+	// 提示词以新 ID 开始独立的版本历史
+	if err := s.recordAgentPrompt(newAgent, strconv.Itoa(userID)); err != nil {
+		logger.Warn("Failed to record agent prompt version", zap.Error(err), zap.Int("agent_id", newAgent.ID))
+	}
+
 	// 记录 Fork
 	fork := &model.AgentFork{
 		OriginalID: originalID,
@@ -336,6 +349,38 @@ func (s *AgentService) ForkAgent(ctx context.Context, userID int, originalID int
 	return newAgent, nil
 }
 
+// forkAgentModel 复制助手配置给 userID，指针、切片与 JSON 字段都会复制，统计清零
+func forkAgentModel(original *model.Agent, userID int, forkName string) *model.Agent {
+	fork := &model.Agent{
+		UserID:      &userID,
+		Identifier:  generateIdentifier(forkName),
+		Name:        forkName,
+		Avatar:      original.Avatar,
+		Description: fmt.Sprintf("Fork of %s", original.Name),
+		Category:    original.Category,
+		SystemRole:  original.SystemRole,
+		Model:       original.Model,
+		Temperature: original.Temperature,
+		TopP:        original.TopP,
+		IsPublic:    false, // Fork 默认为私有
+		Status:      1,
+	}
+	if original.MaxTokens != nil {
+		maxTokens := *original.MaxTokens
+		fork.MaxTokens = &maxTokens
+	}
+	if original.Tools != nil {
+		fork.Tools = append(json.RawMessage(nil), original.Tools...)
+	}
+	if original.PluginIDs != nil {
+		fork.PluginIDs = append(pq.Int64Array(nil), original.PluginIDs...)
+	}
+	if original.KnowledgeBaseIDs != nil {
+		fork.KnowledgeBaseIDs = append(pq.Int64Array(nil), original.KnowledgeBaseIDs...)
+	}
+	return fork
+}
+
 // RecordAgentUsage 记录助手使用情况
 func (s *AgentService) RecordAgentUsage(ctx context.Context, agentID int, userID int, sessionID string, messageCount, tokenCount int, cost float64) error {
 	usage := &model.AgentUsage{
@@ -363,6 +408,15 @@ func (s *AgentService) GetUsageStats(ctx context.Context, agentID int) (map[stri
 		return nil, err
 	}
 
+	agent, err := s.agentRepo.FindByID(ctx, agentID)
+	if err != nil {
+		logger.Error("Failed to get agent", zap.Error(err))
+		return nil, err
+	}
+	if agent != nil {
+		stats["fork_count"] = agent.Forks
+	}
+
 	return stats, nil
 }
 
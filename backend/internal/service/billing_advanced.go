@@ -40,7 +40,7 @@ func (s *AdvancedBillingService) RecordUsage(ctx context.Context, record *model.
 }
 
 // CalculateCost 计算 Token 成本
-func (s *AdvancedBillingService) CalculateCost(ctx context.Context, userID, model string, promptTokens, completionTokens int) (float32, error) {
+func (s *AdvancedBillingService) CalculateCost(ctx context.Context, userID, modelID string, promptTokens, completionTokens int) (float32, error) {
 	// 获取用户订阅信息
 	var subscription model.Subscription
 	if err := s.db.WithContext(ctx).
@@ -56,9 +56,9 @@ func (s *AdvancedBillingService) CalculateCost(ctx context.Context, userID, mode
 	// 获取模型价格
 	var modelPrice model.ModelPrice
 	if err := s.db.WithContext(ctx).
-		Where("model_id = ? AND is_active = ?", model, true).
+		Where("model_id = ? AND is_active = ?", modelID, true).
 		First(&modelPrice).Error; err != nil {
-		return 0, fmt.Errorf("model price not found for %s", model)
+		return 0, fmt.Errorf("model price not found for %s", modelID)
 	}
 
 	// 计算成本
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// ErrInvalidAlertPreference 通知偏好的渠道、目标或等级无效
+var ErrInvalidAlertPreference = errors.New("invalid alert preference")
+
+// AlertRecordStore 预警偏好与投递记录存储，由 repository.AlertRepository 实现
+type AlertRecordStore interface {
+	ListPreferences(ctx context.Context, userID string) ([]*model.AlertPreference, error)
+	UpsertPreference(ctx context.Context, pref *model.AlertPreference) error
+	DeletePreference(ctx context.Context, userID, channel string) (bool, error)
+	CreateAlert(ctx context.Context, alert *model.QuotaAlertRecord) error
+	CreateDelivery(ctx context.Context, delivery *model.QuotaAlertDelivery) error
+	ListAlerts(ctx context.Context, userID string, limit, offset int) ([]*model.QuotaAlertRecord, int64, error)
+	ListDeliveries(ctx context.Context, alertIDs []string) ([]*model.QuotaAlertDelivery, error)
+}
+
+// AlertHistoryItem 预警及其各渠道的投递结果
+type AlertHistoryItem struct {
+	*model.QuotaAlertRecord
+	Deliveries []*model.QuotaAlertDelivery `json:"deliveries"`
+}
+
+// AlertPreferenceInput 设置某个渠道的通知偏好
+type AlertPreferenceInput struct {
+	Channel  string
+	Target   string
+	Secret   string // 仅 Webhook 使用
+	MinLevel string // 为空时为 warning
+	Enabled  bool
+}
+
+// QuotaAlertService 管理配额预警通知偏好并查询预警历史，同时实现 billing.AlertStore
+type QuotaAlertService struct {
+	store AlertRecordStore
+}
+
+// NewQuotaAlertService 创建配额预警服务
+func NewQuotaAlertService(store AlertRecordStore) *QuotaAlertService {
+	return &QuotaAlertService{store: store}
+}
+
+// SetupAlertNotifications 按配置为预警管理器注册 Webhook 与邮件通知渠道，通知偏好与投递记录保存在 store 中
+func SetupAlertNotifications(am *billing.AlertManager, store billing.AlertStore, cfg *config.AlertConfig) error {
+	am.SetAlertStore(store)
+	am.SetCooldown(time.Duration(cfg.CooldownMinutes) * time.Minute)
+	if err := am.SetDefaultThresholds(billing.DefaultAlertThresholds()); err != nil {
+		return err
+	}
+
+	notifiers := []billing.AlertNotifier{billing.NewWebhookNotifier()}
+	if cfg.SMTPAddr != "" {
+		tmpl := ""
+		if cfg.EmailTemplateFile != "" {
+			content, err := os.ReadFile(cfg.EmailTemplateFile)
+			if err != nil {
+				return err
+			}
+			tmpl = string(content)
+		}
+		sender := &billing.SMTPSender{Addr: cfg.SMTPAddr, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword}
+		email, err := billing.NewEmailNotifier(sender, cfg.SMTPFrom, tmpl)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, email)
+	} else {
+		logger.Info("ALERT_SMTP_ADDR not set, alert emails disabled")
+	}
+
+	for _, level := range []billing.AlertLevel{billing.AlertLevelWarning, billing.AlertLevelCritical, billing.AlertLevelExhausted} {
+		for _, n := range notifiers {
+			am.RegisterNotifier(level, n)
+		}
+	}
+	return nil
+}
+
+// GetAlertPreferences 返回用户的通知偏好，实现 billing.AlertStore
+func (s *QuotaAlertService) GetAlertPreferences(ctx context.Context, userID string) ([]*billing.AlertPreference, error) {
+	rows, err := s.store.ListPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	prefs := make([]*billing.AlertPreference, 0, len(rows))
+	for _, row := range rows {
+		level, err := billing.ParseAlertLevel(row.MinLevel)
+		if err != nil {
+			level = billing.AlertLevelWarning
+		}
+		prefs = append(prefs, &billing.AlertPreference{
+			UserID:   row.UserID,
+			Channel:  row.Channel,
+			Target:   row.Target,
+			Secret:   row.Secret,
+			MinLevel: level,
+			Enabled:  row.Enabled,
+		})
+	}
+	return prefs, nil
+}
+
+// RecordAlert 保存触发的预警，实现 billing.AlertStore
+func (s *QuotaAlertService) RecordAlert(ctx context.Context, alert *billing.QuotaAlert) error {
+	return s.store.CreateAlert(ctx, &model.QuotaAlertRecord{
+		AlertID:        alert.AlertID,
+		UserID:         alert.UserID,
+		Level:          alert.Level.String(),
+		UsageRate:      alert.UsageRate,
+		RemainingQuota: alert.RemainingQuota,
+		UsedQuota:      alert.UsedQuota,
+		TotalQuota:     alert.TotalQuota,
+		Message:        alert.Message,
+		TriggeredAt:    alert.TriggeredAt,
+	})
+}
+
+// RecordDelivery 保存投递结果，实现 billing.AlertStore
+func (s *QuotaAlertService) RecordDelivery(ctx context.Context, delivery *billing.AlertDelivery) error {
+	return s.store.CreateDelivery(ctx, &model.QuotaAlertDelivery{
+		AlertID:     delivery.AlertID,
+		UserID:      delivery.UserID,
+		Channel:     delivery.Channel,
+		Target:      delivery.Target,
+		Status:      delivery.Status,
+		Attempts:    delivery.Attempts,
+		Error:       delivery.Error,
+		DeliveredAt: delivery.DeliveredAt,
+		CreatedAt:   delivery.CreatedAt,
+	})
+}
+
+// ListPreferences 查询用户的通知偏好
+func (s *QuotaAlertService) ListPreferences(ctx context.Context, userID string) ([]*model.AlertPreference, error) {
+	return s.store.ListPreferences(ctx, userID)
+}
+
+// SavePreference 创建或更新用户在某个渠道上的通知偏好
+func (s *QuotaAlertService) SavePreference(ctx context.Context, userID string, in AlertPreferenceInput) (*model.AlertPreference, error) {
+	if in.MinLevel == "" {
+		in.MinLevel = billing.AlertLevelWarning.String()
+	}
+	if _, err := billing.ParseAlertLevel(in.MinLevel); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAlertPreference, err)
+	}
+
+	switch in.Channel {
+	case billing.AlertChannelWebhook:
+		u, err := url.Parse(in.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%w: webhook target must be an http(s) URL", ErrInvalidAlertPreference)
+		}
+	case billing.AlertChannelEmail:
+		addr, err := mail.ParseAddress(in.Target)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid email address", ErrInvalidAlertPreference)
+		}
+		in.Target = addr.Address
+		in.Secret = ""
+	default:
+		return nil, fmt.Errorf("%w: unknown channel %q", ErrInvalidAlertPreference, in.Channel)
+	}
+
+	now := time.Now()
+	pref := &model.AlertPreference{
+		UserID:    userID,
+		Channel:   in.Channel,
+		Target:    in.Target,
+		Secret:    in.Secret,
+		MinLevel:  in.MinLevel,
+		Enabled:   in.Enabled,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.store.UpsertPreference(ctx, pref); err != nil {
+		return nil, err
+	}
+	return pref, nil
+}
+
+// DeletePreference 删除用户在某个渠道上的通知偏好，返回是否存在
+func (s *QuotaAlertService) DeletePreference(ctx context.Context, userID, channel string) (bool, error) {
+	return s.store.DeletePreference(ctx, userID, channel)
+}
+
+// ListAlerts 分页查询用户的预警历史及投递结果，page_size 默认 20，最大 100
+func (s *QuotaAlertService) ListAlerts(ctx context.Context, userID string, page, pageSize int) ([]*AlertHistoryItem, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	alerts, total, err := s.store.ListAlerts(ctx, userID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	alertIDs := make([]string, 0, len(alerts))
+	items := make([]*AlertHistoryItem, 0, len(alerts))
+	byID := make(map[string]*AlertHistoryItem, len(alerts))
+	for _, alert := range alerts {
+		item := &AlertHistoryItem{QuotaAlertRecord: alert, Deliveries: make([]*model.QuotaAlertDelivery, 0)}
+		alertIDs = append(alertIDs, alert.AlertID)
+		items = append(items, item)
+		byID[alert.AlertID] = item
+	}
+
+	deliveries, err := s.store.ListDeliveries(ctx, alertIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, d := range deliveries {
+		if item, ok := byID[d.AlertID]; ok {
+			item.Deliveries = append(item.Deliveries, d)
+		}
+	}
+	return items, total, nil
+}
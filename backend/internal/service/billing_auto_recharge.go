@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidAutoRecharge 自动充值配置无效
+var ErrInvalidAutoRecharge = errors.New("invalid auto-recharge settings")
+
+// AutoRechargeRecordStore 自动充值配置与记录存储，由 repository.AutoRechargeRepository 实现
+type AutoRechargeRecordStore interface {
+	FindSetting(ctx context.Context, userID int) (*model.AutoRechargeSetting, error)
+	SaveSetting(ctx context.Context, setting *model.AutoRechargeSetting) error
+	ListEnabledSettings(ctx context.Context) ([]*model.AutoRechargeSetting, error)
+	Suspend(ctx context.Context, userID int, reason string) error
+	SaveRecord(ctx context.Context, record *model.AutoRechargeRecord) error
+	FindUnfinishedRecord(ctx context.Context, userID int) (*model.AutoRechargeRecord, error)
+	CountCompleted(ctx context.Context, userID int, since time.Time) (int64, error)
+	ListRecords(ctx context.Context, userID, limit int) ([]*model.AutoRechargeRecord, error)
+}
+
+// StripePaymentClient 使用 Stripe 保存的支付方式离线扣款，实现 billing.PaymentClient
+type StripePaymentClient struct {
+	stripe *StripeClient
+}
+
+// NewStripePaymentClient 创建 Stripe 扣款客户端
+func NewStripePaymentClient(stripe *StripeClient) *StripePaymentClient {
+	return &StripePaymentClient{stripe: stripe}
+}
+
+// ChargeOffSession 创建并确认离线 PaymentIntent，卡被拒绝或需要持卡人验证时返回 billing.ErrPaymentDeclined
+func (c *StripePaymentClient) ChargeOffSession(ctx context.Context, req *billing.ChargeRequest) (*billing.ChargeResult, error) {
+	intent, err := c.stripe.CreateOffSessionPaymentIntent(ctx, req.CustomerID, req.PaymentMethodID, req.AmountCents, req.Currency,
+		req.Description, req.IdempotencyKey, map[string]string{"user_id": req.UserID, "purpose": "auto_recharge"})
+	if err != nil {
+		var stripeErr *StripeError
+		if errors.As(err, &stripeErr) && stripeErr.IsCardError() {
+			return nil, fmt.Errorf("%w: %s", billing.ErrPaymentDeclined, stripeErr.Message)
+		}
+		return nil, err
+	}
+
+	switch intent.Status {
+	case "succeeded":
+		return &billing.ChargeResult{PaymentIntentID: intent.ID}, nil
+	case "requires_action", "requires_payment_method", "canceled":
+		return nil, fmt.Errorf("%w: payment intent %s is %s", billing.ErrPaymentDeclined, intent.ID, intent.Status)
+	default:
+		// processing 等中间状态，稍后以相同幂等键重试取得最终结果
+		return nil, fmt.Errorf("payment intent %s is %s", intent.ID, intent.Status)
+	}
+}
+
+// Refund 全额退款
+func (c *StripePaymentClient) Refund(ctx context.Context, paymentIntentID, idempotencyKey string) error {
+	return c.stripe.RefundPaymentIntent(ctx, paymentIntentID, idempotencyKey)
+}
+
+// AutoRechargeInput 更新自动充值配置
+type AutoRechargeInput struct {
+	Enabled          bool
+	TriggerThreshold float64
+	RechargeAmount   int64
+	MaxPerPeriod     int
+	PeriodDays       int
+}
+
+// AutoRechargeStatus 自动充值配置、支付方式状态与最近的充值记录
+type AutoRechargeStatus struct {
+	*model.AutoRechargeSetting
+	HasPaymentMethod bool                        `json:"has_payment_method"`
+	Records          []*model.AutoRechargeRecord `json:"records"`
+}
+
+// AutoRechargeService 管理自动充值配置与保存的支付方式，同时实现 billing.AutoRechargeStore
+type AutoRechargeService struct {
+	store    AutoRechargeRecordStore
+	manager  *billing.AutoRechargeManager
+	stripe   *StripeClient
+	currency string
+	cfg      CheckoutConfig
+}
+
+// NewAutoRechargeService 创建自动充值服务
+func NewAutoRechargeService(store AutoRechargeRecordStore, manager *billing.AutoRechargeManager, stripe *StripeClient, currency string, cfg CheckoutConfig) *AutoRechargeService {
+	return &AutoRechargeService{
+		store:    store,
+		manager:  manager,
+		stripe:   stripe,
+		currency: currency,
+		cfg:      cfg,
+	}
+}
+
+// LoadConfigs 将数据库中启用的自动充值配置加载到 AutoRechargeManager
+func (s *AutoRechargeService) LoadConfigs(ctx context.Context) (int, error) {
+	settings, err := s.store.ListEnabledSettings(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, setting := range settings {
+		s.apply(setting)
+	}
+	return len(settings), nil
+}
+
+// GetStatus 查询用户的自动充值配置与最近 10 条充值记录
+func (s *AutoRechargeService) GetStatus(ctx context.Context, userID int) (*AutoRechargeStatus, error) {
+	setting, err := s.store.FindSetting(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if setting == nil {
+		setting = &model.AutoRechargeSetting{UserID: userID, TriggerThreshold: 90, MaxPerPeriod: 3, PeriodDays: 30}
+	}
+	records, err := s.store.ListRecords(ctx, userID, 10)
+	if err != nil {
+		return nil, err
+	}
+	return &AutoRechargeStatus{
+		AutoRechargeSetting: setting,
+		HasPaymentMethod:    setting.PaymentMethodID != "",
+		Records:             records,
+	}, nil
+}
+
+// UpdateSettings 更新自动充值配置，启用时要求已保存支付方式
+func (s *AutoRechargeService) UpdateSettings(ctx context.Context, userID int, in AutoRechargeInput) (*model.AutoRechargeSetting, error) {
+	if in.TriggerThreshold <= 0 || in.TriggerThreshold > 100 {
+		return nil, fmt.Errorf("%w: trigger_threshold must be in (0, 100]", ErrInvalidAutoRecharge)
+	}
+	if in.RechargeAmount <= 0 || in.MaxPerPeriod < 1 || in.PeriodDays < 1 {
+		return nil, fmt.Errorf("%w: recharge_amount, max_per_period and period_days must be positive", ErrInvalidAutoRecharge)
+	}
+
+	setting, err := s.store.FindSetting(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if setting == nil {
+		setting = &model.AutoRechargeSetting{UserID: userID, CreatedAt: time.Now()}
+	}
+	if in.Enabled && setting.PaymentMethodID == "" {
+		return nil, billing.ErrNoPaymentMethod
+	}
+
+	setting.Enabled = in.Enabled
+	setting.TriggerThreshold = in.TriggerThreshold
+	setting.RechargeAmount = in.RechargeAmount
+	setting.MaxPerPeriod = in.MaxPerPeriod
+	setting.PeriodDays = in.PeriodDays
+	setting.UpdatedAt = time.Now()
+	if err := s.store.SaveSetting(ctx, setting); err != nil {
+		return nil, err
+	}
+	s.apply(setting)
+	return setting, nil
+}
+
+// CreatePaymentMethodSetup 创建保存支付方式的 Checkout Session，返回支付页地址；完成后由 Webhook 保存支付方式
+func (s *AutoRechargeService) CreatePaymentMethodSetup(ctx context.Context, userID int) (string, error) {
+	setting, err := s.store.FindSetting(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if setting == nil {
+		setting = &model.AutoRechargeSetting{UserID: userID, TriggerThreshold: 90, MaxPerPeriod: 3, PeriodDays: 30, CreatedAt: time.Now()}
+	}
+	if setting.StripeCustomerID == "" {
+		customerID, err := s.stripe.CreateCustomer(ctx, "", map[string]string{"user_id": strconv.Itoa(userID)})
+		if err != nil {
+			return "", err
+		}
+		setting.StripeCustomerID = customerID
+		setting.UpdatedAt = time.Now()
+		if err := s.store.SaveSetting(ctx, setting); err != nil {
+			return "", err
+		}
+	}
+
+	session, err := s.stripe.CreateSetupSession(ctx, setting.StripeCustomerID, s.currency, strconv.Itoa(userID), s.cfg.SuccessURL, s.cfg.CancelURL)
+	if err != nil {
+		return "", err
+	}
+	return session.URL, nil
+}
+
+// HandleSetupCompleted 保存 setup 模式 Checkout Session 中的支付方式，并恢复被暂停的自动充值
+func (s *AutoRechargeService) HandleSetupCompleted(ctx context.Context, session *StripeCheckoutSession) error {
+	userID, err := strconv.Atoi(session.ClientReferenceID)
+	if err != nil {
+		return fmt.Errorf("invalid client_reference_id %q in setup session %s", session.ClientReferenceID, session.ID)
+	}
+	paymentMethodID, err := s.stripe.SetupIntentPaymentMethod(ctx, session.SetupIntent)
+	if err != nil {
+		return err
+	}
+
+	setting, err := s.store.FindSetting(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if setting == nil {
+		setting = &model.AutoRechargeSetting{UserID: userID, TriggerThreshold: 90, MaxPerPeriod: 3, PeriodDays: 30, CreatedAt: time.Now()}
+	}
+	if session.Customer != "" {
+		setting.StripeCustomerID = session.Customer
+	}
+	setting.PaymentMethodID = paymentMethodID
+	setting.Suspended = false
+	setting.SuspendReason = ""
+	setting.UpdatedAt = time.Now()
+	if err := s.store.SaveSetting(ctx, setting); err != nil {
+		return err
+	}
+
+	logger.Info("auto-recharge payment method saved",
+		zap.Int("user_id", userID),
+		zap.String("session_id", session.ID))
+	return nil
+}
+
+// apply 同步配置到 AutoRechargeManager
+func (s *AutoRechargeService) apply(setting *model.AutoRechargeSetting) {
+	userID := strconv.Itoa(setting.UserID)
+	if !setting.Enabled {
+		s.manager.RemoveAutoRechargeConfig(userID)
+		return
+	}
+	if err := s.manager.CreateAutoRechargeConfig(userID, setting.TriggerThreshold, float64(setting.RechargeAmount), setting.MaxPerPeriod, setting.PeriodDays); err != nil {
+		logger.Warn("invalid auto-recharge settings",
+			zap.Int("user_id", setting.UserID),
+			zap.Error(err))
+	}
+}
+
+// GetPaymentMethod 实现 billing.AutoRechargeStore
+func (s *AutoRechargeService) GetPaymentMethod(ctx context.Context, userID string) (*billing.PaymentMethod, error) {
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user id %q", userID)
+	}
+	setting, err := s.store.FindSetting(ctx, id)
+	if err != nil || setting == nil || setting.PaymentMethodID == "" {
+		return nil, err
+	}
+	return &billing.PaymentMethod{
+		UserID:          userID,
+		CustomerID:      setting.StripeCustomerID,
+		PaymentMethodID: setting.PaymentMethodID,
+		Suspended:       setting.Suspended,
+		SuspendReason:   setting.SuspendReason,
+	}, nil
+}
+
+// SuspendAutoRecharge 实现 billing.AutoRechargeStore
+func (s *AutoRechargeService) SuspendAutoRecharge(ctx context.Context, userID, reason string) error {
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user id %q", userID)
+	}
+	return s.store.Suspend(ctx, id, reason)
+}
+
+// SaveRecharge 实现 billing.AutoRechargeStore
+func (s *AutoRechargeService) SaveRecharge(ctx context.Context, record *billing.RechargeRecord) error {
+	id, err := strconv.Atoi(record.UserID)
+	if err != nil {
+		return fmt.Errorf("invalid user id %q", record.UserID)
+	}
+	return s.store.SaveRecord(ctx, &model.AutoRechargeRecord{
+		RecordID:        record.RecordID,
+		UserID:          id,
+		Amount:          record.Amount,
+		AmountCents:     record.AmountCents,
+		Currency:        record.Currency,
+		Status:          record.Status,
+		IdempotencyKey:  record.IdempotencyKey,
+		PaymentIntentID: record.PaymentIntentID,
+		Error:           record.Error,
+		Reason:          record.Reason,
+		CreatedAt:       record.CreatedAt,
+		UpdatedAt:       record.UpdatedAt,
+	})
+}
+
+// PendingRecharge 实现 billing.AutoRechargeStore
+func (s *AutoRechargeService) PendingRecharge(ctx context.Context, userID string) (*billing.RechargeRecord, error) {
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user id %q", userID)
+	}
+	row, err := s.store.FindUnfinishedRecord(ctx, id)
+	if err != nil || row == nil {
+		return nil, err
+	}
+	return &billing.RechargeRecord{
+		RecordID:        row.RecordID,
+		UserID:          userID,
+		Amount:          row.Amount,
+		AmountCents:     row.AmountCents,
+		Currency:        row.Currency,
+		Status:          row.Status,
+		IdempotencyKey:  row.IdempotencyKey,
+		PaymentIntentID: row.PaymentIntentID,
+		Error:           row.Error,
+		Reason:          row.Reason,
+		CreatedAt:       row.CreatedAt,
+		UpdatedAt:       row.UpdatedAt,
+	}, nil
+}
+
+// CountRecharges 实现 billing.AutoRechargeStore
+func (s *AutoRechargeService) CountRecharges(ctx context.Context, userID string, since time.Time) (int, error) {
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid user id %q", userID)
+	}
+	count, err := s.store.CountCompleted(ctx, id, since)
+	return int(count), err
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/quota"
+	"go.uber.org/zap"
+)
+
+// ErrUnknownQuotaPackage 额度套餐不存在
+var ErrUnknownQuotaPackage = errors.New("unknown quota package")
+
+// QuotaPackage 可购买的额度套餐
+type QuotaPackage struct {
+	ID          string `json:"id"`
+	Quota       int64  `json:"quota"`        // 入账额度（分）
+	AmountCents int64  `json:"amount_cents"` // 售价（最小货币单位）
+	Currency    string `json:"currency"`
+}
+
+// ParseQuotaPackages 解析套餐配置，格式为 "id:quota:amount_cents"，多个套餐以逗号分隔
+func ParseQuotaPackages(spec, currency string) ([]QuotaPackage, error) {
+	var packages []QuotaPackage
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid quota package %q", item)
+		}
+		quota, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || quota <= 0 {
+			return nil, fmt.Errorf("invalid quota in package %q", item)
+		}
+		amount, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil || amount <= 0 {
+			return nil, fmt.Errorf("invalid amount in package %q", item)
+		}
+		if seen[parts[0]] {
+			return nil, fmt.Errorf("duplicate quota package %q", parts[0])
+		}
+		seen[parts[0]] = true
+		packages = append(packages, QuotaPackage{ID: parts[0], Quota: quota, AmountCents: amount, Currency: currency})
+	}
+	return packages, nil
+}
+
+// RechargeInvoiceStore 充值发票存储，由 repository.RechargeInvoiceRepository 实现
+type RechargeInvoiceStore interface {
+	CreateInvoice(ctx context.Context, invoice *model.RechargeInvoice) error
+	ListInvoices(ctx context.Context, userID int, limit, offset int) ([]*model.RechargeInvoice, int64, error)
+	// MarkPaid 在事务中结算待支付的发票：verify 返回错误时标记为 failed 且不入账，否则标记为已支付并入账；
+	// 发票不存在时返回 nil，已结算的发票返回 false 且不调用 verify
+	MarkPaid(ctx context.Context, sessionID, paymentIntentID, receiptURL string, verify func(invoice *model.RechargeInvoice) error) (*model.RechargeInvoice, bool, error)
+}
+
+// CheckoutConfig Checkout 支付完成或取消后的跳转地址
+type CheckoutConfig struct {
+	SuccessURL string
+	CancelURL  string
+}
+
+// CheckoutService 通过 Stripe Checkout 购买额度套餐，支付完成后入账
+type CheckoutService struct {
+	store    RechargeInvoiceStore
+	quotas   *billing.QuotaManager
+	notifier quota.BalanceNotifier
+	stripe   *StripeClient
+	packages []QuotaPackage
+	cfg      CheckoutConfig
+
+	// 处理保存支付方式（setup 模式）的 Checkout Session，为 nil 时忽略
+	setupHandler func(ctx context.Context, session *StripeCheckoutSession) error
+}
+
+// NewCheckoutService 创建充值服务
+func NewCheckoutService(store RechargeInvoiceStore, quotas *billing.QuotaManager, stripe *StripeClient, packages []QuotaPackage, cfg CheckoutConfig) *CheckoutService {
+	return &CheckoutService{
+		store:    store,
+		quotas:   quotas,
+		notifier: quota.NewRedisBalanceNotifier(nil),
+		stripe:   stripe,
+		packages: packages,
+		cfg:      cfg,
+	}
+}
+
+// SetSetupHandler 设置 setup 模式 Checkout Session 完成后的处理函数
+func (s *CheckoutService) SetSetupHandler(handler func(ctx context.Context, session *StripeCheckoutSession) error) {
+	s.setupHandler = handler
+}
+
+// Packages 返回可购买的套餐
+func (s *CheckoutService) Packages() []QuotaPackage {
+	return s.packages
+}
+
+// CreateInvoice 为套餐创建 Checkout Session 并记录待支付的发票
+func (s *CheckoutService) CreateInvoice(ctx context.Context, userID int, packageID string) (*model.RechargeInvoice, error) {
+	pkg, ok := s.findPackage(packageID)
+	if !ok {
+		return nil, ErrUnknownQuotaPackage
+	}
+
+	session, err := s.stripe.CreateCheckoutSession(ctx, StripeCheckoutParams{
+		ClientReferenceID: strconv.Itoa(userID),
+		ProductName:       fmt.Sprintf("Quota package %s", pkg.ID),
+		AmountCents:       pkg.AmountCents,
+		Currency:          pkg.Currency,
+		SuccessURL:        s.cfg.SuccessURL,
+		CancelURL:         s.cfg.CancelURL,
+		Metadata:          map[string]string{"user_id": strconv.Itoa(userID), "package_id": pkg.ID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invoice := &model.RechargeInvoice{
+		UserID:            userID,
+		PackageID:         pkg.ID,
+		Quota:             pkg.Quota,
+		AmountCents:       pkg.AmountCents,
+		Currency:          pkg.Currency,
+		Status:            model.RechargeInvoicePending,
+		CheckoutSessionID: session.ID,
+		CheckoutURL:       session.URL,
+	}
+	if err := s.store.CreateInvoice(ctx, invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// ListInvoices 分页查询用户的充值发票，page_size 默认 20，最大 100
+func (s *CheckoutService) ListInvoices(ctx context.Context, userID, page, pageSize int) ([]*model.RechargeInvoice, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return s.store.ListInvoices(ctx, userID, pageSize, (page-1)*pageSize)
+}
+
+// HandleWebhook 校验签名并处理 Checkout 支付完成事件；重复投递的事件不会重复入账
+func (s *CheckoutService) HandleWebhook(ctx context.Context, payload []byte, signature string) error {
+	event, err := s.stripe.ConstructEvent(payload, signature)
+	if err != nil {
+		return err
+	}
+	if event.Type != "checkout.session.completed" && event.Type != "checkout.session.async_payment_succeeded" {
+		return nil
+	}
+
+	var session StripeCheckoutSession
+	if err := json.Unmarshal(event.Data.Object, &session); err != nil {
+		return fmt.Errorf("decode checkout session: %w", err)
+	}
+	if session.Mode == "setup" {
+		if s.setupHandler == nil {
+			return nil
+		}
+		return s.setupHandler(ctx, &session)
+	}
+	// 异步支付方式在 completed 时尚未到账，等待 async_payment_succeeded
+	if session.PaymentStatus != "paid" {
+		logger.Info("checkout session completed without payment",
+			zap.String("session_id", session.ID),
+			zap.String("payment_status", session.PaymentStatus))
+		return nil
+	}
+
+	receiptURL := ""
+	if session.PaymentIntent != "" {
+		if receiptURL, err = s.stripe.ReceiptURL(ctx, session.PaymentIntent); err != nil {
+			logger.Warn("failed to fetch stripe receipt",
+				zap.String("session_id", session.ID),
+				zap.Error(err))
+		}
+	}
+
+	// 金额或币种不符的发票标记为 failed 后正常确认，Stripe 不再重试，由人工核对后退款或补记
+	invoice, credited, err := s.store.MarkPaid(ctx, session.ID, session.PaymentIntent, receiptURL, func(invoice *model.RechargeInvoice) error {
+		if session.AmountTotal != invoice.AmountCents || !strings.EqualFold(session.Currency, invoice.Currency) {
+			return fmt.Errorf("checkout session %s paid %d %s, invoice expects %d %s",
+				session.ID, session.AmountTotal, session.Currency, invoice.AmountCents, invoice.Currency)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("failed to settle recharge invoice",
+			zap.String("session_id", session.ID),
+			zap.Error(err))
+		return err
+	}
+
+	switch {
+	case invoice == nil:
+		logger.Warn("checkout session has no recharge invoice", zap.String("session_id", session.ID))
+	case credited:
+		// 数据库事务提交后才更新内存额度并广播余额变更，提交失败时 Stripe 重试不会重复入账
+		if err := s.quotas.Recharge(strconv.Itoa(invoice.UserID), float64(invoice.Quota)); err != nil {
+			logger.Warn("failed to update in-memory quota after recharge",
+				zap.Int64("invoice_id", invoice.ID),
+				zap.Error(err))
+		}
+		if s.notifier != nil {
+			_ = s.notifier.NotifyBalanceChanged(context.WithoutCancel(ctx), invoice.UserID)
+		}
+		logger.Info("recharge invoice paid",
+			zap.Int64("invoice_id", invoice.ID),
+			zap.Int("user_id", invoice.UserID),
+			zap.Int64("quota", invoice.Quota))
+	case invoice.Status == model.RechargeInvoiceFailed:
+		logger.Error("recharge invoice failed verification, not credited",
+			zap.String("event_id", event.ID),
+			zap.Int64("invoice_id", invoice.ID),
+			zap.Int("user_id", invoice.UserID),
+			zap.String("reason", invoice.FailureReason))
+	default:
+		logger.Info("duplicate checkout webhook ignored",
+			zap.String("event_id", event.ID),
+			zap.Int64("invoice_id", invoice.ID))
+	}
+	return nil
+}
+
+func (s *CheckoutService) findPackage(id string) (QuotaPackage, bool) {
+	for _, pkg := range s.packages {
+		if pkg.ID == id {
+			return pkg, true
+		}
+	}
+	return QuotaPackage{}, false
+}
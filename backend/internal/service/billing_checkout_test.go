@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testWebhookSecret = "whsec_test"
+
+// memoryInvoices 内存中的发票存储，MarkPaid 与数据库实现一样在 verify 失败时标记为 failed，
+// commitErr 不为空时模拟事务提交失败
+type memoryInvoices struct {
+	mu        sync.Mutex
+	invoices  []*model.RechargeInvoice
+	commitErr error
+}
+
+func (s *memoryInvoices) CreateInvoice(ctx context.Context, invoice *model.RechargeInvoice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invoice.ID = int64(len(s.invoices) + 1)
+	invoice.CreatedAt = time.Now()
+	copied := *invoice
+	s.invoices = append(s.invoices, &copied)
+	return nil
+}
+
+func (s *memoryInvoices) ListInvoices(ctx context.Context, userID int, limit, offset int) ([]*model.RechargeInvoice, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []*model.RechargeInvoice
+	for _, invoice := range s.invoices {
+		if invoice.UserID == userID {
+			copied := *invoice
+			result = append(result, &copied)
+		}
+	}
+	return result, int64(len(result)), nil
+}
+
+func (s *memoryInvoices) MarkPaid(ctx context.Context, sessionID, paymentIntentID, receiptURL string, verify func(invoice *model.RechargeInvoice) error) (*model.RechargeInvoice, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, invoice := range s.invoices {
+		if invoice.CheckoutSessionID != sessionID {
+			continue
+		}
+		if invoice.Status != model.RechargeInvoicePending {
+			copied := *invoice
+			return &copied, false, nil
+		}
+		updated := *invoice
+		updated.PaymentIntentID = paymentIntentID
+		updated.ReceiptURL = receiptURL
+		credited := true
+		if err := verify(&updated); err != nil {
+			updated.Status = model.RechargeInvoiceFailed
+			updated.FailureReason = err.Error()
+			credited = false
+		} else {
+			now := time.Now()
+			updated.Status = model.RechargeInvoicePaid
+			updated.PaidAt = &now
+		}
+		if s.commitErr != nil {
+			return nil, false, s.commitErr
+		}
+		*invoice = updated
+		return &updated, credited, nil
+	}
+	return nil, false, nil
+}
+
+// recordingNotifier 记录广播的余额变更
+type recordingNotifier struct {
+	mu      sync.Mutex
+	userIDs []int
+}
+
+func (n *recordingNotifier) NotifyBalanceChanged(ctx context.Context, userID int) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.userIDs = append(n.userIDs, userID)
+	return nil
+}
+
+// fakeStripe 模拟 Checkout Session 创建与 PaymentIntent 查询
+func fakeStripe(t *testing.T) *httptest.Server {
+	sessions := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		assert.Equal(t, "sk_test", user)
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/checkout/sessions":
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "payment", r.Form.Get("mode"))
+			sessions++
+			id := fmt.Sprintf("cs_test_%d", sessions)
+			json.NewEncoder(w).Encode(map[string]string{"id": id, "url": "https://checkout.stripe.test/" + id})
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/payment_intents/pi_1":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"latest_charge": map[string]string{"receipt_url": "https://pay.stripe.test/receipts/1"},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"message":"no such resource"}}`))
+		}
+	}))
+}
+
+func newTestCheckout(t *testing.T) (*CheckoutService, *memoryInvoices, *billing.QuotaManager) {
+	server := fakeStripe(t)
+	t.Cleanup(server.Close)
+
+	store := &memoryInvoices{}
+	quotas := billing.NewQuotaManager()
+	packages, err := ParseQuotaPackages("starter:500:500,pro:12000:10000", "usd")
+	require.NoError(t, err)
+
+	checkout := NewCheckoutService(store, quotas, NewStripeClient("sk_test", testWebhookSecret, server.URL), packages,
+		CheckoutConfig{SuccessURL: "https://app.test/ok", CancelURL: "https://app.test/cancel"})
+	checkout.notifier = &recordingNotifier{}
+	return checkout, store, quotas
+}
+
+// signedCompletedEvent 构造签名后的 checkout.session.completed 事件
+func signedCompletedEvent(t *testing.T, eventID, sessionID string, amount int64) ([]byte, string) {
+	object, err := json.Marshal(map[string]interface{}{
+		"id":             sessionID,
+		"payment_status": "paid",
+		"payment_intent": "pi_1",
+		"amount_total":   amount,
+		"currency":       "usd",
+	})
+	require.NoError(t, err)
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":   eventID,
+		"type": "checkout.session.completed",
+		"data": map[string]json.RawMessage{"object": object},
+	})
+	require.NoError(t, err)
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	return payload, "t=" + ts + ",v1=" + StripeSignature(testWebhookSecret, ts, payload)
+}
+
+func TestParseQuotaPackages(t *testing.T) {
+	packages, err := ParseQuotaPackages(" starter:500:500 , pro:12000:10000", "usd")
+	require.NoError(t, err)
+	require.Len(t, packages, 2)
+	assert.Equal(t, QuotaPackage{ID: "pro", Quota: 12000, AmountCents: 10000, Currency: "usd"}, packages[1])
+
+	for _, spec := range []string{"starter:500", "starter:0:500", "starter:500:x", "a:1:1,a:2:2"} {
+		_, err := ParseQuotaPackages(spec, "usd")
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestCheckoutCreateInvoice(t *testing.T) {
+	checkout, _, _ := newTestCheckout(t)
+	ctx := context.Background()
+
+	invoice, err := checkout.CreateInvoice(ctx, 7, "starter")
+	require.NoError(t, err)
+	assert.Equal(t, model.RechargeInvoicePending, invoice.Status)
+	assert.Equal(t, "cs_test_1", invoice.CheckoutSessionID)
+	assert.Equal(t, "https://checkout.stripe.test/cs_test_1", invoice.CheckoutURL)
+	assert.Equal(t, int64(500), invoice.Quota)
+
+	_, err = checkout.CreateInvoice(ctx, 7, "enterprise")
+	assert.ErrorIs(t, err, ErrUnknownQuotaPackage)
+
+	invoices, total, err := checkout.ListInvoices(ctx, 7, 1, 20)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Len(t, invoices, 1)
+}
+
+func TestCheckoutWebhookCreditsOnce(t *testing.T) {
+	checkout, store, quotas := newTestCheckout(t)
+	ctx := context.Background()
+
+	invoice, err := checkout.CreateInvoice(ctx, 7, "pro")
+	require.NoError(t, err)
+
+	payload, signature := signedCompletedEvent(t, "evt_1", invoice.CheckoutSessionID, 10000)
+	require.NoError(t, checkout.HandleWebhook(ctx, payload, signature))
+	// Stripe 至少投递一次，重复的事件不能重复入账
+	require.NoError(t, checkout.HandleWebhook(ctx, payload, signature))
+
+	assert.Equal(t, float64(12000), quotas.GetQuota("7"))
+	assert.Equal(t, []int{7}, checkout.notifier.(*recordingNotifier).userIDs, "balance change published once")
+
+	invoices, _, err := store.ListInvoices(ctx, 7, 20, 0)
+	require.NoError(t, err)
+	require.Len(t, invoices, 1)
+	assert.Equal(t, model.RechargeInvoicePaid, invoices[0].Status)
+	assert.Equal(t, "pi_1", invoices[0].PaymentIntentID)
+	assert.Equal(t, "https://pay.stripe.test/receipts/1", invoices[0].ReceiptURL)
+	assert.NotNil(t, invoices[0].PaidAt)
+}
+
+func TestCheckoutWebhookRejectsBadSignature(t *testing.T) {
+	checkout, _, quotas := newTestCheckout(t)
+	ctx := context.Background()
+
+	invoice, err := checkout.CreateInvoice(ctx, 7, "starter")
+	require.NoError(t, err)
+
+	payload, _ := signedCompletedEvent(t, "evt_1", invoice.CheckoutSessionID, 500)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	forged := "t=" + ts + ",v1=" + StripeSignature("whsec_other", ts, payload)
+	assert.ErrorIs(t, checkout.HandleWebhook(ctx, payload, forged), ErrInvalidStripeSignature)
+
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	replayed := "t=" + stale + ",v1=" + StripeSignature(testWebhookSecret, stale, payload)
+	assert.ErrorIs(t, checkout.HandleWebhook(ctx, payload, replayed), ErrInvalidStripeSignature)
+
+	assert.Equal(t, float64(0), quotas.GetQuota("7"))
+}
+
+func TestCheckoutWebhookAmountMismatchFailsInvoice(t *testing.T) {
+	checkout, store, quotas := newTestCheckout(t)
+	ctx := context.Background()
+
+	invoice, err := checkout.CreateInvoice(ctx, 7, "pro")
+	require.NoError(t, err)
+
+	// 金额不符是终态，确认 Webhook 避免 Stripe 无限重试，重复投递同样确认
+	payload, signature := signedCompletedEvent(t, "evt_1", invoice.CheckoutSessionID, 500)
+	require.NoError(t, checkout.HandleWebhook(ctx, payload, signature))
+	require.NoError(t, checkout.HandleWebhook(ctx, payload, signature))
+
+	assert.Equal(t, float64(0), quotas.GetQuota("7"))
+	assert.Empty(t, checkout.notifier.(*recordingNotifier).userIDs)
+	invoices, _, err := store.ListInvoices(ctx, 7, 20, 0)
+	require.NoError(t, err)
+	assert.Equal(t, model.RechargeInvoiceFailed, invoices[0].Status)
+	assert.Contains(t, invoices[0].FailureReason, "paid 500 usd")
+	assert.Nil(t, invoices[0].PaidAt)
+}
+
+func TestCheckoutWebhookCreditsOnlyAfterCommit(t *testing.T) {
+	checkout, store, quotas := newTestCheckout(t)
+	ctx := context.Background()
+
+	invoice, err := checkout.CreateInvoice(ctx, 7, "pro")
+	require.NoError(t, err)
+
+	// 事务提交失败时不更新内存额度，Stripe 重试后只入账一次
+	store.commitErr = errors.New("commit failed")
+	payload, signature := signedCompletedEvent(t, "evt_1", invoice.CheckoutSessionID, 10000)
+	assert.Error(t, checkout.HandleWebhook(ctx, payload, signature))
+	assert.Equal(t, float64(0), quotas.GetQuota("7"))
+	assert.Empty(t, checkout.notifier.(*recordingNotifier).userIDs)
+
+	store.commitErr = nil
+	require.NoError(t, checkout.HandleWebhook(ctx, payload, signature))
+	assert.Equal(t, float64(12000), quotas.GetQuota("7"))
+	assert.Equal(t, []int{7}, checkout.notifier.(*recordingNotifier).userIDs)
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+)
+
+// BillingDeadLetterStore 将计费死信保存到数据库，实现 billing.DeadLetterStore
+type BillingDeadLetterStore struct {
+	repo *repository.BillingDeadLetterRepository
+}
+
+// NewBillingDeadLetterStore 创建计费死信存储
+func NewBillingDeadLetterStore(repo *repository.BillingDeadLetterRepository) *BillingDeadLetterStore {
+	return &BillingDeadLetterStore{repo: repo}
+}
+
+// CreateDeadLetter 保存死信并回填 ID
+func (s *BillingDeadLetterStore) CreateDeadLetter(ctx context.Context, dl *billing.DeadLetter) error {
+	row, err := deadLetterRow(dl)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Create(ctx, row); err != nil {
+		return err
+	}
+	dl.ID = row.ID
+	return nil
+}
+
+// GetDeadLetter 查询死信
+func (s *BillingDeadLetterStore) GetDeadLetter(ctx context.Context, id int64) (*billing.DeadLetter, error) {
+	row, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, billing.ErrDeadLetterNotFound
+	}
+	return deadLetterFromRow(row)
+}
+
+// ListDeadLetters 分页查询死信，page_size 默认 20，最大 100
+func (s *BillingDeadLetterStore) ListDeadLetters(ctx context.Context, filter billing.DeadLetterFilter) ([]*billing.DeadLetter, int64, error) {
+	page, pageSize := filter.Page, filter.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	rows, total, err := s.repo.List(ctx, filter.UserID, filter.ModelName, filter.Status, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	dls := make([]*billing.DeadLetter, 0, len(rows))
+	for _, row := range rows {
+		dl, err := deadLetterFromRow(row)
+		if err != nil {
+			return nil, 0, err
+		}
+		dls = append(dls, dl)
+	}
+	return dls, total, nil
+}
+
+// UpdateDeadLetter 更新死信的状态、失败原因与失败次数
+func (s *BillingDeadLetterStore) UpdateDeadLetter(ctx context.Context, dl *billing.DeadLetter) error {
+	row, err := deadLetterRow(dl)
+	if err != nil {
+		return err
+	}
+	return s.repo.Update(ctx, row)
+}
+
+func deadLetterRow(dl *billing.DeadLetter) (*model.BillingDeadLetter, error) {
+	event, err := json.Marshal(dl.Event)
+	if err != nil {
+		return nil, fmt.Errorf("marshal billing event: %w", err)
+	}
+	return &model.BillingDeadLetter{
+		ID:         dl.ID,
+		EventID:    dl.Event.EventID,
+		UserID:     dl.Event.UserID,
+		ModelName:  dl.Event.ModelName,
+		Event:      event,
+		Reason:     dl.Reason,
+		Attempts:   dl.Attempts,
+		Status:     dl.Status,
+		CreatedAt:  dl.CreatedAt,
+		UpdatedAt:  dl.UpdatedAt,
+		ResolvedAt: dl.ResolvedAt,
+	}, nil
+}
+
+func deadLetterFromRow(row *model.BillingDeadLetter) (*billing.DeadLetter, error) {
+	var event billing.BillingEvent
+	if err := json.Unmarshal(row.Event, &event); err != nil {
+		return nil, fmt.Errorf("unmarshal dead letter %d: %w", row.ID, err)
+	}
+	return &billing.DeadLetter{
+		ID:         row.ID,
+		Event:      &event,
+		Reason:     row.Reason,
+		Attempts:   row.Attempts,
+		Status:     row.Status,
+		CreatedAt:  row.CreatedAt,
+		UpdatedAt:  row.UpdatedAt,
+		ResolvedAt: row.ResolvedAt,
+	}, nil
+}
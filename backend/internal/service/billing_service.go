@@ -3,9 +3,13 @@ package service
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/quota"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
 )
 
@@ -17,6 +21,7 @@ type BillingService struct {
 	pricingRepo    *repository.PricingPlanRepository
 	userRepo       *repository.UserRepository
 	modelPriceRepo *repository.ModelPriceRepository
+	notifier       quota.BalanceNotifier
 }
 
 // NewBillingService 创建计费服务
@@ -28,6 +33,7 @@ func NewBillingService() *BillingService {
 		pricingRepo:    repository.NewPricingPlanRepository(),
 		userRepo:       repository.NewUserRepository(),
 		modelPriceRepo: repository.NewModelPriceRepository(),
+		notifier:       quota.NewRedisBalanceNotifier(nil),
 	}
 }
 
@@ -45,10 +51,10 @@ func (s *BillingService) CalculateCost(ctx context.Context, modelName string, in
 	}
 
 	// 计算费用
-	// 输入：price.InputPrice（美元/1K tokens）
-	// 输出：price.OutputPrice（美元/1K tokens）
-	inputCostUSD := float64(inputTokens) / 1000.0 * price.InputPrice
-	outputCostUSD := float64(outputTokens) / 1000.0 * price.OutputPrice
+	// 输入：price.PromptPricePerK（美元/1K tokens）
+	// 输出：price.CompletionPricePerK（美元/1K tokens）
+	inputCostUSD := float64(inputTokens) / 1000.0 * float64(price.PromptPricePerK)
+	outputCostUSD := float64(outputTokens) / 1000.0 * float64(price.CompletionPricePerK)
 	totalCostUSD := inputCostUSD + outputCostUSD
 
 	// 转换为分（1 美元 = 100 分，这里用最小单位）
@@ -59,7 +65,8 @@ func (s *BillingService) CalculateCost(ctx context.Context, modelName string, in
 }
 
 // Charge 扣费并记录日志
-func (s *BillingService) Charge(ctx context.Context, userID int, sessionID, messageID uuid.UUID, modelName string, inputTokens, outputTokens int) (*model.BillingLog, error) {
+// reasoningTokens 已包含在 outputTokens 中并按输出价格计费，这里单独记录以便分析
+func (s *BillingService) Charge(ctx context.Context, userID int, sessionID, messageID uuid.UUID, modelName string, inputTokens, outputTokens, reasoningTokens int) (*model.BillingLog, error) {
 	// 1. 计算费用
 	cost, costUSD, err := s.CalculateCost(ctx, modelName, inputTokens, outputTokens)
 	if err != nil {
@@ -81,16 +88,17 @@ func (s *BillingService) Charge(ctx context.Context, userID int, sessionID, mess
 
 	// 3. 创建计费日志
 	log := &model.BillingLog{
-		UserID:       userID,
-		SessionID:    &sessionID,
-		MessageID:    &messageID,
-		Model:        modelName,
-		InputTokens:  inputTokens,
-		OutputTokens: outputTokens,
-		TotalTokens:  inputTokens + outputTokens,
-		Cost:         cost,
-		CostUSD:      costUSD,
-		Status:       1, // 已记录
+		UserID:          userID,
+		SessionID:       &sessionID,
+		MessageID:       &messageID,
+		Model:           modelName,
+		InputTokens:     inputTokens,
+		OutputTokens:    outputTokens,
+		ReasoningTokens: reasoningTokens,
+		TotalTokens:     inputTokens + outputTokens,
+		Cost:            cost,
+		CostUSD:         costUSD,
+		Status:          1, // 已记录
 	}
 
 	if err := s.billingRepo.Create(ctx, log); err != nil {
@@ -101,6 +109,7 @@ func (s *BillingService) Charge(ctx context.Context, userID int, sessionID, mess
 	if err := s.userRepo.DeductQuota(ctx, userID, cost); err != nil {
 		return nil, fmt.Errorf("failed to deduct quota: %w", err)
 	}
+	s.notifyBalanceChanged(ctx, userID)
 
 	// 5. 记录额度变更日志
 	quotaLog := &model.QuotaLog{
@@ -155,22 +164,47 @@ func (s *BillingService) GenerateInvoice(ctx context.Context, userID int) (*mode
 		return nil, fmt.Errorf("no billing logs found for user %d", userID)
 	}
 
-	// 计算总费用
-	var totalCost int64
+	// 按模型汇总费用
 	var totalUSD float64
+	items := make([]*model.InvoiceItem, 0)
+	itemByModel := make(map[string]*model.InvoiceItem)
 	for _, log := range logs {
-		totalCost += log.Cost
 		totalUSD += log.CostUSD
+
+		item, ok := itemByModel[log.Model]
+		if !ok {
+			item = &model.InvoiceItem{
+				Model:       log.Model,
+				Description: fmt.Sprintf("AI API usage - %s", log.Model),
+			}
+			itemByModel[log.Model] = item
+			items = append(items, item)
+		}
+		item.Quantity += int64(log.TotalTokens)
+		item.Amount += float32(log.CostUSD)
+	}
+
+	invoiceItems := model.JSONArray{}
+	for _, item := range items {
+		invoiceItems = append(invoiceItems, item)
 	}
 
 	// 创建发票
+	now := time.Now()
+	dueDate := now.AddDate(0, 0, 30)
+	id := uuid.New()
 	invoice := &model.Invoice{
-		UserID:    userID,
-		InvoiceNo: fmt.Sprintf("INV-%d-%d", userID, len(logs)),
-		TotalCost: totalCost,
-		TotalUSD:  totalUSD,
-		ItemCount: len(logs),
-		Status:    1, // 未支付
+		ID:            id.String(),
+		UserID:        strconv.Itoa(userID),
+		BillingMonth:  now.Format("2006-01"),
+		InvoiceNumber: fmt.Sprintf("INV-%s-%s", now.Format("200601"), strings.ToUpper(strings.ReplaceAll(id.String(), "-", ""))),
+		Amount:        float32(totalUSD),
+		FinalAmount:   float32(totalUSD),
+		Currency:      "USD",
+		Status:        "issued", // 已开具，待支付
+		IssuedAt:      &now,
+		DueDate:       &dueDate,
+		Items:         invoiceItems,
 	}
 
 	if err := s.invoiceRepo.Create(ctx, invoice); err != nil {
@@ -198,6 +232,7 @@ func (s *BillingService) RefundCharge(ctx context.Context, billingLogID int) err
 	if err := s.userRepo.AddQuota(ctx, log.UserID, refundAmount); err != nil {
 		return fmt.Errorf("failed to add quota: %w", err)
 	}
+	s.notifyBalanceChanged(ctx, log.UserID)
 
 	// 记录额度变更日志
 	quotaLog := &model.QuotaLog{
@@ -225,3 +260,45 @@ func (s *BillingService) RefundCharge(ctx context.Context, billingLogID int) err
 	// 更新计费日志状态为已退款
 	return s.billingRepo.UpdateStatus(ctx, billingLogID, 3)
 }
+
+// Recharge 为用户充值并记录额度变更
+func (s *BillingService) Recharge(ctx context.Context, userID int, amount int64, reason string) error {
+	if amount <= 0 {
+		return fmt.Errorf("invalid recharge amount: %d", amount)
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user not found: %d", userID)
+	}
+
+	if err := s.userRepo.AddQuota(ctx, userID, amount); err != nil {
+		return fmt.Errorf("failed to add quota: %w", err)
+	}
+	s.notifyBalanceChanged(ctx, userID)
+
+	quotaLog := &model.QuotaLog{
+		UserID:        userID,
+		OperationType: "recharge",
+		Amount:        amount,
+		Reason:        reason,
+		BalanceBefore: user.Quota,
+		BalanceAfter:  user.Quota + amount,
+	}
+	if err := s.quotaLogRepo.Create(ctx, quotaLog); err != nil {
+		return fmt.Errorf("failed to create quota log: %w", err)
+	}
+
+	return nil
+}
+
+// notifyBalanceChanged 广播余额变更，使网关的额度预检缓存失效
+func (s *BillingService) notifyBalanceChanged(ctx context.Context, userID int) {
+	if s.notifier == nil {
+		return
+	}
+	_ = s.notifier.NotifyBalanceChanged(context.WithoutCancel(ctx), userID)
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+)
+
+const (
+	// defaultUsageRange 未指定开始时间时的查询范围
+	defaultUsageRange = 30 * 24 * time.Hour
+	// maxUsageRange 单次报表允许的最大时间范围
+	maxUsageRange = 366 * 24 * time.Hour
+	// usageCSVPageSize CSV 导出时每次查询的分组数
+	usageCSVPageSize = 1000
+)
+
+// ErrInvalidUsageQuery 报表参数不合法
+var ErrInvalidUsageQuery = errors.New("invalid usage query")
+
+// UsageStore 消费日志聚合，由 repository.UnifiedLogRepository 实现
+type UsageStore interface {
+	AggregateUsage(ctx context.Context, filter *repository.UsageFilter) ([]*repository.UsageAggregate, int64, *repository.UsageAggregate, error)
+}
+
+// UsageQuery 用量报表查询条件，时间范围为 [Start, End)
+type UsageQuery struct {
+	UserID   int
+	GroupBy  string
+	Start    time.Time
+	End      time.Time
+	Page     int
+	PageSize int
+}
+
+// UsageReport 一页分组汇总与整个时间范围的合计
+type UsageReport struct {
+	GroupBy  string                       `json:"group_by"`
+	Start    time.Time                    `json:"start"`
+	End      time.Time                    `json:"end"`
+	Items    []*repository.UsageAggregate `json:"items"`
+	Totals   *repository.UsageAggregate   `json:"totals"`
+	Total    int64                        `json:"total"` // 分组总数
+	Page     int                          `json:"page"`
+	PageSize int                          `json:"page_size"`
+}
+
+// UsageReportService 按模型、天或渠道汇总用户的消费
+type UsageReportService struct {
+	store UsageStore
+	now   func() time.Time
+}
+
+// NewUsageReportService 创建用量报表服务
+func NewUsageReportService(store UsageStore) *UsageReportService {
+	return &UsageReportService{store: store, now: time.Now}
+}
+
+// normalize 填充默认值并校验分组维度与时间范围：结束时间默认为当前，开始时间默认为结束前 30 天
+func (s *UsageReportService) normalize(q *UsageQuery) error {
+	switch q.GroupBy {
+	case "":
+		q.GroupBy = repository.UsageGroupByModel
+	case repository.UsageGroupByModel, repository.UsageGroupByDay, repository.UsageGroupByChannel:
+	default:
+		return fmt.Errorf("%w: group_by must be model, day or channel", ErrInvalidUsageQuery)
+	}
+
+	if q.End.IsZero() {
+		q.End = s.now()
+	}
+	if q.Start.IsZero() {
+		q.Start = q.End.Add(-defaultUsageRange)
+	}
+	if !q.Start.Before(q.End) {
+		return fmt.Errorf("%w: start must be before end", ErrInvalidUsageQuery)
+	}
+	if q.End.Sub(q.Start) > maxUsageRange {
+		return fmt.Errorf("%w: time range must not exceed 366 days", ErrInvalidUsageQuery)
+	}
+
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize < 1 || q.PageSize > 100 {
+		q.PageSize = 20
+	}
+	return nil
+}
+
+// Report 查询一页分组汇总
+func (s *UsageReportService) Report(ctx context.Context, q UsageQuery) (*UsageReport, error) {
+	if err := s.normalize(&q); err != nil {
+		return nil, err
+	}
+
+	items, total, totals, err := s.store.AggregateUsage(ctx, &repository.UsageFilter{
+		UserID:    q.UserID,
+		GroupBy:   q.GroupBy,
+		StartTime: q.Start,
+		EndTime:   q.End,
+		Limit:     q.PageSize,
+		Offset:    (q.Page - 1) * q.PageSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UsageReport{
+		GroupBy:  q.GroupBy,
+		Start:    q.Start,
+		End:      q.End,
+		Items:    items,
+		Totals:   totals,
+		Total:    total,
+		Page:     q.Page,
+		PageSize: q.PageSize,
+	}, nil
+}
+
+// WriteCSV 按页读取全部分组并写出 CSV，忽略查询中的分页参数
+func (s *UsageReportService) WriteCSV(ctx context.Context, w io.Writer, q UsageQuery) error {
+	if err := s.normalize(&q); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{q.GroupBy, "name", "requests", "cost", "input_tokens", "output_tokens"}); err != nil {
+		return err
+	}
+
+	for offset := 0; ; offset += usageCSVPageSize {
+		items, total, _, err := s.store.AggregateUsage(ctx, &repository.UsageFilter{
+			UserID:    q.UserID,
+			GroupBy:   q.GroupBy,
+			StartTime: q.Start,
+			EndTime:   q.End,
+			Limit:     usageCSVPageSize,
+			Offset:    offset,
+		})
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			err := cw.Write([]string{
+				item.Key,
+				item.Name,
+				strconv.FormatInt(item.Requests, 10),
+				strconv.FormatInt(item.Cost, 10),
+				strconv.FormatInt(item.InputTokens, 10),
+				strconv.FormatInt(item.OutputTokens, 10),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(items) < usageCSVPageSize || int64(offset+len(items)) >= total {
+			break
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryUsage 在内存中按与 SQL 相同的语义聚合消费日志
+type memoryUsage struct {
+	logs  []model.UnifiedLog
+	calls int
+}
+
+func (s *memoryUsage) AggregateUsage(ctx context.Context, filter *repository.UsageFilter) ([]*repository.UsageAggregate, int64, *repository.UsageAggregate, error) {
+	s.calls++
+	groups := make(map[string]*repository.UsageAggregate)
+	totals := &repository.UsageAggregate{}
+	for _, l := range s.logs {
+		if l.UserID != filter.UserID || l.LogType != model.LogTypeConsume ||
+			l.CreatedAt.Before(filter.StartTime) || !l.CreatedAt.Before(filter.EndTime) {
+			continue
+		}
+		var key, name string
+		switch filter.GroupBy {
+		case repository.UsageGroupByModel:
+			key = l.ModelName
+		case repository.UsageGroupByDay:
+			key = l.CreatedAt.Format(time.DateOnly)
+		case repository.UsageGroupByChannel:
+			key, name = strconv.Itoa(l.ChannelID), l.ChannelName
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &repository.UsageAggregate{Key: key, Name: name}
+			groups[key] = g
+		}
+		for _, agg := range []*repository.UsageAggregate{g, totals} {
+			agg.Requests++
+			agg.Cost += int64(l.Quota)
+			agg.InputTokens += int64(l.PromptTokens)
+			agg.OutputTokens += int64(l.CompletionTokens)
+		}
+	}
+
+	rows := make([]*repository.UsageAggregate, 0, len(groups))
+	for _, g := range groups {
+		rows = append(rows, g)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Key < rows[j].Key })
+
+	total := int64(len(rows))
+	if filter.Offset >= len(rows) {
+		return nil, total, totals, nil
+	}
+	rows = rows[filter.Offset:]
+	if len(rows) > filter.Limit {
+		rows = rows[:filter.Limit]
+	}
+	return rows, total, totals, nil
+}
+
+var usageModels = []string{"gpt-4", "gpt-4o-mini", "claude-3-5-sonnet", "gemini-1.5-pro"}
+
+// seedUsage 生成 1000 条用户 1 的消费日志（每条间隔 1 小时）以及其他用户和非消费类型的干扰数据
+func seedUsage(start time.Time) *memoryUsage {
+	store := &memoryUsage{}
+	for i := 0; i < 1000; i++ {
+		store.logs = append(store.logs, model.UnifiedLog{
+			UserID:           1,
+			LogType:          model.LogTypeConsume,
+			ModelName:        usageModels[i%len(usageModels)],
+			ChannelID:        i%3 + 1,
+			ChannelName:      fmt.Sprintf("channel-%d", i%3+1),
+			Quota:            10 + i%7,
+			PromptTokens:     100,
+			CompletionTokens: 50 + i%5,
+			CreatedAt:        start.Add(time.Duration(i) * time.Hour),
+		})
+	}
+	store.logs = append(store.logs,
+		model.UnifiedLog{UserID: 2, LogType: model.LogTypeConsume, ModelName: "gpt-4", Quota: 999, CreatedAt: start},
+		model.UnifiedLog{UserID: 1, LogType: model.LogTypeTopup, ModelName: "gpt-4", Quota: 999, CreatedAt: start},
+	)
+	return store
+}
+
+func newUsageService(store UsageStore, now time.Time) *UsageReportService {
+	s := NewUsageReportService(store)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestUsageReportGroupByModel(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(1000 * time.Hour)
+	s := newUsageService(seedUsage(start), end)
+
+	report, err := s.Report(context.Background(), UsageQuery{UserID: 1, GroupBy: "model", Start: start, End: end})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(4), report.Total)
+	require.Len(t, report.Items, 4)
+	assert.Equal(t, "claude-3-5-sonnet", report.Items[0].Key)
+
+	var requests, cost int64
+	for _, item := range report.Items {
+		assert.Equal(t, int64(250), item.Requests)
+		assert.Equal(t, int64(250*100), item.InputTokens)
+		requests += item.Requests
+		cost += item.Cost
+	}
+	assert.Equal(t, int64(1000), requests)
+	assert.Equal(t, report.Totals.Cost, cost)
+	assert.Equal(t, int64(1000), report.Totals.Requests)
+}
+
+func TestUsageReportGroupByDayPaginates(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(1000 * time.Hour)
+	s := newUsageService(seedUsage(start), end)
+	ctx := context.Background()
+
+	// 1000 小时跨 42 天（最后一天 16 条）
+	seen := make(map[string]int64)
+	for page := 1; ; page++ {
+		report, err := s.Report(ctx, UsageQuery{UserID: 1, GroupBy: "day", Start: start, End: end, Page: page, PageSize: 10})
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), report.Total)
+		for _, item := range report.Items {
+			seen[item.Key] = item.Requests
+		}
+		if len(report.Items) < 10 {
+			break
+		}
+	}
+	require.Len(t, seen, 42)
+	assert.Equal(t, int64(24), seen["2026-01-01"])
+	assert.Equal(t, int64(16), seen["2026-02-11"])
+
+	// 范围过滤：只取第二天
+	report, err := s.Report(ctx, UsageQuery{UserID: 1, GroupBy: "day", Start: start.AddDate(0, 0, 1), End: start.AddDate(0, 0, 2)})
+	require.NoError(t, err)
+	require.Len(t, report.Items, 1)
+	assert.Equal(t, "2026-01-02", report.Items[0].Key)
+	assert.Equal(t, int64(24), report.Totals.Requests)
+}
+
+func TestUsageReportGroupByChannel(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newUsageService(seedUsage(start), start.Add(1000*time.Hour))
+
+	report, err := s.Report(context.Background(), UsageQuery{UserID: 1, GroupBy: "channel", Start: start})
+	require.NoError(t, err)
+	require.Len(t, report.Items, 3)
+	assert.Equal(t, "1", report.Items[0].Key)
+	assert.Equal(t, "channel-1", report.Items[0].Name)
+	assert.Equal(t, int64(334), report.Items[0].Requests)
+}
+
+func TestUsageReportValidation(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	s := newUsageService(&memoryUsage{}, now)
+	ctx := context.Background()
+
+	_, err := s.Report(ctx, UsageQuery{UserID: 1, GroupBy: "token"})
+	assert.ErrorIs(t, err, ErrInvalidUsageQuery)
+
+	_, err = s.Report(ctx, UsageQuery{UserID: 1, Start: now, End: now.Add(-time.Hour)})
+	assert.ErrorIs(t, err, ErrInvalidUsageQuery)
+
+	_, err = s.Report(ctx, UsageQuery{UserID: 1, Start: now.AddDate(-2, 0, 0), End: now})
+	assert.ErrorIs(t, err, ErrInvalidUsageQuery)
+
+	report, err := s.Report(ctx, UsageQuery{UserID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "model", report.GroupBy)
+	assert.Equal(t, now, report.End)
+	assert.Equal(t, now.Add(-30*24*time.Hour), report.Start)
+	assert.Equal(t, 20, report.PageSize)
+}
+
+func TestUsageReportCSV(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &memoryUsage{}
+	// 1200 个模型，超过单页 1000 个分组，CSV 需要分两次读取
+	for i := 0; i < 1200; i++ {
+		store.logs = append(store.logs, model.UnifiedLog{
+			UserID: 1, LogType: model.LogTypeConsume, ModelName: fmt.Sprintf("model-%04d", i),
+			Quota: 3, PromptTokens: 10, CompletionTokens: 20, CreatedAt: start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	s := newUsageService(store, start.AddDate(0, 0, 1))
+
+	var buf bytes.Buffer
+	require.NoError(t, s.WriteCSV(context.Background(), &buf, UsageQuery{UserID: 1, GroupBy: "model", Start: start, PageSize: 5}))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 1201)
+	assert.Equal(t, []string{"model", "name", "requests", "cost", "input_tokens", "output_tokens"}, records[0])
+	assert.Equal(t, []string{"model-0000", "", "1", "3", "10", "20"}, records[1])
+	assert.Equal(t, "model-1199", records[1200][0])
+	assert.Equal(t, 2, store.calls)
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	// userGroupCacheTTL 用户分组的缓存时间，调整分组后最多延迟这么久生效
+	userGroupCacheTTL = time.Minute
+	// userGroupCacheSize 缓存条目达到该数量时清理过期条目
+	userGroupCacheSize = 10000
+)
+
+// UserFinder 按 ID 查询用户，由 repository.UserRepository 实现
+type UserFinder interface {
+	FindByID(ctx context.Context, id int) (*model.User, error)
+}
+
+type cachedUserGroup struct {
+	group     string
+	expiresAt time.Time
+}
+
+// UserGroupResolver 从 users 表查询计费事件所属用户的分组，结果短暂缓存
+type UserGroupResolver struct {
+	users UserFinder
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedUserGroup
+}
+
+// NewUserGroupResolver 创建用户分组查询
+func NewUserGroupResolver(users UserFinder) *UserGroupResolver {
+	return &UserGroupResolver{
+		users: users,
+		ttl:   userGroupCacheTTL,
+		now:   time.Now,
+		cache: make(map[string]cachedUserGroup),
+	}
+}
+
+// Resolve 返回用户分组，用户不存在或查询失败时返回 billing.DefaultUserGroup（失败结果不缓存）
+func (r *UserGroupResolver) Resolve(userID string) string {
+	now := r.now()
+	r.mu.Lock()
+	if entry, ok := r.cache[userID]; ok && now.Before(entry.expiresAt) {
+		r.mu.Unlock()
+		return entry.group
+	}
+	r.mu.Unlock()
+
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return billing.DefaultUserGroup
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	user, err := r.users.FindByID(ctx, id)
+	if err != nil {
+		logger.Warn("failed to resolve user group, using base price",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return billing.DefaultUserGroup
+	}
+
+	group := billing.DefaultUserGroup
+	if user != nil && user.Group != "" {
+		group = user.Group
+	}
+
+	r.mu.Lock()
+	if len(r.cache) >= userGroupCacheSize {
+		for key, entry := range r.cache {
+			if !now.Before(entry.expiresAt) {
+				delete(r.cache, key)
+			}
+		}
+	}
+	r.cache[userID] = cachedUserGroup{group: group, expiresAt: now.Add(r.ttl)}
+	r.mu.Unlock()
+	return group
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/capprobe"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrProbeRunNotFound 探测记录不存在
+	ErrProbeRunNotFound = errors.New("capability probe run not found")
+	// ErrProbeModelRequired 未指定模型且渠道没有配置模型
+	ErrProbeModelRequired = errors.New("model is required: channel has no configured models")
+)
+
+// capabilityColumns 能力对应的 channel_abilities 特性列
+var capabilityColumns = map[capprobe.Capability]string{
+	capprobe.CapabilityJSONMode: "supports_json_mode",
+	capprobe.CapabilityTools:    "supports_tools",
+	capprobe.CapabilityVision:   "supports_vision",
+	capprobe.CapabilityStream:   "supports_stream",
+}
+
+// CapabilityProbeReport 一次能力探测的结果
+type CapabilityProbeReport struct {
+	RunID     string                       `json:"run_id"`
+	ChannelID int                          `json:"channel_id"`
+	Model     string                       `json:"model"`
+	ProbedAt  time.Time                    `json:"probed_at"`
+	Results   []*capprobe.Result           `json:"results"`
+	Features  map[capprobe.Capability]bool `json:"features"` // 可应用到渠道能力的特性标记
+}
+
+// CapabilityProbeService 渠道能力探测
+//
+// 探测产生的用量记入系统账号，每个渠道在间隔内只允许探测一次；
+// 结果按时间保存，特性标记需管理员确认后才会写入渠道能力。
+type CapabilityProbeService struct {
+	channelRepo  *repository.ChannelRepository
+	probeRepo    *repository.CapabilityProbeRepository
+	abilityRepo  repository.ChannelAbilityRepository
+	logRepo      *repository.UnifiedLogRepository
+	prober       *capprobe.Prober
+	throttle     *capprobe.Throttle
+	systemUserID int
+	onApply      func(ctx context.Context) error
+}
+
+// NewCapabilityProbeService 创建能力探测服务
+func NewCapabilityProbeService(abilityRepo repository.ChannelAbilityRepository, systemUserID int, interval time.Duration) *CapabilityProbeService {
+	return &CapabilityProbeService{
+		channelRepo:  repository.NewChannelRepository(),
+		probeRepo:    repository.NewCapabilityProbeRepository(),
+		abilityRepo:  abilityRepo,
+		logRepo:      repository.NewUnifiedLogRepository(),
+		prober:       capprobe.NewProber(nil),
+		throttle:     capprobe.NewThrottle(interval),
+		systemUserID: systemUserID,
+	}
+}
+
+// SetOnApply 设置特性标记更新后的回调（如重新加载中转渠道缓存）
+func (s *CapabilityProbeService) SetOnApply(fn func(ctx context.Context) error) {
+	s.onApply = fn
+}
+
+// Probe 对渠道的指定模型执行能力探测，modelName 为空时使用渠道的第一个模型
+//
+// 渠道不存在时返回 nil, nil；探测过于频繁时返回 *capprobe.RateLimitedError。
+func (s *CapabilityProbeService) Probe(ctx context.Context, channelID int, modelName string, adminID int) (*CapabilityProbeReport, error) {
+	ch, err := s.channelRepo.GetByID(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil {
+		return nil, nil
+	}
+
+	if modelName == "" {
+		models := ch.GetSupportedModels()
+		if len(models) == 0 {
+			return nil, ErrProbeModelRequired
+		}
+		modelName = models[0]
+	}
+
+	target, err := capprobe.TargetFromChannel(ch, modelName)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.throttle.Acquire(ch.ID); err != nil {
+		return nil, err
+	}
+
+	report := &CapabilityProbeReport{
+		RunID:     uuid.NewString(),
+		ChannelID: ch.ID,
+		Model:     modelName,
+		ProbedAt:  time.Now(),
+	}
+	report.Results = s.prober.Probe(ctx, target)
+	report.Features = capprobe.Features(report.Results)
+
+	records := make([]*model.ChannelCapabilityProbe, 0, len(report.Results))
+	for _, r := range report.Results {
+		records = append(records, &model.ChannelCapabilityProbe{
+			RunID:      report.RunID,
+			ChannelID:  ch.ID,
+			Model:      modelName,
+			Capability: string(r.Capability),
+			Outcome:    string(r.Outcome),
+			Detail:     r.Detail,
+			StatusCode: r.StatusCode,
+			Evidence:   r.Evidence,
+			LatencyMs:  r.LatencyMs,
+			ProbedBy:   adminID,
+			CreatedAt:  report.ProbedAt,
+		})
+		s.recordUsage(ctx, ch, report, r)
+	}
+	if err := s.probeRepo.CreateBatch(context.WithoutCancel(ctx), records); err != nil {
+		return report, fmt.Errorf("failed to save probe results: %w", err)
+	}
+
+	logger.Info("channel capabilities probed",
+		zap.Int("channel_id", ch.ID),
+		zap.String("model", modelName),
+		zap.String("run_id", report.RunID),
+		zap.Any("features", report.Features))
+	return report, nil
+}
+
+// recordUsage 将探测请求以消费日志记入系统账号，与用户请求一样参与用量统计
+func (s *CapabilityProbeService) recordUsage(ctx context.Context, ch *model.Channel, report *CapabilityProbeReport, r *capprobe.Result) {
+	if s.logRepo == nil || r.StatusCode == 0 {
+		return
+	}
+
+	other, _ := json.Marshal(map[string]interface{}{
+		"source":     "capability_probe",
+		"run_id":     report.RunID,
+		"capability": r.Capability,
+		"outcome":    r.Outcome,
+	})
+	entry := &model.UnifiedLog{
+		UserID:      s.systemUserID,
+		Username:    "system",
+		ChannelID:   ch.ID,
+		ChannelName: ch.Name,
+		LogType:     model.LogTypeConsume,
+		ModelName:   report.Model,
+		UseTime:     r.LatencyMs,
+		IsStream:    r.Capability == capprobe.CapabilityStream,
+		RequestID:   report.RunID,
+		Other:       string(other),
+		CreatedAt:   time.Now(),
+	}
+	if r.Usage != nil {
+		entry.PromptTokens = r.Usage.PromptTokens
+		entry.CompletionTokens = r.Usage.CompletionTokens
+		entry.ReasoningTokens = r.Usage.ReasoningTokens()
+	}
+	if err := s.logRepo.Create(context.WithoutCancel(ctx), entry); err != nil {
+		logger.Warn("failed to record capability probe usage",
+			zap.String("run_id", report.RunID),
+			zap.Error(err))
+	}
+}
+
+// History 获取渠道的探测历史
+func (s *CapabilityProbeService) History(ctx context.Context, channelID int, modelName string, limit int) ([]*model.ChannelCapabilityProbe, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.probeRepo.ListByChannel(ctx, channelID, modelName, limit)
+}
+
+// ApplyFeatures 将某次探测得出的特性标记写入渠道能力，返回写入的标记与更新的能力行数
+func (s *CapabilityProbeService) ApplyFeatures(ctx context.Context, channelID int, runID string) (map[capprobe.Capability]bool, int64, error) {
+	records, err := s.probeRepo.FindByRun(ctx, channelID, runID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(records) == 0 {
+		return nil, 0, ErrProbeRunNotFound
+	}
+
+	results := make([]*capprobe.Result, 0, len(records))
+	for _, r := range records {
+		results = append(results, &capprobe.Result{
+			Capability: capprobe.Capability(r.Capability),
+			Outcome:    capprobe.Outcome(r.Outcome),
+		})
+	}
+	features := capprobe.Features(results)
+	if len(features) == 0 {
+		return features, 0, nil
+	}
+
+	updates := map[string]interface{}{"features_probed_at": records[0].CreatedAt}
+	for capability, supported := range features {
+		if column, ok := capabilityColumns[capability]; ok {
+			updates[column] = supported
+		}
+	}
+
+	rows, err := s.abilityRepo.UpdateFeatures(ctx, channelID, records[0].Model, updates)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	logger.Info("channel ability features updated from probe",
+		zap.Int("channel_id", channelID),
+		zap.String("model", records[0].Model),
+		zap.String("run_id", runID),
+		zap.Int64("rows", rows))
+
+	if rows > 0 && s.onApply != nil {
+		if err := s.onApply(ctx); err != nil {
+			logger.Error("failed to reload channels after applying features", zap.Error(err))
+		}
+	}
+	return features, rows, nil
+}
@@ -58,7 +58,16 @@ func (s *DefaultChannelAbilityService) SyncFromChannel(ctx context.Context, chan
 		return nil
 	}
 
-	// 2. 构建能力列表
+	// 2. 构建能力列表，保留能力探测确认的特性标记
+	existing, err := s.abilityRepo.FindByChannel(ctx, channel.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load abilities: %w", err)
+	}
+	features := make(map[string]*model.ChannelAbility, len(existing))
+	for _, ability := range existing {
+		features[ability.Model] = ability
+	}
+
 	abilities := make([]*model.ChannelAbility, 0, len(models))
 	for _, modelName := range models {
 		ability := &model.ChannelAbility{
@@ -71,6 +80,9 @@ func (s *DefaultChannelAbilityService) SyncFromChannel(ctx context.Context, chan
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		}
+		if prev, ok := features[modelName]; ok {
+			ability.CopyFeatures(prev)
+		}
 		abilities = append(abilities, ability)
 	}
 
@@ -2,11 +2,25 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/shirosoralumie648/Oblivious/backend/internal/adapter"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidChannelConfig 渠道的静态请求头或查询参数配置无效
+	ErrInvalidChannelConfig = errors.New("invalid channel config")
+	// ErrChannelTestModelRequired 渠道未配置模型且测试时未指定模型
+	ErrChannelTestModelRequired = errors.New("model is required: channel has no configured models")
 )
 
 // ChannelService 渠道服务
@@ -33,6 +47,9 @@ func (s *ChannelService) Create(ctx context.Context, channel *model.Channel) err
 	if channel.APIKey == "" {
 		return fmt.Errorf("api key is required")
 	}
+	if err := validateUpstreamOverrides(channel); err != nil {
+		return err
+	}
 
 	// 设置默认值
 	if channel.BaseURL == "" {
@@ -62,6 +79,9 @@ func (s *ChannelService) Update(ctx context.Context, channel *model.Channel) err
 	if existing == nil {
 		return fmt.Errorf("channel not found: %d", channel.ID)
 	}
+	if err := validateUpstreamOverrides(channel); err != nil {
+		return err
+	}
 
 	channel.UpdatedAt = time.Now()
 	return s.repo.Update(ctx, channel)
@@ -120,3 +140,193 @@ func (s *ChannelService) Disable(ctx context.Context, id int) error {
 func (s *ChannelService) GetAllEnabled(ctx context.Context) ([]*model.Channel, error) {
 	return s.repo.GetAll(ctx)
 }
+
+// ValidateRelayChannel 校验中转管理接口写入的渠道：类型须有对应的适配器，Base URL 须为合法的
+// http(s) 地址（为空时使用默认地址），且至少配置一个模型
+func ValidateRelayChannel(channel *model.Channel) error {
+	if !adapter.SupportsChannelType(channel.Type) {
+		return fmt.Errorf("%w: unsupported channel type %q", ErrInvalidChannelConfig, channel.Type)
+	}
+	if channel.BaseURL != "" {
+		u, err := url.Parse(channel.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: invalid base_url %q", ErrInvalidChannelConfig, channel.BaseURL)
+		}
+	}
+	if len(channel.GetSupportedModels()) == 0 {
+		return fmt.Errorf("%w: at least one model is required", ErrInvalidChannelConfig)
+	}
+	return validateUpstreamOverrides(channel)
+}
+
+// validateUpstreamOverrides 校验渠道的静态请求头、查询参数以及默认与强制的请求参数
+func validateUpstreamOverrides(channel *model.Channel) error {
+	headers, err := channel.GetHeaderOverride()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidChannelConfig, err)
+	}
+	settings := channel.GetSettings()
+	if err := adapter.ValidateUpstreamOverrides(headers, settings.QueryParams); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidChannelConfig, err)
+	}
+	if err := adapter.ValidateChannelParams(settings.ParamDefaults, settings.ParamOverrides); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidChannelConfig, err)
+	}
+	if settings.MaxConcurrency < 0 {
+		return fmt.Errorf("%w: max_concurrency must not be negative", ErrInvalidChannelConfig)
+	}
+	for _, capability := range settings.Capabilities {
+		if capability != relay.FeatureImages && capability != relay.FeatureAudio {
+			return fmt.Errorf("%w: unknown capability %q (supported: %s, %s)", ErrInvalidChannelConfig, capability, relay.FeatureImages, relay.FeatureAudio)
+		}
+	}
+	for _, feature := range settings.DisabledFeatures {
+		switch relay.ChannelAbilityFeature(feature) {
+		case relay.FeatureFunctionCalling, relay.FeatureParallelFunctions, relay.FeatureJSONMode, relay.FeatureVision:
+		default:
+			return fmt.Errorf("%w: unknown disabled feature %q (supported: %s, %s, %s, %s)", ErrInvalidChannelConfig, feature,
+				relay.FeatureFunctionCalling, relay.FeatureParallelFunctions, relay.FeatureJSONMode, relay.FeatureVision)
+		}
+	}
+	return nil
+}
+
+// RedactChannel 返回用于管理接口展示的渠道副本，密钥只保留末 4 位，静态请求头中疑似密钥的值被掩码
+func RedactChannel(channel *model.Channel) *model.Channel {
+	redacted := *channel
+	redacted.Keys = nil
+	keys := channel.GetKeys()
+	masked := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key != "" {
+			masked = append(masked, relay.MaskKey(key))
+		}
+	}
+	redacted.APIKey = strings.Join(masked, "\n")
+	if headers, err := channel.GetHeaderOverride(); err == nil && len(headers) > 0 {
+		_ = redacted.SetHeaderOverride(adapter.MaskHeaders(headers))
+	}
+	return &redacted
+}
+
+// MergeMaskedKeys 管理端回传的密钥列表中未修改的掩码值还原为原密钥，避免把掩码写回数据库
+func MergeMaskedKeys(incoming, existing []string) []string {
+	used := make([]bool, len(existing))
+	merged := make([]string, 0, len(incoming))
+	for _, key := range incoming {
+		for i, old := range existing {
+			if !used[i] && key == relay.MaskKey(old) {
+				key = old
+				used[i] = true
+				break
+			}
+		}
+		merged = append(merged, key)
+	}
+	return merged
+}
+
+// ChannelKeyHealth 渠道各密钥的健康状态
+//
+// live 为负载均衡器中的实时状态（多密钥渠道）；为空时按渠道配置的密钥列出，均视为可用。
+func ChannelKeyHealth(channel *model.Channel, live []relay.KeyHealth) []relay.KeyHealth {
+	if len(live) > 0 {
+		return live
+	}
+	keys := channel.GetKeys()
+	health := make([]relay.KeyHealth, 0, len(keys))
+	for i, key := range keys {
+		if key == "" {
+			continue
+		}
+		health = append(health, relay.KeyHealth{Index: i, Key: relay.MaskKey(key), Status: relay.KeyStatusActive})
+	}
+	return health
+}
+
+// MergeMaskedHeaders 管理端回传的请求头中未修改的掩码值保留原值，避免把掩码写回数据库
+func MergeMaskedHeaders(incoming, existing map[string]string) map[string]string {
+	merged := make(map[string]string, len(incoming))
+	for name, value := range incoming {
+		if old, ok := existing[name]; ok && value == adapter.MaskHeaderValue(name, old) {
+			value = old
+		}
+		merged[name] = value
+	}
+	return merged
+}
+
+// ChannelTestResult 渠道测试结果
+type ChannelTestResult struct {
+	ChannelID   int               `json:"channel_id"`
+	Model       string            `json:"model"`
+	Success     bool              `json:"success"`
+	StatusCode  int               `json:"status_code,omitempty"`
+	LatencyMs   int64             `json:"latency_ms"`
+	Message     string            `json:"message"`
+	Headers     map[string]string `json:"headers,omitempty"` // 注入的静态请求头，疑似密钥已掩码
+	QueryParams map[string]string `json:"query_params,omitempty"`
+}
+
+// TestChannel 向渠道发送一次最小的 Chat Completion 请求
+//
+// 与中转请求使用同一个适配器，静态请求头与查询参数的注入路径完全一致。
+// modelName 为空时使用渠道配置的第一个模型。
+func (s *ChannelService) TestChannel(ctx context.Context, channel *model.Channel, modelName string) (*ChannelTestResult, error) {
+	if modelName == "" {
+		models := channel.GetSupportedModels()
+		if len(models) == 0 {
+			return nil, ErrChannelTestModelRequired
+		}
+		modelName = models[0]
+	}
+
+	headers, err := channel.GetHeaderOverride()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidChannelConfig, err)
+	}
+	result := &ChannelTestResult{
+		ChannelID:   channel.ID,
+		Model:       modelName,
+		Headers:     adapter.MaskHeaders(headers),
+		QueryParams: channel.GetSettings().QueryParams,
+	}
+
+	adaptor, err := adapter.GetAdapterByChannel(channel)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := adaptor.ConvertRequest(&adapter.OpenAIRequest{
+		Model:     modelName,
+		Messages:  []adapter.Message{{Role: "user", Content: "ping"}},
+		MaxTokens: 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := adaptor.DoRequest(ctx, converted)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Message = err.Error()
+	} else {
+		defer resp.Body.Close()
+		result.StatusCode = resp.StatusCode
+		if upstreamErr := adaptor.GetError(resp); upstreamErr != nil {
+			result.Message = upstreamErr.Error()
+		} else {
+			result.Success = true
+			result.Message = "连接正常"
+		}
+	}
+
+	if !result.Success {
+		logger.Warn("channel test failed",
+			zap.Int("channel_id", channel.ID),
+			zap.String("model", modelName),
+			zap.Any("headers", result.Headers),
+			zap.String("error", result.Message))
+	}
+	return result, nil
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// overrideChannel 配置了静态请求头与查询参数的渠道
+func overrideChannel(t *testing.T, baseURL string) *model.Channel {
+	t.Helper()
+	ch := &model.Channel{
+		ID:            7,
+		Type:          "openai",
+		BaseURL:       baseURL,
+		APIKey:        "sk-channel-key",
+		SupportModels: "gpt-4o-mini,gpt-4o",
+	}
+	require.NoError(t, ch.SetHeaderOverride(map[string]string{
+		"X-Portkey-Provider": "openai",
+		"X-Tenant-Token":     "tenant-secret-value",
+		"Authorization":      "Bearer {api_key}",
+	}))
+	require.NoError(t, ch.SetSettings(model.ChannelSettings{QueryParams: map[string]string{"api-version": "2024-06-01"}}))
+	return ch
+}
+
+func TestChannelTestUsesInjectionPath(t *testing.T) {
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer server.Close()
+
+	s := NewChannelService(nil)
+	result, err := s.TestChannel(context.Background(), overrideChannel(t, server.URL), "")
+	require.NoError(t, err)
+
+	assert.True(t, result.Success, result.Message)
+	assert.Equal(t, "gpt-4o-mini", result.Model, "defaults to the first configured model")
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	require.NotNil(t, received)
+	assert.Equal(t, "openai", received.Header.Get("X-Portkey-Provider"))
+	assert.Equal(t, "tenant-secret-value", received.Header.Get("X-Tenant-Token"))
+	assert.Equal(t, "Bearer sk-channel-key", received.Header.Get("Authorization"))
+	assert.Equal(t, "2024-06-01", received.URL.Query().Get("api-version"))
+
+	// 结果中的请求头已掩码
+	assert.Equal(t, "tena****alue", result.Headers["X-Tenant-Token"])
+	assert.Equal(t, "Bearer {api_key}", result.Headers["Authorization"])
+}
+
+func TestChannelTestReportsUpstreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"missing tenant header","type":"invalid_request_error"}}`))
+	}))
+	defer server.Close()
+
+	result, err := NewChannelService(nil).TestChannel(context.Background(), overrideChannel(t, server.URL), "gpt-4o")
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+	assert.Contains(t, result.Message, "missing tenant header")
+
+	_, err = NewChannelService(nil).TestChannel(context.Background(), &model.Channel{Type: "openai"}, "")
+	assert.ErrorIs(t, err, ErrChannelTestModelRequired)
+}
+
+func TestRedactChannelAndMergeMaskedHeaders(t *testing.T) {
+	ch := overrideChannel(t, "https://gateway.example.com")
+
+	redacted := RedactChannel(ch)
+	headers, err := redacted.GetHeaderOverride()
+	require.NoError(t, err)
+	assert.Equal(t, "tena****alue", headers["X-Tenant-Token"])
+	assert.Equal(t, "openai", headers["X-Portkey-Provider"])
+
+	original, err := ch.GetHeaderOverride()
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-secret-value", original["X-Tenant-Token"], "the stored channel is not modified")
+
+	// 回传未修改的掩码值保留原值，修改过的值覆盖
+	headers["X-Portkey-Provider"] = "anthropic"
+	merged := MergeMaskedHeaders(headers, original)
+	assert.Equal(t, "tenant-secret-value", merged["X-Tenant-Token"])
+	assert.Equal(t, "anthropic", merged["X-Portkey-Provider"])
+}
+
+func TestValidateChannelUpstreamOverrides(t *testing.T) {
+	ch := overrideChannel(t, "https://gateway.example.com")
+	assert.NoError(t, validateUpstreamOverrides(ch))
+
+	require.NoError(t, ch.SetHeaderOverride(map[string]string{"Host": "internal.example.com"}))
+	assert.ErrorIs(t, validateUpstreamOverrides(ch), ErrInvalidChannelConfig)
+
+	malformed := `{"X-Tenant":`
+	ch.HeaderOverride = &malformed
+	assert.ErrorIs(t, validateUpstreamOverrides(ch), ErrInvalidChannelConfig)
+}
+
+func TestRedactChannelMasksKeys(t *testing.T) {
+	ch := &model.Channel{ID: 3, Type: "openai"}
+	ch.SetKeys(model.ParseKeys("sk-first-key-1111\nsk-second-key-2222"))
+	require.True(t, ch.ChannelInfo.IsMultiKey)
+
+	redacted := RedactChannel(ch)
+	assert.Equal(t, "****1111\n****2222", redacted.APIKey)
+	assert.Equal(t, "sk-first-key-1111\nsk-second-key-2222", ch.APIKey, "the stored channel is not modified")
+
+	health := ChannelKeyHealth(ch, nil)
+	require.Len(t, health, 2)
+	assert.Equal(t, "****2222", health[1].Key)
+	assert.Equal(t, relay.KeyStatusActive, health[1].Status)
+
+	// 回传未修改的掩码值还原为原密钥，新增的密钥原样保留
+	merged := MergeMaskedKeys([]string{"****2222", "sk-third-key-3333"}, ch.GetKeys())
+	assert.Equal(t, []string{"sk-second-key-2222", "sk-third-key-3333"}, merged)
+}
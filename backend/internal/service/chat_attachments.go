@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// ErrAttachmentNotFound 附件不存在或不属于当前用户
+var ErrAttachmentNotFound = utils.NewAppError(utils.CodeInvalidRequest, "attachment not found")
+
+// maxMessageAttachments 单条消息最多引用的文件数
+const maxMessageAttachments = 10
+
+// messageAttachment 消息 files 字段中记录的附件信息
+type messageAttachment struct {
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	MimeType string    `json:"mime_type"`
+}
+
+// resolveAttachments 校验消息引用的已上传文件，返回写入 Message.Files 的 JSON
+//
+// 文件必须属于当前用户；重复的 ID 只记录一次。
+func (s *ChatService) resolveAttachments(ctx context.Context, userID int, fileIDs []uuid.UUID) (string, error) {
+	if len(fileIDs) == 0 {
+		return "[]", nil
+	}
+	if len(fileIDs) > maxMessageAttachments {
+		return "", fmt.Errorf("%w: at most %d files per message", ErrAttachmentNotFound, maxMessageAttachments)
+	}
+
+	attachments := make([]messageAttachment, 0, len(fileIDs))
+	seen := make(map[uuid.UUID]bool, len(fileIDs))
+	for _, id := range fileIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		file, err := s.files.FindByID(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		if file == nil || file.UserID != userID {
+			return "", fmt.Errorf("%w: %s", ErrAttachmentNotFound, id)
+		}
+		attachments = append(attachments, messageAttachment{
+			ID:       file.ID,
+			Name:     file.Name,
+			Size:     file.Size,
+			MimeType: file.MimeType,
+		})
+	}
+
+	data, err := json.Marshal(attachments)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// attachmentFileStore 按 ID 查找文件的内存实现
+type attachmentFileStore struct {
+	FileRecordStore
+	files map[uuid.UUID]*model.File
+}
+
+func (s *attachmentFileStore) FindByID(ctx context.Context, id uuid.UUID) (*model.File, error) {
+	return s.files[id], nil
+}
+
+func TestResolveAttachments(t *testing.T) {
+	own := &model.File{ID: uuid.New(), UserID: 1, Name: "report.pdf", Size: 2048, MimeType: "application/pdf"}
+	other := &model.File{ID: uuid.New(), UserID: 2, Name: "secret.txt", Size: 10, MimeType: "text/plain"}
+	s := &ChatService{files: &attachmentFileStore{files: map[uuid.UUID]*model.File{own.ID: own, other.ID: other}}}
+	ctx := context.Background()
+
+	files, err := s.resolveAttachments(ctx, 1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "[]", files)
+
+	files, err = s.resolveAttachments(ctx, 1, []uuid.UUID{own.ID, own.ID})
+	require.NoError(t, err)
+	var attachments []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(files), &attachments))
+	require.Len(t, attachments, 1)
+	assert.Equal(t, own.ID.String(), attachments[0]["id"])
+	assert.Equal(t, "report.pdf", attachments[0]["name"])
+	assert.Equal(t, "application/pdf", attachments[0]["mime_type"])
+
+	_, err = s.resolveAttachments(ctx, 1, []uuid.UUID{own.ID, other.ID})
+	assert.ErrorIs(t, err, ErrAttachmentNotFound, "files of other users cannot be attached")
+
+	_, err = s.resolveAttachments(ctx, 1, []uuid.UUID{uuid.New()})
+	assert.ErrorIs(t, err, ErrAttachmentNotFound)
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// ErrKnowledgeBaseNotFound 知识库不存在或不属于当前用户
+var ErrKnowledgeBaseNotFound = utils.NewAppError(utils.CodeNotFound, "knowledge base not found")
+
+const (
+	chatKnowledgeTopK      = 5   // 每条消息检索的文本块数
+	citationSnippetRunes   = 200 // 引用中摘要的最大字符数
+	knowledgeBudgetShare   = 2   // 注入的参考资料最多占输入 Token 预算的 1/knowledgeBudgetShare
+	knowledgeSearchTimeout = 10 * time.Second
+)
+
+// knowledgePromptHeader 参考资料的引用模板说明，每段资料以 [编号] 标题 开头
+const knowledgePromptHeader = "请根据以下知识库参考资料回答用户的问题。引用资料时在相应句子末尾标注编号，如 [1]；" +
+	"参考资料中没有相关信息时请直接说明。"
+
+// KnowledgeSearcher 检索用户知识库中与问题相关的文本块，结果按相关度降序
+type KnowledgeSearcher interface {
+	SearchKnowledge(ctx context.Context, userID, kbID int, query string, topK int) ([]*model.KBSearchResult, error)
+}
+
+// Citation 回复引用的知识库文本块
+type Citation struct {
+	Index      int       `json:"index"` // 提示词中的引用编号，从 1 开始
+	DocumentID uuid.UUID `json:"doc_id"`
+	ChunkID    uuid.UUID `json:"chunk_id"`
+	Title      string    `json:"title"`
+	Snippet    string    `json:"snippet"`
+	Score      float64   `json:"score"`
+}
+
+// ChatReply 发送消息的结果：助手消息及其引用的知识库文本块
+type ChatReply struct {
+	*model.Message
+	Citations []Citation `json:"citations,omitempty"`
+}
+
+// SetKnowledgeSearch 设置知识库检索，消息指定 knowledge_base_id 时使用，未设置时拒绝这类消息
+func (s *ChatService) SetKnowledgeSearch(searcher KnowledgeSearcher) {
+	s.knowledge = searcher
+}
+
+// searchKnowledge 以用户消息检索指定的知识库
+func (s *ChatService) searchKnowledge(ctx context.Context, userID int, req *SendMessageRequest) ([]*model.KBSearchResult, error) {
+	if req.KnowledgeBaseID <= 0 {
+		return nil, nil
+	}
+	if s.knowledge == nil {
+		return nil, fmt.Errorf("knowledge base search is not configured")
+	}
+	return s.knowledge.SearchKnowledge(ctx, userID, req.KnowledgeBaseID, req.Content, chatKnowledgeTopK)
+}
+
+// injectKnowledge 把检索结果按引用模板组成系统消息，插入到开头的系统消息之后，返回实际注入的引用
+//
+// 参考资料与系统提示词一样在裁剪时保留，因此最多占裁剪预算的 1/knowledgeBudgetShare（扣除系统提示词与
+// 当前消息），其余留给对话历史；放不下的文本块及其后的结果不再注入。没有启用裁剪时注入全部结果。
+func (s *ChatService) injectKnowledge(session *model.Session, results []*model.KBSearchResult, messages []relay.ChatMessage) ([]relay.ChatMessage, []Citation) {
+	if len(results) == 0 {
+		return messages, nil
+	}
+
+	pinned, history := splitSystemMessages(messages)
+	budget := s.contextBudget(session)
+	remaining := budget / knowledgeBudgetShare
+	if budget > 0 {
+		for _, m := range pinned {
+			remaining -= messageTokens(session.Model, m)
+		}
+		if len(history) > 0 {
+			remaining -= messageTokens(session.Model, history[len(history)-1])
+		}
+		if remaining <= 0 {
+			return messages, nil
+		}
+	}
+
+	var (
+		prompt    strings.Builder
+		citations []Citation
+	)
+	prompt.WriteString(knowledgePromptHeader)
+	for _, result := range results {
+		section := fmt.Sprintf("\n\n[%d] %s\n%s", len(citations)+1, result.DocumentTitle, strings.TrimSpace(result.Content))
+		candidate := relay.ChatMessage{Role: "system", Content: prompt.String() + section}
+		if budget > 0 && messageTokens(session.Model, candidate) > remaining {
+			break
+		}
+		prompt.WriteString(section)
+		citations = append(citations, Citation{
+			Index:      len(citations) + 1,
+			DocumentID: result.DocumentID,
+			ChunkID:    result.ChunkID,
+			Title:      result.DocumentTitle,
+			Snippet:    truncateRunes(strings.TrimSpace(result.Content), citationSnippetRunes),
+			Score:      result.Score,
+		})
+	}
+	if len(citations) == 0 {
+		return messages, nil
+	}
+
+	injected := make([]relay.ChatMessage, 0, len(messages)+1)
+	injected = append(injected, pinned...)
+	injected = append(injected, relay.ChatMessage{Role: "system", Content: prompt.String()})
+	injected = append(injected, history...)
+	return injected, citations
+}
+
+// KBSearchClient 通过知识库服务的搜索接口检索，以消息发送者的身份签发短期访问令牌，
+// 知识库服务按令牌中的用户校验知识库归属
+type KBSearchClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewKBSearchClient 创建知识库服务的搜索客户端，baseURL 如 http://localhost:8085
+func NewKBSearchClient(baseURL string) *KBSearchClient {
+	return &KBSearchClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: knowledgeSearchTimeout},
+	}
+}
+
+// SearchKnowledge 实现 KnowledgeSearcher，使用混合搜索
+func (c *KBSearchClient) SearchKnowledge(ctx context.Context, userID, kbID int, query string, topK int) ([]*model.KBSearchResult, error) {
+	token, err := utils.GenerateAccessToken(userID, "", 0, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"query": query,
+		"mode":  SearchModeHybrid,
+		"top_k": topK,
+	})
+	url := fmt.Sprintf("%s/api/v1/knowledge-bases/%d/search", c.baseURL, kbID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("knowledge base search failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden, http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %d", ErrKnowledgeBaseNotFound, kbID)
+	default:
+		return nil, fmt.Errorf("knowledge base search failed: status=%d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data SearchResponse `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid knowledge base search response: %w", err)
+	}
+	return result.Data.Results, nil
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// kbSearchServer 模拟知识库服务的搜索接口：知识库 7 属于用户 42，返回两个文本块
+func kbSearchServer(t *testing.T, chunks []*model.KBSearchResult) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := utils.ParseToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/knowledge-bases/7/search" || claims.UserID != 42 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var req struct {
+			Query string `json:"query"`
+			Mode  string `json:"mode"`
+			TopK  int    `json:"top_k"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "How long do refunds take?", req.Query)
+		assert.Equal(t, "hybrid", req.Mode)
+		assert.Equal(t, chatKnowledgeTopK, req.TopK)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data":    SearchResponse{Results: chunks, Metadata: SearchMetadata{Mode: SearchModeHybrid}},
+		})
+	}))
+}
+
+func twoChunks() []*model.KBSearchResult {
+	return []*model.KBSearchResult{
+		{
+			ChunkID:       uuid.New(),
+			DocumentID:    uuid.New(),
+			DocumentTitle: "Refund policy",
+			Content:       "Refunds are processed within five business days.",
+			Score:         0.031,
+		},
+		{
+			ChunkID:       uuid.New(),
+			DocumentID:    uuid.New(),
+			DocumentTitle: "Billing FAQ",
+			Content:       "Invoices are issued on the first day of each month.",
+			Score:         0.016,
+		},
+	}
+}
+
+func TestChatKnowledgeInjectsCitations(t *testing.T) {
+	utils.InitJWT(&config.JWTConfig{Secret: "test-secret"})
+	chunks := twoChunks()
+	server := kbSearchServer(t, chunks)
+	defer server.Close()
+
+	s := &ChatService{relayService: NewRelayService()}
+	s.SetKnowledgeSearch(NewKBSearchClient(server.URL + "/"))
+	req := &SendMessageRequest{Content: "How long do refunds take?", KnowledgeBaseID: 7}
+
+	results, err := s.searchKnowledge(context.Background(), 42, req)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	session := &model.Session{Model: "gpt-4"}
+	messages := []relay.ChatMessage{
+		{Role: "system", Content: "You are a support agent."},
+		{Role: "user", Content: "Hi"},
+		{Role: "assistant", Content: "Hello!"},
+		{Role: "user", Content: req.Content},
+	}
+	injected, citations := s.injectKnowledge(session, results, messages)
+
+	// 参考资料紧跟在会话的系统提示词之后，裁剪时一同保留
+	require.Len(t, injected, len(messages)+1)
+	assert.Equal(t, messages[0], injected[0])
+	assert.Equal(t, "system", injected[1].Role)
+	assert.Contains(t, injected[1].Content, "[1] Refund policy\nRefunds are processed within five business days.")
+	assert.Contains(t, injected[1].Content, "[2] Billing FAQ\nInvoices are issued on the first day of each month.")
+	assert.Equal(t, messages[1:], injected[2:])
+
+	require.Len(t, citations, 2)
+	for i, c := range citations {
+		assert.Equal(t, i+1, c.Index)
+		assert.Equal(t, chunks[i].DocumentID, c.DocumentID)
+		assert.Equal(t, chunks[i].ChunkID, c.ChunkID)
+		assert.Equal(t, chunks[i].Content, c.Snippet)
+		assert.Equal(t, chunks[i].Score, c.Score)
+	}
+
+	data, err := json.Marshal(citations[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"doc_id":"`+chunks[0].DocumentID.String()+`"`)
+	assert.Contains(t, string(data), `"chunk_id":"`+chunks[0].ChunkID.String()+`"`)
+
+	// 其他用户的知识库
+	_, err = s.searchKnowledge(context.Background(), 43, req)
+	assert.ErrorIs(t, err, ErrKnowledgeBaseNotFound)
+
+	// 未指定知识库时不检索
+	results, err = s.searchKnowledge(context.Background(), 42, &SendMessageRequest{Content: req.Content})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestChatKnowledgeRespectsContextBudget(t *testing.T) {
+	chunks := twoChunks()
+	chunks[1].Content = strings.Repeat("lorem ipsum ", 300)
+
+	s := &ChatService{relayService: NewRelayService()}
+	s.SetContextTrimming(&TokenBudgetTrimmer{}, 1024, 256)
+	session := &model.Session{Model: "gpt-4"}
+	messages := longConversation()
+
+	// 预算的一半放不下第二个文本块，只注入第一个
+	injected, citations := s.injectKnowledge(session, chunks, messages)
+	require.Len(t, citations, 1)
+	assert.Equal(t, chunks[0].ChunkID, citations[0].ChunkID)
+	assert.NotContains(t, injected[1].Content, "[2]")
+	assert.LessOrEqual(t, messageTokens(session.Model, injected[0])+messageTokens(session.Model, injected[1])+
+		messageTokens(session.Model, messages[len(messages)-1]), (1024-256)/knowledgeBudgetShare)
+
+	// 裁剪移除的是对话历史，参考资料保留
+	kept, trimmed := s.trimContext(context.Background(), session, injected)
+	assert.Greater(t, trimmed, 0)
+	assert.Equal(t, injected[:2], kept[:2])
+	assert.LessOrEqual(t, countMessagesTokens(session.Model, kept), 1024-256)
+
+	// 系统提示词已用完一半预算时不注入
+	long := append([]relay.ChatMessage{{Role: "system", Content: strings.Repeat("rule ", 500)}}, messages[1:]...)
+	injected, citations = s.injectKnowledge(session, chunks, long)
+	assert.Empty(t, citations)
+	assert.Equal(t, long, injected)
+}
+
+func countMessagesTokens(model string, messages []relay.ChatMessage) int {
+	total := 0
+	for _, m := range messages {
+		total += messageTokens(model, m)
+	}
+	return total
+}
@@ -3,44 +3,73 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/chat"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/scaling"
 	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
 	"go.uber.org/zap"
 )
 
 type ChatService struct {
 	sessionRepo    *repository.SessionRepository
 	messageRepo    *repository.MessageRepository
+	editRepo       *repository.MessageEditRepository
+	files          FileRecordStore // 消息附件引用的已上传文件
 	relayService   *RelayService
 	billingService *BillingService
+	activeStreams  *scaling.Gauge // 正在输出的流式响应数，未设置时不统计
+
+	// 上下文裁剪，未设置时不裁剪
+	trimmer              ContextTrimmer
+	defaultContextWindow int // 渠道未声明上下文窗口时使用，0 表示不裁剪
+	completionReserve    int // 会话未设置 max_tokens 时为输出预留的 Token 数
+
+	titles *TitleGenerator // 自动生成会话标题，未设置时不生成
+
+	knowledge KnowledgeSearcher // 消息指定的知识库检索，未设置时不支持 knowledge_base_id
+
+	branches *chat.BranchManager // 重新生成回复时创建的消息分支
+	exports  *chat.ExportManager // 会话导出
 }
 
 func NewChatService() *ChatService {
 	return &ChatService{
 		sessionRepo:    repository.NewSessionRepository(),
 		messageRepo:    repository.NewMessageRepository(),
+		editRepo:       repository.NewMessageEditRepository(),
+		files:          repository.NewFileRepository(),
 		relayService:   NewRelayService(),
 		billingService: NewBillingService(),
+		branches:       chat.NewPersistentBranchManager(&messageBranchStore{repo: repository.NewMessageBranchRepository()}),
+		exports:        chat.NewExportManager(),
 	}
 }
 
 type CreateSessionRequest struct {
-	Title         string  `json:"title" binding:"required"`
+	Title         string  `json:"title"`
 	Model         string  `json:"model" binding:"required"`
 	Temperature   float64 `json:"temperature"`
 	SystemRole    string  `json:"system_role"`
 	ContextLength int     `json:"context_length"`
+	AutoTitle     bool    `json:"auto_title"` // 第一轮对话完成后自动生成标题
 }
 
 type SendMessageRequest struct {
-	SessionID uuid.UUID `json:"session_id" binding:"required"`
-	Content   string    `json:"content" binding:"required"`
+	SessionID uuid.UUID   `json:"session_id" binding:"required"`
+	Content   string      `json:"content" binding:"required"`
+	FileIDs   []uuid.UUID `json:"file_ids"` // 通过文件服务上传的附件
+
+	// KnowledgeBaseID 检索该知识库并把相关文本块作为带编号的参考资料注入，回复中返回引用
+	KnowledgeBaseID int `json:"knowledge_base_id"`
 }
 
 // CreateSession 创建会话
@@ -52,9 +81,13 @@ func (s *ChatService) CreateSession(ctx context.Context, userID int, req *Create
 		Temperature:   req.Temperature,
 		SystemRole:    req.SystemRole,
 		ContextLength: req.ContextLength,
+		AutoTitle:     req.AutoTitle,
 	}
 
 	// 设置默认值
+	if session.Title == "" {
+		session.Title = DefaultSessionTitle
+	}
 	if session.Temperature == 0 {
 		session.Temperature = 0.7
 	}
@@ -69,15 +102,23 @@ func (s *ChatService) CreateSession(ctx context.Context, userID int, req *Create
 	return session, nil
 }
 
-// GetUserSessions 获取用户的会话列表
-func (s *ChatService) GetUserSessions(ctx context.Context, userID int, page, pageSize int) ([]*model.Session, int64, error) {
+// ErrInvalidSessionState 会话列表的筛选状态无效
+var ErrInvalidSessionState = utils.NewAppError(utils.CodeInvalidRequest, "state must be one of active, archived, deleted")
+
+// GetUserSessions 按状态获取用户的会话列表，state 为空时返回未归档、未删除的会话
+func (s *ChatService) GetUserSessions(ctx context.Context, userID int, state string, page, pageSize int) ([]*model.Session, int64, error) {
+	switch state {
+	case "", model.SessionStateActive, model.SessionStateArchived, model.SessionStateDeleted:
+	default:
+		return nil, 0, ErrInvalidSessionState
+	}
 	if page <= 0 {
 		page = 1
 	}
 	if pageSize <= 0 {
 		pageSize = 20
 	}
-	return s.sessionRepo.FindByUserID(ctx, userID, page, pageSize)
+	return s.sessionRepo.FindByUserID(ctx, userID, state, page, pageSize)
 }
 
 // GetSessionByID 获取会话详情
@@ -117,14 +158,57 @@ func (s *ChatService) GetSessionMessages(ctx context.Context, sessionID uuid.UUI
 	return s.messageRepo.FindBySessionID(ctx, sessionID, page, pageSize)
 }
 
+// SessionUsage 会话用量明细
+type SessionUsage struct {
+	InputTokens     int64                           `json:"input_tokens"`
+	OutputTokens    int64                           `json:"output_tokens"`
+	ReasoningTokens int64                           `json:"reasoning_tokens"` // 已包含在 OutputTokens 中
+	TotalTokens     int64                           `json:"total_tokens"`
+	Cost            int64                           `json:"cost"`
+	Models          []*repository.SessionModelUsage `json:"models"`
+}
+
+// GetSessionUsage 获取会话的 Token 用量明细（按模型拆分）
+func (s *ChatService) GetSessionUsage(ctx context.Context, sessionID uuid.UUID, userID int) (*SessionUsage, error) {
+	if _, err := s.GetSessionByID(ctx, sessionID, userID); err != nil {
+		return nil, err
+	}
+
+	models, err := s.messageRepo.GetSessionUsage(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &SessionUsage{Models: models}
+	for _, m := range models {
+		usage.InputTokens += m.InputTokens
+		usage.OutputTokens += m.OutputTokens
+		usage.ReasoningTokens += m.ReasoningTokens
+		usage.TotalTokens += m.TotalTokens
+		usage.Cost += m.Cost
+	}
+	return usage, nil
+}
+
 // SendMessage 发送消息（调用中转服务获取 AI 响应）
-func (s *ChatService) SendMessage(ctx context.Context, userID int, req *SendMessageRequest) (*model.Message, error) {
+func (s *ChatService) SendMessage(ctx context.Context, userID int, req *SendMessageRequest) (*ChatReply, error) {
 	// 1. 查询会话并检查权限
 	session, err := s.GetSessionByID(ctx, req.SessionID, userID)
 	if err != nil {
 		return nil, err
 	}
 
+	files, err := s.resolveAttachments(ctx, userID, req.FileIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 检索失败时不保存用户消息
+	knowledge, err := s.searchKnowledge(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
 	// 2. 创建用户消息
 	userMsg := &model.Message{
 		SessionID: req.SessionID,
@@ -132,7 +216,7 @@ func (s *ChatService) SendMessage(ctx context.Context, userID int, req *SendMess
 		Content:   req.Content,
 		Model:     session.Model,
 		Metadata:  "{}",
-		Files:     "[]",
+		Files:     files,
 		ToolCalls: "[]",
 	}
 	if err := s.messageRepo.Create(ctx, userMsg); err != nil {
@@ -182,6 +266,9 @@ func (s *ChatService) SendMessage(ctx context.Context, userID int, req *SendMess
 		}
 	}
 
+	relayMessages, citations := s.injectKnowledge(session, knowledge, relayMessages)
+	relayMessages, trimmed := s.trimContext(ctx, session, relayMessages)
+
 	relayReq := &relay.ChatCompletionRequest{
 		Model:       session.Model,
 		Messages:    relayMessages,
@@ -195,7 +282,8 @@ func (s *ChatService) SendMessage(ctx context.Context, userID int, req *SendMess
 	}
 
 	// 调用 Relay Service
-	relayResp, err := s.relayService.RelayChatCompletion(ctx, relayReq)
+	relayCtx, rc := chatRelayContext(ctx)
+	relayResp, err := s.relayService.RelayChatCompletion(relayCtx, relayReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get AI response: %w", err)
 	}
@@ -209,19 +297,34 @@ func (s *ChatService) SendMessage(ctx context.Context, userID int, req *SendMess
 	// 提取 Token 使用量
 	inputTokens := relayResp.Usage.PromptTokens
 	outputTokens := relayResp.Usage.CompletionTokens
+	reasoningTokens := relayResp.Usage.ReasoningTokens()
+	inputTokens, outputTokens = estimateMissingUsage(relayReq, aiContent, inputTokens, outputTokens)
 
 	// 6. 创建 AI 消息
 	aiMsg := &model.Message{
-		SessionID:    req.SessionID,
-		Role:         "assistant",
-		Content:      aiContent,
-		Model:        session.Model,
-		InputTokens:  inputTokens,
-		OutputTokens: outputTokens,
-		TotalTokens:  inputTokens + outputTokens,
-		Metadata:     "{}",
-		Files:        "[]",
-		ToolCalls:    "[]",
+		SessionID:       req.SessionID,
+		Role:            "assistant",
+		Content:         aiContent,
+		Model:           session.Model,
+		InputTokens:     inputTokens,
+		OutputTokens:    outputTokens,
+		ReasoningTokens: reasoningTokens,
+		TotalTokens:     inputTokens + outputTokens,
+		Metadata:        "{}",
+		Files:           "[]",
+		ToolCalls:       "[]",
+		Latency:         messageLatency(rc),
+	}
+	meta := map[string]interface{}{}
+	if trimmed > 0 {
+		meta["trimmed_messages"] = trimmed
+	}
+	if len(citations) > 0 {
+		meta["citations"] = citations
+	}
+	if len(meta) > 0 {
+		md, _ := json.Marshal(meta)
+		aiMsg.Metadata = string(md)
 	}
 	if err := s.messageRepo.Create(ctx, aiMsg); err != nil {
 		return nil, err
@@ -229,7 +332,7 @@ func (s *ChatService) SendMessage(ctx context.Context, userID int, req *SendMess
 
 	// 7. 处理计费（如果有 Token 使用）
 	if inputTokens > 0 || outputTokens > 0 {
-		_, err := s.billingService.Charge(ctx, userID, req.SessionID, aiMsg.ID, session.Model, inputTokens, outputTokens)
+		_, err := s.billingService.Charge(ctx, userID, req.SessionID, aiMsg.ID, session.Model, inputTokens, outputTokens, reasoningTokens)
 		if err != nil {
 			// 计费失败不影响消息的返回，仅记录日志
 			fmt.Printf("计费失败: %v\n", err)
@@ -240,7 +343,12 @@ func (s *ChatService) SendMessage(ctx context.Context, userID int, req *SendMess
 	session.UpdatedAt = aiMsg.CreatedAt
 	_ = s.sessionRepo.Update(ctx, session)
 
-	return aiMsg, nil
+	// 上下文中只有本次的用户消息，即第一轮对话
+	if len(contextMessages) <= 1 {
+		s.generateTitleAsync(userID, session, req.Content, aiContent)
+	}
+
+	return &ChatReply{Message: aiMsg, Citations: citations}, nil
 }
 
 // UpdateSession 更新会话
@@ -252,6 +360,7 @@ func (s *ChatService) UpdateSession(ctx context.Context, userID int, sessionID u
 
 	if title != "" {
 		session.Title = title
+		session.AutoTitle = false // 已命名的会话不再自动生成标题
 	}
 
 	if err := s.sessionRepo.Update(ctx, session); err != nil {
@@ -261,7 +370,7 @@ func (s *ChatService) UpdateSession(ctx context.Context, userID int, sessionID u
 	return session, nil
 }
 
-// DeleteSession 删除会话
+// DeleteSession 删除会话（软删除，保留期内可通过 RestoreSession 恢复）
 func (s *ChatService) DeleteSession(ctx context.Context, userID int, sessionID uuid.UUID) error {
 	// 检查权限
 	_, err := s.GetSessionByID(ctx, sessionID, userID)
@@ -272,14 +381,183 @@ func (s *ChatService) DeleteSession(ctx context.Context, userID int, sessionID u
 	return s.sessionRepo.Delete(ctx, sessionID)
 }
 
-// SendMessageStream 流式发送消息（SSE）
+// RestoreSession 恢复已删除的会话及其消息
+func (s *ChatService) RestoreSession(ctx context.Context, userID int, sessionID uuid.UUID) (*model.Session, error) {
+	session, err := s.sessionRepo.FindDeletedByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, fmt.Errorf("session not found")
+	}
+	if session.UserID != userID {
+		return nil, fmt.Errorf("permission denied")
+	}
+
+	if err := s.sessionRepo.Restore(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// SetSessionArchived 归档或取消归档会话
+func (s *ChatService) SetSessionArchived(ctx context.Context, userID int, sessionID uuid.UUID, archived bool) (*model.Session, error) {
+	session, err := s.GetSessionByID(ctx, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	session.Archived = archived
+	if err := s.sessionRepo.Update(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// MessageDetail 管理员查看的消息详情，包含生成该消息的中转请求分阶段耗时
+type MessageDetail struct {
+	*model.Message
+	Latency *relay.LatencyBreakdown `json:"latency"`
+}
+
+// GetMessageDetail 获取消息详情（管理员功能），消息不存在时返回 nil
+func (s *ChatService) GetMessageDetail(ctx context.Context, messageID uuid.UUID) (*MessageDetail, error) {
+	message, err := s.messageRepo.FindByID(ctx, messageID)
+	if err != nil || message == nil {
+		return nil, err
+	}
+
+	detail := &MessageDetail{Message: message}
+	if message.Latency != nil {
+		var latency relay.LatencyBreakdown
+		if err := json.Unmarshal([]byte(*message.Latency), &latency); err == nil {
+			detail.Latency = &latency
+		}
+	}
+	return detail, nil
+}
+
+// chatRelayContext 为对话请求创建中转上下文，中转结束后从中读取分阶段耗时
+func chatRelayContext(ctx context.Context) (context.Context, *relay.RelayContext) {
+	rc, err := relay.NewRelayContextBuilder("", relay.EndpointChatCompletions).Build()
+	if err != nil {
+		return ctx, nil
+	}
+	return relay.WithRelayContext(ctx, rc), rc
+}
+
+// messageLatency 截至保存消息时的分阶段耗时，中转之后的处理计入后处理阶段
+func messageLatency(rc *relay.RelayContext) *string {
+	if rc == nil {
+		return nil
+	}
+	data, err := json.Marshal(rc.Latency(time.Now()))
+	if err != nil {
+		return nil
+	}
+	latency := string(data)
+	return &latency
+}
+
+// SetScalingSignals 导出正在输出的流式响应数，maxStreams 为软/硬上限的基准（0 表示不设上限）
+func (s *ChatService) SetScalingSignals(reg *scaling.Registry, maxStreams int) {
+	s.activeStreams = reg.NewGauge(scaling.MetricChatActiveStreams,
+		"Chat responses currently being streamed to clients.", nil, float64(maxStreams))
+}
+
+// SetContextTrimming 启用上下文裁剪：对话超出模型上下文窗口时按 trimmer 的策略移除较早的消息
+//
+// defaultWindow 用于没有渠道声明上下文窗口的模型（0 表示这类模型不裁剪），
+// completionReserve 为会话未设置 max_tokens 时给输出预留的 Token 数。
+func (s *ChatService) SetContextTrimming(trimmer ContextTrimmer, defaultWindow, completionReserve int) {
+	s.trimmer = trimmer
+	s.defaultContextWindow = defaultWindow
+	s.completionReserve = completionReserve
+}
+
+// contextBudget 会话输入消息的 Token 预算：模型上下文窗口减去输出预留，0 表示不裁剪
+func (s *ChatService) contextBudget(session *model.Session) int {
+	if s.trimmer == nil {
+		return 0
+	}
+	window := s.relayService.ContextWindow(session.Model)
+	if window <= 0 {
+		window = s.defaultContextWindow
+	}
+	if window <= 0 {
+		return 0
+	}
+
+	reserve := s.completionReserve
+	if session.MaxTokens != nil && *session.MaxTokens > 0 {
+		reserve = *session.MaxTokens
+	}
+	budget := window - reserve
+	if budget <= 0 {
+		budget = window
+	}
+	return budget
+}
+
+// trimContext 按模型上下文窗口裁剪对话消息，返回裁剪后的消息与移除的消息数；裁剪失败时原样发送
+func (s *ChatService) trimContext(ctx context.Context, session *model.Session, messages []relay.ChatMessage) ([]relay.ChatMessage, int) {
+	budget := s.contextBudget(session)
+	if budget <= 0 {
+		return messages, 0
+	}
+
+	result, err := s.trimmer.Trim(ctx, session.Model, messages, budget)
+	if err != nil {
+		logger.Warn("context trimming failed, sending full history",
+			zap.String("model", session.Model),
+			zap.Error(err))
+		return messages, 0
+	}
+	return result.Messages, result.Trimmed
+}
+
+// ChatEventWriter 按事件接收流式对话输出的写入器
+//
+// 传入 SendMessageStream、RegenerateMessage 的 writer 实现该接口时不再按 SSE 格式写入，
+// 由写入器自行编码（如 WebSocket 帧）。事件的 type 为 chunk、complete 或 citations。
+type ChatEventWriter interface {
+	io.Writer
+	WriteEvent(event map[string]interface{}) error
+}
+
+// writeStreamEvent 写入一个流式事件，默认编码为 SSE data 行
+func writeStreamEvent(writer io.Writer, event map[string]interface{}) {
+	if eventWriter, ok := writer.(ChatEventWriter); ok {
+		_ = eventWriter.WriteEvent(event)
+		return
+	}
+	jsonData, _ := json.Marshal(event)
+	fmt.Fprintf(writer, "data: %s\n\n", string(jsonData))
+}
+
+// SendMessageStream 流式发送消息（SSE，writer 实现 ChatEventWriter 时按事件输出）
 func (s *ChatService) SendMessageStream(ctx context.Context, userID int, req *SendMessageRequest, writer io.Writer) error {
+	if s.activeStreams != nil {
+		s.activeStreams.Inc()
+		defer s.activeStreams.Dec()
+	}
+
 	// 1. 查询会话并检查权限
 	session, err := s.GetSessionByID(ctx, req.SessionID, userID)
 	if err != nil {
 		return err
 	}
 
+	files, err := s.resolveAttachments(ctx, userID, req.FileIDs)
+	if err != nil {
+		return err
+	}
+
+	knowledge, err := s.searchKnowledge(ctx, userID, req)
+	if err != nil {
+		return err
+	}
+
 	// 2. 创建用户消息
 	userMsg := &model.Message{
 		SessionID: req.SessionID,
@@ -287,7 +565,7 @@ func (s *ChatService) SendMessageStream(ctx context.Context, userID int, req *Se
 		Content:   req.Content,
 		Model:     session.Model,
 		Metadata:  "{}",
-		Files:     "[]",
+		Files:     files,
 		ToolCalls: "[]",
 	}
 	if err := s.messageRepo.Create(ctx, userMsg); err != nil {
@@ -325,6 +603,9 @@ func (s *ChatService) SendMessageStream(ctx context.Context, userID int, req *Se
 		Content: req.Content,
 	})
 
+	relayMessages, citations := s.injectKnowledge(session, knowledge, relayMessages)
+	relayMessages, trimmed := s.trimContext(ctx, session, relayMessages)
+
 	// 5. 调用 Relay 服务的流式端点
 	maxTokens := 0
 	if session.MaxTokens != nil {
@@ -344,9 +625,18 @@ func (s *ChatService) SendMessageStream(ctx context.Context, userID int, req *Se
 	fullContent := ""
 	totalInputTokens := 0
 	totalOutputTokens := 0
+	totalReasoningTokens := 0
 
 	// 通过流式处理函数接收 Relay 响应
-	err = s.relayService.StreamChatCompletion(ctx, relayReq, func(chunk *relay.ChatCompletionResponse) error {
+	relayCtx, rc := chatRelayContext(ctx)
+	err = s.relayService.StreamChatCompletion(relayCtx, relayReq, func(chunk *relay.ChatCompletionResponse) error {
+		// 使用量可能在不含 choices 的最后一个数据块中返回
+		if chunk.Usage.CompletionTokens > 0 {
+			totalInputTokens = chunk.Usage.PromptTokens
+			totalOutputTokens = chunk.Usage.CompletionTokens
+			totalReasoningTokens = chunk.Usage.ReasoningTokens()
+		}
+
 		// 提取流式数据
 		if len(chunk.Choices) > 0 {
 			choice := chunk.Choices[0]
@@ -357,46 +647,57 @@ func (s *ChatService) SendMessageStream(ctx context.Context, userID int, req *Se
 			}
 
 			// 发送给客户端
-			data := map[string]interface{}{
+			writeStreamEvent(writer, map[string]interface{}{
 				"type":    "chunk",
 				"content": choice.Delta.Content,
 				"model":   chunk.Model,
-			}
-			jsonData, _ := json.Marshal(data)
-			fmt.Fprintf(writer, "data: %s\n\n", string(jsonData))
-
-			// 检查是否完成
-			if choice.FinishReason == "stop" {
-				// 更新 token 统计
-				if chunk.Usage.PromptTokens > 0 {
-					totalInputTokens = chunk.Usage.PromptTokens
-				}
-				if chunk.Usage.CompletionTokens > 0 {
-					totalOutputTokens = chunk.Usage.CompletionTokens
-				}
-			}
+			})
 		}
 
 		return nil
 	})
 
-	if err != nil {
+	// 已输出部分内容后上游中断：保存已输出的内容，只按已输出的 Token 计费
+	var interrupted *relay.StreamInterruptedError
+	partial := errors.As(err, &interrupted) && interrupted.Partial
+	if err != nil && !partial {
 		logger.Error("relay stream error", zap.Error(err))
 		return err
 	}
+	meta := map[string]interface{}{}
+	if partial {
+		logger.Warn("relay stream interrupted, keeping partial result", zap.Error(err))
+		totalInputTokens = interrupted.PromptTokens
+		totalOutputTokens = interrupted.DeliveredTokens
+		totalReasoningTokens = 0
+		meta["partial"] = true
+		meta["error_class"] = interrupted.Class
+	} else {
+		totalInputTokens, totalOutputTokens = estimateMissingUsage(relayReq, fullContent, totalInputTokens, totalOutputTokens)
+	}
+	if trimmed > 0 {
+		meta["trimmed_messages"] = trimmed
+	}
+	if len(citations) > 0 {
+		meta["citations"] = citations
+	}
+	md, _ := json.Marshal(meta)
+	metadata := string(md)
 
 	// 7. 创建 AI 消息记录
 	aiMsg := &model.Message{
-		SessionID:    req.SessionID,
-		Role:         "assistant",
-		Content:      fullContent,
-		Model:        session.Model,
-		InputTokens:  totalInputTokens,
-		OutputTokens: totalOutputTokens,
-		TotalTokens:  totalInputTokens + totalOutputTokens,
-		Metadata:     "{}",
-		Files:        "[]",
-		ToolCalls:    "[]",
+		SessionID:       req.SessionID,
+		Role:            "assistant",
+		Content:         fullContent,
+		Model:           session.Model,
+		InputTokens:     totalInputTokens,
+		OutputTokens:    totalOutputTokens,
+		ReasoningTokens: totalReasoningTokens,
+		TotalTokens:     totalInputTokens + totalOutputTokens,
+		Metadata:        metadata,
+		Files:           "[]",
+		ToolCalls:       "[]",
+		Latency:         messageLatency(rc),
 	}
 	if err := s.messageRepo.Create(ctx, aiMsg); err != nil {
 		logger.Error("failed to create message", zap.Error(err))
@@ -405,7 +706,7 @@ func (s *ChatService) SendMessageStream(ctx context.Context, userID int, req *Se
 
 	// 8. 处理计费
 	if totalInputTokens > 0 || totalOutputTokens > 0 {
-		_, err := s.billingService.Charge(ctx, userID, req.SessionID, aiMsg.ID, session.Model, totalInputTokens, totalOutputTokens)
+		_, err := s.billingService.Charge(ctx, userID, req.SessionID, aiMsg.ID, session.Model, totalInputTokens, totalOutputTokens, totalReasoningTokens)
 		if err != nil {
 			logger.Error("billing error", zap.Error(err))
 			// 计费失败不影响消息返回
@@ -418,15 +719,50 @@ func (s *ChatService) SendMessageStream(ctx context.Context, userID int, req *Se
 
 	// 10. 发送最终消息事件
 	finalMsg := map[string]interface{}{
-		"type":          "complete",
-		"message_id":    aiMsg.ID.String(),
-		"content":       fullContent,
-		"input_tokens":  totalInputTokens,
-		"output_tokens": totalOutputTokens,
-		"total_tokens":  totalInputTokens + totalOutputTokens,
-	}
-	jsonData, _ := json.Marshal(finalMsg)
-	fmt.Fprintf(writer, "data: %s\n\n", string(jsonData))
+		"type":             "complete",
+		"message_id":       aiMsg.ID.String(),
+		"content":          fullContent,
+		"input_tokens":     totalInputTokens,
+		"output_tokens":    totalOutputTokens,
+		"reasoning_tokens": totalReasoningTokens,
+		"total_tokens":     totalInputTokens + totalOutputTokens,
+	}
+	if partial {
+		finalMsg["partial"] = true
+		finalMsg["error_class"] = interrupted.Class
+	}
+	if trimmed > 0 {
+		finalMsg["trimmed_messages"] = trimmed
+	}
+	writeStreamEvent(writer, finalMsg)
+
+	// 11. 最后发送回复引用的知识库文本块
+	if len(citations) > 0 {
+		writeStreamEvent(writer, map[string]interface{}{
+			"type":      "citations",
+			"citations": citations,
+		})
+	}
 
+	// 第一轮对话完整输出后生成标题
+	if len(contextMessages) <= 1 && !partial {
+		s.generateTitleAsync(userID, session, req.Content, fullContent)
+	}
+
+	// 由调用方发送 error 事件，告知客户端内容不完整
+	if partial {
+		return err
+	}
 	return nil
 }
+
+// estimateMissingUsage 上游没有返回用量时按请求消息与输出内容统计 Token 数，保证消息记录与计费有用量
+func estimateMissingUsage(req *relay.ChatCompletionRequest, completion string, inputTokens, outputTokens int) (int, int) {
+	if inputTokens <= 0 {
+		inputTokens = countPromptTokens(req)
+	}
+	if outputTokens <= 0 {
+		outputTokens = countTextTokens(req.Model, completion)
+	}
+	return inputTokens, outputTokens
+}
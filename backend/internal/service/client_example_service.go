@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/clientexample"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+)
+
+// 示例模型与密钥的兜底值
+const (
+	fallbackExampleModel          = "gpt-4o-mini"
+	fallbackExampleEmbeddingModel = "text-embedding-3-small"
+	exampleKeyPlaceholder         = "YOUR_API_KEY"
+	exampleKeyNote                = "这是 Token 的掩码预览，请在令牌页面查看完整密钥后替换"
+)
+
+// 示例模型的来源
+const (
+	ExampleModelOverride  = "override"  // 部署配置指定
+	ExampleModelProject   = "project"   // 调用方最新 Token 绑定项目的默认模型
+	ExampleModelAvailable = "available" // 当前可用渠道提供的模型
+	ExampleModelFallback  = "fallback"  // 没有可用模型时的兜底值
+)
+
+// ModelLister 列出当前可用的模型
+type ModelLister interface {
+	ListModels(ctx context.Context, includeChannels bool) (*relay.ModelList, error)
+}
+
+// ClientExampleConfig 示例生成配置
+type ClientExampleConfig struct {
+	BaseURL        string // 对外 API 地址
+	Model          string // 管理员指定的示例模型，为空时按调用方推荐
+	EmbeddingModel string // 管理员指定的 Embedding 示例模型
+}
+
+// ClientExamples 一种语言的调用示例
+type ClientExamples struct {
+	Language       string                  `json:"language"`
+	BaseURL        string                  `json:"base_url"`
+	Model          string                  `json:"model"`
+	ModelSource    string                  `json:"model_source"`
+	EmbeddingModel string                  `json:"embedding_model"`
+	KeyPreview     string                  `json:"key_preview,omitempty"` // 最新 Token 的掩码预览，没有 Token 时为空
+	KeyNote        string                  `json:"key_note,omitempty"`
+	Snippets       []clientexample.Snippet `json:"snippets"`
+}
+
+// ClientExampleService 按部署配置与调用方信息生成可直接粘贴的 API 调用示例
+type ClientExampleService struct {
+	tokenRepo   repository.TokenRepository
+	loadProject relay.ProjectLoader
+	models      ModelLister
+	cfg         ClientExampleConfig
+}
+
+// NewClientExampleService 创建调用示例服务
+func NewClientExampleService(tokenRepo repository.TokenRepository, loadProject relay.ProjectLoader, models ModelLister, cfg ClientExampleConfig) *ClientExampleService {
+	return &ClientExampleService{
+		tokenRepo:   tokenRepo,
+		loadProject: loadProject,
+		models:      models,
+		cfg:         cfg,
+	}
+}
+
+// Examples 生成指定语言的 Chat（非流式、流式）与 Embedding 示例
+func (s *ClientExampleService) Examples(ctx context.Context, userID int, lang string) (*ClientExamples, error) {
+	token, err := s.newestToken(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	available := s.availableModels(ctx)
+
+	result := &ClientExamples{
+		Language: lang,
+		BaseURL:  strings.TrimRight(s.cfg.BaseURL, "/"),
+	}
+	result.Model, result.ModelSource = s.chatModel(ctx, token, available)
+	result.EmbeddingModel = s.embeddingModel(available)
+
+	params := clientexample.Params{
+		BaseURL:        result.BaseURL,
+		Model:          result.Model,
+		EmbeddingModel: result.EmbeddingModel,
+		APIKey:         exampleKeyPlaceholder,
+	}
+	if token != nil {
+		result.KeyPreview = tokenKeyPreview(token)
+		result.KeyNote = exampleKeyNote
+		params.APIKey = result.KeyPreview
+		params.KeyNote = result.KeyNote
+	}
+
+	result.Snippets, err = clientexample.Render(lang, params)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// newestToken 调用方最新创建的有效 Token
+func (s *ClientExampleService) newestToken(ctx context.Context, userID int) (*model.Token, error) {
+	tokens, err := s.tokenRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		if token.IsValid() {
+			return token, nil
+		}
+	}
+	return nil, nil
+}
+
+// availableModels 当前可用渠道提供的模型，获取失败时按没有可用模型处理
+func (s *ClientExampleService) availableModels(ctx context.Context) []string {
+	if s.models == nil {
+		return nil
+	}
+	list, err := s.models.ListModels(ctx, false)
+	if err != nil || list == nil {
+		return nil
+	}
+	ids := make([]string, 0, len(list.Data))
+	for _, m := range list.Data {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+// chatModel 推荐 Chat 示例模型：部署配置 > 项目默认模型 > Token 允许的可用模型 > 兜底值
+func (s *ClientExampleService) chatModel(ctx context.Context, token *model.Token, available []string) (string, string) {
+	if s.cfg.Model != "" {
+		return s.cfg.Model, ExampleModelOverride
+	}
+	if token != nil && s.loadProject != nil {
+		if project, err := relay.ResolveProject(ctx, token, s.loadProject); err == nil && project != nil && project.DefaultModel != "" {
+			return project.DefaultModel, ExampleModelProject
+		}
+	}
+	for _, id := range available {
+		if isEmbeddingModel(id) {
+			continue
+		}
+		if token == nil || token.ValidateModel(id) {
+			return id, ExampleModelAvailable
+		}
+	}
+	return fallbackExampleModel, ExampleModelFallback
+}
+
+// embeddingModel 推荐 Embedding 示例模型
+func (s *ClientExampleService) embeddingModel(available []string) string {
+	if s.cfg.EmbeddingModel != "" {
+		return s.cfg.EmbeddingModel
+	}
+	for _, id := range available {
+		if isEmbeddingModel(id) {
+			return id
+		}
+	}
+	return fallbackExampleEmbeddingModel
+}
+
+// isEmbeddingModel 按模型名判断是否为 Embedding 模型
+func isEmbeddingModel(id string) bool {
+	return strings.Contains(strings.ToLower(id), "embed")
+}
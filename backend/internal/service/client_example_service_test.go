@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/clientexample"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exampleTokens 只实现 ListByUserID，按 ID 倒序返回
+type exampleTokens struct {
+	repository.TokenRepository
+	tokens []*model.Token
+}
+
+func (r *exampleTokens) ListByUserID(ctx context.Context, userID int) ([]*model.Token, error) {
+	return r.tokens, nil
+}
+
+// staticModels 固定的可用模型列表
+type staticModels []string
+
+func (m staticModels) ListModels(ctx context.Context, includeChannels bool) (*relay.ModelList, error) {
+	list := &relay.ModelList{Object: "list"}
+	for _, id := range m {
+		list.Data = append(list.Data, &relay.ModelInfo{ID: id, Object: "model"})
+	}
+	return list, nil
+}
+
+func TestClientExamplesModelRecommendation(t *testing.T) {
+	ctx := context.Background()
+	projects := map[int]*model.Project{
+		9: {ID: 9, UserID: 1, DefaultModel: "claude-3-5-sonnet", Status: model.ProjectStatusActive},
+	}
+	loadProject := func(ctx context.Context, id int) (*model.Project, error) { return projects[id], nil }
+	models := staticModels{"bge-m3-embedding", "gpt-4o", "qwen-max"}
+
+	newService := func(cfg ClientExampleConfig, tokens ...*model.Token) *ClientExampleService {
+		cfg.BaseURL = "https://api.example.com/v1"
+		return NewClientExampleService(&exampleTokens{tokens: tokens}, loadProject, models, cfg)
+	}
+
+	// 没有 Token：第一个可用的 Chat 模型，密钥使用占位符
+	res, err := newService(ClientExampleConfig{}).Examples(ctx, 1, clientexample.LangPython)
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o", res.Model)
+	assert.Equal(t, ExampleModelAvailable, res.ModelSource)
+	assert.Equal(t, "bge-m3-embedding", res.EmbeddingModel)
+	assert.Empty(t, res.KeyPreview)
+	assert.Contains(t, res.Snippets[0].Code, exampleKeyPlaceholder)
+
+	// Token 限制了模型：选择 Token 允许的模型，示例使用掩码预览
+	restricted := &model.Token{ID: 2, UserID: 1, TokenHash: "0123456789abcdef0123", Status: model.TokenStatusNormal, ModelWhitelist: pq.StringArray{"qwen-max"}}
+	res, err = newService(ClientExampleConfig{}, restricted).Examples(ctx, 1, clientexample.LangCurl)
+	require.NoError(t, err)
+	assert.Equal(t, "qwen-max", res.Model)
+	assert.Equal(t, "0123****0123", res.KeyPreview)
+	assert.NotEmpty(t, res.KeyNote)
+	for _, s := range res.Snippets {
+		assert.NotContains(t, s.Code, restricted.TokenHash, "full key never appears in examples")
+	}
+
+	// 最新的有效 Token 绑定了项目：使用项目默认模型，已禁用的更新 Token 被跳过
+	disabled := &model.Token{ID: 5, UserID: 1, TokenHash: "disabled-token-hash", Status: model.TokenStatusDisabled}
+	projectToken := &model.Token{ID: 4, UserID: 1, TokenHash: "project-token-hash", Status: model.TokenStatusNormal, ProjectID: sql.NullInt64{Int64: 9, Valid: true}}
+	res, err = newService(ClientExampleConfig{}, disabled, projectToken).Examples(ctx, 1, clientexample.LangNode)
+	require.NoError(t, err)
+	assert.Equal(t, "claude-3-5-sonnet", res.Model)
+	assert.Equal(t, ExampleModelProject, res.ModelSource)
+	assert.Equal(t, "proj****hash", res.KeyPreview)
+
+	// 部署配置覆盖推荐
+	res, err = newService(ClientExampleConfig{Model: "deepseek-chat", EmbeddingModel: "text-embedding-v3"}, projectToken).Examples(ctx, 1, clientexample.LangGo)
+	require.NoError(t, err)
+	assert.Equal(t, "deepseek-chat", res.Model)
+	assert.Equal(t, ExampleModelOverride, res.ModelSource)
+	assert.Contains(t, res.Snippets[2].Code, `"text-embedding-v3"`)
+
+	_, err = newService(ClientExampleConfig{}).Examples(ctx, 1, "ruby")
+	assert.ErrorIs(t, err, clientexample.ErrUnsupportedLanguage)
+}
+
+func TestClientExamplesFallbackModel(t *testing.T) {
+	s := NewClientExampleService(&exampleTokens{}, nil, staticModels{}, ClientExampleConfig{BaseURL: "http://localhost:8083/v1/"})
+	res, err := s.Examples(context.Background(), 1, clientexample.LangCurl)
+	require.NoError(t, err)
+	assert.Equal(t, fallbackExampleModel, res.Model)
+	assert.Equal(t, ExampleModelFallback, res.ModelSource)
+	assert.Equal(t, fallbackExampleEmbeddingModel, res.EmbeddingModel)
+	assert.Equal(t, "http://localhost:8083/v1", res.BaseURL)
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tokenizer"
+)
+
+// 上下文裁剪策略
+const (
+	ContextStrategyTokens        = "tokens"         // 保留系统提示词与 Token 预算内最近的消息
+	ContextStrategySlidingWindow = "sliding_window" // 先只保留最近 N 条消息，再按 Token 预算裁剪
+)
+
+// TrimResult 上下文裁剪结果
+type TrimResult struct {
+	Messages []relay.ChatMessage
+	Trimmed  int // 被移除的历史消息数
+}
+
+// ContextTrimmer 在调用上游前裁剪对话消息，使输入不超过 budget 个 Token
+//
+// 开头的系统消息与最后一条（当前用户）消息总是保留；实现可以替换被移除的消息，
+// 如以摘要代替较早的对话。
+type ContextTrimmer interface {
+	Trim(ctx context.Context, model string, messages []relay.ChatMessage, budget int) (*TrimResult, error)
+}
+
+// NewContextTrimmer 按策略名称创建裁剪器，windowMessages 为滑动窗口保留的消息数
+func NewContextTrimmer(strategy string, windowMessages int) (ContextTrimmer, error) {
+	switch strategy {
+	case "", ContextStrategyTokens:
+		return &TokenBudgetTrimmer{}, nil
+	case ContextStrategySlidingWindow:
+		if windowMessages <= 0 {
+			return nil, fmt.Errorf("sliding window needs a positive message count, got %d", windowMessages)
+		}
+		return &SlidingWindowTrimmer{MaxMessages: windowMessages}, nil
+	default:
+		return nil, fmt.Errorf("unknown context trimming strategy %q", strategy)
+	}
+}
+
+// TokenBudgetTrimmer 保留系统提示词，从最新的消息往前保留，直到 Token 预算用完
+type TokenBudgetTrimmer struct{}
+
+// Trim 实现 ContextTrimmer
+//
+// 每条消息单独计数（包含消息格式开销），总数略高于整体计数，裁剪结果偏保守。
+func (t *TokenBudgetTrimmer) Trim(ctx context.Context, model string, messages []relay.ChatMessage, budget int) (*TrimResult, error) {
+	pinned, history := splitSystemMessages(messages)
+	if budget <= 0 || len(history) <= 1 {
+		return &TrimResult{Messages: messages}, nil
+	}
+
+	used := messageTokens(model, history[len(history)-1])
+	for _, m := range pinned {
+		used += messageTokens(model, m)
+	}
+
+	// 保持对话连续：遇到第一条放不下的消息后，更早的消息全部移除
+	start := len(history) - 1
+	for start > 0 {
+		cost := messageTokens(model, history[start-1])
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		start--
+	}
+
+	kept := make([]relay.ChatMessage, 0, len(pinned)+len(history)-start)
+	kept = append(kept, pinned...)
+	kept = append(kept, history[start:]...)
+	return &TrimResult{Messages: kept, Trimmed: start}, nil
+}
+
+// SlidingWindowTrimmer 只保留系统提示词与最近 MaxMessages 条消息，仍超出预算时再按 Token 裁剪
+type SlidingWindowTrimmer struct {
+	MaxMessages int
+}
+
+// Trim 实现 ContextTrimmer
+func (t *SlidingWindowTrimmer) Trim(ctx context.Context, model string, messages []relay.ChatMessage, budget int) (*TrimResult, error) {
+	pinned, history := splitSystemMessages(messages)
+	dropped := 0
+	if t.MaxMessages > 0 && len(history) > t.MaxMessages {
+		dropped = len(history) - t.MaxMessages
+		windowed := make([]relay.ChatMessage, 0, len(pinned)+t.MaxMessages)
+		windowed = append(windowed, pinned...)
+		messages = append(windowed, history[dropped:]...)
+	}
+
+	result, err := (&TokenBudgetTrimmer{}).Trim(ctx, model, messages, budget)
+	if err != nil {
+		return nil, err
+	}
+	result.Trimmed += dropped
+	return result, nil
+}
+
+// splitSystemMessages 拆分开头的系统消息与其后的对话历史
+func splitSystemMessages(messages []relay.ChatMessage) (pinned, history []relay.ChatMessage) {
+	i := 0
+	for i < len(messages) && messages[i].Role == "system" {
+		i++
+	}
+	return messages[:i], messages[i:]
+}
+
+// messageTokens 单条消息的 Token 数
+func messageTokens(model string, m relay.ChatMessage) int {
+	return tokenizer.CountMessages(model, []tokenizer.Message{{Role: m.Role, Content: m.OpenAIContent()}})
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tokenizer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// longConversation 系统提示词加 50 条约 100 Token 的对话消息，最后一条为当前用户消息
+func longConversation() []relay.ChatMessage {
+	messages := []relay.ChatMessage{{Role: "system", Content: "You are a helpful assistant."}}
+	for i := 0; i < 50; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages = append(messages, relay.ChatMessage{
+			Role:    role,
+			Content: fmt.Sprintf("message %02d ", i) + strings.Repeat("lorem ipsum ", 33),
+		})
+	}
+	return messages
+}
+
+func toTokenizerMessages(messages []relay.ChatMessage) []tokenizer.Message {
+	out := make([]tokenizer.Message, len(messages))
+	for i, m := range messages {
+		out[i] = tokenizer.Message{Role: m.Role, Content: m.Content}
+	}
+	return out
+}
+
+func TestTokenBudgetTrimmerKeepsSystemPromptAndLatestMessages(t *testing.T) {
+	messages := longConversation()
+	const budget = 4096 - 1024
+	require.Greater(t, tokenizer.CountMessages("gpt-4", toTokenizerMessages(messages)), budget)
+
+	result, err := (&TokenBudgetTrimmer{}).Trim(context.Background(), "gpt-4", messages, budget)
+	require.NoError(t, err)
+
+	kept := result.Messages
+	assert.Equal(t, "system", kept[0].Role)
+	assert.Equal(t, messages[len(messages)-1], kept[len(kept)-1])
+	assert.Equal(t, len(messages), len(kept)+result.Trimmed)
+	assert.Greater(t, result.Trimmed, 0)
+	assert.LessOrEqual(t, tokenizer.CountMessages("gpt-4", toTokenizerMessages(kept)), budget)
+
+	// 保留的是连续的最近消息
+	assert.Equal(t, messages[1+result.Trimmed:], kept[1:])
+
+	// 未超出预算时不裁剪
+	short := messages[:4]
+	result, err = (&TokenBudgetTrimmer{}).Trim(context.Background(), "gpt-4", short, budget)
+	require.NoError(t, err)
+	assert.Equal(t, short, result.Messages)
+	assert.Zero(t, result.Trimmed)
+}
+
+func TestSlidingWindowTrimmer(t *testing.T) {
+	messages := longConversation()
+
+	trimmer, err := NewContextTrimmer(ContextStrategySlidingWindow, 10)
+	require.NoError(t, err)
+
+	// 预算足够时只按窗口裁剪
+	result, err := trimmer.Trim(context.Background(), "gpt-4", messages, 100000)
+	require.NoError(t, err)
+	assert.Len(t, result.Messages, 11)
+	assert.Equal(t, 40, result.Trimmed)
+	assert.Equal(t, "system", result.Messages[0].Role)
+	assert.Equal(t, messages[41:], result.Messages[1:])
+
+	// 窗口内仍超出预算时继续按 Token 裁剪
+	result, err = trimmer.Trim(context.Background(), "gpt-4", messages, 500)
+	require.NoError(t, err)
+	assert.Less(t, len(result.Messages), 11)
+	assert.Equal(t, len(messages), len(result.Messages)+result.Trimmed)
+	assert.Equal(t, messages[len(messages)-1], result.Messages[len(result.Messages)-1])
+
+	_, err = NewContextTrimmer("summary", 0)
+	assert.Error(t, err)
+	_, err = NewContextTrimmer(ContextStrategySlidingWindow, 0)
+	assert.Error(t, err)
+}
+
+func TestChatServiceTrimContextUsesChannelWindow(t *testing.T) {
+	relayService := NewRelayService()
+	require.NoError(t, relayService.AbilityManager().RegisterAbility("1", &relay.ChannelAbilityVersion{
+		Version:         "v1",
+		SupportedModels: []string{"gpt-4"},
+		Features: map[relay.ChannelAbilityFeature]relay.FeatureConfig{
+			relay.FeatureContextWindow: {Supported: true, Limits: map[string]interface{}{relay.ContextWindowLimitKey: 4096}},
+		},
+	}))
+
+	s := &ChatService{relayService: relayService}
+	session := &model.Session{Model: "gpt-4"}
+	messages := longConversation()
+
+	// 未启用裁剪
+	kept, trimmed := s.trimContext(context.Background(), session, messages)
+	assert.Equal(t, messages, kept)
+	assert.Zero(t, trimmed)
+
+	s.SetContextTrimming(&TokenBudgetTrimmer{}, 0, 1024)
+	kept, trimmed = s.trimContext(context.Background(), session, messages)
+	assert.Greater(t, trimmed, 0)
+	assert.LessOrEqual(t, tokenizer.CountMessages("gpt-4", toTokenizerMessages(kept)), 4096-1024)
+
+	// 会话的 max_tokens 优先于默认预留
+	maxTokens := 3000
+	session.MaxTokens = &maxTokens
+	_, trimmedMore := s.trimContext(context.Background(), session, messages)
+	assert.Greater(t, trimmedMore, trimmed)
+
+	// 没有渠道声明窗口且没有默认窗口的模型不裁剪
+	kept, trimmed = s.trimContext(context.Background(), &model.Session{Model: "claude-3"}, messages)
+	assert.Equal(t, messages, kept)
+	assert.Zero(t, trimmed)
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+var (
+	// ErrEmailNotVerified 账号邮箱尚未验证，不能登录
+	ErrEmailNotVerified = utils.NewAppError(utils.CodeEmailNotVerified, "email address is not verified")
+	// ErrVerificationTokenInvalid 验证链接无效、已过期或已被使用
+	ErrVerificationTokenInvalid = utils.NewAppError(utils.CodeInvalidToken, "invalid or expired verification token")
+	// ErrVerificationThrottled 同一邮箱重发验证邮件过于频繁
+	ErrVerificationThrottled = utils.NewAppError(utils.CodeRateLimited, "verification email was sent recently, please try again later")
+)
+
+// Redis 键前缀
+const (
+	emailVerifyTokenPrefix  = "email_verify:token:"
+	emailVerifyResendPrefix = "email_verify:resend:"
+)
+
+// EmailVerificationConfig 邮箱验证参数
+type EmailVerificationConfig struct {
+	TokenTTL       time.Duration // 验证令牌的有效期
+	ResendInterval time.Duration // 同一邮箱重发验证邮件的最短间隔
+	PublicURL      string        // 验证链接的对外地址前缀
+}
+
+// NewEmailVerificationConfig 从配置文件创建邮箱验证参数
+func NewEmailVerificationConfig(cfg *config.EmailVerificationConfig) EmailVerificationConfig {
+	return EmailVerificationConfig{
+		TokenTTL:       time.Duration(cfg.TokenTTLMinutes) * time.Minute,
+		ResendInterval: time.Duration(cfg.ResendIntervalSeconds) * time.Second,
+		PublicURL:      cfg.PublicURL,
+	}
+}
+
+// ResendVerificationRequest 重发验证邮件请求
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// VerificationStore 保存一次性验证令牌与重发节流标记，生产环境使用 Redis
+type VerificationStore interface {
+	// Put 保存令牌对应的用户，ttl 后过期
+	Put(ctx context.Context, key string, userID int, ttl time.Duration) error
+	// Take 取出并删除令牌，不存在或已过期时 ok 为 false
+	Take(ctx context.Context, key string) (userID int, ok bool, err error)
+	// Acquire 在 ttl 内对同一 key 只成功一次
+	Acquire(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// VerificationUsers 邮箱验证依赖的用户仓储
+type VerificationUsers interface {
+	FindByID(ctx context.Context, id int) (*model.User, error)
+	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	Update(ctx context.Context, user *model.User) error
+}
+
+// VerificationMailer 发送验证邮件，可替换为第三方邮件服务
+type VerificationMailer interface {
+	SendVerification(ctx context.Context, user *model.User, link string) error
+}
+
+// EmailVerificationService 注册邮箱验证：签发一次性验证令牌、激活账号、按邮箱限制重发频率
+//
+// 令牌为随机 ID 加 HMAC 签名，只有 ID 存入 Redis，伪造的令牌在查询 Redis 前即被拒绝。
+type EmailVerificationService struct {
+	users  VerificationUsers
+	store  VerificationStore
+	mailer VerificationMailer
+	secret []byte
+	config EmailVerificationConfig
+}
+
+// NewEmailVerificationService 创建邮箱验证服务，secret 用于签名验证令牌
+func NewEmailVerificationService(users VerificationUsers, store VerificationStore, mailer VerificationMailer, secret []byte, config EmailVerificationConfig) *EmailVerificationService {
+	return &EmailVerificationService{users: users, store: store, mailer: mailer, secret: secret, config: config}
+}
+
+// Send 为待验证用户签发令牌并发送验证邮件，同时开始该邮箱的重发间隔
+func (s *EmailVerificationService) Send(ctx context.Context, user *model.User) error {
+	if _, err := s.store.Acquire(ctx, s.resendKey(user.Email), s.config.ResendInterval); err != nil {
+		return err
+	}
+	return s.send(ctx, user)
+}
+
+// Resend 重新发送验证邮件，同一邮箱在重发间隔内只发送一次
+//
+// 邮箱不存在或已验证时同样返回成功，避免通过该接口探测注册邮箱。
+func (s *EmailVerificationService) Resend(ctx context.Context, email string) error {
+	acquired, err := s.store.Acquire(ctx, s.resendKey(email), s.config.ResendInterval)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		return ErrVerificationThrottled
+	}
+
+	user, err := s.users.FindByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Status != model.UserStatusPending {
+		return nil
+	}
+	return s.send(ctx, user)
+}
+
+// Verify 校验令牌并激活账号，令牌使用一次后即失效
+func (s *EmailVerificationService) Verify(ctx context.Context, token string) (*model.User, error) {
+	id, ok := s.parseToken(token)
+	if !ok {
+		return nil, ErrVerificationTokenInvalid
+	}
+	userID, ok, err := s.store.Take(ctx, emailVerifyTokenPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrVerificationTokenInvalid
+	}
+	// 已激活或已被禁用的账号不再修改状态
+	if user.Status == model.UserStatusPending {
+		user.Status = model.UserStatusActive
+		if err := s.users.Update(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// send 签发令牌并发送验证邮件
+func (s *EmailVerificationService) send(ctx context.Context, user *model.User) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	tokenID := hex.EncodeToString(id)
+	if err := s.store.Put(ctx, emailVerifyTokenPrefix+tokenID, user.ID, s.config.TokenTTL); err != nil {
+		return err
+	}
+	return s.mailer.SendVerification(ctx, user, s.link(tokenID+"."+s.sign(tokenID)))
+}
+
+// link 验证链接
+func (s *EmailVerificationService) link(token string) string {
+	return strings.TrimRight(s.config.PublicURL, "/") + "/api/v1/verify-email?token=" + url.QueryEscape(token)
+}
+
+// sign 令牌 ID 的 HMAC-SHA256 签名
+func (s *EmailVerificationService) sign(id string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseToken 校验令牌签名，返回令牌 ID
+func (s *EmailVerificationService) parseToken(token string) (string, bool) {
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok || id == "" || !hmac.Equal([]byte(sig), []byte(s.sign(id))) {
+		return "", false
+	}
+	return id, true
+}
+
+// resendKey 重发节流的键，邮箱不区分大小写
+func (s *EmailVerificationService) resendKey(email string) string {
+	return emailVerifyResendPrefix + strings.ToLower(strings.TrimSpace(email))
+}
+
+// RedisVerificationStore 基于 Redis 的验证令牌存储，过期由 Redis TTL 处理
+type RedisVerificationStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisVerificationStore 创建 Redis 验证令牌存储
+func NewRedisVerificationStore(client redis.UniversalClient) *RedisVerificationStore {
+	return &RedisVerificationStore{client: client}
+}
+
+// Put 保存令牌
+func (r *RedisVerificationStore) Put(ctx context.Context, key string, userID int, ttl time.Duration) error {
+	return r.client.Set(ctx, key, userID, ttl).Err()
+}
+
+// Take 在同一事务中读取并删除令牌，并发验证时只有一个请求能取到
+func (r *RedisVerificationStore) Take(ctx context.Context, key string) (int, bool, error) {
+	pipe := r.client.TxPipeline()
+	get := pipe.Get(ctx, key)
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return 0, false, err
+	}
+	value, err := get.Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	userID, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid verification token value %q", value)
+	}
+	return userID, true, nil
+}
+
+// Acquire 使用 SET NX 设置节流标记
+func (r *RedisVerificationStore) Acquire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, 1, ttl).Result()
+}
+
+// SMTPVerificationMailer 通过 SMTP 发送纯文本验证邮件
+type SMTPVerificationMailer struct {
+	sender billing.MailSender
+	from   string
+}
+
+// NewSMTPVerificationMailer 创建 SMTP 验证邮件发送器
+func NewSMTPVerificationMailer(sender billing.MailSender, from string) *SMTPVerificationMailer {
+	return &SMTPVerificationMailer{sender: sender, from: from}
+}
+
+// SendVerification 发送验证邮件
+func (m *SMTPVerificationMailer) SendVerification(ctx context.Context, user *model.User, link string) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\nTo: %s\r\n", m.from, user.Email)
+	buf.WriteString("Subject: Verify your email address\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&buf, "Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nIf you did not create an account, you can ignore this email.\n", user.Username, link)
+
+	// net/smtp 不支持 context，超时前返回避免阻塞注册请求
+	done := make(chan error, 1)
+	go func() {
+		done <- m.sender.SendMail(m.from, []string{user.Email}, buf.Bytes())
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryVerificationStore 内存中的验证令牌存储，过期时间按 now 判断
+type memoryVerificationStore struct {
+	now     time.Time
+	tokens  map[string]int
+	expires map[string]time.Time
+}
+
+func newMemoryVerificationStore() *memoryVerificationStore {
+	return &memoryVerificationStore{now: time.Now(), tokens: map[string]int{}, expires: map[string]time.Time{}}
+}
+
+func (m *memoryVerificationStore) live(key string) bool {
+	expiry, ok := m.expires[key]
+	return ok && m.now.Before(expiry)
+}
+
+func (m *memoryVerificationStore) Put(ctx context.Context, key string, userID int, ttl time.Duration) error {
+	m.tokens[key], m.expires[key] = userID, m.now.Add(ttl)
+	return nil
+}
+
+func (m *memoryVerificationStore) Take(ctx context.Context, key string) (int, bool, error) {
+	userID, live := m.tokens[key], m.live(key)
+	delete(m.tokens, key)
+	delete(m.expires, key)
+	return userID, live, nil
+}
+
+func (m *memoryVerificationStore) Acquire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if m.live(key) {
+		return false, nil
+	}
+	m.expires[key] = m.now.Add(ttl)
+	return true, nil
+}
+
+// memoryVerificationUsers 内存中的用户仓储
+type memoryVerificationUsers struct {
+	users []*model.User
+}
+
+func (m *memoryVerificationUsers) FindByID(ctx context.Context, id int) (*model.User, error) {
+	for _, u := range m.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryVerificationUsers) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryVerificationUsers) Update(ctx context.Context, user *model.User) error {
+	return nil
+}
+
+// recordingMailer 记录发送的验证链接
+type recordingMailer struct {
+	links []string
+}
+
+func (m *recordingMailer) SendVerification(ctx context.Context, user *model.User, link string) error {
+	m.links = append(m.links, link)
+	return nil
+}
+
+// token 最近一封验证邮件中的令牌
+func (m *recordingMailer) token(t *testing.T) string {
+	require.NotEmpty(t, m.links)
+	parsed, err := url.Parse(m.links[len(m.links)-1])
+	require.NoError(t, err)
+	return parsed.Query().Get("token")
+}
+
+func setupEmailVerification(t *testing.T) (*EmailVerificationService, *memoryVerificationStore, *recordingMailer, *model.User) {
+	store := newMemoryVerificationStore()
+	mailer := &recordingMailer{}
+	user := &model.User{ID: 5, Username: "alice", Email: "alice@example.com", Status: model.UserStatusPending}
+	s := NewEmailVerificationService(&memoryVerificationUsers{users: []*model.User{user}}, store, mailer, []byte("secret"),
+		EmailVerificationConfig{TokenTTL: time.Hour, ResendInterval: time.Minute, PublicURL: "https://app.example.com/"})
+	return s, store, mailer, user
+}
+
+func TestEmailVerificationActivatesOnce(t *testing.T) {
+	s, _, mailer, user := setupEmailVerification(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.Send(ctx, user))
+	require.Len(t, mailer.links, 1)
+	assert.True(t, strings.HasPrefix(mailer.links[0], "https://app.example.com/api/v1/verify-email?token="))
+
+	verified, err := s.Verify(ctx, mailer.token(t))
+	require.NoError(t, err)
+	assert.Equal(t, 5, verified.ID)
+	assert.Equal(t, model.UserStatusActive, user.Status)
+
+	// 已使用的令牌不能再次验证
+	_, err = s.Verify(ctx, mailer.token(t))
+	assert.ErrorIs(t, err, ErrVerificationTokenInvalid)
+}
+
+func TestEmailVerificationRejectsExpiredAndForgedTokens(t *testing.T) {
+	s, store, mailer, user := setupEmailVerification(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.Send(ctx, user))
+	token := mailer.token(t)
+
+	id, _, _ := strings.Cut(token, ".")
+	_, err := s.Verify(ctx, id+".deadbeef")
+	assert.ErrorIs(t, err, ErrVerificationTokenInvalid, "a token with a wrong signature is rejected")
+	_, err = s.Verify(ctx, "")
+	assert.ErrorIs(t, err, ErrVerificationTokenInvalid)
+
+	store.now = store.now.Add(time.Hour + time.Second)
+	_, err = s.Verify(ctx, token)
+	assert.ErrorIs(t, err, ErrVerificationTokenInvalid)
+	assert.Equal(t, model.UserStatusPending, user.Status)
+}
+
+func TestEmailVerificationResendThrottledPerAddress(t *testing.T) {
+	s, store, mailer, user := setupEmailVerification(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.Send(ctx, user))
+	assert.ErrorIs(t, s.Resend(ctx, "Alice@Example.com"), ErrVerificationThrottled, "registration starts the resend interval")
+
+	// 其它邮箱不受影响，未注册的邮箱不发送邮件也不报错
+	require.NoError(t, s.Resend(ctx, "nobody@example.com"))
+	assert.Len(t, mailer.links, 1)
+
+	store.now = store.now.Add(time.Minute)
+	require.NoError(t, s.Resend(ctx, "alice@example.com"))
+	require.Len(t, mailer.links, 2)
+	assert.ErrorIs(t, s.Resend(ctx, "alice@example.com"), ErrVerificationThrottled)
+
+	// 验证后不再重发
+	_, err := s.Verify(ctx, mailer.token(t))
+	require.NoError(t, err)
+	store.now = store.now.Add(time.Minute)
+	require.NoError(t, s.Resend(ctx, "alice@example.com"))
+	assert.Len(t, mailer.links, 2)
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/storage"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrFileNotFound       = errors.New("file not found")
+	ErrFileForbidden      = errors.New("permission denied")
+	ErrFileTooLarge       = errors.New("file too large")
+	ErrFileTypeNotAllowed = errors.New("file type not allowed")
+)
+
+// DefaultMaxUploadSize 未配置时单个文件的最大大小
+const DefaultMaxUploadSize = 20 << 20
+
+// DefaultAllowedMIMETypes 未配置时允许上传的 MIME 类型
+var DefaultAllowedMIMETypes = []string{
+	"image/*", "application/pdf", "text/plain", "text/markdown", "text/csv", "application/json",
+}
+
+// FileRecordStore 文件元数据的持久化（由 repository.FileRepository 实现）
+type FileRecordStore interface {
+	Create(ctx context.Context, file *model.File) error
+	FindByID(ctx context.Context, id uuid.UUID) (*model.File, error)
+	FindByUserAndHash(ctx context.Context, userID int, hash string) (*model.File, error)
+	FindByUserID(ctx context.Context, userID int, page, pageSize int) ([]*model.File, int64, error)
+}
+
+// UploadResult 上传结果，Duplicate 表示用户之前已上传过相同内容
+type UploadResult struct {
+	*model.File
+	Duplicate bool `json:"duplicate"`
+}
+
+// FileService 文件上传与下载
+//
+// 文件内容按 SHA-256 保存在对象存储中，不同用户上传的相同内容只保存一份。
+type FileService struct {
+	records FileRecordStore
+	objects storage.ObjectStore
+	maxSize int64
+	allowed []string
+}
+
+// NewFileService 创建文件服务，maxSize 不大于 0 或 allowed 为空时使用默认值
+func NewFileService(records FileRecordStore, objects storage.ObjectStore, maxSize int64, allowed []string) *FileService {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxUploadSize
+	}
+	if len(allowed) == 0 {
+		allowed = DefaultAllowedMIMETypes
+	}
+	return &FileService{records: records, objects: objects, maxSize: maxSize, allowed: allowed}
+}
+
+// MaxUploadSize 单个文件的最大大小
+func (s *FileService) MaxUploadSize() int64 {
+	return s.maxSize
+}
+
+// Upload 保存上传的文件并记录元数据
+func (s *FileService) Upload(ctx context.Context, userID int, header *multipart.FileHeader) (*UploadResult, error) {
+	if header.Size > s.maxSize {
+		return nil, ErrFileTooLarge
+	}
+
+	hash, size, sniffed, err := s.digest(header)
+	if err != nil {
+		return nil, err
+	}
+
+	mimeType := detectMIMEType(sniffed, header.Filename)
+	if !s.mimeAllowed(mimeType) {
+		return nil, fmt.Errorf("%w: %s", ErrFileTypeNotAllowed, mimeType)
+	}
+
+	if existing, err := s.records.FindByUserAndHash(ctx, userID, hash); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return &UploadResult{File: existing, Duplicate: true}, nil
+	}
+
+	key := fileStorageKey(hash)
+	if err := s.store(ctx, key, header); err != nil {
+		logger.Error("Failed to store file", zap.String("key", key), zap.Error(err))
+		return nil, err
+	}
+
+	file := &model.File{
+		UserID:     userID,
+		Name:       sanitizeFileName(header.Filename),
+		Size:       size,
+		MimeType:   mimeType,
+		Hash:       hash,
+		StorageKey: key,
+	}
+	if err := s.records.Create(ctx, file); err != nil {
+		// 同一用户并发上传相同内容时唯一索引冲突，返回先写入的记录
+		if existing, findErr := s.records.FindByUserAndHash(ctx, userID, hash); findErr == nil && existing != nil {
+			return &UploadResult{File: existing, Duplicate: true}, nil
+		}
+		return nil, err
+	}
+
+	return &UploadResult{File: file}, nil
+}
+
+// Open 打开用户自己的文件，调用方负责关闭返回的 Reader
+func (s *FileService) Open(ctx context.Context, userID int, id uuid.UUID) (*model.File, io.ReadCloser, error) {
+	file, err := s.records.FindByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if file == nil {
+		return nil, nil, ErrFileNotFound
+	}
+	if file.UserID != userID {
+		return nil, nil, ErrFileForbidden
+	}
+
+	r, err := s.objects.Get(ctx, file.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			logger.Error("File content missing", zap.String("file_id", id.String()), zap.String("key", file.StorageKey))
+			return nil, nil, ErrFileNotFound
+		}
+		return nil, nil, err
+	}
+	return file, r, nil
+}
+
+// ListFiles 分页获取用户的文件
+func (s *FileService) ListFiles(ctx context.Context, userID int, page, pageSize int) ([]*model.File, int64, error) {
+	return s.records.FindByUserID(ctx, userID, page, pageSize)
+}
+
+// digest 计算文件的 SHA-256 与大小，并返回用于类型检测的前 512 字节
+func (s *FileService) digest(header *multipart.FileHeader) (string, int64, []byte, error) {
+	f, err := header.Open()
+	if err != nil {
+		return "", 0, nil, err
+	}
+	defer f.Close()
+
+	sniffed := make([]byte, 512)
+	n, err := io.ReadFull(f, sniffed)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", 0, nil, err
+	}
+	sniffed = sniffed[:n]
+
+	hash := sha256.New()
+	hash.Write(sniffed)
+	rest, err := io.Copy(hash, io.LimitReader(f, s.maxSize-int64(n)+1))
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	size := int64(n) + rest
+	if size > s.maxSize {
+		return "", 0, nil, ErrFileTooLarge
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, sniffed, nil
+}
+
+// store 将文件内容写入对象存储，相同内容已存在时跳过
+func (s *FileService) store(ctx context.Context, key string, header *multipart.FileHeader) error {
+	exists, err := s.objects.Exists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = s.objects.Put(ctx, key, f)
+	return err
+}
+
+// mimeAllowed 检查 MIME 类型是否在允许列表中，支持 image/* 形式的通配
+func (s *FileService) mimeAllowed(mimeType string) bool {
+	for _, allowed := range s.allowed {
+		if allowed == mimeType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mimeType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// fileStorageKey 按内容哈希生成对象键
+func fileStorageKey(hash string) string {
+	return "files/" + hash[:2] + "/" + hash
+}
+
+// textMIMETypes 内容检测为纯文本时按扩展名细分的类型
+var textMIMETypes = map[string]string{
+	".md":       "text/markdown",
+	".markdown": "text/markdown",
+	".csv":      "text/csv",
+	".json":     "application/json",
+}
+
+// detectMIMEType 根据内容判断 MIME 类型
+//
+// 内容检测只能识别出纯文本时，按扩展名细分为 markdown、csv、json；
+// 扩展名不能改变二进制内容的类型，避免把其他文件伪装成文本。
+func detectMIMEType(sniffed []byte, name string) string {
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(sniffed))
+	if detected != "text/plain" {
+		return detected
+	}
+	if byExt, ok := textMIMETypes[strings.ToLower(filepath.Ext(name))]; ok {
+		return byExt
+	}
+	return detected
+}
+
+// sanitizeFileName 去掉路径部分并限制长度
+func sanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		name = "file"
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// HealthCheckService 渠道健康检查服务接口
+type HealthCheckService interface {
+	// CheckChannel 立即检查指定渠道
+	CheckChannel(ctx context.Context, channelID int) (*HealthCheckResult, error)
+	// GetHealthStatus 获取渠道健康状态
+	GetHealthStatus(ctx context.Context, channelID int) (*HealthStatus, error)
+	// CalculateHealthScore 计算渠道健康评分
+	CalculateHealthScore(ctx context.Context, channelID int) (*HealthScore, error)
+}
+
+// HealthCheckResult 单次健康检查结果
+type HealthCheckResult struct {
+	ChannelID int       `json:"channel_id"`
+	Healthy   bool      `json:"healthy"`
+	LatencyMs int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// HealthStatus 渠道健康状态
+type HealthStatus struct {
+	ChannelID           int                `json:"channel_id"`
+	Healthy             bool               `json:"healthy"`
+	TotalChecks         int64              `json:"total_checks"`
+	FailedChecks        int64              `json:"failed_checks"`
+	ConsecutiveFailures int64              `json:"consecutive_failures"`
+	LastResult          *HealthCheckResult `json:"last_result,omitempty"`
+}
+
+// HealthScore 渠道健康评分（0-100）
+type HealthScore struct {
+	ChannelID    int     `json:"channel_id"`
+	Score        float64 `json:"score"`
+	SuccessRate  float64 `json:"success_rate"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
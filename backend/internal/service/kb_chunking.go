@@ -0,0 +1,322 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tokenizer"
+)
+
+// ChunkStrategy 文档分块策略
+type ChunkStrategy string
+
+const (
+	ChunkStrategyFixed     ChunkStrategy = "fixed"     // 按词切成固定 Token 数的窗口
+	ChunkStrategySentence  ChunkStrategy = "sentence"  // 按句子合并，块边界总在句末
+	ChunkStrategyRecursive ChunkStrategy = "recursive" // 依次按空行、换行、句子、词切分，适合代码与表格
+)
+
+// ChunkStrategies 可选的分块策略
+var ChunkStrategies = []ChunkStrategy{ChunkStrategyFixed, ChunkStrategySentence, ChunkStrategyRecursive}
+
+// 知识库未指定时的分块配置
+const (
+	defaultChunkSize    = 512
+	defaultChunkOverlap = 50
+)
+
+// ErrInvalidChunkingConfig 分块配置不合法
+var ErrInvalidChunkingConfig = errors.New("invalid chunking config")
+
+// ChunkingConfig 分块配置，大小与重叠都按 Token 计
+type ChunkingConfig struct {
+	Strategy     ChunkStrategy `json:"strategy"`
+	ChunkSize    int           `json:"chunk_size"`
+	ChunkOverlap int           `json:"chunk_overlap"`
+}
+
+// Validate 检查策略是否可用、重叠是否小于块大小
+func (c ChunkingConfig) Validate() error {
+	valid := false
+	for _, strategy := range ChunkStrategies {
+		if c.Strategy == strategy {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("%w: strategy %q must be one of fixed, sentence, recursive", ErrInvalidChunkingConfig, c.Strategy)
+	}
+	if c.ChunkSize <= 0 {
+		return fmt.Errorf("%w: chunk_size must be positive", ErrInvalidChunkingConfig)
+	}
+	if c.ChunkOverlap < 0 || c.ChunkOverlap >= c.ChunkSize {
+		return fmt.Errorf("%w: chunk_overlap must be between 0 and chunk_size", ErrInvalidChunkingConfig)
+	}
+	return nil
+}
+
+// ChunkingOverride 上传时对知识库分块配置的覆盖，未设置的字段沿用知识库配置
+type ChunkingOverride struct {
+	Strategy     ChunkStrategy `json:"strategy"`
+	ChunkSize    *int          `json:"chunk_size"`
+	ChunkOverlap *int          `json:"chunk_overlap"`
+}
+
+// Apply 在知识库配置上应用覆盖并校验结果，override 为 nil 时返回原配置
+func (o *ChunkingOverride) Apply(base ChunkingConfig) (ChunkingConfig, error) {
+	config := base
+	if o != nil {
+		if o.Strategy != "" {
+			config.Strategy = o.Strategy
+		}
+		if o.ChunkSize != nil {
+			config.ChunkSize = *o.ChunkSize
+		}
+		if o.ChunkOverlap != nil {
+			config.ChunkOverlap = *o.ChunkOverlap
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return ChunkingConfig{}, err
+	}
+	return config, nil
+}
+
+// KnowledgeBaseChunking 知识库生效的分块配置，早于分块策略创建的知识库按 fixed 处理
+func KnowledgeBaseChunking(kb *model.KnowledgeBase) ChunkingConfig {
+	config := ChunkingConfig{
+		Strategy:     ChunkStrategy(kb.ChunkStrategy),
+		ChunkSize:    kb.ChunkSize,
+		ChunkOverlap: kb.ChunkOverlap,
+	}
+	if config.Strategy == "" {
+		config.Strategy = ChunkStrategyFixed
+	}
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = defaultChunkSize
+	}
+	if config.ChunkOverlap < 0 || config.ChunkOverlap >= config.ChunkSize {
+		config.ChunkOverlap = config.ChunkSize / 2
+	}
+	return config
+}
+
+// documentChunking 文档分块时实际使用的配置
+func documentChunking(doc *model.Document) ChunkingConfig {
+	return ChunkingConfig{
+		Strategy:     ChunkStrategy(doc.ChunkStrategy),
+		ChunkSize:    doc.ChunkSize,
+		ChunkOverlap: doc.ChunkOverlap,
+	}
+}
+
+// setDocumentChunking 记录文档使用的分块配置
+func setDocumentChunking(doc *model.Document, config ChunkingConfig) {
+	doc.ChunkStrategy = string(config.Strategy)
+	doc.ChunkSize = config.ChunkSize
+	doc.ChunkOverlap = config.ChunkOverlap
+}
+
+// KnowledgeBaseDetail 知识库详情，附带生效的分块配置
+type KnowledgeBaseDetail struct {
+	*model.KnowledgeBase
+	Chunking ChunkingConfig `json:"chunking"`
+}
+
+// textChunker 按配置把文本切分为不超过 ChunkSize 个 Token 的块，相邻块重叠不超过 ChunkOverlap 个 Token
+//
+// 文本先切成带前导空白的片段（词、句子或段落），按片段累计 Token 数，块边界总落在片段之间。
+type textChunker struct {
+	config ChunkingConfig
+	count  func(text string) int
+}
+
+// newTextChunker 使用 Embedding 模型的分词器计数的分块器
+func newTextChunker(config ChunkingConfig, embeddingModel string) *textChunker {
+	return &textChunker{
+		config: config,
+		count: func(text string) int {
+			return tokenizer.CountText(embeddingModel, text)
+		},
+	}
+}
+
+// textPiece 分块的最小单位及其 Token 数
+type textPiece struct {
+	text   string
+	tokens int
+}
+
+// Chunk 切分文本，去掉块首尾空白后为空的块会被丢弃
+func (c *textChunker) Chunk(text string) []string {
+	var pieces []textPiece
+	switch c.config.Strategy {
+	case ChunkStrategySentence:
+		for _, sentence := range splitSentences(text) {
+			pieces = append(pieces, c.fit(sentence, nil)...)
+		}
+	case ChunkStrategyRecursive:
+		pieces = c.fit(text, []func(string) []string{
+			func(s string) []string { return strings.SplitAfter(s, "\n\n") },
+			func(s string) []string { return strings.SplitAfter(s, "\n") },
+			splitSentences,
+		})
+	default:
+		pieces = c.words(text)
+	}
+	return c.pack(pieces)
+}
+
+// fit 依次用 splitters 切分超过块大小的文本，仍然过大的部分按词切分
+func (c *textChunker) fit(text string, splitters []func(string) []string) []textPiece {
+	if text == "" {
+		return nil
+	}
+	if tokens := c.count(text); tokens <= c.config.ChunkSize {
+		return []textPiece{{text: text, tokens: tokens}}
+	}
+	if len(splitters) == 0 {
+		return c.words(text)
+	}
+
+	var pieces []textPiece
+	for _, part := range splitters[0](text) {
+		pieces = append(pieces, c.fit(part, splitters[1:])...)
+	}
+	return pieces
+}
+
+// words 按词切分，汉字等没有空格分隔的文字逐字切分，超过块大小的词再按字符切开
+func (c *textChunker) words(text string) []textPiece {
+	var pieces []textPiece
+	for _, word := range splitWords(text) {
+		tokens := c.count(word)
+		if tokens <= c.config.ChunkSize {
+			pieces = append(pieces, textPiece{text: word, tokens: tokens})
+			continue
+		}
+		pieces = append(pieces, c.splitLongWord(word)...)
+	}
+	return pieces
+}
+
+// splitLongWord 把单个过长的词切成若干段，每段取不超过块大小的最长前缀
+func (c *textChunker) splitLongWord(word string) []textPiece {
+	var pieces []textPiece
+	runes := []rune(word)
+	for len(runes) > 0 {
+		lo, hi := 1, len(runes)
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if c.count(string(runes[:mid])) <= c.config.ChunkSize {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		part := string(runes[:lo])
+		pieces = append(pieces, textPiece{text: part, tokens: c.count(part)})
+		runes = runes[lo:]
+	}
+	return pieces
+}
+
+// pack 把片段合并为块，下一块从当前块末尾不超过重叠大小的片段开始
+func (c *textChunker) pack(pieces []textPiece) []string {
+	var chunks []string
+	for start := 0; start < len(pieces); {
+		end, tokens := start, 0
+		for end < len(pieces) && (end == start || tokens+pieces[end].tokens <= c.config.ChunkSize) {
+			tokens += pieces[end].tokens
+			end++
+		}
+
+		var b strings.Builder
+		for _, piece := range pieces[start:end] {
+			b.WriteString(piece.text)
+		}
+		if chunk := strings.TrimSpace(b.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(pieces) {
+			break
+		}
+
+		// 重叠部分至少留出一个新片段，保证向前推进
+		next, overlap := end, 0
+		for next > start+1 && overlap+pieces[next-1].tokens <= c.config.ChunkOverlap {
+			next--
+			overlap += pieces[next].tokens
+		}
+		start = next
+	}
+	return chunks
+}
+
+// splitWords 按空白切分，空白保留在后一个词的开头；汉字、假名等逐字成词
+func splitWords(text string) []string {
+	var words []string
+	start := 0
+	inWord := false
+	for i, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, text[start:i])
+				start = i
+				inWord = false
+			}
+		case isLogogram(r):
+			if inWord {
+				words = append(words, text[start:i])
+				start = i
+			}
+			end := i + utf8.RuneLen(r)
+			words = append(words, text[start:end])
+			start = end
+			inWord = false
+		default:
+			inWord = true
+		}
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+	return words
+}
+
+// isLogogram 没有空格分词的文字
+func isLogogram(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// splitSentences 在句末标点与换行之后切分，英文句点后需跟空白才算句末（避免切开小数与缩写）
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	runes := []rune(text)
+	offset := 0
+	for i, r := range runes {
+		offset += utf8.RuneLen(r)
+		end := false
+		switch r {
+		case '。', '！', '？', '\n':
+			end = true
+		case '.', '!', '?':
+			end = i+1 == len(runes) || unicode.IsSpace(runes[i+1])
+		}
+		if end {
+			sentences = append(sentences, text[start:offset])
+			start = offset
+		}
+	}
+	if start < len(text) {
+		sentences = append(sentences, text[start:])
+	}
+	return sentences
+}
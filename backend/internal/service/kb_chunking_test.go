@@ -0,0 +1,111 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wordChunker 每个空白分隔的词计 1 个 Token 的分块器，结果不依赖 BPE 词表
+func wordChunker(strategy ChunkStrategy, size, overlap int) *textChunker {
+	return &textChunker{
+		config: ChunkingConfig{Strategy: strategy, ChunkSize: size, ChunkOverlap: overlap},
+		count:  func(text string) int { return len(strings.Fields(text)) },
+	}
+}
+
+// numberedWords w1 w2 ... wn
+func numberedWords(n int) string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%d", i+1)
+	}
+	return strings.Join(words, " ")
+}
+
+func TestFixedChunkingOverlap(t *testing.T) {
+	chunks := wordChunker(ChunkStrategyFixed, 8, 3).Chunk(numberedWords(20))
+
+	// 每块 8 个词，下一块重复上一块的最后 3 个词
+	assert.Equal(t, []string{
+		"w1 w2 w3 w4 w5 w6 w7 w8",
+		"w6 w7 w8 w9 w10 w11 w12 w13",
+		"w11 w12 w13 w14 w15 w16 w17 w18",
+		"w16 w17 w18 w19 w20",
+	}, chunks)
+
+	// 没有重叠时相邻块首尾相接
+	chunks = wordChunker(ChunkStrategyFixed, 8, 0).Chunk(numberedWords(20))
+	assert.Equal(t, []string{
+		"w1 w2 w3 w4 w5 w6 w7 w8",
+		"w9 w10 w11 w12 w13 w14 w15 w16",
+		"w17 w18 w19 w20",
+	}, chunks)
+}
+
+func TestSentenceChunkingBoundaries(t *testing.T) {
+	text := "Alpha beta gamma. Delta epsilon. Zeta eta theta iota. Kappa."
+	chunks := wordChunker(ChunkStrategySentence, 6, 2).Chunk(text)
+
+	// 块边界总在句末；重叠只保留完整的句子，第三句超过重叠大小，不重复
+	assert.Equal(t, []string{
+		"Alpha beta gamma. Delta epsilon.",
+		"Delta epsilon. Zeta eta theta iota.",
+		"Kappa.",
+	}, chunks)
+
+	// 小数与中文句号
+	chunks = wordChunker(ChunkStrategySentence, 4, 0).Chunk("Pi is 3.14 roughly. 第一句。第二句。")
+	assert.Equal(t, []string{"Pi is 3.14 roughly.", "第一句。第二句。"}, chunks)
+
+	// 超过块大小的句子按词切开
+	chunks = wordChunker(ChunkStrategySentence, 3, 0).Chunk("one two three four five. six.")
+	assert.Equal(t, []string{"one two three", "four five. six."}, chunks)
+}
+
+func TestRecursiveChunkingKeepsBlocks(t *testing.T) {
+	code := "func a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 2\n}\n\nfunc c() {\n\tx := 3\n\treturn x\n}\n"
+	chunks := wordChunker(ChunkStrategyRecursive, 9, 0).Chunk(code)
+
+	// 按空行切分后每个函数完整地落在一个块中
+	require.Len(t, chunks, 3)
+	for i, name := range []string{"a", "b", "c"} {
+		assert.True(t, strings.HasPrefix(chunks[i], "func "+name+"() {"), chunks[i])
+		assert.True(t, strings.HasSuffix(chunks[i], "}"), chunks[i])
+	}
+
+	// 单个块放不下时退到按行切分，行不会被切开
+	chunks = wordChunker(ChunkStrategyRecursive, 3, 0).Chunk("a b\nc d\ne f g\n")
+	assert.Equal(t, []string{"a b", "c d", "e f g"}, chunks)
+}
+
+func TestSplitWordsLogograms(t *testing.T) {
+	assert.Equal(t, []string{"你", "好", " world，", "世", "界"}, splitWords("你好 world，世界"))
+	assert.Equal(t, []string{"  lead", " trailing", " "}, splitWords("  lead trailing "))
+}
+
+func TestChunkingConfig(t *testing.T) {
+	kb := &model.KnowledgeBase{ChunkSize: 256, ChunkOverlap: 32}
+	base := KnowledgeBaseChunking(kb)
+	assert.Equal(t, ChunkingConfig{Strategy: ChunkStrategyFixed, ChunkSize: 256, ChunkOverlap: 32}, base)
+
+	size, overlap := 100, 0
+	config, err := (&ChunkingOverride{Strategy: ChunkStrategyRecursive, ChunkSize: &size, ChunkOverlap: &overlap}).Apply(base)
+	require.NoError(t, err)
+	assert.Equal(t, ChunkingConfig{Strategy: ChunkStrategyRecursive, ChunkSize: 100, ChunkOverlap: 0}, config)
+
+	// 未设置的字段沿用知识库配置
+	config, err = (*ChunkingOverride)(nil).Apply(base)
+	require.NoError(t, err)
+	assert.Equal(t, base, config)
+
+	_, err = (&ChunkingOverride{Strategy: "semantic"}).Apply(base)
+	assert.ErrorIs(t, err, ErrInvalidChunkingConfig)
+	overlap = 256
+	_, err = (&ChunkingOverride{ChunkOverlap: &overlap}).Apply(base)
+	assert.ErrorIs(t, err, ErrInvalidChunkingConfig)
+}
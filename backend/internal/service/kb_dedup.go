@@ -0,0 +1,174 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// DuplicateAction 上传内容与知识库中已有文档完全相同时的处理方式
+type DuplicateAction string
+
+const (
+	DuplicateSkip    DuplicateAction = "skip"    // 不创建文档，返回已有文档（默认）
+	DuplicateReplace DuplicateAction = "replace" // 在已有文档 ID 下重新分块与向量化
+	DuplicateForce   DuplicateAction = "force"   // 仍然创建一份重复文档
+)
+
+// DuplicateActions 可选的重复处理方式
+var DuplicateActions = []DuplicateAction{DuplicateSkip, DuplicateReplace, DuplicateForce}
+
+// ParseDuplicateAction 解析重复处理方式，空值为 skip
+func ParseDuplicateAction(s string) (DuplicateAction, error) {
+	if s == "" {
+		return DuplicateSkip, nil
+	}
+	for _, action := range DuplicateActions {
+		if DuplicateAction(s) == action {
+			return action, nil
+		}
+	}
+	return "", fmt.Errorf("invalid on_duplicate %q: must be one of skip, replace, force", s)
+}
+
+// DuplicateDocumentError 上传内容与知识库中已有文档的摘要相同
+type DuplicateDocumentError struct {
+	Existing *model.Document
+}
+
+func (e *DuplicateDocumentError) Error() string {
+	return fmt.Sprintf("document with identical content already exists: %s (%s)", e.Existing.Title, e.Existing.ID)
+}
+
+// NearDuplicateThreshold simhash 相似度不低于该值时视为近似重复（64 位指纹最多相差 6 位）
+const NearDuplicateThreshold = 0.9
+
+// NearDuplicate 与上传内容近似重复的已有文档，只作提示，不阻止上传
+type NearDuplicate struct {
+	DocumentID uuid.UUID `json:"document_id"`
+	Title      string    `json:"title"`
+	Similarity float64   `json:"similarity"`
+}
+
+// UploadDocumentResult 上传结果，文档字段平铺在响应中
+type UploadDocumentResult struct {
+	*model.Document
+	JobID          *uuid.UUID      `json:"job_id,omitempty"` // 分块与向量化任务，解析失败时为空
+	Replaced       bool            `json:"replaced,omitempty"`
+	NearDuplicates []NearDuplicate `json:"near_duplicates,omitempty"`
+}
+
+// shingleSize simhash 使用的字符 n-gram 长度，按字符切分以兼容没有空格分词的中文
+const shingleSize = 5
+
+// NormalizeDocumentText 归一化提取后的文本：去掉 BOM 与首尾空白，连续空白折叠为一个空格
+func NormalizeDocumentText(text string) string {
+	text = strings.TrimPrefix(text, "\ufeff")
+	return strings.Join(strings.FieldsFunc(text, unicode.IsSpace), " ")
+}
+
+// ContentDigest 归一化文本的 SHA-256 摘要（十六进制）
+func ContentDigest(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// SimHash 归一化文本的 64 位 simhash 指纹，特征为小写后的字符 n-gram
+func SimHash(normalized string) uint64 {
+	runes := []rune(strings.ToLower(normalized))
+	if len(runes) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	addFeature := func(feature string) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+
+	if len(runes) <= shingleSize {
+		addFeature(string(runes))
+	} else {
+		for i := 0; i+shingleSize <= len(runes); i++ {
+			addFeature(string(runes[i : i+shingleSize]))
+		}
+	}
+
+	var fingerprint uint64
+	for i, w := range weights {
+		if w > 0 {
+			fingerprint |= 1 << uint(i)
+		}
+	}
+	return fingerprint
+}
+
+// SimHashSimilarity 两个指纹的相似度：1 - 汉明距离 / 64
+func SimHashSimilarity(a, b uint64) float64 {
+	return 1 - float64(bits.OnesCount64(a^b))/64
+}
+
+// findNearDuplicates 返回指纹相似度达到阈值的文档，跳过与上传内容摘要完全相同的文档
+func findNearDuplicates(docs []*model.Document, digest string, fingerprint uint64) []NearDuplicate {
+	var matches []NearDuplicate
+	for _, doc := range docs {
+		if doc.ContentDigest == digest {
+			continue
+		}
+		similarity := SimHashSimilarity(uint64(doc.SimHash), fingerprint)
+		if similarity >= NearDuplicateThreshold {
+			matches = append(matches, NearDuplicate{DocumentID: doc.ID, Title: doc.Title, Similarity: similarity})
+		}
+	}
+	return matches
+}
+
+// chunkAnchor 文本块的锚点：归一化内容的摘要前缀，重新分块后内容相同的块锚点相同
+func chunkAnchor(content string) string {
+	return ContentDigest(NormalizeDocumentText(content))[:16]
+}
+
+// planDocumentChunks 为分块结果构建文本块记录，locations 与 texts 一一对应（可为 nil）
+//
+// 替换已有文档时，锚点与旧文本块一致的新块沿用旧块的 ID 与创建时间，
+// 已有的引用（按 chunk_id 记录）在重新处理后仍然有效；同一锚点出现多次时按顺序一一对应。
+func planDocumentChunks(docID uuid.UUID, texts []string, locations []chunkLocation, existing []*model.DocumentChunk) []*model.DocumentChunk {
+	byAnchor := make(map[string][]*model.DocumentChunk)
+	for _, chunk := range existing {
+		anchor := chunkAnchor(chunk.Content)
+		byAnchor[anchor] = append(byAnchor[anchor], chunk)
+	}
+
+	chunks := make([]*model.DocumentChunk, 0, len(texts))
+	for i, text := range texts {
+		anchor := chunkAnchor(text)
+		chunk := &model.DocumentChunk{
+			ID:         uuid.New(),
+			DocumentID: docID,
+			Content:    text,
+			Metadata:   fmt.Sprintf(`{"chunk_index": %d, "total_chunks": %d, "anchor": %q%s}`, i, len(texts), anchor, locationMetadata(locations, i)),
+		}
+		if olds := byAnchor[anchor]; len(olds) > 0 {
+			chunk.ID = olds[0].ID
+			chunk.CreatedAt = olds[0].CreatedAt
+			byAnchor[anchor] = olds[1:]
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
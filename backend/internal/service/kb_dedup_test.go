@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sampleDocument 生成若干段互不相同的文本
+func sampleDocument(paragraphs int) string {
+	var b strings.Builder
+	for i := 0; i < paragraphs; i++ {
+		fmt.Fprintf(&b, "Section %d describes how the relay selects channel %d for model family %d, "+
+			"including retry budgets, priority tiers and the fallback order used when quota runs out.\n\n", i, i*7+3, i%5)
+	}
+	return b.String()
+}
+
+func TestContentDigestNormalization(t *testing.T) {
+	text := sampleDocument(3)
+	variant := "\ufeff  " + strings.ReplaceAll(text, " ", " \t ") + "\r\n"
+
+	assert.Equal(t, ContentDigest(NormalizeDocumentText(text)), ContentDigest(NormalizeDocumentText(variant)))
+	assert.NotEqual(t, ContentDigest(NormalizeDocumentText(text)), ContentDigest(NormalizeDocumentText(strings.ToUpper(text))))
+	assert.Len(t, ContentDigest(""), 64)
+}
+
+func TestParseDuplicateAction(t *testing.T) {
+	action, err := ParseDuplicateAction("")
+	require.NoError(t, err)
+	assert.Equal(t, DuplicateSkip, action)
+
+	action, err = ParseDuplicateAction("replace")
+	require.NoError(t, err)
+	assert.Equal(t, DuplicateReplace, action)
+
+	_, err = ParseDuplicateAction("merge")
+	assert.Error(t, err)
+}
+
+func TestPlanDocumentChunksReplacePreservesAnchors(t *testing.T) {
+	s := &RAGService{}
+	docID := uuid.New()
+	text := sampleDocument(8)
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	original := planDocumentChunks(docID, s.ChunkText(text, 200, 20), nil, nil)
+	require.Greater(t, len(original), 3)
+	for _, chunk := range original {
+		chunk.CreatedAt = created
+		assert.Contains(t, chunk.Metadata, `"anchor": "`+chunkAnchor(chunk.Content)+`"`)
+	}
+
+	// 完全相同的内容重新处理：所有文本块沿用原 ID，已有引用仍然有效
+	replaced := planDocumentChunks(docID, s.ChunkText(text, 200, 20), nil, original)
+	require.Len(t, replaced, len(original))
+	for i := range original {
+		assert.Equal(t, original[i].ID, replaced[i].ID)
+		assert.Equal(t, created, replaced[i].CreatedAt)
+		assert.Equal(t, docID, replaced[i].DocumentID)
+	}
+
+	// 只修改结尾：结尾之前的文本块保留，修改所在的块获得新 ID
+	edited := strings.TrimRight(text, "\n") + " Appendix added later."
+	editedChunks := planDocumentChunks(docID, s.ChunkText(edited, 200, 20), nil, original)
+	last := len(editedChunks) - 1
+	for i := 0; i < last-1; i++ {
+		assert.Equal(t, original[i].ID, editedChunks[i].ID, "chunk %d", i)
+	}
+	assert.NotEqual(t, original[len(original)-1].ID, editedChunks[last].ID)
+	assert.True(t, editedChunks[last].CreatedAt.IsZero())
+}
+
+func TestPlanDocumentChunksRepeatedAnchors(t *testing.T) {
+	docID := uuid.New()
+	original := planDocumentChunks(docID, []string{"same", "same", "other"}, nil, nil)
+
+	// 同一锚点出现多次时按顺序一一对应，不会两个新块复用同一 ID
+	replaced := planDocumentChunks(docID, []string{"same", "same", "same"}, nil, original)
+	assert.Equal(t, original[0].ID, replaced[0].ID)
+	assert.Equal(t, original[1].ID, replaced[1].ID)
+	assert.NotEqual(t, original[0].ID, replaced[2].ID)
+	assert.NotEqual(t, original[1].ID, replaced[2].ID)
+}
+
+func TestFindNearDuplicates(t *testing.T) {
+	text := NormalizeDocumentText(sampleDocument(10))
+	nearText := NormalizeDocumentText(strings.Replace(sampleDocument(10), "retry budgets", "retry limits", 1))
+	otherText := NormalizeDocumentText(strings.Repeat("Billing exports are archived nightly and pruned after thirty days. ", 20))
+
+	existing := func(title, normalized string) *model.Document {
+		return &model.Document{ID: uuid.New(), Title: title, ContentDigest: ContentDigest(normalized), SimHash: int64(SimHash(normalized))}
+	}
+	docs := []*model.Document{
+		existing("exact", text),
+		existing("near", nearText),
+		existing("other", otherText),
+	}
+
+	similarity := SimHashSimilarity(SimHash(text), SimHash(nearText))
+	assert.GreaterOrEqual(t, similarity, NearDuplicateThreshold)
+	assert.Less(t, SimHashSimilarity(SimHash(text), SimHash(otherText)), NearDuplicateThreshold)
+
+	// 摘要完全相同的文档由重复检测处理，不再作为近似重复提示
+	matches := findNearDuplicates(docs, ContentDigest(text), SimHash(text))
+	require.Len(t, matches, 1)
+	assert.Equal(t, "near", matches[0].Title)
+	assert.Equal(t, docs[1].ID, matches[0].DocumentID)
+	assert.Equal(t, similarity, matches[0].Similarity)
+
+	// 阈值边界：恰好相差 6 位仍视为近似重复，相差 7 位则不提示
+	base := SimHash(text)
+	atThreshold := []*model.Document{{ID: uuid.New(), SimHash: int64(base ^ 0x3f)}}
+	belowThreshold := []*model.Document{{ID: uuid.New(), SimHash: int64(base ^ 0x7f)}}
+	assert.Len(t, findNearDuplicates(atThreshold, ContentDigest(text), base), 1)
+	assert.Empty(t, findNearDuplicates(belowThreshold, ContentDigest(text), base))
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	embeddingCacheKeyPrefix = "kb:embedding:"
+	embeddingCacheIndexKey  = "kb:embedding:index" // 按写入时间排序的键，用于限制条目数
+)
+
+// EmbeddingCacheConfig 向量缓存配置
+type EmbeddingCacheConfig struct {
+	TTL        time.Duration // 条目有效期
+	LocalSize  int           // 进程内 LRU 的最大条目数，0 表示不使用
+	MaxEntries int64         // Redis 中的最大条目数，超出时删除最早写入的条目，0 表示不限制
+}
+
+// DefaultEmbeddingCacheConfig 默认缓存 7 天，进程内 1 万条，Redis 中 100 万条
+func DefaultEmbeddingCacheConfig() EmbeddingCacheConfig {
+	return EmbeddingCacheConfig{
+		TTL:        7 * 24 * time.Hour,
+		LocalSize:  10000,
+		MaxEntries: 1000000,
+	}
+}
+
+// EmbeddingCacheStats 向量缓存的命中统计
+type EmbeddingCacheStats struct {
+	Hits         int64   `json:"hits"`
+	Misses       int64   `json:"misses"`
+	HitRate      float64 `json:"hit_rate"` // 0-1
+	LocalEntries int     `json:"local_entries"`
+	Redis        bool    `json:"redis"`
+}
+
+// EmbeddingCache 以 (模型, 文本 SHA-256) 为键缓存文本向量，Redis 为共享存储，进程内 LRU 在前
+//
+// 键包含模型名，知识库更换 Embedding 模型后不会命中旧模型的向量，旧条目按 TTL 过期。
+// 向量以 float32 存储，与 Embedding API 返回的精度一致。缓存读写失败只记录日志，按未命中处理。
+type EmbeddingCache struct {
+	redis  redis.UniversalClient // 为 nil 时只使用进程内缓存
+	local  *embeddingLRU
+	config EmbeddingCacheConfig
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewEmbeddingCache 创建向量缓存，client 为 nil 时只使用进程内 LRU
+func NewEmbeddingCache(client redis.UniversalClient, config EmbeddingCacheConfig) *EmbeddingCache {
+	c := &EmbeddingCache{redis: client, config: config}
+	if config.LocalSize > 0 {
+		c.local = newEmbeddingLRU(config.LocalSize, config.TTL)
+	}
+	return c
+}
+
+// embeddingCacheKey 缓存键 kb:embedding:<模型>:<文本 SHA-256>
+func embeddingCacheKey(model, text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return embeddingCacheKeyPrefix + model + ":" + hex.EncodeToString(sum[:])
+}
+
+// Get 查找文本向量，先查进程内缓存，再查 Redis 并回填
+func (c *EmbeddingCache) Get(ctx context.Context, model, text string) ([]float64, bool) {
+	key := embeddingCacheKey(model, text)
+	if c.local != nil {
+		if vector, ok := c.local.Get(key); ok {
+			c.hits.Add(1)
+			metrics.CacheLookup("embedding", true)
+			return vector, true
+		}
+	}
+
+	if c.redis != nil {
+		data, err := c.redis.Get(ctx, key).Bytes()
+		switch {
+		case err == nil:
+			if vector, ok := decodeEmbedding(data); ok {
+				if c.local != nil {
+					c.local.Set(key, vector)
+				}
+				c.hits.Add(1)
+				metrics.CacheLookup("embedding", true)
+				return vector, true
+			}
+		case !errors.Is(err, redis.Nil):
+			logger.Warn("embedding cache read failed", zap.Error(err))
+		}
+	}
+
+	c.misses.Add(1)
+	metrics.CacheLookup("embedding", false)
+	return nil, false
+}
+
+// Set 写入文本向量
+func (c *EmbeddingCache) Set(ctx context.Context, model, text string, vector []float64) {
+	key := embeddingCacheKey(model, text)
+	if c.local != nil {
+		c.local.Set(key, vector)
+	}
+	if c.redis == nil {
+		return
+	}
+
+	pipe := c.redis.Pipeline()
+	pipe.Set(ctx, key, encodeEmbedding(vector), c.config.TTL)
+	pipe.ZAdd(ctx, embeddingCacheIndexKey, &redis.Z{Score: float64(time.Now().UnixNano()), Member: key})
+	size := pipe.ZCard(ctx, embeddingCacheIndexKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Warn("embedding cache write failed", zap.Error(err))
+		return
+	}
+
+	if max := c.config.MaxEntries; max > 0 && size.Val() > max {
+		if err := trimCacheIndex(ctx, c.redis, embeddingCacheIndexKey, size.Val()-max); err != nil {
+			logger.Warn("embedding cache trim failed", zap.Error(err))
+		}
+	}
+}
+
+// trimCacheIndex 删除索引中最早写入的 n 个条目，其中可能有已按 TTL 过期的键
+func trimCacheIndex(ctx context.Context, client redis.UniversalClient, indexKey string, n int64) error {
+	keys, err := client.ZRange(ctx, indexKey, 0, n-1).Result()
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	members := make([]interface{}, len(keys))
+	for i, key := range keys {
+		members[i] = key
+	}
+
+	pipe := client.Pipeline()
+	for _, key := range keys {
+		// 集群模式下各个键可能位于不同的槽位，逐个删除
+		pipe.Del(ctx, key)
+	}
+	pipe.ZRem(ctx, indexKey, members...)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Stats 返回命中统计
+func (c *EmbeddingCache) Stats() EmbeddingCacheStats {
+	stats := EmbeddingCacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Redis:  c.redis != nil,
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	if c.local != nil {
+		stats.LocalEntries = c.local.Len()
+	}
+	return stats
+}
+
+// encodeEmbedding 向量编码为小端 float32 序列
+func encodeEmbedding(vector []float64) []byte {
+	data := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(float32(v)))
+	}
+	return data
+}
+
+func decodeEmbedding(data []byte) ([]float64, bool) {
+	if len(data) == 0 || len(data)%4 != 0 {
+		return nil, false
+	}
+	vector := make([]float64, len(data)/4)
+	for i := range vector {
+		vector[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:])))
+	}
+	return vector, true
+}
+
+// ttlLRU 进程内的定长 LRU，条目超过 ttl 后视为不存在
+type ttlLRU[V any] struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	order   *list.List // 最近使用的在前
+	entries map[string]*list.Element
+}
+
+type ttlLRUEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+func newTTLLRU[V any](size int, ttl time.Duration) *ttlLRU[V] {
+	return &ttlLRU[V]{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// embeddingLRU 进程内的文本向量缓存
+type embeddingLRU = ttlLRU[[]float64]
+
+func newEmbeddingLRU(size int, ttl time.Duration) *embeddingLRU {
+	return newTTLLRU[[]float64](size, ttl)
+}
+
+func (l *ttlLRU[V]) Get(key string) (V, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var zero V
+	elem, ok := l.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := elem.Value.(*ttlLRUEntry[V])
+	if l.ttl > 0 && l.now().After(entry.expiresAt) {
+		l.order.Remove(elem)
+		delete(l.entries, key)
+		return zero, false
+	}
+	l.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (l *ttlLRU[V]) Set(key string, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expiresAt := l.now().Add(l.ttl)
+	if elem, ok := l.entries[key]; ok {
+		entry := elem.Value.(*ttlLRUEntry[V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		l.order.MoveToFront(elem)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(&ttlLRUEntry[V]{key: key, value: value, expiresAt: expiresAt})
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*ttlLRUEntry[V]).key)
+	}
+}
+
+func (l *ttlLRU[V]) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// embeddingServer 模拟 Embedding API，按模型记录调用次数，向量为 [文本长度, 0.5]
+func embeddingServer(t *testing.T) (*httptest.Server, func(model string) int) {
+	var (
+		mu    sync.Mutex
+		calls = map[string]int{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+			Input string `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		mu.Lock()
+		calls[req.Model]++
+		mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": []map[string]interface{}{{"embedding": []float64{float64(len(req.Input)), 0.5}}},
+		})
+	}))
+	return server, func(model string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls[model]
+	}
+}
+
+func TestEmbeddingCacheSkipsRepeatedChunks(t *testing.T) {
+	server, calls := embeddingServer(t)
+	defer server.Close()
+
+	s := &RAGService{embeddingURL: server.URL, embeddingModel: "embed-small"}
+	s.SetEmbeddingCache(NewEmbeddingCache(nil, DefaultEmbeddingCacheConfig()))
+
+	texts := wordChunker(ChunkStrategyFixed, 10, 0).Chunk(numberedWords(60))
+	require.Len(t, texts, 6)
+	chunks := func() []*model.DocumentChunk {
+		result := make([]*model.DocumentChunk, len(texts))
+		for i, text := range texts {
+			result[i] = &model.DocumentChunk{Content: text}
+		}
+		return result
+	}
+
+	// 首次索引逐块调用 API
+	first := chunks()
+	_, err := embedChunks(context.Background(), s.knowledgeBaseEmbedder(nil), first, func(int) {})
+	require.NoError(t, err)
+	assert.Equal(t, 6, calls("embed-small"))
+
+	// 重新索引相同内容全部命中缓存
+	second := chunks()
+	_, err = embedChunks(context.Background(), s.knowledgeBaseEmbedder(nil), second, func(int) {})
+	require.NoError(t, err)
+	assert.Equal(t, 6, calls("embed-small"))
+	for i := range first {
+		assert.Equal(t, first[i].Embedding, second[i].Embedding)
+	}
+
+	stats := s.EmbeddingCacheStats()
+	require.NotNil(t, stats)
+	assert.Equal(t, int64(6), stats.Hits)
+	assert.Equal(t, int64(6), stats.Misses)
+	assert.Equal(t, 0.5, stats.HitRate)
+	assert.Equal(t, 6, stats.LocalEntries)
+	assert.False(t, stats.Redis)
+
+	// 知识库更换 Embedding 模型后不使用旧模型的向量
+	kb := &model.KnowledgeBase{EmbeddingModel: "embed-large"}
+	_, err = embedChunks(context.Background(), s.knowledgeBaseEmbedder(kb), chunks(), func(int) {})
+	require.NoError(t, err)
+	assert.Equal(t, 6, calls("embed-large"))
+	assert.Equal(t, 6, calls("embed-small"))
+}
+
+func TestEmbeddingLRUEvictsAndExpires(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	lru := newEmbeddingLRU(2, time.Hour)
+	lru.now = func() time.Time { return now }
+
+	lru.Set("a", []float64{1})
+	lru.Set("b", []float64{2})
+	_, ok := lru.Get("a")
+	require.True(t, ok)
+
+	// 超出容量时淘汰最久未使用的 b
+	lru.Set("c", []float64{3})
+	_, ok = lru.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, lru.Len())
+
+	now = now.Add(time.Hour + time.Second)
+	_, ok = lru.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, lru.Len())
+}
+
+func TestEmbeddingEncoding(t *testing.T) {
+	vector := []float64{0.25, -1.5, 3}
+	decoded, ok := decodeEmbedding(encodeEmbedding(vector))
+	require.True(t, ok)
+	assert.Equal(t, vector, decoded)
+
+	_, ok = decodeEmbedding([]byte{1, 2, 3})
+	assert.False(t, ok)
+
+	assert.NotEqual(t, embeddingCacheKey("m1", "text"), embeddingCacheKey("m2", "text"))
+}
@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/rag"
+	"go.uber.org/zap"
+)
+
+// DefaultIndexWorkers 同时分块与向量化的文档数
+const DefaultIndexWorkers = 2
+
+// ErrDocumentNotFound 文档不存在或不属于该知识库
+var ErrDocumentNotFound = errors.New("document not found")
+
+// indexQueue 文档索引任务的先进先出队列，由固定数量的工作协程处理
+type indexQueue struct {
+	run func(ctx context.Context, jobID uuid.UUID)
+
+	mu      sync.Mutex
+	pending []uuid.UUID
+	wake    chan struct{}
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func newIndexQueue(run func(ctx context.Context, jobID uuid.UUID)) *indexQueue {
+	return &indexQueue{run: run, wake: make(chan struct{}, 1)}
+}
+
+// Start 启动 workers 个工作协程
+func (q *indexQueue) Start(workers int) {
+	if workers <= 0 {
+		workers = DefaultIndexWorkers
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for ctx.Err() == nil {
+				jobID, ok := q.pop()
+				if !ok {
+					select {
+					case <-ctx.Done():
+					case <-q.wake:
+					}
+					continue
+				}
+				q.run(ctx, jobID)
+			}
+		}()
+	}
+}
+
+// Stop 停止工作协程并等待正在处理的任务返回，处理中的任务通过 ctx 取消
+func (q *indexQueue) Stop() {
+	if q.cancel != nil {
+		q.cancel()
+	}
+	q.wg.Wait()
+}
+
+// Push 任务排队
+func (q *indexQueue) Push(jobID uuid.UUID) {
+	q.mu.Lock()
+	q.pending = append(q.pending, jobID)
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *indexQueue) pop() (uuid.UUID, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return uuid.Nil, false
+	}
+	jobID := q.pending[0]
+	q.pending = q.pending[1:]
+	if len(q.pending) > 0 {
+		// 唤醒下一个空闲的工作协程
+		q.signal()
+	}
+	return jobID, true
+}
+
+func (q *indexQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// StartIndexing 启动文档索引的工作协程，最多同时处理 workers 个文档
+//
+// 上次停止时排队中与处理中的任务重新排队，处理中的任务从头重新分块与向量化。
+// 未启动时上传的文档只创建任务，由下次启动的知识库服务处理。
+func (s *RAGService) StartIndexing(workers int) {
+	s.indexer = newIndexQueue(s.runIndexJob)
+
+	jobs, err := s.kbRepo.ListUnfinishedIndexJobs(context.Background())
+	if err != nil {
+		logger.Error("Failed to load unfinished index jobs", zap.Error(err))
+	}
+	for _, job := range jobs {
+		s.indexer.Push(job.ID)
+	}
+	if len(jobs) > 0 {
+		logger.Info("Resuming unfinished index jobs", zap.Int("jobs", len(jobs)))
+	}
+
+	s.indexer.Start(workers)
+}
+
+// StopIndexing 停止文档索引，未完成的任务保留状态，下次启动时重新处理
+func (s *RAGService) StopIndexing() {
+	if s.indexer != nil {
+		s.indexer.Stop()
+	}
+}
+
+// enqueueIndexJob 保存解析后的文本并创建索引任务，replace 为 true 时替换文档已有的文本块
+func (s *RAGService) enqueueIndexJob(ctx context.Context, doc *model.Document, sections []rag.Section, replace bool) (*model.DocumentIndexJob, error) {
+	data, err := json.Marshal(sections)
+	if err != nil {
+		return nil, err
+	}
+	encoded := string(data)
+
+	job := &model.DocumentIndexJob{
+		ID:              uuid.New(),
+		DocumentID:      doc.ID,
+		KnowledgeBaseID: doc.KnowledgeBaseID,
+		State:           model.IndexJobQueued,
+		Replace:         replace,
+		Sections:        &encoded,
+	}
+	if err := s.kbRepo.CreateIndexJob(ctx, job); err != nil {
+		return nil, err
+	}
+	if s.indexer != nil {
+		s.indexer.Push(job.ID)
+	}
+	return job, nil
+}
+
+// runIndexJob 分块、向量化并保存文档的文本块，每个文本块完成后更新进度
+//
+// ctx 取消（服务停止）时任务保持处理中，不标记失败。
+func (s *RAGService) runIndexJob(ctx context.Context, jobID uuid.UUID) {
+	job, err := s.kbRepo.FindIndexJobByID(ctx, jobID)
+	if err != nil || job == nil {
+		return
+	}
+	if job.State != model.IndexJobQueued && job.State != model.IndexJobProcessing {
+		return
+	}
+
+	doc, err := s.kbRepo.FindDocumentByID(ctx, job.DocumentID)
+	if err != nil {
+		return
+	}
+	if doc == nil {
+		s.failIndexJob(ctx, job, nil, "Document was deleted before indexing")
+		return
+	}
+
+	var sections []rag.Section
+	if job.Sections != nil {
+		if err := json.Unmarshal([]byte(*job.Sections), &sections); err != nil {
+			s.failIndexJob(ctx, job, doc, fmt.Sprintf("Failed to load document content: %v", err))
+			return
+		}
+	}
+
+	// 重新处理上次中断的任务时，文本块可能已经保存，按替换处理
+	replace := job.Replace || job.StartedAt != nil
+
+	now := time.Now()
+	job.State = model.IndexJobProcessing
+	job.StartedAt = &now
+	job.ChunksDone = 0
+	job.ChunksTotal = 0
+	s.kbRepo.UpdateIndexJob(ctx, job)
+
+	// 更新文档状态为处理中
+	doc.Status = model.DocumentStatusProcessing
+	doc.ProcessingStartedAt = &now
+	s.kbRepo.UpdateDocument(ctx, doc)
+
+	var existing []*model.DocumentChunk
+	if replace {
+		chunks, err := s.kbRepo.GetChunksByDocumentID(ctx, doc.ID)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.failIndexJob(ctx, job, doc, fmt.Sprintf("Failed to load existing chunks: %v", err))
+			}
+			return
+		}
+		existing = chunks
+	}
+
+	// 按文档记录的分块配置逐页或逐章节分块，记录每块的位置
+	chunker := newTextChunker(documentChunking(doc), s.embeddingModel)
+	chunks, locations := chunkSections(sections, chunker)
+
+	// 创建文本块并获取向量表示，使用知识库的 Embedding 模型
+	documentChunks := planDocumentChunks(doc.ID, chunks, locations, existing)
+	job.ChunksTotal = len(documentChunks)
+	s.kbRepo.UpdateIndexJob(ctx, job)
+
+	kb, _ := s.kbRepo.FindKBByID(ctx, doc.KnowledgeBaseID)
+	failed, err := embedChunks(ctx, s.knowledgeBaseEmbedder(kb), documentChunks, func(done int) {
+		job.ChunksDone = done
+		s.kbRepo.UpdateIndexJobProgress(ctx, job.ID, done)
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Error("Failed to get embedding for chunk",
+			zap.Error(err),
+			zap.Int("chunk_index", failed),
+			zap.String("doc_id", doc.ID.String()))
+		s.failIndexJob(ctx, job, doc, fmt.Sprintf("Failed to embed chunk %d: %v", failed, err))
+		return
+	}
+
+	// 批量保存文本块
+	if replace {
+		err = s.kbRepo.ReplaceChunks(ctx, doc.ID, documentChunks)
+	} else {
+		err = s.kbRepo.CreateChunks(ctx, documentChunks)
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			s.failIndexJob(ctx, job, doc, fmt.Sprintf("Failed to save chunks: %v", err))
+		}
+		return
+	}
+
+	// 更新文档状态和统计
+	now = time.Now()
+	doc.Status = model.DocumentStatusCompleted
+	doc.ChunkCount = len(documentChunks)
+	doc.ErrorMessage = ""
+	doc.ProcessingCompletedAt = &now
+	s.kbRepo.UpdateDocument(ctx, doc)
+
+	job.State = model.IndexJobReady
+	job.Sections = nil
+	job.CompletedAt = &now
+	s.kbRepo.UpdateIndexJob(ctx, job)
+
+	// 更新知识库的统计信息
+	if !job.Replace {
+		s.kbRepo.IncrementDocumentCount(ctx, doc.KnowledgeBaseID)
+	}
+	s.kbRepo.IncrementTotalChunks(ctx, doc.KnowledgeBaseID, len(documentChunks)-len(existing))
+
+	logger.Info("Document processed successfully",
+		zap.String("doc_id", doc.ID.String()),
+		zap.String("job_id", job.ID.String()),
+		zap.Int("chunks", len(documentChunks)))
+}
+
+// embedChunks 依次获取文本块的向量，每完成一块以已完成数调用 progress；失败时返回出错的文本块下标
+func embedChunks(ctx context.Context, embed func(context.Context, string) ([]float64, error), chunks []*model.DocumentChunk, progress func(done int)) (int, error) {
+	for i, chunk := range chunks {
+		embedding, err := embed(ctx, chunk.Content)
+		if err != nil {
+			return i, err
+		}
+		chunk.Embedding = embedding
+		progress(i + 1)
+	}
+	return len(chunks), nil
+}
+
+// failIndexJob 标记任务与文档失败，doc 为 nil 时只更新任务
+func (s *RAGService) failIndexJob(ctx context.Context, job *model.DocumentIndexJob, doc *model.Document, message string) {
+	now := time.Now()
+	job.State = model.IndexJobFailed
+	job.Error = message
+	job.Sections = nil
+	job.CompletedAt = &now
+	s.kbRepo.UpdateIndexJob(ctx, job)
+
+	if doc != nil {
+		doc.Status = model.DocumentStatusFailed
+		doc.ErrorMessage = message
+		s.kbRepo.UpdateDocument(ctx, doc)
+	}
+}
+
+// DocumentIndexStatus 文档的索引进度
+type DocumentIndexStatus struct {
+	JobID       *uuid.UUID `json:"job_id"` // 早于异步索引处理的文档没有任务
+	DocumentID  uuid.UUID  `json:"document_id"`
+	State       string     `json:"state"` // queued、processing、ready、failed
+	ChunksDone  int        `json:"chunks_done"`
+	ChunksTotal int        `json:"chunks_total"`
+	Progress    float64    `json:"progress"` // 0-1
+	Error       string     `json:"error,omitempty"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// GetDocumentIndexStatus 获取文档最近一次索引任务的状态与进度
+func (s *RAGService) GetDocumentIndexStatus(ctx context.Context, userID, kbID int, docID uuid.UUID) (*DocumentIndexStatus, error) {
+	if _, err := s.GetKnowledgeBase(ctx, kbID, userID); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.kbRepo.FindDocumentByID(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil || doc.KnowledgeBaseID != kbID {
+		return nil, ErrDocumentNotFound
+	}
+
+	job, err := s.kbRepo.FindLatestIndexJob(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	return documentIndexStatus(doc, job), nil
+}
+
+// documentIndexStatus 由任务得到索引状态，没有任务时（解析失败或旧文档）按文档状态推断
+func documentIndexStatus(doc *model.Document, job *model.DocumentIndexJob) *DocumentIndexStatus {
+	status := &DocumentIndexStatus{DocumentID: doc.ID}
+	if job != nil {
+		status.JobID = &job.ID
+		status.State = job.State
+		status.ChunksDone = job.ChunksDone
+		status.ChunksTotal = job.ChunksTotal
+		status.Error = job.Error
+		status.StartedAt = job.StartedAt
+		status.CompletedAt = job.CompletedAt
+	} else {
+		switch doc.Status {
+		case model.DocumentStatusCompleted:
+			status.State = model.IndexJobReady
+		case model.DocumentStatusFailed:
+			status.State = model.IndexJobFailed
+		case model.DocumentStatusProcessing:
+			status.State = model.IndexJobProcessing
+		default:
+			status.State = model.IndexJobQueued
+		}
+		status.ChunksDone = doc.ChunkCount
+		status.ChunksTotal = doc.ChunkCount
+		status.Error = doc.ErrorMessage
+		status.StartedAt = doc.ProcessingStartedAt
+		status.CompletedAt = doc.ProcessingCompletedAt
+	}
+
+	switch {
+	case status.State == model.IndexJobReady:
+		status.Progress = 1
+	case status.ChunksTotal > 0:
+		status.Progress = float64(status.ChunksDone) / float64(status.ChunksTotal)
+	}
+	return status
+}
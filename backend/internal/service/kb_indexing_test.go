@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexQueueLimitsConcurrency(t *testing.T) {
+	var (
+		running, peak atomic.Int32
+		mu            sync.Mutex
+		done          []uuid.UUID
+		wg            sync.WaitGroup
+	)
+	q := newIndexQueue(func(ctx context.Context, jobID uuid.UUID) {
+		defer wg.Done()
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		done = append(done, jobID)
+		mu.Unlock()
+	})
+
+	// 启动前排队的任务（重启后恢复的任务）与启动后上传的任务都会被处理
+	jobs := make([]uuid.UUID, 12)
+	for i := range jobs {
+		jobs[i] = uuid.New()
+	}
+	wg.Add(len(jobs))
+	for _, id := range jobs[:4] {
+		q.Push(id)
+	}
+	q.Start(3)
+	for _, id := range jobs[4:] {
+		q.Push(id)
+	}
+	wg.Wait()
+	q.Stop()
+
+	assert.ElementsMatch(t, jobs, done)
+	assert.LessOrEqual(t, peak.Load(), int32(3))
+	assert.Greater(t, peak.Load(), int32(1))
+}
+
+func TestIndexQueueStopCancelsRunningJobs(t *testing.T) {
+	started := make(chan struct{})
+	var cancelled atomic.Bool
+	q := newIndexQueue(func(ctx context.Context, jobID uuid.UUID) {
+		close(started)
+		<-ctx.Done()
+		cancelled.Store(true)
+	})
+	q.Start(1)
+	q.Push(uuid.New())
+	q.Push(uuid.New())
+
+	<-started
+	q.Stop()
+	assert.True(t, cancelled.Load())
+
+	// 第二个任务没有开始，留在队列中
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	assert.Len(t, q.pending, 1)
+}
+
+func TestDocumentIndexStatus(t *testing.T) {
+	doc := &model.Document{ID: uuid.New(), Status: model.DocumentStatusProcessing}
+	job := &model.DocumentIndexJob{ID: uuid.New(), DocumentID: doc.ID, State: model.IndexJobProcessing, ChunksDone: 3, ChunksTotal: 12}
+
+	status := documentIndexStatus(doc, job)
+	require.NotNil(t, status.JobID)
+	assert.Equal(t, job.ID, *status.JobID)
+	assert.Equal(t, "processing", status.State)
+	assert.Equal(t, 0.25, status.Progress)
+
+	// 分块前没有总数
+	job.ChunksDone, job.ChunksTotal, job.State = 0, 0, model.IndexJobQueued
+	assert.Zero(t, documentIndexStatus(doc, job).Progress)
+
+	// 没有任务的文档按文档状态推断
+	legacy := &model.Document{ID: uuid.New(), Status: model.DocumentStatusCompleted, ChunkCount: 8}
+	status = documentIndexStatus(legacy, nil)
+	assert.Nil(t, status.JobID)
+	assert.Equal(t, "ready", status.State)
+	assert.Equal(t, 8, status.ChunksDone)
+	assert.Equal(t, 1.0, status.Progress)
+
+	failed := &model.Document{ID: uuid.New(), Status: model.DocumentStatusFailed, ErrorMessage: "Failed to parse document: encrypted PDF"}
+	status = documentIndexStatus(failed, nil)
+	assert.Equal(t, "failed", status.State)
+	assert.Equal(t, failed.ErrorMessage, status.Error)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/rag"
+)
+
+// documentFileTypes 内容类型对应的文档类型
+var documentFileTypes = map[string]string{
+	"application/pdf":   model.FileTypePDF,
+	rag.DOCXContentType: model.FileTypeDocx,
+	"text/markdown":     model.FileTypeMarkdown,
+	"text/html":         model.FileTypeHTML,
+	"text/plain":        model.FileTypeTXT,
+}
+
+// documentFileType 根据检测到的内容类型确定文档类型，其他类型取 MIME 子类型（如 csv、json）
+func documentFileType(contentType string) string {
+	if fileType, ok := documentFileTypes[contentType]; ok {
+		return fileType
+	}
+	_, subtype, _ := strings.Cut(contentType, "/")
+	if len(subtype) > 50 {
+		subtype = subtype[:50]
+	}
+	return subtype
+}
+
+// chunkLocation 文本块在原文档中的位置，用于引用时显示页码或标题路径
+type chunkLocation struct {
+	Page        int
+	HeadingPath []string
+}
+
+// chunkSections 按页或章节分别分块，块不会跨越章节边界
+func chunkSections(sections []rag.Section, chunker *textChunker) ([]string, []chunkLocation) {
+	var (
+		texts     []string
+		locations []chunkLocation
+	)
+	for _, section := range sections {
+		for _, chunk := range chunker.Chunk(section.Text) {
+			texts = append(texts, chunk)
+			locations = append(locations, chunkLocation{Page: section.Page, HeadingPath: section.HeadingPath})
+		}
+	}
+	return texts, locations
+}
+
+// locationMetadata 第 i 个文本块位置信息的 JSON 片段（以逗号开头），没有位置时为空
+func locationMetadata(locations []chunkLocation, i int) string {
+	if i >= len(locations) {
+		return ""
+	}
+
+	var b strings.Builder
+	if page := locations[i].Page; page > 0 {
+		fmt.Fprintf(&b, `, "page": %d`, page)
+	}
+	if path := locations[i].HeadingPath; len(path) > 0 {
+		data, _ := json.Marshal(path)
+		fmt.Fprintf(&b, `, "heading_path": %s`, data)
+	}
+	return b.String()
+}
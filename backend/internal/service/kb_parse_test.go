@@ -0,0 +1,49 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/rag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkSectionsRecordsLocations(t *testing.T) {
+	sections := []rag.Section{
+		{Text: sampleDocument(4), Page: 1},
+		{Text: "Short closing page.", Page: 2, HeadingPath: []string{"Appendix", "Glossary"}},
+	}
+
+	texts, locations := chunkSections(sections, wordChunker(ChunkStrategyFixed, 40, 5))
+	require.Len(t, locations, len(texts))
+	require.Greater(t, len(texts), 2)
+
+	// 文本块不跨越页边界
+	last := len(texts) - 1
+	assert.Equal(t, "Short closing page.", texts[last])
+	for i := 0; i < last; i++ {
+		assert.Equal(t, 1, locations[i].Page)
+	}
+
+	chunks := planDocumentChunks(uuid.New(), texts, locations, nil)
+	var first, closing map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(chunks[0].Metadata), &first))
+	require.NoError(t, json.Unmarshal([]byte(chunks[last].Metadata), &closing))
+
+	assert.Equal(t, float64(1), first["page"])
+	assert.NotContains(t, first, "heading_path")
+	assert.Equal(t, float64(2), closing["page"])
+	assert.Equal(t, []interface{}{"Appendix", "Glossary"}, closing["heading_path"])
+	assert.Equal(t, float64(last), closing["chunk_index"])
+}
+
+func TestDocumentFileType(t *testing.T) {
+	assert.Equal(t, model.FileTypePDF, documentFileType("application/pdf"))
+	assert.Equal(t, model.FileTypeDocx, documentFileType(rag.DOCXContentType))
+	assert.Equal(t, model.FileTypeMarkdown, documentFileType("text/markdown"))
+	assert.Equal(t, "csv", documentFileType("text/csv"))
+	assert.Equal(t, "octet-stream", documentFileType("application/octet-stream"))
+}
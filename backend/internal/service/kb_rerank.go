@@ -0,0 +1,192 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"go.uber.org/zap"
+)
+
+// defaultRerankTimeout 重排序接口的默认超时，超时后保持检索的原始顺序
+const defaultRerankTimeout = 5 * time.Second
+
+// RerankResult 重排序接口返回的单个结果，Index 为候选文本在请求中的下标
+type RerankResult struct {
+	Index int
+	Score float64
+}
+
+// Reranker 以交叉编码器对查询与候选文本逐对打分，返回按分数降序的前 topN 个结果
+type Reranker interface {
+	Rerank(ctx context.Context, query string, documents []string, topN int) ([]RerankResult, error)
+}
+
+// HTTPReranker 调用 Cohere 风格的 /rerank 接口，兼容 Jina、Xinference 等同格式的本地服务
+type HTTPReranker struct {
+	url    string
+	apiKey string
+	model  string
+	client *http.Client
+}
+
+// NewHTTPReranker 创建重排序客户端，url 为完整的接口地址，timeout 为 0 时使用默认超时
+func NewHTTPReranker(url, apiKey, model string, timeout time.Duration) *HTTPReranker {
+	if timeout <= 0 {
+		timeout = defaultRerankTimeout
+	}
+	return &HTTPReranker{
+		url:    url,
+		apiKey: apiKey,
+		model:  model,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Rerank 实现 Reranker
+func (r *HTTPReranker) Rerank(ctx context.Context, query string, documents []string, topN int) ([]RerankResult, error) {
+	reqBody := map[string]interface{}{
+		"query":     query,
+		"documents": documents,
+		"top_n":     topN,
+	}
+	if r.model != "" {
+		reqBody["model"] = r.model
+	}
+	jsonBody, _ := json.Marshal(reqBody)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.apiKey)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rerank API error: status=%d", resp.StatusCode)
+	}
+
+	var result struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("invalid rerank response: %w", err)
+	}
+
+	results := make([]RerankResult, 0, len(result.Results))
+	for _, item := range result.Results {
+		if item.Index < 0 || item.Index >= len(documents) {
+			return nil, fmt.Errorf("invalid rerank response: index %d out of range", item.Index)
+		}
+		results = append(results, RerankResult{Index: item.Index, Score: item.RelevanceScore})
+	}
+	return results, nil
+}
+
+// SearchOptions 知识库搜索参数
+type SearchOptions struct {
+	Mode SearchMode
+	TopK int // 检索的结果数；重排序时为送入重排序的候选数
+
+	Rerank     bool // 以重排序接口对检索结果重新排序
+	RerankTopN int  // 重排序后保留的结果数，默认与 TopK 相同
+}
+
+// SearchResponse 知识库搜索结果
+type SearchResponse struct {
+	Results  []*model.KBSearchResult `json:"results"`
+	Metadata SearchMetadata          `json:"metadata"`
+}
+
+// SearchMetadata 搜索过程的说明
+type SearchMetadata struct {
+	Mode   SearchMode      `json:"mode"`
+	Rerank *RerankMetadata `json:"rerank,omitempty"` // 未请求重排序时为空
+}
+
+// RerankMetadata 重排序阶段的结果
+type RerankMetadata struct {
+	Applied   bool   `json:"applied"` // false 时重排序失败，结果保持检索顺序
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SetReranker 设置重排序接口，未设置时请求重排序的搜索保持检索顺序
+func (s *RAGService) SetReranker(reranker Reranker) {
+	s.reranker = reranker
+}
+
+// rerankResults 按重排序分数重新排序并截取前 topN 个结果，Score 改为重排序分数
+//
+// 重排序失败或超时时保持原顺序截取，并在返回的元数据中记录错误。
+func rerankResults(ctx context.Context, reranker Reranker, query string, results []*model.KBSearchResult, topN int) ([]*model.KBSearchResult, *RerankMetadata) {
+	if topN <= 0 || topN > len(results) {
+		topN = len(results)
+	}
+	meta := &RerankMetadata{}
+	fallback := func(err error) ([]*model.KBSearchResult, *RerankMetadata) {
+		logger.Warn("rerank failed, keeping retrieval order",
+			zap.Int("candidates", len(results)),
+			zap.Error(err))
+		meta.Error = err.Error()
+		return results[:topN], meta
+	}
+
+	if reranker == nil {
+		return fallback(fmt.Errorf("reranker is not configured"))
+	}
+	if len(results) == 0 {
+		meta.Applied = true
+		return results, meta
+	}
+
+	documents := make([]string, len(results))
+	for i, r := range results {
+		documents[i] = r.Content
+	}
+
+	start := time.Now()
+	ranked, err := reranker.Rerank(ctx, query, documents, topN)
+	meta.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		return fallback(err)
+	}
+	if len(ranked) == 0 {
+		return fallback(fmt.Errorf("rerank returned no results"))
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+	reordered := make([]*model.KBSearchResult, 0, topN)
+	seen := make(map[int]bool, len(ranked))
+	for _, r := range ranked {
+		if seen[r.Index] || len(reordered) == topN {
+			continue
+		}
+		seen[r.Index] = true
+		hit := results[r.Index]
+		hit.RerankScore = r.Score
+		hit.Score = r.Score
+		reordered = append(reordered, hit)
+	}
+	meta.Applied = true
+	return reordered, meta
+}
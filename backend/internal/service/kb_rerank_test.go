@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rerankServer 模拟 Cohere 风格的 /rerank 接口：包含 refund 的文本得分最高，其余按在请求中的倒序打分
+func rerankServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer rerank-key", r.Header.Get("Authorization"))
+
+		var req struct {
+			Model     string   `json:"model"`
+			Query     string   `json:"query"`
+			Documents []string `json:"documents"`
+			TopN      int      `json:"top_n"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "rerank-v3", req.Model)
+
+		type item struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		}
+		var results []item
+		for i, doc := range req.Documents {
+			score := float64(i+1) / 10
+			if strings.Contains(strings.ToLower(doc), "refund") {
+				score = 0.99
+			}
+			results = append(results, item{Index: i, RelevanceScore: score})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	}))
+}
+
+func rerankCandidates() []*model.KBSearchResult {
+	contents := []string{
+		"Invoices are issued on the first day of each month.",
+		"Streaming responses start after the first token arrives.",
+		"Refunds are processed within five business days.",
+		"Error ERR_QUOTA_4021 means the monthly quota was exhausted.",
+	}
+	results := make([]*model.KBSearchResult, len(contents))
+	for i, content := range contents {
+		results[i] = &model.KBSearchResult{ChunkID: uuid.New(), Content: content, Score: 1 / float64(rrfK+i+1)}
+	}
+	return results
+}
+
+func TestRerankReordersCandidates(t *testing.T) {
+	server := rerankServer(t)
+	defer server.Close()
+	reranker := NewHTTPReranker(server.URL+"/rerank", "rerank-key", "rerank-v3", 0)
+
+	candidates := rerankCandidates()
+	ids := make([]uuid.UUID, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.ChunkID
+	}
+
+	results, meta := rerankResults(context.Background(), reranker, "how do refunds work", candidates, 3)
+	require.NotNil(t, meta)
+	assert.True(t, meta.Applied)
+	assert.Empty(t, meta.Error)
+	assert.GreaterOrEqual(t, meta.LatencyMs, int64(0))
+
+	// 检索排第 3 的退款说明被提到第 1，其余按重排序分数排列，截取前 3 个
+	require.Len(t, results, 3)
+	assert.Equal(t, []uuid.UUID{ids[2], ids[3], ids[1]}, []uuid.UUID{results[0].ChunkID, results[1].ChunkID, results[2].ChunkID})
+	assert.Equal(t, 0.99, results[0].RerankScore)
+	assert.Equal(t, results[0].RerankScore, results[0].Score)
+	assert.Equal(t, 0.4, results[1].Score)
+}
+
+func TestRerankFallsBackToRetrievalOrder(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	done := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+	}))
+	defer slow.Close()
+	defer close(done)
+
+	for name, reranker := range map[string]Reranker{
+		"error":          NewHTTPReranker(failing.URL, "", "", 0),
+		"timeout":        NewHTTPReranker(slow.URL, "", "", 20*time.Millisecond),
+		"not configured": nil,
+	} {
+		candidates := rerankCandidates()
+		original := append([]*model.KBSearchResult(nil), candidates...)
+
+		results, meta := rerankResults(context.Background(), reranker, "refunds", candidates, 2)
+		require.NotNil(t, meta, name)
+		assert.False(t, meta.Applied, name)
+		assert.NotEmpty(t, meta.Error, name)
+		assert.Equal(t, original[:2], results, name)
+		for _, r := range results {
+			assert.Zero(t, r.RerankScore, name)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// SearchMode 知识库搜索方式
+type SearchMode string
+
+const (
+	SearchModeVector  SearchMode = "vector"  // 只按向量余弦相似度
+	SearchModeKeyword SearchMode = "keyword" // 只按关键词全文搜索
+	SearchModeHybrid  SearchMode = "hybrid"  // 两路检索结果按倒数排名融合（默认）
+)
+
+// SearchModes 可选的搜索方式
+var SearchModes = []SearchMode{SearchModeVector, SearchModeKeyword, SearchModeHybrid}
+
+// ParseSearchMode 解析搜索方式，空值为 hybrid
+func ParseSearchMode(s string) (SearchMode, error) {
+	if s == "" {
+		return SearchModeHybrid, nil
+	}
+	for _, mode := range SearchModes {
+		if SearchMode(s) == mode {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("invalid mode %q: must be one of vector, keyword, hybrid", s)
+}
+
+const (
+	defaultSearchTopK = 10
+	maxSearchTopK     = 100
+
+	// rrfK 倒数排名融合的平滑常数，取常用的 60，降低单路排名靠前结果的权重
+	rrfK = 60
+	// hybridCandidateFactor 混合搜索时每路召回 top_k 的倍数作为融合候选
+	hybridCandidateFactor = 4
+)
+
+// chunkSearcher 按向量或关键词检索知识库中的文本块，由 KnowledgeBaseRepository 实现
+type chunkSearcher interface {
+	SearchChunksByVector(ctx context.Context, kbID int, embedding []float64, limit int) ([]*model.KBSearchResult, error)
+	SearchChunksByKeyword(ctx context.Context, kbID int, query string, substring bool, limit int) ([]*model.KBSearchResult, error)
+}
+
+// searchChunks 按搜索方式检索文本块，返回按 Score 降序的前 topK 个结果
+//
+// 向量检索只在需要时调用 embed。混合搜索中每个结果同时带有两路分数，只出现在一路中的结果另一路分数为 0。
+func searchChunks(ctx context.Context, searcher chunkSearcher, embed func(context.Context, string) ([]float64, error), kbID int, query string, mode SearchMode, topK int) ([]*model.KBSearchResult, error) {
+	candidates := topK
+	if mode == SearchModeHybrid {
+		candidates = topK * hybridCandidateFactor
+	}
+
+	var vectorHits, keywordHits []*model.KBSearchResult
+	if mode != SearchModeKeyword {
+		embedding, err := embed(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		if vectorHits, err = searcher.SearchChunksByVector(ctx, kbID, embedding, candidates); err != nil {
+			return nil, err
+		}
+	}
+	if mode != SearchModeVector {
+		var err error
+		if keywordHits, err = searcher.SearchChunksByKeyword(ctx, kbID, query, containsCJK(query), candidates); err != nil {
+			return nil, err
+		}
+	}
+
+	switch mode {
+	case SearchModeVector:
+		for _, hit := range vectorHits {
+			hit.Score = hit.Similarity
+		}
+		return vectorHits, nil
+	case SearchModeKeyword:
+		for _, hit := range keywordHits {
+			hit.Score = hit.KeywordScore
+		}
+		return keywordHits, nil
+	default:
+		return fuseSearchResults(vectorHits, keywordHits, topK), nil
+	}
+}
+
+// fuseSearchResults 倒数排名融合：每个结果的分数为其在各路结果中 1/(rrfK+排名) 之和，排名从 1 开始
+func fuseSearchResults(vectorHits, keywordHits []*model.KBSearchResult, topK int) []*model.KBSearchResult {
+	fused := make(map[uuid.UUID]*model.KBSearchResult)
+	var order []*model.KBSearchResult
+	add := func(hits []*model.KBSearchResult, merge func(existing, hit *model.KBSearchResult)) {
+		for rank, hit := range hits {
+			existing, ok := fused[hit.ChunkID]
+			if !ok {
+				existing = hit
+				fused[hit.ChunkID] = hit
+				order = append(order, hit)
+			} else {
+				merge(existing, hit)
+			}
+			existing.Score += 1 / float64(rrfK+rank+1)
+		}
+	}
+	add(vectorHits, func(existing, hit *model.KBSearchResult) { existing.Similarity = hit.Similarity })
+	add(keywordHits, func(existing, hit *model.KBSearchResult) { existing.KeywordScore = hit.KeywordScore })
+
+	// 分数相同时按先出现的顺序（向量结果优先）
+	sort.SliceStable(order, func(i, j int) bool { return order[i].Score > order[j].Score })
+	if len(order) > topK {
+		order = order[:topK]
+	}
+	return order
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// corpusChunk 内存语料中的文本块
+type corpusChunk struct {
+	id        uuid.UUID
+	kbID      int
+	content   string
+	embedding []float64
+}
+
+// memorySearcher 在内存语料上模拟仓储的两路检索：向量按余弦相似度排序返回全部结果，
+// 关键词与 plainto_tsquery 一样要求所有词都出现
+type memorySearcher struct {
+	chunks []corpusChunk
+}
+
+func (m *memorySearcher) SearchChunksByVector(ctx context.Context, kbID int, embedding []float64, limit int) ([]*model.KBSearchResult, error) {
+	var results []*model.KBSearchResult
+	for _, c := range m.chunks {
+		if c.kbID == kbID {
+			results = append(results, &model.KBSearchResult{ChunkID: c.id, Content: c.content, Similarity: cosine(c.embedding, embedding)})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Similarity > results[j].Similarity })
+	return truncateResults(results, limit), nil
+}
+
+func (m *memorySearcher) SearchChunksByKeyword(ctx context.Context, kbID int, query string, substring bool, limit int) ([]*model.KBSearchResult, error) {
+	terms := searchTerms(query)
+	var results []*model.KBSearchResult
+	for _, c := range m.chunks {
+		if c.kbID != kbID {
+			continue
+		}
+		words := make(map[string]bool)
+		for _, w := range searchTerms(c.content) {
+			words[w] = true
+		}
+		matched := len(terms) > 0
+		for _, term := range terms {
+			matched = matched && words[term]
+		}
+		if matched {
+			results = append(results, &model.KBSearchResult{ChunkID: c.id, Content: c.content, KeywordScore: 0.5})
+		}
+	}
+	return truncateResults(results, limit), nil
+}
+
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+func truncateResults(results []*model.KBSearchResult, limit int) []*model.KBSearchResult {
+	if len(results) > limit {
+		return results[:limit]
+	}
+	return results
+}
+
+func cosine(a, b []float64) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// searchCorpus 两个知识库的小语料，查询向量由 queryEmbeddings 给出
+func searchCorpus() (*memorySearcher, map[string]uuid.UUID, func(context.Context, string) ([]float64, error)) {
+	ids := map[string]uuid.UUID{
+		"refund":  uuid.New(),
+		"quota":   uuid.New(),
+		"latency": uuid.New(),
+		"billing": uuid.New(),
+		"other":   uuid.New(),
+	}
+	searcher := &memorySearcher{chunks: []corpusChunk{
+		{ids["refund"], 1, "Refunds are processed within five business days.", []float64{1, 0, 0}},
+		{ids["billing"], 1, "Invoices are issued on the first day of each month.", []float64{0.7, 0.7, 0}},
+		{ids["latency"], 1, "Streaming responses start after the first token arrives.", []float64{0, 1, 0.1}},
+		{ids["quota"], 1, "Error ERR_QUOTA_4021 means the monthly quota was exhausted.", []float64{0, 0.2, 1}},
+		// 其他用户的知识库中同样包含该错误码，不应出现在结果中
+		{ids["other"], 2, "ERR_QUOTA_4021 troubleshooting notes.", []float64{1, 0, 0}},
+	}}
+
+	queryEmbeddings := map[string][]float64{
+		// 罕见的标识符在向量空间中没有意义，落在与退款相近的方向
+		"ERR_QUOTA_4021": {0.9, 0.3, 0.1},
+		// 同义改写：与退款语义相近，但没有共同的词
+		"how do I get my money back": {0.95, 0.1, 0},
+	}
+	embed := func(ctx context.Context, query string) ([]float64, error) {
+		return queryEmbeddings[query], nil
+	}
+	return searcher, ids, embed
+}
+
+func TestHybridSearchFindsKeywordOnlyAndVectorOnlyHits(t *testing.T) {
+	searcher, ids, embed := searchCorpus()
+	ctx := context.Background()
+
+	// 标识符只能通过关键词找到
+	results, err := searchChunks(ctx, searcher, embed, 1, "ERR_QUOTA_4021", SearchModeVector, 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.NotEqual(t, ids["quota"], results[0].ChunkID)
+
+	results, err = searchChunks(ctx, searcher, embed, 1, "ERR_QUOTA_4021", SearchModeKeyword, 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, ids["quota"], results[0].ChunkID)
+
+	results, err = searchChunks(ctx, searcher, embed, 1, "ERR_QUOTA_4021", SearchModeHybrid, 2)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	hit := results[0]
+	assert.Equal(t, ids["quota"], hit.ChunkID)
+	assert.Equal(t, 0.5, hit.KeywordScore)
+	assert.Greater(t, hit.Similarity, 0.0)
+	// 关键词排名第 1，向量排名第 4
+	assert.InDelta(t, 1.0/61+1.0/64, hit.Score, 1e-12)
+	// 只出现在向量结果中的块没有关键词分数
+	assert.Equal(t, ids["refund"], results[1].ChunkID)
+	assert.Zero(t, results[1].KeywordScore)
+	assert.InDelta(t, 1.0/61, results[1].Score, 1e-12)
+
+	// 同义改写只能通过向量找到
+	results, err = searchChunks(ctx, searcher, embed, 1, "how do I get my money back", SearchModeKeyword, 3)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	results, err = searchChunks(ctx, searcher, embed, 1, "how do I get my money back", SearchModeHybrid, 3)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, ids["refund"], results[0].ChunkID)
+	assert.Zero(t, results[0].KeywordScore)
+
+	// 结果只来自指定的知识库
+	for _, mode := range SearchModes {
+		results, err := searchChunks(ctx, searcher, embed, 1, "ERR_QUOTA_4021", mode, 10)
+		require.NoError(t, err)
+		for _, r := range results {
+			assert.NotEqual(t, ids["other"], r.ChunkID, "mode %s", mode)
+		}
+	}
+}
+
+func TestKeywordSearchSkipsEmbedding(t *testing.T) {
+	searcher, ids, _ := searchCorpus()
+	embed := func(ctx context.Context, query string) ([]float64, error) {
+		return nil, errors.New("embedding API unavailable")
+	}
+
+	results, err := searchChunks(context.Background(), searcher, embed, 1, "monthly quota", SearchModeKeyword, 5)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, ids["quota"], results[0].ChunkID)
+	assert.Equal(t, results[0].KeywordScore, results[0].Score)
+
+	_, err = searchChunks(context.Background(), searcher, embed, 1, "monthly quota", SearchModeHybrid, 5)
+	assert.Error(t, err)
+}
+
+func TestParseSearchMode(t *testing.T) {
+	mode, err := ParseSearchMode("")
+	require.NoError(t, err)
+	assert.Equal(t, SearchModeHybrid, mode)
+
+	mode, err = ParseSearchMode("keyword")
+	require.NoError(t, err)
+	assert.Equal(t, SearchModeKeyword, mode)
+
+	_, err = ParseSearchMode("bm25")
+	assert.Error(t, err)
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/chat"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrMessageNotFound  = utils.NewAppError(utils.CodeNotFound, "message not found")
+	ErrBranchNotFound   = utils.NewAppError(utils.CodeNotFound, "branch not found")
+	ErrCannotRegenerate = utils.NewAppError(utils.CodeInvalidRequest, "only user and assistant messages can be regenerated")
+)
+
+// regenerateBranchName 重新生成回复时创建的分支名称
+const regenerateBranchName = "regenerate"
+
+// RegenerateResult 重新生成的回复及其所在分支
+type RegenerateResult struct {
+	BranchID string         `json:"branch_id"`
+	Message  *model.Message `json:"message"`
+}
+
+// MessageBranchView 分支及分支内的消息
+type MessageBranchView struct {
+	*chat.MessageBranch
+	Messages []*model.Message `json:"messages"`
+}
+
+// RegenerateMessage 为助手回复（或直接指定的用户消息）重新生成回复
+//
+// 新回复保存在从父用户消息分出的新分支中，原回复保留在主线对话。writer 不为空时以 SSE 流式输出，
+// 事件格式与 SendMessageStream 相同，complete 事件附带 branch_id。
+func (s *ChatService) RegenerateMessage(ctx context.Context, userID int, messageID uuid.UUID, writer io.Writer) (*RegenerateResult, error) {
+	target, err := s.messageRepo.FindByID(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, ErrMessageNotFound
+	}
+	session, err := s.GetSessionByID(ctx, target.SessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := s.regenerationParent(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. 以父消息为止的主线对话作为上下文
+	history, err := s.messageRepo.GetContextMessagesUntil(ctx, session.ID, parent.CreatedAt, session.ContextLength*2)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 || history[len(history)-1].ID != parent.ID {
+		history = append(history, parent)
+	}
+
+	relayMessages := make([]relay.ChatMessage, 0, len(history)+1)
+	if session.SystemRole != "" {
+		relayMessages = append(relayMessages, relay.ChatMessage{Role: "system", Content: session.SystemRole})
+	}
+	for _, msg := range history {
+		relayMessages = append(relayMessages, relay.ChatMessage{Role: msg.Role, Content: msg.Content})
+	}
+	relayMessages, trimmed := s.trimContext(ctx, session, relayMessages)
+
+	relayReq := &relay.ChatCompletionRequest{
+		Model:       session.Model,
+		Messages:    relayMessages,
+		Temperature: session.Temperature,
+		Stream:      writer != nil,
+	}
+	if session.MaxTokens != nil {
+		relayReq.MaxTokens = *session.MaxTokens
+	}
+
+	// 2. 重新调用模型
+	content, inputTokens, outputTokens, reasoningTokens := "", 0, 0, 0
+	relayCtx, rc := chatRelayContext(ctx)
+	if writer == nil {
+		resp, err := s.relayService.RelayChatCompletion(relayCtx, relayReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get AI response: %w", err)
+		}
+		if len(resp.Choices) > 0 {
+			content = resp.Choices[0].Message.Content
+		}
+		inputTokens = resp.Usage.PromptTokens
+		outputTokens = resp.Usage.CompletionTokens
+		reasoningTokens = resp.Usage.ReasoningTokens()
+	} else {
+		err := s.relayService.StreamChatCompletion(relayCtx, relayReq, func(chunk *relay.ChatCompletionResponse) error {
+			if chunk.Usage.CompletionTokens > 0 {
+				inputTokens = chunk.Usage.PromptTokens
+				outputTokens = chunk.Usage.CompletionTokens
+				reasoningTokens = chunk.Usage.ReasoningTokens()
+			}
+			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta != nil {
+				delta := chunk.Choices[0].Delta.Content
+				content += delta
+				writeStreamEvent(writer, map[string]interface{}{
+					"type":    "chunk",
+					"content": delta,
+					"model":   chunk.Model,
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Error("relay stream error", zap.Error(err))
+			return nil, err
+		}
+	}
+	inputTokens, outputTokens = estimateMissingUsage(relayReq, content, inputTokens, outputTokens)
+
+	// 3. 创建分支并保存新回复
+	branch, err := s.branches.CreateBranch(session.ID.String(), regenerateBranchName, parent.ID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]interface{}{"regenerated_from": target.ID.String()}
+	if trimmed > 0 {
+		metadata["trimmed_messages"] = trimmed
+	}
+	md, _ := json.Marshal(metadata)
+
+	parentID := parent.ID
+	aiMsg := &model.Message{
+		SessionID:       session.ID,
+		ParentID:        &parentID,
+		BranchID:        &branch.ID,
+		Role:            "assistant",
+		Content:         content,
+		Model:           session.Model,
+		InputTokens:     inputTokens,
+		OutputTokens:    outputTokens,
+		ReasoningTokens: reasoningTokens,
+		TotalTokens:     inputTokens + outputTokens,
+		Metadata:        string(md),
+		Files:           "[]",
+		ToolCalls:       "[]",
+		Latency:         messageLatency(rc),
+	}
+	if err := s.messageRepo.Create(ctx, aiMsg); err != nil {
+		return nil, err
+	}
+	if err := s.branches.AddMessageToBranch(branch.ID, aiMsg.ID.String()); err != nil {
+		return nil, err
+	}
+
+	// 4. 计费，失败不影响返回
+	if inputTokens > 0 || outputTokens > 0 {
+		if _, err := s.billingService.Charge(ctx, userID, session.ID, aiMsg.ID, session.Model, inputTokens, outputTokens, reasoningTokens); err != nil {
+			logger.Error("billing error", zap.Error(err))
+		}
+	}
+
+	session.UpdatedAt = aiMsg.CreatedAt
+	_ = s.sessionRepo.Update(ctx, session)
+
+	if writer != nil {
+		writeStreamEvent(writer, map[string]interface{}{
+			"type":             "complete",
+			"message_id":       aiMsg.ID.String(),
+			"branch_id":        branch.ID,
+			"content":          content,
+			"input_tokens":     inputTokens,
+			"output_tokens":    outputTokens,
+			"reasoning_tokens": reasoningTokens,
+			"total_tokens":     inputTokens + outputTokens,
+		})
+	}
+
+	return &RegenerateResult{BranchID: branch.ID, Message: aiMsg}, nil
+}
+
+// ListMessageBranches 列出消息的备选回复分支，message 可以是用户消息或其任一回复
+func (s *ChatService) ListMessageBranches(ctx context.Context, userID int, messageID uuid.UUID) ([]*MessageBranchView, error) {
+	target, err := s.ownedMessage(ctx, userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := s.regenerationParent(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	branches := s.branches.GetBranchesForMessage(parent.ID.String())
+	views := make([]*MessageBranchView, 0, len(branches))
+	for _, branch := range branches {
+		ids := make([]uuid.UUID, 0, len(branch.MessageIDs))
+		for _, id := range branch.MessageIDs {
+			if parsed, err := uuid.Parse(id); err == nil {
+				ids = append(ids, parsed)
+			}
+		}
+		messages, err := s.messageRepo.FindByIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, &MessageBranchView{MessageBranch: branch, Messages: messages})
+	}
+	return views, nil
+}
+
+// GetBranchMessages 获取分支视图的消息：主线中截至分支父消息的消息，加上分支内的消息
+func (s *ChatService) GetBranchMessages(ctx context.Context, sessionID uuid.UUID, userID int, branchID string, page, pageSize int) ([]*model.Message, int64, error) {
+	if _, err := s.GetSessionByID(ctx, sessionID, userID); err != nil {
+		return nil, 0, err
+	}
+
+	branch, err := s.branches.GetBranch(branchID)
+	if err != nil || branch.SessionID != sessionID.String() {
+		return nil, 0, ErrBranchNotFound
+	}
+	parentID, err := uuid.Parse(branch.ParentMessageID)
+	if err != nil {
+		return nil, 0, ErrBranchNotFound
+	}
+	parent, err := s.messageRepo.FindByID(ctx, parentID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if parent == nil {
+		return nil, 0, ErrBranchNotFound
+	}
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 50
+	}
+	return s.messageRepo.FindBranchView(ctx, sessionID, parent.CreatedAt, branch.ID, page, pageSize)
+}
+
+// regenerationParent 查找回复对应的用户消息：用户消息为其自身，分支中的回复使用 ParentID，主线回复取之前最后一条用户消息
+func (s *ChatService) regenerationParent(ctx context.Context, message *model.Message) (*model.Message, error) {
+	switch message.Role {
+	case "user":
+		return message, nil
+	case "assistant":
+	default:
+		return nil, ErrCannotRegenerate
+	}
+
+	var parent *model.Message
+	var err error
+	if message.ParentID != nil {
+		parent, err = s.messageRepo.FindByID(ctx, *message.ParentID)
+	} else {
+		parent, err = s.messageRepo.FindPreviousUserMessage(ctx, message.SessionID, message.CreatedAt)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil {
+		return nil, ErrMessageNotFound
+	}
+	return parent, nil
+}
+
+// messageBranchStore 将消息分支保存到 Postgres，实现 chat.BranchStore
+type messageBranchStore struct {
+	repo *repository.MessageBranchRepository
+}
+
+// SaveBranch 实现 chat.BranchStore
+func (s *messageBranchStore) SaveBranch(branch *chat.MessageBranch) error {
+	record, err := toBranchModel(branch)
+	if err != nil {
+		return err
+	}
+	return s.repo.Save(context.Background(), record)
+}
+
+// FindBranch 实现 chat.BranchStore
+func (s *messageBranchStore) FindBranch(branchID string) (*chat.MessageBranch, error) {
+	record, err := s.repo.FindByID(context.Background(), branchID)
+	if err != nil || record == nil {
+		return nil, err
+	}
+	return fromBranchModel(record), nil
+}
+
+// FindBranchesForMessage 实现 chat.BranchStore
+func (s *messageBranchStore) FindBranchesForMessage(messageID string) ([]*chat.MessageBranch, error) {
+	parentID, err := uuid.Parse(messageID)
+	if err != nil {
+		return nil, nil
+	}
+	records, err := s.repo.FindByParentMessageID(context.Background(), parentID)
+	if err != nil {
+		return nil, err
+	}
+	branches := make([]*chat.MessageBranch, len(records))
+	for i, record := range records {
+		branches[i] = fromBranchModel(record)
+	}
+	return branches, nil
+}
+
+func toBranchModel(branch *chat.MessageBranch) (*model.MessageBranch, error) {
+	sessionID, err := uuid.Parse(branch.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session id %q: %w", branch.SessionID, err)
+	}
+	parentID, err := uuid.Parse(branch.ParentMessageID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parent message id %q: %w", branch.ParentMessageID, err)
+	}
+	return &model.MessageBranch{
+		ID:              branch.ID,
+		SessionID:       sessionID,
+		Name:            branch.Name,
+		ParentMessageID: parentID,
+		MessageIDs:      branch.MessageIDs,
+		CreatedAt:       branch.CreatedAt,
+		UpdatedAt:       branch.UpdatedAt,
+	}, nil
+}
+
+func fromBranchModel(record *model.MessageBranch) *chat.MessageBranch {
+	messageIDs := []string(record.MessageIDs)
+	if messageIDs == nil {
+		messageIDs = []string{}
+	}
+	return &chat.MessageBranch{
+		ID:              record.ID,
+		SessionID:       record.SessionID.String(),
+		Name:            record.Name,
+		ParentMessageID: record.ParentMessageID.String(),
+		MessageIDs:      messageIDs,
+		CreatedAt:       record.CreatedAt,
+		UpdatedAt:       record.UpdatedAt,
+	}
+}
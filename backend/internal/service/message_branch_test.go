@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/chat"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranchModelRoundTrip(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	branch := &chat.MessageBranch{
+		ID:              "branch-1",
+		SessionID:       uuid.New().String(),
+		Name:            regenerateBranchName,
+		ParentMessageID: uuid.New().String(),
+		MessageIDs:      []string{uuid.New().String()},
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	record, err := toBranchModel(branch)
+	require.NoError(t, err)
+	assert.Equal(t, branch, fromBranchModel(record))
+
+	// 数据库中的空数组返回为空切片，便于序列化为 []
+	assert.Equal(t, []string{}, fromBranchModel(&model.MessageBranch{}).MessageIDs)
+
+	branch.SessionID = "session-1"
+	_, err = toBranchModel(branch)
+	assert.Error(t, err)
+}
+
+func TestRegenerationParentRejectsOtherRoles(t *testing.T) {
+	s := &ChatService{}
+	user := &model.Message{ID: uuid.New(), Role: "user"}
+
+	parent, err := s.regenerationParent(context.Background(), user)
+	require.NoError(t, err)
+	assert.Same(t, user, parent)
+
+	_, err = s.regenerationParent(context.Background(), &model.Message{Role: "tool"})
+	assert.ErrorIs(t, err, ErrCannotRegenerate)
+}
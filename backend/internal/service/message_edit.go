@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// EditMessageRequest 编辑消息请求
+type EditMessageRequest struct {
+	Content string `json:"content" binding:"required"`
+	Reason  string `json:"reason" binding:"max=500"`
+}
+
+// EditMessageResult 编辑后的消息与本次编辑记录
+type EditMessageResult struct {
+	Message *model.Message     `json:"message"`
+	Edit    *model.MessageEdit `json:"edit"`
+}
+
+// EditMessage 修改自己会话中的消息并记录编辑历史
+//
+// truncateAfter 时作废主线对话中该消息之后的消息（如编辑提问后之前的回复不再有效），
+// 作废的消息数记录在编辑记录的 truncated_messages 中。
+func (s *ChatService) EditMessage(ctx context.Context, userID int, messageID uuid.UUID, req *EditMessageRequest, truncateAfter bool) (*EditMessageResult, error) {
+	if _, err := s.ownedMessage(ctx, userID, messageID); err != nil {
+		return nil, err
+	}
+
+	edit := &model.MessageEdit{
+		MessageID:  messageID,
+		EditorID:   userID,
+		NewContent: req.Content,
+		Reason:     req.Reason,
+	}
+	message, err := s.editRepo.Apply(ctx, edit, truncateAfter)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil {
+		return nil, ErrMessageNotFound
+	}
+	return &EditMessageResult{Message: message, Edit: edit}, nil
+}
+
+// GetMessageEdits 按编辑顺序获取消息的编辑历史
+func (s *ChatService) GetMessageEdits(ctx context.Context, userID int, messageID uuid.UUID) ([]*model.MessageEdit, error) {
+	if _, err := s.ownedMessage(ctx, userID, messageID); err != nil {
+		return nil, err
+	}
+	return s.editRepo.ListByMessageID(ctx, messageID)
+}
+
+// ownedMessage 获取消息并检查其会话属于该用户
+func (s *ChatService) ownedMessage(ctx context.Context, userID int, messageID uuid.UUID) (*model.Message, error) {
+	message, err := s.messageRepo.FindByID(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil {
+		return nil, ErrMessageNotFound
+	}
+	if _, err := s.GetSessionByID(ctx, message.SessionID, userID); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
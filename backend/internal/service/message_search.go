@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// ErrEmptySearchQuery 搜索关键词为空
+var ErrEmptySearchQuery = utils.NewAppError(utils.CodeInvalidRequest, "search query is required")
+
+const (
+	maxSearchQueryRunes = 200 // 搜索关键词的最大字符数
+	maxSearchPageSize   = 100
+	searchSnippetRunes  = 40 // 子串匹配时摘要中匹配内容前后保留的字符数
+)
+
+// SearchMessages 按内容搜索用户自己会话中的消息，按相关度排序，摘要中的匹配内容以 <mark></mark> 标出
+//
+// 默认使用 tsvector 全文搜索；关键词包含中日韩文字或不产生词位（如只有符号）时改用 pg_trgm 子串匹配。
+func (s *ChatService) SearchMessages(ctx context.Context, userID int, query string, page, pageSize int) ([]*repository.MessageSearchHit, int64, error) {
+	query = truncateRunes(strings.TrimSpace(query), maxSearchQueryRunes)
+	if query == "" {
+		return nil, 0, ErrEmptySearchQuery
+	}
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > maxSearchPageSize {
+		pageSize = maxSearchPageSize
+	}
+	offset := (page - 1) * pageSize
+
+	fullText := !containsCJK(query)
+	if fullText {
+		hasLexemes, err := s.messageRepo.HasSearchLexemes(ctx, query)
+		if err != nil {
+			return nil, 0, err
+		}
+		fullText = hasLexemes
+	}
+	if fullText {
+		return s.messageRepo.SearchFullText(ctx, userID, query, pageSize, offset)
+	}
+
+	hits, total, err := s.messageRepo.SearchSubstring(ctx, userID, query, pageSize, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, hit := range hits {
+		hit.Snippet = highlightSnippet(hit.Content, query, searchSnippetRunes)
+	}
+	return hits, total, nil
+}
+
+// containsCJK 文本是否包含中日韩文字（simple 全文搜索配置不会切分这类文本）
+func containsCJK(s string) bool {
+	for _, r := range s {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightSnippet 截取第一处匹配（不区分大小写）前后 radius 个字符，并用 <mark></mark> 标出匹配内容
+func highlightSnippet(content, query string, radius int) string {
+	runes := []rune(content)
+	needle := []rune(strings.ToLower(query))
+	idx := indexRunesFold(runes, needle)
+	if idx < 0 {
+		return truncateRunes(content, 2*radius)
+	}
+
+	start := idx - radius
+	if start < 0 {
+		start = 0
+	}
+	end := idx + len(needle) + radius
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	b.WriteString(string(runes[start:idx]))
+	b.WriteString("<mark>")
+	b.WriteString(string(runes[idx : idx+len(needle)]))
+	b.WriteString("</mark>")
+	b.WriteString(string(runes[idx+len(needle) : end]))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// indexRunesFold 在 runes 中查找小写的 needle（逐字符比较小写形式），返回字符下标
+func indexRunesFold(runes, needle []rune) int {
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := 0; i+len(needle) <= len(runes); i++ {
+		match := true
+		for j, r := range needle {
+			if unicode.ToLower(runes[i+j]) != r {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainsCJK(t *testing.T) {
+	assert.True(t, containsCJK("一致性哈希"))
+	assert.True(t, containsCJK("カタカナ"))
+	assert.True(t, containsCJK("한국어 search"))
+	assert.False(t, containsCJK("consistent hashing"))
+	assert.False(t, containsCJK("???"))
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	content := strings.Repeat("前文", 30) + "我们讨论了一致性哈希的虚拟节点" + strings.Repeat("后文", 30)
+
+	snippet := highlightSnippet(content, "一致性哈希", 10)
+	assert.Equal(t, "…文前文前文我们讨论了<mark>一致性哈希</mark>的虚拟节点后文后文后…", snippet)
+
+	// 不区分大小写，保留原文大小写
+	assert.Equal(t, "Use <mark>PostgreSQL</mark> here", highlightSnippet("Use PostgreSQL here", "postgresql", 10))
+
+	// 没有匹配时截取开头
+	assert.Equal(t, "abcd", highlightSnippet("abcdefgh", "xyz", 2))
+}
+
+func TestSearchMessagesRequiresQuery(t *testing.T) {
+	s := &ChatService{}
+	_, _, err := s.SearchMessages(context.Background(), 1, "   ", 1, 20)
+	assert.ErrorIs(t, err, ErrEmptySearchQuery)
+}
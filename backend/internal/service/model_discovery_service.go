@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/adapter"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+// discoveryTimeout 单次模型列表请求的超时
+const discoveryTimeout = 10 * time.Second
+
+// ModelDiscoveryStatus 自托管渠道的模型发现状态
+type ModelDiscoveryStatus struct {
+	ChannelID   int       `json:"channel_id"`
+	ChannelName string    `json:"channel_name"`
+	Online      bool      `json:"online"`
+	Models      []string  `json:"models"`
+	Added       []string  `json:"added,omitempty"`
+	Removed     []string  `json:"removed,omitempty"`
+	LastChecked time.Time `json:"last_checked"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// ModelDiscoveryService 定期从自托管渠道发现模型并同步渠道能力
+//
+// 运维在 Ollama/vLLM 上加载或卸载模型后，无需手动修改渠道即可路由到新模型。
+// 上游离线（如笔记本休眠）视为预期状态，只在状态切换时记录一条日志。
+type ModelDiscoveryService struct {
+	channelRepo    *repository.ChannelRepository
+	abilityService ChannelAbilityService
+	interval       time.Duration
+	onChange       func(ctx context.Context) error
+
+	mu      sync.RWMutex
+	status  map[int]*ModelDiscoveryStatus
+	nextRun map[int]time.Time
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewModelDiscoveryService 创建模型发现服务，abilityService 可为 nil
+func NewModelDiscoveryService(abilityService ChannelAbilityService, interval time.Duration) *ModelDiscoveryService {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	return &ModelDiscoveryService{
+		channelRepo:    repository.NewChannelRepository(),
+		abilityService: abilityService,
+		interval:       interval,
+		status:         make(map[int]*ModelDiscoveryStatus),
+		nextRun:        make(map[int]time.Time),
+		stopCh:         make(chan struct{}),
+	}
+}
+
+// SetOnChange 设置模型列表变化后的回调（如重新加载中转渠道缓存）
+func (s *ModelDiscoveryService) SetOnChange(fn func(ctx context.Context) error) {
+	s.onChange = fn
+}
+
+// Start 启动后台轮询
+func (s *ModelDiscoveryService) Start() {
+	// 渠道可配置比全局更短的间隔，按较细的粒度检查是否到期
+	tick := s.interval
+	if tick > 15*time.Second {
+		tick = 15 * time.Second
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		s.RunOnce(context.Background())
+
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+				s.RunOnce(context.Background())
+			}
+		}
+	}()
+}
+
+// Stop 停止后台轮询
+func (s *ModelDiscoveryService) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+}
+
+// RunOnce 检查所有到期的自托管渠道
+func (s *ModelDiscoveryService) RunOnce(ctx context.Context) {
+	channels, err := s.channelRepo.GetAll(ctx)
+	if err != nil {
+		logger.Warn("model discovery: failed to load channels", zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	for _, ch := range channels {
+		if !adapter.ParseProviderType(ch.Type).IsSelfHosted() {
+			continue
+		}
+
+		interval := s.channelInterval(ch)
+		if interval <= 0 {
+			continue
+		}
+
+		s.mu.RLock()
+		due := now.After(s.nextRun[ch.ID])
+		s.mu.RUnlock()
+		if !due {
+			continue
+		}
+
+		s.mu.Lock()
+		s.nextRun[ch.ID] = now.Add(interval)
+		s.mu.Unlock()
+
+		// 错误已记录在状态与日志中
+		_, _ = s.DiscoverChannel(ctx, ch)
+	}
+}
+
+// channelInterval 渠道的轮询间隔，负数表示关闭
+func (s *ModelDiscoveryService) channelInterval(ch *model.Channel) time.Duration {
+	seconds := ch.GetSettings().ModelDiscoveryIntervalSeconds
+	switch {
+	case seconds < 0:
+		return 0
+	case seconds > 0:
+		return time.Duration(seconds) * time.Second
+	default:
+		return s.interval
+	}
+}
+
+// DiscoverChannel 立即对指定渠道执行一次模型发现，模型列表变化时更新渠道能力
+func (s *ModelDiscoveryService) DiscoverChannel(ctx context.Context, ch *model.Channel) (*ModelDiscoveryStatus, error) {
+	adaptor, err := adapter.GetAdapterByChannel(ch)
+	if err != nil {
+		return nil, err
+	}
+	lister, ok := adaptor.(adapter.ModelLister)
+	if !ok {
+		return nil, fmt.Errorf("channel type %s does not support model discovery", ch.Type)
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+
+	status := &ModelDiscoveryStatus{
+		ChannelID:   ch.ID,
+		ChannelName: ch.Name,
+		Models:      ch.GetSupportedModels(),
+		LastChecked: time.Now(),
+	}
+
+	models, err := lister.ListModels(listCtx)
+	if err != nil {
+		status.LastError = err.Error()
+		s.setStatus(status, err)
+		return status, err
+	}
+
+	status.Online = true
+	if len(models) == 0 {
+		// 空列表会让渠道变成“支持所有模型”，保留上次的列表
+		status.LastError = "no models loaded"
+		s.setStatus(status, nil)
+		return status, nil
+	}
+
+	status.Added, status.Removed = diffModels(status.Models, models)
+	status.Models = models
+	if len(status.Added) > 0 || len(status.Removed) > 0 {
+		if err := s.applyModels(ctx, ch, models); err != nil {
+			status.LastError = err.Error()
+			s.setStatus(status, nil)
+			return status, err
+		}
+		logger.Info("model discovery: channel models changed",
+			zap.Int("channel_id", ch.ID),
+			zap.Strings("added", status.Added),
+			zap.Strings("removed", status.Removed))
+	}
+
+	s.setStatus(status, nil)
+	return status, nil
+}
+
+// applyModels 保存模型列表并同步渠道能力
+func (s *ModelDiscoveryService) applyModels(ctx context.Context, ch *model.Channel, models []string) error {
+	supportModels := strings.Join(models, ",")
+	if err := s.channelRepo.UpdateSupportModels(ctx, ch.ID, supportModels); err != nil {
+		return fmt.Errorf("failed to update channel models: %w", err)
+	}
+	ch.SupportModels = supportModels
+
+	if s.abilityService != nil {
+		if err := s.abilityService.SyncFromChannel(ctx, ch); err != nil {
+			return err
+		}
+	}
+	if s.onChange != nil {
+		if err := s.onChange(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setStatus 保存状态，只在在线/离线切换时输出日志，避免离线的开发机刷屏
+func (s *ModelDiscoveryService) setStatus(status *ModelDiscoveryStatus, err error) {
+	s.mu.Lock()
+	prev, seen := s.status[status.ChannelID]
+	s.status[status.ChannelID] = status
+	s.mu.Unlock()
+
+	wasOnline := !seen || prev.Online
+	switch {
+	case err == nil:
+		if seen && !prev.Online {
+			logger.Info("model discovery: channel is back online", zap.Int("channel_id", status.ChannelID))
+		}
+	case adapter.IsUnavailableError(err):
+		if wasOnline {
+			logger.Info("model discovery: channel is offline", zap.Int("channel_id", status.ChannelID), zap.Error(err))
+		} else {
+			logger.Debug("model discovery: channel still offline", zap.Int("channel_id", status.ChannelID))
+		}
+	default:
+		logger.Warn("model discovery failed", zap.Int("channel_id", status.ChannelID), zap.Error(err))
+	}
+}
+
+// GetStatus 获取渠道的最近一次发现结果
+func (s *ModelDiscoveryService) GetStatus(channelID int) (*ModelDiscoveryStatus, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status, ok := s.status[channelID]
+	return status, ok
+}
+
+// ListStatus 获取所有自托管渠道的发现状态
+func (s *ModelDiscoveryService) ListStatus() []*ModelDiscoveryStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*ModelDiscoveryStatus, 0, len(s.status))
+	for _, status := range s.status {
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ChannelID < result[j].ChannelID })
+	return result
+}
+
+// diffModels 比较新旧模型列表
+func diffModels(oldModels, newModels []string) (added, removed []string) {
+	oldSet := make(map[string]bool, len(oldModels))
+	for _, m := range oldModels {
+		oldSet[m] = true
+	}
+	newSet := make(map[string]bool, len(newModels))
+	for _, m := range newModels {
+		newSet[m] = true
+		if !oldSet[m] {
+			added = append(added, m)
+		}
+	}
+	for _, m := range oldModels {
+		if !newSet[m] {
+			removed = append(removed, m)
+		}
+	}
+	return added, removed
+}
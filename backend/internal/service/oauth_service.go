@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/oauth"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+)
+
+// OAuthUsers 第三方登录后更新最近登录信息所需的用户仓储
+type OAuthUsers interface {
+	Update(ctx context.Context, user *model.User) error
+}
+
+// OAuthService GitHub、Google 等第三方账号登录与绑定服务
+type OAuthService struct {
+	manager  *oauth.Manager
+	sessions *SessionService
+	users    OAuthUsers
+}
+
+// NewOAuthService 创建第三方登录服务
+func NewOAuthService(manager *oauth.Manager, sessions *SessionService, users OAuthUsers) *OAuthService {
+	return &OAuthService{manager: manager, sessions: sessions, users: users}
+}
+
+// NewOAuthManager 按配置启用提供方，身份与用户保存在数据库中
+func NewOAuthManager(cfg *config.OAuthConfig, states oauth.StateStore) *oauth.Manager {
+	m := oauth.NewManager(&oauthStore{
+		orgRepo:  repository.NewOrganizationRepository(),
+		userRepo: repository.NewUserRepository(),
+	}, states, cfg.RedirectBaseURL, nil)
+	m.SetStateTTL(time.Duration(cfg.StateTTLSeconds) * time.Second)
+	if cfg.GitHubClientID != "" {
+		m.Register(oauth.GitHub(cfg.GitHubClientID, cfg.GitHubClientSecret))
+	}
+	if cfg.GoogleClientID != "" {
+		m.Register(oauth.Google(cfg.GoogleClientID, cfg.GoogleClientSecret))
+	}
+	return m
+}
+
+// OAuthCallbackResponse 回调响应：登录流程返回登录令牌，绑定流程只返回绑定结果
+type OAuthCallbackResponse struct {
+	*LoginResponse
+	Provider    string `json:"provider"`
+	Bound       bool   `json:"bound"`
+	Provisioned bool   `json:"provisioned"`
+}
+
+// Providers 已启用的提供方
+func (s *OAuthService) Providers() []string {
+	return s.manager.Providers()
+}
+
+// BeginLogin 发起第三方登录，返回提供方授权地址
+func (s *OAuthService) BeginLogin(ctx context.Context, provider string) (string, error) {
+	return s.manager.BeginLogin(ctx, provider)
+}
+
+// BeginBind 为当前用户发起绑定，返回提供方授权地址
+func (s *OAuthService) BeginBind(ctx context.Context, provider string, userID int) (string, error) {
+	return s.manager.BeginBind(ctx, provider, userID)
+}
+
+// Callback 处理提供方回调，登录流程创建登录会话并签发登录令牌
+func (s *OAuthService) Callback(ctx context.Context, provider, state, code string, client ClientInfo) (*OAuthCallbackResponse, error) {
+	result, err := s.manager.Complete(ctx, provider, state, code)
+	if err != nil {
+		return nil, err
+	}
+	if result.Bound {
+		return &OAuthCallbackResponse{Provider: provider, Bound: true}, nil
+	}
+
+	user := result.User
+	resp, err := s.sessions.IssueTokens(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user.LastLoginAt = &now
+	user.LastLoginIP = client.IP
+	_ = s.users.Update(ctx, user)
+
+	return &OAuthCallbackResponse{
+		LoginResponse: resp,
+		Provider:      provider,
+		Provisioned:   result.Provisioned,
+	}, nil
+}
+
+// Bindings 列出当前用户绑定的第三方账号
+func (s *OAuthService) Bindings(ctx context.Context, userID int) ([]oauth.Binding, error) {
+	return s.manager.Bindings(ctx, userID)
+}
+
+// Unbind 解除当前用户与第三方账号的绑定
+func (s *OAuthService) Unbind(ctx context.Context, userID int, provider string) error {
+	return s.manager.Unbind(ctx, userID, provider)
+}
+
+// oauthStore 将仓储适配为 oauth.Store，身份与组织 SSO 共用 user_identities 表
+type oauthStore struct {
+	orgRepo  *repository.OrganizationRepository
+	userRepo *repository.UserRepository
+}
+
+func (st *oauthStore) FindIdentity(ctx context.Context, issuer, subject string) (*model.UserIdentity, error) {
+	return st.orgRepo.FindIdentity(ctx, issuer, subject)
+}
+
+func (st *oauthStore) ListIdentities(ctx context.Context, userID int) ([]*model.UserIdentity, error) {
+	return st.orgRepo.ListIdentities(ctx, userID)
+}
+
+func (st *oauthStore) CreateIdentity(ctx context.Context, identity *model.UserIdentity) error {
+	return st.orgRepo.CreateIdentity(ctx, identity)
+}
+
+func (st *oauthStore) DeleteIdentity(ctx context.Context, userID int, issuer string) (bool, error) {
+	return st.orgRepo.DeleteIdentity(ctx, userID, issuer)
+}
+
+func (st *oauthStore) FindUserByID(ctx context.Context, id int) (*model.User, error) {
+	return st.userRepo.FindByID(ctx, id)
+}
+
+func (st *oauthStore) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	return st.userRepo.FindByEmail(ctx, email)
+}
+
+func (st *oauthStore) CreateUser(ctx context.Context, user *model.User) error {
+	return provisionUser(ctx, st.userRepo, user)
+}
+
+func (st *oauthStore) ActivateUser(ctx context.Context, user *model.User) error {
+	return st.userRepo.Update(ctx, user)
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tools"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrPluginNotFound      = errors.New("plugin not found")
+	ErrPluginExists        = errors.New("plugin already exists")
+	ErrPluginNotRunning    = errors.New("plugin not running")
+	ErrInvalidPluginConfig = errors.New("invalid plugin config")
+)
+
+// pluginNamePattern 插件名同时用作路由参数，只允许字母、数字、下划线和连字符
+var pluginNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// PluginStore 插件配置的持久化（由 repository.PluginRepository 实现）
+type PluginStore interface {
+	Create(ctx context.Context, plugin *model.Plugin) error
+	FindAll(ctx context.Context) ([]*model.Plugin, error)
+	UpdateEnabled(ctx context.Context, name string, enabled bool) error
+}
+
+// RegisterPluginRequest 注册插件请求
+type RegisterPluginRequest struct {
+	Name               string   `json:"name" binding:"required"`
+	Description        string   `json:"description"`
+	Mode               string   `json:"mode" binding:"required"` // stdio 或 http
+	Command            string   `json:"command"`
+	Args               []string `json:"args"`
+	URL                string   `json:"url"`
+	TimeoutSeconds     int      `json:"timeout_seconds"`
+	MaxRetries         int      `json:"max_retries"`
+	AutoRestart        bool     `json:"auto_restart"`
+	ToolRefreshSeconds int      `json:"tool_refresh_seconds"`
+}
+
+// PluginInfo 插件配置、运行状态与工具列表
+type PluginInfo struct {
+	*model.Plugin
+	Status tools.MCPPluginStatus `json:"status"`
+	Tools  []*tools.MCPTool      `json:"tools,omitempty"`
+}
+
+// PluginService 插件注册表：配置持久化到 plugins 表，运行时由 MCPPluginManager 管理
+type PluginService struct {
+	store   PluginStore
+	manager *tools.MCPPluginManager
+
+	// 插件进程与健康检查的生命周期独立于单个请求
+	ctx context.Context
+
+	mu      sync.RWMutex
+	plugins map[string]*model.Plugin
+}
+
+// NewPluginService 创建插件服务
+func NewPluginService(store PluginStore, manager *tools.MCPPluginManager) *PluginService {
+	return &PluginService{
+		store:   store,
+		manager: manager,
+		ctx:     context.Background(),
+		plugins: make(map[string]*model.Plugin),
+	}
+}
+
+// Restore 重新注册已保存的插件，并启动上次处于启用状态的插件
+//
+// 单个插件注册或启动失败只记录日志，不影响其他插件。
+func (s *PluginService) Restore(ctx context.Context) error {
+	records, err := s.store.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if err := s.register(record); err != nil {
+			logger.Warn("failed to restore plugin", zap.String("plugin", record.Name), zap.Error(err))
+			continue
+		}
+		if !record.Enabled {
+			continue
+		}
+		if err := s.manager.StartPlugin(s.ctx, record.Name); err != nil {
+			logger.Warn("failed to start plugin", zap.String("plugin", record.Name), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// RegisterPlugin 校验并保存插件配置，注册后插件处于停止状态
+func (s *PluginService) RegisterPlugin(ctx context.Context, userID int, req *RegisterPluginRequest) (*PluginInfo, error) {
+	record := &model.Plugin{
+		Name:               req.Name,
+		Description:        req.Description,
+		Mode:               req.Mode,
+		Command:            req.Command,
+		Args:               req.Args,
+		URL:                req.URL,
+		TimeoutSeconds:     req.TimeoutSeconds,
+		MaxRetries:         req.MaxRetries,
+		AutoRestart:        req.AutoRestart,
+		ToolRefreshSeconds: req.ToolRefreshSeconds,
+	}
+	if userID > 0 {
+		record.CreatedBy = &userID
+	}
+	if err := validatePlugin(record); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	_, exists := s.plugins[record.Name]
+	s.mu.RUnlock()
+	if exists {
+		return nil, ErrPluginExists
+	}
+
+	if err := s.store.Create(ctx, record); err != nil {
+		logger.Error("Failed to save plugin", zap.Error(err))
+		return nil, err
+	}
+	if err := s.register(record); err != nil {
+		return nil, err
+	}
+
+	return s.GetPlugin(record.Name)
+}
+
+// ListPlugins 按名称列出所有插件及其状态
+func (s *PluginService) ListPlugins() []*PluginInfo {
+	statuses := make(map[string]tools.MCPPluginStatus)
+	for _, status := range s.manager.GetAllStatus() {
+		statuses[status.Name] = status
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]*PluginInfo, 0, len(s.plugins))
+	for name, record := range s.plugins {
+		infos = append(infos, &PluginInfo{Plugin: record, Status: statuses[name]})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// GetPlugin 获取插件配置、状态与工具列表
+func (s *PluginService) GetPlugin(name string) (*PluginInfo, error) {
+	record, plugin, err := s.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PluginInfo{Plugin: record, Status: plugin.GetStatus(), Tools: plugin.GetTools()}, nil
+}
+
+// StartPlugin 启动插件，并在服务重启后自动启动
+func (s *PluginService) StartPlugin(ctx context.Context, name string) (*PluginInfo, error) {
+	if _, _, err := s.lookup(name); err != nil {
+		return nil, err
+	}
+
+	if err := s.manager.StartPlugin(s.ctx, name); err != nil {
+		return nil, err
+	}
+	if err := s.setEnabled(ctx, name, true); err != nil {
+		return nil, err
+	}
+
+	return s.GetPlugin(name)
+}
+
+// StopPlugin 停止插件，服务重启后不再自动启动
+func (s *PluginService) StopPlugin(ctx context.Context, name string) (*PluginInfo, error) {
+	if _, _, err := s.lookup(name); err != nil {
+		return nil, err
+	}
+
+	if err := s.manager.StopPlugin(name); err != nil {
+		return nil, err
+	}
+	if err := s.setEnabled(ctx, name, false); err != nil {
+		return nil, err
+	}
+
+	return s.GetPlugin(name)
+}
+
+// ExecuteTool 调用插件提供的工具
+func (s *PluginService) ExecuteTool(ctx context.Context, name, toolName string, args map[string]interface{}) (interface{}, error) {
+	_, plugin, err := s.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if plugin.GetStatus().State != "running" {
+		return nil, ErrPluginNotRunning
+	}
+
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	return s.manager.CallTool(ctx, name, toolName, args)
+}
+
+// lookup 获取已注册的插件配置与运行实例
+func (s *PluginService) lookup(name string) (*model.Plugin, tools.MCPPlugin, error) {
+	s.mu.RLock()
+	record, ok := s.plugins[name]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, nil, ErrPluginNotFound
+	}
+
+	plugin, err := s.manager.GetPlugin(name)
+	if err != nil {
+		return nil, nil, ErrPluginNotFound
+	}
+	return record, plugin, nil
+}
+
+// register 将插件配置注册到 MCPPluginManager
+func (s *PluginService) register(record *model.Plugin) error {
+	if _, err := s.manager.RegisterPlugin(record.Name, pluginConfig(record)); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.plugins[record.Name] = record
+	s.mu.Unlock()
+	return nil
+}
+
+// setEnabled 持久化插件的启用状态
+func (s *PluginService) setEnabled(ctx context.Context, name string, enabled bool) error {
+	if err := s.store.UpdateEnabled(ctx, name, enabled); err != nil {
+		logger.Error("Failed to update plugin", zap.Error(err))
+		return err
+	}
+
+	s.mu.Lock()
+	s.plugins[name].Enabled = enabled
+	s.mu.Unlock()
+	return nil
+}
+
+// pluginConfig 将保存的配置转换为 MCPPluginConfig
+func pluginConfig(record *model.Plugin) *tools.MCPPluginConfig {
+	return &tools.MCPPluginConfig{
+		Name:                record.Name,
+		Description:         record.Description,
+		Mode:                record.Mode,
+		Command:             record.Command,
+		Args:                record.Args,
+		URL:                 record.URL,
+		Timeout:             time.Duration(record.TimeoutSeconds) * time.Second,
+		MaxRetries:          record.MaxRetries,
+		AutoRestart:         record.AutoRestart,
+		ToolRefreshInterval: time.Duration(record.ToolRefreshSeconds) * time.Second,
+	}
+}
+
+// validatePlugin 校验插件配置：stdio 模式需要 command，http 模式需要 http(s) URL
+func validatePlugin(record *model.Plugin) error {
+	if !pluginNamePattern.MatchString(record.Name) {
+		return fmt.Errorf("%w: name must be 1-64 letters, digits, '_' or '-'", ErrInvalidPluginConfig)
+	}
+	if record.TimeoutSeconds < 0 || record.MaxRetries < 0 || record.ToolRefreshSeconds < 0 {
+		return fmt.Errorf("%w: timeout, retries and refresh interval must not be negative", ErrInvalidPluginConfig)
+	}
+
+	switch record.Mode {
+	case "stdio":
+		if record.Command == "" {
+			return fmt.Errorf("%w: command is required in stdio mode", ErrInvalidPluginConfig)
+		}
+	case "http":
+		u, err := url.Parse(record.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: url must be an absolute http(s) URL in http mode", ErrInvalidPluginConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported mode %q", ErrInvalidPluginConfig, record.Mode)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticPluginStore 返回固定插件列表的注册表
+type staticPluginStore struct {
+	plugins []*model.Plugin
+}
+
+func (s *staticPluginStore) Create(ctx context.Context, plugin *model.Plugin) error {
+	s.plugins = append(s.plugins, plugin)
+	return nil
+}
+
+func (s *staticPluginStore) FindAll(ctx context.Context) ([]*model.Plugin, error) {
+	return s.plugins, nil
+}
+
+func (s *staticPluginStore) UpdateEnabled(ctx context.Context, name string, enabled bool) error {
+	return nil
+}
+
+func TestPluginServiceRestoreStartsEnabledPlugins(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tools":
+			w.Write([]byte(`[{"name": "echo"}]`))
+		case "/call":
+			var msg tools.MCPMessage
+			json.NewDecoder(r.Body).Decode(&msg)
+			json.NewEncoder(w).Encode(tools.MCPMessage{Type: "tool_result", ID: msg.ID, Result: msg.ToolCall.Arguments["text"]})
+		}
+	}))
+	defer server.Close()
+
+	store := &staticPluginStore{plugins: []*model.Plugin{
+		{ID: 1, Name: "running", Mode: "http", URL: server.URL, Enabled: true},
+		{ID: 2, Name: "idle", Mode: "http", URL: server.URL},
+		{ID: 3, Name: "broken", Mode: "grpc"},
+	}}
+
+	manager := tools.NewMCPPluginManager()
+	svc := NewPluginService(store, manager)
+	require.NoError(t, svc.Restore(context.Background()))
+	defer manager.StopPlugin("running")
+
+	plugins := svc.ListPlugins()
+	require.Len(t, plugins, 2, "unsupported plugin should be skipped")
+	assert.Equal(t, "idle", plugins[0].Name)
+	assert.Equal(t, "stopped", plugins[0].Status.State)
+	assert.Equal(t, "running", plugins[1].Status.State)
+	assert.Equal(t, 1, plugins[1].Status.ToolCount)
+
+	result, err := svc.ExecuteTool(context.Background(), "running", "echo", map[string]interface{}{"text": "restored"})
+	require.NoError(t, err)
+	assert.Equal(t, "restored", result)
+
+	_, err = svc.ExecuteTool(context.Background(), "idle", "echo", nil)
+	assert.ErrorIs(t, err, ErrPluginNotRunning)
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/database"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+// 项目错误
+var (
+	ErrProjectNotFound         = errors.New("project not found")
+	ErrProjectPermissionDenied = errors.New("permission denied")
+	ErrProjectInvalidKB        = errors.New("knowledge base not found")
+)
+
+// ProjectService 中转项目管理：项目配置、项目 Token 与用量
+type ProjectService struct {
+	projectRepo  *repository.ProjectRepository
+	kbRepo       *repository.KnowledgeBaseRepository
+	tokenRepo    repository.TokenRepository
+	tokenService *TokenService
+}
+
+// NewProjectService 创建新的项目 Service
+func NewProjectService() *ProjectService {
+	tokenRepo := repository.NewTokenRepository(database.DB)
+	return &ProjectService{
+		projectRepo:  repository.NewProjectRepository(),
+		kbRepo:       repository.NewKnowledgeBaseRepository(),
+		tokenRepo:    tokenRepo,
+		tokenService: NewTokenService(tokenRepo),
+	}
+}
+
+// ProjectRequest 创建或更新项目的请求结构（更新时整体替换配置）
+type ProjectRequest struct {
+	Name             string              `json:"name" binding:"required"`
+	Description      string              `json:"description"`
+	SystemPrompt     string              `json:"system_prompt"`
+	DefaultModel     string              `json:"default_model"`
+	Params           model.ProjectParams `json:"params"`
+	KnowledgeBaseIDs []int64             `json:"knowledge_base_ids"`
+	MetadataTags     model.ProjectTags   `json:"metadata_tags"`
+	Locks            model.ProjectLocks  `json:"locks"`
+	Disabled         bool                `json:"disabled"`
+}
+
+// CreateProjectTokenRequest 创建项目 Token 的请求结构
+type CreateProjectTokenRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	QuotaLimit  int64  `json:"quota_limit"`
+	ExpireDays  int    `json:"expire_days"`
+}
+
+// ProjectToken 项目 Token 信息，Key 仅在创建时完整返回
+type ProjectToken struct {
+	ID         int        `json:"id"`
+	ProjectID  int        `json:"project_id"`
+	Name       string     `json:"name"`
+	Key        string     `json:"key"`
+	Status     string     `json:"status"`
+	QuotaLimit int64      `json:"quota_limit"` // -1 表示不限
+	QuotaUsed  int64      `json:"quota_used"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpireAt   *time.Time `json:"expire_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CreateProject 创建项目
+func (s *ProjectService) CreateProject(ctx context.Context, userID int, req *ProjectRequest) (*model.Project, error) {
+	if err := s.validateKnowledgeBases(ctx, userID, req.KnowledgeBaseIDs); err != nil {
+		return nil, err
+	}
+
+	project := &model.Project{UserID: userID}
+	applyProjectRequest(project, req)
+
+	if err := s.projectRepo.Create(ctx, project); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Project created", zap.Int("project_id", project.ID), zap.Int("user_id", userID))
+	return project, nil
+}
+
+// GetProject 获取项目详情，只允许所有者访问
+func (s *ProjectService) GetProject(ctx context.Context, userID int, id int) (*model.Project, error) {
+	project, err := s.projectRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if project == nil {
+		return nil, ErrProjectNotFound
+	}
+	if project.UserID != userID {
+		return nil, ErrProjectPermissionDenied
+	}
+	return project, nil
+}
+
+// ListProjects 获取用户的项目列表
+func (s *ProjectService) ListProjects(ctx context.Context, userID int) ([]*model.Project, error) {
+	return s.projectRepo.FindByUserID(ctx, userID)
+}
+
+// UpdateProject 更新项目配置
+func (s *ProjectService) UpdateProject(ctx context.Context, userID int, id int, req *ProjectRequest) (*model.Project, error) {
+	project, err := s.GetProject(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateKnowledgeBases(ctx, userID, req.KnowledgeBaseIDs); err != nil {
+		return nil, err
+	}
+
+	applyProjectRequest(project, req)
+	if err := s.projectRepo.Update(ctx, project); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// DeleteProject 删除项目，绑定的 Token 随之失效
+func (s *ProjectService) DeleteProject(ctx context.Context, userID int, id int) error {
+	if _, err := s.GetProject(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.projectRepo.Delete(ctx, id)
+}
+
+// CreateProjectToken 创建绑定到项目的 API Token
+func (s *ProjectService) CreateProjectToken(ctx context.Context, userID int, id int, req *CreateProjectTokenRequest) (*ProjectToken, error) {
+	if _, err := s.GetProject(ctx, userID, id); err != nil {
+		return nil, err
+	}
+
+	expireDays := req.ExpireDays
+	if expireDays <= 0 {
+		expireDays = 365
+	}
+
+	token, key, err := s.tokenService.CreateProjectToken(ctx, userID, id, req.Name, req.Description, req.QuotaLimit, expireDays)
+	if err != nil {
+		return nil, err
+	}
+
+	info := toProjectToken(token)
+	info.Key = key
+	return info, nil
+}
+
+// ListProjectTokens 列出项目的 Token（Key 已脱敏）
+func (s *ProjectService) ListProjectTokens(ctx context.Context, userID int, id int) ([]*ProjectToken, error) {
+	if _, err := s.GetProject(ctx, userID, id); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.tokenRepo.ListByProjectID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*ProjectToken, 0, len(tokens))
+	for _, token := range tokens {
+		if token.Status == model.TokenStatusDeleted || token.UserID != userID {
+			continue
+		}
+		result = append(result, toProjectToken(token))
+	}
+	return result, nil
+}
+
+// GetProjectUsage 获取项目最近若干天的用量（按模型汇总）
+func (s *ProjectService) GetProjectUsage(ctx context.Context, userID int, id int, days int) ([]*repository.ProjectUsage, error) {
+	if _, err := s.GetProject(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	if days <= 0 || days > 365 {
+		days = 30
+	}
+	return s.projectRepo.GetUsage(ctx, id, time.Now().AddDate(0, 0, -days))
+}
+
+// validateKnowledgeBases 项目只能引用所有者自己的知识库
+func (s *ProjectService) validateKnowledgeBases(ctx context.Context, userID int, kbIDs []int64) error {
+	for _, kbID := range kbIDs {
+		kb, err := s.kbRepo.FindKBByID(ctx, int(kbID))
+		if err != nil {
+			return err
+		}
+		// 他人的知识库与不存在的一样处理，不暴露其存在
+		if kb == nil || kb.DeletedAt != nil || kb.UserID != userID {
+			return fmt.Errorf("%w: %d", ErrProjectInvalidKB, kbID)
+		}
+	}
+	return nil
+}
+
+// applyProjectRequest 将请求中的配置写入项目
+func applyProjectRequest(project *model.Project, req *ProjectRequest) {
+	project.Name = req.Name
+	project.Description = req.Description
+	project.SystemPrompt = req.SystemPrompt
+	project.DefaultModel = req.DefaultModel
+	project.Params = req.Params
+	project.KnowledgeBaseIDs = pq.Int64Array(req.KnowledgeBaseIDs)
+	project.MetadataTags = req.MetadataTags
+	project.Locks = req.Locks
+	project.Status = model.ProjectStatusActive
+	if req.Disabled {
+		project.Status = model.ProjectStatusDisabled
+	}
+}
+
+// toProjectToken 转换为对外的 Token 信息，Key 只保留首尾字符
+func toProjectToken(token *model.Token) *ProjectToken {
+	info := &ProjectToken{
+		ID:         token.ID,
+		ProjectID:  int(token.ProjectID.Int64),
+		Name:       token.Name,
+		Key:        tokenKeyPreview(token),
+		Status:     token.Status.String(),
+		QuotaLimit: -1,
+		QuotaUsed:  token.QuotaUsed,
+		CreatedAt:  token.CreatedAt,
+	}
+	if token.QuotaLimit.Valid {
+		info.QuotaLimit = token.QuotaLimit.Int64
+	}
+	if token.ExpireAt.Valid {
+		info.ExpireAt = &token.ExpireAt.Time
+	}
+	if token.LastUsedAt.Valid {
+		info.LastUsedAt = &token.LastUsedAt.Time
+	}
+	return info
+}
+
+// tokenKeyPreview 脱敏后的 Token Key，早期 Token 的 Key 保存在 TokenHash 中
+func tokenKeyPreview(token *model.Token) string {
+	if token.KeyHint != "" {
+		return token.KeyHint
+	}
+	return maskTokenKey(token.TokenHash)
+}
+
+// maskTokenKey 脱敏 Token Key
+func maskTokenKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:4] + "****" + key[len(key)-4:]
+}
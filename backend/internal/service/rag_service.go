@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/rag"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
 	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
 	"go.uber.org/zap"
@@ -23,6 +25,10 @@ type RAGService struct {
 	embeddingURL  string // Embedding API URL
 	embeddingKey  string // Embedding API Key
 	embeddingModel string // 使用的 Embedding 模型
+	parsers       *rag.DocumentParserRegistry // 按内容类型选择的文档解析器
+	reranker      Reranker                    // 搜索结果重排序，可为 nil
+	indexer       *indexQueue                 // 文档索引工作协程，StartIndexing 前为 nil
+	embeddings    *EmbeddingCache             // 文本向量缓存，可为 nil
 }
 
 // NewRAGService 创建新的 RAG Service
@@ -36,6 +42,7 @@ func NewRAGService(embeddingURL, embeddingKey string) *RAGService {
 		embeddingURL:   embeddingURL,
 		embeddingKey:   embeddingKey,
 		embeddingModel: "text-embedding-3-small",
+		parsers:        rag.NewDocumentParserRegistry(),
 	}
 }
 
@@ -44,20 +51,30 @@ type CreateKnowledgeBaseRequest struct {
 	Name           string `json:"name" binding:"required"`
 	Description    string `json:"description"`
 	EmbeddingModel string `json:"embedding_model"`
-	ChunkSize      int    `json:"chunk_size"`
-	ChunkOverlap   int    `json:"chunk_overlap"`
+	ChunkSize      int    `json:"chunk_size"`     // 按 Token 计
+	ChunkOverlap   int    `json:"chunk_overlap"`  // 按 Token 计
+	ChunkStrategy  string `json:"chunk_strategy"` // fixed（默认）、sentence、recursive
 }
 
 // CreateKnowledgeBase 创建知识库
 func (s *RAGService) CreateKnowledgeBase(ctx context.Context, userID int, req *CreateKnowledgeBaseRequest) (*model.KnowledgeBase, error) {
 	// 设置默认值
-	chunkSize := req.ChunkSize
-	if chunkSize == 0 {
-		chunkSize = 512
+	chunking := ChunkingConfig{
+		Strategy:     ChunkStrategy(req.ChunkStrategy),
+		ChunkSize:    req.ChunkSize,
+		ChunkOverlap: req.ChunkOverlap,
+	}
+	if chunking.Strategy == "" {
+		chunking.Strategy = ChunkStrategyFixed
+	}
+	if chunking.ChunkSize == 0 {
+		chunking.ChunkSize = defaultChunkSize
 	}
-	chunkOverlap := req.ChunkOverlap
-	if chunkOverlap == 0 {
-		chunkOverlap = 50
+	if chunking.ChunkOverlap == 0 {
+		chunking.ChunkOverlap = defaultChunkOverlap
+	}
+	if err := chunking.Validate(); err != nil {
+		return nil, err
 	}
 
 	embeddingModel := req.EmbeddingModel
@@ -70,8 +87,9 @@ func (s *RAGService) CreateKnowledgeBase(ctx context.Context, userID int, req *C
 		Name:           req.Name,
 		Description:    req.Description,
 		EmbeddingModel: embeddingModel,
-		ChunkSize:      chunkSize,
-		ChunkOverlap:   chunkOverlap,
+		ChunkSize:      chunking.ChunkSize,
+		ChunkOverlap:   chunking.ChunkOverlap,
+		ChunkStrategy:  string(chunking.Strategy),
 		Status:         1,
 	}
 
@@ -132,11 +150,59 @@ func (s *RAGService) DeleteKnowledgeBase(ctx context.Context, id int, userID int
 	return s.kbRepo.DeleteKB(ctx, id)
 }
 
-// GetTextEmbedding 调用 API 获取文本的向量表示
+// SetEmbeddingCache 设置文本向量缓存，获取向量前先查缓存
+func (s *RAGService) SetEmbeddingCache(cache *EmbeddingCache) {
+	s.embeddings = cache
+}
+
+// EmbeddingCacheStats 向量缓存的命中统计，未启用缓存时返回 nil
+func (s *RAGService) EmbeddingCacheStats() *EmbeddingCacheStats {
+	if s.embeddings == nil {
+		return nil
+	}
+	stats := s.embeddings.Stats()
+	return &stats
+}
+
+// GetTextEmbedding 使用默认模型获取文本的向量表示
 func (s *RAGService) GetTextEmbedding(ctx context.Context, text string) ([]float64, error) {
+	return s.embedText(ctx, s.embeddingModel, text)
+}
+
+// knowledgeBaseEmbedder 使用知识库的 Embedding 模型获取向量
+func (s *RAGService) knowledgeBaseEmbedder(kb *model.KnowledgeBase) func(context.Context, string) ([]float64, error) {
+	embeddingModel := s.embeddingModel
+	if kb != nil && kb.EmbeddingModel != "" {
+		embeddingModel = kb.EmbeddingModel
+	}
+	return func(ctx context.Context, text string) ([]float64, error) {
+		return s.embedText(ctx, embeddingModel, text)
+	}
+}
+
+// embedText 获取文本的向量表示，先查缓存，未命中时调用 API 并写入缓存
+func (s *RAGService) embedText(ctx context.Context, embeddingModel, text string) ([]float64, error) {
+	if s.embeddings != nil {
+		if vector, ok := s.embeddings.Get(ctx, embeddingModel, text); ok {
+			return vector, nil
+		}
+	}
+
+	vector, err := s.requestEmbedding(ctx, embeddingModel, text)
+	if err != nil {
+		return nil, err
+	}
+	if s.embeddings != nil {
+		s.embeddings.Set(ctx, embeddingModel, text, vector)
+	}
+	return vector, nil
+}
+
+// requestEmbedding 调用 API 获取文本的向量表示
+func (s *RAGService) requestEmbedding(ctx context.Context, embeddingModel, text string) ([]float64, error) {
 	// 构建请求
 	reqBody := map[string]interface{}{
-		"model": s.embeddingModel,
+		"model": embeddingModel,
 		"input": text,
 	}
 
@@ -228,8 +294,39 @@ func (s *RAGService) ChunkText(text string, chunkSize, chunkOverlap int) []strin
 	return chunks
 }
 
+// ErrDocumentProcessing 文档仍在处理中，不能替换
+var ErrDocumentProcessing = errors.New("document is still being processed")
+
 // UploadDocument 上传和处理文档
-func (s *RAGService) UploadDocument(ctx context.Context, userID int, kbID int, title string, fileContent string) (*model.Document, error) {
+//
+// 归一化文本的摘要与知识库中已有文档相同时按 onDuplicate 处理：skip 返回 DuplicateDocumentError，
+// replace 在已有文档 ID 下重新处理，force 仍创建新文档。近似重复只在结果中提示，不阻止上传。
+// chunking 覆盖知识库的分块配置（可为 nil）；已有文档的分块配置与本次不同时，skip 也会按新配置重新分块。
+func (s *RAGService) UploadDocument(ctx context.Context, userID int, kbID int, title string, fileContent string, onDuplicate DuplicateAction, chunking *ChunkingOverride) (*UploadDocumentResult, error) {
+	// 获取知识库并检查权限
+	kb, err := s.GetKnowledgeBase(ctx, kbID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if kb == nil {
+		return nil, fmt.Errorf("knowledge base not found")
+	}
+
+	config, err := chunking.Apply(KnowledgeBaseChunking(kb))
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &rag.ParsedContent{Content: fileContent, Sections: []rag.Section{{Text: fileContent}}}
+	return s.uploadParsed(ctx, kb, title, model.FileTypeTXT, int64(len(fileContent)), parsed, onDuplicate, config)
+}
+
+// UploadDocumentFile 解析上传的文件（PDF、DOCX、Markdown、HTML 等）并加入知识库
+//
+// 解析器按检测到的内容类型选择，重复文档的处理与 UploadDocument 相同。解析失败时仍保存文档记录，
+// 状态为失败并附带错误信息，可在文档列表中查看，上传本身不返回错误。
+func (s *RAGService) UploadDocumentFile(ctx context.Context, userID int, kbID int, title, filename string, data []byte, onDuplicate DuplicateAction, chunking *ChunkingOverride) (*UploadDocumentResult, error) {
 	// 获取知识库并检查权限
 	kb, err := s.GetKnowledgeBase(ctx, kbID, userID)
 	if err != nil {
@@ -240,121 +337,165 @@ func (s *RAGService) UploadDocument(ctx context.Context, userID int, kbID int, t
 		return nil, fmt.Errorf("knowledge base not found")
 	}
 
+	config, err := chunking.Apply(KnowledgeBaseChunking(kb))
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := s.parsers.ParseBytes(data, filename)
+	if err != nil {
+		logger.Warn("Failed to parse document",
+			zap.Error(err),
+			zap.Int("kb_id", kbID),
+			zap.String("filename", filename))
+
+		doc := &model.Document{
+			ID:              uuid.New(),
+			KnowledgeBaseID: kbID,
+			Title:           title,
+			FileType:        documentFileType(rag.DetectContentType(data, filename)),
+			FileSize:        int64(len(data)),
+			Status:          model.DocumentStatusFailed,
+			ErrorMessage:    fmt.Sprintf("Failed to parse document: %v", err),
+		}
+		if err := s.kbRepo.CreateDocument(ctx, doc); err != nil {
+			return nil, err
+		}
+		return &UploadDocumentResult{Document: doc}, nil
+	}
+
+	return s.uploadParsed(ctx, kb, title, documentFileType(parsed.ContentType), int64(len(data)), parsed, onDuplicate, config)
+}
+
+// uploadParsed 对解析后的内容做重复检测，创建文档记录并按 chunking 异步分块与向量化
+func (s *RAGService) uploadParsed(ctx context.Context, kb *model.KnowledgeBase, title string, fileType string, fileSize int64, parsed *rag.ParsedContent, onDuplicate DuplicateAction, chunking ChunkingConfig) (*UploadDocumentResult, error) {
+	kbID := kb.ID
+	normalized := NormalizeDocumentText(parsed.Content)
+	digest := ContentDigest(normalized)
+	fingerprint := SimHash(normalized)
+
+	existing, err := s.kbRepo.FindDocumentByDigest(ctx, kbID, digest)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		switch {
+		case onDuplicate == DuplicateReplace:
+			return s.replaceDocument(ctx, existing, title, fileType, fileSize, parsed.Sections, fingerprint, chunking)
+		case onDuplicate == DuplicateForce:
+		case documentChunking(existing) != chunking:
+			// 内容相同但分块配置不同：按新配置重新分块并替换原有向量
+			return s.replaceDocument(ctx, existing, title, fileType, fileSize, parsed.Sections, fingerprint, chunking)
+		default:
+			return nil, &DuplicateDocumentError{Existing: existing}
+		}
+	}
+
+	// 近似重复检测失败不影响上传
+	var nearDuplicates []NearDuplicate
+	if docs, err := s.kbRepo.FindDocumentFingerprints(ctx, kbID); err != nil {
+		logger.Warn("Failed to check near-duplicate documents", zap.Error(err), zap.Int("kb_id", kbID))
+	} else {
+		nearDuplicates = findNearDuplicates(docs, digest, fingerprint)
+	}
+
 	// 创建文档记录
 	doc := &model.Document{
 		ID:              uuid.New(),
 		KnowledgeBaseID: kbID,
 		Title:           title,
 		Status:          model.DocumentStatusPending,
-		FileSize:        int64(len(fileContent)),
+		FileType:        fileType,
+		FileSize:        fileSize,
+		ContentDigest:   digest,
+		SimHash:         int64(fingerprint),
 	}
+	setDocumentChunking(doc, chunking)
 
 	if err := s.kbRepo.CreateDocument(ctx, doc); err != nil {
 		return nil, err
 	}
 
-	// 异步处理文档（在生产环境中应该使用消息队列）
-	go s.processDocumentAsync(context.Background(), doc.ID, kbID, fileContent, kb)
+	job, err := s.enqueueIndexJob(ctx, doc, parsed.Sections, false)
+	if err != nil {
+		return nil, err
+	}
 
-	return doc, nil
+	return &UploadDocumentResult{Document: doc, JobID: &job.ID, NearDuplicates: nearDuplicates}, nil
 }
 
-// processDocumentAsync 异步处理文档
-func (s *RAGService) processDocumentAsync(ctx context.Context, docID uuid.UUID, kbID int, content string, kb *model.KnowledgeBase) {
-	// 更新文档状态为处理中
-	doc, _ := s.kbRepo.FindDocumentByID(ctx, docID)
-	if doc == nil {
-		return
-	}
-	doc.Status = model.DocumentStatusProcessing
-	now := time.Now()
-	doc.ProcessingStartedAt = &now
-	s.kbRepo.UpdateDocument(ctx, doc)
-
-	// 文本分块
-	chunks := s.ChunkText(content, kb.ChunkSize, kb.ChunkOverlap)
-
-	// 创建文本块并获取向量表示
-	documentChunks := make([]*model.DocumentChunk, 0)
-
-	for i, chunkText := range chunks {
-		// 获取向量
-		embedding, err := s.GetTextEmbedding(ctx, chunkText)
-		if err != nil {
-			logger.Error("Failed to get embedding for chunk",
-				zap.Error(err),
-				zap.Int("chunk_index", i),
-				zap.String("doc_id", docID.String()))
-			doc.Status = model.DocumentStatusFailed
-			doc.ErrorMessage = fmt.Sprintf("Failed to embed chunk %d: %v", i, err)
-			s.kbRepo.UpdateDocument(ctx, doc)
-			return
-		}
-
-		// 转换为 pq.Float64Array
-		chunk := &model.DocumentChunk{
-			ID:        uuid.New(),
-			DocumentID: docID,
-			Content:   chunkText,
-			Embedding: embedding,
-			Metadata:  fmt.Sprintf(`{"chunk_index": %d, "total_chunks": %d}`, i, len(chunks)),
-		}
-
-		documentChunks = append(documentChunks, chunk)
+// replaceDocument 在已有文档 ID 下重新分块与向量化，锚点相同的文本块保留原 ID
+func (s *RAGService) replaceDocument(ctx context.Context, doc *model.Document, title string, fileType string, fileSize int64, sections []rag.Section, fingerprint uint64, chunking ChunkingConfig) (*UploadDocumentResult, error) {
+	if doc.Status == model.DocumentStatusPending || doc.Status == model.DocumentStatusProcessing {
+		return nil, ErrDocumentProcessing
 	}
 
-	// 批量保存文本块
-	if err := s.kbRepo.CreateChunks(ctx, documentChunks); err != nil {
-		logger.Error("Failed to create chunks", zap.Error(err))
-		doc.Status = model.DocumentStatusFailed
-		doc.ErrorMessage = fmt.Sprintf("Failed to save chunks: %v", err)
-		s.kbRepo.UpdateDocument(ctx, doc)
-		return
+	doc.Title = title
+	doc.FileType = fileType
+	doc.FileSize = fileSize
+	doc.SimHash = int64(fingerprint)
+	setDocumentChunking(doc, chunking)
+	doc.Status = model.DocumentStatusPending
+	doc.ErrorMessage = ""
+	if err := s.kbRepo.UpdateDocument(ctx, doc); err != nil {
+		return nil, err
 	}
 
-	// 更新文档状态和统计
-	doc.Status = model.DocumentStatusCompleted
-	doc.ChunkCount = len(documentChunks)
-	now = time.Now()
-	doc.ProcessingCompletedAt = &now
-	s.kbRepo.UpdateDocument(ctx, doc)
-
-	// 更新知识库的统计信息
-	s.kbRepo.IncrementDocumentCount(ctx, kbID)
-	s.kbRepo.IncrementTotalChunks(ctx, kbID, len(documentChunks))
+	job, err := s.enqueueIndexJob(ctx, doc, sections, true)
+	if err != nil {
+		return nil, err
+	}
 
-	logger.Info("Document processed successfully",
-		zap.String("doc_id", docID.String()),
-		zap.Int("chunks", len(documentChunks)))
+	return &UploadDocumentResult{Document: doc, JobID: &job.ID, Replaced: true}, nil
 }
 
-// SearchDocuments 搜索知识库中的文档
-func (s *RAGService) SearchDocuments(ctx context.Context, userID int, kbID int, query string, limit int) ([]*model.KBSearchResult, error) {
-	// 获取知识库并检查权限
-	_, err := s.GetKnowledgeBase(ctx, kbID, userID)
+// SearchDocuments 按搜索方式检索知识库中的文档，不重排序
+func (s *RAGService) SearchDocuments(ctx context.Context, userID int, kbID int, query string, mode SearchMode, topK int) ([]*model.KBSearchResult, error) {
+	resp, err := s.Search(ctx, userID, kbID, query, SearchOptions{Mode: mode, TopK: topK})
 	if err != nil {
 		return nil, err
 	}
+	return resp.Results, nil
+}
 
-	// 获取查询文本的向量表示
-	queryEmbedding, err := s.GetTextEmbedding(ctx, query)
+// Search 搜索知识库中的文档
+//
+// mode 为 vector 时按向量相似度，keyword 时按关键词全文搜索，hybrid（默认）时两路结果按倒数排名融合；
+// 结果只来自调用者自己的知识库。请求重排序时检索的 top_k 个结果作为候选，按重排序分数保留前 rerank_top_n 个。
+func (s *RAGService) Search(ctx context.Context, userID int, kbID int, query string, opts SearchOptions) (*SearchResponse, error) {
+	// 获取知识库并检查权限
+	kb, err := s.GetKnowledgeBase(ctx, kbID, userID)
 	if err != nil {
-		logger.Error("Failed to get query embedding", zap.Error(err))
 		return nil, err
 	}
 
-	if limit <= 0 || limit > 100 {
-		limit = 10
+	query = truncateRunes(strings.TrimSpace(query), maxSearchQueryRunes)
+	if query == "" {
+		return nil, ErrEmptySearchQuery
+	}
+	mode := opts.Mode
+	if mode == "" {
+		mode = SearchModeHybrid
+	}
+	topK := opts.TopK
+	if topK <= 0 {
+		topK = defaultSearchTopK
+	}
+	if topK > maxSearchTopK {
+		topK = maxSearchTopK
 	}
 
-	// 执行向量相似度搜索
-	results, err := s.kbRepo.SearchChunksByVector(ctx, kbID, queryEmbedding, limit)
+	results, err := searchChunks(ctx, s.kbRepo, s.knowledgeBaseEmbedder(kb), kbID, query, mode, topK)
 	if err != nil {
-		logger.Error("Failed to search chunks", zap.Error(err))
+		logger.Error("Failed to search chunks", zap.Error(err), zap.String("mode", string(mode)))
 		return nil, err
 	}
 
-	return results, nil
+	resp := &SearchResponse{Results: results, Metadata: SearchMetadata{Mode: mode}}
+	if opts.Rerank {
+		resp.Results, resp.Metadata.Rerank = rerankResults(ctx, s.reranker, query, results, opts.RerankTopN)
+	}
+	return resp, nil
 }
 
 // GetDocumentList 获取知识库的文档列表
@@ -404,7 +545,7 @@ func (s *RAGService) DeleteDocument(ctx context.Context, userID int, kbID int, d
 // BuildRAGContext 为对话构建 RAG 上下文
 func (s *RAGService) BuildRAGContext(ctx context.Context, userID int, kbID int, userQuery string, limit int) (string, error) {
 	// 搜索相关文档
-	results, err := s.SearchDocuments(ctx, userID, kbID, userQuery, limit)
+	results, err := s.SearchDocuments(ctx, userID, kbID, userQuery, SearchModeHybrid, limit)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,460 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrBatchNotFound 批量任务不存在或不属于当前用户
+	ErrBatchNotFound = errors.New("batch not found")
+	// ErrBatchInvalid 批量任务的请求列表无效（为空、超出条目上限或请求缺少模型）
+	ErrBatchInvalid = errors.New("invalid batch")
+)
+
+// RelayBatchStore 批量任务持久化
+type RelayBatchStore interface {
+	// Create 在一个事务中创建任务及其全部条目
+	Create(ctx context.Context, batch *model.RelayBatch, items []*model.RelayBatchItem) error
+	// FindByID 任务不存在时返回 nil, nil
+	FindByID(ctx context.Context, id uuid.UUID) (*model.RelayBatch, error)
+	Save(ctx context.Context, batch *model.RelayBatch) error
+	ListByStatus(ctx context.Context, statuses ...string) ([]*model.RelayBatch, error)
+	// ListItems 按输入顺序列出条目，指定 statuses 时只列出这些状态的条目
+	ListItems(ctx context.Context, batchID uuid.UUID, statuses ...string) ([]*model.RelayBatchItem, error)
+	// SaveItem 保存未完成条目的变更
+	SaveItem(ctx context.Context, item *model.RelayBatchItem) error
+	// FinishItem 保存条目结果并累加任务的成功或失败数
+	FinishItem(ctx context.Context, item *model.RelayBatchItem) error
+}
+
+// RelayBatchConfig 批量任务配置
+type RelayBatchConfig struct {
+	Concurrency    int           // 同时处理的条目数（所有任务共享）
+	MaxItems       int           // 单个任务的最大条目数
+	ReservationTTL time.Duration // 创建任务时预留额度的有效期
+	Interval       time.Duration // 待处理任务的检查间隔，创建任务时会立即唤醒
+}
+
+// NewRelayBatchConfig 由应用配置生成批量任务配置
+func NewRelayBatchConfig(cfg *config.BatchConfig) RelayBatchConfig {
+	return RelayBatchConfig{
+		Concurrency:    cfg.Concurrency,
+		MaxItems:       cfg.MaxItems,
+		ReservationTTL: time.Duration(cfg.ReservationTTLHours) * time.Hour,
+		Interval:       time.Minute,
+	}
+}
+
+// RelayBatchResult 单个条目的结果，Response 与 Error 二选一
+type RelayBatchResult struct {
+	Index    int             `json:"index"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    json.RawMessage `json:"error,omitempty"`
+}
+
+// BatchErrorFormatter 将条目的中转错误转换为结果中的错误对象
+type BatchErrorFormatter func(err error, rc *relay.RelayContext) interface{}
+
+// RelayBatchService 批量 Chat Completion 任务
+//
+// 创建任务时逐条应用 Token 绑定项目的默认参数并校验模型，再为每个条目各预留一笔额度，
+// 额度不足时整个任务不创建；条目处理完成后按实际用量结算各自的预留。
+// 条目在后台以有限并发经过正常的渠道选择与失败切换，单个条目失败不影响其它条目。
+// 任务与条目状态保存在数据库中，服务停止时进行中的条目回到待处理，重启后继续处理。
+type RelayBatchService struct {
+	store       RelayBatchStore
+	relay       *RelayService
+	tokens      *TokenService
+	config      RelayBatchConfig
+	formatError BatchErrorFormatter
+	now         func() time.Time
+
+	wake   chan struct{}
+	stopCh chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRelayBatchService 创建批量任务服务
+func NewRelayBatchService(store RelayBatchStore, relayService *RelayService, tokens *TokenService, cfg RelayBatchConfig) *RelayBatchService {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 4
+	}
+	if cfg.MaxItems <= 0 {
+		cfg.MaxItems = 1000
+	}
+	if cfg.ReservationTTL <= 0 {
+		cfg.ReservationTTL = 24 * time.Hour
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	return &RelayBatchService{
+		store:       store,
+		relay:       relayService,
+		tokens:      tokens,
+		config:      cfg,
+		formatError: defaultBatchError,
+		now:         time.Now,
+		wake:        make(chan struct{}, 1),
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// SetErrorFormatter 设置条目错误的格式（入口处转换为 OpenAI 格式的错误对象）
+func (s *RelayBatchService) SetErrorFormatter(format BatchErrorFormatter) {
+	s.formatError = format
+}
+
+// defaultBatchError 未设置错误格式时只记录错误信息
+func defaultBatchError(err error, rc *relay.RelayContext) interface{} {
+	return map[string]string{"message": err.Error(), "type": "api_error"}
+}
+
+// Create 创建批量任务，条目的顺序即结果的顺序
+func (s *RelayBatchService) Create(ctx context.Context, token *model.Token, clientIP string, reqs []*relay.ChatCompletionRequest) (*model.RelayBatch, error) {
+	if token == nil {
+		return nil, fmt.Errorf("%w: batch requires an API token", ErrQuotaTokenRejected)
+	}
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("%w: batch must contain at least one request", ErrBatchInvalid)
+	}
+	if len(reqs) > s.config.MaxItems {
+		return nil, fmt.Errorf("%w: batch has %d requests, the limit is %d", ErrBatchInvalid, len(reqs), s.config.MaxItems)
+	}
+
+	// 项目的默认参数在创建时应用，条目以最终的请求保存
+	rc, err := relay.NewRelayContextBuilder("", relay.EndpointChatCompletions).
+		Token(token).
+		Client(clientIP).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+	projectCtx := relay.WithRelayContext(ctx, rc)
+	for i, req := range reqs {
+		if req == nil {
+			return nil, fmt.Errorf("%w: request %d is empty", ErrBatchInvalid, i)
+		}
+		if err := s.relay.ApplyProject(projectCtx, token, req); err != nil {
+			return nil, err
+		}
+		if req.Model == "" {
+			return nil, fmt.Errorf("%w: request %d: model is required", ErrBatchInvalid, i)
+		}
+		if !token.ValidateModel(req.Model) {
+			return nil, fmt.Errorf("%w: request %d: %s", model.ErrModelNotAllowed, i, req.Model)
+		}
+		req.Stream = false
+	}
+
+	var reservations []BatchReservation
+	if s.relay.quotaGuard != nil {
+		reservations, err = s.relay.quotaGuard.ReserveBatch(ctx, rc, reqs, s.config.ReservationTTL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	batch := &model.RelayBatch{
+		ID:           uuid.New(),
+		UserID:       token.UserID,
+		TokenID:      token.ID,
+		ProjectID:    rc.ProjectID,
+		MetadataTags: rc.Tags,
+		ClientIP:     clientIP,
+		Status:       model.RelayBatchStatusPending,
+		Total:        len(reqs),
+	}
+	items := make([]*model.RelayBatchItem, len(reqs))
+	for i, req := range reqs {
+		body, err := json.Marshal(req)
+		if err != nil {
+			s.releaseReservations(reservations)
+			return nil, fmt.Errorf("failed to marshal request %d: %w", i, err)
+		}
+		items[i] = &model.RelayBatchItem{
+			BatchID: batch.ID,
+			Index:   i,
+			Status:  model.RelayBatchStatusPending,
+			Request: body,
+		}
+		if reservations != nil {
+			items[i].ReservationID = reservations[i].ID
+			items[i].Reserved = reservations[i].Amount
+		}
+	}
+	if err := s.store.Create(ctx, batch, items); err != nil {
+		s.releaseReservations(reservations)
+		return nil, fmt.Errorf("failed to create batch: %w", err)
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return batch, nil
+}
+
+// Get 查询用户的批量任务
+func (s *RelayBatchService) Get(ctx context.Context, userID int, id uuid.UUID) (*model.RelayBatch, error) {
+	batch, err := s.store.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil || batch.UserID != userID {
+		return nil, ErrBatchNotFound
+	}
+	return batch, nil
+}
+
+// Results 按输入顺序返回已处理条目的结果，尚未处理的条目不包含在内
+func (s *RelayBatchService) Results(ctx context.Context, userID int, id uuid.UUID) ([]RelayBatchResult, error) {
+	if _, err := s.Get(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	items, err := s.store.ListItems(ctx, id, model.RelayBatchStatusCompleted, model.RelayBatchStatusFailed)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]RelayBatchResult, len(items))
+	for i, item := range items {
+		results[i] = RelayBatchResult{Index: item.Index}
+		if item.Status == model.RelayBatchStatusCompleted {
+			results[i].Response = json.RawMessage(item.Response)
+		} else {
+			results[i].Error = json.RawMessage(item.Error)
+		}
+	}
+	return results, nil
+}
+
+// Start 启动后台处理：先继续上次中断的任务，之后处理新任务
+func (s *RelayBatchService) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		s.processBatches(ctx, model.RelayBatchStatusRunning, model.RelayBatchStatusPending)
+
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-s.wake:
+				s.processBatches(ctx, model.RelayBatchStatusPending)
+			case <-ticker.C:
+				s.processBatches(ctx, model.RelayBatchStatusPending)
+			}
+		}
+	}()
+}
+
+// Stop 停止后台处理，取消进行中的条目，未完成的条目下次启动时继续处理
+func (s *RelayBatchService) Stop() {
+	close(s.stopCh)
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// processBatches 按创建顺序逐个处理指定状态的任务
+func (s *RelayBatchService) processBatches(ctx context.Context, statuses ...string) {
+	batches, err := s.store.ListByStatus(ctx, statuses...)
+	if err != nil {
+		logger.Error("failed to list relay batches", zap.Error(err))
+		return
+	}
+
+	for _, batch := range batches {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.Run(ctx, batch); err != nil && ctx.Err() == nil {
+			// 任务保持处理中，下次启动时继续
+			logger.Error("relay batch failed", zap.String("batch_id", batch.ID.String()), zap.Error(err))
+		}
+	}
+}
+
+// Run 以有限并发处理任务中待处理的条目，全部处理完成后标记任务完成
+func (s *RelayBatchService) Run(ctx context.Context, batch *model.RelayBatch) error {
+	batch.Status = model.RelayBatchStatusRunning
+	if err := s.store.Save(ctx, batch); err != nil {
+		return fmt.Errorf("failed to save batch: %w", err)
+	}
+
+	items, err := s.store.ListItems(ctx, batch.ID, model.RelayBatchStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to list batch items: %w", err)
+	}
+
+	token, tokenErr, err := s.batchToken(ctx, batch)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s.config.Concurrency)
+	for _, item := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(item *model.RelayBatchItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var ok bool
+			if tokenErr != nil {
+				ok = s.processed(ctx, batch, item, nil, tokenErr, nil)
+			} else {
+				ok = s.processItem(ctx, batch, token, item)
+			}
+			if !ok {
+				return
+			}
+			mu.Lock()
+			if item.Status == model.RelayBatchStatusCompleted {
+				batch.Completed++
+			} else {
+				batch.Failed++
+			}
+			mu.Unlock()
+		}(item)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	now := s.now()
+	batch.Status = model.RelayBatchStatusCompleted
+	batch.CompletedAt = &now
+	if err := s.store.Save(ctx, batch); err != nil {
+		return fmt.Errorf("failed to save batch: %w", err)
+	}
+	logger.Info("relay batch completed",
+		zap.String("batch_id", batch.ID.String()),
+		zap.Int("completed", batch.Completed),
+		zap.Int("failed", batch.Failed))
+	return nil
+}
+
+// batchToken 加载任务使用的 Token；Token 已删除、禁用或过期时返回 tokenErr，剩余条目不再调用上游。
+// 条目的额度已在创建时预留，额度耗尽的 Token 仍继续处理
+func (s *RelayBatchService) batchToken(ctx context.Context, batch *model.RelayBatch) (token *model.Token, tokenErr error, err error) {
+	token, err = s.tokens.GetTokenByID(ctx, batch.TokenID)
+	if errors.Is(err, model.ErrTokenInvalid) {
+		return nil, fmt.Errorf("%w: token %d no longer exists", ErrQuotaTokenRejected, batch.TokenID), nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !token.IsValid() && !tokenExhausted(token) {
+		return nil, fmt.Errorf("%w: token %d is no longer valid", ErrQuotaTokenRejected, token.ID), nil
+	}
+	return token, nil, nil
+}
+
+// processItem 经过正常的中转流程处理一个条目，返回条目是否已处理完成
+func (s *RelayBatchService) processItem(ctx context.Context, batch *model.RelayBatch, token *model.Token, item *model.RelayBatchItem) bool {
+	var req relay.ChatCompletionRequest
+	if err := json.Unmarshal(item.Request, &req); err != nil {
+		return s.processed(ctx, batch, item, nil, fmt.Errorf("invalid batch request: %w", err), nil)
+	}
+
+	rc, err := relay.NewRelayContextBuilder(fmt.Sprintf("batch-%s-%d", batch.ID, item.Index), relay.EndpointChatCompletions).
+		Token(token).
+		Client(batch.ClientIP).
+		Routing("", "", relay.PriorityBatch).
+		Tags(batch.MetadataTags).
+		Build()
+	if err != nil {
+		return s.processed(ctx, batch, item, nil, err, nil)
+	}
+	rc.ProjectID = batch.ProjectID
+	// 使用创建任务时为该条目预留的额度，中转结束时按实际用量结算
+	rc.ReservationID = item.ReservationID
+	rc.Reserved = item.Reserved
+
+	resp, err := s.relay.RelayChatCompletion(relay.WithRelayContext(ctx, rc), &req)
+	return s.processed(ctx, batch, item, resp, err, rc)
+}
+
+// processed 记录条目的结果；服务停止导致的失败不记录，条目保持待处理
+func (s *RelayBatchService) processed(ctx context.Context, batch *model.RelayBatch, item *model.RelayBatchItem, resp *relay.ChatCompletionResponse, relayErr error, rc *relay.RelayContext) bool {
+	// 预留已随中转结束结算或释放（没有进入中转时在这里释放），继续处理时按单个请求重新预留
+	if rc == nil && item.ReservationID != "" {
+		s.releaseReservations([]BatchReservation{{ID: item.ReservationID}})
+	}
+	item.ReservationID = ""
+	item.Reserved = 0
+	saveCtx := context.WithoutCancel(ctx)
+
+	if relayErr != nil && ctx.Err() != nil {
+		if err := s.store.SaveItem(saveCtx, item); err != nil {
+			logger.Error("failed to save batch item",
+				zap.String("batch_id", batch.ID.String()),
+				zap.Int("index", item.Index),
+				zap.Error(err))
+		}
+		return false
+	}
+
+	var err error
+	if relayErr == nil {
+		item.Status = model.RelayBatchStatusCompleted
+		item.Response, err = json.Marshal(resp)
+	} else {
+		item.Status = model.RelayBatchStatusFailed
+		item.Error, err = json.Marshal(s.formatError(relayErr, rc))
+	}
+	if err != nil {
+		item.Status = model.RelayBatchStatusFailed
+		item.Error, _ = json.Marshal(defaultBatchError(err, rc))
+	}
+	now := s.now()
+	item.CompletedAt = &now
+
+	if err := s.store.FinishItem(saveCtx, item); err != nil {
+		logger.Error("failed to save batch item result",
+			zap.String("batch_id", batch.ID.String()),
+			zap.Int("index", item.Index),
+			zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// releaseReservations 任务创建失败时释放已预留的额度
+func (s *RelayBatchService) releaseReservations(reservations []BatchReservation) {
+	if s.relay.quotaGuard != nil && reservations != nil {
+		s.relay.quotaGuard.ReleaseBatch(reservations)
+	}
+}
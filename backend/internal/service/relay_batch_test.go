@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memBatchStore 内存中的批量任务存储
+type memBatchStore struct {
+	mu      sync.Mutex
+	batches map[uuid.UUID]*model.RelayBatch
+	items   map[uuid.UUID][]*model.RelayBatchItem
+}
+
+func newMemBatchStore() *memBatchStore {
+	return &memBatchStore{batches: make(map[uuid.UUID]*model.RelayBatch), items: make(map[uuid.UUID][]*model.RelayBatchItem)}
+}
+
+func (m *memBatchStore) Create(ctx context.Context, batch *model.RelayBatch, items []*model.RelayBatchItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := *batch
+	m.batches[batch.ID] = &saved
+	for _, item := range items {
+		copied := *item
+		m.items[batch.ID] = append(m.items[batch.ID], &copied)
+	}
+	return nil
+}
+
+func (m *memBatchStore) FindByID(ctx context.Context, id uuid.UUID) (*model.RelayBatch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	batch, ok := m.batches[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *batch
+	return &copied, nil
+}
+
+func (m *memBatchStore) Save(ctx context.Context, batch *model.RelayBatch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := *batch
+	m.batches[batch.ID] = &saved
+	return nil
+}
+
+func (m *memBatchStore) ListByStatus(ctx context.Context, statuses ...string) ([]*model.RelayBatch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var batches []*model.RelayBatch
+	for _, batch := range m.batches {
+		for _, status := range statuses {
+			if batch.Status == status {
+				copied := *batch
+				batches = append(batches, &copied)
+			}
+		}
+	}
+	return batches, nil
+}
+
+func (m *memBatchStore) ListItems(ctx context.Context, batchID uuid.UUID, statuses ...string) ([]*model.RelayBatchItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var items []*model.RelayBatchItem
+	for _, item := range m.items[batchID] {
+		match := len(statuses) == 0
+		for _, status := range statuses {
+			match = match || item.Status == status
+		}
+		if match {
+			copied := *item
+			items = append(items, &copied)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Index < items[j].Index })
+	return items, nil
+}
+
+func (m *memBatchStore) SaveItem(ctx context.Context, item *model.RelayBatchItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *item
+	m.items[item.BatchID][item.Index] = &copied
+	return nil
+}
+
+func (m *memBatchStore) FinishItem(ctx context.Context, item *model.RelayBatchItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *item
+	m.items[item.BatchID][item.Index] = &copied
+	if item.Status == model.RelayBatchStatusFailed {
+		m.batches[item.BatchID].Failed++
+	} else {
+		m.batches[item.BatchID].Completed++
+	}
+	return nil
+}
+
+// batchUpstream 按用户消息应答，消息为 reject 时返回 400
+func batchUpstream(hits *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		var req relay.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt := req.Messages[len(req.Messages)-1].Content
+		w.Header().Set("Content-Type", "application/json")
+		if prompt == "reject" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"Invalid prompt.","type":"invalid_request_error"}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      "chatcmpl-" + prompt,
+			"choices": []map[string]interface{}{{"index": 0, "message": map[string]string{"role": "assistant", "content": "echo " + prompt}, "finish_reason": "stop"}},
+			"usage":   map[string]int{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+		})
+	}
+}
+
+func batchRequests(prompts ...string) []*relay.ChatCompletionRequest {
+	reqs := make([]*relay.ChatCompletionRequest, len(prompts))
+	for i, prompt := range prompts {
+		reqs[i] = &relay.ChatCompletionRequest{
+			Model:     "gpt-4",
+			Messages:  []relay.ChatMessage{{Role: "user", Content: prompt}},
+			MaxTokens: 20,
+			Stream:    true,
+		}
+	}
+	return reqs
+}
+
+// newTestBatchService 带额度预检的批量任务服务，返回调用方使用的 Token
+func newTestBatchService(t *testing.T, hits *int32, limit int64) (*RelayBatchService, *memBatchStore, *quotaTokens, *billing.QuotaManager) {
+	t.Helper()
+	s, _ := newTestRelayService(t, batchUpstream(hits))
+	guard, tokens, quota := newTestQuotaGuard(t, limit, 0)
+	s.SetQuotaGuard(guard)
+	store := newMemBatchStore()
+	return NewRelayBatchService(store, s, guard.tokens, RelayBatchConfig{Concurrency: 2, MaxItems: 5}), store, tokens, quota
+}
+
+func TestRelayBatchKeepsInputOrderAndIsolatesFailures(t *testing.T) {
+	var hits int32
+	batches, store, tokens, quota := newTestBatchService(t, &hits, 100000)
+	ctx := context.Background()
+
+	token := tokens.token
+	batch, err := batches.Create(ctx, &token, "127.0.0.1", batchRequests("one", "reject", "three"))
+	require.NoError(t, err)
+	assert.Equal(t, model.RelayBatchStatusPending, batch.Status)
+	assert.Equal(t, 3, batch.Total)
+	assert.Positive(t, quota.GetReserved(tokenQuotaKey(3)), "quota is reserved when the batch is created")
+
+	require.NoError(t, batches.Run(ctx, batch))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&hits))
+
+	saved, err := batches.Get(ctx, 42, batch.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.RelayBatchStatusCompleted, saved.Status)
+	assert.Equal(t, 2, saved.Completed)
+	assert.Equal(t, 1, saved.Failed)
+	assert.NotNil(t, saved.CompletedAt)
+
+	results, err := batches.Results(ctx, 42, batch.ID)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	for i, result := range results {
+		assert.Equal(t, i, result.Index)
+	}
+	var first relay.ChatCompletionResponse
+	require.NoError(t, json.Unmarshal(results[0].Response, &first))
+	assert.Equal(t, "chatcmpl-one", first.ID)
+	assert.Nil(t, results[1].Response)
+	assert.Contains(t, string(results[1].Error), "Invalid prompt.")
+	assert.NotNil(t, results[2].Response)
+
+	// 每个成功的条目按实际用量结算，预留全部退回
+	assert.Zero(t, quota.GetReserved(tokenQuotaKey(3)))
+	assert.EqualValues(t, 2*15, tokens.used())
+
+	// 条目以非流式请求保存
+	items, _ := store.ListItems(ctx, batch.ID)
+	var req relay.ChatCompletionRequest
+	require.NoError(t, json.Unmarshal(items[0].Request, &req))
+	assert.False(t, req.Stream)
+
+	_, err = batches.Get(ctx, 7, batch.ID)
+	assert.ErrorIs(t, err, ErrBatchNotFound, "batches are only visible to their owner")
+}
+
+func TestRelayBatchReservesWholeBatchUpFront(t *testing.T) {
+	var hits int32
+	req := batchRequests("one")[0]
+	estimate := int64(countPromptTokens(req) + req.MaxTokens)
+	batches, store, tokens, quota := newTestBatchService(t, &hits, 3*estimate-1)
+	ctx := context.Background()
+
+	token := tokens.token
+	_, err := batches.Create(ctx, &token, "127.0.0.1", batchRequests("one", "two", "three"))
+	assert.True(t, errors.Is(err, billing.ErrInsufficientQuota), "got %v", err)
+	assert.Zero(t, quota.GetReserved(tokenQuotaKey(3)), "partial reservations are released")
+	assert.Empty(t, store.batches)
+
+	_, err = batches.Create(ctx, &token, "127.0.0.1", batchRequests("a", "b", "c", "d", "e", "f"))
+	assert.ErrorIs(t, err, ErrBatchInvalid)
+
+	reqs := batchRequests("one")
+	reqs[0].Model = ""
+	_, err = batches.Create(ctx, &token, "127.0.0.1", reqs)
+	assert.ErrorIs(t, err, ErrBatchInvalid)
+	assert.Zero(t, atomic.LoadInt32(&hits))
+}
+
+func TestRelayBatchResumesPendingItems(t *testing.T) {
+	var hits int32
+	batches, store, tokens, _ := newTestBatchService(t, &hits, 100000)
+	ctx := context.Background()
+
+	token := tokens.token
+	batch, err := batches.Create(ctx, &token, "127.0.0.1", batchRequests("one", "two"))
+	require.NoError(t, err)
+
+	// 模拟重启前已处理完第一个条目
+	done := store.items[batch.ID][0]
+	done.Status = model.RelayBatchStatusCompleted
+	done.Response = []byte(`{"id":"chatcmpl-before-restart"}`)
+	store.batches[batch.ID].Status = model.RelayBatchStatusRunning
+	store.batches[batch.ID].Completed = 1
+
+	batches.Start()
+	defer batches.Stop()
+	require.Eventually(t, func() bool {
+		saved, _ := store.FindByID(ctx, batch.ID)
+		return saved.Status == model.RelayBatchStatusCompleted
+	}, 5e9, 1e7)
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&hits), "only the pending item is sent upstream")
+	saved, _ := store.FindByID(ctx, batch.ID)
+	assert.Equal(t, 2, saved.Completed)
+	results, err := batches.Results(ctx, 42, batch.ID)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.JSONEq(t, `{"id":"chatcmpl-before-restart"}`, string(results[0].Response))
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayModelFallbackChain(t *testing.T) {
+	var upstreamModels []string
+	s, chA := newTestRelayService(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		upstreamModels = append(upstreamModels, body.Model)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      "chatcmpl-1",
+			"model":   body.Model,
+			"choices": []map[string]interface{}{{"index": 0, "message": map[string]string{"role": "assistant", "content": "ok"}, "finish_reason": "stop"}},
+			"usage":   map[string]int{"prompt_tokens": 12, "completion_tokens": 9, "total_tokens": 21},
+		})
+	})
+	guard, _, _ := newTestQuotaGuard(t, 100000, 0)
+	s.SetQuotaGuard(guard)
+
+	// 每个模型一个渠道：A、B 的渠道断路器已打开，只有 C 可用
+	chA.SupportModels = "gpt-4o"
+	chB := &model.Channel{ID: 8, Name: "openai-b", Type: "openai", APIKey: "sk-test", BaseURL: chA.BaseURL, Weight: 1, SupportModels: "gpt-4o-mini"}
+	chC := &model.Channel{ID: 9, Name: "openai-c", Type: "openai", APIKey: "sk-test", BaseURL: chA.BaseURL, Weight: 1, SupportModels: "gpt-4"}
+	relayChannels := make([]*relay.Channel, 0, 3)
+	for _, ch := range []*model.Channel{chA, chB, chC} {
+		s.channels[strconv.Itoa(ch.ID)] = ch
+		relayChannels = append(relayChannels, toRelayChannel(ch))
+	}
+	require.NoError(t, s.cache.RefreshCache(relayChannels))
+	s.TripCircuitBreaker(chA.ID)
+	s.TripCircuitBreaker(chB.ID)
+
+	// A→B 为精确规则，B→C 通过通配符规则展开；其它分组的规则不生效
+	require.NoError(t, s.fallbackRules.Set([]*relay.FallbackRule{
+		{ModelPattern: "gpt-4o", Models: []string{"gpt-4o-mini"}},
+		{ModelPattern: "gpt-4o-*", Models: []string{"gpt-4"}},
+		{ModelPattern: "gpt-4o", UserGroup: "vip", Models: []string{"gpt-3.5-turbo"}},
+	}))
+
+	rc := newQuotaRelayContext(t, "req-1")
+	req := quotaTestRequest()
+	req.Model = "gpt-4o"
+	resp, err := s.RelayChatCompletion(relay.WithRelayContext(context.Background(), rc), req)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"gpt-4"}, upstreamModels)
+	assert.Equal(t, "gpt-4", rc.Model, "billing and logs use the model actually served")
+	assert.Equal(t, "gpt-4o", rc.RequestedModel)
+	assert.Equal(t, chC.ID, rc.ChannelID)
+	assert.Positive(t, rc.Cost, "cost is priced on the fallback model")
+	assert.Equal(t, []string{"model gpt-4o has no available channel; fell back to gpt-4"}, resp.Warnings)
+
+	// 降级链全部不可用时返回原错误并恢复请求的模型
+	s.TripCircuitBreaker(chC.ID)
+	rc = newQuotaRelayContext(t, "req-2")
+	req = quotaTestRequest()
+	req.Model = "gpt-4o"
+	_, err = s.RelayChatCompletion(relay.WithRelayContext(context.Background(), rc), req)
+	require.ErrorIs(t, err, relay.ErrNoAvailableChannel)
+	assert.Equal(t, "gpt-4o", rc.Model)
+	assert.Empty(t, rc.RequestedModel)
+	assert.Empty(t, rc.Warnings)
+	assert.Len(t, upstreamModels, 1)
+}
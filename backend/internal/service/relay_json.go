@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/adapter"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+)
+
+// jsonOutput 按选项累积的模型输出，用于 strict_json 校验；只返回工具调用的选项不校验
+type jsonOutput struct {
+	content   map[int]*strings.Builder
+	toolCalls map[int]bool
+}
+
+func newJSONOutput() *jsonOutput {
+	return &jsonOutput{content: make(map[int]*strings.Builder), toolCalls: make(map[int]bool)}
+}
+
+// add 累积一个选项的消息或流式增量
+func (o *jsonOutput) add(index int, m *adapter.Message) {
+	if m == nil {
+		return
+	}
+	if len(m.ToolCalls) > 0 {
+		o.toolCalls[index] = true
+	}
+	sb, ok := o.content[index]
+	if !ok {
+		sb = &strings.Builder{}
+		o.content[index] = sb
+	}
+	if text, ok := m.Content.(string); ok {
+		sb.WriteString(text)
+	}
+}
+
+// validate 校验每个选项的输出是否为合法的 JSON，没有任何输出时同样视为不合法
+func (o *jsonOutput) validate(format string) error {
+	indexes := make([]int, 0, len(o.content))
+	for index := range o.content {
+		indexes = append(indexes, index)
+	}
+	if len(indexes) == 0 {
+		return relay.ValidateJSONOutput("", format)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		content := o.content[index].String()
+		if o.toolCalls[index] && strings.TrimSpace(content) == "" {
+			continue
+		}
+		if err := relay.ValidateJSONOutput(content, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateJSONResponse 校验非流式响应的输出
+func validateJSONResponse(resp *adapter.OpenAIResponse, format string) error {
+	output := newJSONOutput()
+	for i := range resp.Choices {
+		output.add(resp.Choices[i].Index, &resp.Choices[i].Message)
+	}
+	return output.validate(format)
+}
+
+// withJSONRetry strict_json 请求的输出不是合法 JSON 时，在同一渠道上追加要求只输出 JSON 的系统提示重试一次，
+// 仍不合法时返回 relay.InvalidJSONError；未开启 strict_json 或其它错误原样返回
+//
+// 两次尝试都记录在统一日志中，计费以最后一次尝试的用量为准。
+func withJSONRetry(req *relay.ChatCompletionRequest, run func(req *relay.ChatCompletionRequest) error) error {
+	err := run(req)
+	if !req.StrictJSON || !errors.Is(err, relay.ErrInvalidJSONOutput) {
+		return err
+	}
+	if err = run(relay.WithJSONNudge(req)); errors.Is(err, relay.ErrInvalidJSONOutput) {
+		return &relay.InvalidJSONError{Model: req.Model, Attempts: 2, Err: err}
+	}
+	return err
+}
+
+// responseFormat 请求的 response_format，未设置时为 nil，不向上游发送 null
+func responseFormat(req *relay.ChatCompletionRequest) interface{} {
+	if len(req.ResponseFormat) == 0 {
+		return nil
+	}
+	return req.ResponseFormat
+}
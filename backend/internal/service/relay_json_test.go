@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jsonUpstream 按顺序返回 outputs 中的内容，记录每次请求的 response_format 与最后一条消息
+type jsonUpstream struct {
+	outputs  []string
+	stream   bool
+	hits     int32
+	formats  []interface{}
+	lastMsgs []string
+}
+
+func (u *jsonUpstream) handle(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ResponseFormat interface{}         `json:"response_format"`
+		StrictJSON     *bool               `json:"strict_json"`
+		Messages       []relay.ChatMessage `json:"messages"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	n := int(atomic.AddInt32(&u.hits, 1)) - 1
+	u.formats = append(u.formats, body.ResponseFormat)
+	u.lastMsgs = append(u.lastMsgs, body.Messages[len(body.Messages)-1].Content)
+	if body.StrictJSON != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	output, _ := json.Marshal(u.outputs[n%len(u.outputs)])
+	if !u.stream {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"c%d","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":%s}}],
+			"usage":{"prompt_tokens":10,"completion_tokens":%d,"total_tokens":%d}}`, n, output, n+1, n+11)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprintf(w, "data: {\"id\":\"c%d\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":%s}}]}\n\n", n, output)
+	fmt.Fprintf(w, "data: {\"id\":\"c%d\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}],\"usage\":{\"prompt_tokens\":10,\"completion_tokens\":%d,\"total_tokens\":%d}}\n\n", n, n+1, n+11)
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func jsonRequest(strict bool) *relay.ChatCompletionRequest {
+	return &relay.ChatCompletionRequest{
+		Model:          "gpt-4",
+		Messages:       []relay.ChatMessage{{Role: "user", Content: "list two colors"}},
+		ResponseFormat: map[string]interface{}{"type": "json_object"},
+		StrictJSON:     strict,
+	}
+}
+
+func TestRelayStrictJSONRetriesWithNudge(t *testing.T) {
+	upstream := &jsonUpstream{outputs: []string{"Sure! Here are two colors: red, blue", `{"colors":["red","blue"]}`}}
+	s, _ := newTestRelayService(t, upstream.handle)
+
+	rc := newTestRelayContext(t)
+	req := jsonRequest(true)
+	resp, err := s.RelayChatCompletion(relay.WithRelayContext(context.Background(), rc), req)
+	require.NoError(t, err)
+	assert.Equal(t, `{"colors":["red","blue"]}`, resp.Choices[0].Message.Content)
+
+	require.EqualValues(t, 2, upstream.hits)
+	assert.Equal(t, map[string]interface{}{"type": "json_object"}, upstream.formats[0], "response_format is forwarded")
+	assert.Equal(t, "list two colors", upstream.lastMsgs[0])
+	assert.Equal(t, relay.JSONNudge, upstream.lastMsgs[1], "the retry appends a system nudge")
+	assert.Len(t, req.Messages, 1, "the caller's messages are not modified")
+
+	require.Len(t, rc.Attempts, 2)
+	assert.ErrorIs(t, rc.Attempts[0].Err, relay.ErrInvalidJSONOutput)
+	assert.NoError(t, rc.Attempts[1].Err)
+	assert.Equal(t, 2, rc.Usage.CompletionTokens, "the request is billed for the final attempt")
+}
+
+func TestRelayStrictJSONFailsAfterRetry(t *testing.T) {
+	upstream := &jsonUpstream{outputs: []string{"```json\n{\"colors\":[]}\n```", `["red"]`}}
+	s, _ := newTestRelayService(t, upstream.handle)
+
+	_, err := s.RelayChatCompletion(relay.WithRelayContext(context.Background(), newTestRelayContext(t)), jsonRequest(true))
+	var invalid *relay.InvalidJSONError
+	require.True(t, errors.As(err, &invalid), "got %v", err)
+	assert.Equal(t, 2, invalid.Attempts)
+	assert.Equal(t, "model gpt-4 returned invalid JSON after 2 attempts: top-level value is not an object", err.Error())
+	assert.Equal(t, "invalid_json", relay.ErrorClass(err))
+	assert.EqualValues(t, 2, upstream.hits, "invalid output does not fail over to other channels")
+
+	// 未开启 strict_json 时原样返回
+	upstream.hits = 0
+	resp, err := s.RelayChatCompletion(relay.WithRelayContext(context.Background(), newTestRelayContext(t)), jsonRequest(false))
+	require.NoError(t, err)
+	assert.Contains(t, resp.Choices[0].Message.Content, "```json")
+	assert.EqualValues(t, 1, upstream.hits)
+}
+
+func TestRelayStrictJSONStreamBuffersUntilValid(t *testing.T) {
+	upstream := &jsonUpstream{outputs: []string{`{"colors": ["red",`, `{"colors":["red","blue"]}`}, stream: true}
+	s, _ := newTestRelayService(t, upstream.handle)
+
+	rc := newTestRelayContext(t)
+	var content strings.Builder
+	var ids []string
+	err := s.RelayChatCompletionStream(relay.WithRelayContext(context.Background(), rc), jsonRequest(true),
+		func(chunk *relay.ChatCompletionResponse) error {
+			ids = append(ids, chunk.ID)
+			for _, choice := range chunk.Choices {
+				if choice.Delta != nil {
+					content.WriteString(choice.Delta.Content)
+				}
+			}
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, `{"colors":["red","blue"]}`, content.String())
+	for _, id := range ids {
+		assert.Equal(t, "c1", id, "chunks of the invalid response are never forwarded")
+	}
+	require.Len(t, rc.Attempts, 2)
+	assert.ErrorIs(t, rc.Attempts[0].Err, relay.ErrInvalidJSONOutput)
+	assert.Equal(t, 2, rc.Usage.CompletionTokens)
+}
+
+func TestRelayJSONModeSelectsCapableChannels(t *testing.T) {
+	upstream := &jsonUpstream{outputs: []string{`{"ok":true}`}}
+	s := newTestToolsRelayService(t, upstream.handle,
+		toolChannel(t, 7, "openai", "gpt-4", string(relay.FeatureJSONMode)),
+		toolChannel(t, 8, "openai", "gpt-4"),
+		toolChannel(t, 9, "claude", "gpt-4"),
+	)
+
+	for i := 0; i < 6; i++ {
+		rc := newTestRelayContext(t)
+		_, err := s.RelayChatCompletion(relay.WithRelayContext(context.Background(), rc), jsonRequest(false))
+		require.NoError(t, err)
+		assert.Equal(t, 8, rc.ChannelID, "only channel 8 supports JSON mode")
+	}
+
+	s = newTestToolsRelayService(t, upstream.handle, toolChannel(t, 9, "claude", "gpt-4"))
+	_, err := s.RelayChatCompletion(relay.WithRelayContext(context.Background(), newTestRelayContext(t)), jsonRequest(false))
+	assert.EqualError(t, err, "no channel for model gpt-4 supports json_mode (channel 9 lacks json_mode)")
+}
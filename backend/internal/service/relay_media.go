@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/logtail"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+)
+
+// mediaTimeout 图像生成与语音请求的上游超时，生成耗时明显长于 Embedding
+const mediaTimeout = 5 * time.Minute
+
+// RelayImageGeneration 中转图像生成请求，只在标记了 images 能力的渠道间选择，按生成的图片数计费
+func (s *RelayService) RelayImageGeneration(ctx context.Context, req *relay.ImageGenerationRequest) (*relay.ImageGenerationResponse, error) {
+	ctx, rc := s.beginRelay(ctx, relay.EndpointImages, req, req.Model, false, []string{relay.FeatureImages})
+	content := mediaTailContent(req.Prompt)
+	if err := s.reserveUnits(ctx, rc, req.Model, req.Images()); err != nil {
+		s.finish(ctx, rc, err, content)
+		return nil, err
+	}
+
+	var resp *relay.ImageGenerationResponse
+	err := s.withFailoverFeature(ctx, rc, relay.FeatureImages, func(ctx context.Context, channel *model.Channel) error {
+		var err error
+		resp, err = s.imageGeneration(ctx, rc, channel, req)
+		return err
+	})
+	s.finish(ctx, rc, err, content)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// imageGeneration 通过 ImageHandler 在指定渠道上执行一次图像生成请求
+func (s *RelayService) imageGeneration(ctx context.Context, rc *relay.RelayContext, channel *model.Channel, req *relay.ImageGenerationRequest) (*relay.ImageGenerationResponse, error) {
+	start := time.Now()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal image request: %w", err)
+	}
+
+	hresp, err := relay.NewImageHandler(singleChannelClient(ctx, channel, mediaTimeout)).Handle(upstreamContext(ctx, rc), &relay.HandlerRequest{
+		Type:     relay.RequestTypeImage,
+		ID:       rc.RequestID,
+		Model:    req.Model,
+		Endpoint: "/images/generations",
+		Headers:  handlerHeaders(rc, channel),
+		Body:     body,
+	})
+	recordHandlerRequestID(rc, hresp)
+	if err != nil {
+		err = s.handlerError(rc, hresp, err)
+		rc.EndAttempt(start, nil, err, nil)
+		return nil, err
+	}
+
+	rc.EnterPhase(relay.PhaseGeneration)
+	var resp relay.ImageGenerationResponse
+	if err := json.Unmarshal(hresp.Body, &resp); err != nil {
+		err = fmt.Errorf("failed to parse image response: %w", err)
+		rc.EndAttempt(start, nil, err, nil)
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		err := &relay.UpstreamError{
+			StatusCode:        http.StatusBadGateway,
+			Message:           "upstream returned no images",
+			RequestID:         rc.RequestID,
+			ProviderRequestID: rc.UpstreamRequestID,
+		}
+		rc.EndAttempt(start, nil, err, nil)
+		return nil, err
+	}
+	if resp.Created == 0 {
+		resp.Created = time.Now().Unix()
+	}
+
+	// 按实际返回的图片数计费
+	rc.EndAttempt(start, &relay.ChatUsage{Units: len(resp.Data)}, nil, nil)
+	return &resp, nil
+}
+
+// RelaySpeech 中转语音合成请求，只在标记了 audio 能力的渠道间选择，按次计费
+func (s *RelayService) RelaySpeech(ctx context.Context, req *relay.SpeechRequest) (*relay.MediaResponse, error) {
+	ctx, rc := s.beginRelay(ctx, relay.EndpointSpeech, req, req.Model, false, []string{relay.FeatureAudio})
+	content := mediaTailContent(req.Input)
+	if err := s.reserveUnits(ctx, rc, req.Model, 1); err != nil {
+		s.finish(ctx, rc, err, content)
+		return nil, err
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		s.finish(ctx, rc, err, content)
+		return nil, fmt.Errorf("failed to marshal speech request: %w", err)
+	}
+
+	var resp *relay.MediaResponse
+	err = s.withFailoverFeature(ctx, rc, relay.FeatureAudio, func(ctx context.Context, channel *model.Channel) error {
+		headers := handlerHeaders(rc, channel)
+		headers["Content-Type"] = "application/json"
+		var err error
+		resp, err = s.audio(ctx, rc, channel, &relay.HandlerRequest{
+			Model:    req.Model,
+			Endpoint: "/audio/speech",
+			Headers:  headers,
+			Body:     body,
+		}, "audio/mpeg")
+		return err
+	})
+	s.finish(ctx, rc, err, content)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RelayTranscription 中转语音转写请求，只在标记了 audio 能力的渠道间选择，按次计费
+//
+// 音频文件边读取边以 multipart 形式写给上游，不在内存中缓存；
+// 文件一旦开始发送就无法重放，此后的失败不再切换渠道。
+func (s *RelayService) RelayTranscription(ctx context.Context, req *relay.TranscriptionRequest) (*relay.MediaResponse, error) {
+	ctx, rc := s.beginRelay(ctx, relay.EndpointTranscriptions, req, req.Model, false, []string{relay.FeatureAudio})
+	content := mediaTailContent(req.Fields["prompt"])
+	if err := s.reserveUnits(ctx, rc, req.Model, 1); err != nil {
+		s.finish(ctx, rc, err, content)
+		return nil, err
+	}
+
+	file := &readTracker{r: req.File}
+	var resp *relay.MediaResponse
+	err := s.withFailoverFeature(ctx, rc, relay.FeatureAudio, func(ctx context.Context, channel *model.Channel) error {
+		pr, pw := io.Pipe()
+		form := multipart.NewWriter(pw)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			pw.CloseWithError(writeTranscriptionForm(form, req, file))
+		}()
+
+		headers := handlerHeaders(rc, channel)
+		headers["Content-Type"] = form.FormDataContentType()
+		var err error
+		resp, err = s.audio(ctx, rc, channel, &relay.HandlerRequest{
+			Model:      req.Model,
+			Endpoint:   "/audio/transcriptions",
+			Headers:    headers,
+			BodyReader: pr,
+		}, "application/json")
+		// 上游提前结束请求时关闭读端，等写入表单的协程退出后再判断文件是否已被读取
+		pr.Close()
+		<-done
+		if err != nil && file.started() {
+			return relay.NoFailover(err)
+		}
+		return err
+	})
+	s.finish(ctx, rc, err, content)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// audio 通过 AudioHandler 在指定渠道上执行一次语音请求，响应按上游的 Content-Type 原样返回
+func (s *RelayService) audio(ctx context.Context, rc *relay.RelayContext, channel *model.Channel, hreq *relay.HandlerRequest, defaultContentType string) (*relay.MediaResponse, error) {
+	start := time.Now()
+
+	hreq.Type = relay.RequestTypeAudio
+	hreq.ID = rc.RequestID
+	hresp, err := relay.NewAudioHandler(singleChannelClient(ctx, channel, mediaTimeout)).Handle(upstreamContext(ctx, rc), hreq)
+	recordHandlerRequestID(rc, hresp)
+	if err != nil {
+		err = s.handlerError(rc, hresp, err)
+		rc.EndAttempt(start, nil, err, nil)
+		return nil, err
+	}
+
+	rc.EnterPhase(relay.PhaseGeneration)
+	resp := &relay.MediaResponse{ContentType: hresp.Headers["Content-Type"], Body: hresp.Body}
+	if resp.ContentType == "" {
+		resp.ContentType = defaultContentType
+	}
+
+	rc.EndAttempt(start, &relay.ChatUsage{Units: 1}, nil, nil)
+	return resp, nil
+}
+
+// writeTranscriptionForm 按字段名顺序写入表单字段，最后写入音频文件
+func writeTranscriptionForm(form *multipart.Writer, req *relay.TranscriptionRequest, file io.Reader) error {
+	if err := form.WriteField("model", req.Model); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(req.Fields))
+	for name := range req.Fields {
+		if name != "model" && name != "file" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := form.WriteField(name, req.Fields[name]); err != nil {
+			return err
+		}
+	}
+
+	filename := req.Filename
+	if filename == "" {
+		filename = "audio"
+	}
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return fmt.Errorf("failed to forward audio file: %w", err)
+	}
+	return form.Close()
+}
+
+// readTracker 记录请求体是否已开始读取，已读取的请求体无法在其它渠道上重放
+type readTracker struct {
+	r    io.Reader
+	read atomic.Bool
+}
+
+func (t *readTracker) Read(p []byte) (int, error) {
+	t.read.Store(true)
+	return t.r.Read(p)
+}
+
+func (t *readTracker) started() bool {
+	return t.read.Load()
+}
+
+// mediaTailContent 图像与语音请求的输入文本
+func mediaTailContent(prompt string) func(*relay.RelayAttempt) func() *logtail.Content {
+	return func(*relay.RelayAttempt) func() *logtail.Content {
+		return func() *logtail.Content { return &logtail.Content{Prompt: prompt} }
+	}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMediaService 测试渠道标记了指定能力的中转服务
+func newTestMediaService(t *testing.T, upstream http.HandlerFunc, capabilities ...string) *RelayService {
+	t.Helper()
+	s, ch := newTestRelayService(t, upstream)
+	require.NoError(t, ch.SetSettings(model.ChannelSettings{Capabilities: capabilities}))
+	require.NoError(t, s.cache.RefreshCache([]*relay.Channel{toRelayChannel(ch)}))
+	return s
+}
+
+// newMediaRelayContext 按入口的方式构造带 Token 密钥的媒体请求上下文
+func newMediaRelayContext(t *testing.T, endpoint, modelName string) *relay.RelayContext {
+	t.Helper()
+	rc, err := relay.NewRelayContextBuilder("req-1", endpoint).
+		Token(&model.Token{ID: 3, UserID: 42, Name: "ci", TokenHash: "sk-quota"}).
+		Client("127.0.0.1").
+		Model(modelName, false).
+		Build()
+	require.NoError(t, err)
+	return rc
+}
+
+func TestRelayImageGenerationBillsPerImage(t *testing.T) {
+	s := newTestMediaService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/images/generations", r.URL.Path)
+		assert.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		var req relay.ImageGenerationRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "a lighthouse at dawn", req.Prompt)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "img-upstream-1")
+		data := make([]map[string]string, req.N)
+		for i := range data {
+			data[i] = map[string]string{"url": "https://img.example/" + string(rune('a'+i))}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"created": 1700000000, "data": data})
+	}, relay.FeatureImages)
+	guard, tokens, _ := newTestQuotaGuard(t, 1000, 0)
+	require.NoError(t, guard.pricing.RegisterModelPrice("dall-e-3", 40, 0, billing.PricingByRequest))
+	s.SetQuotaGuard(guard)
+
+	rc := newMediaRelayContext(t, relay.EndpointImages, "dall-e-3")
+	resp, err := s.RelayImageGeneration(relay.WithRelayContext(context.Background(), rc),
+		&relay.ImageGenerationRequest{Model: "dall-e-3", Prompt: "a lighthouse at dawn", N: 2})
+	require.NoError(t, err)
+
+	require.Len(t, resp.Data, 2)
+	assert.EqualValues(t, 1700000000, resp.Created)
+	assert.Equal(t, "img-upstream-1", rc.UpstreamRequestID)
+	require.NotNil(t, rc.Usage)
+	assert.Equal(t, 2, rc.Usage.Units)
+	assert.EqualValues(t, 80, rc.Cost, "priced per image")
+	assert.EqualValues(t, 80, tokens.used())
+}
+
+func TestRelayMediaRequiresChannelCapability(t *testing.T) {
+	var hits int32
+	s := newTestMediaService(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}, relay.FeatureAudio)
+
+	rc := newMediaRelayContext(t, relay.EndpointImages, "dall-e-3")
+	_, err := s.RelayImageGeneration(relay.WithRelayContext(context.Background(), rc),
+		&relay.ImageGenerationRequest{Model: "dall-e-3", Prompt: "a lighthouse"})
+	assert.True(t, errors.Is(err, relay.ErrModelNotSupported), "got %v", err)
+	assert.Zero(t, atomic.LoadInt32(&hits))
+}
+
+func TestRelaySpeech(t *testing.T) {
+	s := newTestMediaService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/audio/speech", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var req relay.SpeechRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "alloy", req.Voice)
+
+		w.Header().Set("Content-Type", "audio/opus")
+		w.Write([]byte("OggS-fake-audio"))
+	}, relay.FeatureAudio)
+
+	rc := newMediaRelayContext(t, relay.EndpointSpeech, "tts-1")
+	resp, err := s.RelaySpeech(relay.WithRelayContext(context.Background(), rc),
+		&relay.SpeechRequest{Model: "tts-1", Input: "Hello there", Voice: "alloy", ResponseFormat: "opus"})
+	require.NoError(t, err)
+	assert.Equal(t, "audio/opus", resp.ContentType)
+	assert.Equal(t, "OggS-fake-audio", string(resp.Body))
+	assert.Equal(t, 1, rc.Usage.Units)
+}
+
+// onceReader 只能顺序读取一次的请求体，模拟客户端上传的文件流
+type onceReader struct {
+	r io.Reader
+}
+
+func (o *onceReader) Read(p []byte) (int, error) { return o.r.Read(p) }
+
+func TestRelayTranscriptionStreamsMultipart(t *testing.T) {
+	audio := strings.Repeat("RIFF-wave-data-", 4096)
+	s := newTestMediaService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/audio/transcriptions", r.URL.Path)
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "whisper-1", r.FormValue("model"))
+		assert.Equal(t, "en", r.FormValue("language"))
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		defer file.Close()
+		assert.Equal(t, "meeting.wav", header.Filename)
+		data, _ := io.ReadAll(file)
+		assert.Equal(t, audio, string(data))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text":"hello world"}`))
+	}, relay.FeatureAudio)
+
+	rc := newMediaRelayContext(t, relay.EndpointTranscriptions, "whisper-1")
+	resp, err := s.RelayTranscription(relay.WithRelayContext(context.Background(), rc), &relay.TranscriptionRequest{
+		Model:    "whisper-1",
+		Filename: "meeting.wav",
+		File:     &onceReader{r: strings.NewReader(audio)},
+		Fields:   map[string]string{"language": "en"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", resp.ContentType)
+	assert.JSONEq(t, `{"text":"hello world"}`, string(resp.Body))
+}
+
+func TestRelayTranscriptionUpstreamError(t *testing.T) {
+	s := newTestMediaService(t, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"Invalid file format.","type":"invalid_request_error"}}`))
+	}, relay.FeatureAudio)
+
+	rc := newMediaRelayContext(t, relay.EndpointTranscriptions, "whisper-1")
+	_, err := s.RelayTranscription(relay.WithRelayContext(context.Background(), rc), &relay.TranscriptionRequest{
+		Model: "whisper-1",
+		File:  strings.NewReader("not audio"),
+	})
+	var upstreamErr *relay.UpstreamError
+	require.True(t, errors.As(err, &upstreamErr), "got %v", err)
+	assert.Equal(t, http.StatusBadRequest, upstreamErr.StatusCode)
+	assert.Equal(t, "Invalid file format.", upstreamErr.Message)
+	assert.Nil(t, rc.Usage, "failed requests are not billed")
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/adapter"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+)
+
+// nativeMessagesTypes 可以原样透传 Anthropic Messages 请求的渠道类型
+var nativeMessagesTypes = []string{"claude", "anthropic"}
+
+// RelayMessages 中转 Anthropic 原生 Messages 请求
+//
+// 有 Claude 渠道提供该模型时只在 Claude 渠道间选择并原样透传；
+// 否则转换为 OpenAI 格式交给其它渠道，再把响应转换回 Anthropic 格式。
+func (s *RelayService) RelayMessages(ctx context.Context, req *relay.MessagesRequest) (*relay.MessagesResponse, error) {
+	chatReq := req.ChatRequest()
+	ctx, rc := s.beginRelay(ctx, relay.EndpointMessages, req, req.Model, false, relay.ChatFeatures(chatReq))
+	channelType, err := s.messagesChannelType(ctx, req)
+	if err == nil {
+		err = s.reserveQuota(ctx, rc, chatReq)
+	}
+	if err != nil {
+		s.finish(ctx, rc, err, chatAttemptContent(chatReq.Messages))
+		return nil, err
+	}
+
+	var resp *relay.MessagesResponse
+	err = s.withFailoverType(ctx, rc, channelType, func(ctx context.Context, channel *model.Channel) error {
+		if isNativeMessagesChannel(channel) {
+			var err error
+			resp, err = s.nativeMessages(ctx, rc, channel, req)
+			return err
+		}
+		chatResp, err := s.chatCompletion(ctx, rc, channel, chatReq)
+		if err != nil {
+			return err
+		}
+		resp = relay.MessagesResponseFromChat(chatResp)
+		return nil
+	})
+	s.finish(ctx, rc, err, chatAttemptContent(chatReq.Messages))
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RelayMessagesStream 中转流式 Anthropic Messages 请求，handler 按顺序接收 Anthropic 格式的流式事件
+//
+// 与 RelayChatCompletionStream 一样，开始向客户端输出之后不再切换渠道。
+func (s *RelayService) RelayMessagesStream(ctx context.Context, req *relay.MessagesRequest, handler func(event *relay.MessagesStreamEvent) error) error {
+	req.Stream = true
+	chatReq := req.ChatRequest()
+	ctx, rc := s.beginRelay(ctx, relay.EndpointMessages, req, req.Model, true, relay.ChatFeatures(chatReq))
+	channelType, err := s.messagesChannelType(ctx, req)
+	if err == nil {
+		err = s.reserveQuota(ctx, rc, chatReq)
+	}
+	if err != nil {
+		s.finish(ctx, rc, err, chatAttemptContent(chatReq.Messages))
+		return err
+	}
+
+	err = s.withFailoverType(ctx, rc, channelType, func(ctx context.Context, channel *model.Channel) error {
+		if isNativeMessagesChannel(channel) {
+			return s.nativeMessagesStream(ctx, rc, channel, req, handler)
+		}
+
+		encoder := relay.NewMessagesStreamEncoder(req.Model, countPromptTokens(chatReq))
+		emit := func(events []*relay.MessagesStreamEvent) error {
+			for _, event := range events {
+				if err := handler(event); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := s.chatCompletionStream(ctx, rc, channel, chatReq, func(chunk *relay.ChatCompletionResponse) error {
+			return emit(encoder.Encode(chunk))
+		}); err != nil {
+			return err
+		}
+		// 上游没有在流中报告用量时，使用本次尝试记录的用量
+		var usage *relay.ChatUsage
+		if n := len(rc.Attempts); n > 0 {
+			usage = rc.Attempts[n-1].Usage
+		}
+		return relay.NoFailover(emit(encoder.Close(usage)))
+	})
+	s.finish(ctx, rc, err, chatAttemptContent(chatReq.Messages))
+	return err
+}
+
+// messagesChannelType 有 Claude 渠道提供该模型时返回其渠道类型；
+// 没有时请求要转换为 OpenAI 格式，此时只能包含文本内容块
+func (s *RelayService) messagesChannelType(ctx context.Context, req *relay.MessagesRequest) (string, error) {
+	if err := s.ensureChannels(ctx); err != nil {
+		return "", err
+	}
+	for _, channelType := range nativeMessagesTypes {
+		filter := &relay.ChannelFilter{Type: channelType, Model: req.Model, OnlyEnabled: true}
+		if len(s.cache.FilterChannels(filter)) > 0 {
+			return channelType, nil
+		}
+	}
+	return "", req.TextOnly()
+}
+
+// isNativeMessagesChannel 渠道是否直接支持 Anthropic Messages API
+func isNativeMessagesChannel(channel *model.Channel) bool {
+	return adapter.ParseProviderType(channel.Type) == adapter.ProviderAnthropic
+}
+
+// claudeAdapter 创建渠道的 Claude 适配器
+func claudeAdapter(ctx context.Context, channel *model.Channel) (*adapter.ClaudeAdapter, error) {
+	adaptor, err := channelAdapter(ctx, channel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create adapter: %w", err)
+	}
+	claude, ok := adaptor.(*adapter.ClaudeAdapter)
+	if !ok {
+		return nil, fmt.Errorf("channel %d does not support the messages api", channel.ID)
+	}
+	return claude, nil
+}
+
+// nativeMessages 在 Claude 渠道上原样透传一次非流式请求
+func (s *RelayService) nativeMessages(ctx context.Context, rc *relay.RelayContext, channel *model.Channel, req *relay.MessagesRequest) (*relay.MessagesResponse, error) {
+	start := time.Now()
+
+	claude, err := claudeAdapter(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	body, err := req.NativeBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	httpResp, err := claude.DoMessages(upstreamContext(ctx, rc), body)
+	if err != nil {
+		rc.EndAttempt(start, nil, err, nil)
+		return nil, fmt.Errorf("upstream request failed: %w", err)
+	}
+	if err := s.checkUpstream(claude, httpResp, rc); err != nil {
+		rc.EndAttempt(start, nil, err, nil)
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	rc.EnterPhase(relay.PhaseGeneration)
+
+	var resp relay.MessagesResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		err = fmt.Errorf("failed to parse response: %w", err)
+		rc.EndAttempt(start, nil, err, nil)
+		return nil, err
+	}
+	rc.EndAttempt(start, resp.Usage.ChatUsage(), nil, resp.Content.Text)
+	return &resp, nil
+}
+
+// nativeMessagesStream 在 Claude 渠道上原样透传一次流式请求，同时从事件中读取用量
+func (s *RelayService) nativeMessagesStream(ctx context.Context, rc *relay.RelayContext, channel *model.Channel, req *relay.MessagesRequest, handler func(event *relay.MessagesStreamEvent) error) error {
+	start := time.Now()
+
+	claude, err := claudeAdapter(ctx, channel)
+	if err != nil {
+		return err
+	}
+	body, err := req.NativeBody()
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	httpResp, err := claude.DoMessages(upstreamContext(ctx, rc), body)
+	if err != nil {
+		rc.EndAttempt(start, nil, err, nil)
+		return fmt.Errorf("upstream request failed: %w", err)
+	}
+	if err := s.checkUpstream(claude, httpResp, rc); err != nil {
+		rc.EndAttempt(start, nil, err, nil)
+		return err
+	}
+
+	events := claude.ParseMessagesStream(httpResp)
+	drain := func() {
+		for range events {
+		}
+	}
+
+	var usage relay.MessagesUsage
+	forwarded := false
+	generating := false // 已收到首个内容增量，之前的时间计入首 Token 等待
+	var delivered strings.Builder
+	// 上游未报告用量时按请求与已输出内容估算
+	estimated := func() *relay.ChatUsage {
+		if usage.InputTokens == 0 {
+			usage.InputTokens = countPromptTokens(req.ChatRequest())
+		}
+		if usage.OutputTokens == 0 {
+			usage.OutputTokens = countTextTokens(req.Model, delivered.String())
+		}
+		return usage.ChatUsage()
+	}
+
+	for event := range events {
+		if event.Err != nil {
+			drain()
+			interrupted := &relay.StreamInterruptedError{
+				Class:        event.Err.Class,
+				Message:      event.Err.Message,
+				Partial:      forwarded,
+				PromptTokens: usage.InputTokens,
+			}
+			if !forwarded {
+				rc.EndAttempt(start, nil, interrupted, nil)
+				return interrupted
+			}
+			// 只按已经输出给客户端的内容计费
+			usage.OutputTokens = 0
+			chatUsage := estimated()
+			interrupted.PromptTokens = chatUsage.PromptTokens
+			interrupted.DeliveredTokens = chatUsage.CompletionTokens
+			rc.EndAttempt(start, chatUsage, interrupted, delivered.String)
+			return interrupted
+		}
+
+		text := relay.ObserveMessagesEvent(event.Event, event.Data, &usage)
+		if !generating && event.Event == "content_block_delta" {
+			generating = true
+			rc.EnterPhase(relay.PhaseGeneration)
+		}
+		if err := handler(&relay.MessagesStreamEvent{Type: event.Event, Data: event.Data}); err != nil {
+			drain()
+			rc.EndAttempt(start, estimated(), err, delivered.String)
+			return relay.NoFailover(err)
+		}
+		forwarded = true
+		rc.MarkFirstByte()
+		delivered.WriteString(text)
+	}
+
+	rc.EndAttempt(start, usage.ChatUsage(), nil, delivered.String)
+	return nil
+}
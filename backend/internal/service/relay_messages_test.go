@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// claudeMessagesBody 带工具定义的 Anthropic 请求，原生透传时应原样到达上游
+const claudeMessagesBody = `{"model":"claude-3-5-sonnet","max_tokens":64,"system":"Be brief.","messages":[{"role":"user","content":[{"type":"text","text":"Weather in Paris?"}]}],"tools":[{"name":"get_weather","input_schema":{"type":"object"}}]}`
+
+// addTestChannel 向中转服务添加一个指向 upstream 的渠道
+func addTestChannel(t *testing.T, s *RelayService, id int, channelType string, upstream http.HandlerFunc) *model.Channel {
+	t.Helper()
+	server := httptest.NewServer(upstream)
+	t.Cleanup(server.Close)
+
+	ch := &model.Channel{
+		ID:      id,
+		Name:    fmt.Sprintf("%s-test", channelType),
+		Type:    channelType,
+		APIKey:  "sk-" + channelType,
+		BaseURL: server.URL,
+		Weight:  1,
+		Status:  model.ChannelStatusEnabled,
+		Enabled: true,
+	}
+	s.channels[strconv.Itoa(ch.ID)] = ch
+	relayChannels := make([]*relay.Channel, 0, len(s.channels))
+	for _, c := range s.channels {
+		relayChannels = append(relayChannels, toRelayChannel(c))
+	}
+	require.NoError(t, s.cache.RefreshCache(relayChannels))
+	return ch
+}
+
+// newTestMessagesService 只有一个指定类型渠道的中转服务
+func newTestMessagesService(t *testing.T, channelType string, upstream http.HandlerFunc) (*RelayService, *model.Channel) {
+	t.Helper()
+	s := NewRelayService()
+	s.logRepo = nil
+	s.loaded = true
+	return s, addTestChannel(t, s, 7, channelType, upstream)
+}
+
+// newMessagesRelayContext 按 /v1/messages 入口的方式构造中转上下文
+func newMessagesRelayContext(t *testing.T) *relay.RelayContext {
+	t.Helper()
+	rc, err := relay.NewRelayContextBuilder("req-1", relay.EndpointMessages).
+		Token(&model.Token{ID: 3, UserID: 42, Name: "ci"}).
+		Build()
+	require.NoError(t, err)
+	return rc
+}
+
+func parseMessagesRequest(t *testing.T, body string) *relay.MessagesRequest {
+	t.Helper()
+	var req relay.MessagesRequest
+	require.NoError(t, json.Unmarshal([]byte(body), &req))
+	require.NoError(t, req.Validate())
+	return &req
+}
+
+func TestRelayMessagesNativeClaude(t *testing.T) {
+	s, ch := newTestMessagesService(t, "claude", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/messages", r.URL.Path)
+		assert.Equal(t, "sk-claude", r.Header.Get("x-api-key"))
+		assert.Empty(t, r.Header.Get("Authorization"))
+		assert.Equal(t, "2023-06-01", r.Header.Get("anthropic-version"))
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, claudeMessagesBody, string(body), "the request is forwarded unchanged")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Request-Id", "req_up_1")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet","content":[{"type":"text","text":"Let me check."},{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}],"stop_reason":"tool_use","stop_sequence":null,"usage":{"input_tokens":30,"output_tokens":12}}`)
+	})
+
+	rc := newMessagesRelayContext(t)
+	resp, err := s.RelayMessages(relay.WithRelayContext(context.Background(), rc), parseMessagesRequest(t, claudeMessagesBody))
+	require.NoError(t, err)
+
+	out, _ := json.Marshal(resp)
+	assert.Contains(t, string(out), `{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}`)
+	require.NotNil(t, resp.StopReason)
+	assert.Equal(t, "tool_use", *resp.StopReason)
+	assert.Equal(t, relay.MessagesUsage{InputTokens: 30, OutputTokens: 12}, resp.Usage)
+
+	assert.Equal(t, relay.EndpointMessages, rc.Endpoint)
+	assert.Equal(t, ch.ID, rc.ChannelID)
+	assert.Equal(t, "req_up_1", rc.UpstreamRequestID)
+	require.NotNil(t, rc.Usage)
+	assert.Equal(t, 42, rc.Usage.TotalTokens)
+	assert.Equal(t, "Let me check.", rc.Attempts[0].Output())
+}
+
+func TestRelayMessagesNativeClaudeStream(t *testing.T) {
+	events := []string{
+		`event: message_start` + "\n" + `data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet","content":[],"stop_reason":null,"usage":{"input_tokens":30,"output_tokens":1}}}`,
+		`event: content_block_start` + "\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`event: ping` + "\n" + `data: {"type":"ping"}`,
+		`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Sunny"}}`,
+		`event: content_block_stop` + "\n" + `data: {"type":"content_block_stop","index":0}`,
+		`event: message_delta` + "\n" + `data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":4}}`,
+		`event: message_stop` + "\n" + `data: {"type":"message_stop"}`,
+	}
+	s, _ := newTestMessagesService(t, "claude", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Contains(t, string(body), `"stream":true`)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range events {
+			fmt.Fprint(w, ev+"\n\n")
+		}
+	})
+
+	rc := newMessagesRelayContext(t)
+	req := parseMessagesRequest(t, `{"model":"claude-3-5-sonnet","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"Weather?"}]}`)
+	var got []*relay.MessagesStreamEvent
+	err := s.RelayMessagesStream(relay.WithRelayContext(context.Background(), rc), req, func(event *relay.MessagesStreamEvent) error {
+		got = append(got, event)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, got, len(events))
+	assert.Equal(t, "message_start", got[0].Type)
+	assert.Equal(t, "ping", got[2].Type)
+	data, _ := json.Marshal(got[3].Data)
+	assert.JSONEq(t, `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Sunny"}}`, string(data))
+	assert.Equal(t, "message_stop", got[len(got)-1].Type)
+
+	require.NotNil(t, rc.Usage)
+	assert.Equal(t, 30, rc.Usage.PromptTokens)
+	assert.Equal(t, 4, rc.Usage.CompletionTokens)
+	assert.Equal(t, "Sunny", rc.Attempts[0].Output())
+	assert.Positive(t, rc.Timings.FirstByte)
+}
+
+func TestRelayMessagesOpenAIChannel(t *testing.T) {
+	s, ch := newTestMessagesService(t, "openai", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		var body struct {
+			Model     string              `json:"model"`
+			MaxTokens int                 `json:"max_tokens"`
+			Messages  []relay.ChatMessage `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, 64, body.MaxTokens)
+		assert.Equal(t, []relay.ChatMessage{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "Weather in Paris?"},
+		}, body.Messages)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"claude-3-5-sonnet","choices":[{"index":0,"message":{"role":"assistant","content":"Sunny, 24C."},"finish_reason":"length"}],"usage":{"prompt_tokens":14,"completion_tokens":6,"total_tokens":20}}`)
+	})
+
+	rc := newMessagesRelayContext(t)
+	req := parseMessagesRequest(t, `{"model":"claude-3-5-sonnet","max_tokens":64,"system":"Be brief.","messages":[{"role":"user","content":[{"type":"text","text":"Weather in Paris?"}]}]}`)
+	resp, err := s.RelayMessages(relay.WithRelayContext(context.Background(), rc), req)
+	require.NoError(t, err)
+
+	out, _ := json.Marshal(resp)
+	assert.JSONEq(t, `{"id":"chatcmpl-1","type":"message","role":"assistant","model":"claude-3-5-sonnet","content":[{"type":"text","text":"Sunny, 24C."}],"stop_reason":"max_tokens","stop_sequence":null,"usage":{"input_tokens":14,"output_tokens":6}}`, string(out))
+	assert.Equal(t, ch.ID, rc.ChannelID)
+	require.NotNil(t, rc.Usage)
+	assert.Equal(t, 20, rc.Usage.TotalTokens)
+}
+
+func TestRelayMessagesOpenAIChannelStream(t *testing.T) {
+	s, _ := newTestMessagesService(t, "openai", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Sun\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"ny\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[],\"usage\":{\"prompt_tokens\":14,\"completion_tokens\":2,\"total_tokens\":16}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	rc := newMessagesRelayContext(t)
+	req := parseMessagesRequest(t, `{"model":"gpt-4o","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"Weather?"}]}`)
+	var types []string
+	var text string
+	var final map[string]interface{}
+	err := s.RelayMessagesStream(relay.WithRelayContext(context.Background(), rc), req, func(event *relay.MessagesStreamEvent) error {
+		types = append(types, event.Type)
+		data, _ := json.Marshal(event.Data)
+		var payload map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &payload))
+		assert.Equal(t, event.Type, payload["type"], "the data type matches the event name")
+		switch event.Type {
+		case "content_block_delta":
+			text += payload["delta"].(map[string]interface{})["text"].(string)
+		case "message_delta":
+			final = payload
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"message_start", "content_block_start", "content_block_delta", "content_block_delta",
+		"content_block_stop", "message_delta", "message_stop"}, types)
+	assert.Equal(t, "Sunny", text)
+	require.NotNil(t, final)
+	assert.Equal(t, "end_turn", final["delta"].(map[string]interface{})["stop_reason"])
+	assert.Equal(t, map[string]interface{}{"input_tokens": float64(14), "output_tokens": float64(2)}, final["usage"])
+	require.NotNil(t, rc.Usage)
+	assert.Equal(t, 16, rc.Usage.TotalTokens)
+}
+
+func TestRelayMessagesPrefersClaudeChannel(t *testing.T) {
+	var openaiHits int32
+	s, _ := newTestMessagesService(t, "openai", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&openaiHits, 1)
+	})
+	claude := addTestChannel(t, s, 8, "anthropic", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`)
+	})
+
+	for i := 0; i < 3; i++ {
+		rc := newMessagesRelayContext(t)
+		_, err := s.RelayMessages(relay.WithRelayContext(context.Background(), rc), parseMessagesRequest(t, claudeMessagesBody))
+		require.NoError(t, err)
+		assert.Equal(t, claude.ID, rc.ChannelID)
+	}
+	assert.Zero(t, atomic.LoadInt32(&openaiHits))
+}
+
+func TestRelayMessagesRejectsUnsupportedContent(t *testing.T) {
+	var hits int32
+	s, _ := newTestMessagesService(t, "openai", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	})
+
+	req := parseMessagesRequest(t, `{"model":"gpt-4o","max_tokens":64,"messages":[{"role":"user","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBOR"}}]}]}`)
+	_, err := s.RelayMessages(context.Background(), req)
+	assert.ErrorIs(t, err, relay.ErrUnsupportedContent)
+	assert.Zero(t, atomic.LoadInt32(&hits), "upstream is never called")
+}
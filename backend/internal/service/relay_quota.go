@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+// defaultReserveMaxTokens 请求未指定 max_tokens 时预估的输出 Token 数
+const defaultReserveMaxTokens = 1024
+
+// ErrQuotaTokenRejected 预检时 Token 校验失败（已禁用、过期、IP 或模型不在白名单）
+var ErrQuotaTokenRejected = errors.New("token rejected")
+
+// RelayQuotaGuard 中转额度预检：上游调用前按估算费用预留 Token 额度，请求结束后按实际用量结算
+//
+// 预留记录在 billing.QuotaManager 中（以 Token 为键），同一 Token 的并发请求
+// 不会同时占用同一份剩余额度；结算后的用量通过 TokenService 写回数据库。
+// QuotaManager 设置了 QuotaReservationStore 时预留同时写入数据库，重启后恢复。
+// 没有设置额度上限的 Token 只做有效性校验；模型没有登记价格时预留额为 0，
+// 此时只拒绝额度已耗尽的 Token。价格按 Token 额度单位登记。
+type RelayQuotaGuard struct {
+	tokens           *TokenService
+	quota            *billing.QuotaManager
+	pricing          *billing.PricingManager
+	defaultMaxTokens int
+}
+
+// NewRelayQuotaGuard 创建额度预检，defaultMaxTokens <= 0 时使用默认值
+func NewRelayQuotaGuard(tokens *TokenService, quota *billing.QuotaManager, pricing *billing.PricingManager, defaultMaxTokens int) *RelayQuotaGuard {
+	if defaultMaxTokens <= 0 {
+		defaultMaxTokens = defaultReserveMaxTokens
+	}
+	return &RelayQuotaGuard{
+		tokens:           tokens,
+		quota:            quota,
+		pricing:          pricing,
+		defaultMaxTokens: defaultMaxTokens,
+	}
+}
+
+// Reserve 重新校验 Token 并预留估算费用，额度不足时返回 billing.ErrInsufficientQuota
+func (g *RelayQuotaGuard) Reserve(ctx context.Context, rc *relay.RelayContext, req *relay.ChatCompletionRequest) error {
+	return g.reserve(ctx, rc, req.Model, func() int64 { return g.estimate(req) })
+}
+
+// estimate 按提示词 Token 数与 max_tokens（未指定时为默认值）估算请求的最高费用
+func (g *RelayQuotaGuard) estimate(req *relay.ChatCompletionRequest) int64 {
+	completionTokens := req.MaxTokens
+	if completionTokens <= 0 {
+		completionTokens = g.defaultMaxTokens
+	}
+	return g.cost(req.Model, countPromptTokens(req), completionTokens)
+}
+
+// ReserveUnits 按次计费的请求（图像生成、语音）预留 units 次的费用
+func (g *RelayQuotaGuard) ReserveUnits(ctx context.Context, rc *relay.RelayContext, modelName string, units int) error {
+	return g.reserve(ctx, rc, modelName, func() int64 {
+		return g.usageCost(modelName, &relay.ChatUsage{Units: units})
+	})
+}
+
+// reserve 校验 Token 后预留 estimate 估算的费用
+func (g *RelayQuotaGuard) reserve(ctx context.Context, rc *relay.RelayContext, modelName string, estimate func() int64) error {
+	token, err := g.validate(ctx, rc, modelName)
+	if err != nil || token == nil {
+		return err
+	}
+
+	amount := estimate()
+	reservationID, err := g.reserveToken(token, amount, 0)
+	if err != nil {
+		return err
+	}
+	rc.ReservationID = reservationID
+	rc.Reserved = amount
+	return nil
+}
+
+// BatchReservation 批量任务中单个请求的额度预留，ID 为空表示没有预留
+type BatchReservation struct {
+	ID     string
+	Amount int64
+}
+
+// ReserveBatch 为批量任务的每个请求各预留一笔额度，有效期 ttl 需覆盖整个任务的执行时间；
+// 任一请求预留失败时释放已预留的部分，整个任务不创建
+func (g *RelayQuotaGuard) ReserveBatch(ctx context.Context, rc *relay.RelayContext, reqs []*relay.ChatCompletionRequest, ttl time.Duration) ([]BatchReservation, error) {
+	// 同一模型只校验一次 Token
+	tokens := make(map[string]*model.Token)
+	for _, req := range reqs {
+		if _, ok := tokens[req.Model]; ok {
+			continue
+		}
+		token, err := g.validate(ctx, rc, req.Model)
+		if err != nil {
+			return nil, err
+		}
+		tokens[req.Model] = token
+	}
+
+	reservations := make([]BatchReservation, len(reqs))
+	for i, req := range reqs {
+		token := tokens[req.Model]
+		if token == nil {
+			continue
+		}
+		amount := g.estimate(req)
+		reservationID, err := g.reserveToken(token, amount, ttl)
+		if err != nil {
+			g.ReleaseBatch(reservations[:i])
+			return nil, fmt.Errorf("request %d: %w", i, err)
+		}
+		reservations[i] = BatchReservation{ID: reservationID, Amount: amount}
+	}
+	return reservations, nil
+}
+
+// ReleaseBatch 释放批量任务中尚未结算的预留
+func (g *RelayQuotaGuard) ReleaseBatch(reservations []BatchReservation) {
+	for _, r := range reservations {
+		if r.ID != "" {
+			g.quota.Release(r.ID)
+		}
+	}
+}
+
+// validate 重新校验 Token，返回需要预留额度的 Token；内部调用或 Token 没有额度上限时返回 nil
+func (g *RelayQuotaGuard) validate(ctx context.Context, rc *relay.RelayContext, modelName string) (*model.Token, error) {
+	// 内部调用没有 Token，不做预检
+	if rc.TokenKey() == "" {
+		return nil, nil
+	}
+
+	token, err := g.tokens.ValidateToken(ctx, rc.TokenKey(), rc.ClientIP, modelName)
+	if err != nil {
+		// 额度耗尽的 Token 同样无效，按额度不足返回，便于调用方区分充值与更换 Token
+		if current, lookupErr := g.tokens.GetTokenByHash(ctx, rc.TokenKey()); lookupErr == nil && tokenExhausted(current) {
+			return nil, fmt.Errorf("%w: token %d quota exhausted", billing.ErrInsufficientQuota, current.ID)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrQuotaTokenRejected, err)
+	}
+	if !token.QuotaLimit.Valid {
+		return nil, nil
+	}
+	return token, nil
+}
+
+// reserveToken 按 Token 当前的用量同步额度后预留 amount，ttl <= 0 时使用默认有效期
+func (g *RelayQuotaGuard) reserveToken(token *model.Token, amount int64, ttl time.Duration) (string, error) {
+	key := tokenQuotaKey(token.ID)
+	g.quota.SyncQuota(key, float64(token.QuotaLimit.Int64), float64(token.QuotaUsed))
+
+	reservationID, err := g.quota.ReserveFor(key, float64(amount), ttl)
+	if err != nil {
+		if errors.Is(err, billing.ErrInsufficientQuota) {
+			return "", fmt.Errorf("%w: token %d has %d of %d left, request needs up to %d",
+				err, token.ID, token.QuotaLimit.Int64-token.QuotaUsed, token.QuotaLimit.Int64, amount)
+		}
+		return "", err
+	}
+	return reservationID, nil
+}
+
+// Settle 按实际用量结算预留，多预留的部分退回；没有用量（如上游失败）时整笔释放
+func (g *RelayQuotaGuard) Settle(ctx context.Context, rc *relay.RelayContext) {
+	if rc.ReservationID == "" {
+		return
+	}
+
+	var actual int64
+	if rc.Usage != nil {
+		actual = g.usageCost(rc.Model, rc.Usage)
+	}
+	reservationID := rc.ReservationID
+	rc.ReservationID = ""
+	rc.Cost = actual
+	if actual == 0 {
+		g.quota.Release(reservationID)
+		return
+	}
+
+	if err := g.quota.Confirm(reservationID, float64(actual)); err != nil {
+		// 预留已过期被释放，用量仍写回数据库，下次预检时同步
+		logger.Warn("relay quota reservation expired before settlement",
+			zap.String("request_id", rc.RequestID),
+			zap.String("reservation_id", reservationID),
+			zap.Error(err))
+	}
+
+	if err := g.tokens.UseQuota(ctx, rc.TokenID, actual); err != nil {
+		logger.Warn("failed to record relay quota usage",
+			zap.String("request_id", rc.RequestID),
+			zap.Int("token_id", rc.TokenID),
+			zap.Int64("cost", actual),
+			zap.Error(err))
+	}
+}
+
+// usageCost 用量的费用，计费单位数大于 1 时（如生成多张图片）按单价累加
+func (g *RelayQuotaGuard) usageCost(modelName string, usage *relay.ChatUsage) int64 {
+	units := usage.Units
+	if units < 1 {
+		units = 1
+	}
+	return g.cost(modelName, usage.PromptTokens, usage.CompletionTokens) * int64(units)
+}
+
+// cost 按价格表计算费用并向上取整到额度单位，模型未登记价格时为 0
+func (g *RelayQuotaGuard) cost(modelName string, promptTokens, completionTokens int) int64 {
+	if g.pricing == nil {
+		return 0
+	}
+	price, err := g.pricing.CalculatePrice(modelName, int64(promptTokens), int64(completionTokens))
+	if err != nil {
+		return 0
+	}
+	return int64(math.Ceil(price))
+}
+
+// QuotaReservationStore 将额度预留保存到数据库，实现 billing.ReservationStore
+type QuotaReservationStore struct {
+	repo *repository.QuotaReservationRepository
+}
+
+// NewQuotaReservationStore 创建额度预留存储
+func NewQuotaReservationStore(repo *repository.QuotaReservationRepository) *QuotaReservationStore {
+	return &QuotaReservationStore{repo: repo}
+}
+
+// SaveReservation 保存预留
+func (s *QuotaReservationStore) SaveReservation(ctx context.Context, r *billing.Reservation) error {
+	return s.repo.Create(ctx, &model.QuotaReservation{
+		ID:        r.ID,
+		QuotaKey:  r.UserID,
+		Amount:    r.Amount,
+		CreatedAt: r.CreatedAt,
+		ExpiresAt: r.ExpiresAt,
+	})
+}
+
+// DeleteReservations 删除预留
+func (s *QuotaReservationStore) DeleteReservations(ctx context.Context, ids []string) error {
+	return s.repo.DeleteByIDs(ctx, ids)
+}
+
+// ListReservations 列出全部未结算的预留
+func (s *QuotaReservationStore) ListReservations(ctx context.Context) ([]*billing.Reservation, error) {
+	rows, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reservations := make([]*billing.Reservation, len(rows))
+	for i, row := range rows {
+		reservations[i] = &billing.Reservation{
+			ID:        row.ID,
+			UserID:    row.QuotaKey,
+			Amount:    row.Amount,
+			CreatedAt: row.CreatedAt,
+			ExpiresAt: row.ExpiresAt,
+		}
+	}
+	return reservations, nil
+}
+
+// tokenExhausted Token 状态正常但额度已用完，或已被标记为耗尽
+func tokenExhausted(token *model.Token) bool {
+	if token.Status == model.TokenStatusExhausted {
+		return true
+	}
+	return token.Status == model.TokenStatusNormal && token.QuotaLimit.Valid && token.QuotaUsed >= token.QuotaLimit.Int64
+}
+
+// tokenQuotaKey Token 在 QuotaManager 中的键
+func tokenQuotaKey(tokenID int) string {
+	return "token:" + strconv.Itoa(tokenID)
+}
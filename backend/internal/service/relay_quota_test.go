@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// quotaTokens 内存中的单个 Token，只实现额度预检用到的方法
+type quotaTokens struct {
+	repository.TokenRepository
+	mu    sync.Mutex
+	token model.Token
+}
+
+func (r *quotaTokens) GetByHash(ctx context.Context, tokenHash string) (*model.Token, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if tokenHash != r.token.TokenHash {
+		return nil, nil
+	}
+	token := r.token
+	return &token, nil
+}
+
+func (r *quotaTokens) GetByID(ctx context.Context, id int) (*model.Token, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token := r.token
+	return &token, nil
+}
+
+func (r *quotaTokens) Update(ctx context.Context, token *model.Token) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.token = *token
+	return nil
+}
+
+func (r *quotaTokens) LogAudit(ctx context.Context, log *model.TokenAuditLog) error {
+	return nil
+}
+
+func (r *quotaTokens) used() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.token.QuotaUsed
+}
+
+// newTestQuotaGuard Token 额度上限为 limit，gpt-4 每个 Token 计 1 个额度单位
+func newTestQuotaGuard(t *testing.T, limit, used int64) (*RelayQuotaGuard, *quotaTokens, *billing.QuotaManager) {
+	t.Helper()
+	tokens := &quotaTokens{token: model.Token{
+		ID:         3,
+		UserID:     42,
+		Name:       "ci",
+		TokenHash:  "sk-quota",
+		Status:     model.TokenStatusNormal,
+		QuotaLimit: sql.NullInt64{Int64: limit, Valid: true},
+		QuotaUsed:  used,
+	}}
+	pricing := billing.NewPricingManager()
+	require.NoError(t, pricing.RegisterModelPrice("gpt-4", 1000, 1000, billing.PricingByToken))
+	quota := billing.NewQuotaManager()
+	return NewRelayQuotaGuard(NewTokenService(tokens), quota, pricing, 0), tokens, quota
+}
+
+// newQuotaRelayContext 按入口的方式构造带 Token 密钥的中转上下文
+func newQuotaRelayContext(t *testing.T, requestID string) *relay.RelayContext {
+	t.Helper()
+	rc, err := relay.NewRelayContextBuilder(requestID, relay.EndpointChatCompletions).
+		Token(&model.Token{ID: 3, UserID: 42, Name: "ci", TokenHash: "sk-quota"}).
+		Client("127.0.0.1").
+		Model("gpt-4", false).
+		Build()
+	require.NoError(t, err)
+	return rc
+}
+
+func quotaTestRequest() *relay.ChatCompletionRequest {
+	return &relay.ChatCompletionRequest{
+		Model:     "gpt-4",
+		Messages:  []relay.ChatMessage{{Role: "user", Content: "Summarize the quarterly report in three short bullet points."}},
+		MaxTokens: 20,
+	}
+}
+
+func TestRelayQuotaGuardConcurrentReservations(t *testing.T) {
+	ctx := context.Background()
+	req := quotaTestRequest()
+	estimate := int64(countPromptTokens(req) + req.MaxTokens)
+
+	// 剩余额度只够 3 笔预留
+	guard, tokens, quota := newTestQuotaGuard(t, 4*estimate-1, 0)
+
+	const workers = 10
+	var ok, rejected int32
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rc := newQuotaRelayContext(t, fmt.Sprintf("req-%d", i))
+			err := guard.Reserve(ctx, rc, quotaTestRequest())
+			switch {
+			case err == nil:
+				atomic.AddInt32(&ok, 1)
+				assert.Equal(t, estimate, rc.Reserved)
+			case errors.Is(err, billing.ErrInsufficientQuota):
+				atomic.AddInt32(&rejected, 1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, 3, ok)
+	assert.EqualValues(t, workers-3, rejected)
+	assert.Equal(t, float64(3*estimate), quota.GetReserved(tokenQuotaKey(3)))
+	assert.Zero(t, tokens.used(), "reservations are not persisted before settlement")
+}
+
+func TestRelayQuotaGuardSettle(t *testing.T) {
+	ctx := context.Background()
+	guard, tokens, quota := newTestQuotaGuard(t, 1000, 100)
+	key := tokenQuotaKey(3)
+
+	// 按实际用量扣费，多预留的部分退回
+	rc := newQuotaRelayContext(t, "req-1")
+	require.NoError(t, guard.Reserve(ctx, rc, quotaTestRequest()))
+	assert.Positive(t, rc.Reserved)
+	rc.Usage = &relay.ChatUsage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7}
+	guard.Settle(ctx, rc)
+	assert.EqualValues(t, 7, rc.Cost)
+	assert.EqualValues(t, 107, tokens.used())
+	assert.Zero(t, quota.GetReserved(key))
+	assert.Empty(t, rc.ReservationID)
+
+	// 没有用量（上游失败）时整笔释放
+	rc = newQuotaRelayContext(t, "req-2")
+	require.NoError(t, guard.Reserve(ctx, rc, quotaTestRequest()))
+	guard.Settle(ctx, rc)
+	assert.Zero(t, rc.Cost)
+	assert.EqualValues(t, 107, tokens.used())
+	assert.Zero(t, quota.GetReserved(key))
+
+	// 重复结算不会再次扣费
+	guard.Settle(ctx, rc)
+	assert.EqualValues(t, 107, tokens.used())
+}
+
+func TestRelayQuotaGuardRejectsInvalidToken(t *testing.T) {
+	guard, tokens, _ := newTestQuotaGuard(t, 1000, 0)
+	tokens.token.Status = model.TokenStatusDisabled
+
+	err := guard.Reserve(context.Background(), newQuotaRelayContext(t, "req-1"), quotaTestRequest())
+	assert.ErrorIs(t, err, ErrQuotaTokenRejected)
+}
+
+func TestRelayChatCompletionRejectsExhaustedToken(t *testing.T) {
+	var hits int32
+	s, _ := newTestRelayService(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	})
+	guard, _, _ := newTestQuotaGuard(t, 100, 100)
+	s.SetQuotaGuard(guard)
+
+	rc := newQuotaRelayContext(t, "req-1")
+	_, err := s.RelayChatCompletion(relay.WithRelayContext(context.Background(), rc), quotaTestRequest())
+	assert.ErrorIs(t, err, billing.ErrInsufficientQuota)
+	assert.Zero(t, atomic.LoadInt32(&hits), "upstream is never called")
+	assert.Empty(t, rc.Attempts)
+	assert.Same(t, err, rc.Err)
+}
+
+func TestRelayChatCompletionStreamClientDisconnect(t *testing.T) {
+	s, _ := newTestRelayService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 5; i++ {
+			fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hello \"}}]}\n\n")
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+	guard, tokens, quota := newTestQuotaGuard(t, 1000, 0)
+	s.SetQuotaGuard(guard)
+
+	rc := newQuotaRelayContext(t, "req-1")
+	disconnected := errors.New("client disconnected")
+	chunks := 0
+	err := s.RelayChatCompletionStream(relay.WithRelayContext(context.Background(), rc), quotaTestRequest(), func(chunk *relay.ChatCompletionResponse) error {
+		if chunks++; chunks == 3 {
+			return disconnected
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, disconnected)
+
+	// 按请求与已输出内容估算的用量结算，剩余预留退回
+	require.NotNil(t, rc.Usage)
+	assert.Positive(t, rc.Cost)
+	assert.Less(t, rc.Cost, rc.Reserved)
+	assert.Equal(t, rc.Cost, tokens.used())
+	assert.Zero(t, quota.GetReserved(tokenQuotaKey(3)))
+}
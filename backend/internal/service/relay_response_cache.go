@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/metrics"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"go.uber.org/zap"
+)
+
+const (
+	responseCacheKeyPrefix = "relay:response:"
+	responseCacheIndexKey  = "relay:response:index" // 按写入时间排序的键，用于限制条目数
+
+	// ResponseCacheHeader 开启响应缓存的请求头：true/1 开启，force 同时忽略 temperature 上限，false/0 关闭
+	ResponseCacheHeader = "X-Relay-Cache"
+	cacheModeForce      = "force"
+)
+
+// ResponseCacheConfig 响应缓存配置
+type ResponseCacheConfig struct {
+	TTL            time.Duration // 条目有效期
+	LocalSize      int           // 进程内 LRU 的最大条目数，0 表示不使用
+	MaxEntries     int64         // Redis 中的最大条目数，超出时删除最早写入的条目，0 表示不限制
+	MaxTemperature float64       // temperature 超过该值的请求不缓存，强制缓存除外
+	UserIDs        []int         // 允许使用缓存的用户，为空表示所有用户
+}
+
+// NewResponseCacheConfig 由服务配置创建响应缓存配置
+func NewResponseCacheConfig(cfg *config.ResponseCacheConfig) ResponseCacheConfig {
+	return ResponseCacheConfig{
+		TTL:            time.Duration(cfg.TTLSeconds) * time.Second,
+		LocalSize:      cfg.LocalSize,
+		MaxEntries:     int64(cfg.MaxEntries),
+		MaxTemperature: cfg.MaxTemperature,
+		UserIDs:        cfg.UserIDs,
+	}
+}
+
+// ResponseCache 缓存非流式 Chat Completion 的完整响应，相同的请求直接返回缓存的响应，不调用上游也不计费
+//
+// 请求需通过 cache 字段或 X-Relay-Cache 请求头开启。缓存键由调用方用户与规范化后的请求（去掉 stream 与 cache）
+// 的 SHA-256 组成，不同用户之间不共享响应。流式请求不缓存；temperature 超过上限的请求输出随机性较大，
+// 只有 X-Relay-Cache: force 时缓存。未设置 temperature（由渠道默认参数决定）按 0 处理。
+// 缓存读写失败只记录日志，按未命中处理。
+type ResponseCache struct {
+	redis  redis.UniversalClient // 为 nil 时只使用进程内缓存
+	local  *ttlLRU[[]byte]
+	config ResponseCacheConfig
+	users  map[int]bool
+}
+
+// NewResponseCache 创建响应缓存，client 为 nil 时只使用进程内 LRU
+func NewResponseCache(client redis.UniversalClient, config ResponseCacheConfig) *ResponseCache {
+	c := &ResponseCache{redis: client, config: config}
+	if config.LocalSize > 0 {
+		c.local = newTTLLRU[[]byte](config.LocalSize, config.TTL)
+	}
+	if len(config.UserIDs) > 0 {
+		c.users = make(map[int]bool, len(config.UserIDs))
+		for _, id := range config.UserIDs {
+			c.users[id] = true
+		}
+	}
+	return c
+}
+
+// Key 返回请求的缓存键，请求未开启缓存或不可缓存时返回 false
+func (c *ResponseCache) Key(rc *relay.RelayContext, req *relay.ChatCompletionRequest) (string, bool) {
+	enabled, force := req.Cache, false
+	switch mode := strings.ToLower(strings.TrimSpace(rc.Headers.Get(ResponseCacheHeader))); mode {
+	case "":
+	case cacheModeForce:
+		enabled, force = true, true
+	default:
+		if on, err := strconv.ParseBool(mode); err == nil {
+			enabled = on
+		}
+	}
+
+	switch {
+	case !enabled, req.Stream:
+		return "", false
+	case c.users != nil && !c.users[rc.UserID]:
+		return "", false
+	case !force && req.Temperature > c.config.MaxTemperature:
+		return "", false
+	}
+
+	normalized := *req
+	normalized.Stream = false
+	normalized.Cache = false
+	data, err := json.Marshal(&normalized)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	return responseCacheKeyPrefix + strconv.Itoa(rc.UserID) + ":" + hex.EncodeToString(sum[:]), true
+}
+
+// Get 查找缓存的响应，先查进程内缓存，再查 Redis 并回填
+func (c *ResponseCache) Get(ctx context.Context, key string) (*relay.ChatCompletionResponse, bool) {
+	data, ok := c.lookup(ctx, key)
+	if ok {
+		var resp relay.ChatCompletionResponse
+		if err := json.Unmarshal(data, &resp); err == nil {
+			metrics.CacheLookup("relay_response", true)
+			return &resp, true
+		}
+	}
+	metrics.CacheLookup("relay_response", false)
+	return nil, false
+}
+
+func (c *ResponseCache) lookup(ctx context.Context, key string) ([]byte, bool) {
+	if c.local != nil {
+		if data, ok := c.local.Get(key); ok {
+			return data, true
+		}
+	}
+	if c.redis == nil {
+		return nil, false
+	}
+
+	data, err := c.redis.Get(ctx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			logger.Warn("response cache read failed", zap.Error(err))
+		}
+		return nil, false
+	}
+	if c.local != nil {
+		c.local.Set(key, data)
+	}
+	return data, true
+}
+
+// Set 写入响应
+func (c *ResponseCache) Set(ctx context.Context, key string, resp *relay.ChatCompletionResponse) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	if c.local != nil {
+		c.local.Set(key, data)
+	}
+	if c.redis == nil {
+		return
+	}
+
+	pipe := c.redis.Pipeline()
+	pipe.Set(ctx, key, data, c.config.TTL)
+	pipe.ZAdd(ctx, responseCacheIndexKey, &redis.Z{Score: float64(time.Now().UnixNano()), Member: key})
+	size := pipe.ZCard(ctx, responseCacheIndexKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Warn("response cache write failed", zap.Error(err))
+		return
+	}
+
+	if max := c.config.MaxEntries; max > 0 && size.Val() > max {
+		if err := trimCacheIndex(ctx, c.redis, responseCacheIndexKey, size.Val()-max); err != nil {
+			logger.Warn("response cache trim failed", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestResponseCache() *ResponseCache {
+	return NewResponseCache(nil, ResponseCacheConfig{TTL: time.Hour, LocalSize: 100, MaxTemperature: 0.5})
+}
+
+func TestRelayResponseCacheHitIsNotBilled(t *testing.T) {
+	var hits int32
+	s, _ := newTestRelayService(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      "chatcmpl-1",
+			"choices": []map[string]interface{}{{"index": 0, "message": map[string]string{"role": "assistant", "content": "- revenue up\n- costs flat\n- outlook stable"}, "finish_reason": "stop"}},
+			"usage":   map[string]int{"prompt_tokens": 12, "completion_tokens": 9, "total_tokens": 21},
+		})
+	})
+	guard, tokens, _ := newTestQuotaGuard(t, 100000, 0)
+	s.SetQuotaGuard(guard)
+	s.SetResponseCache(newTestResponseCache())
+
+	relayOnce := func(requestID string) (*relay.RelayContext, []byte) {
+		rc := newQuotaRelayContext(t, requestID)
+		req := quotaTestRequest()
+		req.Cache = true
+		resp, err := s.RelayChatCompletion(relay.WithRelayContext(context.Background(), rc), req)
+		require.NoError(t, err)
+		body, err := json.Marshal(resp)
+		require.NoError(t, err)
+		return rc, body
+	}
+
+	first, firstBody := relayOnce("req-1")
+	assert.False(t, first.CacheHit)
+	assert.Positive(t, first.Cost)
+	used := tokens.used()
+
+	second, secondBody := relayOnce("req-2")
+	assert.True(t, second.CacheHit)
+	assert.Equal(t, string(firstBody), string(secondBody))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&hits), "upstream is called once")
+	assert.Zero(t, second.Cost)
+	assert.Nil(t, second.Usage)
+	assert.Empty(t, second.Attempts)
+	assert.Equal(t, used, tokens.used(), "cache hits are not billed")
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	cache := newTestResponseCache()
+	rc := newTestRelayContext(t)
+	base := func() *relay.ChatCompletionRequest {
+		return &relay.ChatCompletionRequest{Model: "gpt-4", Cache: true, Messages: []relay.ChatMessage{{Role: "user", Content: "hi"}}}
+	}
+
+	key, ok := cache.Key(rc, base())
+	require.True(t, ok)
+
+	// stream 与 cache 字段不影响缓存键
+	req := base()
+	req.Cache = false
+	rc.Headers = http.Header{ResponseCacheHeader: {"true"}}
+	other, ok := cache.Key(rc, req)
+	require.True(t, ok)
+	assert.Equal(t, key, other)
+
+	req = base()
+	req.MaxTokens = 10
+	other, _ = cache.Key(rc, req)
+	assert.NotEqual(t, key, other, "parameters are part of the key")
+
+	// 流式请求与 temperature 超过上限的请求不缓存，force 只放开 temperature 上限
+	req = base()
+	req.Stream = true
+	_, ok = cache.Key(rc, req)
+	assert.False(t, ok)
+
+	req = base()
+	req.Temperature = 0.9
+	_, ok = cache.Key(rc, req)
+	assert.False(t, ok)
+	rc.Headers.Set(ResponseCacheHeader, "force")
+	_, ok = cache.Key(rc, req)
+	assert.True(t, ok)
+
+	// 请求头可关闭请求体中的 cache
+	rc.Headers.Set(ResponseCacheHeader, "false")
+	_, ok = cache.Key(rc, base())
+	assert.False(t, ok)
+
+	// 未开启缓存的用户
+	rc.Headers = nil
+	restricted := NewResponseCache(nil, ResponseCacheConfig{TTL: time.Hour, LocalSize: 10, MaxTemperature: 0.5, UserIDs: []int{7}})
+	_, ok = restricted.Key(rc, base())
+	assert.False(t, ok)
+}
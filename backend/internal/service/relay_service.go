@@ -2,133 +2,1503 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/shirosoralumie648/Oblivious/backend/internal/adapter"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/logtail"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/metrics"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relaylog"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tokenizer"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
 )
 
+// projectKBResultLimit 每个项目知识库注入的检索结果数
+const projectKBResultLimit = 3
+
+// statusClientClosedRequest 客户端取消的请求在统一日志中记录的状态码（沿用 nginx 的 499）
+const statusClientClosedRequest = 499
+
 // RelayService 中转服务
 type RelayService struct {
-	selector       *relay.ChannelSelector
-	channelRepo    *repository.ChannelRepository
+	cache          *relay.ChannelCache
+	loadBalancer   *relay.LoadBalancer
+	health         *relay.HealthChecker
+	channelRepo    relayChannelSource
 	modelPriceRepo *repository.ModelPriceRepository
+	logRepo        *repository.UnifiedLogRepository
+	logWriter      *relaylog.Writer // 统一日志异步批量写入，为 nil 时同步写入
+	paramRuleRepo  *repository.ModelParamRuleRepository
+	fallbackRepo   *repository.ModelFallbackRuleRepository
+	fallbackRules  *relay.FallbackRules
+	projectRepo    *repository.ProjectRepository
+	ragService     *RAGService       // 项目知识库检索，可为 nil
+	tail           *logtail.Registry // 管理员实时跟踪，可为 nil
+	quotaGuard     *RelayQuotaGuard  // 上游调用前的额度预检，可为 nil
+	responseCache  *ResponseCache    // 非流式响应缓存，可为 nil
+	abilities      *relay.ChannelAbilityManager
+	hooks          []RelayCompletionHook
+
+	// chatAbilities 渠道的 Chat 能力（工具调用等），每次加载渠道时按渠道类型与设置重建
+	chatAbilities *relay.ChannelAbilityValidator
+
+	// 选择器渠道 ID -> 数据库渠道
+	channels   map[string]*model.Channel
+	loaded     bool
+	channelsMu sync.RWMutex
 }
 
+// relayChannelSource 中转加载渠道的来源（由 ChannelRepository 实现），只返回启用的渠道
+type relayChannelSource interface {
+	GetAll(ctx context.Context) ([]*model.Channel, error)
+}
+
+// RelayCompletionHook 请求结束后接收完整的中转上下文，如计费发布
+type RelayCompletionHook func(ctx context.Context, rc *relay.RelayContext)
+
 // NewRelayService 创建中转服务
 func NewRelayService() *RelayService {
+	cache := relay.NewChannelCache(relay.ChannelCacheLevelMemory)
+
 	return &RelayService{
-		selector:       relay.NewChannelSelector(),
+		cache:          cache,
+		loadBalancer:   relay.NewLoadBalancer(cache, relayLoadBalancerConfig()),
+		health:         relay.NewHealthChecker(cache, relay.DefaultHealthCheckConfig()),
 		channelRepo:    repository.NewChannelRepository(),
 		modelPriceRepo: repository.NewModelPriceRepository(),
+		logRepo:        repository.NewUnifiedLogRepository(),
+		paramRuleRepo:  repository.NewModelParamRuleRepository(),
+		fallbackRepo:   repository.NewModelFallbackRuleRepository(),
+		fallbackRules:  relay.NewFallbackRules(),
+		projectRepo:    repository.NewProjectRepository(),
+		abilities:      relay.NewChannelAbilityManager(),
+		chatAbilities:  relay.NewChannelAbilityValidator(relay.NewChannelAbilityManager()),
+		channels:       make(map[string]*model.Channel),
+	}
+}
+
+// relayLoadBalancerConfig 中转使用的负载均衡配置
+//
+// 健康检查与权重自适应需要后台任务，这里只使用选择、断路器与故障转移。
+func relayLoadBalancerConfig() *relay.LoadBalancerConfig {
+	lbConfig := relay.DefaultLoadBalancerConfig()
+	lbConfig.EnableHealthCheck = false
+	lbConfig.EnableAdaptiveWeight = false
+	return lbConfig
+}
+
+// SetFailoverConfig 设置故障转移参数，需在加载渠道之前调用
+//
+// queueTimeout 为渠道并发已满且没有其它可用渠道时排队等待的最长时间，0 表示直接返回 429。
+func (s *RelayService) SetFailoverConfig(maxRetries int, partialFailureWeight float64, queueTimeout time.Duration) {
+	lbConfig := relayLoadBalancerConfig()
+	lbConfig.MaxRetries = maxRetries
+	lbConfig.PartialFailureWeight = partialFailureWeight
+	lbConfig.ChannelQueueTimeout = queueTimeout
+	s.loadBalancer = relay.NewLoadBalancer(s.cache, lbConfig)
+}
+
+// SetLogTail 设置实时跟踪注册表，请求完成后向跟踪该用户的管理员推送元数据
+func (s *RelayService) SetLogTail(registry *logtail.Registry) {
+	s.tail = registry
+}
+
+// SetLogWriter 设置统一日志的异步写入器
+func (s *RelayService) SetLogWriter(writer *relaylog.Writer) {
+	s.logWriter = writer
+}
+
+// SetQuotaGuard 启用额度预检：上游调用前预留估算费用，请求结束后按实际用量结算
+func (s *RelayService) SetQuotaGuard(guard *RelayQuotaGuard) {
+	s.quotaGuard = guard
+}
+
+// AddCompletionHook 注册请求结束回调，需在开始处理请求之前调用
+func (s *RelayService) AddCompletionHook(hook RelayCompletionHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// SetResponseCache 设置非流式 Chat Completion 的响应缓存
+func (s *RelayService) SetResponseCache(cache *ResponseCache) {
+	s.responseCache = cache
+}
+
+// SetRAGService 设置知识库检索服务，用于注入项目关联知识库的上下文
+func (s *RelayService) SetRAGService(ragService *RAGService) {
+	s.ragService = ragService
+}
+
+// ApplyProject 将 API Token 绑定的项目配置应用到请求
+//
+// 合并系统提示词、默认模型与参数（遵循字段锁定），注入知识库检索结果，
+// 并把项目与元数据标签记录到中转上下文中用于日志和用量统计。
+func (s *RelayService) ApplyProject(ctx context.Context, token *model.Token, req *relay.ChatCompletionRequest) error {
+	if token == nil {
+		return nil
+	}
+
+	project, err := relay.ResolveProject(ctx, token, s.projectRepo.FindByID)
+	if err != nil || project == nil {
+		return err
+	}
+
+	ignored := relay.ApplyProject(req, project)
+	if rc := relay.RelayContextFromContext(ctx); rc != nil {
+		rc.ProjectID = project.ID
+		rc.Tags = project.MetadataTags
+		for _, field := range ignored {
+			rc.Warnings = append(rc.Warnings, fmt.Sprintf("%s is locked by project %d; client value ignored", field, project.ID))
+		}
+	}
+
+	s.injectKnowledge(ctx, project, req)
+	return nil
+}
+
+// injectKnowledge 检索项目关联的知识库并追加到系统消息，检索失败不影响请求
+func (s *RelayService) injectKnowledge(ctx context.Context, project *model.Project, req *relay.ChatCompletionRequest) {
+	if s.ragService == nil || len(project.KnowledgeBaseIDs) == 0 {
+		return
+	}
+
+	query := relay.LastUserMessage(req)
+	if query == "" {
+		return
+	}
+
+	for _, kbID := range project.KnowledgeBaseIDs {
+		// 以项目所有者身份检索，项目无法引用他人的知识库
+		kbContext, err := s.ragService.BuildRAGContext(ctx, project.UserID, int(kbID), query, projectKBResultLimit)
+		if err != nil {
+			logger.Ctx(ctx).Warn("project knowledge base retrieval failed",
+				zap.Int("project_id", project.ID),
+				zap.Int64("kb_id", kbID),
+				zap.Error(err))
+			continue
+		}
+		relay.AppendSystemContext(req, kbContext)
+	}
+}
+
+// ReloadChannels 从数据库重新加载渠道到选择器缓存
+func (s *RelayService) ReloadChannels(ctx context.Context) error {
+	dbChannels, err := s.channelRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load channels: %w", err)
+	}
+
+	channels := make(map[string]*model.Channel, len(dbChannels))
+	relayChannels := make([]*relay.Channel, 0, len(dbChannels))
+	keys := make(map[string][]string, len(dbChannels))
+	abilities := relay.NewChannelAbilityManager()
+	abilities.SetLogFunc(func(level, msg string, args ...interface{}) {})
+	for _, ch := range dbChannels {
+		rc := toRelayChannel(ch)
+		channels[rc.ID] = ch
+		relayChannels = append(relayChannels, rc)
+		keys[rc.ID] = ch.GetKeys()
+		if ch.IsEnabled() {
+			if err := abilities.RegisterAbility(rc.ID, chatAbility(ch)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.cache.RefreshCache(relayChannels); err != nil {
+		return err
+	}
+	s.loadBalancer.SyncChannelKeys(keys)
+
+	s.channelsMu.Lock()
+	s.channels = channels
+	s.chatAbilities = relay.NewChannelAbilityValidator(abilities)
+	s.loaded = true
+	s.channelsMu.Unlock()
+
+	s.loadParamRules(ctx)
+	if err := s.ReloadFallbackRules(ctx); err != nil {
+		logger.Warn("failed to load model fallback rules, keeping current rules", zap.Error(err))
+	}
+
+	return nil
+}
+
+// ReloadFallbackRules 从数据库重新加载启用的模型降级规则，失败时保留当前规则
+func (s *RelayService) ReloadFallbackRules(ctx context.Context) error {
+	if s.fallbackRepo == nil {
+		return nil
+	}
+
+	dbRules, err := s.fallbackRepo.ListEnabled(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load model fallback rules: %w", err)
+	}
+	rules := make([]*relay.FallbackRule, 0, len(dbRules))
+	for _, r := range dbRules {
+		rules = append(rules, &relay.FallbackRule{
+			ModelPattern: r.ModelPattern,
+			UserGroup:    r.UserGroup,
+			Models:       r.GetFallbackModels(),
+		})
+	}
+	return s.fallbackRules.Set(rules)
+}
+
+// loadParamRules 从数据库加载模型参数适配规则，未配置时沿用内置规则
+func (s *RelayService) loadParamRules(ctx context.Context) {
+	if s.paramRuleRepo == nil {
+		return
+	}
+
+	dbRules, err := s.paramRuleRepo.ListEnabled(ctx)
+	if err != nil {
+		logger.Warn("failed to load model param rules, keeping current rules", zap.Error(err))
+		return
+	}
+	if len(dbRules) == 0 {
+		return
+	}
+
+	rules := make([]*adapter.ModelParamRule, 0, len(dbRules))
+	for _, r := range dbRules {
+		rules = append(rules, &adapter.ModelParamRule{
+			Family:                  r.Family,
+			ModelPrefixes:           r.GetModelPrefixes(),
+			MaxTokensField:          r.MaxTokensField,
+			UnsupportedParams:       r.GetUnsupportedParams(),
+			SupportsReasoningEffort: r.SupportsReasoningEffort,
+		})
+	}
+	if err := adapter.GetParamRuleSet().Set(rules); err != nil {
+		logger.Warn("invalid model param rules, keeping current rules", zap.Error(err))
+	}
+}
+
+// adaptParams 按模型家族规则调整参数，丢弃的参数记录为警告
+func (s *RelayService) adaptParams(adaptor adapter.Adapter, req *adapter.OpenAIRequest, rc *relay.RelayContext) error {
+	pa, ok := adaptor.(adapter.ParamAdapter)
+	if !ok {
+		return nil
+	}
+
+	warnings, err := pa.AdaptParams(req)
+	if err != nil {
+		return err
+	}
+	rc.Warnings = append(rc.Warnings, warnings...)
+	return nil
+}
+
+// filterExtraBody 按渠道 allowlist 过滤 extra_body，不支持透传的适配器直接忽略
+func (s *RelayService) filterExtraBody(adaptor adapter.Adapter, req *adapter.OpenAIRequest, rc *relay.RelayContext) {
+	if len(req.Extra) == 0 {
+		return
+	}
+
+	eb, ok := adaptor.(adapter.ExtraBodyAdapter)
+	if !ok {
+		rc.Warnings = append(rc.Warnings, fmt.Sprintf("extra_body is not supported by channel type %s and was ignored", adaptor.Name()))
+		req.Extra = nil
+		return
+	}
+	for _, key := range eb.DroppedExtraBody(req) {
+		rc.Warnings = append(rc.Warnings, fmt.Sprintf("extra_body.%s is not allowed for this channel and was dropped", key))
+	}
+}
+
+// applyChannelParams 应用渠道的默认参数与强制参数，用户设置的值被修改时记录为警告
+func applyChannelParams(channel *model.Channel, req *adapter.OpenAIRequest, rc *relay.RelayContext) {
+	settings := channel.GetSettings()
+	if len(settings.ParamDefaults) == 0 && len(settings.ParamOverrides) == 0 {
+		return
+	}
+	notes := adapter.ApplyChannelParams(req, settings.ParamDefaults, settings.ParamOverrides)
+	rc.Warnings = append(rc.Warnings, notes...)
+}
+
+// ensureChannels 首次使用时从数据库加载渠道，之后只在 ReloadChannels 时刷新
+func (s *RelayService) ensureChannels(ctx context.Context) error {
+	s.channelsMu.RLock()
+	loaded := s.loaded
+	s.channelsMu.RUnlock()
+	if loaded {
+		return nil
+	}
+	return s.ReloadChannels(ctx)
+}
+
+// getChannel 根据选择器渠道 ID 获取数据库渠道
+func (s *RelayService) getChannel(id string) (*model.Channel, error) {
+	s.channelsMu.RLock()
+	channel, ok := s.channels[id]
+	s.channelsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("channel %s not found", id)
+	}
+	return channel, nil
+}
+
+// withFailover 选择支持该模型的渠道执行 attempt，可重试的失败会切换到其它渠道
+func (s *RelayService) withFailover(ctx context.Context, rc *relay.RelayContext, attempt func(ctx context.Context, channel *model.Channel) error) error {
+	return s.withFailoverType(ctx, rc, "", attempt)
+}
+
+// withFailoverType 与 withFailover 相同，但只在指定类型的渠道间选择，channelType 为空时不限
+func (s *RelayService) withFailoverType(ctx context.Context, rc *relay.RelayContext, channelType string, attempt func(ctx context.Context, channel *model.Channel) error) error {
+	return s.failover(ctx, rc, &relay.ChannelSelectOptions{ChannelType: channelType}, attempt)
+}
+
+// withFailoverFeature 与 withFailover 相同，但只在能力中标记了 feature 的渠道间选择（如图像生成、语音）
+func (s *RelayService) withFailoverFeature(ctx context.Context, rc *relay.RelayContext, feature string, attempt func(ctx context.Context, channel *model.Channel) error) error {
+	return s.failover(ctx, rc, &relay.ChannelSelectOptions{Feature: feature}, attempt)
+}
+
+// withChatFailover 与 withFailover 相同，但请求依赖工具调用等能力时只在具备这些能力的渠道间选择，
+// 提供该模型的渠道都不具备时返回 relay.FeatureUnsupportedError
+func (s *RelayService) withChatFailover(ctx context.Context, rc *relay.RelayContext, req *relay.ChatCompletionRequest, attempt func(ctx context.Context, channel *model.Channel) error) error {
+	options := &relay.ChannelSelectOptions{}
+	if features := relay.ChatAbilityFeatures(req); features != nil {
+		if err := s.ensureChannels(ctx); err != nil {
+			return err
+		}
+		channelIDs, err := s.chatChannels(rc.Model, features)
+		if err != nil {
+			return err
+		}
+		options.ChannelIDs = channelIDs
+	}
+	return s.failover(ctx, rc, options, attempt)
+}
+
+// chatChannels 提供该模型且具备 features 的渠道 ID
+//
+// 没有渠道提供该模型时返回 nil，由渠道选择返回 relay.ErrModelNotSupported。
+func (s *RelayService) chatChannels(modelName string, features map[relay.ChannelAbilityFeature]bool) ([]string, error) {
+	s.channelsMu.RLock()
+	validator := s.chatAbilities
+	s.channelsMu.RUnlock()
+
+	channelIDs, err := validator.GetCompatibleChannels(modelName, features)
+	if err != nil || len(channelIDs) > 0 {
+		return channelIDs, err
+	}
+	gaps := validator.FeatureGaps(modelName, features)
+	if len(gaps) == 0 {
+		return nil, nil
+	}
+	required := make([]relay.ChannelAbilityFeature, 0, len(features))
+	for feature, ok := range features {
+		if ok {
+			required = append(required, feature)
+		}
+	}
+	sort.Slice(required, func(i, j int) bool { return required[i] < required[j] })
+	return nil, &relay.FeatureUnsupportedError{Model: modelName, Features: required, Channels: gaps}
+}
+
+// failover 按 options 限定的范围选择支持该模型的渠道执行 attempt
+func (s *RelayService) failover(ctx context.Context, rc *relay.RelayContext, options *relay.ChannelSelectOptions, attempt func(ctx context.Context, channel *model.Channel) error) error {
+	if err := s.ensureChannels(ctx); err != nil {
+		return err
+	}
+
+	// 每次尝试都会重新适配参数，只保留尝试之前（如项目合并）产生的警告
+	baseWarnings := len(rc.Warnings)
+	options.Model, options.Region, options.HashKey = rc.Model, rc.Region, rc.HashKey()
+	return s.loadBalancer.ExecuteWithFailover(ctx, options, func(ctx context.Context, selected *relay.Channel) error {
+		channel, err := s.getChannel(selected.ID)
+		if err != nil {
+			return err
+		}
+		rc.BeginAttempt(channel.ID, channel.Name, baseWarnings)
+
+		// 上游调用的 span 覆盖整个尝试，流式响应在读完最后一个数据块后结束
+		ctx, span := tracing.Start(ctx, "relay.upstream",
+			attribute.Int("channel_id", channel.ID), attribute.String("channel_type", channel.Type), attribute.String("model", rc.Model))
+		err = attempt(ctx, channel)
+		if n := len(rc.Attempts); n > 0 && rc.Attempts[n-1].Usage != nil {
+			usage := rc.Attempts[n-1].Usage
+			span.SetAttributes(attribute.Int("prompt_tokens", usage.PromptTokens), attribute.Int("completion_tokens", usage.CompletionTokens))
+		}
+		tracing.End(span, err)
+		return err
+	})
+}
+
+// withModelFallback 执行 run，请求的模型没有可用渠道时按降级规则依次改用降级模型重试
+//
+// 降级成功后中转上下文的 Model 为实际使用的模型（计费与日志按它计算），RequestedModel 为原模型，
+// 并追加一条说明降级的警告；降级链全部没有可用渠道时恢复原模型并返回原错误。
+func (s *RelayService) withModelFallback(rc *relay.RelayContext, setModel func(name string), run func() error) error {
+	err := run()
+	if !isNoChannelError(err) {
+		return err
+	}
+	fallbacks := s.fallbackRules.Resolve(rc.Model, rc.Group)
+	if len(fallbacks) == 0 {
+		return err
+	}
+
+	requested := rc.Model
+	baseWarnings := len(rc.Warnings)
+	for _, fallback := range fallbacks {
+		rc.Model, rc.RequestedModel = fallback, requested
+		setModel(fallback)
+		rc.Warnings = append(rc.Warnings[:baseWarnings], fmt.Sprintf("model %s has no available channel; fell back to %s", requested, fallback))
+		fallbackErr := run()
+		if !isNoChannelError(fallbackErr) {
+			return fallbackErr
+		}
+	}
+
+	rc.Model, rc.RequestedModel = requested, ""
+	setModel(requested)
+	rc.Warnings = rc.Warnings[:baseWarnings]
+	return err
+}
+
+// isNoChannelError 渠道选择没有找到提供该模型的可用渠道
+func isNoChannelError(err error) bool {
+	return errors.Is(err, relay.ErrNoAvailableChannel) || errors.Is(err, relay.ErrModelNotSupported)
+}
+
+// channelKey 本次尝试使用的渠道密钥：多密钥渠道为负载均衡器轮询选中的密钥，否则为渠道密钥
+func channelKey(ctx context.Context, channel *model.Channel) string {
+	if sel, ok := relay.ChannelKeyFromContext(ctx); ok {
+		return sel.Key
+	}
+	return channel.PrimaryKey()
+}
+
+// channelAdapter 创建使用本次尝试所选密钥的渠道适配器
+func channelAdapter(ctx context.Context, channel *model.Channel) (adapter.Adapter, error) {
+	return adapter.GetAdapterByChannelKey(channel, channelKey(ctx, channel))
+}
+
+// ChannelKeyHealth 渠道各密钥的健康状态（密钥已掩码），单密钥渠道返回 nil
+func (s *RelayService) ChannelKeyHealth(channelID int) []relay.KeyHealth {
+	return s.loadBalancer.ChannelKeyHealth(strconv.Itoa(channelID))
+}
+
+// CircuitBreakerStates 每个渠道的断路器状态
+func (s *RelayService) CircuitBreakerStates() []relay.CircuitBreakerState {
+	return s.loadBalancer.GetCircuitBreakerStates()
+}
+
+// ResetCircuitBreaker 强制关闭渠道的断路器，渠道不存在时返回 relay.ErrChannelNotFound
+func (s *RelayService) ResetCircuitBreaker(channelID int) error {
+	return s.loadBalancer.ResetCircuitBreaker(strconv.Itoa(channelID))
+}
+
+// ChannelConcurrency 每个渠道进行中与排队的请求数
+func (s *RelayService) ChannelConcurrency() []relay.ChannelConcurrency {
+	return s.loadBalancer.GetChannelConcurrency()
+}
+
+// TripCircuitBreaker 强制打开渠道的断路器，用于禁用或删除渠道后立即停止向其转发
+func (s *RelayService) TripCircuitBreaker(channelID int) {
+	s.loadBalancer.TripCircuitBreaker(strconv.Itoa(channelID))
+}
+
+// StartHealthChecks 加载渠道并按 interval 在后台探测渠道健康状态
+// 未调用时只在管理员手动触发时探测
+func (s *RelayService) StartHealthChecks(ctx context.Context, interval time.Duration) error {
+	if err := s.ensureChannels(ctx); err != nil {
+		return err
+	}
+	config := relay.DefaultHealthCheckConfig()
+	config.Interval = interval
+	s.health = relay.NewHealthChecker(s.cache, config)
+	s.health.Start()
+	return nil
+}
+
+// StopHealthChecks 停止后台健康探测，只在 StartHealthChecks 成功后调用
+func (s *RelayService) StopHealthChecks() {
+	s.health.Stop()
+}
+
+// SetHealthCheckInterval 修改后台健康探测的间隔（配置热加载），只在 StartHealthChecks 成功后调用
+func (s *RelayService) SetHealthCheckInterval(interval time.Duration) {
+	s.health.SetInterval(interval)
+}
+
+// ChannelHealth 渠道的健康检查状态与最近的检查结果，渠道不存在时返回 relay.ErrChannelNotFound
+func (s *RelayService) ChannelHealth(ctx context.Context, channelID int) (*relay.ChannelHealth, error) {
+	if err := s.ensureChannels(ctx); err != nil {
+		return nil, err
+	}
+	return s.health.GetChannelHealth(strconv.Itoa(channelID))
+}
+
+// CheckChannelHealth 立即探测渠道并返回结果，渠道不存在时返回 relay.ErrChannelNotFound
+func (s *RelayService) CheckChannelHealth(ctx context.Context, channelID int) (*relay.HealthCheckResult, error) {
+	if err := s.ensureChannels(ctx); err != nil {
+		return nil, err
+	}
+	return s.health.CheckNow(strconv.Itoa(channelID))
+}
+
+// toRelayChannel 将数据库渠道转换为选择器渠道
+func toRelayChannel(ch *model.Channel) *relay.Channel {
+	rc := relay.NewChannel(strconv.Itoa(ch.ID), ch.Name, ch.BaseURL, ch.Type)
+	rc.Priority = int(ch.Priority)
+	if ch.Weight > 0 {
+		rc.Weight = ch.Weight
 	}
+	// 健康探测使用渠道密钥
+	for i, key := range ch.GetKeys() {
+		rc.Keys = append(rc.Keys, &relay.ChannelKey{ID: strconv.Itoa(i), APIKey: key, Enabled: true})
+	}
+	settings := ch.GetSettings()
+	rc.Ability.MaxConcurrency = settings.MaxConcurrency
+	for _, capability := range settings.Capabilities {
+		rc.Ability.Features[capability] = true
+	}
+	rc.Ability.SupportedModels = ch.GetSupportedModels()
+	if len(rc.Ability.SupportedModels) == 0 {
+		// 未配置模型列表的渠道支持所有模型
+		rc.Ability.SupportedModels = []string{"*"}
+	}
+	return rc
 }
 
-// RelayChatCompletion 中转 Chat Completion 请求
+// chatAbilityVersion 按渠道类型与设置生成的 Chat 能力的版本号
+const chatAbilityVersion = "channel"
+
+// chatAbility 渠道的 Chat 能力：工具调用、JSON 模式与图片输入取决于渠道类型的适配器是否转发相应参数，可由渠道设置关闭
+func chatAbility(ch *model.Channel) *relay.ChannelAbilityVersion {
+	providerType := adapter.ParseProviderType(ch.Type)
+	tools, parallel := providerType.ToolCallSupport()
+	jsonMode := providerType.JSONModeSupport()
+	vision := providerType.VisionSupport()
+	for _, feature := range ch.GetSettings().DisabledFeatures {
+		switch relay.ChannelAbilityFeature(feature) {
+		case relay.FeatureFunctionCalling:
+			tools = false
+		case relay.FeatureParallelFunctions:
+			parallel = false
+		case relay.FeatureJSONMode:
+			jsonMode = false
+		case relay.FeatureVision:
+			vision = false
+		}
+	}
+
+	config := relay.ChannelAbilityConfig{
+		ChannelID: strconv.Itoa(ch.ID),
+		Version:   chatAbilityVersion,
+		Models:    ch.GetSupportedModels(),
+	}
+	if len(config.Models) == 0 {
+		config.Models = []string{"*"}
+	}
+	if tools {
+		config.Features = append(config.Features, relay.FeatureFunctionCalling)
+		if parallel {
+			config.Features = append(config.Features, relay.FeatureParallelFunctions)
+		}
+	}
+	if jsonMode {
+		config.Features = append(config.Features, relay.FeatureJSONMode)
+	}
+	if vision {
+		config.Features = append(config.Features, relay.FeatureVision)
+	}
+	return config.BuildAbilityVersion("derived from channel type and settings")
+}
+
+// RelayChatCompletion 中转 Chat Completion 请求，上游失败时按负载均衡配置切换渠道重试
 func (s *RelayService) RelayChatCompletion(ctx context.Context, req *relay.ChatCompletionRequest) (*relay.ChatCompletionResponse, error) {
-	// 1. 选择渠道
-	channel, err := s.selector.SelectChannel(ctx, req.Model)
+	ctx, rc := s.beginRelay(ctx, relay.EndpointChatCompletions, req, req.Model, req.Stream, relay.ChatFeatures(req))
+
+	// 命中响应缓存时不预留额度、不调用上游，用量为空因此不计费
+	var cacheKey string
+	cacheable := false
+	if s.responseCache != nil {
+		cacheKey, cacheable = s.responseCache.Key(rc, req)
+	}
+	if cacheable {
+		if resp, ok := s.responseCache.Get(ctx, cacheKey); ok {
+			rc.CacheHit = true
+			rc.Warnings = resp.Warnings
+			s.finish(ctx, rc, nil, chatAttemptContent(req.Messages))
+			return resp, nil
+		}
+	}
+
+	if err := s.reserveQuota(ctx, rc, req); err != nil {
+		s.finish(ctx, rc, err, chatAttemptContent(req.Messages))
+		return nil, err
+	}
+
+	var resp *relay.ChatCompletionResponse
+	err := s.withModelFallback(rc, func(name string) { req.Model = name }, func() error {
+		return s.withChatFailover(ctx, rc, req, func(ctx context.Context, channel *model.Channel) error {
+			return withJSONRetry(req, func(req *relay.ChatCompletionRequest) error {
+				var err error
+				resp, err = s.chatCompletion(ctx, rc, channel, req)
+				return err
+			})
+		})
+	})
+	s.finish(ctx, rc, err, chatAttemptContent(req.Messages))
 	if err != nil {
-		return nil, fmt.Errorf("failed to select channel: %w", err)
+		return nil, err
+	}
+
+	resp.Warnings = rc.Warnings
+	// 降级模型的响应不缓存，避免原模型恢复后仍返回降级模型的结果
+	if cacheable && rc.RequestedModel == "" {
+		s.responseCache.Set(ctx, cacheKey, resp)
 	}
+	return resp, nil
+}
+
+// chatCompletion 在指定渠道上执行一次非流式请求
+func (s *RelayService) chatCompletion(ctx context.Context, rc *relay.RelayContext, channel *model.Channel, req *relay.ChatCompletionRequest) (*relay.ChatCompletionResponse, error) {
+	start := time.Now()
 
-	// 2. 获取适配器
-	adaptor, err := adapter.GetAdapterByChannel(channel)
+	// 1. 获取适配器
+	adaptor, err := channelAdapter(ctx, channel)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create adapter: %w", err)
 	}
 
-	// 3. 转换请求
+	// 2. 转换请求
 	// 注意：adapter 包使用的是 adapter.OpenAIRequest，我们需要做类型转换
 	adapterReq := s.convertToAdapterRequest(req)
+	if err := s.adaptParams(adaptor, adapterReq, rc); err != nil {
+		return nil, err
+	}
+	s.filterExtraBody(adaptor, adapterReq, rc)
+	applyChannelParams(channel, adapterReq, rc)
 	convertedReq, err := adaptor.ConvertRequest(adapterReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert request: %w", err)
 	}
 
-	// 4. 发送请求
-	httpResp, err := adaptor.DoRequest(ctx, convertedReq)
+	// 3. 发送请求
+	httpResp, err := adaptor.DoRequest(upstreamContext(ctx, rc), convertedReq)
 	if err != nil {
+		rc.EndAttempt(start, nil, err, nil)
 		return nil, fmt.Errorf("upstream request failed: %w", err)
 	}
+	if err := s.checkUpstream(adaptor, httpResp, rc); err != nil {
+		rc.EndAttempt(start, nil, err, nil)
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	rc.EnterPhase(relay.PhaseGeneration)
 
-	// 5. 解析响应
+	// 4. 解析响应
 	adapterResp, err := adaptor.ParseResponse(httpResp)
 	if err != nil {
+		rc.EndAttempt(start, nil, err, nil)
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
+	// strict_json：输出不是合法 JSON 时本次尝试记为失败，上游已生成内容，用量照常记录
+	if req.StrictJSON {
+		if err := validateJSONResponse(adapterResp, relay.ResponseFormatType(req)); err != nil {
+			rc.EndAttempt(start, attemptUsage(&adapterResp.Usage), err, func() string { return responseText(adapterResp) })
+			return nil, err
+		}
+	}
+	rc.EndAttempt(start, attemptUsage(&adapterResp.Usage), nil, func() string { return responseText(adapterResp) })
 
-	// 6. 转换响应回 Relay 格式
+	// 5. 转换响应回 Relay 格式
 	return s.convertFromAdapterResponse(adapterResp), nil
 }
 
 // RelayChatCompletionStream 中转流式 Chat Completion 请求
+//
+// 在开始向客户端输出之前（连接失败、上游返回错误状态码）可以切换渠道重试，
+// 一旦开始输出就不再切换，避免客户端收到两个渠道拼接的内容。
+//
+// strict_json 请求先缓冲完整响应，校验为合法 JSON 后才一次性输出：客户端在生成结束前收不到任何数据块，
+// 首字延迟等于完整的生成时间（校验失败重试时还要加上重试的时间），只应在需要可靠 JSON 时开启。
 func (s *RelayService) RelayChatCompletionStream(ctx context.Context, req *relay.ChatCompletionRequest, handler func(chunk *relay.ChatCompletionResponse) error) error {
-	// 1. 选择渠道
-	channel, err := s.selector.SelectChannel(ctx, req.Model)
-	if err != nil {
-		return fmt.Errorf("failed to select channel: %w", err)
+	req.Stream = true
+	ctx, rc := s.beginRelay(ctx, relay.EndpointChatCompletions, req, req.Model, req.Stream, relay.ChatFeatures(req))
+	if err := s.reserveQuota(ctx, rc, req); err != nil {
+		s.finish(ctx, rc, err, chatAttemptContent(req.Messages))
+		return err
 	}
 
-	// 2. 获取适配器
-	adaptor, err := adapter.GetAdapterByChannel(channel)
+	err := s.withModelFallback(rc, func(name string) { req.Model = name }, func() error {
+		return s.withChatFailover(ctx, rc, req, func(ctx context.Context, channel *model.Channel) error {
+			return withJSONRetry(req, func(req *relay.ChatCompletionRequest) error {
+				return s.chatCompletionStream(ctx, rc, channel, req, handler)
+			})
+		})
+	})
+	s.finish(ctx, rc, err, chatAttemptContent(req.Messages))
+	return err
+}
+
+// chatCompletionStream 在指定渠道上执行一次流式请求
+func (s *RelayService) chatCompletionStream(ctx context.Context, rc *relay.RelayContext, channel *model.Channel, req *relay.ChatCompletionRequest, handler func(chunk *relay.ChatCompletionResponse) error) error {
+	start := time.Now()
+
+	// 1. 获取适配器
+	adaptor, err := channelAdapter(ctx, channel)
 	if err != nil {
 		return fmt.Errorf("failed to create adapter: %w", err)
 	}
 
-	// 3. 转换请求
-	req.Stream = true
+	// 2. 转换请求
 	adapterReq := s.convertToAdapterRequest(req)
+	if err := s.adaptParams(adaptor, adapterReq, rc); err != nil {
+		return err
+	}
+	s.filterExtraBody(adaptor, adapterReq, rc)
+	applyChannelParams(channel, adapterReq, rc)
 	convertedReq, err := adaptor.ConvertRequest(adapterReq)
 	if err != nil {
 		return fmt.Errorf("failed to convert request: %w", err)
 	}
 
-	// 4. 发送请求
-	httpResp, err := adaptor.DoRequest(ctx, convertedReq)
+	// 3. 发送请求，客户端断开时取消上游请求，不再读取无人接收的内容
+	upstreamCtx, cancelUpstream := context.WithCancel(ctx)
+	defer cancelUpstream()
+	httpResp, err := adaptor.DoRequest(upstreamContext(upstreamCtx, rc), convertedReq)
 	if err != nil {
+		if ctx.Err() != nil {
+			return endCancelledStream(rc, start, req, nil, "", context.Cause(ctx))
+		}
+		rc.EndAttempt(start, nil, err, nil)
 		return fmt.Errorf("upstream request failed: %w", err)
 	}
+	// 流式响应的请求头先于响应体到达，此时即可拿到上游请求 ID
+	if err := s.checkUpstream(adaptor, httpResp, rc); err != nil {
+		rc.EndAttempt(start, nil, err, nil)
+		return err
+	}
 
-	// 5. 解析流式响应
+	// 4. 解析流式响应
 	streamChan, err := adaptor.ParseStreamResponse(httpResp)
 	if err != nil {
+		rc.EndAttempt(start, nil, err, nil)
 		return fmt.Errorf("failed to parse stream response: %w", err)
 	}
 
-	// 6. 处理流式数据
+	// 5. 处理流式数据，已开始输出的错误不再切换渠道
+	usage := &adapter.Usage{}
+	forwarded := false
+	var delivered strings.Builder
+	// strict_json 时缓冲全部数据块，校验通过后再输出
+	var buffered []*adapter.StreamChunk
+	var output *jsonOutput
+	if req.StrictJSON {
+		output = newJSONOutput()
+	}
 	for chunk := range streamChan {
-		relayChunk := s.convertFromAdapterStreamChunk(chunk)
-		if err := handler(relayChunk); err != nil {
+		if chunk.Err != nil {
+			drainStream(streamChan)
+			if ctx.Err() != nil {
+				// 读取中断是因为客户端已断开，不是上游故障
+				return endCancelledStream(rc, start, req, usage, delivered.String(), context.Cause(ctx))
+			}
+			interrupted := &relay.StreamInterruptedError{
+				Class:        chunk.Err.Class,
+				Message:      chunk.Err.Message,
+				Partial:      forwarded,
+				PromptTokens: usage.PromptTokens,
+			}
+			if !forwarded {
+				rc.EndAttempt(start, nil, interrupted, nil)
+				return interrupted
+			}
+			// 只按已经输出给客户端的内容计费，上游未报告输入 Token 时按请求估算
+			interrupted.DeliveredTokens = countTextTokens(req.Model, delivered.String())
+			if interrupted.PromptTokens == 0 {
+				interrupted.PromptTokens = countPromptTokens(req)
+			}
+			attempt := rc.EndAttempt(start, &relay.ChatUsage{
+				PromptTokens:     interrupted.PromptTokens,
+				CompletionTokens: interrupted.DeliveredTokens,
+				TotalTokens:      interrupted.PromptTokens + interrupted.DeliveredTokens,
+			}, interrupted, delivered.String)
+			attempt.UsageEstimated = true
+			return interrupted
+		}
+
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if !forwarded && len(chunk.Choices) > 0 {
+			rc.EnterPhase(relay.PhaseGeneration)
+		}
+		if output != nil {
+			buffered = append(buffered, chunk)
+			for i := range chunk.Choices {
+				output.add(chunk.Choices[i].Index, chunk.Choices[i].Delta)
+			}
+			continue
+		}
+		if err := s.forwardStreamChunk(rc, chunk, handler, &forwarded, &delivered); err != nil {
+			// 写入客户端失败：先取消上游请求再丢弃已缓冲的数据块
+			cancelUpstream()
+			drainStream(streamChan)
+			return endCancelledStream(rc, start, req, usage, delivered.String(), err)
+		}
+	}
+
+	if output != nil {
+		if err := output.validate(relay.ResponseFormatType(req)); err != nil {
+			var generated strings.Builder
+			for _, chunk := range buffered {
+				generated.WriteString(chunk.DeltaText())
+			}
+			attempt := rc.EndAttempt(start, attemptUsage(usage), err, generated.String)
+			estimateStreamUsage(attempt, req, generated.String())
 			return err
 		}
+		for _, chunk := range buffered {
+			if err := s.forwardStreamChunk(rc, chunk, handler, &forwarded, &delivered); err != nil {
+				return endCancelledStream(rc, start, req, usage, delivered.String(), err)
+			}
+		}
 	}
 
+	attempt := rc.EndAttempt(start, attemptUsage(usage), nil, delivered.String)
+	estimateStreamUsage(attempt, req, delivered.String())
 	return nil
 }
 
+// forwardStreamChunk 向客户端输出一个数据块，记录首字时间与已输出的内容
+func (s *RelayService) forwardStreamChunk(rc *relay.RelayContext, chunk *adapter.StreamChunk, handler func(chunk *relay.ChatCompletionResponse) error, forwarded *bool, delivered *strings.Builder) error {
+	if err := handler(s.convertFromAdapterStreamChunk(chunk)); err != nil {
+		return err
+	}
+	if len(chunk.Choices) > 0 {
+		*forwarded = true
+		rc.MarkFirstByte()
+	}
+	delivered.WriteString(chunk.DeltaText())
+	return nil
+}
+
+// estimateStreamUsage 按请求与已输出内容估算流式响应的用量
+//
+// 很多提供方的流式响应不带用量：上游未报告输出 Token 时以估算值计费并标记为估算，
+// 未报告输入 Token 时同样按请求估算；上游报告了用量时仍以报告值计费，估算值只记录在日志中用于监控偏差。
+func estimateStreamUsage(attempt *relay.RelayAttempt, req *relay.ChatCompletionRequest, delivered string) {
+	promptTokens := countPromptTokens(req)
+	completionTokens := countTextTokens(req.Model, delivered)
+	estimated := &relay.ChatUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+
+	reported := attempt.Usage
+	if reported != nil && reported.CompletionTokens > 0 {
+		attempt.EstimatedUsage = estimated
+		return
+	}
+	if reported != nil && reported.PromptTokens > 0 {
+		estimated.PromptTokens = reported.PromptTokens
+		estimated.TotalTokens = estimated.PromptTokens + completionTokens
+	}
+	attempt.Usage = estimated
+	attempt.UsageEstimated = true
+}
+
+// endCancelledStream 客户端取消时结束本次尝试：只按取消前已输出的内容计费，
+// 尚未输出任何内容时不计费；上游未报告输入 Token 时按请求估算
+func endCancelledStream(rc *relay.RelayContext, start time.Time, req *relay.ChatCompletionRequest, usage *adapter.Usage, delivered string, cause error) error {
+	cancelled := &relay.ClientCancelledError{Cause: cause}
+	var chatUsage *relay.ChatUsage
+	estimated := delivered != ""
+	if estimated {
+		cancelled.PromptTokens = usage.PromptTokens
+		if cancelled.PromptTokens == 0 {
+			cancelled.PromptTokens = countPromptTokens(req)
+		}
+		cancelled.DeliveredTokens = countTextTokens(req.Model, delivered)
+		chatUsage = &relay.ChatUsage{
+			PromptTokens:     cancelled.PromptTokens,
+			CompletionTokens: cancelled.DeliveredTokens,
+			TotalTokens:      cancelled.PromptTokens + cancelled.DeliveredTokens,
+		}
+	}
+	attempt := rc.EndAttempt(start, chatUsage, cancelled, func() string { return delivered })
+	attempt.UsageEstimated = estimated
+	return relay.NoFailover(cancelled)
+}
+
+// drainStream 丢弃剩余数据块，让解析协程可以退出
+func drainStream(ch <-chan *adapter.StreamChunk) {
+	for range ch {
+	}
+}
+
+// countTextTokens 统计文本的 Token 数，BPE 词表不可用时退回字符估算
+func countTextTokens(modelName, text string) int {
+	return tokenizer.CountText(modelName, text)
+}
+
+// countPromptTokens 统计请求消息的输入 Token 数（含消息格式开销）
+func countPromptTokens(req *relay.ChatCompletionRequest) int {
+	return tokenizer.CountMessages(req.Model, tokenizerMessages(req.Messages))
+}
+
+// tokenizerMessages 转换为计数器使用的消息格式
+func tokenizerMessages(messages []relay.ChatMessage) []tokenizer.Message {
+	msgs := make([]tokenizer.Message, 0, len(messages))
+	for i := range messages {
+		msgs = append(msgs, tokenizer.Message{Role: messages[i].Role, Content: messages[i].OpenAIContent()})
+	}
+	return msgs
+}
+
+// RelayEmbeddings 中转 Embedding 请求，支持批量输入，上游失败时按负载均衡配置切换渠道重试
+func (s *RelayService) RelayEmbeddings(ctx context.Context, req *relay.EmbeddingRequest) (*relay.EmbeddingResponse, error) {
+	ctx, rc := s.beginRelay(ctx, relay.EndpointEmbeddings, req, req.Model, false, nil)
+
+	var resp *relay.EmbeddingResponse
+	err := s.withFailover(ctx, rc, func(ctx context.Context, channel *model.Channel) error {
+		r, err := s.embeddings(ctx, rc, channel, req)
+		if err != nil {
+			return err
+		}
+		resp = r
+		return nil
+	})
+	s.finish(ctx, rc, err, func(*relay.RelayAttempt) func() *logtail.Content { return embeddingTailContent(req) })
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// embeddings 通过 EmbeddingHandler 在指定渠道上执行一次 Embedding 请求
+func (s *RelayService) embeddings(ctx context.Context, rc *relay.RelayContext, channel *model.Channel, req *relay.EmbeddingRequest) (*relay.EmbeddingResponse, error) {
+	start := time.Now()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
+	}
+
+	hresp, err := relay.NewEmbeddingHandler(singleChannelClient(ctx, channel, 30*time.Second)).Handle(upstreamContext(ctx, rc), &relay.HandlerRequest{
+		Type:     relay.RequestTypeEmbedding,
+		ID:       rc.RequestID,
+		Model:    req.Model,
+		Endpoint: "/embeddings",
+		Headers:  handlerHeaders(rc, channel),
+		Body:     body,
+	})
+	recordHandlerRequestID(rc, hresp)
+	if err != nil {
+		err = s.handlerError(rc, hresp, err)
+		rc.EndAttempt(start, nil, err, nil)
+		return nil, err
+	}
+
+	rc.EnterPhase(relay.PhaseGeneration)
+	var resp relay.EmbeddingResponse
+	if err := json.Unmarshal(hresp.Body, &resp); err != nil {
+		err = fmt.Errorf("failed to parse embedding response: %w", err)
+		rc.EndAttempt(start, nil, err, nil)
+		return nil, err
+	}
+	if len(resp.Data) != len(req.Input) {
+		err := &relay.UpstreamError{
+			StatusCode:        http.StatusBadGateway,
+			Message:           fmt.Sprintf("upstream returned %d embeddings for %d inputs", len(resp.Data), len(req.Input)),
+			RequestID:         rc.RequestID,
+			ProviderRequestID: rc.UpstreamRequestID,
+		}
+		rc.EndAttempt(start, nil, err, nil)
+		return nil, err
+	}
+	resp.Object = "list"
+	if resp.Model == "" {
+		resp.Model = req.Model
+	}
+	for i := range resp.Data {
+		if resp.Data[i].Object == "" {
+			resp.Data[i].Object = "embedding"
+		}
+	}
+
+	rc.EndAttempt(start, &relay.ChatUsage{
+		PromptTokens: resp.Usage.PromptTokens,
+		TotalTokens:  resp.Usage.TotalTokens,
+	}, nil, nil)
+	return &resp, nil
+}
+
+// singleChannelClient 只包含选中渠道与本次密钥的请求客户端，不重试：
+// 渠道选择与切换由负载均衡器负责，这里只向选中的渠道发送一次
+func singleChannelClient(ctx context.Context, channel *model.Channel, timeout time.Duration) *relay.RequestClient {
+	ch := relay.NewChannel(strconv.Itoa(channel.ID), channel.Name, channel.BaseURL, channel.Type)
+	ch.Keys = append(ch.Keys, &relay.ChannelKey{APIKey: channelKey(ctx, channel), Enabled: true})
+	client := relay.NewRequestClient(timeout)
+	client.AddChannel(ch)
+	policy := relay.NewRetryPolicy()
+	policy.MaxRetries = 0
+	client.SetRetryPolicy(policy)
+	return client
+}
+
+// handlerHeaders 通过 relay 处理器发送的上游请求头，透传请求 ID
+func handlerHeaders(rc *relay.RelayContext, channel *model.Channel) map[string]string {
+	headers := make(map[string]string)
+	idHeader := channel.GetRequestIDHeader(adapter.DefaultRequestIDHeader(adapter.ParseProviderType(channel.Type)))
+	if idHeader != "" && rc.RequestID != "" {
+		headers[idHeader] = rc.RequestID
+	}
+	return headers
+}
+
+// recordHandlerRequestID 从处理器响应头中记录提供方请求 ID
+func recordHandlerRequestID(rc *relay.RelayContext, hresp *relay.HandlerResponse) {
+	if hresp == nil {
+		return
+	}
+	header := make(http.Header, len(hresp.Headers))
+	for k, v := range hresp.Headers {
+		header.Set(k, v)
+	}
+	rc.UpstreamRequestID = adapter.UpstreamRequestID(nil, &http.Response{Header: header})
+}
+
+// handlerError 将 relay 处理器的错误转换为 UpstreamError，保留上游状态码与错误信息
+func (s *RelayService) handlerError(rc *relay.RelayContext, hresp *relay.HandlerResponse, err error) error {
+	retryErr, ok := err.(*relay.RetryableError)
+	if !ok {
+		return err
+	}
+	if retryErr.StatusCode == 0 {
+		// 网络错误，保留原始错误以便判断是否切换渠道
+		return fmt.Errorf("upstream request failed: %w", retryErr.Err)
+	}
+
+	message := http.StatusText(retryErr.StatusCode)
+	if hresp != nil && len(hresp.Body) > 0 {
+		var body struct {
+			Error *adapter.ErrorInfo `json:"error"`
+		}
+		if json.Unmarshal(hresp.Body, &body) == nil && body.Error != nil && body.Error.Message != "" {
+			message = body.Error.Message
+		}
+	}
+	return &relay.UpstreamError{
+		StatusCode:        retryErr.StatusCode,
+		Message:           message,
+		RequestID:         rc.RequestID,
+		ProviderRequestID: rc.UpstreamRequestID,
+	}
+}
+
+// reserveQuota 上游调用前预留额度，未启用额度预检或调用方已预留（如批量任务）时直接通过
+func (s *RelayService) reserveQuota(ctx context.Context, rc *relay.RelayContext, req *relay.ChatCompletionRequest) error {
+	if s.quotaGuard == nil || rc.ReservationID != "" {
+		return nil
+	}
+	return s.quotaGuard.Reserve(ctx, rc, req)
+}
+
+// reserveUnits 按次计费的请求在上游调用前预留 units 次的费用，未启用额度预检时直接通过
+func (s *RelayService) reserveUnits(ctx context.Context, rc *relay.RelayContext, modelName string, units int) error {
+	if s.quotaGuard == nil {
+		return nil
+	}
+	return s.quotaGuard.ReserveUnits(ctx, rc, modelName, units)
+}
+
+// beginRelay 获取入口创建的中转上下文（内部调用没有时补建）并写入调用方请求与路由输入，
+// 同时把请求 ID 注入上游请求的上下文
+func (s *RelayService) beginRelay(ctx context.Context, endpoint string, request interface{}, modelName string, stream bool, features []string) (context.Context, *relay.RelayContext) {
+	rc := relay.RelayContextFromContext(ctx)
+	if rc == nil {
+		rc = &relay.RelayContext{Endpoint: endpoint, Timings: relay.RelayTimings{StartedAt: time.Now()}}
+		ctx = relay.WithRelayContext(ctx, rc)
+	}
+	rc.Request = request
+	rc.Model = modelName
+	rc.Stream = stream
+	rc.Features = features
+	if rc.RequestID != "" {
+		ctx = adapter.WithRequestID(ctx, rc.RequestID)
+	}
+	return ctx, rc
+}
+
+// upstreamContext 进入上游连接阶段，并在获得连接时进入等待首个 Token 的阶段
+func upstreamContext(ctx context.Context, rc *relay.RelayContext) context.Context {
+	rc.EnterPhase(relay.PhaseConnect)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) { rc.EnterPhase(relay.PhaseFirstToken) },
+	})
+}
+
+// checkUpstream 记录上游请求 ID，并将上游错误转换为 UpstreamError
+func (s *RelayService) checkUpstream(adaptor adapter.Adapter, resp *http.Response, rc *relay.RelayContext) error {
+	rc.UpstreamRequestID = adapter.UpstreamRequestID(adaptor, resp)
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	message := http.StatusText(resp.StatusCode)
+	if err := adaptor.GetError(resp); err != nil {
+		message = err.Error()
+	}
+
+	return &relay.UpstreamError{
+		StatusCode:        resp.StatusCode,
+		Message:           message,
+		RequestID:         rc.RequestID,
+		ProviderRequestID: rc.UpstreamRequestID,
+	}
+}
+
+// finish 请求结束时统一消费中转上下文：每次渠道尝试写入一条统一日志并推送实时跟踪，
+// 结算额度预留（客户端已断开也要结算），然后交给请求结束回调（如计费发布）
+func (s *RelayService) finish(ctx context.Context, rc *relay.RelayContext, relayErr error, content func(a *relay.RelayAttempt) func() *logtail.Content) {
+	rc.Finish(relayErr)
+	for _, a := range rc.Attempts {
+		s.recordLog(ctx, rc, a, content(a))
+	}
+
+	if rc.Usage != nil {
+		metrics.AddRelayTokens(rc.Model, rc.Usage.PromptTokens, rc.Usage.CompletionTokens)
+	}
+
+	// 额度结算与计费发布记录在一个 span 中
+	ctx, span := tracing.Start(context.WithoutCancel(ctx), "billing.enqueue", attribute.String("model", rc.Model))
+	defer span.End()
+	if rc.Usage != nil {
+		span.SetAttributes(attribute.Int("prompt_tokens", rc.Usage.PromptTokens), attribute.Int("completion_tokens", rc.Usage.CompletionTokens),
+			attribute.Bool("usage_estimated", rc.UsageEstimated))
+	}
+	if s.quotaGuard != nil {
+		s.quotaGuard.Settle(ctx, rc)
+		span.SetAttributes(attribute.Int64("cost", rc.Cost))
+	}
+	for _, hook := range s.hooks {
+		hook(ctx, rc)
+	}
+}
+
+// recordLog 写入一次渠道尝试的统一日志，同时记录本系统与上游的请求 ID
+func (s *RelayService) recordLog(ctx context.Context, rc *relay.RelayContext, a *relay.RelayAttempt, content func() *logtail.Content) {
+	entry := &model.UnifiedLog{
+		UserID:            rc.UserID,
+		TokenID:           rc.TokenID,
+		TokenName:         rc.TokenName,
+		ProjectID:         rc.ProjectID,
+		ChannelID:         a.ChannelID,
+		ChannelName:       a.ChannelName,
+		LogType:           model.LogTypeConsume,
+		ModelName:         rc.Model,
+		UseTime:           int(a.Latency.Milliseconds()),
+		IsStream:          rc.Stream,
+		RequestID:         rc.RequestID,
+		UpstreamRequestID: a.UpstreamRequestID,
+		Other:             "{}",
+		CreatedAt:         a.StartedAt.Add(a.Latency),
+	}
+	if a.Usage != nil {
+		entry.PromptTokens = a.Usage.PromptTokens
+		entry.CompletionTokens = a.Usage.CompletionTokens
+		entry.ReasoningTokens = a.Usage.ReasoningTokens()
+	}
+	other := make(map[string]interface{})
+	// 用量为估算值时标记；上游报告了用量时同时记录估算值，便于监控估算偏差
+	if a.UsageEstimated {
+		other["usage_estimated"] = true
+	}
+	if a.Usage != nil && a.Usage.Units > 1 {
+		other["units"] = a.Usage.Units
+	}
+	if a.EstimatedUsage != nil {
+		other["estimated_usage"] = map[string]int{
+			"prompt_tokens":     a.EstimatedUsage.PromptTokens,
+			"completion_tokens": a.EstimatedUsage.CompletionTokens,
+		}
+	}
+	if len(rc.Tags) > 0 {
+		other["tags"] = rc.Tags
+	}
+	if rc.RequestedModel != "" {
+		other["requested_model"] = rc.RequestedModel
+	}
+	// 分阶段耗时属于整个请求，记录在最后一次尝试的日志上
+	if n := len(rc.Attempts); n > 0 && rc.Attempts[n-1] == a {
+		other["latency"] = rc.Latency(time.Now())
+	}
+	relayErr := a.Err
+	entry.Status = model.LogStatusSuccess
+	entry.StatusCode = http.StatusOK
+	var interrupted *relay.StreamInterruptedError
+	var upstreamErr *relay.UpstreamError
+	var cancelled *relay.ClientCancelledError
+	switch {
+	case errors.As(relayErr, &cancelled):
+		// 客户端取消不是失败，按取消前已输出的内容记为消费
+		entry.Content = relayErr.Error()
+		entry.Status = model.LogStatusCancelled
+		entry.StatusCode = statusClientClosedRequest
+		other["outcome"] = "cancelled"
+		other["delivered_tokens"] = cancelled.DeliveredTokens
+	case errors.As(relayErr, &interrupted) && interrupted.Partial:
+		// 已输出部分内容的中断仍按消费记录，便于对账
+		entry.Content = relayErr.Error()
+		entry.Status = model.LogStatusPartial
+		other["outcome"] = "partial"
+		other["error_class"] = interrupted.Class
+		other["delivered_tokens"] = interrupted.DeliveredTokens
+	case relayErr != nil:
+		entry.LogType = model.LogTypeError
+		entry.Content = relayErr.Error()
+		entry.Status = model.LogStatusError
+		entry.StatusCode = 0
+		if errors.As(relayErr, &upstreamErr) {
+			entry.StatusCode = upstreamErr.StatusCode
+		}
+	}
+	if bodies := s.captureBodies(rc, a); bodies != nil {
+		other["bodies"] = bodies
+	}
+	if len(other) > 0 {
+		if data, err := json.Marshal(other); err == nil {
+			entry.Other = string(data)
+		}
+	}
+
+	s.publishTail(entry, relayErr, content)
+	if a.ChannelID != 0 {
+		metrics.ObserveRelayAttempt(strconv.Itoa(a.ChannelID), rc.Model, entry.Status, a.Latency)
+	}
+
+	// 日志写入失败不影响主流程：有异步写入器时交给后台批量写入，否则同步写入且不受已取消的请求上下文影响
+	if s.logWriter != nil {
+		if !s.logWriter.Write(entry) {
+			logger.Ctx(ctx).Warn("relay log buffer full, dropping log")
+		}
+		return
+	}
+	if s.logRepo == nil {
+		return
+	}
+	if err := s.logRepo.Create(context.WithoutCancel(ctx), entry); err != nil {
+		logger.Ctx(ctx).Warn("failed to record relay log", zap.Error(err))
+	}
+}
+
+// captureBodies 渠道开启请求体日志时记录脱敏后的请求头、请求体与响应体，
+// 请求要求不保留内容（no-store）时不记录
+func (s *RelayService) captureBodies(rc *relay.RelayContext, a *relay.RelayAttempt) *relaylog.Bodies {
+	if rc.Policy.NoStore || a.ChannelID == 0 {
+		return nil
+	}
+	channel, err := s.getChannel(strconv.Itoa(a.ChannelID))
+	if err != nil || !channel.GetSettings().LogBodies {
+		return nil
+	}
+
+	var response string
+	switch {
+	case a.Err != nil:
+		response = a.Err.Error()
+	case a.Output != nil:
+		response = a.Output()
+	}
+	maxBytes := relaylog.DefaultMaxBodyBytes
+	if s.logWriter != nil {
+		maxBytes = s.logWriter.MaxBodyBytes()
+	}
+	return relaylog.Capture(rc.Headers, rc.Request, response, maxBytes)
+}
+
+// publishTail 将请求元数据推送给正在实时跟踪该用户的管理员
+func (s *RelayService) publishTail(entry *model.UnifiedLog, relayErr error, content func() *logtail.Content) {
+	if s.tail == nil || entry.UserID == 0 {
+		return
+	}
+
+	s.tail.Publish(entry.UserID, &logtail.Event{
+		Timestamp:        entry.CreatedAt,
+		RequestID:        entry.RequestID,
+		Model:            entry.ModelName,
+		ChannelID:        entry.ChannelID,
+		ChannelName:      entry.ChannelName,
+		Status:           entry.Status,
+		LatencyMs:        entry.UseTime,
+		PromptTokens:     entry.PromptTokens,
+		CompletionTokens: entry.CompletionTokens,
+		ReasoningTokens:  entry.ReasoningTokens,
+		ErrorClass:       relay.ErrorClass(relayErr),
+	}, content)
+}
+
+// chatTailContent 实时跟踪的消息内容，只在管理员显式请求且用户保留策略允许时才会生成
+func chatTailContent(messages []relay.ChatMessage, completion func() string) func() *logtail.Content {
+	return func() *logtail.Content {
+		var sb strings.Builder
+		for _, m := range messages {
+			sb.WriteString(m.Role)
+			sb.WriteString(": ")
+			sb.WriteString(m.Content)
+			sb.WriteString("\n")
+		}
+		c := &logtail.Content{Prompt: sb.String()}
+		if completion != nil {
+			c.Completion = completion()
+		}
+		return c
+	}
+}
+
+// chatAttemptContent 按渠道尝试生成实时跟踪内容，失败的尝试只包含输入消息
+func chatAttemptContent(messages []relay.ChatMessage) func(a *relay.RelayAttempt) func() *logtail.Content {
+	return func(a *relay.RelayAttempt) func() *logtail.Content {
+		return chatTailContent(messages, a.Output)
+	}
+}
+
+// embeddingTailContent Embedding 请求的输入内容
+func embeddingTailContent(req *relay.EmbeddingRequest) func() *logtail.Content {
+	return func() *logtail.Content {
+		return &logtail.Content{Prompt: strings.Join(req.Input, "\n")}
+	}
+}
+
+// responseText 非流式响应的输出文本
+func responseText(resp *adapter.OpenAIResponse) string {
+	var sb strings.Builder
+	for _, choice := range resp.Choices {
+		if text, ok := choice.Message.Content.(string); ok {
+			sb.WriteString(text)
+		}
+	}
+	return sb.String()
+}
+
+// attemptUsage 将适配器用量转换为中转用量，未产生用量时返回 nil
+func attemptUsage(usage *adapter.Usage) *relay.ChatUsage {
+	if usage == nil {
+		return nil
+	}
+	u := convertUsage(usage)
+	return &u
+}
+
 // 辅助函数：类型转换
 func (s *RelayService) convertToAdapterRequest(req *relay.ChatCompletionRequest) *adapter.OpenAIRequest {
 	messages := make([]adapter.Message, len(req.Messages))
 	for i, m := range req.Messages {
 		messages[i] = adapter.Message{
-			Role:    m.Role,
-			Content: m.Content,
+			Role:       m.Role,
+			Content:    m.OpenAIContent(),
+			Name:       m.Name,
+			ToolCalls:  toAdapterToolCalls(m.ToolCalls),
+			ToolCallID: m.ToolCallID,
 		}
 	}
 
 	return &adapter.OpenAIRequest{
-		Model:            req.Model,
-		Messages:         messages,
-		Temperature:      float32(req.Temperature),
-		MaxTokens:        req.MaxTokens,
-		TopP:             float32(req.TopP),
-		FrequencyPenalty: float32(req.FrequencyPenalty),
-		PresencePenalty:  float32(req.PresencePenalty),
-		Stream:           req.Stream,
+		Model:               req.Model,
+		Messages:            messages,
+		Temperature:         float32(req.Temperature),
+		MaxTokens:           req.MaxTokens,
+		MaxCompletionTokens: req.MaxCompletionTokens,
+		TopP:                float32(req.TopP),
+		FrequencyPenalty:    float32(req.FrequencyPenalty),
+		PresencePenalty:     float32(req.PresencePenalty),
+		Stream:              req.Stream,
+		ReasoningEffort:     req.ReasoningEffort,
+		Tools:               toAdapterTools(req.Tools),
+		ToolChoice:          req.ToolChoice,
+		ParallelToolCalls:   req.ParallelToolCalls,
+		ResponseFormat:      responseFormat(req),
+		Extra:               req.ExtraBody,
+	}
+}
+
+// toAdapterTools 将 OpenAI 格式的 tools 转换为适配器类型，无法解析的定义会被丢弃
+func toAdapterTools(tools []map[string]interface{}) []adapter.Tool {
+	if len(tools) == 0 {
+		return nil
 	}
+	result := make([]adapter.Tool, 0, len(tools))
+	for _, t := range tools {
+		data, err := json.Marshal(t)
+		if err != nil {
+			continue
+		}
+		var tool adapter.Tool
+		if err := json.Unmarshal(data, &tool); err != nil || tool.Function.Name == "" {
+			continue
+		}
+		if tool.Type == "" {
+			tool.Type = "function"
+		}
+		result = append(result, tool)
+	}
+	return result
+}
+
+// toAdapterToolCalls 转换助手消息中的工具调用
+func toAdapterToolCalls(calls []relay.ChatToolCall) []adapter.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	result := make([]adapter.ToolCall, len(calls))
+	for i, c := range calls {
+		result[i] = adapter.ToolCall{
+			ID:       c.ID,
+			Type:     c.Type,
+			Function: adapter.ToolCallFunction{Name: c.Function.Name, Arguments: c.Function.Arguments},
+		}
+	}
+	return result
+}
+
+// fromAdapterMessage 转换响应中的消息，content 为 null（如只包含工具调用）时为空字符串
+func fromAdapterMessage(m adapter.Message) relay.ChatMessage {
+	msg := relay.ChatMessage{Role: m.Role, Name: m.Name, ToolCallID: m.ToolCallID}
+	if m.Content != nil {
+		msg.Content = fmt.Sprintf("%v", m.Content) // 简单处理 content
+	}
+	msg.ToolCalls = fromAdapterToolCalls(m.ToolCalls)
+	return msg
+}
+
+// fromAdapterToolCalls 转换响应中的工具调用，流式增量保留调用序号
+func fromAdapterToolCalls(calls []adapter.ToolCall) []relay.ChatToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	result := make([]relay.ChatToolCall, len(calls))
+	for i, c := range calls {
+		result[i] = relay.ChatToolCall{
+			Index:    c.Index,
+			ID:       c.ID,
+			Type:     c.Type,
+			Function: relay.ChatFunctionCall{Name: c.Function.Name, Arguments: c.Function.Arguments},
+		}
+	}
+	return result
 }
 
 func (s *RelayService) convertFromAdapterResponse(resp *adapter.OpenAIResponse) *relay.ChatCompletionResponse {
@@ -146,11 +1516,8 @@ func (s *RelayService) convertFromAdapterResponse(resp *adapter.OpenAIResponse)
 			Delta        *relay.ChatMessage `json:"delta,omitempty"`
 			FinishReason string             `json:"finish_reason"`
 		}{
-			Index: c.Index,
-			Message: relay.ChatMessage{
-				Role:    c.Message.Role,
-				Content: fmt.Sprintf("%v", c.Message.Content), // 简单处理 content
-			},
+			Index:        c.Index,
+			Message:      fromAdapterMessage(c.Message),
 			FinishReason: c.FinishReason,
 		}
 	}
@@ -161,16 +1528,23 @@ func (s *RelayService) convertFromAdapterResponse(resp *adapter.OpenAIResponse)
 		Created: resp.Created,
 		Model:   resp.Model,
 		Choices: choices,
-		Usage: struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-			TotalTokens      int `json:"total_tokens"`
-		}{
-			PromptTokens:     resp.Usage.PromptTokens,
-			CompletionTokens: resp.Usage.CompletionTokens,
-			TotalTokens:      resp.Usage.TotalTokens,
-		},
+		Usage:   convertUsage(&resp.Usage),
+	}
+}
+
+// convertUsage 转换使用量，保留推理 Token 明细
+func convertUsage(usage *adapter.Usage) relay.ChatUsage {
+	u := relay.ChatUsage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+	if usage.CompletionTokensDetails != nil {
+		u.CompletionTokensDetails = &relay.CompletionTokensDetails{
+			ReasoningTokens: usage.CompletionTokensDetails.ReasoningTokens,
+		}
 	}
+	return u
 }
 
 func (s *RelayService) convertFromAdapterStreamChunk(chunk *adapter.StreamChunk) *relay.ChatCompletionResponse {
@@ -184,9 +1558,9 @@ func (s *RelayService) convertFromAdapterStreamChunk(chunk *adapter.StreamChunk)
 	for i, c := range chunk.Choices {
 		var delta *relay.ChatMessage
 		if c.Delta != nil {
-			delta = &relay.ChatMessage{
-				Role:    c.Delta.Role,
-				Content: fmt.Sprintf("%v", c.Delta.Content),
+			delta = &relay.ChatMessage{Role: c.Delta.Role, ToolCalls: fromAdapterToolCalls(c.Delta.ToolCalls)}
+			if c.Delta.Content != nil {
+				delta.Content = fmt.Sprintf("%v", c.Delta.Content)
 			}
 		}
 
@@ -202,18 +1576,48 @@ func (s *RelayService) convertFromAdapterStreamChunk(chunk *adapter.StreamChunk)
 		}
 	}
 
-	return &relay.ChatCompletionResponse{
+	resp := &relay.ChatCompletionResponse{
 		ID:      chunk.ID,
 		Object:  chunk.Object,
 		Created: chunk.Created,
 		Model:   chunk.Model,
 		Choices: choices,
 	}
+	if chunk.Usage != nil {
+		resp.Usage = convertUsage(chunk.Usage)
+	}
+	return resp
+}
+
+// AbilityManager 渠道能力注册表，注册的模型会出现在模型列表中
+func (s *RelayService) AbilityManager() *relay.ChannelAbilityManager {
+	return s.abilities
+}
+
+// ContextWindow 渠道为模型声明的上下文窗口（Token 数），未声明时返回 0
+func (s *RelayService) ContextWindow(model string) int {
+	return s.abilities.ContextWindow(model)
+}
+
+// ListModels 列出已启用且断路器未打开的渠道提供的模型，includeChannels 时附带提供模型的渠道 ID
+func (s *RelayService) ListModels(ctx context.Context, includeChannels bool) (*relay.ModelList, error) {
+	if err := s.ensureChannels(ctx); err != nil {
+		return nil, err
+	}
+
+	s.channelsMu.RLock()
+	channels := make([]*model.Channel, 0, len(s.channels))
+	for _, ch := range s.channels {
+		channels = append(channels, ch)
+	}
+	s.channelsMu.RUnlock()
+
+	return relay.CollectModels(channels, s.abilities, s.loadBalancer.IsChannelAvailable, includeChannels), nil
 }
 
 // GetAvailableChannels 获取所有可用渠道
 func (s *RelayService) GetAvailableChannels(ctx context.Context) ([]*model.Channel, error) {
-	return s.selector.GetAllChannels(ctx)
+	return s.channelRepo.GetAll(ctx)
 }
 
 // StreamChatCompletion 流式 Chat Completion 的别名
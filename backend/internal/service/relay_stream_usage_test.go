@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relaylog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamUsageUpstream 输出三个内容数据块；withUsage 时按 include_usage 在最后返回用量，cutOff 时不发送结束标记直接断开
+func streamUsageUpstream(t *testing.T, withUsage, cutOff bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			StreamOptions *struct {
+				IncludeUsage bool `json:"include_usage"`
+			} `json:"stream_options"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.True(t, body.StreamOptions != nil && body.StreamOptions.IncludeUsage, "OpenAI channels request usage in the stream")
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, text := range []string{"The ", "answer ", "is 42."} {
+			fmt.Fprintf(w, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", text)
+		}
+		if cutOff {
+			return
+		}
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		if withUsage {
+			fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[],\"usage\":{\"prompt_tokens\":30,\"completion_tokens\":7,\"total_tokens\":37}}\n\n")
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}
+}
+
+func TestRelayStreamUsageAccounting(t *testing.T) {
+	req := func() *relay.ChatCompletionRequest {
+		return &relay.ChatCompletionRequest{Model: "gpt-4", Messages: []relay.ChatMessage{{Role: "user", Content: "What is the answer?"}}}
+	}
+	estimatedPrompt := countPromptTokens(req())
+	estimatedCompletion := countTextTokens("gpt-4", "The answer is 42.")
+
+	for _, tc := range []struct {
+		name               string
+		withUsage, cutOff  bool
+		prompt, completion int
+		estimated          bool
+		logged             []string
+	}{
+		// 上游报告的用量优先，估算值只写入日志用于监控偏差
+		{"reported usage", true, false, 30, 7, false, []string{
+			fmt.Sprintf(`"estimated_usage":{"completion_tokens":%d,"prompt_tokens":%d}`, estimatedCompletion, estimatedPrompt)}},
+		{"no usage", false, false, estimatedPrompt, estimatedCompletion, true, []string{`"usage_estimated":true`}},
+		// 中途断开只按已输出的内容计费
+		{"cut off mid-stream", false, true, estimatedPrompt, estimatedCompletion, true, []string{`"usage_estimated":true`, `"outcome":"partial"`}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := newTestRelayService(t, streamUsageUpstream(t, tc.withUsage, tc.cutOff))
+			store := &captureLogStore{}
+			writer := relaylog.NewWriter(store, &relaylog.Config{FlushInterval: time.Hour})
+			writer.Start()
+			s.SetLogWriter(writer)
+
+			rc := newTestRelayContext(t)
+			err := s.RelayChatCompletionStream(relay.WithRelayContext(context.Background(), rc), req(), func(*relay.ChatCompletionResponse) error { return nil })
+			if tc.cutOff {
+				var interrupted *relay.StreamInterruptedError
+				require.ErrorAs(t, err, &interrupted)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.NotNil(t, rc.Usage)
+			assert.Equal(t, tc.prompt, rc.Usage.PromptTokens)
+			assert.Equal(t, tc.completion, rc.Usage.CompletionTokens)
+			assert.Equal(t, tc.prompt+tc.completion, rc.Usage.TotalTokens)
+			assert.Equal(t, tc.estimated, rc.UsageEstimated)
+
+			writer.Stop()
+			require.Len(t, store.logs, 1)
+			entry := store.logs[0]
+			assert.Equal(t, tc.completion, entry.CompletionTokens)
+			for _, want := range tc.logged {
+				assert.Contains(t, entry.Other, want)
+			}
+			if !tc.estimated {
+				assert.NotContains(t, entry.Other, "usage_estimated")
+			}
+		})
+	}
+}
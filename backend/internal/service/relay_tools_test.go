@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestToolsRelayService 按渠道表加载渠道的中转服务，channels 中的渠道共用测试上游
+func newTestToolsRelayService(t *testing.T, upstream http.HandlerFunc, channels ...*model.Channel) *RelayService {
+	t.Helper()
+	s, base := newTestRelayService(t, upstream)
+	s.paramRuleRepo = nil
+	s.fallbackRepo = nil
+	for _, ch := range channels {
+		ch.BaseURL, ch.APIKey, ch.Weight = base.BaseURL, base.APIKey, 1
+		ch.Status, ch.Enabled = model.ChannelStatusEnabled, true
+	}
+	s.channelRepo = &fakeChannelSource{channels: channels}
+	require.NoError(t, s.ReloadChannels(context.Background()))
+	return s
+}
+
+// toolChannel 提供 models 的渠道，disabled 为关闭的 Chat 能力
+func toolChannel(t *testing.T, id int, channelType, models string, disabled ...string) *model.Channel {
+	t.Helper()
+	ch := &model.Channel{ID: id, Name: fmt.Sprintf("channel-%d", id), Type: channelType, SupportModels: models}
+	require.NoError(t, ch.SetSettings(model.ChannelSettings{DisabledFeatures: disabled}))
+	return ch
+}
+
+func toolsRequest(parallel *bool) *relay.ChatCompletionRequest {
+	return &relay.ChatCompletionRequest{
+		Model:             "gpt-4",
+		Messages:          []relay.ChatMessage{{Role: "user", Content: "weather in Oslo and Paris?"}},
+		Tools:             []map[string]interface{}{{"type": "function", "function": map[string]interface{}{"name": "get_weather", "parameters": map[string]interface{}{"type": "object"}}}},
+		ToolChoice:        "auto",
+		ParallelToolCalls: parallel,
+	}
+}
+
+func TestRelayToolsSelectsCapableChannels(t *testing.T) {
+	var parallelSeen int32
+	s := newTestToolsRelayService(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "auto", body["tool_choice"])
+		assert.Len(t, body["tools"], 1)
+		if body["parallel_tool_calls"] == true {
+			atomic.AddInt32(&parallelSeen, 1)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"chatcmpl-1","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}],
+			"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`)
+	},
+		toolChannel(t, 7, "openai", "gpt-4", string(relay.FeatureParallelFunctions)),
+		toolChannel(t, 8, "openai", "gpt-4"),
+		toolChannel(t, 9, "claude", "gpt-4"),
+	)
+
+	relayTools := func(parallel *bool) int {
+		rc := newTestRelayContext(t)
+		_, err := s.RelayChatCompletion(relay.WithRelayContext(context.Background(), rc), toolsRequest(parallel))
+		require.NoError(t, err)
+		return rc.ChannelID
+	}
+
+	parallel := true
+	for i := 0; i < 6; i++ {
+		assert.Equal(t, 8, relayTools(&parallel), "only channel 8 supports parallel tool calls")
+	}
+	assert.EqualValues(t, 6, atomic.LoadInt32(&parallelSeen))
+
+	for i := 0; i < 6; i++ {
+		assert.NotEqual(t, 9, relayTools(nil), "the claude adapter does not forward tools")
+	}
+}
+
+func TestRelayToolsWithoutCapableChannel(t *testing.T) {
+	var hits int32
+	s := newTestToolsRelayService(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	},
+		toolChannel(t, 7, "openai", "gpt-4", string(relay.FeatureFunctionCalling)),
+		toolChannel(t, 9, "claude", "gpt-*"),
+		toolChannel(t, 10, "openai", "gpt-4o"),
+	)
+
+	_, err := s.RelayChatCompletion(relay.WithRelayContext(context.Background(), newTestRelayContext(t)), toolsRequest(nil))
+	var featureErr *relay.FeatureUnsupportedError
+	require.True(t, errors.As(err, &featureErr), "got %v", err)
+	assert.True(t, errors.Is(err, relay.ErrModelNotSupported), "falls back like an unsupported model")
+	assert.Equal(t, "no channel for model gpt-4 supports function_calling (channel 7 lacks function_calling; channel 9 lacks function_calling)", err.Error())
+
+	parallel := true
+	err = s.RelayChatCompletionStream(relay.WithRelayContext(context.Background(), newTestRelayContext(t)), toolsRequest(&parallel),
+		func(chunk *relay.ChatCompletionResponse) error { return nil })
+	assert.Contains(t, err.Error(), "channel 7 lacks function_calling, parallel_functions")
+	assert.Zero(t, atomic.LoadInt32(&hits))
+}
+
+func TestRelayStreamForwardsToolCallDeltas(t *testing.T) {
+	s, _ := newTestRelayService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, data := range []string{
+			`{"id":"c1","choices":[{"index":0,"delta":{"role":"assistant","content":null,"tool_calls":[{"index":0,"id":"call-1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+			`{"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+			`{"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Oslo\"}"}}]}}]}`,
+			`{"id":"c1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":5,"completion_tokens":9,"total_tokens":14}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	var chunks []string
+	var args string
+	err := s.RelayChatCompletionStream(relay.WithRelayContext(context.Background(), newTestRelayContext(t)), toolsRequest(nil),
+		func(chunk *relay.ChatCompletionResponse) error {
+			data, _ := json.Marshal(chunk)
+			chunks = append(chunks, string(data))
+			for _, choice := range chunk.Choices {
+				if choice.Delta != nil {
+					for _, call := range choice.Delta.ToolCalls {
+						require.NotNil(t, call.Index)
+						assert.Equal(t, 0, *call.Index)
+						args += call.Function.Arguments
+					}
+				}
+			}
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, `{"city":"Oslo"}`, args)
+	require.GreaterOrEqual(t, len(chunks), 3)
+	assert.Contains(t, chunks[0], `"id":"call-1"`)
+	assert.NotContains(t, chunks[1], `"id":""`, "continuation deltas carry no call id")
+}
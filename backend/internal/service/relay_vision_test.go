@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func visionRequest(t *testing.T) *relay.ChatCompletionRequest {
+	t.Helper()
+	var req relay.ChatCompletionRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":[
+		{"type":"text","text":"What is this?"},
+		{"type":"image_url","image_url":{"url":"https://example.com/cat.png","detail":"low"}}]}]}`), &req))
+	return &req
+}
+
+func TestRelayVisionSelectsCapableChannels(t *testing.T) {
+	var content interface{}
+	s := newTestToolsRelayService(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		content = body["messages"].([]interface{})[0].(map[string]interface{})["content"]
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"c1","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"A cat."}}],
+			"usage":{"prompt_tokens":100,"completion_tokens":3,"total_tokens":103}}`)
+	},
+		toolChannel(t, 7, "openai", "gpt-4o", string(relay.FeatureVision)),
+		toolChannel(t, 8, "openai", "gpt-4o"),
+		toolChannel(t, 9, "deepseek", "gpt-4o"),
+	)
+
+	for i := 0; i < 6; i++ {
+		rc := newTestRelayContext(t)
+		_, err := s.RelayChatCompletion(relay.WithRelayContext(context.Background(), rc), visionRequest(t))
+		require.NoError(t, err)
+		assert.Equal(t, 8, rc.ChannelID, "only channel 8 accepts images")
+	}
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"type": "text", "text": "What is this?"},
+		map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "https://example.com/cat.png", "detail": "low"}},
+	}, content, "content parts reach the upstream unchanged")
+
+	// 额度预估按 OpenAI 的 low 细节计入 85 Token
+	req := visionRequest(t)
+	text := *req
+	text.Messages = []relay.ChatMessage{{Role: "user", Content: "What is this?"}}
+	assert.Equal(t, 85, countPromptTokens(req)-countPromptTokens(&text))
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"io"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/chat"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// exportBatchSize 导出会话时每次从数据库读取的消息数
+const exportBatchSize = 500
+
+// ExportSession 将会话主线对话的消息按格式流式写入 w，返回导出的消息数
+//
+// session 需由调用方通过 GetSessionByID 取得（已检查权限）；消息分批读取，不在内存中保留完整导出内容。
+func (s *ChatService) ExportSession(ctx context.Context, session *model.Session, format chat.ExportFormat, w io.Writer) (int, error) {
+	page := 0
+	var batch []*model.Message
+	exhausted := false
+
+	next := func() (*chat.Message, error) {
+		if len(batch) == 0 && !exhausted {
+			page++
+			messages, _, err := s.messageRepo.FindBySessionID(ctx, session.ID, page, exportBatchSize)
+			if err != nil {
+				return nil, err
+			}
+			batch = messages
+			exhausted = len(messages) < exportBatchSize
+		}
+		if len(batch) == 0 {
+			return nil, nil
+		}
+		msg := batch[0]
+		batch = batch[1:]
+		return toExportMessage(msg), nil
+	}
+
+	return s.exports.StreamMessages(w, format, next)
+}
+
+// toExportMessage 转换为导出使用的消息格式
+func toExportMessage(msg *model.Message) *chat.Message {
+	return &chat.Message{
+		ID:        msg.ID.String(),
+		Role:      msg.Role,
+		Content:   msg.Content,
+		Tokens:    int64(msg.TotalTokens),
+		Timestamp: msg.CreatedAt,
+	}
+}
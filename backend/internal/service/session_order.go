@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// MaxSessionReorderItems 一次批量排序最多更新的会话数
+const MaxSessionReorderItems = 200
+
+var (
+	ErrEmptySessionOrder    = utils.NewAppError(utils.CodeInvalidRequest, "at least one session is required")
+	ErrTooManySessionOrders = utils.NewAppError(utils.CodeInvalidRequest, "at most 200 sessions can be reordered per request")
+	ErrSessionNotOwned      = utils.NewAppError(utils.CodeNotFound, "session not found or permission denied")
+)
+
+// PatchSessionRequest 部分更新会话，未提供的字段保持不变
+type PatchSessionRequest struct {
+	Pinned    *bool `json:"pinned"`
+	SortOrder *int  `json:"sort_order"`
+}
+
+// SessionOrder 批量排序中的一项
+type SessionOrder struct {
+	ID        uuid.UUID `json:"id" binding:"required"`
+	SortOrder int       `json:"sort_order"`
+}
+
+// PatchSession 置顶 / 取消置顶会话或调整其排序
+func (s *ChatService) PatchSession(ctx context.Context, userID int, sessionID uuid.UUID, req *PatchSessionRequest) (*model.Session, error) {
+	session, err := s.GetSessionByID(ctx, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]interface{})
+	if req.Pinned != nil {
+		session.Pinned = *req.Pinned
+		columns["pinned"] = session.Pinned
+	}
+	if req.SortOrder != nil {
+		session.SortOrder = *req.SortOrder
+		columns["sort_order"] = session.SortOrder
+	}
+	if len(columns) == 0 {
+		return session, nil
+	}
+
+	if err := s.sessionRepo.UpdateColumns(ctx, sessionID, columns); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ReorderSessions 批量更新会话排序，所有会话都必须属于该用户，否则不做任何修改
+func (s *ChatService) ReorderSessions(ctx context.Context, userID int, items []SessionOrder) error {
+	if len(items) == 0 {
+		return ErrEmptySessionOrder
+	}
+	if len(items) > MaxSessionReorderItems {
+		return ErrTooManySessionOrders
+	}
+
+	orders := make(map[uuid.UUID]int, len(items))
+	for _, item := range items {
+		orders[item.ID] = item.SortOrder
+	}
+
+	owned, err := s.sessionRepo.UpdateSortOrders(ctx, userID, orders)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return ErrSessionNotOwned
+	}
+	return nil
+}
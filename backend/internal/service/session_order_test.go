@@ -0,0 +1,21 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReorderSessionsValidatesItemCount(t *testing.T) {
+	s := &ChatService{}
+
+	assert.ErrorIs(t, s.ReorderSessions(context.Background(), 1, nil), ErrEmptySessionOrder)
+
+	items := make([]SessionOrder, MaxSessionReorderItems+1)
+	for i := range items {
+		items[i] = SessionOrder{ID: uuid.New(), SortOrder: i}
+	}
+	assert.ErrorIs(t, s.ReorderSessions(context.Background(), 1, items), ErrTooManySessionOrders)
+}
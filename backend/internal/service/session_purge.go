@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+// DefaultSessionRetention 已删除会话的默认保留期
+const DefaultSessionRetention = 30 * 24 * time.Hour
+
+// SessionPurger 定期永久删除超过保留期的已删除会话
+type SessionPurger struct {
+	sessionRepo *repository.SessionRepository
+	retention   time.Duration
+	interval    time.Duration
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewSessionPurger 创建清理任务，retention 不大于 0 时使用 DefaultSessionRetention
+func NewSessionPurger(retention, interval time.Duration) *SessionPurger {
+	if retention <= 0 {
+		retention = DefaultSessionRetention
+	}
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &SessionPurger{
+		sessionRepo: repository.NewSessionRepository(),
+		retention:   retention,
+		interval:    interval,
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// Start 启动后台清理
+func (p *SessionPurger) Start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		p.RunOnce(context.Background())
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stopCh:
+				return
+			case <-ticker.C:
+				p.RunOnce(context.Background())
+			}
+		}
+	}()
+}
+
+// Stop 停止后台清理
+func (p *SessionPurger) Stop() {
+	close(p.stopCh)
+	p.wg.Wait()
+}
+
+// RunOnce 永久删除一次超过保留期的会话
+func (p *SessionPurger) RunOnce(ctx context.Context) {
+	purged, err := p.sessionRepo.PurgeDeleted(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		logger.Warn("failed to purge deleted sessions", zap.Error(err))
+		return
+	}
+	if purged > 0 {
+		logger.Info("purged deleted sessions", zap.Int64("count", purged))
+	}
+}
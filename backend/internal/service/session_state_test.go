@@ -0,0 +1,15 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUserSessionsRejectsUnknownState(t *testing.T) {
+	s := &ChatService{}
+
+	_, _, err := s.GetUserSessions(context.Background(), 1, "trashed", 1, 20)
+	assert.ErrorIs(t, err, ErrInvalidSessionState)
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"go.uber.org/zap"
+)
+
+// DefaultSessionTitle 开启自动标题且未指定标题的会话使用的临时标题
+const DefaultSessionTitle = "New chat"
+
+// MaxSessionTitleRunes 自动生成标题的最大字符数
+const MaxSessionTitleRunes = 30
+
+// titleGenerationTimeout 生成标题的最长等待
+const titleGenerationTimeout = 30 * time.Second
+
+// titleExcerptRunes 生成标题时每条消息截取的最大字符数
+const titleExcerptRunes = 1000
+
+const titlePrompt = "Generate a short title (at most 30 characters) for the conversation below. " +
+	"Reply with the title only, in the language of the conversation, without quotes or trailing punctuation."
+
+// chatCompleter 发送非流式对话请求，由 RelayService 实现
+type chatCompleter interface {
+	RelayChatCompletion(ctx context.Context, req *relay.ChatCompletionRequest) (*relay.ChatCompletionResponse, error)
+}
+
+// TitleGenerator 根据第一轮对话生成会话标题
+type TitleGenerator struct {
+	client chatCompleter
+	model  string
+}
+
+// NewTitleGenerator 创建标题生成器，model 为生成标题使用的模型（应选择低价模型）
+func NewTitleGenerator(client chatCompleter, model string) *TitleGenerator {
+	return &TitleGenerator{client: client, model: model}
+}
+
+// Generate 根据第一条用户消息与助手回复生成不超过 MaxSessionTitleRunes 个字符的标题
+func (g *TitleGenerator) Generate(ctx context.Context, question, answer string) (string, error) {
+	conversation := "User: " + truncateRunes(question, titleExcerptRunes) +
+		"\n\nAssistant: " + truncateRunes(answer, titleExcerptRunes)
+
+	resp, err := g.client.RelayChatCompletion(ctx, &relay.ChatCompletionRequest{
+		Model: g.model,
+		Messages: []relay.ChatMessage{
+			{Role: "system", Content: titlePrompt},
+			{Role: "user", Content: conversation},
+		},
+		Temperature: 0.3,
+		MaxTokens:   32,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("empty title response")
+	}
+
+	title := cleanTitle(resp.Choices[0].Message.Content)
+	if title == "" {
+		return "", errors.New("empty title response")
+	}
+	return title, nil
+}
+
+// cleanTitle 取第一行，去掉引号、"Title:" 前缀与结尾标点，并截断到 MaxSessionTitleRunes 个字符
+func cleanTitle(raw string) string {
+	title := strings.TrimSpace(raw)
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = title[:i]
+	}
+	if len(title) > 6 && strings.EqualFold(title[:6], "title:") {
+		title = title[6:]
+	}
+	title = strings.Trim(title, " \t\"'`“”‘’「」《》*#")
+	title = strings.TrimRight(title, ".。!！?？,，;；:：")
+	title = strings.TrimSpace(truncateRunes(title, MaxSessionTitleRunes))
+	return title
+}
+
+// truncateRunes 截断到最多 max 个字符
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
+
+// SetTitleGeneration 启用自动标题，model 为生成标题使用的模型，为空时不生成
+func (s *ChatService) SetTitleGeneration(model string) {
+	if model == "" {
+		s.titles = nil
+		return
+	}
+	s.titles = NewTitleGenerator(s.relayService, model)
+}
+
+// generateTitleAsync 第一轮对话完成后在后台生成标题，失败只记录日志，不影响消息流程
+func (s *ChatService) generateTitleAsync(userID int, session *model.Session, question, answer string) {
+	if s.titles == nil || !session.AutoTitle || answer == "" {
+		return
+	}
+	go s.generateTitle(userID, session.ID, question, answer)
+}
+
+// generateTitle 生成并保存标题；用户在生成期间重命名了会话时不覆盖
+func (s *ChatService) generateTitle(userID int, sessionID uuid.UUID, question, answer string) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("session title generation panicked", zap.Any("panic", r))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), titleGenerationTimeout)
+	defer cancel()
+
+	relayCtx, _ := chatRelayContext(ctx)
+	title, err := s.titles.Generate(relayCtx, question, answer)
+	if err != nil {
+		logger.Warn("failed to generate session title",
+			zap.String("session_id", sessionID.String()),
+			zap.Error(err))
+		return
+	}
+
+	session, err := s.GetSessionByID(ctx, sessionID, userID)
+	if err != nil || !session.AutoTitle {
+		return
+	}
+	if _, err := s.UpdateSession(ctx, userID, sessionID, title); err != nil {
+		logger.Warn("failed to save session title",
+			zap.String("session_id", sessionID.String()),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCompleter 记录请求并返回固定回复的对话客户端
+type fakeCompleter struct {
+	reply    string
+	err      error
+	requests []*relay.ChatCompletionRequest
+}
+
+func (f *fakeCompleter) RelayChatCompletion(ctx context.Context, req *relay.ChatCompletionRequest) (*relay.ChatCompletionResponse, error) {
+	f.requests = append(f.requests, req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": f.reply}}},
+	})
+	resp := &relay.ChatCompletionResponse{}
+	err := json.Unmarshal(body, resp)
+	return resp, err
+}
+
+func TestTitleGeneratorGenerate(t *testing.T) {
+	client := &fakeCompleter{reply: "Title: \"Fixing a Go race condition.\"\nExtra line"}
+	gen := NewTitleGenerator(client, "gpt-3.5-turbo")
+
+	title, err := gen.Generate(context.Background(), "How do I fix this data race?", strings.Repeat("Use a mutex. ", 200))
+	require.NoError(t, err)
+	assert.Equal(t, "Fixing a Go race condition", title)
+
+	require.Len(t, client.requests, 1)
+	req := client.requests[0]
+	assert.Equal(t, "gpt-3.5-turbo", req.Model)
+	assert.False(t, req.Stream)
+	require.Len(t, req.Messages, 2)
+	assert.Contains(t, req.Messages[1].Content, "How do I fix this data race?")
+	// 过长的回复被截断后再发送
+	assert.LessOrEqual(t, utf8.RuneCountInString(req.Messages[1].Content), 2*titleExcerptRunes+32)
+}
+
+func TestTitleGeneratorLimitsLength(t *testing.T) {
+	gen := NewTitleGenerator(&fakeCompleter{reply: "《关于如何在分布式系统中实现一致性哈希与虚拟节点的详细讨论以及相关的工程实践》"}, "gpt-3.5-turbo")
+
+	title, err := gen.Generate(context.Background(), "一致性哈希", "……")
+	require.NoError(t, err)
+	assert.Equal(t, MaxSessionTitleRunes, utf8.RuneCountInString(title))
+	assert.True(t, strings.HasPrefix(title, "关于如何"))
+}
+
+func TestTitleGeneratorErrors(t *testing.T) {
+	_, err := NewTitleGenerator(&fakeCompleter{err: errors.New("upstream down")}, "gpt-3.5-turbo").Generate(context.Background(), "hi", "hello")
+	assert.EqualError(t, err, "upstream down")
+
+	_, err = NewTitleGenerator(&fakeCompleter{reply: "  \"\"  "}, "gpt-3.5-turbo").Generate(context.Background(), "hi", "hello")
+	assert.Error(t, err)
+}
+
+func TestGenerateTitleAsyncSkipsWithoutAutoTitle(t *testing.T) {
+	client := &fakeCompleter{reply: "Greeting"}
+	s := &ChatService{titles: NewTitleGenerator(client, "gpt-3.5-turbo")}
+
+	// 未开启自动标题或已重命名的会话不调用模型
+	s.generateTitleAsync(1, &model.Session{AutoTitle: false}, "hi", "hello")
+	// 没有回复内容时不生成
+	s.generateTitleAsync(1, &model.Session{AutoTitle: true}, "hi", "")
+	assert.Empty(t, client.requests)
+
+	// 未启用标题生成
+	s.SetTitleGeneration("")
+	assert.Nil(t, s.titles)
+	s.generateTitleAsync(1, &model.Session{AutoTitle: true}, "hi", "hello")
+	assert.Empty(t, client.requests)
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/sso"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+// SSOService 组织单点登录服务
+type SSOService struct {
+	orgRepo  *repository.OrganizationRepository
+	userRepo *repository.UserRepository
+	manager  *sso.Manager
+	sessions *SessionService
+}
+
+// NewSSOService 创建单点登录服务
+func NewSSOService(sessions *SessionService) *SSOService {
+	s := &SSOService{
+		orgRepo:  repository.NewOrganizationRepository(),
+		userRepo: repository.NewUserRepository(),
+		sessions: sessions,
+	}
+	s.manager = sso.NewManager(&ssoStore{s}, nil)
+	return s
+}
+
+// SSOLoginResponse SSO 登录响应
+type SSOLoginResponse struct {
+	LoginResponse
+	OrgID       int  `json:"org_id"`
+	OrgRole     int  `json:"org_role"`
+	Provisioned bool `json:"provisioned"`
+}
+
+// SSOConfigRequest 组织 SSO 配置请求
+type SSOConfigRequest struct {
+	OrgName          string         `json:"org_name"`
+	IssuerURL        string         `json:"issuer_url" binding:"required,url"`
+	ClientID         string         `json:"client_id" binding:"required"`
+	ClientSecret     string         `json:"client_secret"`
+	RedirectURI      string         `json:"redirect_uri" binding:"required"`
+	Scopes           string         `json:"scopes"`
+	AllowedDomains   []string       `json:"allowed_domains"`
+	GroupsClaim      string         `json:"groups_claim"`
+	GroupRoleMapping map[string]int `json:"group_role_mapping"`
+	DefaultRole      int            `json:"default_role"`
+	Enabled          *bool          `json:"enabled"`
+}
+
+// BeginLogin 发起组织 SSO 登录，返回 IdP 授权地址
+func (s *SSOService) BeginLogin(ctx context.Context, orgSlug string) (string, error) {
+	return s.manager.BeginLogin(ctx, orgSlug)
+}
+
+// CompleteLogin 处理 IdP 回调，创建登录会话并签发登录令牌
+func (s *SSOService) CompleteLogin(ctx context.Context, orgSlug, state, code string, client ClientInfo) (*SSOLoginResponse, error) {
+	result, err := s.manager.CompleteLogin(ctx, orgSlug, state, code)
+	if err != nil {
+		return nil, err
+	}
+
+	user := result.User
+	resp, err := s.sessions.IssueTokens(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user.LastLoginAt = &now
+	user.LastLoginIP = client.IP
+	_ = s.userRepo.Update(ctx, user)
+
+	return &SSOLoginResponse{
+		LoginResponse: *resp,
+		OrgID:         result.Org.ID,
+		OrgRole:       result.OrgRole,
+		Provisioned:   result.Provisioned,
+	}, nil
+}
+
+// GetConfig 获取组织 SSO 配置
+func (s *SSOService) GetConfig(ctx context.Context, orgSlug string) (*model.OrgSSOConfig, error) {
+	org, err := s.orgRepo.FindBySlug(ctx, orgSlug)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, sso.ErrOrgNotFound
+	}
+	return s.orgRepo.FindSSOConfig(ctx, org.ID)
+}
+
+// SaveConfig 创建或更新组织 SSO 配置，组织不存在时一并创建
+func (s *SSOService) SaveConfig(ctx context.Context, orgSlug string, req *SSOConfigRequest) (*model.OrgSSOConfig, error) {
+	if err := sso.ValidateRedirectURI(req.RedirectURI, orgSlug); err != nil {
+		return nil, err
+	}
+
+	org, err := s.orgRepo.FindBySlug(ctx, orgSlug)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		name := req.OrgName
+		if name == "" {
+			name = orgSlug
+		}
+		org = &model.Organization{Slug: orgSlug, Name: name}
+		if err := s.orgRepo.Create(ctx, org); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := s.orgRepo.FindSSOConfig(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		cfg = &model.OrgSSOConfig{OrgID: org.ID, Enabled: true}
+	}
+
+	mapping, err := json.Marshal(req.GroupRoleMapping)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.IssuerURL = strings.TrimRight(req.IssuerURL, "/")
+	cfg.ClientID = req.ClientID
+	// 未提供新密钥时保留原密钥，避免读取配置后回写时被清空
+	if req.ClientSecret != "" {
+		cfg.ClientSecret = req.ClientSecret
+	}
+	cfg.RedirectURI = req.RedirectURI
+	cfg.Scopes = req.Scopes
+	cfg.AllowedDomains = strings.Join(req.AllowedDomains, ",")
+	cfg.GroupsClaim = req.GroupsClaim
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	cfg.GroupRoleMapping = mapping
+	cfg.DefaultRole = req.DefaultRole
+	if cfg.DefaultRole <= 0 {
+		cfg.DefaultRole = 1
+	}
+	if req.Enabled != nil {
+		cfg.Enabled = *req.Enabled
+	}
+
+	if err := s.orgRepo.SaveSSOConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// TestConnection 测试组织 SSO 配置
+func (s *SSOService) TestConnection(ctx context.Context, orgSlug string) (*sso.ConnectionTestResult, error) {
+	cfg, err := s.GetConfig(ctx, orgSlug)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return nil, sso.ErrSSONotConfigured
+	}
+	return s.manager.TestConnection(ctx, orgSlug, cfg), nil
+}
+
+// ssoStore 将仓储适配为 sso.Store
+type ssoStore struct {
+	s *SSOService
+}
+
+func (st *ssoStore) GetOrgBySlug(ctx context.Context, slug string) (*model.Organization, error) {
+	return st.s.orgRepo.FindBySlug(ctx, slug)
+}
+
+func (st *ssoStore) GetSSOConfig(ctx context.Context, orgID int) (*model.OrgSSOConfig, error) {
+	return st.s.orgRepo.FindSSOConfig(ctx, orgID)
+}
+
+func (st *ssoStore) FindIdentity(ctx context.Context, issuer, subject string) (*model.UserIdentity, error) {
+	return st.s.orgRepo.FindIdentity(ctx, issuer, subject)
+}
+
+func (st *ssoStore) CreateIdentity(ctx context.Context, identity *model.UserIdentity) error {
+	return st.s.orgRepo.CreateIdentity(ctx, identity)
+}
+
+func (st *ssoStore) FindUserByID(ctx context.Context, id int) (*model.User, error) {
+	return st.s.userRepo.FindByID(ctx, id)
+}
+
+func (st *ssoStore) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	return st.s.userRepo.FindByEmail(ctx, email)
+}
+
+func (st *ssoStore) UpsertMember(ctx context.Context, member *model.OrganizationMember) error {
+	return st.s.orgRepo.UpsertMember(ctx, member)
+}
+
+func (st *ssoStore) CreateUser(ctx context.Context, user *model.User) error {
+	return provisionUser(ctx, st.s.userRepo, user)
+}
+
+func (st *ssoStore) ActivateUser(ctx context.Context, user *model.User) error {
+	return st.s.userRepo.Update(ctx, user)
+}
+
+// provisionUser 即时开通用户（SSO 与第三方登录）：生成唯一用户名和不可用于密码登录的随机密码
+func provisionUser(ctx context.Context, users *repository.UserRepository, user *model.User) error {
+	username, err := uniqueUsername(ctx, users, user.Username)
+	if err != nil {
+		return err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	hashedPassword, err := utils.HashPassword(hex.EncodeToString(secret))
+	if err != nil {
+		return err
+	}
+
+	user.Username = username
+	user.PasswordHash = hashedPassword
+	user.InviteCode = generateInviteCode()
+	user.Quota = 500000
+	user.TotalQuota = 500000
+
+	return users.Create(ctx, user)
+}
+
+// uniqueUsername 在用户名冲突时追加数字后缀
+func uniqueUsername(ctx context.Context, users *repository.UserRepository, base string) (string, error) {
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	candidate := base
+	for i := 1; i <= 100; i++ {
+		existing, err := users.FindByUsername(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+	return "", errors.New("unable to allocate a unique username")
+}
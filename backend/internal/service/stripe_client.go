@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultStripeAPIBase Stripe API 地址
+	DefaultStripeAPIBase = "https://api.stripe.com"
+	// stripeSignatureTolerance Webhook 签名时间戳允许的最大偏差，超出视为重放
+	stripeSignatureTolerance = 5 * time.Minute
+)
+
+// ErrInvalidStripeSignature Webhook 签名缺失、不匹配或已过期
+var ErrInvalidStripeSignature = errors.New("invalid stripe signature")
+
+// StripeError Stripe 返回的错误
+type StripeError struct {
+	StatusCode  int
+	Type        string // card_error、invalid_request_error 等
+	Code        string // card_declined、authentication_required 等
+	DeclineCode string
+	Message     string
+}
+
+func (e *StripeError) Error() string {
+	return fmt.Sprintf("stripe returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// IsCardError 卡被拒绝或需要持卡人验证，重试不会成功
+func (e *StripeError) IsCardError() bool {
+	return e.Type == "card_error" || e.StatusCode == http.StatusPaymentRequired
+}
+
+// StripeClient 通过 HTTP API 调用 Stripe 并校验 Webhook 签名
+type StripeClient struct {
+	secretKey     string
+	webhookSecret string
+	baseURL       string
+	httpClient    *http.Client
+	now           func() time.Time
+}
+
+// NewStripeClient 创建 Stripe 客户端，baseURL 为空时使用 DefaultStripeAPIBase
+func NewStripeClient(secretKey, webhookSecret, baseURL string) *StripeClient {
+	if baseURL == "" {
+		baseURL = DefaultStripeAPIBase
+	}
+	return &StripeClient{
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		httpClient:    &http.Client{Timeout: 15 * time.Second},
+		now:           time.Now,
+	}
+}
+
+// StripeCheckoutParams 创建 Checkout Session 的参数
+type StripeCheckoutParams struct {
+	ClientReferenceID string
+	ProductName       string
+	AmountCents       int64
+	Currency          string
+	SuccessURL        string
+	CancelURL         string
+	Metadata          map[string]string
+}
+
+// StripeCheckoutSession Checkout Session 中用到的字段
+type StripeCheckoutSession struct {
+	ID                string            `json:"id"`
+	URL               string            `json:"url"`
+	Status            string            `json:"status"`
+	PaymentStatus     string            `json:"payment_status"`
+	PaymentIntent     string            `json:"payment_intent"`
+	AmountTotal       int64             `json:"amount_total"`
+	Currency          string            `json:"currency"`
+	ClientReferenceID string            `json:"client_reference_id"`
+	Mode              string            `json:"mode"`         // payment 或 setup
+	Customer          string            `json:"customer"`     // setup 模式下保存支付方式的客户
+	SetupIntent       string            `json:"setup_intent"` // setup 模式下的 SetupIntent ID
+	Metadata          map[string]string `json:"metadata"`
+}
+
+// StripeEvent Webhook 事件
+type StripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// CreateCheckoutSession 创建一次性支付的 Checkout Session
+func (c *StripeClient) CreateCheckoutSession(ctx context.Context, params StripeCheckoutParams) (*StripeCheckoutSession, error) {
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("success_url", params.SuccessURL)
+	form.Set("cancel_url", params.CancelURL)
+	form.Set("client_reference_id", params.ClientReferenceID)
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", params.Currency)
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(params.AmountCents, 10))
+	form.Set("line_items[0][price_data][product_data][name]", params.ProductName)
+	form.Set("payment_intent_data[metadata][client_reference_id]", params.ClientReferenceID)
+	for k, v := range params.Metadata {
+		form.Set("metadata["+k+"]", v)
+	}
+
+	var session StripeCheckoutSession
+	if err := c.do(ctx, http.MethodPost, "/v1/checkout/sessions", strings.NewReader(form.Encode()), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// CreateCustomer 创建客户，用于保存支付方式
+func (c *StripeClient) CreateCustomer(ctx context.Context, email string, metadata map[string]string) (string, error) {
+	form := url.Values{}
+	if email != "" {
+		form.Set("email", email)
+	}
+	for k, v := range metadata {
+		form.Set("metadata["+k+"]", v)
+	}
+
+	var customer struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/v1/customers", strings.NewReader(form.Encode()), &customer); err != nil {
+		return "", err
+	}
+	return customer.ID, nil
+}
+
+// CreateSetupSession 创建保存支付方式的 Checkout Session（setup 模式），供之后离线扣款
+func (c *StripeClient) CreateSetupSession(ctx context.Context, customerID, currency, clientReferenceID, successURL, cancelURL string) (*StripeCheckoutSession, error) {
+	form := url.Values{}
+	form.Set("mode", "setup")
+	form.Set("customer", customerID)
+	form.Set("currency", currency)
+	form.Set("client_reference_id", clientReferenceID)
+	form.Set("success_url", successURL)
+	form.Set("cancel_url", cancelURL)
+	form.Set("setup_intent_data[metadata][client_reference_id]", clientReferenceID)
+
+	var session StripeCheckoutSession
+	if err := c.do(ctx, http.MethodPost, "/v1/checkout/sessions", strings.NewReader(form.Encode()), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// SetupIntentPaymentMethod 查询 SetupIntent 保存的支付方式
+func (c *StripeClient) SetupIntentPaymentMethod(ctx context.Context, setupIntentID string) (string, error) {
+	var intent struct {
+		Status        string `json:"status"`
+		PaymentMethod string `json:"payment_method"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/setup_intents/"+url.PathEscape(setupIntentID), nil, &intent); err != nil {
+		return "", err
+	}
+	if intent.Status != "succeeded" || intent.PaymentMethod == "" {
+		return "", fmt.Errorf("setup intent %s is %s", setupIntentID, intent.Status)
+	}
+	return intent.PaymentMethod, nil
+}
+
+// StripePaymentIntent PaymentIntent 中用到的字段
+type StripePaymentIntent struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// CreateOffSessionPaymentIntent 使用保存的支付方式立即离线扣款，相同的幂等键只会扣款一次
+func (c *StripeClient) CreateOffSessionPaymentIntent(ctx context.Context, customerID, paymentMethodID string, amountCents int64, currency, description, idempotencyKey string, metadata map[string]string) (*StripePaymentIntent, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(amountCents, 10))
+	form.Set("currency", currency)
+	form.Set("customer", customerID)
+	form.Set("payment_method", paymentMethodID)
+	form.Set("off_session", "true")
+	form.Set("confirm", "true")
+	if description != "" {
+		form.Set("description", description)
+	}
+	for k, v := range metadata {
+		form.Set("metadata["+k+"]", v)
+	}
+
+	var intent StripePaymentIntent
+	if err := c.doIdempotent(ctx, http.MethodPost, "/v1/payment_intents", strings.NewReader(form.Encode()), idempotencyKey, &intent); err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+// RefundPaymentIntent 全额退款
+func (c *StripeClient) RefundPaymentIntent(ctx context.Context, paymentIntentID, idempotencyKey string) error {
+	form := url.Values{}
+	form.Set("payment_intent", paymentIntentID)
+
+	var refund struct {
+		ID string `json:"id"`
+	}
+	return c.doIdempotent(ctx, http.MethodPost, "/v1/refunds", strings.NewReader(form.Encode()), idempotencyKey, &refund)
+}
+
+// ReceiptURL 查询支付对应的收据地址
+func (c *StripeClient) ReceiptURL(ctx context.Context, paymentIntentID string) (string, error) {
+	var intent struct {
+		LatestCharge struct {
+			ReceiptURL string `json:"receipt_url"`
+		} `json:"latest_charge"`
+	}
+	path := "/v1/payment_intents/" + url.PathEscape(paymentIntentID) + "?expand[]=latest_charge"
+	if err := c.do(ctx, http.MethodGet, path, nil, &intent); err != nil {
+		return "", err
+	}
+	return intent.LatestCharge.ReceiptURL, nil
+}
+
+// ConstructEvent 校验 Stripe-Signature 后解析事件：v1 签名为 HMAC-SHA256(secret, "t.payload")
+func (c *StripeClient) ConstructEvent(payload []byte, header string) (*StripeEvent, error) {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return nil, ErrInvalidStripeSignature
+	}
+	if age := c.now().Sub(time.Unix(ts, 0)); age > stripeSignatureTolerance || age < -stripeSignatureTolerance {
+		return nil, fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidStripeSignature)
+	}
+
+	expected := StripeSignature(c.webhookSecret, timestamp, payload)
+	valid := false
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, ErrInvalidStripeSignature
+	}
+
+	var event StripeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("decode stripe event: %w", err)
+	}
+	return &event, nil
+}
+
+// StripeSignature 计算 Webhook 的 v1 签名
+func StripeSignature(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// do 发送请求并解析响应，非 2xx 时返回 *StripeError
+func (c *StripeClient) do(ctx context.Context, method, path string, body io.Reader, out interface{}) error {
+	return c.doIdempotent(ctx, method, path, body, "", out)
+}
+
+// doIdempotent 同 do，idempotencyKey 不为空时附带 Idempotency-Key，Stripe 对相同的键返回首次请求的结果
+func (c *StripeClient) doIdempotent(ctx context.Context, method, path string, body io.Reader, idempotencyKey string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.secretKey, "")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("stripe request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read stripe response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Type        string `json:"type"`
+				Code        string `json:"code"`
+				DeclineCode string `json:"decline_code"`
+				Message     string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(data, &apiErr)
+		return &StripeError{
+			StatusCode:  resp.StatusCode,
+			Type:        apiErr.Error.Type,
+			Code:        apiErr.Error.Code,
+			DeclineCode: apiErr.Error.DeclineCode,
+			Message:     apiErr.Error.Message,
+		}
+	}
+	return json.Unmarshal(data, out)
+}
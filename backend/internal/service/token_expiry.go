@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/billing"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"go.uber.org/zap"
+)
+
+// TokenExpiryNotifier 发送 Token 即将过期通知
+type TokenExpiryNotifier interface {
+	NotifyTokenExpiring(ctx context.Context, token *model.Token) error
+}
+
+// alertTokenExpiryNotifier 通过预警管理器按用户的通知偏好（Webhook/邮件）发送
+type alertTokenExpiryNotifier struct {
+	alerts *billing.AlertManager
+}
+
+// NewAlertTokenExpiryNotifier 创建基于预警通知渠道的 Token 过期通知
+func NewAlertTokenExpiryNotifier(alerts *billing.AlertManager) TokenExpiryNotifier {
+	return &alertTokenExpiryNotifier{alerts: alerts}
+}
+
+// NotifyTokenExpiring 以 warning 等级发送，每个 Token 使用独立的预警类型，避免被同一用户的冷却期合并
+func (n *alertTokenExpiryNotifier) NotifyTokenExpiring(ctx context.Context, token *model.Token) error {
+	message := fmt.Sprintf("API Token %q (#%d) will expire at %s", token.Name, token.ID, token.ExpireAt.Time.Format(time.RFC3339))
+	n.alerts.RaiseAlert(strconv.Itoa(token.UserID), fmt.Sprintf("token_expiring:%d", token.ID), billing.AlertLevelWarning, message)
+	return nil
+}
+
+// TokenExpiryStatus 过期检查的运行状态
+type TokenExpiryStatus struct {
+	Running       bool       `json:"running"`
+	Interval      string     `json:"interval"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastExpired   int        `json:"last_expired"`  // 最近一次标记为过期的数量
+	LastNotified  int        `json:"last_notified"` // 最近一次发送即将过期通知的数量
+	LastPruned    int64      `json:"last_pruned"`   // 最近一次删除的长期未使用登录会话数量
+	Runs          int64      `json:"runs"`
+	TotalExpired  int64      `json:"total_expired"`
+	TotalNotified int64      `json:"total_notified"`
+	TotalPruned   int64      `json:"total_pruned"`
+}
+
+// TokenExpirySweeper 定期将过期的 Token 标记为过期，并为即将过期的 Token 发送一次通知
+type TokenExpirySweeper struct {
+	tokens   *TokenService
+	notifier TokenExpiryNotifier
+	interval time.Duration
+	now      func() time.Time
+
+	sessions    *SessionService
+	sessionIdle time.Duration
+
+	mu     sync.Mutex
+	status TokenExpiryStatus
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewTokenExpirySweeper 创建过期检查任务，notifier 为空时只标记过期不发送通知
+func NewTokenExpirySweeper(tokens *TokenService, notifier TokenExpiryNotifier, interval time.Duration) *TokenExpirySweeper {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	return &TokenExpirySweeper{
+		tokens:   tokens,
+		notifier: notifier,
+		interval: interval,
+		now:      time.Now,
+		status:   TokenExpiryStatus{Interval: interval.String()},
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// SetSessionPruning 每次检查时一并删除超过 idle 未使用的登录会话，idle 不大于 0 时不清理
+func (s *TokenExpirySweeper) SetSessionPruning(sessions *SessionService, idle time.Duration) {
+	s.sessions = sessions
+	s.sessionIdle = idle
+}
+
+// Start 启动定时检查，启动时立即执行一次
+func (s *TokenExpirySweeper) Start() {
+	s.mu.Lock()
+	s.status.Running = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), s.interval)
+			if err := s.RunOnce(ctx); err != nil {
+				logger.Error("token expiry sweep failed", zap.Error(err))
+			}
+			cancel()
+
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop 停止定时检查，等待进行中的检查结束
+func (s *TokenExpirySweeper) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	s.status.Running = false
+	s.mu.Unlock()
+}
+
+// Status 返回最近一次检查的时间与数量
+func (s *TokenExpirySweeper) Status() TokenExpiryStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	if status.LastRunAt != nil {
+		lastRunAt := *status.LastRunAt
+		status.LastRunAt = &lastRunAt
+	}
+	return status
+}
+
+// RunOnce 执行一次检查：标记已过期的 Token，为即将过期且尚未通知的 Token 发送通知，并清理长期未使用的登录会话
+func (s *TokenExpirySweeper) RunOnce(ctx context.Context) error {
+	now := s.now()
+	expired, notified, err := s.sweep(ctx, now)
+	pruned, pruneErr := s.pruneSessions(ctx)
+	if err == nil {
+		err = pruneErr
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.LastRunAt = &now
+	s.status.LastExpired = expired
+	s.status.LastNotified = notified
+	s.status.LastPruned = pruned
+	s.status.Runs++
+	s.status.TotalExpired += int64(expired)
+	s.status.TotalNotified += int64(notified)
+	s.status.TotalPruned += pruned
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	return err
+}
+
+// sweep 返回本次标记为过期与发送通知的数量
+func (s *TokenExpirySweeper) sweep(ctx context.Context, now time.Time) (int, int, error) {
+	expired, err := s.tokens.CheckAndUpdateExpiredTokens(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	if s.notifier == nil {
+		return expired, 0, nil
+	}
+
+	tokens, err := s.tokens.tokenRepo.ListExpiringTokens(ctx, now, now.Add(model.TokenExpiringSoonWindow))
+	if err != nil {
+		return expired, 0, err
+	}
+
+	notified := 0
+	for _, token := range tokens {
+		if !token.IsExpiringSoonAt(now) {
+			continue
+		}
+		// 先标记再通知：多实例同时运行或通知失败时宁可少发，也不重复发送
+		claimed, err := s.tokens.tokenRepo.MarkExpiryNotified(ctx, token.ID, now)
+		if err != nil {
+			return expired, notified, err
+		}
+		if !claimed {
+			continue
+		}
+		if err := s.notifier.NotifyTokenExpiring(ctx, token); err != nil {
+			logger.Warn("failed to send token expiry notification", zap.Int("token_id", token.ID), zap.Error(err))
+			continue
+		}
+		notified++
+	}
+	return expired, notified, nil
+}
+
+// pruneSessions 删除长期未使用的登录会话，未配置时跳过
+func (s *TokenExpirySweeper) pruneSessions(ctx context.Context) (int64, error) {
+	if s.sessions == nil || s.sessionIdle <= 0 {
+		return 0, nil
+	}
+	return s.sessions.PruneIdle(ctx, s.sessionIdle)
+}
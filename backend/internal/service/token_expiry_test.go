@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expiryClock 可手动推进的时钟
+type expiryClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *expiryClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *expiryClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// expiryTokens 按时钟判断过期的内存 Token 仓储
+type expiryTokens struct {
+	repository.TokenRepository
+	clock  *expiryClock
+	tokens map[int]*model.Token
+}
+
+func (r *expiryTokens) CheckAndUpdateExpiredTokens(ctx context.Context) (int, error) {
+	count := 0
+	for _, token := range r.tokens {
+		if token.Status == model.TokenStatusNormal && token.ExpireAt.Valid && !token.ExpireAt.Time.After(r.clock.Now()) {
+			token.Status = model.TokenStatusExpired
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *expiryTokens) ListExpiringTokens(ctx context.Context, from, to time.Time) ([]*model.Token, error) {
+	var result []*model.Token
+	for _, token := range r.tokens {
+		if token.Status == model.TokenStatusNormal && !token.ExpiryNotifiedAt.Valid &&
+			token.ExpireAt.Time.After(from) && !token.ExpireAt.Time.After(to) {
+			copied := *token
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+func (r *expiryTokens) MarkExpiryNotified(ctx context.Context, id int, at time.Time) (bool, error) {
+	token := r.tokens[id]
+	if token.ExpiryNotifiedAt.Valid {
+		return false, nil
+	}
+	token.ExpiryNotifiedAt = sql.NullTime{Time: at, Valid: true}
+	return true, nil
+}
+
+// recordingExpiryNotifier 记录收到通知的 Token
+type recordingExpiryNotifier struct {
+	notified []int
+}
+
+func (n *recordingExpiryNotifier) NotifyTokenExpiring(ctx context.Context, token *model.Token) error {
+	n.notified = append(n.notified, token.ID)
+	return nil
+}
+
+func TestTokenExpirySweeper(t *testing.T) {
+	ctx := context.Background()
+	clock := &expiryClock{t: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)}
+	expireIn := func(d time.Duration) sql.NullTime {
+		return sql.NullTime{Time: clock.Now().Add(d), Valid: true}
+	}
+	repo := &expiryTokens{clock: clock, tokens: map[int]*model.Token{
+		1: {ID: 1, UserID: 7, Name: "soon", Status: model.TokenStatusNormal, ExpireAt: expireIn(3 * 24 * time.Hour)},
+		2: {ID: 2, UserID: 7, Name: "later", Status: model.TokenStatusNormal, ExpireAt: expireIn(10 * 24 * time.Hour)},
+		3: {ID: 3, UserID: 8, Name: "stale", Status: model.TokenStatusNormal, ExpireAt: expireIn(-time.Hour)},
+		4: {ID: 4, UserID: 8, Name: "forever", Status: model.TokenStatusNormal},
+	}}
+	notifier := &recordingExpiryNotifier{}
+	sweeper := NewTokenExpirySweeper(NewTokenService(repo), notifier, time.Minute)
+	sweeper.now = clock.Now
+
+	// 第一次：已过期的被标记，7 天内过期的收到通知
+	require.NoError(t, sweeper.RunOnce(ctx))
+	assert.Equal(t, model.TokenStatusExpired, repo.tokens[3].Status)
+	assert.Equal(t, []int{1}, notifier.notified)
+	status := sweeper.Status()
+	assert.Equal(t, 1, status.LastExpired)
+	assert.Equal(t, 1, status.LastNotified)
+	assert.Equal(t, clock.Now(), *status.LastRunAt)
+
+	// 再次运行不会重复通知
+	clock.Advance(time.Hour)
+	require.NoError(t, sweeper.RunOnce(ctx))
+	assert.Equal(t, []int{1}, notifier.notified)
+	assert.Equal(t, 0, sweeper.Status().LastNotified)
+
+	// 4 天后：token 1 过期，token 2 进入 7 天窗口
+	clock.Advance(4 * 24 * time.Hour)
+	require.NoError(t, sweeper.RunOnce(ctx))
+	assert.Equal(t, model.TokenStatusExpired, repo.tokens[1].Status)
+	assert.Equal(t, model.TokenStatusNormal, repo.tokens[2].Status)
+	assert.Equal(t, []int{1, 2}, notifier.notified)
+
+	// 永不过期的 Token 不受影响
+	clock.Advance(30 * 24 * time.Hour)
+	require.NoError(t, sweeper.RunOnce(ctx))
+	assert.Equal(t, model.TokenStatusExpired, repo.tokens[2].Status)
+	assert.Equal(t, model.TokenStatusNormal, repo.tokens[4].Status)
+	assert.Equal(t, []int{1, 2}, notifier.notified)
+
+	status = sweeper.Status()
+	assert.Equal(t, int64(4), status.Runs)
+	assert.Equal(t, int64(3), status.TotalExpired)
+	assert.Equal(t, int64(2), status.TotalNotified)
+	assert.Empty(t, status.LastError)
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+// Token 管理错误
+var (
+	ErrTokenNotFound       = errors.New("token not found")
+	ErrInvalidTokenRequest = errors.New("invalid token request")
+)
+
+const (
+	// defaultTokenExpireDays 创建 Token 时未指定有效期的默认天数
+	defaultTokenExpireDays = 365
+	// DefaultTokenRotationGrace 轮换后旧 Key 的默认宽限期
+	DefaultTokenRotationGrace = 15 * time.Minute
+	// maxTokenRotationGrace 旧 Key 宽限期上限
+	maxTokenRotationGrace = 24 * time.Hour
+)
+
+// CreateTokenRequest 创建 API Token 的请求结构
+type CreateTokenRequest struct {
+	Name           string   `json:"name" binding:"required"`
+	Description    string   `json:"description"`
+	QuotaLimit     int64    `json:"quota_limit"` // 0 表示不限
+	ExpireDays     int      `json:"expire_days"`
+	IPWhitelist    []string `json:"ip_whitelist"`
+	ModelWhitelist []string `json:"model_whitelist"`
+}
+
+// UpdateTokenRequest 更新 API Token 的请求结构，未提供的字段保持不变
+type UpdateTokenRequest struct {
+	Name           *string   `json:"name"`
+	Description    *string   `json:"description"`
+	QuotaLimit     *int64    `json:"quota_limit"` // 0 表示不限
+	IPWhitelist    *[]string `json:"ip_whitelist"`
+	ModelWhitelist *[]string `json:"model_whitelist"`
+}
+
+// UserToken 用户 API Token 信息，Key 仅在创建与轮换时完整返回
+type UserToken struct {
+	ID             int        `json:"id"`
+	ProjectID      int        `json:"project_id,omitempty"`
+	Name           string     `json:"name"`
+	Description    string     `json:"description"`
+	Key            string     `json:"key"`
+	Status         string     `json:"status"`
+	QuotaLimit     int64      `json:"quota_limit"` // -1 表示不限
+	QuotaUsed      int64      `json:"quota_used"`
+	IPWhitelist    []string   `json:"ip_whitelist"`
+	ModelWhitelist []string   `json:"model_whitelist"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpireAt       *time.Time `json:"expire_at,omitempty"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	// PreviousKeyExpireAt 轮换前的 Key 失效时间，宽限期结束后不再返回
+	PreviousKeyExpireAt *time.Time `json:"previous_key_expire_at,omitempty"`
+}
+
+// CreateUserToken 创建当前用户的 API Token，返回完整 Key
+func (ts *TokenService) CreateUserToken(ctx context.Context, userID int, req *CreateTokenRequest) (*UserToken, error) {
+	ipWhitelist, err := normalizeIPWhitelist(req.IPWhitelist)
+	if err != nil {
+		return nil, err
+	}
+	modelWhitelist, err := normalizeModelWhitelist(req.ModelWhitelist)
+	if err != nil {
+		return nil, err
+	}
+	if req.QuotaLimit < 0 {
+		return nil, fmt.Errorf("%w: quota_limit must not be negative", ErrInvalidTokenRequest)
+	}
+
+	expireDays := req.ExpireDays
+	if expireDays <= 0 {
+		expireDays = defaultTokenExpireDays
+	}
+
+	token := newToken(userID, req.Name, req.Description, req.QuotaLimit)
+	token.IPWhitelist = ipWhitelist
+	token.ModelWhitelist = modelWhitelist
+
+	token, key, err := ts.createToken(ctx, token, expireDays)
+	if err != nil {
+		return nil, err
+	}
+
+	info := toUserToken(token)
+	info.Key = key
+	return info, nil
+}
+
+// ListUserTokens 列出当前用户未删除的 API Token（Key 已脱敏）
+func (ts *TokenService) ListUserTokens(ctx context.Context, userID int) ([]*UserToken, error) {
+	tokens, err := ts.ListTokens(ctx, userID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*UserToken, 0, len(tokens))
+	for _, token := range tokens {
+		result = append(result, toUserToken(token))
+	}
+	return result, nil
+}
+
+// UpdateUserToken 更新名称、额度上限与 IP/模型白名单
+func (ts *TokenService) UpdateUserToken(ctx context.Context, userID int, tokenID int, req *UpdateTokenRequest) (*UserToken, error) {
+	token, err := ts.ownedToken(ctx, userID, tokenID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: name must not be empty", ErrInvalidTokenRequest)
+		}
+		token.Name = name
+	}
+	if req.Description != nil {
+		token.Description = toNullString(*req.Description)
+	}
+	if req.QuotaLimit != nil {
+		if *req.QuotaLimit < 0 {
+			return nil, fmt.Errorf("%w: quota_limit must not be negative", ErrInvalidTokenRequest)
+		}
+		token.QuotaLimit = toNullInt64(*req.QuotaLimit)
+		// 提高上限后已耗尽的 Token 恢复可用
+		if token.Status == model.TokenStatusExhausted && (!token.QuotaLimit.Valid || token.QuotaUsed < token.QuotaLimit.Int64) {
+			token.Status = model.TokenStatusNormal
+		}
+	}
+	if req.IPWhitelist != nil {
+		if token.IPWhitelist, err = normalizeIPWhitelist(*req.IPWhitelist); err != nil {
+			return nil, err
+		}
+	}
+	if req.ModelWhitelist != nil {
+		if token.ModelWhitelist, err = normalizeModelWhitelist(*req.ModelWhitelist); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ts.tokenRepo.Update(ctx, token); err != nil {
+		return nil, fmt.Errorf("failed to update token: %w", err)
+	}
+
+	// 记录审计日志
+	_ = ts.logAudit(ctx, userID, token.ID, model.TokenOpUpdate, &token.Status, &token.Status, nil, "", "")
+
+	return toUserToken(token), nil
+}
+
+// RotateUserToken 轮换当前用户的 API Token，返回新 Key；grace 为负数时使用默认宽限期
+func (ts *TokenService) RotateUserToken(ctx context.Context, userID int, tokenID int, grace time.Duration) (*UserToken, error) {
+	if _, err := ts.ownedToken(ctx, userID, tokenID); err != nil {
+		return nil, err
+	}
+	if grace < 0 {
+		grace = DefaultTokenRotationGrace
+	}
+	if grace > maxTokenRotationGrace {
+		return nil, fmt.Errorf("%w: grace period must not exceed %s", ErrInvalidTokenRequest, maxTokenRotationGrace)
+	}
+
+	token, key, err := ts.RotateToken(ctx, tokenID, grace)
+	if err != nil {
+		return nil, err
+	}
+
+	info := toUserToken(token)
+	info.Key = key
+	return info, nil
+}
+
+// DeleteUserToken 软删除当前用户的 API Token，删除后立即失效
+func (ts *TokenService) DeleteUserToken(ctx context.Context, userID int, tokenID int) error {
+	if _, err := ts.ownedToken(ctx, userID, tokenID); err != nil {
+		return err
+	}
+	return ts.SoftDeleteToken(ctx, tokenID)
+}
+
+// ownedToken 获取用户未删除的 Token，他人的 Token 与不存在的一样处理
+func (ts *TokenService) ownedToken(ctx context.Context, userID int, tokenID int) (*model.Token, error) {
+	token, err := ts.tokenRepo.GetByID(ctx, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	if token == nil || token.UserID != userID || token.Status == model.TokenStatusDeleted {
+		return nil, ErrTokenNotFound
+	}
+	return token, nil
+}
+
+// normalizeIPWhitelist 校验 IP 白名单，只支持精确 IP 与 "*"
+func normalizeIPWhitelist(ips []string) (pq.StringArray, error) {
+	result := pq.StringArray{}
+	for _, ip := range ips {
+		ip = strings.TrimSpace(ip)
+		if ip == "" {
+			continue
+		}
+		if ip != "*" && net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("%w: invalid ip address %q", ErrInvalidTokenRequest, ip)
+		}
+		result = append(result, ip)
+	}
+	return result, nil
+}
+
+// normalizeModelWhitelist 去除模型白名单中的空白项
+func normalizeModelWhitelist(models []string) (pq.StringArray, error) {
+	result := pq.StringArray{}
+	for _, m := range models {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		if len(m) > 128 {
+			return nil, fmt.Errorf("%w: model name too long", ErrInvalidTokenRequest)
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// toUserToken 转换为对外的 Token 信息，Key 已脱敏
+func toUserToken(token *model.Token) *UserToken {
+	info := &UserToken{
+		ID:             token.ID,
+		ProjectID:      int(token.ProjectID.Int64),
+		Name:           token.Name,
+		Description:    token.Description.String,
+		Key:            tokenKeyPreview(token),
+		Status:         token.Status.String(),
+		QuotaLimit:     -1,
+		QuotaUsed:      token.QuotaUsed,
+		IPWhitelist:    append([]string{}, token.IPWhitelist...),
+		ModelWhitelist: append([]string{}, token.ModelWhitelist...),
+		CreatedAt:      token.CreatedAt,
+	}
+	if token.QuotaLimit.Valid {
+		info.QuotaLimit = token.QuotaLimit.Int64
+	}
+	if token.ExpireAt.Valid {
+		info.ExpireAt = &token.ExpireAt.Time
+	}
+	if token.LastUsedAt.Valid {
+		info.LastUsedAt = &token.LastUsedAt.Time
+	}
+	if token.PreviousHashExpireAt.Valid && token.PreviousHashExpireAt.Time.After(time.Now()) {
+		info.PreviousKeyExpireAt = &token.PreviousHashExpireAt.Time
+	}
+	return info
+}
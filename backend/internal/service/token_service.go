@@ -2,10 +2,13 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
@@ -24,7 +27,7 @@ func NewTokenService(tokenRepo repository.TokenRepository) *TokenService {
 	}
 }
 
-// CreateToken 创建新的 Token
+// CreateToken 创建新的 Token，返回的 Key 只在创建时可见，数据库只保存其哈希
 func (ts *TokenService) CreateToken(
 	ctx context.Context,
 	userID int,
@@ -32,21 +35,30 @@ func (ts *TokenService) CreateToken(
 	description string,
 	quotaLimit int64,
 	expireDays int,
-) (*model.Token, error) {
-	// 生成 Token 哈希
-	tokenHash := ts.generateTokenHash()
+) (*model.Token, string, error) {
+	return ts.createToken(ctx, newToken(userID, name, description, quotaLimit), expireDays)
+}
 
-	// 设置过期时间
-	expireAt := time.Now().AddDate(0, 0, expireDays)
+// CreateProjectToken 创建绑定到中转项目的 Token，调用方需先校验项目归属
+func (ts *TokenService) CreateProjectToken(
+	ctx context.Context,
+	userID int,
+	projectID int,
+	name string,
+	description string,
+	quotaLimit int64,
+	expireDays int,
+) (*model.Token, string, error) {
+	token := newToken(userID, name, description, quotaLimit)
+	token.ProjectID = toNullInt64(int64(projectID))
+	return ts.createToken(ctx, token, expireDays)
+}
 
+// newToken 构造待创建的 Token
+func newToken(userID int, name string, description string, quotaLimit int64) *model.Token {
 	token := &model.Token{
 		UserID:         userID,
-		TokenHash:      tokenHash,
 		Name:           name,
-		Status:         model.TokenStatusNormal,
-		QuotaUsed:      0,
-		CreatedAt:      time.Now(),
-		ExpireAt:       toNullTime(expireAt),
 		IPWhitelist:    []string{},
 		ModelWhitelist: []string{},
 		Metadata:       make(map[string]interface{}),
@@ -60,17 +72,84 @@ func (ts *TokenService) CreateToken(
 		token.QuotaLimit = toNullInt64(quotaLimit)
 	}
 
-	// 保存到数据库
-	err := ts.tokenRepo.Create(ctx, token)
+	return token
+}
+
+// createToken 生成 Key 并保存 Token，返回 Key 明文
+func (ts *TokenService) createToken(ctx context.Context, token *model.Token, expireDays int) (*model.Token, string, error) {
+	key, tokenHash, err := generateTokenKey()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create token: %w", err)
+		return nil, "", err
+	}
+
+	token.TokenHash = tokenHash
+	token.KeyHint = maskTokenKey(key)
+	token.Status = model.TokenStatusNormal
+	token.QuotaUsed = 0
+	token.CreatedAt = time.Now()
+	token.ExpireAt = toNullTime(time.Now().AddDate(0, 0, expireDays))
+
+	// 保存到数据库
+	if err := ts.tokenRepo.Create(ctx, token); err != nil {
+		return nil, "", fmt.Errorf("failed to create token: %w", err)
 	}
 
 	// 记录审计日志
 	normalStatus := model.TokenStatusNormal
-	_ = ts.logAudit(ctx, userID, token.ID, model.TokenOpCreate, nil, &normalStatus, nil, "", "")
+	_ = ts.logAudit(ctx, token.UserID, token.ID, model.TokenOpCreate, nil, &normalStatus, nil, "", "")
 
-	return token, nil
+	return token, key, nil
+}
+
+// AuthenticateKey 校验客户端提交的 API Key：按 Key 的哈希查找 Token（轮换宽限期内的旧 Key 同样有效），
+// 再通过 AuthenticateToken 校验状态与 IP 白名单
+func (ts *TokenService) AuthenticateKey(ctx context.Context, key string, ipAddress string) (*model.Token, error) {
+	token, err := ts.lookupKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return ts.AuthenticateToken(ctx, token.TokenHash, ipAddress)
+}
+
+// RotateToken 为 Token 生成新的 Key，旧 Key 在 grace 内仍然有效（grace 为 0 时立即失效），返回新 Key
+func (ts *TokenService) RotateToken(ctx context.Context, tokenID int, grace time.Duration) (*model.Token, string, error) {
+	token, err := ts.tokenRepo.GetByID(ctx, tokenID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get token: %w", err)
+	}
+	if token == nil {
+		return nil, "", model.ErrTokenInvalid
+	}
+
+	key, tokenHash, err := generateTokenKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 早期 Token 直接保存 Key，旧 Key 的哈希需要重新计算
+	previousHash := token.TokenHash
+	if token.KeyHint == "" {
+		previousHash = HashTokenKey(token.TokenHash)
+	}
+
+	token.TokenHash = tokenHash
+	token.KeyHint = maskTokenKey(key)
+	token.PreviousTokenHash = sql.NullString{}
+	token.PreviousHashExpireAt = clearNullTime()
+	if grace > 0 {
+		token.PreviousTokenHash = toNullString(previousHash)
+		token.PreviousHashExpireAt = toNullTime(time.Now().Add(grace))
+	}
+
+	if err := ts.tokenRepo.Update(ctx, token); err != nil {
+		return nil, "", fmt.Errorf("failed to rotate token: %w", err)
+	}
+
+	// 记录审计日志
+	details := map[string]interface{}{"grace_seconds": int64(grace.Seconds())}
+	_ = ts.logAudit(ctx, token.UserID, token.ID, model.TokenOpRotate, &token.Status, &token.Status, details, "", "")
+
+	return token, key, nil
 }
 
 // GetTokenByHash 通过 Hash 获取 Token
@@ -79,6 +158,9 @@ func (ts *TokenService) GetTokenByHash(ctx context.Context, tokenHash string) (*
 	if err != nil {
 		return nil, fmt.Errorf("failed to get token: %w", err)
 	}
+	if token == nil {
+		return nil, model.ErrTokenInvalid
+	}
 
 	// 检查状态并更新过期状态
 	if token.Status == model.TokenStatusNormal && token.ExpireAt.Valid && token.ExpireAt.Time.Before(time.Now()) {
@@ -89,13 +171,53 @@ func (ts *TokenService) GetTokenByHash(ctx context.Context, tokenHash string) (*
 	return token, nil
 }
 
+// GetTokenByID 通过 ID 获取 Token
+func (ts *TokenService) GetTokenByID(ctx context.Context, id int) (*model.Token, error) {
+	token, err := ts.tokenRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	if token == nil {
+		return nil, model.ErrTokenInvalid
+	}
+	return token, nil
+}
+
 // ValidateToken 验证 Token
 func (ts *TokenService) ValidateToken(
 	ctx context.Context,
 	tokenHash string,
 	ipAddress string,
-	model string,
+	modelName string,
 ) (*model.Token, error) {
+	token, err := ts.AuthenticateToken(ctx, tokenHash, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查模型白名单
+	if !token.ValidateModel(modelName) {
+		return nil, fmt.Errorf("%w: %s", model.ErrModelNotAllowed, modelName)
+	}
+
+	return token, nil
+}
+
+// ValidateKey 校验客户端提交的 API Key：按哈希查找 Token 后通过 ValidateToken 校验状态、IP 与模型白名单，
+// modelName 为空（如列出模型）时不检查模型白名单
+func (ts *TokenService) ValidateKey(ctx context.Context, key string, ipAddress string, modelName string) (*model.Token, error) {
+	token, err := ts.lookupKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if modelName == "" {
+		return ts.AuthenticateToken(ctx, token.TokenHash, ipAddress)
+	}
+	return ts.ValidateToken(ctx, token.TokenHash, ipAddress, modelName)
+}
+
+// AuthenticateToken 校验 Token 状态与 IP 白名单（模型白名单由调用方在确定模型后检查）
+func (ts *TokenService) AuthenticateToken(ctx context.Context, tokenHash string, ipAddress string) (*model.Token, error) {
 	token, err := ts.GetTokenByHash(ctx, tokenHash)
 	if err != nil {
 		return nil, err
@@ -111,11 +233,6 @@ func (ts *TokenService) ValidateToken(
 		return nil, fmt.Errorf("ip address not in whitelist: %s", ipAddress)
 	}
 
-	// 检查模型白名单
-	if !token.ValidateModel(model) {
-		return nil, fmt.Errorf("model not in whitelist: %s", model)
-	}
-
 	// 更新最后使用时间
 	_ = ts.updateLastUsedAt(ctx, token.ID)
 
@@ -140,6 +257,8 @@ func (ts *TokenService) RenewToken(ctx context.Context, tokenID int, extendDays
 
 	token.ExpireAt = toNullTime(newExpireAt)
 	token.RenewedAt = toNullTime(time.Now())
+	// 续期后重新发送即将过期通知
+	token.ExpiryNotifiedAt = clearNullTime()
 
 	// 更新数据库
 	err = ts.tokenRepo.Update(ctx, token)
@@ -148,11 +267,12 @@ func (ts *TokenService) RenewToken(ctx context.Context, tokenID int, extendDays
 	}
 
 	// 记录续期日志
-	_ = ts.logRenewal(ctx, tokenID, oldExpireAt, toNullTime(newExpireAt), "manual_renewal")
+	_ = ts.logRenewal(ctx, tokenID, oldExpireAt, token.ExpireAt, "manual_renewal")
 
 	// 记录审计日志
 	oldStatus := token.Status
-	_ = ts.logAudit(ctx, token.UserID, tokenID, model.TokenOpRenew, &oldStatus, &model.TokenStatusNormal, nil, "", "")
+	newStatus := model.TokenStatusNormal
+	_ = ts.logAudit(ctx, token.UserID, tokenID, model.TokenOpRenew, &oldStatus, &newStatus, nil, "", "")
 
 	return nil
 }
@@ -250,7 +370,7 @@ func (ts *TokenService) RestoreToken(ctx context.Context, tokenID int) error {
 	newStatus := model.TokenStatusNormal
 
 	token.Status = newStatus
-	token.DeletedAt = toNullTime(time.Time{})
+	token.DeletedAt = clearNullTime()
 
 	// 更新数据库
 	err = ts.tokenRepo.Update(ctx, token)
@@ -278,7 +398,7 @@ func (ts *TokenService) UseQuota(ctx context.Context, tokenID int, amount int64)
 		oldStatus := token.Status
 		token.Status = newStatus
 
-		_, _ = ts.tokenRepo.Update(ctx, token)
+		_ = ts.tokenRepo.Update(ctx, token)
 		_ = ts.logAudit(ctx, token.UserID, tokenID, model.TokenOpUseQuota, &oldStatus, &newStatus, nil, "", "")
 
 		return fmt.Errorf("quota exceeded for token %d", tokenID)
@@ -365,19 +485,76 @@ func (ts *TokenService) CheckAndUpdateExpiredTokens(ctx context.Context) (int, e
 
 // 私有方法
 
-// generateTokenHash 生成 Token 哈希
-func (ts *TokenService) generateTokenHash() string {
-	data := []byte(fmt.Sprintf("%d-%d-%d", time.Now().UnixNano(), time.Now().Unix(), time.Now().Nanosecond()))
-	hash := sha256.Sum256(data)
+// tokenKeyPrefix API Key 前缀
+const tokenKeyPrefix = "sk-"
+
+// generateTokenKey 使用 crypto/rand 生成 API Key，返回 Key 明文与其哈希
+func generateTokenKey() (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate token key: %w", err)
+	}
+	key := tokenKeyPrefix + hex.EncodeToString(secret)
+	return key, HashTokenKey(key), nil
+}
+
+// HashTokenKey 计算 API Key 的 SHA-256 哈希，数据库只保存该哈希
+func HashTokenKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
 	return hex.EncodeToString(hash[:])
 }
 
+// lookupKey 根据 API Key 查找 Token
+func (ts *TokenService) lookupKey(ctx context.Context, key string) (*model.Token, error) {
+	if key == "" {
+		return nil, model.ErrTokenInvalid
+	}
+	tokenHash := HashTokenKey(key)
+
+	if strings.HasPrefix(key, tokenKeyPrefix) {
+		token, err := ts.tokenRepo.GetByHash(ctx, tokenHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get token: %w", err)
+		}
+		if token != nil {
+			return token, nil
+		}
+	}
+
+	// 轮换前的 Key（包括早期没有前缀的 Key）在宽限期内仍然有效
+	token, err := ts.tokenRepo.GetByPreviousHash(ctx, tokenHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	if token != nil && token.PreviousHashExpireAt.Valid && time.Now().Before(token.PreviousHashExpireAt.Time) {
+		return token, nil
+	}
+
+	if !strings.HasPrefix(key, tokenKeyPrefix) {
+		return ts.lookupLegacyKey(ctx, key)
+	}
+	return nil, model.ErrTokenInvalid
+}
+
+// lookupLegacyKey 查找早期 Token：Key 没有 sk- 前缀并直接保存在 token_hash 中，这类 Token 没有 KeyHint；
+// 新 Token 的 token_hash 是哈希值，不能当作 Key 使用
+func (ts *TokenService) lookupLegacyKey(ctx context.Context, key string) (*model.Token, error) {
+	token, err := ts.tokenRepo.GetByHash(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	if token != nil && token.KeyHint == "" {
+		return token, nil
+	}
+	return nil, model.ErrTokenInvalid
+}
+
 // updateTokenStatus 更新 Token 状态
 func (ts *TokenService) updateTokenStatus(ctx context.Context, token *model.Token, newStatus model.TokenStatus) error {
 	oldStatus := token.Status
 	token.Status = newStatus
 
-	err = ts.tokenRepo.Update(ctx, token)
+	err := ts.tokenRepo.Update(ctx, token)
 	if err != nil {
 		return fmt.Errorf("failed to update token status: %w", err)
 	}
@@ -437,57 +614,41 @@ func (ts *TokenService) logAudit(
 func (ts *TokenService) logRenewal(
 	ctx context.Context,
 	tokenID int,
-	oldExpireAt interface{},
-	newExpireAt interface{},
+	oldExpireAt sql.NullTime,
+	newExpireAt sql.NullTime,
 	reason string,
 ) error {
 	renewalLog := &model.TokenRenewalLog{
 		TokenID:       tokenID,
+		OldExpireAt:   oldExpireAt,
+		NewExpireAt:   newExpireAt,
 		RenewalReason: reason,
 		CreatedAt:     time.Now(),
 	}
 
-	// 处理旧过期时间
-	if oldExpireAt != nil {
-		if nullTime, ok := oldExpireAt.(time.Time); ok {
-			renewalLog.OldExpireAt = toNullTime(nullTime)
-		}
-	}
-
-	// 处理新过期时间
-	if newExpireAt != nil {
-		if nt, ok := newExpireAt.(interface{}).(interface {
-			Time() time.Time
-			Valid() bool
-		}); ok {
-			renewalLog.NewExpireAt = toNullTime(nt.Time())
-		}
-	}
-
 	return ts.tokenRepo.LogRenewal(ctx, renewalLog)
 }
 
 // 辅助函数
 
-func toNullString(s string) interface{} {
-	if s == "" {
-		return nil
-	}
-	return s
+// toNullString 空字符串视为 NULL
+func toNullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
 
-func toNullInt64(i int64) interface{} {
-	if i == 0 {
-		return nil
-	}
-	return i
+// toNullInt64 0 视为 NULL
+func toNullInt64(i int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: i, Valid: i != 0}
 }
 
-func toNullTime(t time.Time) interface{} {
-	if t.IsZero() {
-		return nil
-	}
-	return t
+// toNullTime 零值时间视为 NULL
+func toNullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// clearNullTime 返回 NULL 时间，用于清空 deleted_at 等可空列（Update 会写入 NULL）
+func clearNullTime() sql.NullTime {
+	return sql.NullTime{}
 }
 
 // GetTokenDetailsJSON 获取 Token 详情的 JSON 格式
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// renewalTokens 内存中的单个 Token，记录续期日志
+type renewalTokens struct {
+	repository.TokenRepository
+	token    model.Token
+	renewals []*model.TokenRenewalLog
+}
+
+func (r *renewalTokens) GetByID(ctx context.Context, id int) (*model.Token, error) {
+	token := r.token
+	return &token, nil
+}
+
+func (r *renewalTokens) Update(ctx context.Context, token *model.Token) error {
+	r.token = *token
+	return nil
+}
+
+func (r *renewalTokens) LogAudit(ctx context.Context, log *model.TokenAuditLog) error {
+	return nil
+}
+
+func (r *renewalTokens) LogRenewal(ctx context.Context, log *model.TokenRenewalLog) error {
+	r.renewals = append(r.renewals, log)
+	return nil
+}
+
+func TestNullHelpers(t *testing.T) {
+	assert.Equal(t, sql.NullString{String: "a", Valid: true}, toNullString("a"))
+	assert.False(t, toNullString("").Valid)
+	assert.Equal(t, sql.NullInt64{Int64: 5, Valid: true}, toNullInt64(5))
+	assert.False(t, toNullInt64(0).Valid)
+
+	now := time.Now()
+	assert.Equal(t, sql.NullTime{Time: now, Valid: true}, toNullTime(now))
+	assert.False(t, toNullTime(time.Time{}).Valid)
+	assert.False(t, clearNullTime().Valid)
+}
+
+func TestRenewTokenLogsExpiry(t *testing.T) {
+	expireAt := time.Now().Add(72 * time.Hour)
+	repo := &renewalTokens{token: model.Token{
+		ID:       3,
+		UserID:   7,
+		Status:   model.TokenStatusNormal,
+		ExpireAt: sql.NullTime{Time: expireAt, Valid: true},
+	}}
+
+	require.NoError(t, NewTokenService(repo).RenewToken(context.Background(), 3, 30))
+
+	require.Len(t, repo.renewals, 1)
+	renewal := repo.renewals[0]
+	assert.Equal(t, sql.NullTime{Time: expireAt, Valid: true}, renewal.OldExpireAt)
+	assert.Equal(t, sql.NullTime{Time: expireAt.AddDate(0, 0, 30), Valid: true}, renewal.NewExpireAt)
+	assert.Equal(t, renewal.NewExpireAt, repo.token.ExpireAt)
+}
+
+func TestRestoreTokenClearsDeletedAt(t *testing.T) {
+	repo := &renewalTokens{token: model.Token{
+		ID:        3,
+		UserID:    7,
+		Status:    model.TokenStatusDeleted,
+		DeletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}}
+	tokens := NewTokenService(repo)
+
+	require.NoError(t, tokens.RestoreToken(context.Background(), 3))
+	assert.False(t, repo.token.DeletedAt.Valid)
+	assert.Equal(t, model.TokenStatusNormal, repo.token.Status)
+
+	// 未删除的 Token 不能恢复
+	assert.Error(t, tokens.RestoreToken(context.Background(), 3))
+}
+
+// hashedTokens 按 token_hash 查找的内存 Token
+type hashedTokens struct {
+	repository.TokenRepository
+	byHash map[string]*model.Token
+}
+
+func (r *hashedTokens) GetByHash(ctx context.Context, hash string) (*model.Token, error) {
+	return r.byHash[hash], nil
+}
+
+func (r *hashedTokens) GetByPreviousHash(ctx context.Context, hash string) (*model.Token, error) {
+	return nil, nil
+}
+
+func TestLookupKeyLegacyOnlyWithoutKeyHint(t *testing.T) {
+	const key = "sk-current"
+	legacyKey := strings.Repeat("ab", 32)
+	current := &model.Token{ID: 1, TokenHash: HashTokenKey(key), KeyHint: "sk-c****rent"}
+	legacy := &model.Token{ID: 2, TokenHash: legacyKey}
+	tokens := NewTokenService(&hashedTokens{byHash: map[string]*model.Token{
+		current.TokenHash: current,
+		legacy.TokenHash:  legacy,
+	}})
+	ctx := context.Background()
+
+	token, err := tokens.lookupKey(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, 1, token.ID)
+
+	token, err = tokens.lookupKey(ctx, legacyKey)
+	require.NoError(t, err)
+	assert.Equal(t, 2, token.ID)
+
+	// 新 Token 的哈希值不能当作 Key 使用
+	_, err = tokens.lookupKey(ctx, current.TokenHash)
+	assert.ErrorIs(t, err, model.ErrTokenInvalid)
+}
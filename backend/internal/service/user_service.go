@@ -7,23 +7,33 @@ import (
 	"time"
 
 	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	logger "github.com/shirosoralumie648/Oblivious/backend/internal/logging"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
 	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"go.uber.org/zap"
 )
 
 type UserService struct {
-	userRepo *repository.UserRepository
-	jwtCfg   *config.JWTConfig
+	userRepo     *repository.UserRepository
+	jwtCfg       *config.JWTConfig
+	sessions     *SessionService
+	verification *EmailVerificationService // 为 nil 时注册即激活
 }
 
-func NewUserService(jwtCfg *config.JWTConfig) *UserService {
+func NewUserService(jwtCfg *config.JWTConfig, sessions *SessionService) *UserService {
 	return &UserService{
 		userRepo: repository.NewUserRepository(),
 		jwtCfg:   jwtCfg,
+		sessions: sessions,
 	}
 }
 
+// SetEmailVerification 开启注册邮箱验证：新用户处于待验证状态，验证邮箱后才能登录
+func (s *UserService) SetEmailVerification(verification *EmailVerificationService) {
+	s.verification = verification
+}
+
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=20"`
 	Email    string `json:"email" binding:"required,email"`
@@ -81,16 +91,26 @@ func (s *UserService) Register(ctx context.Context, req *RegisterRequest) (*mode
 		Quota:        500000, // 新用户默认 5000 元（500000 分）
 		TotalQuota:   500000,
 	}
+	if s.verification != nil {
+		user.Status = model.UserStatusPending
+	}
 
 	if err := s.userRepo.Create(ctx, user); err != nil {
 		return nil, err
 	}
 
+	// 验证邮件发送失败不影响注册，用户可以通过重发接口再次获取
+	if s.verification != nil {
+		if err := s.verification.Send(ctx, user); err != nil {
+			logger.Warn("Failed to send verification email", zap.Int("user_id", user.ID), zap.Error(err))
+		}
+	}
+
 	return user, nil
 }
 
 // Login 用户登录
-func (s *UserService) Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
+func (s *UserService) Login(ctx context.Context, req *LoginRequest, client ClientInfo) (*LoginResponse, error) {
 	// 查询用户
 	user, err := s.userRepo.FindByUsername(ctx, req.Username)
 	if err != nil {
@@ -106,25 +126,15 @@ func (s *UserService) Login(ctx context.Context, req *LoginRequest) (*LoginRespo
 	}
 
 	// 检查用户状态
+	if user.Status == model.UserStatusPending {
+		return nil, ErrEmailNotVerified
+	}
 	if user.Status != 1 {
 		return nil, errors.New("user account is disabled")
 	}
 
-	// 生成 Token
-	accessToken, err := utils.GenerateAccessToken(
-		user.ID,
-		user.Username,
-		user.Role,
-		s.jwtCfg.ExpireHours,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	refreshToken, err := utils.GenerateRefreshToken(
-		user.ID,
-		s.jwtCfg.RefreshExpireDays,
-	)
+	// 创建登录会话并签发 Token
+	resp, err := s.sessions.IssueTokens(ctx, user, client)
 	if err != nil {
 		return nil, err
 	}
@@ -132,14 +142,10 @@ func (s *UserService) Login(ctx context.Context, req *LoginRequest) (*LoginRespo
 	// 更新最后登录时间
 	now := time.Now()
 	user.LastLoginAt = &now
+	user.LastLoginIP = client.IP
 	_ = s.userRepo.Update(ctx, user)
 
-	return &LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    s.jwtCfg.ExpireHours * 3600,
-		User:         user,
-	}, nil
+	return resp, nil
 }
 
 // GetUserByID 获取用户信息
@@ -151,53 +157,9 @@ type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
-// RefreshAccessToken 刷新 Access Token
-func (s *UserService) RefreshAccessToken(ctx context.Context, refreshToken string) (*LoginResponse, error) {
-	// 解析 Refresh Token
-	claims, err := utils.ParseToken(refreshToken)
-	if err != nil {
-		return nil, errors.New("invalid refresh token")
-	}
-
-	// 查询用户
-	user, err := s.userRepo.FindByID(ctx, claims.UserID)
-	if err != nil {
-		return nil, err
-	}
-	if user == nil {
-		return nil, errors.New("user not found")
-	}
-
-	// 检查用户状态
-	if user.Status != 1 {
-		return nil, errors.New("user account is disabled")
-	}
-
-	// 生成新的 Token
-	accessToken, err := utils.GenerateAccessToken(
-		user.ID,
-		user.Username,
-		user.Role,
-		s.jwtCfg.ExpireHours,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	newRefreshToken, err := utils.GenerateRefreshToken(
-		user.ID,
-		s.jwtCfg.RefreshExpireDays,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	return &LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: newRefreshToken,
-		ExpiresIn:    s.jwtCfg.ExpireHours * 3600,
-		User:         user,
-	}, nil
+// RefreshAccessToken 刷新 Access Token，所属会话已被撤销时拒绝
+func (s *UserService) RefreshAccessToken(ctx context.Context, refreshToken string, client ClientInfo) (*LoginResponse, error) {
+	return s.sessions.Refresh(ctx, refreshToken, client)
 }
 
 type UpdateProfileRequest struct {
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/repository"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+)
+
+var (
+	// ErrSessionNotFound 会话不存在、不属于当前用户或已被撤销
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionRevoked 刷新令牌所属的会话已被撤销或因长期未使用被清理
+	ErrSessionRevoked = errors.New("session has been revoked")
+)
+
+// ClientInfo 发起登录或刷新的客户端信息
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// GeoLocator 按 IP 查询地理位置，查询失败时返回空字符串
+type GeoLocator interface {
+	Locate(ctx context.Context, ip string) string
+}
+
+// SessionUsers 会话服务依赖的用户仓储
+type SessionUsers interface {
+	FindByID(ctx context.Context, id int) (*model.User, error)
+}
+
+// SessionService 登录会话：每次登录创建一条刷新令牌链并记录设备与 IP，撤销会话后该令牌链不能再刷新
+//
+// 访问令牌在有效期内仍然可用，撤销只阻止后续刷新，访问令牌的有效期（JWT_EXPIRE_HOURS）决定了生效延迟。
+type SessionService struct {
+	sessions repository.UserSessionRepository
+	users    SessionUsers
+	jwtCfg   *config.JWTConfig
+	locator  GeoLocator
+	now      func() time.Time
+}
+
+// NewSessionService 创建登录会话服务
+func NewSessionService(sessions repository.UserSessionRepository, users SessionUsers, jwtCfg *config.JWTConfig) *SessionService {
+	return &SessionService{sessions: sessions, users: users, jwtCfg: jwtCfg, now: time.Now}
+}
+
+// SetGeoLocator 设置 IP 地理位置查询，未设置时会话不记录位置
+func (s *SessionService) SetGeoLocator(locator GeoLocator) {
+	s.locator = locator
+}
+
+// IssueTokens 为登录成功的用户创建会话并签发令牌
+func (s *SessionService) IssueTokens(ctx context.Context, user *model.User, client ClientInfo) (*LoginResponse, error) {
+	family := make([]byte, 16)
+	if _, err := rand.Read(family); err != nil {
+		return nil, err
+	}
+	now := s.now()
+	session := &model.UserSession{
+		UserID:     user.ID,
+		FamilyID:   hex.EncodeToString(family),
+		Device:     deviceFromUserAgent(client.UserAgent),
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		LastUsedAt: now,
+		CreatedAt:  now,
+	}
+	if s.locator != nil && client.IP != "" {
+		session.Location = s.locator.Locate(ctx, client.IP)
+	}
+	if err := s.sessions.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	return s.tokens(user, session.FamilyID)
+}
+
+// Refresh 校验刷新令牌所属的会话并签发新令牌，新令牌沿用同一条令牌链
+//
+// 本功能上线前签发的刷新令牌不带会话 ID，刷新时为其创建新会话。
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string, client ClientInfo) (*LoginResponse, error) {
+	claims, err := utils.ParseToken(refreshToken)
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	user, err := s.users.FindByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	if user.Status != model.UserStatusActive {
+		return nil, errors.New("user account is disabled")
+	}
+
+	if claims.Session == "" {
+		return s.IssueTokens(ctx, user, client)
+	}
+	session, err := s.sessions.GetByFamily(ctx, claims.Session)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.RevokedAt != nil || session.UserID != user.ID {
+		return nil, ErrSessionRevoked
+	}
+	if err := s.sessions.Touch(ctx, session.ID, s.now(), client.IP); err != nil {
+		return nil, err
+	}
+	return s.tokens(user, session.FamilyID)
+}
+
+// List 列出用户未撤销的会话，currentFamily 为发起查询的令牌所属会话
+func (s *SessionService) List(ctx context.Context, userID int, currentFamily string) ([]*model.UserSession, error) {
+	sessions, err := s.sessions.ListActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = currentFamily != "" && session.FamilyID == currentFamily
+	}
+	return sessions, nil
+}
+
+// Revoke 撤销用户的会话，该会话的刷新令牌随即失效
+func (s *SessionService) Revoke(ctx context.Context, userID, id int) error {
+	revoked, err := s.sessions.Revoke(ctx, userID, id, s.now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// PruneIdle 删除超过 idle 未使用的会话，被删除会话的刷新令牌不能再刷新
+func (s *SessionService) PruneIdle(ctx context.Context, idle time.Duration) (int64, error) {
+	return s.sessions.DeleteIdle(ctx, s.now().Add(-idle))
+}
+
+// tokens 签发属于会话的访问令牌与刷新令牌
+func (s *SessionService) tokens(user *model.User, family string) (*LoginResponse, error) {
+	accessToken, err := utils.GenerateSessionAccessToken(user.ID, user.Username, user.Role, family, s.jwtCfg.ExpireHours)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := utils.GenerateSessionRefreshToken(user.ID, family, s.jwtCfg.RefreshExpireDays)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.jwtCfg.ExpireHours * 3600,
+		User:         user,
+	}, nil
+}
+
+// userAgentBrowsers 按匹配顺序排列的浏览器标识，Edge 与 Opera 的 User-Agent 同时包含 Chrome
+var userAgentBrowsers = []struct{ token, name string }{
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Firefox/", "Firefox"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"curl/", "curl"},
+}
+
+// userAgentSystems 按匹配顺序排列的操作系统标识，Android 与 iOS 的 User-Agent 同时包含 Linux 与 Mac OS X
+var userAgentSystems = []struct{ token, name string }{
+	{"Android", "Android"},
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"Windows", "Windows"},
+	{"Mac OS X", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// deviceFromUserAgent 由 User-Agent 得到 "浏览器 on 系统" 形式的设备描述，无法识别的部分省略
+func deviceFromUserAgent(userAgent string) string {
+	var browser, system string
+	for _, b := range userAgentBrowsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, o := range userAgentSystems {
+		if strings.Contains(userAgent, o.token) {
+			system = o.name
+			break
+		}
+	}
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	default:
+		return "Unknown device"
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySessions 内存中的登录会话仓储
+type memorySessions struct {
+	mu       sync.Mutex
+	nextID   int
+	sessions map[int]*model.UserSession
+}
+
+func newMemorySessions() *memorySessions {
+	return &memorySessions{sessions: make(map[int]*model.UserSession)}
+}
+
+func (r *memorySessions) Create(ctx context.Context, session *model.UserSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	session.ID = r.nextID
+	copied := *session
+	r.sessions[session.ID] = &copied
+	return nil
+}
+
+func (r *memorySessions) GetByFamily(ctx context.Context, familyID string) (*model.UserSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if session.FamilyID == familyID {
+			copied := *session
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memorySessions) Touch(ctx context.Context, id int, at time.Time, ip string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session, ok := r.sessions[id]; ok {
+		session.LastUsedAt, session.IP = at, ip
+	}
+	return nil
+}
+
+func (r *memorySessions) ListActive(ctx context.Context, userID int) ([]*model.UserSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sessions []*model.UserSession
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, nil
+}
+
+func (r *memorySessions) Revoke(ctx context.Context, userID, id int, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return false, nil
+	}
+	session.RevokedAt = &at
+	return true, nil
+}
+
+func (r *memorySessions) DeleteIdle(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted int64
+	for id, session := range r.sessions {
+		if session.LastUsedAt.Before(before) {
+			delete(r.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// sessionUsers 内存中的用户
+type sessionUsers map[int]*model.User
+
+func (u sessionUsers) FindByID(ctx context.Context, id int) (*model.User, error) {
+	return u[id], nil
+}
+
+func newTestSessionService(t *testing.T) (*SessionService, *memorySessions, *time.Time) {
+	utils.InitJWT(&config.JWTConfig{Secret: "test-secret"})
+	repo := newMemorySessions()
+	users := sessionUsers{
+		1: {ID: 1, Username: "alice", Role: 1, Status: model.UserStatusActive},
+		2: {ID: 2, Username: "bob", Role: 1, Status: model.UserStatusActive},
+	}
+	s := NewSessionService(repo, users, &config.JWTConfig{ExpireHours: 2, RefreshExpireDays: 7})
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, repo, &now
+}
+
+const testChromeUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+
+func TestSessionRevokePreventsRefresh(t *testing.T) {
+	s, _, now := newTestSessionService(t)
+	ctx := context.Background()
+	alice := &model.User{ID: 1, Username: "alice"}
+
+	laptop, err := s.IssueTokens(ctx, alice, ClientInfo{UserAgent: testChromeUA, IP: "10.0.0.1"})
+	require.NoError(t, err)
+	phone, err := s.IssueTokens(ctx, alice, ClientInfo{UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Version/17.0 Mobile Safari/604.1", IP: "10.0.0.2"})
+	require.NoError(t, err)
+
+	claims, err := utils.ParseToken(laptop.AccessToken)
+	require.NoError(t, err)
+	require.NotEmpty(t, claims.Session)
+
+	*now = now.Add(time.Hour)
+	refreshed, err := s.Refresh(ctx, laptop.RefreshToken, ClientInfo{IP: "10.0.0.9"})
+	require.NoError(t, err)
+	refreshedClaims, err := utils.ParseToken(refreshed.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, claims.Session, refreshedClaims.Session, "refreshing keeps the token family")
+
+	sessions, err := s.List(ctx, 1, claims.Session)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "Chrome on Windows", sessions[0].Device)
+	assert.Equal(t, "10.0.0.9", sessions[0].IP)
+	assert.True(t, sessions[0].Current)
+	assert.Equal(t, "Safari on iOS", sessions[1].Device)
+	assert.False(t, sessions[1].Current)
+
+	assert.ErrorIs(t, s.Revoke(ctx, 2, sessions[1].ID), ErrSessionNotFound, "users cannot revoke other users' sessions")
+	require.NoError(t, s.Revoke(ctx, 1, sessions[1].ID))
+	assert.ErrorIs(t, s.Revoke(ctx, 1, sessions[1].ID), ErrSessionNotFound)
+
+	_, err = s.Refresh(ctx, phone.RefreshToken, ClientInfo{})
+	assert.ErrorIs(t, err, ErrSessionRevoked)
+	_, err = s.Refresh(ctx, refreshed.RefreshToken, ClientInfo{})
+	assert.NoError(t, err, "other sessions keep working")
+
+	sessions, err = s.List(ctx, 1, "")
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+}
+
+func TestSessionRefreshWithoutFamilyStartsSession(t *testing.T) {
+	s, repo, _ := newTestSessionService(t)
+
+	legacy, err := utils.GenerateRefreshToken(1, 7)
+	require.NoError(t, err)
+	resp, err := s.Refresh(context.Background(), legacy, ClientInfo{UserAgent: testChromeUA})
+	require.NoError(t, err)
+
+	claims, err := utils.ParseToken(resp.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEmpty(t, claims.Session)
+	assert.Len(t, repo.sessions, 1)
+}
+
+func TestSessionPruneIdle(t *testing.T) {
+	s, repo, now := newTestSessionService(t)
+	ctx := context.Background()
+
+	stale, err := s.IssueTokens(ctx, &model.User{ID: 1}, ClientInfo{})
+	require.NoError(t, err)
+	*now = now.Add(20 * 24 * time.Hour)
+	_, err = s.IssueTokens(ctx, &model.User{ID: 1}, ClientInfo{})
+	require.NoError(t, err)
+
+	*now = now.Add(15 * 24 * time.Hour)
+	pruned, err := s.PruneIdle(ctx, 30*24*time.Hour)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, pruned)
+	assert.Len(t, repo.sessions, 1)
+
+	_, err = s.Refresh(ctx, stale.RefreshToken, ClientInfo{})
+	assert.ErrorIs(t, err, ErrSessionRevoked, "a pruned session cannot be refreshed")
+}
+
+func TestDeviceFromUserAgent(t *testing.T) {
+	cases := map[string]string{
+		testChromeUA: "Chrome on Windows",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/537.36 Chrome/120.0 Safari/537.36 Edg/120.0": "Edge on macOS",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36":        "Chrome on Android",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0":                               "Firefox on Linux",
+		"curl/8.4.0": "curl",
+		"":           "Unknown device",
+	}
+	for userAgent, want := range cases {
+		assert.Equal(t, want, deviceFromUserAgent(userAgent), userAgent)
+	}
+}
@@ -0,0 +1,434 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+)
+
+var (
+	// ErrOrgNotFound 组织不存在
+	ErrOrgNotFound = errors.New("organization not found")
+	// ErrSSONotConfigured 组织未配置或未启用 SSO
+	ErrSSONotConfigured = errors.New("sso is not configured for this organization")
+	// ErrInvalidState state 无效或已过期
+	ErrInvalidState = errors.New("invalid or expired sso state")
+	// ErrEmailNotVerified IdP 未确认邮箱
+	ErrEmailNotVerified = errors.New("email is missing or not verified by the identity provider")
+	// ErrDomainNotAllowed 邮箱域名不在白名单内
+	ErrDomainNotAllowed = errors.New("email domain is not allowed for this organization")
+	// ErrUserDisabled 用户已被禁用
+	ErrUserDisabled = errors.New("user account is disabled")
+)
+
+// Store SSO 依赖的持久化接口
+type Store interface {
+	GetOrgBySlug(ctx context.Context, slug string) (*model.Organization, error)
+	GetSSOConfig(ctx context.Context, orgID int) (*model.OrgSSOConfig, error)
+	FindIdentity(ctx context.Context, issuer, subject string) (*model.UserIdentity, error)
+	CreateIdentity(ctx context.Context, identity *model.UserIdentity) error
+	FindUserByID(ctx context.Context, id int) (*model.User, error)
+	FindUserByEmail(ctx context.Context, email string) (*model.User, error)
+	// CreateUser 创建即时开通的用户，实现方负责补全用户名、密码等字段
+	CreateUser(ctx context.Context, user *model.User) error
+	// ActivateUser 激活待验证邮箱的用户
+	ActivateUser(ctx context.Context, user *model.User) error
+	UpsertMember(ctx context.Context, member *model.OrganizationMember) error
+}
+
+// LoginResult SSO 登录结果
+type LoginResult struct {
+	User        *model.User
+	Org         *model.Organization
+	OrgRole     int
+	Provisioned bool // 本次登录即时创建了用户
+	Linked      bool // 本次登录绑定了已有用户
+}
+
+// ConnectionTestResult 连接测试结果
+type ConnectionTestResult struct {
+	DiscoveryOK           bool     `json:"discovery_ok"`
+	Issuer                string   `json:"issuer,omitempty"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint         string   `json:"token_endpoint,omitempty"`
+	JWKSURI               string   `json:"jwks_uri,omitempty"`
+	RedirectURIValid      bool     `json:"redirect_uri_valid"`
+	Errors                []string `json:"errors,omitempty"`
+}
+
+// Manager 组织 SSO 登录流程管理器
+type Manager struct {
+	store    Store
+	client   *OIDCClient
+	states   StateStore
+	stateTTL time.Duration
+}
+
+// NewManager 创建 SSO 管理器
+func NewManager(store Store, httpClient *http.Client) *Manager {
+	return &Manager{
+		store:    store,
+		client:   NewOIDCClient(httpClient),
+		states:   NewMemoryStateStore(),
+		stateTTL: 10 * time.Minute,
+	}
+}
+
+// SetStateStore 替换登录状态存储
+func (m *Manager) SetStateStore(states StateStore) {
+	m.states = states
+}
+
+// loadConfig 加载组织及其启用的 SSO 配置
+func (m *Manager) loadConfig(ctx context.Context, orgSlug string) (*model.Organization, *model.OrgSSOConfig, error) {
+	org, err := m.store.GetOrgBySlug(ctx, orgSlug)
+	if err != nil {
+		return nil, nil, err
+	}
+	if org == nil {
+		return nil, nil, ErrOrgNotFound
+	}
+
+	cfg, err := m.store.GetSSOConfig(ctx, org.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil, ErrSSONotConfigured
+	}
+
+	return org, cfg, nil
+}
+
+// BeginLogin 发起组织登录，返回 IdP 授权地址
+func (m *Manager) BeginLogin(ctx context.Context, orgSlug string) (string, error) {
+	org, cfg, err := m.loadConfig(ctx, orgSlug)
+	if err != nil {
+		return "", err
+	}
+
+	meta, err := m.client.Discover(ctx, cfg.IssuerURL)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomToken(24)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken(24)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := NewCodeVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	m.states.Save(state, &LoginState{
+		OrgID:        org.ID,
+		OrgSlug:      org.Slug,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(m.stateTTL),
+	})
+
+	authURL, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+	q := authURL.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", cfg.ClientID)
+	q.Set("redirect_uri", cfg.RedirectURI)
+	q.Set("scope", cfg.GetScopes())
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", CodeChallengeS256(verifier))
+	q.Set("code_challenge_method", "S256")
+	authURL.RawQuery = q.Encode()
+
+	return authURL.String(), nil
+}
+
+// CompleteLogin 处理 IdP 回调：换取令牌、校验 ID Token、绑定或即时开通用户
+func (m *Manager) CompleteLogin(ctx context.Context, orgSlug, state, code string) (*LoginResult, error) {
+	loginState, ok := m.states.Take(state)
+	if !ok || loginState.OrgSlug != orgSlug {
+		return nil, ErrInvalidState
+	}
+
+	org, cfg, err := m.loadConfig(ctx, orgSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := m.client.Discover(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := m.client.ExchangeCode(ctx, meta, cfg.ClientID, cfg.ClientSecret, cfg.RedirectURI, code, loginState.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := m.client.VerifyIDToken(ctx, meta, token.IDToken, cfg.ClientID, cfg.ClientSecret, loginState.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("invalid id_token: missing subject")
+	}
+	email := strings.ToLower(strings.TrimSpace(stringClaim(claims, "email")))
+	emailVerified := boolClaim(claims, "email_verified")
+
+	result := &LoginResult{
+		Org:     org,
+		OrgRole: ResolveRole(cfg, groupsFromClaims(claims, cfg.GroupsClaim)),
+	}
+
+	identity, err := m.store.FindIdentity(ctx, meta.Issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if identity != nil {
+		user, err := m.store.FindUserByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, errors.New("linked user no longer exists")
+		}
+		result.User = user
+	} else {
+		// 首次登录：绑定已有账号或即时开通，都要求邮箱已验证且域名在白名单内
+		if email == "" || !emailVerified {
+			return nil, ErrEmailNotVerified
+		}
+		if !DomainAllowed(cfg, email) {
+			return nil, ErrDomainNotAllowed
+		}
+
+		user, err := m.store.FindUserByEmail(ctx, email)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			result.Linked = true
+			// IdP 已验证该邮箱，注册后尚未验证邮箱的账号直接激活
+			if user.Status == model.UserStatusPending {
+				user.Status = model.UserStatusActive
+				if err := m.store.ActivateUser(ctx, user); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			user = &model.User{
+				Email:       email,
+				Username:    usernameFromClaims(claims, email),
+				DisplayName: displayNameFromClaims(claims, email),
+				Role:        1,
+				Status:      model.UserStatusActive,
+			}
+			if err := m.store.CreateUser(ctx, user); err != nil {
+				return nil, err
+			}
+			result.Provisioned = true
+		}
+
+		if err := m.store.CreateIdentity(ctx, &model.UserIdentity{
+			UserID:  user.ID,
+			Issuer:  meta.Issuer,
+			Subject: subject,
+			Email:   email,
+		}); err != nil {
+			return nil, err
+		}
+		result.User = user
+	}
+
+	if result.User.Status != 1 {
+		return nil, ErrUserDisabled
+	}
+
+	// 每次登录都按 IdP 组重新同步组织角色
+	if err := m.store.UpsertMember(ctx, &model.OrganizationMember{
+		OrgID:  org.ID,
+		UserID: result.User.ID,
+		Role:   result.OrgRole,
+	}); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// TestConnection 测试 SSO 配置：拉取发现文档并校验回调地址
+func (m *Manager) TestConnection(ctx context.Context, orgSlug string, cfg *model.OrgSSOConfig) *ConnectionTestResult {
+	result := &ConnectionTestResult{}
+
+	if err := ValidateRedirectURI(cfg.RedirectURI, orgSlug); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	} else {
+		result.RedirectURIValid = true
+	}
+
+	if cfg.ClientID == "" {
+		result.Errors = append(result.Errors, "client_id is required")
+	}
+
+	// 测试时绕过缓存，确保看到的是 IdP 当前的配置
+	meta, err := m.client.fetchDiscovery(ctx, strings.TrimRight(cfg.IssuerURL, "/"))
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+
+	result.DiscoveryOK = true
+	result.Issuer = meta.Issuer
+	result.AuthorizationEndpoint = meta.AuthorizationEndpoint
+	result.TokenEndpoint = meta.TokenEndpoint
+	result.JWKSURI = meta.JWKSURI
+
+	if len(meta.ResponseTypesSupported) > 0 && !containsString(meta.ResponseTypesSupported, "code") {
+		result.Errors = append(result.Errors, "provider does not support the authorization code flow")
+	}
+	if len(meta.CodeChallengeMethodsSupported) > 0 && !containsString(meta.CodeChallengeMethodsSupported, "S256") {
+		result.Errors = append(result.Errors, "provider does not support PKCE S256")
+	}
+
+	return result
+}
+
+// ValidateRedirectURI 校验回调地址必须是指向本组织回调路由的绝对地址
+func ValidateRedirectURI(redirectURI, orgSlug string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return errors.New("redirect_uri must be an absolute URL")
+	}
+
+	host := u.Hostname()
+	isLocal := host == "localhost" || host == "127.0.0.1" || host == "::1"
+	if u.Scheme != "https" && !(u.Scheme == "http" && isLocal) {
+		return errors.New("redirect_uri must use https")
+	}
+	if u.Fragment != "" {
+		return errors.New("redirect_uri must not contain a fragment")
+	}
+
+	expectedSuffix := "/sso/" + orgSlug + "/callback"
+	if !strings.HasSuffix(strings.TrimRight(u.Path, "/"), expectedSuffix) {
+		return fmt.Errorf("redirect_uri path must end with %s", expectedSuffix)
+	}
+
+	return nil
+}
+
+// DomainAllowed 检查邮箱域名是否在白名单内（白名单为空时拒绝）
+func DomainAllowed(cfg *model.OrgSSOConfig, email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+
+	for _, allowed := range cfg.GetAllowedDomains() {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveRole 按组映射计算组织角色，取命中的最高角色，未命中时使用默认角色
+func ResolveRole(cfg *model.OrgSSOConfig, groups []string) int {
+	role := cfg.DefaultRole
+	if role <= 0 {
+		role = 1
+	}
+
+	mapping := cfg.GetGroupRoleMapping()
+	matched := false
+	best := 0
+	for _, group := range groups {
+		if r, ok := mapping[group]; ok && (!matched || r > best) {
+			best = r
+			matched = true
+		}
+	}
+	if matched {
+		return best
+	}
+	return role
+}
+
+// groupsFromClaims 读取组声明，兼容数组和单个字符串
+func groupsFromClaims(claims jwt.MapClaims, claimName string) []string {
+	if claimName == "" {
+		claimName = "groups"
+	}
+
+	switch v := claims[claimName].(type) {
+	case []interface{}:
+		groups := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+		return groups
+	case string:
+		return []string{v}
+	}
+	return nil
+}
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+func boolClaim(claims jwt.MapClaims, name string) bool {
+	switch v := claims[name].(type) {
+	case bool:
+		return v
+	case string:
+		// 部分 IdP 以字符串形式返回布尔值
+		return v == "true"
+	}
+	return false
+}
+
+func usernameFromClaims(claims jwt.MapClaims, email string) string {
+	if name := stringClaim(claims, "preferred_username"); name != "" && !strings.Contains(name, "@") {
+		return name
+	}
+	return email[:strings.LastIndex(email, "@")]
+}
+
+func displayNameFromClaims(claims jwt.MapClaims, email string) string {
+	if name := stringClaim(claims, "name"); name != "" {
+		return name
+	}
+	return usernameFromClaims(claims, email)
+}
+
+func containsString(list []string, target string) bool {
+	for _, s := range list {
+		if s == target {
+			return true
+		}
+	}
+	return false
+}
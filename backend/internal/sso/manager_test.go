@@ -0,0 +1,371 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider 模拟 OIDC 提供方
+type fakeProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+
+	mu         sync.Mutex
+	challenges map[string]string // code -> code_challenge
+	nonces     map[string]string // code -> nonce
+	claims     map[string]interface{}
+}
+
+func newFakeProvider(t *testing.T, clientID string) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := &fakeProvider{
+		key:        key,
+		clientID:   clientID,
+		challenges: make(map[string]string),
+		nonces:     make(map[string]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                           p.server.URL,
+			"authorization_endpoint":           p.server.URL + "/authorize",
+			"token_endpoint":                   p.server.URL + "/token",
+			"jwks_uri":                         p.server.URL + "/jwks",
+			"response_types_supported":         []string{"code"},
+			"code_challenge_methods_supported": []string{"S256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		code := r.PostForm.Get("code")
+
+		p.mu.Lock()
+		challenge, ok := p.challenges[code]
+		nonce := p.nonces[code]
+		claims := jwt.MapClaims{}
+		for k, v := range p.claims {
+			claims[k] = v
+		}
+		p.mu.Unlock()
+
+		if !ok || CodeChallengeS256(r.PostForm.Get("code_verifier")) != challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		claims["iss"] = p.server.URL
+		claims["aud"] = p.clientID
+		claims["nonce"] = nonce
+		claims["iat"] = time.Now().Unix()
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test-key"
+		signed, err := token.SignedString(p.key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     signed,
+		})
+	})
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// authorize 模拟用户在 IdP 完成登录，返回授权码
+func (p *fakeProvider) authorize(t *testing.T, authURL string, claims map[string]interface{}) (state, code string) {
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	q := u.Query()
+	require.Equal(t, "S256", q.Get("code_challenge_method"))
+
+	code = "code-" + q.Get("state")
+	p.mu.Lock()
+	p.challenges[code] = q.Get("code_challenge")
+	p.nonces[code] = q.Get("nonce")
+	p.claims = claims
+	p.mu.Unlock()
+
+	return q.Get("state"), code
+}
+
+// memoryStore 内存版 Store
+type memoryStore struct {
+	orgs       map[string]*model.Organization
+	configs    map[int]*model.OrgSSOConfig
+	users      []*model.User
+	identities []*model.UserIdentity
+	members    map[[2]int]*model.OrganizationMember
+	activated  []int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		orgs:    make(map[string]*model.Organization),
+		configs: make(map[int]*model.OrgSSOConfig),
+		members: make(map[[2]int]*model.OrganizationMember),
+	}
+}
+
+func (s *memoryStore) GetOrgBySlug(ctx context.Context, slug string) (*model.Organization, error) {
+	return s.orgs[slug], nil
+}
+
+func (s *memoryStore) GetSSOConfig(ctx context.Context, orgID int) (*model.OrgSSOConfig, error) {
+	return s.configs[orgID], nil
+}
+
+func (s *memoryStore) FindIdentity(ctx context.Context, issuer, subject string) (*model.UserIdentity, error) {
+	for _, id := range s.identities {
+		if id.Issuer == issuer && id.Subject == subject {
+			return id, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryStore) CreateIdentity(ctx context.Context, identity *model.UserIdentity) error {
+	identity.ID = len(s.identities) + 1
+	s.identities = append(s.identities, identity)
+	return nil
+}
+
+func (s *memoryStore) FindUserByID(ctx context.Context, id int) (*model.User, error) {
+	for _, u := range s.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryStore) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	for _, u := range s.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryStore) CreateUser(ctx context.Context, user *model.User) error {
+	user.ID = len(s.users) + 1
+	s.users = append(s.users, user)
+	return nil
+}
+
+func (s *memoryStore) ActivateUser(ctx context.Context, user *model.User) error {
+	s.activated = append(s.activated, user.ID)
+	return nil
+}
+
+func (s *memoryStore) UpsertMember(ctx context.Context, member *model.OrganizationMember) error {
+	s.members[[2]int{member.OrgID, member.UserID}] = member
+	return nil
+}
+
+func setupSSO(t *testing.T) (*Manager, *memoryStore, *fakeProvider) {
+	provider := newFakeProvider(t, "oblivious")
+	store := newMemoryStore()
+	store.orgs["acme"] = &model.Organization{ID: 7, Slug: "acme", Name: "Acme"}
+	store.configs[7] = &model.OrgSSOConfig{
+		OrgID:            7,
+		IssuerURL:        provider.server.URL,
+		ClientID:         "oblivious",
+		ClientSecret:     "secret",
+		RedirectURI:      "https://app.example.com/api/v1/sso/acme/callback",
+		AllowedDomains:   "acme.com, acme.io",
+		GroupsClaim:      "groups",
+		GroupRoleMapping: []byte(`{"engineering": 10, "admins": 50}`),
+		DefaultRole:      1,
+		Enabled:          true,
+	}
+	return NewManager(store, provider.server.Client()), store, provider
+}
+
+func login(t *testing.T, m *Manager, p *fakeProvider, claims map[string]interface{}) (*LoginResult, error) {
+	authURL, err := m.BeginLogin(context.Background(), "acme")
+	require.NoError(t, err)
+	state, code := p.authorize(t, authURL, claims)
+	return m.CompleteLogin(context.Background(), "acme", state, code)
+}
+
+func TestSSOJITProvisioningWithGroupMapping(t *testing.T) {
+	m, store, p := setupSSO(t)
+
+	result, err := login(t, m, p, map[string]interface{}{
+		"sub":                "idp-user-1",
+		"email":              "Alice@Acme.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+		"groups":             []string{"everyone", "engineering", "admins"},
+	})
+	require.NoError(t, err)
+
+	assert.True(t, result.Provisioned)
+	assert.Equal(t, "alice@acme.com", result.User.Email)
+	assert.Equal(t, "alice", result.User.Username)
+	assert.Equal(t, 50, result.OrgRole)
+	assert.Equal(t, 50, store.members[[2]int{7, result.User.ID}].Role)
+	require.Len(t, store.identities, 1)
+
+	// 再次登录复用身份绑定，组变化后角色同步
+	result, err = login(t, m, p, map[string]interface{}{
+		"sub":    "idp-user-1",
+		"groups": []string{"engineering"},
+	})
+	require.NoError(t, err)
+	assert.False(t, result.Provisioned)
+	assert.Len(t, store.users, 1)
+	assert.Equal(t, 10, store.members[[2]int{7, result.User.ID}].Role)
+}
+
+func TestSSODefaultRoleWithoutMatchingGroup(t *testing.T) {
+	m, _, p := setupSSO(t)
+
+	result, err := login(t, m, p, map[string]interface{}{
+		"sub":            "idp-user-2",
+		"email":          "bob@acme.io",
+		"email_verified": true,
+		"groups":         "sales",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.OrgRole)
+}
+
+func TestSSOLinksExistingUserByVerifiedEmail(t *testing.T) {
+	m, store, p := setupSSO(t)
+	existing := &model.User{ID: 42, Username: "carol", Email: "carol@acme.com", Status: 1}
+	store.users = append(store.users, existing)
+
+	result, err := login(t, m, p, map[string]interface{}{
+		"sub":            "idp-user-3",
+		"email":          "carol@acme.com",
+		"email_verified": true,
+	})
+	require.NoError(t, err)
+	assert.True(t, result.Linked)
+	assert.Equal(t, 42, result.User.ID)
+	assert.Len(t, store.users, 1)
+}
+
+func TestSSOActivatesPendingUserWithVerifiedEmail(t *testing.T) {
+	m, store, p := setupSSO(t)
+	store.users = append(store.users, &model.User{ID: 42, Username: "carol", Email: "carol@acme.com", Status: model.UserStatusPending})
+
+	result, err := login(t, m, p, map[string]interface{}{
+		"sub":            "idp-user-3",
+		"email":          "carol@acme.com",
+		"email_verified": true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.UserStatusActive, result.User.Status)
+	assert.Equal(t, []int{42}, store.activated)
+}
+
+func TestSSORejectsUnverifiedEmail(t *testing.T) {
+	m, store, p := setupSSO(t)
+	store.users = append(store.users, &model.User{ID: 42, Email: "carol@acme.com", Status: 1})
+
+	_, err := login(t, m, p, map[string]interface{}{
+		"sub":            "idp-user-3",
+		"email":          "carol@acme.com",
+		"email_verified": false,
+	})
+	assert.ErrorIs(t, err, ErrEmailNotVerified)
+	assert.Empty(t, store.identities)
+}
+
+func TestSSORejectsDisallowedDomain(t *testing.T) {
+	m, store, p := setupSSO(t)
+
+	_, err := login(t, m, p, map[string]interface{}{
+		"sub":            "idp-user-4",
+		"email":          "mallory@evil.com",
+		"email_verified": true,
+	})
+	assert.ErrorIs(t, err, ErrDomainNotAllowed)
+	assert.Empty(t, store.users)
+	assert.Empty(t, store.members)
+}
+
+func TestSSORejectsReusedState(t *testing.T) {
+	m, _, p := setupSSO(t)
+
+	authURL, err := m.BeginLogin(context.Background(), "acme")
+	require.NoError(t, err)
+	state, code := p.authorize(t, authURL, map[string]interface{}{
+		"sub":            "idp-user-5",
+		"email":          "dave@acme.com",
+		"email_verified": true,
+	})
+
+	_, err = m.CompleteLogin(context.Background(), "acme", state, code)
+	require.NoError(t, err)
+
+	_, err = m.CompleteLogin(context.Background(), "acme", state, code)
+	assert.ErrorIs(t, err, ErrInvalidState)
+}
+
+func TestSSOTestConnection(t *testing.T) {
+	m, store, _ := setupSSO(t)
+	cfg := store.configs[7]
+
+	result := m.TestConnection(context.Background(), "acme", cfg)
+	assert.True(t, result.DiscoveryOK)
+	assert.True(t, result.RedirectURIValid)
+	assert.Empty(t, result.Errors)
+
+	bad := *cfg
+	bad.RedirectURI = "http://app.example.com/callback"
+	result = m.TestConnection(context.Background(), "acme", &bad)
+	assert.True(t, result.DiscoveryOK)
+	assert.False(t, result.RedirectURIValid)
+	assert.NotEmpty(t, result.Errors)
+}
+
+func TestValidateRedirectURI(t *testing.T) {
+	assert.NoError(t, ValidateRedirectURI("https://app.example.com/api/v1/sso/acme/callback", "acme"))
+	assert.NoError(t, ValidateRedirectURI("http://localhost:8081/api/v1/sso/acme/callback", "acme"))
+	assert.Error(t, ValidateRedirectURI("/api/v1/sso/acme/callback", "acme"))
+	assert.Error(t, ValidateRedirectURI("http://app.example.com/api/v1/sso/acme/callback", "acme"))
+	assert.Error(t, ValidateRedirectURI("https://app.example.com/api/v1/sso/other/callback", "acme"))
+}
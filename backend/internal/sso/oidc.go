@@ -0,0 +1,305 @@
+package sso
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ProviderMetadata OIDC 发现文档中用到的字段
+type ProviderMetadata struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	UserinfoEndpoint              string   `json:"userinfo_endpoint"`
+	JWKSURI                       string   `json:"jwks_uri"`
+	ResponseTypesSupported        []string `json:"response_types_supported"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+}
+
+// TokenResponse 授权码换取的令牌
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// jsonWebKey JWKS 中的单个公钥
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type cachedMetadata struct {
+	meta      *ProviderMetadata
+	fetchedAt time.Time
+}
+
+type cachedKeys struct {
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// OIDCClient OIDC 协议客户端（发现文档、换取令牌、校验 ID Token）
+type OIDCClient struct {
+	httpClient *http.Client
+	cacheTTL   time.Duration
+
+	mu       sync.Mutex
+	metadata map[string]*cachedMetadata
+	jwks     map[string]*cachedKeys
+}
+
+// NewOIDCClient 创建 OIDC 客户端
+func NewOIDCClient(httpClient *http.Client) *OIDCClient {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &OIDCClient{
+		httpClient: httpClient,
+		cacheTTL:   time.Hour,
+		metadata:   make(map[string]*cachedMetadata),
+		jwks:       make(map[string]*cachedKeys),
+	}
+}
+
+// Discover 获取发行方的发现文档（带缓存）
+func (c *OIDCClient) Discover(ctx context.Context, issuer string) (*ProviderMetadata, error) {
+	issuer = strings.TrimRight(issuer, "/")
+
+	c.mu.Lock()
+	if cached, ok := c.metadata[issuer]; ok && time.Since(cached.fetchedAt) < c.cacheTTL {
+		c.mu.Unlock()
+		return cached.meta, nil
+	}
+	c.mu.Unlock()
+
+	meta, err := c.fetchDiscovery(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.metadata[issuer] = &cachedMetadata{meta: meta, fetchedAt: time.Now()}
+	c.mu.Unlock()
+
+	return meta, nil
+}
+
+// fetchDiscovery 拉取并校验发现文档，不使用缓存
+func (c *OIDCClient) fetchDiscovery(ctx context.Context, issuer string) (*ProviderMetadata, error) {
+	var meta ProviderMetadata
+	if err := c.getJSON(ctx, issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("fetch discovery document: %w", err)
+	}
+
+	if strings.TrimRight(meta.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery issuer mismatch: expected %s, got %s", issuer, meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing required endpoints")
+	}
+
+	return &meta, nil
+}
+
+// ExchangeCode 使用授权码和 PKCE 校验码换取令牌
+func (c *OIDCClient) ExchangeCode(ctx context.Context, meta *ProviderMetadata, clientID, clientSecret, redirectURI, code, codeVerifier string) (*TokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", clientID)
+	form.Set("code_verifier", codeVerifier)
+	if clientSecret != "" {
+		form.Set("client_secret", clientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var token TokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("decode token response: %w", err)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response does not contain an id_token")
+	}
+
+	return &token, nil
+}
+
+// VerifyIDToken 校验 ID Token 的签名、发行方、受众、有效期和 nonce
+func (c *OIDCClient) VerifyIDToken(ctx context.Context, meta *ProviderMetadata, rawIDToken, clientID, clientSecret, nonce string) (jwt.MapClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "HS256"}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA:
+			kid, _ := token.Header["kid"].(string)
+			return c.publicKey(ctx, meta.JWKSURI, kid)
+		case *jwt.SigningMethodHMAC:
+			// HS256 的 ID Token 使用 client_secret 作为密钥
+			if clientSecret == "" {
+				return nil, errors.New("hmac signed id_token requires a client secret")
+			}
+			return []byte(clientSecret), nil
+		default:
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+
+	return claims, nil
+}
+
+// publicKey 按 kid 查找 JWKS 公钥，找不到时强制刷新一次以支持密钥轮换
+func (c *OIDCClient) publicKey(ctx context.Context, jwksURI, kid string) (*rsa.PublicKey, error) {
+	keys, err := c.loadKeys(ctx, jwksURI, false)
+	if err != nil {
+		return nil, err
+	}
+	if key := pickKey(keys, kid); key != nil {
+		return key, nil
+	}
+
+	keys, err = c.loadKeys(ctx, jwksURI, true)
+	if err != nil {
+		return nil, err
+	}
+	if key := pickKey(keys, kid); key != nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("signing key %q not found in jwks", kid)
+}
+
+func pickKey(keys map[string]*rsa.PublicKey, kid string) *rsa.PublicKey {
+	if kid != "" {
+		return keys[kid]
+	}
+	// 未指定 kid 时，仅在只有一把密钥的情况下使用
+	if len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return nil
+}
+
+func (c *OIDCClient) loadKeys(ctx context.Context, jwksURI string, force bool) (map[string]*rsa.PublicKey, error) {
+	c.mu.Lock()
+	if cached, ok := c.jwks[jwksURI]; ok && !force && time.Since(cached.fetchedAt) < c.cacheTTL {
+		c.mu.Unlock()
+		return cached.keys, nil
+	}
+	c.mu.Unlock()
+
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := c.getJSON(ctx, jwksURI, &doc); err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range doc.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := jwk.rsaPublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	c.mu.Lock()
+	c.jwks[jwksURI] = &cachedKeys{keys: keys, fetchedAt: time.Now()}
+	c.mu.Unlock()
+
+	return keys, nil
+}
+
+func (k *jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+func (c *OIDCClient) getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, rawURL)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,90 @@
+package sso
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// LoginState 登录发起时保存、回调时取回的上下文
+type LoginState struct {
+	OrgID        int
+	OrgSlug      string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// StateStore 登录状态存储（多实例部署时可替换为共享存储）
+type StateStore interface {
+	// Save 保存状态
+	Save(state string, data *LoginState)
+	// Take 取出并删除状态，保证一次性使用
+	Take(state string) (*LoginState, bool)
+}
+
+// MemoryStateStore 基于内存的登录状态存储
+type MemoryStateStore struct {
+	mu     sync.Mutex
+	states map[string]*LoginState
+}
+
+// NewMemoryStateStore 创建内存状态存储
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		states: make(map[string]*LoginState),
+	}
+}
+
+// Save 保存状态，并顺带清理已过期的条目
+func (s *MemoryStateStore) Save(state string, data *LoginState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, st := range s.states {
+		if now.After(st.ExpiresAt) {
+			delete(s.states, key)
+		}
+	}
+	s.states[state] = data
+}
+
+// Take 取出并删除状态
+func (s *MemoryStateStore) Take(state string) (*LoginState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.states[state]
+	if !ok {
+		return nil, false
+	}
+	delete(s.states, state)
+
+	if time.Now().After(data.ExpiresAt) {
+		return nil, false
+	}
+	return data, true
+}
+
+// randomToken 生成 URL 安全的随机字符串
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewCodeVerifier 生成 PKCE code_verifier（43 个字符）
+func NewCodeVerifier() (string, error) {
+	return randomToken(32)
+}
+
+// CodeChallengeS256 计算 PKCE S256 code_challenge
+func CodeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 内存中的 S3 兼容服务，校验签名头与负载哈希
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	paths   []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paths = append(f.paths, r.URL.EscapedPath())
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20260102/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+		f.t.Errorf("unexpected Authorization header: %s", auth)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if r.Header.Get("X-Amz-Date") != "20260102T030405Z" {
+		f.t.Errorf("unexpected X-Amz-Date: %s", r.Header.Get("X-Amz-Date"))
+	}
+
+	key := r.URL.Path
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+			f.t.Errorf("payload hash does not match body")
+		}
+		if r.ContentLength != int64(len(body)) {
+			f.t.Errorf("expected Content-Length %d, got %d", len(body), r.ContentLength)
+		}
+		f.objects[key] = body
+	case http.MethodGet, http.MethodHead:
+		body, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newTestS3Store(t *testing.T, pathStyle bool) (*S3ObjectStore, *fakeS3) {
+	t.Helper()
+
+	fake := &fakeS3{t: t, objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3ObjectStore(S3Config{
+		Endpoint:  server.URL,
+		Region:    "eu-west-1",
+		Bucket:    "uploads",
+		AccessKey: "AKID",
+		SecretKey: "secret",
+		PathStyle: pathStyle,
+	})
+	require.NoError(t, err)
+	store.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	return store, fake
+}
+
+func TestS3ObjectStore(t *testing.T) {
+	ctx := context.Background()
+	store, fake := newTestS3Store(t, true)
+
+	n, err := store.Put(ctx, "files/ab/hello world.txt", strings.NewReader("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.Equal(t, "/uploads/files/ab/hello%20world.txt", fake.paths[0])
+
+	ok, err := store.Exists(ctx, "files/ab/hello world.txt")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	r, err := store.Get(ctx, "files/ab/hello world.txt")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	r.Close()
+	assert.Equal(t, "hello", string(data))
+
+	require.NoError(t, store.Delete(ctx, "files/ab/hello world.txt"))
+	_, err = store.Get(ctx, "files/ab/hello world.txt")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+
+	ok, err = store.Exists(ctx, "files/ab/hello world.txt")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestS3ObjectStoreConfig(t *testing.T) {
+	_, err := NewS3ObjectStore(S3Config{Endpoint: "http://minio:9000", Bucket: "b"})
+	assert.Error(t, err, "credentials are required")
+
+	_, err = NewS3ObjectStore(S3Config{Endpoint: "minio:9000", Bucket: "b", AccessKey: "a", SecretKey: "s"})
+	assert.Error(t, err, "endpoint must be an absolute URL")
+
+	store, err := NewS3ObjectStore(S3Config{Endpoint: "https://s3.amazonaws.com", Bucket: "b", AccessKey: "a", SecretKey: "s"})
+	require.NoError(t, err)
+	assert.Equal(t, "us-east-1", store.cfg.Region)
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrObjectNotFound 对象不存在
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectStore 对象存储接口（本地磁盘、MinIO/S3 等实现）
+type ObjectStore interface {
+	// Put 写入对象，已存在时覆盖
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Get 读取对象，调用方负责关闭
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象
+	Delete(ctx context.Context, key string) error
+	// Exists 检查对象是否存在
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// LocalObjectStore 基于本地目录的对象存储
+type LocalObjectStore struct {
+	baseDir string
+}
+
+// NewLocalObjectStore 创建本地对象存储
+func NewLocalObjectStore(baseDir string) (*LocalObjectStore, error) {
+	absDir, err := filepath.Abs(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(absDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage dir: %w", err)
+	}
+	return &LocalObjectStore{baseDir: absDir}, nil
+}
+
+// path 将对象键解析为目录内的路径，拒绝越界访问
+func (s *LocalObjectStore) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	full := filepath.Join(s.baseDir, cleaned)
+	rel, err := filepath.Rel(s.baseDir, full)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("invalid object key: %s", key)
+	}
+	return full, nil
+}
+
+// Put 写入对象（先写临时文件再重命名，避免读到半截文件）
+func (s *LocalObjectStore) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	full, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(full), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(tmp.Name(), full); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Get 读取对象
+func (s *LocalObjectStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	full, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// Delete 删除对象
+func (s *LocalObjectStore) Delete(ctx context.Context, key string) error {
+	full, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Exists 检查对象是否存在
+func (s *LocalObjectStore) Exists(ctx context.Context, key string) (bool, error) {
+	full, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(full)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
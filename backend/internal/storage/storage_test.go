@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalObjectStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalObjectStore(t.TempDir())
+	require.NoError(t, err)
+
+	n, err := store.Put(ctx, "logs/2024/a.gz", strings.NewReader("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	ok, err := store.Exists(ctx, "logs/2024/a.gz")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	r, err := store.Get(ctx, "logs/2024/a.gz")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	r.Close()
+	assert.Equal(t, "hello", string(data))
+
+	require.NoError(t, store.Delete(ctx, "logs/2024/a.gz"))
+	_, err = store.Get(ctx, "logs/2024/a.gz")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestLocalObjectStoreRejectsEscape(t *testing.T) {
+	store, err := NewLocalObjectStore(t.TempDir())
+	require.NoError(t, err)
+
+	// 越界的键被约束在根目录内
+	_, err = store.Put(context.Background(), "", strings.NewReader("x"))
+	assert.Error(t, err)
+
+	n, err := store.Put(context.Background(), "../../escape", strings.NewReader("x"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	ok, _ := store.Exists(context.Background(), "escape")
+	assert.True(t, ok)
+}
@@ -1,8 +1,8 @@
 package token
 
 import (
+	"errors"
 	"testing"
-	"time"
 )
 
 // MockTokenStore 模拟 Token 存储
@@ -65,7 +65,7 @@ func (m *MockTokenStore) Rotate(oldID string, newMetadata *TokenMetadata) error
 	return ErrTokenNotFound
 }
 
-var ErrTokenNotFound = &struct{ msg string }{msg: "token not found"}
+var ErrTokenNotFound = errors.New("token not found")
 
 func TestGenerateToken(t *testing.T) {
 	store := NewMockTokenStore()
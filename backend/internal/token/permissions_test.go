@@ -1,6 +1,7 @@
 package token
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -44,7 +45,7 @@ func (m *MockPermissionStore) Delete(id string) error {
 	return nil
 }
 
-var ErrPermissionsNotFound = &struct{ msg string }{msg: "permissions not found"}
+var ErrPermissionsNotFound = errors.New("permissions not found")
 
 func TestCreatePermissions(t *testing.T) {
 	store := NewMockPermissionStore()
@@ -11,13 +11,16 @@ import (
 	"time"
 )
 
+// DefaultLockoutDuration 未配置 TimeoutDuration 时，连续错误达到上限后 Token 的锁定时长
+const DefaultLockoutDuration = 5 * time.Minute
+
 // SecurityConfig 安全配置
 type SecurityConfig struct {
 	EncryptionKey       string        // AES-256 密钥 (32 字节)
 	AnomalyDetection    bool          // 启用异常检测
 	LeakageMonitoring   bool          // 启用泄露监控
 	FailureThreshold    int           // 失败次数阈值
-	TimeoutDuration     time.Duration // 超时时间
+	TimeoutDuration     time.Duration // 锁定时长，<= 0 时使用 DefaultLockoutDuration
 	MaxConsecutiveErrors int          // 最大连续错误
 }
 
@@ -84,7 +87,13 @@ func NewTokenSecurityManager(config *SecurityConfig) (*TokenSecurityManager, err
 
 	anomalyDetector := &AnomalyDetector{
 		usagePatterns: make(map[string]*UsagePattern),
-		threshold:     0.7,
+		threshold:     0.6, // 模型与请求大小两项异常（各 0.3）同时出现才判定为异常
+	}
+
+	// 复制配置，补全默认值时不修改调用方的配置
+	cfg := *config
+	if cfg.TimeoutDuration <= 0 {
+		cfg.TimeoutDuration = DefaultLockoutDuration
 	}
 
 	leakageMonitor := &LeakageMonitor{
@@ -93,7 +102,7 @@ func NewTokenSecurityManager(config *SecurityConfig) (*TokenSecurityManager, err
 	}
 
 	return &TokenSecurityManager{
-		config:          config,
+		config:          &cfg,
 		encryptionCipher: block,
 		anomalyDetector: anomalyDetector,
 		leakageMonitor:  leakageMonitor,
@@ -202,7 +211,7 @@ func (tsm *TokenSecurityManager) MonitorLeakage(tokenID string) (bool, error) {
 	}
 
 	// 检查泄露数据库
-	for _, db := range tsm.leakageMonitor.publicDatabases {
+	for range tsm.leakageMonitor.publicDatabases {
 		// 这里应该实现实际的泄露检测逻辑
 		// 简化版本仅记录检查时间
 	}
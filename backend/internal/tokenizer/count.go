@@ -0,0 +1,79 @@
+package tokenizer
+
+import (
+	"context"
+	"unicode/utf8"
+)
+
+// 启发式估算的消息固定开销，与 BPE 计数的消息格式开销保持一致
+const (
+	heuristicTokensPerMessage = 3
+	heuristicTokensPerReply   = 3
+)
+
+// CountText 统计文本的 Token 数
+//
+// 编码注册表中的模型使用 BPE 计数，其它模型使用通用计数器估算；
+// BPE 编码无法加载（如离线环境下载不到词表）时退回按字符估算，不返回错误。
+func CountText(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	if tk, err := globalTokenizer(model); err == nil {
+		if n, err := tk.CountText(context.Background(), text, model); err == nil {
+			return n
+		}
+	}
+	return EstimateText(text)
+}
+
+// CountMessages 统计消息列表的输入 Token 数（含每条消息与回复的格式开销），退回规则同 CountText
+//
+// 图片片段按模型所属提供方文档中的单张图片成本计入，见 ImageTokens。
+func CountMessages(model string, msgs []Message) int {
+	if len(msgs) == 0 {
+		return 0
+	}
+	images := countImageTokens(model, msgs)
+	if tk, err := globalTokenizer(model); err == nil {
+		if n, err := tk.CountMessages(context.Background(), msgs, model); err == nil {
+			return n + images
+		}
+	}
+	return EstimateMessages(msgs) + images
+}
+
+// EstimateText 按每 4 个字符 1 个 Token 估算，不足 4 个字符按 1 个计
+func EstimateText(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// EstimateMessages 按字符估算消息列表的 Token 数
+func EstimateMessages(msgs []Message) int {
+	total := heuristicTokensPerReply
+	for _, msg := range msgs {
+		total += heuristicTokensPerMessage + EstimateText(msg.Role) + EstimateText(msg.Name)
+		switch content := msg.Content.(type) {
+		case string:
+			total += EstimateText(content)
+		case []interface{}:
+			for _, part := range content {
+				if partMap, ok := part.(map[string]interface{}); ok && partMap["type"] == "text" {
+					if text, ok := partMap["text"].(string); ok {
+						total += EstimateText(text)
+					}
+				}
+			}
+		}
+	}
+	return total
+}
+
+// globalTokenizer 从全局工厂获取模型的计数器
+func globalTokenizer(model string) (Tokenizer, error) {
+	factory, err := GetGlobalFactory()
+	if err != nil {
+		return nil, err
+	}
+	return factory.GetTokenizer(model)
+}
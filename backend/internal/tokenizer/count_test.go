@@ -0,0 +1,158 @@
+package tokenizer
+
+import (
+	"testing"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+func TestEncodingRegistry(t *testing.T) {
+	r := NewEncodingRegistry()
+	tests := map[string]string{
+		"gpt-4o-mini":            EncodingO200kBase,
+		"GPT-4o":                 EncodingO200kBase,
+		"o1-preview":             EncodingO200kBase,
+		"gpt-4-turbo":            EncodingCl100kBase,
+		"gpt-3.5-turbo-0125":     EncodingCl100kBase,
+		"text-embedding-3-small": EncodingCl100kBase,
+		"text-davinci-003":       EncodingP50kBase,
+		"text-davinci-001":       EncodingR50kBase,
+		"claude-3-5-sonnet":      "",
+		"qwen-max":               "",
+	}
+	for model, want := range tests {
+		if got := r.Encoding(model); got != want {
+			t.Errorf("Encoding(%s) = %q, want %q", model, got, want)
+		}
+	}
+
+	// 自部署模型可以注册为使用 OpenAI 分词，更长的前缀优先
+	r.Register("deepseek", EncodingCl100kBase)
+	r.Register("gpt-4-legacy", EncodingP50kBase)
+	if got := r.Encoding("deepseek-chat"); got != EncodingCl100kBase {
+		t.Errorf("Expected registered prefix to match, got %q", got)
+	}
+	if got := r.Encoding("gpt-4-legacy-0314"); got != EncodingP50kBase {
+		t.Errorf("Expected the longest prefix to win, got %q", got)
+	}
+}
+
+func TestEstimateText(t *testing.T) {
+	tests := map[string]int{
+		"":              0,
+		"hi":            1,
+		"hello world":   3,
+		"你好世界":          1,
+		"abcdefghijklm": 4,
+	}
+	for text, want := range tests {
+		if got := EstimateText(text); got != want {
+			t.Errorf("EstimateText(%q) = %d, want %d", text, got, want)
+		}
+	}
+
+	msgs := []Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: []interface{}{
+			map[string]interface{}{"type": "text", "text": "hello world"},
+			map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "https://example.com/a.png"}},
+		}, Name: "bob"},
+	}
+	// 回复开销 3 + (3 + system 2 + "Be brief." 3) + (3 + user 1 + bob 1 + "hello world" 3)
+	if got := EstimateMessages(msgs); got != 19 {
+		t.Errorf("EstimateMessages = %d, want 19", got)
+	}
+}
+
+func TestCountFallsBackWhenEncodingUnavailable(t *testing.T) {
+	RegisterEncoding("test-missing-bpe", "no_such_encoding")
+	defer defaultRegistry.Register("test-missing-bpe", "")
+
+	if got := CountText("test-missing-bpe-1", "hello world"); got != EstimateText("hello world") {
+		t.Errorf("Expected character estimate when BPE is unavailable, got %d", got)
+	}
+	msgs := []Message{{Role: "user", Content: "hello world"}}
+	if got := CountMessages("test-missing-bpe-1", msgs); got != EstimateMessages(msgs) {
+		t.Errorf("Expected message estimate when BPE is unavailable, got %d", got)
+	}
+	if CountText("gpt-4", "") != 0 || CountMessages("gpt-4", nil) != 0 {
+		t.Error("Expected empty input to count as zero")
+	}
+
+	// 未注册编码的模型由通用计数器估算
+	if got := CountText("my-local-model", "hello world, this is a test"); got != 6 {
+		t.Errorf("Expected generic estimate for unknown model, got %d", got)
+	}
+}
+
+// BPE 计数与 OpenAI tiktoken 的结果一致（词表需要可以加载，离线环境跳过）
+func TestCountTextBPEFixtures(t *testing.T) {
+	if _, err := tiktoken.GetEncoding(EncodingCl100kBase); err != nil {
+		t.Skipf("cl100k_base ranks unavailable: %v", err)
+	}
+
+	fixtures := []struct {
+		text string
+		want int
+	}{
+		{"hello world", 2},
+		{"tiktoken is great!", 6},
+		{"antidisestablishmentarianism", 6},
+		{"2 + 2 = 4", 7},
+		{"お誕生日おめでとう", 9},
+	}
+	for _, f := range fixtures {
+		if got := CountText("gpt-4", f.text); got != f.want {
+			t.Errorf("CountText(gpt-4, %q) = %d, want %d", f.text, got, f.want)
+		}
+	}
+
+	// 每条消息 3 + role + content，回复开销 3
+	msgs := []Message{{Role: "user", Content: "hello world"}}
+	if got := CountMessages("gpt-4", msgs); got != 9 {
+		t.Errorf("CountMessages(gpt-4) = %d, want 9", got)
+	}
+}
+
+func TestImageTokens(t *testing.T) {
+	tests := []struct {
+		model         string
+		width, height int
+		detail        string
+		want          int
+	}{
+		{"gpt-4o", 1024, 1024, "low", 85},
+		{"gpt-4o", 1024, 1024, "high", 765},  // 缩放到 768x768，4 个分块
+		{"gpt-4o", 4096, 2048, "auto", 1105}, // 缩放到 1536x768，6 个分块
+		{"gpt-4o", 0, 0, "", 765},            // 尺寸未知按 1024x1024
+		{"claude-3-5-sonnet", 1000, 1000, "", 1334},
+		{"claude-3-5-sonnet", 4000, 3000, "", 1600},
+		{"gemini-1.5-flash", 300, 200, "", 258},
+		{"gemini-1.5-flash", 1024, 1024, "", 1032},
+	}
+	for _, tt := range tests {
+		if got := ImageTokens(tt.model, tt.width, tt.height, tt.detail); got != tt.want {
+			t.Errorf("ImageTokens(%s, %dx%d, %q) = %d, want %d", tt.model, tt.width, tt.height, tt.detail, got, tt.want)
+		}
+	}
+}
+
+func TestCountMessagesWithImages(t *testing.T) {
+	// 2x1 像素的 PNG
+	const png = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAIAAAABCAYAAAD0In+KAAAAEUlEQVR4nGNgYGD4z8DAwMAAAAUAAf+hWr8AAAAASUVORK5CYII="
+	text := []Message{{Role: "user", Content: []interface{}{map[string]interface{}{"type": "text", "text": "What is this?"}}}}
+	withImage := []Message{{Role: "user", Content: []interface{}{
+		map[string]interface{}{"type": "text", "text": "What is this?"},
+		map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": png}},
+	}}}
+
+	if width, height := imageSize(png); width != 2 || height != 1 {
+		t.Errorf("Expected a 2x1 image, got %dx%d", width, height)
+	}
+	if got := CountMessages("gemini-1.5-flash", withImage) - CountMessages("gemini-1.5-flash", text); got != 258 {
+		t.Errorf("Expected a small image to cost 258 tokens on Gemini, got %d", got)
+	}
+	if got := CountMessages("gpt-4o", withImage) - CountMessages("gpt-4o", text); got != 255 {
+		t.Errorf("Expected a small image to cost one tile on OpenAI, got %d", got)
+	}
+}
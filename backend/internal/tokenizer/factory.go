@@ -2,7 +2,6 @@ package tokenizer
 
 import (
 	"fmt"
-	"strings"
 	"sync"
 )
 
@@ -53,26 +52,9 @@ func (f *TokenizerFactory) CreateBatchStreamCounter() *BatchStreamTokenCounter {
 	return NewBatchStreamTokenCounter()
 }
 
-// isOpenAIModel 判断是否为OpenAI模型
+// isOpenAIModel 判断模型是否在编码注册表中（使用 BPE 计数）
 func (f *TokenizerFactory) isOpenAIModel(model string) bool {
-	openAIModels := []string{
-		"gpt-4",
-		"gpt-3.5",
-		"text-embedding",
-		"text-davinci",
-		"text-curie",
-		"text-babbage",
-		"text-ada",
-	}
-
-	modelLower := strings.ToLower(model)
-	for _, prefix := range openAIModels {
-		if strings.HasPrefix(modelLower, prefix) {
-			return true
-		}
-	}
-
-	return false
+	return EncodingForModel(model) != ""
 }
 
 // getGenericTokenizer 获取通用计数器（带缓存）
@@ -0,0 +1,124 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"image"
+	_ "image/gif"  // 注册 GIF 解码器，用于读取图片尺寸
+	_ "image/jpeg" // 注册 JPEG 解码器
+	_ "image/png"  // 注册 PNG 解码器
+	"math"
+	"strings"
+)
+
+// 各提供方文档中的图片计费规则
+const (
+	openAIImageBaseTokens = 85  // low 细节的固定 Token，也是 high 细节的基础 Token
+	openAIImageTileTokens = 170 // high 细节每个 512x512 分块的 Token
+
+	claudeImagePixelsPerToken = 750  // Token 数约为 宽 x 高 / 750
+	claudeImageMaxEdge        = 1568 // 长边超过该值的图片会先被缩小
+	claudeImageMaxTokens      = 1600 // 缩小后的图片约 1600 Token 封顶
+
+	geminiImageTokens   = 258 // 两边都不超过 384 像素的图片，或更大图片的每个分块
+	geminiImageSmallMax = 384
+	geminiImageTile     = 768
+)
+
+// defaultImageSize 无法得知图片尺寸（外部 URL、无法解析的内联数据）时假设的边长
+const defaultImageSize = 1024
+
+// ImageTokens 按模型所属提供方的文档估算一张图片的输入 Token 数
+//
+// Claude 与 Gemini 模型按名称识别，其余模型按 OpenAI 的规则计算；width 或 height 为 0 时按 1024x1024 估算。
+func ImageTokens(model string, width, height int, detail string) int {
+	if width <= 0 || height <= 0 {
+		width, height = defaultImageSize, defaultImageSize
+	}
+	name := strings.ToLower(model)
+	switch {
+	case strings.Contains(name, "claude"):
+		return claudeImageTokens(width, height)
+	case strings.Contains(name, "gemini"):
+		return geminiImageTokensFor(width, height)
+	default:
+		return openAIImageTokens(width, height, detail)
+	}
+}
+
+// openAIImageTokens low 细节固定 85；其余先缩放到 2048x2048 以内、短边不超过 768，再按 512x512 分块计算
+func openAIImageTokens(width, height int, detail string) int {
+	if detail == "low" {
+		return openAIImageBaseTokens
+	}
+	w, h := float64(width), float64(height)
+	if longest := math.Max(w, h); longest > 2048 {
+		w, h = w*2048/longest, h*2048/longest
+	}
+	if shortest := math.Min(w, h); shortest > 768 {
+		w, h = w*768/shortest, h*768/shortest
+	}
+	tiles := int(math.Ceil(w/512) * math.Ceil(h/512))
+	return openAIImageBaseTokens + tiles*openAIImageTileTokens
+}
+
+// claudeImageTokens 长边缩放到 1568 以内后按 宽 x 高 / 750 计算
+func claudeImageTokens(width, height int) int {
+	w, h := float64(width), float64(height)
+	if longest := math.Max(w, h); longest > claudeImageMaxEdge {
+		w, h = w*claudeImageMaxEdge/longest, h*claudeImageMaxEdge/longest
+	}
+	tokens := int(math.Ceil(w * h / claudeImagePixelsPerToken))
+	if tokens > claudeImageMaxTokens {
+		tokens = claudeImageMaxTokens
+	}
+	return tokens
+}
+
+// geminiImageTokensFor 小图固定 258，更大的图片按 768x768 分块，每块 258
+func geminiImageTokensFor(width, height int) int {
+	if width <= geminiImageSmallMax && height <= geminiImageSmallMax {
+		return geminiImageTokens
+	}
+	tiles := ((width + geminiImageTile - 1) / geminiImageTile) * ((height + geminiImageTile - 1) / geminiImageTile)
+	return tiles * geminiImageTokens
+}
+
+// imageSize 解析 data:<mime>;base64,<data> 形式内联图片的尺寸，外部 URL 或无法解析时返回 0
+func imageSize(url string) (width, height int) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return 0, 0
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return 0, 0
+	}
+	config, _, err := image.DecodeConfig(base64.NewDecoder(base64.StdEncoding, strings.NewReader(data)))
+	if err != nil {
+		return 0, 0
+	}
+	return config.Width, config.Height
+}
+
+// countImageTokens 消息中图片片段的输入 Token 数
+func countImageTokens(model string, msgs []Message) int {
+	total := 0
+	for _, msg := range msgs {
+		parts, ok := msg.Content.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, part := range parts {
+			p, ok := part.(map[string]interface{})
+			if !ok || p["type"] != "image_url" {
+				continue
+			}
+			img, _ := p["image_url"].(map[string]interface{})
+			url, _ := img["url"].(string)
+			detail, _ := img["detail"].(string)
+			width, height := imageSize(url)
+			total += ImageTokens(model, width, height, detail)
+		}
+	}
+	return total
+}
@@ -0,0 +1,87 @@
+package tokenizer
+
+import (
+	"strings"
+	"sync"
+)
+
+// BPE 编码名称
+const (
+	EncodingO200kBase  = "o200k_base"
+	EncodingCl100kBase = "cl100k_base"
+	EncodingP50kBase   = "p50k_base"
+	EncodingR50kBase   = "r50k_base"
+)
+
+// EncodingRegistry 模型名前缀到 BPE 编码的映射，按最长前缀匹配
+//
+// 没有匹配的模型不使用 BPE，由通用计数器按字符估算。
+type EncodingRegistry struct {
+	prefixes map[string]string
+	mu       sync.RWMutex
+}
+
+// NewEncodingRegistry 创建包含 OpenAI 模型默认映射的注册表
+func NewEncodingRegistry() *EncodingRegistry {
+	return &EncodingRegistry{
+		prefixes: map[string]string{
+			"gpt-4o":                 EncodingO200kBase,
+			"gpt-4.1":                EncodingO200kBase,
+			"gpt-4.5":                EncodingO200kBase,
+			"gpt-5":                  EncodingO200kBase,
+			"chatgpt-4o":             EncodingO200kBase,
+			"o1":                     EncodingO200kBase,
+			"o3":                     EncodingO200kBase,
+			"o4":                     EncodingO200kBase,
+			"gpt-4":                  EncodingCl100kBase,
+			"gpt-3.5":                EncodingCl100kBase,
+			"gpt-35":                 EncodingCl100kBase,
+			"text-embedding-3":       EncodingCl100kBase,
+			"text-embedding-ada-002": EncodingCl100kBase,
+			"text-davinci-002":       EncodingP50kBase,
+			"text-davinci-003":       EncodingP50kBase,
+			"text-davinci":           EncodingR50kBase,
+			"text-curie":             EncodingR50kBase,
+			"text-babbage":           EncodingR50kBase,
+			"text-ada":               EncodingR50kBase,
+		},
+	}
+}
+
+// Register 为模型名前缀注册编码，已存在时覆盖
+func (r *EncodingRegistry) Register(prefix, encoding string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prefixes[strings.ToLower(prefix)] = encoding
+}
+
+// Encoding 模型使用的编码，未注册时返回空字符串
+func (r *EncodingRegistry) Encoding(model string) string {
+	model = strings.ToLower(model)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	best := ""
+	encoding := ""
+	for prefix, enc := range r.prefixes {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+			encoding = enc
+		}
+	}
+	return encoding
+}
+
+// defaultRegistry 全局编码注册表
+var defaultRegistry = NewEncodingRegistry()
+
+// RegisterEncoding 在全局注册表中为模型名前缀注册编码（如兼容 OpenAI 分词的自部署模型）
+func RegisterEncoding(prefix, encoding string) {
+	defaultRegistry.Register(prefix, encoding)
+}
+
+// EncodingForModel 全局注册表中模型使用的编码，未注册时返回空字符串
+func EncodingForModel(model string) string {
+	return defaultRegistry.Encoding(model)
+}
@@ -7,20 +7,32 @@ import (
 	"math"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkoukk/tiktoken-go"
 )
 
+// encodingRetryInterval 编码加载失败后重新尝试加载的间隔，避免每次计数都去下载词表
+const encodingRetryInterval = time.Minute
+
 // TiktokenTokenizer 基于tiktoken的Token计数器
 type TiktokenTokenizer struct {
 	encoders map[string]*tiktoken.Tiktoken
+	failures map[string]encodingFailure
 	mu       sync.RWMutex
 }
 
+// encodingFailure 最近一次加载编码失败
+type encodingFailure struct {
+	err error
+	at  time.Time
+}
+
 // NewTiktokenTokenizer 创建tiktoken计数器
 func NewTiktokenTokenizer() (*TiktokenTokenizer, error) {
 	return &TiktokenTokenizer{
 		encoders: make(map[string]*tiktoken.Tiktoken),
+		failures: make(map[string]encodingFailure),
 	}, nil
 }
 
@@ -228,10 +240,15 @@ func (t *TiktokenTokenizer) countToolTokens(ctx context.Context, tools []Tool, m
 	return tokens + len(tools)*10, nil
 }
 
-// getEncoder 获取或创建编码器
+// getEncoder 获取或创建编码器，编码由注册表决定，未注册的模型使用 cl100k_base
 func (t *TiktokenTokenizer) getEncoder(model string) (*tiktoken.Tiktoken, error) {
+	encoding := EncodingForModel(model)
+	if encoding == "" {
+		encoding = EncodingCl100kBase
+	}
+
 	t.mu.RLock()
-	encoder, exists := t.encoders[model]
+	encoder, exists := t.encoders[encoding]
 	t.mu.RUnlock()
 
 	if exists {
@@ -243,26 +260,22 @@ func (t *TiktokenTokenizer) getEncoder(model string) (*tiktoken.Tiktoken, error)
 	defer t.mu.Unlock()
 
 	// 双重检查
-	if encoder, exists := t.encoders[model]; exists {
+	if encoder, exists := t.encoders[encoding]; exists {
 		return encoder, nil
 	}
-
-	// 根据模型名称选择编码器
-	var enc *tiktoken.Tiktoken
-	var err error
-
-	if strings.HasPrefix(model, "gpt-4") || strings.HasPrefix(model, "gpt-3.5") {
-		enc, err = tiktoken.EncodingForModel(model)
-	} else {
-		// 默认使用cl100k_base编码（适用于大多数新模型）
-		enc, err = tiktoken.GetEncoding("cl100k_base")
+	if failure, ok := t.failures[encoding]; ok && time.Since(failure.at) < encodingRetryInterval {
+		return nil, failure.err
 	}
 
+	enc, err := tiktoken.GetEncoding(encoding)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get encoding for model %s: %w", model, err)
+		err = fmt.Errorf("failed to get encoding %s for model %s: %w", encoding, model, err)
+		t.failures[encoding] = encodingFailure{err: err, at: time.Now()}
+		return nil, err
 	}
 
-	t.encoders[model] = enc
+	delete(t.failures, encoding)
+	t.encoders[encoding] = enc
 	return enc, nil
 }
 
@@ -273,5 +286,6 @@ func (t *TiktokenTokenizer) Close() error {
 
 	// tiktoken的encoder不需要显式关闭
 	t.encoders = make(map[string]*tiktoken.Tiktoken)
+	t.failures = make(map[string]encodingFailure)
 	return nil
 }
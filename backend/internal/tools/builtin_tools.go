@@ -321,6 +321,136 @@ func (hrt *HTTPRequestTool) GetTool() *Tool {
 	}
 }
 
+// FileOperationTool 文件操作工具（仅允许访问基础目录内的文件）
+type FileOperationTool struct {
+	// 基础目录
+	baseDir string
+
+	// 最大文件大小
+	maxFileSize int64
+}
+
+// NewFileOperationTool 创建文件操作工具
+func NewFileOperationTool(baseDir string) *FileOperationTool {
+	return &FileOperationTool{
+		baseDir:     filepath.Clean(baseDir),
+		maxFileSize: 1024 * 100, // 100KB
+	}
+}
+
+// Execute 执行文件操作
+func (fot *FileOperationTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	operation, ok := args["operation"].(string)
+	if !ok || operation == "" {
+		return nil, fmt.Errorf("operation parameter is required")
+	}
+
+	path, ok := args["path"].(string)
+	if !ok || path == "" {
+		return nil, fmt.Errorf("path parameter is required")
+	}
+
+	fullPath, err := fot.resolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation {
+	case "read":
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat file: %v", err)
+		}
+		if info.Size() > fot.maxFileSize {
+			return nil, fmt.Errorf("file too large: %d bytes", info.Size())
+		}
+		data, err := os.ReadFile(fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %v", err)
+		}
+		return map[string]interface{}{
+			"path":    path,
+			"content": string(data),
+			"size":    len(data),
+		}, nil
+
+	case "write":
+		content, _ := args["content"].(string)
+		if int64(len(content)) > fot.maxFileSize {
+			return nil, fmt.Errorf("content too large: %d bytes", len(content))
+		}
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write file: %v", err)
+		}
+		return map[string]interface{}{
+			"path": path,
+			"size": len(content),
+		}, nil
+
+	case "list":
+		entries, err := os.ReadDir(fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list directory: %v", err)
+		}
+		files := make([]map[string]interface{}, 0, len(entries))
+		for _, entry := range entries {
+			files = append(files, map[string]interface{}{
+				"name":   entry.Name(),
+				"is_dir": entry.IsDir(),
+			})
+		}
+		return map[string]interface{}{
+			"path":  path,
+			"files": files,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported operation: %s", operation)
+	}
+}
+
+// resolvePath 解析路径并确保其位于基础目录内
+func (fot *FileOperationTool) resolvePath(path string) (string, error) {
+	fullPath := filepath.Join(fot.baseDir, filepath.Clean("/"+path))
+	rel, err := filepath.Rel(fot.baseDir, fullPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path outside of base directory: %s", path)
+	}
+	return fullPath, nil
+}
+
+// GetTool 获取工具定义
+func (fot *FileOperationTool) GetTool() *Tool {
+	return &Tool{
+		Name:        "file_operation",
+		Description: "Read, write and list files in the sandbox directory",
+		Parameters: &JSONSchema{
+			Type: "object",
+			Properties: map[string]*JSONSchema{
+				"operation": {
+					Type:        "string",
+					Description: "Operation to perform (read, write, list)",
+					Enum:        []interface{}{"read", "write", "list"},
+				},
+				"path": {
+					Type:        "string",
+					Description: "File path relative to the sandbox directory",
+				},
+				"content": {
+					Type:        "string",
+					Description: "Content to write (write only)",
+				},
+			},
+			Required: []string{"operation", "path"},
+		},
+		Handler: fot.Execute,
+		Timeout: 10 * time.Second,
+	}
+}
+
 // BuiltinToolsFactory 内置工具工厂
 type BuiltinToolsFactory struct {
 	engine *FunctionEngine
@@ -120,8 +120,8 @@ func TestBuiltinToolsFactory(t *testing.T) {
 	}
 
 	tools := engine.ListTools()
-	if len(tools) != 3 {
-		t.Errorf("Expected 3 tools, got %d", len(tools))
+	if len(tools) != 4 {
+		t.Errorf("Expected 4 tools, got %d", len(tools))
 	}
 
 	// Verify tool names
@@ -130,7 +130,7 @@ func TestBuiltinToolsFactory(t *testing.T) {
 		toolNames[tool.Name] = true
 	}
 
-	expectedTools := []string{"web_search", "code_executor", "http_request"}
+	expectedTools := []string{"web_search", "code_executor", "file_operation", "http_request"}
 	for _, name := range expectedTools {
 		if !toolNames[name] {
 			t.Errorf("Expected tool %s not found", name)
@@ -138,6 +138,47 @@ func TestBuiltinToolsFactory(t *testing.T) {
 	}
 }
 
+func TestFileOperationTool(t *testing.T) {
+	tool := NewFileOperationTool(t.TempDir())
+	ctx := context.Background()
+
+	_, err := tool.Execute(ctx, map[string]interface{}{
+		"operation": "write",
+		"path":      "notes/hello.txt",
+		"content":   "hello",
+	})
+	if err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	result, err := tool.Execute(ctx, map[string]interface{}{
+		"operation": "read",
+		"path":      "notes/hello.txt",
+	})
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	content := result.(map[string]interface{})["content"]
+	if content != "hello" {
+		t.Errorf("Expected content hello, got %v", content)
+	}
+}
+
+func TestFileOperationToolPathEscape(t *testing.T) {
+	baseDir := t.TempDir()
+	tool := NewFileOperationTool(baseDir)
+
+	fullPath, err := tool.resolvePath("../../etc/passwd")
+	if err != nil {
+		t.Fatalf("resolvePath failed: %v", err)
+	}
+
+	if fullPath != baseDir+"/etc/passwd" {
+		t.Errorf("Expected path to stay inside base dir, got %s", fullPath)
+	}
+}
+
 func TestCodeExecutorToolGetTool(t *testing.T) {
 	tool := NewCodeExecutorTool()
 	toolDef := tool.GetTool()
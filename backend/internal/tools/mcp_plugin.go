@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os/exec"
+	"regexp"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -77,46 +79,119 @@ type MCPPluginConfig struct {
 
 	// 自动恢复
 	AutoRestart bool
+
+	// 工具列表刷新间隔（http 模式），0 表示只在启动时获取
+	ToolRefreshInterval time.Duration
 }
 
 // MCPPluginStatus 插件状态
 type MCPPluginStatus struct {
 	// 插件名称
-	Name string
+	Name string `json:"name"`
 
 	// 状态
-	State string // "running", "stopped", "error", "crashed"
+	State string `json:"state"` // "running", "stopped", "error", "crashed"
 
 	// 错误信息
-	Error string
+	Error string `json:"error,omitempty"`
 
 	// 启动时间
-	StartedAt time.Time
+	StartedAt time.Time `json:"started_at"`
 
 	// 最后心跳
-	LastHeartbeat time.Time
+	LastHeartbeat time.Time `json:"last_heartbeat"`
 
 	// 工具数
-	ToolCount int
+	ToolCount int `json:"tool_count"`
 
 	// 调用统计
-	TotalCalls int64
-	SuccessCalls int64
-	FailedCalls int64
+	TotalCalls int64 `json:"total_calls"`
+	SuccessCalls int64 `json:"success_calls"`
+	FailedCalls int64 `json:"failed_calls"`
+
+	// 自动重启次数
+	RestartCount int64 `json:"restart_count"`
+
+	// 最后重启时间
+	LastRestartAt time.Time `json:"last_restart_at"`
 }
 
+// mcpToolNamePattern 工具名需满足 OpenAI function name 的约束
+var mcpToolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// validateMCPTool 校验插件声明的工具定义
+func validateMCPTool(tool *MCPTool) error {
+	if !mcpToolNamePattern.MatchString(tool.Name) {
+		return fmt.Errorf("invalid tool name %q", tool.Name)
+	}
+	if tool.Parameters == nil {
+		return nil
+	}
+
+	schema, ok := tool.Parameters.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("tool %s: parameters must be a JSON object", tool.Name)
+	}
+	if t, exists := schema["type"]; exists && t != "object" {
+		return fmt.Errorf("tool %s: parameters type must be object, got %v", tool.Name, t)
+	}
+	if props, exists := schema["properties"]; exists {
+		if _, ok := props.(map[string]interface{}); !ok {
+			return fmt.Errorf("tool %s: properties must be a JSON object", tool.Name)
+		}
+	}
+	return nil
+}
+
+// maxMCPMessageSize 单条 MCP 消息（一行 JSON）的最大字节数
+const maxMCPMessageSize = 4 * 1024 * 1024
+
+const (
+	// defaultMCPMaxRestarts MaxRetries 未配置时连续自动重启的最大次数
+	defaultMCPMaxRestarts = 3
+
+	// mcpRestartBackoff 第一次自动重启前的等待时间，之后每次翻倍
+	mcpRestartBackoff = time.Second
+
+	// mcpMaxRestartBackoff 自动重启等待时间上限
+	mcpMaxRestartBackoff = 30 * time.Second
+
+	// mcpStableUptime 进程运行超过该时间后再崩溃，重新计算连续重启次数
+	mcpStableUptime = time.Minute
+
+	// mcpHeartbeatInterval stdio 插件心跳间隔
+	mcpHeartbeatInterval = 30 * time.Second
+)
+
 // StdioMCPPlugin stdio 模式 MCP 插件
+//
+// stdout 只由一个读取 goroutine 读取，工具调用的响应按消息 ID 分发给等待中的调用；
+// 进程退出时所有等待中的调用立即返回错误。
+//
+// 插件进程的生命周期由插件自己的 context 管理，与调用 Start 的请求无关；
+// 开启 AutoRestart 时进程崩溃后按指数退避重新创建进程和管道。
 type StdioMCPPlugin struct {
-	config     *MCPPluginConfig
-	cmd        *exec.Cmd
-	stdin      io.WriteCloser
-	stdout     io.ReadCloser
-	status     MCPPluginStatus
-	tools      map[string]*MCPTool
-	toolsMu    sync.RWMutex
-	running    int32
-	exitChan   chan error
-	logFunc    func(level, msg string, args ...interface{})
+	config    *MCPPluginConfig
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	stdout    io.ReadCloser
+	cancel    context.CancelFunc // 结束插件生命周期（受 writeMu 保护）
+	writeMu   sync.Mutex
+	status    MCPPluginStatus
+	tools     map[string]*MCPTool
+	toolsMu   sync.RWMutex
+	running   int32
+	callSeq   uint64
+	restarts  int // 连续自动重启次数，只在 waitForExit 中访问
+	pending   map[string]chan *MCPMessage
+	pendingMu sync.Mutex
+	exitErr   error // 进程退出后设置，之后的调用直接失败（受 pendingMu 保护）
+	exitChan  chan error
+	logFunc   func(level, msg string, args ...interface{})
+
+	heartbeatInterval time.Duration
+	restartBackoff    time.Duration
+	stableUptime      time.Duration
 }
 
 // NewStdioMCPPlugin 创建 stdio 模式插件
@@ -124,13 +199,17 @@ func NewStdioMCPPlugin(config *MCPPluginConfig) *StdioMCPPlugin {
 	return &StdioMCPPlugin{
 		config:   config,
 		tools:    make(map[string]*MCPTool),
+		pending:  make(map[string]chan *MCPMessage),
 		exitChan: make(chan error, 1),
 		status: MCPPluginStatus{
 			Name:      config.Name,
 			State:     "stopped",
 			StartedAt: time.Now(),
 		},
-		logFunc: defaultLogFuncMCP,
+		logFunc:           defaultLogFuncMCP,
+		heartbeatInterval: mcpHeartbeatInterval,
+		restartBackoff:    mcpRestartBackoff,
+		stableUptime:      mcpStableUptime,
 	}
 }
 
@@ -140,69 +219,111 @@ func defaultLogFuncMCP(level, msg string, args ...interface{}) {
 }
 
 // Start 启动插件
+//
+// ctx 只用于启动阶段，插件进程在 Stop 之前一直运行，不随 ctx 取消而退出。
 func (smp *StdioMCPPlugin) Start(ctx context.Context) error {
-	if atomic.LoadInt32(&smp.running) == 1 {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !atomic.CompareAndSwapInt32(&smp.running, 0, 1) {
 		return fmt.Errorf("plugin already running")
 	}
 
+	lifeCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	smp.restarts = 0
+
+	if err := smp.spawn(lifeCtx); err != nil {
+		cancel()
+		atomic.StoreInt32(&smp.running, 0)
+		smp.updateStatus(func(status *MCPPluginStatus) {
+			status.State = "error"
+			status.Error = err.Error()
+		})
+		return err
+	}
+
+	smp.writeMu.Lock()
+	smp.cancel = cancel
+	smp.writeMu.Unlock()
+
+	smp.updateStatus(func(status *MCPPluginStatus) {
+		status.StartedAt = time.Now()
+	})
+
+	smp.logFunc("info", fmt.Sprintf("Started plugin: %s", smp.config.Name))
+
+	// 启动心跳检查
+	go smp.healthCheck(lifeCtx)
+
+	return nil
+}
+
+// spawn 创建插件进程和新的 stdin/stdout 管道，并启动读取与退出监听 goroutine
+func (smp *StdioMCPPlugin) spawn(ctx context.Context) error {
 	// 创建命令
-	smp.cmd = exec.CommandContext(ctx, smp.config.Command, smp.config.Args...)
+	cmd := exec.CommandContext(ctx, smp.config.Command, smp.config.Args...)
 
 	// 获取 stdin/stdout
-	stdin, err := smp.cmd.StdinPipe()
+	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return fmt.Errorf("failed to get stdin pipe: %v", err)
 	}
 
-	stdout, err := smp.cmd.StdoutPipe()
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return fmt.Errorf("failed to get stdout pipe: %v", err)
 	}
 
-	smp.stdin = stdin
-	smp.stdout = stdout
-
 	// 启动进程
-	if err := smp.cmd.Start(); err != nil {
+	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start plugin: %v", err)
 	}
 
-	atomic.StoreInt32(&smp.running, 1)
-	smp.status.State = "running"
-	smp.status.StartedAt = time.Now()
-	smp.status.LastHeartbeat = time.Now()
-
-	smp.logFunc("info", fmt.Sprintf("Started plugin: %s", smp.config.Name))
+	smp.writeMu.Lock()
+	smp.cmd = cmd
+	smp.stdin = stdin
+	smp.stdout = stdout
+	smp.writeMu.Unlock()
 
-	// 后台监听输出
-	go smp.readOutput()
+	smp.pendingMu.Lock()
+	smp.exitErr = nil
+	smp.pendingMu.Unlock()
 
-	// 后台监听进程退出
-	go smp.waitForExit()
+	smp.updateStatus(func(status *MCPPluginStatus) {
+		status.State = "running"
+		status.Error = ""
+		status.LastHeartbeat = time.Now()
+	})
 
-	// 启动心跳检查
-	go smp.healthCheck(ctx)
+	// 后台读取输出，读到 EOF 后等待进程退出
+	readDone := make(chan struct{})
+	go smp.readOutput(stdout, readDone)
+	go smp.waitForExit(ctx, cmd, readDone, time.Now())
 
 	return nil
 }
 
 // Stop 停止插件
 func (smp *StdioMCPPlugin) Stop() error {
-	if atomic.LoadInt32(&smp.running) == 0 {
+	if !atomic.CompareAndSwapInt32(&smp.running, 1, 0) {
 		return fmt.Errorf("plugin not running")
 	}
 
-	atomic.StoreInt32(&smp.running, 0)
-
+	smp.writeMu.Lock()
+	if smp.cancel != nil {
+		smp.cancel()
+	}
 	if smp.stdin != nil {
 		smp.stdin.Close()
 	}
-
 	if smp.cmd != nil && smp.cmd.Process != nil {
 		smp.cmd.Process.Kill()
 	}
+	smp.writeMu.Unlock()
 
-	smp.status.State = "stopped"
+	smp.updateStatus(func(status *MCPPluginStatus) {
+		status.State = "stopped"
+	})
 
 	smp.logFunc("info", fmt.Sprintf("Stopped plugin: %s", smp.config.Name))
 
@@ -218,32 +339,31 @@ func (smp *StdioMCPPlugin) Call(ctx context.Context, toolName string, args map[s
 	// 构建请求
 	msg := MCPMessage{
 		Type: "tool_call",
-		ID:   fmt.Sprintf("call-%d", time.Now().UnixNano()),
+		ID:   fmt.Sprintf("call-%d", atomic.AddUint64(&smp.callSeq, 1)),
 		ToolCall: &MCPToolCall{
 			ToolName:  toolName,
 			Arguments: args,
 		},
 	}
 
-	// 发送请求
-	data, err := json.Marshal(msg)
+	// 先登记再发送，避免响应先于登记到达
+	responseChan, err := smp.addPending(msg.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal message: %v", err)
+		atomic.AddInt64(&smp.status.FailedCalls, 1)
+		return nil, err
 	}
+	defer smp.removePending(msg.ID)
 
-	if _, err := smp.stdin.Write(append(data, '\n')); err != nil {
+	if err := smp.send(&msg); err != nil {
+		atomic.AddInt64(&smp.status.FailedCalls, 1)
 		return nil, fmt.Errorf("failed to send request: %v", err)
 	}
 
-	// 等待响应
-	responseChan := make(chan *MCPMessage, 1)
-	go smp.readResponse(msg.ID, responseChan)
-
 	select {
 	case response := <-responseChan:
 		if response.Error != "" {
 			atomic.AddInt64(&smp.status.FailedCalls, 1)
-			return nil, fmt.Errorf(response.Error)
+			return nil, errors.New(response.Error)
 		}
 		atomic.AddInt64(&smp.status.SuccessCalls, 1)
 		return response.Result, nil
@@ -254,6 +374,67 @@ func (smp *StdioMCPPlugin) Call(ctx context.Context, toolName string, args map[s
 	}
 }
 
+// send 向插件写入一条消息，多个调用并发写入时逐条写出
+func (smp *StdioMCPPlugin) send(msg *MCPMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+
+	smp.writeMu.Lock()
+	defer smp.writeMu.Unlock()
+
+	_, err = smp.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// addPending 登记等待响应的调用，进程已退出时返回错误
+func (smp *StdioMCPPlugin) addPending(id string) (chan *MCPMessage, error) {
+	smp.pendingMu.Lock()
+	defer smp.pendingMu.Unlock()
+
+	if smp.exitErr != nil {
+		return nil, smp.exitErr
+	}
+
+	ch := make(chan *MCPMessage, 1)
+	smp.pending[id] = ch
+	return ch, nil
+}
+
+// removePending 取消登记
+func (smp *StdioMCPPlugin) removePending(id string) {
+	smp.pendingMu.Lock()
+	delete(smp.pending, id)
+	smp.pendingMu.Unlock()
+}
+
+// dispatch 将响应交给对应的调用，没有等待者（如已超时）时丢弃
+func (smp *StdioMCPPlugin) dispatch(msg *MCPMessage) {
+	smp.pendingMu.Lock()
+	ch, ok := smp.pending[msg.ID]
+	delete(smp.pending, msg.ID)
+	smp.pendingMu.Unlock()
+
+	if !ok {
+		smp.logFunc("warn", fmt.Sprintf("Dropped response for unknown call %s", msg.ID))
+		return
+	}
+	ch <- msg
+}
+
+// failPending 进程退出时让所有等待中的调用返回错误
+func (smp *StdioMCPPlugin) failPending(err error) {
+	smp.pendingMu.Lock()
+	defer smp.pendingMu.Unlock()
+
+	smp.exitErr = err
+	for id, ch := range smp.pending {
+		ch <- &MCPMessage{ID: id, Error: err.Error()}
+		delete(smp.pending, id)
+	}
+}
+
 // GetTools 获取工具列表
 func (smp *StdioMCPPlugin) GetTools() []*MCPTool {
 	smp.toolsMu.RLock()
@@ -272,15 +453,29 @@ func (smp *StdioMCPPlugin) GetStatus() MCPPluginStatus {
 	smp.toolsMu.RLock()
 	defer smp.toolsMu.RUnlock()
 
-	smp.status.ToolCount = len(smp.tools)
-	smp.status.TotalCalls = smp.status.SuccessCalls + smp.status.FailedCalls
+	status := smp.status
+	status.ToolCount = len(smp.tools)
+	status.SuccessCalls = atomic.LoadInt64(&smp.status.SuccessCalls)
+	status.FailedCalls = atomic.LoadInt64(&smp.status.FailedCalls)
+	status.TotalCalls = status.SuccessCalls + status.FailedCalls
 
-	return smp.status
+	return status
 }
 
-// readOutput 读取输出
-func (smp *StdioMCPPlugin) readOutput() {
-	scanner := bufio.NewScanner(smp.stdout)
+// updateStatus 修改插件状态，与 GetStatus 共用 toolsMu
+func (smp *StdioMCPPlugin) updateStatus(fn func(status *MCPPluginStatus)) {
+	smp.toolsMu.Lock()
+	defer smp.toolsMu.Unlock()
+
+	fn(&smp.status)
+}
+
+// readOutput 读取插件输出，是 stdout 唯一的读取者
+func (smp *StdioMCPPlugin) readOutput(stdout io.Reader, done chan<- struct{}) {
+	defer close(done)
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMCPMessageSize)
 
 	for scanner.Scan() {
 		line := scanner.Bytes()
@@ -294,12 +489,22 @@ func (smp *StdioMCPPlugin) readOutput() {
 		// 处理不同类型的消息
 		switch msg.Type {
 		case "tool_definition":
+			if msg.Tool == nil {
+				continue
+			}
 			smp.toolsMu.Lock()
 			smp.tools[msg.Tool.Name] = msg.Tool
 			smp.toolsMu.Unlock()
 
 		case "heartbeat":
-			smp.status.LastHeartbeat = time.Now()
+			smp.updateStatus(func(status *MCPPluginStatus) {
+				status.LastHeartbeat = time.Now()
+			})
+
+		default:
+			if msg.ID != "" {
+				smp.dispatch(&msg)
+			}
 		}
 	}
 
@@ -308,52 +513,129 @@ func (smp *StdioMCPPlugin) readOutput() {
 	}
 }
 
-// readResponse 读取响应
-func (smp *StdioMCPPlugin) readResponse(callID string, responseChan chan<- *MCPMessage) {
-	scanner := bufio.NewScanner(smp.stdout)
+// waitForExit 输出读取结束后等待进程退出，让等待中的调用失败，并按需自动重启
+func (smp *StdioMCPPlugin) waitForExit(ctx context.Context, cmd *exec.Cmd, readDone <-chan struct{}, startedAt time.Time) {
+	// exec.Cmd 要求读完管道后再调用 Wait
+	<-readDone
+	err := cmd.Wait()
 
-	for scanner.Scan() {
-		var msg MCPMessage
-		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
-			continue
+	if err != nil {
+		smp.failPending(fmt.Errorf("plugin %s exited: %v", smp.config.Name, err))
+	} else {
+		smp.failPending(fmt.Errorf("plugin %s exited", smp.config.Name))
+	}
+
+	select {
+	case smp.exitChan <- err:
+	default:
+	}
+
+	// 主动停止
+	if ctx.Err() != nil || atomic.LoadInt32(&smp.running) == 0 {
+		return
+	}
+
+	if err == nil {
+		smp.shutdown("stopped", "")
+		return
+	}
+
+	smp.logFunc("error", fmt.Sprintf("Plugin crashed: %v", err))
+	if !smp.config.AutoRestart {
+		smp.shutdown("crashed", err.Error())
+		return
+	}
+
+	// 自动恢复
+	if time.Since(startedAt) >= smp.stableUptime {
+		smp.restarts = 0
+	}
+	smp.restart(ctx, err)
+}
+
+// restart 按指数退避重新创建插件进程，连续失败超过 MaxRetries 次后放弃
+func (smp *StdioMCPPlugin) restart(ctx context.Context, cause error) {
+	maxRestarts := smp.config.MaxRetries
+	if maxRestarts <= 0 {
+		maxRestarts = defaultMCPMaxRestarts
+	}
+
+	smp.updateStatus(func(status *MCPPluginStatus) {
+		status.State = "crashed"
+		status.Error = cause.Error()
+	})
+
+	for {
+		if smp.restarts >= maxRestarts {
+			smp.logFunc("error", fmt.Sprintf("Plugin %s gave up after %d restarts", smp.config.Name, smp.restarts))
+			smp.shutdown("crashed", fmt.Sprintf("gave up after %d restarts: %v", smp.restarts, cause))
+			return
+		}
+
+		backoff := smp.restartBackoff << smp.restarts
+		if backoff > mcpMaxRestartBackoff || backoff <= 0 {
+			backoff = mcpMaxRestartBackoff
 		}
+		smp.restarts++
+		attempt := smp.restarts
 
-		if msg.ID == callID {
-			responseChan <- &msg
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
 			return
 		}
+
+		smp.updateStatus(func(status *MCPPluginStatus) {
+			status.RestartCount++
+			status.LastRestartAt = time.Now()
+		})
+
+		// spawn 成功后 restarts 归新进程的 waitForExit 所有，这里不再访问
+		if err := smp.spawn(ctx); err != nil {
+			smp.logFunc("error", fmt.Sprintf("Failed to restart plugin: %v", err))
+			cause = err
+			continue
+		}
+
+		smp.logFunc("info", fmt.Sprintf("Restarted plugin: %s (attempt %d)", smp.config.Name, attempt))
+		return
 	}
 }
 
-// waitForExit 等待进程退出
-func (smp *StdioMCPPlugin) waitForExit() {
-	if err := smp.cmd.Wait(); err != nil {
-		smp.status.State = "crashed"
-		smp.status.Error = err.Error()
-		smp.logFunc("error", fmt.Sprintf("Plugin crashed: %v", err))
+// shutdown 进程退出且不再重启时结束插件生命周期
+func (smp *StdioMCPPlugin) shutdown(state, errMsg string) {
+	if !atomic.CompareAndSwapInt32(&smp.running, 1, 0) {
+		return
+	}
 
-		// 自动恢复
-		if smp.config.AutoRestart {
-			time.Sleep(time.Second)
-			if err := smp.Start(context.Background()); err != nil {
-				smp.logFunc("error", fmt.Sprintf("Failed to restart plugin: %v", err))
-			}
-		}
-	} else {
-		smp.status.State = "stopped"
+	smp.writeMu.Lock()
+	if smp.cancel != nil {
+		smp.cancel()
 	}
+	smp.writeMu.Unlock()
 
-	atomic.StoreInt32(&smp.running, 0)
+	smp.updateStatus(func(status *MCPPluginStatus) {
+		status.State = state
+		if errMsg != "" {
+			status.Error = errMsg
+		}
+	})
 }
 
 // healthCheck 健康检查
 func (smp *StdioMCPPlugin) healthCheck(ctx context.Context) {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(smp.heartbeatInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
+			if atomic.LoadInt32(&smp.running) == 0 {
+				return
+			}
+
 			// 发送心跳
 			msg := MCPMessage{
 				Type:   "heartbeat",
@@ -361,20 +643,17 @@ func (smp *StdioMCPPlugin) healthCheck(ctx context.Context) {
 				Status: "ping",
 			}
 
-			data, err := json.Marshal(msg)
-			if err != nil {
-				continue
-			}
-
-			if _, err := smp.stdin.Write(append(data, '\n')); err != nil {
+			if err := smp.send(&msg); err != nil {
 				smp.logFunc("warn", "Heartbeat failed, plugin may be unresponsive")
 			}
 
 			// 检查最后心跳时间
-			if time.Since(smp.status.LastHeartbeat) > 2*time.Minute {
-				smp.logFunc("error", "Plugin heartbeat timeout")
-				smp.status.State = "error"
-			}
+			smp.updateStatus(func(status *MCPPluginStatus) {
+				if status.State == "running" && time.Since(status.LastHeartbeat) > 4*smp.heartbeatInterval {
+					smp.logFunc("error", "Plugin heartbeat timeout")
+					status.State = "error"
+				}
+			})
 
 		case <-ctx.Done():
 			return
@@ -431,12 +710,88 @@ func (hmp *HTTPMCPPlugin) Start(ctx context.Context) error {
 
 	hmp.logFunc("info", fmt.Sprintf("Connected to HTTP plugin: %s", hmp.config.Name))
 
+	// 获取工具列表，失败时保留空列表，等待下次刷新
+	if _, err := hmp.ForceRefreshTools(ctx); err != nil {
+		hmp.logFunc("warn", fmt.Sprintf("Failed to fetch tools from plugin %s: %v", hmp.config.Name, err))
+	}
+
 	// 启动健康检查
 	go hmp.healthCheck(ctx)
 
+	if hmp.config.ToolRefreshInterval > 0 {
+		go hmp.refreshTools(ctx)
+	}
+
 	return nil
 }
 
+// ForceRefreshTools 立即从 {URL}/tools 重新获取工具列表，返回加载的工具数
+//
+// 未通过校验的工具会被跳过并记录警告，不影响其余工具。
+func (hmp *HTTPMCPPlugin) ForceRefreshTools(ctx context.Context) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", hmp.config.URL+"/tools", nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := hmp.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("plugin returned status %d", resp.StatusCode)
+	}
+
+	var manifest []json.RawMessage
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxMCPMessageSize)).Decode(&manifest); err != nil {
+		return 0, fmt.Errorf("failed to decode tool manifest: %v", err)
+	}
+
+	tools := make(map[string]*MCPTool, len(manifest))
+	for i, raw := range manifest {
+		var tool MCPTool
+		if err := json.Unmarshal(raw, &tool); err != nil {
+			hmp.logFunc("warn", fmt.Sprintf("Skipping tool #%d from plugin %s: %v", i, hmp.config.Name, err))
+			continue
+		}
+		if err := validateMCPTool(&tool); err != nil {
+			hmp.logFunc("warn", fmt.Sprintf("Skipping tool #%d from plugin %s: %v", i, hmp.config.Name, err))
+			continue
+		}
+		if _, exists := tools[tool.Name]; exists {
+			hmp.logFunc("warn", fmt.Sprintf("Skipping duplicate tool %s from plugin %s", tool.Name, hmp.config.Name))
+			continue
+		}
+		tools[tool.Name] = &tool
+	}
+
+	hmp.toolsMu.Lock()
+	hmp.tools = tools
+	hmp.toolsMu.Unlock()
+
+	return len(tools), nil
+}
+
+// refreshTools 按 ToolRefreshInterval 定期刷新工具列表
+func (hmp *HTTPMCPPlugin) refreshTools(ctx context.Context) {
+	ticker := time.NewTicker(hmp.config.ToolRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := hmp.ForceRefreshTools(ctx); err != nil {
+				hmp.logFunc("warn", fmt.Sprintf("Failed to refresh tools from plugin %s: %v", hmp.config.Name, err))
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // Stop 停止插件
 func (hmp *HTTPMCPPlugin) Stop() error {
 	hmp.status.State = "stopped"
@@ -482,7 +837,7 @@ func (hmp *HTTPMCPPlugin) Call(ctx context.Context, toolName string, args map[st
 
 	if response.Error != "" {
 		atomic.AddInt64(&hmp.status.FailedCalls, 1)
-		return nil, fmt.Errorf(response.Error)
+		return nil, errors.New(response.Error)
 	}
 
 	atomic.AddInt64(&hmp.status.SuccessCalls, 1)
@@ -507,10 +862,13 @@ func (hmp *HTTPMCPPlugin) GetStatus() MCPPluginStatus {
 	hmp.toolsMu.RLock()
 	defer hmp.toolsMu.RUnlock()
 
-	hmp.status.ToolCount = len(hmp.tools)
-	hmp.status.TotalCalls = hmp.status.SuccessCalls + hmp.status.FailedCalls
+	status := hmp.status
+	status.ToolCount = len(hmp.tools)
+	status.SuccessCalls = atomic.LoadInt64(&hmp.status.SuccessCalls)
+	status.FailedCalls = atomic.LoadInt64(&hmp.status.FailedCalls)
+	status.TotalCalls = status.SuccessCalls + status.FailedCalls
 
-	return hmp.status
+	return status
 }
 
 // healthCheck 健康检查
@@ -2,6 +2,9 @@ package tools
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -356,3 +359,114 @@ func TestMCPPluginManagerCallToolNotFound(t *testing.T) {
 	}
 }
 
+// toolManifestServer 提供 /health 与 /tools 的假 HTTP 插件，manifest 可在运行中替换
+func toolManifestServer(t *testing.T, manifest *atomic.Value) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/tools":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(manifest.Load().(string)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPMCPPluginDiscoversTools(t *testing.T) {
+	var manifest atomic.Value
+	manifest.Store(`[
+		{"name": "search", "description": "Search the web", "parameters": {"type": "object", "properties": {"q": {"type": "string"}}}},
+		{"name": "no_params", "description": "Takes no arguments"},
+		{"name": "bad name!", "description": "Invalid name"},
+		{"name": "bad_schema", "parameters": {"type": "string"}},
+		{"name": "bad_props", "parameters": {"type": "object", "properties": []}},
+		{"name": 42},
+		{"name": "search", "description": "Duplicate"}
+	]`)
+	server := toolManifestServer(t, &manifest)
+
+	plugin := NewHTTPMCPPlugin(&MCPPluginConfig{Name: "http", Mode: "http", URL: server.URL, Timeout: 5 * time.Second})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := plugin.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	tools := plugin.GetTools()
+	if len(tools) != 2 {
+		t.Fatalf("Expected 2 valid tools, got %d", len(tools))
+	}
+	for _, tool := range tools {
+		if tool.Name == "search" && tool.Description != "Search the web" {
+			t.Errorf("Expected first definition of duplicate tool to win, got %q", tool.Description)
+		}
+	}
+	if status := plugin.GetStatus(); status.ToolCount != 2 {
+		t.Errorf("Expected ToolCount 2, got %d", status.ToolCount)
+	}
+
+	// 手动刷新后替换为新的工具列表
+	manifest.Store(`[{"name": "translate"}]`)
+	n, err := plugin.ForceRefreshTools(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 tool after refresh, got %d (%v)", n, err)
+	}
+	if tools := plugin.GetTools(); len(tools) != 1 || tools[0].Name != "translate" {
+		t.Errorf("Expected tool list to be replaced, got %+v", tools)
+	}
+}
+
+func TestHTTPMCPPluginRefreshesToolsPeriodically(t *testing.T) {
+	var manifest atomic.Value
+	manifest.Store(`[]`)
+	server := toolManifestServer(t, &manifest)
+
+	plugin := NewHTTPMCPPlugin(&MCPPluginConfig{
+		Name:                "http",
+		Mode:                "http",
+		URL:                 server.URL,
+		Timeout:             5 * time.Second,
+		ToolRefreshInterval: 20 * time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := plugin.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if status := plugin.GetStatus(); status.ToolCount != 0 {
+		t.Fatalf("Expected no tools initially, got %d", status.ToolCount)
+	}
+
+	manifest.Store(`[{"name": "search"}, {"name": "fetch"}]`)
+	deadline := time.Now().Add(2 * time.Second)
+	for plugin.GetStatus().ToolCount != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected tools to be refreshed periodically")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHTTPMCPPluginRefreshToolsErrors(t *testing.T) {
+	var manifest atomic.Value
+	manifest.Store(`{"not": "an array"}`)
+	server := toolManifestServer(t, &manifest)
+
+	plugin := NewHTTPMCPPlugin(&MCPPluginConfig{Name: "http", Mode: "http", URL: server.URL, Timeout: 5 * time.Second})
+	if _, err := plugin.ForceRefreshTools(context.Background()); err == nil {
+		t.Error("Expected error for malformed manifest")
+	}
+
+	plugin.config.URL = server.URL + "/missing"
+	if _, err := plugin.ForceRefreshTools(context.Background()); err == nil {
+		t.Error("Expected error for non-200 response")
+	}
+}
@@ -0,0 +1,254 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mcpHelperEnv 设置后测试二进制作为假的 MCP 插件进程运行
+const mcpHelperEnv = "GO_WANT_MCP_HELPER_PROCESS"
+
+// mcpHelperCrashEnv 假插件收到心跳时崩溃：值为 always 时每次都崩溃，
+// 否则视为标记文件路径，只在文件不存在时（即第一次运行）创建文件并崩溃
+const mcpHelperCrashEnv = "MCP_HELPER_CRASH_ON_HEARTBEAT"
+
+// TestMCPHelperProcess 假的 stdio MCP 插件：并发处理工具调用，按参数延迟后乱序返回
+func TestMCPHelperProcess(t *testing.T) {
+	if os.Getenv(mcpHelperEnv) != "1" {
+		return
+	}
+
+	var mu sync.Mutex
+	encoder := json.NewEncoder(os.Stdout)
+	write := func(msg *MCPMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		encoder.Encode(msg)
+	}
+
+	write(&MCPMessage{Type: "tool_definition", Tool: &MCPTool{Name: "echo"}})
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var msg MCPMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+
+		if msg.Type == "heartbeat" {
+			if crash := os.Getenv(mcpHelperCrashEnv); crash == "always" {
+				os.Exit(2)
+			} else if crash != "" {
+				if _, err := os.Stat(crash); os.IsNotExist(err) {
+					os.WriteFile(crash, nil, 0o600)
+					os.Exit(2)
+				}
+			}
+			write(&MCPMessage{Type: "heartbeat", ID: msg.ID, Status: "pong"})
+			continue
+		}
+		if msg.Type != "tool_call" {
+			continue
+		}
+
+		if msg.ToolCall.ToolName == "crash" {
+			os.Exit(3)
+		}
+
+		go func(msg MCPMessage) {
+			delay, _ := msg.ToolCall.Arguments["delay_ms"].(float64)
+			time.Sleep(time.Duration(delay) * time.Millisecond)
+			write(&MCPMessage{Type: "tool_result", ID: msg.ID, Result: msg.ToolCall.Arguments["value"]})
+		}(msg)
+	}
+	os.Exit(0)
+}
+
+// newHelperPlugin 创建以测试二进制为进程的 stdio 插件
+func newHelperPlugin(t *testing.T) *StdioMCPPlugin {
+	t.Helper()
+	t.Setenv(mcpHelperEnv, "1")
+
+	return NewStdioMCPPlugin(&MCPPluginConfig{
+		Name:    "helper",
+		Mode:    "stdio",
+		Command: os.Args[0],
+		Args:    []string{"-test.run=^TestMCPHelperProcess$"},
+	})
+}
+
+// startHelperPlugin 启动假插件，测试结束时停止
+func startHelperPlugin(t *testing.T) *StdioMCPPlugin {
+	t.Helper()
+
+	plugin := newHelperPlugin(t)
+	if err := plugin.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start helper plugin: %v", err)
+	}
+	t.Cleanup(func() { plugin.Stop() })
+	return plugin
+}
+
+func TestStdioMCPPluginConcurrentCalls(t *testing.T) {
+	plugin := startHelperPlugin(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 第一个调用更慢，响应顺序与请求顺序相反
+	delays := []int{300, 0}
+	results := make([]interface{}, len(delays))
+	errs := make([]error, len(delays))
+
+	var wg sync.WaitGroup
+	for i, delay := range delays {
+		wg.Add(1)
+		go func(i, delay int) {
+			defer wg.Done()
+			results[i], errs[i] = plugin.Call(ctx, "echo", map[string]interface{}{
+				"value":    fmt.Sprintf("call-%d", i),
+				"delay_ms": delay,
+			})
+		}(i, delay)
+	}
+	wg.Wait()
+
+	for i := range delays {
+		if errs[i] != nil {
+			t.Fatalf("Call %d failed: %v", i, errs[i])
+		}
+		if results[i] != fmt.Sprintf("call-%d", i) {
+			t.Errorf("Call %d got result %v", i, results[i])
+		}
+	}
+
+	if len(plugin.GetTools()) != 1 {
+		t.Errorf("Expected tool definition to be read alongside responses")
+	}
+	if status := plugin.GetStatus(); status.SuccessCalls != 2 {
+		t.Errorf("Expected 2 successful calls, got %d", status.SuccessCalls)
+	}
+}
+
+func TestStdioMCPPluginFailsPendingCallsOnExit(t *testing.T) {
+	plugin := startHelperPlugin(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pending := make(chan error, 1)
+	go func() {
+		_, err := plugin.Call(ctx, "echo", map[string]interface{}{"value": "slow", "delay_ms": 5000})
+		pending <- err
+	}()
+
+	// 等待慢调用发出后再让进程崩溃
+	time.Sleep(100 * time.Millisecond)
+	if _, err := plugin.Call(ctx, "crash", nil); err == nil {
+		t.Error("Expected crash call to fail")
+	}
+
+	select {
+	case err := <-pending:
+		if err == nil || ctx.Err() != nil {
+			t.Errorf("Expected pending call to fail with plugin exit, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Pending call was not released when the plugin exited")
+	}
+
+	if _, err := plugin.Call(context.Background(), "echo", nil); err == nil {
+		t.Error("Expected calls after exit to fail")
+	}
+}
+
+// waitForStatus 等待插件状态满足条件
+func waitForStatus(t *testing.T, plugin *StdioMCPPlugin, desc string, ok func(MCPPluginStatus) bool) MCPPluginStatus {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status := plugin.GetStatus()
+		if ok(status) {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s, last status %+v", desc, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStdioMCPPluginRestartsAfterCrash(t *testing.T) {
+	t.Setenv(mcpHelperCrashEnv, filepath.Join(t.TempDir(), "crashed"))
+
+	plugin := newHelperPlugin(t)
+	plugin.config.AutoRestart = true
+	plugin.config.MaxRetries = 3
+	plugin.heartbeatInterval = 50 * time.Millisecond
+	plugin.restartBackoff = 10 * time.Millisecond
+
+	// 启动用的 ctx 取消后插件仍应存活并能重启
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := plugin.Start(ctx); err != nil {
+		t.Fatalf("Failed to start helper plugin: %v", err)
+	}
+	cancel()
+	t.Cleanup(func() { plugin.Stop() })
+
+	status := waitForStatus(t, plugin, "restart", func(s MCPPluginStatus) bool {
+		return s.RestartCount == 1 && s.State == "running"
+	})
+	if status.LastRestartAt.IsZero() {
+		t.Error("Expected LastRestartAt to be set")
+	}
+
+	callCtx, callCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer callCancel()
+	result, err := plugin.Call(callCtx, "echo", map[string]interface{}{"value": "after restart"})
+	if err != nil || result != "after restart" {
+		t.Fatalf("Expected call on restarted plugin to succeed, got %v (%v)", result, err)
+	}
+
+	// 重启后的进程正常应答心跳，不再重启
+	time.Sleep(200 * time.Millisecond)
+	if status := plugin.GetStatus(); status.RestartCount != 1 || status.State != "running" {
+		t.Errorf("Expected plugin to stay up after recovering, got %+v", status)
+	}
+}
+
+func TestStdioMCPPluginGivesUpAfterMaxRetries(t *testing.T) {
+	t.Setenv(mcpHelperCrashEnv, "always")
+
+	plugin := newHelperPlugin(t)
+	plugin.config.AutoRestart = true
+	plugin.config.MaxRetries = 2
+	plugin.heartbeatInterval = 20 * time.Millisecond
+	plugin.restartBackoff = 5 * time.Millisecond
+
+	if err := plugin.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start helper plugin: %v", err)
+	}
+
+	status := waitForStatus(t, plugin, "give up", func(s MCPPluginStatus) bool {
+		return s.State == "crashed" && atomic.LoadInt32(&plugin.running) == 0
+	})
+	if status.RestartCount != 2 {
+		t.Errorf("Expected 2 restarts before giving up, got %d", status.RestartCount)
+	}
+
+	if _, err := plugin.Call(context.Background(), "echo", nil); err == nil {
+		t.Error("Expected calls to fail after giving up")
+	}
+	if err := plugin.Stop(); err == nil {
+		t.Error("Expected Stop to report plugin not running")
+	}
+}
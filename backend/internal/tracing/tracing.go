@@ -0,0 +1,125 @@
+// Package tracing 基于 OpenTelemetry 的分布式追踪
+//
+// 请求经网关转发到各服务、再由中转发往上游提供方时，通过 W3C traceparent 请求头传递追踪上下文：
+// Middleware 为每个请求创建服务端 span（流式响应在输出结束后才结束），Inject 在转发与调用上游时写入请求头，
+// 业务代码用 Start/End 创建子 span（渠道选择、上游调用、计费）。未启用时只传递追踪上下文，不记录 span。
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shirosoralumie648/Oblivious/backend/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName 本项目创建的 span 所属的 instrumentation scope
+const instrumentationName = "github.com/shirosoralumie648/Oblivious/backend"
+
+// shutdownTimeout 关闭时导出剩余 span 的最长等待时间
+const shutdownTimeout = 5 * time.Second
+
+func init() {
+	// 未调用 Setup（如测试、未接入追踪的服务）时同样传递 traceparent
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Setup 按配置安装全局 TracerProvider，返回关闭时导出剩余 span 的函数（可直接交给 server.OnClose）
+//
+// 采样策略为 ParentBased(TraceIDRatioBased(SampleRate))：请求已携带 traceparent 时沿用上游的采样决定，
+// 否则按比例采样。未启用时不安装 TracerProvider，返回的函数什么也不做。
+func Setup(serviceName string, cfg *config.TracingConfig) (func() error, error) {
+	if !cfg.Enabled {
+		return func() error { return nil }, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if cfg.OTLPEndpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(Sampler(cfg.SampleRate)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return provider.Shutdown(ctx)
+	}, nil
+}
+
+// Sampler 沿用父 span 的采样决定，没有父 span 时按 rate 采样
+func Sampler(rate float64) sdktrace.Sampler {
+	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(rate))
+}
+
+// Start 创建子 span，ctx 中没有 span 时创建根 span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End 结束 span，err 不为 nil 时记录错误并标记为失败
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject 将 ctx 中的追踪上下文写入请求头（traceparent / tracestate / baggage）
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Middleware 从请求头恢复追踪上下文并为请求创建服务端 span，span 以路由模板命名
+//
+// span 在后续处理全部返回后结束，流式响应（SSE、反向代理的流式转发）因此覆盖整个输出过程。
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		name := c.Request.Method
+		if route != "" {
+			name += " " + route
+		}
+		ctx, span := otel.Tracer(instrumentationName).Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+			))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorCode 稳定的错误码，前端按该值区分错误类型，新增错误码后不得修改已有的值
+type ErrorCode string
+
+// 错误码注册表
+const (
+	CodeInvalidRequest          ErrorCode = "INVALID_REQUEST"           // 请求参数错误
+	CodeUnauthorized            ErrorCode = "UNAUTHORIZED"              // 未登录或凭证无效
+	CodeForbidden               ErrorCode = "FORBIDDEN"                 // 无权限访问
+	CodeNotFound                ErrorCode = "NOT_FOUND"                 // 资源不存在
+	CodeConflict                ErrorCode = "CONFLICT"                  // 资源已存在或状态冲突
+	CodeInvalidToken            ErrorCode = "INVALID_TOKEN"             // Token 无效
+	CodeTokenExpired            ErrorCode = "TOKEN_EXPIRED"             // Token 已过期
+	CodeEmailNotVerified        ErrorCode = "EMAIL_NOT_VERIFIED"        // 账号邮箱尚未验证
+	CodeOAuthIdentityConflict   ErrorCode = "OAUTH_IDENTITY_CONFLICT"   // 第三方账号已绑定到其他用户
+	CodeUserSuspended           ErrorCode = "USER_SUSPENDED"            // 账号已被管理员停用
+	CodeQuotaExceeded           ErrorCode = "QUOTA_EXCEEDED"            // 余额或 Token 额度不足
+	CodeModelNotSupported       ErrorCode = "MODEL_NOT_SUPPORTED"       // 没有渠道提供该模型
+	CodeModelNotAllowed         ErrorCode = "MODEL_NOT_ALLOWED"         // 模型不在 Token 的白名单中
+	CodeModelFeatureUnsupported ErrorCode = "MODEL_FEATURE_UNSUPPORTED" // 提供该模型的渠道都不支持请求依赖的功能（如工具调用）
+	CodeChannelUnavailable      ErrorCode = "CHANNEL_UNAVAILABLE"       // 提供该模型的渠道暂时都不可用
+	CodeUpstreamError           ErrorCode = "UPSTREAM_ERROR"            // 上游提供方返回错误
+	CodeInvalidJSONOutput       ErrorCode = "INVALID_JSON_OUTPUT"       // strict_json 请求重试后模型输出仍不是合法的 JSON
+	CodeRateLimited             ErrorCode = "RATE_LIMITED"              // 请求频率超限
+	CodeInternal                ErrorCode = "INTERNAL_ERROR"            // 内部服务器错误
+)
+
+// errorCodeSpec 错误码默认的 HTTP 状态码与兼容的数字错误码
+type errorCodeSpec struct {
+	status int
+	legacy int
+}
+
+var errorCodeSpecs = map[ErrorCode]errorCodeSpec{
+	CodeInvalidRequest:          {http.StatusBadRequest, ErrInvalidRequest},
+	CodeUnauthorized:            {http.StatusUnauthorized, ErrUnauthorized},
+	CodeForbidden:               {http.StatusForbidden, ErrForbidden},
+	CodeNotFound:                {http.StatusNotFound, ErrNotFound},
+	CodeConflict:                {http.StatusConflict, ErrConflict},
+	CodeInvalidToken:            {http.StatusUnauthorized, ErrInvalidToken},
+	CodeTokenExpired:            {http.StatusUnauthorized, ErrTokenExpired},
+	CodeEmailNotVerified:        {http.StatusForbidden, ErrEmailNotVerified},
+	CodeOAuthIdentityConflict:   {http.StatusConflict, ErrOAuthIdentityConflict},
+	CodeUserSuspended:           {http.StatusForbidden, ErrUserSuspended},
+	CodeQuotaExceeded:           {http.StatusPaymentRequired, ErrInsufficientQuota},
+	CodeModelNotSupported:       {http.StatusBadRequest, ErrModelNotAvailable},
+	CodeModelNotAllowed:         {http.StatusForbidden, ErrModelNotAllowed},
+	CodeModelFeatureUnsupported: {http.StatusBadRequest, ErrModelFeatureUnsupported},
+	CodeChannelUnavailable:      {http.StatusServiceUnavailable, ErrChannelUnavailable},
+	CodeUpstreamError:           {http.StatusBadGateway, ErrUpstream},
+	CodeInvalidJSONOutput:       {http.StatusBadGateway, ErrInvalidJSONOutput},
+	CodeRateLimited:             {http.StatusTooManyRequests, ErrRateLimitExceeded},
+	CodeInternal:                {http.StatusInternalServerError, ErrInternal},
+}
+
+// legacyErrorCodes 数字错误码对应的稳定错误码
+var legacyErrorCodes = func() map[int]ErrorCode {
+	codes := make(map[int]ErrorCode, len(errorCodeSpecs))
+	for code, spec := range errorCodeSpecs {
+		codes[spec.legacy] = code
+	}
+	return codes
+}()
+
+// HTTPStatus 错误码默认的 HTTP 状态码
+func (code ErrorCode) HTTPStatus() int {
+	if spec, ok := errorCodeSpecs[code]; ok {
+		return spec.status
+	}
+	return http.StatusInternalServerError
+}
+
+// AppError 带稳定错误码的错误，服务层返回或包装后由 Handler 直接转换为响应
+type AppError struct {
+	Code       ErrorCode
+	HTTPStatus int
+	Message    string
+	Details    interface{}
+	RetryAfter time.Duration // 大于 0 时响应附带 Retry-After 请求头
+	Err        error         // 被包装的原始错误
+}
+
+// NewAppError 创建使用错误码默认 HTTP 状态码的错误，可作为服务层的哨兵错误
+func NewAppError(code ErrorCode, message string) *AppError {
+	return &AppError{Code: code, HTTPStatus: code.HTTPStatus(), Message: message}
+}
+
+// WrapError 为已有错误附加错误码，消息沿用原始错误
+func WrapError(code ErrorCode, err error) *AppError {
+	return &AppError{Code: code, HTTPStatus: code.HTTPStatus(), Message: err.Error(), Err: err}
+}
+
+// Errorf 创建带错误码的错误，格式与 fmt.Errorf 相同（可使用 %w 包装）
+func Errorf(code ErrorCode, format string, args ...interface{}) *AppError {
+	err := fmt.Errorf(format, args...)
+	return &AppError{Code: code, HTTPStatus: code.HTTPStatus(), Message: err.Error(), Err: errors.Unwrap(err)}
+}
+
+// Error 实现 error 接口
+func (e *AppError) Error() string {
+	if e.Message == "" && e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap 返回被包装的原始错误，errors.Is 可继续匹配服务层的哨兵错误
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// WithDetails 返回附带详情的副本，不修改哨兵错误本身
+func (e *AppError) WithDetails(details interface{}) *AppError {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// AsAppError 提取错误链中的 AppError，不存在时按内部错误处理
+func AsAppError(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return WrapError(CodeInternal, err)
+}
+
+// RespondError 按 AppError 返回统一格式的错误响应，消息使用完整的错误链
+func RespondError(c *gin.Context, err error) {
+	appErr := AsAppError(err)
+	c.JSON(appErr.HTTPStatus, Response{
+		Success: false,
+		Error: &ErrorInfo{
+			Code:      errorCodeSpecs[appErr.Code].legacy,
+			ErrorCode: appErr.Code,
+			Message:   err.Error(),
+			Details:   appErr.Details,
+			RequestID: c.GetString("request_id"),
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// OpenAIError OpenAI 格式的错误响应，中转的 OpenAI 兼容接口使用，现有 SDK 可以直接解析并展示
+type OpenAIError struct {
+	Error OpenAIErrorDetail `json:"error"`
+}
+
+// OpenAIErrorDetail 错误描述、类型、相关参数与错误码，Details 为本系统附加的排查信息
+type OpenAIErrorDetail struct {
+	Message string      `json:"message"`
+	Type    string      `json:"type"`
+	Param   *string     `json:"param"`
+	Code    string      `json:"code"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// NewOpenAIError 将错误转换为 OpenAI 格式，code 为小写的稳定错误码（流式响应中作为 error 事件的内容）
+func NewOpenAIError(err error) OpenAIError {
+	appErr := AsAppError(err)
+	detail := OpenAIErrorDetail{
+		Message: err.Error(),
+		Type:    openAIErrorType(appErr),
+		Code:    strings.ToLower(string(appErr.Code)),
+		Details: appErr.Details,
+	}
+	if appErr.Code == CodeModelNotSupported || appErr.Code == CodeModelNotAllowed {
+		param := "model"
+		detail.Param = &param
+	}
+	if appErr.Code == CodeModelFeatureUnsupported {
+		param := "tools"
+		detail.Param = &param
+	}
+	if appErr.Code == CodeInvalidJSONOutput {
+		param := "response_format"
+		detail.Param = &param
+	}
+	return OpenAIError{Error: detail}
+}
+
+// RespondOpenAIError 按 AppError 的状态码返回 OpenAI 格式的错误响应
+func RespondOpenAIError(c *gin.Context, err error) {
+	appErr := AsAppError(err)
+	if appErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+	}
+	c.AbortWithStatusJSON(appErr.HTTPStatus, NewOpenAIError(err))
+}
+
+// openAIErrorType 按错误码与状态码返回 OpenAI 的错误类型
+func openAIErrorType(appErr *AppError) string {
+	switch {
+	case appErr.Code == CodeQuotaExceeded:
+		return "insufficient_quota"
+	case appErr.Code == CodeRateLimited:
+		return "rate_limit_error"
+	case appErr.HTTPStatus >= http.StatusInternalServerError:
+		return "server_error"
+	}
+	return "invalid_request_error"
+}
+
+// errorCodeFor 数字错误码对应的稳定错误码，未登记时按状态码区分
+func errorCodeFor(errCode int, httpStatus int) ErrorCode {
+	if code, ok := legacyErrorCodes[errCode]; ok {
+		return code
+	}
+	if httpStatus < http.StatusInternalServerError {
+		return CodeInvalidRequest
+	}
+	return CodeInternal
+}
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRespondError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	respond := func(err error) (*httptest.ResponseRecorder, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Set("request_id", "req-1")
+		RespondError(c, err)
+
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w, body
+	}
+
+	t.Run("quota exceeded", func(t *testing.T) {
+		base := errors.New("insufficient quota")
+		w, body := respond(WrapError(CodeQuotaExceeded, base).WithDetails(map[string]int{"required": 10}))
+		assert.Equal(t, http.StatusPaymentRequired, w.Code)
+		assert.Equal(t, false, body["success"])
+		assert.Equal(t, map[string]interface{}{
+			"code":       float64(ErrInsufficientQuota),
+			"error_code": "QUOTA_EXCEEDED",
+			"message":    "insufficient quota",
+			"details":    map[string]interface{}{"required": float64(10)},
+			"request_id": "req-1",
+		}, body["error"])
+	})
+
+	t.Run("sentinel wrapped by service keeps code", func(t *testing.T) {
+		sentinel := NewAppError(CodeNotFound, "message not found")
+		err := fmt.Errorf("load message 42: %w", sentinel)
+		assert.ErrorIs(t, err, sentinel)
+
+		w, body := respond(err)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		errInfo := body["error"].(map[string]interface{})
+		assert.Equal(t, "NOT_FOUND", errInfo["error_code"])
+		assert.Equal(t, "load message 42: message not found", errInfo["message"])
+	})
+
+	t.Run("plain error is internal", func(t *testing.T) {
+		w, body := respond(errors.New("boom"))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, "INTERNAL_ERROR", body["error"].(map[string]interface{})["error_code"])
+	})
+
+	t.Run("legacy helpers carry stable codes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		BadRequest(c, "invalid page")
+
+		var resp Response
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, CodeInvalidRequest, resp.Error.ErrorCode)
+	})
+}
+
+func TestRespondOpenAIError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	respond := func(err error) (*httptest.ResponseRecorder, string) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		RespondOpenAIError(c, err)
+		return w, w.Body.String()
+	}
+
+	t.Run("model not supported", func(t *testing.T) {
+		w, body := respond(Errorf(CodeModelNotSupported, "model not supported: %s", "gpt-9"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":{
+			"message":"model not supported: gpt-9",
+			"type":"invalid_request_error",
+			"param":"model",
+			"code":"model_not_supported"
+		}}`, body)
+	})
+
+	t.Run("quota exceeded", func(t *testing.T) {
+		w, body := respond(NewAppError(CodeQuotaExceeded, "insufficient quota"))
+		assert.Equal(t, http.StatusPaymentRequired, w.Code)
+		assert.JSONEq(t, `{"error":{
+			"message":"insufficient quota",
+			"type":"insufficient_quota",
+			"param":null,
+			"code":"quota_exceeded"
+		}}`, body)
+	})
+
+	t.Run("channel unavailable is a server error", func(t *testing.T) {
+		w, body := respond(NewAppError(CodeChannelUnavailable, "no available channel"))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, body, `"type":"server_error"`)
+		assert.Contains(t, body, `"code":"channel_unavailable"`)
+	})
+}
@@ -12,6 +12,7 @@ type Claims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
 	Role     int    `json:"role"`
+	Session  string `json:"sid,omitempty"` // 登录会话的刷新令牌链 ID
 	jwt.RegisteredClaims
 }
 
@@ -23,10 +24,16 @@ func InitJWT(cfg *config.JWTConfig) {
 
 // GenerateAccessToken 生成访问令牌
 func GenerateAccessToken(userID int, username string, role int, expireHours int) (string, error) {
+	return GenerateSessionAccessToken(userID, username, role, "", expireHours)
+}
+
+// GenerateSessionAccessToken 生成属于某个登录会话的访问令牌
+func GenerateSessionAccessToken(userID int, username string, role int, session string, expireHours int) (string, error) {
 	claims := Claims{
 		UserID:   userID,
 		Username: username,
 		Role:     role,
+		Session:  session,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(expireHours))),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -40,8 +47,14 @@ func GenerateAccessToken(userID int, username string, role int, expireHours int)
 
 // GenerateRefreshToken 生成刷新令牌
 func GenerateRefreshToken(userID int, expireDays int) (string, error) {
+	return GenerateSessionRefreshToken(userID, "", expireDays)
+}
+
+// GenerateSessionRefreshToken 生成属于某个登录会话的刷新令牌，会话被撤销后不能再刷新
+func GenerateSessionRefreshToken(userID int, session string, expireDays int) (string, error) {
 	claims := Claims{
-		UserID: userID,
+		UserID:  userID,
+		Session: session,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * time.Duration(expireDays))),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
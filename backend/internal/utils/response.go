@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
@@ -16,37 +17,58 @@ type Response struct {
 	Timestamp string      `json:"timestamp"`
 }
 
+// ErrorInfo 错误详情，error_code 为稳定错误码（见 errors.go），code 为兼容旧版本的数字错误码
 type ErrorInfo struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Details interface{} `json:"details,omitempty"`
+	Code      int         `json:"code"`
+	ErrorCode ErrorCode   `json:"error_code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"` // 便于按请求 ID 在各服务日志中排查
 }
 
-// 错误码定义
+// 数字错误码定义（兼容旧版本，新代码使用 errors.go 中的稳定错误码）
 const (
-	ErrInternal          = 1000
-	ErrInvalidRequest    = 1001
-	ErrNotFound          = 1004
-	ErrUnauthorized      = 2001
-	ErrForbidden         = 2003
-	ErrInvalidToken      = 2010
-	ErrTokenExpired      = 2011
-	ErrInsufficientQuota = 3001
-	ErrModelNotAvailable = 3002
-	ErrRateLimitExceeded = 3003
+	ErrInternal                = 1000
+	ErrInvalidRequest          = 1001
+	ErrNotFound                = 1004
+	ErrConflict                = 1009
+	ErrUnauthorized            = 2001
+	ErrForbidden               = 2003
+	ErrInvalidToken            = 2010
+	ErrTokenExpired            = 2011
+	ErrEmailNotVerified        = 2012
+	ErrOAuthIdentityConflict   = 2013
+	ErrUserSuspended           = 2014
+	ErrInsufficientQuota       = 3001
+	ErrModelNotAvailable       = 3002
+	ErrRateLimitExceeded       = 3003
+	ErrModelNotAllowed         = 3004
+	ErrChannelUnavailable      = 3005
+	ErrUpstream                = 3006
+	ErrModelFeatureUnsupported = 3007
+	ErrInvalidJSONOutput       = 3008
 )
 
 var errorMessages = map[int]string{
-	ErrInternal:          "内部服务器错误",
-	ErrInvalidRequest:    "请求参数错误",
-	ErrNotFound:          "资源不存在",
-	ErrUnauthorized:      "未登录",
-	ErrForbidden:         "无权限访问",
-	ErrInvalidToken:      "Token 无效",
-	ErrTokenExpired:      "Token 已过期",
-	ErrInsufficientQuota: "余额不足",
-	ErrModelNotAvailable: "模型不可用",
-	ErrRateLimitExceeded: "请求频率超限",
+	ErrInternal:                "内部服务器错误",
+	ErrInvalidRequest:          "请求参数错误",
+	ErrNotFound:                "资源不存在",
+	ErrConflict:                "资源冲突",
+	ErrUnauthorized:            "未登录",
+	ErrForbidden:               "无权限访问",
+	ErrInvalidToken:            "Token 无效",
+	ErrTokenExpired:            "Token 已过期",
+	ErrEmailNotVerified:        "邮箱尚未验证",
+	ErrOAuthIdentityConflict:   "第三方账号已绑定到其他用户",
+	ErrUserSuspended:           "账号已被停用",
+	ErrInsufficientQuota:       "余额不足",
+	ErrModelNotAvailable:       "模型不可用",
+	ErrRateLimitExceeded:       "请求频率超限",
+	ErrModelNotAllowed:         "模型不在 Token 白名单中",
+	ErrChannelUnavailable:      "渠道暂时不可用",
+	ErrUpstream:                "上游服务错误",
+	ErrModelFeatureUnsupported: "模型所需功能不受支持",
+	ErrInvalidJSONOutput:       "模型输出不是合法的 JSON",
 }
 
 // Success 成功响应
@@ -62,6 +84,26 @@ func Success(c *gin.Context, data interface{}, message string) {
 	})
 }
 
+// Created 资源已创建的响应（201）
+func Created(c *gin.Context, data interface{}, message string) {
+	c.JSON(http.StatusCreated, Response{
+		Success:   true,
+		Data:      data,
+		Message:   message,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// Accepted 请求已受理、在后台处理的响应（202）
+func Accepted(c *gin.Context, data interface{}, message string) {
+	c.JSON(http.StatusAccepted, Response{
+		Success:   true,
+		Data:      data,
+		Message:   message,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
 // Error 错误响应
 func Error(c *gin.Context, httpStatus int, errCode int, customMessage string, details interface{}) {
 	message := errorMessages[errCode]
@@ -72,9 +114,11 @@ func Error(c *gin.Context, httpStatus int, errCode int, customMessage string, de
 	c.JSON(httpStatus, Response{
 		Success: false,
 		Error: &ErrorInfo{
-			Code:    errCode,
-			Message: message,
-			Details: details,
+			Code:      errCode,
+			ErrorCode: errorCodeFor(errCode, httpStatus),
+			Message:   message,
+			Details:   details,
+			RequestID: c.GetString("request_id"),
 		},
 		Timestamp: time.Now().Format(time.RFC3339),
 	})
@@ -101,4 +145,9 @@ func InternalError(c *gin.Context, message string) {
 	Error(c, http.StatusInternalServerError, ErrInternal, message, nil)
 }
 
-
+// WriteSSERequestID 在 SSE 流开头写入请求 ID 注释行，客户端会忽略注释，便于抓包时与服务日志关联
+func WriteSSERequestID(c *gin.Context) {
+	if requestID := c.GetString("request_id"); requestID != "" {
+		fmt.Fprintf(c.Writer, ": request_id=%s\n\n", requestID)
+	}
+}
@@ -0,0 +1,13 @@
+-- 回滚组织与 SSO 相关表
+-- Version: 000017
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_identities_user;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS org_sso_configs;
+DROP INDEX IF EXISTS idx_org_members_user;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
+
+COMMIT;
@@ -0,0 +1,59 @@
+-- 创建组织与 SSO 相关表
+-- Version: 000017
+-- Description: 组织、组织成员、组织 OIDC 配置以及外部身份绑定
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(64) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    id SERIAL PRIMARY KEY,
+    org_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role INT DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_org_member UNIQUE (org_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_org_members_user ON organization_members(user_id);
+
+CREATE TABLE IF NOT EXISTS org_sso_configs (
+    id SERIAL PRIMARY KEY,
+    org_id INT NOT NULL UNIQUE REFERENCES organizations(id) ON DELETE CASCADE,
+    issuer_url VARCHAR(255) NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    client_secret VARCHAR(255),
+    redirect_uri VARCHAR(255) NOT NULL,
+    scopes VARCHAR(255) DEFAULT 'openid email profile',
+    allowed_domains TEXT, -- 逗号分隔的邮箱域名白名单
+    groups_claim VARCHAR(64) DEFAULT 'groups',
+    group_role_mapping JSONB, -- {"idp-group": role}
+    default_role INT DEFAULT 1,
+    enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_identity_subject UNIQUE (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_identities_user ON user_identities(user_id);
+
+COMMENT ON TABLE org_sso_configs IS '组织 OIDC 单点登录配置';
+COMMENT ON TABLE user_identities IS '外部身份提供方账号与本地用户的绑定';
+
+COMMIT;
@@ -0,0 +1,9 @@
+-- 回滚统一日志上游请求 ID
+-- Version: 000018
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_logs_upstream_request_id;
+ALTER TABLE unified_logs DROP COLUMN IF EXISTS upstream_request_id;
+
+COMMIT;
@@ -0,0 +1,14 @@
+-- 统一日志增加上游请求 ID
+-- Version: 000018
+-- Description: 记录上游提供方返回的请求 ID，便于双向关联排查
+
+BEGIN;
+
+ALTER TABLE unified_logs ADD COLUMN IF NOT EXISTS upstream_request_id VARCHAR(128);
+
+CREATE INDEX IF NOT EXISTS idx_logs_upstream_request_id
+ON unified_logs(upstream_request_id) WHERE upstream_request_id IS NOT NULL;
+
+COMMENT ON COLUMN unified_logs.upstream_request_id IS '上游提供方请求 ID（x-request-id、request-id 等）';
+
+COMMIT;
@@ -0,0 +1,55 @@
+-- 回滚统一日志分区
+-- Version: 000019
+-- 注意：已归档到对象存储的分区不会自动恢复，回滚前请先通过管理接口恢复需要保留的分区
+
+BEGIN;
+
+DROP TABLE IF EXISTS unified_log_daily_rollups;
+DROP INDEX IF EXISTS idx_log_archives_range;
+DROP TABLE IF EXISTS log_archives;
+
+ALTER SEQUENCE unified_logs_id_seq OWNED BY NONE;
+ALTER TABLE unified_logs RENAME TO unified_logs_partitioned;
+
+CREATE TABLE unified_logs (
+    id BIGINT PRIMARY KEY DEFAULT nextval('unified_logs_id_seq'),
+    user_id INT NOT NULL,
+    username VARCHAR(100),
+    token_id INT,
+    token_name VARCHAR(100),
+    channel_id INT,
+    channel_name VARCHAR(100),
+    log_type INT NOT NULL,
+    model_name VARCHAR(100),
+    content TEXT,
+    quota INT DEFAULT 0,
+    prompt_tokens INT DEFAULT 0,
+    completion_tokens INT DEFAULT 0,
+    use_time INT DEFAULT 0,
+    is_stream BOOLEAN DEFAULT false,
+    "group" VARCHAR(64),
+    ip VARCHAR(45),
+    user_agent TEXT,
+    request_id VARCHAR(100),
+    upstream_request_id VARCHAR(128),
+    other JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO unified_logs SELECT * FROM unified_logs_partitioned;
+DROP TABLE unified_logs_partitioned CASCADE;
+ALTER SEQUENCE unified_logs_id_seq OWNED BY unified_logs.id;
+
+CREATE INDEX IF NOT EXISTS idx_logs_user ON unified_logs(user_id);
+CREATE INDEX IF NOT EXISTS idx_logs_type ON unified_logs(log_type);
+CREATE INDEX IF NOT EXISTS idx_logs_created_at ON unified_logs(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_logs_model ON unified_logs(model_name);
+CREATE INDEX IF NOT EXISTS idx_logs_channel ON unified_logs(channel_id);
+CREATE INDEX IF NOT EXISTS idx_logs_request_id ON unified_logs(request_id);
+CREATE INDEX IF NOT EXISTS idx_logs_upstream_request_id
+ON unified_logs(upstream_request_id) WHERE upstream_request_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_logs_user_time ON unified_logs(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_logs_type_time ON unified_logs(log_type, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_logs_user_type_time ON unified_logs(user_id, log_type, created_at DESC);
+
+COMMIT;
@@ -0,0 +1,140 @@
+-- 统一日志按月分区
+-- Version: 000019
+-- Description: 将 unified_logs 转换为按 created_at 月份分区的表，并创建归档记录与按天汇总表
+--
+-- 维护窗口操作步骤（详见 docs/LOG_ARCHIVAL.md）：
+--   1. 停止所有写日志的服务（relay、billing 等），或将其切换到只读维护模式；
+--   2. 确认 pg_stat_activity 中没有对 unified_logs 的长事务；
+--   3. 执行本迁移（数据量大时耗时与表大小成正比，建议预估：约每千万行数分钟）；
+--   4. 校验 SELECT count(*) FROM unified_logs 与迁移前一致；
+--   5. 恢复服务，并确认分区维护任务（LOG_ARCHIVE_ENABLED）已启用。
+-- 分区按 UTC 自然月划分，命名为 unified_logs_pYYYYMM，超出已建分区的数据落入 unified_logs_default。
+
+BEGIN;
+
+-- 1. 旧表改名，保留 ID 序列供新表继续使用
+ALTER SEQUENCE unified_logs_id_seq OWNED BY NONE;
+ALTER TABLE unified_logs RENAME TO unified_logs_legacy;
+
+DROP INDEX IF EXISTS idx_logs_user_type_time;
+DROP INDEX IF EXISTS idx_logs_type_time;
+DROP INDEX IF EXISTS idx_logs_user_time;
+DROP INDEX IF EXISTS idx_logs_request_id;
+DROP INDEX IF EXISTS idx_logs_upstream_request_id;
+DROP INDEX IF EXISTS idx_logs_channel;
+DROP INDEX IF EXISTS idx_logs_model;
+DROP INDEX IF EXISTS idx_logs_created_at;
+DROP INDEX IF EXISTS idx_logs_type;
+DROP INDEX IF EXISTS idx_logs_user;
+
+-- 2. 创建分区父表（分区键必须包含在主键中）
+CREATE TABLE unified_logs (
+    id BIGINT NOT NULL DEFAULT nextval('unified_logs_id_seq'),
+    user_id INT NOT NULL,
+    username VARCHAR(100),
+    token_id INT,
+    token_name VARCHAR(100),
+    channel_id INT,
+    channel_name VARCHAR(100),
+    log_type INT NOT NULL,
+    model_name VARCHAR(100),
+    content TEXT,
+    quota INT DEFAULT 0,
+    prompt_tokens INT DEFAULT 0,
+    completion_tokens INT DEFAULT 0,
+    use_time INT DEFAULT 0,
+    is_stream BOOLEAN DEFAULT false,
+    "group" VARCHAR(64),
+    ip VARCHAR(45),
+    user_agent TEXT,
+    request_id VARCHAR(100),
+    upstream_request_id VARCHAR(128),
+    other JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+CREATE TABLE unified_logs_default PARTITION OF unified_logs DEFAULT;
+
+-- 3. 为历史数据及未来三个月创建月分区
+DO $$
+DECLARE
+    month_start DATE;
+    last_month DATE := (date_trunc('month', CURRENT_DATE) + INTERVAL '3 months')::DATE;
+BEGIN
+    SELECT COALESCE(date_trunc('month', MIN(created_at))::DATE, date_trunc('month', CURRENT_DATE)::DATE)
+    INTO month_start
+    FROM unified_logs_legacy;
+
+    WHILE month_start <= last_month LOOP
+        EXECUTE format(
+            'CREATE TABLE IF NOT EXISTS %I PARTITION OF unified_logs FOR VALUES FROM (%L) TO (%L)',
+            'unified_logs_p' || to_char(month_start, 'YYYYMM'),
+            month_start,
+            (month_start + INTERVAL '1 month')::DATE
+        );
+        month_start := (month_start + INTERVAL '1 month')::DATE;
+    END LOOP;
+END $$;
+
+-- 4. 迁移数据（created_at 为空的旧数据按迁移时间落入当月分区）
+INSERT INTO unified_logs (
+    id, user_id, username, token_id, token_name, channel_id, channel_name, log_type,
+    model_name, content, quota, prompt_tokens, completion_tokens, use_time, is_stream,
+    "group", ip, user_agent, request_id, upstream_request_id, other, created_at
+)
+SELECT
+    id, user_id, username, token_id, token_name, channel_id, channel_name, log_type,
+    model_name, content, quota, prompt_tokens, completion_tokens, use_time, is_stream,
+    "group", ip, user_agent, request_id, upstream_request_id, other, COALESCE(created_at, CURRENT_TIMESTAMP)
+FROM unified_logs_legacy;
+
+DROP TABLE unified_logs_legacy;
+ALTER SEQUENCE unified_logs_id_seq OWNED BY unified_logs.id;
+
+-- 5. 在父表上重建索引（自动应用到所有分区）
+CREATE INDEX IF NOT EXISTS idx_logs_user ON unified_logs(user_id);
+CREATE INDEX IF NOT EXISTS idx_logs_type ON unified_logs(log_type);
+CREATE INDEX IF NOT EXISTS idx_logs_created_at ON unified_logs(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_logs_model ON unified_logs(model_name);
+CREATE INDEX IF NOT EXISTS idx_logs_channel ON unified_logs(channel_id);
+CREATE INDEX IF NOT EXISTS idx_logs_request_id ON unified_logs(request_id);
+CREATE INDEX IF NOT EXISTS idx_logs_upstream_request_id ON unified_logs(upstream_request_id);
+CREATE INDEX IF NOT EXISTS idx_logs_user_time ON unified_logs(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_logs_type_time ON unified_logs(log_type, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_logs_user_type_time ON unified_logs(user_id, log_type, created_at DESC);
+
+-- 6. 归档记录表
+CREATE TABLE IF NOT EXISTS log_archives (
+    id SERIAL PRIMARY KEY,
+    partition_name VARCHAR(64) NOT NULL UNIQUE,
+    range_start TIMESTAMP NOT NULL,
+    range_end TIMESTAMP NOT NULL,
+    object_key VARCHAR(255) NOT NULL,
+    row_count BIGINT DEFAULT 0,
+    size_bytes BIGINT DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'archived', -- archived / restored
+    archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    restored_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_log_archives_range ON log_archives(range_start, range_end);
+
+-- 7. 按天汇总表（归档前写入，统计分析不受归档影响）
+CREATE TABLE IF NOT EXISTS unified_log_daily_rollups (
+    day DATE NOT NULL,
+    channel_id INT NOT NULL DEFAULT 0,
+    model_name VARCHAR(100) NOT NULL DEFAULT '',
+    requests BIGINT DEFAULT 0,
+    prompt_tokens BIGINT DEFAULT 0,
+    completion_tokens BIGINT DEFAULT 0,
+    quota BIGINT DEFAULT 0,
+    total_use_time BIGINT DEFAULT 0,
+    PRIMARY KEY (day, channel_id, model_name)
+);
+
+COMMENT ON TABLE unified_logs IS '统一日志表（按月分区）- 记录所有操作和调用日志';
+COMMENT ON TABLE log_archives IS '统一日志分区归档记录';
+COMMENT ON TABLE unified_log_daily_rollups IS '统一日志按天汇总，覆盖已归档的时间段';
+
+COMMIT;
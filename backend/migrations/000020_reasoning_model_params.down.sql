@@ -0,0 +1,12 @@
+-- 回滚推理模型参数适配与推理 Token 计量
+-- Version: 000020
+
+BEGIN;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS reasoning_tokens;
+ALTER TABLE billing_logs DROP COLUMN IF EXISTS reasoning_tokens;
+ALTER TABLE unified_logs DROP COLUMN IF EXISTS reasoning_tokens;
+
+DROP TABLE IF EXISTS model_param_rules;
+
+COMMIT;
@@ -0,0 +1,40 @@
+-- 推理模型参数适配与推理 Token 计量
+-- Version: 000020
+-- Description: 新增按模型家族的参数适配规则表，并在日志、计费与消息中单独记录推理 Token
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS model_param_rules (
+    id SERIAL PRIMARY KEY,
+    family VARCHAR(64) NOT NULL UNIQUE,
+    model_prefixes TEXT NOT NULL,
+    max_tokens_field VARCHAR(64) NOT NULL DEFAULT '',
+    unsupported_params JSONB NOT NULL DEFAULT '{}',
+    supports_reasoning_effort BOOLEAN NOT NULL DEFAULT FALSE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE model_param_rules IS '模型家族参数适配规则（新增家族无需改代码）';
+COMMENT ON COLUMN model_param_rules.model_prefixes IS '逗号分隔的模型名前缀，匹配完整名称或 "前缀-" 开头';
+COMMENT ON COLUMN model_param_rules.unsupported_params IS '不支持的参数 -> drop（丢弃并警告）/ reject（拒绝请求）';
+
+INSERT INTO model_param_rules (family, model_prefixes, max_tokens_field, unsupported_params, supports_reasoning_effort) VALUES
+    ('o1', 'o1,o1-preview', 'max_completion_tokens',
+     '{"temperature":"drop","top_p":"drop","frequency_penalty":"drop","presence_penalty":"drop"}', TRUE),
+    ('o1-mini', 'o1-mini', 'max_completion_tokens',
+     '{"temperature":"drop","top_p":"drop","frequency_penalty":"drop","presence_penalty":"drop","tools":"reject"}', FALSE),
+    ('o3', 'o3,o3-mini,o4-mini', 'max_completion_tokens',
+     '{"temperature":"drop","top_p":"drop","frequency_penalty":"drop","presence_penalty":"drop"}', TRUE)
+ON CONFLICT (family) DO NOTHING;
+
+ALTER TABLE unified_logs ADD COLUMN IF NOT EXISTS reasoning_tokens INT NOT NULL DEFAULT 0;
+ALTER TABLE billing_logs ADD COLUMN IF NOT EXISTS reasoning_tokens INT NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS reasoning_tokens INT NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN unified_logs.reasoning_tokens IS '推理 Token 数（已包含在 completion_tokens 中）';
+COMMENT ON COLUMN billing_logs.reasoning_tokens IS '推理 Token 数（已包含在 output_tokens 中）';
+COMMENT ON COLUMN messages.reasoning_tokens IS '推理 Token 数（已包含在 output_tokens 中）';
+
+COMMIT;
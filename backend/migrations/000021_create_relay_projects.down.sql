@@ -0,0 +1,32 @@
+-- 回滚中转项目与项目级 API Token
+-- Version: 000021
+
+BEGIN;
+
+-- 汇总表合并各项目的数据后恢复原主键
+CREATE TEMP TABLE rollups_merged ON COMMIT DROP AS
+SELECT day, channel_id, model_name,
+       SUM(requests) AS requests, SUM(prompt_tokens) AS prompt_tokens,
+       SUM(completion_tokens) AS completion_tokens, SUM(quota) AS quota,
+       SUM(total_use_time) AS total_use_time
+FROM unified_log_daily_rollups
+GROUP BY day, channel_id, model_name;
+
+ALTER TABLE unified_log_daily_rollups DROP CONSTRAINT IF EXISTS unified_log_daily_rollups_pkey;
+DELETE FROM unified_log_daily_rollups;
+ALTER TABLE unified_log_daily_rollups DROP COLUMN IF EXISTS project_id;
+INSERT INTO unified_log_daily_rollups
+    (day, channel_id, model_name, requests, prompt_tokens, completion_tokens, quota, total_use_time)
+SELECT day, channel_id, model_name, requests, prompt_tokens, completion_tokens, quota, total_use_time
+FROM rollups_merged;
+ALTER TABLE unified_log_daily_rollups ADD PRIMARY KEY (day, channel_id, model_name);
+
+DROP INDEX IF EXISTS idx_logs_project_time;
+ALTER TABLE unified_logs DROP COLUMN IF EXISTS project_id;
+
+DROP INDEX IF EXISTS idx_tokens_project_id;
+ALTER TABLE tokens DROP COLUMN IF EXISTS project_id;
+
+DROP TABLE IF EXISTS relay_projects;
+
+COMMIT;
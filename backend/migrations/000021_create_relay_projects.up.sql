@@ -0,0 +1,45 @@
+-- 中转项目与项目级 API Token
+-- Version: 000021
+-- Description: 新增中转项目表（系统提示词、知识库、默认模型、参数默认值与字段锁定），Token 可绑定项目，统一日志与按天汇总增加项目维度
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS relay_projects (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    system_prompt TEXT,
+    default_model VARCHAR(100),
+    params JSONB NOT NULL DEFAULT '{}',
+    knowledge_base_ids INTEGER[],
+    metadata_tags JSONB NOT NULL DEFAULT '{}',
+    locks JSONB NOT NULL DEFAULT '{}',
+    status INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_relay_projects_user_id ON relay_projects(user_id);
+CREATE INDEX IF NOT EXISTS idx_relay_projects_deleted_at ON relay_projects(deleted_at);
+
+COMMENT ON TABLE relay_projects IS '中转项目：绑定到 API Token 的服务端对话配置';
+COMMENT ON COLUMN relay_projects.params IS '参数默认值（temperature/top_p/max_tokens 等）';
+COMMENT ON COLUMN relay_projects.metadata_tags IS '强制附加到请求日志的元数据标签';
+COMMENT ON COLUMN relay_projects.locks IS '字段锁定标记，锁定字段忽略客户端传入的值';
+
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS project_id INT REFERENCES relay_projects(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_tokens_project_id ON tokens(project_id);
+
+ALTER TABLE unified_logs ADD COLUMN IF NOT EXISTS project_id INT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_logs_project_time ON unified_logs(project_id, created_at DESC);
+
+ALTER TABLE unified_log_daily_rollups ADD COLUMN IF NOT EXISTS project_id INT NOT NULL DEFAULT 0;
+ALTER TABLE unified_log_daily_rollups DROP CONSTRAINT IF EXISTS unified_log_daily_rollups_pkey;
+ALTER TABLE unified_log_daily_rollups ADD PRIMARY KEY (day, channel_id, model_name, project_id);
+
+COMMENT ON COLUMN tokens.project_id IS '绑定的中转项目，为空表示普通 Token';
+COMMENT ON COLUMN unified_logs.project_id IS '中转项目 ID，0 表示未使用项目 Token';
+
+COMMIT;
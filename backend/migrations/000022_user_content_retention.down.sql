@@ -0,0 +1,8 @@
+-- 回滚用户内容保留策略
+-- Version: 000022
+
+BEGIN;
+
+ALTER TABLE user_settings DROP COLUMN IF EXISTS content_retention;
+
+COMMIT;
@@ -0,0 +1,12 @@
+-- 用户内容保留策略
+-- Version: 000022
+-- Description: user_settings 增加 content_retention，管理员实时跟踪用户请求时只有策略为 full 才能查看消息内容
+
+BEGIN;
+
+ALTER TABLE user_settings
+    ADD COLUMN IF NOT EXISTS content_retention VARCHAR(20) NOT NULL DEFAULT 'metadata';
+
+COMMENT ON COLUMN user_settings.content_retention IS '内容保留策略：metadata 只保留元数据，full 允许保留并查看消息内容';
+
+COMMIT;
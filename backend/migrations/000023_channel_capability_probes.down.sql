@@ -0,0 +1,15 @@
+-- 回滚渠道能力探测
+-- Version: 000023
+
+BEGIN;
+
+ALTER TABLE channel_abilities
+    DROP COLUMN IF EXISTS features_probed_at,
+    DROP COLUMN IF EXISTS supports_stream,
+    DROP COLUMN IF EXISTS supports_vision,
+    DROP COLUMN IF EXISTS supports_tools,
+    DROP COLUMN IF EXISTS supports_json_mode;
+
+DROP TABLE IF EXISTS channel_capability_probes;
+
+COMMIT;
@@ -0,0 +1,38 @@
+-- 渠道能力探测
+-- Version: 000023
+-- Description: 新增能力探测记录表（JSON 模式、工具调用、图片输入、流式输出），渠道能力增加探测确认的特性标记
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS channel_capability_probes (
+    id BIGSERIAL PRIMARY KEY,
+    run_id VARCHAR(36) NOT NULL,
+    channel_id INT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    model VARCHAR(100) NOT NULL,
+    capability VARCHAR(32) NOT NULL,
+    outcome VARCHAR(16) NOT NULL,
+    detail TEXT,
+    status_code INT NOT NULL DEFAULT 0,
+    evidence TEXT,
+    latency_ms INT NOT NULL DEFAULT 0,
+    probed_by INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_capability_probes_run_id ON channel_capability_probes(run_id);
+CREATE INDEX IF NOT EXISTS idx_capability_probes_channel_time ON channel_capability_probes(channel_id, model, created_at DESC);
+
+COMMENT ON TABLE channel_capability_probes IS '渠道能力探测记录，保留历史以便发现上游变更导致的能力回退';
+COMMENT ON COLUMN channel_capability_probes.outcome IS 'pass 通过，fail 接受参数但未生效，unsupported 上游拒绝，error 探测请求失败';
+COMMENT ON COLUMN channel_capability_probes.evidence IS '上游原始响应（截断）';
+
+ALTER TABLE channel_abilities
+    ADD COLUMN IF NOT EXISTS supports_json_mode BOOLEAN,
+    ADD COLUMN IF NOT EXISTS supports_tools BOOLEAN,
+    ADD COLUMN IF NOT EXISTS supports_vision BOOLEAN,
+    ADD COLUMN IF NOT EXISTS supports_stream BOOLEAN,
+    ADD COLUMN IF NOT EXISTS features_probed_at TIMESTAMP;
+
+COMMENT ON COLUMN channel_abilities.supports_json_mode IS '探测确认的 JSON 模式支持，NULL 表示未探测';
+
+COMMIT;
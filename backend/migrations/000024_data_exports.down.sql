@@ -0,0 +1,8 @@
+-- 回滚用户数据导出
+-- Version: 000024
+
+BEGIN;
+
+DROP TABLE IF EXISTS data_exports;
+
+COMMIT;
@@ -0,0 +1,29 @@
+-- 用户数据导出
+-- Version: 000024
+-- Description: 新增用户数据导出任务表，记录进度与续跑断点，归档过期后清理
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS data_exports (
+    id UUID PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    progress INT NOT NULL DEFAULT 0,
+    stage VARCHAR(32),
+    checkpoint JSONB NOT NULL DEFAULT '{}',
+    object_key VARCHAR(255),
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    expires_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_time ON data_exports(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports(status);
+
+COMMENT ON TABLE data_exports IS '用户数据导出任务，每个用户每天限一次，归档保留 7 天';
+COMMENT ON COLUMN data_exports.checkpoint IS '已完成的导出部分及其清单条目，服务重启后从断点续跑';
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- 回滚文档内容摘要
+-- Version: 000025
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_documents_digest;
+DROP INDEX IF EXISTS idx_documents_kb_digest;
+ALTER TABLE documents DROP COLUMN IF EXISTS simhash;
+ALTER TABLE documents DROP COLUMN IF EXISTS content_digest;
+
+COMMIT;
@@ -0,0 +1,13 @@
+-- 文档内容摘要
+-- Version: 000025
+-- Description: 为文档记录归一化文本的 SHA-256 摘要与 simhash 指纹，用于上传时的重复与近似重复检测
+
+BEGIN;
+
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_digest VARCHAR(64);
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS simhash BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_documents_kb_digest ON documents(kb_id, content_digest) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_documents_digest ON documents(content_digest) WHERE deleted_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- 回滚请求分阶段耗时
+-- Version: 000026
+
+BEGIN;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS latency;
+
+COMMIT;
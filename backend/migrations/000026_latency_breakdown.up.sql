@@ -0,0 +1,10 @@
+-- 请求分阶段耗时
+-- Version: 000026
+-- Description: 为对话产生的助手消息记录中转请求的分阶段耗时（准入、渠道选择、连接、首 Token、生成、后处理）；
+--              中转请求的耗时记录在 unified_logs.other 的 latency 字段中，无需修改表结构
+
+BEGIN;
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS latency JSONB;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- 回滚统一日志中转结果
+-- Version: 000027
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_logs_status;
+ALTER TABLE unified_logs DROP COLUMN IF EXISTS status_code;
+ALTER TABLE unified_logs DROP COLUMN IF EXISTS status;
+
+COMMIT;
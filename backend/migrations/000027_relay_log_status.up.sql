@@ -0,0 +1,16 @@
+-- 统一日志增加中转结果
+-- Version: 000027
+-- Description: 记录每次渠道尝试的结果与上游 HTTP 状态码，便于按状态筛选排查上游失败；
+--              开启请求体日志的渠道，脱敏后的请求头、请求体与响应体记录在 other 中，无需修改表结构
+
+BEGIN;
+
+ALTER TABLE unified_logs ADD COLUMN IF NOT EXISTS status VARCHAR(16);
+ALTER TABLE unified_logs ADD COLUMN IF NOT EXISTS status_code INT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_logs_status ON unified_logs(status);
+
+COMMENT ON COLUMN unified_logs.status IS '中转结果：success、partial、error';
+COMMENT ON COLUMN unified_logs.status_code IS '上游 HTTP 状态码，未收到响应为 0';
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- 回滚会话自动标题
+-- Version: 000028
+
+BEGIN;
+
+ALTER TABLE sessions DROP COLUMN IF EXISTS auto_title;
+
+COMMIT;
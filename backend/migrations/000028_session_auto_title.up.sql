@@ -0,0 +1,10 @@
+-- 会话自动标题
+-- Version: 000028
+-- Description: 创建会话时可开启自动标题，第一轮对话完成后由低价模型生成标题；
+--              生成成功或用户重命名后清除标记，不再重新生成
+
+BEGIN;
+
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS auto_title BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMIT;
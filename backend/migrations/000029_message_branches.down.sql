@@ -0,0 +1,11 @@
+-- 回滚消息分支
+-- Version: 000029
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_messages_branch_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS branch_id;
+
+DROP TABLE IF EXISTS message_branches;
+
+COMMIT;
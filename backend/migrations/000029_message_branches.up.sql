@@ -0,0 +1,24 @@
+-- 消息分支
+-- Version: 000029
+-- Description: 重新生成助手回复时从用户消息分出分支并保留原回复；
+--              分支内的消息带 branch_id，主线对话只包含 branch_id 为空的消息
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS message_branches (
+    id VARCHAR(100) PRIMARY KEY,
+    session_id UUID NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    name VARCHAR(100),
+    parent_message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    message_ids TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_branches_session_id ON message_branches(session_id);
+CREATE INDEX IF NOT EXISTS idx_message_branches_parent_message_id ON message_branches(parent_message_id);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS branch_id VARCHAR(100);
+CREATE INDEX IF NOT EXISTS idx_messages_branch_id ON messages(branch_id);
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- 回滚消息编辑记录
+-- Version: 000030
+
+BEGIN;
+
+DROP TABLE IF EXISTS message_edits;
+
+COMMIT;
@@ -0,0 +1,21 @@
+-- 消息编辑记录
+-- Version: 000030
+-- Description: 持久化消息的编辑历史（原内容、新内容、编辑者、原因），编辑时可作废之后的消息
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS message_edits (
+    id BIGSERIAL PRIMARY KEY,
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    session_id UUID NOT NULL,
+    editor_id INT NOT NULL,
+    original_content TEXT NOT NULL,
+    new_content TEXT NOT NULL,
+    reason VARCHAR(500),
+    truncated_messages INT NOT NULL DEFAULT 0,
+    edited_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_edits_message_id ON message_edits(message_id, edited_at);
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- 回滚消息全文搜索
+-- Version: 000031
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_messages_content_trgm;
+DROP INDEX IF EXISTS idx_messages_content_tsv;
+ALTER TABLE messages DROP COLUMN IF EXISTS content_tsv;
+
+COMMIT;
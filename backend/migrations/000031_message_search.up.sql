@@ -0,0 +1,16 @@
+-- 消息全文搜索
+-- Version: 000031
+-- Description: 为消息内容增加 tsvector 生成列与 GIN 索引，用于按关键词搜索用户的历史对话；
+--              simple 配置不会切分中日韩文本，这类查询使用 pg_trgm 三元组索引做子串匹配
+
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS content_tsv tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', coalesce(content, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_messages_content_tsv ON messages USING GIN (content_tsv);
+CREATE INDEX IF NOT EXISTS idx_messages_content_trgm ON messages USING GIN (content gin_trgm_ops);
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- 回滚会话软删除
+-- Version: 000032
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_sessions_deleted;
+
+DROP INDEX IF EXISTS idx_messages_deleted_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted_at;
+
+COMMIT;
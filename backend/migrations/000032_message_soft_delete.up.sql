@@ -0,0 +1,13 @@
+-- 会话软删除
+-- Version: 000032
+-- Description: 删除会话时会话与消息都只标记 deleted_at（同一时间戳），恢复会话时一并恢复消息；
+--              超过保留期的已删除会话由对话服务的后台任务永久删除（消息随外键级联删除）
+
+BEGIN;
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL;
+CREATE INDEX IF NOT EXISTS idx_messages_deleted_at ON messages(deleted_at);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_deleted ON sessions(user_id, deleted_at DESC) WHERE deleted_at IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- 回滚会话置顶排序
+-- Version: 000033
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_sessions_pinned;
+CREATE INDEX IF NOT EXISTS idx_sessions_pinned ON sessions(user_id, pinned DESC, updated_at DESC) WHERE deleted_at IS NULL;
+
+ALTER TABLE sessions DROP COLUMN IF EXISTS sort_order;
+
+COMMIT;
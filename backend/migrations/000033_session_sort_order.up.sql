@@ -0,0 +1,12 @@
+-- 会话置顶排序
+-- Version: 000033
+-- Description: 置顶会话按 sort_order 排在列表前面，其余会话仍按更新时间倒序
+
+BEGIN;
+
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS sort_order INTEGER NOT NULL DEFAULT 0;
+
+DROP INDEX IF EXISTS idx_sessions_pinned;
+CREATE INDEX IF NOT EXISTS idx_sessions_pinned ON sessions(user_id, pinned DESC, sort_order, updated_at DESC) WHERE deleted_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- 回滚系统提示词版本历史
+-- Version: 000034
+
+BEGIN;
+
+DROP TABLE IF EXISTS prompt_versions;
+
+COMMIT;
@@ -0,0 +1,20 @@
+-- 系统提示词版本历史
+-- Version: 000034
+-- Description: 每次创建、修改或回滚提示词都追加一个版本（内容、修改人、时间），
+--              回滚以旧内容创建新版本，历史不会被覆盖
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS prompt_versions (
+    id BIGSERIAL PRIMARY KEY,
+    prompt_id VARCHAR(100) NOT NULL,
+    version BIGINT NOT NULL,
+    content TEXT NOT NULL,
+    editor VARCHAR(100),
+    rolled_back_from BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_prompt_versions_prompt_version ON prompt_versions(prompt_id, version);
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- 回滚 MCP 插件注册表
+-- Version: 000035
+
+BEGIN;
+
+DROP TABLE IF EXISTS plugins;
+
+COMMIT;
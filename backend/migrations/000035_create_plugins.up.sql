@@ -0,0 +1,28 @@
+-- MCP 插件注册表
+-- Version: 000035
+-- Description: 保存通过插件服务注册的 MCP 插件配置，服务重启时重新注册，
+--              enabled 的插件自动启动
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS plugins (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(64) NOT NULL,
+    description TEXT,
+    mode VARCHAR(10) NOT NULL,
+    command TEXT,
+    args JSONB NOT NULL DEFAULT '[]',
+    url TEXT,
+    timeout_seconds INTEGER NOT NULL DEFAULT 0,
+    max_retries INTEGER NOT NULL DEFAULT 0,
+    auto_restart BOOLEAN NOT NULL DEFAULT FALSE,
+    tool_refresh_seconds INTEGER NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_plugins_name ON plugins(name);
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- 回滚用户上传的文件
+-- Version: 000036
+
+BEGIN;
+
+DROP TABLE IF EXISTS files;
+
+COMMIT;
@@ -0,0 +1,22 @@
+-- 用户上传的文件
+-- Version: 000036
+-- Description: 文件内容按 SHA-256 存放在对象存储中，相同内容只保存一份；
+--              每个用户对同一内容只有一条记录
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS files (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    hash CHAR(64) NOT NULL,
+    storage_key VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_files_user_created ON files(user_id, created_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_files_user_hash ON files(user_id, hash);
+
+COMMIT;
@@ -0,0 +1,12 @@
+-- 回滚分块配置
+-- Version: 000037
+
+BEGIN;
+
+ALTER TABLE documents DROP COLUMN IF EXISTS chunk_overlap;
+ALTER TABLE documents DROP COLUMN IF EXISTS chunk_size;
+ALTER TABLE documents DROP COLUMN IF EXISTS chunk_strategy;
+
+ALTER TABLE knowledge_bases DROP COLUMN IF EXISTS chunk_strategy;
+
+COMMIT;
@@ -0,0 +1,14 @@
+-- 分块配置
+-- Version: 000037
+-- Description: 知识库可选择分块策略（fixed、sentence、recursive），分块大小按 Token 计；
+--              文档记录分块时实际使用的配置，配置变化后重新上传会重新分块
+
+BEGIN;
+
+ALTER TABLE knowledge_bases ADD COLUMN IF NOT EXISTS chunk_strategy VARCHAR(20) DEFAULT 'fixed';
+
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS chunk_strategy VARCHAR(20) DEFAULT '';
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS chunk_size INT DEFAULT 0;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS chunk_overlap INT DEFAULT 0;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- 回滚知识库文本块关键词搜索
+-- Version: 000038
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_chunks_content_trgm;
+DROP INDEX IF EXISTS idx_chunks_content_tsv;
+ALTER TABLE chunks DROP COLUMN IF EXISTS content_tsv;
+
+COMMIT;
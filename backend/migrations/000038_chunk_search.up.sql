@@ -0,0 +1,16 @@
+-- 知识库文本块关键词搜索
+-- Version: 000038
+-- Description: 为文本块内容增加 tsvector 生成列与 GIN 索引，与向量检索一起用于混合搜索；
+--              simple 配置不会切分中日韩文本，这类查询使用 pg_trgm 三元组索引做子串匹配
+
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS content_tsv tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', coalesce(content, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_chunks_content_tsv ON chunks USING GIN (content_tsv);
+CREATE INDEX IF NOT EXISTS idx_chunks_content_trgm ON chunks USING GIN (content gin_trgm_ops);
+
+COMMIT;
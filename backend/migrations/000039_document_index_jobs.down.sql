@@ -0,0 +1,8 @@
+-- 回滚文档异步索引任务
+-- Version: 000039
+
+BEGIN;
+
+DROP TABLE IF EXISTS document_index_jobs;
+
+COMMIT;
@@ -0,0 +1,31 @@
+-- 文档异步索引任务
+-- Version: 000039
+-- Description: 上传文档后由后台工作协程分块与向量化，任务记录解析后的文本与进度，
+--              服务重启后重新排队未完成的任务
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS document_index_jobs (
+    id UUID PRIMARY KEY,
+    document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    kb_id INT NOT NULL REFERENCES knowledge_bases(id) ON DELETE CASCADE,
+    state VARCHAR(20) NOT NULL DEFAULT 'queued',
+    replace BOOLEAN NOT NULL DEFAULT FALSE,
+    sections JSONB,
+    chunks_done INT NOT NULL DEFAULT 0,
+    chunks_total INT NOT NULL DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_index_jobs_document ON document_index_jobs(document_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_document_index_jobs_unfinished ON document_index_jobs(created_at)
+    WHERE state IN ('queued', 'processing');
+
+COMMENT ON TABLE document_index_jobs IS '文档分块与向量化任务，状态为 queued、processing、ready、failed';
+COMMENT ON COLUMN document_index_jobs.sections IS '解析后的文本段落，任务完成后清空';
+
+COMMIT;
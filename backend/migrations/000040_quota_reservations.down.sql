@@ -0,0 +1,8 @@
+-- 回滚额度预留持久化
+-- Version: 000040
+
+BEGIN;
+
+DROP TABLE IF EXISTS quota_reservations;
+
+COMMIT;
@@ -0,0 +1,22 @@
+-- 额度预留持久化
+-- Version: 000040
+-- Description: 中转请求开始前预留的额度写入数据库，服务重启后恢复尚未结算的预留，
+--              超过有效期未结算的预留由清理协程释放
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS quota_reservations (
+    id VARCHAR(64) PRIMARY KEY,
+    quota_key VARCHAR(64) NOT NULL,
+    amount DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_reservations_quota_key ON quota_reservations(quota_key);
+CREATE INDEX IF NOT EXISTS idx_quota_reservations_expires_at ON quota_reservations(expires_at);
+
+COMMENT ON TABLE quota_reservations IS '尚未结算的额度预留，结算、释放或过期后删除';
+COMMENT ON COLUMN quota_reservations.quota_key IS '额度键，如 token:3 表示 ID 为 3 的 Token';
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- 回滚计费死信持久化
+-- Version: 000041
+
+BEGIN;
+
+DROP TABLE IF EXISTS billing_dead_letters;
+
+COMMIT;
@@ -0,0 +1,29 @@
+-- 计费死信持久化
+-- Version: 000041
+-- Description: 重试耗尽的计费事件写入数据库，进程崩溃后不丢失，管理员可查询、重放或丢弃
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS billing_dead_letters (
+    id BIGSERIAL PRIMARY KEY,
+    event_id VARCHAR(100) NOT NULL,
+    user_id VARCHAR(100) NOT NULL,
+    model_name VARCHAR(100) NOT NULL DEFAULT '',
+    event JSONB NOT NULL,
+    reason TEXT,
+    attempts INT NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_billing_dead_letters_event ON billing_dead_letters(event_id);
+CREATE INDEX IF NOT EXISTS idx_billing_dead_letters_user ON billing_dead_letters(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_billing_dead_letters_pending ON billing_dead_letters(created_at)
+    WHERE status = 'pending';
+
+COMMENT ON TABLE billing_dead_letters IS '重试耗尽的计费事件，状态为 pending、replaying、replayed、discarded';
+COMMENT ON COLUMN billing_dead_letters.attempts IS '累计失败次数，重放后再次失败时累加';
+
+COMMIT;
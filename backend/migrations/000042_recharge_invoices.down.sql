@@ -0,0 +1,8 @@
+-- 回滚预付费充值发票
+-- Version: 000042
+
+BEGIN;
+
+DROP TABLE IF EXISTS recharge_invoices;
+
+COMMIT;
@@ -0,0 +1,31 @@
+-- 预付费充值发票
+-- Version: 000042
+-- Description: 用户通过 Stripe Checkout 购买额度套餐，支付完成的 Webhook 将发票标记为已支付并入账额度
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS recharge_invoices (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    package_id VARCHAR(50) NOT NULL,
+    quota BIGINT NOT NULL,
+    amount_cents BIGINT NOT NULL,
+    currency VARCHAR(10) NOT NULL DEFAULT 'usd',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    checkout_session_id VARCHAR(255) NOT NULL,
+    checkout_url TEXT,
+    payment_intent_id VARCHAR(255),
+    receipt_url TEXT,
+    paid_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recharge_invoices_session ON recharge_invoices(checkout_session_id);
+CREATE INDEX IF NOT EXISTS idx_recharge_invoices_user ON recharge_invoices(user_id, created_at DESC);
+
+COMMENT ON TABLE recharge_invoices IS '预付费充值发票，状态为 pending、paid';
+COMMENT ON COLUMN recharge_invoices.quota IS '支付完成后入账的额度（分）';
+COMMENT ON COLUMN recharge_invoices.checkout_session_id IS 'Stripe Checkout Session ID，Webhook 据此定位发票';
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- 回滚用量报表索引
+-- Version: 000043
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_logs_usage_report;
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- 用量报表索引
+-- Version: 000043
+-- Description: GET /billing/usage 按模型、天、渠道汇总消费日志，覆盖索引使一年范围的聚合可以只扫描索引
+
+BEGIN;
+
+CREATE INDEX IF NOT EXISTS idx_logs_usage_report
+ON unified_logs(user_id, log_type, created_at)
+INCLUDE (model_name, channel_id, quota, prompt_tokens, completion_tokens);
+
+COMMIT;
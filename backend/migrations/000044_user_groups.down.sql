@@ -0,0 +1,9 @@
+-- 回滚用户分组
+-- Version: 000044
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_users_group;
+ALTER TABLE users DROP COLUMN IF EXISTS "group";
+
+COMMIT;
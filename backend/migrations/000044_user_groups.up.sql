@@ -0,0 +1,13 @@
+-- 用户分组
+-- Version: 000044
+-- Description: 用户分组对应计费服务中的同名价格组，消费按价格组倍率计费
+
+BEGIN;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS "group" VARCHAR(64) NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS idx_users_group ON users("group") WHERE "group" <> 'default';
+
+COMMENT ON COLUMN users."group" IS '用户分组（用于计费价格组倍率），默认 default 按基础价格计费';
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- 回滚配额预警通知
+-- Version: 000045
+
+BEGIN;
+
+DROP TABLE IF EXISTS quota_alert_deliveries;
+DROP TABLE IF EXISTS quota_alerts;
+DROP TABLE IF EXISTS alert_preferences;
+
+COMMIT;
@@ -0,0 +1,57 @@
+-- 配额预警通知
+-- Version: 000045
+-- Description: 用户的预警通知偏好（Webhook、邮件），以及触发的预警与各渠道的投递记录
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS alert_preferences (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(100) NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    target VARCHAR(500) NOT NULL,
+    secret VARCHAR(255) NOT NULL DEFAULT '',
+    min_level VARCHAR(20) NOT NULL DEFAULT 'warning',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_alert_preferences_user_channel ON alert_preferences(user_id, channel);
+
+CREATE TABLE IF NOT EXISTS quota_alerts (
+    id BIGSERIAL PRIMARY KEY,
+    alert_id VARCHAR(100) NOT NULL,
+    user_id VARCHAR(100) NOT NULL,
+    level VARCHAR(20) NOT NULL,
+    usage_rate DOUBLE PRECISION NOT NULL DEFAULT 0,
+    remaining_quota DOUBLE PRECISION NOT NULL DEFAULT 0,
+    used_quota DOUBLE PRECISION NOT NULL DEFAULT 0,
+    total_quota DOUBLE PRECISION NOT NULL DEFAULT 0,
+    message TEXT,
+    triggered_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_quota_alerts_alert_id ON quota_alerts(alert_id);
+CREATE INDEX IF NOT EXISTS idx_quota_alerts_user ON quota_alerts(user_id, triggered_at DESC);
+
+CREATE TABLE IF NOT EXISTS quota_alert_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    alert_id VARCHAR(100) NOT NULL,
+    user_id VARCHAR(100) NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    target VARCHAR(500) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    error TEXT,
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_alert_deliveries_alert ON quota_alert_deliveries(alert_id);
+
+COMMENT ON TABLE alert_preferences IS '配额预警通知偏好，每个用户每个渠道一条';
+COMMENT ON COLUMN alert_preferences.min_level IS '低于该等级的预警不通知：warning、critical、exhausted';
+COMMENT ON TABLE quota_alert_deliveries IS '配额预警的投递记录，状态为 sent 或 failed（重试耗尽）';
+
+COMMIT;
@@ -0,0 +1,9 @@
+-- 回滚自动充值扣款
+-- Version: 000046
+
+BEGIN;
+
+DROP TABLE IF EXISTS auto_recharge_records;
+DROP TABLE IF EXISTS auto_recharge_settings;
+
+COMMIT;
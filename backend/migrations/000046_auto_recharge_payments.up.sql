@@ -0,0 +1,48 @@
+-- 自动充值扣款
+-- Version: 000046
+-- Description: 保存用户的 Stripe 支付方式与自动充值配置，自动充值先离线扣款再入账额度
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS auto_recharge_settings (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    stripe_customer_id VARCHAR(255) NOT NULL DEFAULT '',
+    payment_method_id VARCHAR(255) NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    trigger_threshold DOUBLE PRECISION NOT NULL DEFAULT 90,
+    recharge_amount BIGINT NOT NULL DEFAULT 0,
+    max_per_period INT NOT NULL DEFAULT 3,
+    period_days INT NOT NULL DEFAULT 30,
+    suspended BOOLEAN NOT NULL DEFAULT FALSE,
+    suspend_reason TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS auto_recharge_records (
+    id BIGSERIAL PRIMARY KEY,
+    record_id VARCHAR(100) NOT NULL,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount DOUBLE PRECISION NOT NULL,
+    amount_cents BIGINT NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    idempotency_key VARCHAR(255) NOT NULL,
+    payment_intent_id VARCHAR(255) NOT NULL DEFAULT '',
+    error TEXT,
+    reason TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_auto_recharge_records_record_id ON auto_recharge_records(record_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_auto_recharge_records_idempotency_key ON auto_recharge_records(idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_auto_recharge_records_user ON auto_recharge_records(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_auto_recharge_records_unfinished ON auto_recharge_records(user_id)
+    WHERE status IN ('pending', 'charged');
+
+COMMENT ON TABLE auto_recharge_records IS '自动充值记录，状态为 pending、charged、completed、declined、refunded';
+COMMENT ON COLUMN auto_recharge_records.idempotency_key IS '扣款幂等键，重试同一次充值时复用';
+COMMENT ON COLUMN auto_recharge_settings.suspended IS '扣款失败后暂停自动充值，更新支付方式后恢复';
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- 回滚 API Token 密钥哈希与轮换
+-- Version: 000047
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_tokens_previous_token_hash;
+ALTER TABLE tokens DROP COLUMN IF EXISTS previous_hash_expire_at;
+ALTER TABLE tokens DROP COLUMN IF EXISTS previous_token_hash;
+ALTER TABLE tokens DROP COLUMN IF EXISTS key_hint;
+
+COMMIT;
@@ -0,0 +1,18 @@
+-- API Token 密钥哈希与轮换
+-- Version: 000047
+-- Description: 保存脱敏后的 Key 用于展示，记录轮换前的 Key 哈希及其宽限期
+
+BEGIN;
+
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS key_hint VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS previous_token_hash VARCHAR(255);
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS previous_hash_expire_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_tokens_previous_token_hash ON tokens(previous_token_hash) WHERE previous_token_hash IS NOT NULL;
+
+COMMENT ON COLUMN tokens.token_hash IS 'API Key 的 SHA-256 哈希；key_hint 为空的早期 Token 直接保存 Key';
+COMMENT ON COLUMN tokens.key_hint IS '脱敏后的 Key，用于列表展示';
+COMMENT ON COLUMN tokens.previous_token_hash IS '轮换前的 Key 哈希，宽限期内仍可用于鉴权';
+COMMENT ON COLUMN tokens.previous_hash_expire_at IS '轮换前的 Key 失效时间';
+
+COMMIT;
@@ -0,0 +1,9 @@
+-- 回滚 API Token 即将过期通知
+-- Version: 000048
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_tokens_expiring;
+ALTER TABLE tokens DROP COLUMN IF EXISTS expiry_notified_at;
+
+COMMIT;
@@ -0,0 +1,13 @@
+-- API Token 即将过期通知
+-- Version: 000048
+-- Description: 记录即将过期通知的发送时间，保证每个 Token 只通知一次（续期后清空）
+
+BEGIN;
+
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS expiry_notified_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_tokens_expiring ON tokens(expire_at) WHERE status = 1 AND expiry_notified_at IS NULL;
+
+COMMENT ON COLUMN tokens.expiry_notified_at IS '已发送即将过期通知的时间，续期后清空';
+
+COMMIT;
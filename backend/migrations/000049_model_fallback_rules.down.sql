@@ -0,0 +1,8 @@
+-- 回滚模型降级路由规则
+-- Version: 000049
+
+BEGIN;
+
+DROP TABLE IF EXISTS model_fallback_rules;
+
+COMMIT;
@@ -0,0 +1,23 @@
+-- 模型降级路由规则
+-- Version: 000049
+-- Description: 请求的模型没有可用渠道时，按规则依次改用降级模型
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS model_fallback_rules (
+    id SERIAL PRIMARY KEY,
+    model_pattern VARCHAR(128) NOT NULL,
+    user_group VARCHAR(64) NOT NULL DEFAULT '',
+    fallback_models TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (model_pattern, user_group)
+);
+
+COMMENT ON TABLE model_fallback_rules IS '模型降级路由规则（请求的模型没有可用渠道时依次尝试降级模型）';
+COMMENT ON COLUMN model_fallback_rules.model_pattern IS '模型名，支持 * 通配符，如 gpt-4*';
+COMMENT ON COLUMN model_fallback_rules.user_group IS '只对该用户分组生效，空字符串表示所有分组';
+COMMENT ON COLUMN model_fallback_rules.fallback_models IS '逗号分隔的降级模型，按顺序尝试';
+
+COMMIT;
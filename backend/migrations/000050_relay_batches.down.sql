@@ -0,0 +1,9 @@
+-- 回滚批量 Chat Completion 任务
+-- Version: 000050
+
+BEGIN;
+
+DROP TABLE IF EXISTS relay_batch_items;
+DROP TABLE IF EXISTS relay_batches;
+
+COMMIT;
@@ -0,0 +1,44 @@
+-- 批量 Chat Completion 任务
+-- Version: 000050
+-- Description: 新增批量任务与任务条目表，逐条记录结果与额度预留，服务重启后继续处理未完成的条目
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS relay_batches (
+    id UUID PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_id INT NOT NULL,
+    project_id INT NOT NULL DEFAULT 0,
+    metadata_tags JSONB,
+    client_ip VARCHAR(64),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    total INT NOT NULL DEFAULT 0,
+    completed INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_relay_batches_user_time ON relay_batches(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_relay_batches_status ON relay_batches(status);
+
+CREATE TABLE IF NOT EXISTS relay_batch_items (
+    batch_id UUID NOT NULL REFERENCES relay_batches(id) ON DELETE CASCADE,
+    idx INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    request JSONB NOT NULL,
+    response JSONB,
+    error JSONB,
+    reservation_id VARCHAR(64),
+    reserved BIGINT NOT NULL DEFAULT 0,
+    completed_at TIMESTAMP,
+    PRIMARY KEY (batch_id, idx)
+);
+
+COMMENT ON TABLE relay_batches IS '批量 Chat Completion 任务，条目在后台以有限并发处理';
+COMMENT ON COLUMN relay_batches.metadata_tags IS '创建时 Token 绑定项目的元数据标签，处理每个条目时附加到请求日志';
+COMMENT ON TABLE relay_batch_items IS '批量任务条目，按输入顺序编号，每条记录响应或错误';
+COMMENT ON COLUMN relay_batch_items.reservation_id IS '创建任务时为该条目预留的额度，条目处理完成后按实际用量结算';
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- 回滚用户登录会话
+-- Version: 000051
+
+BEGIN;
+
+DROP TABLE IF EXISTS user_sessions;
+
+COMMIT;
@@ -0,0 +1,24 @@
+-- 用户登录会话
+-- Version: 000051
+-- Description: 记录每次登录的设备、IP 与刷新令牌链，刷新时更新最近使用时间，删除会话后该令牌链无法继续刷新
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id VARCHAR(64) NOT NULL,
+    device VARCHAR(128),
+    user_agent TEXT,
+    ip VARCHAR(64),
+    location VARCHAR(128),
+    last_used_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_sessions_family ON user_sessions(family_id);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions(user_id, last_used_at DESC);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_last_used ON user_sessions(last_used_at);
+
+COMMIT;
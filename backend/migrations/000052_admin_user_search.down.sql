@@ -0,0 +1,10 @@
+-- 回滚管理后台用户检索
+-- Version: 000052
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_users_role_created;
+DROP INDEX IF EXISTS idx_users_email_trgm;
+DROP INDEX IF EXISTS idx_users_username_trgm;
+
+COMMIT;
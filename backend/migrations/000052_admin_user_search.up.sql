@@ -0,0 +1,14 @@
+-- 管理后台用户检索
+-- Version: 000052
+-- Description: 管理员按用户名或邮箱子串搜索用户（ILIKE '%q%'），使用 pg_trgm 三元组索引；
+--              按角色筛选并按注册时间排序使用组合索引，状态筛选沿用 idx_users_status
+
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING GIN (username gin_trgm_ops) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON users USING GIN (email gin_trgm_ops) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_role_created ON users(role, created_at DESC) WHERE deleted_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- 回滚充值发票校验失败
+-- Version: 000053
+
+BEGIN;
+
+ALTER TABLE recharge_invoices DROP COLUMN IF EXISTS failure_reason;
+
+COMMENT ON TABLE recharge_invoices IS '预付费充值发票，状态为 pending、paid';
+
+COMMIT;
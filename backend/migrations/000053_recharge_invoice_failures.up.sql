@@ -0,0 +1,12 @@
+-- 充值发票校验失败
+-- Version: 000053
+-- Description: Stripe 实付金额或币种与发票不符时，发票标记为 failed 并记录原因，不入账、不再重试，由人工核对处理
+
+BEGIN;
+
+ALTER TABLE recharge_invoices ADD COLUMN IF NOT EXISTS failure_reason TEXT;
+
+COMMENT ON TABLE recharge_invoices IS '预付费充值发票，状态为 pending、paid、failed';
+COMMENT ON COLUMN recharge_invoices.failure_reason IS '发票标记为 failed 的原因，如实付金额与发票不符';
+
+COMMIT;